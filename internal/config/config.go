@@ -0,0 +1,334 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"k8s.io/client-go/util/homedir"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// DefaultIstioRepoURL is the upstream Istio Helm chart repository
+	DefaultIstioRepoURL = "https://istio-release.storage.googleapis.com/charts"
+	// DefaultSailRepoURL is the upstream Sail operator Helm chart repository
+	DefaultSailRepoURL = "https://istio-ecosystem.github.io/sail-operator"
+	// DefaultMetalLBRepoURL is the upstream MetalLB Helm chart repository
+	DefaultMetalLBRepoURL = "https://metallb.github.io/metallb"
+	// DefaultSPIRERepoURL is the upstream SPIFFE hardened Helm chart repository
+	DefaultSPIRERepoURL = "https://spiffe.github.io/helm-charts-hardened"
+	// DefaultAlertFailureThreshold is the number of consecutive failures before an alert fires
+	DefaultAlertFailureThreshold = 3
+	// DefaultAlertCooldown is the minimum time between repeated alerts for the same check
+	DefaultAlertCooldown = "15m"
+	// DefaultScheduleHistory is the number of outcomes kept per scheduled check
+	DefaultScheduleHistory = 50
+	// DefaultHistoryMaxEntries is the number of tool results kept in the history store
+	DefaultHistoryMaxEntries = 5000
+	// DefaultDebugImage is the image of the ephemeral debug containers and node debug pods of tools without an
+	// image of their own, unless debug.image is set
+	DefaultDebugImage = "nicolaka/netshoot:v0.13"
+	// DefaultPageSize is the number of items list-style tools return per page
+	DefaultPageSize = 100
+	// DefaultMaxResponseBytes bounds the items of one page, so a page of large items is cut short
+	DefaultMaxResponseBytes = 256 * 1024
+)
+
+// Config holds the meshpilot server configuration
+type Config struct {
+	Helm       HelmConfig       `json:"helm,omitempty"`
+	Alerts     AlertsConfig     `json:"alerts,omitempty"`
+	Schedules  []ScheduleConfig `json:"schedules,omitempty"`
+	History    HistoryConfig    `json:"history,omitempty"`
+	Scope      ScopeConfig      `json:"scope,omitempty"`
+	Guardrails GuardrailsConfig `json:"guardrails,omitempty"`
+	Debug      DebugConfig      `json:"debug,omitempty"`
+	Pagination PaginationConfig `json:"pagination,omitempty"`
+}
+
+// PaginationConfig bounds the size of list-style tool results, which are returned a page at a time
+type PaginationConfig struct {
+	PageSize         int `json:"page_size,omitempty"`          // items per page unless a call sets page_size (default: 100)
+	MaxResponseBytes int `json:"max_response_bytes,omitempty"` // encoded size of the items of one page (default: 262144)
+}
+
+// DebugConfig configures the containers the debugging tools attach to pods or run on nodes
+type DebugConfig struct {
+	Image    string `json:"image,omitempty"`    // debug image (default: per tool, env: MESHPILOT_DEBUG_IMAGE)
+	Profile  string `json:"profile,omitempty"`  // sysadmin or netadmin for ephemeral containers (default: per tool, env: MESHPILOT_DEBUG_PROFILE)
+	Registry string `json:"registry,omitempty"` // registry prefixed to debug images without one, e.g. a mirror (env: MESHPILOT_DEBUG_REGISTRY)
+}
+
+// ImageRef returns image, or the configured image when empty, else DefaultDebugImage, pulled from the
+// configured registry if the image names none
+func (d DebugConfig) ImageRef(image string) string {
+	if image == "" {
+		image = d.Image
+	}
+	if image == "" {
+		image = DefaultDebugImage
+	}
+	if d.Registry == "" {
+		return image
+	}
+	// As in Docker, the first path component is a registry host only if it looks like one
+	if first, _, found := strings.Cut(image, "/"); found && (strings.ContainsAny(first, ".:") || first == "localhost") {
+		return image
+	}
+	if !strings.Contains(image, "/") {
+		image = "library/" + image
+	}
+	return strings.TrimSuffix(d.Registry, "/") + "/" + image
+}
+
+// ScopeConfig restricts tools to a set of namespaces so a team can share a cluster safely
+type ScopeConfig struct {
+	Namespaces          []string `json:"namespaces,omitempty"`           // namespaces tools may act on (default: all, scoping disabled)
+	ProtectedNamespaces []string `json:"protected_namespaces,omitempty"` // namespaces that may be inspected but never modified (default: istio-system when scoped)
+	Contexts            []string `json:"contexts,omitempty"`             // kubeconfig contexts the per-call context parameter may select (default: none when scoped)
+}
+
+// Enabled reports whether tools are restricted to an allowlist of namespaces
+func (s ScopeConfig) Enabled() bool {
+	return len(s.Namespaces) > 0
+}
+
+// GuardrailsConfig protects the control plane from destructive tools, whether or not tools are scoped
+type GuardrailsConfig struct {
+	Disabled            bool     `json:"disabled,omitempty"`             // let destructive tools touch protected namespaces and releases
+	ProtectedNamespaces []string `json:"protected_namespaces,omitempty"` // namespaces destructive tools refuse to act on (default: istio-system, kube-system)
+	ProtectedReleases   []string `json:"protected_releases,omitempty"`   // Helm releases destructive tools refuse to remove (default: istio-base, istiod, istio-cni, ztunnel)
+	AllowForce          *bool    `json:"allow_force,omitempty"`          // let a call override the guardrails with force (default: true)
+}
+
+// ForceAllowed reports whether a call may override the guardrails with force
+func (g GuardrailsConfig) ForceAllowed() bool {
+	return g.AllowForce == nil || *g.AllowForce
+}
+
+// HistoryConfig configures the persistent store of tool results
+type HistoryConfig struct {
+	Enabled      *bool    `json:"enabled,omitempty"`       // record tool results (default: true)
+	Path         string   `json:"path,omitempty"`          // database file (default: ~/.meshpilot/history.db)
+	MaxEntries   int      `json:"max_entries,omitempty"`   // oldest results are pruned beyond this count (default: 5000)
+	ExcludeTools []string `json:"exclude_tools,omitempty"` // tools whose results are never recorded
+}
+
+// HelmConfig configures where Helm charts are pulled from
+type HelmConfig struct {
+	Istio   ChartRepository `json:"istio,omitempty"`
+	Sail    ChartRepository `json:"sail,omitempty"`
+	MetalLB ChartRepository `json:"metallb,omitempty"`
+	SPIRE   ChartRepository `json:"spire,omitempty"`
+}
+
+// ChartRepository describes a Helm chart repository or OCI registry
+type ChartRepository struct {
+	Name                  string `json:"name,omitempty"`                     // local repo name (ignored for OCI)
+	URL                   string `json:"url,omitempty"`                      // https:// repository or oci:// registry path
+	Username              string `json:"username,omitempty"`                 // repository username
+	Password              string `json:"password,omitempty"`                 // repository password (prefer password_env)
+	PasswordEnv           string `json:"password_env,omitempty"`             // environment variable holding the password
+	CAFile                string `json:"ca_file,omitempty"`                  // CA bundle for the repository
+	InsecureSkipTLSVerify bool   `json:"insecure_skip_tls_verify,omitempty"` // skip TLS verification
+}
+
+// AlertsConfig configures notifications sent when monitors or health tools detect failures
+type AlertsConfig struct {
+	FailureThreshold int             `json:"failure_threshold,omitempty"` // consecutive failures before an alert fires
+	Cooldown         string          `json:"cooldown,omitempty"`          // minimum time between repeated alerts for a check
+	HealthTools      []string        `json:"health_tools,omitempty"`      // tools whose failures raise alerts
+	SendResolved     *bool           `json:"send_resolved,omitempty"`     // notify when a failing check recovers (default: true)
+	Webhooks         []WebhookConfig `json:"webhooks,omitempty"`
+}
+
+// ScheduleConfig describes a read-only tool run periodically in server mode
+type ScheduleConfig struct {
+	Name    string                 `json:"name"`
+	Cron    string                 `json:"cron"`              // standard 5-field cron expression or @every/@hourly descriptor
+	Tool    string                 `json:"tool"`              // read-only tool to run
+	Args    map[string]interface{} `json:"args,omitempty"`    // tool arguments
+	History int                    `json:"history,omitempty"` // outcomes kept in memory (default: 50)
+}
+
+// WebhookConfig describes a notification endpoint
+type WebhookConfig struct {
+	Name    string            `json:"name,omitempty"`
+	URL     string            `json:"url,omitempty"`     // webhook URL (prefer url_env for URLs embedding tokens)
+	URLEnv  string            `json:"url_env,omitempty"` // environment variable holding the URL
+	Format  string            `json:"format,omitempty"`  // slack (default) or json
+	Headers map[string]string `json:"headers,omitempty"` // extra HTTP headers, e.g. Authorization
+}
+
+// ResolveURL returns the configured URL, preferring url_env
+func (w WebhookConfig) ResolveURL() string {
+	if w.URLEnv != "" {
+		if url := os.Getenv(w.URLEnv); url != "" {
+			return url
+		}
+	}
+	return w.URL
+}
+
+// IsOCI reports whether the repository is an OCI registry
+func (r ChartRepository) IsOCI() bool {
+	return strings.HasPrefix(r.URL, "oci://")
+}
+
+// ChartRef returns the reference used to install a chart from this repository
+func (r ChartRepository) ChartRef(chart string) string {
+	if r.IsOCI() {
+		return strings.TrimSuffix(r.URL, "/") + "/" + chart
+	}
+	return r.Name + "/" + chart
+}
+
+// Registry returns the registry host of an OCI repository
+func (r ChartRepository) Registry() string {
+	host := strings.TrimPrefix(r.URL, "oci://")
+	if idx := strings.Index(host, "/"); idx >= 0 {
+		host = host[:idx]
+	}
+	return host
+}
+
+// ResolvePassword returns the configured password, preferring password_env
+func (r ChartRepository) ResolvePassword() string {
+	if r.PasswordEnv != "" {
+		if password := os.Getenv(r.PasswordEnv); password != "" {
+			return password
+		}
+	}
+	return r.Password
+}
+
+// Default returns the configuration used when no config file is present
+func Default() *Config {
+	cfg := &Config{}
+	cfg.applyDefaults()
+	return cfg
+}
+
+// DefaultPath returns the config file location, honoring MESHPILOT_CONFIG
+func DefaultPath() string {
+	if path := os.Getenv("MESHPILOT_CONFIG"); path != "" {
+		return path
+	}
+	if home := homedir.HomeDir(); home != "" {
+		return filepath.Join(home, ".meshpilot", "config.yaml")
+	}
+	return ""
+}
+
+// Load reads the config file at path; a missing file yields the default configuration
+func Load(path string) (*Config, error) {
+	cfg := &Config{}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		if err == nil {
+			if err := yaml.Unmarshal(data, cfg); err != nil {
+				return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+			}
+		}
+	}
+
+	cfg.applyDefaults()
+	return cfg, nil
+}
+
+// applyDefaults fills in unset values
+func (c *Config) applyDefaults() {
+	if c.Helm.Istio.URL == "" {
+		c.Helm.Istio.URL = DefaultIstioRepoURL
+	}
+	if c.Helm.Istio.Name == "" {
+		c.Helm.Istio.Name = "istio"
+	}
+	if c.Helm.Sail.URL == "" {
+		c.Helm.Sail.URL = DefaultSailRepoURL
+	}
+	if c.Helm.Sail.Name == "" {
+		c.Helm.Sail.Name = "sail-operator"
+	}
+	if c.Helm.MetalLB.URL == "" {
+		c.Helm.MetalLB.URL = DefaultMetalLBRepoURL
+	}
+	if c.Helm.MetalLB.Name == "" {
+		c.Helm.MetalLB.Name = "metallb"
+	}
+	if c.Helm.SPIRE.URL == "" {
+		c.Helm.SPIRE.URL = DefaultSPIRERepoURL
+	}
+	if c.Helm.SPIRE.Name == "" {
+		c.Helm.SPIRE.Name = "spiffe"
+	}
+	if c.Alerts.FailureThreshold == 0 {
+		c.Alerts.FailureThreshold = DefaultAlertFailureThreshold
+	}
+	if c.Alerts.Cooldown == "" {
+		c.Alerts.Cooldown = DefaultAlertCooldown
+	}
+	if c.Alerts.SendResolved == nil {
+		sendResolved := true
+		c.Alerts.SendResolved = &sendResolved
+	}
+	if len(c.Alerts.HealthTools) == 0 {
+		c.Alerts.HealthTools = []string{"check_istio_status", "check_sail_status", "test_connectivity", "test_sleep_to_httpbin", "test_ingress_connectivity"}
+	}
+	if c.History.Enabled == nil {
+		enabled := true
+		c.History.Enabled = &enabled
+	}
+	if c.History.Path == "" {
+		if home := homedir.HomeDir(); home != "" {
+			c.History.Path = filepath.Join(home, ".meshpilot", "history.db")
+		}
+	}
+	if c.History.MaxEntries == 0 {
+		c.History.MaxEntries = DefaultHistoryMaxEntries
+	}
+	// The environment overrides the config file, so a deployment can point at a mirror without editing it
+	if image := os.Getenv("MESHPILOT_DEBUG_IMAGE"); image != "" {
+		c.Debug.Image = image
+	}
+	if profile := os.Getenv("MESHPILOT_DEBUG_PROFILE"); profile != "" {
+		c.Debug.Profile = profile
+	}
+	if registry := os.Getenv("MESHPILOT_DEBUG_REGISTRY"); registry != "" {
+		c.Debug.Registry = registry
+	}
+	if c.Pagination.PageSize <= 0 {
+		c.Pagination.PageSize = DefaultPageSize
+	}
+	if c.Pagination.MaxResponseBytes <= 0 {
+		c.Pagination.MaxResponseBytes = DefaultMaxResponseBytes
+	}
+	if c.Scope.Enabled() && len(c.Scope.ProtectedNamespaces) == 0 {
+		c.Scope.ProtectedNamespaces = []string{"istio-system"}
+	}
+	if len(c.Guardrails.ProtectedNamespaces) == 0 {
+		c.Guardrails.ProtectedNamespaces = []string{"istio-system", "kube-system"}
+	}
+	if len(c.Guardrails.ProtectedReleases) == 0 {
+		c.Guardrails.ProtectedReleases = []string{"istio-base", "istiod", "istio-cni", "ztunnel"}
+	}
+	if c.Guardrails.AllowForce == nil {
+		allowForce := true
+		c.Guardrails.AllowForce = &allowForce
+	}
+	for i := range c.Schedules {
+		if c.Schedules[i].History == 0 {
+			c.Schedules[i].History = DefaultScheduleHistory
+		}
+	}
+	for i := range c.Alerts.Webhooks {
+		if c.Alerts.Webhooks[i].Format == "" {
+			c.Alerts.Webhooks[i].Format = "slack"
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package k8s
+
+import "sync"
+
+// ClientCache keeps one client per kubeconfig context so a single process can work with several clusters
+type ClientCache struct {
+	mu      sync.Mutex
+	clients map[string]*Client
+}
+
+// NewClientCache creates an empty client cache
+func NewClientCache() *ClientCache {
+	return &ClientCache{clients: make(map[string]*Client)}
+}
+
+// Get returns the client for a kubeconfig context, creating it on first use
+func (c *ClientCache) Get(contextName string) (*Client, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if client, ok := c.clients[contextName]; ok {
+		return client, nil
+	}
+	client, err := NewClientForContext(contextName)
+	if err != nil {
+		return nil, err
+	}
+	c.clients[contextName] = client
+	return client, nil
+}
+
+// Reset drops every cached client, so clients are rebuilt from a kubeconfig that changed at runtime
+func (c *ClientCache) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.clients = make(map[string]*Client)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"k8s.io/client-go/dynamic"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/clientcmd"
@@ -19,8 +20,12 @@ import (
 type Client struct {
 	Kubernetes kubernetes.Interface
 	Istio      istioclient.Interface
+	Dynamic    dynamic.Interface
 	Config     *rest.Config
 	Context    context.Context
+
+	// contextName is the kubeconfig context of a client created for a named context
+	contextName string
 }
 
 // NewClient creates a new Kubernetes client
@@ -29,7 +34,27 @@ func NewClient() (*Client, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to get kubeconfig: %w", err)
 	}
+	return newClientForConfig(config)
+}
 
+// NewClientForContext creates a client for a named kubeconfig context without changing the current context
+func NewClientForContext(contextName string) (*Client, error) {
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	overrides := &clientcmd.ConfigOverrides{CurrentContext: contextName}
+	config, err := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig context %s: %w", contextName, err)
+	}
+	client, err := newClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client.contextName = contextName
+	return client, nil
+}
+
+// newClientForConfig creates the Kubernetes, Istio and dynamic clients for a REST config
+func newClientForConfig(config *rest.Config) (*Client, error) {
 	// Create Kubernetes client
 	kubeClient, err := kubernetes.NewForConfig(config)
 	if err != nil {
@@ -42,9 +67,16 @@ func NewClient() (*Client, error) {
 		return nil, fmt.Errorf("failed to create Istio client: %w", err)
 	}
 
+	// Create dynamic client for CRDs without generated clients
+	dynamicClient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create dynamic client: %w", err)
+	}
+
 	return &Client{
 		Kubernetes: kubeClient,
 		Istio:      istioClient,
+		Dynamic:    dynamicClient,
 		Config:     config,
 		Context:    context.Background(),
 	}, nil
@@ -52,10 +84,12 @@ func NewClient() (*Client, error) {
 
 // getKubeConfig returns the Kubernetes configuration
 func getKubeConfig() (*rest.Config, error) {
-	// Try in-cluster config first
-	if config, err := rest.InClusterConfig(); err == nil {
-		logrus.Info("Using in-cluster Kubernetes configuration")
-		return config, nil
+	// Try in-cluster config first, unless a kubeconfig was given explicitly
+	if os.Getenv("KUBECONFIG") == "" {
+		if config, err := rest.InClusterConfig(); err == nil {
+			logrus.Info("Using in-cluster Kubernetes configuration")
+			return config, nil
+		}
 	}
 
 	// Fall back to kubeconfig file
@@ -78,8 +112,12 @@ func getKubeConfig() (*rest.Config, error) {
 	return config, nil
 }
 
-// GetCurrentContext returns the current Kubernetes context
+// GetCurrentContext returns the Kubernetes context the client talks to: its named context, or the
+// kubeconfig's current context
 func (c *Client) GetCurrentContext() (string, error) {
+	if c.contextName != "" {
+		return c.contextName, nil
+	}
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	configOverrides := &clientcmd.ConfigOverrides{}
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
@@ -0,0 +1,189 @@
+package mcp
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+)
+
+var (
+	rawMessageType    = reflect.TypeOf(json.RawMessage(nil))
+	timeType          = reflect.TypeOf(time.Time{})
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// schemaDeriver derives JSON schemas from Go types the way encoding/json encodes and decodes them
+type schemaDeriver struct {
+	output   bool                  // describe encoded values, where nil slices, maps and pointers are null
+	visiting map[reflect.Type]bool // structs being derived, so recursive types stop at a plain object
+}
+
+// paramsSchema derives an object schema from a tool's argument struct the way encoding/json decodes it:
+// every JSON field is a property, embedded structs contribute their fields, and fields without omitempty
+// are required
+func paramsSchema(t reflect.Type) *jsonschema.Schema {
+	d := &schemaDeriver{visiting: map[reflect.Type]bool{}}
+	return d.structSchema(t)
+}
+
+// resultSchema derives the schema of the structured content of a tool from the type its result is the JSON
+// encoding of; fields without omitempty are always present, so they are required
+func resultSchema(t reflect.Type) *jsonschema.Schema {
+	d := &schemaDeriver{output: true, visiting: map[reflect.Type]bool{}}
+	return d.structSchema(t)
+}
+
+// structSchema derives the object schema of a struct
+func (d *schemaDeriver) structSchema(t reflect.Type) *jsonschema.Schema {
+	if d.visiting[t] {
+		return &jsonschema.Schema{Type: "object"}
+	}
+	schema := &jsonschema.Schema{
+		Type:       "object",
+		Properties: map[string]*jsonschema.Schema{},
+	}
+	d.visiting[t] = true
+	d.addStructFields(schema, t, true)
+	delete(d.visiting, t)
+	return schema
+}
+
+// addStructFields adds the JSON fields of a struct to an object schema; fields of an embedded struct pointer
+// are left out of the encoding when it is nil, so they are not required in output schemas
+func (d *schemaDeriver) addStructFields(schema *jsonschema.Schema, t reflect.Type, required bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded, embeddedRequired := field.Type, required
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+				embeddedRequired = required && !d.output
+			}
+			if embedded.Kind() == reflect.Struct {
+				d.addStructFields(schema, embedded, embeddedRequired)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = d.typeSchema(field.Type)
+		if required && !containsOption(options, "omitempty") {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// typeSchema maps a Go type to the JSON schema of its values
+func (d *schemaDeriver) typeSchema(t reflect.Type) *jsonschema.Schema {
+	switch {
+	case t.Kind() == reflect.Pointer:
+		return d.nullable(d.typeSchema(t.Elem()))
+	case t == rawMessageType:
+		return &jsonschema.Schema{}
+	case t == timeType:
+		return &jsonschema.Schema{Type: "string"}
+	case t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType):
+		// A custom encoding can produce any JSON value
+		return &jsonschema.Schema{}
+	case t.Implements(textMarshalerType):
+		return &jsonschema.Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return &jsonschema.Schema{Type: "string"}
+	case reflect.Bool:
+		return &jsonschema.Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonschema.Schema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonschema.Schema{Type: "number"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// Byte slices are base64 strings
+			return d.nullable(&jsonschema.Schema{Type: "string"})
+		}
+		return d.nullable(&jsonschema.Schema{Type: "array", Items: d.typeSchema(t.Elem())})
+	case reflect.Array:
+		return &jsonschema.Schema{Type: "array", Items: d.typeSchema(t.Elem())}
+	case reflect.Map:
+		schema := &jsonschema.Schema{Type: "object"}
+		if t.Elem().Kind() != reflect.Interface {
+			schema.AdditionalProperties = d.typeSchema(t.Elem())
+		}
+		return d.nullable(schema)
+	case reflect.Struct:
+		return d.structSchema(t)
+	default:
+		// interface{} holds any JSON value
+		return &jsonschema.Schema{}
+	}
+}
+
+// nullable lets an output schema accept null, which encoding/json writes for nil slices, maps and pointers;
+// input schemas are left as they are, since callers have no reason to send null
+func (d *schemaDeriver) nullable(schema *jsonschema.Schema) *jsonschema.Schema {
+	if !d.output || schema.Type == "" {
+		return schema
+	}
+	schema.Types = []string{schema.Type, "null"}
+	schema.Type = ""
+	return schema
+}
+
+// describeParams copies the descriptions, defaults, enums and bounds of a hand-written schema onto the
+// schema derived from the handler's struct; hand-written properties the handler does not decode are dropped
+func describeParams(derived, described *jsonschema.Schema) *jsonschema.Schema {
+	if described == nil {
+		return derived
+	}
+	for name, property := range derived.Properties {
+		if doc, ok := described.Properties[name]; ok {
+			describeProperty(property, doc)
+		}
+	}
+	return derived
+}
+
+// describeProperty copies the documentation of a hand-written property, and of its items and nested
+// properties, onto a derived one; the derived type and required fields are kept
+func describeProperty(property, doc *jsonschema.Schema) {
+	property.Description = doc.Description
+	property.Default = doc.Default
+	property.Enum = doc.Enum
+	property.Minimum = doc.Minimum
+	property.Maximum = doc.Maximum
+	if property.Items != nil && doc.Items != nil {
+		describeProperty(property.Items, doc.Items)
+	}
+	for name, nested := range property.Properties {
+		if nestedDoc, ok := doc.Properties[name]; ok {
+			describeProperty(nested, nestedDoc)
+		}
+	}
+}
+
+// containsOption reports whether a comma-separated struct tag option list holds an option
+func containsOption(options, option string) bool {
+	for _, candidate := range strings.Split(options, ",") {
+		if candidate == option {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+package mcp
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"meshpilot/internal/tools"
+)
+
+// TestResultSchemasValidateEncodedResults encodes every declared result type, both empty and with every
+// field filled in, and checks the encoding validates against the output schema derived from the type
+func TestResultSchemasValidateEncodedResults(t *testing.T) {
+	var names []string
+	for name := range GetToolDefinitions() {
+		if tools.ResultType(name) != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		t.Fatal("no tool declares a result type")
+	}
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			resultType := tools.ResultType(name)
+			resolved, err := resultSchema(resultType).Resolve(nil)
+			if err != nil {
+				t.Fatalf("resolving the output schema: %v", err)
+			}
+
+			values := map[string]reflect.Value{
+				"empty":  reflect.New(resultType).Elem(),
+				"filled": filledValue(resultType, map[reflect.Type]bool{}),
+			}
+			for kind, value := range values {
+				data, err := json.Marshal(value.Interface())
+				if err != nil {
+					t.Fatalf("encoding the %s result: %v", kind, err)
+				}
+				var decoded any
+				if err := json.Unmarshal(data, &decoded); err != nil {
+					t.Fatalf("decoding the %s result: %v", kind, err)
+				}
+				if err := resolved.Validate(decoded); err != nil {
+					t.Errorf("%s result %s does not validate: %v", kind, data, err)
+				}
+			}
+		})
+	}
+}
+
+// TestStructuredContentOnlyForDeclaredResults checks that structured content is only attached for tools whose
+// result type, and so output schema, is declared
+func TestStructuredContentOnlyForDeclaredResults(t *testing.T) {
+	object := &tools.CallToolResult{
+		Content: []interface{}{tools.TextContent{Type: "text", Text: `{"namespace": "default"}`}},
+	}
+	tests := []struct {
+		name     string
+		toolName string
+		result   *tools.CallToolResult
+		want     bool
+	}{
+		{name: "declared result", toolName: "get_cluster_info", result: object, want: true},
+		{name: "undeclared result", toolName: "get_network_policies", result: object, want: false},
+		{name: "error result", toolName: "get_cluster_info", result: &tools.CallToolResult{IsError: true, Content: object.Content}, want: false},
+		{name: "plain text", toolName: "get_cluster_info", result: &tools.CallToolResult{
+			Content: []interface{}{tools.TextContent{Type: "text", Text: "no pods found"}},
+		}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := structuredContent(tt.toolName, tt.result) != nil; got != tt.want {
+				t.Errorf("structuredContent(%s) attached = %v, want %v", tt.toolName, got, tt.want)
+			}
+		})
+	}
+}
+
+// filledValue builds a value of a type with every settable field, slice, map and pointer filled in, so the
+// schemas of nested items are exercised too; recursive types stop at their zero value
+func filledValue(t reflect.Type, visiting map[reflect.Type]bool) reflect.Value {
+	value := reflect.New(t).Elem()
+	switch {
+	case t == timeType:
+		value.Set(reflect.ValueOf(time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)))
+		return value
+	case t == rawMessageType:
+		value.Set(reflect.ValueOf(json.RawMessage(`{"raw": true}`)))
+		return value
+	case t.Kind() != reflect.Pointer && (t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) ||
+		t.Implements(textMarshalerType)):
+		// Custom encodings, such as net.IP, may not accept arbitrary field values; the zero value encodes
+		return value
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		value.SetString("value")
+	case reflect.Bool:
+		value.SetBool(true)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		value.SetInt(1)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		value.SetUint(1)
+	case reflect.Float32, reflect.Float64:
+		value.SetFloat(1.5)
+	case reflect.Pointer:
+		if visiting[t.Elem()] {
+			return value
+		}
+		value.Set(filledValue(t.Elem(), visiting).Addr())
+	case reflect.Slice:
+		if visiting[t.Elem()] {
+			return value
+		}
+		value.Set(reflect.Append(reflect.MakeSlice(t, 0, 1), filledValue(t.Elem(), visiting)))
+	case reflect.Array:
+		for i := 0; i < t.Len(); i++ {
+			value.Index(i).Set(filledValue(t.Elem(), visiting))
+		}
+	case reflect.Map:
+		if t.Key().Kind() != reflect.String || visiting[t.Elem()] {
+			return value
+		}
+		value.Set(reflect.MakeMap(t))
+		value.SetMapIndex(reflect.ValueOf("key").Convert(t.Key()), filledValue(t.Elem(), visiting))
+	case reflect.Interface:
+		if t.NumMethod() == 0 {
+			value.Set(reflect.ValueOf(map[string]any{"any": []any{"value", 1.5, true}}))
+		}
+	case reflect.Struct:
+		if visiting[t] {
+			return value
+		}
+		visiting[t] = true
+		for i := 0; i < t.NumField(); i++ {
+			if t.Field(i).IsExported() {
+				value.Field(i).Set(filledValue(t.Field(i).Type, visiting))
+			}
+		}
+		delete(visiting, t)
+	}
+	return value
+}
@@ -0,0 +1,116 @@
+package mcp
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
+)
+
+// httpShutdownTimeout bounds how long in-flight tool calls may finish after a shutdown signal
+const httpShutdownTimeout = 30 * time.Second
+
+// ListenAndServe serves MCP over HTTP until ctx is cancelled: the streamable HTTP transport at /mcp,
+// the older SSE transport at /sse for clients that predate it, and a /healthz probe for Kubernetes.
+// Every client gets its own MCP session (keyed by the Mcp-Session-Id header for streamable HTTP) on the
+// one shared server, so responses go only to the caller, but all sessions run tools with the same
+// Kubernetes client, configuration, monitors and history. When token is set, /mcp and /sse require it
+// as a bearer token.
+func (s *Server) ListenAndServe(ctx context.Context, addr, token string) error {
+	getServer := func(*http.Request) *mcp.Server { return s.mcpServer }
+
+	// Long-lived event streams would hold a graceful shutdown open until it times out, so they are
+	// closed as soon as shutdown starts while POSTed tool calls get to finish
+	streamsCtx, closeStreams := context.WithCancel(context.Background())
+	defer closeStreams()
+
+	mux := http.NewServeMux()
+	mux.Handle("/mcp", requireToken(token, closeOnShutdown(streamsCtx, mcp.NewStreamableHTTPHandler(getServer, nil))))
+	mux.Handle("/sse", requireToken(token, closeOnShutdown(streamsCtx, mcp.NewSSEHandler(getServer))))
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	httpServer.RegisterOnShutdown(closeStreams)
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+	logrus.Infof("MCP server listening on %s (streamable HTTP at /mcp, SSE at /sse)", listener.Addr())
+	if token == "" && !isLoopback(listener.Addr()) {
+		logrus.Warnf("MCP server on %s is reachable from the network without authentication and exposes tools that modify the cluster; set MESHPILOT_HTTP_TOKEN to require a bearer token", listener.Addr())
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- httpServer.Serve(listener)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+	}
+
+	logrus.Infof("Shutting down MCP HTTP server, waiting up to %s for in-flight requests", httpShutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		httpServer.Close()
+		return fmt.Errorf("graceful shutdown did not complete: %w", err)
+	}
+	if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// closeOnShutdown ends the GET event streams of a handler once streamsCtx is cancelled
+func closeOnShutdown(streamsCtx context.Context, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			ctx, cancel := context.WithCancel(r.Context())
+			defer cancel()
+			stop := context.AfterFunc(streamsCtx, cancel)
+			defer stop()
+			r = r.WithContext(ctx)
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// requireToken rejects requests without the bearer token; an empty token leaves the handler open
+func requireToken(token string, handler http.Handler) http.Handler {
+	if token == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		presented, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="meshpilot"`)
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// isLoopback reports whether a listener only accepts connections from the local host
+func isLoopback(addr net.Addr) bool {
+	tcp, ok := addr.(*net.TCPAddr)
+	return ok && tcp.IP.IsLoopback()
+}
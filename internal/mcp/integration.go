@@ -4,15 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"meshpilot/internal/tools"
 
 	"github.com/modelcontextprotocol/go-sdk/mcp"
+	"github.com/sirupsen/logrus"
 )
 
 // ToolWrapper wraps our existing tool manager to work with the MCP SDK
 type ToolWrapper struct {
 	manager *tools.Manager
+	server  *mcp.Server
 }
 
 // NewToolWrapper creates a new tool wrapper
@@ -24,7 +27,21 @@ func NewToolWrapper(manager *tools.Manager) *ToolWrapper {
 
 // WrapTool creates an MCP tool handler that wraps our existing tool functions
 func (tw *ToolWrapper) WrapTool(toolName string) mcp.ToolHandler {
-	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[map[string]any]) (*mcp.CallToolResultFor[any], error) {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[map[string]any]) (toolResult *mcp.CallToolResultFor[any], toolErr error) {
+		// A panicking tool fails its own call rather than the server and every session connected to it
+		defer func() {
+			if r := recover(); r != nil {
+				logrus.Errorf("Tool %s panicked: %v", toolName, r)
+				toolResult = &mcp.CallToolResultFor[any]{
+					Content: []mcp.Content{
+						&mcp.TextContent{Text: fmt.Sprintf("Tool execution failed: %v", r)},
+					},
+					IsError: true,
+				}
+				toolErr = nil
+			}
+		}()
+
 		// Convert arguments to JSON
 		argsJSON, err := json.Marshal(params.Arguments)
 		if err != nil {
@@ -36,8 +53,8 @@ func (tw *ToolWrapper) WrapTool(toolName string) mcp.ToolHandler {
 			}, nil
 		}
 
-		// Call our existing tool
-		result, err := tw.manager.ExecuteTool(toolName, argsJSON)
+		// Call our existing tool; ctx is cancelled when the client cancels the request or disconnects
+		result, err := tw.manager.ExecuteTool(withProgressNotifications(ctx, ss, toolName, params), toolName, argsJSON)
 		if err != nil {
 			return &mcp.CallToolResultFor[any]{
 				Content: []mcp.Content{
@@ -47,6 +64,11 @@ func (tw *ToolWrapper) WrapTool(toolName string) mcp.ToolHandler {
 			}, nil
 		}
 
+		// A fresh permission probe may enable or disable tools
+		if toolName == "check_tool_permissions" && !result.IsError {
+			tw.registerTools()
+		}
+
 		// Convert our result to MCP format
 		mcpResult := &mcp.CallToolResultFor[any]{
 			IsError: result.IsError,
@@ -58,6 +80,14 @@ func (tw *ToolWrapper) WrapTool(toolName string) mcp.ToolHandler {
 				mcpResult.Content = append(mcpResult.Content, &mcp.TextContent{
 					Text: textContent.Text,
 				})
+			} else if resourceContent, ok := content.(tools.ResourceContent); ok {
+				mcpResult.Content = append(mcpResult.Content, &mcp.EmbeddedResource{
+					Resource: &mcp.ResourceContents{
+						URI:      resourceContent.URI,
+						MIMEType: resourceContent.MIMEType,
+						Blob:     resourceContent.Blob,
+					},
+				})
 			} else {
 				// Fallback: convert to string
 				contentStr := ""
@@ -77,16 +107,106 @@ func (tw *ToolWrapper) WrapTool(toolName string) mcp.ToolHandler {
 			}
 		}
 
+		// The text stays for clients that predate structured content
+		if structured := structuredContent(toolName, result); structured != nil {
+			mcpResult.StructuredContent = structured
+		}
+
 		return mcpResult, nil
 	}
 }
 
-// RegisterAllTools registers all available tools with the MCP server using proper schemas
+// structuredContent returns the JSON object a successful tool result's text encodes, so clients can read the
+// result without parsing the text. Only tools with a declared result type have an output schema the object
+// conforms to, so other tools, plain text results and results that are not an object have none
+func structuredContent(toolName string, result *tools.CallToolResult) json.RawMessage {
+	if result.IsError || tools.ResultType(toolName) == nil {
+		return nil
+	}
+	for _, content := range result.Content {
+		textContent, ok := content.(tools.TextContent)
+		if !ok {
+			continue
+		}
+		text := strings.TrimSpace(textContent.Text)
+		if strings.HasPrefix(text, "{") && json.Valid([]byte(text)) {
+			return json.RawMessage(text)
+		}
+		return nil
+	}
+	return nil
+}
+
+// withProgressNotifications forwards a tool's incremental output to the client: as progress notifications when the
+// request carries a progress token, otherwise as log messages, which clients receive once they set a log level
+func withProgressNotifications(ctx context.Context, ss *mcp.ServerSession, toolName string, params *mcp.CallToolParamsFor[map[string]any]) context.Context {
+	token := params.GetProgressToken()
+	var sent float64
+	return tools.WithProgress(ctx, func(message string) {
+		var err error
+		if token != nil {
+			sent++
+			err = ss.NotifyProgress(ctx, &mcp.ProgressNotificationParams{
+				ProgressToken: token,
+				Progress:      sent,
+				Message:       message,
+			})
+		} else {
+			err = ss.Log(ctx, &mcp.LoggingMessageParams{
+				Level:  "info",
+				Logger: toolName,
+				Data:   message,
+			})
+		}
+		if err != nil {
+			logrus.Debugf("Failed to send %s output to the client: %v", toolName, err)
+		}
+	})
+}
+
+// RegisterAllTools registers all available tools with the MCP server using proper schemas; none are
+// disabled until ProbePermissions runs
 func (tw *ToolWrapper) RegisterAllTools(server *mcp.Server) {
+	tw.server = server
+	tw.registerTools()
+}
+
+// ProbePermissions probes the credentials and re-registers the tools that would fail with Forbidden as disabled
+func (tw *ToolWrapper) ProbePermissions(ctx context.Context) {
+	if _, err := tw.manager.ProbeToolPermissions(ctx); err != nil {
+		logrus.Warnf("Registering all tools without permission filtering: %v", err)
+		return
+	}
+	tw.registerTools()
+}
+
+// registerTools (re)registers every tool, marking tools whose permissions are missing as disabled
+func (tw *ToolWrapper) registerTools() {
 	toolDefs := GetToolDefinitions()
 
 	// Register all tools with their proper schemas
 	for toolName, toolDef := range toolDefs {
-		server.AddTool(toolDef, tw.WrapTool(toolName))
+		missing := tw.manager.MissingPermissions(toolName)
+		if len(missing) == 0 {
+			tw.server.AddTool(toolDef, tw.WrapTool(toolName))
+			continue
+		}
+
+		disabled := *toolDef
+		disabled.Description = fmt.Sprintf("[DISABLED: missing permissions: %s] %s", strings.Join(missing, ", "), toolDef.Description)
+		tw.server.AddTool(&disabled, tw.disabledTool(toolName, missing))
+	}
+}
+
+// disabledTool returns a handler that explains why a tool can't run instead of calling the cluster
+func (tw *ToolWrapper) disabledTool(toolName string, missing []string) mcp.ToolHandler {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.CallToolParamsFor[map[string]any]) (*mcp.CallToolResultFor[any], error) {
+		return &mcp.CallToolResultFor[any]{
+			Content: []mcp.Content{
+				&mcp.TextContent{Text: fmt.Sprintf("Tool %s is disabled because the current credentials lack: %s. Grant the permissions and run check_tool_permissions to re-enable it.",
+					toolName, strings.Join(missing, ", "))},
+			},
+			IsError: true,
+		}, nil
 	}
 }
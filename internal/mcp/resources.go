@@ -0,0 +1,83 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"meshpilot/internal/tools"
+
+	"github.com/modelcontextprotocol/go-sdk/mcp"
+)
+
+// resourceProvider reads the live cluster state behind an MCP resource
+type resourceProvider struct {
+	resource *mcp.Resource
+	read     func(ctx context.Context) (interface{}, error)
+}
+
+// resourceProviders lists the resources MCP clients can read without invoking tools
+func resourceProviders(manager *tools.Manager) []resourceProvider {
+	return []resourceProvider{
+		{
+			resource: &mcp.Resource{
+				URI:         "istio://status",
+				Name:        "istio-status",
+				Title:       "Istio control plane status",
+				Description: "Whether Istio is installed in istio-system, its version and the readiness of each control plane component",
+				MIMEType:    "application/json",
+			},
+			read: func(ctx context.Context) (interface{}, error) { return manager.IstioStatusResource(ctx) },
+		},
+		{
+			resource: &mcp.Resource{
+				URI:         "cluster://namespaces",
+				Name:        "cluster-namespaces",
+				Title:       "Namespaces",
+				Description: "Namespaces with their injection labels and dataplane mode (sidecar, ambient, mixed or none), limited to the configured namespace scope",
+				MIMEType:    "application/json",
+			},
+			read: func(ctx context.Context) (interface{}, error) { return manager.NamespacesResource(ctx) },
+		},
+		{
+			resource: &mcp.Resource{
+				URI:         "mesh://proxies",
+				Name:        "mesh-proxies",
+				Title:       "Mesh proxies",
+				Description: "Running sidecar, gateway, waypoint and ztunnel proxies with their owner, revision, image and readiness, and counts per kind and version",
+				MIMEType:    "application/json",
+			},
+			read: func(ctx context.Context) (interface{}, error) { return manager.MeshProxiesResource(ctx) },
+		},
+	}
+}
+
+// RegisterResources registers the cluster state resources with the MCP server
+func RegisterResources(server *mcp.Server, manager *tools.Manager) {
+	for _, provider := range resourceProviders(manager) {
+		server.AddResource(provider.resource, provider.handler())
+	}
+}
+
+// handler reads the provider's state on every request so clients always see the live cluster
+func (p resourceProvider) handler() mcp.ResourceHandler {
+	return func(ctx context.Context, ss *mcp.ServerSession, params *mcp.ReadResourceParams) (*mcp.ReadResourceResult, error) {
+		state, err := p.read(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", p.resource.URI, err)
+		}
+		data, err := json.MarshalIndent(state, "", "  ")
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode %s: %w", p.resource.URI, err)
+		}
+		return &mcp.ReadResourceResult{
+			Contents: []*mcp.ResourceContents{
+				{
+					URI:      p.resource.URI,
+					MIMEType: p.resource.MIMEType,
+					Text:     string(data),
+				},
+			},
+		}, nil
+	}
+}
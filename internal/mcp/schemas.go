@@ -3,6 +3,8 @@ package mcp
 import (
 	"encoding/json"
 
+	"meshpilot/internal/tools"
+
 	"github.com/modelcontextprotocol/go-sdk/jsonschema"
 	"github.com/modelcontextprotocol/go-sdk/mcp"
 )
@@ -37,6 +39,64 @@ func createObjectSchema(properties map[string]*jsonschema.Schema, required []str
 
 // GetToolDefinitions returns tool definitions with proper schemas
 func GetToolDefinitions() map[string]*mcp.Tool {
+	defs := describedTools()
+
+	// Properties, types and required arguments come from the struct each handler decodes its arguments into,
+	// so the schemas can't drift from the handlers; describedTools only documents them
+	for name, def := range defs {
+		if params := tools.ParamsType(name); params != nil {
+			def.InputSchema = describeParams(paramsSchema(params), def.InputSchema)
+		}
+	}
+
+	// Tools whose result is the JSON encoding of a Go type declare its schema for their structured content
+	for name, def := range defs {
+		if result := tools.ResultType(name); result != nil {
+			def.OutputSchema = resultSchema(result)
+		}
+	}
+
+	// Destructive tools can override the guardrails
+	for name, def := range defs {
+		if tools.Guarded(name) {
+			def.InputSchema.Properties["force"] = &jsonschema.Schema{
+				Type:        "boolean",
+				Description: "Override the guardrails that protect namespaces such as istio-system, Helm releases such as istiod, the Istio CRDs and dev clusters (the server must allow force)",
+				Default:     jsonBool(false),
+			}
+		}
+	}
+
+	// Every cluster tool can target another kubeconfig context for a single call
+	for name, def := range defs {
+		if tools.AcceptsContext(name) {
+			def.InputSchema.Properties["context"] = &jsonschema.Schema{
+				Type:        "string",
+				Description: "Kubeconfig context to run against for this call, without switching the current context (default: current context)",
+			}
+		}
+	}
+
+	// List-style tools return a page of items and the cursor of the next page
+	for name, def := range defs {
+		if tools.Paginated(name) {
+			def.InputSchema.Properties["cursor"] = &jsonschema.Schema{
+				Type:        "string",
+				Description: "next_cursor of the previous page; pass it with the same other arguments to get the next page",
+			}
+			def.InputSchema.Properties["page_size"] = &jsonschema.Schema{
+				Type:        "integer",
+				Description: "Items (or log lines) per page; a page is also cut short at the configured response size (default: pagination.page_size, 100)",
+				Minimum:     float64Ptr(1),
+			}
+		}
+	}
+	return defs
+}
+
+// describedTools returns the hand-written tool definitions, whose input schemas document the arguments the
+// handlers decode
+func describedTools() map[string]*mcp.Tool {
 	return map[string]*mcp.Tool{
 		"list_contexts": {
 			Name:        "list_contexts",
@@ -53,372 +113,4294 @@ func GetToolDefinitions() map[string]*mcp.Tool {
 				},
 			}, []string{"context"}),
 		},
-		"get_cluster_info": {
-			Name:        "get_cluster_info",
-			Description: "Get information about the current cluster",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{}, nil),
-		},
-		"install_istio": {
-			Name:        "install_istio",
-			Description: "Install Istio service mesh on the cluster using Helm",
+		"configure_kubeconfig": {
+			Name:        "configure_kubeconfig",
+			Description: "Point MeshPilot at a kubeconfig file or inline kubeconfig at runtime, for a server started without cluster access or with rotated credentials, and report whether the selected cluster is reachable",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"version": {
+				"path": {
 					Type:        "string",
-					Description: "Istio version to install (default: latest)",
-					Default:     jsonString("latest"),
+					Description: "Path of an existing kubeconfig file on the MeshPilot host",
 				},
-				"values": {
+				"content": {
 					Type:        "string",
-					Description: "Custom Helm values in YAML format",
+					Description: "Kubeconfig YAML, saved to ~/.meshpilot/kubeconfig with mode 0600; use instead of path",
 				},
-				"install_gateway": {
+				"context": {
+					Type:        "string",
+					Description: "Context to select (default: the kubeconfig's current context)",
+				},
+			}, nil),
+		},
+		"get_cluster_info": {
+			Name:        "get_cluster_info",
+			Description: "Get the cluster version, nodes and namespaces, and detect the platform (eks, gke, aks, openshift, kind, ...), node OS/arch, CNI, ingress and gateway classes, metrics-server and LoadBalancer implementation to choose install options",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"include_namespaces": {
 					Type:        "boolean",
-					Description: "Whether to install Istio gateway (default: false)",
-					Default:     jsonBool(false),
+					Description: "List the namespaces; false leaves them out of the result (default: true)",
+					Default:     jsonBool(true),
 				},
-				"gateway_namespace": {
+				"namespace_selector": {
 					Type:        "string",
-					Description: "Namespace for gateway installation (default: istio-ingress)",
-					Default:     jsonString("istio-ingress"),
+					Description: "Only list namespaces matching this label selector, e.g. istio-injection=enabled",
 				},
-				"install_cni": {
+				"namespace_details": {
 					Type:        "boolean",
-					Description: "Whether to install Istio CNI (default: false)",
+					Description: "List each namespace with its pod counts and sidecar injection or ambient enrollment instead of its name only (default: false)",
 					Default:     jsonBool(false),
 				},
-				"cni_values": {
+			}, nil),
+		},
+		"validate_access": {
+			Name:        "validate_access",
+			Description: "Check per kubeconfig context that the config parses, the API server is reachable, the token or client certificate has not expired, and basic list permissions are granted",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"context": {
 					Type:        "string",
-					Description: "Custom CNI Helm values in YAML format",
+					Description: "Only check this context (default: all contexts)",
 				},
 				"timeout": {
-					Type:        "string",
-					Description: "Helm timeout for installation (default: 10m)",
-					Default:     jsonString("10m"),
-				},
-				"wait": {
-					Type:        "boolean",
-					Description: "Wait for installation to complete (default: true)",
-					Default:     jsonBool(true),
+					Type:        "integer",
+					Description: "Timeout per context in seconds (default: 5)",
+					Default:     jsonInt(5),
 				},
 			}, nil),
 		},
-		"uninstall_istio": {
-			Name:        "uninstall_istio",
-			Description: "Uninstall Istio service mesh from the cluster using Helm",
+		"get_fleet_status": {
+			Name:        "get_fleet_status",
+			Description: "Survey several clusters in one call: per kubeconfig context, whether the API server is reachable, the Kubernetes version, whether Istio is installed and its version, and mesh health",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"delete_crds": {
-					Type:        "boolean",
-					Description: "Whether to delete Istio CRDs (default: false)",
-					Default:     jsonBool(false),
+				"contexts": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Kubeconfig contexts to survey (default: all contexts)",
 				},
-				"uninstall_cni": {
-					Type:        "boolean",
-					Description: "Whether to uninstall CNI components (default: false)",
-					Default:     jsonBool(false),
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of the Istio control plane in each cluster (default: istio-system)",
+					Default:     jsonString("istio-system"),
 				},
 				"timeout": {
-					Type:        "string",
-					Description: "Helm timeout for uninstallation (default: 10m)",
-					Default:     jsonString("10m"),
+					Type:        "integer",
+					Description: "Timeout per cluster in seconds (default: 10)",
+					Default:     jsonInt(10),
 				},
 			}, nil),
 		},
-		"check_istio_status": {
-			Name:        "check_istio_status",
-			Description: "Check the status of Istio installation",
+		"check_tool_permissions": {
+			Name:        "check_tool_permissions",
+			Description: "Probe the current credentials with SelfSubjectAccessReviews, list tools that would fail with Forbidden and their missing permissions, and re-enable tools that became available",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"namespace": {
+				"tool": {
 					Type:        "string",
-					Description: "Namespace to check Istio status (default: istio-system)",
-					Default:     jsonString("istio-system"),
+					Description: "Only report this tool",
 				},
 			}, nil),
 		},
-		"install_sail_operator": {
-			Name:        "install_sail_operator",
-			Description: "Install Sail operator for Istio management using Helm",
+		"create_dev_cluster": {
+			Name:        "create_dev_cluster",
+			Description: "Create a local kind or minikube cluster with gateway port mappings and register its kubeconfig context",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"version": {
+				"name": {
 					Type:        "string",
-					Description: "Sail operator version to install (default: latest)",
-					Default:     jsonString("latest"),
+					Description: "Cluster name (default: meshpilot)",
+					Default:     jsonString("meshpilot"),
 				},
-				"release_name": {
+				"provider": {
 					Type:        "string",
-					Description: "Helm release name (default: sail-operator)",
-					Default:     jsonString("sail-operator"),
+					Description: "Cluster provider (default: kind)",
+					Default:     jsonString("kind"),
+					Enum:        []interface{}{"kind", "minikube"},
 				},
-				"values": {
+				"kubernetes_version": {
 					Type:        "string",
-					Description: "Custom Helm values in YAML format",
+					Description: "Kubernetes version, e.g. v1.29.2 (default: provider default)",
 				},
-				"timeout": {
+				"node_image": {
 					Type:        "string",
-					Description: "Helm timeout for installation (default: 10m)",
-					Default:     jsonString("10m"),
+					Description: "kind node image override (default: kindest/node for kubernetes_version)",
+				},
+				"workers": {
+					Type:        "integer",
+					Description: "Worker nodes in addition to the control plane (default: 0)",
+					Default:     jsonInt(0),
+					Minimum:     float64Ptr(0),
+				},
+				"http_port": {
+					Type:        "integer",
+					Description: "Host port mapped to the gateway HTTP node port 30080 (kind only, default: 80)",
+					Default:     jsonInt(80),
+				},
+				"https_port": {
+					Type:        "integer",
+					Description: "Host port mapped to the gateway HTTPS node port 30443 (kind only, default: 443)",
+					Default:     jsonInt(443),
 				},
 				"wait": {
-					Type:        "boolean",
-					Description: "Wait for installation to complete (default: true)",
-					Default:     jsonBool(true),
+					Type:        "string",
+					Description: "Time to wait for the control plane (kind only, default: 5m)",
+					Default:     jsonString("5m"),
 				},
 			}, nil),
 		},
-		"uninstall_sail_operator": {
-			Name:        "uninstall_sail_operator",
-			Description: "Uninstall Sail operator from the cluster using Helm",
+		"delete_dev_cluster": {
+			Name:        "delete_dev_cluster",
+			Description: "Delete a local kind or minikube cluster and its kubeconfig context",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"release_name": {
+				"name": {
 					Type:        "string",
-					Description: "Helm release name (default: sail-operator)",
-					Default:     jsonString("sail-operator"),
+					Description: "Cluster name (default: meshpilot)",
+					Default:     jsonString("meshpilot"),
 				},
-				"timeout": {
+				"provider": {
 					Type:        "string",
-					Description: "Helm timeout for uninstallation (default: 10m)",
-					Default:     jsonString("10m"),
+					Description: "Cluster provider (default: kind)",
+					Default:     jsonString("kind"),
+					Enum:        []interface{}{"kind", "minikube"},
 				},
 			}, nil),
 		},
-		"check_sail_status": {
-			Name:        "check_sail_status",
-			Description: "Check the status of Sail operator installation",
+		"self_test": {
+			Name:        "self_test",
+			Description: "Validate the meshpilot and cluster setup end to end: optionally provision a kind cluster, install Istio, deploy the sample apps, run connectivity and diagnostics, then tear everything down, reporting pass/fail per stage",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"namespace": {
+				"provision_cluster": {
+					Type:        "boolean",
+					Description: "Create a disposable dev cluster for the run and delete it afterwards",
+					Default:     jsonBool(false),
+				},
+				"cluster_name": {
 					Type:        "string",
-					Description: "Namespace to check Sail operator status (default: sail-operator)",
-					Default:     jsonString("sail-operator"),
+					Description: "Name of the dev cluster (default: meshpilot-selftest)",
+					Default:     jsonString("meshpilot-selftest"),
 				},
-			}, nil),
-		},
-		"deploy_sleep_app": {
-			Name:        "deploy_sleep_app",
-			Description: "Deploy sleep sample application for testing",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"namespace": {
+				"provider": {
 					Type:        "string",
-					Description: "Namespace to deploy sleep app (default: default)",
-					Default:     jsonString("default"),
+					Description: "Dev cluster provider (default: kind)",
+					Default:     jsonString("kind"),
+					Enum:        []interface{}{"kind", "minikube"},
 				},
-			}, nil),
-		},
-		"deploy_httpbin_app": {
-			Name:        "deploy_httpbin_app",
-			Description: "Deploy httpbin sample application for testing",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"namespace": {
+				"istio_version": {
 					Type:        "string",
-					Description: "Namespace to deploy httpbin app (default: default)",
-					Default:     jsonString("default"),
+					Description: "Istio version to install (default: latest)",
 				},
-			}, nil),
-		},
-		"undeploy_sleep_app": {
-			Name:        "undeploy_sleep_app",
-			Description: "Remove sleep sample application",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
 				"namespace": {
 					Type:        "string",
-					Description: "Namespace to remove sleep app from (default: default)",
-					Default:     jsonString("default"),
+					Description: "Namespace for the sample apps (default: meshpilot-selftest)",
+					Default:     jsonString("meshpilot-selftest"),
 				},
-			}, nil),
-		},
-		"undeploy_httpbin_app": {
-			Name:        "undeploy_httpbin_app",
-			Description: "Remove httpbin sample application",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"namespace": {
+				"keep": {
+					Type:        "boolean",
+					Description: "Skip teardown and leave everything in place for inspection",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
 					Type:        "string",
-					Description: "Namespace to remove httpbin app from (default: default)",
-					Default:     jsonString("default"),
+					Description: "Timeout for installs and pod readiness (default: 5m)",
+					Default:     jsonString("5m"),
 				},
 			}, nil),
 		},
-		"test_connectivity": {
-			Name:        "test_connectivity",
-			Description: "Test network connectivity between pods",
+		"install_metallb": {
+			Name:        "install_metallb",
+			Description: "Install MetalLB with an L2 address pool so LoadBalancer services such as the ingress gateway get a reachable external IP in local clusters",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"source_pod": {
-					Type:        "string",
-					Description: "Name of the source pod",
-				},
-				"source_namespace": {
+				"namespace": {
 					Type:        "string",
-					Description: "Namespace of the source pod (default: default)",
-					Default:     jsonString("default"),
+					Description: "Namespace for MetalLB (default: metallb-system)",
+					Default:     jsonString("metallb-system"),
 				},
-				"target_service": {
+				"version": {
 					Type:        "string",
-					Description: "Target service name or IP",
-				},
-				"target_port": {
-					Type:        "integer",
-					Description: "Target port number",
-					Minimum:     float64Ptr(1),
-					Maximum:     float64Ptr(65535),
+					Description: "MetalLB chart version (default: latest)",
 				},
-				"protocol": {
-					Type:        "string",
-					Description: "Protocol to test (http, https, tcp) (default: http)",
-					Default:     jsonString("http"),
-					Enum:        []interface{}{"http", "https", "tcp"},
+				"address_pool": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Address ranges or CIDRs for the pool, e.g. 172.18.255.200-172.18.255.250 (default: detected from the docker network on kind)",
 				},
-			}, []string{"source_pod", "target_service", "target_port"}),
-		},
-		"test_sleep_to_httpbin": {
-			Name:        "test_sleep_to_httpbin",
-			Description: "Test connectivity from sleep pod to httpbin service",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"sleep_namespace": {
+				"pool_name": {
 					Type:        "string",
-					Description: "Namespace of the sleep pod (default: default)",
-					Default:     jsonString("default"),
+					Description: "Name of the IPAddressPool (default: meshpilot-pool)",
+					Default:     jsonString("meshpilot-pool"),
 				},
-				"httpbin_namespace": {
+				"docker_network": {
 					Type:        "string",
-					Description: "Namespace of the httpbin service (default: default)",
-					Default:     jsonString("default"),
+					Description: "Docker network used to detect the pool on kind (default: kind)",
+					Default:     jsonString("kind"),
 				},
-				"path": {
+				"timeout": {
 					Type:        "string",
-					Description: "HTTP path to test (default: /get)",
-					Default:     jsonString("/get"),
+					Description: "Helm timeout for installation (default: 5m)",
+					Default:     jsonString("5m"),
 				},
 			}, nil),
 		},
-		"get_pod_logs": {
-			Name:        "get_pod_logs",
-			Description: "Get logs from a specific pod container",
+		"install_istio": {
+			Name:        "install_istio",
+			Description: "Install Istio service mesh on the cluster using Helm, in sidecar mode or ambient mode with ztunnel",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"pod_name": {
+				"namespace": {
 					Type:        "string",
-					Description: "Name of the pod to get logs from",
+					Description: "Namespace to install istiod into (default: istio-system)",
+					Default:     jsonString("istio-system"),
 				},
-				"namespace": {
+				"version": {
 					Type:        "string",
-					Description: "Namespace of the pod (default: default)",
-					Default:     jsonString("default"),
+					Description: "Istio version to install (default: latest)",
+					Default:     jsonString("latest"),
 				},
-				"container": {
+				"profile": {
 					Type:        "string",
-					Description: "Container name (optional)",
+					Description: "Data plane mode: default installs sidecar mode; ambient also installs ztunnel and the CNI node agent configured for ambient (default: default)",
+					Default:     jsonString("default"),
+					Enum:        []interface{}{"default", "ambient"},
 				},
-				"tail_lines": {
-					Type:        "integer",
-					Description: "Number of lines to tail (default: 100)",
-					Default:     jsonInt(100),
-					Minimum:     float64Ptr(1),
+				"values": {
+					Type:        "object",
+					Description: "Custom Helm values, merged over the chart defaults",
 				},
-				"follow": {
+				"install_gateway": {
 					Type:        "boolean",
-					Description: "Follow log output (default: false)",
+					Description: "Whether to install Istio gateway (default: false)",
 					Default:     jsonBool(false),
 				},
-			}, []string{"pod_name"}),
-		},
-		"get_istio_proxy_logs": {
-			Name:        "get_istio_proxy_logs",
-			Description: "Get Istio sidecar proxy logs from a pod",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
-				"pod_name": {
+				"gateway_namespace": {
 					Type:        "string",
-					Description: "Name of the pod to get Istio proxy logs from",
+					Description: "Namespace for gateway installation (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
 				},
-				"namespace": {
+				"gateway_mode": {
 					Type:        "string",
-					Description: "Namespace of the pod (default: default)",
-					Default:     jsonString("default"),
+					Description: "How install_gateway deploys the ingress gateway: helm installs the gateway chart; gateway_api creates a Gateway API Gateway named istio-ingress that istiod deploys, and installs the Gateway API CRDs if missing (default: helm)",
+					Default:     jsonString("helm"),
+					Enum:        []interface{}{"helm", "gateway_api"},
 				},
-				"tail_lines": {
-					Type:        "integer",
-					Description: "Number of lines to tail (default: 100)",
-					Default:     jsonInt(100),
-					Minimum:     float64Ptr(1),
+				"gateway_api_crds": {
+					Type:        "boolean",
+					Description: "Install the standard Gateway API CRDs before istiod; CRDs already on the cluster are left unchanged (default: false)",
+					Default:     jsonBool(false),
 				},
-			}, []string{"pod_name"}),
-		},
-		"exec_pod_command": {
-			Name:        "exec_pod_command",
-			Description: "Execute a command inside a pod container",
-			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"gateway_api_version": {
+					Type:        "string",
+					Description: "Gateway API release whose CRDs are installed (default: v1.2.1)",
+					Default:     jsonString("v1.2.1"),
+				},
+				"install_cni": {
+					Type:        "boolean",
+					Description: "Whether to install Istio CNI (default: false)",
+					Default:     jsonBool(false),
+				},
+				"cni_values": {
+					Type:        "object",
+					Description: "Custom CNI Helm values, merged over the chart defaults",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm timeout for installation (default: 10m)",
+					Default:     jsonString("10m"),
+				},
+				"wait": {
+					Type:        "boolean",
+					Description: "Wait for installation to complete (default: true)",
+					Default:     jsonBool(true),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Chart repository URL or oci:// registry to install from (default: configured Istio repository)",
+				},
+				"pin_digests": {
+					Type:        "boolean",
+					Description: "Resolve image tags to digests and pin istiod, proxy, CNI and ztunnel images by digest (default: false)",
+					Default:     jsonBool(false),
+				},
+				"image_variant": {
+					Type:        "string",
+					Description: "Image variant for all Istio components: default, distroless or fips (fips requires values.global.hub to point at a FIPS registry)",
+					Default:     jsonString("default"),
+					Enum:        []interface{}{"default", "distroless", "fips"},
+				},
+				"skip_preflight": {
+					Type:        "boolean",
+					Description: "Skip the node capacity, ResourceQuota and LimitRange checks run before installing (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"istio_canary_upgrade": {
+			Name:        "istio_canary_upgrade",
+			Description: "Upgrade Istio the revision-based way: install a new istiod revision alongside the existing one, relabel the chosen namespaces to it, restart their workloads, verify the proxies reconnect to the new revision and optionally remove the old revision",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"version": {
+					Type:        "string",
+					Description: "Istio chart version to upgrade to",
+				},
+				"namespaces": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Namespaces to move to the new revision",
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Name of the new revision (default: the version with dots replaced by dashes, e.g. 1-23-2)",
+				},
+				"old_revision": {
+					Type:        "string",
+					Description: "Revision being replaced (default: the revision the first namespace uses)",
+				},
+				"old_release": {
+					Type:        "string",
+					Description: "Helm release of the old revision (default: istiod for the default revision, otherwise istiod-<old_revision>)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"values": {
+					Type:        "object",
+					Description: "Extra Helm values for the new revision, applied over the copied values",
+				},
+				"copy_values": {
+					Type:        "boolean",
+					Description: "Start the new revision from the old release's user-supplied values (default: true)",
+					Default:     jsonBool(true),
+				},
+				"upgrade_base": {
+					Type:        "boolean",
+					Description: "Upgrade the istio-base chart (CRDs) to the new version first (default: true)",
+					Default:     jsonBool(true),
+				},
+				"restart": {
+					Type:        "boolean",
+					Description: "Restart the sidecar workloads in the namespaces and wait for their proxies to reconnect (default: true)",
+					Default:     jsonBool(true),
+				},
+				"remove_old_revision": {
+					Type:        "boolean",
+					Description: "Uninstall the old revision once all proxies reconnect and no namespace, tag or pod still uses it (default: false)",
+					Default:     jsonBool(false),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for each Helm operation, each rollout and the proxy verification (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, []string{"version", "namespaces"}),
+		},
+		"migrate_istio_install": {
+			Name:        "migrate_istio_install",
+			Description: "Detect an istioctl or IstioOperator installation and migrate it to Helm (adopting the existing resources into istio-base, istiod, istio-cni, ztunnel and gateway releases with values extracted from the IstioOperator) or to the Sail operator (a new revision the namespaces move to). Returns the plan and equivalent commands; set execute to carry it out and verify",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"target": {
+					Type:        "string",
+					Description: "Management to migrate to: helm or sail (default: helm)",
+					Enum:        []interface{}{"helm", "sail"},
+					Default:     jsonString("helm"),
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Revision to migrate (default: the default revision)",
+				},
+				"version": {
+					Type:        "string",
+					Description: "Istio version of the charts or Sail resource (default: the version istiod runs, so nothing is upgraded)",
+				},
+				"new_revision": {
+					Type:        "string",
+					Description: "Sail only: name of the Istio resource and its revision (default: sail)",
+					Default:     jsonString("sail"),
+				},
+				"namespaces": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Sail only: namespaces to move to the new revision (default: every namespace using the migrated revision)",
+				},
+				"sail_namespace": {
+					Type:        "string",
+					Description: "Namespace of the Sail operator (default: sail-operator)",
+					Default:     jsonString("sail-operator"),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"execute": {
+					Type:        "boolean",
+					Description: "Carry out the plan instead of only returning it (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for each Helm install, rollout and readiness wait (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"migrate_to_ambient": {
+			Name:        "migrate_to_ambient",
+			Description: "Migrate namespaces from sidecars to ambient one at a time: deploy a waypoint where L7 policies or routes need one, switch the namespace labels, restart the workloads without sidecars, compare service reachability before and after, and roll the namespace back if anything regressed. Returns the plan unless execute is set",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespaces": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Namespaces to migrate, in order; the migration stops at the first one that fails",
+				},
+				"waypoint_name": {
+					Type:        "string",
+					Description: "Name of the waypoint Gateway deployed where needed (default: waypoint)",
+					Default:     jsonString("waypoint"),
+				},
+				"deploy_waypoints": {
+					Type:        "boolean",
+					Description: "Deploy a waypoint in namespaces with L7 AuthorizationPolicies, mesh VirtualServices, Service HTTPRoutes or RequestAuthentications (default: true)",
+					Default:     jsonBool(true),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to probe the services from (default: the first app=sleep pod of each namespace)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the probe source (default: the namespace being migrated)",
+				},
+				"rollback": {
+					Type:        "boolean",
+					Description: "Restore the labels, remove the created waypoint and restart the workloads with sidecars when a namespace fails (default: true)",
+					Default:     jsonBool(true),
+				},
+				"execute": {
+					Type:        "boolean",
+					Description: "Carry out the migration instead of only returning the plan (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for each rollout and readiness wait (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, []string{"namespaces"}),
+		},
+		"uninstall_istio": {
+			Name:        "uninstall_istio",
+			Description: "Uninstall Istio service mesh from the cluster using Helm",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace Istio is installed in (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the ingress gateway (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"delete_crds": {
+					Type:        "boolean",
+					Description: "Whether to delete Istio CRDs (default: false)",
+					Default:     jsonBool(false),
+				},
+				"uninstall_cni": {
+					Type:        "boolean",
+					Description: "Whether to uninstall CNI components (default: false)",
+					Default:     jsonBool(false),
+				},
+				"wait": {
+					Type:        "boolean",
+					Description: "Wait for the uninstall to complete; always enabled",
+					Default:     jsonBool(true),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm timeout for uninstallation (default: 10m)",
+					Default:     jsonString("10m"),
+				},
+			}, nil),
+		},
+		"check_istio_status": {
+			Name:        "check_istio_status",
+			Description: "Check the status of Istio installation",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to check Istio status (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+			}, nil),
+		},
+		"proxy_status": {
+			Name:        "proxy_status",
+			Description: "Show the xDS sync state (CDS, LDS, EDS, RDS, ECDS) of every proxy and the istiod it is connected to, flagging stale or rejected (NACKed) configs and sidecars connected to no istiod (like istioctl proxy-status)",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only report proxies in this namespace (default: all namespaces)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"only_problems": {
+					Type:        "boolean",
+					Description: "Leave out proxies whose config is fully synced",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"istiod_debug": {
+			Name:        "istiod_debug",
+			Description: "Read an istiod debug endpoint (/debug/syncz, /debug/push_status or /debug/adsz) from every istiod pod, keeping only the entries of the proxies matching a proxy name or namespace",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"endpoint": {
+					Type:        "string",
+					Description: "Debug endpoint to read: syncz (xDS nonces sent and acknowledged), push_status (last push, including NACKs and conflicts) or adsz (connected clients and their watched resources)",
+					Enum:        []interface{}{"syncz", "push_status", "adsz"},
+				},
+				"proxy": {
+					Type:        "string",
+					Description: "Only proxies whose ID (pod.namespace) contains this, e.g. a pod name",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Only proxies in this namespace",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Only query istiod pods of this revision (default: every revision)",
+				},
+				"limit": {
+					Type:        "integer",
+					Description: "Maximum proxies returned per istiod for syncz and adsz (default: 100)",
+					Default:     jsonInt(100),
+				},
+			}, []string{"endpoint"}),
+		},
+		"audit_istiod_flags": {
+			Name:        "audit_istiod_flags",
+			Description: "List the PILOT_*, ENABLE_* and experimental environment variables set on each istiod, map them to the features they control, and flag risky values, deprecated flags, compatibility flags kept after an upgrade and flags that differ between revisions",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Only audit the istiod of this revision (default: every revision)",
+				},
+				"include_defaults": {
+					Type:        "boolean",
+					Description: "Also list the variables the Helm chart always sets, such as REVISION and CLUSTER_ID",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"watch_mesh_events": {
+			Name:        "watch_mesh_events",
+			Description: "Watch the Istio and gateway namespaces for a bounded duration and return a timeline of the Warning events and container restarts seen meanwhile, to follow an install or upgrade as it happens instead of polling status",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where Istio is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"namespaces": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Additional namespaces to watch",
+				},
+				"include_gateways": {
+					Type:        "boolean",
+					Description: "Also watch the namespaces running ingress, egress or Gateway API gateways (default: true)",
+					Default:     jsonBool(true),
+				},
+				"duration": {
+					Type:        "string",
+					Description: "How long to watch, at most 10m (default: 1m)",
+					Default:     jsonString("1m"),
+				},
+				"stop_on_warning": {
+					Type:        "boolean",
+					Description: "Return as soon as the first warning or restart is seen",
+					Default:     jsonBool(false),
+				},
+				"max_events": {
+					Type:        "integer",
+					Description: "Return once this many events were collected (default: 200)",
+					Default:     jsonInt(200),
+				},
+			}, nil),
+		},
+		"inspect_revision_tags": {
+			Name:        "inspect_revision_tags",
+			Description: "List istiod revisions and istio.io/tag revision tags, show which control plane each injection-enabled namespace resolves to, and detect orphaned tags pointing at removed revisions",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only resolve this namespace (default: all namespaces with injection labels)",
+				},
+			}, nil),
+		},
+		"audit_discovery_selectors": {
+			Name:        "audit_discovery_selectors",
+			Description: "Show the meshConfig.discoverySelectors in effect and which namespaces are inside or outside istiod's discovery scope, flagging meshed namespaces istiod ignores",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+			}, nil),
+		},
+		"istio_analyze": {
+			Name:        "istio_analyze",
+			Description: "Analyze Istio configuration the way istioctl analyze does and report coded messages with a level: unresolved references, DestinationRule subsets and policies selecting no pods, VirtualServices and Gateways that conflict, gateway ports missing from the gateway Service, namespaces without injection (a warning when gateway routes send traffic to them), pods missing the proxy and service ports whose protocol cannot be inferred",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only report messages for resources in this namespace; references are still resolved cluster-wide (default: all namespaces)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of the Istio control plane, excluded from the injection checks (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"cluster_domain": {
+					Type:        "string",
+					Description: "Cluster DNS domain used to expand short host names (default: cluster.local)",
+					Default:     jsonString("cluster.local"),
+				},
+				"min_level": {
+					Type:        "string",
+					Description: "Lowest message level to report (default: Info)",
+					Enum:        []interface{}{"Error", "Warning", "Info"},
+					Default:     jsonString("Info"),
+				},
+				"suppress": {
+					Type:        "array",
+					Description: "Message codes to leave out of the report, e.g. IST0118",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+			}, nil),
+		},
+		"audit_istio_resources": {
+			Name:        "audit_istio_resources",
+			Description: "Find Istio resources that reference things that no longer exist or select nothing, e.g. VirtualServices attached to deleted Gateways or routing to missing hosts or undefined subsets, DestinationRule subsets with no matching pods, Gateways without pods or routes, and policies selecting no workloads, and report them as cleanup candidates",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only audit resources in this namespace; references are still resolved cluster-wide (default: all namespaces)",
+				},
+				"cluster_domain": {
+					Type:        "string",
+					Description: "Cluster DNS domain used to expand short host names (default: cluster.local)",
+					Default:     jsonString("cluster.local"),
+				},
+			}, nil),
+		},
+		"get_injection_config": {
+			Name:        "get_injection_config",
+			Description: "Show the sidecar injection policy, default and custom injection templates, and the proxy values the templates render, optionally with the full text of one template",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision (default: the default revision)",
+				},
+				"template": {
+					Type:        "string",
+					Description: "Include the text of this template, e.g. sidecar",
+				},
+			}, nil),
+		},
+		"set_injection_template": {
+			Name:        "set_injection_template",
+			Description: "Add, replace or remove a custom sidecar injection template (e.g. lifecycle hooks or extra volumes), set the default templates or override injection values on the istiod Helm release; the template is validated first and a canary pod can be rendered afterwards",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Custom template name; built-in templates such as sidecar cannot be replaced",
+				},
+				"template": {
+					Type:        "string",
+					Description: "Go template rendering a pod patch (metadata and spec), merged over the templates listed before it",
+				},
+				"remove": {
+					Type:        "boolean",
+					Description: "Remove the custom template (default: false)",
+					Default:     jsonBool(false),
+				},
+				"default_templates": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Templates applied to every injected pod, in merge order, e.g. [\"sidecar\", \"custom\"]",
+				},
+				"values": {
+					Type:        "object",
+					Description: "Injection values keyed by dotted path under global.proxy, global.proxy_init or sidecarInjectorWebhook, e.g. {\"global.proxy.holdApplicationUntilProxyStarts\": true}",
+				},
+				"preview_namespace": {
+					Type:        "string",
+					Description: "Render a canary pod in this namespace with a server-side dry run after applying",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision (default: the default revision)",
+				},
+				"release": {
+					Type:        "string",
+					Description: "Name of the istiod Helm release (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only validate and show the Helm values that would be set (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm upgrade timeout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Chart repository URL or oci:// registry (default: configured Istio repository)",
+				},
+			}, nil),
+		},
+		"preview_injection": {
+			Name:        "preview_injection",
+			Description: "Render a canary pod through the sidecar injection webhook with a server-side dry run, showing the containers, lifecycle hooks and volumes the selected templates add; nothing is created",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to render the pod in (default: default)",
+					Default:     jsonString("default"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision to inject with (default: the default revision)",
+				},
+				"templates": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Templates to render, set as the inject.istio.io/templates annotation (default: the injector's default templates)",
+				},
+				"annotations": {
+					Type:        "object",
+					Description: "Extra pod annotations, e.g. sidecar.istio.io/proxyCPU",
+				},
+				"show_pod": {
+					Type:        "boolean",
+					Description: "Include the full rendered pod YAML (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"configure_discovery_selectors": {
+			Name:        "configure_discovery_selectors",
+			Description: "Set meshConfig.discoverySelectors on the istiod Helm release so istiod only watches the selected namespaces, previewing which namespaces enter or leave the discovery scope",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespaces": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Namespaces istiod should watch, matched by name; istiod's own namespace is always added",
+				},
+				"selectors": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "object",
+					},
+					Description: "Raw label selectors, e.g. [{\"matchLabels\": {\"istio-discovery\": \"enabled\"}}]",
+				},
+				"clear": {
+					Type:        "boolean",
+					Description: "Remove the discovery selectors so istiod watches every namespace",
+					Default:     jsonBool(false),
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to check (default: the default revision)",
+				},
+				"release": {
+					Type:        "string",
+					Description: "Helm release name of istiod (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only preview the namespaces entering or leaving the scope",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm upgrade timeout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+			}, nil),
+		},
+		"install_otel_collector": {
+			Name:        "install_otel_collector",
+			Description: "Deploy an OpenTelemetry collector that receives OTLP traces on 4317 (gRPC) and 4318 (HTTP) and logs them with the debug exporter",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace for the collector (default: observability)",
+					Default:     jsonString("observability"),
+				},
+				"name": {
+					Type:        "string",
+					Description: "Name of the collector deployment and service (default: otel-collector)",
+					Default:     jsonString("otel-collector"),
+				},
+				"image": {
+					Type:        "string",
+					Description: "Collector image (default: otel/opentelemetry-collector:0.98.0)",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "How long to wait for the collector to become ready (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"configure_tracing": {
+			Name:        "configure_tracing",
+			Description: "Register an OpenTelemetry collector as a meshConfig tracing provider, enable it mesh-wide with a Telemetry resource at the given sampling rate, and verify spans reach the collector by generating sleep-to-httpbin traffic",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"collector_service": {
+					Type:        "string",
+					Description: "Collector service FQDN (default: otel-collector.observability.svc.cluster.local)",
+					Default:     jsonString("otel-collector.observability.svc.cluster.local"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Collector OTLP gRPC port (default: 4317)",
+					Default:     jsonInt(4317),
+				},
+				"provider": {
+					Type:        "string",
+					Description: "Extension provider name (default: otel-tracing)",
+					Default:     jsonString("otel-tracing"),
+				},
+				"sampling": {
+					Type:        "number",
+					Description: "Percentage of requests traced (default: 100)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed; the Telemetry resource is created here (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"release": {
+					Type:        "string",
+					Description: "Helm release name of istiod (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"verify": {
+					Type:        "boolean",
+					Description: "Generate traffic and check the collector received spans (default: true)",
+					Default:     jsonBool(true),
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the sleep and httpbin apps used to generate traffic (default: default)",
+					Default:     jsonString("default"),
+				},
+				"requests": {
+					Type:        "integer",
+					Description: "Requests sent per verification attempt (default: 20)",
+					Default:     jsonInt(20),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm upgrade timeout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"check_cni_chaining": {
+			Name:        "check_cni_chaining",
+			Description: "Check istio-cni chaining in each node's CNI configuration, flag conflicting plugins and verify the istio-cni DaemonSet covers every node",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the istio-cni-node DaemonSet (default: istio-system, other namespaces are searched if not found)",
+					Default:     jsonString("istio-system"),
+				},
+				"node": {
+					Type:        "string",
+					Description: "Only check this node",
+				},
+				"conf_dir": {
+					Type:        "string",
+					Description: "CNI configuration directory inside the install-cni container",
+					Default:     jsonString("/host/etc/cni/net.d"),
+				},
+			}, nil),
+		},
+		"detect_cni_race": {
+			Name:        "detect_cni_race",
+			Description: "Find meshed pods that started before the Istio CNI agent was ready on their node and recommend which ones to restart",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only check pods in this namespace (default: all namespaces)",
+				},
+				"node": {
+					Type:        "string",
+					Description: "Only check pods on this node",
+				},
+				"cni_namespace": {
+					Type:        "string",
+					Description: "Namespace of the istio-cni-node DaemonSet",
+					Default:     jsonString("istio-system"),
+				},
+				"check_iptables": {
+					Type:        "boolean",
+					Description: "Confirm suspect pods by inspecting their nat table with a debug container",
+					Default:     jsonBool(false),
+				},
+				"max_iptables": {
+					Type:        "integer",
+					Description: "Maximum number of pods to inspect with check_iptables",
+					Default:     jsonInt(5),
+				},
+			}, nil),
+		},
+		"estimate_mesh_cost": {
+			Name:        "estimate_mesh_cost",
+			Description: "Sum the CPU and memory requested by the mesh (istiod, gateways, waypoints, ztunnel and CNI agents, sidecar proxies) as a share of the cluster's allocatable resources, and project the sidecar overhead of enabling injection in additional namespaces; optionally priced per core-hour and GiB-hour",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod and its sidecar injector configmap (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Revision whose injector values set the projected proxy requests (default: the default revision)",
+				},
+				"namespaces": {
+					Type:        "array",
+					Items:       &jsonschema.Schema{Type: "string"},
+					Description: "Namespaces to project enabling injection in (default: every namespace with pods and no injection or ambient label)",
+				},
+				"proxy_cpu": {
+					Type:        "string",
+					Description: "CPU request per projected sidecar (default: global.proxy.resources.requests.cpu from the injector values, else 100m)",
+				},
+				"proxy_memory": {
+					Type:        "string",
+					Description: "Memory request per projected sidecar (default: global.proxy.resources.requests.memory from the injector values, else 128Mi)",
+				},
+				"cpu_core_hour_price": {
+					Type:        "number",
+					Description: "Price of one requested CPU core per hour, to report monthly costs",
+				},
+				"memory_gib_hour_price": {
+					Type:        "number",
+					Description: "Price of one requested GiB of memory per hour, to report monthly costs",
+				},
+			}, nil),
+		},
+		"check_install_capacity": {
+			Name:        "check_install_capacity",
+			Description: "Check node capacity, ResourceQuotas and LimitRanges against the resources an Istio install will request",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Istio control plane namespace (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"values": {
+					Type:        "object",
+					Description: "istiod Helm values that will be used for the install (resource and replica overrides are honored)",
+				},
+				"install_gateway": {
+					Type:        "boolean",
+					Description: "Include the ingress gateway (default: false)",
+					Default:     jsonBool(false),
+				},
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace for the gateway (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"install_cni": {
+					Type:        "boolean",
+					Description: "Include the CNI node agent (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"get_release_values": {
+			Name:        "get_release_values",
+			Description: "Get the user-supplied and computed Helm values of an installed istiod, gateway, CNI, base or Sail operator release",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"component": {
+					Type:        "string",
+					Description: "Mesh component whose release to inspect (default: istiod)",
+					Default:     jsonString("istiod"),
+					Enum:        []interface{}{"base", "istiod", "cni", "gateway", "sail"},
+				},
+				"release_name": {
+					Type:        "string",
+					Description: "Helm release name (overrides the component's default release)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the release (overrides the component's default namespace)",
+				},
+				"computed": {
+					Type:        "boolean",
+					Description: "Include computed values (chart defaults merged with overrides) (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"list_available_istio_versions": {
+			Name:        "list_available_istio_versions",
+			Description: "List installable Istio chart versions and release dates from the configured Helm repository",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"chart": {
+					Type:        "string",
+					Description: "Istio chart to list versions for (default: istiod)",
+					Default:     jsonString("istiod"),
+					Enum:        []interface{}{"base", "istiod", "cni", "gateway", "ztunnel"},
+				},
+				"include_prerelease": {
+					Type:        "boolean",
+					Description: "Include alpha, beta and release-candidate versions (default: false)",
+					Default:     jsonBool(false),
+				},
+				"limit": {
+					Type:        "integer",
+					Description: "Maximum number of versions to return (default: 20)",
+					Default:     jsonInt(20),
+					Minimum:     float64Ptr(1),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Chart repository URL to query (default: configured Istio repository)",
+				},
+			}, nil),
+		},
+		"get_istio_release_notes": {
+			Name:        "get_istio_release_notes",
+			Description: "Summarize upstream Istio upgrade notes between the installed version and a target version, flagging notes that touch the running configuration",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"to_version": {
+					Type:        "string",
+					Description: "Target Istio version to upgrade to",
+				},
+				"from_version": {
+					Type:        "string",
+					Description: "Current Istio version (default: detected from the running istiod)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Istio control plane namespace (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"relevant_only": {
+					Type:        "boolean",
+					Description: "Only return notes that reference configuration in use (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"to_version"}),
+		},
+		"install_sail_operator": {
+			Name:        "install_sail_operator",
+			Description: "Install Sail operator for Istio management using Helm",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to install the Sail operator into (default: sail-operator)",
+					Default:     jsonString("sail-operator"),
+				},
+				"version": {
+					Type:        "string",
+					Description: "Sail operator version to install (default: latest)",
+					Default:     jsonString("latest"),
+				},
+				"release_name": {
+					Type:        "string",
+					Description: "Helm release name (default: sail-operator)",
+					Default:     jsonString("sail-operator"),
+				},
+				"values": {
+					Type:        "object",
+					Description: "Custom Helm values, merged over the chart defaults",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm timeout for installation (default: 10m)",
+					Default:     jsonString("10m"),
+				},
+				"wait": {
+					Type:        "boolean",
+					Description: "Wait for installation to complete (default: true)",
+					Default:     jsonBool(true),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Chart repository URL or oci:// registry to install from (default: configured Sail repository)",
+				},
+			}, nil),
+		},
+		"uninstall_sail_operator": {
+			Name:        "uninstall_sail_operator",
+			Description: "Uninstall Sail operator from the cluster using Helm",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace the Sail operator is installed in (default: sail-operator)",
+					Default:     jsonString("sail-operator"),
+				},
+				"wait": {
+					Type:        "boolean",
+					Description: "Wait for the uninstall to complete; always enabled",
+					Default:     jsonBool(true),
+				},
+				"release_name": {
+					Type:        "string",
+					Description: "Helm release name (default: sail-operator)",
+					Default:     jsonString("sail-operator"),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm timeout for uninstallation (default: 10m)",
+					Default:     jsonString("10m"),
+				},
+			}, nil),
+		},
+		"check_sail_status": {
+			Name:        "check_sail_status",
+			Description: "Check the status of Sail operator installation",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to check Sail operator status (default: sail-operator)",
+					Default:     jsonString("sail-operator"),
+				},
+			}, nil),
+		},
+		"deploy_sleep_app": {
+			Name:        "deploy_sleep_app",
+			Description: "Deploy sleep sample application for testing",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to deploy sleep app (default: default)",
+					Default:     jsonString("default"),
+				},
+				"istio_injection": {
+					Type:        "boolean",
+					Description: "Enable sidecar injection on the namespace; always enabled, as the app is for mesh testing",
+					Default:     jsonBool(true),
+				},
+				"replicas": {
+					Type:        "integer",
+					Description: "Number of replicas (default: 1)",
+					Default:     jsonInt(1),
+					Minimum:     float64Ptr(1),
+				},
+				"pin_digests": {
+					Type:        "boolean",
+					Description: "Resolve the image tag to a digest and pin the deployment to it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"deploy_httpbin_app": {
+			Name:        "deploy_httpbin_app",
+			Description: "Deploy httpbin sample application for testing",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to deploy httpbin app (default: default)",
+					Default:     jsonString("default"),
+				},
+				"istio_injection": {
+					Type:        "boolean",
+					Description: "Enable sidecar injection on the namespace; always enabled, as the app is for mesh testing",
+					Default:     jsonBool(true),
+				},
+				"replicas": {
+					Type:        "integer",
+					Description: "Number of replicas (default: 1)",
+					Default:     jsonInt(1),
+					Minimum:     float64Ptr(1),
+				},
+				"expose_service": {
+					Type:        "boolean",
+					Description: "Create a Service for httpbin; always enabled, as the connectivity tests call it",
+					Default:     jsonBool(true),
+				},
+				"pin_digests": {
+					Type:        "boolean",
+					Description: "Resolve the image tag to a digest and pin the deployment to it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"undeploy_sleep_app": {
+			Name:        "undeploy_sleep_app",
+			Description: "Remove sleep sample application",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to remove sleep app from (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"undeploy_httpbin_app": {
+			Name:        "undeploy_httpbin_app",
+			Description: "Remove httpbin sample application",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to remove httpbin app from (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"deploy_bookinfo_app": {
+			Name:        "deploy_bookinfo_app",
+			Description: "Deploy the Bookinfo sample application (productpage, details, ratings and reviews v1, v2 and v3) with DestinationRule version subsets and an optional ingress Gateway and VirtualService, for multi-version traffic routing demos",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to deploy Bookinfo into (default: default)",
+					Default:     jsonString("default"),
+				},
+				"istio_injection": {
+					Type:        "boolean",
+					Description: "Enable sidecar injection on the namespace; always enabled, as the app is for mesh testing",
+					Default:     jsonBool(true),
+				},
+				"replicas": {
+					Type:        "integer",
+					Description: "Replicas per service version (default: 1)",
+					Default:     jsonInt(1),
+				},
+				"pin_digests": {
+					Type:        "boolean",
+					Description: "Resolve the image tags to digests and pin the deployments to them (default: false)",
+					Default:     jsonBool(false),
+				},
+				"subsets": {
+					Type:        "boolean",
+					Description: "Create a DestinationRule per service with one subset per version (default: true)",
+					Default:     jsonBool(true),
+				},
+				"gateway": {
+					Type:        "boolean",
+					Description: "Expose productpage through the ingress gateway with Gateway bookinfo-gateway and VirtualService bookinfo (default: false)",
+					Default:     jsonBool(false),
+				},
+				"gateway_selector": {
+					Type:        "string",
+					Description: "Label selector of the ingress gateway pods as key=value pairs (default: istio=ingressgateway)",
+					Default:     jsonString("istio=ingressgateway"),
+				},
+				"hosts": {
+					Type:        "array",
+					Items:       &jsonschema.Schema{Type: "string"},
+					Description: "Hosts the Gateway and VirtualService accept (default: [\"*\"])",
+				},
+			}, nil),
+		},
+		"undeploy_bookinfo_app": {
+			Name:        "undeploy_bookinfo_app",
+			Description: "Remove the Bookinfo sample application with its DestinationRules, Gateway and VirtualService",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to remove Bookinfo from (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"apply_manifest": {
+			Name:        "apply_manifest",
+			Description: "Apply the objects of a YAML or JSON manifest, given inline or by URL, with server-side apply through the dynamic client; any built-in or custom kind known to API discovery is supported and each object is reported as created, configured, unchanged or failed. Applied objects carry the app.kubernetes.io/managed-by=meshpilot label",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"manifest": {
+					Type:        "string",
+					Description: "Inline YAML or JSON manifest; multiple YAML documents and List kinds are allowed (required unless url is given)",
+				},
+				"url": {
+					Type:        "string",
+					Description: "http or https URL to download the manifest from (required unless manifest is given)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace for namespaced objects that do not set one (default: default)",
+					Default:     jsonString("default"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the objects with a server-side dry run without persisting them (default: false)",
+					Default:     jsonBool(false),
+				},
+				"force_conflicts": {
+					Type:        "boolean",
+					Description: "Take ownership of fields currently owned by other field managers instead of failing on conflicts (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"cleanup_demo": {
+			Name:        "cleanup_demo",
+			Description: "Remove everything meshpilot deployed (sample apps, test namespaces, generated Istio resources and network policies) by its app.kubernetes.io/managed-by=meshpilot label, stop running monitors and report leftover debug containers, leaving user resources untouched",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only clean up this namespace (default: all namespaces and cluster-scoped resources)",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "List what would be removed without deleting anything (default: false)",
+					Default:     jsonBool(false),
+				},
+				"stop_monitors": {
+					Type:        "boolean",
+					Description: "Stop running connectivity monitors (default: true)",
+					Default:     jsonBool(true),
+				},
+			}, nil),
+		},
+		"list_managed_resources": {
+			Name:        "list_managed_resources",
+			Description: "Inventory the resources meshpilot created, found by their app.kubernetes.io/managed-by=meshpilot label, with the tool that created each one and when",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only list this namespace (default: all namespaces and cluster-scoped resources)",
+				},
+				"tool": {
+					Type:        "string",
+					Description: "Only list resources created by this tool, e.g. deploy_sleep_app",
+				},
+			}, nil),
+		},
+		"test_connectivity": {
+			Name:        "test_connectivity",
+			Description: "Test network connectivity between pods",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"source_pod": {
+					Type:        "string",
+					Description: "Name of the source pod",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"target_service": {
+					Type:        "string",
+					Description: "Target service name or IP",
+				},
+				"target_port": {
+					Type:        "integer",
+					Description: "Target port number",
+					Minimum:     float64Ptr(1),
+					Maximum:     float64Ptr(65535),
+				},
+				"protocol": {
+					Type:        "string",
+					Description: "Protocol to test (http, https, tcp) (default: http)",
+					Default:     jsonString("http"),
+					Enum:        []interface{}{"http", "https", "tcp"},
+				},
+				"path": {
+					Type:        "string",
+					Description: "HTTP path to request (default: /)",
+					Default:     jsonString("/"),
+				},
+				"method": {
+					Type:        "string",
+					Description: "HTTP method (default: GET)",
+					Default:     jsonString("GET"),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout in seconds (default: 10)",
+					Default:     jsonInt(10),
+					Minimum:     float64Ptr(1),
+				},
+			}, []string{"source_pod", "target_service", "target_port"}),
+		},
+		"test_sleep_to_httpbin": {
+			Name:        "test_sleep_to_httpbin",
+			Description: "Test connectivity from sleep pod to httpbin service",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the sleep pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"target_namespace": {
+					Type:        "string",
+					Description: "Namespace of the httpbin service (default: default)",
+					Default:     jsonString("default"),
+				},
+				"test_endpoints": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "httpbin paths to request (default: /get, /headers, /status/200 and /delay/1)",
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout per request in seconds (default: 10)",
+					Default:     jsonInt(10),
+					Minimum:     float64Ptr(1),
+				},
+			}, nil),
+		},
+		"verify_waypoint": {
+			Name:        "verify_waypoint",
+			Description: "Send test traffic to a service bound to an ambient waypoint and confirm from waypoint stats and access logs that the waypoint handled it, so L7 policy is not silently bypassed",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"service": {
+					Type:        "string",
+					Description: "Service fronted by the waypoint",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the service (default: default)",
+					Default:     jsonString("default"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Service port to send requests to (default: first service port)",
+				},
+				"path": {
+					Type:        "string",
+					Description: "HTTP path to request (default: /)",
+					Default:     jsonString("/"),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to send requests from (default: first app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: the service namespace)",
+				},
+				"source_container": {
+					Type:        "string",
+					Description: "Container to run curl in (default: first non-proxy container)",
+				},
+				"requests": {
+					Type:        "integer",
+					Description: "Number of test requests (default: 5)",
+					Default:     jsonInt(5),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout per request in seconds (default: 5)",
+					Default:     jsonInt(5),
+				},
+			}, []string{"service"}),
+		},
+		"configure_egress_routing": {
+			Name:        "configure_egress_routing",
+			Description: "Force traffic to external hosts through the egress gateway by creating a ServiceEntry, Gateway, DestinationRule and VirtualServices, then verify from a sleep pod that the gateway carried the requests using its stats and access logs",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"hosts": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "External hosts to route through the egress gateway, e.g. edition.cnn.com",
+				},
+				"protocol": {
+					Type:        "string",
+					Description: "tls routes HTTPS by SNI on port 443 with passthrough; http routes plain HTTP on port 80 (default: tls)",
+					Enum:        []interface{}{"tls", "http"},
+					Default:     jsonString("tls"),
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace for the routing resources (default: default)",
+					Default:     jsonString("default"),
+				},
+				"name": {
+					Type:        "string",
+					Description: "Name of the ServiceEntry, Gateway and DestinationRule, and prefix of the VirtualServices (default: egress)",
+					Default:     jsonString("egress"),
+				},
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the egress gateway (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"gateway_service": {
+					Type:        "string",
+					Description: "Service of the egress gateway (default: istio-egressgateway)",
+					Default:     jsonString("istio-egressgateway"),
+				},
+				"gateway_selector": {
+					Type:        "string",
+					Description: "Label selecting the egress gateway pods (default: istio=egressgateway)",
+					Default:     jsonString("istio=egressgateway"),
+				},
+				"install_gateway": {
+					Type:        "boolean",
+					Description: "Install the Istio gateway chart as a ClusterIP egress gateway if none is running (default: false)",
+					Default:     jsonBool(false),
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"verify": {
+					Type:        "boolean",
+					Description: "Send requests to each host and check they traverse the gateway (default: true)",
+					Default:     jsonBool(true),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to send the test requests from (default: first app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: namespace)",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for installing the gateway (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, []string{"hosts"}),
+		},
+		"run_mesh_conformance": {
+			Name:        "run_mesh_conformance",
+			Description: "Run routing, fault injection, timeout, retry, mTLS and authorization scenarios against disposable test apps in a sandbox namespace and report pass/fail per capability, e.g. to validate a cluster and mesh after an upgrade",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Sandbox namespace for the test apps; a <namespace>-legacy namespace without injection holds the plaintext client (default: meshpilot-conformance)",
+					Default:     jsonString("meshpilot-conformance"),
+				},
+				"scenarios": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+						Enum: []interface{}{"baseline_http", "header_routing", "fault_abort", "fault_delay", "request_timeout", "retries", "mtls_strict", "authorization_deny"},
+					},
+					Description: "Capabilities to check (default: all)",
+				},
+				"keep": {
+					Type:        "boolean",
+					Description: "Leave the sandbox namespaces and test apps in place for inspection",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "How long to wait for the test apps to become ready (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"generate_traffic": {
+			Name:        "generate_traffic",
+			Description: "Send requests from a source pod to a service at a fixed rate for a duration, tagging every request with a run ID header and a meshpilot-traffic/<run-id> user agent so later log and metric queries can isolate exactly this traffic; reports response codes, latency percentiles and the run's time window",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"service": {
+					Type:        "string",
+					Description: "Service to send requests to",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the service (default: default)",
+					Default:     jsonString("default"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Service port (default: first service port)",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path (default: /)",
+					Default:     jsonString("/"),
+				},
+				"method": {
+					Type:        "string",
+					Description: "HTTP method (default: GET)",
+					Default:     jsonString("GET"),
+				},
+				"headers": {
+					Type:        "object",
+					Description: "Extra request headers to send",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"rps": {
+					Type:        "integer",
+					Description: "Requests per second, at most 50 (default: 5)",
+					Default:     jsonInt(5),
+				},
+				"duration": {
+					Type:        "string",
+					Description: "How long to send traffic, at most 5m (default: 30s)",
+					Default:     jsonString("30s"),
+				},
+				"run_id": {
+					Type:        "string",
+					Description: "Run ID to tag requests with (default: generated from the start time)",
+				},
+				"header": {
+					Type:        "string",
+					Description: "Request header carrying the run ID (default: x-meshpilot-run-id)",
+					Default:     jsonString("x-meshpilot-run-id"),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to send requests from; its container needs sh and curl (default: first running app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: namespace)",
+				},
+				"source_container": {
+					Type:        "string",
+					Description: "Container to run curl in (default: first non-proxy container)",
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout per request in seconds (default: 5)",
+					Default:     jsonInt(5),
+				},
+			}, []string{"service"}),
+		},
+		"test_header_routing": {
+			Name:        "test_header_routing",
+			Description: "Send requests with given headers and cookies from a sleep pod and report which backend versions answered, compared with the versions the VirtualService match rules select",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"service": {
+					Type:        "string",
+					Description: "Service to send requests to",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the service (default: default)",
+					Default:     jsonString("default"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Service port (default: first service port)",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path; use an endpoint that identifies the backend version, such as helloworld's /hello (default: /)",
+					Default:     jsonString("/"),
+				},
+				"headers": {
+					Type:        "object",
+					Description: "Request headers to send, e.g. {\"end-user\": \"jason\"}",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"cookies": {
+					Type:        "object",
+					Description: "Cookies to send, e.g. {\"user\": \"jason\"}",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"version_header": {
+					Type:        "string",
+					Description: "Response header that names the backend version (default: detect from the version label of the pod named in the response, or a version field in the body)",
+				},
+				"requests": {
+					Type:        "integer",
+					Description: "Number of requests to send (default: 10)",
+					Default:     jsonInt(10),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to send requests from (default: first app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: the service namespace)",
+				},
+				"source_container": {
+					Type:        "string",
+					Description: "Container to run curl in (default: first non-proxy container)",
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout per request in seconds (default: 5)",
+					Default:     jsonInt(5),
+				},
+			}, []string{"service"}),
+		},
+		"test_ingress_connectivity": {
+			Name:        "test_ingress_connectivity",
+			Description: "Send a request from outside the cluster through the ingress gateway using its LoadBalancer IP, falling back to a node port",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_service": {
+					Type:        "string",
+					Description: "Name of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"host": {
+					Type:        "string",
+					Description: "Host header to send, matching a Gateway/VirtualService host",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path (default: /)",
+					Default:     jsonString("/"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Gateway service port (default: 80)",
+					Default:     jsonInt(80),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Request timeout in seconds (default: 10)",
+					Default:     jsonInt(10),
+				},
+			}, nil),
+		},
+		"test_gateway_paths": {
+			Name:        "test_gateway_paths",
+			Description: "Send the same request to an ingress gateway through its pods, ClusterIP, the node port on every node and its load balancer address, and compare the results to isolate whether a failure is in the mesh, kube-proxy or the external load balancer",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_service": {
+					Type:        "string",
+					Description: "Name of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"host": {
+					Type:        "string",
+					Description: "Host header to send, matching a Gateway/VirtualService host",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path (default: /)",
+					Default:     jsonString("/"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Gateway service port (default: 80)",
+					Default:     jsonInt(80),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod that sends the in-cluster requests (default: first running app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout per request in seconds (default: 5)",
+					Default:     jsonInt(5),
+				},
+			}, nil),
+		},
+		"sweep_service_ports": {
+			Name:        "sweep_service_ports",
+			Description: "Probe every declared port of the services in a namespace from a test pod and report listening, refused or filtered per port, flagging services whose targetPort doesn't match any container port",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the services to sweep (default: default)",
+					Default:     jsonString("default"),
+				},
+				"services": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Services to probe (default: all services in the namespace)",
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to probe from; needs sh and curl (default: first running app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: namespace)",
+				},
+				"source_container": {
+					Type:        "string",
+					Description: "Container to run the probes in (default: first non-proxy container)",
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Connect timeout per port in seconds (default: 3)",
+					Default:     jsonInt(3),
+				},
+			}, nil),
+		},
+		"probe_gateway_tls": {
+			Name:        "probe_gateway_tls",
+			Description: "Connect to the ingress gateway with combinations of SNI, ALPN and Host header and report the certificate served and the Gateway servers and routes that match, flagging TLS misconfigurations",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_service": {
+					Type:        "string",
+					Description: "Name of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Gateway service port (default: 443)",
+					Default:     jsonInt(443),
+				},
+				"sni": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Server names to send; an empty string sends no SNI (default: hosts configured on the port, plus no SNI)",
+				},
+				"alpn": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "ALPN protocol lists to offer, one probe per comma-separated entry, e.g. [\"h2\", \"http/1.1\"] (default: [\"h2,http/1.1\"])",
+				},
+				"hosts": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Host headers to send (default: same as the SNI)",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path (default: /)",
+					Default:     jsonString("/"),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout per probe in seconds (default: 10)",
+					Default:     jsonInt(10),
+				},
+			}, nil),
+		},
+		"diagnose_ingress_request": {
+			Name:        "diagnose_ingress_request",
+			Description: "Explain why a host and path fail at the ingress gateway: find the matching Gateway server and VirtualService or HTTPRoute rule, check the Envoy route and cluster health on the gateway pods, sample the gateway access logs for the path and interpret 404/503 outcomes",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"host": {
+					Type:        "string",
+					Description: "Host header of the failing request, e.g. httpbin.example.com",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path (default: /)",
+					Default:     jsonString("/"),
+				},
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_service": {
+					Type:        "string",
+					Description: "Name of the gateway service (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Gateway service port (default: 80, or 443 with https)",
+				},
+				"https": {
+					Type:        "boolean",
+					Description: "Send the request over TLS with the host as SNI (default: false)",
+					Default:     jsonBool(false),
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod, used to check whether access logging is enabled (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"send_request": {
+					Type:        "boolean",
+					Description: "Send the request to the gateway and report who answered (default: true)",
+					Default:     jsonBool(true),
+				},
+				"since": {
+					Type:        "integer",
+					Description: "Seconds of gateway access logs to sample (default: 600)",
+					Default:     jsonInt(600),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Request timeout in seconds (default: 10)",
+					Default:     jsonInt(10),
+				},
+			}, []string{"host"}),
+		},
+		"benchmark_mesh_overhead": {
+			Name:        "benchmark_mesh_overhead",
+			Description: "Run identical Fortio load with and without sidecars and report the added p50/p99 latency and sidecar CPU cost",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace for the benchmark deployments (default: meshpilot-bench)",
+					Default:     jsonString("meshpilot-bench"),
+				},
+				"qps": {
+					Type:        "integer",
+					Description: "Target requests per second (default: 1000)",
+					Default:     jsonInt(1000),
+					Minimum:     float64Ptr(1),
+				},
+				"connections": {
+					Type:        "integer",
+					Description: "Concurrent connections (default: 16)",
+					Default:     jsonInt(16),
+					Minimum:     float64Ptr(1),
+				},
+				"duration": {
+					Type:        "string",
+					Description: "Duration of each load run (default: 30s)",
+					Default:     jsonString("30s"),
+				},
+				"payload_bytes": {
+					Type:        "integer",
+					Description: "Request payload size in bytes (default: 0)",
+					Default:     jsonInt(0),
+					Minimum:     float64Ptr(0),
+				},
+				"keep_resources": {
+					Type:        "boolean",
+					Description: "Keep the benchmark namespace after the run (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"start_monitor": {
+			Name:        "start_monitor",
+			Description: "Start a background monitor that periodically probes endpoints from a pod inside the cluster and keeps rolling results",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Unique name of the monitor",
+				},
+				"endpoints": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Endpoints to probe, e.g. http://httpbin.default:8000/get or tcp://redis.cache:6379",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod probes are sent from (default: default)",
+					Default:     jsonString("default"),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod probes are sent from (default: first ready pod matching source_selector)",
+				},
+				"source_selector": {
+					Type:        "string",
+					Description: "Label selector used to pick the source pod (default: app=sleep)",
+					Default:     jsonString("app=sleep"),
+				},
+				"container": {
+					Type:        "string",
+					Description: "Container the probe command runs in (default: sleep)",
+					Default:     jsonString("sleep"),
+				},
+				"interval": {
+					Type:        "string",
+					Description: "Time between probe rounds (default: 30s)",
+					Default:     jsonString("30s"),
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Probe timeout in seconds (default: 5)",
+					Default:     jsonInt(5),
+					Minimum:     float64Ptr(1),
+				},
+				"retention": {
+					Type:        "string",
+					Description: "How long probe results are kept (default: 2h)",
+					Default:     jsonString("2h"),
+				},
+			}, []string{"name", "endpoints"}),
+		},
+		"stop_monitor": {
+			Name:        "stop_monitor",
+			Description: "Stop a running connectivity monitor and return its final results",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the monitor to stop",
+				},
+				"forget": {
+					Type:        "boolean",
+					Description: "Also discard the stored results (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"get_monitor_results": {
+			Name:        "get_monitor_results",
+			Description: "Summarize connectivity monitor results over a time window, reporting success rate, latency and whether each endpoint was stable",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the monitor (default: all monitors)",
+				},
+				"window": {
+					Type:        "string",
+					Description: "Time window to summarize, e.g. 30m or 2h (default: 30m)",
+					Default:     jsonString("30m"),
+				},
+			}, nil),
+		},
+		"get_scheduled_results": {
+			Name:        "get_scheduled_results",
+			Description: "Show the recorded outcomes of the read-only health checks scheduled in the meshpilot config file",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the schedule (default: all schedules)",
+				},
+				"limit": {
+					Type:        "integer",
+					Description: "Most recent outcomes to return per schedule (default: 5)",
+					Default:     jsonInt(5),
+					Minimum:     float64Ptr(1),
+				},
+				"failures_only": {
+					Type:        "boolean",
+					Description: "Only return failed outcomes (default: false)",
+					Default:     jsonBool(false),
+				},
+				"include_output": {
+					Type:        "boolean",
+					Description: "Include the tool output of each outcome (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"traffic_shift": {
+			Name:        "traffic_shift",
+			Description: "Split a service's traffic between two subsets (e.g. v1/v2) for canary routing by generating or updating its DestinationRule and VirtualService",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"service": {
+					Type:        "string",
+					Description: "Service to shift traffic for; the DestinationRule and VirtualService are named after it",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the service (default: default)",
+					Default:     jsonString("default"),
+				},
+				"from": {
+					Type:        "string",
+					Description: "Subset receiving the remaining traffic (default: v1)",
+					Default:     jsonString("v1"),
+				},
+				"to": {
+					Type:        "string",
+					Description: "Canary subset (default: v2)",
+					Default:     jsonString("v2"),
+				},
+				"weight": {
+					Type:        "integer",
+					Description: "Percent of requests sent to the to subset; the from subset gets the rest",
+					Minimum:     float64Ptr(0),
+					Maximum:     float64Ptr(100),
+				},
+				"subset_label": {
+					Type:        "string",
+					Description: "Pod label whose value selects each subset (default: version)",
+					Default:     jsonString("version"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"service", "weight"}),
+		},
+		"create_virtual_service": {
+			Name:        "create_virtual_service",
+			Description: "Create or update an Istio VirtualService, either from hosts and weighted destinations for the default HTTP route or from a full spec, validated against the Istio API",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the VirtualService",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the VirtualService (default: default)",
+					Default:     jsonString("default"),
+				},
+				"hosts": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Hosts the VirtualService applies to, e.g. reviews or reviews.default.svc.cluster.local",
+				},
+				"gateways": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Gateways the routes apply to; add mesh to keep applying them to sidecars (default: mesh only)",
+				},
+				"routes": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "object",
+						Properties: map[string]*jsonschema.Schema{
+							"host":   {Type: "string", Description: "Destination service host"},
+							"subset": {Type: "string", Description: "DestinationRule subset"},
+							"port":   {Type: "integer", Description: "Destination port"},
+							"weight": {Type: "integer", Description: "Share of the traffic in percent"},
+						},
+						Required: []string{"host"},
+					},
+					Description: "Weighted destinations of the default HTTP route",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Request timeout of the default HTTP route, e.g. 5s",
+				},
+				"spec": {
+					Type:        "object",
+					Description: "Full VirtualService spec; replaces hosts, gateways, routes and timeout",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"get_virtual_service": {
+			Name:        "get_virtual_service",
+			Description: "Show a VirtualService with its spec, or every VirtualService in the namespace when no name is given",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the VirtualService (default: all in the namespace)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the VirtualService (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"delete_virtual_service": {
+			Name:        "delete_virtual_service",
+			Description: "Delete a VirtualService",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the VirtualService",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the VirtualService (default: default)",
+					Default:     jsonString("default"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"create_destination_rule": {
+			Name:        "create_destination_rule",
+			Description: "Create or update an Istio DestinationRule, either from a host, subsets, load balancer and TLS mode or from a full spec, validated against the Istio API",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the DestinationRule",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the DestinationRule (default: default)",
+					Default:     jsonString("default"),
+				},
+				"host": {
+					Type:        "string",
+					Description: "Service host the rule applies to, e.g. reviews",
+				},
+				"subsets": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "object",
+						Properties: map[string]*jsonschema.Schema{
+							"name":   {Type: "string", Description: "Subset name"},
+							"labels": {Type: "object", Description: "Pod labels selecting the subset, e.g. {\"version\":\"v1\"}"},
+						},
+						Required: []string{"name", "labels"},
+					},
+					Description: "Named subsets of the host",
+				},
+				"load_balancer": {
+					Type:        "string",
+					Description: "Simple load balancing algorithm",
+					Enum:        []interface{}{"ROUND_ROBIN", "LEAST_REQUEST", "RANDOM", "PASSTHROUGH"},
+				},
+				"tls_mode": {
+					Type:        "string",
+					Description: "TLS mode for connections to the host",
+					Enum:        []interface{}{"DISABLE", "SIMPLE", "MUTUAL", "ISTIO_MUTUAL"},
+				},
+				"traffic_policy": {
+					Type:        "object",
+					Description: "Additional trafficPolicy fields, e.g. connectionPool or outlierDetection; merged over load_balancer and tls_mode",
+				},
+				"spec": {
+					Type:        "object",
+					Description: "Full DestinationRule spec; replaces the other fields",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"get_destination_rule": {
+			Name:        "get_destination_rule",
+			Description: "Show a DestinationRule with its spec, or every DestinationRule in the namespace when no name is given",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the DestinationRule (default: all in the namespace)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the DestinationRule (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"delete_destination_rule": {
+			Name:        "delete_destination_rule",
+			Description: "Delete a DestinationRule",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the DestinationRule",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the DestinationRule (default: default)",
+					Default:     jsonString("default"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"detect_connection_pool_exhaustion": {
+			Name:        "detect_connection_pool_exhaustion",
+			Description: "Find outbound clusters whose client sidecars overflowed connection pool or circuit breaker limits (upstream_cx_overflow, upstream_rq_pending_overflow, upstream_rq_retry_overflow), correlate them with the DestinationRule connectionPool that applies and suggest concrete limit changes",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the client workloads whose sidecars enforce the limits (default: default)",
+					Default:     jsonString("default"),
+				},
+				"selector": {
+					Type:        "string",
+					Description: "Label selector to limit the client pods, e.g. app=productpage",
+				},
+			}, nil),
+		},
+		"expose_service_via_gateway": {
+			Name:        "expose_service_via_gateway",
+			Description: "Route a service through the ingress gateway by creating an Istio Gateway and VirtualService, or a Gateway API Gateway and HTTPRoute, optionally terminating TLS with a provisioned self-signed certificate, then return the external address and a curl command to reach it",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"service": {
+					Type:        "string",
+					Description: "Service to expose",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the service and the routing resources (default: default)",
+					Default:     jsonString("default"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Service port to route to (default: the port named http, or the only port)",
+				},
+				"host": {
+					Type:        "string",
+					Description: "Host to accept, e.g. httpbin.example.com (default: *, required with tls)",
+					Default:     jsonString("*"),
+				},
+				"path": {
+					Type:        "string",
+					Description: "Path prefix to route (default: /)",
+					Default:     jsonString("/"),
+				},
+				"name": {
+					Type:        "string",
+					Description: "Name prefix of the created resources (default: the service name)",
+				},
+				"api": {
+					Type:        "string",
+					Description: "Routing API: istio (Gateway and VirtualService on the installed ingress gateway) or gateway_api (Gateway and HTTPRoute, with a gateway deployed by Istio)",
+					Enum:        []interface{}{"istio", "gateway_api"},
+					Default:     jsonString("istio"),
+				},
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the ingress gateway for api istio (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_service": {
+					Type:        "string",
+					Description: "Service of the ingress gateway for api istio (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_class": {
+					Type:        "string",
+					Description: "GatewayClass of the Gateway for api gateway_api (default: istio)",
+					Default:     jsonString("istio"),
+				},
+				"tls": {
+					Type:        "boolean",
+					Description: "Terminate HTTPS on port 443 instead of serving HTTP on port 80",
+					Default:     jsonBool(false),
+				},
+				"credential_name": {
+					Type:        "string",
+					Description: "Existing kubernetes.io/tls secret to serve with tls (default: provision a self-signed certificate for the host)",
+				},
+				"verify": {
+					Type:        "boolean",
+					Description: "Request the exposed path through the gateway until the route answers (default: true)",
+					Default:     jsonBool(true),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "How long to wait for the gateway deployed for api gateway_api (default: 2m)",
+					Default:     jsonString("2m"),
+				},
+			}, []string{"service"}),
+		},
+		"create_gateway_route": {
+			Name:        "create_gateway_route",
+			Description: "Create or update a Gateway API HTTPRoute or GRPCRoute attached to a Gateway, either from hostnames, a match and weighted backend Services or from a full spec",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the route",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the route (default: default)",
+					Default:     jsonString("default"),
+				},
+				"kind": {
+					Type:        "string",
+					Description: "Route kind (default: HTTPRoute)",
+					Default:     jsonString("HTTPRoute"),
+					Enum:        []interface{}{"HTTPRoute", "GRPCRoute"},
+				},
+				"gateway": {
+					Type:        "string",
+					Description: "Name of the parent Gateway (default: istio-ingress, the gateway install_istio deploys with gateway_mode gateway_api)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"gateway_namespace": {
+					Type:        "string",
+					Description: "Namespace of the parent Gateway (default: istio-ingress)",
+					Default:     jsonString("istio-ingress"),
+				},
+				"section_name": {
+					Type:        "string",
+					Description: "Listener of the Gateway to attach to (default: all listeners that allow the route)",
+				},
+				"hostnames": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Hostnames the route serves (default: every hostname of the listener)",
+				},
+				"backends": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "object",
+						Properties: map[string]*jsonschema.Schema{
+							"name":      {Type: "string", Description: "Backend Service"},
+							"namespace": {Type: "string", Description: "Namespace of the Service (default: the route namespace; other namespaces need a ReferenceGrant)"},
+							"port":      {Type: "integer", Description: "Service port"},
+							"weight":    {Type: "integer", Description: "Relative share of the traffic"},
+						},
+						Required: []string{"name", "port"},
+					},
+					Description: "Weighted backend Services of the route",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Path prefix an HTTPRoute matches (default: /)",
+					Default:     jsonString("/"),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Request timeout of an HTTPRoute, e.g. 5s",
+				},
+				"grpc_service": {
+					Type:        "string",
+					Description: "gRPC service a GRPCRoute matches, e.g. helloworld.Greeter (default: all services)",
+				},
+				"spec": {
+					Type:        "object",
+					Description: "Full route spec; replaces gateway, section_name, hostnames, backends, path, timeout and grpc_service",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"get_gateway_routes": {
+			Name:        "get_gateway_routes",
+			Description: "Show a Gateway API HTTPRoute or GRPCRoute, or every route in the namespace, with the Accepted and ResolvedRefs conditions each parent Gateway reports",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the route (default: all in the namespace)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the routes (default: default)",
+					Default:     jsonString("default"),
+				},
+				"kind": {
+					Type:        "string",
+					Description: "Route kind (default: both)",
+					Enum:        []interface{}{"HTTPRoute", "GRPCRoute"},
+				},
+			}, nil),
+		},
+		"delete_gateway_route": {
+			Name:        "delete_gateway_route",
+			Description: "Delete a Gateway API HTTPRoute or GRPCRoute",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the route",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the route (default: default)",
+					Default:     jsonString("default"),
+				},
+				"kind": {
+					Type:        "string",
+					Description: "Route kind (default: HTTPRoute)",
+					Default:     jsonString("HTTPRoute"),
+					Enum:        []interface{}{"HTTPRoute", "GRPCRoute"},
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate the change on the API server without persisting it (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"get_gateway_status": {
+			Name:        "get_gateway_status",
+			Description: "Report whether Gateway API Gateways are accepted and programmed, with their addresses, listener conditions and attached routes, and flag routes their gateway did not accept or whose backends do not resolve",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "Name of the Gateway (default: every Gateway)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the Gateways (default: all namespaces)",
+				},
+			}, nil),
+		},
+		"get_pod_logs": {
+			Name:        "get_pod_logs",
+			Description: "Get logs from a specific pod container",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Name of the pod to get logs from",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"container": {
+					Type:        "string",
+					Description: "Container name (optional)",
+				},
+				"lines": {
+					Type:        "integer",
+					Description: "Number of lines to tail (default: 100)",
+					Default:     jsonInt(100),
+					Minimum:     float64Ptr(1),
+				},
+				"since": {
+					Type:        "string",
+					Description: "Only return lines newer than this duration, e.g. 30m or 1h",
+				},
+				"previous": {
+					Type:        "boolean",
+					Description: "Get the logs of the previous, terminated container instance (default: false)",
+					Default:     jsonBool(false),
+				},
+				"parse_logs": {
+					Type:        "boolean",
+					Description: "Parse JSON and Envoy access log lines into structured entries (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timestamps": {
+					Type:        "boolean",
+					Description: "Prefix each line with its timestamp; always enabled",
+					Default:     jsonBool(true),
+				},
+				"follow": {
+					Type:        "boolean",
+					Description: "Follow the log for duration, sending new lines as progress notifications (or log messages without a progress token) while the call runs; the result holds every line read (default: false)",
+					Default:     jsonBool(false),
+				},
+				"duration": {
+					Type:        "string",
+					Description: "How long to follow the log, at most 10m (default: 1m)",
+					Default:     jsonString("1m"),
+				},
+				"max_lines": {
+					Type:        "integer",
+					Description: "Maximum lines returned; following stops once reached (default: 1000)",
+					Default:     jsonInt(1000),
+				},
+			}, []string{"pod_name"}),
+		},
+		"get_kubernetes_events": {
+			Name:        "get_kubernetes_events",
+			Description: "List the Kubernetes Events of a namespace, a pod, an object or an Istio component, newest first with Warning and Normal counts; the first place to look when a component isn't ready",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to list events from (default: default)",
+					Default:     jsonString("default"),
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Only events about this pod",
+				},
+				"kind": {
+					Type:        "string",
+					Description: "Only events about objects of this kind, e.g. Deployment",
+				},
+				"name": {
+					Type:        "string",
+					Description: "Only events about the object with this name",
+				},
+				"component": {
+					Type:        "string",
+					Description: "Only events about an Istio component's pods, ReplicaSets, Deployments and DaemonSets in istio_namespace; replaces namespace",
+					Enum:        []interface{}{"istiod", "gateway", "ztunnel", "cni"},
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace searched for component (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"type": {
+					Type:        "string",
+					Description: "Only Warning or Normal events",
+					Enum:        []interface{}{"Warning", "Normal"},
+				},
+				"since": {
+					Type:        "string",
+					Description: "Only events last seen within this duration, e.g. 30m",
+				},
+				"max_events": {
+					Type:        "integer",
+					Description: "Maximum number of events returned, most recent first (default: 100)",
+					Default:     jsonInt(100),
+				},
+			}, nil),
+		},
+		"get_istio_proxy_logs": {
+			Name:        "get_istio_proxy_logs",
+			Description: "Get Istio sidecar proxy logs from a pod",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Name of the pod to get Istio proxy logs from",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"lines": {
+					Type:        "integer",
+					Description: "Number of lines to tail (default: 100)",
+					Default:     jsonInt(100),
+					Minimum:     float64Ptr(1),
+				},
+				"since": {
+					Type:        "string",
+					Description: "Only return lines newer than this duration, e.g. 30m or 1h",
+				},
+				"log_level": {
+					Type:        "string",
+					Description: "Log level of interest, e.g. warning or error; it is recorded in the server log, the returned lines are not filtered",
+				},
+			}, []string{"pod_name"}),
+		},
+		"get_istiod_logs": {
+			Name:        "get_istiod_logs",
+			Description: "Get the logs of the istiod pods, keeping the lines of the given logging scopes at or above a level; the control plane counterpart of get_istio_proxy_logs",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"scopes": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "istiod logging scopes to keep, e.g. ads, validation or injection (default: all)",
+				},
+				"level": {
+					Type:        "string",
+					Description: "Minimum level of the lines kept (default: info)",
+					Default:     jsonString("info"),
+					Enum:        []interface{}{"debug", "info", "warn", "error", "fatal"},
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Only istiod pods of this revision (default: every revision)",
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Only this istiod pod",
+				},
+				"since": {
+					Type:        "string",
+					Description: "Only lines logged within this duration (default: 1h)",
+					Default:     jsonString("1h"),
+				},
+				"lines": {
+					Type:        "integer",
+					Description: "Lines read per pod before filtering (default: 2000)",
+					Default:     jsonInt(2000),
+					Minimum:     float64Ptr(1),
+				},
+				"max_lines": {
+					Type:        "integer",
+					Description: "Newest matching lines returned per pod (default: 200)",
+					Default:     jsonInt(200),
+					Minimum:     float64Ptr(1),
+				},
+			}, nil),
+		},
+		"analyze_response_flags": {
+			Name:        "analyze_response_flags",
+			Description: "Aggregate the Envoy response flags (NR, UH, UF, UO, URX, DC, ...) in the access logs of a namespace's sidecar and gateway proxies over a time window, returning counts per flag with status codes, pods, upstream clusters, a plain-English explanation and likely causes",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the proxies to analyze (default: default)",
+					Default:     jsonString("default"),
+				},
+				"selector": {
+					Type:        "string",
+					Description: "Label selector to limit the pods, e.g. app=reviews",
+				},
+				"since": {
+					Type:        "string",
+					Description: "Window of access logs to analyze as a duration like 10m or 1h (default: 10m)",
+					Default:     jsonString("10m"),
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod, used to check whether access logging is enabled (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+			}, nil),
+		},
+		"exec_pod_command": {
+			Name:        "exec_pod_command",
+			Description: "Execute a command inside a pod container",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Name of the pod to execute command in",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"container": {
+					Type:        "string",
+					Description: "Container name (optional)",
+				},
+				"command": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Command to execute as array of strings",
+				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Timeout in seconds (default: none)",
+					Minimum:     float64Ptr(1),
+				},
+				"interactive": {
+					Type:        "boolean",
+					Description: "Not supported over MCP; commands always run without a TTY",
+				},
+			}, []string{"pod_name", "command"}),
+		},
+		"get_iptables_rules": {
+			Name:        "get_iptables_rules",
+			Description: "Get iptables rules from inside a pod",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Name of the pod to get iptables rules from",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"container": {
+					Type:        "string",
+					Description: "Container whose network namespace is inspected (default: istio-proxy, else the first container)",
+				},
+				"tables": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+						Enum: []interface{}{"filter", "nat", "mangle", "raw"},
+					},
+					Description: "Iptables tables to query (default: filter, nat and mangle)",
+				},
+				"verbose": {
+					Type:        "boolean",
+					Description: "Include packet and byte counters and rule numbers (default: false)",
+					Default:     jsonBool(false),
+				},
+				"image": {
+					Type:        "string",
+					Description: "Image of the ephemeral containers, which needs iptables-nft (default: debug.image from the config, istio/base)",
+				},
+				"profile": {
+					Type:        "string",
+					Description: "Security profile of the ephemeral containers (default: debug.profile from the config, else sysadmin)",
+					Enum:        []interface{}{"sysadmin", "netadmin"},
+				},
+			}, []string{"pod_name"}),
+		},
+		"capture_packets": {
+			Name:        "capture_packets",
+			Description: "Capture a pod's traffic with tcpdump in an ephemeral container for a number of seconds and return the pcap, base64-encoded or as an embedded resource the client can save and open in Wireshark; useful for mTLS handshakes and retry storms that logs don't show",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Name of the pod to capture traffic of",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"filter": {
+					Type:        "string",
+					Description: "BPF capture filter, e.g. \"tcp port 9080\" or \"host 10.244.1.7\" (default: all traffic)",
+				},
+				"duration": {
+					Type:        "integer",
+					Description: "Seconds to capture, 1 to 120 (default: 10)",
+					Default:     jsonInt(10),
+				},
+				"interface": {
+					Type:        "string",
+					Description: "Interface to capture on; lo carries the plaintext hop between istio-proxy and the application (default: any)",
+					Default:     jsonString("any"),
+				},
+				"snap_length": {
+					Type:        "integer",
+					Description: "Bytes captured per packet (default: 262144, the whole packet)",
+					Default:     jsonInt(262144),
+				},
+				"max_packets": {
+					Type:        "integer",
+					Description: "Stop after this many packets, at most 20000 (default: 5000)",
+					Default:     jsonInt(5000),
+				},
+				"image": {
+					Type:        "string",
+					Description: "Image of the ephemeral container, which needs sh, timeout, tcpdump and base64 (default: debug.image from the config, nicolaka/netshoot:v0.13)",
+				},
+				"profile": {
+					Type:        "string",
+					Description: "Security profile of the ephemeral container (default: debug.profile from the config, else netadmin)",
+					Enum:        []interface{}{"netadmin", "sysadmin"},
+				},
+				"output": {
+					Type:        "string",
+					Description: "Return the pcap inline as base64 or as an embedded MCP resource (default: base64)",
+					Default:     jsonString("base64"),
+					Enum:        []interface{}{"base64", "resource"},
+				},
+			}, []string{"pod_name"}),
+		},
+		"get_interception_mode": {
+			Name:        "get_interception_mode",
+			Description: "Report per pod whether traffic is intercepted by istio-init, the Istio CNI plugin or ambient, and which debugging path applies",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to inspect (default: default)",
+					Default:     jsonString("default"),
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Only report this pod",
+				},
+			}, nil),
+		},
+		"tune_proxy": {
+			Name:        "tune_proxy",
+			Description: "Set proxy concurrency, CPU/memory requests and limits and stats inclusion mesh-wide or for selected deployments, measuring sidecar CPU and latency under a fixed Fortio load before and after the change",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"concurrency": {
+					Type:        "integer",
+					Description: "Envoy worker threads; 0 starts one per node core",
+					Minimum:     float64Ptr(0),
+				},
+				"cpu_request": {
+					Type:        "string",
+					Description: "Proxy CPU request, e.g. 100m",
+				},
+				"cpu_limit": {
+					Type:        "string",
+					Description: "Proxy CPU limit, e.g. 2",
+				},
+				"memory_request": {
+					Type:        "string",
+					Description: "Proxy memory request, e.g. 128Mi",
+				},
+				"memory_limit": {
+					Type:        "string",
+					Description: "Proxy memory limit, e.g. 1Gi",
+				},
+				"stats_inclusion_prefixes": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Extra Envoy stats prefixes to keep, e.g. cluster.outbound or upstream_cx",
+				},
+				"stats_inclusion_regexps": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Extra Envoy stats to keep, as regular expressions",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the deployments (default: default)",
+					Default:     jsonString("default"),
+				},
+				"deployments": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Tune only these deployments through pod annotations (default: mesh-wide through the istiod Helm values)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"release": {
+					Type:        "string",
+					Description: "istiod Helm release (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config is read",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio chart repository override",
+				},
+				"measure": {
+					Type:        "boolean",
+					Description: "Measure sidecar CPU under Fortio load before and after the change (default: true)",
+					Default:     jsonBool(true),
+				},
+				"benchmark_namespace": {
+					Type:        "string",
+					Description: "Temporary namespace for the Fortio client and server (default: meshpilot-bench)",
+					Default:     jsonString("meshpilot-bench"),
+				},
+				"qps": {
+					Type:        "integer",
+					Description: "Requests per second of the load (default: 1000)",
+					Default:     jsonInt(1000),
+				},
+				"connections": {
+					Type:        "integer",
+					Description: "Concurrent connections of the load (default: 16)",
+					Default:     jsonInt(16),
+				},
+				"duration": {
+					Type:        "string",
+					Description: "Duration of each load run (default: 30s)",
+					Default:     jsonString("30s"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only report the current settings (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for the Helm upgrade and rollouts (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"configure_dns_proxying": {
+			Name:        "configure_dns_proxying",
+			Description: "Turn sidecar DNS proxying (ISTIO_META_DNS_CAPTURE) and ServiceEntry address auto-allocation (ISTIO_META_DNS_AUTO_ALLOCATE) on or off mesh-wide or for selected deployments, explain the impact, and check DNS interception from a workload before and after by resolving probe ServiceEntry hosts",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"dns_capture": {
+					Type:        "boolean",
+					Description: "Let sidecars answer DNS queries for mesh hosts (default: true)",
+					Default:     jsonBool(true),
+				},
+				"auto_allocate": {
+					Type:        "boolean",
+					Description: "Give ServiceEntries without addresses a virtual IP; requires dns_capture (default: true)",
+					Default:     jsonBool(true),
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the deployments and the source deployment (default: default)",
+					Default:     jsonString("default"),
+				},
+				"deployments": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Configure only these deployments through the proxy.istio.io/config annotation (default: mesh-wide through meshConfig.defaultConfig.proxyMetadata)",
+				},
+				"source_deployment": {
+					Type:        "string",
+					Description: "Deployment with a sleep container to resolve the probe hosts from; restarted after a mesh-wide change (default: sleep)",
+					Default:     jsonString("sleep"),
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"release": {
+					Type:        "string",
+					Description: "Helm release name of istiod (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"verify": {
+					Type:        "boolean",
+					Description: "Resolve probe ServiceEntry hosts from the source deployment before and after the change (default: true)",
+					Default:     jsonBool(true),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only report the current settings and the impact of the change (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for the Helm upgrade and each rollout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"audit_sidecar_startup": {
+			Name:        "audit_sidecar_startup",
+			Description: "Audit sidecar workloads for startup-ordering problems where app containers, app init containers or Jobs race Envoy, report which have holdApplicationUntilProxyStarts or native sidecars enabled, and optionally enable either mesh-wide or for selected workloads",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to audit; required with workloads (default: all namespaces)",
+				},
+				"fix": {
+					Type:        "string",
+					Description: "Enable holdApplicationUntilProxyStarts (hold) or native sidecars (native, Kubernetes 1.29+); omit to only audit",
+					Enum:        []interface{}{"hold", "native"},
+				},
+				"workloads": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Apply the fix only to these workloads as Kind/name (Deployment, StatefulSet, DaemonSet or CronJob; a bare name is a Deployment) through pod template annotations (default: mesh-wide through the istiod release)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"release": {
+					Type:        "string",
+					Description: "Helm release name of istiod (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config and istiod to read (default: the default revision)",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only report the changes the fix would make (default: false)",
+					Default:     jsonBool(false),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for the Helm upgrade (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"diagnose_job_sidecars": {
+			Name:        "diagnose_job_sidecars",
+			Description: "Detect Kubernetes Jobs stuck NotReady because the istio-proxy sidecar never exits after the job's containers finish, explain the remediation options (native sidecars on supported Kubernetes, quitquitquit, injection opt-out) and optionally apply one",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the Jobs; required with job (default: all namespaces)",
+				},
+				"job": {
+					Type:        "string",
+					Description: "Only check this Job (default: all Jobs)",
+				},
+				"apply": {
+					Type:        "string",
+					Description: "Remediation to apply: quitquitquit stops istio-proxy in stuck pods, native and exclude change the pod template of the owning CronJobs; omit to only diagnose",
+					Enum:        []interface{}{"quitquitquit", "native", "exclude"},
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision (default: the default revision)",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Only report the changes the remediation would make (default: false)",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"configure_traffic_exclusions": {
+			Name:        "configure_traffic_exclusions",
+			Description: "Set sidecar interception exclusions (inbound ports, outbound ports, outbound CIDRs) on a deployment through traffic.sidecar.istio.io annotations, wait for the rollout and verify the exclusions in a new pod's iptables rules",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"deployment": {
+					Type:        "string",
+					Description: "Deployment to configure",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the deployment (default: default)",
+					Default:     jsonString("default"),
+				},
+				"exclude_inbound_ports": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "integer",
+					},
+					Description: "Inbound ports that bypass the sidecar",
+				},
+				"exclude_outbound_ports": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "integer",
+					},
+					Description: "Destination ports that bypass the sidecar",
+				},
+				"exclude_outbound_ip_ranges": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Destination CIDRs or addresses that bypass the sidecar",
+				},
+				"replace": {
+					Type:        "boolean",
+					Description: "Replace the existing exclusions instead of adding to them; with no values this clears them (default: false)",
+					Default:     jsonBool(false),
+				},
+				"verify": {
+					Type:        "boolean",
+					Description: "Check the new pod's iptables rules for the exclusions (default: true)",
+					Default:     jsonBool(true),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "How long to wait for the rollout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, []string{"deployment"}),
+		},
+		"inspect_sidecar_annotations": {
+			Name:        "inspect_sidecar_annotations",
+			Description: "List the sidecar.istio.io and traffic.sidecar.istio.io annotations on a pod or deployment, explain each one's effect and flag deprecated, invalid or conflicting annotations",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the workload (default: default)",
+					Default:     jsonString("default"),
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Pod to inspect",
+				},
+				"deployment": {
+					Type:        "string",
+					Description: "Deployment whose pod template to inspect",
+				},
+			}, nil),
+		},
+		"detect_dataplane_mode": {
+			Name:        "detect_dataplane_mode",
+			Description: "Detect per namespace whether workloads run sidecars or ambient, and warn about inconsistent or mixed states during ambient migration",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only inspect this namespace (default: all non-system namespaces)",
+				},
+				"include_system": {
+					Type:        "boolean",
+					Description: "Include kube-system and other system namespaces",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"get_proxy_config": {
+			Name:        "get_proxy_config",
+			Description: "Show the Envoy clusters, listeners, routes or endpoints of a pod's istio-proxy from its config dump, filtered by FQDN, port, direction or subset (like istioctl proxy-config)",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Pod whose istio-proxy to inspect",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"type": {
+					Type:        "string",
+					Description: "clusters, listeners, routes, endpoints or all (default: clusters)",
+					Default:     jsonString("clusters"),
+					Enum:        []interface{}{"clusters", "listeners", "routes", "endpoints", "all"},
+				},
+				"fqdn": {
+					Type:        "string",
+					Description: "Only include entries for hosts containing this, e.g. reviews.default.svc.cluster.local",
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Only include entries for this port",
+				},
+				"direction": {
+					Type:        "string",
+					Description: "Clusters only: inbound or outbound",
+					Enum:        []interface{}{"inbound", "outbound"},
+				},
+				"subset": {
+					Type:        "string",
+					Description: "Clusters only: DestinationRule subset",
+				},
+				"raw": {
+					Type:        "boolean",
+					Description: "Include the full Envoy objects of the matching clusters, listeners and routes",
+					Default:     jsonBool(false),
+				},
+			}, []string{"pod_name"}),
+		},
+		"envoy_admin_get": {
+			Name:        "envoy_admin_get",
+			Description: "Read a read-only Envoy admin endpoint (/stats, /clusters, /config_dump, /certs or /listeners) of a sidecar, gateway or waypoint, with allowed query parameters and a response size limit, for admin data no dedicated tool covers",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"pod_name": {
+					Type:        "string",
+					Description: "Pod with an istio-proxy container",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"endpoint": {
+					Type:        "string",
+					Description: "Admin endpoint to read",
+					Enum:        []interface{}{"/stats", "/clusters", "/config_dump", "/certs", "/listeners"},
+				},
+				"query": {
+					Type:        "object",
+					Description: "Query parameters: filter, format, usedonly and histogram_buckets for /stats; format for /clusters and /listeners; resource, mask, name_regex and include_eds for /config_dump",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"max_bytes": {
+					Type:        "integer",
+					Description: "Truncate the response after this many bytes, at most 1048576 (default: 65536)",
+					Default:     jsonInt(65536),
+				},
+			}, []string{"pod_name", "endpoint"}),
+		},
+		"get_ztunnel_config": {
+			Name:        "get_ztunnel_config",
+			Description: "Dump the workloads, services, policies and certificates known to the ztunnel on a node, the ambient equivalent of proxy config inspection",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"node": {
+					Type:        "string",
+					Description: "Node whose ztunnel to inspect (may be omitted on single-node clusters)",
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Inspect the ztunnel on the node running this pod",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of pod_name (default: default)",
+					Default:     jsonString("default"),
+				},
+				"section": {
+					Type:        "string",
+					Description: "summary, workloads, services, policies, certificates or all (default: summary)",
+					Default:     jsonString("summary"),
+					Enum:        []interface{}{"summary", "workloads", "services", "policies", "certificates", "all"},
+				},
+				"filter": {
+					Type:        "string",
+					Description: "Only include entries whose name, namespace, hostname or service account contains this",
+				},
+				"local_only": {
+					Type:        "boolean",
+					Description: "Only include workloads scheduled on the ztunnel's node",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"get_network_policies": {
+			Name:        "get_network_policies",
+			Description: "List Kubernetes, Cilium and Calico network policies grouped per namespace, flagging namespaces with a default-deny posture, or analyze whether traffic between two pods is allowed",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to list network policies (default: all namespaces, or default with pod_name or analyze)",
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Only list policies that select this pod",
+				},
+				"label_selector": {
+					Type:        "string",
+					Description: "Label selector to filter policies",
+				},
+				"analyze": {
+					Type:        "boolean",
+					Description: "Simulate traffic from source_pod to destination_pod/destination_service and report which rule allows or denies it",
+					Default:     jsonBool(false),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Source pod for analyze mode",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: namespace)",
+				},
+				"destination_pod": {
+					Type:        "string",
+					Description: "Destination pod for analyze mode",
+				},
+				"destination_service": {
+					Type:        "string",
+					Description: "Destination service for analyze mode; resolved to a backing pod and target port",
+				},
+				"destination_namespace": {
+					Type:        "string",
+					Description: "Namespace of the destination (default: namespace)",
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Destination port (pod port, or service port when destination_service is set)",
+				},
+				"protocol": {
+					Type:        "string",
+					Description: "Protocol for analyze mode",
+					Default:     jsonString("TCP"),
+					Enum:        []interface{}{"TCP", "UDP", "SCTP"},
+				},
+			}, nil),
+		},
+		"generate_network_policy": {
+			Name:        "generate_network_policy",
+			Description: "Generate a least-privilege NetworkPolicy for an app from observed sidecar traffic or declared intents, with a dry-run diff against existing policies",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the application (default: default)",
+					Default:     jsonString("default"),
+				},
+				"app": {
+					Type:        "string",
+					Description: "Application name; selects pods with app=<app>",
+				},
+				"pod_selector": {
+					Type:        "string",
+					Description: "Label selector for the application pods (overrides app)",
+				},
+				"from": {
+					Type:        "string",
+					Description: "Where connections come from (default: observed, or intents when intents are given)",
+					Enum:        []interface{}{"observed", "intents", "both"},
+				},
+				"intents": {
+					Type: "array",
+					Items: createObjectSchema(map[string]*jsonschema.Schema{
+						"direction": {
+							Type: "string",
+							Enum: []interface{}{"ingress", "egress"},
+						},
+						"peer_namespace": {
+							Type:        "string",
+							Description: "Namespace of the peer pods (default: namespace)",
+						},
+						"peer_selector": {
+							Type:        "string",
+							Description: "Label selector for the peer pods, e.g. app=sleep (default: all pods)",
+						},
+						"cidr": {
+							Type:        "string",
+							Description: "External peer CIDR instead of pods",
+						},
+						"port": {
+							Type:        "string",
+							Description: "Port number or named port (default: all ports)",
+						},
+						"protocol": {
+							Type:        "string",
+							Description: "Protocol (default: TCP)",
+						},
+					}, []string{"direction"}),
+					Description: "Declared connections the application accepts (ingress) or makes (egress)",
+				},
+				"since": {
+					Type:        "string",
+					Description: "How far back to read sidecar access logs (default: 1h)",
+					Default:     jsonString("1h"),
+				},
+				"include_dns": {
+					Type:        "boolean",
+					Description: "Allow egress to kube-dns on port 53",
+					Default:     jsonBool(true),
+				},
+				"policy_name": {
+					Type:        "string",
+					Description: "Name of the generated policy (default: <app>-least-privilege)",
+				},
+				"apply": {
+					Type:        "boolean",
+					Description: "Create or update the policy; otherwise only return the YAML and diff",
+					Default:     jsonBool(false),
+				},
+			}, nil),
+		},
+		"trace_network_path": {
+			Name:        "trace_network_path",
+			Description: "Trace network path between pods",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"source_pod": {
+					Type:        "string",
+					Description: "Name of the source pod",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"target_pod": {
+					Type:        "string",
+					Description: "Name of the target pod; either target_pod or target_host is required",
+				},
+				"target_namespace": {
+					Type:        "string",
+					Description: "Namespace of the target pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"target_host": {
+					Type:        "string",
+					Description: "Target IP address or host name to trace to",
+				},
+				"target_port": {
+					Type:        "integer",
+					Description: "Target port number (optional)",
+					Minimum:     float64Ptr(1),
+					Maximum:     float64Ptr(65535),
+				},
+				"max_hops": {
+					Type:        "integer",
+					Description: "Maximum number of hops (default: 30)",
+					Default:     jsonInt(30),
+					Minimum:     float64Ptr(1),
+				},
+			}, []string{"source_pod"}),
+		},
+		"validate_dual_stack": {
+			Name:        "validate_dual_stack",
+			Description: "Validate an IPv4/IPv6 dual-stack mesh: compare the IP families of node pod CIDRs, the service CIDR, Services and pods, check Istio's ISTIO_DUAL_STACK setting on istiod and the proxies, and optionally probe a Service's ClusterIPs and pod IPs over each family from a source pod",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only count Services and pods in this namespace (default: all namespaces)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Istio revision whose istiod and mesh config to check (default: the default revision)",
+				},
+				"service": {
+					Type:        "string",
+					Description: "Service to probe over each of its IP families",
+				},
+				"service_namespace": {
+					Type:        "string",
+					Description: "Namespace of the service (default: namespace, or default)",
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Service port to probe (default: first service port)",
+				},
+				"path": {
+					Type:        "string",
+					Description: "HTTP path to request (default: /)",
+					Default:     jsonString("/"),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Pod to send the probes from; it needs curl (default: first running app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the source pod (default: service_namespace)",
+				},
+			}, nil),
+		},
+		"check_clock_skew": {
+			Name:        "check_clock_skew",
+			Description: "Compare node clocks through short-lived debug pods and flag nodes whose clock is far enough from the istiod node's to make freshly issued workload certificates not yet valid or certificates and tokens expire early",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"nodes": {
+					Type:        "array",
+					Description: "Nodes to check (default: all nodes)",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"max_skew": {
+					Type:        "string",
+					Description: "Largest tolerated difference from the istiod node's clock, e.g. 500ms or 2s (default: 1s)",
+					Default:     jsonString("1s"),
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace of istiod, whose node is the reference clock (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"image": {
+					Type:        "string",
+					Description: "Image of the debug pods, which needs date (default: debug.image from the config, nicolaka/netshoot:v0.13)",
+				},
+				"debug_namespace": {
+					Type:        "string",
+					Description: "Namespace to run the debug pods in; it must allow privileged pods (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"diagnose_pod_node_network": {
+			Name:        "diagnose_pod_node_network",
+			Description: "Inspect the node side of a pod's traffic through a short-lived privileged host-network debug pod on its node: host route and veth state, bridge, forwarding sysctls, node iptables rules for the pod IP and conntrack entries; for traffic that fails although pod-level tools show nothing wrong",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
 				"pod_name": {
 					Type:        "string",
-					Description: "Name of the pod to execute command in",
+					Description: "Name of the pod whose node-side network path to inspect",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pod (default: default)",
+					Default:     jsonString("default"),
+				},
+				"image": {
+					Type:        "string",
+					Description: "Image of the node debug pod, which needs sh, ip, sysctl, iptables-save and conntrack (default: debug.image from the config, nicolaka/netshoot:v0.13)",
+				},
+				"debug_namespace": {
+					Type:        "string",
+					Description: "Namespace to run the node debug pod in; it must allow privileged pods (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, []string{"pod_name"}),
+		},
+		"setup_ext_authz": {
+			Name:        "setup_ext_authz",
+			Description: "Deploy Istio's sample ext-authz service, register it in meshConfig.extensionProviders on the istiod Helm release, protect a workload with a CUSTOM AuthorizationPolicy and verify allow/deny end to end",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the workload; the authorizer is deployed here too (default: default)",
+					Default:     jsonString("default"),
+				},
+				"workload": {
+					Type:        "string",
+					Description: "app label of the workload to protect (default: httpbin)",
+					Default:     jsonString("httpbin"),
+				},
+				"provider": {
+					Type:        "string",
+					Description: "Extension provider name (default: sample-ext-authz-http or sample-ext-authz-grpc)",
+				},
+				"protocol": {
+					Type:        "string",
+					Description: "Protocol Envoy uses to call the authorizer",
+					Enum:        []interface{}{"http", "grpc"},
+					Default:     jsonString("http"),
+				},
+				"paths": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Request paths checked by the authorizer (default: all requests)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"release": {
+					Type:        "string",
+					Description: "Helm release name of istiod (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"verify": {
+					Type:        "boolean",
+					Description: "Send allowed and denied requests from a sleep pod afterwards (default: true)",
+					Default:     jsonBool(true),
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for the authorizer rollout and the Helm upgrade (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
+		},
+		"test_ext_authz": {
+			Name:        "test_ext_authz",
+			Description: "Check that an ext_authz extension provider is configured and used by a CUSTOM AuthorizationPolicy, then send requests with x-ext-authz: allow and deny from a sleep pod and expect 200 and 403",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the protected service (default: default)",
+					Default:     jsonString("default"),
+				},
+				"service": {
+					Type:        "string",
+					Description: "Protected service (default: httpbin)",
+					Default:     jsonString("httpbin"),
+				},
+				"port": {
+					Type:        "integer",
+					Description: "Service port (default: first service port)",
+				},
+				"path": {
+					Type:        "string",
+					Description: "Request path (default: /headers)",
+					Default:     jsonString("/headers"),
+				},
+				"provider": {
+					Type:        "string",
+					Description: "Extension provider name (default: sample-ext-authz-http)",
+					Default:     jsonString("sample-ext-authz-http"),
+				},
+				"source_pod": {
+					Type:        "string",
+					Description: "Client pod (default: first app=sleep pod)",
+				},
+				"source_namespace": {
+					Type:        "string",
+					Description: "Namespace of the client pod (default: namespace)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
 				},
+				"timeout": {
+					Type:        "integer",
+					Description: "Seconds to wait for the expected allow/deny behaviour (default: 30)",
+					Default:     jsonInt(30),
+				},
+			}, nil),
+		},
+		"install_spire": {
+			Name:        "install_spire",
+			Description: "Install the SPIRE server, agents and SPIFFE CSI driver from the hardened SPIFFE Helm charts with a trust domain matching the mesh",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
 				"namespace": {
 					Type:        "string",
-					Description: "Namespace of the pod (default: default)",
-					Default:     jsonString("default"),
+					Description: "Namespace for the Helm releases (default: spire-mgmt)",
+					Default:     jsonString("spire-mgmt"),
 				},
-				"container": {
+				"server_namespace": {
 					Type:        "string",
-					Description: "Container name (optional)",
+					Description: "Namespace for the SPIRE server (default: spire-server)",
+					Default:     jsonString("spire-server"),
 				},
-				"command": {
+				"agent_namespace": {
+					Type:        "string",
+					Description: "Namespace for the SPIRE agents and CSI driver (default: spire-system)",
+					Default:     jsonString("spire-system"),
+				},
+				"trust_domain": {
+					Type:        "string",
+					Description: "SPIFFE trust domain; must match the mesh trust domain (default: cluster.local)",
+					Default:     jsonString("cluster.local"),
+				},
+				"cluster_name": {
+					Type:        "string",
+					Description: "Cluster name used in agent node attestation (default: meshpilot)",
+					Default:     jsonString("meshpilot"),
+				},
+				"version": {
+					Type:        "string",
+					Description: "Chart version (default: latest)",
+				},
+				"values": {
+					Type:        "object",
+					Description: "Extra values for the spire chart, keyed by dotted path",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Helm install timeout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "SPIRE Helm chart repository URL (default: from config)",
+				},
+			}, nil),
+		},
+		"configure_istio_spire": {
+			Name:        "configure_istio_spire",
+			Description: "Configure Istio to take workload identities from SPIRE: add a spire sidecar injection template mounting the agent socket via the SPIFFE CSI driver, align the mesh trust domain, register a ClusterSPIFFEID and optionally opt deployments in",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"trust_domain": {
+					Type:        "string",
+					Description: "Trust domain shared by SPIRE and the mesh (default: cluster.local)",
+					Default:     jsonString("cluster.local"),
+				},
+				"socket_file": {
+					Type:        "string",
+					Description: "Name of the SPIRE agent socket in the CSI volume (default: spire-agent.sock)",
+					Default:     jsonString("spire-agent.sock"),
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the deployments to opt in (default: default)",
+					Default:     jsonString("default"),
+				},
+				"deployments": {
 					Type: "array",
 					Items: &jsonschema.Schema{
 						Type: "string",
 					},
-					Description: "Command to execute as array of strings",
+					Description: "Deployments to annotate with inject.istio.io/templates: sidecar,spire (rolls their pods)",
 				},
-			}, []string{"pod_name", "command"}),
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"release": {
+					Type:        "string",
+					Description: "Helm release name of istiod (default: istiod)",
+					Default:     jsonString("istiod"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+				"repo_url": {
+					Type:        "string",
+					Description: "Istio Helm chart repository URL (default: from config)",
+				},
+				"timeout": {
+					Type:        "string",
+					Description: "Timeout for the Helm upgrade and each rollout (default: 5m)",
+					Default:     jsonString("5m"),
+				},
+			}, nil),
 		},
-		"get_iptables_rules": {
-			Name:        "get_iptables_rules",
-			Description: "Get iptables rules from inside a pod",
+		"verify_spire_identities": {
+			Name:        "verify_spire_identities",
+			Description: "Read the certificate each proxy serves and check it carries the expected SPIFFE ID and, for SPIRE-managed pods, was not issued by istiod",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to check (default: default)",
+					Default:     jsonString("default"),
+				},
 				"pod_name": {
 					Type:        "string",
-					Description: "Name of the pod to get iptables rules from",
+					Description: "Only check this pod (default: every pod with a sidecar)",
+				},
+				"trust_domain": {
+					Type:        "string",
+					Description: "Expected trust domain (default: the mesh trust domain)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config to read (default: the default revision)",
+				},
+			}, nil),
+		},
+		"set_mtls_mode": {
+			Name:        "set_mtls_mode",
+			Description: "Set the mTLS mode (STRICT, PERMISSIVE, DISABLE or UNSET) mesh-wide, for a namespace or for the workloads matching a selector by creating or updating the PeerAuthentication for that scope, warning about clients without a proxy when switching to STRICT",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"mode": {
+					Type:        "string",
+					Description: "mTLS mode to apply",
+					Enum:        []interface{}{"STRICT", "PERMISSIVE", "DISABLE", "UNSET"},
 				},
 				"namespace": {
 					Type:        "string",
-					Description: "Namespace of the pod (default: default)",
+					Description: "Namespace of the policy; the mesh root namespace makes it mesh-wide (default: the mesh root namespace)",
+				},
+				"selector": {
+					Type:        "object",
+					Description: "Workload labels the policy applies to, e.g. {\"app\": \"httpbin\"} (default: the whole namespace)",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"port_level": {
+					Type:        "object",
+					Description: "Per-port modes for workload policies, e.g. {\"8080\": \"PERMISSIVE\"}",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"name": {
+					Type:        "string",
+					Description: "PeerAuthentication name (default: the existing namespace-wide policy or \"default\"; for workload policies, the selector's app label)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed, used to find the mesh root namespace (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate server-side without persisting",
+					Default:     jsonBool(false),
+				},
+			}, []string{"mode"}),
+		},
+		"get_mtls_status": {
+			Name:        "get_mtls_status",
+			Description: "List the PeerAuthentications at mesh, namespace and workload scope and resolve the effective mTLS mode of every running pod, with the policy it comes from and whether a proxy enforces it",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Only report pods in this namespace (default: all namespaces)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed, used to find the mesh root namespace (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+			}, nil),
+		},
+		"get_workload_certificates": {
+			Name:        "get_workload_certificates",
+			Description: "Report the SANs, issuer, validity window and days to expiry of the certificates proxies received over SDS (workload certificate, trust anchors and, on gateways, served credentials), or of the TLS Secrets gateways reference; flags expired certificates, workload certificates that were not rotated and certificates expiring within warn_days",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the pods, or of the gateways with source gateway_secrets (default: default)",
 					Default:     jsonString("default"),
 				},
-				"table": {
+				"pod_name": {
 					Type:        "string",
-					Description: "Iptables table to query (default: filter)",
-					Default:     jsonString("filter"),
-					Enum:        []interface{}{"filter", "nat", "mangle", "raw"},
+					Description: "Only read this pod's proxy (default: every pod with a proxy)",
 				},
-			}, []string{"pod_name"}),
+				"source": {
+					Type:        "string",
+					Description: "Read the certificates from the proxies' SDS secrets, or from the Secrets referenced by Istio Gateways and Gateway API Gateways (default: proxy)",
+					Default:     jsonString("proxy"),
+					Enum:        []interface{}{"proxy", "gateway_secrets"},
+				},
+				"warn_days": {
+					Type:        "integer",
+					Description: "Flag CA and gateway certificates expiring within this many days; workload certificates are flagged when they miss their rotation (default: 30)",
+					Default:     jsonInt(30),
+				},
+			}, nil),
 		},
-		"get_network_policies": {
-			Name:        "get_network_policies",
-			Description: "List Kubernetes network policies",
+		"inspect_trust_domain": {
+			Name:        "inspect_trust_domain",
+			Description: "Report the mesh trust domain and its aliases, check that each proxy's certificate carries the SPIFFE ID expected from the trust domain, namespace and service account and that its istio-token audience is one istiod accepts, and compare trust domain, mesh ID and root certificate with other clusters sharing the mesh",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
 				"namespace": {
 					Type:        "string",
-					Description: "Namespace to list network policies (default: all namespaces)",
+					Description: "Namespace of the workloads to check (default: default)",
+					Default:     jsonString("default"),
+				},
+				"pod_name": {
+					Type:        "string",
+					Description: "Only check this pod (default: every pod with a sidecar)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+				"revision": {
+					Type:        "string",
+					Description: "Control plane revision whose mesh config is read",
+				},
+				"contexts": {
+					Type:        "array",
+					Description: "Kubeconfig contexts of the other clusters sharing the mesh, to compare trust domains and roots of trust with",
+					Items:       &jsonschema.Schema{Type: "string"},
 				},
 			}, nil),
 		},
-		"trace_network_path": {
-			Name:        "trace_network_path",
-			Description: "Trace network path between pods",
+		"check_mtls_between": {
+			Name:        "check_mtls_between",
+			Description: "Determine whether traffic from a source pod to a destination pod or service is actually mTLS encrypted: resolve the PeerAuthentication and DestinationRule in effect, read the client proxy's TLS handshake stats and optionally sample the wire with tcpdump",
 			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
 				"source_pod": {
 					Type:        "string",
-					Description: "Name of the source pod",
+					Description: "Name of the client pod",
 				},
 				"source_namespace": {
 					Type:        "string",
-					Description: "Namespace of the source pod (default: default)",
+					Description: "Namespace of the client pod (default: namespace)",
+				},
+				"destination_pod": {
+					Type:        "string",
+					Description: "Name of the server pod; set this or destination_service",
+				},
+				"destination_service": {
+					Type:        "string",
+					Description: "Name of the server service, resolved to one of its running pods; set this or destination_pod",
+				},
+				"destination_namespace": {
+					Type:        "string",
+					Description: "Namespace of the server (default: namespace)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of both workloads unless set separately (default: default)",
 					Default:     jsonString("default"),
 				},
-				"target_ip": {
+				"port": {
+					Type:        "integer",
+					Description: "Pod port with destination_pod, service port with destination_service; optional for single-port services",
+				},
+				"istio_namespace": {
 					Type:        "string",
-					Description: "Target IP address to trace to",
+					Description: "Namespace where istiod is installed, used to find the mesh root namespace and mesh config (default: istio-system)",
+					Default:     jsonString("istio-system"),
 				},
-				"target_port": {
+				"capture": {
+					Type:        "boolean",
+					Description: "Also sample the source's packets on the destination with tcpdump in an ephemeral container and count TLS records; send traffic while it runs",
+					Default:     jsonBool(false),
+				},
+				"duration": {
 					Type:        "integer",
-					Description: "Target port number (optional)",
+					Description: "Seconds to capture, 1 to 120 (default: 10)",
+					Default:     jsonInt(10),
+				},
+				"image": {
+					Type:        "string",
+					Description: "Image of the ephemeral container, which needs sh, timeout and tcpdump (default: debug.image from the config, nicolaka/netshoot:v0.13)",
+				},
+				"profile": {
+					Type:        "string",
+					Description: "Security profile of the ephemeral container (default: debug.profile from the config, else netadmin)",
+					Enum:        []interface{}{"netadmin", "sysadmin"},
+				},
+			}, []string{"source_pod"}),
+		},
+		"create_authorization_policy": {
+			Name:        "create_authorization_policy",
+			Description: "Create or update an Istio AuthorizationPolicy, either from an action, workload selector and rules or from a full spec, validated against the Istio API",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "AuthorizationPolicy name",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace of the policy; the mesh root namespace makes it mesh-wide (default: default)",
+					Default:     jsonString("default"),
+				},
+				"action": {
+					Type:        "string",
+					Description: "Action taken when a rule matches (default: ALLOW)",
+					Enum:        []interface{}{"ALLOW", "DENY", "AUDIT", "CUSTOM"},
+					Default:     jsonString("ALLOW"),
+				},
+				"selector": {
+					Type:        "object",
+					Description: "Workload labels the policy applies to, e.g. {\"app\": \"httpbin\"} (default: the whole namespace)",
+					AdditionalProperties: &jsonschema.Schema{
+						Type: "string",
+					},
+				},
+				"rules": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "object",
+					},
+					Description: "Istio rules with from, to and when, e.g. [{\"from\":[{\"source\":{\"namespaces\":[\"frontend\"]}}],\"to\":[{\"operation\":{\"methods\":[\"GET\"]}}]}]; [] denies every request for ALLOW, [{}] matches every request",
+				},
+				"provider": {
+					Type:        "string",
+					Description: "Extension provider from meshConfig for CUSTOM policies",
+				},
+				"spec": {
+					Type:        "object",
+					Description: "Full AuthorizationPolicy spec; replaces action, selector, rules and provider",
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate server-side without persisting",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"get_authorization_policy": {
+			Name:        "get_authorization_policy",
+			Description: "Show an AuthorizationPolicy with its spec, or every AuthorizationPolicy in the namespace when no name is given",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "AuthorizationPolicy name (default: all in the namespace)",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace (default: default)",
+					Default:     jsonString("default"),
+				},
+			}, nil),
+		},
+		"delete_authorization_policy": {
+			Name:        "delete_authorization_policy",
+			Description: "Delete an AuthorizationPolicy",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"name": {
+					Type:        "string",
+					Description: "AuthorizationPolicy name",
+				},
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace (default: default)",
+					Default:     jsonString("default"),
+				},
+				"dry_run": {
+					Type:        "boolean",
+					Description: "Validate server-side without deleting",
+					Default:     jsonBool(false),
+				},
+			}, []string{"name"}),
+		},
+		"audit_authorization_policies": {
+			Name:        "audit_authorization_policies",
+			Description: "Audit AuthorizationPolicies for allow-all and deny-all policies, selectors matching no pods, rules shadowed by a deny-all policy, duplicate rules, sources in missing namespaces and L7 rules ztunnel cannot evaluate",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"namespace": {
+					Type:        "string",
+					Description: "Namespace to audit (default: all namespaces)",
+				},
+				"istio_namespace": {
+					Type:        "string",
+					Description: "Namespace where istiod is installed, used to find the mesh root namespace (default: istio-system)",
+					Default:     jsonString("istio-system"),
+				},
+			}, nil),
+		},
+		"scan_mesh_images": {
+			Name:        "scan_mesh_images",
+			Description: "Scan the images used by istiod, gateways, ztunnel, CNI and sample apps for vulnerabilities and report CVE counts by severity per image",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"components": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Components to scan: istiod, gateway, ztunnel, cni, samples (default: all)",
+				},
+				"scanner": {
+					Type:        "string",
+					Description: "Vulnerability scanner binary (default: trivy)",
+					Default:     jsonString("trivy"),
+				},
+				"severities": {
+					Type: "array",
+					Items: &jsonschema.Schema{
+						Type: "string",
+					},
+					Description: "Severities to report, e.g. CRITICAL, HIGH (default: all)",
+				},
+				"timeout_seconds": {
+					Type:        "integer",
+					Description: "Scan timeout per image in seconds (default: 300)",
+					Default:     jsonInt(300),
+					Minimum:     float64Ptr(30),
+				},
+			}, nil),
+		},
+		"list_history": {
+			Name:        "list_history",
+			Description: "List previously recorded tool results with their parameters and timestamps, newest first",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"tool": {
+					Type:        "string",
+					Description: "Only list results of this tool",
+				},
+				"since": {
+					Type:        "string",
+					Description: "Only list results newer than a duration (e.g. 24h) or an RFC3339 timestamp",
+				},
+				"errors_only": {
+					Type:        "boolean",
+					Description: "Only list failed tool calls (default: false)",
+					Default:     jsonBool(false),
+				},
+				"limit": {
+					Type:        "integer",
+					Description: "Maximum number of results (default: 20)",
+					Default:     jsonInt(20),
 					Minimum:     float64Ptr(1),
-					Maximum:     float64Ptr(65535),
 				},
-			}, []string{"source_pod", "target_ip"}),
+			}, nil),
+		},
+		"get_result": {
+			Name:        "get_result",
+			Description: "Get the full output of a previously recorded tool result",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"id": {
+					Type:        "integer",
+					Description: "Result ID from list_history",
+					Minimum:     float64Ptr(1),
+				},
+			}, []string{"id"}),
+		},
+		"compare_with_snapshot": {
+			Name:        "compare_with_snapshot",
+			Description: "Re-run a read-only status or report tool and diff the result against a stored previous run, highlighting regressions (what changed since yesterday)",
+			InputSchema: createObjectSchema(map[string]*jsonschema.Schema{
+				"tool": {
+					Type:        "string",
+					Description: "Read-only tool to compare, e.g. check_istio_status or test_sleep_to_httpbin",
+				},
+				"args": {
+					Type:        "object",
+					Description: "Arguments for the tool; the baseline must have been recorded with the same arguments",
+				},
+				"baseline_id": {
+					Type:        "integer",
+					Description: "History result ID to compare against (default: most recent matching run)",
+					Minimum:     float64Ptr(1),
+				},
+				"older_than": {
+					Type:        "string",
+					Description: "Compare against the newest matching run older than this duration, e.g. 24h",
+				},
+			}, []string{"tool"}),
 		},
 	}
 }
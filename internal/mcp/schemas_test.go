@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"encoding/json"
+	"sort"
+	"testing"
+
+	"meshpilot/internal/tools"
+
+	"github.com/modelcontextprotocol/go-sdk/jsonschema"
+)
+
+// TestDescribedParamsMatchHandlers checks that every hand-written property documents an argument the handler
+// decodes, with the type the handler decodes it as; describeParams would otherwise drop it silently
+func TestDescribedParamsMatchHandlers(t *testing.T) {
+	described := describedTools()
+	names := make([]string, 0, len(described))
+	for name := range described {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			doc := described[name].InputSchema
+			params := tools.ParamsType(name)
+			if params == nil {
+				if doc != nil && len(doc.Properties) > 0 {
+					t.Fatalf("documents %d arguments, but the handler decodes none", len(doc.Properties))
+				}
+				return
+			}
+			if doc == nil {
+				return
+			}
+			derived := paramsSchema(params)
+			for property, propertyDoc := range doc.Properties {
+				checkDescribedProperty(t, property, derived.Properties[property], propertyDoc)
+			}
+		})
+	}
+}
+
+// checkDescribedProperty reports a documented property, item or nested property that is missing from the
+// derived schema or has another type there; derived properties of any type accept any documentation
+func checkDescribedProperty(t *testing.T, path string, derived, doc *jsonschema.Schema) {
+	t.Helper()
+	if derived == nil {
+		t.Errorf("%s is documented but not decoded by the handler", path)
+		return
+	}
+	if derived.Type == "" {
+		return
+	}
+	if doc.Type != derived.Type {
+		t.Errorf("%s is documented as %q but decoded as %q", path, doc.Type, derived.Type)
+		return
+	}
+	if doc.Items != nil {
+		checkDescribedProperty(t, path+"[]", derived.Items, doc.Items)
+	}
+	for name, nested := range doc.Properties {
+		if derived.Properties == nil {
+			// Maps decode any property name
+			if derived.AdditionalProperties != nil {
+				checkDescribedProperty(t, path+"."+name, derived.AdditionalProperties, nested)
+			}
+			continue
+		}
+		checkDescribedProperty(t, path+"."+name, derived.Properties[name], nested)
+	}
+}
+
+// TestToolDefinitionsResolve checks that every published input schema resolves the way the SDK resolves it
+// when the tool is added, defaults included, and that enums and bounds fit the type of their property
+func TestToolDefinitionsResolve(t *testing.T) {
+	defs := GetToolDefinitions()
+	names := make([]string, 0, len(defs))
+	for name := range defs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		t.Run(name, func(t *testing.T) {
+			schema := defs[name].InputSchema
+			if schema == nil {
+				t.Fatal("no input schema")
+			}
+			if _, err := schema.Resolve(&jsonschema.ResolveOptions{ValidateDefaults: true}); err != nil {
+				t.Fatalf("input schema does not resolve: %v", err)
+			}
+			for property, propertySchema := range schema.Properties {
+				checkPropertyValues(t, property, propertySchema)
+			}
+		})
+	}
+}
+
+// checkPropertyValues reports enum values and bounds that don't fit the type of a property, its items or its
+// nested properties
+func checkPropertyValues(t *testing.T, path string, schema *jsonschema.Schema) {
+	t.Helper()
+	numeric := schema.Type == "integer" || schema.Type == "number"
+	if (schema.Minimum != nil || schema.Maximum != nil) && !numeric {
+		t.Errorf("%s has bounds but is of type %q", path, schema.Type)
+	}
+	if schema.Minimum != nil && schema.Maximum != nil && *schema.Minimum > *schema.Maximum {
+		t.Errorf("%s has minimum %v above maximum %v", path, *schema.Minimum, *schema.Maximum)
+	}
+	for _, value := range schema.Enum {
+		if valueType := jsonType(value); schema.Type != "" && valueType != schema.Type && !(schema.Type == "number" && valueType == "integer") {
+			t.Errorf("%s enum value %v is of type %q, not %q", path, value, valueType, schema.Type)
+		}
+	}
+	if schema.Items != nil {
+		checkPropertyValues(t, path+"[]", schema.Items)
+	}
+	for name, nested := range schema.Properties {
+		checkPropertyValues(t, path+"."+name, nested)
+	}
+}
+
+// jsonType returns the JSON schema type of a value as it arrives, decoded from JSON
+func jsonType(value any) string {
+	data, _ := json.Marshal(value)
+	var decoded any
+	_ = json.Unmarshal(data, &decoded)
+	switch v := decoded.(type) {
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64:
+		if v == float64(int64(v)) {
+			return "integer"
+		}
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	default:
+		return "null"
+	}
+}
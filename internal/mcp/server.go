@@ -37,12 +37,21 @@ func NewServer(name, version string, toolManager *tools.Manager) *Server {
 	// Register all tools
 	toolWrapper.RegisterAllTools(mcpServer)
 
+	// Register read-only cluster state resources
+	RegisterResources(mcpServer, toolManager)
+
 	return &Server{
 		mcpServer:   mcpServer,
 		toolWrapper: toolWrapper,
 	}
 }
 
+// ProbePermissions disables the tools the current credentials cannot run; only server modes need it, so
+// one-shot commands skip the SelfSubjectAccessReviews
+func (s *Server) ProbePermissions(ctx context.Context) {
+	s.toolWrapper.ProbePermissions(ctx)
+}
+
 // Serve starts the MCP server using stdio transport
 func (s *Server) Serve(ctx context.Context) error {
 	// Disable logrus output to avoid interfering with MCP protocol
@@ -0,0 +1,313 @@
+package tools
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// credentialExpiryWarning is how close to expiry a client certificate or token must be to be reported
+const credentialExpiryWarning = 7 * 24 * time.Hour
+
+// ContextAccess is the health of a single kubeconfig context
+type ContextAccess struct {
+	Context       string          `json:"context"`
+	Current       bool            `json:"current,omitempty"`
+	Server        string          `json:"server,omitempty"`
+	Status        string          `json:"status"` // healthy, degraded, unreachable or invalid
+	ServerVersion string          `json:"server_version,omitempty"`
+	Latency       string          `json:"latency,omitempty"`
+	Credential    string          `json:"credential,omitempty"` // client certificate, token, exec plugin, auth provider or none
+	CredentialExp *time.Time      `json:"credential_expires,omitempty"`
+	CAExpiry      *time.Time      `json:"ca_expires,omitempty"`
+	Permissions   map[string]bool `json:"permissions,omitempty"`
+	Issues        []string        `json:"issues,omitempty"`
+}
+
+// AccessReport summarizes kubeconfig and credential health across contexts
+type AccessReport struct {
+	Kubeconfig []string         `json:"kubeconfig"`
+	InCluster  bool             `json:"in_cluster"`
+	Summary    map[string]int   `json:"summary"`
+	Contexts   []*ContextAccess `json:"contexts"`
+	Issues     []string         `json:"issues,omitempty"`
+	Timestamp  time.Time        `json:"timestamp"`
+}
+
+// validateAccessParams are the arguments of validate_access
+type validateAccessParams struct {
+	Context string `json:"context,omitempty"` // default: all contexts
+	Timeout int    `json:"timeout,omitempty"` // seconds per context, default: 5
+}
+
+// ValidateAccess checks that each kubeconfig context parses, reaches its API server, holds unexpired
+// credentials and can list basic resources
+func (m *Manager) ValidateAccess(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params validateAccessParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Timeout <= 0 {
+		params.Timeout = 5
+	}
+
+	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+	report := &AccessReport{
+		Kubeconfig: loadingRules.GetLoadingPrecedence(),
+		Summary:    make(map[string]int),
+		Timestamp:  time.Now(),
+	}
+	if _, err := rest.InClusterConfig(); err == nil {
+		report.InCluster = true
+	}
+
+	raw, err := loadingRules.Load()
+	if err != nil {
+		report.Issues = append(report.Issues, fmt.Sprintf("Failed to parse kubeconfig: %v", err))
+	} else if len(raw.Contexts) == 0 && !report.InCluster {
+		report.Issues = append(report.Issues, fmt.Sprintf("No contexts found in %s", strings.Join(report.Kubeconfig, ", ")))
+	}
+
+	if raw != nil {
+		names := make([]string, 0, len(raw.Contexts))
+		for name := range raw.Contexts {
+			if params.Context == "" || params.Context == name {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		if params.Context != "" && len(names) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Context %s not found in kubeconfig", params.Context),
+					},
+				},
+			}, nil
+		}
+
+		for _, name := range names {
+			access := validateContext(ctx, raw, name, time.Duration(params.Timeout)*time.Second)
+			report.Contexts = append(report.Contexts, access)
+			report.Summary[access.Status]++
+			for _, issue := range access.Issues {
+				report.Issues = append(report.Issues, fmt.Sprintf("%s: %s", name, issue))
+			}
+		}
+	}
+
+	if m.k8sClient == nil && report.Summary["healthy"] > 0 {
+		report.Issues = append(report.Issues, "MeshPilot started without a Kubernetes client; restart it after fixing the current context")
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// validateContext checks a single context of a parsed kubeconfig
+func validateContext(ctx context.Context, raw *clientcmdapi.Config, name string, timeout time.Duration) *ContextAccess {
+	kubeContext := raw.Contexts[name]
+	access := &ContextAccess{
+		Context: name,
+		Current: name == raw.CurrentContext,
+	}
+
+	cluster, ok := raw.Clusters[kubeContext.Cluster]
+	if !ok {
+		access.Status = "invalid"
+		access.Issues = append(access.Issues, fmt.Sprintf("cluster %q is not defined", kubeContext.Cluster))
+		return access
+	}
+	access.Server = cluster.Server
+	if expiry, err := certificateExpiry(cluster.CertificateAuthorityData, cluster.CertificateAuthority); err == nil && expiry != nil {
+		access.CAExpiry = expiry
+		if time.Now().After(*expiry) {
+			access.Issues = append(access.Issues, fmt.Sprintf("cluster CA certificate expired at %s", expiry.Format(time.RFC3339)))
+		}
+	}
+
+	authInfo, ok := raw.AuthInfos[kubeContext.AuthInfo]
+	if !ok {
+		access.Status = "invalid"
+		access.Issues = append(access.Issues, fmt.Sprintf("user %q is not defined", kubeContext.AuthInfo))
+		return access
+	}
+	inspectCredential(access, authInfo)
+
+	config, err := clientcmd.NewNonInteractiveClientConfig(*raw, name, &clientcmd.ConfigOverrides{}, nil).ClientConfig()
+	if err != nil {
+		access.Status = "invalid"
+		access.Issues = append(access.Issues, fmt.Sprintf("invalid configuration: %v", err))
+		return access
+	}
+	config.Timeout = timeout
+
+	client, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		access.Status = "invalid"
+		access.Issues = append(access.Issues, fmt.Sprintf("failed to create client: %v", err))
+		return access
+	}
+
+	start := time.Now()
+	version, err := client.Discovery().ServerVersion()
+	if err != nil {
+		access.Status = "unreachable"
+		access.Issues = append(access.Issues, fmt.Sprintf("API server %s is unreachable: %v", cluster.Server, err))
+		return access
+	}
+	access.ServerVersion = version.GitVersion
+	access.Latency = time.Since(start).Round(time.Millisecond).String()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	access.Permissions = make(map[string]bool)
+	for _, p := range []permission{listNamespaces, listPods} {
+		review, err := client.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+			Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+				ResourceAttributes: &authorizationv1.ResourceAttributes{
+					Namespace: kubeContext.Namespace,
+					Verb:      p.verb,
+					Group:     p.group,
+					Resource:  p.resource,
+				},
+			},
+		}, metav1.CreateOptions{})
+		if err != nil {
+			// Authentication failures surface here: discovery is often readable anonymously
+			access.Issues = append(access.Issues, fmt.Sprintf("failed to check %q: %v", p.String(), err))
+			continue
+		}
+		access.Permissions[p.String()] = review.Status.Allowed
+		if !review.Status.Allowed {
+			access.Issues = append(access.Issues, fmt.Sprintf("credentials cannot %s", p.String()))
+		}
+	}
+
+	access.Status = "healthy"
+	if len(access.Issues) > 0 {
+		access.Status = "degraded"
+	}
+	return access
+}
+
+// inspectCredential records the credential type of a kubeconfig user and flags expired credentials
+func inspectCredential(access *ContextAccess, authInfo *clientcmdapi.AuthInfo) {
+	switch {
+	case len(authInfo.ClientCertificateData) > 0 || authInfo.ClientCertificate != "":
+		access.Credential = "client certificate"
+		expiry, err := certificateExpiry(authInfo.ClientCertificateData, authInfo.ClientCertificate)
+		if err != nil {
+			access.Issues = append(access.Issues, fmt.Sprintf("failed to read client certificate: %v", err))
+			return
+		}
+		access.CredentialExp = expiry
+	case authInfo.Token != "" || authInfo.TokenFile != "":
+		access.Credential = "token"
+		token := authInfo.Token
+		if token == "" {
+			data, err := os.ReadFile(authInfo.TokenFile)
+			if err != nil {
+				access.Issues = append(access.Issues, fmt.Sprintf("failed to read token file: %v", err))
+				return
+			}
+			token = strings.TrimSpace(string(data))
+		}
+		access.CredentialExp = tokenExpiry(token)
+	case authInfo.Exec != nil:
+		access.Credential = "exec plugin (" + authInfo.Exec.Command + ")"
+	case authInfo.AuthProvider != nil:
+		access.Credential = "auth provider (" + authInfo.AuthProvider.Name + ")"
+	case authInfo.Username != "":
+		access.Credential = "basic auth"
+	default:
+		access.Credential = "none"
+	}
+
+	if access.CredentialExp == nil {
+		return
+	}
+	remaining := time.Until(*access.CredentialExp)
+	switch {
+	case remaining <= 0:
+		access.Issues = append(access.Issues, fmt.Sprintf("%s expired at %s", access.Credential, access.CredentialExp.Format(time.RFC3339)))
+	case remaining < credentialExpiryWarning:
+		access.Issues = append(access.Issues, fmt.Sprintf("%s expires in %s", access.Credential, remaining.Round(time.Minute)))
+	}
+}
+
+// certificateExpiry returns the expiry of the first PEM certificate in inline data or a file
+func certificateExpiry(data []byte, file string) (*time.Time, error) {
+	if len(data) == 0 {
+		if file == "" {
+			return nil, nil
+		}
+		var err error
+		if data, err = os.ReadFile(file); err != nil {
+			return nil, err
+		}
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM certificate found")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &cert.NotAfter, nil
+}
+
+// tokenExpiry reads the exp claim of a JWT bearer token without verifying it; opaque tokens have no known expiry
+func tokenExpiry(token string) *time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return nil
+	}
+	expiry := time.Unix(claims.Exp, 0)
+	return &expiry
+}
@@ -0,0 +1,269 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"meshpilot/internal/config"
+
+	"github.com/sirupsen/logrus"
+)
+
+// AlertEvent is the payload sent to json webhooks when a check starts or stops failing
+type AlertEvent struct {
+	Check               string    `json:"check"`
+	Status              string    `json:"status"` // firing or resolved
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Details             []string  `json:"details,omitempty"`
+	Cluster             string    `json:"cluster,omitempty"`
+	Timestamp           time.Time `json:"timestamp"`
+}
+
+// checkState tracks the failure streak of a single check
+type checkState struct {
+	failures int
+	firing   bool
+	lastSent time.Time
+}
+
+// alerter decides when failing checks cross the threshold and delivers notifications
+type alerter struct {
+	cfg      config.AlertsConfig
+	cooldown time.Duration
+	client   *http.Client
+
+	mu     sync.Mutex
+	checks map[string]*checkState
+}
+
+// newAlerter creates an alerter from the alerts configuration
+func newAlerter(cfg config.AlertsConfig) *alerter {
+	cooldown, err := time.ParseDuration(cfg.Cooldown)
+	if err != nil {
+		logrus.Warnf("Invalid alerts cooldown %q, using %s: %v", cfg.Cooldown, config.DefaultAlertCooldown, err)
+		cooldown, _ = time.ParseDuration(config.DefaultAlertCooldown)
+	}
+	return &alerter{
+		cfg:      cfg,
+		cooldown: cooldown,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		checks:   make(map[string]*checkState),
+	}
+}
+
+// enabled reports whether any webhook is configured
+func (a *alerter) enabled() bool {
+	return len(a.cfg.Webhooks) > 0
+}
+
+// isHealthTool reports whether failures of a tool should raise alerts
+func (a *alerter) isHealthTool(toolName string) bool {
+	return containsString(a.cfg.HealthTools, toolName)
+}
+
+// recordCheckOutcome updates the failure streak of a check and returns the event to send, if any
+func (a *alerter) recordCheckOutcome(check string, failed bool, details []string) *AlertEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	state, ok := a.checks[check]
+	if !ok {
+		state = &checkState{}
+		a.checks[check] = state
+	}
+
+	now := time.Now()
+	if !failed {
+		wasFiring := state.firing
+		failures := state.failures
+		state.failures = 0
+		state.firing = false
+		if wasFiring && *a.cfg.SendResolved {
+			return &AlertEvent{Check: check, Status: "resolved", ConsecutiveFailures: failures, Timestamp: now}
+		}
+		return nil
+	}
+
+	state.failures++
+	if state.failures < a.cfg.FailureThreshold {
+		return nil
+	}
+	if state.firing && now.Sub(state.lastSent) < a.cooldown {
+		return nil
+	}
+	state.firing = true
+	state.lastSent = now
+	return &AlertEvent{
+		Check:               check,
+		Status:              "firing",
+		ConsecutiveFailures: state.failures,
+		Details:             details,
+		Timestamp:           now,
+	}
+}
+
+// send delivers an alert to every configured webhook
+func (a *alerter) send(ctx context.Context, event *AlertEvent) {
+	for _, webhook := range a.cfg.Webhooks {
+		if err := a.post(ctx, webhook, event); err != nil {
+			logrus.Errorf("Failed to send alert for %s to webhook %s: %v", event.Check, webhook.Name, err)
+		}
+	}
+}
+
+// post sends an alert to a single webhook in its configured format
+func (a *alerter) post(ctx context.Context, webhook config.WebhookConfig, event *AlertEvent) error {
+	url := webhook.ResolveURL()
+	if url == "" {
+		return fmt.Errorf("webhook has no url")
+	}
+
+	var payload interface{} = event
+	if webhook.Format != "json" {
+		payload = map[string]string{"text": formatAlertText(event)}
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range webhook.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook returned HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// formatAlertText renders an alert as a Slack-compatible message
+func formatAlertText(event *AlertEvent) string {
+	var b strings.Builder
+	if event.Status == "resolved" {
+		fmt.Fprintf(&b, ":white_check_mark: *meshpilot* check `%s` recovered", event.Check)
+	} else {
+		fmt.Fprintf(&b, ":rotating_light: *meshpilot* check `%s` failed %d times in a row", event.Check, event.ConsecutiveFailures)
+	}
+	if event.Cluster != "" {
+		fmt.Fprintf(&b, " on cluster `%s`", event.Cluster)
+	}
+	for _, detail := range event.Details {
+		fmt.Fprintf(&b, "\n• %s", truncateText(detail, 300))
+	}
+	return b.String()
+}
+
+// recordCheckOutcome feeds a check result into the alerter and sends any resulting alert in the background
+func (m *Manager) recordCheckOutcome(check string, failed bool, details []string) {
+	if !m.alerts.enabled() {
+		return
+	}
+	event := m.alerts.recordCheckOutcome(check, failed, details)
+	if event == nil {
+		return
+	}
+	if m.k8sClient != nil {
+		if cluster, err := m.k8sClient.GetCurrentContext(); err == nil {
+			event.Cluster = cluster
+		}
+	}
+	go m.alerts.send(context.Background(), event)
+}
+
+// recordToolOutcome raises alerts for failures reported by explicitly-run health tools
+func (m *Manager) recordToolOutcome(toolName string, args json.RawMessage, result *CallToolResult, err error) {
+	if !m.alerts.enabled() || !m.alerts.isHealthTool(toolName) {
+		return
+	}
+
+	check := toolName
+	if compact := compactArgs(args); compact != "" {
+		check = fmt.Sprintf("%s %s", toolName, compact)
+	}
+
+	switch {
+	case err != nil:
+		m.recordCheckOutcome(check, true, []string{err.Error()})
+	case result == nil:
+		return
+	default:
+		failures := toolResultFailures(result)
+		m.recordCheckOutcome(check, len(failures) > 0, failures)
+	}
+}
+
+// toolResultFailures extracts failure details from a tool result: error results, reported
+// issues and unsuccessful test results all count as failures
+func toolResultFailures(result *CallToolResult) []string {
+	var text string
+	if len(result.Content) > 0 {
+		if tc, ok := result.Content[0].(TextContent); ok {
+			text = tc.Text
+		}
+	}
+	if result.IsError {
+		return []string{text}
+	}
+
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(text), &data); err != nil {
+		return nil
+	}
+
+	var failures []string
+	if issues, ok := data["issues"].([]interface{}); ok {
+		for _, issue := range issues {
+			failures = append(failures, fmt.Sprintf("%v", issue))
+		}
+	}
+	if success, ok := data["success"].(bool); ok && !success {
+		failures = append(failures, resultErrorDetail(data))
+	}
+	if results, ok := data["results"].([]interface{}); ok {
+		for _, item := range results {
+			if entry, ok := item.(map[string]interface{}); ok {
+				if success, ok := entry["success"].(bool); ok && !success {
+					failures = append(failures, resultErrorDetail(entry))
+				}
+			}
+		}
+	}
+	return failures
+}
+
+// resultErrorDetail describes an unsuccessful result entry
+func resultErrorDetail(entry map[string]interface{}) string {
+	if errText, ok := entry["error"].(string); ok && errText != "" {
+		return errText
+	}
+	if code, ok := entry["status_code"].(float64); ok && code > 0 {
+		return fmt.Sprintf("HTTP status %d", int(code))
+	}
+	return "check reported success=false"
+}
+
+// compactArgs renders tool arguments on one line so distinct invocations are tracked separately
+func compactArgs(args json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, args); err != nil || buf.String() == "{}" || buf.String() == "null" {
+		return ""
+	}
+	return buf.String()
+}
@@ -0,0 +1,510 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+var requestAuthenticationGVR = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "requestauthentications"}
+
+// ambientMigrationLabels are the namespace labels a sidecar-to-ambient migration changes, restored on rollback
+var ambientMigrationLabels = []string{"istio-injection", "istio.io/rev", "istio.io/dataplane-mode", "istio.io/use-waypoint"}
+
+// AmbientMigrationNamespace is the migration state of one namespace
+type AmbientMigrationNamespace struct {
+	Namespace       string              `json:"namespace"`
+	Status          string              `json:"status"` // planned, migrated, rolled_back, failed or skipped
+	PreviousLabels  map[string]string   `json:"previous_labels"`
+	L7Features      []string            `json:"l7_features,omitempty"` // resources only a waypoint can enforce in ambient
+	Waypoint        string              `json:"waypoint,omitempty"`
+	WaypointCreated bool                `json:"waypoint_created,omitempty"` // rollback deletes only waypoints it created
+	Restarted       []string            `json:"restarted,omitempty"`
+	SidecarPods     int                 `json:"sidecar_pods"`
+	AmbientPods     int                 `json:"ambient_pods"`
+	Before          map[string]string   `json:"before,omitempty"` // service:port probe state with sidecars
+	After           map[string]string   `json:"after,omitempty"`  // service:port probe state in ambient
+	Regressions     []string            `json:"regressions,omitempty"`
+	Steps           []CanaryUpgradeStep `json:"steps,omitempty"`
+	Warnings        []string            `json:"warnings,omitempty"`
+}
+
+// addStep records the outcome of a migration step
+func (n *AmbientMigrationNamespace) addStep(step, status, detail string) {
+	n.Steps = append(n.Steps, CanaryUpgradeStep{Step: step, Status: status, Detail: detail})
+}
+
+// AmbientMigrationResult is the plan, and when executed the outcome, of moving namespaces from sidecars to ambient
+type AmbientMigrationResult struct {
+	Executed   bool                        `json:"executed"`
+	Ztunnels   int                         `json:"ztunnels"`
+	Namespaces []AmbientMigrationNamespace `json:"namespaces"`
+	Issues     []string                    `json:"issues,omitempty"`
+	Notes      []string                    `json:"notes,omitempty"`
+	Timestamp  time.Time                   `json:"timestamp"`
+}
+
+// migrateToAmbientParams are the arguments of migrate_to_ambient
+type migrateToAmbientParams struct {
+	Namespaces      []string `json:"namespaces"`                 // migrated in this order
+	WaypointName    string   `json:"waypoint_name,omitempty"`    // default: waypoint
+	DeployWaypoints *bool    `json:"deploy_waypoints,omitempty"` // default: true, when L7 features need one
+	SourcePod       string   `json:"source_pod,omitempty"`       // probe source, default: first app=sleep pod of each namespace
+	SourceNamespace string   `json:"source_namespace,omitempty"` // default: the namespace being migrated
+	Rollback        *bool    `json:"rollback,omitempty"`         // default: true
+	Execute         bool     `json:"execute,omitempty"`          // default: false, only plan
+	Timeout         string   `json:"timeout,omitempty"`          // default: 5m
+}
+
+// MigrateToAmbient moves namespaces from sidecars to ambient one at a time: it deploys a waypoint where
+// L7 features need one, switches the namespace labels, restarts the workloads without sidecars and
+// compares service reachability before and after, rolling the namespace back when anything regressed
+func (m *Manager) MigrateToAmbient(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params migrateToAmbientParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if len(params.Namespaces) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "namespaces is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.WaypointName == "" {
+		params.WaypointName = "waypoint"
+	}
+	if params.DeployWaypoints == nil {
+		params.DeployWaypoints = boolPtr(true)
+	}
+	if params.Rollback == nil {
+		params.Rollback = boolPtr(true)
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "migrate_to_ambient")
+
+	result := &AmbientMigrationResult{
+		Executed:   params.Execute,
+		Ztunnels:   len(m.ztunnelPodsByNode(ctx)),
+		Namespaces: []AmbientMigrationNamespace{},
+		Timestamp:  time.Now(),
+	}
+	if result.Ztunnels == 0 {
+		result.Issues = append(result.Issues, "No ztunnel is running; install Istio with the ambient profile before migrating")
+		result.Executed = false
+	}
+
+	for _, name := range params.Namespaces {
+		entry := AmbientMigrationNamespace{Namespace: name, Status: "planned", PreviousLabels: map[string]string{}}
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			entry.Status = "skipped"
+			entry.Warnings = append(entry.Warnings, fmt.Sprintf("Failed to get namespace: %v", err))
+			result.Namespaces = append(result.Namespaces, entry)
+			continue
+		}
+		for _, label := range ambientMigrationLabels {
+			if value, ok := ns.Labels[label]; ok {
+				entry.PreviousLabels[label] = value
+			}
+		}
+		if ns.Labels["istio.io/dataplane-mode"] == "ambient" && namespaceInjectionRevision(ns) == "" {
+			entry.Status = "skipped"
+			entry.Warnings = append(entry.Warnings, "Namespace is already in ambient mode")
+		}
+		entry.L7Features, entry.Warnings = m.ambientL7Features(ctx, name, entry.Warnings)
+		if len(entry.L7Features) > 0 {
+			if *params.DeployWaypoints {
+				entry.Waypoint = params.WaypointName
+			} else {
+				entry.Warnings = append(entry.Warnings, "L7 features need a waypoint in ambient mode but deploy_waypoints is false; they stop being enforced")
+			}
+		}
+		if pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(name).List(ctx, metav1.ListOptions{}); err == nil {
+			for i := range pods.Items {
+				if podHasSidecar(&pods.Items[i]) && pods.Items[i].Status.Phase == corev1.PodRunning {
+					entry.SidecarPods++
+				}
+			}
+		}
+		result.Namespaces = append(result.Namespaces, entry)
+	}
+
+	if result.Executed {
+		for i := range result.Namespaces {
+			entry := &result.Namespaces[i]
+			if entry.Status == "skipped" {
+				continue
+			}
+			sourceNamespace := params.SourceNamespace
+			if sourceNamespace == "" {
+				sourceNamespace = entry.Namespace
+			}
+			m.migrateNamespaceToAmbient(ctx, entry, sourceNamespace, params.SourcePod, *params.Rollback, timeout)
+			if entry.Status != "migrated" {
+				result.Issues = append(result.Issues, fmt.Sprintf("Migration of namespace %s stopped with status %s; the remaining namespaces were not touched", entry.Namespace, entry.Status))
+				break
+			}
+		}
+	}
+
+	result.Notes = append(result.Notes,
+		"Sidecar metrics per request are replaced by ztunnel TCP metrics, and by waypoint L7 metrics only for services bound to a waypoint; update dashboards and alerts accordingly",
+		"PeerAuthentication, Telemetry and Sidecar resources scoped to workloads are not migrated automatically; review them once every namespace is ambient")
+	if !params.Execute {
+		result.Notes = append(result.Notes, "Plan only; set execute to migrate the namespaces in order")
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// migrateNamespaceToAmbient runs the migration of one namespace and rolls it back when the workloads
+// don't come up in ambient or a service that answered with sidecars stops answering
+func (m *Manager) migrateNamespaceToAmbient(ctx context.Context, entry *AmbientMigrationNamespace, sourceNamespace, sourcePod string, rollback bool, timeout time.Duration) {
+	entry.Before = m.probeNamespaceServices(ctx, entry, sourceNamespace, sourcePod)
+
+	fail := func(step, detail string) {
+		entry.addStep(step, "failed", detail)
+		entry.Status = "failed"
+		if rollback {
+			m.rollbackAmbientMigration(ctx, entry, timeout)
+		}
+	}
+
+	if entry.Waypoint != "" {
+		created, err := m.ensureWaypoint(ctx, entry.Namespace, entry.Waypoint, timeout)
+		entry.WaypointCreated = created
+		if err != nil {
+			fail("deploy waypoint", err.Error())
+			return
+		}
+		detail := "reused the existing Gateway " + entry.Waypoint
+		if created {
+			detail = "created Gateway " + entry.Waypoint
+		}
+		entry.addStep("deploy waypoint", "done", detail)
+	}
+
+	err := m.updateNamespaceLabels(ctx, entry.Namespace, func(labels map[string]string) {
+		delete(labels, "istio-injection")
+		delete(labels, "istio.io/rev")
+		labels["istio.io/dataplane-mode"] = "ambient"
+		if entry.Waypoint != "" {
+			labels["istio.io/use-waypoint"] = entry.Waypoint
+		}
+	})
+	if err != nil {
+		fail("relabel namespace", err.Error())
+		return
+	}
+	entry.addStep("relabel namespace", "done", "istio.io/dataplane-mode=ambient, injection labels removed")
+
+	restarted, issues := m.restartSidecarWorkloads(ctx, entry.Namespace, timeout)
+	entry.Restarted = restarted
+	if len(issues) > 0 {
+		fail("restart workloads", strings.Join(issues, "; "))
+		return
+	}
+	entry.addStep("restart workloads", "done", fmt.Sprintf("%d workloads restarted", len(restarted)))
+
+	// Restarted pods must come back without sidecars and with ztunnel redirection
+	pending := ""
+	err = wait.PollUntilContextTimeout(ctx, 5*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(entry.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return false, err
+		}
+		entry.SidecarPods, entry.AmbientPods = 0, 0
+		notReady := 0
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+				continue
+			}
+			if owner := metav1.GetControllerOf(pod); owner == nil || owner.Kind == "Job" {
+				continue
+			}
+			switch {
+			case podHasSidecar(pod):
+				entry.SidecarPods++
+			case pod.Annotations["ambient.istio.io/redirection"] == "enabled":
+				entry.AmbientPods++
+			default:
+				notReady++
+			}
+		}
+		pending = fmt.Sprintf("%d sidecar pods, %d ambient pods, %d pods without redirection", entry.SidecarPods, entry.AmbientPods, notReady)
+		return entry.SidecarPods == 0 && notReady == 0, nil
+	})
+	if err != nil {
+		fail("verify ambient enrollment", pending)
+		return
+	}
+	entry.addStep("verify ambient enrollment", "done", pending)
+
+	entry.After = m.probeNamespaceServices(ctx, entry, sourceNamespace, sourcePod)
+	for key, before := range entry.Before {
+		if before == portListening && entry.After[key] != portListening {
+			entry.Regressions = append(entry.Regressions, fmt.Sprintf("%s answered with sidecars but is %s in ambient", key, entry.After[key]))
+		}
+	}
+	sort.Strings(entry.Regressions)
+	if len(entry.Regressions) > 0 {
+		fail("verify traffic parity", strings.Join(entry.Regressions, "; "))
+		return
+	}
+	if entry.Before == nil {
+		entry.addStep("verify traffic parity", "skipped", "no probe source pod")
+	} else {
+		entry.addStep("verify traffic parity", "done", fmt.Sprintf("%d service ports answer as they did with sidecars", len(entry.Before)))
+	}
+	entry.Status = "migrated"
+}
+
+// rollbackAmbientMigration restores the namespace labels, removes the waypoint the migration created and
+// restarts the workloads so they get their sidecars back
+func (m *Manager) rollbackAmbientMigration(ctx context.Context, entry *AmbientMigrationNamespace, timeout time.Duration) {
+	err := m.updateNamespaceLabels(ctx, entry.Namespace, func(labels map[string]string) {
+		for _, label := range ambientMigrationLabels {
+			delete(labels, label)
+		}
+		for label, value := range entry.PreviousLabels {
+			labels[label] = value
+		}
+	})
+	if err != nil {
+		entry.addStep("rollback labels", "failed", err.Error())
+		return
+	}
+	entry.addStep("rollback labels", "done", "previous injection labels restored")
+
+	if entry.WaypointCreated {
+		err := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(entry.Namespace).Delete(ctx, entry.Waypoint, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			entry.addStep("rollback waypoint", "failed", err.Error())
+		} else {
+			entry.addStep("rollback waypoint", "done", "deleted Gateway "+entry.Waypoint)
+		}
+	}
+
+	// Ambient pods carry no sidecar, so restartSidecarWorkloads would skip them
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(entry.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		entry.addStep("rollback restart", "failed", err.Error())
+		return
+	}
+	var failed []string
+	for i := range deployments.Items {
+		if err := m.restartDeployment(ctx, &deployments.Items[i], timeout); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", deployments.Items[i].Name, err))
+		}
+	}
+	if len(failed) > 0 {
+		entry.addStep("rollback restart", "failed", strings.Join(failed, "; "))
+		return
+	}
+	entry.addStep("rollback restart", "done", fmt.Sprintf("%d deployments restarted with sidecars", len(deployments.Items)))
+	entry.Status = "rolled_back"
+}
+
+// updateNamespaceLabels changes the labels of a namespace, retrying on conflicts
+func (m *Manager) updateNamespaceLabels(ctx context.Context, name string, mutate func(map[string]string)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if ns.Labels == nil {
+			ns.Labels = make(map[string]string)
+		}
+		mutate(ns.Labels)
+		_, err = m.k8sClient.Kubernetes.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// ensureWaypoint creates a service waypoint in the namespace unless one exists and waits for it to be
+// ready; it reports whether it created the Gateway
+func (m *Manager) ensureWaypoint(ctx context.Context, namespace, name string, timeout time.Duration) (bool, error) {
+	resource := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(namespace)
+	created := false
+	if _, err := resource.Get(ctx, name, metav1.GetOptions{}); errors.IsNotFound(err) {
+		waypoint := &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "Gateway",
+			"metadata": map[string]interface{}{
+				"name":      name,
+				"namespace": namespace,
+				"labels":    map[string]interface{}{"istio.io/waypoint-for": "service"},
+			},
+			"spec": map[string]interface{}{
+				"gatewayClassName": "istio-waypoint",
+				"listeners": []interface{}{
+					map[string]interface{}{"name": "mesh", "port": int64(15008), "protocol": "HBONE"},
+				},
+			},
+		}}
+		markManaged(ctx, waypoint)
+		if _, err := resource.Create(ctx, waypoint, metav1.CreateOptions{}); err != nil {
+			return false, fmt.Errorf("failed to create waypoint: %w", err)
+		}
+		created = true
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get waypoint: %w", err)
+	}
+
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return deployment.Status.ReadyReplicas > 0, nil
+	})
+	if err != nil {
+		return created, fmt.Errorf("waypoint %s did not become ready within %s", name, timeout)
+	}
+	return created, nil
+}
+
+// ambientL7Features lists the resources of a namespace that sidecars enforce but ztunnel cannot, so
+// they need a waypoint after the migration
+func (m *Manager) ambientL7Features(ctx context.Context, namespace string, warnings []string) ([]string, []string) {
+	var features []string
+
+	for _, gvr := range authorizationPolicyGVRs {
+		list, err := m.k8sClient.Dynamic.Resource(gvr).Namespace(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			continue
+		}
+		for _, item := range list.Items {
+			var policy waypointPolicy
+			if err := remarshal(item.Object, &policy); err != nil || !policy.hasL7Rules() {
+				continue
+			}
+			features = append(features, "AuthorizationPolicy "+item.GetName())
+			if policy.Spec.TargetRef == nil && len(policy.Spec.TargetRefs) == 0 {
+				warnings = append(warnings, fmt.Sprintf("AuthorizationPolicy %s has L7 rules and a workload selector; ztunnel would enforce it and deny the traffic it cannot evaluate, so switch it to targetRefs pointing at the services or the waypoint", item.GetName()))
+			}
+		}
+		break
+	}
+
+	if list, err := m.k8sClient.Dynamic.Resource(virtualServiceGVR).Namespace(namespace).List(ctx, metav1.ListOptions{}); err == nil {
+		for _, item := range list.Items {
+			gateways, _, _ := unstructured.NestedStringSlice(item.Object, "spec", "gateways")
+			if len(gateways) == 0 || containsString(gateways, "mesh") {
+				features = append(features, "VirtualService "+item.GetName())
+			}
+		}
+	}
+
+	if list, err := m.k8sClient.Dynamic.Resource(httpRouteGVR).Namespace(namespace).List(ctx, metav1.ListOptions{}); err == nil {
+		for _, item := range list.Items {
+			parents, _, _ := unstructured.NestedSlice(item.Object, "spec", "parentRefs")
+			for _, parent := range parents {
+				if ref, ok := parent.(map[string]interface{}); ok && ref["kind"] == "Service" {
+					features = append(features, "HTTPRoute "+item.GetName())
+					break
+				}
+			}
+		}
+	}
+
+	if list, err := m.k8sClient.Dynamic.Resource(requestAuthenticationGVR).Namespace(namespace).List(ctx, metav1.ListOptions{}); err == nil {
+		for _, item := range list.Items {
+			features = append(features, "RequestAuthentication "+item.GetName())
+		}
+	}
+
+	sort.Strings(features)
+	return features, warnings
+}
+
+// probeNamespaceServices probes every TCP port of the namespace's services from the source pod, which
+// is looked up again each time because the migration restarts it when it lives in the namespace
+func (m *Manager) probeNamespaceServices(ctx context.Context, entry *AmbientMigrationNamespace, sourceNamespace, sourcePod string) map[string]string {
+	source, err := m.findWaypointSource(ctx, sourceNamespace, sourcePod)
+	if err != nil {
+		entry.Warnings = append(entry.Warnings, fmt.Sprintf("Traffic parity was not checked: %v", err))
+		return nil
+	}
+	container := ""
+	for _, c := range source.Spec.Containers {
+		if c.Name != "istio-proxy" {
+			container = c.Name
+			break
+		}
+	}
+	services, err := m.k8sClient.Kubernetes.CoreV1().Services(entry.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		entry.Warnings = append(entry.Warnings, fmt.Sprintf("Traffic parity was not checked: %v", err))
+		return nil
+	}
+
+	states := make(map[string]string)
+	for _, svc := range services.Items {
+		if svc.Spec.Type == corev1.ServiceTypeExternalName || svc.Labels["gateway.istio.io/managed"] != "" {
+			continue
+		}
+		for _, port := range svc.Spec.Ports {
+			if port.Protocol != "" && port.Protocol != corev1.ProtocolTCP {
+				continue
+			}
+			probe := ServicePortProbe{}
+			host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+			m.probeServicePort(ctx, source, container, host, port.Port, 3, podHasSidecar(source), &probe)
+			states[fmt.Sprintf("%s:%d", svc.Name, port.Port)] = probe.State
+		}
+	}
+	return states
+}
@@ -0,0 +1,459 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// analysisLevels lists the istio_analyze message levels from most to least severe
+var analysisLevels = []string{"Error", "Warning", "Info"}
+
+// portNamePrefixes are the protocol prefixes Istio recognizes in Service port names
+var portNamePrefixes = []string{"http", "http2", "https", "grpc", "grpc-web", "mongo", "mysql", "redis", "tcp", "tls", "udp"}
+
+// AnalysisMessage is one istio_analyze finding, using the istioctl analyze message codes
+type AnalysisMessage struct {
+	Code     string `json:"code"`
+	Name     string `json:"name"`
+	Level    string `json:"level"`
+	Resource string `json:"resource"` // Kind namespace/name
+	Message  string `json:"message"`
+	DocURL   string `json:"doc_url"`
+}
+
+// IstioAnalysis is the result of istio_analyze
+type IstioAnalysis struct {
+	Namespace string            `json:"namespace"`
+	Summary   map[string]int    `json:"summary"` // messages per level
+	Messages  []AnalysisMessage `json:"messages"`
+	Notes     []string          `json:"notes,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// istioAnalyzeParams are the arguments of istio_analyze
+type istioAnalyzeParams struct {
+	Namespace      string   `json:"namespace,omitempty"`       // namespace to analyze, default: all
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	ClusterDomain  string   `json:"cluster_domain,omitempty"`  // default: cluster.local
+	MinLevel       string   `json:"min_level,omitempty"`       // Error, Warning or Info, default: Info
+	Suppress       []string `json:"suppress,omitempty"`        // message codes to leave out, e.g. IST0102
+}
+
+// IstioAnalyze checks Istio and Kubernetes configuration for the common misconfigurations istioctl analyze
+// reports: unresolved references, conflicting VirtualServices and Gateways, gateway ports missing from the
+// gateway Service, subsets and selectors matching no pods, and namespaces or pods left out of the mesh
+func (m *Manager) IstioAnalyze(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params istioAnalyzeParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.ClusterDomain == "" {
+		params.ClusterDomain = "cluster.local"
+	}
+	if params.MinLevel == "" {
+		params.MinLevel = "Info"
+	}
+
+	minLevel := -1
+	for i, level := range analysisLevels {
+		if strings.EqualFold(level, params.MinLevel) {
+			minLevel = i
+		}
+	}
+	if minLevel < 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid min_level %q: must be one of %s", params.MinLevel, strings.Join(analysisLevels, ", ")),
+				},
+			},
+		}, nil
+	}
+
+	inv, config, err := m.loadIstioAuditInventory(ctx, params.ClusterDomain)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to load Istio resources: %v", err),
+				},
+			},
+		}, nil
+	}
+	namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	analysis := &IstioAnalysis{
+		Namespace: params.Namespace,
+		Summary:   map[string]int{"Error": 0, "Warning": 0, "Info": 0},
+		Messages:  []AnalysisMessage{},
+		Notes:     config.notes,
+		Timestamp: time.Now(),
+	}
+	if analysis.Namespace == "" {
+		analysis.Namespace = "all"
+	}
+	inScope := func(namespace string) bool {
+		return params.Namespace == "" || namespace == params.Namespace
+	}
+	var messages []AnalysisMessage
+	add := func(code, name, level, kind, namespace, resourceName, message string) {
+		resource := kind + " " + resourceName
+		if namespace != "" {
+			resource = fmt.Sprintf("%s %s/%s", kind, namespace, resourceName)
+		}
+		messages = append(messages, AnalysisMessage{
+			Code:     code,
+			Name:     name,
+			Level:    level,
+			Resource: resource,
+			Message:  message,
+			DocURL:   fmt.Sprintf("https://istio.io/latest/docs/reference/config/analysis/%s/", strings.ToLower(code)),
+		})
+	}
+
+	// Unresolved references and selectors matching nothing, from the resource audit
+	findings, _ := m.auditIstioConfig(ctx, inv, config, params.Namespace)
+	for _, finding := range findings {
+		switch {
+		case finding.Severity == "broken":
+			add("IST0101", "ReferencedResourceNotFound", "Error", finding.Kind, finding.Namespace, finding.Name, finding.Problem)
+		case finding.Kind == "DestinationRule" && strings.HasPrefix(finding.Reference, "subset "):
+			add("IST0173", "DestinationRuleSubsetNotSelectPods", "Warning", finding.Kind, finding.Namespace, finding.Name, finding.Problem)
+		case finding.Kind == "Gateway" && strings.HasPrefix(finding.Reference, "selector "):
+			add("IST0101", "ReferencedResourceNotFound", "Error", finding.Kind, finding.Namespace, finding.Name, "Referenced "+finding.Reference+" not found: "+finding.Problem)
+		case strings.HasPrefix(finding.Reference, "selector "):
+			add("IST0127", "NoMatchingWorkloadsFound", "Warning", finding.Kind, finding.Namespace, finding.Name, finding.Problem)
+		}
+	}
+
+	// VirtualServices bound to the mesh gateway that define the same host conflict; only one takes effect
+	meshHosts := make(map[string][]istioMeta)
+	for _, vs := range config.virtualServices {
+		if !appliesToMesh(vs.Spec.Gateways) {
+			continue
+		}
+		seen := make(map[string]bool)
+		for _, host := range vs.Spec.Hosts {
+			host = inv.fqdn(host, vs.Metadata.Namespace)
+			if !seen[host] {
+				seen[host] = true
+				meshHosts[host] = append(meshHosts[host], vs.Metadata)
+			}
+		}
+	}
+	for host, owners := range meshHosts {
+		if len(owners) < 2 {
+			continue
+		}
+		names := make([]string, 0, len(owners))
+		for _, owner := range owners {
+			names = append(names, owner.Namespace+"/"+owner.Name)
+		}
+		sort.Strings(names)
+		for _, owner := range owners {
+			if inScope(owner.Namespace) {
+				add("IST0109", "ConflictingMeshGatewayVirtualServiceHosts", "Error", "VirtualService", owner.Namespace, owner.Name,
+					fmt.Sprintf("The VirtualServices %s associated with mesh gateway define the same host %s, which can lead to undefined behavior; merge them into one VirtualService", strings.Join(names, ", "), host))
+			}
+		}
+	}
+
+	// Gateways selecting the same workload must not claim the same port and host
+	for i, a := range config.gateways {
+	pairs:
+		for _, b := range config.gateways[i+1:] {
+			if labels.SelectorFromSet(a.Spec.Selector).String() != labels.SelectorFromSet(b.Spec.Selector).String() {
+				continue
+			}
+			for _, serverA := range a.Spec.Servers {
+				for _, serverB := range b.Spec.Servers {
+					if serverA.Port.Number != serverB.Port.Number {
+						continue
+					}
+					host := overlappingGatewayHost(serverA.Hosts, serverB.Hosts)
+					if host == "" {
+						continue
+					}
+					message := fmt.Sprintf("Conflict with Gateways %s/%s and %s/%s (workload selector %s, port %d, hosts %s)",
+						a.Metadata.Namespace, a.Metadata.Name, b.Metadata.Namespace, b.Metadata.Name,
+						labels.SelectorFromSet(a.Spec.Selector).String(), serverA.Port.Number, host)
+					for _, gw := range []istioMeta{a.Metadata, b.Metadata} {
+						if inScope(gw.Namespace) {
+							add("IST0145", "ConflictingGateways", "Error", "Gateway", gw.Namespace, gw.Name, message)
+						}
+					}
+					continue pairs
+				}
+			}
+		}
+	}
+
+	// Gateway server ports must be exposed by a Service in front of the gateway pods
+	for _, gw := range config.gateways {
+		if !inScope(gw.Metadata.Namespace) || len(gw.Spec.Selector) == 0 {
+			continue
+		}
+		ports, found := gatewayServicePorts(inv, gw.Spec.Selector)
+		if !found {
+			continue
+		}
+		reported := make(map[int]bool)
+		for _, server := range gw.Spec.Servers {
+			if ports[server.Port.Number] || reported[server.Port.Number] {
+				continue
+			}
+			reported[server.Port.Number] = true
+			add("IST0162", "GatewayPortNotDefinedOnService", "Warning", "Gateway", gw.Metadata.Namespace, gw.Metadata.Name,
+				fmt.Sprintf("The gateway is listening on a target port (port %d) that is not defined in the Service associated with its workload instances (selector %s); traffic never reaches it",
+					server.Port.Number, labels.SelectorFromSet(gw.Spec.Selector).String()))
+		}
+	}
+
+	// Namespaces that gateway routes send traffic to
+	gatewayTargets := make(map[string][]string)
+	for _, vs := range config.virtualServices {
+		var attached []string
+		for _, gateway := range vs.Spec.Gateways {
+			if gateway != "mesh" {
+				attached = append(attached, gatewayRef(gateway, vs.Metadata.Namespace))
+			}
+		}
+		if len(attached) == 0 {
+			continue
+		}
+		var destinations []istioDestination
+		for _, route := range vs.Spec.HTTP {
+			destinations = append(destinations, route.Route...)
+		}
+		for _, route := range vs.Spec.TCP {
+			destinations = append(destinations, route.Route...)
+		}
+		for _, route := range vs.Spec.TLS {
+			destinations = append(destinations, route.Route...)
+		}
+		for _, dest := range destinations {
+			svc := inv.services[inv.fqdn(dest.Destination.Host, vs.Metadata.Namespace)]
+			if svc == nil {
+				continue
+			}
+			for _, ref := range attached {
+				if !containsString(gatewayTargets[svc.Namespace], ref) {
+					gatewayTargets[svc.Namespace] = append(gatewayTargets[svc.Namespace], ref)
+				}
+			}
+		}
+	}
+
+	// Namespaces and pods left out of the mesh, and Service ports Istio cannot detect the protocol of
+	podsByNamespace := make(map[string][]*corev1.Pod)
+	for i := range inv.pods {
+		pod := &inv.pods[i]
+		podsByNamespace[pod.Namespace] = append(podsByNamespace[pod.Namespace], pod)
+	}
+	for i := range namespaces.Items {
+		ns := &namespaces.Items[i]
+		if !inScope(ns.Name) || ns.Name == params.IstioNamespace || strings.HasPrefix(ns.Name, "kube-") || len(podsByNamespace[ns.Name]) == 0 {
+			continue
+		}
+		ambient := ns.Labels["istio.io/dataplane-mode"] == "ambient"
+		injected := namespaceInjectionRevision(ns) != ""
+
+		if !injected && !ambient {
+			if targets := gatewayTargets[ns.Name]; len(targets) > 0 {
+				sort.Strings(targets)
+				add("IST0102", "NamespaceNotInjected", "Warning", "Namespace", "", ns.Name,
+					fmt.Sprintf("The namespace is not enabled for Istio injection but receives traffic from Gateway %s; its workloads have no proxy to accept mTLS or apply policy. Run 'kubectl label namespace %s istio-injection=enabled' to enable it, or 'kubectl label namespace %s istio-injection=disabled' to explicitly mark it as not needing injection",
+						strings.Join(targets, ", "), ns.Name, ns.Name))
+			} else if ns.Labels["istio-injection"] != "disabled" {
+				add("IST0102", "NamespaceNotInjected", "Info", "Namespace", "", ns.Name,
+					fmt.Sprintf("The namespace is not enabled for Istio injection. Run 'kubectl label namespace %s istio-injection=enabled' to enable it, or 'kubectl label namespace %s istio-injection=disabled' to explicitly mark it as not needing injection", ns.Name, ns.Name))
+			}
+			continue
+		}
+		if !injected {
+			continue
+		}
+
+		for _, pod := range podsByNamespace[ns.Name] {
+			if podHasSidecar(pod) || pod.Spec.HostNetwork || pod.Status.Phase != corev1.PodRunning ||
+				pod.Labels["sidecar.istio.io/inject"] == "false" || pod.Annotations["sidecar.istio.io/inject"] == "false" {
+				continue
+			}
+			add("IST0103", "PodMissingProxy", "Warning", "Pod", pod.Namespace, pod.Name,
+				"The pod is missing the Istio proxy. This can often be resolved by restarting or redeploying the workload")
+		}
+		for _, svc := range inv.services {
+			if svc.Namespace != ns.Name {
+				continue
+			}
+			for _, port := range svc.Spec.Ports {
+				if port.AppProtocol != nil || hasProtocolPrefix(port.Name) {
+					continue
+				}
+				add("IST0118", "PortNameIsNotUnderNamingConvention", "Info", "Service", svc.Namespace, svc.Name,
+					fmt.Sprintf("Port name %s (port: %d, targetPort: %s) doesn't follow the naming convention of Istio port; set appProtocol or prefix the name with the protocol, e.g. http-%s",
+						portDisplayName(port.Name), port.Port, port.TargetPort.String(), port.Name))
+			}
+		}
+	}
+
+	for _, message := range messages {
+		if containsString(params.Suppress, message.Code) {
+			continue
+		}
+		if indexOf(analysisLevels, message.Level) > minLevel {
+			continue
+		}
+		analysis.Messages = append(analysis.Messages, message)
+		analysis.Summary[message.Level]++
+	}
+	sort.SliceStable(analysis.Messages, func(i, j int) bool {
+		a, b := analysis.Messages[i], analysis.Messages[j]
+		if a.Level != b.Level {
+			return indexOf(analysisLevels, a.Level) < indexOf(analysisLevels, b.Level)
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		return a.Resource < b.Resource
+	})
+	if len(analysis.Messages) == 0 {
+		analysis.Notes = append(analysis.Notes, "No validation issues found")
+	}
+
+	resultJSON, _ := json.MarshalIndent(analysis, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// overlappingGatewayHost returns a host two Gateway servers both accept, or "" when their hosts are disjoint;
+// hosts may carry a namespace/ prefix and a leading wildcard
+func overlappingGatewayHost(a, b []string) string {
+	strip := func(host string) string {
+		if idx := strings.Index(host, "/"); idx >= 0 {
+			return host[idx+1:]
+		}
+		return host
+	}
+	matches := func(pattern, host string) bool {
+		return pattern == "*" || pattern == host ||
+			(strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]))
+	}
+	for _, hostA := range a {
+		for _, hostB := range b {
+			x, y := strip(hostA), strip(hostB)
+			if matches(x, y) {
+				return y
+			}
+			if matches(y, x) {
+				return x
+			}
+		}
+	}
+	return ""
+}
+
+// gatewayServicePorts returns the ports and target ports of the Services selecting the pods a Gateway selects,
+// reporting whether any such Service exists
+func gatewayServicePorts(inv *istioAuditInventory, selector map[string]string) (map[int]bool, bool) {
+	gatewaySelector := labels.SelectorFromSet(selector)
+	ports := make(map[int]bool)
+	found := false
+	for _, svc := range inv.services {
+		if len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		serviceSelector := labels.SelectorFromSet(svc.Spec.Selector)
+		selects := false
+		for i := range inv.pods {
+			pod := &inv.pods[i]
+			if pod.Namespace == svc.Namespace && gatewaySelector.Matches(labels.Set(pod.Labels)) && serviceSelector.Matches(labels.Set(pod.Labels)) {
+				selects = true
+				break
+			}
+		}
+		if !selects {
+			continue
+		}
+		found = true
+		for _, port := range svc.Spec.Ports {
+			ports[int(port.Port)] = true
+			if port.TargetPort.IntValue() > 0 {
+				ports[port.TargetPort.IntValue()] = true
+			}
+		}
+	}
+	return ports, found
+}
+
+// hasProtocolPrefix reports whether a Service port name is a protocol or starts with protocol-
+func hasProtocolPrefix(name string) bool {
+	protocol := strings.ToLower(name)
+	if idx := strings.Index(protocol, "-"); idx >= 0 && !strings.HasPrefix(protocol, "grpc-web") {
+		protocol = protocol[:idx]
+	} else if strings.HasPrefix(protocol, "grpc-web") {
+		protocol = "grpc-web"
+	}
+	return containsString(portNamePrefixes, protocol)
+}
+
+// portDisplayName quotes an empty port name the way it appears in the Service
+func portDisplayName(name string) string {
+	if name == "" {
+		return `""`
+	}
+	return name
+}
+
+// indexOf returns the position of a value in a list, or len(list) when it is missing
+func indexOf(list []string, value string) int {
+	for i, item := range list {
+		if item == value {
+			return i
+		}
+	}
+	return len(list)
+}
@@ -0,0 +1,596 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	apisecurityv1beta1 "istio.io/api/security/v1beta1"
+	securityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// AuthorizationPolicyFinding is a problem found by audit_authorization_policies
+type AuthorizationPolicyFinding struct {
+	Policy   string `json:"policy"` // namespace/name
+	Action   string `json:"action"`
+	Rule     *int   `json:"rule,omitempty"` // index of the offending rule, when the finding is about one rule
+	Kind     string `json:"kind"`           // allow_all, deny_all, unreachable, redundant or invalid
+	Severity string `json:"severity"`       // error, warning or info
+	Message  string `json:"message"`
+}
+
+// AuthorizationPolicyAudit is the result of audit_authorization_policies
+type AuthorizationPolicyAudit struct {
+	Namespace     string                       `json:"namespace,omitempty"`
+	RootNamespace string                       `json:"root_namespace"`
+	Policies      int                          `json:"policies"`
+	Summary       map[string]int               `json:"summary"`
+	Findings      []AuthorizationPolicyFinding `json:"findings"`
+	Timestamp     time.Time                    `json:"timestamp"`
+}
+
+// createAuthorizationPolicyParams are the arguments of create_authorization_policy
+type createAuthorizationPolicyParams struct {
+	Name      string                   `json:"name"`
+	Namespace string                   `json:"namespace,omitempty"` // default: default
+	Action    string                   `json:"action,omitempty"`    // default: ALLOW
+	Selector  map[string]string        `json:"selector,omitempty"`  // workload labels; namespace-wide when empty
+	Rules     []map[string]interface{} `json:"rules,omitempty"`     // [] denies everything for ALLOW, [{}] matches every request
+	Provider  string                   `json:"provider,omitempty"`  // extension provider for CUSTOM
+	Spec      map[string]interface{}   `json:"spec,omitempty"`      // full spec, replaces the fields above
+	DryRun    bool                     `json:"dry_run,omitempty"`   // validate server-side without persisting
+}
+
+// CreateAuthorizationPolicy creates or updates an AuthorizationPolicy from a full spec or from an action,
+// workload selector and rules
+func (m *Manager) CreateAuthorizationPolicy(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params createAuthorizationPolicyParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Action == "" {
+		params.Action = "ALLOW"
+	}
+
+	if params.Name == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "name is required",
+				},
+			},
+		}, nil
+	}
+
+	spec := params.Spec
+	if spec == nil {
+		// An ALLOW policy without rules denies every request, so an omitted rules list must not pass silently
+		if params.Rules == nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "rules is required unless spec is given; pass [] for an ALLOW policy that denies every request, or [{}] for one that matches every request",
+					},
+				},
+			}, nil
+		}
+		action := strings.ToUpper(params.Action)
+		if action == "CUSTOM" && params.Provider == "" {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "provider is required for CUSTOM policies",
+					},
+				},
+			}, nil
+		}
+		spec = map[string]interface{}{
+			"action": action,
+			"rules":  params.Rules,
+		}
+		if len(params.Selector) > 0 {
+			spec["selector"] = map[string]interface{}{"matchLabels": params.Selector}
+		}
+		if params.Provider != "" {
+			spec["provider"] = map[string]interface{}{"name": params.Provider}
+		}
+	}
+
+	ctx = withManagingTool(ctx, "create_authorization_policy")
+
+	policy := &securityv1beta1.AuthorizationPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: params.Name, Namespace: params.Namespace},
+	}
+	if err := decodeTrafficSpec(spec, &policy.Spec); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid AuthorizationPolicy spec: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	client := m.k8sClient.Istio.SecurityV1beta1().AuthorizationPolicies(params.Namespace)
+	result := &TrafficChangeResult{Action: "created", DryRun: params.DryRun}
+	existing, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		// Keep the existing metadata so labels and annotations set by others survive the update
+		existing.Spec.Reset()
+		proto.Merge(&existing.Spec, &policy.Spec)
+		policy, err = client.Update(ctx, existing, metav1.UpdateOptions{DryRun: trafficDryRun(params.DryRun)})
+		result.Action = "updated"
+	case errors.IsNotFound(err):
+		markManaged(ctx, policy)
+		policy, err = client.Create(ctx, policy, metav1.CreateOptions{DryRun: trafficDryRun(params.DryRun)})
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply AuthorizationPolicy %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+	result.Resource = authorizationPolicyResource(policy)
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getAuthorizationPolicyParams are the arguments of get_authorization_policy
+type getAuthorizationPolicyParams struct {
+	Name      string `json:"name,omitempty"`      // default: all in the namespace
+	Namespace string `json:"namespace,omitempty"` // default: default
+}
+
+// GetAuthorizationPolicy returns an AuthorizationPolicy, or every AuthorizationPolicy in a namespace when no name is given
+func (m *Manager) GetAuthorizationPolicy(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getAuthorizationPolicyParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	client := m.k8sClient.Istio.SecurityV1beta1().AuthorizationPolicies(params.Namespace)
+
+	var result interface{}
+	if params.Name != "" {
+		policy, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get AuthorizationPolicy %s/%s: %v", params.Namespace, params.Name, err),
+					},
+				},
+			}, nil
+		}
+		result = authorizationPolicyResource(policy)
+	} else {
+		list, err := client.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list AuthorizationPolicies: %v", err),
+					},
+				},
+			}, nil
+		}
+		resources := []*TrafficResource{}
+		for _, policy := range list.Items {
+			resources = append(resources, authorizationPolicyResource(policy))
+		}
+		result = resources
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// deleteAuthorizationPolicyParams are the arguments of delete_authorization_policy
+type deleteAuthorizationPolicyParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // default: default
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
+
+// DeleteAuthorizationPolicy deletes an AuthorizationPolicy
+func (m *Manager) DeleteAuthorizationPolicy(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deleteAuthorizationPolicyParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	err := m.k8sClient.Istio.SecurityV1beta1().AuthorizationPolicies(params.Namespace).Delete(ctx, params.Name, metav1.DeleteOptions{DryRun: trafficDryRun(params.DryRun)})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to delete AuthorizationPolicy %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+
+	result := &TrafficChangeResult{Action: "deleted", DryRun: params.DryRun, Kind: "AuthorizationPolicy", Name: params.Namespace + "/" + params.Name}
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// auditAuthorizationPoliciesParams are the arguments of audit_authorization_policies
+type auditAuthorizationPoliciesParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: all namespaces
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+}
+
+// AuditAuthorizationPolicies flags AuthorizationPolicies that allow or deny everything, rules that can never
+// match, and policies whose rules are shadowed by a deny-all policy on the same workloads
+func (m *Manager) AuditAuthorizationPolicies(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params auditAuthorizationPoliciesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	// Mesh-wide policies live in the root namespace, so the whole list is needed even for one namespace
+	list, err := m.k8sClient.Istio.SecurityV1beta1().AuthorizationPolicies("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list AuthorizationPolicies: %v", err),
+				},
+			},
+		}, nil
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+	namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := &AuthorizationPolicyAudit{
+		Namespace:     params.Namespace,
+		RootNamespace: m.meshRootNamespace(ctx, params.IstioNamespace),
+		Summary:       make(map[string]int),
+		Findings:      []AuthorizationPolicyFinding{},
+		Timestamp:     time.Now(),
+	}
+
+	namespaceModes := make(map[string]string)
+	for _, ns := range namespaces.Items {
+		namespaceModes[ns.Name] = ns.Labels["istio.io/dataplane-mode"]
+	}
+	var running []*corev1.Pod
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning {
+			running = append(running, &pods.Items[i])
+		}
+	}
+
+	// Policies whose DENY rule matches every request make every ALLOW rule on the same pods unreachable
+	denyAll := make(map[string]string) // pod -> namespace/name of the deny-all policy
+	for _, policy := range list.Items {
+		if policy.Spec.GetAction() != apisecurityv1beta1.AuthorizationPolicy_DENY || !hasMatchAllRule(policy) || policy.Spec.GetTargetRef() != nil {
+			continue
+		}
+		for _, pod := range policyPods(policy, running, report.RootNamespace) {
+			if _, ok := denyAll[pod.Namespace+"/"+pod.Name]; !ok {
+				denyAll[pod.Namespace+"/"+pod.Name] = policy.Namespace + "/" + policy.Name
+			}
+		}
+	}
+
+	for _, policy := range list.Items {
+		if params.Namespace != "" && policy.Namespace != params.Namespace {
+			continue
+		}
+		report.Policies++
+		report.Findings = append(report.Findings, auditAuthorizationPolicy(policy, running, namespaces.Items, namespaceModes, denyAll, report.RootNamespace)...)
+	}
+
+	sort.SliceStable(report.Findings, func(i, j int) bool { return report.Findings[i].Policy < report.Findings[j].Policy })
+	for _, finding := range report.Findings {
+		report.Summary[finding.Kind]++
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// auditAuthorizationPolicy checks one policy against the pods it selects
+func auditAuthorizationPolicy(policy *securityv1beta1.AuthorizationPolicy, pods []*corev1.Pod, namespaces []corev1.Namespace,
+	namespaceModes map[string]string, denyAll map[string]string, rootNamespace string) []AuthorizationPolicyFinding {
+	var findings []AuthorizationPolicyFinding
+	name := policy.Namespace + "/" + policy.Name
+	action := policy.Spec.GetAction()
+	add := func(rule int, kind, severity, message string) {
+		finding := AuthorizationPolicyFinding{Policy: name, Action: action.String(), Kind: kind, Severity: severity, Message: message}
+		if rule >= 0 {
+			index := rule
+			finding.Rule = &index
+		}
+		findings = append(findings, finding)
+	}
+
+	if action == apisecurityv1beta1.AuthorizationPolicy_CUSTOM && policy.Spec.GetProvider().GetName() == "" {
+		add(-1, "invalid", "error", "CUSTOM policy names no extension provider, so istiod rejects it")
+	}
+
+	rules := policy.Spec.GetRules()
+	switch {
+	case action == apisecurityv1beta1.AuthorizationPolicy_ALLOW && len(rules) == 0:
+		add(-1, "deny_all", "info", "ALLOW policy without rules matches nothing, so every request to the selected workloads is denied unless another ALLOW policy matches it")
+	case action == apisecurityv1beta1.AuthorizationPolicy_ALLOW && hasMatchAllRule(policy):
+		add(-1, "allow_all", "warning", "ALLOW policy has a rule without from, to or when, so it allows every request and makes other ALLOW policies on the same workloads irrelevant")
+	case action == apisecurityv1beta1.AuthorizationPolicy_DENY && hasMatchAllRule(policy):
+		add(-1, "deny_all", "warning", "DENY policy has a rule without from, to or when, so it denies every request to the selected workloads regardless of any ALLOW policy")
+	}
+
+	// Policies attached with targetRef are enforced by a waypoint or gateway rather than selected pods
+	if policy.Spec.GetTargetRef() != nil {
+		return findings
+	}
+
+	selected := policyPods(policy, pods, rootNamespace)
+	if len(policySelector(policy)) > 0 && len(selected) == 0 {
+		add(-1, "unreachable", "warning", fmt.Sprintf("Selector %s matches no running pods in %s, so the policy applies to nothing", labels.SelectorFromSet(policySelector(policy)), policy.Namespace))
+		return findings
+	}
+
+	if action == apisecurityv1beta1.AuthorizationPolicy_ALLOW && len(rules) > 0 && len(selected) > 0 {
+		shadowedBy := ""
+		for _, pod := range selected {
+			deny, ok := denyAll[pod.Namespace+"/"+pod.Name]
+			if !ok || deny == name {
+				shadowedBy = ""
+				break
+			}
+			shadowedBy = deny
+		}
+		if shadowedBy != "" {
+			add(-1, "unreachable", "warning", fmt.Sprintf("Every selected pod is also covered by deny-all policy %s, so no request reaches these ALLOW rules", shadowedBy))
+		}
+	}
+
+	ambient := len(selected) > 0
+	for _, pod := range selected {
+		if podInterceptionMode(pod, namespaceModes[pod.Namespace]) != "ambient" {
+			ambient = false
+			break
+		}
+	}
+
+	existing := make(map[string]bool)
+	for _, ns := range namespaces {
+		existing[ns.Name] = true
+	}
+	for i, rule := range rules {
+		duplicate := -1
+		for j := 0; j < i && duplicate < 0; j++ {
+			if proto.Equal(rule, rules[j]) {
+				duplicate = j
+			}
+		}
+		if duplicate >= 0 {
+			add(i, "redundant", "info", fmt.Sprintf("Rule is identical to rule %d", duplicate))
+			continue
+		}
+
+		for _, from := range rule.GetFrom() {
+			source := from.GetSource()
+			if len(source.GetNamespaces()) == 0 {
+				continue
+			}
+			var missing []string
+			for _, namespace := range source.GetNamespaces() {
+				if strings.Contains(namespace, "*") || existing[namespace] {
+					missing = nil
+					break
+				}
+				missing = append(missing, namespace)
+			}
+			if len(missing) > 0 {
+				add(i, "unreachable", "warning", fmt.Sprintf("Rule only matches sources in namespaces that do not exist (%s)", strings.Join(missing, ", ")))
+			}
+		}
+
+		if ambient && ruleHasL7Attributes(rule) {
+			if action == apisecurityv1beta1.AuthorizationPolicy_DENY {
+				add(i, "unreachable", "warning", "Rule uses L7 attributes but the selected pods are ambient without a targetRef, so ztunnel enforces it and denies all matching connections instead of the L7 requests; attach it to a waypoint with targetRef")
+			} else {
+				add(i, "unreachable", "warning", "Rule uses L7 attributes but the selected pods are ambient without a targetRef, so ztunnel enforces it and the rule never matches; attach it to a waypoint with targetRef")
+			}
+		}
+	}
+	return findings
+}
+
+// policyPods returns the pods a selector-based policy applies to: the root namespace without a selector covers
+// the mesh, any other namespace covers itself
+func policyPods(policy *securityv1beta1.AuthorizationPolicy, pods []*corev1.Pod, rootNamespace string) []*corev1.Pod {
+	match := labels.SelectorFromSet(policySelector(policy))
+	var selected []*corev1.Pod
+	for _, pod := range pods {
+		if policy.Namespace != rootNamespace && pod.Namespace != policy.Namespace {
+			continue
+		}
+		if match.Matches(labels.Set(pod.Labels)) {
+			selected = append(selected, pod)
+		}
+	}
+	return selected
+}
+
+// policySelector returns the workload labels a policy selects by
+func policySelector(policy *securityv1beta1.AuthorizationPolicy) map[string]string {
+	return policy.Spec.GetSelector().GetMatchLabels()
+}
+
+// hasMatchAllRule reports whether a policy has a rule without from, to or when, which matches every request
+func hasMatchAllRule(policy *securityv1beta1.AuthorizationPolicy) bool {
+	for _, rule := range policy.Spec.GetRules() {
+		if len(rule.GetFrom()) == 0 && len(rule.GetTo()) == 0 && len(rule.GetWhen()) == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// ruleHasL7Attributes reports whether a rule uses attributes only a waypoint or sidecar can evaluate
+func ruleHasL7Attributes(rule *apisecurityv1beta1.Rule) bool {
+	for _, to := range rule.GetTo() {
+		op := to.GetOperation()
+		if len(op.GetHosts())+len(op.GetNotHosts())+len(op.GetMethods())+len(op.GetNotMethods())+len(op.GetPaths())+len(op.GetNotPaths()) > 0 {
+			return true
+		}
+	}
+	for _, from := range rule.GetFrom() {
+		if len(from.GetSource().GetRequestPrincipals())+len(from.GetSource().GetNotRequestPrincipals()) > 0 {
+			return true
+		}
+	}
+	for _, when := range rule.GetWhen() {
+		if strings.HasPrefix(when.GetKey(), "request.") {
+			return true
+		}
+	}
+	return false
+}
+
+// authorizationPolicyResource converts an AuthorizationPolicy for tool output
+func authorizationPolicyResource(policy *securityv1beta1.AuthorizationPolicy) *TrafficResource {
+	spec, _ := policy.Spec.MarshalJSON()
+	return trafficResource("AuthorizationPolicy", policy.ObjectMeta, spec)
+}
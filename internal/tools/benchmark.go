@@ -0,0 +1,452 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// fortioImage is the load generator and echo server image used for benchmarks
+const fortioImage = "fortio/fortio:latest_release"
+
+// LoadResult represents the latency and throughput measured by a single Fortio run
+type LoadResult struct {
+	Target       string  `json:"target"`
+	Requests     int64   `json:"requests"`
+	ActualQPS    float64 `json:"actual_qps"`
+	P50Ms        float64 `json:"p50_ms"`
+	P90Ms        float64 `json:"p90_ms"`
+	P99Ms        float64 `json:"p99_ms"`
+	ErrorPercent float64 `json:"error_percent"`
+	ProxyCPUSecs float64 `json:"proxy_cpu_seconds,omitempty"` // client and server sidecar CPU during the run
+}
+
+// MeshOverhead summarizes the latency and CPU added by the sidecars
+type MeshOverhead struct {
+	QPS                  int        `json:"qps"`
+	Connections          int        `json:"connections"`
+	Duration             string     `json:"duration"`
+	PayloadBytes         int        `json:"payload_bytes"`
+	Baseline             LoadResult `json:"baseline"`
+	Mesh                 LoadResult `json:"mesh"`
+	AddedP50Ms           float64    `json:"added_p50_ms"`
+	AddedP99Ms           float64    `json:"added_p99_ms"`
+	ProxyMillicores      float64    `json:"proxy_millicores,omitempty"`        // average sidecar CPU during the mesh run
+	ProxyCPUPer1KRequest float64    `json:"proxy_cpu_ms_per_1k_req,omitempty"` // sidecar CPU milliseconds per 1000 requests
+	Issues               []string   `json:"issues,omitempty"`
+}
+
+// fortioReport is the subset of the Fortio JSON output used by the benchmark
+type fortioReport struct {
+	ActualQPS         float64
+	DurationHistogram struct {
+		Count       int64
+		Percentiles []struct {
+			Percentile float64
+			Value      float64
+		}
+	}
+	RetCodes map[string]int64
+}
+
+// benchmarkMeshOverheadParams are the arguments of benchmark_mesh_overhead
+type benchmarkMeshOverheadParams struct {
+	Namespace     string `json:"namespace,omitempty"`      // default: meshpilot-bench
+	QPS           int    `json:"qps,omitempty"`            // default: 1000
+	Connections   int    `json:"connections,omitempty"`    // default: 16
+	Duration      string `json:"duration,omitempty"`       // default: 30s
+	PayloadBytes  int    `json:"payload_bytes,omitempty"`  // default: 0
+	KeepResources bool   `json:"keep_resources,omitempty"` // keep the benchmark namespace afterwards
+}
+
+// BenchmarkMeshOverhead compares Fortio load with sidecars against a no-sidecar control
+func (m *Manager) BenchmarkMeshOverhead(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params benchmarkMeshOverheadParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "meshpilot-bench"
+	}
+	if params.QPS == 0 {
+		params.QPS = 1000
+	}
+	if params.Connections == 0 {
+		params.Connections = 16
+	}
+	if params.Duration == "" {
+		params.Duration = "30s"
+	}
+
+	duration, err := time.ParseDuration(params.Duration)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %q: %v", params.Duration, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "benchmark_mesh_overhead")
+
+	// Injection is controlled per deployment so both variants share the namespace
+	if err := m.createOrUpdateNamespace(ctx, params.Namespace, false); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to create benchmark namespace: %v", err),
+				},
+			},
+		}, nil
+	}
+	if !params.KeepResources {
+		defer func() {
+			if err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Delete(context.WithoutCancel(ctx), params.Namespace, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				logrus.Warnf("Failed to delete benchmark namespace: %v", err)
+			}
+		}()
+	}
+
+	for _, name := range []string{"fortio-server-mesh", "fortio-client-mesh", "fortio-server-plain", "fortio-client-plain"} {
+		if err := m.createFortioDeployment(ctx, params.Namespace, name, strings.HasSuffix(name, "-mesh")); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to deploy %s: %v", name, err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	pods, err := m.waitForBenchmarkPods(ctx, params.Namespace, 4, 3*time.Minute)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Benchmark pods did not become ready: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	overhead := &MeshOverhead{
+		QPS:          params.QPS,
+		Connections:  params.Connections,
+		Duration:     duration.String(),
+		PayloadBytes: params.PayloadBytes,
+	}
+
+	// Identical load against the control first, then through the sidecars
+	baseline, err := m.runFortioLoad(ctx, params.Namespace, pods["fortio-client-plain"], "fortio-server-plain", params.QPS, params.Connections, duration, params.PayloadBytes, nil)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Baseline load test failed: %v", err),
+				},
+			},
+		}, nil
+	}
+	overhead.Baseline = *baseline
+
+	proxyPods := []string{pods["fortio-client-mesh"], pods["fortio-server-mesh"]}
+	mesh, err := m.runFortioLoad(ctx, params.Namespace, pods["fortio-client-mesh"], "fortio-server-mesh", params.QPS, params.Connections, duration, params.PayloadBytes, proxyPods)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Mesh load test failed: %v", err),
+				},
+			},
+		}, nil
+	}
+	overhead.Mesh = *mesh
+
+	overhead.AddedP50Ms = mesh.P50Ms - baseline.P50Ms
+	overhead.AddedP99Ms = mesh.P99Ms - baseline.P99Ms
+	if mesh.ProxyCPUSecs > 0 {
+		overhead.ProxyMillicores = mesh.ProxyCPUSecs / duration.Seconds() * 1000
+		if mesh.Requests > 0 {
+			overhead.ProxyCPUPer1KRequest = mesh.ProxyCPUSecs * 1000 / float64(mesh.Requests) * 1000
+		}
+	} else {
+		overhead.Issues = append(overhead.Issues, "Sidecar CPU usage could not be read from the istio-proxy cgroup")
+	}
+	if mesh.ActualQPS < float64(params.QPS)*0.95 || baseline.ActualQPS < float64(params.QPS)*0.95 {
+		overhead.Issues = append(overhead.Issues, "Requested QPS was not reached; latency comparisons are only meaningful below saturation")
+	}
+	if mesh.ErrorPercent > 0 || baseline.ErrorPercent > 0 {
+		overhead.Issues = append(overhead.Issues, "Some requests failed during the benchmark")
+	}
+
+	resultJSON, _ := json.MarshalIndent(overhead, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// createFortioDeployment creates a Fortio server deployment and service with or without a sidecar
+func (m *Manager) createFortioDeployment(ctx context.Context, namespace, name string, sidecar bool) error {
+	replicas := int32(1)
+	labels := map[string]string{"app": name, "meshpilot.io/benchmark": "true"}
+
+	// The pod label opts each deployment in or out of injection regardless of the namespace
+	podLabels := map[string]string{"sidecar.istio.io/inject": strconv.FormatBool(sidecar)}
+	for k, v := range labels {
+		podLabels[k] = v
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"app": name},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: podLabels,
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            "fortio",
+							Image:           fortioImage,
+							Args:            []string{"server"},
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: 8080,
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	markManaged(ctx, deployment)
+	_, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: map[string]string{"app": name},
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       8080,
+					TargetPort: intstr.FromInt(8080),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+		},
+	}
+
+	markManaged(ctx, service)
+	_, err = m.k8sClient.Kubernetes.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return err
+	}
+	return nil
+}
+
+// waitForBenchmarkPods waits until count benchmark deployments have a ready pod, keyed by app
+func (m *Manager) waitForBenchmarkPods(ctx context.Context, namespace string, count int, timeout time.Duration) (map[string]string, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: "meshpilot.io/benchmark=true",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ready := make(map[string]string)
+		for _, pod := range pods.Items {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			for _, condition := range pod.Status.Conditions {
+				if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+					ready[pod.Labels["app"]] = pod.Name
+				}
+			}
+		}
+		if len(ready) == count {
+			return ready, nil
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("only %d of %d benchmark pods ready after %s", len(ready), count, timeout)
+		}
+		time.Sleep(3 * time.Second)
+	}
+}
+
+// runFortioLoad runs Fortio from a client pod against a service, measuring sidecar CPU in proxyPods
+func (m *Manager) runFortioLoad(ctx context.Context, namespace, clientPod, service string, qps, connections int, duration time.Duration, payloadBytes int, proxyPods []string) (*LoadResult, error) {
+	target := fmt.Sprintf("http://%s.%s:8080/echo", service, namespace)
+	command := []string{
+		"fortio", "load",
+		"-qps", strconv.Itoa(qps),
+		"-c", strconv.Itoa(connections),
+		"-t", duration.String(),
+		"-json", "-",
+		"-quiet",
+	}
+	if payloadBytes > 0 {
+		command = append(command, "-payload-size", strconv.Itoa(payloadBytes))
+	}
+	command = append(command, target)
+
+	cpuBefore := m.readProxyCPU(ctx, namespace, proxyPods)
+
+	output, err := m.execCommandInPod(ctx, namespace, clientPod, "fortio", command)
+	if err != nil {
+		return nil, err
+	}
+
+	cpuAfter := m.readProxyCPU(ctx, namespace, proxyPods)
+
+	// Fortio may print log lines before the JSON document
+	if idx := strings.Index(output, "{"); idx > 0 {
+		output = output[idx:]
+	}
+	var report fortioReport
+	if err := json.Unmarshal([]byte(output), &report); err != nil {
+		return nil, fmt.Errorf("failed to parse fortio output: %w", err)
+	}
+
+	result := &LoadResult{
+		Target:    target,
+		Requests:  report.DurationHistogram.Count,
+		ActualQPS: report.ActualQPS,
+	}
+	for _, percentile := range report.DurationHistogram.Percentiles {
+		switch percentile.Percentile {
+		case 50:
+			result.P50Ms = percentile.Value * 1000
+		case 90:
+			result.P90Ms = percentile.Value * 1000
+		case 99:
+			result.P99Ms = percentile.Value * 1000
+		}
+	}
+	var failed int64
+	for code, count := range report.RetCodes {
+		if code != "200" {
+			failed += count
+		}
+	}
+	if result.Requests > 0 {
+		result.ErrorPercent = float64(failed) / float64(result.Requests) * 100
+	}
+	if cpuBefore >= 0 && cpuAfter >= cpuBefore {
+		result.ProxyCPUSecs = cpuAfter - cpuBefore
+	}
+
+	return result, nil
+}
+
+// readProxyCPU returns the total CPU seconds used by the istio-proxy containers of the pods,
+// or -1 if any of them can't be read
+func (m *Manager) readProxyCPU(ctx context.Context, namespace string, pods []string) float64 {
+	if len(pods) == 0 {
+		return -1
+	}
+
+	var total float64
+	for _, pod := range pods {
+		seconds, err := m.readContainerCPUSeconds(ctx, namespace, pod, "istio-proxy")
+		if err != nil {
+			logrus.Debugf("Failed to read istio-proxy CPU usage in %s: %v", pod, err)
+			return -1
+		}
+		total += seconds
+	}
+	return total
+}
+
+// readContainerCPUSeconds reads the cumulative CPU time of a container from its cgroup
+func (m *Manager) readContainerCPUSeconds(ctx context.Context, namespace, pod, container string) (float64, error) {
+	// cgroup v2 reports usage_usec in cpu.stat
+	output, err := m.execCommandInPod(ctx, namespace, pod, container, []string{"cat", "/sys/fs/cgroup/cpu.stat"})
+	if err == nil {
+		for _, line := range strings.Split(output, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 2 && fields[0] == "usage_usec" {
+				usec, err := strconv.ParseFloat(fields[1], 64)
+				if err != nil {
+					return 0, err
+				}
+				return usec / 1e6, nil
+			}
+		}
+	}
+
+	// cgroup v1 reports nanoseconds in cpuacct.usage
+	output, err = m.execCommandInPod(ctx, namespace, pod, container, []string{"cat", "/sys/fs/cgroup/cpuacct/cpuacct.usage"})
+	if err != nil {
+		return 0, err
+	}
+	nsec, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+	if err != nil {
+		return 0, err
+	}
+	return nsec / 1e9, nil
+}
@@ -0,0 +1,530 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// bookinfoImageHub is the registry holding the Bookinfo images
+	bookinfoImageHub = "docker.io/istio"
+	// bookinfoImageTag is the Bookinfo release deployed by deploy_bookinfo_app
+	bookinfoImageTag = "1.20.2"
+	// bookinfoPort is the port every Bookinfo service listens on
+	bookinfoPort = 9080
+)
+
+// bookinfoService is one service of the Bookinfo application and the versions deployed behind it
+type bookinfoService struct {
+	Name     string
+	Versions []string
+}
+
+// bookinfoServices lists the Bookinfo services in the order they are created, backends first
+var bookinfoServices = []bookinfoService{
+	{Name: "details", Versions: []string{"v1"}},
+	{Name: "ratings", Versions: []string{"v1"}},
+	{Name: "reviews", Versions: []string{"v1", "v2", "v3"}},
+	{Name: "productpage", Versions: []string{"v1"}},
+}
+
+// deployBookinfoAppParams are the arguments of deploy_bookinfo_app
+type deployBookinfoAppParams struct {
+	Namespace       string   `json:"namespace,omitempty"`        // default: default
+	IstioInjection  bool     `json:"istio_injection,omitempty"`  // default: true
+	Replicas        int32    `json:"replicas,omitempty"`         // default: 1
+	PinDigests      bool     `json:"pin_digests,omitempty"`      // pin the images by digest
+	Subsets         *bool    `json:"subsets,omitempty"`          // default: true, create DestinationRules with v1/v2/v3 subsets
+	Gateway         bool     `json:"gateway,omitempty"`          // expose productpage through the ingress gateway
+	GatewaySelector string   `json:"gateway_selector,omitempty"` // default: istio=ingressgateway
+	Hosts           []string `json:"hosts,omitempty"`            // default: ["*"]
+}
+
+// DeployBookinfoApp deploys the Bookinfo sample application, with productpage, details, ratings and three
+// versions of reviews, plus optionally version subsets and an ingress Gateway and VirtualService
+func (m *Manager) DeployBookinfoApp(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deployBookinfoAppParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Replicas == 0 {
+		params.Replicas = 1
+	}
+	if params.Subsets == nil {
+		params.Subsets = boolPtr(true)
+	}
+	if params.GatewaySelector == "" {
+		params.GatewaySelector = "istio=ingressgateway"
+	}
+	if len(params.Hosts) == 0 {
+		params.Hosts = []string{"*"}
+	}
+	params.IstioInjection = true // Always enable for mesh testing
+
+	gatewaySelector := make(map[string]interface{})
+	for _, pair := range strings.Split(params.GatewaySelector, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || key == "" {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid gateway_selector %q: must be key=value pairs separated by commas", params.GatewaySelector),
+					},
+				},
+			}, nil
+		}
+		gatewaySelector[key] = value
+	}
+	hostList := make([]interface{}, 0, len(params.Hosts))
+	for _, host := range params.Hosts {
+		hostList = append(hostList, host)
+	}
+
+	ctx = withManagingTool(ctx, "deploy_bookinfo_app")
+
+	// Create namespace if it doesn't exist and enable Istio injection
+	if err := m.createOrUpdateNamespace(ctx, params.Namespace, params.IstioInjection); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to create/update namespace: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	var pinned []PinnedImage
+	for _, service := range bookinfoServices {
+		if err := m.createBookinfoServiceAccount(ctx, params.Namespace, service.Name); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to create service account for %s: %v", service.Name, err),
+					},
+				},
+			}, nil
+		}
+
+		if err := m.createBookinfoService(ctx, params.Namespace, service.Name); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to create service %s: %v", service.Name, err),
+					},
+				},
+			}, nil
+		}
+
+		for _, version := range service.Versions {
+			image := fmt.Sprintf("%s/examples-bookinfo-%s-%s:%s", bookinfoImageHub, service.Name, version, bookinfoImageTag)
+			if params.PinDigests {
+				var err error
+				image, err = pinImage(ctx, image, &pinned)
+				if err != nil {
+					return &CallToolResult{
+						IsError: true,
+						Content: []interface{}{
+							TextContent{
+								Type: "text",
+								Text: fmt.Sprintf("Failed to pin image digest: %v", err),
+							},
+						},
+					}, nil
+				}
+			}
+
+			if err := m.createBookinfoDeployment(ctx, params.Namespace, service.Name, version, params.Replicas, image); err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Failed to create deployment %s-%s: %v", service.Name, version, err),
+						},
+					},
+				}, nil
+			}
+		}
+	}
+
+	message := fmt.Sprintf("Bookinfo app deployment initiated in namespace '%s' with %d replicas per version and Istio injection enabled", params.Namespace, params.Replicas)
+
+	if *params.Subsets {
+		for _, service := range bookinfoServices {
+			if err := m.applyResource(ctx, destinationRuleGVR, bookinfoDestinationRule(params.Namespace, service)); err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Failed to apply DestinationRule %s: %v", service.Name, err),
+						},
+					},
+				}, nil
+			}
+		}
+		message += "\nDestinationRules with version subsets created for details, ratings, reviews (v1, v2, v3) and productpage"
+	}
+
+	if params.Gateway {
+		if err := m.applyResource(ctx, istioGatewayGVR, bookinfoGateway(params.Namespace, gatewaySelector, hostList)); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to apply Gateway bookinfo-gateway: %v", err),
+					},
+				},
+			}, nil
+		}
+		if err := m.applyResource(ctx, virtualServiceGVR, bookinfoVirtualService(params.Namespace, hostList)); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to apply VirtualService bookinfo: %v", err),
+					},
+				},
+			}, nil
+		}
+		message += fmt.Sprintf("\nGateway bookinfo-gateway (selector %s) and VirtualService bookinfo route /productpage to productpage:%d", params.GatewaySelector, bookinfoPort)
+	}
+
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: message + formatPinnedImages(pinned),
+			},
+		},
+	}, nil
+}
+
+// undeployBookinfoAppParams are the arguments of undeploy_bookinfo_app
+type undeployBookinfoAppParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: default
+}
+
+// UndeployBookinfoApp removes the Bookinfo sample application along with its DestinationRules, Gateway and VirtualService
+func (m *Manager) UndeployBookinfoApp(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params undeployBookinfoAppParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	// Delete the Istio routing resources first so the gateway stops sending traffic to terminating pods
+	err := m.k8sClient.Dynamic.Resource(virtualServiceGVR).Namespace(params.Namespace).Delete(ctx, "bookinfo", metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		logrus.Warnf("Failed to delete bookinfo virtual service: %v", err)
+	}
+	err = m.k8sClient.Dynamic.Resource(istioGatewayGVR).Namespace(params.Namespace).Delete(ctx, "bookinfo-gateway", metav1.DeleteOptions{})
+	if err != nil && !errors.IsNotFound(err) {
+		logrus.Warnf("Failed to delete bookinfo gateway: %v", err)
+	}
+
+	for _, service := range bookinfoServices {
+		err := m.k8sClient.Dynamic.Resource(destinationRuleGVR).Namespace(params.Namespace).Delete(ctx, service.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			logrus.Warnf("Failed to delete %s destination rule: %v", service.Name, err)
+		}
+
+		for _, version := range service.Versions {
+			name := service.Name + "-" + version
+			err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Delete(ctx, name, metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				logrus.Warnf("Failed to delete %s deployment: %v", name, err)
+			}
+		}
+
+		err = m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Delete(ctx, service.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			logrus.Warnf("Failed to delete %s service: %v", service.Name, err)
+		}
+
+		err = m.k8sClient.Kubernetes.CoreV1().ServiceAccounts(params.Namespace).Delete(ctx, "bookinfo-"+service.Name, metav1.DeleteOptions{})
+		if err != nil && !errors.IsNotFound(err) {
+			logrus.Warnf("Failed to delete bookinfo-%s service account: %v", service.Name, err)
+		}
+	}
+
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Bookinfo app removal initiated from namespace '%s'", params.Namespace),
+			},
+		},
+	}, nil
+}
+
+func (m *Manager) createBookinfoServiceAccount(ctx context.Context, namespace, service string) error {
+	serviceAccount := &corev1.ServiceAccount{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "bookinfo-" + service,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"account": service,
+			},
+		},
+	}
+
+	markManaged(ctx, serviceAccount)
+	_, err := m.k8sClient.Kubernetes.CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create service account: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) createBookinfoService(ctx context.Context, namespace, name string) error {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app":     name,
+				"service": name,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       bookinfoPort,
+					TargetPort: intstr.FromInt(bookinfoPort),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+			Selector: map[string]string{
+				"app": name,
+			},
+		},
+	}
+
+	markManaged(ctx, service)
+	_, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) createBookinfoDeployment(ctx context.Context, namespace, service, version string, replicas int32, image string) error {
+	labels := map[string]string{
+		"app":     service,
+		"version": version,
+	}
+
+	// reviews runs on WebSphere Liberty and productpage on Flask, both of which write to the container
+	// filesystem; the upstream manifests mount emptyDirs there
+	var env []corev1.EnvVar
+	var mounts []corev1.VolumeMount
+	var volumes []corev1.Volume
+	switch service {
+	case "reviews":
+		env = []corev1.EnvVar{{Name: "LOG_DIR", Value: "/tmp/logs"}}
+		mounts = []corev1.VolumeMount{
+			{Name: "tmp", MountPath: "/tmp"},
+			{Name: "wlp-output", MountPath: "/opt/ibm/wlp/output"},
+		}
+	case "productpage":
+		mounts = []corev1.VolumeMount{{Name: "tmp", MountPath: "/tmp"}}
+	}
+	for _, mount := range mounts {
+		volumes = append(volumes, corev1.Volume{
+			Name:         mount.Name,
+			VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+		})
+	}
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      service + "-" + version,
+			Namespace: namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					ServiceAccountName: "bookinfo-" + service,
+					Containers: []corev1.Container{
+						{
+							Name:            service,
+							Image:           image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Env:             env,
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: bookinfoPort,
+									Name:          "http",
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							VolumeMounts: mounts,
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("64Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("500m"),
+									corev1.ResourceMemory: resource.MustParse("512Mi"),
+								},
+							},
+						},
+					},
+					Volumes: volumes,
+				},
+			},
+		},
+	}
+
+	markManaged(ctx, deployment)
+	_, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	return nil
+}
+
+// bookinfoDestinationRule builds the DestinationRule defining one subset per version of a Bookinfo service
+func bookinfoDestinationRule(namespace string, service bookinfoService) *unstructured.Unstructured {
+	subsets := make([]interface{}, 0, len(service.Versions))
+	for _, version := range service.Versions {
+		subsets = append(subsets, map[string]interface{}{
+			"name":   version,
+			"labels": map[string]interface{}{"version": version},
+		})
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "DestinationRule",
+		"metadata": map[string]interface{}{
+			"name":      service.Name,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"host":    service.Name,
+			"subsets": subsets,
+		},
+	}}
+}
+
+// bookinfoGateway builds the Gateway accepting plain HTTP on port 80 of the selected ingress gateway
+func bookinfoGateway(namespace string, selector map[string]interface{}, hosts []interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "Gateway",
+		"metadata": map[string]interface{}{
+			"name":      "bookinfo-gateway",
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"selector": selector,
+			"servers": []interface{}{
+				map[string]interface{}{
+					"port": map[string]interface{}{
+						"number":   int64(80),
+						"name":     "http",
+						"protocol": "HTTP",
+					},
+					"hosts": hosts,
+				},
+			},
+		},
+	}}
+}
+
+// bookinfoVirtualService builds the VirtualService routing the productpage paths from bookinfo-gateway
+func bookinfoVirtualService(namespace string, hosts []interface{}) *unstructured.Unstructured {
+	match := []interface{}{
+		map[string]interface{}{"uri": map[string]interface{}{"exact": "/productpage"}},
+		map[string]interface{}{"uri": map[string]interface{}{"prefix": "/static"}},
+		map[string]interface{}{"uri": map[string]interface{}{"exact": "/login"}},
+		map[string]interface{}{"uri": map[string]interface{}{"exact": "/logout"}},
+		map[string]interface{}{"uri": map[string]interface{}{"prefix": "/api/v1/products"}},
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "VirtualService",
+		"metadata": map[string]interface{}{
+			"name":      "bookinfo",
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"hosts":    hosts,
+			"gateways": []interface{}{"bookinfo-gateway"},
+			"http": []interface{}{
+				map[string]interface{}{
+					"match": match,
+					"route": []interface{}{
+						map[string]interface{}{
+							"destination": map[string]interface{}{
+								"host": "productpage",
+								"port": map[string]interface{}{"number": int64(bookinfoPort)},
+							},
+						},
+					},
+				},
+			},
+		},
+	}}
+}
@@ -0,0 +1,341 @@
+package tools
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"meshpilot/internal/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+const (
+	// maxCaptureDuration caps how long capture_packets captures
+	maxCaptureDuration = 2 * time.Minute
+	// maxCapturePackets caps the packet count of one capture
+	maxCapturePackets = 20000
+	// maxCaptureBytes caps the pcap returned by capture_packets; the capture travels base64-encoded
+	// through the container log, which the kubelet rotates at 10MiB by default
+	maxCaptureBytes = 5 << 20
+)
+
+// captureScript runs tcpdump for the duration and prints its summary and the base64 pcap between markers;
+// arguments are the duration in seconds, interface, snap length, packet count, byte limit and the BPF filter
+const captureScript = `duration=$1; iface=$2; snaplen=$3; count=$4; limit=$5; shift 5
+timeout "$duration" tcpdump -i "$iface" -s "$snaplen" -c "$count" -U -w /tmp/capture.pcap "$@" 2>/tmp/tcpdump.log
+echo "==tcpdump=="
+cat /tmp/tcpdump.log
+echo "==size=="
+wc -c < /tmp/capture.pcap 2>/dev/null || echo 0
+echo "==pcap=="
+head -c "$limit" /tmp/capture.pcap 2>/dev/null | base64`
+
+// PacketCapture is the result of capture_packets
+type PacketCapture struct {
+	Pod         string    `json:"pod"`
+	Namespace   string    `json:"namespace"`
+	Container   string    `json:"container"` // ephemeral tcpdump container
+	Image       string    `json:"image"`
+	Interface   string    `json:"interface"`
+	Filter      string    `json:"filter,omitempty"`
+	Duration    string    `json:"duration"`
+	Packets     int       `json:"packets"`
+	Bytes       int       `json:"bytes"`
+	Truncated   bool      `json:"truncated,omitempty"`
+	Output      string    `json:"output"` // base64 or resource
+	ResourceURI string    `json:"resource_uri,omitempty"`
+	PcapBase64  string    `json:"pcap_base64,omitempty"`
+	Tcpdump     []string  `json:"tcpdump,omitempty"` // tcpdump's own summary lines
+	Issues      []string  `json:"issues,omitempty"`
+	Timestamp   time.Time `json:"timestamp"`
+}
+
+// capturePacketsParams are the arguments of capture_packets
+type capturePacketsParams struct {
+	PodName    string `json:"pod_name"`
+	Namespace  string `json:"namespace,omitempty"`   // default: default
+	Filter     string `json:"filter,omitempty"`      // BPF filter, e.g. "tcp port 9080"
+	Duration   int    `json:"duration,omitempty"`    // seconds, default: 10
+	Interface  string `json:"interface,omitempty"`   // default: any
+	SnapLength int    `json:"snap_length,omitempty"` // bytes per packet, default: 262144 (whole packet)
+	MaxPackets int    `json:"max_packets,omitempty"` // default: 5000
+	Image      string `json:"image,omitempty"`       // default: the configured debug image
+	Profile    string `json:"profile,omitempty"`     // sysadmin or netadmin, default: the configured profile or netadmin
+	Output     string `json:"output,omitempty"`      // base64 or resource, default: base64
+}
+
+// CapturePackets attaches an ephemeral tcpdump container to a pod, captures its traffic for a number of
+// seconds and returns the pcap, inline as base64 or as an embedded MCP resource the client can save
+func (m *Manager) CapturePackets(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params capturePacketsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.PodName == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "pod_name is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Duration == 0 {
+		params.Duration = 10
+	}
+	if params.Interface == "" {
+		params.Interface = "any"
+	}
+	if params.SnapLength <= 0 {
+		params.SnapLength = 262144
+	}
+	if params.MaxPackets <= 0 {
+		params.MaxPackets = 5000
+	}
+	params.Image = m.debugImage(params.Image, config.DefaultDebugImage)
+	if params.Output == "" {
+		params.Output = "base64"
+	}
+
+	duration := time.Duration(params.Duration) * time.Second
+	if duration < time.Second || duration > maxCaptureDuration {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %d: must be between 1 and %d seconds", params.Duration, int(maxCaptureDuration.Seconds())),
+				},
+			},
+		}, nil
+	}
+	if params.MaxPackets > maxCapturePackets {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid max_packets %d: must be at most %d", params.MaxPackets, maxCapturePackets),
+				},
+			},
+		}, nil
+	}
+	if params.Output != "base64" && params.Output != "resource" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid output %q: must be base64 or resource", params.Output),
+				},
+			},
+		}, nil
+	}
+
+	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get pod: %v", err),
+				},
+			},
+		}, nil
+	}
+	if pod.Spec.HostNetwork {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Pod %s/%s uses the host network; a capture would record the whole node's traffic", pod.Namespace, pod.Name),
+				},
+			},
+		}, nil
+	}
+
+	command := []string{"sh", "-c", captureScript, "sh",
+		strconv.Itoa(params.Duration), params.Interface, strconv.Itoa(params.SnapLength),
+		strconv.Itoa(params.MaxPackets), strconv.Itoa(maxCaptureBytes)}
+	if params.Filter != "" {
+		command = append(command, params.Filter)
+	}
+
+	securityContext, _, err := m.debugSecurityContext(params.Profile, "netadmin")
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+	if err := m.checkDebugImage(ctx, pod, params.Image); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	container := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:            captureContainerPrefix + utilrand.String(5),
+			Image:           params.Image,
+			Command:         command,
+			SecurityContext: securityContext,
+		},
+	}
+
+	result := &PacketCapture{
+		Pod:       pod.Name,
+		Namespace: pod.Namespace,
+		Container: container.Name,
+		Image:     params.Image,
+		Interface: params.Interface,
+		Filter:    params.Filter,
+		Duration:  duration.String(),
+		Output:    params.Output,
+		Timestamp: time.Now(),
+	}
+
+	// Pulling the image and starting the container come on top of the capture itself
+	logs, err := m.runEphemeralContainer(ctx, pod.Namespace, pod.Name, container, duration+2*time.Minute)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to capture packets in %s/%s: %v", pod.Namespace, pod.Name, err),
+				},
+			},
+		}, nil
+	}
+
+	summary, size, encoded := parseCaptureOutput(logs)
+	result.Tcpdump = summary
+	result.Truncated = size > maxCaptureBytes
+	pcap, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(pcap) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Capture produced no pcap; tcpdump reported: %s", strings.Join(summary, "; ")),
+				},
+			},
+		}, nil
+	}
+	result.Bytes = len(pcap)
+	for _, line := range summary {
+		if fields := strings.Fields(line); len(fields) > 2 && fields[1] == "packets" && fields[2] == "captured" {
+			result.Packets, _ = strconv.Atoi(fields[0])
+		}
+	}
+
+	if result.Packets == 0 {
+		result.Issues = append(result.Issues, "No packets matched; check the filter and that traffic flowed during the capture")
+	}
+	if result.Packets >= params.MaxPackets {
+		result.Issues = append(result.Issues, fmt.Sprintf("Capture stopped at max_packets %d before the duration elapsed", params.MaxPackets))
+	}
+	if result.Truncated {
+		result.Issues = append(result.Issues, fmt.Sprintf("The pcap was cut at %d of %d bytes; the last packet is incomplete. Narrow the filter or lower snap_length", maxCaptureBytes, size))
+	}
+	if podHasSidecar(pod) {
+		result.Issues = append(result.Issues, "Traffic between sidecars is mTLS encrypted on eth0; the plaintext hop between istio-proxy and the application is on interface lo")
+	}
+
+	if params.Output == "base64" {
+		result.PcapBase64 = encoded
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	result.ResourceURI = fmt.Sprintf("pcap://%s/%s/%s.pcap", pod.Namespace, pod.Name, result.Timestamp.UTC().Format("20060102-150405"))
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+			ResourceContent{
+				Type:     "resource",
+				URI:      result.ResourceURI,
+				MIMEType: "application/vnd.tcpdump.pcap",
+				Blob:     pcap,
+			},
+		},
+	}, nil
+}
+
+// parseCaptureOutput splits the output of captureScript into tcpdump's summary lines, the pcap size and the base64 pcap
+func parseCaptureOutput(output string) ([]string, int, string) {
+	var summary []string
+	var encoded strings.Builder
+	size := 0
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch line {
+		case "==tcpdump==", "==size==", "==pcap==":
+			section = line
+			continue
+		}
+		if line == "" {
+			continue
+		}
+		switch section {
+		case "==tcpdump==":
+			summary = append(summary, line)
+		case "==size==":
+			size, _ = strconv.Atoi(line)
+		case "==pcap==":
+			encoded.WriteString(line)
+		}
+	}
+	return summary, size, encoded.String()
+}
@@ -0,0 +1,493 @@
+package tools
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// workloadCertificateRotationShare is the share of its lifetime a workload certificate may have left before
+// the report flags it: proxies rotate at half the lifetime, so a certificate this close to expiry was not rotated
+const workloadCertificateRotationShare = 0.25
+
+// CertificateInfo summarizes one certificate of a proxy secret or Kubernetes Secret
+type CertificateInfo struct {
+	Secret       string    `json:"secret"` // SDS secret name, or key of the Kubernetes Secret
+	Role         string    `json:"role"`   // leaf, intermediate, root or trust_anchor
+	Subject      string    `json:"subject"`
+	Issuer       string    `json:"issuer"`
+	SerialNumber string    `json:"serial_number"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	URISANs      []string  `json:"uri_sans,omitempty"`
+	IPSANs       []string  `json:"ip_sans,omitempty"`
+	NotBefore    time.Time `json:"not_before"`
+	NotAfter     time.Time `json:"not_after"`
+	DaysToExpiry int       `json:"days_to_expiry"` // negative once expired
+	Status       string    `json:"status"`         // valid, expiring, expired or not-yet-valid
+}
+
+// ProxyCertificates holds the certificates a proxy received over SDS
+type ProxyCertificates struct {
+	Pod          string            `json:"pod"`
+	Namespace    string            `json:"namespace"`
+	Certificates []CertificateInfo `json:"certificates"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// GatewaySecretCertificates holds the certificates of a TLS credential referenced by gateways
+type GatewaySecretCertificates struct {
+	Secret       string            `json:"secret"` // namespace/name
+	Gateways     []string          `json:"gateways"`
+	Hosts        []string          `json:"hosts,omitempty"`
+	Certificates []CertificateInfo `json:"certificates"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// WorkloadCertificateReport is the result of get_workload_certificates
+type WorkloadCertificateReport struct {
+	Namespace      string                      `json:"namespace"`
+	Source         string                      `json:"source"` // proxy or gateway_secrets
+	WarnDays       int                         `json:"warn_days"`
+	Proxies        []ProxyCertificates         `json:"proxies,omitempty"`
+	GatewaySecrets []GatewaySecretCertificates `json:"gateway_secrets,omitempty"`
+	Summary        map[string]int              `json:"summary"` // certificates per status
+	Issues         []string                    `json:"issues,omitempty"`
+	Timestamp      time.Time                   `json:"timestamp"`
+}
+
+// getWorkloadCertificatesParams are the arguments of get_workload_certificates
+type getWorkloadCertificatesParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: default
+	PodName   string `json:"pod_name,omitempty"`  // default: every pod with a proxy
+	Source    string `json:"source,omitempty"`    // proxy or gateway_secrets, default: proxy
+	WarnDays  int    `json:"warn_days,omitempty"` // days before expiry CA and gateway certificates are flagged, default: 30
+}
+
+// GetWorkloadCertificates reports the SANs, issuer, validity window and days to expiry of the certificates the
+// proxies hold, the equivalent of istioctl proxy-config secret, or of the TLS credentials gateways serve
+func (m *Manager) GetWorkloadCertificates(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getWorkloadCertificatesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Source == "" {
+		params.Source = "proxy"
+	}
+	if params.WarnDays <= 0 {
+		params.WarnDays = 30
+	}
+
+	report := &WorkloadCertificateReport{
+		Namespace: params.Namespace,
+		Source:    params.Source,
+		WarnDays:  params.WarnDays,
+		Summary:   make(map[string]int),
+		Timestamp: time.Now(),
+	}
+	var err error
+	switch params.Source {
+	case "proxy":
+		err = m.proxyCertificates(ctx, report, params.PodName)
+	case "gateway_secrets":
+		err = m.gatewaySecretCertificates(ctx, report)
+	default:
+		err = fmt.Errorf("invalid source %q: must be proxy or gateway_secrets", params.Source)
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	if report.Summary["expired"] > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d certificates have expired; TLS handshakes using them fail", report.Summary["expired"]))
+	}
+	if report.Summary["not-yet-valid"] > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d certificates are not valid yet; check the clocks of the CA and the nodes with check_clock_skew", report.Summary["not-yet-valid"]))
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// proxyCertificates reads the active SDS secrets of each proxy: the workload certificate ("default"), the mesh
+// trust anchors ("ROOTCA") and, on gateways, the TLS credentials they serve
+func (m *Manager) proxyCertificates(ctx context.Context, report *WorkloadCertificateReport, podName string) error {
+	var pods []corev1.Pod
+	if podName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(report.Namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return fmt.Errorf("failed to get pod: %w", err)
+		}
+		if !podHasSidecar(pod) {
+			return fmt.Errorf("pod %s/%s has no istio-proxy container; ambient workloads get their certificates through ztunnel", pod.Namespace, pod.Name)
+		}
+		pods = []corev1.Pod{*pod}
+	} else {
+		running, err := m.runningPods(ctx, report.Namespace, "")
+		if err != nil {
+			return fmt.Errorf("failed to list pods: %w", err)
+		}
+		for _, pod := range running {
+			if podHasSidecar(&pod) {
+				pods = append(pods, pod)
+			}
+		}
+	}
+	report.Proxies = []ProxyCertificates{}
+	if len(pods) == 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("No running pods with a proxy in namespace %s", report.Namespace))
+		return nil
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		proxy := ProxyCertificates{Pod: pod.Name, Namespace: pod.Namespace, Certificates: []CertificateInfo{}}
+		secrets, err := m.proxySecrets(ctx, pod)
+		if err != nil {
+			proxy.Error = err.Error()
+			report.Issues = append(report.Issues, fmt.Sprintf("Pod %s: %v", pod.Name, err))
+			report.Proxies = append(report.Proxies, proxy)
+			continue
+		}
+
+		for _, secret := range secrets {
+			for j, cert := range secret.chain {
+				info := certificateInfo(cert, secret.name, chainRole(cert, j), report.Timestamp, report.WarnDays)
+				// Workload certificates live a day by default, so they are judged by their rotation instead
+				if secret.name == "default" && j == 0 {
+					info.Status = workloadCertificateStatus(cert, report.Timestamp)
+					if info.Status == "expiring" {
+						report.Issues = append(report.Issues, fmt.Sprintf("Pod %s: the workload certificate expires at %s and was not rotated at half its lifetime; check the proxy can reach istiod",
+							pod.Name, cert.NotAfter.Format(time.RFC3339)))
+					}
+				}
+				proxy.Certificates = append(proxy.Certificates, info)
+			}
+			for _, cert := range secret.trustAnchors {
+				proxy.Certificates = append(proxy.Certificates, certificateInfo(cert, secret.name, "trust_anchor", report.Timestamp, report.WarnDays))
+			}
+		}
+		if len(secrets) == 0 || secrets[0].name != "default" {
+			report.Issues = append(report.Issues, fmt.Sprintf("Pod %s has no workload certificate yet; it may still be waiting for SDS", pod.Name))
+		}
+		for _, info := range proxy.Certificates {
+			report.Summary[info.Status]++
+			if info.Status == "expiring" && !(info.Secret == "default" && info.Role == "leaf") {
+				report.Issues = append(report.Issues, fmt.Sprintf("Pod %s: %s certificate %q of secret %s expires in %d days", pod.Name, info.Role, info.Subject, info.Secret, info.DaysToExpiry))
+			}
+		}
+		report.Proxies = append(report.Proxies, proxy)
+	}
+	return nil
+}
+
+// proxySecret is one active SDS secret of a proxy
+type proxySecret struct {
+	name         string
+	chain        []*x509.Certificate
+	trustAnchors []*x509.Certificate
+}
+
+// proxySecrets reads a proxy's active SDS secrets, the workload certificate first
+func (m *Manager) proxySecrets(ctx context.Context, pod *corev1.Pod) ([]proxySecret, error) {
+	body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/config_dump?resource=dynamic_active_secrets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy secrets: %w", err)
+	}
+	var dump struct {
+		Configs []struct {
+			Name   string `json:"name"`
+			Secret struct {
+				TLSCertificate struct {
+					CertificateChain struct {
+						InlineBytes string `json:"inline_bytes"`
+					} `json:"certificate_chain"`
+				} `json:"tls_certificate"`
+				ValidationContext struct {
+					TrustedCA struct {
+						InlineBytes string `json:"inline_bytes"`
+					} `json:"trusted_ca"`
+				} `json:"validation_context"`
+			} `json:"secret"`
+		} `json:"configs"`
+	}
+	if err := json.Unmarshal(body, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse proxy secrets: %w", err)
+	}
+
+	var secrets []proxySecret
+	for _, config := range dump.Configs {
+		secret := proxySecret{name: config.Name}
+		chain, err := base64.StdEncoding.DecodeString(config.Secret.TLSCertificate.CertificateChain.InlineBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode secret %s: %w", config.Name, err)
+		}
+		secret.chain = parsePEMCertificates(chain)
+		trustedCA, err := base64.StdEncoding.DecodeString(config.Secret.ValidationContext.TrustedCA.InlineBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode secret %s: %w", config.Name, err)
+		}
+		secret.trustAnchors = parsePEMCertificates(trustedCA)
+		secrets = append(secrets, secret)
+	}
+	sort.SliceStable(secrets, func(i, j int) bool {
+		return secrets[i].name == "default" && secrets[j].name != "default"
+	})
+	return secrets, nil
+}
+
+// gatewaySecretCertificates reads the TLS credentials that the Istio Gateways and Gateway API Gateways of the
+// namespace reference
+func (m *Manager) gatewaySecretCertificates(ctx context.Context, report *WorkloadCertificateReport) error {
+	type credential struct {
+		gateways []string
+		hosts    []string
+	}
+	credentials := make(map[string]*credential)
+	add := func(namespace, name, gateway string, hosts []string) {
+		key := namespace + "/" + name
+		if credentials[key] == nil {
+			credentials[key] = &credential{}
+		}
+		if !containsString(credentials[key].gateways, gateway) {
+			credentials[key].gateways = append(credentials[key].gateways, gateway)
+		}
+		for _, host := range hosts {
+			if !containsString(credentials[key].hosts, host) {
+				credentials[key].hosts = append(credentials[key].hosts, host)
+			}
+		}
+	}
+
+	istioGateways, err := m.k8sClient.Dynamic.Resource(istioGatewayGVR).Namespace(report.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		report.Issues = append(report.Issues, fmt.Sprintf("Could not list Istio Gateways: %v", err))
+	} else {
+		for _, item := range istioGateways.Items {
+			var spec istioGatewaySpec
+			if err := remarshal(item.Object["spec"], &spec); err != nil {
+				continue
+			}
+			// Gateways read credentials from the namespace of the gateway pods
+			secretNamespace := item.GetNamespace()
+			if len(spec.Selector) > 0 {
+				if pods, err := m.runningPods(ctx, metav1.NamespaceAll, labels.SelectorFromSet(spec.Selector).String()); err == nil && len(pods) > 0 {
+					secretNamespace = pods[0].Namespace
+				}
+			}
+			for _, server := range spec.Servers {
+				if server.TLS == nil || server.TLS.CredentialName == "" {
+					continue
+				}
+				var hosts []string
+				for _, host := range server.Hosts {
+					// Hosts may be qualified with the namespaces allowed to bind routes, e.g. "prod/app.example.com"
+					if idx := strings.Index(host, "/"); idx >= 0 {
+						host = host[idx+1:]
+					}
+					hosts = append(hosts, host)
+				}
+				add(secretNamespace, server.TLS.CredentialName, "Gateway "+item.GetNamespace()+"/"+item.GetName(), hosts)
+			}
+		}
+	}
+
+	kubeGateways, err := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(report.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		report.Issues = append(report.Issues, fmt.Sprintf("Could not list Gateway API Gateways: %v", err))
+	} else {
+		for _, item := range kubeGateways.Items {
+			var spec kubeGatewaySpec
+			if err := remarshal(item.Object["spec"], &spec); err != nil {
+				continue
+			}
+			for _, listener := range spec.Listeners {
+				if listener.TLS == nil {
+					continue
+				}
+				var hosts []string
+				if listener.Hostname != "" {
+					hosts = []string{listener.Hostname}
+				}
+				for _, ref := range listener.TLS.CertificateRefs {
+					namespace := ref.Namespace
+					if namespace == "" {
+						namespace = item.GetNamespace()
+					}
+					add(namespace, ref.Name, "Gateway "+item.GetNamespace()+"/"+item.GetName()+" (Gateway API)", hosts)
+				}
+			}
+		}
+	}
+
+	report.GatewaySecrets = []GatewaySecretCertificates{}
+	if len(credentials) == 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("No gateway in namespace %s references a TLS credential", report.Namespace))
+		return nil
+	}
+	keys := make([]string, 0, len(credentials))
+	for key := range credentials {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		namespace, name, _ := strings.Cut(key, "/")
+		entry := GatewaySecretCertificates{Secret: key, Gateways: credentials[key].gateways, Hosts: credentials[key].hosts, Certificates: []CertificateInfo{}}
+		secret, err := m.k8sClient.Kubernetes.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			entry.Error = err.Error()
+			report.Issues = append(report.Issues, fmt.Sprintf("Secret %s: %v", key, err))
+			report.GatewaySecrets = append(report.GatewaySecrets, entry)
+			continue
+		}
+
+		// kubernetes.io/tls Secrets use tls.crt and ca.crt, Istio's generic format cert and cacert
+		for _, dataKey := range []string{"tls.crt", "cert", "ca.crt", "cacert"} {
+			for i, cert := range parsePEMCertificates(secret.Data[dataKey]) {
+				role := chainRole(cert, i)
+				if dataKey == "ca.crt" || dataKey == "cacert" {
+					role = "trust_anchor"
+				}
+				entry.Certificates = append(entry.Certificates, certificateInfo(cert, dataKey, role, report.Timestamp, report.WarnDays))
+				if role != "leaf" {
+					continue
+				}
+				for _, host := range entry.Hosts {
+					if strings.HasPrefix(host, "*.") {
+						host = "probe" + host[1:]
+					}
+					if host != "*" && cert.VerifyHostname(host) != nil {
+						report.Issues = append(report.Issues, fmt.Sprintf("Secret %s: the certificate does not cover host %s", key, host))
+					}
+				}
+			}
+		}
+		if len(entry.Certificates) == 0 {
+			entry.Error = "no PEM certificate under tls.crt or cert"
+			report.Issues = append(report.Issues, fmt.Sprintf("Secret %s has no PEM certificate under tls.crt or cert", key))
+		}
+		for _, info := range entry.Certificates {
+			report.Summary[info.Status]++
+			if info.Status == "expiring" {
+				report.Issues = append(report.Issues, fmt.Sprintf("Secret %s: %s certificate %q expires in %d days", key, info.Role, info.Subject, info.DaysToExpiry))
+			}
+		}
+		report.GatewaySecrets = append(report.GatewaySecrets, entry)
+	}
+	return nil
+}
+
+// parsePEMCertificates parses every certificate in PEM data, skipping blocks that are not certificates
+func parsePEMCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return certs
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		if cert, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, cert)
+		}
+	}
+}
+
+// chainRole names the position of a certificate in a chain
+func chainRole(cert *x509.Certificate, index int) string {
+	switch {
+	case index == 0:
+		return "leaf"
+	case cert.Subject.String() == cert.Issuer.String():
+		return "root"
+	default:
+		return "intermediate"
+	}
+}
+
+// certificateInfo summarizes a certificate, flagging it as expiring within warnDays
+func certificateInfo(cert *x509.Certificate, secret, role string, now time.Time, warnDays int) CertificateInfo {
+	info := CertificateInfo{
+		Secret:       secret,
+		Role:         role,
+		Subject:      cert.Subject.String(),
+		Issuer:       cert.Issuer.String(),
+		SerialNumber: cert.SerialNumber.Text(16),
+		DNSNames:     cert.DNSNames,
+		NotBefore:    cert.NotBefore,
+		NotAfter:     cert.NotAfter,
+		DaysToExpiry: int(math.Floor(cert.NotAfter.Sub(now).Hours() / 24)),
+		Status:       "valid",
+	}
+	for _, uri := range cert.URIs {
+		info.URISANs = append(info.URISANs, uri.String())
+	}
+	for _, ip := range cert.IPAddresses {
+		info.IPSANs = append(info.IPSANs, ip.String())
+	}
+	switch {
+	case now.Before(cert.NotBefore):
+		info.Status = "not-yet-valid"
+	case now.After(cert.NotAfter):
+		info.Status = "expired"
+	case cert.NotAfter.Sub(now) < time.Duration(warnDays)*24*time.Hour:
+		info.Status = "expiring"
+	}
+	return info
+}
+
+// workloadCertificateStatus judges a workload certificate by how much of its lifetime is left
+func workloadCertificateStatus(cert *x509.Certificate, now time.Time) string {
+	lifetime := cert.NotAfter.Sub(cert.NotBefore)
+	switch {
+	case now.Before(cert.NotBefore):
+		return "not-yet-valid"
+	case now.After(cert.NotAfter):
+		return "expired"
+	case float64(cert.NotAfter.Sub(now)) < workloadCertificateRotationShare*float64(lifetime):
+		return "expiring"
+	}
+	return "valid"
+}
@@ -0,0 +1,319 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"meshpilot/internal/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// clockSamples is the number of clock readings taken per node; the one with the shortest round trip wins
+	clockSamples = 3
+	// tokenSkewThreshold is the skew at which JWT validation with the usual 60s leeway starts failing
+	tokenSkewThreshold = time.Minute
+)
+
+// NodeClock is the clock reading of one node
+type NodeClock struct {
+	Node     string  `json:"node"`
+	Istiod   bool    `json:"istiod,omitempty"` // an istiod pod runs on this node
+	OffsetMs float64 `json:"offset_ms"`        // node clock minus the meshpilot host clock
+	SkewMs   float64 `json:"skew_ms"`          // node clock minus the reference clock
+	RTTMs    float64 `json:"rtt_ms"`           // round trip of the best reading, its uncertainty
+	Error    string  `json:"error,omitempty"`
+}
+
+// ClockSkewReport is the result of check_clock_skew
+type ClockSkewReport struct {
+	Reference string      `json:"reference"` // clock the skew is measured against
+	MaxSkew   string      `json:"max_skew"`
+	Nodes     []NodeClock `json:"nodes"`
+	Issues    []string    `json:"issues,omitempty"`
+	Notes     []string    `json:"notes,omitempty"`
+	Timestamp time.Time   `json:"timestamp"`
+}
+
+// checkClockSkewParams are the arguments of check_clock_skew
+type checkClockSkewParams struct {
+	Nodes          []string `json:"nodes,omitempty"`           // default: all nodes
+	MaxSkew        string   `json:"max_skew,omitempty"`        // default: 1s
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Image          string   `json:"image,omitempty"`           // default: the configured debug image
+	DebugNamespace string   `json:"debug_namespace,omitempty"` // default: default
+}
+
+// CheckClockSkew reads every node's clock from a short-lived debug pod and flags nodes whose clock is far
+// enough from istiod's to reject freshly issued workload certificates or expire tokens early
+func (m *Manager) CheckClockSkew(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkClockSkewParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.MaxSkew == "" {
+		params.MaxSkew = "1s"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	params.Image = m.debugImage(params.Image, config.DefaultDebugImage)
+	if params.DebugNamespace == "" {
+		params.DebugNamespace = "default"
+	}
+
+	maxSkew, err := time.ParseDuration(params.MaxSkew)
+	if err != nil || maxSkew <= 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid max_skew %q: must be a positive duration such as 500ms or 2s", params.MaxSkew),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "check_clock_skew")
+
+	if len(params.Nodes) == 0 {
+		nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list nodes: %v", err),
+					},
+				},
+			}, nil
+		}
+		for _, node := range nodes.Items {
+			params.Nodes = append(params.Nodes, node.Name)
+		}
+	}
+
+	istiodNodes := make(map[string]bool)
+	if pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.IstioNamespace).List(ctx, metav1.ListOptions{LabelSelector: "app=istiod"}); err == nil {
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodRunning {
+				istiodNodes[pod.Spec.NodeName] = true
+			}
+		}
+	}
+
+	result := &ClockSkewReport{
+		MaxSkew:   maxSkew.String(),
+		Nodes:     make([]NodeClock, len(params.Nodes)),
+		Timestamp: time.Now(),
+	}
+
+	// Nodes are read in parallel; each reading is relative to this host's clock, so they stay comparable
+	var wg sync.WaitGroup
+	for i, node := range params.Nodes {
+		wg.Add(1)
+		go func(i int, node string) {
+			defer wg.Done()
+			clock := NodeClock{Node: node, Istiod: istiodNodes[node]}
+			offset, rtt, err := m.readNodeClock(ctx, params.DebugNamespace, node, params.Image)
+			if err != nil {
+				clock.Error = err.Error()
+			} else {
+				clock.OffsetMs = durationMs(offset)
+				clock.RTTMs = durationMs(rtt)
+			}
+			result.Nodes[i] = clock
+		}(i, node)
+	}
+	wg.Wait()
+
+	// Istiod issues the workload certificates, so its clock is the one that matters; without it the
+	// median node stands in
+	var measured, istiodOffsets []float64
+	for _, clock := range result.Nodes {
+		if clock.Error != "" {
+			result.Issues = append(result.Issues, fmt.Sprintf("Could not read the clock of node %s: %s", clock.Node, clock.Error))
+			continue
+		}
+		measured = append(measured, clock.OffsetMs)
+		if clock.Istiod {
+			istiodOffsets = append(istiodOffsets, clock.OffsetMs)
+		}
+	}
+	if len(measured) == 0 {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+	reference := median(measured)
+	result.Reference = "median node clock"
+	if len(istiodOffsets) > 0 {
+		reference = median(istiodOffsets)
+		result.Reference = "clock of the istiod node"
+	} else {
+		result.Notes = append(result.Notes, fmt.Sprintf("No running istiod in %s; skew is measured against the median node", params.IstioNamespace))
+	}
+
+	for i := range result.Nodes {
+		clock := &result.Nodes[i]
+		if clock.Error != "" {
+			continue
+		}
+		clock.SkewMs = round3(clock.OffsetMs - reference)
+		skew := time.Duration(math.Abs(clock.SkewMs) * float64(time.Millisecond))
+		// The reading itself is only accurate to half its round trip
+		if skew <= maxSkew || skew <= time.Duration(clock.RTTMs/2*float64(time.Millisecond)) {
+			continue
+		}
+		direction := "behind"
+		consequence := "freshly issued workload certificates are not yet valid there, so mTLS handshakes fail right after every rotation"
+		if clock.SkewMs > 0 {
+			direction = "ahead of"
+			consequence = "certificates and tokens expire early there, so proxies on it may reject peer certificates as expired"
+		}
+		issue := fmt.Sprintf("Node %s is %s %s the %s; %s", clock.Node, skew.Round(time.Millisecond), direction, result.Reference, consequence)
+		if skew >= tokenSkewThreshold {
+			issue += fmt.Sprintf("; beyond %s, JWT and service account token validation (nbf/exp with the usual leeway) fails as well", tokenSkewThreshold)
+		}
+		result.Issues = append(result.Issues, issue+". Check NTP/chrony on the node")
+	}
+
+	if local := time.Duration(math.Abs(reference) * float64(time.Millisecond)); local > maxSkew {
+		result.Notes = append(result.Notes, fmt.Sprintf("The meshpilot host clock differs from the %s by %s; this does not affect the mesh", result.Reference, local.Round(time.Millisecond)))
+	}
+	sort.Slice(result.Nodes, func(i, j int) bool { return result.Nodes[i].Node < result.Nodes[j].Node })
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// readNodeClock starts a debug pod on a node and returns the node clock's offset from this host's clock,
+// taken from the reading with the shortest exec round trip, and that round trip
+func (m *Manager) readNodeClock(ctx context.Context, namespace, node, image string) (time.Duration, time.Duration, error) {
+	pods := m.k8sClient.Kubernetes.CoreV1().Pods(namespace)
+	pod := nodeDebugPod(namespace, node, image, []string{"sleep", "300"})
+	markManaged(ctx, pod)
+
+	created, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to create debug pod: %w", err)
+	}
+	defer func() {
+		_ = pods.Delete(context.WithoutCancel(ctx), created.Name, metav1.DeleteOptions{GracePeriodSeconds: new(int64)})
+	}()
+
+	err = wait.PollUntilContextTimeout(ctx, time.Second, nodeDebugPodTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		if current.Status.Phase == corev1.PodRunning {
+			return true, nil
+		}
+		for _, status := range current.Status.ContainerStatuses {
+			if waiting := status.State.Waiting; waiting != nil {
+				switch waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerError", "CreateContainerConfigError":
+					return false, fmt.Errorf("debug pod is not starting: %s: %s", waiting.Reason, waiting.Message)
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("waiting for debug pod %s/%s: %w", namespace, created.Name, err)
+	}
+
+	var best, bestRTT time.Duration
+	var lastErr error
+	for sample := 0; sample < clockSamples; sample++ {
+		before := time.Now()
+		output, err := m.execCommandInPod(ctx, namespace, created.Name, "debugger", []string{"date", "+%s.%N"})
+		after := time.Now()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		nodeTime, err := parseEpochSeconds(strings.TrimSpace(output))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		rtt := after.Sub(before)
+		if bestRTT == 0 || rtt < bestRTT {
+			bestRTT = rtt
+			best = nodeTime.Sub(before.Add(rtt / 2))
+		}
+	}
+	if bestRTT == 0 {
+		return 0, 0, fmt.Errorf("failed to read the clock: %v", lastErr)
+	}
+	return best, bestRTT, nil
+}
+
+// parseEpochSeconds parses the output of date +%s.%N; date implementations without %N print seconds only
+func parseEpochSeconds(value string) (time.Time, error) {
+	seconds, fraction, _ := strings.Cut(value, ".")
+	secs, err := strconv.ParseInt(seconds, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("unexpected date output %q", value)
+	}
+	nanos, err := strconv.ParseInt((fraction + "000000000")[:9], 10, 64)
+	if err != nil {
+		nanos = 0
+	}
+	return time.Unix(secs, nanos), nil
+}
+
+// median returns the median of values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
+
+// durationMs converts a duration to milliseconds with microsecond precision
+func durationMs(d time.Duration) float64 {
+	return round3(float64(d) / float64(time.Millisecond))
+}
@@ -4,20 +4,47 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+	"k8s.io/client-go/util/homedir"
 )
 
 // ClusterInfo represents cluster information
 type ClusterInfo struct {
-	Name       string            `json:"name"`
-	Server     string            `json:"server"`
-	Version    string            `json:"version"`
-	Nodes      int               `json:"nodes"`
-	Namespaces []string          `json:"namespaces"`
-	Context    string            `json:"context"`
-	Labels     map[string]string `json:"labels,omitempty"`
+	Name             string             `json:"name"`
+	Server           string             `json:"server"`
+	Version          string             `json:"version"`
+	Nodes            int                `json:"nodes"`
+	Namespaces       []string           `json:"namespaces,omitempty"`
+	NamespaceDetails []NamespaceSummary `json:"namespace_details,omitempty"` // replaces namespaces with namespace_details
+	Context          string             `json:"context"`
+	Labels           map[string]string  `json:"labels,omitempty"`
+	ClusterEnvironment
+	Pagination
+}
+
+// NamespaceSummary describes a namespace's pods and sidecar injection
+type NamespaceSummary struct {
+	Name        string `json:"name"`
+	Pods        int    `json:"pods"`
+	RunningPods int    `json:"running_pods"`
+	Injection   string `json:"injection"` // sidecar revision, ambient, disabled or none
+}
+
+// KubeconfigStatus is the result of configure_kubeconfig
+type KubeconfigStatus struct {
+	Kubeconfig     string   `json:"kubeconfig"`
+	Contexts       []string `json:"contexts"`
+	CurrentContext string   `json:"current_context"`
+	Connected      bool     `json:"connected"`
+	ServerVersion  string   `json:"server_version,omitempty"`
+	Error          string   `json:"error,omitempty"`
 }
 
 // ContextInfo represents a Kubernetes context
@@ -30,7 +57,7 @@ type ContextInfo struct {
 }
 
 // ListContexts lists available Kubernetes contexts
-func (m *Manager) ListContexts(args json.RawMessage) (*CallToolResult, error) {
+func (m *Manager) ListContexts(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
 	loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
 	configOverrides := &clientcmd.ConfigOverrides{}
 	kubeConfig := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, configOverrides)
@@ -70,11 +97,14 @@ func (m *Manager) ListContexts(args json.RawMessage) (*CallToolResult, error) {
 	}, nil
 }
 
+// switchContextParams are the arguments of switch_context
+type switchContextParams struct {
+	Context string `json:"context"`
+}
+
 // SwitchContext switches to a different Kubernetes context
-func (m *Manager) SwitchContext(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Context string `json:"context"`
-	}
+func (m *Manager) SwitchContext(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params switchContextParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -140,80 +170,277 @@ func (m *Manager) SwitchContext(args json.RawMessage) (*CallToolResult, error) {
 		}, nil
 	}
 
+	// Point the tools at the new context
+	message := fmt.Sprintf("Successfully switched to context: %s", params.Context)
+	if err := m.reconnectCluster(); err != nil {
+		message += fmt.Sprintf(" (the cluster is not reachable yet: %v)", err)
+	}
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: message,
+			},
+		},
+	}, nil
+}
+
+// configureKubeconfigParams are the arguments of configure_kubeconfig
+type configureKubeconfigParams struct {
+	Path    string `json:"path,omitempty"`    // existing kubeconfig file
+	Content string `json:"content,omitempty"` // kubeconfig YAML, saved to ~/.meshpilot/kubeconfig
+	Context string `json:"context,omitempty"` // context to select, default: the kubeconfig's current context
+}
+
+// ConfigureKubeconfig points the tools at a kubeconfig file or inline kubeconfig at runtime, for servers that started
+// without one or whose credentials were rotated, and reports whether the selected cluster is reachable
+func (m *Manager) ConfigureKubeconfig(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureKubeconfigParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if (params.Path == "") == (params.Content == "") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Exactly one of path or content is required",
+				},
+			},
+		}, nil
+	}
+
+	path := params.Path
+	var kubeconfig *clientcmdapi.Config
+	var err error
+	if params.Content != "" {
+		kubeconfig, err = clientcmd.Load([]byte(params.Content))
+	} else {
+		path, err = filepath.Abs(path)
+		if err == nil {
+			kubeconfig, err = clientcmd.LoadFromFile(path)
+		}
+	}
+	if err == nil && len(kubeconfig.Contexts) == 0 {
+		err = fmt.Errorf("no contexts defined")
+	}
+	if err == nil && params.Context != "" {
+		if _, ok := kubeconfig.Contexts[params.Context]; !ok {
+			err = fmt.Errorf("context %q does not exist", params.Context)
+		}
+		kubeconfig.CurrentContext = params.Context
+	}
+	if err == nil && kubeconfig.Contexts[kubeconfig.CurrentContext] == nil {
+		err = fmt.Errorf("no current context; set the context parameter")
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid kubeconfig: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Inline kubeconfigs are kept beside the other MeshPilot state, readable by the owner only
+	if params.Content != "" {
+		home := homedir.HomeDir()
+		if home == "" {
+			err = fmt.Errorf("no home directory")
+		} else {
+			path = filepath.Join(home, ".meshpilot", "kubeconfig")
+			err = os.MkdirAll(filepath.Dir(path), 0700)
+		}
+	}
+	if err == nil && (params.Content != "" || params.Context != "") {
+		err = clientcmd.WriteToFile(*kubeconfig, path)
+	}
+	if err == nil && params.Content != "" {
+		// A kubeconfig saved earlier keeps its mode when rewritten
+		err = os.Chmod(path, 0600)
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to save kubeconfig: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// kubectl, Helm and the per-context clients all follow KUBECONFIG
+	os.Setenv("KUBECONFIG", path)
+	m.clients.Reset()
+
+	result := &KubeconfigStatus{
+		Kubeconfig:     path,
+		CurrentContext: kubeconfig.CurrentContext,
+	}
+	for name := range kubeconfig.Contexts {
+		result.Contexts = append(result.Contexts, name)
+	}
+	sort.Strings(result.Contexts)
+
+	if err := m.reconnectCluster(); err != nil {
+		result.Error = err.Error()
+	} else if version, err := m.k8sClient.Kubernetes.Discovery().ServerVersion(); err != nil {
+		result.Error = fmt.Sprintf("cluster not reachable: %v", err)
+	} else {
+		result.Connected = true
+		result.ServerVersion = version.GitVersion
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
 		Content: []interface{}{
 			TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Successfully switched to context: %s", params.Context),
+				Text: string(resultJSON),
 			},
 		},
 	}, nil
 }
 
+// getClusterInfoParams are the arguments of get_cluster_info
+type getClusterInfoParams struct {
+	IncludeNamespaces *bool  `json:"include_namespaces,omitempty"` // default: true
+	NamespaceSelector string `json:"namespace_selector,omitempty"` // label selector for the namespaces listed
+	NamespaceDetails  bool   `json:"namespace_details,omitempty"`  // pod counts and injection per namespace
+}
+
 // GetClusterInfo gets information about the current cluster
-func (m *Manager) GetClusterInfo(args json.RawMessage) (*CallToolResult, error) {
-	ctx := context.Background()
+func (m *Manager) GetClusterInfo(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getClusterInfoParams
 
-	// Get server version
-	version, err := m.k8sClient.Kubernetes.Discovery().ServerVersion()
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IncludeNamespaces == nil {
+		includeNamespaces := true
+		params.IncludeNamespaces = &includeNamespaces
+	}
+
+	pages, err := m.newPager("get_cluster_info", args)
 	if err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Failed to get server version: %v", err),
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
 				},
 			},
 		}, nil
 	}
 
-	// Get nodes
-	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	// Get server version
+	version, err := m.k8sClient.Kubernetes.Discovery().ServerVersion()
 	if err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Failed to get nodes: %v", err),
+					Text: fmt.Sprintf("Failed to get server version: %v", err),
 				},
 			},
 		}, nil
 	}
 
-	// Get namespaces
-	namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	// Get nodes
+	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
 	if err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Failed to get namespaces: %v", err),
+					Text: fmt.Sprintf("Failed to get nodes: %v", err),
 				},
 			},
 		}, nil
 	}
 
+	// Get namespaces
+	namespaces := &corev1.NamespaceList{}
+	if *params.IncludeNamespaces {
+		namespaces, err = m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: params.NamespaceSelector})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get namespaces: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Get current context
 	currentContext, err := m.k8sClient.GetCurrentContext()
 	if err != nil {
 		currentContext = "unknown"
 	}
 
-	var nsNames []string
-	for _, ns := range namespaces.Items {
-		nsNames = append(nsNames, ns.Name)
+	clusterInfo := ClusterInfo{
+		Name:    currentContext,
+		Server:  m.k8sClient.Config.Host,
+		Version: version.GitVersion,
+		Nodes:   len(nodes.Items),
+		Context: currentContext,
+
+		ClusterEnvironment: m.detectClusterEnvironment(ctx, version.GitVersion, nodes.Items),
 	}
 
-	clusterInfo := ClusterInfo{
-		Name:       currentContext,
-		Server:     m.k8sClient.Config.Host,
-		Version:    version.GitVersion,
-		Nodes:      len(nodes.Items),
-		Namespaces: nsNames,
-		Context:    currentContext,
+	if params.NamespaceDetails {
+		summaries, err := m.namespaceSummaries(ctx, namespaces.Items)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list pods: %v", err),
+					},
+				},
+			}, nil
+		}
+		clusterInfo.NamespaceDetails, clusterInfo.Pagination = paginate(pages, summaries)
+	} else {
+		var nsNames []string
+		for _, ns := range namespaces.Items {
+			nsNames = append(nsNames, ns.Name)
+		}
+		clusterInfo.Namespaces, clusterInfo.Pagination = paginate(pages, nsNames)
 	}
 
 	result, _ := json.MarshalIndent(clusterInfo, "", "  ")
@@ -226,3 +453,40 @@ func (m *Manager) GetClusterInfo(args json.RawMessage) (*CallToolResult, error)
 		},
 	}, nil
 }
+
+// namespaceSummaries counts the pods of each namespace and reports how its pods join the mesh
+func (m *Manager) namespaceSummaries(ctx context.Context, namespaces []corev1.Namespace) ([]NamespaceSummary, error) {
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	total := make(map[string]int)
+	running := make(map[string]int)
+	for _, pod := range pods.Items {
+		total[pod.Namespace]++
+		if pod.Status.Phase == corev1.PodRunning {
+			running[pod.Namespace]++
+		}
+	}
+
+	summaries := make([]NamespaceSummary, 0, len(namespaces))
+	for i := range namespaces {
+		ns := &namespaces[i]
+		summary := NamespaceSummary{
+			Name:        ns.Name,
+			Pods:        total[ns.Name],
+			RunningPods: running[ns.Name],
+			Injection:   "none",
+		}
+		switch {
+		case ns.Labels["istio-injection"] == "disabled":
+			summary.Injection = "disabled"
+		case namespaceInjectionRevision(ns) != "":
+			summary.Injection = "sidecar (" + namespaceInjectionRevision(ns) + ")"
+		case ns.Labels["istio.io/dataplane-mode"] == "ambient":
+			summary.Injection = "ambient"
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
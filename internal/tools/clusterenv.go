@@ -0,0 +1,180 @@
+package tools
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var gatewayClassGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gatewayclasses"}
+
+// cniDaemonSets maps the DaemonSets network plugins run as to the plugin; a name matches exactly or as a prefix
+// followed by a dash
+var cniDaemonSets = []struct {
+	name string
+	cni  string
+}{
+	{"calico-node", "calico"},
+	{"cilium", "cilium"},
+	{"anetd", "cilium"}, // GKE Dataplane V2
+	{"aws-node", "aws-vpc-cni"},
+	{"azure-cns", "azure-cni"},
+	{"kindnet", "kindnet"},
+	{"kube-flannel", "flannel"},
+	{"canal", "canal"},
+	{"weave-net", "weave"},
+	{"ovnkube-node", "ovn-kubernetes"},
+	{"sdn", "openshift-sdn"},
+	{"antrea-agent", "antrea"},
+	{"kube-router", "kube-router"},
+	{"istio-cni-node", "istio-cni"}, // chained after the primary plugin
+}
+
+// platformNodeLabels identify managed platforms by the labels they put on nodes
+var platformNodeLabels = []struct {
+	label    string
+	platform string
+}{
+	{"node.openshift.io/os_id", "openshift"},
+	{"eks.amazonaws.com/nodegroup", "eks"},
+	{"alpha.eksctl.io/cluster-name", "eks"},
+	{"cloud.google.com/gke-nodepool", "gke"},
+	{"kubernetes.azure.com/cluster", "aks"},
+	{"minikube.k8s.io/name", "minikube"},
+}
+
+// ClassInfo describes an IngressClass or GatewayClass
+type ClassInfo struct {
+	Name       string `json:"name"`
+	Controller string `json:"controller"`
+	Default    bool   `json:"default,omitempty"`
+}
+
+// ClusterEnvironment is what get_cluster_info reports beyond the version and nodes, to help choose install options
+type ClusterEnvironment struct {
+	Platform       string         `json:"platform"`                 // eks, gke, aks, openshift, kind, minikube, k3s, rke2 or unknown
+	CloudProvider  string         `json:"cloud_provider,omitempty"` // provider ID scheme of the nodes, e.g. aws or gce
+	NodePlatforms  map[string]int `json:"node_platforms"`           // node count per os/arch
+	CNI            []string       `json:"cni,omitempty"`
+	IngressClasses []ClassInfo    `json:"ingress_classes,omitempty"`
+	GatewayClasses []ClassInfo    `json:"gateway_classes,omitempty"`
+	MetricsServer  bool           `json:"metrics_server"`
+	LoadBalancer   string         `json:"load_balancer"` // cloud, metallb, servicelb or none
+}
+
+// detectClusterEnvironment inspects the cluster's platform and add-ons. Each check is best effort: a check the
+// credentials may not run is left out rather than failing get_cluster_info
+func (m *Manager) detectClusterEnvironment(ctx context.Context, gitVersion string, nodes []corev1.Node) ClusterEnvironment {
+	env := ClusterEnvironment{NodePlatforms: make(map[string]int)}
+	for _, node := range nodes {
+		env.NodePlatforms[node.Status.NodeInfo.OperatingSystem+"/"+node.Status.NodeInfo.Architecture]++
+	}
+	env.Platform, env.CloudProvider = m.detectPlatform(gitVersion, nodes)
+	env.MetricsServer = m.servesGroupVersion("metrics.k8s.io/v1beta1")
+
+	var serviceLB bool
+	daemonSets, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logrus.Debugf("Skipping CNI detection: %v", err)
+	} else {
+		found := make(map[string]bool)
+		for _, daemonSet := range daemonSets.Items {
+			if strings.HasPrefix(daemonSet.Name, "svclb-") {
+				serviceLB = true
+			}
+			for _, known := range cniDaemonSets {
+				if daemonSet.Name == known.name || strings.HasPrefix(daemonSet.Name, known.name+"-") {
+					found[known.cni] = true
+				}
+			}
+		}
+		for cni := range found {
+			env.CNI = append(env.CNI, cni)
+		}
+		sort.Strings(env.CNI)
+	}
+
+	ingressClasses, err := m.k8sClient.Kubernetes.NetworkingV1().IngressClasses().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logrus.Debugf("Skipping IngressClass detection: %v", err)
+	} else {
+		for _, class := range ingressClasses.Items {
+			env.IngressClasses = append(env.IngressClasses, ClassInfo{
+				Name:       class.Name,
+				Controller: class.Spec.Controller,
+				Default:    class.Annotations["ingressclass.kubernetes.io/is-default-class"] == "true",
+			})
+		}
+	}
+
+	gatewayClasses, err := m.k8sClient.Dynamic.Resource(gatewayClassGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		logrus.Debugf("Skipping GatewayClass detection: %v", err)
+	} else {
+		for _, class := range gatewayClasses.Items {
+			controller, _, _ := unstructured.NestedString(class.Object, "spec", "controllerName")
+			env.GatewayClasses = append(env.GatewayClasses, ClassInfo{Name: class.GetName(), Controller: controller})
+		}
+	}
+
+	switch {
+	case m.servesGroupVersion("metallb.io/v1beta1"):
+		env.LoadBalancer = "metallb"
+	case serviceLB:
+		env.LoadBalancer = "servicelb"
+	case env.Platform == "eks" || env.Platform == "gke" || env.Platform == "aks" ||
+		(env.CloudProvider != "" && env.CloudProvider != "kind"):
+		env.LoadBalancer = "cloud"
+	default:
+		env.LoadBalancer = "none"
+	}
+	return env
+}
+
+// detectPlatform names the distribution or managed service the cluster runs on, and the cloud its nodes run in
+func (m *Manager) detectPlatform(gitVersion string, nodes []corev1.Node) (string, string) {
+	var cloud string
+	for _, node := range nodes {
+		if scheme, _, found := strings.Cut(node.Spec.ProviderID, "://"); found {
+			cloud = scheme
+			break
+		}
+	}
+
+	if m.servesGroupVersion("config.openshift.io/v1") {
+		return "openshift", cloud
+	}
+	for _, node := range nodes {
+		for _, known := range platformNodeLabels {
+			if _, ok := node.Labels[known.label]; ok {
+				return known.platform, cloud
+			}
+		}
+	}
+	switch {
+	case strings.Contains(gitVersion, "-eks-"):
+		return "eks", cloud
+	case strings.Contains(gitVersion, "-gke."):
+		return "gke", cloud
+	case strings.Contains(gitVersion, "+k3s"):
+		return "k3s", cloud
+	case strings.Contains(gitVersion, "+rke2"):
+		return "rke2", cloud
+	case cloud == "kind":
+		return "kind", cloud
+	}
+	return "unknown", cloud
+}
+
+// servesGroupVersion reports whether the API server serves an API group version, e.g. one added by an aggregated
+// API server or a CRD
+func (m *Manager) servesGroupVersion(groupVersion string) bool {
+	resources, err := m.k8sClient.Kubernetes.Discovery().ServerResourcesForGroupVersion(groupVersion)
+	return err == nil && len(resources.APIResources) > 0
+}
@@ -0,0 +1,935 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var (
+	ciliumPolicyGVR        = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumnetworkpolicies"}
+	ciliumClusterPolicyGVR = schema.GroupVersionResource{Group: "cilium.io", Version: "v2", Resource: "ciliumclusterwidenetworkpolicies"}
+
+	// Calico serves projectcalico.org/v3 through its API server; the backing CRDs are always present
+	calicoPolicyGVRs = []schema.GroupVersionResource{
+		{Group: "projectcalico.org", Version: "v3", Resource: "networkpolicies"},
+		{Group: "crd.projectcalico.org", Version: "v1", Resource: "networkpolicies"},
+	}
+	calicoGlobalPolicyGVRs = []schema.GroupVersionResource{
+		{Group: "projectcalico.org", Version: "v3", Resource: "globalnetworkpolicies"},
+		{Group: "crd.projectcalico.org", Version: "v1", Resource: "globalnetworkpolicies"},
+	}
+)
+
+// calicoKubernetesPolicyOrder is the order Calico assigns to Kubernetes NetworkPolicies
+const calicoKubernetesPolicyOrder = 1000
+
+// CNIPolicyInfo represents a Cilium or Calico policy
+type CNIPolicyInfo struct {
+	Kind      string      `json:"kind"`
+	Name      string      `json:"name"`
+	Namespace string      `json:"namespace,omitempty"`
+	Selector  string      `json:"selector"`
+	Order     *float64    `json:"order,omitempty"`
+	Spec      interface{} `json:"spec"`
+}
+
+// cniPolicy is a decoded CNI-specific policy; a Cilium policy with several specs yields one entry per spec
+type cniPolicy struct {
+	kind      string
+	name      string
+	namespace string // empty for cluster-wide policies
+	cilium    *ciliumPolicySpec
+	calico    *calicoPolicySpec
+	spec      interface{}
+}
+
+// ciliumPolicySpec is the subset of a CiliumNetworkPolicy spec used for analysis
+type ciliumPolicySpec struct {
+	EndpointSelector *metav1.LabelSelector `json:"endpointSelector,omitempty"`
+	NodeSelector     *metav1.LabelSelector `json:"nodeSelector,omitempty"`
+	Ingress          []ciliumRule          `json:"ingress,omitempty"`
+	IngressDeny      []ciliumRule          `json:"ingressDeny,omitempty"`
+	Egress           []ciliumRule          `json:"egress,omitempty"`
+	EgressDeny       []ciliumRule          `json:"egressDeny,omitempty"`
+}
+
+// ciliumRule is an ingress or egress rule of a Cilium policy
+type ciliumRule struct {
+	FromEndpoints []metav1.LabelSelector `json:"fromEndpoints,omitempty"`
+	FromEntities  []string               `json:"fromEntities,omitempty"`
+	FromCIDR      []string               `json:"fromCIDR,omitempty"`
+	FromCIDRSet   []interface{}          `json:"fromCIDRSet,omitempty"`
+	ToEndpoints   []metav1.LabelSelector `json:"toEndpoints,omitempty"`
+	ToEntities    []string               `json:"toEntities,omitempty"`
+	ToCIDR        []string               `json:"toCIDR,omitempty"`
+	ToCIDRSet     []interface{}          `json:"toCIDRSet,omitempty"`
+	ToFQDNs       []interface{}          `json:"toFQDNs,omitempty"`
+	ToServices    []interface{}          `json:"toServices,omitempty"`
+	ToPorts       []struct {
+		Ports []struct {
+			Port     string `json:"port"`
+			EndPort  int32  `json:"endPort,omitempty"`
+			Protocol string `json:"protocol,omitempty"`
+		} `json:"ports,omitempty"`
+	} `json:"toPorts,omitempty"`
+}
+
+// calicoPolicySpec is the subset of a Calico NetworkPolicy or GlobalNetworkPolicy spec used for analysis
+type calicoPolicySpec struct {
+	Order             *float64     `json:"order,omitempty"`
+	Tier              string       `json:"tier,omitempty"`
+	Selector          string       `json:"selector,omitempty"`
+	NamespaceSelector string       `json:"namespaceSelector,omitempty"`
+	Types             []string     `json:"types,omitempty"`
+	Ingress           []calicoRule `json:"ingress,omitempty"`
+	Egress            []calicoRule `json:"egress,omitempty"`
+}
+
+// calicoRule is an ingress or egress rule of a Calico policy
+type calicoRule struct {
+	Action      string       `json:"action"`
+	Protocol    interface{}  `json:"protocol,omitempty"`
+	Source      calicoEntity `json:"source,omitempty"`
+	Destination calicoEntity `json:"destination,omitempty"`
+}
+
+// calicoEntity is the source or destination of a Calico rule
+type calicoEntity struct {
+	Selector          string        `json:"selector,omitempty"`
+	NamespaceSelector string        `json:"namespaceSelector,omitempty"`
+	Nets              []string      `json:"nets,omitempty"`
+	NotNets           []string      `json:"notNets,omitempty"`
+	Ports             []interface{} `json:"ports,omitempty"`
+}
+
+// listCNIPolicies returns the Cilium and Calico policies of a namespace together with cluster-wide ones.
+// CRDs that are not installed are skipped silently.
+func (m *Manager) listCNIPolicies(ctx context.Context, namespace string) ([]cniPolicy, []string) {
+	var policies []cniPolicy
+	var notes []string
+
+	if m.k8sClient.Dynamic == nil {
+		return nil, []string{"Dynamic client not available; Cilium and Calico policies were not evaluated"}
+	}
+
+	decode := func(kind string, items []map[string]interface{}) {
+		for _, item := range items {
+			metadata, _ := item["metadata"].(map[string]interface{})
+			name, _ := metadata["name"].(string)
+			ns, _ := metadata["namespace"].(string)
+
+			if strings.HasPrefix(kind, "Cilium") {
+				var specs []interface{}
+				if spec, ok := item["spec"]; ok {
+					specs = append(specs, spec)
+				}
+				if list, ok := item["specs"].([]interface{}); ok {
+					specs = append(specs, list...)
+				}
+				for _, raw := range specs {
+					spec := &ciliumPolicySpec{}
+					if err := remarshal(raw, spec); err != nil {
+						notes = append(notes, fmt.Sprintf("Failed to decode %s %s: %v", kind, name, err))
+						continue
+					}
+					policies = append(policies, cniPolicy{kind: kind, name: name, namespace: ns, cilium: spec, spec: raw})
+				}
+				continue
+			}
+
+			spec := &calicoPolicySpec{}
+			if err := remarshal(item["spec"], spec); err != nil {
+				notes = append(notes, fmt.Sprintf("Failed to decode %s %s: %v", kind, name, err))
+				continue
+			}
+			policies = append(policies, cniPolicy{kind: kind, name: name, namespace: ns, calico: spec, spec: item["spec"]})
+		}
+	}
+
+	list := func(gvr schema.GroupVersionResource, ns string) ([]map[string]interface{}, bool) {
+		var resource dynamic.ResourceInterface = m.k8sClient.Dynamic.Resource(gvr)
+		if ns != "" {
+			resource = m.k8sClient.Dynamic.Resource(gvr).Namespace(ns)
+		}
+		result, err := resource.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				notes = append(notes, fmt.Sprintf("Failed to list %s: %v", gvr.GroupResource(), err))
+			}
+			return nil, false
+		}
+		var items []map[string]interface{}
+		for _, item := range result.Items {
+			items = append(items, item.Object)
+		}
+		return items, true
+	}
+
+	if items, ok := list(ciliumPolicyGVR, namespace); ok {
+		decode("CiliumNetworkPolicy", items)
+	}
+	if items, ok := list(ciliumClusterPolicyGVR, ""); ok {
+		decode("CiliumClusterwideNetworkPolicy", items)
+	}
+	for _, gvr := range calicoPolicyGVRs {
+		if items, ok := list(gvr, namespace); ok {
+			decode("CalicoNetworkPolicy", items)
+			break
+		}
+	}
+	for _, gvr := range calicoGlobalPolicyGVRs {
+		if items, ok := list(gvr, ""); ok {
+			decode("CalicoGlobalNetworkPolicy", items)
+			break
+		}
+	}
+
+	// Calico's own copies of Kubernetes policies are already covered by the NetworkPolicy analysis
+	filtered := policies[:0]
+	for _, policy := range policies {
+		if policy.calico != nil && strings.HasPrefix(policy.name, "knp.default.") {
+			continue
+		}
+		filtered = append(filtered, policy)
+	}
+
+	return filtered, notes
+}
+
+// info converts a CNI policy for listing
+func (p *cniPolicy) info() CNIPolicyInfo {
+	info := CNIPolicyInfo{Kind: p.kind, Name: p.name, Namespace: p.namespace, Spec: p.spec}
+	if p.cilium != nil {
+		if p.cilium.EndpointSelector != nil {
+			info.Selector = metav1.FormatLabelSelector(p.cilium.EndpointSelector)
+		} else if p.cilium.NodeSelector != nil {
+			info.Selector = "node: " + metav1.FormatLabelSelector(p.cilium.NodeSelector)
+		}
+	} else {
+		info.Selector = p.calico.Selector
+		if info.Selector == "" {
+			info.Selector = "all()"
+		}
+		info.Order = p.calico.Order
+	}
+	return info
+}
+
+// ref names a CNI policy in verdicts
+func (p *cniPolicy) ref() string {
+	if p.namespace == "" {
+		return fmt.Sprintf("%s %s", p.kind, p.name)
+	}
+	return fmt.Sprintf("%s %s/%s", p.kind, p.namespace, p.name)
+}
+
+// selects reports whether the policy applies to the endpoint
+func (p *cniPolicy) selects(endpoint *policyEndpoint) bool {
+	if p.namespace != "" && p.namespace != endpoint.pod.Namespace {
+		return false
+	}
+	if p.cilium != nil {
+		// Host policies select nodes rather than pods
+		if p.cilium.EndpointSelector == nil {
+			return false
+		}
+		return selectorMatches(normalizeCiliumSelector(p.cilium.EndpointSelector), ciliumLabels(endpoint))
+	}
+
+	if ok, err := calicoSelectorMatches(p.calico.Selector, calicoLabels(endpoint)); err != nil || !ok {
+		return false
+	}
+	if p.namespace == "" && p.calico.NamespaceSelector != "" {
+		ok, err := calicoSelectorMatches(p.calico.NamespaceSelector, calicoNamespaceLabels(endpoint))
+		return err == nil && ok
+	}
+	return true
+}
+
+// isDefaultDeny reports whether the policy selects every endpoint and allows nothing in a direction
+func (p *cniPolicy) isDefaultDeny(direction networkingv1.PolicyType) bool {
+	if p.cilium != nil {
+		selector := p.cilium.EndpointSelector
+		if selector == nil || len(selector.MatchLabels) > 0 || len(selector.MatchExpressions) > 0 {
+			return false
+		}
+		rules := p.cilium.Ingress
+		if direction == networkingv1.PolicyTypeEgress {
+			rules = p.cilium.Egress
+		}
+		if rules == nil {
+			return false
+		}
+		for _, rule := range rules {
+			data, _ := json.Marshal(rule)
+			if string(data) != "{}" {
+				return false
+			}
+		}
+		return true
+	}
+
+	if selector := strings.TrimSpace(p.calico.Selector); selector != "" && selector != "all()" {
+		return false
+	}
+	if !calicoHasType(p.calico, direction) {
+		return false
+	}
+	if direction == networkingv1.PolicyTypeIngress {
+		return len(p.calico.Ingress) == 0
+	}
+	return len(p.calico.Egress) == 0
+}
+
+// applyCNIPolicies refines a NetworkPolicy verdict with the Cilium and Calico policies selecting the subject
+func applyCNIPolicies(verdict *PolicyVerdict, direction networkingv1.PolicyType, policies []cniPolicy, subject, peer *policyEndpoint, port int32, protocol string) {
+	destination := peer
+	if direction == networkingv1.PolicyTypeIngress {
+		destination = subject
+	}
+	k8sIsolated := verdict.Isolated
+	k8sRuleAllowed := verdict.Isolated && verdict.Allowed
+
+	var ciliumPolicies, calicoPolicies []*cniPolicy
+	for i := range policies {
+		policy := &policies[i]
+		if !policy.selects(subject) {
+			continue
+		}
+		if policy.cilium != nil {
+			ciliumPolicies = append(ciliumPolicies, policy)
+		} else if calicoHasType(policy.calico, direction) {
+			calicoPolicies = append(calicoPolicies, policy)
+		}
+	}
+
+	// Cilium: allow rules from all policies are combined with NetworkPolicies; deny rules always win
+	if len(ciliumPolicies) > 0 {
+		selected := false
+		for _, policy := range ciliumPolicies {
+			rules, denies := policy.cilium.Ingress, policy.cilium.IngressDeny
+			if direction == networkingv1.PolicyTypeEgress {
+				rules, denies = policy.cilium.Egress, policy.cilium.EgressDeny
+			}
+			if rules == nil && denies == nil {
+				continue
+			}
+			selected = true
+			verdict.CNIPolicies = append(verdict.CNIPolicies, policy.ref())
+			for r, rule := range denies {
+				if ciliumRuleMatches(rule, direction, policy, peer, destination.pod, port, protocol) {
+					verdict.Isolated = true
+					verdict.Allowed = false
+					verdict.DecidingPolicy = policy.ref()
+					verdict.DecidingRule = r + 1
+					verdict.Reason = fmt.Sprintf("%s deny rule %d of %s matches port %d/%s", verdict.Direction, r+1, policy.ref(), port, protocol)
+					return
+				}
+			}
+		}
+
+		if selected {
+			verdict.Isolated = true
+			if !k8sRuleAllowed {
+				verdict.Allowed = false
+				for _, policy := range ciliumPolicies {
+					rules := policy.cilium.Ingress
+					if direction == networkingv1.PolicyTypeEgress {
+						rules = policy.cilium.Egress
+					}
+					for r, rule := range rules {
+						if ciliumRuleMatches(rule, direction, policy, peer, destination.pod, port, protocol) {
+							verdict.Allowed = true
+							verdict.DecidingPolicy = policy.ref()
+							verdict.DecidingRule = r + 1
+							verdict.Reason = fmt.Sprintf("%s rule %d of %s allows the peer on port %d/%s", verdict.Direction, r+1, policy.ref(), port, protocol)
+							break
+						}
+					}
+					if verdict.Allowed {
+						break
+					}
+				}
+				if !verdict.Allowed {
+					verdict.DecidingPolicy = ""
+					verdict.DecidingRule = 0
+					verdict.Reason = fmt.Sprintf("%s/%s is isolated for %s by %s and no rule matches the peer and port %d/%s",
+						subject.pod.Namespace, subject.pod.Name, verdict.Direction,
+						strings.Join(append(append([]string{}, verdict.SelectingPolicy...), verdict.CNIPolicies...), ", "), port, protocol)
+				}
+			}
+		}
+	}
+
+	// Calico: policies are evaluated by order, with Kubernetes policies at order 1000; the first
+	// matching Allow or Deny decides and an endpoint selected by any policy is denied otherwise
+	if len(calicoPolicies) == 0 {
+		return
+	}
+	sort.SliceStable(calicoPolicies, func(i, j int) bool {
+		oi, oj := calicoOrder(calicoPolicies[i].calico), calicoOrder(calicoPolicies[j].calico)
+		if oi != oj {
+			return oi < oj
+		}
+		return calicoPolicies[i].name < calicoPolicies[j].name
+	})
+	k8sVerdict := *verdict
+	k8sEvaluated := false
+
+	for _, policy := range calicoPolicies {
+		verdict.CNIPolicies = append(verdict.CNIPolicies, policy.ref())
+	}
+	verdict.Isolated = true
+
+	for _, policy := range calicoPolicies {
+		if !k8sEvaluated && calicoOrder(policy.calico) > calicoKubernetesPolicyOrder {
+			k8sEvaluated = true
+			if k8sRuleAllowed {
+				restoreK8sVerdict(verdict, k8sVerdict)
+				return
+			}
+		}
+
+		rules := policy.calico.Ingress
+		if direction == networkingv1.PolicyTypeEgress {
+			rules = policy.calico.Egress
+		}
+		for r, rule := range rules {
+			if !calicoRuleMatches(rule, direction, policy, subject, peer, destination.pod, port, protocol) {
+				continue
+			}
+			switch strings.ToLower(rule.Action) {
+			case "allow":
+				verdict.Allowed = true
+				verdict.Reason = fmt.Sprintf("%s rule %d of %s allows the peer on port %d/%s", verdict.Direction, r+1, policy.ref(), port, protocol)
+			case "deny":
+				verdict.Allowed = false
+				verdict.Reason = fmt.Sprintf("%s rule %d of %s denies the peer on port %d/%s", verdict.Direction, r+1, policy.ref(), port, protocol)
+			case "pass":
+				verdict.Allowed = true
+				verdict.Reason = fmt.Sprintf("%s rule %d of %s passes the traffic to the next tier; assumed allowed by the namespace profile", verdict.Direction, r+1, policy.ref())
+			default:
+				continue
+			}
+			verdict.DecidingPolicy = policy.ref()
+			verdict.DecidingRule = r + 1
+			return
+		}
+	}
+
+	if !k8sEvaluated && k8sRuleAllowed {
+		restoreK8sVerdict(verdict, k8sVerdict)
+		return
+	}
+
+	verdict.Allowed = false
+	verdict.DecidingPolicy = ""
+	verdict.DecidingRule = 0
+	selecting := append(append([]string{}, verdict.SelectingPolicy...), verdict.CNIPolicies...)
+	if !k8sIsolated {
+		selecting = verdict.CNIPolicies
+	}
+	verdict.Reason = fmt.Sprintf("%s/%s is selected for %s by %s and no rule matches the peer and port %d/%s (end-of-tier deny)",
+		subject.pod.Namespace, subject.pod.Name, verdict.Direction, strings.Join(selecting, ", "), port, protocol)
+}
+
+// restoreK8sVerdict keeps the NetworkPolicy decision while retaining the CNI policies that were considered
+func restoreK8sVerdict(verdict *PolicyVerdict, k8sVerdict PolicyVerdict) {
+	cniPolicies := verdict.CNIPolicies
+	*verdict = k8sVerdict
+	verdict.CNIPolicies = cniPolicies
+}
+
+// ciliumRuleMatches reports whether a Cilium rule matches the peer and port
+func ciliumRuleMatches(rule ciliumRule, direction networkingv1.PolicyType, policy *cniPolicy, peer *policyEndpoint, destPod *corev1.Pod, port int32, protocol string) bool {
+	endpoints, entities, cidrs := rule.FromEndpoints, rule.FromEntities, len(rule.FromCIDR)+len(rule.FromCIDRSet)
+	if direction == networkingv1.PolicyTypeEgress {
+		endpoints, entities = rule.ToEndpoints, rule.ToEntities
+		cidrs = len(rule.ToCIDR) + len(rule.ToCIDRSet) + len(rule.ToFQDNs) + len(rule.ToServices)
+	}
+
+	peerMatched := false
+	switch {
+	case endpoints == nil && entities == nil && cidrs == 0:
+		// An L4-only rule applies to every peer; an empty rule only enables default deny
+		peerMatched = len(rule.ToPorts) > 0
+	default:
+		for _, selector := range endpoints {
+			normalized := normalizeCiliumSelector(&selector)
+			if policy.namespace != "" && !selectsNamespaceLabel(normalized) && peer.pod.Namespace != policy.namespace {
+				continue
+			}
+			if selectorMatches(normalized, ciliumLabels(peer)) {
+				peerMatched = true
+				break
+			}
+		}
+		for _, entity := range entities {
+			if entity == "all" || entity == "cluster" {
+				peerMatched = true
+			}
+		}
+		// CIDR rules never match endpoints managed by Cilium, so they cannot match a pod peer
+	}
+	if !peerMatched {
+		return false
+	}
+
+	if len(rule.ToPorts) == 0 {
+		return true
+	}
+	for _, toPorts := range rule.ToPorts {
+		if len(toPorts.Ports) == 0 {
+			return true
+		}
+		for _, p := range toPorts.Ports {
+			if p.Protocol != "" && !strings.EqualFold(p.Protocol, "ANY") && !strings.EqualFold(p.Protocol, protocol) {
+				continue
+			}
+			rulePort := int32(0)
+			if n, err := strconv.Atoi(p.Port); err == nil {
+				rulePort = int32(n)
+			} else if p.Port != "" {
+				rulePort = resolveNamedPort(destPod, p.Port, "")
+			}
+			if rulePort == 0 && p.Port != "" && p.Port != "0" {
+				continue
+			}
+			if rulePort == 0 || rulePort == port || (p.EndPort != 0 && port >= rulePort && port <= p.EndPort) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// calicoRuleMatches reports whether a Calico rule matches the connection
+func calicoRuleMatches(rule calicoRule, direction networkingv1.PolicyType, policy *cniPolicy, subject, peer *policyEndpoint, destPod *corev1.Pod, port int32, protocol string) bool {
+	if rule.Protocol != nil && !calicoProtocolMatches(rule.Protocol, protocol) {
+		return false
+	}
+	source, destination := peer, subject
+	if direction == networkingv1.PolicyTypeEgress {
+		source, destination = subject, peer
+	}
+	if !calicoEntityMatches(rule.Source, policy, source) || !calicoEntityMatches(rule.Destination, policy, destination) {
+		return false
+	}
+	if len(rule.Destination.Ports) == 0 {
+		return true
+	}
+	for _, p := range rule.Destination.Ports {
+		if calicoPortMatches(p, destPod, port, protocol) {
+			return true
+		}
+	}
+	return false
+}
+
+// calicoEntityMatches reports whether an endpoint matches the selectors and nets of a rule entity
+func calicoEntityMatches(entity calicoEntity, policy *cniPolicy, endpoint *policyEndpoint) bool {
+	if entity.NamespaceSelector != "" {
+		ok, err := calicoSelectorMatches(entity.NamespaceSelector, calicoNamespaceLabels(endpoint))
+		if err != nil || !ok {
+			return false
+		}
+	} else if entity.Selector != "" && policy.namespace != "" && endpoint.pod.Namespace != policy.namespace {
+		// Selectors in namespaced policies are limited to the policy's namespace
+		return false
+	}
+	if entity.Selector != "" {
+		ok, err := calicoSelectorMatches(entity.Selector, calicoLabels(endpoint))
+		if err != nil || !ok {
+			return false
+		}
+	}
+	if len(entity.Nets) > 0 {
+		matched := false
+		for _, cidr := range entity.Nets {
+			if calicoNetContains(cidr, endpoint.pod.Status.PodIP) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	for _, cidr := range entity.NotNets {
+		if calicoNetContains(cidr, endpoint.pod.Status.PodIP) {
+			return false
+		}
+	}
+	return true
+}
+
+// calicoPortMatches matches a Calico port, range ("8000:8080") or named port
+func calicoPortMatches(value interface{}, destPod *corev1.Pod, port int32, protocol string) bool {
+	switch v := value.(type) {
+	case float64:
+		return int32(v) == port
+	case string:
+		if low, high, ok := strings.Cut(v, ":"); ok {
+			lo, errLo := strconv.Atoi(low)
+			hi, errHi := strconv.Atoi(high)
+			return errLo == nil && errHi == nil && int(port) >= lo && int(port) <= hi
+		}
+		if n, err := strconv.Atoi(v); err == nil {
+			return int32(n) == port
+		}
+		return resolveNamedPort(destPod, v, protocol) == port
+	}
+	return false
+}
+
+// calicoProtocolMatches compares a Calico protocol name or number with the analyzed protocol
+func calicoProtocolMatches(value interface{}, protocol string) bool {
+	numbers := map[string]float64{"TCP": 6, "UDP": 17, "SCTP": 132}
+	switch v := value.(type) {
+	case string:
+		return strings.EqualFold(v, protocol)
+	case float64:
+		return numbers[protocol] == v
+	}
+	return false
+}
+
+// calicoHasType reports whether a Calico policy applies to a direction, applying the API defaults
+func calicoHasType(spec *calicoPolicySpec, direction networkingv1.PolicyType) bool {
+	if len(spec.Types) == 0 {
+		if direction == networkingv1.PolicyTypeIngress {
+			return true
+		}
+		return len(spec.Egress) > 0
+	}
+	for _, t := range spec.Types {
+		if strings.EqualFold(t, string(direction)) {
+			return true
+		}
+	}
+	return false
+}
+
+// calicoOrder returns a policy's order; policies without one are evaluated last
+func calicoOrder(spec *calicoPolicySpec) float64 {
+	if spec.Order == nil {
+		return 1e18
+	}
+	return *spec.Order
+}
+
+// ciliumLabels returns the labels Cilium derives for a pod endpoint
+func ciliumLabels(endpoint *policyEndpoint) map[string]string {
+	set := make(map[string]string, len(endpoint.pod.Labels)+len(endpoint.namespaceLabels)+2)
+	for k, v := range endpoint.pod.Labels {
+		set[k] = v
+	}
+	for k, v := range endpoint.namespaceLabels {
+		set["io.cilium.k8s.namespace.labels."+k] = v
+	}
+	set["io.kubernetes.pod.namespace"] = endpoint.pod.Namespace
+	serviceAccount := endpoint.pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	set["io.cilium.k8s.policy.serviceaccount"] = serviceAccount
+	return set
+}
+
+// normalizeCiliumSelector strips the k8s: and any: label source prefixes from a selector
+func normalizeCiliumSelector(selector *metav1.LabelSelector) *metav1.LabelSelector {
+	strip := func(key string) string {
+		for _, prefix := range []string{"k8s:", "any:"} {
+			key = strings.TrimPrefix(key, prefix)
+		}
+		return key
+	}
+	normalized := &metav1.LabelSelector{}
+	if selector.MatchLabels != nil {
+		normalized.MatchLabels = make(map[string]string, len(selector.MatchLabels))
+		for k, v := range selector.MatchLabels {
+			normalized.MatchLabels[strip(k)] = v
+		}
+	}
+	for _, expr := range selector.MatchExpressions {
+		expr.Key = strip(expr.Key)
+		normalized.MatchExpressions = append(normalized.MatchExpressions, expr)
+	}
+	return normalized
+}
+
+// selectsNamespaceLabel reports whether a Cilium selector constrains the peer namespace explicitly
+func selectsNamespaceLabel(selector *metav1.LabelSelector) bool {
+	if _, ok := selector.MatchLabels["io.kubernetes.pod.namespace"]; ok {
+		return true
+	}
+	for _, expr := range selector.MatchExpressions {
+		if expr.Key == "io.kubernetes.pod.namespace" {
+			return true
+		}
+	}
+	return false
+}
+
+// calicoLabels returns the labels Calico exposes for a pod endpoint
+func calicoLabels(endpoint *policyEndpoint) map[string]string {
+	set := make(map[string]string, len(endpoint.pod.Labels)+3)
+	for k, v := range endpoint.pod.Labels {
+		set[k] = v
+	}
+	set["projectcalico.org/namespace"] = endpoint.pod.Namespace
+	set["projectcalico.org/orchestrator"] = "k8s"
+	serviceAccount := endpoint.pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	set["projectcalico.org/serviceaccount"] = serviceAccount
+	return set
+}
+
+// calicoNamespaceLabels returns the labels Calico exposes for a pod's namespace
+func calicoNamespaceLabels(endpoint *policyEndpoint) map[string]string {
+	set := make(map[string]string, len(endpoint.namespaceLabels)+1)
+	for k, v := range endpoint.namespaceLabels {
+		set[k] = v
+	}
+	set["projectcalico.org/name"] = endpoint.pod.Namespace
+	return set
+}
+
+// remarshal converts a decoded JSON value into a typed struct
+func remarshal(in, out interface{}) error {
+	data, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// calicoSelectorMatches evaluates a Calico selector expression such as
+// "app == 'web' && has(tier) && env in {'prod', 'staging'}" against a label set
+func calicoSelectorMatches(expr string, set map[string]string) (bool, error) {
+	if strings.TrimSpace(expr) == "" {
+		return true, nil
+	}
+	tokens, err := tokenizeCalicoSelector(expr)
+	if err != nil {
+		return false, err
+	}
+	p := &calicoSelectorParser{tokens: tokens, labels: set}
+	result, err := p.parseOr()
+	if err != nil {
+		return false, err
+	}
+	if p.pos != len(p.tokens) {
+		return false, fmt.Errorf("unexpected %q in selector %q", p.tokens[p.pos], expr)
+	}
+	return result, nil
+}
+
+// tokenizeCalicoSelector splits a selector into operators, words and quoted strings (kept with a leading quote)
+func tokenizeCalicoSelector(expr string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case strings.HasPrefix(expr[i:], "&&"), strings.HasPrefix(expr[i:], "||"), strings.HasPrefix(expr[i:], "=="), strings.HasPrefix(expr[i:], "!="):
+			tokens = append(tokens, expr[i:i+2])
+			i += 2
+		case strings.ContainsRune("!(){},", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		case c == '\'' || c == '"':
+			end := strings.IndexByte(expr[i+1:], c)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string in selector %q", expr)
+			}
+			tokens = append(tokens, "'"+expr[i+1:i+1+end])
+			i += end + 2
+		default:
+			start := i
+			for i < len(expr) && !strings.ContainsRune(" \t\n!(){},=&|'\"", rune(expr[i])) {
+				i++
+			}
+			if start == i {
+				return nil, fmt.Errorf("unexpected %q in selector %q", string(c), expr)
+			}
+			tokens = append(tokens, expr[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// calicoSelectorParser is a recursive descent evaluator for Calico selectors
+type calicoSelectorParser struct {
+	tokens []string
+	pos    int
+	labels map[string]string
+}
+
+func (p *calicoSelectorParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *calicoSelectorParser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+func (p *calicoSelectorParser) expect(token string) error {
+	if got := p.next(); got != token {
+		return fmt.Errorf("expected %q, got %q", token, got)
+	}
+	return nil
+}
+
+func (p *calicoSelectorParser) parseOr() (bool, error) {
+	result, err := p.parseAnd()
+	if err != nil {
+		return false, err
+	}
+	for p.peek() == "||" {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return false, err
+		}
+		result = result || right
+	}
+	return result, nil
+}
+
+func (p *calicoSelectorParser) parseAnd() (bool, error) {
+	result, err := p.parseUnary()
+	if err != nil {
+		return false, err
+	}
+	for p.peek() == "&&" {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return false, err
+		}
+		result = result && right
+	}
+	return result, nil
+}
+
+func (p *calicoSelectorParser) parseUnary() (bool, error) {
+	if p.peek() == "!" {
+		p.next()
+		result, err := p.parseUnary()
+		return !result, err
+	}
+	return p.parsePrimary()
+}
+
+func (p *calicoSelectorParser) parsePrimary() (bool, error) {
+	token := p.next()
+	switch token {
+	case "(":
+		result, err := p.parseOr()
+		if err != nil {
+			return false, err
+		}
+		return result, p.expect(")")
+	case "all", "global":
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+		return true, p.expect(")")
+	case "has":
+		if err := p.expect("("); err != nil {
+			return false, err
+		}
+		key := p.next()
+		_, ok := p.labels[key]
+		return ok, p.expect(")")
+	case "", ")", "{", "}", ",", "&&", "||", "==", "!=":
+		return false, fmt.Errorf("unexpected %q", token)
+	}
+
+	value, present := p.labels[token]
+	switch op := p.next(); op {
+	case "==":
+		literal := p.literal()
+		return present && value == literal, nil
+	case "!=":
+		literal := p.literal()
+		return !present || value != literal, nil
+	case "in":
+		values, err := p.literalSet()
+		return present && values[value], err
+	case "not":
+		if err := p.expect("in"); err != nil {
+			return false, err
+		}
+		values, err := p.literalSet()
+		return !present || !values[value], err
+	case "contains":
+		literal := p.literal()
+		return present && strings.Contains(value, literal), nil
+	case "starts":
+		if err := p.expect("with"); err != nil {
+			return false, err
+		}
+		literal := p.literal()
+		return present && strings.HasPrefix(value, literal), nil
+	case "ends":
+		if err := p.expect("with"); err != nil {
+			return false, err
+		}
+		literal := p.literal()
+		return present && strings.HasSuffix(value, literal), nil
+	default:
+		return false, fmt.Errorf("unsupported operator %q after %q", op, token)
+	}
+}
+
+func (p *calicoSelectorParser) literal() string {
+	return strings.TrimPrefix(p.next(), "'")
+}
+
+func (p *calicoSelectorParser) literalSet() (map[string]bool, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	values := make(map[string]bool)
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unterminated set")
+		}
+		values[p.literal()] = true
+		if p.peek() == "," {
+			p.next()
+		}
+	}
+	p.next()
+	return values, nil
+}
+
+// calicoNetContains matches a Calico net, which may be a plain address without a prefix length
+func calicoNetContains(cidr, ip string) bool {
+	if !strings.Contains(cidr, "/") {
+		return net.ParseIP(cidr).Equal(net.ParseIP(ip))
+	}
+	return ipBlockContains(&networkingv1.IPBlock{CIDR: cidr}, ip)
+}
@@ -0,0 +1,601 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+var peerAuthenticationGVR = schema.GroupVersionResource{Group: "security.istio.io", Version: "v1beta1", Resource: "peerauthentications"}
+
+// ConformanceResult is the outcome of one capability check
+type ConformanceResult struct {
+	Capability  string   `json:"capability"`
+	Description string   `json:"description"`
+	Status      string   `json:"status"` // passed, failed or skipped
+	Duration    string   `json:"duration,omitempty"`
+	Observed    string   `json:"observed,omitempty"` // last observed behaviour
+	Details     []string `json:"details,omitempty"`
+}
+
+// ConformanceReport is the result of a mesh conformance run
+type ConformanceReport struct {
+	Passed    bool                `json:"passed"`
+	Namespace string              `json:"namespace"`
+	Summary   map[string]int      `json:"summary"`
+	Results   []ConformanceResult `json:"results"`
+	Issues    []string            `json:"issues,omitempty"`
+	Notes     []string            `json:"notes,omitempty"`
+	Duration  string              `json:"duration"`
+	Timestamp time.Time           `json:"timestamp"`
+}
+
+// conformanceResponse is what the sleep client saw for one request
+type conformanceResponse struct {
+	code    string
+	headers string
+	elapsed time.Duration
+}
+
+// conformanceScenario exercises one mesh capability: its resources are applied, then probe is
+// polled until it observes the expected behaviour or the propagation timeout passes
+type conformanceScenario struct {
+	name        string
+	description string
+	resources   func(namespace string) []*unstructured.Unstructured
+	probe       func(ctx context.Context, env *conformanceEnv) (bool, string, error)
+}
+
+// conformanceEnv is the sandbox the scenarios run in
+type conformanceEnv struct {
+	m         *Manager
+	namespace string
+	host      string
+	client    *corev1.Pod // meshed sleep pod
+	legacy    *corev1.Pod // sleep pod without a sidecar
+}
+
+// conformanceScenarios lists the capabilities checked, in the order they run
+var conformanceScenarios = []conformanceScenario{
+	{
+		name:        "baseline_http",
+		description: "Plain HTTP request between two meshed workloads",
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			resp, err := env.request(ctx, env.client, "/status/200", nil)
+			if err != nil {
+				return false, "", err
+			}
+			return resp.code == "200", "HTTP " + resp.code, nil
+		},
+	},
+	{
+		name:        "header_routing",
+		description: "VirtualService header match selects a route that sets a response header",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{conformanceVirtualService(namespace, []interface{}{
+				map[string]interface{}{
+					"match": []interface{}{map[string]interface{}{
+						"headers": map[string]interface{}{"x-conformance": map[string]interface{}{"exact": "route"}},
+					}},
+					"route":   conformanceRoute(),
+					"headers": map[string]interface{}{"response": map[string]interface{}{"set": map[string]interface{}{"x-conformance-route": "matched"}}},
+				},
+				map[string]interface{}{"route": conformanceRoute()},
+			})}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			matched, err := env.request(ctx, env.client, "/headers", map[string]string{"x-conformance": "route"})
+			if err != nil {
+				return false, "", err
+			}
+			unmatched, err := env.request(ctx, env.client, "/headers", nil)
+			if err != nil {
+				return false, "", err
+			}
+			hasMatched := strings.Contains(strings.ToLower(matched.headers), "x-conformance-route: matched")
+			hasUnmatched := strings.Contains(strings.ToLower(unmatched.headers), "x-conformance-route")
+			return hasMatched && !hasUnmatched, fmt.Sprintf("matching request routed by header rule: %t, other request routed by it: %t", hasMatched, hasUnmatched), nil
+		},
+	},
+	{
+		name:        "fault_abort",
+		description: "Fault injection aborts every request with HTTP 418",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{conformanceVirtualService(namespace, []interface{}{
+				map[string]interface{}{
+					"fault": map[string]interface{}{"abort": map[string]interface{}{
+						"httpStatus": int64(418),
+						"percentage": map[string]interface{}{"value": float64(100)},
+					}},
+					"route": conformanceRoute(),
+				},
+			})}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			resp, err := env.request(ctx, env.client, "/status/200", nil)
+			if err != nil {
+				return false, "", err
+			}
+			return resp.code == "418", "HTTP " + resp.code, nil
+		},
+	},
+	{
+		name:        "fault_delay",
+		description: "Fault injection delays every request by 3s",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{conformanceVirtualService(namespace, []interface{}{
+				map[string]interface{}{
+					"fault": map[string]interface{}{"delay": map[string]interface{}{
+						"fixedDelay": "3s",
+						"percentage": map[string]interface{}{"value": float64(100)},
+					}},
+					"route": conformanceRoute(),
+				},
+			})}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			resp, err := env.request(ctx, env.client, "/status/200", nil)
+			if err != nil {
+				return false, "", err
+			}
+			return resp.code == "200" && resp.elapsed >= 3*time.Second, fmt.Sprintf("HTTP %s after %s", resp.code, resp.elapsed.Round(time.Millisecond)), nil
+		},
+	},
+	{
+		name:        "request_timeout",
+		description: "A 1s route timeout cuts off a 3s upstream response with HTTP 504",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{conformanceVirtualService(namespace, []interface{}{
+				map[string]interface{}{
+					"timeout": "1s",
+					"route":   conformanceRoute(),
+				},
+			})}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			resp, err := env.request(ctx, env.client, "/delay/3", nil)
+			if err != nil {
+				return false, "", err
+			}
+			return resp.code == "504", fmt.Sprintf("HTTP %s after %s", resp.code, resp.elapsed.Round(time.Millisecond)), nil
+		},
+	},
+	{
+		name:        "retries",
+		description: "A retry policy on 5xx retries a failing request 3 times",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{conformanceVirtualService(namespace, []interface{}{
+				map[string]interface{}{
+					"retries": map[string]interface{}{
+						"attempts":      int64(3),
+						"perTryTimeout": "2s",
+						"retryOn":       "5xx",
+					},
+					"route": conformanceRoute(),
+				},
+			})}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			before, err := env.retryCount(ctx)
+			if err != nil {
+				return false, "", err
+			}
+			resp, err := env.request(ctx, env.client, "/status/503", nil)
+			if err != nil {
+				return false, "", err
+			}
+			after, err := env.retryCount(ctx)
+			if err != nil {
+				return false, "", err
+			}
+			// Istio's default policy doesn't retry 503 responses, so only the configured policy adds 3 retries
+			return resp.code == "503" && after-before >= 3, fmt.Sprintf("HTTP %s with %d retries", resp.code, after-before), nil
+		},
+	},
+	{
+		name:        "mtls_strict",
+		description: "STRICT PeerAuthentication accepts mTLS from a meshed client and rejects a plaintext client",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{{Object: map[string]interface{}{
+				"apiVersion": "security.istio.io/v1beta1",
+				"kind":       "PeerAuthentication",
+				"metadata":   map[string]interface{}{"name": "conformance", "namespace": namespace},
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "httpbin"}},
+					"mtls":     map[string]interface{}{"mode": "STRICT"},
+				},
+			}}}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			meshed, err := env.request(ctx, env.client, "/status/200", nil)
+			if err != nil {
+				return false, "", err
+			}
+			plaintext, err := env.request(ctx, env.legacy, "/status/200", nil)
+			if err != nil {
+				return false, "", err
+			}
+			return meshed.code == "200" && plaintext.code == "000", fmt.Sprintf("meshed client got HTTP %s, plaintext client got HTTP %s", meshed.code, plaintext.code), nil
+		},
+	},
+	{
+		name:        "authorization_deny",
+		description: "A DENY AuthorizationPolicy rejects one path with HTTP 403 and leaves others alone",
+		resources: func(namespace string) []*unstructured.Unstructured {
+			return []*unstructured.Unstructured{{Object: map[string]interface{}{
+				"apiVersion": "security.istio.io/v1beta1",
+				"kind":       "AuthorizationPolicy",
+				"metadata":   map[string]interface{}{"name": "conformance", "namespace": namespace},
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "httpbin"}},
+					"action":   "DENY",
+					"rules": []interface{}{map[string]interface{}{
+						"to": []interface{}{map[string]interface{}{
+							"operation": map[string]interface{}{"paths": []interface{}{"/deny"}},
+						}},
+					}},
+				},
+			}}}
+		},
+		probe: func(ctx context.Context, env *conformanceEnv) (bool, string, error) {
+			denied, err := env.request(ctx, env.client, "/deny", nil)
+			if err != nil {
+				return false, "", err
+			}
+			allowed, err := env.request(ctx, env.client, "/status/200", nil)
+			if err != nil {
+				return false, "", err
+			}
+			return denied.code == "403" && allowed.code == "200", fmt.Sprintf("/deny got HTTP %s, /status/200 got HTTP %s", denied.code, allowed.code), nil
+		},
+	},
+}
+
+// runMeshConformanceParams are the arguments of run_mesh_conformance
+type runMeshConformanceParams struct {
+	Namespace string   `json:"namespace,omitempty"` // sandbox namespace, default: meshpilot-conformance
+	Scenarios []string `json:"scenarios,omitempty"` // capabilities to check, default: all
+	Keep      bool     `json:"keep,omitempty"`      // leave the sandbox in place for inspection
+	Timeout   string   `json:"timeout,omitempty"`   // wait for the test apps, default: 5m
+}
+
+// RunMeshConformance runs routing, fault injection, timeout, retry, mTLS and authorization scenarios
+// against disposable sample apps in a sandbox namespace and reports pass/fail per capability
+func (m *Manager) RunMeshConformance(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params runMeshConformanceParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "meshpilot-conformance"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	scenarios := conformanceScenarios
+	if len(params.Scenarios) > 0 {
+		known := make([]string, 0, len(conformanceScenarios))
+		for _, scenario := range conformanceScenarios {
+			known = append(known, scenario.name)
+		}
+		scenarios = nil
+		for _, name := range params.Scenarios {
+			if !containsString(known, name) {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Unknown scenario %q; valid scenarios are: %s", name, strings.Join(known, ", ")),
+						},
+					},
+				}, nil
+			}
+		}
+		for _, scenario := range conformanceScenarios {
+			if containsString(params.Scenarios, scenario.name) {
+				scenarios = append(scenarios, scenario)
+			}
+		}
+	}
+
+	ctx = withManagingTool(ctx, "run_mesh_conformance")
+	if !m.istiodInstalled(ctx) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Istio is not installed in istio-system; install it with install_istio first",
+				},
+			},
+		}, nil
+	}
+
+	started := time.Now()
+	report := &ConformanceReport{
+		Namespace: params.Namespace,
+		Summary:   make(map[string]int),
+		Results:   []ConformanceResult{},
+		Timestamp: started,
+	}
+	env := &conformanceEnv{
+		m:         m,
+		namespace: params.Namespace,
+		host:      fmt.Sprintf("httpbin.%s.svc.cluster.local:8000", params.Namespace),
+	}
+
+	// Only namespaces this run created are deleted afterwards
+	legacyNamespace := params.Namespace + "-legacy"
+	var created []string
+	setupErr := func() error {
+		namespaces := []string{params.Namespace, legacyNamespace}
+		for _, ns := range namespaces {
+			if _, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, ns, metav1.GetOptions{}); errors.IsNotFound(err) {
+				created = append(created, ns)
+			}
+		}
+		if err := m.createOrUpdateNamespace(ctx, params.Namespace, true); err != nil {
+			return fmt.Errorf("failed to create namespace %s: %w", params.Namespace, err)
+		}
+		if err := m.createOrUpdateNamespace(ctx, legacyNamespace, false); err != nil {
+			return fmt.Errorf("failed to create namespace %s: %w", legacyNamespace, err)
+		}
+		for _, ns := range namespaces {
+			if err := m.createSleepServiceAccount(ctx, ns); err != nil {
+				return err
+			}
+			if err := m.createSleepDeployment(ctx, ns, 1, sleepImage); err != nil {
+				return err
+			}
+		}
+		if err := m.createHttpbinServiceAccount(ctx, params.Namespace); err != nil {
+			return err
+		}
+		if err := m.createHttpbinDeployment(ctx, params.Namespace, 1, httpbinImage); err != nil {
+			return err
+		}
+		if err := m.createHttpbinService(ctx, params.Namespace); err != nil {
+			return err
+		}
+		if err := m.waitForPodsReady(ctx, params.Namespace, []string{"app=sleep", "app=httpbin"}, timeout); err != nil {
+			return fmt.Errorf("test apps in %s did not become ready: %w", params.Namespace, err)
+		}
+		if err := m.waitForPodsReady(ctx, legacyNamespace, []string{"app=sleep"}, timeout); err != nil {
+			return fmt.Errorf("plaintext client in %s did not become ready: %w", legacyNamespace, err)
+		}
+
+		var err error
+		if env.client, err = m.findWaypointSource(ctx, params.Namespace, ""); err != nil {
+			return err
+		}
+		if !podHasSidecar(env.client) {
+			return fmt.Errorf("sleep pod %s/%s has no sidecar; check that injection works in %s", params.Namespace, env.client.Name, params.Namespace)
+		}
+		env.legacy, err = m.findWaypointSource(ctx, legacyNamespace, "")
+		return err
+	}()
+
+	for _, scenario := range scenarios {
+		if setupErr != nil {
+			report.add(ConformanceResult{Capability: scenario.name, Description: scenario.description, Status: "skipped", Details: []string{"sandbox setup failed"}})
+			continue
+		}
+		report.add(env.run(ctx, scenario))
+	}
+	if setupErr != nil {
+		report.Issues = append(report.Issues, fmt.Sprintf("Sandbox setup failed: %v", setupErr))
+	}
+
+	if params.Keep {
+		report.Notes = append(report.Notes, fmt.Sprintf("keep is set; the test apps in %s and %s were left in place", params.Namespace, legacyNamespace))
+	} else {
+		for _, ns := range created {
+			if err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Delete(ctx, ns, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+				report.Issues = append(report.Issues, fmt.Sprintf("Failed to delete namespace %s: %v", ns, err))
+			}
+		}
+		for _, ns := range []string{params.Namespace, legacyNamespace} {
+			if !containsString(created, ns) {
+				report.Notes = append(report.Notes, fmt.Sprintf("Namespace %s existed before the run, so it and the test apps in it were left in place", ns))
+			}
+		}
+	}
+
+	report.Passed = len(report.Issues) == 0
+	report.Duration = time.Since(started).Round(time.Second).String()
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+func (r *ConformanceReport) add(result ConformanceResult) {
+	r.Results = append(r.Results, result)
+	r.Summary[result.Status]++
+	if result.Status == "failed" {
+		r.Issues = append(r.Issues, fmt.Sprintf("%s: %s", result.Capability, strings.Join(result.Details, "; ")))
+	}
+}
+
+// run applies a scenario's resources, polls its probe and removes the resources again
+func (env *conformanceEnv) run(ctx context.Context, scenario conformanceScenario) ConformanceResult {
+	result := ConformanceResult{Capability: scenario.name, Description: scenario.description}
+	start := time.Now()
+
+	var resources []*unstructured.Unstructured
+	if scenario.resources != nil {
+		resources = scenario.resources(env.namespace)
+	}
+	defer func() {
+		for _, obj := range resources {
+			gvr := istioResourceGVR(obj)
+			err := env.m.k8sClient.Dynamic.Resource(gvr).Namespace(env.namespace).Delete(context.WithoutCancel(ctx), obj.GetName(), metav1.DeleteOptions{})
+			if err != nil && !errors.IsNotFound(err) {
+				result.Details = append(result.Details, fmt.Sprintf("failed to remove %s %s: %v", obj.GetKind(), obj.GetName(), err))
+			}
+		}
+	}()
+
+	for _, obj := range resources {
+		markManaged(ctx, obj)
+		if _, err := env.m.k8sClient.Dynamic.Resource(istioResourceGVR(obj)).Namespace(env.namespace).Create(ctx, obj, metav1.CreateOptions{}); err != nil {
+			result.Status = "failed"
+			result.Details = append(result.Details, fmt.Sprintf("failed to create %s %s: %v", obj.GetKind(), obj.GetName(), err))
+			return result
+		}
+	}
+
+	var probeErr error
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, configPropagationTimeout, true, func(ctx context.Context) (bool, error) {
+		passed, observed, err := scenario.probe(ctx, env)
+		if err != nil {
+			probeErr = err
+			return false, nil
+		}
+		result.Observed = observed
+		return passed, nil
+	})
+	result.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	switch {
+	case err == nil:
+		result.Status = "passed"
+	case probeErr != nil && result.Observed == "":
+		result.Status = "failed"
+		result.Details = append(result.Details, fmt.Sprintf("probe failed: %v", probeErr))
+	default:
+		result.Status = "failed"
+		result.Details = append(result.Details, fmt.Sprintf("expected behaviour not observed within %s", configPropagationTimeout))
+	}
+	return result
+}
+
+// request sends a GET to httpbin from a client pod
+func (env *conformanceEnv) request(ctx context.Context, client *corev1.Pod, path string, headers map[string]string) (*conformanceResponse, error) {
+	start := time.Now()
+	code, responseHeaders, err := env.m.curlFromPod(ctx, client, "http://"+env.host+path, headers, 10)
+	if err != nil {
+		return nil, err
+	}
+	return &conformanceResponse{code: code, headers: responseHeaders, elapsed: time.Since(start)}, nil
+}
+
+// curlFromPod sends a GET from the sleep container of a pod and returns the status code and response
+// headers; a request that gets no response is reported as code 000
+func (m *Manager) curlFromPod(ctx context.Context, client *corev1.Pod, url string, headers map[string]string, timeoutSeconds int) (string, string, error) {
+	command := []string{"curl", "-s", "-o", "/dev/null", "-D", "-", "-w", "\\nHTTP_CODE:%{http_code}\\n", "--max-time", strconv.Itoa(timeoutSeconds)}
+	for name, value := range headers {
+		command = append(command, "-H", fmt.Sprintf("%s: %s", name, value))
+	}
+	command = append(command, url)
+
+	output, err := m.execCommandInPod(ctx, client.Namespace, client.Name, "sleep", command)
+	idx := strings.LastIndex(output, "HTTP_CODE:")
+	if idx < 0 {
+		if err != nil {
+			return "", "", fmt.Errorf("failed to run curl in %s/%s: %w", client.Namespace, client.Name, err)
+		}
+		return "000", "", nil
+	}
+	return strings.TrimSpace(output[idx+len("HTTP_CODE:"):]), output[:idx], nil
+}
+
+// retryCount reads the client sidecar's retry counter for the httpbin cluster
+func (env *conformanceEnv) retryCount(ctx context.Context) (int, error) {
+	body, err := env.m.portForwardGet(ctx, env.client.Namespace, env.client.Name, 15000, "/stats?filter=upstream_rq_retry$")
+	if err != nil {
+		return 0, err
+	}
+	host := strings.Split(env.host, ":")[0]
+	total := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ": ")
+		if !found || !strings.HasPrefix(name, "cluster.outbound|") || !strings.Contains(name, "|"+host+".") {
+			continue
+		}
+		if count, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			total += count
+		}
+	}
+	return total, nil
+}
+
+// conformanceVirtualService builds the httpbin VirtualService a scenario routes through
+func conformanceVirtualService(namespace string, http []interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "networking.istio.io/v1beta1",
+		"kind":       "VirtualService",
+		"metadata":   map[string]interface{}{"name": "conformance", "namespace": namespace},
+		"spec": map[string]interface{}{
+			"hosts": []interface{}{"httpbin"},
+			"http":  http,
+		},
+	}}
+}
+
+func conformanceRoute() []interface{} {
+	return []interface{}{map[string]interface{}{
+		"destination": map[string]interface{}{"host": "httpbin", "port": map[string]interface{}{"number": int64(8000)}},
+	}}
+}
+
+// istioResourceGVR maps an Istio resource built as unstructured to its API resource
+func istioResourceGVR(obj *unstructured.Unstructured) schema.GroupVersionResource {
+	switch obj.GetKind() {
+	case "PeerAuthentication":
+		return peerAuthenticationGVR
+	case "AuthorizationPolicy":
+		return authorizationPolicyGVRs[1]
+	case "DestinationRule":
+		return destinationRuleGVR
+	case "ServiceEntry":
+		return serviceEntryGVR
+	case "Gateway":
+		return istioGatewayGVR
+	default:
+		return virtualServiceGVR
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"net/http"
 	"strings"
 	"time"
 
@@ -27,6 +28,12 @@ type ConnectivityTestResult struct {
 	Timestamp   time.Time `json:"timestamp"`
 }
 
+// ConnectivityReport is the result of a connectivity test run, with one result per request
+type ConnectivityReport struct {
+	Summary string                   `json:"summary"`
+	Results []ConnectivityTestResult `json:"results"`
+}
+
 // PodInfo represents information about a pod
 type PodInfo struct {
 	Name      string `json:"name"`
@@ -35,18 +42,21 @@ type PodInfo struct {
 	Node      string `json:"node,omitempty"`
 }
 
+// testConnectivityParams are the arguments of test_connectivity
+type testConnectivityParams struct {
+	SourcePod       string `json:"source_pod"`
+	SourceNamespace string `json:"source_namespace,omitempty"`
+	TargetService   string `json:"target_service"`
+	TargetPort      int    `json:"target_port"`        // Required in schema
+	Protocol        string `json:"protocol,omitempty"` // http, https, tcp
+	Path            string `json:"path,omitempty"`     // for HTTP requests
+	Timeout         int    `json:"timeout,omitempty"`  // seconds
+	Method          string `json:"method,omitempty"`   // GET, POST, etc.
+}
+
 // TestConnectivity tests connectivity between two pods
-func (m *Manager) TestConnectivity(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		SourcePod       string `json:"source_pod"`
-		SourceNamespace string `json:"source_namespace,omitempty"`
-		TargetService   string `json:"target_service"`
-		TargetPort      int    `json:"target_port"`        // Required in schema
-		Protocol        string `json:"protocol,omitempty"` // http, https, tcp
-		Path            string `json:"path,omitempty"`     // for HTTP requests
-		Timeout         int    `json:"timeout,omitempty"`  // seconds
-		Method          string `json:"method,omitempty"`   // GET, POST, etc.
-	}
+func (m *Manager) TestConnectivity(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params testConnectivityParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -114,8 +124,6 @@ func (m *Manager) TestConnectivity(args json.RawMessage) (*CallToolResult, error
 		params.Method = "GET"
 	}
 
-	ctx := context.Background()
-
 	// Get source pod info
 	sourcePod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.SourceNamespace).Get(ctx, params.SourcePod, metav1.GetOptions{})
 	if err != nil {
@@ -206,9 +214,9 @@ func (m *Manager) TestConnectivity(args json.RawMessage) (*CallToolResult, error
 		result.Destination.Name,
 		status)
 
-	resultData := map[string]interface{}{
-		"summary": summary,
-		"results": []ConnectivityTestResult{result},
+	resultData := &ConnectivityReport{
+		Summary: summary,
+		Results: []ConnectivityTestResult{result},
 	}
 
 	resultJSON, _ := json.MarshalIndent(resultData, "", "  ")
@@ -222,14 +230,17 @@ func (m *Manager) TestConnectivity(args json.RawMessage) (*CallToolResult, error
 	}, nil
 }
 
+// testSleepToHttpbinParams are the arguments of test_sleep_to_httpbin
+type testSleepToHttpbinParams struct {
+	SourceNamespace string   `json:"source_namespace,omitempty"`
+	TargetNamespace string   `json:"target_namespace,omitempty"`
+	TestEndpoints   []string `json:"test_endpoints,omitempty"` // endpoints to test
+	Timeout         int      `json:"timeout,omitempty"`
+}
+
 // TestSleepToHttpbin tests connectivity from sleep pod to httpbin service
-func (m *Manager) TestSleepToHttpbin(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		SourceNamespace string   `json:"source_namespace,omitempty"`
-		TargetNamespace string   `json:"target_namespace,omitempty"`
-		TestEndpoints   []string `json:"test_endpoints,omitempty"` // endpoints to test
-		Timeout         int      `json:"timeout,omitempty"`
-	}
+func (m *Manager) TestSleepToHttpbin(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params testSleepToHttpbinParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -257,8 +268,6 @@ func (m *Manager) TestSleepToHttpbin(args json.RawMessage) (*CallToolResult, err
 		params.TestEndpoints = []string{"/get", "/headers", "/status/200", "/delay/1"}
 	}
 
-	ctx := context.Background()
-
 	// Find sleep pod
 	sleepPods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.SourceNamespace).List(ctx, metav1.ListOptions{
 		LabelSelector: "app=sleep",
@@ -366,9 +375,9 @@ func (m *Manager) TestSleepToHttpbin(args json.RawMessage) (*CallToolResult, err
 
 	summary := fmt.Sprintf("Sleep to Httpbin connectivity test completed: %d/%d tests successful", successful, len(results))
 
-	output := map[string]interface{}{
-		"summary": summary,
-		"results": results,
+	output := &ConnectivityReport{
+		Summary: summary,
+		Results: results,
 	}
 
 	resultJSON, _ := json.MarshalIndent(output, "", "  ")
@@ -421,3 +430,182 @@ func (m *Manager) execCommandInPod(ctx context.Context, namespace, podName, cont
 
 	return stdout.String(), nil
 }
+
+// IngressTestResult represents the result of a request sent through the ingress gateway
+type IngressTestResult struct {
+	Gateway    string    `json:"gateway"`
+	Address    string    `json:"address"`
+	Via        string    `json:"via"` // loadbalancer or nodeport
+	URL        string    `json:"url"`
+	Host       string    `json:"host,omitempty"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Duration   string    `json:"duration,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// IngressTestReport is the result of an ingress connectivity test
+type IngressTestReport struct {
+	Summary string              `json:"summary"`
+	Results []IngressTestResult `json:"results"`
+}
+
+// testIngressConnectivityParams are the arguments of test_ingress_connectivity
+type testIngressConnectivityParams struct {
+	GatewayNamespace string `json:"gateway_namespace,omitempty"` // default: istio-ingress
+	GatewayService   string `json:"gateway_service,omitempty"`   // default: istio-ingress
+	Host             string `json:"host,omitempty"`              // Host header for the request
+	Path             string `json:"path,omitempty"`              // default: /
+	Port             int    `json:"port,omitempty"`              // gateway service port, default: 80
+	Timeout          int    `json:"timeout,omitempty"`           // seconds
+}
+
+// TestIngressConnectivity sends a request from outside the cluster through the ingress gateway
+func (m *Manager) TestIngressConnectivity(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params testIngressConnectivityParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+	if params.GatewayService == "" {
+		params.GatewayService = "istio-ingress"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.Port == 0 {
+		params.Port = 80
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 10
+	}
+
+	address, port, via, err := m.resolveGatewayAddress(ctx, params.GatewayNamespace, params.GatewayService, params.Port)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to resolve ingress gateway address: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := IngressTestResult{
+		Gateway:   fmt.Sprintf("%s/%s", params.GatewayNamespace, params.GatewayService),
+		Address:   fmt.Sprintf("%s:%d", address, port),
+		Via:       via,
+		URL:       fmt.Sprintf("http://%s:%d%s", address, port, params.Path),
+		Host:      params.Host,
+		Timestamp: time.Now(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, result.URL, nil)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid request: %v", err),
+				},
+			},
+		}, nil
+	}
+	if params.Host != "" {
+		req.Host = params.Host
+	}
+
+	client := &http.Client{Timeout: time.Duration(params.Timeout) * time.Second}
+	resp, err := client.Do(req)
+	result.Duration = time.Since(result.Timestamp).String()
+	if err != nil {
+		result.Error = err.Error()
+	} else {
+		resp.Body.Close()
+		result.StatusCode = resp.StatusCode
+		result.Success = resp.StatusCode >= 200 && resp.StatusCode < 400
+	}
+
+	status := "FAILED"
+	if result.Success {
+		status = "SUCCESS"
+	}
+	resultData := &IngressTestReport{
+		Summary: fmt.Sprintf("Ingress test to %s via %s: %s", result.Gateway, result.Via, status),
+		Results: []IngressTestResult{result},
+	}
+
+	resultJSON, _ := json.MarshalIndent(resultData, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// resolveGatewayAddress returns the externally reachable address of a gateway service port,
+// preferring a LoadBalancer IP (such as one assigned by MetalLB) over a node port
+func (m *Manager) resolveGatewayAddress(ctx context.Context, namespace, name string, servicePort int) (string, int, string, error) {
+	service, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to get gateway service: %w", err)
+	}
+
+	var port *corev1.ServicePort
+	for i := range service.Spec.Ports {
+		if int(service.Spec.Ports[i].Port) == servicePort {
+			port = &service.Spec.Ports[i]
+			break
+		}
+	}
+	if port == nil {
+		return "", 0, "", fmt.Errorf("service %s/%s has no port %d", namespace, name, servicePort)
+	}
+
+	for _, ingress := range service.Status.LoadBalancer.Ingress {
+		if ingress.IP != "" {
+			return ingress.IP, servicePort, "loadbalancer", nil
+		}
+		if ingress.Hostname != "" {
+			return ingress.Hostname, servicePort, "loadbalancer", nil
+		}
+	}
+
+	// Fall back to the node port on the first node's internal address
+	if port.NodePort == 0 {
+		return "", 0, "", fmt.Errorf("service %s/%s has no external IP and no node port; install a load balancer such as MetalLB (install_metallb)", namespace, name)
+	}
+	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to list nodes: %w", err)
+	}
+	for _, node := range nodes.Items {
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				logrus.Infof("Gateway %s/%s has no external IP, using node port %d", namespace, name, port.NodePort)
+				return address.Address, int(port.NodePort), "nodeport", nil
+			}
+		}
+	}
+	return "", 0, "", fmt.Errorf("no node address found for node port %d", port.NodePort)
+}
@@ -0,0 +1,417 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apinetworkingv1beta1 "istio.io/api/networking/v1beta1"
+	networkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// connectionPoolStatsFilter selects the outbound cluster counters and gauges that show pool exhaustion
+const connectionPoolStatsFilter = `^cluster\.outbound\|.*\.(upstream_cx_overflow|upstream_rq_pending_overflow|upstream_rq_retry_overflow|upstream_cx_active|upstream_rq_active|upstream_rq_pending_active|upstream_rq_total)$`
+
+// connectionPoolNearLimit is the share of a limit the busiest client proxy may use before it is reported
+const connectionPoolNearLimit = 0.8
+
+// ConnectionPoolLimits is the effective DestinationRule connectionPool for a cluster; zero means unset
+// (Istio then leaves the Envoy circuit breakers effectively unlimited)
+type ConnectionPoolLimits struct {
+	MaxConnections           int32 `json:"max_connections,omitempty"`
+	HTTP1MaxPendingRequests  int32 `json:"http1_max_pending_requests,omitempty"`
+	HTTP2MaxRequests         int32 `json:"http2_max_requests,omitempty"`
+	MaxRequestsPerConnection int32 `json:"max_requests_per_connection,omitempty"`
+	MaxRetries               int32 `json:"max_retries,omitempty"`
+}
+
+// ConnectionPoolSuggestion is a concrete change to one connectionPool setting
+type ConnectionPoolSuggestion struct {
+	Setting   string `json:"setting"` // path under trafficPolicy.connectionPool
+	Current   int32  `json:"current,omitempty"`
+	Suggested int32  `json:"suggested"`
+	Reason    string `json:"reason"`
+}
+
+// ConnectionPoolCluster is the pool usage of one outbound cluster summed over the client proxies
+type ConnectionPoolCluster struct {
+	Cluster           string                     `json:"cluster"`
+	Host              string                     `json:"host"`
+	Port              int                        `json:"port"`
+	Subset            string                     `json:"subset,omitempty"`
+	CxOverflow        int                        `json:"upstream_cx_overflow"`
+	PendingOverflow   int                        `json:"upstream_rq_pending_overflow"`
+	RetryOverflow     int                        `json:"upstream_rq_retry_overflow"`
+	RequestsTotal     int                        `json:"upstream_rq_total"`
+	PeakCxActive      int                        `json:"peak_cx_active"` // highest gauge of a single client proxy
+	PeakRqActive      int                        `json:"peak_rq_active"`
+	PeakPendingActive int                        `json:"peak_rq_pending_active"`
+	OverflowingPods   []string                   `json:"overflowing_pods,omitempty"`
+	DestinationRule   string                     `json:"destination_rule,omitempty"`
+	Limits            ConnectionPoolLimits       `json:"limits"`
+	Findings          []string                   `json:"findings"`
+	Suggestions       []ConnectionPoolSuggestion `json:"suggestions,omitempty"`
+}
+
+// ConnectionPoolReport lists the outbound clusters whose connection pools overflowed or are close to their limits
+type ConnectionPoolReport struct {
+	Namespace       string                  `json:"namespace"`
+	Selector        string                  `json:"selector,omitempty"`
+	ProxiesScanned  int                     `json:"proxies_scanned"`
+	ClustersChecked int                     `json:"clusters_checked"`
+	Exhausted       []ConnectionPoolCluster `json:"exhausted"`
+	Notes           []string                `json:"notes,omitempty"`
+	Timestamp       time.Time               `json:"timestamp"`
+}
+
+// detectConnectionPoolExhaustionParams are the arguments of detect_connection_pool_exhaustion
+type detectConnectionPoolExhaustionParams struct {
+	Namespace string `json:"namespace,omitempty"` // namespace of the client workloads, default: default
+	Selector  string `json:"selector,omitempty"`  // label selector for the client pods
+}
+
+// DetectConnectionPoolExhaustion reads the outbound cluster stats of a namespace's sidecars, finds the clusters
+// that overflowed their connection pool or circuit breaker limits and suggests connectionPool changes
+func (m *Manager) DetectConnectionPoolExhaustion(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params detectConnectionPoolExhaustionParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	pods, err := m.runningPods(ctx, params.Namespace, params.Selector)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := ConnectionPoolReport{
+		Namespace: params.Namespace,
+		Selector:  params.Selector,
+		Exhausted: []ConnectionPoolCluster{},
+		Timestamp: time.Now(),
+	}
+
+	// Circuit breakers are enforced by each client proxy, so stats are kept per pod and summed per cluster
+	clusters := make(map[string]*ConnectionPoolCluster)
+	for i := range pods {
+		pod := &pods[i]
+		if !podHasSidecar(pod) {
+			continue
+		}
+		body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/stats?filter="+url.QueryEscape(connectionPoolStatsFilter))
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to read Envoy stats of %s: %v", pod.Name, err))
+			continue
+		}
+		result.ProxiesScanned++
+		if !addConnectionPoolStats(clusters, pod.Name, string(body)) {
+			result.Notes = append(result.Notes, fmt.Sprintf("%s exposes no outbound cluster stats; it has sent no traffic yet, or its proxyStatsMatcher excludes them (add the inclusion regexp .*(overflow|_active|upstream_rq_total)$ with tune_proxy)", pod.Name))
+		}
+	}
+	result.ClustersChecked = len(clusters)
+
+	var rules []*networkingv1beta1.DestinationRule
+	if list, err := m.k8sClient.Istio.NetworkingV1beta1().DestinationRules(metav1.NamespaceAll).List(ctx, metav1.ListOptions{}); err == nil {
+		rules = list.Items
+	} else {
+		result.Notes = append(result.Notes, fmt.Sprintf("Failed to list DestinationRules, limits are not correlated: %v", err))
+	}
+
+	for _, cluster := range clusters {
+		if rule := connectionPoolRule(rules, params.Namespace, cluster.Host); rule != nil {
+			cluster.DestinationRule = fmt.Sprintf("%s/%s", rule.Namespace, rule.Name)
+			cluster.Limits = effectiveConnectionPool(&rule.Spec, cluster.Subset, cluster.Port)
+		}
+		analyzeConnectionPool(cluster)
+		if len(cluster.Findings) > 0 {
+			sort.Strings(cluster.OverflowingPods)
+			result.Exhausted = append(result.Exhausted, *cluster)
+		}
+	}
+	sort.Slice(result.Exhausted, func(i, j int) bool {
+		a, b := result.Exhausted[i], result.Exhausted[j]
+		if overflowA, overflowB := a.CxOverflow+a.PendingOverflow+a.RetryOverflow, b.CxOverflow+b.PendingOverflow+b.RetryOverflow; overflowA != overflowB {
+			return overflowA > overflowB
+		}
+		return a.Cluster < b.Cluster
+	})
+
+	switch {
+	case result.ProxiesScanned == 0:
+		result.Notes = append(result.Notes, fmt.Sprintf("No sidecar stats could be read in namespace %s; circuit breakers are enforced by client sidecars, so run this against the namespace of the calling workloads", params.Namespace))
+	case len(result.Exhausted) == 0:
+		result.Notes = append(result.Notes, fmt.Sprintf("None of the %d outbound clusters overflowed or came close to a connection pool limit since the proxies started", result.ClustersChecked))
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// addConnectionPoolStats adds one proxy's outbound cluster stats, reporting whether it had any
+func addConnectionPoolStats(clusters map[string]*ConnectionPoolCluster, pod, stats string) bool {
+	found := false
+	overflowed := make(map[string]bool)
+	scanner := bufio.NewScanner(strings.NewReader(stats))
+	for scanner.Scan() {
+		name, value, ok := strings.Cut(scanner.Text(), ": ")
+		if !ok || !strings.HasPrefix(name, "cluster.outbound|") {
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		// cluster.outbound|<port>|<subset>|<host>.<stat>
+		dot := strings.LastIndex(name, ".")
+		clusterName, stat := strings.TrimPrefix(name[:dot], "cluster."), name[dot+1:]
+		parts := strings.SplitN(clusterName, "|", 4)
+		if len(parts) != 4 {
+			continue
+		}
+		found = true
+
+		cluster := clusters[clusterName]
+		if cluster == nil {
+			port, _ := strconv.Atoi(parts[1])
+			cluster = &ConnectionPoolCluster{Cluster: clusterName, Port: port, Subset: parts[2], Host: parts[3], Findings: []string{}}
+			clusters[clusterName] = cluster
+		}
+		switch stat {
+		case "upstream_cx_overflow":
+			cluster.CxOverflow += count
+		case "upstream_rq_pending_overflow":
+			cluster.PendingOverflow += count
+		case "upstream_rq_retry_overflow":
+			cluster.RetryOverflow += count
+		case "upstream_rq_total":
+			cluster.RequestsTotal += count
+		case "upstream_cx_active":
+			cluster.PeakCxActive = max(cluster.PeakCxActive, count)
+		case "upstream_rq_active":
+			cluster.PeakRqActive = max(cluster.PeakRqActive, count)
+		case "upstream_rq_pending_active":
+			cluster.PeakPendingActive = max(cluster.PeakPendingActive, count)
+		}
+		if strings.HasSuffix(stat, "_overflow") && count > 0 && !overflowed[clusterName] {
+			overflowed[clusterName] = true
+			cluster.OverflowingPods = append(cluster.OverflowingPods, pod)
+		}
+	}
+	return found
+}
+
+// connectionPoolRule picks the DestinationRule Istio applies to a host for clients in a namespace: a rule in the
+// client namespace first, then the host's own namespace, then any other (such as the mesh root namespace),
+// preferring exact hosts over wildcards
+func connectionPoolRule(rules []*networkingv1beta1.DestinationRule, clientNamespace, host string) *networkingv1beta1.DestinationRule {
+	hostNamespace := ""
+	if parts := strings.Split(host, "."); len(parts) > 2 && strings.HasSuffix(host, ".svc.cluster.local") {
+		hostNamespace = parts[1]
+	}
+
+	var best *networkingv1beta1.DestinationRule
+	bestRank := 0
+	for _, rule := range rules {
+		qualified := qualifyHost(rule.Spec.Host, rule.Namespace)
+		if !hostMatches(qualified, host) {
+			continue
+		}
+		rank := 2
+		switch rule.Namespace {
+		case clientNamespace:
+			rank = 6
+		case hostNamespace:
+			rank = 4
+		}
+		if !strings.HasPrefix(qualified, "*") {
+			rank++
+		}
+		if rank > bestRank {
+			best, bestRank = rule, rank
+		}
+	}
+	return best
+}
+
+// effectiveConnectionPool resolves the connectionPool of a rule for a subset and port, with port-level settings
+// overriding the traffic policy and a subset's policy overriding the rule's
+func effectiveConnectionPool(rule *apinetworkingv1beta1.DestinationRule, subset string, port int) ConnectionPoolLimits {
+	pool := trafficPolicyConnectionPool(rule.TrafficPolicy, port)
+	for _, s := range rule.Subsets {
+		if s.Name == subset {
+			if override := trafficPolicyConnectionPool(s.TrafficPolicy, port); override != nil {
+				pool = override
+			}
+		}
+	}
+
+	var limits ConnectionPoolLimits
+	if pool == nil {
+		return limits
+	}
+	if tcp := pool.Tcp; tcp != nil {
+		limits.MaxConnections = tcp.MaxConnections
+	}
+	if http := pool.Http; http != nil {
+		limits.HTTP1MaxPendingRequests = http.Http1MaxPendingRequests
+		limits.HTTP2MaxRequests = http.Http2MaxRequests
+		limits.MaxRequestsPerConnection = http.MaxRequestsPerConnection
+		limits.MaxRetries = http.MaxRetries
+	}
+	return limits
+}
+
+// trafficPolicyConnectionPool returns the connectionPool of a traffic policy for a port, if it sets one
+func trafficPolicyConnectionPool(policy *apinetworkingv1beta1.TrafficPolicy, port int) *apinetworkingv1beta1.ConnectionPoolSettings {
+	if policy == nil {
+		return nil
+	}
+	pool := policy.ConnectionPool
+	for _, portPolicy := range policy.PortLevelSettings {
+		if portPolicy.Port != nil && int(portPolicy.Port.Number) == port && portPolicy.ConnectionPool != nil {
+			pool = portPolicy.ConnectionPool
+		}
+	}
+	return pool
+}
+
+// analyzeConnectionPool explains a cluster's overflows against its limits and suggests new limits
+func analyzeConnectionPool(cluster *ConnectionPoolCluster) {
+	limits := cluster.Limits
+	rule := cluster.DestinationRule
+	if rule == "" {
+		rule = "no DestinationRule"
+	}
+
+	if cluster.CxOverflow > 0 {
+		if limits.MaxConnections > 0 {
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d times a client proxy needed a connection beyond tcp.maxConnections %d (%s), so requests queued instead; the limit applies per client pod",
+				cluster.CxOverflow, limits.MaxConnections, rule))
+			cluster.Suggestions = append(cluster.Suggestions, ConnectionPoolSuggestion{
+				Setting:   "tcp.maxConnections",
+				Current:   limits.MaxConnections,
+				Suggested: raisedLimit(limits.MaxConnections, cluster.CxOverflow, cluster.RequestsTotal),
+				Reason:    fmt.Sprintf("%s overflowed the connection limit", overflowShare(cluster.CxOverflow, cluster.RequestsTotal)),
+			})
+		} else {
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d connections overflowed although %s sets no tcp.maxConnections for this cluster; a rule this analysis did not resolve (exportTo, or one in the mesh root namespace) may apply", cluster.CxOverflow, rule))
+		}
+	}
+
+	if cluster.PendingOverflow > 0 {
+		switch {
+		case limits.HTTP1MaxPendingRequests > 0:
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d requests failed with 503 (UO) because the pending request queue reached http.http1MaxPendingRequests %d (%s)",
+				cluster.PendingOverflow, limits.HTTP1MaxPendingRequests, rule))
+			cluster.Suggestions = append(cluster.Suggestions, ConnectionPoolSuggestion{
+				Setting:   "http.http1MaxPendingRequests",
+				Current:   limits.HTTP1MaxPendingRequests,
+				Suggested: raisedLimit(limits.HTTP1MaxPendingRequests, cluster.PendingOverflow, cluster.RequestsTotal),
+				Reason:    fmt.Sprintf("%s overflowed the pending queue", overflowShare(cluster.PendingOverflow, cluster.RequestsTotal)),
+			})
+			if limits.MaxConnections > 0 && cluster.CxOverflow > 0 {
+				cluster.Findings = append(cluster.Findings, "Requests queue because every allowed connection is busy; raising tcp.maxConnections drains the queue faster than raising the queue limit alone")
+			}
+		case limits.HTTP2MaxRequests > 0:
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d requests failed with 503 (UO) because a client proxy reached http.http2MaxRequests %d (%s)",
+				cluster.PendingOverflow, limits.HTTP2MaxRequests, rule))
+		default:
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d requests overflowed although %s sets no pending or concurrent request limit for this cluster; a rule this analysis did not resolve may apply", cluster.PendingOverflow, rule))
+		}
+		if limits.HTTP2MaxRequests > 0 {
+			cluster.Suggestions = append(cluster.Suggestions, ConnectionPoolSuggestion{
+				Setting:   "http.http2MaxRequests",
+				Current:   limits.HTTP2MaxRequests,
+				Suggested: raisedLimit(limits.HTTP2MaxRequests, cluster.PendingOverflow, cluster.RequestsTotal),
+				Reason:    "HTTP/2 requests beyond the concurrent request limit are rejected as pending overflows",
+			})
+		}
+	}
+
+	if cluster.RetryOverflow > 0 {
+		if limits.MaxRetries > 0 {
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d retries were skipped because concurrent retries reached http.maxRetries %d (%s); the original failures surface as URX",
+				cluster.RetryOverflow, limits.MaxRetries, rule))
+			cluster.Suggestions = append(cluster.Suggestions, ConnectionPoolSuggestion{
+				Setting:   "http.maxRetries",
+				Current:   limits.MaxRetries,
+				Suggested: raisedLimit(limits.MaxRetries, cluster.RetryOverflow, cluster.RequestsTotal),
+				Reason:    "only raise this once the upstream failures are understood, as retries add load to a struggling backend",
+			})
+		} else {
+			cluster.Findings = append(cluster.Findings, fmt.Sprintf("%d retries overflowed the retry budget; the upstream is failing often enough that retries are being shed", cluster.RetryOverflow))
+		}
+	}
+
+	// Limits that have not overflowed yet but the busiest client proxy nearly exhausts
+	nearLimit := func(active int, limit int32) bool {
+		return limit > 0 && float64(active) >= connectionPoolNearLimit*float64(limit)
+	}
+	if cluster.CxOverflow == 0 && nearLimit(cluster.PeakCxActive, limits.MaxConnections) {
+		cluster.Findings = append(cluster.Findings, fmt.Sprintf("A client proxy has %d active connections against tcp.maxConnections %d (%s)", cluster.PeakCxActive, limits.MaxConnections, rule))
+		cluster.Suggestions = append(cluster.Suggestions, ConnectionPoolSuggestion{
+			Setting:   "tcp.maxConnections",
+			Current:   limits.MaxConnections,
+			Suggested: limits.MaxConnections * 2,
+			Reason:    fmt.Sprintf("active connections are above %.0f%% of the limit", connectionPoolNearLimit*100),
+		})
+	}
+	if cluster.PendingOverflow == 0 && nearLimit(cluster.PeakPendingActive, limits.HTTP1MaxPendingRequests) {
+		cluster.Findings = append(cluster.Findings, fmt.Sprintf("A client proxy has %d pending requests against http.http1MaxPendingRequests %d (%s)", cluster.PeakPendingActive, limits.HTTP1MaxPendingRequests, rule))
+	}
+	if cluster.PendingOverflow == 0 && nearLimit(cluster.PeakRqActive, limits.HTTP2MaxRequests) {
+		cluster.Findings = append(cluster.Findings, fmt.Sprintf("A client proxy has %d active requests against http.http2MaxRequests %d (%s)", cluster.PeakRqActive, limits.HTTP2MaxRequests, rule))
+	}
+}
+
+// raisedLimit doubles a limit, or quadruples it when more than a quarter of the requests overflowed
+func raisedLimit(current int32, overflow, total int) int32 {
+	factor := int32(2)
+	if total > 0 && float64(overflow)/float64(total) > 0.25 {
+		factor = 4
+	}
+	return current * factor
+}
+
+// overflowShare describes the overflows as a share of the cluster's requests
+func overflowShare(overflow, total int) string {
+	if total == 0 {
+		return fmt.Sprintf("%d requests", overflow)
+	}
+	return fmt.Sprintf("%.1f%% of requests", 100*float64(overflow)/float64(total))
+}
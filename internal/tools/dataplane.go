@@ -0,0 +1,429 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// interceptionDebugPaths explains where to look when traffic interception misbehaves in each mode
+var interceptionDebugPaths = map[string][]string{
+	"init-container": {
+		"Rules live in the pod's network namespace and are written by the istio-init container at startup",
+		"Inspect them with get_iptables_rules and check the istio-init container logs with get_pod_logs",
+	},
+	"cni": {
+		"Rules live in the pod's network namespace but are written by the istio-cni plugin on the node",
+		"Inspect them with get_iptables_rules, check the istio-cni-node logs on the pod's node, and run check_cni_chaining and detect_cni_race",
+	},
+	"ambient": {
+		"The istio-cni agent programs redirection inside the pod's network namespace to the ztunnel on the same node",
+		"Inspect the pod's iptables with get_iptables_rules, and check the ztunnel and istio-cni-node logs on the pod's node",
+	},
+	"none": {
+		"Traffic is not intercepted; the pod is outside the mesh",
+	},
+}
+
+// PodInterception reports how a pod's traffic is intercepted
+type PodInterception struct {
+	Pod              string   `json:"pod"`
+	Namespace        string   `json:"namespace"`
+	Node             string   `json:"node,omitempty"`
+	Mode             string   `json:"mode"`                        // init-container, cni, ambient or none
+	InterceptionMode string   `json:"interception_mode,omitempty"` // REDIRECT or TPROXY for sidecars
+	Ztunnel          string   `json:"ztunnel,omitempty"`
+	Redirected       *bool    `json:"redirected,omitempty"` // ambient only: whether the CNI agent reported redirection
+	DebugPath        []string `json:"debug_path"`
+}
+
+// InterceptionReport summarizes interception modes in a namespace
+type InterceptionReport struct {
+	Namespace string            `json:"namespace"`
+	Summary   map[string]int    `json:"summary"`
+	Pods      []PodInterception `json:"pods"`
+	Issues    []string          `json:"issues,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Pagination
+}
+
+// getInterceptionModeParams are the arguments of get_interception_mode
+type getInterceptionModeParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: default
+	PodName   string `json:"pod_name,omitempty"`  // only report this pod
+}
+
+// GetInterceptionMode reports per pod whether traffic is intercepted by istio-init, the Istio CNI plugin or ambient
+func (m *Manager) GetInterceptionMode(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getInterceptionModeParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	pages, err := m.newPager("get_interception_mode", args)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, params.Namespace, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get namespace: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	var pods []corev1.Pod
+	if params.PodName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get pod: %v", err),
+					},
+				},
+			}, nil
+		}
+		pods = []corev1.Pod{*pod}
+	} else {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list pods: %v", err),
+					},
+				},
+			}, nil
+		}
+		pods = list.Items
+	}
+
+	ztunnels := m.ztunnelPodsByNode(ctx)
+
+	report := &InterceptionReport{
+		Namespace: params.Namespace,
+		Summary:   make(map[string]int),
+		Timestamp: time.Now(),
+	}
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Spec.HostNetwork {
+			continue
+		}
+		info := PodInterception{
+			Pod:       pod.Name,
+			Namespace: pod.Namespace,
+			Node:      pod.Spec.NodeName,
+			Mode:      podInterceptionMode(pod, ns.Labels["istio.io/dataplane-mode"]),
+		}
+		info.DebugPath = interceptionDebugPaths[info.Mode]
+
+		switch info.Mode {
+		case "init-container", "cni":
+			info.InterceptionMode = pod.Annotations["sidecar.istio.io/interceptionMode"]
+			if info.InterceptionMode == "" {
+				info.InterceptionMode = "REDIRECT"
+			}
+		case "ambient":
+			redirected := pod.Annotations["ambient.istio.io/redirection"] == "enabled"
+			info.Redirected = &redirected
+			if ztunnel, ok := ztunnels[pod.Spec.NodeName]; ok {
+				info.Ztunnel = ztunnel.Name
+			} else if pod.Spec.NodeName != "" {
+				report.Issues = append(report.Issues, fmt.Sprintf("%s is enrolled in ambient but no ztunnel runs on node %s", pod.Name, pod.Spec.NodeName))
+			}
+			if !redirected && pod.Status.Phase == corev1.PodRunning {
+				report.Issues = append(report.Issues, fmt.Sprintf("%s is enrolled in ambient but the CNI agent has not enabled redirection", pod.Name))
+			}
+		}
+
+		report.Summary[info.Mode]++
+		report.Pods = append(report.Pods, info)
+	}
+	sort.Slice(report.Pods, func(i, j int) bool {
+		return report.Pods[i].Pod < report.Pods[j].Pod
+	})
+	// The summary and issues cover every pod, the pod list one page of them
+	report.Pods, report.Pagination = paginate(pages, report.Pods)
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// podInterceptionMode classifies how a pod's traffic is intercepted
+func podInterceptionMode(pod *corev1.Pod, namespaceMode string) string {
+	for _, container := range pod.Spec.InitContainers {
+		switch container.Name {
+		case "istio-init":
+			return "init-container"
+		case "istio-validation":
+			return "cni"
+		}
+	}
+	if podHasSidecar(pod) {
+		// A sidecar without istio-init or istio-validation relies on the CNI plugin
+		return "cni"
+	}
+	if cniRedirectionMode(pod, namespaceMode) == "ambient" {
+		return "ambient"
+	}
+	return "none"
+}
+
+// ztunnelPodsByNode maps node names to the ztunnel pod running there
+func (m *Manager) ztunnelPodsByNode(ctx context.Context) map[string]*corev1.Pod {
+	byNode := make(map[string]*corev1.Pod)
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: "app=ztunnel"})
+	if err != nil {
+		return byNode
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName != "" && pod.DeletionTimestamp == nil {
+			byNode[pod.Spec.NodeName] = pod
+		}
+	}
+	return byNode
+}
+
+// systemNamespaces are skipped when detecting dataplane modes across the cluster
+var systemNamespaces = map[string]bool{
+	"kube-system":        true,
+	"kube-public":        true,
+	"kube-node-lease":    true,
+	"local-path-storage": true,
+}
+
+// NamespaceDataplane describes the dataplane mode of a namespace
+type NamespaceDataplane struct {
+	Namespace      string   `json:"namespace"`
+	DataplaneLabel string   `json:"dataplane_label,omitempty"` // value of istio.io/dataplane-mode
+	Injection      string   `json:"injection,omitempty"`       // enabled, disabled or rev=<revision>
+	Mode           string   `json:"mode"`                      // sidecar, ambient, mixed or none
+	Pods           int      `json:"pods"`
+	SidecarPods    int      `json:"sidecar_pods"`
+	AmbientPods    int      `json:"ambient_pods"`
+	UnenrolledPods []string `json:"unenrolled_pods,omitempty"` // pods that should be in the mesh but aren't
+	Waypoint       string   `json:"waypoint,omitempty"`
+	Warnings       []string `json:"warnings,omitempty"`
+}
+
+// DataplaneReport summarizes dataplane modes across namespaces
+type DataplaneReport struct {
+	ZtunnelInstalled bool                 `json:"ztunnel_installed"`
+	ZtunnelNodes     int                  `json:"ztunnel_nodes"`
+	Summary          map[string]int       `json:"summary"`
+	Namespaces       []NamespaceDataplane `json:"namespaces"`
+	Issues           []string             `json:"issues,omitempty"`
+	Timestamp        time.Time            `json:"timestamp"`
+}
+
+// detectDataplaneModeParams are the arguments of detect_dataplane_mode
+type detectDataplaneModeParams struct {
+	Namespace     string `json:"namespace,omitempty"`      // default: all non-system namespaces
+	IncludeSystem bool   `json:"include_system,omitempty"` // include kube-system and similar namespaces
+}
+
+// DetectDataplaneMode reports whether namespaces run sidecars, ambient or a mix, flagging inconsistent states
+func (m *Manager) DetectDataplaneMode(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params detectDataplaneModeParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	var namespaces []corev1.Namespace
+	if params.Namespace != "" {
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, params.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get namespace: %v", err),
+					},
+				},
+			}, nil
+		}
+		namespaces = []corev1.Namespace{*ns}
+	} else {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+					},
+				},
+			}, nil
+		}
+		namespaces = list.Items
+	}
+
+	ztunnels := m.ztunnelPodsByNode(ctx)
+	report := &DataplaneReport{
+		ZtunnelInstalled: len(ztunnels) > 0,
+		ZtunnelNodes:     len(ztunnels),
+		Summary:          make(map[string]int),
+		Timestamp:        time.Now(),
+	}
+
+	for _, ns := range namespaces {
+		if params.Namespace == "" && !params.IncludeSystem && systemNamespaces[ns.Name] {
+			continue
+		}
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			report.Issues = append(report.Issues, fmt.Sprintf("%s: failed to list pods: %v", ns.Name, err))
+			continue
+		}
+
+		dataplane := analyzeNamespaceDataplane(&ns, pods.Items, report.ZtunnelInstalled)
+		report.Summary[dataplane.Mode]++
+		for _, warning := range dataplane.Warnings {
+			report.Issues = append(report.Issues, fmt.Sprintf("%s: %s", ns.Name, warning))
+		}
+		report.Namespaces = append(report.Namespaces, dataplane)
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// analyzeNamespaceDataplane classifies a namespace and detects states typical of an unfinished ambient migration
+func analyzeNamespaceDataplane(ns *corev1.Namespace, pods []corev1.Pod, ztunnelInstalled bool) NamespaceDataplane {
+	dataplane := NamespaceDataplane{
+		Namespace:      ns.Name,
+		DataplaneLabel: ns.Labels["istio.io/dataplane-mode"],
+		Waypoint:       ns.Labels["istio.io/use-waypoint"],
+	}
+	switch {
+	case ns.Labels["istio.io/rev"] != "":
+		dataplane.Injection = "rev=" + ns.Labels["istio.io/rev"]
+	case ns.Labels["istio-injection"] != "":
+		dataplane.Injection = ns.Labels["istio-injection"]
+	}
+	injectionEnabled := dataplane.Injection != "" && dataplane.Injection != "disabled"
+	ambientLabeled := dataplane.DataplaneLabel == "ambient"
+
+	for i := range pods {
+		pod := &pods[i]
+		if pod.Spec.HostNetwork || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		dataplane.Pods++
+		switch {
+		case podHasSidecar(pod):
+			dataplane.SidecarPods++
+		case pod.Annotations["ambient.istio.io/redirection"] == "enabled":
+			dataplane.AmbientPods++
+		case pod.Labels["sidecar.istio.io/inject"] == "false" || pod.Labels["istio.io/dataplane-mode"] == "none":
+			// Explicitly opted out
+		case injectionEnabled || ambientLabeled || pod.Labels["istio.io/dataplane-mode"] == "ambient":
+			dataplane.UnenrolledPods = append(dataplane.UnenrolledPods, pod.Name)
+		}
+	}
+
+	switch {
+	case dataplane.SidecarPods > 0 && dataplane.AmbientPods > 0:
+		dataplane.Mode = "mixed"
+	case dataplane.SidecarPods > 0:
+		dataplane.Mode = "sidecar"
+	case dataplane.AmbientPods > 0:
+		dataplane.Mode = "ambient"
+	case ambientLabeled:
+		dataplane.Mode = "ambient"
+	case injectionEnabled:
+		dataplane.Mode = "sidecar"
+	default:
+		dataplane.Mode = "none"
+	}
+
+	if ambientLabeled && injectionEnabled {
+		dataplane.Warnings = append(dataplane.Warnings, "Namespace is labeled for both ambient and sidecar injection; new pods get sidecars and are skipped by ztunnel. Remove the injection label to finish the migration")
+	}
+	if ambientLabeled && dataplane.SidecarPods > 0 {
+		dataplane.Warnings = append(dataplane.Warnings, fmt.Sprintf("%d pods still run sidecars in an ambient namespace; restart them after removing injection to move them to ambient", dataplane.SidecarPods))
+	}
+	if !ambientLabeled && dataplane.AmbientPods > 0 && dataplane.SidecarPods > 0 {
+		dataplane.Warnings = append(dataplane.Warnings, "Pods run both sidecars and ambient redirection; check per-pod istio.io/dataplane-mode labels")
+	}
+	if ambientLabeled && !ztunnelInstalled {
+		dataplane.Warnings = append(dataplane.Warnings, "Namespace is labeled for ambient but no ztunnel is running")
+	}
+	if len(dataplane.UnenrolledPods) > 0 {
+		reason := "they were started before the namespace was labeled; restart them"
+		if ambientLabeled {
+			reason = "ambient redirection is missing; check the istio-cni agent with detect_cni_race"
+		}
+		dataplane.Warnings = append(dataplane.Warnings, fmt.Sprintf("%d pods are not in the mesh: %s", len(dataplane.UnenrolledPods), reason))
+	}
+	if dataplane.Waypoint != "" && dataplane.SidecarPods > 0 {
+		dataplane.Warnings = append(dataplane.Warnings, "Namespace uses a waypoint but has sidecar pods; waypoints only process traffic of ambient workloads")
+	}
+
+	return dataplane
+}
@@ -0,0 +1,159 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// debugImageCheckTimeout bounds pulling the debug image on a node before an ephemeral container is attached
+const debugImageCheckTimeout = 2 * time.Minute
+
+// debugProfiles are the kubectl debug profiles ephemeral debug containers can run with
+var debugProfiles = map[string]func() *corev1.SecurityContext{
+	"sysadmin": func() *corev1.SecurityContext {
+		return &corev1.SecurityContext{Privileged: boolPtr(true)}
+	},
+	"netadmin": func() *corev1.SecurityContext {
+		return &corev1.SecurityContext{
+			Capabilities: &corev1.Capabilities{
+				Add: []corev1.Capability{"NET_ADMIN", "NET_RAW"},
+			},
+		}
+	},
+}
+
+// debugImage returns the image a debugging tool runs: the image parameter, else the configured one, else the
+// tool's own default, pulled from the configured registry
+func (m *Manager) debugImage(image, toolDefault string) string {
+	if image == "" {
+		image = m.config.Debug.Image
+	}
+	if image == "" {
+		image = toolDefault
+	}
+	return m.config.Debug.ImageRef(image)
+}
+
+// debugSecurityContext returns the security context of an ephemeral debug container for the profile parameter,
+// else the configured profile, else the tool's own default
+func (m *Manager) debugSecurityContext(profile, toolDefault string) (*corev1.SecurityContext, string, error) {
+	if profile == "" {
+		profile = m.config.Debug.Profile
+	}
+	if profile == "" {
+		profile = toolDefault
+	}
+	securityContext, ok := debugProfiles[profile]
+	if !ok {
+		return nil, "", fmt.Errorf("unknown debug profile %q: use sysadmin or netadmin", profile)
+	}
+	return securityContext(), profile, nil
+}
+
+// checkDebugImage makes sure a debug image can be pulled on a pod's node before it is attached as an ephemeral
+// container, which can never be removed again: a failed pull would stay on the pod for good. The image is pulled
+// by a short-lived pod with the pod's pull secrets, and nodes known to have it are not checked again
+func (m *Manager) checkDebugImage(ctx context.Context, pod *corev1.Pod, image string) error {
+	node := pod.Spec.NodeName
+	key := m.kubeContext + "/" + node + "/" + image
+
+	m.debugImagesMu.Lock()
+	pulled := m.debugImages[key]
+	m.debugImagesMu.Unlock()
+	if pulled || node == "" {
+		return nil
+	}
+
+	if current, err := m.k8sClient.Kubernetes.CoreV1().Nodes().Get(ctx, node, metav1.GetOptions{}); err == nil && nodeHasImage(current, image) {
+		m.rememberDebugImage(key)
+		return nil
+	}
+
+	pods := m.k8sClient.Kubernetes.CoreV1().Pods(pod.Namespace)
+	probe := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "debug-image-check-" + utilrand.String(5),
+			Namespace: pod.Namespace,
+			Labels:    map[string]string{"app": "meshpilot-debug-image-check"},
+			Annotations: map[string]string{
+				"sidecar.istio.io/inject": "false",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:         node,
+			RestartPolicy:    corev1.RestartPolicyNever,
+			Tolerations:      []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			ImagePullSecrets: pod.Spec.ImagePullSecrets,
+			Containers: []corev1.Container{{
+				Name:            "pull",
+				Image:           image,
+				ImagePullPolicy: corev1.PullIfNotPresent,
+				Command:         []string{"true"},
+			}},
+		},
+	}
+	markManaged(ctx, probe)
+
+	created, err := pods.Create(ctx, probe, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to create debug image check pod: %w", err)
+	}
+	defer func() {
+		_ = pods.Delete(context.WithoutCancel(ctx), created.Name, metav1.DeleteOptions{GracePeriodSeconds: new(int64)})
+	}()
+
+	logrus.Debugf("Checking that debug image %s can be pulled on node %s", image, node)
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, debugImageCheckTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		for _, status := range current.Status.ContainerStatuses {
+			// Any state past the pull means the image is on the node, even if the image has no true to run
+			if status.ImageID != "" || status.State.Running != nil || status.State.Terminated != nil {
+				return true, nil
+			}
+			if waiting := status.State.Waiting; waiting != nil {
+				switch waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+					return false, fmt.Errorf("%s: %s", waiting.Reason, waiting.Message)
+				case "CreateContainerError", "RunContainerError":
+					return true, nil
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("debug image %s cannot be pulled on node %s (%v); set the image parameter or debug.image/debug.registry in the config", image, node, err)
+	}
+	m.rememberDebugImage(key)
+	return nil
+}
+
+// rememberDebugImage records that a debug image is present on a node
+func (m *Manager) rememberDebugImage(key string) {
+	m.debugImagesMu.Lock()
+	defer m.debugImagesMu.Unlock()
+	m.debugImages[key] = true
+}
+
+// nodeHasImage reports whether a node lists an image among those it holds, matching Docker Hub short names
+func nodeHasImage(node *corev1.Node, image string) bool {
+	for _, nodeImage := range node.Status.Images {
+		for _, name := range nodeImage.Names {
+			if name == image || strings.TrimPrefix(strings.TrimPrefix(name, "docker.io/"), "library/") == strings.TrimPrefix(image, "library/") {
+				return true
+			}
+		}
+	}
+	return false
+}
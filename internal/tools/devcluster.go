@@ -0,0 +1,297 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// gatewayHTTPNodePort is the node port mapped to the host for gateway HTTP traffic
+	gatewayHTTPNodePort = 30080
+	// gatewayHTTPSNodePort is the node port mapped to the host for gateway HTTPS traffic
+	gatewayHTTPSNodePort = 30443
+)
+
+// DevCluster describes a provisioned local development cluster
+type DevCluster struct {
+	Name         string   `json:"name"`
+	Provider     string   `json:"provider"`
+	Context      string   `json:"context"`
+	NodeImage    string   `json:"node_image,omitempty"`
+	Nodes        int      `json:"nodes"`
+	PortMappings []string `json:"port_mappings,omitempty"`
+	Notes        []string `json:"notes,omitempty"`
+}
+
+// createDevClusterParams are the arguments of create_dev_cluster
+type createDevClusterParams struct {
+	Name              string `json:"name,omitempty"`               // default: meshpilot
+	Provider          string `json:"provider,omitempty"`           // kind or minikube, default: kind
+	KubernetesVersion string `json:"kubernetes_version,omitempty"` // e.g. v1.29.2
+	NodeImage         string `json:"node_image,omitempty"`         // kind node image override
+	Workers           int    `json:"workers,omitempty"`            // worker nodes in addition to the control plane
+	HTTPPort          int    `json:"http_port,omitempty"`          // host port for gateway HTTP, default: 80
+	HTTPSPort         int    `json:"https_port,omitempty"`         // host port for gateway HTTPS, default: 443
+	Wait              string `json:"wait,omitempty"`               // wait for the control plane, default: 5m
+}
+
+// CreateDevCluster provisions a local kind or minikube cluster for mesh demos
+func (m *Manager) CreateDevCluster(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params createDevClusterParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Name == "" {
+		params.Name = "meshpilot"
+	}
+	if params.Provider == "" {
+		params.Provider = "kind"
+	}
+	if params.HTTPPort == 0 {
+		params.HTTPPort = 80
+	}
+	if params.HTTPSPort == 0 {
+		params.HTTPSPort = 443
+	}
+	if params.Wait == "" {
+		params.Wait = "5m"
+	}
+
+	var cluster *DevCluster
+	var err error
+	switch params.Provider {
+	case "kind":
+		nodeImage := params.NodeImage
+		if nodeImage == "" && params.KubernetesVersion != "" {
+			nodeImage = "kindest/node:" + ensureVersionPrefix(params.KubernetesVersion)
+		}
+		cluster, err = createKindCluster(ctx, params.Name, nodeImage, params.Workers, params.HTTPPort, params.HTTPSPort, params.Wait)
+	case "minikube":
+		cluster, err = createMinikubeCluster(ctx, params.Name, params.KubernetesVersion, params.Workers)
+	default:
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Unsupported provider %q (valid: kind, minikube)", params.Provider),
+				},
+			},
+		}, nil
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to create %s cluster: %v", params.Provider, err),
+				},
+			},
+		}, nil
+	}
+
+	// The provider registers and selects the new context; point the tools at it
+	if err := m.reconnectCluster(); err != nil {
+		logrus.Warnf("Failed to connect to new cluster: %v", err)
+		cluster.Notes = append(cluster.Notes, fmt.Sprintf("Cluster created but the client could not connect yet: %v", err))
+	}
+
+	result, _ := json.MarshalIndent(cluster, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(result),
+			},
+		},
+	}, nil
+}
+
+// deleteDevClusterParams are the arguments of delete_dev_cluster
+type deleteDevClusterParams struct {
+	Name     string `json:"name,omitempty"`     // default: meshpilot
+	Provider string `json:"provider,omitempty"` // kind or minikube, default: kind
+}
+
+// DeleteDevCluster deletes a local kind or minikube cluster
+func (m *Manager) DeleteDevCluster(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deleteDevClusterParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Name == "" {
+		params.Name = "meshpilot"
+	}
+	if params.Provider == "" {
+		params.Provider = "kind"
+	}
+
+	var cmd *exec.Cmd
+	switch params.Provider {
+	case "kind":
+		cmd = exec.CommandContext(ctx, "kind", "delete", "cluster", "--name", params.Name)
+	case "minikube":
+		cmd = exec.CommandContext(ctx, "minikube", "delete", "--profile", params.Name)
+	default:
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Unsupported provider %q (valid: kind, minikube)", params.Provider),
+				},
+			},
+		}, nil
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to delete %s cluster %s: %v, output: %s", params.Provider, params.Name, err, string(output)),
+				},
+			},
+		}, nil
+	}
+
+	logrus.Infof("%s delete output: %s", params.Provider, string(output))
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("Deleted %s cluster '%s' and its kubeconfig context. Use switch_context to select another cluster.", params.Provider, params.Name),
+			},
+		},
+	}, nil
+}
+
+// createKindCluster creates a kind cluster whose control plane maps the gateway node ports to the host
+func createKindCluster(ctx context.Context, name, nodeImage string, workers, httpPort, httpsPort int, wait string) (*DevCluster, error) {
+	if _, err := exec.LookPath("kind"); err != nil {
+		return nil, fmt.Errorf("kind not found in PATH: %w", err)
+	}
+
+	configFile, err := os.CreateTemp("", "meshpilot-kind-*.yaml")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create kind config: %w", err)
+	}
+	defer os.Remove(configFile.Name())
+
+	if _, err := configFile.WriteString(kindClusterConfig(workers, httpPort, httpsPort)); err != nil {
+		configFile.Close()
+		return nil, fmt.Errorf("failed to write kind config: %w", err)
+	}
+	configFile.Close()
+
+	args := []string{"create", "cluster", "--name", name, "--config", configFile.Name(), "--wait", wait}
+	if nodeImage != "" {
+		args = append(args, "--image", nodeImage)
+	}
+
+	cmd := exec.CommandContext(ctx, "kind", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("kind create cluster failed: %w, output: %s", err, string(output))
+	}
+	logrus.Infof("kind create output: %s", string(output))
+
+	return &DevCluster{
+		Name:      name,
+		Provider:  "kind",
+		Context:   "kind-" + name,
+		NodeImage: nodeImage,
+		Nodes:     workers + 1,
+		PortMappings: []string{
+			fmt.Sprintf("localhost:%d -> nodePort %d (gateway http)", httpPort, gatewayHTTPNodePort),
+			fmt.Sprintf("localhost:%d -> nodePort %d (gateway https)", httpsPort, gatewayHTTPSNodePort),
+		},
+		Notes: []string{
+			fmt.Sprintf("Expose the ingress gateway as a NodePort service on %d/%d to reach it from the host", gatewayHTTPNodePort, gatewayHTTPSNodePort),
+		},
+	}, nil
+}
+
+// kindClusterConfig renders a kind cluster config with gateway port mappings on the control plane
+func kindClusterConfig(workers, httpPort, httpsPort int) string {
+	var sb strings.Builder
+	sb.WriteString("kind: Cluster\napiVersion: kind.x-k8s.io/v1alpha4\nnodes:\n")
+	sb.WriteString("- role: control-plane\n")
+	sb.WriteString("  kubeadmConfigPatches:\n")
+	sb.WriteString("  - |\n    kind: InitConfiguration\n    nodeRegistration:\n      kubeletExtraArgs:\n        node-labels: \"ingress-ready=true\"\n")
+	sb.WriteString("  extraPortMappings:\n")
+	sb.WriteString(fmt.Sprintf("  - containerPort: %d\n    hostPort: %d\n    protocol: TCP\n", gatewayHTTPNodePort, httpPort))
+	sb.WriteString(fmt.Sprintf("  - containerPort: %d\n    hostPort: %d\n    protocol: TCP\n", gatewayHTTPSNodePort, httpsPort))
+	for i := 0; i < workers; i++ {
+		sb.WriteString("- role: worker\n")
+	}
+	return sb.String()
+}
+
+// createMinikubeCluster creates a minikube profile with the requested number of nodes
+func createMinikubeCluster(ctx context.Context, name, kubernetesVersion string, workers int) (*DevCluster, error) {
+	if _, err := exec.LookPath("minikube"); err != nil {
+		return nil, fmt.Errorf("minikube not found in PATH: %w", err)
+	}
+
+	args := []string{"start", "--profile", name, "--nodes", fmt.Sprintf("%d", workers+1)}
+	if kubernetesVersion != "" {
+		args = append(args, "--kubernetes-version", ensureVersionPrefix(kubernetesVersion))
+	}
+
+	cmd := exec.CommandContext(ctx, "minikube", args...)
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return nil, fmt.Errorf("minikube start failed: %w, output: %s", err, string(output))
+	}
+	logrus.Infof("minikube start output: %s", string(output))
+
+	return &DevCluster{
+		Name:     name,
+		Provider: "minikube",
+		Context:  name,
+		Nodes:    workers + 1,
+		Notes: []string{
+			fmt.Sprintf("Run 'minikube tunnel --profile %s' to give LoadBalancer gateway services an external IP", name),
+		},
+	}, nil
+}
+
+// ensureVersionPrefix adds the leading v expected by node images and minikube
+func ensureVersionPrefix(version string) string {
+	if strings.HasPrefix(version, "v") {
+		return version
+	}
+	return "v" + version
+}
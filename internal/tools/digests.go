@@ -0,0 +1,248 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// manifestMediaTypes are the manifest formats accepted when resolving digests, with
+// image indexes first so multi-arch images pin to the index rather than one platform
+var manifestMediaTypes = []string{
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// PinnedImage records the digest an image tag was resolved to
+type PinnedImage struct {
+	Image     string `json:"image"`     // original tagged reference
+	Digest    string `json:"digest"`    // resolved manifest digest
+	Reference string `json:"reference"` // digest-pinned reference used in the install
+}
+
+// imageReference is a parsed container image reference
+type imageReference struct {
+	Registry   string
+	Repository string
+	Tag        string
+}
+
+// parseImageReference splits an image reference into registry, repository and tag,
+// applying the Docker Hub defaults for short names
+func parseImageReference(image string) (imageReference, error) {
+	if strings.Contains(image, "@") {
+		return imageReference{}, fmt.Errorf("image %s is already pinned by digest", image)
+	}
+
+	ref := imageReference{Registry: "docker.io", Tag: "latest"}
+	name := image
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		first := name[:slash]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			ref.Registry = first
+			name = name[slash+1:]
+		}
+	}
+	if colon := strings.LastIndex(name, ":"); colon >= 0 {
+		ref.Tag = name[colon+1:]
+		name = name[:colon]
+	}
+	if name == "" || ref.Tag == "" {
+		return imageReference{}, fmt.Errorf("invalid image reference %s", image)
+	}
+	if ref.Registry == "docker.io" && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+	ref.Repository = name
+	return ref, nil
+}
+
+// registryHost returns the host serving the registry API
+func (r imageReference) registryHost() string {
+	if r.Registry == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return r.Registry
+}
+
+// resolveImageDigest resolves an image tag to its manifest digest using the registry API
+func resolveImageDigest(ctx context.Context, image string) (*PinnedImage, error) {
+	ref, err := parseImageReference(image)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	manifestURL := fmt.Sprintf("https://%s/v2/%s/manifests/%s", ref.registryHost(), ref.Repository, ref.Tag)
+
+	resp, err := headManifest(ctx, client, manifestURL, "")
+	if err != nil {
+		return nil, err
+	}
+
+	// Public registries require an anonymous bearer token even for pulls
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+		token, err := fetchRegistryToken(ctx, client, challenge)
+		if err != nil {
+			return nil, fmt.Errorf("failed to authenticate to %s: %w", ref.Registry, err)
+		}
+		resp, err = headManifest(ctx, client, manifestURL, token)
+		if err != nil {
+			return nil, err
+		}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s for %s", resp.Status, image)
+	}
+
+	digest := resp.Header.Get("Docker-Content-Digest")
+	if digest == "" {
+		return nil, fmt.Errorf("registry did not return a digest for %s", image)
+	}
+
+	name := image[:strings.LastIndex(image, ":"+ref.Tag)]
+	return &PinnedImage{
+		Image:     image,
+		Digest:    digest,
+		Reference: name + "@" + digest,
+	}, nil
+}
+
+// headManifest requests the manifest headers, optionally with a bearer token
+func headManifest(ctx context.Context, client *http.Client, manifestURL, token string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query registry: %w", err)
+	}
+	return resp, nil
+}
+
+// fetchRegistryToken obtains an anonymous pull token from a Bearer WWW-Authenticate challenge
+func fetchRegistryToken(ctx context.Context, client *http.Client, challenge string) (string, error) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(challenge, "Bearer "), ",") {
+		if key, value, found := strings.Cut(strings.TrimSpace(part), "="); found {
+			params[key] = strings.Trim(value, `"`)
+		}
+	}
+	if params["realm"] == "" {
+		return "", fmt.Errorf("authentication challenge has no realm")
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, params["realm"]+"?"+query.Encode(), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	var tokenResp struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.Unmarshal(body, &tokenResp); err != nil {
+		return "", fmt.Errorf("failed to parse token response: %w", err)
+	}
+	if tokenResp.Token != "" {
+		return tokenResp.Token, nil
+	}
+	return tokenResp.AccessToken, nil
+}
+
+// pinImage resolves an image to its digest-pinned reference, recording the result
+func pinImage(ctx context.Context, image string, pinned *[]PinnedImage) (string, error) {
+	pin, err := resolveImageDigest(ctx, image)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve digest for %s: %w", image, err)
+	}
+	*pinned = append(*pinned, *pin)
+	return pin.Reference, nil
+}
+
+// formatPinnedImages renders a digest pinning report for tool output, empty when nothing was pinned
+func formatPinnedImages(pinned []PinnedImage) string {
+	if len(pinned) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString("\nPinned image digests:")
+	for _, pin := range pinned {
+		sb.WriteString(fmt.Sprintf("\n  %s -> %s", pin.Image, pin.Digest))
+	}
+	return sb.String()
+}
+
+// setHelmValue sets a dotted key in a Helm values map, creating intermediate maps
+func setHelmValue(values map[string]interface{}, key string, value interface{}) {
+	parts := strings.Split(key, ".")
+	current := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			next = make(map[string]interface{})
+			current[part] = next
+		}
+		current = next
+	}
+	current[parts[len(parts)-1]] = value
+}
+
+// getHelmValue returns the string value at a dotted key in a Helm values map
+func getHelmValue(values map[string]interface{}, key string) string {
+	parts := strings.Split(key, ".")
+	current := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := current[part].(map[string]interface{})
+		if !ok {
+			return ""
+		}
+		current = next
+	}
+	if value, ok := current[parts[len(parts)-1]].(string); ok {
+		return value
+	}
+	return ""
+}
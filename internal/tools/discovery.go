@@ -0,0 +1,466 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+)
+
+// DiscoveryNamespace is a namespace and whether istiod watches it
+type DiscoveryNamespace struct {
+	Name       string `json:"name"`
+	Meshed     bool   `json:"meshed"`               // labeled for sidecar injection or ambient
+	MatchedBy  string `json:"matched_by,omitempty"` // selector that brings it into scope
+	Discovered bool   `json:"-"`
+}
+
+// DiscoveryScopeReport lists the namespaces inside and outside istiod's discovery scope
+type DiscoveryScopeReport struct {
+	ConfigMap  string                 `json:"config_map"`
+	Selectors  []metav1.LabelSelector `json:"discovery_selectors"`
+	Restricted bool                   `json:"restricted"` // false when istiod watches every namespace
+	Inside     []DiscoveryNamespace   `json:"inside"`
+	Outside    []DiscoveryNamespace   `json:"outside"`
+	Issues     []string               `json:"issues,omitempty"`
+	Notes      []string               `json:"notes,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// DiscoverySelectorChange is the result of changing meshConfig.discoverySelectors
+type DiscoverySelectorChange struct {
+	Release       string                 `json:"release"`
+	Applied       bool                   `json:"applied"`
+	DryRun        bool                   `json:"dry_run,omitempty"`
+	Previous      []metav1.LabelSelector `json:"previous_selectors"`
+	Selectors     []metav1.LabelSelector `json:"selectors"`
+	NowDiscovered []string               `json:"now_discovered,omitempty"`
+	NowIgnored    []string               `json:"now_ignored,omitempty"`
+	Scope         *DiscoveryScopeReport  `json:"scope"`
+	Issues        []string               `json:"issues,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+}
+
+// auditDiscoverySelectorsParams are the arguments of audit_discovery_selectors
+type auditDiscoverySelectorsParams struct {
+	Namespace string `json:"namespace,omitempty"` // istiod namespace, default: istio-system
+	Revision  string `json:"revision,omitempty"`  // control plane revision, default: the default revision
+}
+
+// AuditDiscoverySelectors reports the meshConfig.discoverySelectors in effect and which namespaces
+// istiod watches, flagging meshed namespaces it ignores
+func (m *Manager) AuditDiscoverySelectors(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params auditDiscoverySelectorsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+
+	selectors, err := m.meshDiscoverySelectors(ctx, params.Namespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report, err := discoveryScope(namespaces.Items, selectors, params.Namespace)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid discovery selectors: %v", err),
+				},
+			},
+		}, nil
+	}
+	report.ConfigMap = params.Namespace + "/" + meshConfigMapName(params.Revision)
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// configureDiscoverySelectorsParams are the arguments of configure_discovery_selectors
+type configureDiscoverySelectorsParams struct {
+	Namespaces []string               `json:"namespaces,omitempty"` // namespaces to watch, matched by name
+	Selectors  []metav1.LabelSelector `json:"selectors,omitempty"`  // raw label selectors
+	Clear      bool                   `json:"clear,omitempty"`      // remove the selectors so istiod watches everything
+	Namespace  string                 `json:"namespace,omitempty"`  // istiod namespace, default: istio-system
+	Revision   string                 `json:"revision,omitempty"`   // control plane revision
+	Release    string                 `json:"release,omitempty"`    // istiod Helm release, default: istiod
+	DryRun     bool                   `json:"dry_run,omitempty"`    // only preview the change
+	Timeout    string                 `json:"timeout,omitempty"`    // default: 5m
+	RepoURL    string                 `json:"repo_url,omitempty"`   // chart repository override
+}
+
+// ConfigureDiscoverySelectors sets meshConfig.discoverySelectors on the istiod Helm release so istiod
+// only watches the selected namespaces, previewing which namespaces enter or leave the scope
+func (m *Manager) ConfigureDiscoverySelectors(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureDiscoverySelectorsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	given := 0
+	for _, set := range []bool{len(params.Namespaces) > 0, len(params.Selectors) > 0, params.Clear} {
+		if set {
+			given++
+		}
+	}
+	if given != 1 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Exactly one of namespaces, selectors or clear is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	// Selecting by name always keeps istiod's own namespace, where its gateways and config usually live
+	selectors := params.Selectors
+	if len(params.Namespaces) > 0 {
+		names := append([]string{}, params.Namespaces...)
+		if !containsString(names, params.Namespace) {
+			names = append(names, params.Namespace)
+		}
+		selectors = []metav1.LabelSelector{{
+			MatchExpressions: []metav1.LabelSelectorRequirement{{
+				Key:      "kubernetes.io/metadata.name",
+				Operator: metav1.LabelSelectorOpIn,
+				Values:   names,
+			}},
+		}}
+	}
+
+	previous, err := m.meshDiscoverySelectors(ctx, params.Namespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	before, err := discoveryScope(namespaces.Items, previous, params.Namespace)
+	if err != nil {
+		before = &DiscoveryScopeReport{}
+	}
+	after, err := discoveryScope(namespaces.Items, selectors, params.Namespace)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid selectors: %v", err),
+				},
+			},
+		}, nil
+	}
+	after.ConfigMap = params.Namespace + "/" + meshConfigMapName(params.Revision)
+
+	result := &DiscoverySelectorChange{
+		Release:   params.Namespace + "/" + params.Release,
+		DryRun:    params.DryRun,
+		Previous:  previous,
+		Selectors: selectors,
+		Scope:     after,
+		Issues:    after.Issues,
+		Timestamp: time.Now(),
+	}
+	wasInside := make(map[string]bool)
+	for _, ns := range before.Inside {
+		wasInside[ns.Name] = true
+	}
+	if !before.Restricted {
+		for _, ns := range namespaces.Items {
+			wasInside[ns.Name] = true
+		}
+	}
+	for _, ns := range after.Inside {
+		if !wasInside[ns.Name] {
+			result.NowDiscovered = append(result.NowDiscovered, ns.Name)
+		}
+	}
+	for _, ns := range after.Outside {
+		if wasInside[ns.Name] {
+			result.NowIgnored = append(result.NowIgnored, ns.Name)
+		}
+	}
+
+	if params.DryRun || reflect.DeepEqual(previous, selectors) {
+		if !params.DryRun {
+			after.Notes = append(after.Notes, "The selectors are already in effect; nothing was changed")
+		}
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.setIstiodMeshConfig(ctx, params.Namespace, params.Release, params.RepoURL, "discoverySelectors", selectors, params.Timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to update discovery selectors: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	applied, err := m.meshDiscoverySelectors(ctx, params.Namespace, params.Revision)
+	switch {
+	case err != nil:
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to re-read mesh config: %v", err))
+	case !reflect.DeepEqual(applied, selectors):
+		result.Issues = append(result.Issues, "The Helm upgrade succeeded but the mesh config does not show the new selectors; check for a meshConfig override in another release or configmap")
+	default:
+		result.Applied = true
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// setIstiodMeshConfig upgrades the istiod release in place, replacing one meshConfig field
+func (m *Manager) setIstiodMeshConfig(ctx context.Context, namespace, release, repoURL, field string, value interface{}, timeout string) error {
+	return m.setIstiodValues(ctx, namespace, release, repoURL, map[string]interface{}{"meshConfig." + field: value}, timeout)
+}
+
+// setIstiodValues upgrades the istiod release in place, setting the given dotted value paths and
+// keeping the installed chart version and all other values
+func (m *Manager) setIstiodValues(ctx context.Context, namespace, release, repoURL string, values map[string]interface{}, timeout string) error {
+	if err := m.checkHelmAvailable(); err != nil {
+		return fmt.Errorf("helm is not available: %w", err)
+	}
+	istiod, err := m.getHelmReleaseInfo(namespace, release)
+	if err != nil {
+		return fmt.Errorf("failed to find the istiod release: %w", err)
+	}
+	repo := resolveChartRepository(m.config.Helm.Istio, repoURL)
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		return fmt.Errorf("failed to add Istio Helm repository: %w", err)
+	}
+
+	request := helmChartRequest{
+		Release:     release,
+		Chart:       repo.ChartRef("istiod"),
+		Namespace:   namespace,
+		Values:      values,
+		Wait:        true,
+		Timeout:     timeout,
+		Upgrade:     true,
+		ReuseValues: true,
+	}
+	if version := strings.TrimPrefix(istiod.Chart, "istiod-"); version != istiod.Chart {
+		request.Version = version
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	upgraded, err := m.installHelmChart(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Istiod upgraded (%s): %s", strings.Join(keys, ", "), upgraded)
+	return nil
+}
+
+// meshConfigMapName returns the configmap holding the mesh config of a revision
+func meshConfigMapName(revision string) string {
+	if revision == "" || revision == defaultRevision {
+		return "istio"
+	}
+	return "istio-" + revision
+}
+
+// meshDiscoverySelectors reads meshConfig.discoverySelectors from the mesh configmap
+func (m *Manager) meshDiscoverySelectors(ctx context.Context, namespace, revision string) ([]metav1.LabelSelector, error) {
+	var mesh struct {
+		DiscoverySelectors []metav1.LabelSelector `json:"discoverySelectors"`
+	}
+	if err := m.readMeshConfig(ctx, namespace, revision, &mesh); err != nil {
+		return nil, err
+	}
+	return mesh.DiscoverySelectors, nil
+}
+
+// readMeshConfig decodes the mesh config of a revision from its configmap into out
+func (m *Manager) readMeshConfig(ctx context.Context, namespace, revision string, out interface{}) error {
+	name := meshConfigMapName(revision)
+	cm, err := m.k8sClient.Kubernetes.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return fmt.Errorf("failed to get configmap %s/%s: %w", namespace, name, err)
+	}
+	if err := yaml.Unmarshal([]byte(cm.Data["mesh"]), out); err != nil {
+		return fmt.Errorf("failed to parse mesh config: %w", err)
+	}
+	return nil
+}
+
+// discoveryScope splits namespaces into those istiod watches and those it ignores; a namespace is
+// watched when it matches any selector, and every namespace is watched when there are none
+func discoveryScope(namespaces []corev1.Namespace, selectors []metav1.LabelSelector, istiodNamespace string) (*DiscoveryScopeReport, error) {
+	report := &DiscoveryScopeReport{
+		Selectors:  selectors,
+		Restricted: len(selectors) > 0,
+		Inside:     []DiscoveryNamespace{},
+		Outside:    []DiscoveryNamespace{},
+		Timestamp:  time.Now(),
+	}
+	if report.Selectors == nil {
+		report.Selectors = []metav1.LabelSelector{}
+	}
+
+	parsed := make([]labels.Selector, 0, len(selectors))
+	for i := range selectors {
+		selector, err := metav1.LabelSelectorAsSelector(&selectors[i])
+		if err != nil {
+			return nil, fmt.Errorf("selector %d: %w", i, err)
+		}
+		parsed = append(parsed, selector)
+	}
+
+	for _, ns := range namespaces {
+		entry := DiscoveryNamespace{
+			Name: ns.Name,
+			Meshed: ns.Labels["istio-injection"] == "enabled" || ns.Labels["istio.io/rev"] != "" ||
+				ns.Labels["istio.io/dataplane-mode"] == "ambient",
+			Discovered: len(parsed) == 0,
+		}
+		for i, selector := range parsed {
+			if selector.Matches(labels.Set(ns.Labels)) {
+				entry.Discovered = true
+				entry.MatchedBy = selector.String()
+				if entry.MatchedBy == "" {
+					entry.MatchedBy = fmt.Sprintf("selector %d (matches everything)", i)
+				}
+				break
+			}
+		}
+		if entry.Discovered {
+			report.Inside = append(report.Inside, entry)
+			continue
+		}
+		report.Outside = append(report.Outside, entry)
+		if entry.Meshed {
+			report.Issues = append(report.Issues, fmt.Sprintf("Namespace %s is meshed but outside the discovery scope; its services are invisible to the rest of the mesh and it gets no Istio config", ns.Name))
+		}
+		if ns.Name == istiodNamespace {
+			report.Issues = append(report.Issues, fmt.Sprintf("istiod's namespace %s is outside the discovery scope; gateways and mesh-wide config there are ignored", ns.Name))
+		}
+	}
+
+	if !report.Restricted {
+		report.Notes = append(report.Notes, "No discoverySelectors are set; istiod watches every namespace")
+	}
+	return report, nil
+}
@@ -0,0 +1,618 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// dnsCaptureKey and dnsAutoAllocateKey are the proxy metadata settings controlling the sidecar
+	// DNS proxy and address auto-allocation for ServiceEntries
+	dnsCaptureKey      = "ISTIO_META_DNS_CAPTURE"
+	dnsAutoAllocateKey = "ISTIO_META_DNS_AUTO_ALLOCATE"
+	// proxyConfigAnnotation overrides mesh-wide proxy config for a pod
+	proxyConfigAnnotation = "proxy.istio.io/config"
+
+	// dnsProbeHost is a ServiceEntry host with a fixed address that only the DNS proxy can resolve,
+	// and dnsProbeAutoHost one without an address that resolves only when auto-allocation is on
+	dnsProbeHost     = "dns-probe.meshpilot.internal"
+	dnsProbeAutoHost = "auto.dns-probe.meshpilot.internal"
+	dnsProbeAddress  = "198.51.100.53"
+)
+
+// autoAllocatedRange is the class E range istiod allocates ServiceEntry addresses from
+var autoAllocatedRange = &net.IPNet{IP: net.IPv4(240, 240, 0, 0), Mask: net.CIDRMask(16, 32)}
+
+// DNSProxySettings are the DNS proxying settings of the mesh or of a proxy
+type DNSProxySettings struct {
+	DNSCapture   bool `json:"dns_capture"`
+	AutoAllocate bool `json:"auto_allocate"`
+}
+
+// DNSResolutionCheck is what a workload resolved for the probe ServiceEntry hosts
+type DNSResolutionCheck struct {
+	Pod            string           `json:"pod"`
+	Proxy          DNSProxySettings `json:"proxy"`          // settings the pod's sidecar started with
+	StaticHost     []string         `json:"static_host"`    // addresses returned for the host with a fixed address
+	AutoHost       []string         `json:"auto_host"`      // addresses returned for the host without an address
+	Intercepted    bool             `json:"intercepted"`    // the sidecar answered the DNS query
+	AutoAllocated  bool             `json:"auto_allocated"` // the host without an address got an auto-allocated address
+	ResolutionNote string           `json:"resolution_note,omitempty"`
+}
+
+// DNSProxyingResult is the result of changing DNS proxying settings
+type DNSProxyingResult struct {
+	Scope       string              `json:"scope"` // mesh or the configured deployments
+	Previous    DNSProxySettings    `json:"previous"`
+	Requested   DNSProxySettings    `json:"requested"`
+	Changed     bool                `json:"changed"`
+	DryRun      bool                `json:"dry_run,omitempty"`
+	Deployments []string            `json:"deployments,omitempty"`
+	Impact      []string            `json:"impact"`
+	StalePods   int                 `json:"stale_pods,omitempty"` // sidecar pods still running with other settings
+	Before      *DNSResolutionCheck `json:"before,omitempty"`
+	After       *DNSResolutionCheck `json:"after,omitempty"`
+	Issues      []string            `json:"issues,omitempty"`
+	Notes       []string            `json:"notes,omitempty"`
+	Timestamp   time.Time           `json:"timestamp"`
+}
+
+// configureDNSProxyingParams are the arguments of configure_dns_proxying
+type configureDNSProxyingParams struct {
+	DNSCapture       *bool    `json:"dns_capture,omitempty"`       // default: true
+	AutoAllocate     *bool    `json:"auto_allocate,omitempty"`     // default: true
+	Namespace        string   `json:"namespace,omitempty"`         // namespace of the deployments and the source, default: default
+	Deployments      []string `json:"deployments,omitempty"`       // default: mesh-wide
+	SourceDeployment string   `json:"source_deployment,omitempty"` // deployment to resolve from, default: sleep
+	IstioNamespace   string   `json:"istio_namespace,omitempty"`   // default: istio-system
+	Release          string   `json:"release,omitempty"`           // istiod Helm release, default: istiod
+	Revision         string   `json:"revision,omitempty"`          // control plane revision
+	RepoURL          string   `json:"repo_url,omitempty"`          // chart repository override
+	Verify           *bool    `json:"verify,omitempty"`            // default: true
+	DryRun           bool     `json:"dry_run,omitempty"`           // only report the current settings and impact
+	Timeout          string   `json:"timeout,omitempty"`           // default: 5m
+}
+
+// ConfigureDNSProxying turns the sidecar DNS proxy and ServiceEntry address auto-allocation on or off,
+// mesh-wide or for selected deployments, and checks DNS interception from a workload before and after
+func (m *Manager) ConfigureDNSProxying(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureDNSProxyingParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.DNSCapture == nil {
+		params.DNSCapture = boolPtr(true)
+	}
+	if params.AutoAllocate == nil {
+		params.AutoAllocate = boolPtr(true)
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.SourceDeployment == "" {
+		params.SourceDeployment = "sleep"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Verify == nil {
+		params.Verify = boolPtr(true)
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "configure_dns_proxying")
+
+	requested := DNSProxySettings{DNSCapture: *params.DNSCapture, AutoAllocate: *params.AutoAllocate}
+	result := &DNSProxyingResult{
+		Scope:     "mesh",
+		Requested: requested,
+		DryRun:    params.DryRun,
+		Impact:    dnsProxyingImpact(requested),
+		Timestamp: time.Now(),
+	}
+	if len(params.Deployments) > 0 {
+		result.Scope = "deployments"
+	}
+
+	var mesh struct {
+		DefaultConfig struct {
+			ProxyMetadata map[string]string `json:"proxyMetadata"`
+		} `json:"defaultConfig"`
+	}
+	if err := m.readMeshConfig(ctx, params.IstioNamespace, params.Revision, &mesh); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+	meshSettings := dnsProxySettingsFrom(mesh.DefaultConfig.ProxyMetadata)
+	result.Previous = meshSettings
+	result.Changed = meshSettings != requested
+
+	// Deployments keep the mesh settings unless their pods override them
+	var deployments []*appsv1.Deployment
+	for _, name := range params.Deployments {
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get deployment %s/%s: %v", params.Namespace, name, err),
+					},
+				},
+			}, nil
+		}
+		deployments = append(deployments, deployment)
+		result.Deployments = append(result.Deployments, params.Namespace+"/"+name)
+	}
+	if len(deployments) > 0 {
+		result.Changed = false
+		for _, deployment := range deployments {
+			metadata, err := proxyConfigMetadata(deployment.Spec.Template.Annotations[proxyConfigAnnotation])
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Deployment %s has an unparseable %s annotation: %v", deployment.Name, proxyConfigAnnotation, err))
+				continue
+			}
+			if overlayDNSProxySettings(meshSettings, metadata) != requested {
+				result.Changed = true
+			}
+		}
+		if len(deployments) == 1 {
+			if metadata, err := proxyConfigMetadata(deployments[0].Spec.Template.Annotations[proxyConfigAnnotation]); err == nil {
+				result.Previous = overlayDNSProxySettings(meshSettings, metadata)
+			}
+		}
+	}
+	if requested.AutoAllocate && !requested.DNSCapture {
+		result.Issues = append(result.Issues, "Auto-allocated addresses are only handed out by the sidecar DNS proxy; with dns_capture off, auto_allocate has no effect")
+	}
+
+	if params.DryRun {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	// The probe ServiceEntries exist only for the duration of the check
+	var source *appsv1.Deployment
+	if *params.Verify {
+		source, err = m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, params.SourceDeployment, metav1.GetOptions{})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Could not verify DNS interception: failed to get source deployment %s/%s: %v", params.Namespace, params.SourceDeployment, err))
+		} else {
+			probes := dnsProbeServiceEntries(params.Namespace)
+			defer func() {
+				for _, obj := range probes {
+					err := m.k8sClient.Dynamic.Resource(serviceEntryGVR).Namespace(params.Namespace).Delete(context.WithoutCancel(ctx), obj.GetName(), metav1.DeleteOptions{})
+					if err != nil && !errors.IsNotFound(err) {
+						result.Notes = append(result.Notes, fmt.Sprintf("Failed to remove probe ServiceEntry %s: %v", obj.GetName(), err))
+					}
+				}
+			}()
+			for _, obj := range probes {
+				if err := m.applyResource(ctx, serviceEntryGVR, obj); err != nil {
+					return &CallToolResult{
+						IsError: true,
+						Content: []interface{}{
+							TextContent{
+								Type: "text",
+								Text: fmt.Sprintf("Failed to create probe ServiceEntry %s: %v", obj.GetName(), err),
+							},
+						},
+					}, nil
+				}
+			}
+			before, err := m.checkDNSResolution(ctx, source, nil)
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Failed to resolve the probe hosts before the change: %v", err))
+			}
+			result.Before = before
+		}
+	}
+
+	// Apply the settings; proxies read them at startup, so the affected pods are restarted
+	restartSource := false
+	if len(deployments) == 0 {
+		if meshSettings != requested {
+			metadata := make(map[string]string)
+			for key, value := range mesh.DefaultConfig.ProxyMetadata {
+				metadata[key] = value
+			}
+			metadata[dnsCaptureKey] = fmt.Sprintf("%t", requested.DNSCapture)
+			metadata[dnsAutoAllocateKey] = fmt.Sprintf("%t", requested.AutoAllocate)
+			if err := m.setIstiodMeshConfig(ctx, params.IstioNamespace, params.Release, params.RepoURL, "defaultConfig.proxyMetadata", metadata, params.Timeout); err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Failed to update mesh proxy metadata: %v", err),
+						},
+					},
+				}, nil
+			}
+		}
+		restartSource = source != nil && meshSettings != requested
+	} else {
+		for _, deployment := range deployments {
+			err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+				current, err := m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+				if err != nil {
+					return err
+				}
+				annotation, err := setProxyConfigMetadata(current.Spec.Template.Annotations[proxyConfigAnnotation], map[string]string{
+					dnsCaptureKey:      fmt.Sprintf("%t", requested.DNSCapture),
+					dnsAutoAllocateKey: fmt.Sprintf("%t", requested.AutoAllocate),
+				})
+				if err != nil {
+					return err
+				}
+				if current.Spec.Template.Annotations == nil {
+					current.Spec.Template.Annotations = make(map[string]string)
+				}
+				current.Spec.Template.Annotations[proxyConfigAnnotation] = annotation
+				_, err = m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+				return err
+			})
+			if err == nil {
+				err = m.waitForRollout(ctx, deployment, timeout)
+			}
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Failed to update deployment %s/%s: %v", deployment.Namespace, deployment.Name, err))
+			}
+		}
+		if source != nil && !containsString(params.Deployments, source.Name) {
+			result.Notes = append(result.Notes, fmt.Sprintf("Source deployment %s is not among the configured deployments, so the check after the change shows its unchanged behaviour", source.Name))
+		}
+	}
+	if restartSource {
+		if err := m.restartDeployment(ctx, source, timeout); err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to restart source deployment %s: %v", source.Name, err))
+		} else {
+			result.Notes = append(result.Notes, fmt.Sprintf("Restarted deployment %s/%s so its sidecar picks up the new settings", source.Namespace, source.Name))
+		}
+	}
+
+	if len(deployments) == 0 {
+		stale, err := m.countStaleDNSProxies(ctx, requested)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to count pods still running the previous settings: %v", err))
+		} else if stale > 0 {
+			result.StalePods = stale
+			result.Notes = append(result.Notes, fmt.Sprintf("%d sidecar pods started before the change and keep their previous DNS settings until restarted", stale))
+		}
+	}
+
+	if source != nil && result.Before != nil {
+		var expected *DNSProxySettings
+		if len(deployments) == 0 || containsString(params.Deployments, source.Name) {
+			expected = &requested
+		}
+		after, err := m.checkDNSResolution(ctx, source, expected)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to resolve the probe hosts after the change: %v", err))
+		}
+		result.After = after
+		if after != nil && expected != nil {
+			if after.Intercepted != requested.DNSCapture {
+				result.Issues = append(result.Issues, fmt.Sprintf("DNS interception is %t after the change, expected %t", after.Intercepted, requested.DNSCapture))
+			}
+			if requested.DNSCapture && after.AutoAllocated != requested.AutoAllocate {
+				result.Issues = append(result.Issues, fmt.Sprintf("Auto-allocation is %t after the change, expected %t", after.AutoAllocated, requested.AutoAllocate))
+			}
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// dnsProxyingImpact explains what the requested settings change for workloads
+func dnsProxyingImpact(settings DNSProxySettings) []string {
+	var impact []string
+	if settings.DNSCapture {
+		impact = append(impact,
+			"Sidecars intercept DNS queries on port 53 and answer for Kubernetes services and ServiceEntry hosts from istiod's name table, forwarding everything else to the cluster DNS",
+			"ServiceEntry hosts that exist nowhere in DNS (e.g. VM or external aliases) become resolvable from meshed pods",
+			"Lookups for mesh hosts no longer reach kube-dns, cutting its load and the search-domain round trips")
+	} else {
+		impact = append(impact, "Every DNS query goes to the cluster DNS; ServiceEntry hosts resolve only if real DNS knows them")
+	}
+	if settings.AutoAllocate && settings.DNSCapture {
+		impact = append(impact,
+			"ServiceEntries without addresses get a virtual IP from 240.240.0.0/16, so TCP services sharing a port get separate listeners instead of colliding on 0.0.0.0",
+			"Applications see class E addresses for those hosts; clients or egress firewalls that reject 240.0.0.0/4 need an exception")
+	} else {
+		impact = append(impact, "ServiceEntries without addresses share a wildcard listener per port; TCP services on the same port cannot be told apart")
+	}
+	impact = append(impact, "Proxies read these settings at startup; running pods keep their current behaviour until restarted")
+	return impact
+}
+
+// dnsProxySettingsFrom reads DNS proxying settings from proxy metadata
+func dnsProxySettingsFrom(metadata map[string]string) DNSProxySettings {
+	return overlayDNSProxySettings(DNSProxySettings{}, metadata)
+}
+
+// overlayDNSProxySettings applies the DNS settings present in proxy metadata over a base
+func overlayDNSProxySettings(base DNSProxySettings, metadata map[string]string) DNSProxySettings {
+	if value, ok := metadata[dnsCaptureKey]; ok {
+		base.DNSCapture = value == "true"
+	}
+	if value, ok := metadata[dnsAutoAllocateKey]; ok {
+		base.AutoAllocate = value == "true"
+	}
+	return base
+}
+
+// proxyConfigMetadata reads proxyMetadata from a proxy.istio.io/config annotation
+func proxyConfigMetadata(annotation string) (map[string]string, error) {
+	var config struct {
+		ProxyMetadata map[string]string `json:"proxyMetadata"`
+	}
+	if err := yaml.Unmarshal([]byte(annotation), &config); err != nil {
+		return nil, err
+	}
+	return config.ProxyMetadata, nil
+}
+
+// setProxyConfigMetadata sets proxyMetadata keys in a proxy.istio.io/config annotation, keeping its other fields
+func setProxyConfigMetadata(annotation string, values map[string]string) (string, error) {
+	config := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(annotation), &config); err != nil {
+		return "", fmt.Errorf("failed to parse %s annotation: %w", proxyConfigAnnotation, err)
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	metadata, _ := config["proxyMetadata"].(map[string]interface{})
+	if metadata == nil {
+		metadata = make(map[string]interface{})
+	}
+	for key, value := range values {
+		metadata[key] = value
+	}
+	config["proxyMetadata"] = metadata
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// dnsProbeServiceEntries builds a ServiceEntry with a fixed address and one without an address;
+// neither host exists in real DNS, so only the sidecar DNS proxy can resolve them
+func dnsProbeServiceEntries(namespace string) []*unstructured.Unstructured {
+	port := []interface{}{map[string]interface{}{"number": int64(9999), "name": "tcp-probe", "protocol": "TCP"}}
+	return []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "ServiceEntry",
+			"metadata":   map[string]interface{}{"name": "meshpilot-dns-probe", "namespace": namespace},
+			"spec": map[string]interface{}{
+				"hosts":      []interface{}{dnsProbeHost},
+				"addresses":  []interface{}{dnsProbeAddress},
+				"ports":      port,
+				"resolution": "STATIC",
+				"location":   "MESH_EXTERNAL",
+				"endpoints":  []interface{}{map[string]interface{}{"address": dnsProbeAddress}},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "ServiceEntry",
+			"metadata":   map[string]interface{}{"name": "meshpilot-dns-probe-auto", "namespace": namespace},
+			"spec": map[string]interface{}{
+				"hosts":      []interface{}{dnsProbeAutoHost},
+				"ports":      port,
+				"resolution": "DNS",
+				"location":   "MESH_EXTERNAL",
+			},
+		}},
+	}
+}
+
+// checkDNSResolution resolves the probe hosts from a running pod of the deployment; when expected
+// is set it waits for a pod started with those settings and for the answers to match them
+func (m *Manager) checkDNSResolution(ctx context.Context, deployment *appsv1.Deployment, expected *DNSProxySettings) (*DNSResolutionCheck, error) {
+	var check *DNSResolutionCheck
+	var lastErr error
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, configPropagationTimeout, true, func(ctx context.Context) (bool, error) {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels).String(),
+		})
+		if err != nil {
+			lastErr = err
+			return false, nil
+		}
+		var pod *corev1.Pod
+		for i := range pods.Items {
+			candidate := &pods.Items[i]
+			if candidate.Status.Phase != corev1.PodRunning || candidate.DeletionTimestamp != nil || !isPodReady(candidate) {
+				continue
+			}
+			if expected == nil || podDNSProxySettings(candidate) == *expected {
+				pod = candidate
+				break
+			}
+		}
+		if pod == nil {
+			lastErr = fmt.Errorf("no ready pod of %s/%s runs with the expected settings", deployment.Namespace, deployment.Name)
+			return false, nil
+		}
+
+		check = &DNSResolutionCheck{Pod: pod.Name, Proxy: podDNSProxySettings(pod)}
+		check.StaticHost = m.resolveFromPod(ctx, pod, dnsProbeHost)
+		check.AutoHost = m.resolveFromPod(ctx, pod, dnsProbeAutoHost)
+		check.Intercepted = containsString(check.StaticHost, dnsProbeAddress)
+		for _, address := range check.AutoHost {
+			if ip := net.ParseIP(address); ip != nil && autoAllocatedRange.Contains(ip) {
+				check.AutoAllocated = true
+			}
+		}
+		if !podHasSidecar(pod) {
+			check.ResolutionNote = "Pod has no sidecar; DNS proxying only applies to sidecars"
+			return true, nil
+		}
+		lastErr = nil
+		if expected == nil {
+			return true, nil
+		}
+		return check.Intercepted == expected.DNSCapture && (!expected.DNSCapture || check.AutoAllocated == expected.AutoAllocate), nil
+	})
+	if check == nil {
+		if lastErr != nil {
+			return nil, lastErr
+		}
+		return nil, err
+	}
+	if err != nil && expected != nil {
+		check.ResolutionNote = fmt.Sprintf("Answers did not match the requested settings within %s", configPropagationTimeout)
+	}
+	return check, nil
+}
+
+// resolveFromPod looks a host up with nslookup in the sleep container and returns the addresses found
+func (m *Manager) resolveFromPod(ctx context.Context, pod *corev1.Pod, host string) []string {
+	output, _ := m.execCommandInPod(ctx, pod.Namespace, pod.Name, "sleep", []string{"nslookup", host})
+
+	// Addresses before the Name: line belong to the DNS server
+	addresses := []string{}
+	answered := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "Name:"):
+			answered = true
+		case answered && strings.HasPrefix(line, "Address"):
+			fields := strings.Fields(line)
+			if address := fields[len(fields)-1]; net.ParseIP(address) != nil {
+				addresses = append(addresses, address)
+			}
+		}
+	}
+	return addresses
+}
+
+// podDNSProxySettings reads the DNS settings the pod's sidecar was started with
+func podDNSProxySettings(pod *corev1.Pod) DNSProxySettings {
+	metadata := make(map[string]string)
+	for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+		if container.Name != "istio-proxy" {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == dnsCaptureKey || env.Name == dnsAutoAllocateKey {
+				metadata[env.Name] = env.Value
+			}
+		}
+	}
+	return dnsProxySettingsFrom(metadata)
+}
+
+// countStaleDNSProxies counts running sidecar pods whose DNS settings differ from the given ones
+func (m *Manager) countStaleDNSProxies(ctx context.Context, settings DNSProxySettings) (int, error) {
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return 0, err
+	}
+	stale := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || !podHasSidecar(pod) {
+			continue
+		}
+		// Pods overriding the settings through their annotation are not affected by the mesh change
+		if metadata, err := proxyConfigMetadata(pod.Annotations[proxyConfigAnnotation]); err == nil && len(metadata) > 0 {
+			if _, ok := metadata[dnsCaptureKey]; ok {
+				continue
+			}
+		}
+		if podDNSProxySettings(pod) != settings {
+			stale++
+		}
+	}
+	return stale, nil
+}
+
+// restartDeployment rolls a deployment's pods the way kubectl rollout restart does
+func (m *Manager) restartDeployment(ctx context.Context, deployment *appsv1.Deployment, timeout time.Duration) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if current.Spec.Template.Annotations == nil {
+			current.Spec.Template.Annotations = make(map[string]string)
+		}
+		current.Spec.Template.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
+		_, err = m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return m.waitForRollout(ctx, deployment, timeout)
+}
@@ -0,0 +1,491 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// dualStackEnv is the istiod and proxy setting that turns on Istio's dual-stack support
+const dualStackEnv = "ISTIO_DUAL_STACK"
+
+// DualStackReport is the result of validate_dual_stack
+type DualStackReport struct {
+	ClusterFamilies []string          `json:"cluster_families"` // families of the node pod CIDRs
+	ServiceFamilies []string          `json:"service_families"` // families of the kubernetes Service
+	Nodes           []DualStackNode   `json:"nodes"`
+	Services        DualStackServices `json:"services"`
+	Pods            DualStackPods     `json:"pods"`
+	Istio           DualStackIstio    `json:"istio"`
+	Probes          []DualStackProbe  `json:"probes,omitempty"`
+	Issues          []string          `json:"issues,omitempty"`
+	Notes           []string          `json:"notes,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+}
+
+// DualStackNode lists the IP families a node serves
+type DualStackNode struct {
+	Name      string   `json:"name"`
+	PodCIDRs  []string `json:"pod_cidrs"`
+	Addresses []string `json:"internal_addresses"`
+	Families  []string `json:"families"`
+}
+
+// DualStackServices counts the Services of the inspected namespaces by IP family policy
+type DualStackServices struct {
+	Total         int            `json:"total"`
+	ByPolicy      map[string]int `json:"by_policy"`
+	DualStack     int            `json:"dual_stack"` // Services with a ClusterIP of each family
+	SingleStackOf []string       `json:"single_stack,omitempty"`
+	HeadlessCount int            `json:"headless"`
+}
+
+// DualStackPods counts the running pods of the inspected namespaces by the families of their IPs
+type DualStackPods struct {
+	Total     int `json:"total"`
+	DualStack int `json:"dual_stack"`
+	IPv4Only  int `json:"ipv4_only"`
+	IPv6Only  int `json:"ipv6_only"`
+}
+
+// DualStackIstio reports Istio's dual-stack settings
+type DualStackIstio struct {
+	Installed       bool              `json:"installed"`
+	IstiodDualStack map[string]string `json:"istiod_dual_stack,omitempty"` // ISTIO_DUAL_STACK per istiod deployment
+	ProxyDualStack  string            `json:"proxy_dual_stack,omitempty"`  // meshConfig.defaultConfig.proxyMetadata ISTIO_DUAL_STACK
+	Enabled         bool              `json:"enabled"`
+}
+
+// DualStackProbe is one connectivity check over a single IP family
+type DualStackProbe struct {
+	Family  string `json:"family"`
+	Kind    string `json:"kind"` // cluster_ip or pod_ip
+	Target  string `json:"target"`
+	Success bool   `json:"success"`
+	Code    string `json:"code,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// validateDualStackParams are the arguments of validate_dual_stack
+type validateDualStackParams struct {
+	Namespace       string `json:"namespace,omitempty"`         // default: all namespaces
+	IstioNamespace  string `json:"istio_namespace,omitempty"`   // default: istio-system
+	Revision        string `json:"revision,omitempty"`          // default: default revision
+	Service         string `json:"service,omitempty"`           // Service to probe over each family
+	ServiceNS       string `json:"service_namespace,omitempty"` // default: namespace, or default
+	Port            int    `json:"port,omitempty"`              // default: first service port
+	Path            string `json:"path,omitempty"`              // default: /
+	SourcePod       string `json:"source_pod,omitempty"`        // default: first app=sleep pod
+	SourceNamespace string `json:"source_namespace,omitempty"`  // default: service_namespace
+}
+
+// ValidateDualStack checks that the cluster, its Services and pods, and Istio agree on the IP families in use,
+// and probes a Service over each of its families from a source pod
+func (m *Manager) ValidateDualStack(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params validateDualStackParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.ServiceNS == "" {
+		params.ServiceNS = params.Namespace
+	}
+	if params.ServiceNS == "" {
+		params.ServiceNS = "default"
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.ServiceNS
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+
+	result := &DualStackReport{
+		Nodes: []DualStackNode{},
+		Services: DualStackServices{
+			ByPolicy: make(map[string]int),
+		},
+		Timestamp: time.Now(),
+	}
+
+	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list nodes: %v", err),
+				},
+			},
+		}, nil
+	}
+	clusterFamilies := make(map[string]bool)
+	for _, node := range nodes.Items {
+		entry := DualStackNode{Name: node.Name, PodCIDRs: node.Spec.PodCIDRs, Addresses: []string{}}
+		if len(entry.PodCIDRs) == 0 && node.Spec.PodCIDR != "" {
+			entry.PodCIDRs = []string{node.Spec.PodCIDR}
+		}
+		for _, address := range node.Status.Addresses {
+			if address.Type == corev1.NodeInternalIP {
+				entry.Addresses = append(entry.Addresses, address.Address)
+			}
+		}
+		entry.Families = ipFamilies(entry.PodCIDRs)
+		for _, family := range entry.Families {
+			clusterFamilies[family] = true
+		}
+		if addressFamilies := ipFamilies(entry.Addresses); len(entry.Families) == 2 && len(addressFamilies) < 2 {
+			result.Issues = append(result.Issues, fmt.Sprintf("Node %s has dual-stack pod CIDRs but only %s internal addresses; node-to-node traffic of the other family has no route", node.Name, strings.Join(addressFamilies, ",")))
+		}
+		result.Nodes = append(result.Nodes, entry)
+	}
+	result.ClusterFamilies = sortedKeys(clusterFamilies)
+	dualStackCluster := len(result.ClusterFamilies) == 2
+	for _, node := range result.Nodes {
+		if dualStackCluster && len(node.Families) < 2 {
+			result.Issues = append(result.Issues, fmt.Sprintf("Node %s only has %v pod CIDRs while other nodes are dual-stack; its pods get a single IP", node.Name, node.PodCIDRs))
+		}
+	}
+	if len(result.ClusterFamilies) == 0 {
+		result.Notes = append(result.Notes, "Nodes have no pod CIDRs (the CNI allocates pod IPs itself); families are taken from pod IPs instead")
+	}
+
+	if kubernetesSvc, err := m.k8sClient.Kubernetes.CoreV1().Services("default").Get(ctx, "kubernetes", metav1.GetOptions{}); err == nil {
+		result.ServiceFamilies = ipFamilies(kubernetesSvc.Spec.ClusterIPs)
+	}
+
+	services, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list services: %v", err),
+				},
+			},
+		}, nil
+	}
+	for _, svc := range services.Items {
+		if svc.Spec.Type == corev1.ServiceTypeExternalName {
+			continue
+		}
+		result.Services.Total++
+		policy := string(corev1.IPFamilyPolicySingleStack)
+		if svc.Spec.IPFamilyPolicy != nil {
+			policy = string(*svc.Spec.IPFamilyPolicy)
+		}
+		result.Services.ByPolicy[policy]++
+		if svc.Spec.ClusterIP == corev1.ClusterIPNone {
+			result.Services.HeadlessCount++
+		}
+		if len(svc.Spec.IPFamilies) == 2 {
+			result.Services.DualStack++
+		} else if policy == string(corev1.IPFamilyPolicySingleStack) && svc.Namespace != "default" && svc.Namespace != "kube-system" {
+			result.Services.SingleStackOf = append(result.Services.SingleStackOf, svc.Namespace+"/"+svc.Name)
+		}
+	}
+
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+	podFamilies := make(map[string]bool)
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodRunning || pod.Spec.HostNetwork {
+			continue
+		}
+		result.Pods.Total++
+		families := ipFamilies(podIPs(&pod))
+		for _, family := range families {
+			podFamilies[family] = true
+		}
+		switch {
+		case len(families) == 2:
+			result.Pods.DualStack++
+		case len(families) == 1 && families[0] == "IPv4":
+			result.Pods.IPv4Only++
+		case len(families) == 1:
+			result.Pods.IPv6Only++
+		}
+	}
+	if len(result.ClusterFamilies) == 0 {
+		result.ClusterFamilies = sortedKeys(podFamilies)
+		dualStackCluster = len(result.ClusterFamilies) == 2
+	}
+
+	if dualStackCluster {
+		if len(result.ServiceFamilies) < 2 {
+			result.Issues = append(result.Issues, fmt.Sprintf("Pods are dual-stack but the service CIDR is %v only; no Service can get a ClusterIP of the other family", result.ServiceFamilies))
+		} else if len(result.Services.SingleStackOf) > 0 {
+			result.Notes = append(result.Notes, fmt.Sprintf("%d Services are SingleStack in a dual-stack cluster; set ipFamilyPolicy PreferDualStack to reach them over both families", len(result.Services.SingleStackOf)))
+		}
+		if result.Pods.DualStack < result.Pods.Total {
+			result.Issues = append(result.Issues, fmt.Sprintf("%d of %d running pods have a single IP in a dual-stack cluster", result.Pods.Total-result.Pods.DualStack, result.Pods.Total))
+		}
+	} else {
+		result.Services.SingleStackOf = nil
+	}
+
+	// Istio's dual-stack support is off unless both istiod and the proxies enable it
+	result.Istio = m.dualStackIstioSettings(ctx, params.IstioNamespace, params.Revision)
+	switch {
+	case !result.Istio.Installed:
+		result.Notes = append(result.Notes, fmt.Sprintf("No istiod deployment found in %s", params.IstioNamespace))
+	case dualStackCluster && !result.Istio.Enabled:
+		result.Issues = append(result.Issues, fmt.Sprintf("The cluster is dual-stack but Istio is not: set %s=true in pilot.env (istiod) and meshConfig.defaultConfig.proxyMetadata (proxies); until then proxies only listen on and route to one family", dualStackEnv))
+	case !dualStackCluster && result.Istio.Enabled:
+		result.Notes = append(result.Notes, fmt.Sprintf("%s is enabled but the cluster is single-stack; the setting has no effect", dualStackEnv))
+	}
+	for deployment, value := range result.Istio.IstiodDualStack {
+		if (value == "true") != (result.Istio.ProxyDualStack == "true") {
+			result.Issues = append(result.Issues, fmt.Sprintf("istiod %s has %s=%q but proxyMetadata has %q; istiod and the proxies must agree", deployment, dualStackEnv, value, result.Istio.ProxyDualStack))
+		}
+	}
+
+	if params.Service != "" {
+		probes, err := m.probeDualStackService(ctx, params.ServiceNS, params.Service, params.Port, params.Path, params.SourceNamespace, params.SourcePod)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: err.Error(),
+					},
+				},
+			}, nil
+		}
+		result.Probes = probes
+		for _, probe := range probes {
+			if !probe.Success {
+				result.Issues = append(result.Issues, fmt.Sprintf("%s probe to %s %s failed: %s", probe.Family, probe.Kind, probe.Target, probe.Error))
+			}
+		}
+	} else {
+		result.Notes = append(result.Notes, "Pass service to probe its ClusterIPs and pod IPs over each family")
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// dualStackIstioSettings reads ISTIO_DUAL_STACK from the istiod deployments and the mesh proxy metadata
+func (m *Manager) dualStackIstioSettings(ctx context.Context, istioNamespace, revision string) DualStackIstio {
+	settings := DualStackIstio{IstiodDualStack: make(map[string]string)}
+
+	selector := "app=istiod"
+	if revision != "" {
+		selector += ",istio.io/rev=" + revision
+	}
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(istioNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil || len(deployments.Items) == 0 {
+		return settings
+	}
+	settings.Installed = true
+	for _, deployment := range deployments.Items {
+		value := ""
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			for _, env := range container.Env {
+				if env.Name == dualStackEnv {
+					value = env.Value
+				}
+			}
+		}
+		settings.IstiodDualStack[deployment.Name] = value
+	}
+
+	var mesh struct {
+		DefaultConfig struct {
+			ProxyMetadata map[string]string `json:"proxyMetadata"`
+		} `json:"defaultConfig"`
+	}
+	if err := m.readMeshConfig(ctx, istioNamespace, revision, &mesh); err == nil {
+		settings.ProxyDualStack = mesh.DefaultConfig.ProxyMetadata[dualStackEnv]
+	}
+
+	settings.Enabled = settings.ProxyDualStack == "true"
+	for _, value := range settings.IstiodDualStack {
+		if value != "true" {
+			settings.Enabled = false
+		}
+	}
+	return settings
+}
+
+// probeDualStackService curls each ClusterIP of a Service and each IP of one of its pods from a source pod
+func (m *Manager) probeDualStackService(ctx context.Context, namespace, name string, port int, path, sourceNamespace, sourcePod string) ([]DualStackProbe, error) {
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to get service: %v", err)
+	}
+	if len(svc.Spec.Ports) == 0 {
+		return nil, fmt.Errorf("Service %s/%s has no ports", namespace, name)
+	}
+	servicePort := svc.Spec.Ports[0]
+	for _, candidate := range svc.Spec.Ports {
+		if int(candidate.Port) == port {
+			servicePort = candidate
+		}
+	}
+
+	source, err := m.findWaypointSource(ctx, sourceNamespace, sourcePod)
+	if err != nil {
+		return nil, err
+	}
+	sourceFamilies := ipFamilies(podIPs(source))
+	container := ""
+	for _, c := range source.Spec.Containers {
+		if c.Name != "istio-proxy" {
+			container = c.Name
+			break
+		}
+	}
+
+	var probes []DualStackProbe
+	probe := func(kind, ip string, targetPort int) {
+		family := ipFamily(ip)
+		target := net.JoinHostPort(ip, strconv.Itoa(targetPort))
+		result := DualStackProbe{Family: family, Kind: kind, Target: target}
+		if !containsString(sourceFamilies, family) {
+			result.Error = fmt.Sprintf("source pod %s/%s has no %s address", source.Namespace, source.Name, family)
+			probes = append(probes, result)
+			return
+		}
+		output, err := m.execCommandInPod(ctx, source.Namespace, source.Name, container,
+			[]string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", "5", "http://" + target + path})
+		result.Code = strings.TrimSpace(output)
+		switch {
+		case err != nil && (result.Code == "" || result.Code == "000"):
+			result.Error = fmt.Sprintf("no response: %v", err)
+		case result.Code == "000":
+			result.Error = "no response"
+		default:
+			result.Success = true
+		}
+		probes = append(probes, result)
+	}
+
+	for _, ip := range svc.Spec.ClusterIPs {
+		if ip != corev1.ClusterIPNone {
+			probe("cluster_ip", ip, int(servicePort.Port))
+		}
+	}
+
+	if len(svc.Spec.Selector) > 0 {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String()})
+		if err == nil {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.Status.Phase != corev1.PodRunning {
+					continue
+				}
+				targetPort := servicePort.TargetPort.IntValue()
+				if targetPort == 0 {
+					targetPort = namedContainerPort(pod, servicePort.TargetPort.String())
+				}
+				if targetPort == 0 {
+					targetPort = int(servicePort.Port)
+				}
+				for _, ip := range podIPs(pod) {
+					probe("pod_ip", ip, targetPort)
+				}
+				break
+			}
+		}
+	}
+	return probes, nil
+}
+
+// podIPs returns all IPs of a pod, falling back to the primary IP on clusters that do not fill podIPs
+func podIPs(pod *corev1.Pod) []string {
+	var ips []string
+	for _, ip := range pod.Status.PodIPs {
+		ips = append(ips, ip.IP)
+	}
+	if len(ips) == 0 && pod.Status.PodIP != "" {
+		ips = append(ips, pod.Status.PodIP)
+	}
+	return ips
+}
+
+// namedContainerPort resolves a named container port of a pod, returning 0 when no container has it
+func namedContainerPort(pod *corev1.Pod, name string) int {
+	for _, container := range pod.Spec.Containers {
+		for _, port := range container.Ports {
+			if port.Name == name {
+				return int(port.ContainerPort)
+			}
+		}
+	}
+	return 0
+}
+
+// ipFamily returns IPv4 or IPv6 for an IP address or CIDR
+func ipFamily(address string) string {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		ip, _, _ = net.ParseCIDR(address)
+	}
+	if ip != nil && ip.To4() == nil {
+		return "IPv6"
+	}
+	return "IPv4"
+}
+
+// ipFamilies returns the sorted distinct families of IP addresses or CIDRs
+func ipFamilies(addresses []string) []string {
+	families := make(map[string]bool)
+	for _, address := range addresses {
+		families[ipFamily(address)] = true
+	}
+	return sortedKeys(families)
+}
+
+// sortedKeys returns the keys of a set in order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,536 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// egressGatewayRelease is the Helm release name used for the egress gateway; the gateway chart
+// labels its pods istio=<release without the istio- prefix>, i.e. istio=egressgateway
+const egressGatewayRelease = "istio-egressgateway"
+
+var serviceEntryGVR = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "serviceentries"}
+
+// EgressHostVerification is the result of sending a request to an external host and checking
+// that the egress gateway carried it
+type EgressHostVerification struct {
+	Host             string `json:"host"`
+	URL              string `json:"url"`
+	ResponseCode     string `json:"response_code"`
+	GatewayConns     int    `json:"gateway_connections"` // upstream connections the gateway opened to the host during the test
+	AccessLogMatches int    `json:"access_log_matches"`  // gateway access log entries for the host during the test
+	ThroughGateway   bool   `json:"through_gateway"`
+	WaitedFor        string `json:"waited_for,omitempty"`
+}
+
+// EgressRoutingResult is the result of routing external hosts through the egress gateway
+type EgressRoutingResult struct {
+	Hosts            []string                 `json:"hosts"`
+	Protocol         string                   `json:"protocol"`
+	Port             int                      `json:"port"`
+	Gateway          string                   `json:"gateway"` // <namespace>/<service> of the egress gateway
+	GatewayPods      []string                 `json:"gateway_pods"`
+	GatewayInstalled bool                     `json:"gateway_installed,omitempty"`
+	Resources        []string                 `json:"resources"`
+	Source           string                   `json:"source,omitempty"`
+	Verification     []EgressHostVerification `json:"verification,omitempty"`
+	Issues           []string                 `json:"issues,omitempty"`
+	Notes            []string                 `json:"notes,omitempty"`
+	Timestamp        time.Time                `json:"timestamp"`
+}
+
+// configureEgressRoutingParams are the arguments of configure_egress_routing
+type configureEgressRoutingParams struct {
+	Hosts            []string `json:"hosts"`                       // external hosts, e.g. edition.cnn.com
+	Protocol         string   `json:"protocol,omitempty"`          // tls (SNI passthrough on 443) or http (port 80), default: tls
+	Namespace        string   `json:"namespace,omitempty"`         // namespace for the routing resources, default: default
+	Name             string   `json:"name,omitempty"`              // resource name prefix, default: egress
+	GatewayNamespace string   `json:"gateway_namespace,omitempty"` // default: istio-system
+	GatewayService   string   `json:"gateway_service,omitempty"`   // default: istio-egressgateway
+	GatewaySelector  string   `json:"gateway_selector,omitempty"`  // default: istio=egressgateway
+	InstallGateway   bool     `json:"install_gateway,omitempty"`   // install the gateway chart if no gateway pods are found
+	IstioNamespace   string   `json:"istio_namespace,omitempty"`   // default: istio-system
+	Revision         string   `json:"revision,omitempty"`          // control plane revision
+	RepoURL          string   `json:"repo_url,omitempty"`          // chart repository override
+	Verify           *bool    `json:"verify,omitempty"`            // default: true
+	SourcePod        string   `json:"source_pod,omitempty"`        // default: first app=sleep pod
+	SourceNamespace  string   `json:"source_namespace,omitempty"`  // default: namespace
+	Timeout          string   `json:"timeout,omitempty"`           // default: 5m
+}
+
+// ConfigureEgressRouting forces traffic to external hosts through the egress gateway with a
+// ServiceEntry, Gateway, DestinationRule and VirtualServices, then verifies it from a client pod
+func (m *Manager) ConfigureEgressRouting(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureEgressRoutingParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if len(params.Hosts) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "hosts is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Protocol == "" {
+		params.Protocol = "tls"
+	}
+	port := 443
+	switch params.Protocol {
+	case "tls":
+	case "http":
+		port = 80
+	default:
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid protocol %q: must be tls or http", params.Protocol),
+				},
+			},
+		}, nil
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Name == "" {
+		params.Name = "egress"
+	}
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-system"
+	}
+	if params.GatewayService == "" {
+		params.GatewayService = egressGatewayRelease
+	}
+	if params.GatewaySelector == "" {
+		params.GatewaySelector = "istio=egressgateway"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Verify == nil {
+		params.Verify = boolPtr(true)
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+	selectorKey, selectorValue, found := strings.Cut(params.GatewaySelector, "=")
+	if !found || selectorKey == "" || strings.Contains(selectorValue, ",") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid gateway_selector %q: must be a single key=value label", params.GatewaySelector),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "configure_egress_routing")
+
+	result := &EgressRoutingResult{
+		Hosts:     params.Hosts,
+		Protocol:  params.Protocol,
+		Port:      port,
+		Gateway:   fmt.Sprintf("%s/%s", params.GatewayNamespace, params.GatewayService),
+		Timestamp: time.Now(),
+	}
+
+	// Find the egress gateway, installing it when asked to
+	gatewayPods, err := m.runningPods(ctx, params.GatewayNamespace, params.GatewaySelector)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list egress gateway pods: %v", err),
+				},
+			},
+		}, nil
+	}
+	if len(gatewayPods) == 0 && params.InstallGateway {
+		if err := m.installEgressGateway(ctx, params.IstioNamespace, params.GatewayNamespace, params.RepoURL, params.Timeout); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to install the egress gateway: %v", err),
+					},
+				},
+			}, nil
+		}
+		result.GatewayInstalled = true
+		if err := m.waitForPodsReady(ctx, params.GatewayNamespace, []string{params.GatewaySelector}, timeout); err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Egress gateway did not become ready: %v", err))
+		}
+		gatewayPods, _ = m.runningPods(ctx, params.GatewayNamespace, params.GatewaySelector)
+	}
+	if len(gatewayPods) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No running egress gateway pods match %s in namespace %s; set install_gateway to install one", params.GatewaySelector, params.GatewayNamespace),
+				},
+			},
+		}, nil
+	}
+	for _, pod := range gatewayPods {
+		result.GatewayPods = append(result.GatewayPods, pod.Name)
+	}
+	if _, err := m.k8sClient.Kubernetes.CoreV1().Services(params.GatewayNamespace).Get(ctx, params.GatewayService, metav1.GetOptions{}); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get egress gateway service %s/%s: %v", params.GatewayNamespace, params.GatewayService, err),
+				},
+			},
+		}, nil
+	}
+
+	// Apply the routing resources
+	gatewayHost := fmt.Sprintf("%s.%s.svc.cluster.local", params.GatewayService, params.GatewayNamespace)
+	resources := egressRoutingResources(params.Name, params.Namespace, params.Protocol, port, params.Hosts, gatewayHost, map[string]interface{}{selectorKey: selectorValue})
+	for _, obj := range resources {
+		if err := m.applyResource(ctx, istioResourceGVR(obj), obj); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to apply %s %s: %v", obj.GetKind(), obj.GetName(), err),
+					},
+				},
+			}, nil
+		}
+		result.Resources = append(result.Resources, fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName()))
+	}
+
+	if !*params.Verify {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	source, err := m.findWaypointSource(ctx, params.SourceNamespace, params.SourcePod)
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Could not verify egress routing: %v", err))
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+	result.Source = fmt.Sprintf("%s/%s", source.Namespace, source.Name)
+	if !podHasSidecar(source) {
+		result.Issues = append(result.Issues, fmt.Sprintf("Source pod %s has no sidecar; its traffic bypasses the mesh and never reaches the egress gateway", result.Source))
+	}
+
+	var mesh struct {
+		AccessLogFile string `json:"accessLogFile"`
+	}
+	if err := m.readMeshConfig(ctx, params.IstioNamespace, params.Revision, &mesh); err == nil && mesh.AccessLogFile == "" {
+		result.Notes = append(result.Notes, "meshConfig.accessLogFile is not set, so the gateway writes no access logs; traffic is confirmed from gateway stats only")
+	}
+
+	for _, host := range params.Hosts {
+		verification := m.verifyEgressHost(ctx, source, gatewayPods, params.Protocol, host, port)
+		result.Verification = append(result.Verification, verification)
+		switch {
+		case verification.ThroughGateway:
+		case verification.ResponseCode == "000" || verification.ResponseCode == "":
+			result.Issues = append(result.Issues, fmt.Sprintf("Requests to %s got no response; check the gateway logs and whether the host is reachable from the cluster", verification.URL))
+		default:
+			result.Issues = append(result.Issues, fmt.Sprintf("Requests to %s were answered (%s) but the egress gateway did not carry them; traffic is leaving the mesh directly", verification.URL, verification.ResponseCode))
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// egressRoutingResources builds the ServiceEntry, Gateway and DestinationRule shared by all hosts and
+// one VirtualService per host sending sidecar traffic to the gateway and gateway traffic to the host
+func egressRoutingResources(name, namespace, protocol string, port int, hosts []string, gatewayHost string, selector map[string]interface{}) []*unstructured.Unstructured {
+	hostList := make([]interface{}, 0, len(hosts))
+	for _, host := range hosts {
+		hostList = append(hostList, host)
+	}
+	portName := fmt.Sprintf("%s-%d", protocol, port)
+
+	server := map[string]interface{}{
+		"port":  map[string]interface{}{"number": int64(port), "name": portName, "protocol": strings.ToUpper(protocol)},
+		"hosts": hostList,
+	}
+	if protocol == "tls" {
+		server["tls"] = map[string]interface{}{"mode": "PASSTHROUGH"}
+	}
+
+	resources := []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "ServiceEntry",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec": map[string]interface{}{
+				"hosts":      hostList,
+				"ports":      []interface{}{map[string]interface{}{"number": int64(port), "name": portName, "protocol": strings.ToUpper(protocol)}},
+				"resolution": "DNS",
+				"location":   "MESH_EXTERNAL",
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "Gateway",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec": map[string]interface{}{
+				"selector": selector,
+				"servers":  []interface{}{server},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "DestinationRule",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec": map[string]interface{}{
+				"host":    gatewayHost,
+				"subsets": []interface{}{map[string]interface{}{"name": name}},
+			},
+		}},
+	}
+
+	for _, host := range hosts {
+		toGateway := map[string]interface{}{
+			"match": []interface{}{egressRouteMatch(protocol, "mesh", port, host)},
+			"route": []interface{}{map[string]interface{}{
+				"destination": map[string]interface{}{"host": gatewayHost, "subset": name, "port": map[string]interface{}{"number": int64(port)}},
+			}},
+		}
+		toHost := map[string]interface{}{
+			"match": []interface{}{egressRouteMatch(protocol, name, port, host)},
+			"route": []interface{}{map[string]interface{}{
+				"destination": map[string]interface{}{"host": host, "port": map[string]interface{}{"number": int64(port)}},
+			}},
+		}
+		resources = append(resources, &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "VirtualService",
+			"metadata":   map[string]interface{}{"name": fmt.Sprintf("%s-%s", name, strings.ReplaceAll(host, ".", "-")), "namespace": namespace},
+			"spec": map[string]interface{}{
+				"hosts":    []interface{}{host},
+				"gateways": []interface{}{name, "mesh"},
+				protocol:   []interface{}{toGateway, toHost},
+			},
+		}})
+	}
+	return resources
+}
+
+// egressRouteMatch matches traffic for a host arriving at the given gateway (or the sidecars, for mesh)
+func egressRouteMatch(protocol, gateway string, port int, host string) map[string]interface{} {
+	match := map[string]interface{}{
+		"gateways": []interface{}{gateway},
+		"port":     int64(port),
+	}
+	if protocol == "tls" {
+		match["sniHosts"] = []interface{}{host}
+	}
+	return match
+}
+
+// verifyEgressHost sends requests to an external host until the egress gateway reports carrying one,
+// counting gateway upstream connections and access log entries for the host
+func (m *Manager) verifyEgressHost(ctx context.Context, source *corev1.Pod, gatewayPods []corev1.Pod, protocol, host string, port int) EgressHostVerification {
+	url := fmt.Sprintf("http://%s/", host)
+	if protocol == "tls" {
+		url = fmt.Sprintf("https://%s/", host)
+	}
+	verification := EgressHostVerification{Host: host, URL: url}
+
+	before := make(map[string]int)
+	for _, pod := range gatewayPods {
+		if count, err := m.egressGatewayConnections(ctx, &pod, host, port); err == nil {
+			before[pod.Name] = count
+		}
+	}
+
+	start := time.Now()
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, configPropagationTimeout, true, func(ctx context.Context) (bool, error) {
+		code, _, err := m.curlFromPod(ctx, source, url, nil, 10)
+		if err != nil {
+			return false, nil
+		}
+		verification.ResponseCode = code
+
+		verification.GatewayConns, verification.AccessLogMatches = 0, 0
+		for _, pod := range gatewayPods {
+			if previous, ok := before[pod.Name]; ok {
+				if count, err := m.egressGatewayConnections(ctx, &pod, host, port); err == nil {
+					verification.GatewayConns += count - previous
+				}
+			}
+
+			sinceSeconds := int64(time.Since(start).Seconds()) + 5
+			logs, err := m.k8sClient.Kubernetes.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+				SinceSeconds: &sinceSeconds,
+			}).Do(ctx).Raw()
+			if err != nil {
+				continue
+			}
+			scanner := bufio.NewScanner(strings.NewReader(string(logs)))
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				if strings.Contains(scanner.Text(), host) {
+					verification.AccessLogMatches++
+				}
+			}
+		}
+		verification.ThroughGateway = verification.GatewayConns > 0 || verification.AccessLogMatches > 0
+		return verification.ThroughGateway, nil
+	})
+	if err == nil {
+		verification.WaitedFor = time.Since(start).Round(time.Second).String()
+	}
+	return verification
+}
+
+// egressGatewayConnections reads the gateway's upstream connection counter for an external host
+func (m *Manager) egressGatewayConnections(ctx context.Context, pod *corev1.Pod, host string, port int) (int, error) {
+	body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/stats?filter=upstream_cx_total$")
+	if err != nil {
+		return 0, err
+	}
+
+	// Gateway clusters for an external host are named outbound|<port>||<host>
+	statName := fmt.Sprintf("cluster.outbound|%d||%s.upstream_cx_total", port, host)
+	total := 0
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ": ")
+		if !found || name != statName {
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(value))
+		if err == nil {
+			total += count
+		}
+	}
+	return total, nil
+}
+
+// runningPods lists the running, non-terminating pods matching a label selector
+func (m *Manager) runningPods(ctx context.Context, namespace, selector string) ([]corev1.Pod, error) {
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return nil, err
+	}
+	var running []corev1.Pod
+	for _, pod := range pods.Items {
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			running = append(running, pod)
+		}
+	}
+	return running, nil
+}
+
+// installEgressGateway installs the Istio gateway chart as a ClusterIP egress gateway, pinned to
+// the chart version of the istiod release
+func (m *Manager) installEgressGateway(ctx context.Context, istioNamespace, namespace, repoURL, timeout string) error {
+	if err := m.checkHelmAvailable(); err != nil {
+		return fmt.Errorf("helm is not available: %w", err)
+	}
+	repo := resolveChartRepository(m.config.Helm.Istio, repoURL)
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		return fmt.Errorf("failed to add Istio Helm repository: %w", err)
+	}
+
+	request := helmChartRequest{
+		Release:         egressGatewayRelease,
+		Chart:           repo.ChartRef("gateway"),
+		Namespace:       namespace,
+		Values:          map[string]interface{}{"service.type": "ClusterIP"},
+		CreateNamespace: true,
+		Wait:            true,
+		Timeout:         timeout,
+		Upgrade:         true,
+	}
+	if istiod, err := m.getHelmReleaseInfo(istioNamespace, "istiod"); err == nil {
+		if version := strings.TrimPrefix(istiod.Chart, "istiod-"); version != istiod.Chart {
+			request.Version = version
+		}
+	}
+
+	release, err := m.installHelmChart(ctx, request)
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("Egress gateway installed: %s", release)
+	return nil
+}
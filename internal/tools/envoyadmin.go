@@ -0,0 +1,201 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultEnvoyAdminBytes is the response size envoy_admin_get returns unless asked for more
+	defaultEnvoyAdminBytes = 64 * 1024
+	// maxEnvoyAdminBytes caps the response size envoy_admin_get returns
+	maxEnvoyAdminBytes = 1024 * 1024
+)
+
+// envoyAdminEndpoints lists the read-only Envoy admin endpoints envoy_admin_get may call and the query
+// parameters each accepts; mutating endpoints such as /logging, /quitquitquit or /reset_counters are never reachable
+var envoyAdminEndpoints = map[string][]string{
+	"/stats":       {"filter", "format", "usedonly", "histogram_buckets"},
+	"/clusters":    {"format"},
+	"/config_dump": {"resource", "mask", "name_regex", "include_eds"},
+	"/certs":       {},
+	"/listeners":   {"format"},
+}
+
+// EnvoyAdminResponse is a response from a proxy's Envoy admin interface
+type EnvoyAdminResponse struct {
+	Pod       string          `json:"pod"`
+	Path      string          `json:"path"`
+	Bytes     int             `json:"bytes"`               // size of the response, or of the part read when truncated
+	Truncated bool            `json:"truncated,omitempty"` // the response was cut at max_bytes
+	JSON      json.RawMessage `json:"json,omitempty"`      // the response, when it is complete JSON
+	Text      string          `json:"text,omitempty"`      // the response otherwise
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// envoyAdminGetParams are the arguments of envoy_admin_get
+type envoyAdminGetParams struct {
+	PodName   string            `json:"pod_name"`
+	Namespace string            `json:"namespace,omitempty"` // default: default
+	Endpoint  string            `json:"endpoint"`            // one of envoyAdminEndpoints
+	Query     map[string]string `json:"query,omitempty"`     // query parameters allowed for the endpoint
+	MaxBytes  int               `json:"max_bytes,omitempty"` // default: 65536
+}
+
+// EnvoyAdminGet reads a whitelisted, read-only Envoy admin endpoint of a sidecar, gateway or waypoint,
+// for the admin data no dedicated tool covers
+func (m *Manager) EnvoyAdminGet(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params envoyAdminGetParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.PodName == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "pod_name is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.MaxBytes == 0 {
+		params.MaxBytes = defaultEnvoyAdminBytes
+	}
+
+	endpoint := "/" + strings.TrimPrefix(params.Endpoint, "/")
+	allowed, ok := envoyAdminEndpoints[endpoint]
+	if !ok {
+		endpoints := make([]string, 0, len(envoyAdminEndpoints))
+		for name := range envoyAdminEndpoints {
+			endpoints = append(endpoints, name)
+		}
+		sort.Strings(endpoints)
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Endpoint %q is not allowed: must be one of %s", params.Endpoint, strings.Join(endpoints, ", ")),
+				},
+			},
+		}, nil
+	}
+	if params.MaxBytes < 0 || params.MaxBytes > maxEnvoyAdminBytes {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid max_bytes %d: must be between 1 and %d", params.MaxBytes, maxEnvoyAdminBytes),
+				},
+			},
+		}, nil
+	}
+
+	query := url.Values{}
+	for key, value := range params.Query {
+		if !containsString(allowed, key) {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Query parameter %q is not allowed for %s (allowed: %s)", key, endpoint, strings.Join(allowed, ", ")),
+					},
+				},
+			}, nil
+		}
+		query.Set(key, value)
+	}
+	path := endpoint
+	if len(query) > 0 {
+		path += "?" + query.Encode()
+	}
+
+	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get pod: %v", err),
+				},
+			},
+		}, nil
+	}
+	if !podHasSidecar(pod) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Pod %s/%s has no istio-proxy container; for ambient workloads use get_ztunnel_config", params.Namespace, params.PodName),
+				},
+			},
+		}, nil
+	}
+
+	body, truncated, err := m.portForwardGetLimited(ctx, pod.Namespace, pod.Name, 15000, path, params.MaxBytes)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read %s from the Envoy admin interface: %v", path, err),
+				},
+			},
+		}, nil
+	}
+
+	result := &EnvoyAdminResponse{
+		Pod:       fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+		Path:      path,
+		Bytes:     len(body),
+		Timestamp: time.Now(),
+	}
+	switch {
+	case truncated:
+		result.Truncated = true
+		result.Text = string(body)
+	case json.Valid(body):
+		result.JSON = json.RawMessage(body)
+	default:
+		result.Text = string(body)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
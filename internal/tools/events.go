@@ -0,0 +1,300 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+// KubernetesEvent is one Event, with repeats folded into Count by the API server
+type KubernetesEvent struct {
+	LastSeen  time.Time  `json:"last_seen"`
+	FirstSeen *time.Time `json:"first_seen,omitempty"`
+	Type      string     `json:"type"` // Warning or Normal
+	Reason    string     `json:"reason"`
+	Object    string     `json:"object"` // Kind/name of the involved object
+	Namespace string     `json:"namespace"`
+	Message   string     `json:"message"`
+	Count     int32      `json:"count,omitempty"`
+	Source    string     `json:"source,omitempty"` // component that reported the event
+}
+
+// KubernetesEventList is the result of get_kubernetes_events, newest first
+type KubernetesEventList struct {
+	Namespace string            `json:"namespace"`
+	Filter    string            `json:"filter,omitempty"`
+	Warnings  int               `json:"warnings"`
+	Normal    int               `json:"normal"`
+	Truncated bool              `json:"truncated,omitempty"`
+	Events    []KubernetesEvent `json:"events"`
+}
+
+// getKubernetesEventsParams are the arguments of get_kubernetes_events
+type getKubernetesEventsParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: default
+	PodName        string `json:"pod_name,omitempty"`        // events of one pod
+	Kind           string `json:"kind,omitempty"`            // involved object kind, e.g. Deployment
+	Name           string `json:"name,omitempty"`            // involved object name
+	Component      string `json:"component,omitempty"`       // istiod, gateway, ztunnel or cni
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system; searched for component
+	Type           string `json:"type,omitempty"`            // Warning or Normal
+	Since          string `json:"since,omitempty"`           // only events last seen within this duration
+	MaxEvents      int    `json:"max_events,omitempty"`      // default: 100
+}
+
+// GetKubernetesEvents lists the Events of a namespace, pod, object or Istio component, newest first, split into
+// Warning and Normal
+func (m *Manager) GetKubernetesEvents(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getKubernetesEventsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.MaxEvents == 0 {
+		params.MaxEvents = 100
+	}
+
+	var invalid string
+	switch {
+	case params.Component != "" && (params.PodName != "" || params.Kind != "" || params.Name != ""):
+		invalid = "component cannot be combined with pod_name, kind or name"
+	case params.PodName != "" && (params.Kind != "" || params.Name != ""):
+		invalid = "pod_name cannot be combined with kind or name"
+	case params.Component != "" && (params.Component == "samples" || meshImageSources[params.Component] == ""):
+		invalid = fmt.Sprintf("unknown component %q: use istiod, gateway, ztunnel or cni", params.Component)
+	case params.Type != "" && !strings.EqualFold(params.Type, corev1.EventTypeWarning) && !strings.EqualFold(params.Type, corev1.EventTypeNormal):
+		invalid = fmt.Sprintf("unknown event type %q: use Warning or Normal", params.Type)
+	case params.MaxEvents < 0:
+		invalid = "max_events must be positive"
+	}
+	if invalid != "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %s", invalid),
+				},
+			},
+		}, nil
+	}
+
+	var since time.Time
+	if params.Since != "" {
+		duration, err := time.ParseDuration(params.Since)
+		if err != nil || duration <= 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid since %q: must be a positive duration such as 30m", params.Since),
+					},
+				},
+			}, nil
+		}
+		since = time.Now().Add(-duration)
+	}
+
+	// The API server filters on the involved object and type; a component spans several objects, so its events
+	// are matched below
+	namespace := params.Namespace
+	selector := fields.Set{}
+	var filter string
+	switch {
+	case params.Component != "":
+		namespace = params.IstioNamespace
+		filter = "component=" + params.Component
+	case params.PodName != "":
+		selector["involvedObject.kind"] = "Pod"
+		selector["involvedObject.name"] = params.PodName
+		filter = "Pod/" + params.PodName
+	case params.Kind != "" || params.Name != "":
+		if params.Kind != "" {
+			selector["involvedObject.kind"] = params.Kind
+		}
+		if params.Name != "" {
+			selector["involvedObject.name"] = params.Name
+		}
+		filter = params.Kind + "/" + params.Name
+	}
+	if params.Type != "" {
+		selector["type"] = strings.ToUpper(params.Type[:1]) + strings.ToLower(params.Type[1:])
+	}
+
+	var objects map[string]bool
+	if params.Component != "" {
+		var err error
+		objects, err = m.componentObjects(ctx, namespace, meshImageSources[params.Component])
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to find %s objects in %s: %v", params.Component, namespace, err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	events, err := m.k8sClient.Kubernetes.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: selector.AsSelector().String()})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list events in %s: %v", namespace, err),
+				},
+			},
+		}, nil
+	}
+
+	result := &KubernetesEventList{
+		Namespace: namespace,
+		Filter:    filter,
+		Events:    []KubernetesEvent{},
+	}
+	for _, event := range events.Items {
+		object := event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name
+		if objects != nil && !objects[object] {
+			continue
+		}
+		lastSeen := eventLastSeen(&event)
+		if !since.IsZero() && lastSeen.Before(since) {
+			continue
+		}
+		source := event.Source.Component
+		if source == "" {
+			source = event.ReportingController
+		}
+		entry := KubernetesEvent{
+			LastSeen:  lastSeen,
+			Type:      event.Type,
+			Reason:    event.Reason,
+			Object:    object,
+			Namespace: event.Namespace,
+			Message:   event.Message,
+			Count:     event.Count,
+			Source:    source,
+		}
+		if !event.FirstTimestamp.IsZero() {
+			firstSeen := event.FirstTimestamp.Time
+			entry.FirstSeen = &firstSeen
+		}
+		result.Events = append(result.Events, entry)
+		if event.Type == corev1.EventTypeWarning {
+			result.Warnings++
+		} else {
+			result.Normal++
+		}
+	}
+
+	sort.SliceStable(result.Events, func(i, j int) bool {
+		return result.Events[i].LastSeen.After(result.Events[j].LastSeen)
+	})
+	if len(result.Events) > params.MaxEvents {
+		result.Events = result.Events[:params.MaxEvents]
+		result.Truncated = true
+	}
+
+	resultJSON, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal result: %w", err)
+	}
+
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// componentObjects returns the Kind/name of the pods, ReplicaSets, Deployments and DaemonSets matching a component's
+// label selector, so events about pods that were never created are found through their controllers
+func (m *Manager) componentObjects(ctx context.Context, namespace, selector string) (map[string]bool, error) {
+	options := metav1.ListOptions{LabelSelector: selector}
+	objects := make(map[string]bool)
+
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, pod := range pods.Items {
+		objects["Pod/"+pod.Name] = true
+		for _, owner := range pod.OwnerReferences {
+			objects[owner.Kind+"/"+owner.Name] = true
+		}
+	}
+
+	replicaSets, err := m.k8sClient.Kubernetes.AppsV1().ReplicaSets(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, replicaSet := range replicaSets.Items {
+		objects["ReplicaSet/"+replicaSet.Name] = true
+		for _, owner := range replicaSet.OwnerReferences {
+			objects[owner.Kind+"/"+owner.Name] = true
+		}
+	}
+
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, deployment := range deployments.Items {
+		objects["Deployment/"+deployment.Name] = true
+	}
+
+	daemonSets, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets(namespace).List(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+	for _, daemonSet := range daemonSets.Items {
+		objects["DaemonSet/"+daemonSet.Name] = true
+	}
+	return objects, nil
+}
+
+// eventLastSeen returns when an event last occurred, whichever of the legacy and events.k8s.io fields is set
+func eventLastSeen(event *corev1.Event) time.Time {
+	seen := event.LastTimestamp.Time
+	if event.Series != nil && event.Series.LastObservedTime.Time.After(seen) {
+		seen = event.Series.LastObservedTime.Time
+	}
+	if event.EventTime.Time.After(seen) {
+		seen = event.EventTime.Time
+	}
+	if seen.IsZero() {
+		seen = event.CreationTimestamp.Time
+	}
+	return seen
+}
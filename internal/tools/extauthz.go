@@ -0,0 +1,640 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// extAuthzImage is Istio's sample external authorizer; it allows requests carrying
+	// x-ext-authz: allow and denies everything else
+	extAuthzImage = "gcr.io/istio-testing/ext-authz:latest"
+	// extAuthzHTTPPort and extAuthzGRPCPort are the ports the sample authorizer serves on
+	extAuthzHTTPPort = 8000
+	extAuthzGRPCPort = 9000
+)
+
+// ExtAuthzVerification is the outcome of sending allowed and denied requests through ext_authz
+type ExtAuthzVerification struct {
+	URL          string `json:"url"`
+	Source       string `json:"source"`
+	AllowedCode  string `json:"allowed_code"` // with x-ext-authz: allow, expect 200
+	DeniedCode   string `json:"denied_code"`  // with x-ext-authz: deny, expect 403
+	CheckResult  string `json:"check_result,omitempty"`
+	Passed       bool   `json:"passed"`
+	WaitedFor    string `json:"waited_for,omitempty"`
+	FailedReason string `json:"failed_reason,omitempty"`
+}
+
+// ExtAuthzSetupResult is the result of setting up ext_authz for a workload
+type ExtAuthzSetupResult struct {
+	Provider        string                 `json:"provider"`
+	ProviderConfig  map[string]interface{} `json:"provider_config"`
+	ProviderUpdated bool                   `json:"provider_updated"`
+	Authorizer      string                 `json:"authorizer"`
+	Policy          string                 `json:"policy"`
+	Verification    *ExtAuthzVerification  `json:"verification,omitempty"`
+	Issues          []string               `json:"issues,omitempty"`
+	Notes           []string               `json:"notes,omitempty"`
+	Timestamp       time.Time              `json:"timestamp"`
+}
+
+// ExtAuthzTestResult is the result of checking ext_authz for a workload end to end
+type ExtAuthzTestResult struct {
+	Provider           string                 `json:"provider"`
+	ProviderConfigured bool                   `json:"provider_configured"`
+	ProviderConfig     map[string]interface{} `json:"provider_config,omitempty"`
+	Policies           []string               `json:"policies"` // CUSTOM policies in the namespace using the provider
+	Verification       *ExtAuthzVerification  `json:"verification,omitempty"`
+	Issues             []string               `json:"issues,omitempty"`
+	Timestamp          time.Time              `json:"timestamp"`
+}
+
+// setupExtAuthzParams are the arguments of setup_ext_authz
+type setupExtAuthzParams struct {
+	Namespace      string   `json:"namespace,omitempty"`       // namespace of the workload and authorizer, default: default
+	Workload       string   `json:"workload,omitempty"`        // app label of the workload to protect, default: httpbin
+	Provider       string   `json:"provider,omitempty"`        // default: sample-ext-authz-http or sample-ext-authz-grpc
+	Protocol       string   `json:"protocol,omitempty"`        // http or grpc, default: http
+	Paths          []string `json:"paths,omitempty"`           // paths sent to the authorizer, default: all
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Release        string   `json:"release,omitempty"`         // istiod Helm release, default: istiod
+	Revision       string   `json:"revision,omitempty"`        // control plane revision
+	RepoURL        string   `json:"repo_url,omitempty"`        // chart repository override
+	Verify         *bool    `json:"verify,omitempty"`          // default: true
+	Timeout        string   `json:"timeout,omitempty"`         // default: 5m
+}
+
+// SetupExtAuthz deploys the sample external authorizer, registers it as a meshConfig extension
+// provider and protects a workload with a CUSTOM AuthorizationPolicy, then verifies allow and deny
+func (m *Manager) SetupExtAuthz(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params setupExtAuthzParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Workload == "" {
+		params.Workload = "httpbin"
+	}
+	if params.Protocol == "" {
+		params.Protocol = "http"
+	}
+	if params.Protocol != "http" && params.Protocol != "grpc" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid protocol %q: must be http or grpc", params.Protocol),
+				},
+			},
+		}, nil
+	}
+	if params.Provider == "" {
+		params.Provider = "sample-ext-authz-" + params.Protocol
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Verify == nil {
+		params.Verify = boolPtr(true)
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "setup_ext_authz")
+
+	result := &ExtAuthzSetupResult{
+		Provider:   params.Provider,
+		Authorizer: fmt.Sprintf("%s/ext-authz", params.Namespace),
+		Policy:     fmt.Sprintf("%s/ext-authz-%s", params.Namespace, params.Workload),
+		Timestamp:  time.Now(),
+	}
+
+	// Deploy the sample authorizer
+	if err := m.createExtAuthzDeployment(ctx, params.Namespace); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to deploy ext-authz: %v", err),
+				},
+			},
+		}, nil
+	}
+	if err := m.createExtAuthzService(ctx, params.Namespace); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to deploy ext-authz: %v", err),
+				},
+			},
+		}, nil
+	}
+	if err := m.waitForPodsReady(ctx, params.Namespace, []string{"app=ext-authz"}, timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("ext-authz did not become ready: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Register the authorizer as an extension provider, replacing any provider with the same name
+	provider := map[string]interface{}{"name": params.Provider}
+	service := fmt.Sprintf("ext-authz.%s.svc.cluster.local", params.Namespace)
+	if params.Protocol == "grpc" {
+		provider["envoyExtAuthzGrpc"] = map[string]interface{}{
+			"service": service,
+			"port":    extAuthzGRPCPort,
+		}
+	} else {
+		provider["envoyExtAuthzHttp"] = map[string]interface{}{
+			"service":                      service,
+			"port":                         extAuthzHTTPPort,
+			"includeRequestHeadersInCheck": []interface{}{"x-ext-authz"},
+		}
+	}
+	result.ProviderConfig = provider
+
+	providers, err := m.meshExtensionProviders(ctx, params.IstioNamespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+	var updated []map[string]interface{}
+	updated, result.ProviderUpdated = mergeExtensionProvider(providers, provider)
+	if result.ProviderUpdated {
+		if err := m.setIstiodMeshConfig(ctx, params.IstioNamespace, params.Release, params.RepoURL, "extensionProviders", updated, params.Timeout); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to register extension provider: %v", err),
+					},
+				},
+			}, nil
+		}
+	} else {
+		result.Notes = append(result.Notes, fmt.Sprintf("Extension provider %s was already configured", params.Provider))
+	}
+
+	// Protect the workload with a CUSTOM policy delegating to the provider
+	rule := map[string]interface{}{}
+	if len(params.Paths) > 0 {
+		paths := make([]interface{}, 0, len(params.Paths))
+		for _, path := range params.Paths {
+			paths = append(paths, path)
+		}
+		rule["to"] = []interface{}{map[string]interface{}{
+			"operation": map[string]interface{}{"paths": paths},
+		}}
+	}
+	policy := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "security.istio.io/v1beta1",
+		"kind":       "AuthorizationPolicy",
+		"metadata": map[string]interface{}{
+			"name":      "ext-authz-" + params.Workload,
+			"namespace": params.Namespace,
+		},
+		"spec": map[string]interface{}{
+			"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": params.Workload}},
+			"action":   "CUSTOM",
+			"provider": map[string]interface{}{"name": params.Provider},
+			"rules":    []interface{}{rule},
+		},
+	}}
+	if err := m.applyResource(ctx, authorizationPolicyGVRs[1], policy); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply AuthorizationPolicy: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if *params.Verify {
+		path := "/headers"
+		if len(params.Paths) > 0 {
+			path = strings.TrimSuffix(params.Paths[0], "*")
+		}
+		verification, err := m.verifyExtAuthz(ctx, params.Namespace, params.Workload, "", 0, path, time.Minute)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Could not verify ext_authz: %v", err))
+		} else {
+			result.Verification = verification
+			if !verification.Passed {
+				result.Issues = append(result.Issues, verification.FailedReason)
+			}
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// testExtAuthzParams are the arguments of test_ext_authz
+type testExtAuthzParams struct {
+	Namespace       string `json:"namespace,omitempty"`        // default: default
+	Service         string `json:"service,omitempty"`          // default: httpbin
+	Port            int    `json:"port,omitempty"`             // default: first service port
+	Path            string `json:"path,omitempty"`             // default: /headers
+	Provider        string `json:"provider,omitempty"`         // default: sample-ext-authz-http
+	SourcePod       string `json:"source_pod,omitempty"`       // default: first app=sleep pod
+	SourceNamespace string `json:"source_namespace,omitempty"` // default: namespace
+	IstioNamespace  string `json:"istio_namespace,omitempty"`  // default: istio-system
+	Revision        string `json:"revision,omitempty"`         // control plane revision
+	Timeout         int    `json:"timeout,omitempty"`          // seconds to wait for the expected behaviour, default: 30
+}
+
+// TestExtAuthz checks that an extension provider is configured, a CUSTOM policy uses it, and requests
+// to the workload are allowed or denied by the external authorizer
+func (m *Manager) TestExtAuthz(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params testExtAuthzParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Service == "" {
+		params.Service = "httpbin"
+	}
+	if params.Path == "" {
+		params.Path = "/headers"
+	}
+	if params.Provider == "" {
+		params.Provider = "sample-ext-authz-http"
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 30
+	}
+
+	result := &ExtAuthzTestResult{
+		Provider:  params.Provider,
+		Policies:  []string{},
+		Timestamp: time.Now(),
+	}
+
+	providers, err := m.meshExtensionProviders(ctx, params.IstioNamespace, params.Revision)
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to read mesh config: %v", err))
+	}
+	for _, provider := range providers {
+		if provider["name"] == params.Provider {
+			result.ProviderConfigured = true
+			result.ProviderConfig = provider
+		}
+	}
+	if err == nil && !result.ProviderConfigured {
+		result.Issues = append(result.Issues, fmt.Sprintf("Extension provider %s is not in meshConfig.extensionProviders; CUSTOM policies using it reject every request", params.Provider))
+	}
+
+	list, err := m.k8sClient.Dynamic.Resource(authorizationPolicyGVRs[1]).Namespace(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to list AuthorizationPolicies: %v", err))
+	} else {
+		for _, item := range list.Items {
+			action, _, _ := unstructured.NestedString(item.Object, "spec", "action")
+			provider, _, _ := unstructured.NestedString(item.Object, "spec", "provider", "name")
+			if action == "CUSTOM" && provider == params.Provider {
+				result.Policies = append(result.Policies, item.GetName())
+			}
+		}
+		if len(result.Policies) == 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("No CUSTOM AuthorizationPolicy in %s uses provider %s", params.Namespace, params.Provider))
+		}
+	}
+
+	verification, err := m.verifyExtAuthz(ctx, params.Namespace, params.Service, params.SourceNamespace+"/"+params.SourcePod, params.Port, params.Path, time.Duration(params.Timeout)*time.Second)
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Could not verify ext_authz: %v", err))
+	} else {
+		result.Verification = verification
+		if !verification.Passed {
+			result.Issues = append(result.Issues, verification.FailedReason)
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// verifyExtAuthz sends requests with x-ext-authz: allow and deny from a sleep pod until they are
+// answered with 200 and 403 or the timeout passes; source is namespace/pod, with an empty pod
+// meaning the first sleep pod in the service's namespace
+func (m *Manager) verifyExtAuthz(ctx context.Context, namespace, service, source string, port int, path string, timeout time.Duration) (*ExtAuthzVerification, error) {
+	sourceNamespace, sourcePod := namespace, ""
+	if source != "" {
+		sourceNamespace, sourcePod, _ = strings.Cut(source, "/")
+	}
+	client, err := m.findWaypointSource(ctx, sourceNamespace, sourcePod)
+	if err != nil {
+		return nil, err
+	}
+	if port == 0 {
+		svc, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Get(ctx, service, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get service %s/%s: %w", namespace, service, err)
+		}
+		if len(svc.Spec.Ports) == 0 {
+			return nil, fmt.Errorf("service %s/%s has no ports", namespace, service)
+		}
+		port = int(svc.Spec.Ports[0].Port)
+	}
+
+	verification := &ExtAuthzVerification{
+		URL:    fmt.Sprintf("http://%s.%s.svc.cluster.local:%d%s", service, namespace, port, path),
+		Source: client.Namespace + "/" + client.Name,
+	}
+	start := time.Now()
+	err = wait.PollUntilContextTimeout(ctx, 3*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		allowed, headers, err := m.curlFromPod(ctx, client, verification.URL, map[string]string{"x-ext-authz": "allow"}, 10)
+		if err != nil {
+			return false, err
+		}
+		denied, deniedHeaders, err := m.curlFromPod(ctx, client, verification.URL, map[string]string{"x-ext-authz": "deny"}, 10)
+		if err != nil {
+			return false, err
+		}
+		verification.AllowedCode, verification.DeniedCode = allowed, denied
+		verification.CheckResult = extAuthzCheckResult(headers + deniedHeaders)
+		return allowed == "200" && denied == "403", nil
+	})
+	verification.WaitedFor = time.Since(start).Round(time.Second).String()
+	if err != nil && verification.AllowedCode == "" {
+		return nil, err
+	}
+
+	verification.Passed = err == nil
+	switch {
+	case verification.Passed:
+	case verification.AllowedCode == "403" && verification.DeniedCode == "403":
+		verification.FailedReason = "Both requests were denied; the provider may be missing from meshConfig or the authorizer unreachable (check the istio-proxy logs for ext_authz errors)"
+	case verification.AllowedCode == "200" && verification.DeniedCode == "200":
+		verification.FailedReason = "Both requests were allowed; no CUSTOM policy applies to the workload for this path"
+	default:
+		verification.FailedReason = fmt.Sprintf("Expected 200 for the allowed request and 403 for the denied one, got %s and %s", verification.AllowedCode, verification.DeniedCode)
+	}
+	return verification, nil
+}
+
+// extAuthzCheckResult collects the decisions the sample authorizer reports in its response headers
+func extAuthzCheckResult(headers string) string {
+	var results []string
+	for _, line := range strings.Split(headers, "\n") {
+		name, value, found := strings.Cut(line, ":")
+		if found && strings.EqualFold(strings.TrimSpace(name), "x-ext-authz-check-result") {
+			results = append(results, strings.TrimSpace(value))
+		}
+	}
+	return strings.Join(results, ", ")
+}
+
+// mergeExtensionProvider replaces the provider with the same name or appends it, reporting whether
+// the list changed
+func mergeExtensionProvider(providers []map[string]interface{}, provider map[string]interface{}) ([]map[string]interface{}, bool) {
+	merged := make([]map[string]interface{}, 0, len(providers)+1)
+	changed, found := false, false
+	for _, existing := range providers {
+		if existing["name"] != provider["name"] {
+			merged = append(merged, existing)
+			continue
+		}
+		found = true
+		// Compare through JSON so numbers decoded from the configmap match the ints set here
+		existingJSON, _ := json.Marshal(existing)
+		providerJSON, _ := json.Marshal(provider)
+		if string(existingJSON) != string(providerJSON) {
+			changed = true
+		}
+		merged = append(merged, provider)
+	}
+	if !found {
+		merged = append(merged, provider)
+		changed = true
+	}
+	return merged, changed
+}
+
+// applyResource creates a custom resource or replaces the existing one; cluster-scoped objects have no namespace
+func (m *Manager) applyResource(ctx context.Context, gvr schema.GroupVersionResource, obj *unstructured.Unstructured) error {
+	markManaged(ctx, obj)
+	resources := m.k8sClient.Dynamic.Resource(gvr).Namespace(obj.GetNamespace())
+	existing, err := resources.Get(ctx, obj.GetName(), metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = resources.Create(ctx, obj, metav1.CreateOptions{})
+	case err == nil:
+		obj.SetResourceVersion(existing.GetResourceVersion())
+		_, err = resources.Update(ctx, obj, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// meshExtensionProviders reads meshConfig.extensionProviders from the mesh configmap
+func (m *Manager) meshExtensionProviders(ctx context.Context, namespace, revision string) ([]map[string]interface{}, error) {
+	var mesh struct {
+		ExtensionProviders []map[string]interface{} `json:"extensionProviders"`
+	}
+	if err := m.readMeshConfig(ctx, namespace, revision, &mesh); err != nil {
+		return nil, err
+	}
+	return mesh.ExtensionProviders, nil
+}
+
+func (m *Manager) createExtAuthzDeployment(ctx context.Context, namespace string) error {
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ext-authz",
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": "ext-authz",
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": "ext-authz",
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": "ext-authz",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            "ext-authz",
+							Image:           extAuthzImage,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Ports: []corev1.ContainerPort{
+								{
+									ContainerPort: extAuthzHTTPPort,
+									Name:          "http",
+									Protocol:      corev1.ProtocolTCP,
+								},
+								{
+									ContainerPort: extAuthzGRPCPort,
+									Name:          "grpc",
+									Protocol:      corev1.ProtocolTCP,
+								},
+							},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("10m"),
+									corev1.ResourceMemory: resource.MustParse("32Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("100m"),
+									corev1.ResourceMemory: resource.MustParse("128Mi"),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	markManaged(ctx, deployment)
+	_, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	return nil
+}
+
+func (m *Manager) createExtAuthzService(ctx context.Context, namespace string) error {
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "ext-authz",
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": "ext-authz",
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{
+					Name:       "http",
+					Port:       extAuthzHTTPPort,
+					TargetPort: intstr.FromInt(extAuthzHTTPPort),
+					Protocol:   corev1.ProtocolTCP,
+				},
+				{
+					Name:       "grpc",
+					Port:       extAuthzGRPCPort,
+					TargetPort: intstr.FromInt(extAuthzGRPCPort),
+					Protocol:   corev1.ProtocolTCP,
+				},
+			},
+			Selector: map[string]string{
+				"app": "ext-authz",
+			},
+		},
+	}
+
+	markManaged(ctx, service)
+	_, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+
+	return nil
+}
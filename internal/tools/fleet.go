@@ -0,0 +1,191 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/version"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// FleetCluster is the status of the cluster behind one kubeconfig context
+type FleetCluster struct {
+	Context           string   `json:"context"`
+	Current           bool     `json:"current,omitempty"`
+	Reachable         bool     `json:"reachable"`
+	KubernetesVersion string   `json:"kubernetes_version,omitempty"`
+	IstioInstalled    bool     `json:"istio_installed"`
+	IstioVersion      string   `json:"istio_version,omitempty"`
+	MeshHealth        string   `json:"mesh_health"` // healthy, degraded, not-installed, unknown or refused
+	Issues            []string `json:"issues,omitempty"`
+}
+
+// FleetStatus is the result of get_fleet_status
+type FleetStatus struct {
+	Summary   map[string]int  `json:"summary"` // clusters per mesh health, and unreachable clusters
+	Clusters  []*FleetCluster `json:"clusters"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// getFleetStatusParams are the arguments of get_fleet_status
+type getFleetStatusParams struct {
+	Contexts       []string `json:"contexts,omitempty"`        // default: every kubeconfig context
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Timeout        int      `json:"timeout,omitempty"`         // seconds per cluster, default: 10
+}
+
+// GetFleetStatus surveys the clusters of several kubeconfig contexts at once, reporting per cluster whether it is
+// reachable, its Kubernetes and Istio versions and the health of its mesh
+func (m *Manager) GetFleetStatus(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getFleetStatusParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = 10
+	}
+
+	raw, err := clientcmd.NewDefaultClientConfigLoadingRules().Load()
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to load kubeconfig: %v", err),
+				},
+			},
+		}, nil
+	}
+	names := params.Contexts
+	if len(names) == 0 {
+		for name := range raw.Contexts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+	for _, name := range names {
+		if _, ok := raw.Contexts[name]; !ok {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Context %s not found in kubeconfig", name),
+					},
+				},
+			}, nil
+		}
+	}
+
+	status := &FleetStatus{
+		Summary:   make(map[string]int),
+		Clusters:  make([]*FleetCluster, len(names)),
+		Timestamp: time.Now(),
+	}
+	// Clusters are checked in parallel, so the survey takes as long as the slowest cluster
+	var wg sync.WaitGroup
+	for i, name := range names {
+		status.Clusters[i] = &FleetCluster{Context: name, Current: name == raw.CurrentContext}
+		wg.Add(1)
+		go func(cluster *FleetCluster) {
+			defer wg.Done()
+			clusterCtx, cancel := context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Second)
+			defer cancel()
+			m.checkFleetCluster(clusterCtx, cluster, params.IstioNamespace)
+		}(status.Clusters[i])
+	}
+	wg.Wait()
+
+	for _, cluster := range status.Clusters {
+		status.Summary[cluster.MeshHealth]++
+		if !cluster.Reachable && cluster.MeshHealth != "refused" {
+			status.Summary["unreachable"]++
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(status, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// checkFleetCluster fills in the status of one cluster of the fleet
+func (m *Manager) checkFleetCluster(ctx context.Context, cluster *FleetCluster, istioNamespace string) {
+	cluster.MeshHealth = "unknown"
+	// Other clusters are as much outside a namespace scope as a per-call context
+	if !cluster.Current {
+		if err := m.checkContextScope(cluster.Context); err != nil {
+			cluster.MeshHealth = "refused"
+			cluster.Issues = append(cluster.Issues, err.Error())
+			return
+		}
+	}
+
+	target, err := m.forContext(cluster.Context)
+	if err != nil {
+		cluster.Issues = append(cluster.Issues, fmt.Sprintf("failed to create a client: %v", err))
+		return
+	}
+
+	body, err := target.k8sClient.Kubernetes.Discovery().RESTClient().Get().AbsPath("/version").Do(ctx).Raw()
+	if err != nil {
+		cluster.Issues = append(cluster.Issues, fmt.Sprintf("API server is unreachable: %v", err))
+		return
+	}
+	cluster.Reachable = true
+	var info version.Info
+	if err := json.Unmarshal(body, &info); err == nil {
+		cluster.KubernetesVersion = info.GitVersion
+	}
+
+	istio, err := target.getIstioStatus(ctx, istioNamespace)
+	if err != nil {
+		cluster.Issues = append(cluster.Issues, fmt.Sprintf("failed to get Istio status: %v", err))
+		return
+	}
+	cluster.IstioInstalled = istio.Installed
+	if !istio.Installed {
+		cluster.MeshHealth = "not-installed"
+		return
+	}
+	cluster.IstioVersion = istio.Version
+	if cluster.IstioVersion == "unknown" {
+		if imageVersion, err := target.detectIstiodVersion(ctx, istioNamespace); err == nil {
+			cluster.IstioVersion = imageVersion
+		}
+	}
+	cluster.Issues = append(cluster.Issues, istio.Issues...)
+	cluster.MeshHealth = "healthy"
+	for _, component := range istio.Components {
+		if !component.Ready {
+			cluster.MeshHealth = "degraded"
+		}
+	}
+	if len(istio.Issues) > 0 {
+		cluster.MeshHealth = "degraded"
+	}
+}
@@ -0,0 +1,945 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	// defaultGatewayAPIVersion is the Gateway API release whose CRDs install_istio installs
+	defaultGatewayAPIVersion = "v1.2.1"
+	// gatewayAPIReleaseURL is the standard channel install manifest of a Gateway API release
+	gatewayAPIReleaseURL = "https://github.com/kubernetes-sigs/gateway-api/releases/download/%s/standard-install.yaml"
+	// gatewayAPIBundleAnnotation records the Gateway API release a CRD comes from
+	gatewayAPIBundleAnnotation = "gateway.networking.k8s.io/bundle-version"
+	// ingressGatewayName is the name of the ingress gateway install_istio deploys, as a Helm release or a Gateway
+	ingressGatewayName = "istio-ingress"
+)
+
+var (
+	grpcRouteGVR                = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "grpcroutes"}
+	customResourceDefinitionGVR = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+)
+
+// gatewayRouteKinds maps the route kinds the route tools manage to their resources
+var gatewayRouteKinds = []struct {
+	kind string
+	gvr  schema.GroupVersionResource
+}{
+	{"HTTPRoute", httpRouteGVR},
+	{"GRPCRoute", grpcRouteGVR},
+}
+
+// GatewayCondition is a status condition of a Gateway API resource
+type GatewayCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// GatewayBackend is a weighted backend Service of a route
+type GatewayBackend struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // default: the route namespace; other namespaces need a ReferenceGrant
+	Port      int64  `json:"port"`
+	Weight    int64  `json:"weight,omitempty"`
+}
+
+// RouteParent is the status a gateway reports for a route attached to it
+type RouteParent struct {
+	Gateway    string             `json:"gateway"` // <namespace>/<name>, with /<listener> when bound to one listener
+	Controller string             `json:"controller,omitempty"`
+	Conditions []GatewayCondition `json:"conditions,omitempty"`
+}
+
+// GatewayRoute is an HTTPRoute or GRPCRoute as returned by the route tools
+type GatewayRoute struct {
+	Kind            string          `json:"kind"`
+	Name            string          `json:"name"`
+	Namespace       string          `json:"namespace"`
+	Hostnames       []string        `json:"hostnames,omitempty"`
+	ParentRefs      []string        `json:"parent_refs,omitempty"` // <namespace>/<name>[/<listener>]
+	Parents         []RouteParent   `json:"parents,omitempty"`
+	ResourceVersion string          `json:"resource_version,omitempty"`
+	Created         *time.Time      `json:"created,omitempty"`
+	Spec            json.RawMessage `json:"spec,omitempty"`
+	Issues          []string        `json:"issues,omitempty"`
+}
+
+// GatewayRouteChange is the result of creating, updating or deleting a route
+type GatewayRouteChange struct {
+	Action string        `json:"action"` // created, updated or deleted
+	DryRun bool          `json:"dry_run,omitempty"`
+	Route  *GatewayRoute `json:"route,omitempty"`
+	Kind   string        `json:"kind,omitempty"`
+	Name   string        `json:"name,omitempty"`
+	Notes  []string      `json:"notes,omitempty"`
+}
+
+// GatewayListener is a listener of a Gateway with the status its controller reports
+type GatewayListener struct {
+	Name           string             `json:"name"`
+	Protocol       string             `json:"protocol"`
+	Port           int64              `json:"port"`
+	Hostname       string             `json:"hostname,omitempty"`
+	AttachedRoutes int64              `json:"attached_routes"`
+	Conditions     []GatewayCondition `json:"conditions,omitempty"`
+}
+
+// GatewayAPIStatus is the status of a Gateway API Gateway and the routes attached to it
+type GatewayAPIStatus struct {
+	Name       string             `json:"name"`
+	Namespace  string             `json:"namespace"`
+	Class      string             `json:"class"`
+	Accepted   bool               `json:"accepted"`
+	Programmed bool               `json:"programmed"`
+	Addresses  []string           `json:"addresses,omitempty"`
+	Conditions []GatewayCondition `json:"conditions,omitempty"`
+	Listeners  []GatewayListener  `json:"listeners,omitempty"`
+	Routes     []GatewayRoute     `json:"routes,omitempty"`
+	Issues     []string           `json:"issues,omitempty"`
+}
+
+// GatewayStatusReport is the result of get_gateway_status
+type GatewayStatusReport struct {
+	Namespace string             `json:"namespace"` // "*" for all namespaces
+	Gateways  []GatewayAPIStatus `json:"gateways"`
+	Healthy   bool               `json:"healthy"`
+	Notes     []string           `json:"notes,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// createGatewayRouteParams are the arguments of create_gateway_route
+type createGatewayRouteParams struct {
+	Name             string                 `json:"name"`
+	Namespace        string                 `json:"namespace,omitempty"`         // default: default
+	Kind             string                 `json:"kind,omitempty"`              // HTTPRoute (default) or GRPCRoute
+	Gateway          string                 `json:"gateway,omitempty"`           // parent Gateway, default: istio-ingress
+	GatewayNamespace string                 `json:"gateway_namespace,omitempty"` // default: istio-ingress
+	SectionName      string                 `json:"section_name,omitempty"`      // listener to attach to, default: all
+	Hostnames        []string               `json:"hostnames,omitempty"`
+	Backends         []GatewayBackend       `json:"backends,omitempty"`
+	Path             string                 `json:"path,omitempty"`         // HTTPRoute path prefix, default: /
+	Timeout          string                 `json:"timeout,omitempty"`      // HTTPRoute request timeout
+	GRPCService      string                 `json:"grpc_service,omitempty"` // GRPCRoute service to match, default: all
+	Spec             map[string]interface{} `json:"spec,omitempty"`         // full spec, replaces the fields above
+	DryRun           bool                   `json:"dry_run,omitempty"`      // validate server-side without persisting
+}
+
+// CreateGatewayRoute creates or updates an HTTPRoute or GRPCRoute attached to a Gateway API Gateway, from a
+// full spec or from hostnames, a match and weighted backends
+func (m *Manager) CreateGatewayRoute(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params createGatewayRouteParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Kind == "" {
+		params.Kind = "HTTPRoute"
+	}
+	if params.Gateway == "" {
+		params.Gateway = ingressGatewayName
+	}
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+
+	if params.Name == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "name is required",
+				},
+			},
+		}, nil
+	}
+	kind, gvr, err := gatewayRouteKind(params.Kind)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	result := &GatewayRouteChange{Action: "created", DryRun: params.DryRun}
+	spec := params.Spec
+	if spec == nil {
+		if len(params.Backends) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "backends are required unless spec is given",
+					},
+				},
+			}, nil
+		}
+		var backendRefs []interface{}
+		for _, backend := range params.Backends {
+			if backend.Name == "" || backend.Port == 0 {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: "every backend needs a name and a port",
+						},
+					},
+				}, nil
+			}
+			ref := map[string]interface{}{"name": backend.Name, "port": backend.Port}
+			if backend.Namespace != "" && backend.Namespace != params.Namespace {
+				ref["namespace"] = backend.Namespace
+				result.Notes = append(result.Notes, fmt.Sprintf("Backend %s/%s is in another namespace; it resolves only if a ReferenceGrant there allows %ss from %s", backend.Namespace, backend.Name, kind, params.Namespace))
+			}
+			if backend.Weight != 0 {
+				ref["weight"] = backend.Weight
+			}
+			backendRefs = append(backendRefs, ref)
+		}
+
+		rule := map[string]interface{}{"backendRefs": backendRefs}
+		if kind == "HTTPRoute" {
+			rule["matches"] = []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": params.Path}}}
+			if params.Timeout != "" {
+				rule["timeouts"] = map[string]interface{}{"request": params.Timeout}
+			}
+		} else if params.GRPCService != "" {
+			rule["matches"] = []interface{}{map[string]interface{}{"method": map[string]interface{}{"service": params.GRPCService}}}
+		}
+
+		parentRef := map[string]interface{}{"name": params.Gateway, "namespace": params.GatewayNamespace}
+		if params.SectionName != "" {
+			parentRef["sectionName"] = params.SectionName
+		}
+		spec = map[string]interface{}{
+			"parentRefs": []interface{}{parentRef},
+			"rules":      []interface{}{rule},
+		}
+		if len(params.Hostnames) > 0 {
+			hostnames := make([]interface{}, 0, len(params.Hostnames))
+			for _, hostname := range params.Hostnames {
+				hostnames = append(hostnames, hostname)
+			}
+			spec["hostnames"] = hostnames
+		}
+	}
+
+	ctx = withManagingTool(ctx, "create_gateway_route")
+
+	client := m.k8sClient.Dynamic.Resource(gvr).Namespace(params.Namespace)
+	route, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		// Keep the existing metadata so labels and annotations set by others survive the update
+		route.Object["spec"] = spec
+		route, err = client.Update(ctx, route, metav1.UpdateOptions{DryRun: trafficDryRun(params.DryRun)})
+		result.Action = "updated"
+	case errors.IsNotFound(err):
+		route = &unstructured.Unstructured{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       kind,
+			"metadata":   map[string]interface{}{"name": params.Name, "namespace": params.Namespace},
+			"spec":       spec,
+		}}
+		markManaged(ctx, route)
+		route, err = client.Create(ctx, route, metav1.CreateOptions{DryRun: trafficDryRun(params.DryRun)})
+	}
+	if err != nil {
+		hint := ""
+		if errors.IsNotFound(err) {
+			hint = "; the Gateway API CRDs may not be installed (install_istio with gateway_api_crds)"
+		}
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply %s %s/%s: %v%s", kind, params.Namespace, params.Name, err, hint),
+				},
+			},
+		}, nil
+	}
+	result.Route = gatewayRouteResource(route, true)
+	if !params.DryRun {
+		result.Notes = append(result.Notes, "Use get_gateway_routes or get_gateway_status to check that the gateway accepted the route")
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getGatewayRoutesParams are the arguments of get_gateway_routes
+type getGatewayRoutesParams struct {
+	Name      string `json:"name,omitempty"`      // default: all in the namespace
+	Namespace string `json:"namespace,omitempty"` // default: default
+	Kind      string `json:"kind,omitempty"`      // HTTPRoute or GRPCRoute, default: both
+}
+
+// GetGatewayRoutes returns an HTTPRoute or GRPCRoute, or every route of a namespace, with the status each
+// parent gateway reports for it
+func (m *Manager) GetGatewayRoutes(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getGatewayRoutesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	selected := ""
+	if params.Kind != "" {
+		kind, _, err := gatewayRouteKind(params.Kind)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: err.Error(),
+					},
+				},
+			}, nil
+		}
+		selected = kind
+	}
+
+	routes := []*GatewayRoute{}
+	for _, kind := range gatewayRouteKinds {
+		if selected != "" && kind.kind != selected {
+			continue
+		}
+		client := m.k8sClient.Dynamic.Resource(kind.gvr).Namespace(params.Namespace)
+		if params.Name != "" {
+			route, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				continue
+			}
+			if err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Failed to get %s %s/%s: %v", kind.kind, params.Namespace, params.Name, err),
+						},
+					},
+				}, nil
+			}
+			routes = append(routes, gatewayRouteResource(route, true))
+			continue
+		}
+
+		list, err := client.List(ctx, metav1.ListOptions{})
+		if errors.IsNotFound(err) {
+			// GRPCRoute is only in the standard channel from Gateway API v1.1
+			continue
+		}
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list %ss: %v", kind.kind, err),
+					},
+				},
+			}, nil
+		}
+		for i := range list.Items {
+			routes = append(routes, gatewayRouteResource(&list.Items[i], true))
+		}
+	}
+	if params.Name != "" && len(routes) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No route %s/%s found", params.Namespace, params.Name),
+				},
+			},
+		}, nil
+	}
+
+	var result interface{} = routes
+	if params.Name != "" && len(routes) == 1 {
+		result = routes[0]
+	}
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// deleteGatewayRouteParams are the arguments of delete_gateway_route
+type deleteGatewayRouteParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // default: default
+	Kind      string `json:"kind,omitempty"`      // HTTPRoute (default) or GRPCRoute
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
+
+// DeleteGatewayRoute deletes an HTTPRoute or GRPCRoute
+func (m *Manager) DeleteGatewayRoute(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deleteGatewayRouteParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Kind == "" {
+		params.Kind = "HTTPRoute"
+	}
+
+	kind, gvr, err := gatewayRouteKind(params.Kind)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	err = m.k8sClient.Dynamic.Resource(gvr).Namespace(params.Namespace).Delete(ctx, params.Name, metav1.DeleteOptions{DryRun: trafficDryRun(params.DryRun)})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to delete %s %s/%s: %v", kind, params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+
+	result := &GatewayRouteChange{Action: "deleted", DryRun: params.DryRun, Kind: kind, Name: params.Namespace + "/" + params.Name}
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getGatewayStatusParams are the arguments of get_gateway_status
+type getGatewayStatusParams struct {
+	Name      string `json:"name,omitempty"`      // default: every Gateway
+	Namespace string `json:"namespace,omitempty"` // default: all namespaces
+}
+
+// GetGatewayStatus reports whether Gateway API Gateways are accepted and programmed, the status of their
+// listeners and addresses, and whether the routes attached to them were accepted and resolved
+func (m *Manager) GetGatewayStatus(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getGatewayStatusParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := &GatewayStatusReport{
+		Namespace: params.Namespace,
+		Gateways:  []GatewayAPIStatus{},
+		Healthy:   true,
+		Timestamp: time.Now(),
+	}
+	if report.Namespace == "" {
+		report.Namespace = scopeAllNamespaces
+	}
+
+	list, err := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		hint := ""
+		if errors.IsNotFound(err) {
+			hint = "; the Gateway API CRDs are not installed (install_istio with gateway_api_crds)"
+		}
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list Gateways: %v%s", err, hint),
+				},
+			},
+		}, nil
+	}
+
+	// Routes may attach to a Gateway from any namespace
+	var routes []*GatewayRoute
+	for _, kind := range gatewayRouteKinds {
+		routeList, err := m.k8sClient.Dynamic.Resource(kind.gvr).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				report.Notes = append(report.Notes, fmt.Sprintf("Failed to list %ss: %v", kind.kind, err))
+			}
+			continue
+		}
+		for i := range routeList.Items {
+			routes = append(routes, gatewayRouteResource(&routeList.Items[i], false))
+		}
+	}
+
+	for _, gateway := range list.Items {
+		if params.Name != "" && gateway.GetName() != params.Name {
+			continue
+		}
+		status := gatewayAPIStatus(&gateway, routes)
+		if len(status.Issues) > 0 {
+			report.Healthy = false
+		}
+		report.Gateways = append(report.Gateways, status)
+	}
+	if len(report.Gateways) == 0 {
+		if params.Name != "" {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Gateway %s not found", params.Name),
+					},
+				},
+			}, nil
+		}
+		report.Notes = append(report.Notes, "No Gateway API Gateways found; install_istio with gateway_mode gateway_api deploys one")
+	}
+	for _, gateway := range report.Gateways {
+		if gateway.Class == "istio" || gateway.Class == "istio-waypoint" {
+			continue
+		}
+		report.Notes = append(report.Notes, fmt.Sprintf("Gateway %s/%s uses class %s, which Istio does not program", gateway.Namespace, gateway.Name, gateway.Class))
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// gatewayAPIStatus summarizes the status of a Gateway and of the routes that reference it
+func gatewayAPIStatus(gateway *unstructured.Unstructured, routes []*GatewayRoute) GatewayAPIStatus {
+	status := GatewayAPIStatus{
+		Name:       gateway.GetName(),
+		Namespace:  gateway.GetNamespace(),
+		Conditions: gatewayConditions(gateway.Object, "status", "conditions"),
+	}
+	status.Class, _, _ = unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+	ref := status.Namespace + "/" + status.Name
+
+	for _, condition := range status.Conditions {
+		switch condition.Type {
+		case "Accepted":
+			status.Accepted = condition.Status == "True"
+		case "Programmed":
+			status.Programmed = condition.Status == "True"
+		}
+	}
+	switch {
+	case len(status.Conditions) == 0:
+		status.Issues = append(status.Issues, fmt.Sprintf("Gateway %s has no status; no controller handles gateway class %s", ref, status.Class))
+	case !status.Accepted:
+		status.Issues = append(status.Issues, fmt.Sprintf("Gateway %s is not accepted: %s", ref, conditionSummary(status.Conditions, "Accepted")))
+	case !status.Programmed:
+		status.Issues = append(status.Issues, fmt.Sprintf("Gateway %s is not programmed: %s", ref, conditionSummary(status.Conditions, "Programmed")))
+	}
+
+	addresses, _, _ := unstructured.NestedSlice(gateway.Object, "status", "addresses")
+	for _, entry := range addresses {
+		address, _ := entry.(map[string]interface{})
+		if value, _ := address["value"].(string); value != "" {
+			status.Addresses = append(status.Addresses, value)
+		}
+	}
+	if status.Programmed && len(status.Addresses) == 0 {
+		status.Issues = append(status.Issues, fmt.Sprintf("Gateway %s has no address; its Service may be waiting for a load balancer", ref))
+	}
+
+	listenerStatus := make(map[string]map[string]interface{})
+	statusListeners, _, _ := unstructured.NestedSlice(gateway.Object, "status", "listeners")
+	for _, entry := range statusListeners {
+		if listener, ok := entry.(map[string]interface{}); ok {
+			name, _ := listener["name"].(string)
+			listenerStatus[name] = listener
+		}
+	}
+	specListeners, _, _ := unstructured.NestedSlice(gateway.Object, "spec", "listeners")
+	for _, entry := range specListeners {
+		spec, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		listener := GatewayListener{}
+		listener.Name, _ = spec["name"].(string)
+		listener.Protocol, _ = spec["protocol"].(string)
+		listener.Port, _, _ = unstructured.NestedInt64(spec, "port")
+		listener.Hostname, _ = spec["hostname"].(string)
+		if observed, ok := listenerStatus[listener.Name]; ok {
+			listener.AttachedRoutes, _, _ = unstructured.NestedInt64(observed, "attachedRoutes")
+			listener.Conditions = gatewayConditions(observed, "conditions")
+		}
+		for _, condition := range listener.Conditions {
+			failed := condition.Status == "False"
+			if condition.Type == "Conflicted" {
+				failed = condition.Status == "True"
+			}
+			if failed {
+				status.Issues = append(status.Issues, fmt.Sprintf("Listener %s of %s is %s: %s", listener.Name, ref, conditionState(condition), conditionDetail(condition)))
+			}
+		}
+		status.Listeners = append(status.Listeners, listener)
+	}
+
+	for _, route := range routes {
+		if !routeReferences(route, status.Namespace, status.Name) {
+			continue
+		}
+		attached := GatewayRoute{Kind: route.Kind, Name: route.Name, Namespace: route.Namespace, Hostnames: route.Hostnames}
+		reported := false
+		for _, parent := range route.Parents {
+			if parent.Gateway != ref && !strings.HasPrefix(parent.Gateway, ref+"/") {
+				continue
+			}
+			reported = true
+			attached.Parents = append(attached.Parents, parent)
+			for _, condition := range parent.Conditions {
+				if (condition.Type == "Accepted" || condition.Type == "ResolvedRefs") && condition.Status == "False" {
+					status.Issues = append(status.Issues, fmt.Sprintf("%s %s/%s is %s by %s: %s", route.Kind, route.Namespace, route.Name, conditionState(condition), ref, conditionDetail(condition)))
+				}
+			}
+		}
+		if !reported {
+			status.Issues = append(status.Issues, fmt.Sprintf("%s %s/%s references %s but has no status from it yet", route.Kind, route.Namespace, route.Name, ref))
+		}
+		status.Routes = append(status.Routes, attached)
+	}
+	return status
+}
+
+// gatewayRouteResource converts an HTTPRoute or GRPCRoute for tool output, with or without its spec
+func gatewayRouteResource(obj *unstructured.Unstructured, withSpec bool) *GatewayRoute {
+	route := &GatewayRoute{
+		Kind:            obj.GetKind(),
+		Name:            obj.GetName(),
+		Namespace:       obj.GetNamespace(),
+		ResourceVersion: obj.GetResourceVersion(),
+	}
+	route.Hostnames, _, _ = unstructured.NestedStringSlice(obj.Object, "spec", "hostnames")
+	if created := obj.GetCreationTimestamp(); !created.IsZero() {
+		route.Created = &created.Time
+	}
+	if spec, ok := obj.Object["spec"]; ok && withSpec {
+		route.Spec, _ = json.Marshal(spec)
+	}
+
+	parentRefs, _, _ := unstructured.NestedSlice(obj.Object, "spec", "parentRefs")
+	for _, entry := range parentRefs {
+		if ref, ok := entry.(map[string]interface{}); ok {
+			route.ParentRefs = append(route.ParentRefs, parentReference(ref, route.Namespace))
+		}
+	}
+
+	parents, _, _ := unstructured.NestedSlice(obj.Object, "status", "parents")
+	for _, entry := range parents {
+		parent, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		ref, _, _ := unstructured.NestedMap(parent, "parentRef")
+		status := RouteParent{
+			Gateway:    parentReference(ref, route.Namespace),
+			Conditions: gatewayConditions(parent, "conditions"),
+		}
+		status.Controller, _ = parent["controllerName"].(string)
+		for _, condition := range status.Conditions {
+			if (condition.Type == "Accepted" || condition.Type == "ResolvedRefs") && condition.Status == "False" {
+				route.Issues = append(route.Issues, fmt.Sprintf("%s is %s: %s", status.Gateway, conditionState(condition), conditionDetail(condition)))
+			}
+		}
+		route.Parents = append(route.Parents, status)
+	}
+	if len(route.ParentRefs) > 0 && len(route.Parents) == 0 {
+		route.Issues = append(route.Issues, "No gateway has reported status for this route; check that the parent Gateway exists and its class is handled")
+	}
+	return route
+}
+
+// routeReferences reports whether a route lists the Gateway among its parents
+func routeReferences(route *GatewayRoute, namespace, name string) bool {
+	ref := namespace + "/" + name
+	for _, parent := range route.ParentRefs {
+		if parent == ref || strings.HasPrefix(parent, ref+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// parentReference formats a route parentRef as <namespace>/<name>[/<listener>], defaulting the namespace
+// to the route's own
+func parentReference(ref map[string]interface{}, routeNamespace string) string {
+	namespace, _ := ref["namespace"].(string)
+	if namespace == "" {
+		namespace = routeNamespace
+	}
+	name, _ := ref["name"].(string)
+	reference := namespace + "/" + name
+	if section, _ := ref["sectionName"].(string); section != "" {
+		reference += "/" + section
+	}
+	return reference
+}
+
+// gatewayConditions reads the conditions list at the given fields of a Gateway API object
+func gatewayConditions(obj map[string]interface{}, fields ...string) []GatewayCondition {
+	entries, _, _ := unstructured.NestedSlice(obj, fields...)
+	var conditions []GatewayCondition
+	for _, entry := range entries {
+		raw, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		condition := GatewayCondition{}
+		condition.Type, _ = raw["type"].(string)
+		condition.Status, _ = raw["status"].(string)
+		condition.Reason, _ = raw["reason"].(string)
+		condition.Message, _ = raw["message"].(string)
+		conditions = append(conditions, condition)
+	}
+	sort.SliceStable(conditions, func(i, j int) bool { return conditions[i].Type < conditions[j].Type })
+	return conditions
+}
+
+// conditionSummary describes the condition of the given type, or its absence
+func conditionSummary(conditions []GatewayCondition, conditionType string) string {
+	for _, condition := range conditions {
+		if condition.Type == conditionType {
+			return conditionDetail(condition)
+		}
+	}
+	return conditionType + " condition not reported"
+}
+
+// conditionState names the state a failed condition puts a resource in, such as "not Accepted"
+func conditionState(condition GatewayCondition) string {
+	if condition.Type == "Conflicted" {
+		return "Conflicted"
+	}
+	return "not " + condition.Type
+}
+
+// conditionDetail formats the reason and message of a condition
+func conditionDetail(condition GatewayCondition) string {
+	if condition.Message == "" {
+		return condition.Reason
+	}
+	return fmt.Sprintf("%s (%s)", condition.Reason, condition.Message)
+}
+
+// gatewayRouteKind resolves the kind parameter of the route tools, case-insensitively
+func gatewayRouteKind(kind string) (string, schema.GroupVersionResource, error) {
+	for _, candidate := range gatewayRouteKinds {
+		if strings.EqualFold(kind, candidate.kind) {
+			return candidate.kind, candidate.gvr, nil
+		}
+	}
+	return "", schema.GroupVersionResource{}, fmt.Errorf("invalid kind %q (valid: HTTPRoute, GRPCRoute)", kind)
+}
+
+// installGatewayAPICRDs installs the standard channel CRDs of a Gateway API release; CRDs that are already
+// installed are left alone, since the cluster provider or another controller may own a different release
+func (m *Manager) installGatewayAPICRDs(ctx context.Context, version string) (string, error) {
+	crd, err := m.k8sClient.Dynamic.Resource(customResourceDefinitionGVR).Get(ctx, "gateways.gateway.networking.k8s.io", metav1.GetOptions{})
+	if err == nil {
+		installed := crd.GetAnnotations()[gatewayAPIBundleAnnotation]
+		if installed == "" {
+			installed = "unknown release"
+		}
+		return fmt.Sprintf("Gateway API CRDs already installed (%s), left unchanged", installed), nil
+	}
+	if !errors.IsNotFound(err) {
+		return "", fmt.Errorf("failed to check for the Gateway API CRDs: %w", err)
+	}
+
+	data, err := fetchManifest(ctx, fmt.Sprintf(gatewayAPIReleaseURL, version))
+	if err != nil {
+		return "", fmt.Errorf("failed to download the Gateway API %s manifest: %w", version, err)
+	}
+	objects, err := decodeManifest(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse the Gateway API %s manifest: %w", version, err)
+	}
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(m.k8sClient.Config)
+	if err != nil {
+		return "", fmt.Errorf("failed to create discovery client: %w", err)
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+	for _, obj := range objects {
+		if applied := m.applyManifestObject(ctx, mapper, obj, "", false, false); applied.Error != "" {
+			return "", fmt.Errorf("failed to apply %s %s: %s", applied.Kind, applied.Name, applied.Error)
+		}
+	}
+	logrus.Infof("Gateway API %s CRDs installed", version)
+	return fmt.Sprintf("Gateway API %s CRDs installed", version), nil
+}
+
+// deployIngressGateway creates the ingress Gateway with class istio, which istiod deploys a gateway for, and
+// waits for that deployment to be ready; it returns the name of the gateway Service
+func (m *Manager) deployIngressGateway(ctx context.Context, namespace string, timeout time.Duration) (string, error) {
+	namespaces := m.k8sClient.Kubernetes.CoreV1().Namespaces()
+	if _, err := namespaces.Get(ctx, namespace, metav1.GetOptions{}); errors.IsNotFound(err) {
+		// Like the Helm gateway install, the namespace is not labeled as meshpilot's: cleanup_demo must keep it
+		if _, err := namespaces.Create(ctx, &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}, metav1.CreateOptions{}); err != nil && !errors.IsAlreadyExists(err) {
+			return "", fmt.Errorf("failed to create namespace %s: %w", namespace, err)
+		}
+	} else if err != nil {
+		return "", fmt.Errorf("failed to get namespace %s: %w", namespace, err)
+	}
+
+	gateway := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "gateway.networking.k8s.io/v1",
+		"kind":       "Gateway",
+		"metadata":   map[string]interface{}{"name": ingressGatewayName, "namespace": namespace},
+		"spec": map[string]interface{}{
+			"gatewayClassName": "istio",
+			"listeners": []interface{}{map[string]interface{}{
+				"name":          "http",
+				"port":          int64(80),
+				"protocol":      "HTTP",
+				"allowedRoutes": map[string]interface{}{"namespaces": map[string]interface{}{"from": "All"}},
+			}},
+		},
+	}}
+	if err := m.applyResource(ctx, gatewayGVR, gateway); err != nil {
+		return "", fmt.Errorf("failed to create Gateway %s/%s: %w", namespace, ingressGatewayName, err)
+	}
+
+	// Istio names the deployment and Service of a Gateway after the Gateway and its class
+	service := ingressGatewayName + "-istio"
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Get(ctx, service, metav1.GetOptions{})
+		if err != nil {
+			return false, nil
+		}
+		return deployment.Status.ReadyReplicas > 0, nil
+	})
+	if err != nil {
+		return service, fmt.Errorf("gateway deployment %s/%s did not become ready within %s", namespace, service, timeout)
+	}
+	return service, nil
+}
+
+// removeIngressGateway deletes the ingress Gateway deployIngressGateway created; Gateways meshpilot did not
+// create, and clusters without the Gateway API CRDs, are left alone
+func (m *Manager) removeIngressGateway(ctx context.Context, namespace string) (bool, error) {
+	resources := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(namespace)
+	gateway, err := resources.Get(ctx, ingressGatewayName, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if gateway.GetLabels()[managedByLabel] != managedByValue {
+		return false, nil
+	}
+	if err := resources.Delete(ctx, ingressGatewayName, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+		return false, err
+	}
+	return true, nil
+}
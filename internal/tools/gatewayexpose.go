@@ -0,0 +1,594 @@
+package tools
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// selfSignedCertificateValidity is the lifetime of the certificates expose_service_via_gateway provisions
+const selfSignedCertificateValidity = 90 * 24 * time.Hour
+
+// ExposureCheck is the outcome of requesting the exposed service through the gateway
+type ExposureCheck struct {
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	WaitedFor  string `json:"waited_for,omitempty"`
+}
+
+// ServiceExposure is the result of expose_service_via_gateway
+type ServiceExposure struct {
+	Service      string         `json:"service"` // <namespace>/<service>:<port>
+	Host         string         `json:"host"`
+	Path         string         `json:"path"`
+	API          string         `json:"api"`     // istio or gateway_api
+	Gateway      string         `json:"gateway"` // <namespace>/<service> of the gateway carrying the traffic
+	Resources    []string       `json:"resources"`
+	TLSSecret    string         `json:"tls_secret,omitempty"` // <namespace>/<name>
+	SelfSigned   bool           `json:"self_signed,omitempty"`
+	Address      string         `json:"address,omitempty"`
+	Via          string         `json:"via,omitempty"` // loadbalancer or nodeport
+	URL          string         `json:"url,omitempty"`
+	Curl         string         `json:"curl,omitempty"`
+	Verification *ExposureCheck `json:"verification,omitempty"`
+	Issues       []string       `json:"issues,omitempty"`
+	Notes        []string       `json:"notes,omitempty"`
+	Timestamp    time.Time      `json:"timestamp"`
+}
+
+// exposeServiceViaGatewayParams are the arguments of expose_service_via_gateway
+type exposeServiceViaGatewayParams struct {
+	Service          string `json:"service"`                     // service to expose
+	Namespace        string `json:"namespace,omitempty"`         // default: default
+	Port             int    `json:"port,omitempty"`              // service port, default: the http port or the only port
+	Host             string `json:"host,omitempty"`              // default: * (required with tls)
+	Path             string `json:"path,omitempty"`              // path prefix, default: /
+	Name             string `json:"name,omitempty"`              // resource name prefix, default: service
+	API              string `json:"api,omitempty"`               // istio or gateway_api, default: istio
+	GatewayNamespace string `json:"gateway_namespace,omitempty"` // istio: default: istio-ingress
+	GatewayService   string `json:"gateway_service,omitempty"`   // istio: default: istio-ingress
+	GatewayClass     string `json:"gateway_class,omitempty"`     // gateway_api: default: istio
+	TLS              bool   `json:"tls,omitempty"`               // terminate HTTPS on port 443
+	CredentialName   string `json:"credential_name,omitempty"`   // existing TLS secret, default: provision a self-signed one
+	Verify           *bool  `json:"verify,omitempty"`            // default: true
+	Timeout          string `json:"timeout,omitempty"`           // wait for a gateway_api gateway, default: 2m
+}
+
+// ExposeServiceViaGateway routes a service through the ingress gateway with an Istio Gateway and VirtualService,
+// or a Gateway API Gateway and HTTPRoute, optionally terminating TLS with a self-signed certificate, and returns
+// the external address to reach it
+func (m *Manager) ExposeServiceViaGateway(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params exposeServiceViaGatewayParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Service == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "service is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Host == "" {
+		params.Host = "*"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.Name == "" {
+		params.Name = params.Service
+	}
+	if params.API == "" {
+		params.API = "istio"
+	}
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+	if params.GatewayService == "" {
+		params.GatewayService = "istio-ingress"
+	}
+	if params.GatewayClass == "" {
+		params.GatewayClass = "istio"
+	}
+	if params.Verify == nil {
+		params.Verify = boolPtr(true)
+	}
+	if params.Timeout == "" {
+		params.Timeout = "2m"
+	}
+
+	if params.API != "istio" && params.API != "gateway_api" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid api %q: must be istio or gateway_api", params.API),
+				},
+			},
+		}, nil
+	}
+	if params.TLS && strings.Contains(params.Host, "*") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "host is required with tls, e.g. httpbin.example.com: the certificate and SNI need a concrete name",
+				},
+			},
+		}, nil
+	}
+	if !strings.HasPrefix(params.Path, "/") {
+		params.Path = "/" + params.Path
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	service, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Get(ctx, params.Service, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get service %s/%s: %v", params.Namespace, params.Service, err),
+				},
+			},
+		}, nil
+	}
+	port, err := exposedServicePort(service, params.Port)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "expose_service_via_gateway")
+
+	result := &ServiceExposure{
+		Service:   fmt.Sprintf("%s/%s:%d", params.Namespace, params.Service, port),
+		Host:      params.Host,
+		Path:      params.Path,
+		API:       params.API,
+		Timestamp: time.Now(),
+	}
+	gatewayPort := 80
+	if params.TLS {
+		gatewayPort = 443
+	}
+
+	// Istio Gateways use the TLS secret from the gateway pods' namespace, Gateway API Gateways from their own
+	gatewayName := params.Name + "-gateway"
+	gatewayNamespace, gatewayService := params.GatewayNamespace, params.GatewayService
+	if params.API == "gateway_api" {
+		// Istio deploys a gateway named <gateway>-<class> for every Gateway of its class
+		gatewayNamespace, gatewayService = params.Namespace, fmt.Sprintf("%s-%s", gatewayName, params.GatewayClass)
+	}
+	result.Gateway = fmt.Sprintf("%s/%s", gatewayNamespace, gatewayService)
+
+	var gatewaySelector map[string]interface{}
+	if params.API == "istio" {
+		gateway, err := m.k8sClient.Kubernetes.CoreV1().Services(gatewayNamespace).Get(ctx, gatewayService, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get gateway service %s: %v; install the ingress gateway with install_istio (install_gateway) or use api gateway_api", result.Gateway, err),
+					},
+				},
+			}, nil
+		}
+		if len(gateway.Spec.Selector) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Gateway service %s has no selector to find its pods", result.Gateway),
+					},
+				},
+			}, nil
+		}
+		// The gateway service selects the gateway pods, so the Gateway selects them with the same labels
+		gatewaySelector = make(map[string]interface{})
+		for key, value := range gateway.Spec.Selector {
+			gatewaySelector[key] = value
+		}
+		hasPort := false
+		for _, servicePort := range gateway.Spec.Ports {
+			hasPort = hasPort || int(servicePort.Port) == gatewayPort
+		}
+		if !hasPort {
+			result.Issues = append(result.Issues, fmt.Sprintf("Gateway service %s has no port %d, so the Gateway server is unreachable from outside the cluster", result.Gateway, gatewayPort))
+		}
+	}
+
+	if params.TLS {
+		secretNamespace := gatewayNamespace
+		if params.CredentialName == "" {
+			params.CredentialName = params.Name + "-tls"
+			if err := m.applySelfSignedSecret(ctx, secretNamespace, params.CredentialName, params.Host); err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Failed to provision a self-signed certificate: %v", err),
+						},
+					},
+				}, nil
+			}
+			result.SelfSigned = true
+			result.Resources = append(result.Resources, fmt.Sprintf("Secret/%s/%s", secretNamespace, params.CredentialName))
+		} else if _, err := m.k8sClient.Kubernetes.CoreV1().Secrets(secretNamespace).Get(ctx, params.CredentialName, metav1.GetOptions{}); err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("TLS secret %s/%s is not readable (%v); the gateway needs it in its own namespace", secretNamespace, params.CredentialName, err))
+		}
+		result.TLSSecret = fmt.Sprintf("%s/%s", secretNamespace, params.CredentialName)
+	}
+
+	var resources []*unstructured.Unstructured
+	if params.API == "istio" {
+		resources = istioExposureResources(params.Name, params.Namespace, gatewaySelector, params.Host, params.Path, params.Service, port, gatewayPort, params.CredentialName)
+	} else {
+		resources = kubeExposureResources(params.Name, params.Namespace, params.GatewayClass, params.Host, params.Path, params.Service, port, gatewayPort, params.CredentialName)
+	}
+	for _, obj := range resources {
+		gvr := istioResourceGVR(obj)
+		if params.API == "gateway_api" {
+			gvr = gatewayGVR
+			if obj.GetKind() == "HTTPRoute" {
+				gvr = httpRouteGVR
+			}
+		}
+		if err := m.applyResource(ctx, gvr, obj); err != nil {
+			hint := ""
+			if params.API == "gateway_api" && errors.IsNotFound(err) {
+				hint = "; the Gateway API CRDs may not be installed"
+			}
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to apply %s %s: %v%s", obj.GetKind(), obj.GetName(), err, hint),
+					},
+				},
+			}, nil
+		}
+		result.Resources = append(result.Resources, fmt.Sprintf("%s/%s/%s", obj.GetKind(), obj.GetNamespace(), obj.GetName()))
+	}
+
+	if params.API == "gateway_api" {
+		err := wait.PollUntilContextTimeout(ctx, 3*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+			deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(gatewayNamespace).Get(ctx, gatewayService, metav1.GetOptions{})
+			if err != nil {
+				return false, nil
+			}
+			return deployment.Status.ReadyReplicas > 0, nil
+		})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Gateway deployment %s did not become ready within %s; check the Gateway status and that gateway class %s exists", result.Gateway, timeout, params.GatewayClass))
+			resultJSON, _ := json.MarshalIndent(result, "", "  ")
+			return &CallToolResult{
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: string(resultJSON),
+					},
+				},
+			}, nil
+		}
+	}
+
+	address, addressPort, via, err := m.resolveGatewayAddress(ctx, gatewayNamespace, gatewayService, gatewayPort)
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Could not find an external address for the gateway: %v", err))
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+	result.Address = net.JoinHostPort(address, strconv.Itoa(addressPort))
+	result.Via = via
+	result.URL, result.Curl = exposureCurl(params.TLS, params.Host, address, addressPort, params.Path)
+	if result.SelfSigned {
+		result.Notes = append(result.Notes, "The certificate is self-signed, so curl needs -k (or --cacert with the tls.crt of the secret)")
+	}
+	if via == "nodeport" {
+		result.Notes = append(result.Notes, "The gateway has no load balancer address, so the URL uses a node port on a node's internal IP; install_metallb gives it an external IP")
+	}
+
+	if *params.Verify {
+		result.Verification = m.checkExposure(ctx, result.Address, params.TLS, params.Host, params.Path)
+		switch {
+		case result.Verification.Error != "":
+			result.Issues = append(result.Issues, fmt.Sprintf("The gateway could not be reached from meshpilot (%s); it may still be reachable from elsewhere", result.Verification.Error))
+		case result.Verification.StatusCode == http.StatusNotFound:
+			result.Issues = append(result.Issues, "The gateway still answers 404 for the host and path; check the route with diagnose_ingress_request")
+		case result.Verification.StatusCode >= 500:
+			result.Issues = append(result.Issues, fmt.Sprintf("The gateway routed the request but got %d; check that the service has ready endpoints", result.Verification.StatusCode))
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// exposedServicePort picks the service port to route to: the requested one, else the port named http, else the
+// only port
+func exposedServicePort(service *corev1.Service, port int) (int, error) {
+	for _, servicePort := range service.Spec.Ports {
+		if port != 0 && int(servicePort.Port) == port {
+			return port, nil
+		}
+	}
+	if port != 0 {
+		return 0, fmt.Errorf("service %s/%s has no port %d", service.Namespace, service.Name, port)
+	}
+	for _, servicePort := range service.Spec.Ports {
+		if servicePort.Name == "http" || strings.HasPrefix(servicePort.Name, "http-") {
+			return int(servicePort.Port), nil
+		}
+	}
+	if len(service.Spec.Ports) == 1 {
+		return int(service.Spec.Ports[0].Port), nil
+	}
+	return 0, fmt.Errorf("service %s/%s has %d ports and none named http; set port", service.Namespace, service.Name, len(service.Spec.Ports))
+}
+
+// istioExposureResources builds the Gateway accepting the host on the ingress gateway and the VirtualService
+// routing the path to the service
+func istioExposureResources(name, namespace string, selector map[string]interface{}, host, path, service string, port, gatewayPort int, credentialName string) []*unstructured.Unstructured {
+	server := map[string]interface{}{
+		"port":  map[string]interface{}{"number": int64(gatewayPort), "name": "http", "protocol": "HTTP"},
+		"hosts": []interface{}{host},
+	}
+	if credentialName != "" {
+		server["port"] = map[string]interface{}{"number": int64(gatewayPort), "name": "https", "protocol": "HTTPS"}
+		server["tls"] = map[string]interface{}{"mode": "SIMPLE", "credentialName": credentialName}
+	}
+
+	return []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "Gateway",
+			"metadata":   map[string]interface{}{"name": name + "-gateway", "namespace": namespace},
+			"spec": map[string]interface{}{
+				"selector": selector,
+				"servers":  []interface{}{server},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "networking.istio.io/v1beta1",
+			"kind":       "VirtualService",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec": map[string]interface{}{
+				"hosts":    []interface{}{host},
+				"gateways": []interface{}{name + "-gateway"},
+				"http": []interface{}{map[string]interface{}{
+					"match": []interface{}{map[string]interface{}{"uri": map[string]interface{}{"prefix": path}}},
+					"route": []interface{}{map[string]interface{}{
+						"destination": map[string]interface{}{
+							"host": fmt.Sprintf("%s.%s.svc.cluster.local", service, namespace),
+							"port": map[string]interface{}{"number": int64(port)},
+						},
+					}},
+				}},
+			},
+		}},
+	}
+}
+
+// kubeExposureResources builds the Gateway API Gateway listening for the host and the HTTPRoute routing the
+// path to the service
+func kubeExposureResources(name, namespace, class, host, path, service string, port, gatewayPort int, credentialName string) []*unstructured.Unstructured {
+	listener := map[string]interface{}{"name": "http", "port": int64(gatewayPort), "protocol": "HTTP"}
+	if credentialName != "" {
+		listener["name"], listener["protocol"] = "https", "HTTPS"
+		listener["tls"] = map[string]interface{}{
+			"mode":            "Terminate",
+			"certificateRefs": []interface{}{map[string]interface{}{"name": credentialName}},
+		}
+	}
+	route := map[string]interface{}{
+		"parentRefs": []interface{}{map[string]interface{}{"name": name + "-gateway"}},
+		"rules": []interface{}{map[string]interface{}{
+			"matches":     []interface{}{map[string]interface{}{"path": map[string]interface{}{"type": "PathPrefix", "value": path}}},
+			"backendRefs": []interface{}{map[string]interface{}{"name": service, "port": int64(port)}},
+		}},
+	}
+	// A Gateway API listener or route without hostnames matches every host
+	if host != "*" {
+		listener["hostname"] = host
+		route["hostnames"] = []interface{}{host}
+	}
+
+	return []*unstructured.Unstructured{
+		{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "Gateway",
+			"metadata":   map[string]interface{}{"name": name + "-gateway", "namespace": namespace},
+			"spec": map[string]interface{}{
+				"gatewayClassName": class,
+				"listeners":        []interface{}{listener},
+			},
+		}},
+		{Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "HTTPRoute",
+			"metadata":   map[string]interface{}{"name": name, "namespace": namespace},
+			"spec":       route,
+		}},
+	}
+}
+
+// applySelfSignedSecret creates or replaces a kubernetes.io/tls Secret holding a self-signed certificate for the host
+func (m *Manager) applySelfSignedSecret(ctx context.Context, namespace, name, host string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate a key: %w", err)
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return fmt.Errorf("failed to generate a serial number: %w", err)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: host, Organization: []string{"meshpilot"}},
+		DNSNames:              []string{host},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedCertificateValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create the certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("failed to encode the key: %w", err)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		Type:       corev1.SecretTypeTLS,
+		Data: map[string][]byte{
+			corev1.TLSCertKey:       pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+			corev1.TLSPrivateKeyKey: pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		},
+	}
+	secrets := m.k8sClient.Kubernetes.CoreV1().Secrets(namespace)
+	existing, err := secrets.Get(ctx, name, metav1.GetOptions{})
+	switch {
+	case errors.IsNotFound(err):
+		_, err = secrets.Create(ctx, secret, metav1.CreateOptions{})
+	case err == nil:
+		secret.ResourceVersion = existing.ResourceVersion
+		_, err = secrets.Update(ctx, secret, metav1.UpdateOptions{})
+	}
+	return err
+}
+
+// exposureCurl returns the URL of the exposed service and a curl command reaching it through the gateway address
+func exposureCurl(useTLS bool, host, address string, port int, path string) (string, string) {
+	target := net.JoinHostPort(address, strconv.Itoa(port))
+	if useTLS {
+		// --resolve keeps the host in the URL, so curl sends it as SNI and Host header
+		url := fmt.Sprintf("https://%s%s", net.JoinHostPort(host, strconv.Itoa(port)), path)
+		return url, fmt.Sprintf("curl -k --resolve %s:%d:%s %s", host, port, address, url)
+	}
+	url := fmt.Sprintf("http://%s%s", target, path)
+	if host == "*" {
+		return url, "curl " + url
+	}
+	// A wildcard host matches any name in its domain
+	return url, fmt.Sprintf("curl -H 'Host: %s' %s", strings.Replace(host, "*", "meshpilot", 1), url)
+}
+
+// checkExposure requests the exposed path through the gateway until the route is no longer answered with 404,
+// giving the gateway time to receive the new configuration
+func (m *Manager) checkExposure(ctx context.Context, address string, useTLS bool, host, path string) *ExposureCheck {
+	check := &ExposureCheck{}
+	requestHost := strings.Replace(host, "*", "meshpilot", 1)
+	client := &http.Client{Timeout: 10 * time.Second}
+
+	start := time.Now()
+	err := wait.PollUntilContextTimeout(ctx, 3*time.Second, configPropagationTimeout, true, func(ctx context.Context) (bool, error) {
+		check.StatusCode, check.Error = 0, ""
+		if useTLS {
+			probe := probeGatewayTLS(ctx, address, host, nil, host, path, 10*time.Second)
+			check.StatusCode, check.Error = probe.StatusCode, probe.Error
+		} else {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s", address, path), nil)
+			if err != nil {
+				check.Error = err.Error()
+				return true, nil
+			}
+			req.Host = requestHost
+			resp, err := client.Do(req)
+			if err != nil {
+				check.Error = err.Error()
+				return false, nil
+			}
+			resp.Body.Close()
+			check.StatusCode = resp.StatusCode
+		}
+		return check.Error == "" && check.StatusCode != http.StatusNotFound, nil
+	})
+	if err == nil {
+		check.WaitedFor = time.Since(start).Round(time.Second).String()
+	}
+	return check
+}
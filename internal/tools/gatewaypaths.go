@@ -0,0 +1,380 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Paths a request can take to an ingress gateway, from the innermost to the outermost layer
+const (
+	gatewayPathPod          = "pod"
+	gatewayPathClusterIP    = "cluster_ip"
+	gatewayPathNodePort     = "node_port"
+	gatewayPathLoadBalancer = "load_balancer"
+)
+
+// Layers a gateway path failure is attributed to
+const (
+	gatewayLayerNone         = "none"
+	gatewayLayerMesh         = "mesh"
+	gatewayLayerKubeProxy    = "kube-proxy"
+	gatewayLayerLoadBalancer = "load_balancer"
+	gatewayLayerUnknown      = "unknown"
+)
+
+// GatewayPathProbe is one request to the gateway over a single path
+type GatewayPathProbe struct {
+	Path       string `json:"path"` // pod, cluster_ip, node_port or load_balancer
+	Target     string `json:"target"`
+	Node       string `json:"node,omitempty"` // node of the gateway pod or of the node port
+	From       string `json:"from"`           // source pod or the meshpilot host
+	Success    bool   `json:"success"`
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+	Duration   string `json:"duration,omitempty"`
+}
+
+// GatewayPathReport is the result of test_gateway_paths
+type GatewayPathReport struct {
+	Gateway               string             `json:"gateway"`
+	Type                  string             `json:"service_type"`
+	ExternalTrafficPolicy string             `json:"external_traffic_policy,omitempty"`
+	Host                  string             `json:"host,omitempty"`
+	Path                  string             `json:"request_path"`
+	Source                PodInfo            `json:"source"`
+	Probes                []GatewayPathProbe `json:"probes"`
+	Summary               map[string]string  `json:"summary"`       // pass, fail, partial or skipped per path
+	FailingLayer          string             `json:"failing_layer"` // none, mesh, kube-proxy, load_balancer or unknown
+	Issues                []string           `json:"issues,omitempty"`
+	Notes                 []string           `json:"notes,omitempty"`
+	Timestamp             time.Time          `json:"timestamp"`
+}
+
+// testGatewayPathsParams are the arguments of test_gateway_paths
+type testGatewayPathsParams struct {
+	GatewayNamespace string `json:"gateway_namespace,omitempty"` // default: istio-ingress
+	GatewayService   string `json:"gateway_service,omitempty"`   // default: istio-ingress
+	Host             string `json:"host,omitempty"`              // Host header for the request
+	Path             string `json:"path,omitempty"`              // default: /
+	Port             int    `json:"port,omitempty"`              // gateway service port, default: 80
+	SourcePod        string `json:"source_pod,omitempty"`        // default: first app=sleep pod
+	SourceNamespace  string `json:"source_namespace,omitempty"`  // default: default
+	Timeout          int    `json:"timeout,omitempty"`           // seconds per request, default: 5
+}
+
+// TestGatewayPaths sends the same request to an ingress gateway through its pods, ClusterIP, every node port
+// and its load balancer, and compares the outcomes to tell whether a failure lies in the mesh, kube-proxy or
+// the external load balancer
+func (m *Manager) TestGatewayPaths(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params testGatewayPathsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+	if params.GatewayService == "" {
+		params.GatewayService = "istio-ingress"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.Port == 0 {
+		params.Port = 80
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = "default"
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = 5
+	}
+
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(params.GatewayNamespace).Get(ctx, params.GatewayService, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get gateway service: %v", err),
+				},
+			},
+		}, nil
+	}
+	var servicePort *corev1.ServicePort
+	for i := range svc.Spec.Ports {
+		if int(svc.Spec.Ports[i].Port) == params.Port {
+			servicePort = &svc.Spec.Ports[i]
+			break
+		}
+	}
+	if servicePort == nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Service %s/%s has no port %d", params.GatewayNamespace, params.GatewayService, params.Port),
+				},
+			},
+		}, nil
+	}
+
+	source, err := m.findWaypointSource(ctx, params.SourceNamespace, params.SourcePod)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+	container := ""
+	for _, c := range source.Spec.Containers {
+		if c.Name != "istio-proxy" {
+			container = c.Name
+			break
+		}
+	}
+	sourceName := fmt.Sprintf("%s/%s", source.Namespace, source.Name)
+
+	result := &GatewayPathReport{
+		Gateway:               fmt.Sprintf("%s/%s", params.GatewayNamespace, params.GatewayService),
+		Type:                  string(svc.Spec.Type),
+		ExternalTrafficPolicy: string(svc.Spec.ExternalTrafficPolicy),
+		Host:                  params.Host,
+		Path:                  params.Path,
+		Source: PodInfo{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			IP:        source.Status.PodIP,
+			Node:      source.Spec.NodeName,
+		},
+		Summary:   make(map[string]string),
+		Timestamp: time.Now(),
+	}
+	if podHasSidecar(source) {
+		result.Notes = append(result.Notes, "The source pod has a sidecar; in-cluster requests leave through its Envoy as passthrough traffic")
+	}
+
+	inCluster := func(path, node, address string, port int) {
+		probe := GatewayPathProbe{Path: path, Target: net.JoinHostPort(address, strconv.Itoa(port)), Node: node, From: sourceName}
+		m.probeGatewayFromPod(ctx, source, container, params.Host, params.Path, params.Timeout, &probe)
+		result.Probes = append(result.Probes, probe)
+	}
+
+	// Gateway pods directly: only the gateway's own listeners and routes are involved
+	gatewayNodes := make(map[string]bool)
+	if len(svc.Spec.Selector) > 0 {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.GatewayNamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to list gateway pods: %v", err))
+		} else {
+			for i := range pods.Items {
+				pod := &pods.Items[i]
+				if pod.Status.Phase != corev1.PodRunning || pod.Status.PodIP == "" {
+					continue
+				}
+				gatewayNodes[pod.Spec.NodeName] = true
+				targetPort := servicePort.TargetPort.IntValue()
+				if targetPort == 0 {
+					targetPort = namedContainerPort(pod, servicePort.TargetPort.String())
+				}
+				if targetPort == 0 {
+					targetPort = int(servicePort.Port)
+				}
+				inCluster(gatewayPathPod, pod.Spec.NodeName, pod.Status.PodIP, targetPort)
+			}
+		}
+	}
+	if len(gatewayNodes) == 0 {
+		result.Issues = append(result.Issues, fmt.Sprintf("Gateway service %s has no running pods", result.Gateway))
+	}
+
+	// ClusterIP: adds the kube-proxy service rules
+	if svc.Spec.ClusterIP != "" && svc.Spec.ClusterIP != corev1.ClusterIPNone {
+		inCluster(gatewayPathClusterIP, "", svc.Spec.ClusterIP, int(servicePort.Port))
+	}
+
+	// Node ports on every node: adds the per-node kube-proxy node port rules and externalTrafficPolicy
+	if servicePort.NodePort != 0 {
+		nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to list nodes: %v", err))
+		} else {
+			sort.Slice(nodes.Items, func(i, j int) bool { return nodes.Items[i].Name < nodes.Items[j].Name })
+			for _, node := range nodes.Items {
+				for _, address := range node.Status.Addresses {
+					if address.Type == corev1.NodeInternalIP {
+						inCluster(gatewayPathNodePort, node.Name, address.Address, int(servicePort.NodePort))
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Load balancer from outside the cluster; from inside, kube-proxy short-circuits the external IP
+	for _, ingress := range svc.Status.LoadBalancer.Ingress {
+		address := ingress.IP
+		if address == "" {
+			address = ingress.Hostname
+		}
+		if address == "" {
+			continue
+		}
+		probe := GatewayPathProbe{Path: gatewayPathLoadBalancer, Target: net.JoinHostPort(address, strconv.Itoa(int(servicePort.Port))), From: "meshpilot host"}
+		probeGatewayFromHost(ctx, params.Host, params.Path, time.Duration(params.Timeout)*time.Second, &probe)
+		result.Probes = append(result.Probes, probe)
+	}
+
+	localPolicy := svc.Spec.ExternalTrafficPolicy == corev1.ServiceExternalTrafficPolicyLocal
+	result.FailingLayer = explainGatewayPaths(result, gatewayNodes, localPolicy)
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// probeGatewayFromPod curls the gateway over one path from the source pod
+func (m *Manager) probeGatewayFromPod(ctx context.Context, source *corev1.Pod, container, host, path string, timeout int, probe *GatewayPathProbe) {
+	command := []string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", strconv.Itoa(timeout)}
+	if host != "" {
+		command = append(command, "-H", "Host: "+host)
+	}
+	command = append(command, "http://"+probe.Target+path)
+
+	start := time.Now()
+	output, err := m.execCommandInPod(ctx, source.Namespace, source.Name, container, command)
+	probe.Duration = time.Since(start).Round(time.Millisecond).String()
+	code, _ := strconv.Atoi(strings.TrimSpace(output))
+	switch {
+	case code == 0 && err != nil:
+		probe.Error = fmt.Sprintf("no response: %v", err)
+	case code == 0:
+		probe.Error = "no response"
+	default:
+		probe.StatusCode = code
+		probe.Success = code >= 200 && code < 400
+	}
+}
+
+// probeGatewayFromHost sends the request to the gateway from the meshpilot host
+func probeGatewayFromHost(ctx context.Context, host, path string, timeout time.Duration, probe *GatewayPathProbe) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+probe.Target+path, nil)
+	if err != nil {
+		probe.Error = err.Error()
+		return
+	}
+	if host != "" {
+		req.Host = host
+	}
+	client := &http.Client{
+		Timeout:       timeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	probe.Duration = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		probe.Error = err.Error()
+		return
+	}
+	resp.Body.Close()
+	probe.StatusCode = resp.StatusCode
+	probe.Success = resp.StatusCode >= 200 && resp.StatusCode < 400
+}
+
+// explainGatewayPaths summarizes the probes per path and returns the innermost layer that fails; a node port
+// on a node without gateway pods is expected to fail under externalTrafficPolicy Local
+func explainGatewayPaths(result *GatewayPathReport, gatewayNodes map[string]bool, localPolicy bool) string {
+	passed := make(map[string]int)
+	failed := make(map[string]int)
+	var failedNodes []string
+	for _, probe := range result.Probes {
+		if probe.Success {
+			passed[probe.Path]++
+			continue
+		}
+		if probe.Path == gatewayPathNodePort && localPolicy && !gatewayNodes[probe.Node] {
+			result.Notes = append(result.Notes, fmt.Sprintf("Node port on %s does not answer because it runs no gateway pod and externalTrafficPolicy is Local; load balancer health checks should skip it", probe.Node))
+			continue
+		}
+		failed[probe.Path]++
+		if probe.Path == gatewayPathNodePort {
+			failedNodes = append(failedNodes, probe.Node)
+		}
+	}
+
+	for _, path := range []string{gatewayPathPod, gatewayPathClusterIP, gatewayPathNodePort, gatewayPathLoadBalancer} {
+		switch {
+		case passed[path] == 0 && failed[path] == 0:
+			result.Summary[path] = "skipped"
+		case failed[path] == 0:
+			result.Summary[path] = "pass"
+		case passed[path] == 0:
+			result.Summary[path] = "fail"
+		default:
+			result.Summary[path] = "partial"
+		}
+	}
+	if result.Summary[gatewayPathLoadBalancer] == "skipped" {
+		result.Notes = append(result.Notes, "The gateway service has no load balancer address, so the external path was not tested")
+	}
+
+	// The first layer that fails while the ones inside it pass is where the problem is
+	switch {
+	case failed[gatewayPathPod] > 0:
+		if passed[gatewayPathPod] > 0 {
+			result.Issues = append(result.Issues, "Some gateway pods fail the request while others serve it; compare their config with get_proxy_config or proxy_status")
+		} else {
+			result.Issues = append(result.Issues, "The gateway pods themselves fail the request, so the problem is in the mesh: check the Gateway and VirtualService/HTTPRoute for the host and path with diagnose_ingress_request")
+		}
+		return gatewayLayerMesh
+	case failed[gatewayPathClusterIP] > 0:
+		result.Issues = append(result.Issues, "The gateway pods answer but the ClusterIP does not, so the problem is in kube-proxy's service rules or the service's endpoints")
+		return gatewayLayerKubeProxy
+	case failed[gatewayPathNodePort] > 0:
+		result.Issues = append(result.Issues, fmt.Sprintf("The ClusterIP answers but the node port fails on %s, so the problem is kube-proxy's node port rules or a host firewall on those nodes", strings.Join(failedNodes, ", ")))
+		return gatewayLayerKubeProxy
+	case failed[gatewayPathLoadBalancer] > 0:
+		result.Issues = append(result.Issues, "Every in-cluster path answers but the load balancer address does not, so the problem is the external load balancer: its listeners, health checks or firewall, or the meshpilot host cannot route to it")
+		return gatewayLayerLoadBalancer
+	case len(passed) == 0:
+		return gatewayLayerUnknown
+	}
+	return gatewayLayerNone
+}
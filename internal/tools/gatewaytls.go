@@ -0,0 +1,594 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/http2"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// gatewayCertificateExpiryWarning is how close to expiry a served gateway certificate must be to be reported
+const gatewayCertificateExpiryWarning = 7 * 24 * time.Hour
+
+var (
+	istioGatewayGVR   = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "gateways"}
+	virtualServiceGVR = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "virtualservices"}
+	httpRouteGVR      = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "httproutes"}
+)
+
+// ServedCertificate summarizes the leaf certificate a gateway presented during a handshake
+type ServedCertificate struct {
+	Subject    string    `json:"subject"`
+	Issuer     string    `json:"issuer"`
+	DNSNames   []string  `json:"dns_names,omitempty"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	MatchesSNI bool      `json:"matches_sni"`
+}
+
+// TLSProbe is the outcome of one SNI/ALPN/Host combination against a gateway
+type TLSProbe struct {
+	SNI                string             `json:"sni"`
+	ALPN               []string           `json:"alpn,omitempty"`
+	Host               string             `json:"host"`
+	Handshake          bool               `json:"handshake"`
+	TLSVersion         string             `json:"tls_version,omitempty"`
+	NegotiatedProtocol string             `json:"negotiated_protocol,omitempty"`
+	Certificate        *ServedCertificate `json:"certificate,omitempty"`
+	StatusCode         int                `json:"status_code,omitempty"`
+	Servers            []string           `json:"matched_servers,omitempty"` // gateway servers or listeners whose hosts cover the SNI
+	Routes             []string           `json:"matched_routes,omitempty"`  // routes bound to the gateway whose hosts cover the Host header
+	Error              string             `json:"error,omitempty"`
+	Duration           string             `json:"duration,omitempty"`
+}
+
+// GatewayTLSReport is the result of probing a gateway with several TLS client configurations
+type GatewayTLSReport struct {
+	Gateway   string     `json:"gateway"`
+	Address   string     `json:"address"`
+	Via       string     `json:"via"`
+	Probes    []TLSProbe `json:"probes"`
+	Issues    []string   `json:"issues,omitempty"`
+	Notes     []string   `json:"notes,omitempty"`
+	Timestamp time.Time  `json:"timestamp"`
+}
+
+// gatewayServer is a host set the gateway terminates or passes through on the probed port
+type gatewayServer struct {
+	name   string
+	hosts  []string
+	tls    string
+	secret string
+}
+
+// gatewayRoute is a route bound to the gateway with the hosts it serves
+type gatewayRoute struct {
+	name  string
+	hosts []string
+}
+
+// istioGatewaySpec holds the parts of an Istio Gateway needed to match servers
+type istioGatewaySpec struct {
+	Selector map[string]string `json:"selector"`
+	Servers  []struct {
+		Name string `json:"name"`
+		Port struct {
+			Number   int    `json:"number"`
+			Protocol string `json:"protocol"`
+		} `json:"port"`
+		Hosts []string `json:"hosts"`
+		TLS   *struct {
+			Mode           string `json:"mode"`
+			CredentialName string `json:"credentialName"`
+		} `json:"tls"`
+	} `json:"servers"`
+}
+
+// kubeGatewaySpec holds the parts of a Gateway API Gateway needed to match listeners
+type kubeGatewaySpec struct {
+	Listeners []struct {
+		Name     string `json:"name"`
+		Hostname string `json:"hostname"`
+		Port     int    `json:"port"`
+		Protocol string `json:"protocol"`
+		TLS      *struct {
+			Mode            string `json:"mode"`
+			CertificateRefs []struct {
+				Name      string `json:"name"`
+				Namespace string `json:"namespace"`
+			} `json:"certificateRefs"`
+		} `json:"tls"`
+	} `json:"listeners"`
+}
+
+// probeGatewayTLSParams are the arguments of probe_gateway_tls
+type probeGatewayTLSParams struct {
+	GatewayNamespace string   `json:"gateway_namespace,omitempty"` // default: istio-ingress
+	GatewayService   string   `json:"gateway_service,omitempty"`   // default: istio-ingress
+	Port             int      `json:"port,omitempty"`              // gateway service port, default: 443
+	SNI              []string `json:"sni,omitempty"`               // default: hosts configured on the port, plus no SNI
+	ALPN             []string `json:"alpn,omitempty"`              // comma-separated protocol lists, default: "h2,http/1.1"
+	Hosts            []string `json:"hosts,omitempty"`             // Host headers, default: same as the SNI
+	Path             string   `json:"path,omitempty"`              // default: /
+	Timeout          int      `json:"timeout,omitempty"`           // seconds per probe
+}
+
+// ProbeGatewayTLS connects to the ingress gateway with each combination of SNI, ALPN and Host header
+// and reports the certificate served and the gateway servers and routes that should handle it
+func (m *Manager) ProbeGatewayTLS(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params probeGatewayTLSParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+	if params.GatewayService == "" {
+		params.GatewayService = "istio-ingress"
+	}
+	if params.Port == 0 {
+		params.Port = 443
+	}
+	if len(params.ALPN) == 0 {
+		params.ALPN = []string{"h2,http/1.1"}
+	}
+	if len(params.Hosts) == 0 {
+		params.Hosts = []string{""}
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 10
+	}
+
+	service, err := m.k8sClient.Kubernetes.CoreV1().Services(params.GatewayNamespace).Get(ctx, params.GatewayService, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get gateway service: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	address, port, via, err := m.resolveGatewayAddress(ctx, params.GatewayNamespace, params.GatewayService, params.Port)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to resolve ingress gateway address: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := GatewayTLSReport{
+		Gateway:   fmt.Sprintf("%s/%s", params.GatewayNamespace, params.GatewayService),
+		Address:   net.JoinHostPort(address, strconv.Itoa(port)),
+		Via:       via,
+		Timestamp: time.Now(),
+	}
+
+	servers, routes, notes := m.gatewayTLSConfig(ctx, service, params.Port)
+	report.Notes = append(report.Notes, notes...)
+	if len(servers) == 0 {
+		report.Notes = append(report.Notes, fmt.Sprintf("No Gateway servers or listeners for port %d were found for this service; route matching is not reported", params.Port))
+	}
+
+	if len(params.SNI) == 0 {
+		params.SNI = defaultProbeSNIs(servers)
+	}
+
+	timeout := time.Duration(params.Timeout) * time.Second
+	for _, sni := range params.SNI {
+		for _, alpn := range params.ALPN {
+			for _, host := range params.Hosts {
+				if host == "" {
+					host = sni
+				}
+				probe := probeGatewayTLS(ctx, report.Address, sni, splitList(alpn), host, params.Path, timeout)
+				probe.Servers, probe.Routes = matchGatewayConfig(servers, routes, sni, host)
+				report.Issues = append(report.Issues, tlsProbeIssues(probe, len(servers) > 0)...)
+				report.Probes = append(report.Probes, probe)
+			}
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// gatewayTLSConfig collects the Istio Gateway servers and Gateway API listeners served by the gateway
+// service on a port, and the routes bound to them
+func (m *Manager) gatewayTLSConfig(ctx context.Context, service *corev1.Service, servicePort int) ([]gatewayServer, []gatewayRoute, []string) {
+	var servers []gatewayServer
+	var routes []gatewayRoute
+	var notes []string
+
+	// Istio Gateway servers listen on the pod port, which may differ from the service port
+	ports := map[int]bool{servicePort: true}
+	for _, p := range service.Spec.Ports {
+		if int(p.Port) == servicePort && p.TargetPort.IntValue() != 0 {
+			ports[p.TargetPort.IntValue()] = true
+		}
+	}
+
+	// Istio Gateways select the gateway pods by label, usually from any namespace
+	var bound []string
+	if list, err := m.k8sClient.Dynamic.Resource(istioGatewayGVR).List(ctx, metav1.ListOptions{}); err != nil {
+		notes = append(notes, fmt.Sprintf("Could not list Istio Gateways: %v", err))
+	} else {
+		for _, item := range list.Items {
+			var spec istioGatewaySpec
+			if err := remarshal(item.Object["spec"], &spec); err != nil || len(spec.Selector) == 0 || !labels.SelectorFromSet(spec.Selector).Matches(labels.Set(service.Spec.Selector)) {
+				continue
+			}
+			name := item.GetNamespace() + "/" + item.GetName()
+			for i, server := range spec.Servers {
+				if !ports[server.Port.Number] {
+					continue
+				}
+				s := gatewayServer{name: fmt.Sprintf("%s[%d]", name, i), tls: "none"}
+				if server.Name != "" {
+					s.name = name + "/" + server.Name
+				}
+				for _, host := range server.Hosts {
+					// Hosts may be qualified with the namespaces allowed to bind routes, e.g. "prod/app.example.com"
+					if idx := strings.Index(host, "/"); idx >= 0 {
+						host = host[idx+1:]
+					}
+					s.hosts = append(s.hosts, host)
+				}
+				if server.TLS != nil {
+					s.tls = server.TLS.Mode
+					s.secret = server.TLS.CredentialName
+				}
+				servers = append(servers, s)
+				if !containsString(bound, name) {
+					bound = append(bound, name)
+				}
+			}
+		}
+	}
+	if len(bound) > 0 {
+		if list, err := m.k8sClient.Dynamic.Resource(virtualServiceGVR).List(ctx, metav1.ListOptions{}); err != nil {
+			notes = append(notes, fmt.Sprintf("Could not list VirtualServices: %v", err))
+		} else {
+			for _, item := range list.Items {
+				var spec struct {
+					Hosts    []string `json:"hosts"`
+					Gateways []string `json:"gateways"`
+				}
+				if err := remarshal(item.Object["spec"], &spec); err != nil {
+					continue
+				}
+				for _, gateway := range spec.Gateways {
+					if !strings.Contains(gateway, "/") {
+						gateway = item.GetNamespace() + "/" + gateway
+					}
+					if containsString(bound, gateway) {
+						routes = append(routes, gatewayRoute{name: "VirtualService " + item.GetNamespace() + "/" + item.GetName(), hosts: spec.Hosts})
+						break
+					}
+				}
+			}
+		}
+	}
+
+	// Istio labels the service it deploys for a Gateway API Gateway with the gateway's name
+	gatewayName := service.Labels["gateway.networking.k8s.io/gateway-name"]
+	if gatewayName == "" {
+		return servers, routes, notes
+	}
+	gateway, err := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(service.Namespace).Get(ctx, gatewayName, metav1.GetOptions{})
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("Could not get Gateway %s/%s: %v", service.Namespace, gatewayName, err))
+		return servers, routes, notes
+	}
+	var spec kubeGatewaySpec
+	if err := remarshal(gateway.Object["spec"], &spec); err != nil {
+		notes = append(notes, fmt.Sprintf("Could not parse Gateway %s/%s: %v", service.Namespace, gatewayName, err))
+		return servers, routes, notes
+	}
+	var listeners []string
+	for _, listener := range spec.Listeners {
+		if listener.Port != servicePort {
+			continue
+		}
+		s := gatewayServer{name: fmt.Sprintf("Gateway %s/%s/%s", service.Namespace, gatewayName, listener.Name), hosts: []string{"*"}, tls: "none"}
+		if listener.Hostname != "" {
+			s.hosts = []string{listener.Hostname}
+		}
+		if listener.TLS != nil {
+			s.tls = listener.TLS.Mode
+			if s.tls == "" {
+				s.tls = "Terminate"
+			}
+			if len(listener.TLS.CertificateRefs) > 0 {
+				s.secret = listener.TLS.CertificateRefs[0].Name
+			}
+		}
+		servers = append(servers, s)
+		listeners = append(listeners, listener.Name)
+	}
+	if len(listeners) == 0 {
+		return servers, routes, notes
+	}
+
+	list, err := m.k8sClient.Dynamic.Resource(httpRouteGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		notes = append(notes, fmt.Sprintf("Could not list HTTPRoutes: %v", err))
+		return servers, routes, notes
+	}
+	for _, item := range list.Items {
+		var route struct {
+			ParentRefs []struct {
+				Name        string `json:"name"`
+				Namespace   string `json:"namespace"`
+				SectionName string `json:"sectionName"`
+			} `json:"parentRefs"`
+			Hostnames []string `json:"hostnames"`
+		}
+		if err := remarshal(item.Object["spec"], &route); err != nil {
+			continue
+		}
+		for _, ref := range route.ParentRefs {
+			namespace := ref.Namespace
+			if namespace == "" {
+				namespace = item.GetNamespace()
+			}
+			if ref.Name != gatewayName || namespace != service.Namespace || (ref.SectionName != "" && !containsString(listeners, ref.SectionName)) {
+				continue
+			}
+			hosts := route.Hostnames
+			if len(hosts) == 0 {
+				hosts = []string{"*"}
+			}
+			routes = append(routes, gatewayRoute{name: "HTTPRoute " + item.GetNamespace() + "/" + item.GetName(), hosts: hosts})
+			break
+		}
+	}
+	return servers, routes, notes
+}
+
+// defaultProbeSNIs returns the hosts configured on TLS servers, with wildcards made concrete,
+// plus an empty SNI to show what clients that send none receive
+func defaultProbeSNIs(servers []gatewayServer) []string {
+	snis := []string{}
+	for _, server := range servers {
+		if server.tls == "none" {
+			continue
+		}
+		for _, host := range server.hosts {
+			if host == "*" {
+				continue
+			}
+			if strings.HasPrefix(host, "*.") {
+				host = "probe" + host[1:]
+			}
+			if !containsString(snis, host) {
+				snis = append(snis, host)
+			}
+		}
+	}
+	return append(snis, "")
+}
+
+// matchGatewayConfig returns the servers whose hosts cover the SNI and the routes whose hosts cover the Host header
+func matchGatewayConfig(servers []gatewayServer, routes []gatewayRoute, sni, host string) ([]string, []string) {
+	var matchedServers, matchedRoutes []string
+	for _, server := range servers {
+		for _, pattern := range server.hosts {
+			if sni != "" && hostMatches(pattern, sni) {
+				desc := fmt.Sprintf("%s (tls: %s", server.name, server.tls)
+				if server.secret != "" {
+					desc += ", credential: " + server.secret
+				}
+				matchedServers = append(matchedServers, desc+")")
+				break
+			}
+		}
+	}
+	hostname := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		hostname = h
+	}
+	for _, route := range routes {
+		for _, pattern := range route.hosts {
+			if hostname != "" && hostMatches(pattern, hostname) {
+				matchedRoutes = append(matchedRoutes, route.name)
+				break
+			}
+		}
+	}
+	return matchedServers, matchedRoutes
+}
+
+// hostMatches reports whether a host matches a gateway host pattern such as "*" or "*.example.com"
+func hostMatches(pattern, host string) bool {
+	pattern = strings.ToLower(pattern)
+	host = strings.ToLower(host)
+	switch {
+	case pattern == "*":
+		return true
+	case strings.HasPrefix(pattern, "*."):
+		return strings.HasSuffix(host, pattern[1:])
+	default:
+		return pattern == host
+	}
+}
+
+// probeGatewayTLS performs one handshake with the given SNI and ALPN, then sends a request with the Host header
+func probeGatewayTLS(ctx context.Context, address, sni string, alpn []string, host, path string, timeout time.Duration) (probe TLSProbe) {
+	probe = TLSProbe{SNI: sni, ALPN: alpn, Host: host}
+	start := time.Now()
+	defer func() {
+		probe.Duration = time.Since(start).Round(time.Millisecond).String()
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Verification is left to the report so mismatched or self-signed certificates can still be inspected
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			ServerName:         sni,
+			NextProtos:         alpn,
+			InsecureSkipVerify: true,
+		},
+	}
+	rawConn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		probe.Error = fmt.Sprintf("TLS handshake failed: %v", err)
+		return probe
+	}
+	conn := rawConn.(*tls.Conn)
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(timeout))
+
+	state := conn.ConnectionState()
+	probe.Handshake = true
+	probe.TLSVersion = tls.VersionName(state.Version)
+	probe.NegotiatedProtocol = state.NegotiatedProtocol
+	if len(state.PeerCertificates) > 0 {
+		probe.Certificate = servedCertificate(state.PeerCertificates[0], sni)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+address+path, nil)
+	if err != nil {
+		probe.Error = fmt.Sprintf("Invalid request: %v", err)
+		return probe
+	}
+	if host != "" {
+		req.Host = host
+	}
+
+	var resp *http.Response
+	if state.NegotiatedProtocol == "h2" {
+		var cc *http2.ClientConn
+		cc, err = (&http2.Transport{}).NewClientConn(conn)
+		if err == nil {
+			resp, err = cc.RoundTrip(req)
+		}
+	} else if err = req.Write(conn); err == nil {
+		resp, err = http.ReadResponse(bufio.NewReader(conn), req)
+	}
+	if err != nil {
+		probe.Error = fmt.Sprintf("Request failed: %v", err)
+		return probe
+	}
+	resp.Body.Close()
+	probe.StatusCode = resp.StatusCode
+	return probe
+}
+
+// servedCertificate summarizes a leaf certificate and whether it covers the SNI
+func servedCertificate(cert *x509.Certificate, sni string) *ServedCertificate {
+	return &ServedCertificate{
+		Subject:    cert.Subject.String(),
+		Issuer:     cert.Issuer.String(),
+		DNSNames:   cert.DNSNames,
+		NotBefore:  cert.NotBefore,
+		NotAfter:   cert.NotAfter,
+		MatchesSNI: sni != "" && cert.VerifyHostname(sni) == nil,
+	}
+}
+
+// tlsProbeIssues reports the problems a probe revealed
+func tlsProbeIssues(probe TLSProbe, configured bool) []string {
+	label := fmt.Sprintf("SNI %q", probe.SNI)
+	if probe.SNI == "" {
+		label = "no SNI"
+	}
+	if len(probe.ALPN) > 0 {
+		label += fmt.Sprintf(", ALPN %s", strings.Join(probe.ALPN, ","))
+	}
+	if probe.Host != probe.SNI {
+		label += fmt.Sprintf(", Host %q", probe.Host)
+	}
+
+	var issues []string
+	if !probe.Handshake {
+		if probe.SNI != "" && len(probe.Servers) > 0 {
+			issues = append(issues, fmt.Sprintf("%s: handshake failed although %s is configured for it; check that the credential secret exists in the gateway namespace: %s",
+				label, strings.Join(probe.Servers, ", "), probe.Error))
+		} else if probe.SNI != "" {
+			issues = append(issues, fmt.Sprintf("%s: %s", label, probe.Error))
+		}
+		return issues
+	}
+
+	if cert := probe.Certificate; cert != nil {
+		if probe.SNI != "" && !cert.MatchesSNI {
+			issues = append(issues, fmt.Sprintf("%s: served certificate %q does not cover the SNI (names: %s); the gateway may be falling back to another server's certificate",
+				label, cert.Subject, strings.Join(cert.DNSNames, ", ")))
+		}
+		if remaining := time.Until(cert.NotAfter); remaining <= 0 {
+			issues = append(issues, fmt.Sprintf("%s: served certificate %q expired at %s", label, cert.Subject, cert.NotAfter.Format(time.RFC3339)))
+		} else if remaining < gatewayCertificateExpiryWarning {
+			issues = append(issues, fmt.Sprintf("%s: served certificate %q expires in %s", label, cert.Subject, remaining.Round(time.Minute)))
+		}
+	}
+	if probe.SNI != "" && configured && len(probe.Servers) == 0 {
+		issues = append(issues, fmt.Sprintf("%s: no gateway server or listener on this port covers the SNI", label))
+	}
+	if probe.Host != "" && configured && len(probe.Routes) == 0 {
+		issues = append(issues, fmt.Sprintf("%s: no route bound to the gateway covers the Host header", label))
+	}
+	switch {
+	case probe.StatusCode == http.StatusMisdirectedRequest:
+		issues = append(issues, fmt.Sprintf("%s: gateway returned 421 Misdirected Request; the Host header does not belong to the server selected by the SNI", label))
+	case probe.StatusCode == http.StatusNotFound && len(probe.Routes) > 0:
+		issues = append(issues, fmt.Sprintf("%s: gateway returned 404 although %s covers the host; check the route's path matches and that it was accepted", label, strings.Join(probe.Routes, ", ")))
+	}
+	return issues
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,228 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// guardedTool describes what a destructive tool removes or runs in, so the guardrails can refuse it on
+// protected targets
+type guardedTool struct {
+	namespaces     map[string]string       // namespace parameters and their defaults
+	namespaceLists []string                // parameters listing namespaces the tool modifies
+	releases       []string                // Helm releases the tool always removes
+	flagReleases   map[string]string       // Helm releases removed when a boolean parameter is set
+	releaseParams  map[string]releaseParam // parameters naming a removed or rewritten Helm release
+	flagTargets    map[string]string       // other targets, always protected, removed when a boolean parameter is set
+	clusterParams  map[string]string       // parameters naming a cluster the tool deletes, and their defaults
+	readOnlyWhen   map[string]bool         // boolean parameter values under which the tool only plans or validates
+}
+
+// releaseParam is a parameter naming a Helm release a guarded tool removes or rewrites
+type releaseParam struct {
+	fallback string // release when the parameter is omitted
+	flag     string // boolean parameter that must be set for the release to be touched; empty when it always is
+}
+
+// guardedTools lists the destructive tools the guardrails apply to; cleanup_demo without a namespace only
+// removes what meshpilot created, so it is checked only when given one. apply_manifest also checks the
+// namespaces its documents name, once they are decoded (see checkManifestGuardrails)
+var guardedTools = map[string]guardedTool{
+	"uninstall_istio": {
+		namespaces:   map[string]string{"namespace": "istio-system", "gateway_namespace": "istio-ingress"},
+		releases:     []string{"istio-ingress", "ztunnel", "istiod", "istio-base"},
+		flagReleases: map[string]string{"uninstall_cni": "istio-cni"},
+		flagTargets:  map[string]string{"delete_crds": "Istio CRDs"},
+	},
+	"uninstall_sail_operator": {
+		namespaces:    map[string]string{"namespace": "sail-operator"},
+		releaseParams: map[string]releaseParam{"release_name": {fallback: "sail-operator"}},
+	},
+	"istio_canary_upgrade": {
+		namespaces:     map[string]string{"istio_namespace": "istio-system"},
+		namespaceLists: []string{"namespaces"},
+		releaseParams:  map[string]releaseParam{"old_release": {fallback: "istiod", flag: "remove_old_revision"}},
+	},
+	"migrate_istio_install": {
+		namespaces:     map[string]string{"istio_namespace": "istio-system", "sail_namespace": "sail-operator"},
+		namespaceLists: []string{"namespaces"},
+		readOnlyWhen:   map[string]bool{"execute": false},
+	},
+	"migrate_to_ambient": {
+		namespaceLists: []string{"namespaces"},
+		readOnlyWhen:   map[string]bool{"execute": false},
+	},
+	"set_injection_template": {
+		namespaces:    map[string]string{"namespace": "istio-system"},
+		releaseParams: map[string]releaseParam{"release": {fallback: "istiod"}},
+		readOnlyWhen:  map[string]bool{"dry_run": true},
+	},
+	"configure_discovery_selectors": {
+		namespaces:    map[string]string{"namespace": "istio-system"},
+		releaseParams: map[string]releaseParam{"release": {fallback: "istiod"}},
+		readOnlyWhen:  map[string]bool{"dry_run": true},
+	},
+	"apply_manifest": {
+		namespaces:   map[string]string{"namespace": "default"},
+		readOnlyWhen: map[string]bool{"dry_run": true},
+	},
+	"delete_dev_cluster":          {clusterParams: map[string]string{"name": "meshpilot"}},
+	"undeploy_sleep_app":          {namespaces: map[string]string{"namespace": "default"}},
+	"undeploy_httpbin_app":        {namespaces: map[string]string{"namespace": "default"}},
+	"undeploy_bookinfo_app":       {namespaces: map[string]string{"namespace": "default"}},
+	"cleanup_demo":                {namespaces: map[string]string{"namespace": scopeAllNamespaces}},
+	"delete_virtual_service":      {namespaces: map[string]string{"namespace": "default"}},
+	"delete_destination_rule":     {namespaces: map[string]string{"namespace": "default"}},
+	"delete_authorization_policy": {namespaces: map[string]string{"namespace": "default"}},
+	"delete_gateway_route":        {namespaces: map[string]string{"namespace": "default"}},
+	"exec_pod_command":            {namespaces: map[string]string{"namespace": "default"}},
+}
+
+// Guarded reports whether a tool is subject to the guardrails, and so takes the force parameter
+func Guarded(toolName string) bool {
+	_, ok := guardedTools[toolName]
+	return ok
+}
+
+// checkGuardrails refuses destructive tool calls that touch protected namespaces or Helm releases, unless the
+// call sets force and the server allows forcing
+func (m *Manager) checkGuardrails(toolName string, args json.RawMessage) error {
+	tool, ok := guardedTools[toolName]
+	if m.config.Guardrails.Disabled || !ok {
+		return nil
+	}
+
+	var values map[string]interface{}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &values); err != nil {
+			// The targets can't be checked, so the call must not run
+			return fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+	for name, value := range tool.readOnlyWhen {
+		if set, _ := values[name].(bool); set == value {
+			return nil
+		}
+	}
+
+	var namespaces []string
+	names := make([]string, 0, len(tool.namespaces))
+	for name := range tool.namespaces {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		namespace, _ := values[name].(string)
+		if namespace == "" {
+			namespace = tool.namespaces[name]
+		}
+		namespaces = append(namespaces, namespace)
+	}
+	for _, name := range tool.namespaceLists {
+		list, _ := values[name].([]interface{})
+		for _, item := range list {
+			if namespace, ok := item.(string); ok {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+
+	releases := append([]string{}, tool.releases...)
+	for name, release := range tool.flagReleases {
+		if set, _ := values[name].(bool); set {
+			releases = append(releases, release)
+		}
+	}
+	for name, param := range tool.releaseParams {
+		if set, _ := values[param.flag].(bool); param.flag != "" && !set {
+			continue
+		}
+		release, _ := values[name].(string)
+		if release == "" {
+			release = param.fallback
+		}
+		releases = append(releases, release)
+	}
+
+	var others []string
+	for name, target := range tool.flagTargets {
+		if set, _ := values[name].(bool); set {
+			others = append(others, target)
+		}
+	}
+	for name, fallback := range tool.clusterParams {
+		cluster, _ := values[name].(string)
+		if cluster == "" {
+			cluster = fallback
+		}
+		others = append(others, "dev cluster "+cluster)
+	}
+
+	force, _ := values["force"].(bool)
+	return m.guard(toolName, namespaces, releases, others, force)
+}
+
+// checkManifestGuardrails refuses to apply manifest documents that name a protected namespace, or are one,
+// unless forced; the namespace parameter the other documents default to is checked by checkGuardrails
+func (m *Manager) checkManifestGuardrails(objects []*unstructured.Unstructured, force bool) error {
+	if m.config.Guardrails.Disabled {
+		return nil
+	}
+	var namespaces []string
+	for _, obj := range objects {
+		if obj.GetNamespace() != "" {
+			namespaces = append(namespaces, obj.GetNamespace())
+		}
+		if obj.GetKind() == "Namespace" && obj.GetAPIVersion() == "v1" {
+			namespaces = append(namespaces, obj.GetName())
+		}
+	}
+	return m.guard("apply_manifest", namespaces, nil, nil, force)
+}
+
+// guard refuses a call whose protected namespaces, protected Helm releases or other targets are not empty,
+// unless force is set and allowed; a forced call is logged
+func (m *Manager) guard(toolName string, namespaces, releases, others []string, force bool) error {
+	guardrails := m.config.Guardrails
+
+	var targets []string
+	seen := map[string]bool{}
+	for _, namespace := range namespaces {
+		if containsString(guardrails.ProtectedNamespaces, namespace) && !seen["namespace "+namespace] {
+			seen["namespace "+namespace] = true
+			targets = append(targets, "namespace "+namespace)
+		}
+	}
+	sort.Strings(releases)
+	for _, release := range releases {
+		if containsString(guardrails.ProtectedReleases, release) && !seen["Helm release "+release] {
+			seen["Helm release "+release] = true
+			targets = append(targets, "Helm release "+release)
+		}
+	}
+	sort.Strings(others)
+	targets = append(targets, others...)
+	if len(targets) == 0 {
+		return nil
+	}
+
+	cluster := "the current context"
+	if m.kubeContext != "" {
+		cluster = "context " + m.kubeContext
+	}
+	switch {
+	case !force:
+		return fmt.Errorf("tool %s would modify protected %s in %s; check this is the intended cluster and set force to override",
+			toolName, strings.Join(targets, ", "), cluster)
+	case !guardrails.ForceAllowed():
+		return fmt.Errorf("tool %s would modify protected %s in %s, and this server does not allow force (guardrails.allow_force)",
+			toolName, strings.Join(targets, ", "), cluster)
+	}
+	logrus.Warnf("Guardrails overridden with force: %s modifies protected %s in %s", toolName, strings.Join(targets, ", "), cluster)
+	return nil
+}
@@ -0,0 +1,136 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"meshpilot/internal/config"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testGuardrails protects the default namespaces and releases
+var testGuardrails = config.GuardrailsConfig{
+	ProtectedNamespaces: []string{"istio-system", "kube-system"},
+	ProtectedReleases:   []string{"istio-base", "istiod", "istio-cni", "ztunnel"},
+}
+
+func TestCheckGuardrails(t *testing.T) {
+	forceRefused := testGuardrails
+	forceRefused.AllowForce = new(bool)
+	disabled := testGuardrails
+	disabled.Disabled = true
+	unprotected := config.GuardrailsConfig{}
+
+	tests := []struct {
+		name       string
+		guardrails config.GuardrailsConfig
+		context    string
+		tool       string
+		args       string
+		wantErr    []string // substrings of the refusal, empty when the call is allowed
+	}{
+		{name: "unguarded tool", guardrails: testGuardrails, tool: "get_pod_logs", args: `{"namespace": "istio-system"}`},
+		{name: "guardrails disabled", guardrails: disabled, tool: "uninstall_istio", args: `{}`},
+		{name: "defaulted protected targets", guardrails: testGuardrails, tool: "uninstall_istio", args: `{}`,
+			wantErr: []string{"namespace istio-system", "Helm release istiod", "Helm release istio-base", "in the current context", "set force to override"}},
+		{name: "flag release", guardrails: testGuardrails, tool: "uninstall_istio", args: `{"uninstall_cni": true}`,
+			wantErr: []string{"Helm release istio-cni"}},
+		{name: "CRDs named", guardrails: unprotected, tool: "uninstall_istio", args: `{"delete_crds": true}`,
+			wantErr: []string{"protected Istio CRDs"}},
+		{name: "CRDs kept", guardrails: unprotected, tool: "uninstall_istio", args: `{}`},
+		{name: "forced", guardrails: testGuardrails, tool: "uninstall_istio", args: `{"delete_crds": true, "force": true}`},
+		{name: "force not allowed", guardrails: forceRefused, tool: "uninstall_istio", args: `{"force": true}`,
+			wantErr: []string{"does not allow force (guardrails.allow_force)"}},
+		{name: "context named", guardrails: testGuardrails, context: "prod", tool: "exec_pod_command", args: `{"namespace": "kube-system"}`,
+			wantErr: []string{"namespace kube-system in context prod"}},
+		{name: "unprotected namespace", guardrails: testGuardrails, tool: "undeploy_sleep_app", args: `{}`},
+		{name: "protected namespace", guardrails: testGuardrails, tool: "undeploy_sleep_app", args: `{"namespace": "istio-system"}`,
+			wantErr: []string{"namespace istio-system"}},
+		{name: "all namespaces", guardrails: testGuardrails, tool: "cleanup_demo", args: `{}`},
+		{name: "unparsable arguments", guardrails: testGuardrails, tool: "uninstall_istio", args: `[]`,
+			wantErr: []string{"invalid arguments"}},
+		{name: "protected namespace listed", guardrails: testGuardrails, tool: "migrate_to_ambient", args: `{"namespaces": ["bookinfo", "kube-system"], "execute": true}`,
+			wantErr: []string{"namespace kube-system"}},
+		{name: "plan only", guardrails: testGuardrails, tool: "migrate_to_ambient", args: `{"namespaces": ["kube-system"]}`},
+		{name: "canary keeps the old revision", guardrails: testGuardrails, tool: "istio_canary_upgrade", args: `{"istio_namespace": "mesh", "namespaces": ["bookinfo"]}`},
+		{name: "canary removes the old revision", guardrails: testGuardrails, tool: "istio_canary_upgrade", args: `{"istio_namespace": "mesh", "namespaces": ["bookinfo"], "remove_old_revision": true}`,
+			wantErr: []string{"Helm release istiod"}},
+		{name: "istiod release rewritten", guardrails: testGuardrails, tool: "set_injection_template", args: `{"namespace": "mesh", "name": "hooks"}`,
+			wantErr: []string{"Helm release istiod"}},
+		{name: "dry run", guardrails: testGuardrails, tool: "configure_discovery_selectors", args: `{"namespaces": ["bookinfo"], "dry_run": true}`},
+		{name: "manifest namespace", guardrails: testGuardrails, tool: "apply_manifest", args: `{"manifest": "{}", "namespace": "istio-system"}`,
+			wantErr: []string{"namespace istio-system"}},
+		{name: "manifest default namespace", guardrails: testGuardrails, tool: "apply_manifest", args: `{"manifest": "{}"}`},
+		{name: "dev cluster", guardrails: unprotected, tool: "delete_dev_cluster", args: `{"name": "demo"}`,
+			wantErr: []string{"protected dev cluster demo"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{config: &config.Config{Guardrails: tt.guardrails}, kubeContext: tt.context}
+			err := m.checkGuardrails(tt.tool, json.RawMessage(tt.args))
+			if len(tt.wantErr) == 0 {
+				if err != nil {
+					t.Fatalf("checkGuardrails(%s, %s) refused: %v", tt.tool, tt.args, err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("checkGuardrails(%s, %s) allowed, want a refusal", tt.tool, tt.args)
+			}
+			for _, want := range tt.wantErr {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("checkGuardrails(%s, %s) = %v, want it to contain %q", tt.tool, tt.args, err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckManifestGuardrails(t *testing.T) {
+	object := func(apiVersion, kind, namespace, name string) *unstructured.Unstructured {
+		obj := &unstructured.Unstructured{}
+		obj.SetAPIVersion(apiVersion)
+		obj.SetKind(kind)
+		obj.SetNamespace(namespace)
+		obj.SetName(name)
+		return obj
+	}
+	tests := []struct {
+		name    string
+		objects []*unstructured.Unstructured
+		force   bool
+		wantErr string
+	}{
+		{name: "unprotected namespaces", objects: []*unstructured.Unstructured{
+			object("v1", "ConfigMap", "bookinfo", "settings"),
+			object("v1", "Namespace", "", "bookinfo"),
+			object("rbac.authorization.k8s.io/v1", "ClusterRole", "", "reader"),
+		}},
+		{name: "object in a protected namespace", objects: []*unstructured.Unstructured{
+			object("v1", "ConfigMap", "bookinfo", "settings"),
+			object("networking.istio.io/v1", "EnvoyFilter", "istio-system", "tap"),
+		}, wantErr: "namespace istio-system"},
+		{name: "protected namespace object", objects: []*unstructured.Unstructured{
+			object("v1", "Namespace", "", "kube-system"),
+		}, wantErr: "namespace kube-system"},
+		{name: "forced", objects: []*unstructured.Unstructured{
+			object("networking.istio.io/v1", "EnvoyFilter", "istio-system", "tap"),
+		}, force: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{config: &config.Config{Guardrails: testGuardrails}}
+			err := m.checkManifestGuardrails(tt.objects, tt.force)
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("checkManifestGuardrails refused: %v", err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("checkManifestGuardrails allowed, want refusal containing %q", tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("checkManifestGuardrails = %v, want refusal containing %q", err, tt.wantErr)
+			}
+		})
+	}
+}
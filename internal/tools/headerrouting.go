@@ -0,0 +1,480 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var destinationRuleGVR = schema.GroupVersionResource{Group: "networking.istio.io", Version: "v1beta1", Resource: "destinationrules"}
+
+// versionPattern finds a version in response bodies such as helloworld's "Hello version: v2"
+var versionPattern = regexp.MustCompile(`(?i)"?version"?\s*[:=]\s*"?([A-Za-z0-9][\w.-]*)`)
+
+// HeaderRoutingResult reports which backend versions answered requests carrying the given headers
+type HeaderRoutingResult struct {
+	Service          string            `json:"service"`
+	Source           PodInfo           `json:"source"`
+	Headers          map[string]string `json:"headers,omitempty"`
+	Cookies          map[string]string `json:"cookies,omitempty"`
+	RequestsSent     int               `json:"requests_sent"`
+	ResponseCodes    map[string]int    `json:"response_codes"`
+	Versions         map[string]int    `json:"versions"` // backend version that answered, "unidentified" when none was found
+	MatchedRoute     string            `json:"matched_route,omitempty"`
+	ExpectedVersions []string          `json:"expected_versions,omitempty"`
+	Issues           []string          `json:"issues,omitempty"`
+	Notes            []string          `json:"notes,omitempty"`
+	Timestamp        time.Time         `json:"timestamp"`
+}
+
+// virtualServiceSpec holds the parts of a VirtualService needed to evaluate HTTP match rules
+type virtualServiceSpec struct {
+	Hosts    []string `json:"hosts"`
+	Gateways []string `json:"gateways"`
+	HTTP     []struct {
+		Name  string `json:"name"`
+		Match []struct {
+			Name           string                 `json:"name"`
+			URI            *stringMatch           `json:"uri"`
+			Method         *stringMatch           `json:"method"`
+			Headers        map[string]stringMatch `json:"headers"`
+			WithoutHeaders map[string]stringMatch `json:"withoutHeaders"`
+			QueryParams    map[string]stringMatch `json:"queryParams"`
+			SourceLabels   map[string]string      `json:"sourceLabels"`
+		} `json:"match"`
+		Route []struct {
+			Destination struct {
+				Host   string `json:"host"`
+				Subset string `json:"subset"`
+			} `json:"destination"`
+			Weight int `json:"weight"`
+		} `json:"route"`
+	} `json:"http"`
+}
+
+// stringMatch is an Istio StringMatch; exactly one field is set
+type stringMatch struct {
+	Exact  string `json:"exact,omitempty"`
+	Prefix string `json:"prefix,omitempty"`
+	Regex  string `json:"regex,omitempty"`
+}
+
+// matches evaluates the match against a value; Istio regexes must match the whole value
+func (s stringMatch) matches(value string) bool {
+	switch {
+	case s.Exact != "":
+		return value == s.Exact
+	case s.Prefix != "":
+		return strings.HasPrefix(value, s.Prefix)
+	case s.Regex != "":
+		re, err := regexp.Compile("^(?:" + s.Regex + ")$")
+		return err == nil && re.MatchString(value)
+	default:
+		return true
+	}
+}
+
+// testHeaderRoutingParams are the arguments of test_header_routing
+type testHeaderRoutingParams struct {
+	Service         string            `json:"service"`
+	Namespace       string            `json:"namespace,omitempty"`
+	Port            int               `json:"port,omitempty"`             // default: first service port
+	Path            string            `json:"path,omitempty"`             // default: /
+	Headers         map[string]string `json:"headers,omitempty"`          // request headers, e.g. {"end-user": "jason"}
+	Cookies         map[string]string `json:"cookies,omitempty"`          // request cookies
+	VersionHeader   string            `json:"version_header,omitempty"`   // response header naming the backend version
+	Requests        int               `json:"requests,omitempty"`         // default: 10
+	SourcePod       string            `json:"source_pod,omitempty"`       // default: first app=sleep pod
+	SourceNamespace string            `json:"source_namespace,omitempty"` // default: namespace
+	SourceContainer string            `json:"source_container,omitempty"` // default: first non-proxy container
+	Timeout         int               `json:"timeout,omitempty"`          // seconds per request, default: 5
+}
+
+// TestHeaderRouting sends requests with the given headers and cookies from a sleep pod and reports
+// which backend versions answered, compared with what the VirtualService match rules select
+func (m *Manager) TestHeaderRouting(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params testHeaderRoutingParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Service == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "service is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.Requests <= 0 {
+		params.Requests = 10
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = 5
+	}
+
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Get(ctx, params.Service, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get service: %v", err),
+				},
+			},
+		}, nil
+	}
+	if params.Port == 0 {
+		if len(svc.Spec.Ports) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Service %s/%s has no ports", svc.Namespace, svc.Name),
+					},
+				},
+			}, nil
+		}
+		params.Port = int(svc.Spec.Ports[0].Port)
+	}
+
+	source, err := m.findWaypointSource(ctx, params.SourceNamespace, params.SourcePod)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if params.SourceContainer == "" {
+		for _, container := range source.Spec.Containers {
+			if container.Name != "istio-proxy" {
+				params.SourceContainer = container.Name
+				break
+			}
+		}
+	}
+
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+	result := &HeaderRoutingResult{
+		Service: fmt.Sprintf("%s:%d", host, params.Port),
+		Source: PodInfo{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			IP:        source.Status.PodIP,
+			Node:      source.Spec.NodeName,
+		},
+		Headers:       params.Headers,
+		Cookies:       params.Cookies,
+		ResponseCodes: make(map[string]int),
+		Versions:      make(map[string]int),
+		Timestamp:     time.Now(),
+	}
+
+	// Backend pods let responses that carry a pod name be attributed to a version
+	var backends []corev1.Pod
+	if len(svc.Spec.Selector) > 0 {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err == nil {
+			backends = pods.Items
+		}
+	}
+
+	// Cookies reach Envoy as a Cookie header, which is what VirtualService cookie matches inspect
+	requestHeaders := make(map[string]string)
+	for name, value := range params.Headers {
+		requestHeaders[strings.ToLower(name)] = value
+	}
+	var cookies []string
+	for name, value := range params.Cookies {
+		cookies = append(cookies, name+"="+value)
+	}
+	sort.Strings(cookies)
+	if len(cookies) > 0 {
+		requestHeaders["cookie"] = strings.Join(cookies, "; ")
+	}
+
+	route, expected, notes, err := m.expectedHeaderRoute(ctx, svc, source, params.Path, requestHeaders)
+	if err != nil {
+		result.Notes = append(result.Notes, fmt.Sprintf("Could not evaluate VirtualService rules: %v", err))
+	}
+	result.MatchedRoute = route
+	result.ExpectedVersions = expected
+	result.Notes = append(result.Notes, notes...)
+
+	command := []string{"curl", "-s", "-D", "-", "-w", "\\nHTTP_CODE:%{http_code}\\n", "--max-time", strconv.Itoa(params.Timeout)}
+	for name, value := range params.Headers {
+		command = append(command, "-H", fmt.Sprintf("%s: %s", name, value))
+	}
+	if len(cookies) > 0 {
+		command = append(command, "-b", strings.Join(cookies, "; "))
+	}
+	command = append(command, fmt.Sprintf("http://%s:%d%s", host, params.Port, params.Path))
+
+	for i := 0; i < params.Requests; i++ {
+		output, err := m.execCommandInPod(ctx, source.Namespace, source.Name, params.SourceContainer, command)
+		result.RequestsSent++
+
+		code := "error"
+		if idx := strings.LastIndex(output, "HTTP_CODE:"); idx >= 0 {
+			code = strings.TrimSpace(output[idx+len("HTTP_CODE:"):])
+			output = output[:idx]
+		} else if err == nil {
+			code = "000"
+		}
+		result.ResponseCodes[code]++
+		if code == "error" || code == "000" {
+			continue
+		}
+		result.Versions[responseVersion(output, params.VersionHeader, backends)]++
+	}
+
+	answered := result.RequestsSent - result.ResponseCodes["000"] - result.ResponseCodes["error"]
+	unidentified := result.Versions["unidentified"]
+	switch {
+	case answered == 0:
+		result.Issues = append(result.Issues, fmt.Sprintf("None of the %d requests to %s received a response", result.RequestsSent, result.Service))
+	case unidentified == answered:
+		result.Notes = append(result.Notes, "No response identified the backend version; use an endpoint that returns the version or pod name (such as helloworld's /hello) or set version_header")
+	}
+	if len(expected) > 0 {
+		var unexpected []string
+		for version, count := range result.Versions {
+			if version != "unidentified" && !containsString(expected, version) {
+				unexpected = append(unexpected, fmt.Sprintf("%s (%d)", version, count))
+			}
+		}
+		sort.Strings(unexpected)
+		if len(unexpected) > 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("Requests should be routed to %s by %s but were answered by %s",
+				strings.Join(expected, ", "), route, strings.Join(unexpected, ", ")))
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// expectedHeaderRoute evaluates the mesh VirtualServices for a service in order and returns the first
+// HTTP route matching the request, with the versions its destination subsets select
+func (m *Manager) expectedHeaderRoute(ctx context.Context, svc *corev1.Service, source *corev1.Pod, path string, headers map[string]string) (string, []string, []string, error) {
+	list, err := m.k8sClient.Dynamic.Resource(virtualServiceGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+	uri := path
+	if idx := strings.IndexAny(uri, "?#"); idx >= 0 {
+		uri = uri[:idx]
+	}
+
+	var notes []string
+	for _, item := range list.Items {
+		var spec virtualServiceSpec
+		if err := remarshal(item.Object["spec"], &spec); err != nil {
+			continue
+		}
+		if !appliesToMesh(spec.Gateways) || !virtualServiceHostMatches(spec.Hosts, item.GetNamespace(), host) {
+			continue
+		}
+		name := item.GetNamespace() + "/" + item.GetName()
+
+		for i, httpRoute := range spec.HTTP {
+			matched := len(httpRoute.Match) == 0
+			matchName := ""
+			for _, match := range httpRoute.Match {
+				if len(match.QueryParams) > 0 {
+					notes = append(notes, fmt.Sprintf("%s http[%d] also matches query parameters, which were not evaluated", name, i))
+				}
+				ok := (match.URI == nil || match.URI.matches(uri)) &&
+					(match.Method == nil || match.Method.matches("GET")) &&
+					labels.SelectorFromSet(match.SourceLabels).Matches(labels.Set(source.Labels))
+				for header, condition := range match.Headers {
+					value, present := headers[strings.ToLower(header)]
+					ok = ok && present && condition.matches(value)
+				}
+				for header, condition := range match.WithoutHeaders {
+					value, present := headers[strings.ToLower(header)]
+					ok = ok && !(present && condition.matches(value))
+				}
+				if ok {
+					matched = true
+					matchName = match.Name
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+
+			routeName := fmt.Sprintf("VirtualService %s http[%d]", name, i)
+			if httpRoute.Name != "" {
+				routeName = fmt.Sprintf("VirtualService %s route %q", name, httpRoute.Name)
+			}
+			if matchName != "" {
+				routeName += fmt.Sprintf(" (match %q)", matchName)
+			}
+
+			var versions []string
+			for _, destination := range httpRoute.Route {
+				if len(httpRoute.Route) > 1 && destination.Weight == 0 {
+					continue
+				}
+				if destination.Destination.Subset == "" {
+					notes = append(notes, fmt.Sprintf("%s sends to %s without a subset, so any version may answer", routeName, destination.Destination.Host))
+					return routeName, nil, notes, nil
+				}
+				version, err := m.subsetVersion(ctx, item.GetNamespace(), destination.Destination.Host, destination.Destination.Subset)
+				if err != nil {
+					notes = append(notes, err.Error())
+					return routeName, nil, notes, nil
+				}
+				if !containsString(versions, version) {
+					versions = append(versions, version)
+				}
+			}
+			return routeName, versions, notes, nil
+		}
+	}
+	notes = append(notes, "No VirtualService route matches these requests; they are load balanced across all versions")
+	return "", nil, notes, nil
+}
+
+// subsetVersion resolves a DestinationRule subset to the version label it selects
+func (m *Manager) subsetVersion(ctx context.Context, namespace, host, subset string) (string, error) {
+	fqdn := qualifyHost(host, namespace)
+	list, err := m.k8sClient.Dynamic.Resource(destinationRuleGVR).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return "", fmt.Errorf("Could not list DestinationRules: %v", err)
+	}
+	for _, item := range list.Items {
+		var spec struct {
+			Host    string `json:"host"`
+			Subsets []struct {
+				Name   string            `json:"name"`
+				Labels map[string]string `json:"labels"`
+			} `json:"subsets"`
+		}
+		if err := remarshal(item.Object["spec"], &spec); err != nil || qualifyHost(spec.Host, item.GetNamespace()) != fqdn {
+			continue
+		}
+		for _, s := range spec.Subsets {
+			if s.Name != subset {
+				continue
+			}
+			if version, ok := s.Labels["version"]; ok {
+				return version, nil
+			}
+			return labels.Set(s.Labels).String(), nil
+		}
+	}
+	return "", fmt.Errorf("No DestinationRule defines subset %q for %s; requests routed to it fail with 503", subset, fqdn)
+}
+
+// responseVersion identifies the backend version from a curl response with headers (-D -)
+func responseVersion(output, versionHeader string, backends []corev1.Pod) string {
+	headers, body := output, ""
+	if idx := strings.Index(output, "\r\n\r\n"); idx >= 0 {
+		headers, body = output[:idx], output[idx+4:]
+	}
+	if versionHeader != "" {
+		for _, line := range strings.Split(headers, "\n") {
+			name, value, found := strings.Cut(line, ":")
+			if found && strings.EqualFold(strings.TrimSpace(name), versionHeader) {
+				return strings.TrimSpace(value)
+			}
+		}
+	}
+	for _, pod := range backends {
+		if strings.Contains(output, pod.Name) {
+			if version := pod.Labels["version"]; version != "" {
+				return version
+			}
+			if version := pod.Labels["app.kubernetes.io/version"]; version != "" {
+				return version
+			}
+			return pod.Name
+		}
+	}
+	if match := versionPattern.FindStringSubmatch(body); match != nil {
+		return match[1]
+	}
+	return "unidentified"
+}
+
+// appliesToMesh reports whether a VirtualService with these gateways applies to sidecar and waypoint traffic
+func appliesToMesh(gateways []string) bool {
+	return len(gateways) == 0 || containsString(gateways, "mesh")
+}
+
+// virtualServiceHostMatches reports whether any VirtualService host, resolved in its namespace, covers a service FQDN
+func virtualServiceHostMatches(hosts []string, namespace, fqdn string) bool {
+	for _, host := range hosts {
+		if hostMatches(qualifyHost(host, namespace), fqdn) {
+			return true
+		}
+	}
+	return false
+}
+
+// qualifyHost expands a short service name such as "reviews" to its FQDN in the given namespace
+func qualifyHost(host, namespace string) string {
+	switch {
+	case host == "*" || strings.HasPrefix(host, "*."):
+		return host
+	case strings.Count(host, ".") == 1:
+		return host + ".svc.cluster.local"
+	case strings.Contains(host, "."):
+		return host
+	}
+	return fmt.Sprintf("%s.%s.svc.cluster.local", host, namespace)
+}
@@ -0,0 +1,440 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"meshpilot/internal/config"
+
+	"github.com/sirupsen/logrus"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+	helmrepo "helm.sh/helm/v3/pkg/repo"
+	"helm.sh/helm/v3/pkg/storage/driver"
+	"helm.sh/helm/v3/pkg/strvals"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/client-go/tools/clientcmd"
+	clientcmdapi "k8s.io/client-go/tools/clientcmd/api"
+)
+
+// resolveChartRepository applies a per-call repository URL override to a configured repository
+func resolveChartRepository(repo config.ChartRepository, repoURL string) config.ChartRepository {
+	if repoURL != "" && repoURL != repo.URL {
+		repo.URL = repoURL
+		// Credentials configured for the original repository don't apply to the override
+		repo.Username = ""
+		repo.Password = ""
+		repo.PasswordEnv = ""
+	}
+	return repo
+}
+
+// runHelmStep runs a Helm call that takes no context, returning ctx's error as soon as ctx is done; Helm
+// cannot abort such calls, so a cancelled one finishes in the background and its result is dropped
+func runHelmStep(ctx context.Context, step func() error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- step()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// addHelmRepo makes a chart repository available to Helm, adding and updating
+// classic repositories or logging in to OCI registries when credentials are configured
+func (m *Manager) addHelmRepo(ctx context.Context, repo config.ChartRepository) error {
+	settings := cli.New()
+	password := repo.ResolvePassword()
+
+	if repo.IsOCI() {
+		// OCI charts are pulled directly; only authenticated registries need a login
+		if repo.Username == "" {
+			return nil
+		}
+		registryClient, err := registry.NewClient(
+			registry.ClientOptCredentialsFile(settings.RegistryConfig),
+			registry.ClientOptWriter(io.Discard),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to create registry client: %w", err)
+		}
+		err = runHelmStep(ctx, func() error {
+			return registryClient.Login(repo.Registry(),
+				registry.LoginOptBasicAuth(repo.Username, password),
+				registry.LoginOptInsecure(repo.InsecureSkipTLSVerify),
+				registry.LoginOptTLSClientConfig("", "", repo.CAFile),
+			)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to log in to registry %s: %w", repo.Registry(), err)
+		}
+		return nil
+	}
+
+	entry := &helmrepo.Entry{
+		Name:                  repo.Name,
+		URL:                   repo.URL,
+		Username:              repo.Username,
+		Password:              password,
+		CAFile:                repo.CAFile,
+		InsecureSkipTLSverify: repo.InsecureSkipTLSVerify,
+	}
+	if repo.Username == "" {
+		entry.Password = ""
+	}
+
+	// Download the index first so a bad URL or credentials never reach the repositories file
+	chartRepo, err := helmrepo.NewChartRepository(entry, getter.All(settings))
+	if err != nil {
+		return fmt.Errorf("failed to add %s helm repo: %w", repo.Name, err)
+	}
+	chartRepo.CachePath = settings.RepositoryCache
+	err = runHelmStep(ctx, func() error {
+		_, err := chartRepo.DownloadIndexFile()
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to update %s helm repo: %w", repo.Name, err)
+	}
+
+	// Add the repository, replacing the URL if a repository with the same name exists
+	repoFile, err := helmrepo.LoadFile(settings.RepositoryConfig)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to read helm repositories file: %w", err)
+	}
+	repoFile.Update(entry)
+	if err := os.MkdirAll(filepath.Dir(settings.RepositoryConfig), 0755); err != nil {
+		return fmt.Errorf("failed to create helm config directory: %w", err)
+	}
+	if err := repoFile.WriteFile(settings.RepositoryConfig, 0600); err != nil {
+		return fmt.Errorf("failed to write helm repositories file: %w", err)
+	}
+
+	return nil
+}
+
+// getChartAppVersion returns the appVersion of a chart, used as the default image tag
+func (m *Manager) getChartAppVersion(ctx context.Context, chartRef, version string) (string, error) {
+	cfg, settings, err := m.helmActionConfig(metav1.NamespaceDefault)
+	if err != nil {
+		return "", err
+	}
+
+	show := action.NewShowWithConfig(action.ShowChart, cfg)
+	show.Version = version
+	loaded, err := loadHelmChart(ctx, &show.ChartPathOptions, chartRef, settings)
+	if err != nil {
+		return "", err
+	}
+
+	if loaded.Metadata == nil || loaded.Metadata.AppVersion == "" {
+		return "", fmt.Errorf("chart %s has no appVersion", chartRef)
+	}
+	return loaded.Metadata.AppVersion, nil
+}
+
+// helmRepositoryCache returns the directory holding downloaded repository indexes
+func helmRepositoryCache() string {
+	return cli.New().RepositoryCache
+}
+
+// helmDriver is the Helm release storage backend, matching the helm CLI default
+const helmDriver = "secret"
+
+// defaultHelmTimeout applies to hooks and waits when no timeout is given, matching the helm CLI default
+const defaultHelmTimeout = 5 * time.Minute
+
+// HelmRelease describes the state of an installed Helm release
+type HelmRelease struct {
+	Name       string `json:"name"`
+	Namespace  string `json:"namespace"`
+	Chart      string `json:"chart"`
+	AppVersion string `json:"app_version,omitempty"`
+	Revision   int    `json:"revision"`
+	Status     string `json:"status"`
+	Updated    string `json:"updated,omitempty"`
+}
+
+// newHelmRelease converts a release returned by the Helm SDK
+func newHelmRelease(rel *release.Release) *HelmRelease {
+	info := &HelmRelease{
+		Name:      rel.Name,
+		Namespace: rel.Namespace,
+		Revision:  rel.Version,
+	}
+	if rel.Chart != nil && rel.Chart.Metadata != nil {
+		info.Chart = fmt.Sprintf("%s-%s", rel.Chart.Metadata.Name, rel.Chart.Metadata.Version)
+		info.AppVersion = rel.Chart.Metadata.AppVersion
+	}
+	if rel.Info != nil {
+		info.Status = rel.Info.Status.String()
+		if !rel.Info.LastDeployed.IsZero() {
+			info.Updated = rel.Info.LastDeployed.UTC().Format(time.RFC3339)
+		}
+	}
+	return info
+}
+
+// String summarizes the release for logs
+func (r *HelmRelease) String() string {
+	return fmt.Sprintf("%s/%s %s revision %d (%s)", r.Namespace, r.Name, r.Chart, r.Revision, r.Status)
+}
+
+// restConfigGetter hands the manager's Kubernetes client config to Helm, so releases are
+// managed on the same cluster and context as every other tool
+type restConfigGetter struct {
+	config    *rest.Config
+	namespace string
+}
+
+func (g *restConfigGetter) ToRESTConfig() (*rest.Config, error) {
+	return rest.CopyConfig(g.config), nil
+}
+
+func (g *restConfigGetter) ToDiscoveryClient() (discovery.CachedDiscoveryInterface, error) {
+	client, err := discovery.NewDiscoveryClientForConfig(rest.CopyConfig(g.config))
+	if err != nil {
+		return nil, err
+	}
+	return memory.NewMemCacheClient(client), nil
+}
+
+func (g *restConfigGetter) ToRESTMapper() (meta.RESTMapper, error) {
+	client, err := g.ToDiscoveryClient()
+	if err != nil {
+		return nil, err
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(client)
+	return restmapper.NewShortcutExpander(mapper, client, nil), nil
+}
+
+// ToRawKubeConfigLoader only supplies the release namespace; connection details come from ToRESTConfig
+func (g *restConfigGetter) ToRawKubeConfigLoader() clientcmd.ClientConfig {
+	overrides := &clientcmd.ConfigOverrides{Context: clientcmdapi.Context{Namespace: g.namespace}}
+	return clientcmd.NewDefaultClientConfig(*clientcmdapi.NewConfig(), overrides)
+}
+
+// helmActionConfig prepares the Helm SDK for actions on releases in a namespace, along with the
+// environment settings that locate the repository config, cache and registry credentials
+func (m *Manager) helmActionConfig(namespace string) (*action.Configuration, *cli.EnvSettings, error) {
+	if m.k8sClient == nil || m.k8sClient.Config == nil {
+		return nil, nil, fmt.Errorf("no Kubernetes client configuration")
+	}
+	settings := cli.New()
+
+	cfg := new(action.Configuration)
+	getter := &restConfigGetter{config: m.k8sClient.Config, namespace: namespace}
+	if err := cfg.Init(getter, namespace, helmDriver, logrus.Debugf); err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize Helm: %w", err)
+	}
+
+	registryClient, err := registry.NewClient(
+		registry.ClientOptCredentialsFile(settings.RegistryConfig),
+		registry.ClientOptWriter(io.Discard),
+	)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create registry client: %w", err)
+	}
+	cfg.RegistryClient = registryClient
+
+	return cfg, settings, nil
+}
+
+// checkHelmAvailable checks that Helm can reach the cluster through the manager's Kubernetes client
+func (m *Manager) checkHelmAvailable() error {
+	cfg, _, err := m.helmActionConfig(metav1.NamespaceDefault)
+	if err != nil {
+		return err
+	}
+	if err := cfg.KubeClient.IsReachable(); err != nil {
+		return fmt.Errorf("cluster unreachable: %w", err)
+	}
+	return nil
+}
+
+// parseHelmTimeout parses a Helm timeout such as "5m" or "300s", defaulting to five minutes
+func parseHelmTimeout(timeout string) (time.Duration, error) {
+	if timeout == "" {
+		return defaultHelmTimeout, nil
+	}
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout %q: %w", timeout, err)
+	}
+	return duration, nil
+}
+
+// helmSetValues turns dotted keys into nested chart values, with the same semantics as helm --set-json
+func helmSetValues(values map[string]interface{}) (map[string]interface{}, error) {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make(map[string]interface{})
+	for _, key := range keys {
+		valueJSON, err := json.Marshal(values[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal value for key %s: %w", key, err)
+		}
+		if err := strvals.ParseJSON(fmt.Sprintf("%s=%s", key, string(valueJSON)), result); err != nil {
+			return nil, fmt.Errorf("invalid value for key %s: %w", key, err)
+		}
+	}
+	return result, nil
+}
+
+// helmChartRequest describes a chart to install or upgrade
+type helmChartRequest struct {
+	Release         string
+	Chart           string // repo/chart reference or oci:// URL
+	Namespace       string
+	Version         string                 // chart version, latest when empty
+	Values          map[string]interface{} // dotted keys, applied like --set-json
+	CreateNamespace bool
+	Wait            bool
+	Timeout         string
+	Upgrade         bool // upgrade the release when it exists, like helm upgrade --install
+	ReuseValues     bool // keep the values of the previous revision when upgrading
+}
+
+// installHelmChart installs a chart, or upgrades the existing release when requested, and
+// returns the resulting release
+func (m *Manager) installHelmChart(ctx context.Context, req helmChartRequest) (*HelmRelease, error) {
+	cfg, settings, err := m.helmActionConfig(req.Namespace)
+	if err != nil {
+		return nil, err
+	}
+	values, err := helmSetValues(req.Values)
+	if err != nil {
+		return nil, err
+	}
+	timeout, err := parseHelmTimeout(req.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Upgrade {
+		_, err := action.NewHistory(cfg).Run(req.Release)
+		if err == nil {
+			upgrade := action.NewUpgrade(cfg)
+			upgrade.Namespace = req.Namespace
+			upgrade.Version = req.Version
+			upgrade.ReuseValues = req.ReuseValues
+			upgrade.Wait = req.Wait
+			upgrade.Timeout = timeout
+
+			loaded, err := loadHelmChart(ctx, &upgrade.ChartPathOptions, req.Chart, settings)
+			if err != nil {
+				return nil, err
+			}
+			rel, err := upgrade.RunWithContext(ctx, req.Release, loaded, values)
+			if err != nil {
+				return nil, fmt.Errorf("helm upgrade %s failed: %w", req.Release, err)
+			}
+			return newHelmRelease(rel), nil
+		}
+		if !errors.Is(err, driver.ErrReleaseNotFound) {
+			return nil, fmt.Errorf("failed to read history of release %s: %w", req.Release, err)
+		}
+	}
+
+	install := action.NewInstall(cfg)
+	install.ReleaseName = req.Release
+	install.Namespace = req.Namespace
+	install.Version = req.Version
+	install.CreateNamespace = req.CreateNamespace
+	install.Wait = req.Wait
+	install.Timeout = timeout
+
+	loaded, err := loadHelmChart(ctx, &install.ChartPathOptions, req.Chart, settings)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := install.RunWithContext(ctx, loaded, values)
+	if err != nil {
+		return nil, fmt.Errorf("helm install %s failed: %w", req.Release, err)
+	}
+	return newHelmRelease(rel), nil
+}
+
+// loadHelmChart downloads a chart from a configured repository or OCI registry and loads it
+func loadHelmChart(ctx context.Context, options *action.ChartPathOptions, name string, settings *cli.EnvSettings) (*chart.Chart, error) {
+	var chartPath string
+	err := runHelmStep(ctx, func() error {
+		var err error
+		chartPath, err = options.LocateChart(name, settings)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to locate chart %s: %w", name, err)
+	}
+	loaded, err := loader.Load(chartPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chart %s: %w", name, err)
+	}
+	return loaded, nil
+}
+
+// uninstallHelmRelease uninstalls a release; a missing release is an error unless ignoreNotFound is set.
+// Helm's uninstall takes no context, so the wait is bounded by ctx's deadline and a cancelled call returns
+// without waiting for it
+func (m *Manager) uninstallHelmRelease(ctx context.Context, namespace, releaseName string, ignoreNotFound, wait bool, timeout string) error {
+	cfg, _, err := m.helmActionConfig(namespace)
+	if err != nil {
+		return err
+	}
+	duration, err := parseHelmTimeout(timeout)
+	if err != nil {
+		return err
+	}
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < duration {
+		duration = time.Until(deadline)
+	}
+
+	uninstall := action.NewUninstall(cfg)
+	uninstall.IgnoreNotFound = ignoreNotFound
+	uninstall.Wait = wait
+	uninstall.Timeout = duration
+
+	var response *release.UninstallReleaseResponse
+	err = runHelmStep(ctx, func() error {
+		var err error
+		response, err = uninstall.Run(releaseName)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("helm uninstall %s failed: %w", releaseName, err)
+	}
+	if response != nil && response.Info != "" {
+		logrus.Infof("Helm uninstall %s/%s: %s", namespace, releaseName, response.Info)
+	} else {
+		logrus.Infof("Helm release %s/%s uninstalled", namespace, releaseName)
+	}
+	return nil
+}
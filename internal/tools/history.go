@@ -0,0 +1,384 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"meshpilot/internal/config"
+
+	"github.com/sirupsen/logrus"
+	bolt "go.etcd.io/bbolt"
+)
+
+// maxHistoryResultBytes caps the tool output stored with each history entry
+const maxHistoryResultBytes = 256 * 1024
+
+// historyBucket is the bolt bucket holding recorded tool results
+var historyBucket = []byte("results")
+
+// historyTools are never recorded since they only read the history itself
+var historyTools = map[string]bool{
+	"list_history": true,
+	"get_result":   true,
+}
+
+// secretBearingTools have their invocation recorded without arguments or output, which can hold packet
+// captures, Helm values with credentials, arbitrary command output or kubeconfig credentials
+var secretBearingTools = map[string]bool{
+	"capture_packets":      true,
+	"get_release_values":   true,
+	"exec_pod_command":     true,
+	"configure_kubeconfig": true,
+}
+
+// HistoryEntry is a tool result recorded in the history store
+type HistoryEntry struct {
+	ID        uint64          `json:"id"`
+	Tool      string          `json:"tool"`
+	Args      json.RawMessage `json:"args,omitempty"`
+	Context   string          `json:"context,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+	Duration  string          `json:"duration"`
+	IsError   bool            `json:"is_error"`
+	Result    string          `json:"result,omitempty"`
+	Truncated bool            `json:"truncated,omitempty"` // the result was cut at maxHistoryResultBytes
+}
+
+// historyStore persists tool results in an embedded bolt database
+type historyStore struct {
+	cfg config.HistoryConfig
+
+	// db is opened on first use and kept for the Manager's lifetime, so concurrent sessions share it
+	mu sync.Mutex
+	db *bolt.DB
+}
+
+// newHistoryStore creates a history store from the history configuration
+func newHistoryStore(cfg config.HistoryConfig) *historyStore {
+	return &historyStore{cfg: cfg}
+}
+
+// enabled reports whether tool results should be recorded
+func (h *historyStore) enabled() bool {
+	return h.cfg.Enabled != nil && *h.cfg.Enabled && h.cfg.Path != ""
+}
+
+// open returns the database, opening it on first use; bolt locks the file, so while a server holds it a
+// direct CLI invocation gives up after the short lock timeout instead of blocking
+func (h *historyStore) open(readOnly bool) (*bolt.DB, error) {
+	if !h.enabled() {
+		return nil, fmt.Errorf("result history is disabled")
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.db != nil {
+		return h.db, nil
+	}
+	if readOnly {
+		if _, err := os.Stat(h.cfg.Path); os.IsNotExist(err) {
+			return nil, fmt.Errorf("no results have been recorded yet")
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(h.cfg.Path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create history directory: %w", err)
+	}
+	db, err := bolt.Open(h.cfg.Path, 0600, &bolt.Options{Timeout: 2 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open history database %s: %w", h.cfg.Path, err)
+	}
+	h.db = db
+	return db, nil
+}
+
+// record stores a tool result and prunes the oldest entries beyond the configured maximum
+func (h *historyStore) record(entry *HistoryEntry) error {
+	db, err := h.open(false)
+	if err != nil {
+		return err
+	}
+
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(historyBucket)
+		if err != nil {
+			return err
+		}
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		entry.ID = id
+
+		// Make room for the new entry by dropping the oldest ones
+		var stale [][]byte
+		excess := bucket.Stats().KeyN + 1 - h.cfg.MaxEntries
+		cursor := bucket.Cursor()
+		for k, _ := cursor.First(); k != nil && len(stale) < excess; k, _ = cursor.Next() {
+			stale = append(stale, append([]byte(nil), k...))
+		}
+		for _, k := range stale {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		data, err := json.Marshal(entry)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(historyKey(id), data)
+	})
+}
+
+// list returns matching entries, newest first
+func (h *historyStore) list(match func(*HistoryEntry) bool, limit int) ([]HistoryEntry, error) {
+	db, err := h.open(true)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := []HistoryEntry{}
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket)
+		if bucket == nil {
+			return nil
+		}
+		cursor := bucket.Cursor()
+		for k, v := cursor.Last(); k != nil && len(entries) < limit; k, v = cursor.Prev() {
+			var entry HistoryEntry
+			if err := json.Unmarshal(v, &entry); err != nil {
+				logrus.Debugf("Skipping unreadable history entry %x: %v", k, err)
+				continue
+			}
+			if match(&entry) {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	return entries, err
+}
+
+// get returns a single entry by ID
+func (h *historyStore) get(id uint64) (*HistoryEntry, error) {
+	db, err := h.open(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry *HistoryEntry
+	err = db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(historyBucket)
+		if bucket == nil {
+			return nil
+		}
+		data := bucket.Get(historyKey(id))
+		if data == nil {
+			return nil
+		}
+		entry = &HistoryEntry{}
+		return json.Unmarshal(data, entry)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if entry == nil {
+		return nil, fmt.Errorf("result %d not found", id)
+	}
+	return entry, nil
+}
+
+// historyKey encodes an ID so keys sort in insertion order
+func historyKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
+
+// recordHistory persists a tool invocation unless history is disabled or the tool is excluded
+func (m *Manager) recordHistory(toolName string, args json.RawMessage, start time.Time, result *CallToolResult, err error) {
+	if !m.history.enabled() || historyTools[toolName] || containsString(m.config.History.ExcludeTools, toolName) {
+		return
+	}
+
+	entry := &HistoryEntry{
+		Tool:      toolName,
+		Args:      args,
+		Timestamp: start,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+	}
+	if len(args) == 0 || !json.Valid(args) || secretBearingTools[toolName] {
+		entry.Args = nil
+	}
+	if m.k8sClient != nil {
+		if current, ctxErr := m.k8sClient.GetCurrentContext(); ctxErr == nil {
+			entry.Context = current
+		}
+	}
+	switch {
+	case err != nil:
+		entry.IsError = true
+		entry.Result = err.Error()
+	case result != nil:
+		entry.IsError = result.IsError
+		if len(result.Content) > 0 && !secretBearingTools[toolName] {
+			if tc, ok := result.Content[0].(TextContent); ok {
+				entry.Result = truncateText(tc.Text, maxHistoryResultBytes)
+				entry.Truncated = len(tc.Text) > maxHistoryResultBytes
+			}
+		}
+	}
+
+	if err := m.history.record(entry); err != nil {
+		logrus.Warnf("Failed to record %s result in history: %v", toolName, err)
+	}
+}
+
+// listHistoryParams are the arguments of list_history
+type listHistoryParams struct {
+	Tool       string `json:"tool,omitempty"`
+	Since      string `json:"since,omitempty"` // duration (e.g. 24h) or RFC3339 timestamp
+	ErrorsOnly bool   `json:"errors_only,omitempty"`
+	Limit      int    `json:"limit,omitempty"` // default: 20
+}
+
+// ListHistory lists recorded tool results, newest first
+func (m *Manager) ListHistory(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params listHistoryParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 20
+	}
+
+	var since time.Time
+	if params.Since != "" {
+		if d, err := time.ParseDuration(params.Since); err == nil {
+			since = time.Now().Add(-d)
+		} else if t, err := time.Parse(time.RFC3339, params.Since); err == nil {
+			since = t
+		} else {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid since %q: use a duration such as 24h or an RFC3339 timestamp", params.Since),
+					},
+				},
+			}, nil
+		}
+	}
+
+	entries, err := m.history.list(func(entry *HistoryEntry) bool {
+		if params.Tool != "" && entry.Tool != params.Tool {
+			return false
+		}
+		if params.ErrorsOnly && !entry.IsError {
+			return false
+		}
+		return !entry.Timestamp.Before(since)
+	}, params.Limit)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read history: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Only a preview of each result is listed; get_result returns the full output
+	for i := range entries {
+		entries[i].Result = truncateText(entries[i].Result, 200)
+	}
+
+	resultJSON, _ := json.MarshalIndent(map[string]interface{}{
+		"count":   len(entries),
+		"results": entries,
+	}, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getResultParams are the arguments of get_result
+type getResultParams struct {
+	ID uint64 `json:"id"`
+}
+
+// GetResult returns a single recorded tool result with its full output
+func (m *Manager) GetResult(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getResultParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.ID == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "id is required",
+				},
+			},
+		}, nil
+	}
+
+	entry, err := m.history.get(params.ID)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get result: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	resultJSON, _ := json.MarshalIndent(entry, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
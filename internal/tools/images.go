@@ -0,0 +1,257 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// meshImageSources maps mesh components to the pod label selectors used to find them
+var meshImageSources = map[string]string{
+	"istiod":  "app=istiod",
+	"gateway": "istio,istio!=pilot",
+	"ztunnel": "app=ztunnel",
+	"cni":     "k8s-app=istio-cni-node",
+	"samples": "app in (sleep,httpbin,productpage,details,ratings,reviews)",
+}
+
+// vulnerabilitySeverities lists scanner severities from most to least severe
+var vulnerabilitySeverities = []string{"CRITICAL", "HIGH", "MEDIUM", "LOW", "UNKNOWN"}
+
+// ImageScanResult represents the vulnerability scan result of a single image
+type ImageScanResult struct {
+	Image      string         `json:"image"`
+	Components []string       `json:"components"`
+	Counts     map[string]int `json:"counts"`
+	Total      int            `json:"total"`
+	Critical   []string       `json:"critical_cves,omitempty"`
+	Error      string         `json:"error,omitempty"`
+}
+
+// ImageScanReport summarizes a vulnerability scan of the mesh images
+type ImageScanReport struct {
+	Scanner string            `json:"scanner"`
+	Images  []ImageScanResult `json:"images"`
+	Totals  map[string]int    `json:"totals"`
+	Issues  []string          `json:"issues,omitempty"`
+}
+
+// trivyReport is the subset of the Trivy JSON report used by the scanner
+type trivyReport struct {
+	Results []struct {
+		Target          string `json:"Target"`
+		Vulnerabilities []struct {
+			VulnerabilityID string `json:"VulnerabilityID"`
+			PkgName         string `json:"PkgName"`
+			Severity        string `json:"Severity"`
+		} `json:"Vulnerabilities"`
+	} `json:"Results"`
+}
+
+// scanMeshImagesParams are the arguments of scan_mesh_images
+type scanMeshImagesParams struct {
+	Components     []string `json:"components,omitempty"`      // default: all components
+	Scanner        string   `json:"scanner,omitempty"`         // default: trivy
+	Severities     []string `json:"severities,omitempty"`      // default: all severities
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"` // default: 300 per image
+}
+
+// ScanMeshImages runs a vulnerability scan against the images used by the mesh and sample apps
+func (m *Manager) ScanMeshImages(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params scanMeshImagesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if len(params.Components) == 0 {
+		params.Components = []string{"istiod", "gateway", "ztunnel", "cni", "samples"}
+	}
+	if params.Scanner == "" {
+		params.Scanner = "trivy"
+	}
+	if params.TimeoutSeconds == 0 {
+		params.TimeoutSeconds = 300
+	}
+	for i, severity := range params.Severities {
+		params.Severities[i] = strings.ToUpper(severity)
+	}
+
+	for _, component := range params.Components {
+		if _, ok := meshImageSources[component]; !ok {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Unknown component %q (valid: istiod, gateway, ztunnel, cni, samples)", component),
+					},
+				},
+			}, nil
+		}
+	}
+
+	scannerPath, err := exec.LookPath(params.Scanner)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Scanner %q not found in PATH; install Trivy (https://trivy.dev) or pass the scanner path: %v", params.Scanner, err),
+				},
+			},
+		}, nil
+	}
+
+	images, issues := m.collectMeshImages(ctx, params.Components)
+	report := &ImageScanReport{
+		Scanner: scannerPath,
+		Totals:  make(map[string]int),
+		Issues:  issues,
+	}
+
+	if len(images) == 0 {
+		report.Issues = append(report.Issues, "No mesh images found for the requested components")
+	}
+
+	imageNames := make([]string, 0, len(images))
+	for image := range images {
+		imageNames = append(imageNames, image)
+	}
+	sort.Strings(imageNames)
+
+	for _, image := range imageNames {
+		logrus.Infof("Scanning image %s", image)
+		result := ImageScanResult{
+			Image:      image,
+			Components: images[image],
+			Counts:     make(map[string]int),
+		}
+
+		if err := scanImage(ctx, scannerPath, image, params.Severities, time.Duration(params.TimeoutSeconds)*time.Second, &result); err != nil {
+			logrus.Warnf("Failed to scan image %s: %v", image, err)
+			result.Error = err.Error()
+		}
+
+		for severity, count := range result.Counts {
+			report.Totals[severity] += count
+		}
+		report.Images = append(report.Images, result)
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// collectMeshImages returns the unique container images of the given components, keyed by image
+func (m *Manager) collectMeshImages(ctx context.Context, components []string) (map[string][]string, []string) {
+	images := make(map[string][]string)
+	var issues []string
+
+	for _, component := range components {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+			LabelSelector: meshImageSources[component],
+		})
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("Failed to list %s pods: %v", component, err))
+			continue
+		}
+
+		for _, pod := range pods.Items {
+			for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+				if !containsString(images[container.Image], component) {
+					images[container.Image] = append(images[container.Image], component)
+				}
+			}
+		}
+	}
+
+	return images, issues
+}
+
+// scanImage runs the scanner against an image and records the vulnerability counts by severity
+func scanImage(ctx context.Context, scannerPath, image string, severities []string, timeout time.Duration, result *ImageScanResult) error {
+	scanCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := []string{"image", "--format", "json", "--quiet", "--scanners", "vuln"}
+	if len(severities) > 0 {
+		args = append(args, "--severity", strings.Join(severities, ","))
+	}
+	args = append(args, image)
+
+	cmd := exec.CommandContext(scanCtx, scannerPath, args...)
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return fmt.Errorf("scan failed: %w, output: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return fmt.Errorf("scan failed: %w", err)
+	}
+
+	var report trivyReport
+	if err := json.Unmarshal(output, &report); err != nil {
+		return fmt.Errorf("failed to parse scanner output: %w", err)
+	}
+
+	for _, severity := range vulnerabilitySeverities {
+		if len(severities) == 0 || containsString(severities, severity) {
+			result.Counts[severity] = 0
+		}
+	}
+
+	// The same vulnerability can be reported for several targets within an image
+	seen := make(map[string]bool)
+	for _, target := range report.Results {
+		for _, vuln := range target.Vulnerabilities {
+			key := vuln.VulnerabilityID + "/" + vuln.PkgName
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			severity := strings.ToUpper(vuln.Severity)
+			result.Counts[severity]++
+			result.Total++
+			if severity == "CRITICAL" && !containsString(result.Critical, vuln.VulnerabilityID) {
+				result.Critical = append(result.Critical, vuln.VulnerabilityID)
+			}
+		}
+	}
+
+	return nil
+}
+
+// containsString reports whether a slice contains the given string
+func containsString(items []string, value string) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
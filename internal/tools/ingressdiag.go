@@ -0,0 +1,849 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// ingressLogSamples is how many matching access log entries are returned per diagnosis
+const ingressLogSamples = 20
+
+// accessLogQuoted matches the quoted fields of Istio's default text access log format
+var accessLogQuoted = regexp.MustCompile(`"([^"]*)"`)
+
+// responseFlagExplanations describes the Envoy response flags found in gateway and sidecar access logs
+var responseFlagExplanations = map[string]string{
+	"NR":    "no route: no virtual host matches the Host header, no route matches the path, or the route points at an undefined subset",
+	"NC":    "no cluster: the route's destination cluster does not exist on the proxy, usually a host with no Service or ServiceEntry",
+	"UH":    "no healthy upstream: the destination service has no ready endpoints",
+	"UF":    "upstream connection failure: the proxy could not connect to the backend, often an mTLS mismatch or a closed port",
+	"URX":   "upstream retry limit exceeded",
+	"UC":    "upstream connection terminated by the backend",
+	"UT":    "upstream request timeout",
+	"UO":    "upstream overflow: a DestinationRule connection pool or circuit breaker limit was hit",
+	"UR":    "upstream remote reset: the backend reset the stream",
+	"UAEX":  "denied by the external authorization service",
+	"RL":    "rate limited",
+	"RLSE":  "the rate limit service could not be reached and the request was rejected",
+	"DC":    "the client closed the connection before a response was sent",
+	"DT":    "the request or connection exceeded the downstream max duration",
+	"LR":    "connection reset locally by the proxy",
+	"LH":    "local service failed its health check",
+	"DI":    "delayed by fault injection",
+	"FI":    "aborted by fault injection",
+	"SI":    "stream idle timeout",
+	"IH":    "rejected because of an invalid value in a strictly checked header",
+	"DPE":   "downstream protocol error: the client sent a malformed HTTP request",
+	"UPE":   "upstream protocol error: the backend sent a malformed HTTP response",
+	"UMSDR": "the upstream request reached its max stream duration",
+	"NFCF":  "no filter config found: an ECDS filter config, such as a WasmPlugin, was never delivered",
+	"OM":    "overload manager terminated the request because the proxy is short of memory",
+	"DF":    "DNS resolution of the upstream host failed",
+	"DO":    "drop overload: requests were dropped by the load balancer's drop overload settings",
+}
+
+// IngressRequestProbe is the outcome of sending the diagnosed request to the gateway
+type IngressRequestProbe struct {
+	URL        string            `json:"url"`
+	StatusCode int               `json:"status_code,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"` // response headers that tell who answered
+	Error      string            `json:"error,omitempty"`
+	Duration   string            `json:"duration,omitempty"`
+}
+
+// IngressRouteRule is the rule of a VirtualService or HTTPRoute that handles the path
+type IngressRouteRule struct {
+	Route        string   `json:"route"`
+	Rule         string   `json:"rule,omitempty"`
+	Destinations []string `json:"destinations,omitempty"`
+	Action       string   `json:"action,omitempty"` // redirect or direct response instead of a destination
+	Problem      string   `json:"problem,omitempty"`
+}
+
+// GatewayRDSRoute is the Envoy route the gateway selects for the host and path
+type GatewayRDSRoute struct {
+	Pod            string `json:"pod"`
+	RouteConfig    string `json:"route_config"`
+	VirtualHost    string `json:"virtual_host,omitempty"`
+	Route          string `json:"route,omitempty"`
+	Match          string `json:"match,omitempty"`
+	Cluster        string `json:"cluster,omitempty"`
+	Action         string `json:"action,omitempty"`
+	HealthyHosts   int    `json:"healthy_hosts"`
+	TotalHosts     int    `json:"total_hosts"`
+	ClusterMissing bool   `json:"cluster_missing,omitempty"`
+	Problem        string `json:"problem,omitempty"`
+}
+
+// IngressLogEntry is an access log entry of the gateway for the diagnosed host and path
+type IngressLogEntry struct {
+	Pod           string `json:"pod"`
+	Time          string `json:"time,omitempty"`
+	Method        string `json:"method,omitempty"`
+	Path          string `json:"path"`
+	Authority     string `json:"authority,omitempty"`
+	ResponseCode  int    `json:"response_code"`
+	ResponseFlags string `json:"response_flags,omitempty"`
+	Details       string `json:"details,omitempty"`
+	Cluster       string `json:"cluster,omitempty"`
+	RouteName     string `json:"route_name,omitempty"`
+}
+
+// IngressDiagnosis correlates the configuration, Envoy routes and access logs of an ingress gateway for one URL
+type IngressDiagnosis struct {
+	Gateway      string               `json:"gateway"`
+	Host         string               `json:"host"`
+	Path         string               `json:"path"`
+	Request      *IngressRequestProbe `json:"request,omitempty"`
+	Servers      []string             `json:"matched_servers,omitempty"`
+	Routes       []IngressRouteRule   `json:"matched_routes,omitempty"`
+	EnvoyRoutes  []GatewayRDSRoute    `json:"envoy_routes,omitempty"`
+	LogSummary   map[string]int       `json:"log_summary,omitempty"` // matching entries per "<code> <flags>"
+	LogEntries   []IngressLogEntry    `json:"log_entries,omitempty"`
+	Explanations []string             `json:"explanations,omitempty"`
+	Notes        []string             `json:"notes,omitempty"`
+	Timestamp    time.Time            `json:"timestamp"`
+}
+
+// envoyRouteConfigDump holds the parts of the gateway's dynamic route configs needed to select a route
+type envoyRouteConfigDump struct {
+	Configs []struct {
+		RouteConfig struct {
+			Name         string `json:"name"`
+			VirtualHosts []struct {
+				Name    string   `json:"name"`
+				Domains []string `json:"domains"`
+				Routes  []struct {
+					Name  string `json:"name"`
+					Match struct {
+						Prefix              string            `json:"prefix"`
+						Path                string            `json:"path"`
+						PathSeparatedPrefix string            `json:"path_separated_prefix"`
+						SafeRegex           *envoySafeRegex   `json:"safe_regex"`
+						Headers             []json.RawMessage `json:"headers"`
+					} `json:"match"`
+					Route *struct {
+						Cluster          string `json:"cluster"`
+						WeightedClusters *struct {
+							Clusters []struct {
+								Name string `json:"name"`
+							} `json:"clusters"`
+						} `json:"weighted_clusters"`
+					} `json:"route"`
+					Redirect       json.RawMessage `json:"redirect"`
+					DirectResponse *struct {
+						Status int `json:"status"`
+					} `json:"direct_response"`
+				} `json:"routes"`
+			} `json:"virtual_hosts"`
+		} `json:"route_config"`
+	} `json:"configs"`
+}
+
+// envoySafeRegex is the regular expression of an Envoy route match
+type envoySafeRegex struct {
+	Regex string `json:"regex"`
+}
+
+// envoyClusterStatuses holds the endpoint health the gateway reports per cluster
+type envoyClusterStatuses struct {
+	ClusterStatuses []struct {
+		Name         string `json:"name"`
+		HostStatuses []struct {
+			HealthStatus struct {
+				EdsHealthStatus    string `json:"eds_health_status"`
+				FailedOutlierCheck bool   `json:"failed_outlier_check"`
+				FailedActiveHealth bool   `json:"failed_active_health_check"`
+			} `json:"health_status"`
+		} `json:"host_statuses"`
+	} `json:"cluster_statuses"`
+}
+
+// diagnoseIngressRequestParams are the arguments of diagnose_ingress_request
+type diagnoseIngressRequestParams struct {
+	Host             string `json:"host"`                        // Host header of the failing request
+	Path             string `json:"path,omitempty"`              // default: /
+	GatewayNamespace string `json:"gateway_namespace,omitempty"` // default: istio-ingress
+	GatewayService   string `json:"gateway_service,omitempty"`   // default: istio-ingress
+	Port             int    `json:"port,omitempty"`              // gateway service port, default: 80 (443 with https)
+	HTTPS            bool   `json:"https,omitempty"`             // send the request over TLS with the host as SNI
+	IstioNamespace   string `json:"istio_namespace,omitempty"`   // default: istio-system
+	SendRequest      *bool  `json:"send_request,omitempty"`      // default: true
+	Since            int    `json:"since,omitempty"`             // seconds of access logs to sample, default: 600
+	Timeout          int    `json:"timeout,omitempty"`           // seconds
+}
+
+// DiagnoseIngressRequest explains why a URL fails at the ingress gateway by correlating the Gateway and
+// route resources, the Envoy route the gateway selects, the health of its cluster and the access logs
+func (m *Manager) DiagnoseIngressRequest(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params diagnoseIngressRequestParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Host == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "host is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+	if params.GatewayService == "" {
+		params.GatewayService = "istio-ingress"
+	}
+	if params.Port == 0 {
+		params.Port = 80
+		if params.HTTPS {
+			params.Port = 443
+		}
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.SendRequest == nil {
+		params.SendRequest = boolPtr(true)
+	}
+	if params.Since == 0 {
+		params.Since = 600
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 10
+	}
+
+	service, err := m.k8sClient.Kubernetes.CoreV1().Services(params.GatewayNamespace).Get(ctx, params.GatewayService, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get gateway service: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	host := strings.ToLower(params.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	path, _, _ := strings.Cut(params.Path, "?")
+	result := IngressDiagnosis{
+		Gateway:   fmt.Sprintf("%s/%s", params.GatewayNamespace, params.GatewayService),
+		Host:      host,
+		Path:      params.Path,
+		Timestamp: time.Now(),
+	}
+
+	// Gateway and route resources bound to the gateway that cover the host
+	servers, routes, notes := m.gatewayTLSConfig(ctx, service, params.Port)
+	result.Notes = append(result.Notes, notes...)
+	var routeNames []string
+	result.Servers, routeNames = matchGatewayConfig(servers, routes, host, host)
+	for _, name := range routeNames {
+		result.Routes = append(result.Routes, m.matchIngressRouteRule(ctx, name, path))
+	}
+	switch {
+	case len(servers) == 0:
+		result.Explanations = append(result.Explanations, fmt.Sprintf("No Gateway server or listener is configured for port %d of %s; the gateway has no listener for this request and the connection is refused or answered with 404", params.Port, result.Gateway))
+	case len(result.Servers) == 0:
+		result.Explanations = append(result.Explanations, fmt.Sprintf("No Gateway server or listener on port %d lists host %s; requests for it get 404 (NR)", params.Port, host))
+	case len(result.Routes) == 0:
+		result.Explanations = append(result.Explanations, fmt.Sprintf("No VirtualService or HTTPRoute bound to the gateway serves host %s; requests for it get 404 (NR)", host))
+	}
+	for _, route := range result.Routes {
+		if route.Problem != "" {
+			result.Explanations = append(result.Explanations, fmt.Sprintf("%s: %s", route.Route, route.Problem))
+		}
+	}
+
+	// The routes and cluster health Envoy actually has on the gateway pods
+	pods, err := m.runningPods(ctx, params.GatewayNamespace, labels.SelectorFromSet(service.Spec.Selector).String())
+	if err != nil || len(pods) == 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("No running gateway pods found for %s; Envoy routes and access logs are not checked", result.Gateway))
+	}
+	for i := range pods {
+		route := m.gatewayRDSRoute(ctx, &pods[i], service, params.Port, host, path)
+		if route.Problem != "" {
+			result.Explanations = append(result.Explanations, fmt.Sprintf("%s: %s", route.Pod, route.Problem))
+		}
+		result.EnvoyRoutes = append(result.EnvoyRoutes, route)
+	}
+
+	if *params.SendRequest {
+		result.Request = m.sendIngressRequest(ctx, params.GatewayNamespace, params.GatewayService, params.Port, params.HTTPS, params.Host, params.Path, time.Duration(params.Timeout)*time.Second)
+	}
+
+	// Access logs of the gateway for the host and path
+	var mesh struct {
+		AccessLogFile string `json:"accessLogFile"`
+	}
+	if err := m.readMeshConfig(ctx, params.IstioNamespace, "", &mesh); err == nil && mesh.AccessLogFile == "" {
+		result.Notes = append(result.Notes, "meshConfig.accessLogFile is not set, so the gateway may write no access logs; set it to /dev/stdout to correlate requests")
+	}
+	result.LogSummary = make(map[string]int)
+	sinceSeconds := int64(params.Since)
+	for _, pod := range pods {
+		logs, err := m.k8sClient.Kubernetes.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			SinceSeconds: &sinceSeconds,
+		}).Do(ctx).Raw()
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to read access logs of %s: %v", pod.Name, err))
+			continue
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(logs)))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			entry, ok := parseIngressAccessLog(scanner.Text())
+			if !ok || !ingressLogMatches(entry, host, path) {
+				continue
+			}
+			entry.Pod = pod.Name
+			key := strconv.Itoa(entry.ResponseCode)
+			if entry.ResponseFlags != "" && entry.ResponseFlags != "-" {
+				key += " " + entry.ResponseFlags
+			}
+			result.LogSummary[key]++
+			result.LogEntries = append(result.LogEntries, entry)
+		}
+	}
+	if len(result.LogEntries) > ingressLogSamples {
+		result.LogEntries = result.LogEntries[len(result.LogEntries)-ingressLogSamples:]
+	}
+	if len(pods) > 0 && len(result.LogSummary) == 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("No gateway access log entries for %s%s in the last %ds", host, path, params.Since))
+	}
+
+	result.Explanations = append(result.Explanations, explainIngressOutcomes(result)...)
+	if len(result.Explanations) == 0 {
+		result.Explanations = append(result.Explanations, "The gateway configuration routes this request to a cluster with healthy endpoints and no failures were observed")
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// matchIngressRouteRule finds the rule of a VirtualService or HTTPRoute, named as gatewayTLSConfig reports it,
+// that handles the path
+func (m *Manager) matchIngressRouteRule(ctx context.Context, name, path string) IngressRouteRule {
+	rule := IngressRouteRule{Route: name}
+	kind, ref, _ := strings.Cut(name, " ")
+	namespace, resource, _ := strings.Cut(ref, "/")
+
+	switch kind {
+	case "VirtualService":
+		obj, err := m.k8sClient.Dynamic.Resource(virtualServiceGVR).Namespace(namespace).Get(ctx, resource, metav1.GetOptions{})
+		if err != nil {
+			rule.Problem = fmt.Sprintf("could not get the VirtualService: %v", err)
+			return rule
+		}
+		var spec struct {
+			HTTP []struct {
+				Name  string `json:"name"`
+				Match []struct {
+					URI map[string]string `json:"uri"`
+				} `json:"match"`
+				Route []struct {
+					Destination struct {
+						Host   string `json:"host"`
+						Subset string `json:"subset"`
+						Port   struct {
+							Number int `json:"number"`
+						} `json:"port"`
+					} `json:"destination"`
+				} `json:"route"`
+				Redirect       map[string]interface{} `json:"redirect"`
+				DirectResponse *struct {
+					Status int `json:"status"`
+				} `json:"directResponse"`
+			} `json:"http"`
+		}
+		if err := remarshal(obj.Object["spec"], &spec); err != nil {
+			rule.Problem = fmt.Sprintf("could not parse the VirtualService: %v", err)
+			return rule
+		}
+		for i, httpRoute := range spec.HTTP {
+			matched := len(httpRoute.Match) == 0
+			for _, match := range httpRoute.Match {
+				if uriMatches(match.URI, path) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				continue
+			}
+			rule.Rule = fmt.Sprintf("http[%d]", i)
+			if httpRoute.Name != "" {
+				rule.Rule += " " + httpRoute.Name
+			}
+			for _, route := range httpRoute.Route {
+				destination := route.Destination.Host
+				if route.Destination.Subset != "" {
+					destination += " subset " + route.Destination.Subset
+				}
+				if route.Destination.Port.Number != 0 {
+					destination += fmt.Sprintf(" port %d", route.Destination.Port.Number)
+				}
+				rule.Destinations = append(rule.Destinations, destination)
+			}
+			switch {
+			case httpRoute.Redirect != nil:
+				rule.Action = "redirect"
+			case httpRoute.DirectResponse != nil:
+				rule.Action = fmt.Sprintf("direct response %d", httpRoute.DirectResponse.Status)
+			}
+			return rule
+		}
+		rule.Problem = fmt.Sprintf("no http route matches path %s, so the gateway answers 404 (NR)", path)
+
+	case "HTTPRoute":
+		obj, err := m.k8sClient.Dynamic.Resource(httpRouteGVR).Namespace(namespace).Get(ctx, resource, metav1.GetOptions{})
+		if err != nil {
+			rule.Problem = fmt.Sprintf("could not get the HTTPRoute: %v", err)
+			return rule
+		}
+		var spec struct {
+			Rules []struct {
+				Matches []struct {
+					Path *struct {
+						Type  string `json:"type"`
+						Value string `json:"value"`
+					} `json:"path"`
+				} `json:"matches"`
+				BackendRefs []struct {
+					Name      string `json:"name"`
+					Namespace string `json:"namespace"`
+					Port      int    `json:"port"`
+				} `json:"backendRefs"`
+			} `json:"rules"`
+		}
+		if err := remarshal(obj.Object["spec"], &spec); err != nil {
+			rule.Problem = fmt.Sprintf("could not parse the HTTPRoute: %v", err)
+			return rule
+		}
+		// Gateway API prefers the most specific path match across rules
+		best, bestLength := -1, -1
+		for i, r := range spec.Rules {
+			if len(r.Matches) == 0 && bestLength < 1 {
+				best, bestLength = i, 1
+			}
+			for _, match := range r.Matches {
+				pathType, value := "PathPrefix", "/"
+				if match.Path != nil {
+					pathType, value = match.Path.Type, match.Path.Value
+				}
+				uri := map[string]string{"prefix": value}
+				switch pathType {
+				case "Exact":
+					uri = map[string]string{"exact": value}
+				case "RegularExpression":
+					uri = map[string]string{"regex": value}
+				}
+				if uriMatches(uri, path) && len(value) > bestLength {
+					best, bestLength = i, len(value)
+				}
+			}
+		}
+		if best < 0 {
+			rule.Problem = fmt.Sprintf("no rule matches path %s, so the gateway answers 404 (NR)", path)
+			return rule
+		}
+		rule.Rule = fmt.Sprintf("rules[%d]", best)
+		for _, ref := range spec.Rules[best].BackendRefs {
+			backendNamespace := ref.Namespace
+			if backendNamespace == "" {
+				backendNamespace = namespace
+			}
+			rule.Destinations = append(rule.Destinations, fmt.Sprintf("%s/%s port %d", backendNamespace, ref.Name, ref.Port))
+		}
+		if len(rule.Destinations) == 0 {
+			rule.Problem = "the matching rule has no backendRefs, so the gateway answers 500"
+		}
+	}
+	return rule
+}
+
+// uriMatches evaluates an Istio StringMatch (exact, prefix or regex) against a path
+func uriMatches(uri map[string]string, path string) bool {
+	if len(uri) == 0 {
+		return true
+	}
+	if value, ok := uri["exact"]; ok {
+		return path == value
+	}
+	if value, ok := uri["prefix"]; ok {
+		return strings.HasPrefix(path, value)
+	}
+	if value, ok := uri["regex"]; ok {
+		re, err := regexp.Compile("^(?:" + value + ")$")
+		return err == nil && re.MatchString(path)
+	}
+	return false
+}
+
+// gatewayRDSRoute selects the route the gateway's Envoy applies to the host and path, the way Envoy does:
+// the route config of the listener, the virtual host by domain, then the first route whose match covers the path
+func (m *Manager) gatewayRDSRoute(ctx context.Context, pod *corev1.Pod, service *corev1.Service, servicePort int, host, path string) GatewayRDSRoute {
+	route := GatewayRDSRoute{Pod: pod.Name}
+
+	body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/config_dump?resource=dynamic_route_configs")
+	if err != nil {
+		route.Problem = fmt.Sprintf("could not read the route configuration: %v", err)
+		return route
+	}
+	var dump envoyRouteConfigDump
+	if err := json.Unmarshal(body, &dump); err != nil {
+		route.Problem = fmt.Sprintf("could not parse the route configuration: %v", err)
+		return route
+	}
+
+	// Istio names gateway route configs after the listener port, e.g. http.8080 or https.443.<server>.<gateway>.<namespace>
+	ports := []int{servicePort}
+	for _, p := range service.Spec.Ports {
+		if int(p.Port) == servicePort && p.TargetPort.IntValue() != 0 && p.TargetPort.IntValue() != servicePort {
+			ports = append(ports, p.TargetPort.IntValue())
+		}
+	}
+	var names []string
+	for _, config := range dump.Configs {
+		name := config.RouteConfig.Name
+		for _, port := range ports {
+			if name == fmt.Sprintf("http.%d", port) || strings.HasPrefix(name, fmt.Sprintf("https.%d.", port)) {
+				names = append(names, name)
+			}
+		}
+	}
+	if len(names) == 0 {
+		route.Problem = fmt.Sprintf("the gateway has no route configuration for port %d; no Gateway server for this port has been pushed to it", servicePort)
+		return route
+	}
+
+	for _, config := range dump.Configs {
+		if !containsString(names, config.RouteConfig.Name) {
+			continue
+		}
+		for _, vh := range config.RouteConfig.VirtualHosts {
+			if !envoyDomainMatches(vh.Domains, host, servicePort) {
+				continue
+			}
+			route.RouteConfig = config.RouteConfig.Name
+			route.VirtualHost = vh.Name
+			for _, r := range vh.Routes {
+				if !envoyPathMatches(r.Match.Prefix, r.Match.Path, r.Match.PathSeparatedPrefix, r.Match.SafeRegex, path) {
+					continue
+				}
+				route.Route = r.Name
+				switch {
+				case r.Match.Path != "":
+					route.Match = "path " + r.Match.Path
+				case r.Match.SafeRegex != nil:
+					route.Match = "regex " + r.Match.SafeRegex.Regex
+				case r.Match.PathSeparatedPrefix != "":
+					route.Match = "path_separated_prefix " + r.Match.PathSeparatedPrefix
+				default:
+					route.Match = "prefix " + r.Match.Prefix
+				}
+				if len(r.Match.Headers) > 0 {
+					route.Match += " (also requires headers, not evaluated)"
+				}
+				switch {
+				case r.Route != nil && r.Route.Cluster != "":
+					route.Cluster = r.Route.Cluster
+				case r.Route != nil && r.Route.WeightedClusters != nil && len(r.Route.WeightedClusters.Clusters) > 0:
+					route.Cluster = r.Route.WeightedClusters.Clusters[0].Name
+				case len(r.Redirect) > 0:
+					route.Action = "redirect"
+				case r.DirectResponse != nil:
+					route.Action = fmt.Sprintf("direct response %d", r.DirectResponse.Status)
+					if r.DirectResponse.Status == http.StatusNotFound {
+						route.Problem = "the route answers 404 directly; Istio generates it when no route of the virtual host matches"
+					}
+				}
+				break
+			}
+			if route.Route == "" && route.Action == "" {
+				route.Problem = fmt.Sprintf("virtual host %s has no route for path %s, so the gateway answers 404 (NR)", vh.Name, path)
+			}
+			break
+		}
+		if route.RouteConfig != "" {
+			break
+		}
+	}
+	if route.RouteConfig == "" {
+		route.RouteConfig = strings.Join(names, ", ")
+		route.Problem = fmt.Sprintf("no virtual host of the gateway matches host %s, so the gateway answers 404 (NR)", host)
+		return route
+	}
+	if route.Cluster == "" {
+		return route
+	}
+
+	body, err = m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/clusters?format=json")
+	if err != nil {
+		route.Problem = fmt.Sprintf("could not read cluster health: %v", err)
+		return route
+	}
+	var clusters envoyClusterStatuses
+	if err := json.Unmarshal(body, &clusters); err != nil {
+		route.Problem = fmt.Sprintf("could not parse cluster health: %v", err)
+		return route
+	}
+	route.ClusterMissing = true
+	for _, cluster := range clusters.ClusterStatuses {
+		if cluster.Name != route.Cluster {
+			continue
+		}
+		route.ClusterMissing = false
+		for _, host := range cluster.HostStatuses {
+			route.TotalHosts++
+			health := host.HealthStatus
+			if (health.EdsHealthStatus == "" || health.EdsHealthStatus == "HEALTHY") && !health.FailedOutlierCheck && !health.FailedActiveHealth {
+				route.HealthyHosts++
+			}
+		}
+	}
+	switch {
+	case route.ClusterMissing:
+		route.Problem = fmt.Sprintf("cluster %s does not exist on the gateway, so the gateway answers 503 (NC); check the destination host and subset", route.Cluster)
+	case route.TotalHosts == 0:
+		route.Problem = fmt.Sprintf("cluster %s has no endpoints, so the gateway answers 503 (UH); check that the destination pods are ready and the DestinationRule subset labels match them", route.Cluster)
+	case route.HealthyHosts == 0:
+		route.Problem = fmt.Sprintf("all %d endpoints of cluster %s are unhealthy or ejected by outlier detection, so the gateway answers 503 (UH)", route.TotalHosts, route.Cluster)
+	}
+	return route
+}
+
+// envoyDomainMatches reports whether an Envoy virtual host's domains cover the host, with or without the port
+func envoyDomainMatches(domains []string, host string, port int) bool {
+	hostPort := fmt.Sprintf("%s:%d", host, port)
+	for _, domain := range domains {
+		domain = strings.ToLower(domain)
+		if strings.HasSuffix(domain, ":*") {
+			domain = strings.TrimSuffix(domain, ":*")
+		}
+		switch {
+		case domain == "*", domain == host, domain == hostPort:
+			return true
+		case strings.HasPrefix(domain, "*") && (strings.HasSuffix(host, domain[1:]) || strings.HasSuffix(hostPort, domain[1:])):
+			return true
+		case strings.HasSuffix(domain, "*") && strings.HasPrefix(host, domain[:len(domain)-1]):
+			return true
+		}
+	}
+	return false
+}
+
+// envoyPathMatches evaluates an Envoy route match against a path
+func envoyPathMatches(prefix, exact, separatedPrefix string, safeRegex *envoySafeRegex, path string) bool {
+	switch {
+	case exact != "":
+		return path == exact
+	case safeRegex != nil:
+		re, err := regexp.Compile("^(?:" + safeRegex.Regex + ")$")
+		return err == nil && re.MatchString(path)
+	case separatedPrefix != "":
+		return path == separatedPrefix || strings.HasPrefix(path, separatedPrefix+"/")
+	default:
+		return strings.HasPrefix(path, prefix)
+	}
+}
+
+// sendIngressRequest sends the diagnosed request to the gateway and records who answered
+func (m *Manager) sendIngressRequest(ctx context.Context, namespace, name string, servicePort int, useTLS bool, host, path string, timeout time.Duration) *IngressRequestProbe {
+	address, port, via, err := m.resolveGatewayAddress(ctx, namespace, name, servicePort)
+	if err != nil {
+		return &IngressRequestProbe{Error: fmt.Sprintf("failed to resolve ingress gateway address: %v", err)}
+	}
+	scheme := "http"
+	if useTLS {
+		scheme = "https"
+	}
+	probe := &IngressRequestProbe{URL: fmt.Sprintf("%s://%s%s (via %s)", scheme, net.JoinHostPort(address, strconv.Itoa(port)), path, via)}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s://%s%s", scheme, net.JoinHostPort(address, strconv.Itoa(port)), path), nil)
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	req.Host = host
+
+	sni := host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		sni = h
+	}
+	client := &http.Client{
+		Timeout: timeout,
+		// Report redirects instead of following them away from the gateway
+		CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse },
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{ServerName: sni, InsecureSkipVerify: true}, // the certificate is checked by probe_gateway_tls
+		},
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	probe.Duration = time.Since(start).String()
+	if err != nil {
+		probe.Error = err.Error()
+		return probe
+	}
+	resp.Body.Close()
+	probe.StatusCode = resp.StatusCode
+	probe.Headers = make(map[string]string)
+	for _, header := range []string{"Server", "X-Envoy-Upstream-Service-Time", "Location"} {
+		if value := resp.Header.Get(header); value != "" {
+			probe.Headers[strings.ToLower(header)] = value
+		}
+	}
+	return probe
+}
+
+// parseIngressAccessLog parses a gateway access log line in Istio's default text format or the JSON encoding
+func parseIngressAccessLog(line string) (IngressLogEntry, bool) {
+	var entry IngressLogEntry
+	line = strings.TrimSpace(line)
+
+	if strings.HasPrefix(line, "{") {
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &fields); err != nil {
+			return entry, false
+		}
+		entry.Time, _ = fields["start_time"].(string)
+		entry.Method, _ = fields["method"].(string)
+		entry.Path, _ = fields["path"].(string)
+		entry.Authority, _ = fields["authority"].(string)
+		entry.ResponseFlags, _ = fields["response_flags"].(string)
+		entry.Details, _ = fields["response_code_details"].(string)
+		entry.Cluster, _ = fields["upstream_cluster"].(string)
+		entry.RouteName, _ = fields["route_name"].(string)
+		code, _ := fields["response_code"].(float64)
+		entry.ResponseCode = int(code)
+		// TCP entries have no path but still carry response flags
+		return entry, entry.Path != "" || entry.ResponseFlags != ""
+	}
+
+	// [time] "METHOD PATH PROTOCOL" CODE FLAGS DETAILS ... "AUTHORITY" "UPSTREAM_HOST" CLUSTER ... ROUTE_NAME
+	if !strings.HasPrefix(line, "[") {
+		return entry, false
+	}
+	end := strings.Index(line, "]")
+	if end < 0 {
+		return entry, false
+	}
+	entry.Time = line[1:end]
+	quoted := accessLogQuoted.FindAllStringSubmatchIndex(line, -1)
+	if len(quoted) < 7 {
+		return entry, false
+	}
+	request := strings.Fields(line[quoted[0][2]:quoted[0][3]])
+	if len(request) < 2 {
+		return entry, false
+	}
+	entry.Method, entry.Path = request[0], request[1]
+	status := strings.Fields(line[quoted[0][1]:quoted[1][0]])
+	if len(status) < 3 {
+		return entry, false
+	}
+	entry.ResponseCode, _ = strconv.Atoi(status[0])
+	entry.ResponseFlags, entry.Details = status[1], status[2]
+	entry.Authority = line[quoted[5][2]:quoted[5][3]]
+	rest := strings.Fields(line[quoted[6][1]:])
+	if len(rest) > 0 {
+		entry.Cluster = rest[0]
+		entry.RouteName = rest[len(rest)-1]
+	}
+	return entry, true
+}
+
+// ingressLogMatches reports whether an access log entry is for the host and path
+func ingressLogMatches(entry IngressLogEntry, host, path string) bool {
+	authority := strings.ToLower(entry.Authority)
+	if h, _, err := net.SplitHostPort(authority); err == nil {
+		authority = h
+	}
+	entryPath, _, _ := strings.Cut(entry.Path, "?")
+	return authority == host && entryPath == path
+}
+
+// explainIngressOutcomes turns the observed status codes and response flags into explanations
+func explainIngressOutcomes(result IngressDiagnosis) []string {
+	var explanations []string
+	if result.Request != nil && result.Request.StatusCode != 0 {
+		code := result.Request.StatusCode
+		_, upstream := result.Request.Headers["x-envoy-upstream-service-time"]
+		switch {
+		case code == http.StatusNotFound && upstream:
+			explanations = append(explanations, "The request returned 404 from the backend: the gateway routed it, but the application has no handler for the path (check URI rewrites)")
+		case code == http.StatusNotFound:
+			explanations = append(explanations, "The request returned 404 from the gateway itself: no route matched the host and path (NR)")
+		case code == http.StatusServiceUnavailable && !upstream:
+			explanations = append(explanations, "The request returned 503 from the gateway without reaching a backend: the destination has no healthy endpoints, or the connection to it failed")
+		case code >= 500 && upstream:
+			explanations = append(explanations, fmt.Sprintf("The request returned %d from the backend, after the gateway forwarded it", code))
+		}
+	}
+
+	keys := make([]string, 0, len(result.LogSummary))
+	for key := range result.LogSummary {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		code, flags, _ := strings.Cut(key, " ")
+		if flags == "" {
+			if strings.HasPrefix(code, "4") || strings.HasPrefix(code, "5") {
+				explanations = append(explanations, fmt.Sprintf("%d access log entries with %s and no response flag: the status came from the backend, not the gateway", result.LogSummary[key], code))
+			}
+			continue
+		}
+		for _, flag := range strings.Split(flags, ",") {
+			if explanation, ok := responseFlagExplanations[flag]; ok {
+				explanations = append(explanations, fmt.Sprintf("%d access log entries with %s %s: %s", result.LogSummary[key], code, flag, explanation))
+			}
+		}
+	}
+	return explanations
+}
@@ -0,0 +1,628 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// injectionTemplatesAnnotation selects the injection templates a pod is rendered with
+	injectionTemplatesAnnotation = "inject.istio.io/templates"
+	// injectionPreviewImage is the application image of the preview pod; it is never pulled
+	injectionPreviewImage = "registry.k8s.io/pause:3.9"
+)
+
+// builtinInjectionTemplates are the templates istiod ships; overriding them replaces the sidecar wholesale
+var builtinInjectionTemplates = []string{"sidecar", "gateway", "grpc-simple", "grpc-agent", "waypoint", "kube-gateway"}
+
+// undefinedTemplateFunction matches the parse error for a function text/template doesn't know
+var undefinedTemplateFunction = regexp.MustCompile(`function "([^"]+)" not defined`)
+
+// InjectionTemplate summarizes a template of the injector config
+type InjectionTemplate struct {
+	Name    string `json:"name"`
+	Lines   int    `json:"lines"`
+	Custom  bool   `json:"custom"`  // not one of the templates istiod ships
+	Default bool   `json:"default"` // applied to every injected pod without an annotation
+}
+
+// InjectionConfig is the sidecar injector configuration of a control plane revision
+type InjectionConfig struct {
+	ConfigMap            string                 `json:"configmap"`
+	Policy               string                 `json:"policy"`
+	DefaultTemplates     []string               `json:"default_templates"`
+	Templates            []InjectionTemplate    `json:"templates"`
+	Template             string                 `json:"template,omitempty"` // text of the requested template
+	AlwaysInjectSelector int                    `json:"always_inject_selectors"`
+	NeverInjectSelector  int                    `json:"never_inject_selectors"`
+	InjectedAnnotations  map[string]string      `json:"injected_annotations,omitempty"`
+	Values               map[string]interface{} `json:"values,omitempty"` // proxy and injector values the templates render
+	Notes                []string               `json:"notes,omitempty"`
+	Timestamp            time.Time              `json:"timestamp"`
+}
+
+// InjectedContainer describes a container of a pod after injection
+type InjectedContainer struct {
+	Name         string   `json:"name"`
+	Image        string   `json:"image"`
+	Injected     bool     `json:"injected"` // added by the injector rather than declared by the pod
+	Lifecycle    []string `json:"lifecycle,omitempty"`
+	VolumeMounts []string `json:"volume_mounts,omitempty"`
+	EnvVars      int      `json:"env_vars"`
+}
+
+// InjectionPreview is the pod the injection webhook renders for a server-side dry-run create
+type InjectionPreview struct {
+	Namespace      string              `json:"namespace"`
+	Templates      []string            `json:"templates"`
+	Injected       bool                `json:"injected"`
+	InitContainers []InjectedContainer `json:"init_containers,omitempty"`
+	Containers     []InjectedContainer `json:"containers"`
+	Volumes        []string            `json:"volumes,omitempty"` // volumes added by the injector
+	Annotations    map[string]string   `json:"annotations,omitempty"`
+	Pod            string              `json:"pod,omitempty"` // full rendered pod YAML when requested
+	Notes          []string            `json:"notes,omitempty"`
+}
+
+// InjectionTemplateChange is the result of adding, replacing or removing a custom injection template
+type InjectionTemplateChange struct {
+	Release          string                 `json:"release"`
+	DryRun           bool                   `json:"dry_run"`
+	Template         string                 `json:"template,omitempty"`
+	Action           string                 `json:"action"`
+	DefaultTemplates []string               `json:"default_templates,omitempty"`
+	Values           map[string]interface{} `json:"values"` // Helm values set on the istiod release
+	Applied          bool                   `json:"applied"`
+	Preview          *InjectionPreview      `json:"preview,omitempty"`
+	Notes            []string               `json:"notes,omitempty"`
+	Timestamp        time.Time              `json:"timestamp"`
+}
+
+// injectorConfig holds the parts of the injector configmap's config key that are reported
+type injectorConfig struct {
+	Policy               string            `json:"policy"`
+	DefaultTemplates     []string          `json:"defaultTemplates"`
+	Templates            map[string]string `json:"templates"`
+	AlwaysInjectSelector []interface{}     `json:"alwaysInjectSelector"`
+	NeverInjectSelector  []interface{}     `json:"neverInjectSelector"`
+	InjectedAnnotations  map[string]string `json:"injectedAnnotations"`
+}
+
+// getInjectionConfigParams are the arguments of get_injection_config
+type getInjectionConfigParams struct {
+	Namespace string `json:"namespace,omitempty"` // istiod namespace, default: istio-system
+	Revision  string `json:"revision,omitempty"`  // control plane revision
+	Template  string `json:"template,omitempty"`  // include the text of this template
+}
+
+// GetInjectionConfig shows the sidecar injection policy, templates and the values they render
+func (m *Manager) GetInjectionConfig(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getInjectionConfigParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+
+	config, values, err := m.readInjectorConfig(ctx, params.Namespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read the sidecar injector config: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := InjectionConfig{
+		ConfigMap:            params.Namespace + "/" + injectorConfigMapName(params.Revision),
+		Policy:               config.Policy,
+		DefaultTemplates:     config.DefaultTemplates,
+		AlwaysInjectSelector: len(config.AlwaysInjectSelector),
+		NeverInjectSelector:  len(config.NeverInjectSelector),
+		InjectedAnnotations:  config.InjectedAnnotations,
+		Values:               make(map[string]interface{}),
+		Timestamp:            time.Now(),
+	}
+	if len(result.DefaultTemplates) == 0 {
+		result.DefaultTemplates = []string{"sidecar"}
+	}
+
+	names := make([]string, 0, len(config.Templates))
+	for name := range config.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result.Templates = append(result.Templates, InjectionTemplate{
+			Name:    name,
+			Lines:   strings.Count(config.Templates[name], "\n") + 1,
+			Custom:  !containsString(builtinInjectionTemplates, name),
+			Default: containsString(result.DefaultTemplates, name),
+		})
+	}
+
+	if params.Template != "" {
+		text, ok := config.Templates[params.Template]
+		if !ok {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Template %s not found; available templates: %s", params.Template, strings.Join(names, ", ")),
+					},
+				},
+			}, nil
+		}
+		result.Template = text
+	}
+
+	// The proxy settings and injector values are what templates read through .Values
+	if global, ok := values["global"].(map[string]interface{}); ok {
+		for _, key := range []string{"proxy", "proxy_init"} {
+			if value, ok := global[key]; ok {
+				result.Values["global."+key] = value
+			}
+		}
+	}
+	if webhook, ok := values["sidecarInjectorWebhook"]; ok {
+		result.Values["sidecarInjectorWebhook"] = webhook
+	}
+
+	if config.Policy == "disabled" {
+		result.Notes = append(result.Notes, "Injection policy is disabled: only pods labeled sidecar.istio.io/inject=true are injected")
+	}
+	for _, name := range result.DefaultTemplates {
+		if _, ok := config.Templates[name]; !ok {
+			result.Notes = append(result.Notes, fmt.Sprintf("Default template %s is not defined; injection of pods without an %s annotation fails", name, injectionTemplatesAnnotation))
+		}
+	}
+	result.Notes = append(result.Notes, fmt.Sprintf("Pods select extra templates with the %s annotation, e.g. \"sidecar,<custom>\"; later templates are merged over earlier ones", injectionTemplatesAnnotation))
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// setInjectionTemplateParams are the arguments of set_injection_template
+type setInjectionTemplateParams struct {
+	Name             string                 `json:"name,omitempty"`              // custom template name
+	Template         string                 `json:"template,omitempty"`          // Go template rendering a pod patch
+	Remove           bool                   `json:"remove,omitempty"`            // remove the custom template
+	DefaultTemplates []string               `json:"default_templates,omitempty"` // templates applied to every injected pod
+	Values           map[string]interface{} `json:"values,omitempty"`            // dotted global.proxy* or sidecarInjectorWebhook.* values
+	PreviewNamespace string                 `json:"preview_namespace,omitempty"` // render a canary pod here after applying
+	Namespace        string                 `json:"namespace,omitempty"`         // istiod namespace, default: istio-system
+	Revision         string                 `json:"revision,omitempty"`          // control plane revision
+	Release          string                 `json:"release,omitempty"`           // istiod Helm release, default: istiod
+	DryRun           bool                   `json:"dry_run,omitempty"`           // only validate and show the change
+	Timeout          string                 `json:"timeout,omitempty"`           // default: 5m
+	RepoURL          string                 `json:"repo_url,omitempty"`          // chart repository override
+}
+
+// SetInjectionTemplate adds, replaces or removes a custom injection template and overrides injection
+// values on the istiod Helm release, validating the template first and previewing a canary pod after
+func (m *Manager) SetInjectionTemplate(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params setInjectionTemplateParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	config, _, err := m.readInjectorConfig(ctx, params.Namespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read the sidecar injector config: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	values, action, err := injectionTemplateValues(config, params.Name, params.Template, params.Remove, params.DefaultTemplates, params.Values)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid injection change: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := &InjectionTemplateChange{
+		Release:          params.Namespace + "/" + params.Release,
+		DryRun:           params.DryRun,
+		Template:         params.Name,
+		Action:           action,
+		DefaultTemplates: params.DefaultTemplates,
+		Values:           values,
+		Timestamp:        time.Now(),
+	}
+	if params.Name != "" && !params.Remove && !containsString(params.DefaultTemplates, params.Name) && !containsString(config.DefaultTemplates, params.Name) {
+		result.Notes = append(result.Notes, fmt.Sprintf("Template %s only applies to pods annotated %s: \"sidecar,%s\"; add it to default_templates to apply it to every injected pod", params.Name, injectionTemplatesAnnotation, params.Name))
+	}
+	result.Notes = append(result.Notes, "Injection happens at pod creation; existing pods keep their sidecar until they are restarted")
+
+	if params.DryRun {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.setIstiodValues(ctx, params.Namespace, params.Release, params.RepoURL, values, params.Timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to update the istiod release: %v", err),
+				},
+			},
+		}, nil
+	}
+	result.Applied = true
+
+	if params.PreviewNamespace != "" {
+		templates := params.DefaultTemplates
+		if params.Name != "" && !params.Remove {
+			templates = []string{"sidecar", params.Name}
+		}
+
+		// istiod reloads the injector configmap on change; wait until the canary renders without errors
+		var preview *InjectionPreview
+		err := wait.PollUntilContextTimeout(ctx, 3*time.Second, configPropagationTimeout, true, func(ctx context.Context) (bool, error) {
+			p, err := m.previewInjection(ctx, params.PreviewNamespace, params.Revision, templates, nil, false)
+			if err != nil {
+				return false, nil
+			}
+			preview = p
+			return p.Injected, nil
+		})
+		if preview == nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Canary pod preview in %s failed: %v", params.PreviewNamespace, err))
+		} else {
+			result.Preview = preview
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// previewInjectionParams are the arguments of preview_injection
+type previewInjectionParams struct {
+	Namespace   string            `json:"namespace,omitempty"`   // default: default
+	Revision    string            `json:"revision,omitempty"`    // control plane revision to inject with
+	Templates   []string          `json:"templates,omitempty"`   // default: the injector's default templates
+	Annotations map[string]string `json:"annotations,omitempty"` // extra pod annotations, e.g. sidecar.istio.io/*
+	ShowPod     bool              `json:"show_pod,omitempty"`    // include the full rendered pod
+}
+
+// PreviewInjection renders a canary pod through the injection webhook with a server-side dry run,
+// showing the containers, lifecycle hooks and volumes the selected templates produce
+func (m *Manager) PreviewInjection(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params previewInjectionParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	preview, err := m.previewInjection(ctx, params.Namespace, params.Revision, params.Templates, params.Annotations, params.ShowPod)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to render the canary pod: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	resultJSON, _ := json.MarshalIndent(preview, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// injectorConfigMapName returns the name of the sidecar injector configmap of a revision
+func injectorConfigMapName(revision string) string {
+	if revision == "" || revision == defaultRevision {
+		return "istio-sidecar-injector"
+	}
+	return "istio-sidecar-injector-" + revision
+}
+
+// readInjectorConfig decodes the config and values keys of the sidecar injector configmap
+func (m *Manager) readInjectorConfig(ctx context.Context, namespace, revision string) (*injectorConfig, map[string]interface{}, error) {
+	name := injectorConfigMapName(revision)
+	cm, err := m.k8sClient.Kubernetes.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get configmap %s/%s: %w", namespace, name, err)
+	}
+	var config injectorConfig
+	if err := yaml.Unmarshal([]byte(cm.Data["config"]), &config); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse injector config: %w", err)
+	}
+	values := make(map[string]interface{})
+	if raw := cm.Data["values"]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &values); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse injector values: %w", err)
+		}
+	}
+	return &config, values, nil
+}
+
+// injectionTemplateValues validates a template change and returns the istiod Helm values that make it
+func injectionTemplateValues(config *injectorConfig, name, text string, remove bool, defaults []string, overrides map[string]interface{}) (map[string]interface{}, string, error) {
+	values := make(map[string]interface{})
+	var actions []string
+
+	if name != "" {
+		if errs := validation.IsDNS1123Label(name); len(errs) > 0 {
+			return nil, "", fmt.Errorf("template name %s: %s", name, strings.Join(errs, "; "))
+		}
+		if containsString(builtinInjectionTemplates, name) {
+			return nil, "", fmt.Errorf("%s is a built-in template; add a custom template and list it after %s in default_templates or the %s annotation so it is merged over the built-in one", name, name, injectionTemplatesAnnotation)
+		}
+		_, exists := config.Templates[name]
+		switch {
+		case remove && !exists:
+			return nil, "", fmt.Errorf("template %s does not exist", name)
+		case remove:
+			if containsString(config.DefaultTemplates, name) && len(defaults) == 0 {
+				return nil, "", fmt.Errorf("template %s is a default template; set default_templates without it in the same call", name)
+			}
+			values["sidecarInjectorWebhook.templates."+name] = nil
+			actions = append(actions, "remove template "+name)
+		case strings.TrimSpace(text) == "":
+			return nil, "", fmt.Errorf("template is required unless remove is set")
+		default:
+			if err := validateInjectionTemplate(name, text); err != nil {
+				return nil, "", err
+			}
+			values["sidecarInjectorWebhook.templates."+name] = text
+			if exists {
+				actions = append(actions, "replace template "+name)
+			} else {
+				actions = append(actions, "add template "+name)
+			}
+		}
+	} else if text != "" || remove {
+		return nil, "", fmt.Errorf("name is required with template or remove")
+	}
+
+	if len(defaults) > 0 {
+		for _, template := range defaults {
+			_, exists := config.Templates[template]
+			if !exists && (template != name || remove) {
+				return nil, "", fmt.Errorf("default template %s is not defined", template)
+			}
+		}
+		values["sidecarInjectorWebhook.defaultTemplates"] = defaults
+		actions = append(actions, "set default templates to "+strings.Join(defaults, ","))
+	}
+
+	// Only values the injection templates render may be overridden here
+	for key, value := range overrides {
+		if !strings.HasPrefix(key, "global.proxy.") && !strings.HasPrefix(key, "global.proxy_init.") && !strings.HasPrefix(key, "sidecarInjectorWebhook.") {
+			return nil, "", fmt.Errorf("value %s is not an injection value; use keys under global.proxy, global.proxy_init or sidecarInjectorWebhook", key)
+		}
+		if _, ok := values[key]; ok {
+			return nil, "", fmt.Errorf("value %s conflicts with the template change", key)
+		}
+		values[key] = value
+		actions = append(actions, "set "+key)
+	}
+
+	if len(actions) == 0 {
+		return nil, "", fmt.Errorf("nothing to change; set name with template or remove, default_templates or values")
+	}
+	sort.Strings(actions)
+	return values, strings.Join(actions, ", "), nil
+}
+
+// validateInjectionTemplate parses a template the way istiod does; istiod provides sprig and its own
+// helper functions, which are stubbed here so only the template syntax is checked
+func validateInjectionTemplate(name, text string) error {
+	funcs := template.FuncMap{}
+	for {
+		_, err := template.New(name).Funcs(funcs).Parse(text)
+		if err == nil {
+			break
+		}
+		match := undefinedTemplateFunction.FindStringSubmatch(err.Error())
+		if match == nil || funcs[match[1]] != nil {
+			return fmt.Errorf("template %s does not parse: %w", name, err)
+		}
+		funcs[match[1]] = func(...interface{}) interface{} { return nil }
+	}
+
+	// A template without actions must already be a valid pod patch
+	if !strings.Contains(text, "{{") {
+		var patch map[string]interface{}
+		if err := yaml.Unmarshal([]byte(text), &patch); err != nil {
+			return fmt.Errorf("template %s is not valid YAML: %w", name, err)
+		}
+		for key := range patch {
+			if key != "metadata" && key != "spec" {
+				return fmt.Errorf("template %s sets %s; injection templates patch only the pod metadata and spec", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+// previewInjection creates a canary pod with a server-side dry run, so the injection webhook renders it
+// without anything being persisted, and reports what the injector added
+func (m *Manager) previewInjection(ctx context.Context, namespace, revision string, templates []string, annotations map[string]string, showPod bool) (*InjectionPreview, error) {
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: "meshpilot-injection-preview-",
+			Namespace:    namespace,
+			Labels:       map[string]string{"app": "meshpilot-injection-preview", "sidecar.istio.io/inject": "true"},
+			Annotations:  make(map[string]string),
+		},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "app", Image: injectionPreviewImage}},
+		},
+	}
+	if revision != "" {
+		pod.Labels["istio.io/rev"] = revision
+	}
+	for key, value := range annotations {
+		pod.Annotations[key] = value
+	}
+	if len(templates) > 0 {
+		pod.Annotations[injectionTemplatesAnnotation] = strings.Join(templates, ",")
+	}
+
+	rendered, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).Create(ctx, pod, metav1.CreateOptions{DryRun: []string{metav1.DryRunAll}})
+	if err != nil {
+		return nil, err
+	}
+
+	preview := &InjectionPreview{
+		Namespace:   namespace,
+		Templates:   templates,
+		Injected:    podHasSidecar(rendered),
+		Annotations: make(map[string]string),
+	}
+	if len(preview.Templates) == 0 {
+		preview.Templates = []string{"(default)"}
+	}
+	for key, value := range rendered.Annotations {
+		if _, ok := pod.Annotations[key]; !ok {
+			preview.Annotations[key] = value
+		}
+	}
+
+	describe := func(c corev1.Container) InjectedContainer {
+		container := InjectedContainer{Name: c.Name, Image: c.Image, Injected: c.Name != "app", EnvVars: len(c.Env)}
+		if c.Lifecycle != nil {
+			if c.Lifecycle.PostStart != nil {
+				container.Lifecycle = append(container.Lifecycle, "postStart")
+			}
+			if c.Lifecycle.PreStop != nil {
+				container.Lifecycle = append(container.Lifecycle, "preStop")
+			}
+		}
+		for _, mount := range c.VolumeMounts {
+			container.VolumeMounts = append(container.VolumeMounts, mount.Name+":"+mount.MountPath)
+		}
+		return container
+	}
+	for _, c := range rendered.Spec.InitContainers {
+		preview.InitContainers = append(preview.InitContainers, describe(c))
+	}
+	for _, c := range rendered.Spec.Containers {
+		preview.Containers = append(preview.Containers, describe(c))
+	}
+	for _, volume := range rendered.Spec.Volumes {
+		if !strings.HasPrefix(volume.Name, "kube-api-access-") {
+			preview.Volumes = append(preview.Volumes, volume.Name)
+		}
+	}
+
+	if !preview.Injected {
+		preview.Notes = append(preview.Notes, "The pod was not injected: check that istiod is running, the revision exists and the namespace is not labeled istio-injection=disabled")
+	}
+	if showPod {
+		rendered.ManagedFields = nil
+		if out, err := yaml.Marshal(rendered); err == nil {
+			preview.Pod = string(out)
+		}
+	}
+	return preview, nil
+}
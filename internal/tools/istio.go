@@ -4,12 +4,17 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
 )
 
 // IstioStatus represents the status of Istio installation
@@ -29,19 +34,30 @@ type ComponentStatus struct {
 	Available int32  `json:"available"`
 }
 
+// installIstioParams are the arguments of install_istio
+type installIstioParams struct {
+	Namespace         string                 `json:"namespace,omitempty"`           // default: istio-system
+	Version           string                 `json:"version,omitempty"`             // Istio version
+	Profile           string                 `json:"profile,omitempty"`             // default (sidecar mode) or ambient
+	Values            map[string]interface{} `json:"values,omitempty"`              // custom helm values
+	InstallGateway    bool                   `json:"install_gateway,omitempty"`     // install ingress gateway
+	GatewayNamespace  string                 `json:"gateway_namespace,omitempty"`   // gateway namespace
+	GatewayMode       string                 `json:"gateway_mode,omitempty"`        // helm (default) or gateway_api
+	GatewayAPICRDs    bool                   `json:"gateway_api_crds,omitempty"`    // install the Gateway API CRDs
+	GatewayAPIVersion string                 `json:"gateway_api_version,omitempty"` // Gateway API release of the CRDs
+	InstallCNI        bool                   `json:"install_cni,omitempty"`         // install Istio CNI node agent
+	CNIValues         map[string]interface{} `json:"cni_values,omitempty"`          // custom CNI helm values
+	Timeout           string                 `json:"timeout,omitempty"`             // timeout for installation
+	Wait              bool                   `json:"wait,omitempty"`                // wait for deployment to be ready
+	RepoURL           string                 `json:"repo_url,omitempty"`            // chart repository or oci:// registry override
+	PinDigests        bool                   `json:"pin_digests,omitempty"`         // resolve image tags to digests and pin them
+	ImageVariant      string                 `json:"image_variant,omitempty"`       // default, distroless or fips
+	SkipPreflight     bool                   `json:"skip_preflight,omitempty"`      // skip the capacity and quota checks
+}
+
 // InstallIstio installs Istio on the cluster using Helm
-func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace        string                 `json:"namespace,omitempty"`         // default: istio-system
-		Version          string                 `json:"version,omitempty"`           // Istio version
-		Values           map[string]interface{} `json:"values,omitempty"`            // custom helm values
-		InstallGateway   bool                   `json:"install_gateway,omitempty"`   // install ingress gateway
-		GatewayNamespace string                 `json:"gateway_namespace,omitempty"` // gateway namespace
-		InstallCNI       bool                   `json:"install_cni,omitempty"`       // install Istio CNI node agent
-		CNIValues        map[string]interface{} `json:"cni_values,omitempty"`        // custom CNI helm values
-		Timeout          string                 `json:"timeout,omitempty"`           // timeout for installation
-		Wait             bool                   `json:"wait,omitempty"`              // wait for deployment to be ready
-	}
+func (m *Manager) InstallIstio(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params installIstioParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -66,6 +82,79 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 		params.Timeout = "5m"
 	}
 	params.Wait = true // Always wait for deployment to be ready
+	if params.ImageVariant == "" {
+		params.ImageVariant = "default"
+	}
+	if params.Profile == "" {
+		params.Profile = "default"
+	}
+	if params.GatewayMode == "" {
+		params.GatewayMode = "helm"
+	}
+	if params.GatewayAPIVersion == "" {
+		params.GatewayAPIVersion = defaultGatewayAPIVersion
+	}
+
+	// Ambient mode redirects traffic to ztunnel through the CNI node agent
+	ambient := params.Profile == "ambient"
+	switch params.Profile {
+	case "default":
+	case "ambient":
+		params.InstallCNI = true
+	default:
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid profile %q (valid: default, ambient)", params.Profile),
+				},
+			},
+		}, nil
+	}
+
+	// A Gateway API gateway needs the CRDs, and istiod only deploys it once they exist
+	var gatewayTimeout time.Duration
+	switch params.GatewayMode {
+	case "helm":
+	case "gateway_api":
+		params.GatewayAPICRDs = params.GatewayAPICRDs || params.InstallGateway
+		var err error
+		if gatewayTimeout, err = time.ParseDuration(params.Timeout); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+					},
+				},
+			}, nil
+		}
+	default:
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid gateway_mode %q (valid: helm, gateway_api)", params.GatewayMode),
+				},
+			},
+		}, nil
+	}
+
+	// Validate the image variant before touching the cluster
+	if err := validateImageVariant(params.ImageVariant, params.Values); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid image_variant: %v", err),
+				},
+			},
+		}, nil
+	}
 
 	// Check if Helm is available
 	if err := m.checkHelmAvailable(); err != nil {
@@ -74,14 +163,34 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Helm is not available: %v. Please install Helm to use this feature.", err),
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
 				},
 			},
 		}, nil
 	}
 
+	// Fail fast if the cluster can't accommodate the install
+	if !params.SkipPreflight {
+		requirements := istioInstallRequirements(params.Namespace, params.GatewayNamespace, params.Values, params.InstallGateway, params.InstallCNI, ambient)
+		report, err := m.runInstallPreflight(ctx, requirements)
+		if err != nil {
+			logrus.Warnf("Failed to run preflight checks: %v", err)
+		} else if !report.Passed {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Preflight checks failed; the install would leave pods Pending or rejected:\n%s\nResolve the issues or pass skip_preflight to install anyway.", formatPreflightFailures(report)),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Add Istio Helm repository
-	if err := m.addIstioHelmRepo(); err != nil {
+	repo := resolveChartRepository(m.config.Helm.Istio, params.RepoURL)
+	if err := m.addHelmRepo(ctx, repo); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -93,9 +202,89 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 		}, nil
 	}
 
+	// Apply the image variant consistently to every chart that renders Istio images;
+	// gateways pick up the variant through the proxy image injected by istiod
+	if params.ImageVariant != "default" {
+		if params.Values == nil {
+			params.Values = make(map[string]interface{})
+		}
+		setHelmValue(params.Values, "global.variant", params.ImageVariant)
+		if params.InstallCNI {
+			if params.CNIValues == nil {
+				params.CNIValues = make(map[string]interface{})
+			}
+			setHelmValue(params.CNIValues, "global.variant", params.ImageVariant)
+		}
+	}
+
+	// The ambient profile of each chart enables ambient redirection in istiod and the CNI node agent
+	var ztunnelValues map[string]interface{}
+	if ambient {
+		if params.Values == nil {
+			params.Values = make(map[string]interface{})
+		}
+		if params.CNIValues == nil {
+			params.CNIValues = make(map[string]interface{})
+		}
+		params.Values["profile"] = "ambient"
+		params.CNIValues["profile"] = "ambient"
+		// The ztunnel chart takes the image settings at the top level rather than under global
+		ztunnelValues = make(map[string]interface{})
+		for _, key := range []string{"hub", "tag", "variant"} {
+			if value := getHelmValue(params.Values, "global."+key); value != "" {
+				ztunnelValues[key] = value
+			}
+		}
+	}
+
+	// Resolve image tags to digests before anything is installed
+	var pinned []PinnedImage
+	if params.PinDigests {
+		if params.Values == nil {
+			params.Values = make(map[string]interface{})
+		}
+		if params.InstallCNI && params.CNIValues == nil {
+			params.CNIValues = make(map[string]interface{})
+		}
+		var err error
+		pinned, err = m.pinIstioImages(ctx, repo.ChartRef("istiod"), params.Version, params.Values, params.CNIValues, params.InstallCNI)
+		if err == nil && ambient {
+			err = pinZtunnelImage(ctx, params.Values, ztunnelValues, &pinned)
+		}
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to pin image digests: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	// Install the Gateway API CRDs before istiod, so its Gateway controller starts with them
+	gatewayAPIMessage := ""
+	if params.GatewayAPICRDs {
+		var err error
+		gatewayAPIMessage, err = m.installGatewayAPICRDs(ctx, params.GatewayAPIVersion)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to install Gateway API CRDs: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Install Istio CNI node agent first if requested
 	if params.InstallCNI {
-		if err := m.installIstioCNI(params.Namespace, params.Version, params.CNIValues, params.Wait, params.Timeout); err != nil {
+		if err := m.installIstioCNI(ctx, repo.ChartRef("cni"), params.Namespace, params.Version, params.CNIValues, params.Wait, params.Timeout); err != nil {
 			return &CallToolResult{
 				IsError: true,
 				Content: []interface{}{
@@ -109,7 +298,7 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 	}
 
 	// Install Istio base chart
-	if err := m.installIstioBase(params.Namespace, params.Version, params.Wait, params.Timeout); err != nil {
+	if err := m.installIstioBase(ctx, repo.ChartRef("base"), params.Namespace, params.Version, params.Wait, params.Timeout); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -146,7 +335,7 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 		}
 	}
 
-	if err := m.installIstiod(params.Namespace, params.Version, istiodValues, params.Wait, params.Timeout); err != nil {
+	if err := m.installIstiod(ctx, repo.ChartRef("istiod"), params.Namespace, params.Version, istiodValues, params.Wait, params.Timeout); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -158,17 +347,46 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 		}, nil
 	}
 
+	// ztunnel needs istiod to issue workload certificates, so it is installed last
+	if ambient {
+		if err := m.installIstioZtunnel(ctx, repo.ChartRef("ztunnel"), params.Namespace, params.Version, ztunnelValues, params.Wait, params.Timeout); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to install ztunnel: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
 	message := fmt.Sprintf("Istio successfully installed using Helm in namespace '%s'", params.Namespace)
 	if params.Version != "" {
 		message += fmt.Sprintf(" (version: %s)", params.Version)
 	}
-	if params.InstallCNI {
+	if ambient {
+		message += " in ambient mode with ztunnel and CNI node agent. Label namespaces with istio.io/dataplane-mode=ambient to add them to the mesh"
+	} else if params.InstallCNI {
 		message += " with CNI node agent"
 	}
 
-	// Optionally install ingress gateway
-	if params.InstallGateway {
-		if err := m.installIstioGateway(params.GatewayNamespace, params.Version, params.Wait, params.Timeout); err != nil {
+	if gatewayAPIMessage != "" {
+		message += ". " + gatewayAPIMessage
+	}
+
+	// Optionally install ingress gateway, as a Helm release or as a Gateway istiod deploys
+	if params.InstallGateway && params.GatewayMode == "gateway_api" {
+		service, err := m.deployIngressGateway(withManagingTool(ctx, "install_istio"), params.GatewayNamespace, gatewayTimeout)
+		if err != nil {
+			logrus.Warnf("Failed to deploy Istio gateway: %v", err)
+			message += fmt.Sprintf(". Warning: Gateway deployment failed: %v.", err)
+		} else {
+			message += fmt.Sprintf(". Ingress gateway deployed through the Gateway API as Gateway %s/%s with Service %s.", params.GatewayNamespace, ingressGatewayName, service)
+		}
+	} else if params.InstallGateway {
+		if err := m.installIstioGateway(ctx, repo.ChartRef("gateway"), params.GatewayNamespace, params.Version, params.Wait, params.Timeout); err != nil {
 			logrus.Warnf("Failed to install Istio gateway: %v", err)
 			message += ". Warning: Gateway installation failed."
 		} else {
@@ -176,8 +394,21 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 		}
 	}
 
+	// Verify the running images match the requested variant
+	if !params.PinDigests {
+		gatewayNamespace := ""
+		if params.InstallGateway {
+			gatewayNamespace = params.GatewayNamespace
+		}
+		if mismatches := m.verifyImageVariant(ctx, params.Namespace, gatewayNamespace, params.ImageVariant); len(mismatches) > 0 {
+			message += fmt.Sprintf(" Warning: images do not match the %s variant: %s.", params.ImageVariant, strings.Join(mismatches, ", "))
+		} else if params.ImageVariant != "default" {
+			message += fmt.Sprintf(" Verified %s images are running.", params.ImageVariant)
+		}
+	}
+
 	// Verify installation
-	status, err := m.getIstioStatus(params.Namespace)
+	status, err := m.getIstioStatus(ctx, params.Namespace)
 	if err != nil {
 		logrus.Warnf("Failed to verify Istio installation: %v", err)
 	}
@@ -188,6 +419,8 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 		message += " Use check_istio_status to monitor the deployment status."
 	}
 
+	message += formatPinnedImages(pinned)
+
 	return &CallToolResult{
 		Content: []interface{}{
 			TextContent{
@@ -198,16 +431,19 @@ func (m *Manager) InstallIstio(args json.RawMessage) (*CallToolResult, error) {
 	}, nil
 }
 
+// uninstallIstioParams are the arguments of uninstall_istio
+type uninstallIstioParams struct {
+	Namespace        string `json:"namespace,omitempty"`         // default: istio-system
+	GatewayNamespace string `json:"gateway_namespace,omitempty"` // gateway namespace
+	UninstallCNI     bool   `json:"uninstall_cni,omitempty"`     // uninstall Istio CNI node agent
+	DeleteCRDs       bool   `json:"delete_crds,omitempty"`       // delete Istio CRDs
+	Wait             bool   `json:"wait,omitempty"`              // wait for uninstall to complete
+	Timeout          string `json:"timeout,omitempty"`           // timeout for wait
+}
+
 // UninstallIstio uninstalls Istio from the cluster using Helm
-func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace        string `json:"namespace,omitempty"`         // default: istio-system
-		GatewayNamespace string `json:"gateway_namespace,omitempty"` // gateway namespace
-		UninstallCNI     bool   `json:"uninstall_cni,omitempty"`     // uninstall Istio CNI node agent
-		DeleteCRDs       bool   `json:"delete_crds,omitempty"`       // delete Istio CRDs
-		Wait             bool   `json:"wait,omitempty"`              // wait for uninstall to complete
-		Timeout          string `json:"timeout,omitempty"`           // timeout for wait
-	}
+func (m *Manager) UninstallIstio(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params uninstallIstioParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -240,7 +476,7 @@ func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error)
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Helm is not available: %v. Please install Helm to use this feature.", err),
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
 				},
 			},
 		}, nil
@@ -248,16 +484,30 @@ func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error)
 
 	var messages []string
 
+	// Remove the Gateway API ingress gateway install_istio deployed; its deployment is garbage collected with it
+	if removed, err := m.removeIngressGateway(ctx, params.GatewayNamespace); err != nil {
+		logrus.Warnf("Failed to remove the Gateway API ingress gateway: %v", err)
+		messages = append(messages, "Warning: Gateway API ingress gateway removal failed")
+	} else if removed {
+		messages = append(messages, fmt.Sprintf("Gateway API ingress gateway removed from namespace '%s'", params.GatewayNamespace))
+	}
+
 	// Uninstall gateway if it exists
-	if err := m.uninstallIstioGateway(params.GatewayNamespace, params.Wait, params.Timeout); err != nil {
+	if err := m.uninstallIstioGateway(ctx, params.GatewayNamespace, params.Wait, params.Timeout); err != nil {
 		logrus.Warnf("Failed to uninstall Istio gateway: %v", err)
 		messages = append(messages, "Warning: Gateway uninstall failed")
 	} else {
 		messages = append(messages, fmt.Sprintf("Gateway uninstalled from namespace '%s'", params.GatewayNamespace))
 	}
 
+	// Uninstall ztunnel before istiod, which it depends on for certificates
+	if err := m.uninstallIstioZtunnel(ctx, params.Namespace, params.Wait, params.Timeout); err != nil {
+		logrus.Warnf("Failed to uninstall ztunnel: %v", err)
+		messages = append(messages, "Warning: ztunnel uninstall failed")
+	}
+
 	// Uninstall Istio discovery (istiod)
-	if err := m.uninstallIstiod(params.Namespace, params.Wait, params.Timeout); err != nil {
+	if err := m.uninstallIstiod(ctx, params.Namespace, params.Wait, params.Timeout); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -271,7 +521,7 @@ func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error)
 	messages = append(messages, "Istio discovery (istiod) uninstalled")
 
 	// Uninstall Istio base
-	if err := m.uninstallIstioBase(params.Namespace, params.Wait, params.Timeout); err != nil {
+	if err := m.uninstallIstioBase(ctx, params.Namespace, params.Wait, params.Timeout); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -286,7 +536,7 @@ func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error)
 
 	// Uninstall CNI if requested (after base to maintain proper order)
 	if params.UninstallCNI {
-		if err := m.uninstallIstioCNI(params.Namespace, params.Wait, params.Timeout); err != nil {
+		if err := m.uninstallIstioCNI(ctx, params.Namespace, params.Wait, params.Timeout); err != nil {
 			logrus.Warnf("Failed to uninstall Istio CNI: %v", err)
 			messages = append(messages, "Warning: CNI uninstall failed")
 		} else {
@@ -296,7 +546,7 @@ func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error)
 
 	// Optionally delete CRDs
 	if params.DeleteCRDs {
-		if err := m.deleteIstioCRDs(); err != nil {
+		if err := m.deleteIstioCRDs(ctx); err != nil {
 			logrus.Warnf("Failed to delete Istio CRDs: %v", err)
 			messages = append(messages, "Warning: Failed to delete Istio CRDs")
 		} else {
@@ -314,11 +564,14 @@ func (m *Manager) UninstallIstio(args json.RawMessage) (*CallToolResult, error)
 	}, nil
 }
 
+// checkIstioStatusParams are the arguments of check_istio_status
+type checkIstioStatusParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: istio-system
+}
+
 // CheckIstioStatus checks the status of Istio installation
-func (m *Manager) CheckIstioStatus(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace string `json:"namespace,omitempty"` // default: istio-system
-	}
+func (m *Manager) CheckIstioStatus(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkIstioStatusParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -337,7 +590,7 @@ func (m *Manager) CheckIstioStatus(args json.RawMessage) (*CallToolResult, error
 	}
 
 	// Get status using the helper function
-	status, err := m.getIstioStatus(params.Namespace)
+	status, err := m.getIstioStatus(ctx, params.Namespace)
 	if err != nil {
 		return &CallToolResult{
 			IsError: true,
@@ -361,212 +614,241 @@ func (m *Manager) CheckIstioStatus(args json.RawMessage) (*CallToolResult, error
 	}, nil
 }
 
-// addIstioHelmRepo adds the Istio Helm repository
-func (m *Manager) addIstioHelmRepo() error {
-	// Add the repository
-	cmd := exec.Command("helm", "repo", "add", "istio", "https://istio-release.storage.googleapis.com/charts")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		// Check if repo already exists
-		if !strings.Contains(string(output), "already exists") {
-			return fmt.Errorf("failed to add istio helm repo: %w, output: %s", err, string(output))
+// pinIstioImages resolves the Istio images to digests and sets them as full image references
+// in the istiod and CNI values; gateways use the proxy image injected by istiod
+func (m *Manager) pinIstioImages(ctx context.Context, istiodChart, version string, istiodValues, cniValues map[string]interface{}, includeCNI bool) ([]PinnedImage, error) {
+	hub := getHelmValue(istiodValues, "global.hub")
+	if hub == "" {
+		hub = "docker.io/istio"
+	}
+	tag := getHelmValue(istiodValues, "global.tag")
+	if tag == "" {
+		tag = version
+	}
+	if tag == "" {
+		appVersion, err := m.getChartAppVersion(ctx, istiodChart, version)
+		if err != nil {
+			return nil, fmt.Errorf("failed to determine image tag: %w", err)
 		}
+		tag = appVersion
 	}
 
-	// Update repository
-	cmd = exec.Command("helm", "repo", "update", "istio")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to update istio helm repo: %w, output: %s", err, string(output))
+	if variant := getHelmValue(istiodValues, "global.variant"); variant != "" {
+		tag += "-" + variant
 	}
 
-	return nil
-}
+	var pinned []PinnedImage
 
-// installIstioBase installs the Istio base chart (CRDs and cluster roles)
-func (m *Manager) installIstioBase(namespace, version string, wait bool, timeout string) error {
-	args := []string{
-		"install", "istio-base", "istio/base",
-		"--namespace", namespace,
-		"--create-namespace",
+	pilot, err := pinImage(ctx, fmt.Sprintf("%s/pilot:%s", hub, tag), &pinned)
+	if err != nil {
+		return nil, err
 	}
+	setHelmValue(istiodValues, "pilot.image", pilot)
 
-	// Add version if specified
-	if version != "" {
-		args = append(args, "--version", version)
+	proxy, err := pinImage(ctx, fmt.Sprintf("%s/proxyv2:%s", hub, tag), &pinned)
+	if err != nil {
+		return nil, err
 	}
+	setHelmValue(istiodValues, "global.proxy.image", proxy)
+	setHelmValue(istiodValues, "global.proxy_init.image", proxy)
 
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
+	if includeCNI {
+		cniHub := getHelmValue(cniValues, "cni.hub")
+		if cniHub == "" {
+			cniHub = hub
 		}
+		cni, err := pinImage(ctx, fmt.Sprintf("%s/install-cni:%s", cniHub, tag), &pinned)
+		if err != nil {
+			return nil, err
+		}
+		setHelmValue(cniValues, "cni.image", cni)
 	}
 
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
+	return pinned, nil
+}
+
+// pinZtunnelImage resolves the ztunnel image to a digest with the same hub, tag and variant as the
+// control plane images, which pinIstioImages has already resolved in the istiod values
+func pinZtunnelImage(ctx context.Context, istiodValues, ztunnelValues map[string]interface{}, pinned *[]PinnedImage) error {
+	pilot := getHelmValue(istiodValues, "pilot.image")
+	for _, image := range *pinned {
+		if image.Reference == pilot {
+			pilot = image.Image
+			break
+		}
+	}
+	idx := strings.LastIndex(pilot, "/pilot:")
+	if idx < 0 {
+		return fmt.Errorf("cannot derive the ztunnel image from %s", pilot)
+	}
+	ztunnel, err := pinImage(ctx, pilot[:idx]+"/ztunnel:"+pilot[idx+len("/pilot:"):], pinned)
 	if err != nil {
-		return fmt.Errorf("helm install istio-base failed: %w, output: %s", err, string(output))
+		return err
 	}
-
-	logrus.Infof("Istio base chart install output: %s", string(output))
+	ztunnelValues["image"] = ztunnel
 	return nil
 }
 
-// installIstiod installs the Istio discovery chart (istiod)
-func (m *Manager) installIstiod(namespace, version string, values map[string]interface{}, wait bool, timeout string) error {
-	args := []string{
-		"install", "istiod", "istio/istiod",
-		"--namespace", namespace,
+// validateImageVariant checks the requested image variant against the configured image hub
+func validateImageVariant(variant string, values map[string]interface{}) error {
+	switch variant {
+	case "default", "distroless":
+		return nil
+	case "fips":
+		// Upstream Istio doesn't publish FIPS builds; they come from vendor registries
+		if hub := getHelmValue(values, "global.hub"); hub == "" || hub == "docker.io/istio" {
+			return fmt.Errorf("the fips variant requires values.global.hub to point at a registry that publishes FIPS builds")
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported variant %q (valid: default, distroless, fips)", variant)
 	}
+}
 
-	// Add version if specified
-	if version != "" {
-		args = append(args, "--version", version)
-	}
+// verifyImageVariant checks that the Istio images running in the control plane, CNI and
+// gateway pods carry the requested variant, returning the mismatched images
+func (m *Manager) verifyImageVariant(ctx context.Context, namespace, gatewayNamespace, variant string) []string {
+	var mismatches []string
 
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
+	// The CNI node agent may run outside the control plane namespace
+	selectors := map[string][]string{
+		namespace: {meshImageSources["istiod"], meshImageSources["ztunnel"]},
+		"":        {meshImageSources["cni"]},
+	}
+	if gatewayNamespace != "" {
+		selectors[gatewayNamespace] = append(selectors[gatewayNamespace], meshImageSources["gateway"])
 	}
 
-	// Add custom values if provided
-	if len(values) > 0 {
-		// Convert values to JSON and use --set-json
-		for key, value := range values {
-			valueJSON, err := json.Marshal(value)
+	for ns, nsSelectors := range selectors {
+		for _, selector := range nsSelectors {
+			pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(ns).List(ctx, metav1.ListOptions{
+				LabelSelector: selector,
+			})
 			if err != nil {
-				return fmt.Errorf("failed to marshal value for key %s: %w", key, err)
+				logrus.Warnf("Failed to list pods for variant verification: %v", err)
+				continue
+			}
+			for _, pod := range pods.Items {
+				for _, container := range append(pod.Spec.InitContainers, pod.Spec.Containers...) {
+					if !isIstioImage(container.Image) {
+						continue
+					}
+					if imageVariant(container.Image) != variant && !containsString(mismatches, container.Image) {
+						mismatches = append(mismatches, container.Image)
+					}
+				}
 			}
-			args = append(args, "--set-json", fmt.Sprintf("%s=%s", key, string(valueJSON)))
 		}
 	}
 
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("helm install istiod failed: %w, output: %s", err, string(output))
-	}
-
-	logrus.Infof("Istiod chart install output: %s", string(output))
-	return nil
+	return mismatches
 }
 
-// installIstioGateway installs the Istio ingress gateway
-func (m *Manager) installIstioGateway(namespace, version string, wait bool, timeout string) error {
-	args := []string{
-		"install", "istio-ingress", "istio/gateway",
-		"--namespace", namespace,
-		"--create-namespace",
+// isIstioImage reports whether an image is one of the Istio component images
+func isIstioImage(image string) bool {
+	name := image
+	if idx := strings.LastIndex(name, "/"); idx >= 0 {
+		name = name[idx+1:]
 	}
-
-	// Add version if specified
-	if version != "" {
-		args = append(args, "--version", version)
-	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
+	for _, component := range []string{"pilot", "proxyv2", "install-cni", "ztunnel"} {
+		if strings.HasPrefix(name, component+":") || strings.HasPrefix(name, component+"@") {
+			return true
 		}
 	}
-
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("helm install istio-ingress failed: %w, output: %s", err, string(output))
-	}
-
-	logrus.Infof("Istio gateway install output: %s", string(output))
-	return nil
+	return false
 }
 
-// uninstallIstioGateway uninstalls the Istio gateway
-func (m *Manager) uninstallIstioGateway(namespace string, wait bool, timeout string) error {
-	args := []string{
-		"uninstall", "istio-ingress",
-		"--namespace", namespace,
+// imageVariant returns the variant encoded in an Istio image tag suffix
+func imageVariant(image string) string {
+	idx := strings.LastIndex(image, ":")
+	if idx < 0 || strings.Contains(image[idx:], "/") {
+		return "default"
 	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
+	tag := image[idx+1:]
+	for _, variant := range []string{"distroless", "fips"} {
+		if strings.HasSuffix(tag, "-"+variant) {
+			return variant
 		}
 	}
+	return "default"
+}
 
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
+// installIstioBase installs the Istio base chart (CRDs and cluster roles)
+func (m *Manager) installIstioBase(ctx context.Context, chart, namespace, version string, wait bool, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:         "istio-base",
+		Chart:           chart,
+		Namespace:       namespace,
+		Version:         version,
+		CreateNamespace: true,
+		Wait:            wait,
+		Timeout:         timeout,
+	})
 	if err != nil {
-		// Don't fail if release doesn't exist
-		if strings.Contains(string(output), "not found") {
-			return nil
-		}
-		return fmt.Errorf("helm uninstall istio-ingress failed: %w, output: %s", err, string(output))
+		return err
 	}
 
-	logrus.Infof("Istio gateway uninstall output: %s", string(output))
+	logrus.Infof("Istio base chart installed: %s", release)
 	return nil
 }
 
-// uninstallIstiod uninstalls the Istio discovery chart
-func (m *Manager) uninstallIstiod(namespace string, wait bool, timeout string) error {
-	args := []string{
-		"uninstall", "istiod",
-		"--namespace", namespace,
+// installIstiod installs the Istio discovery chart (istiod)
+func (m *Manager) installIstiod(ctx context.Context, chart, namespace, version string, values map[string]interface{}, wait bool, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:   "istiod",
+		Chart:     chart,
+		Namespace: namespace,
+		Version:   version,
+		Values:    values,
+		Wait:      wait,
+		Timeout:   timeout,
+	})
+	if err != nil {
+		return err
 	}
 
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
-	}
+	logrus.Infof("Istiod chart installed: %s", release)
+	return nil
+}
 
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
+// installIstioGateway installs the Istio ingress gateway
+func (m *Manager) installIstioGateway(ctx context.Context, chart, namespace, version string, wait bool, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:         "istio-ingress",
+		Chart:           chart,
+		Namespace:       namespace,
+		Version:         version,
+		CreateNamespace: true,
+		Wait:            wait,
+		Timeout:         timeout,
+	})
 	if err != nil {
-		return fmt.Errorf("helm uninstall istiod failed: %w, output: %s", err, string(output))
+		return err
 	}
 
-	logrus.Infof("Istiod uninstall output: %s", string(output))
+	logrus.Infof("Istio gateway installed: %s", release)
 	return nil
 }
 
-// uninstallIstioBase uninstalls the Istio base chart
-func (m *Manager) uninstallIstioBase(namespace string, wait bool, timeout string) error {
-	args := []string{
-		"uninstall", "istio-base",
-		"--namespace", namespace,
-	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
-	}
+// uninstallIstioGateway uninstalls the Istio gateway
+func (m *Manager) uninstallIstioGateway(ctx context.Context, namespace string, wait bool, timeout string) error {
+	// Don't fail if release doesn't exist
+	return m.uninstallHelmRelease(ctx, namespace, "istio-ingress", true, wait, timeout)
+}
 
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("helm uninstall istio-base failed: %w, output: %s", err, string(output))
-	}
+// uninstallIstiod uninstalls the Istio discovery chart
+func (m *Manager) uninstallIstiod(ctx context.Context, namespace string, wait bool, timeout string) error {
+	return m.uninstallHelmRelease(ctx, namespace, "istiod", false, wait, timeout)
+}
 
-	logrus.Infof("Istio base uninstall output: %s", string(output))
-	return nil
+// uninstallIstioBase uninstalls the Istio base chart
+func (m *Manager) uninstallIstioBase(ctx context.Context, namespace string, wait bool, timeout string) error {
+	return m.uninstallHelmRelease(ctx, namespace, "istio-base", false, wait, timeout)
 }
 
 // deleteIstioCRDs deletes Istio Custom Resource Definitions
-func (m *Manager) deleteIstioCRDs() error {
-	cmd := exec.Command("kubectl", "get", "crd", "-oname")
+func (m *Manager) deleteIstioCRDs(ctx context.Context) error {
+	cmd := m.kubectlCommand(ctx, "get", "crd", "-oname")
 	output, err := cmd.CombinedOutput()
 	if err != nil {
 		return fmt.Errorf("failed to get CRDs: %w", err)
@@ -584,7 +866,7 @@ func (m *Manager) deleteIstioCRDs() error {
 	// Delete Istio CRDs
 	if len(istioCRDs) > 0 {
 		args := append([]string{"delete"}, istioCRDs...)
-		cmd = exec.Command("kubectl", args...)
+		cmd = m.kubectlCommand(ctx, args...)
 		output, err := cmd.CombinedOutput()
 		if err != nil {
 			return fmt.Errorf("failed to delete Istio CRDs: %w, output: %s", err, string(output))
@@ -596,79 +878,57 @@ func (m *Manager) deleteIstioCRDs() error {
 }
 
 // installIstioCNI installs the Istio CNI node agent
-func (m *Manager) installIstioCNI(namespace, version string, values map[string]interface{}, wait bool, timeout string) error {
-	args := []string{
-		"install", "istio-cni", "istio/cni",
-		"--namespace", namespace,
-	}
-
-	// Add version if specified
-	if version != "" {
-		args = append(args, "--version", version)
-	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
-	}
-
-	// Add custom values if provided
-	if len(values) > 0 {
-		// Convert values to JSON and use --set-json
-		for key, value := range values {
-			valueJSON, err := json.Marshal(value)
-			if err != nil {
-				return fmt.Errorf("failed to marshal CNI value for key %s: %w", key, err)
-			}
-			args = append(args, "--set-json", fmt.Sprintf("%s=%s", key, string(valueJSON)))
-		}
-	}
-
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
+func (m *Manager) installIstioCNI(ctx context.Context, chart, namespace, version string, values map[string]interface{}, wait bool, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:   "istio-cni",
+		Chart:     chart,
+		Namespace: namespace,
+		Version:   version,
+		Values:    values,
+		Wait:      wait,
+		Timeout:   timeout,
+	})
 	if err != nil {
-		return fmt.Errorf("helm install istio-cni failed: %w, output: %s", err, string(output))
+		return err
 	}
 
-	logrus.Infof("Istio CNI install output: %s", string(output))
+	logrus.Infof("Istio CNI installed: %s", release)
 	return nil
 }
 
-// uninstallIstioCNI uninstalls the Istio CNI node agent
-func (m *Manager) uninstallIstioCNI(namespace string, wait bool, timeout string) error {
-	args := []string{
-		"uninstall", "istio-cni",
-		"--namespace", namespace,
-	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
-	}
-
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
+// installIstioZtunnel installs the ztunnel node proxy that carries ambient mesh traffic
+func (m *Manager) installIstioZtunnel(ctx context.Context, chart, namespace, version string, values map[string]interface{}, wait bool, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:   "ztunnel",
+		Chart:     chart,
+		Namespace: namespace,
+		Version:   version,
+		Values:    values,
+		Wait:      wait,
+		Timeout:   timeout,
+	})
 	if err != nil {
-		// Don't fail if release doesn't exist
-		if strings.Contains(string(output), "not found") {
-			return nil
-		}
-		return fmt.Errorf("helm uninstall istio-cni failed: %w, output: %s", err, string(output))
+		return err
 	}
 
-	logrus.Infof("Istio CNI uninstall output: %s", string(output))
+	logrus.Infof("ztunnel installed: %s", release)
 	return nil
 }
 
+// uninstallIstioZtunnel uninstalls ztunnel
+func (m *Manager) uninstallIstioZtunnel(ctx context.Context, namespace string, wait bool, timeout string) error {
+	// Don't fail if release doesn't exist
+	return m.uninstallHelmRelease(ctx, namespace, "ztunnel", true, wait, timeout)
+}
+
+// uninstallIstioCNI uninstalls the Istio CNI node agent
+func (m *Manager) uninstallIstioCNI(ctx context.Context, namespace string, wait bool, timeout string) error {
+	// Don't fail if release doesn't exist
+	return m.uninstallHelmRelease(ctx, namespace, "istio-cni", true, wait, timeout)
+}
+
 // getIstioStatus gets the current status of Istio installation
-func (m *Manager) getIstioStatus(namespace string) (*IstioStatus, error) {
-	ctx := context.Background()
+func (m *Manager) getIstioStatus(ctx context.Context, namespace string) (*IstioStatus, error) {
 
 	// Check if namespace exists
 	_, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
@@ -688,7 +948,8 @@ func (m *Manager) getIstioStatus(namespace string) (*IstioStatus, error) {
 
 	// Check for CNI DaemonSet in addition to regular components
 	cniDS, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets(namespace).Get(ctx, "istio-cni-node", metav1.GetOptions{})
-	if err == nil {
+	cniInstalled := err == nil
+	if cniInstalled {
 		// CNI is installed
 		ready := cniDS.Status.NumberReady == cniDS.Status.DesiredNumberScheduled && cniDS.Status.DesiredNumberScheduled > 0
 		componentStatuses = append(componentStatuses, ComponentStatus{
@@ -703,6 +964,26 @@ func (m *Manager) getIstioStatus(namespace string) (*IstioStatus, error) {
 		installed = true
 	}
 
+	// ztunnel runs on every node in ambient mode
+	ztunnelDS, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets(namespace).Get(ctx, "ztunnel", metav1.GetOptions{})
+	if err == nil {
+		ready := ztunnelDS.Status.NumberReady == ztunnelDS.Status.DesiredNumberScheduled && ztunnelDS.Status.DesiredNumberScheduled > 0
+		componentStatuses = append(componentStatuses, ComponentStatus{
+			Name:      "ztunnel",
+			Ready:     ready,
+			Replicas:  ztunnelDS.Status.DesiredNumberScheduled,
+			Available: ztunnelDS.Status.NumberReady,
+		})
+		if !ready {
+			issues = append(issues, fmt.Sprintf("ztunnel is not ready on all nodes (%d/%d); pods on the other nodes have no ambient connectivity",
+				ztunnelDS.Status.NumberReady, ztunnelDS.Status.DesiredNumberScheduled))
+		}
+		if !cniInstalled {
+			issues = append(issues, "ztunnel is installed without the istio-cni-node DaemonSet, which ambient mode needs to redirect traffic")
+		}
+		installed = true
+	}
+
 	for _, componentName := range components {
 		// Try to find deployment with Helm labels first
 		deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
@@ -754,37 +1035,209 @@ func (m *Manager) getIstioStatus(namespace string) (*IstioStatus, error) {
 
 // getIstioHelmReleaseVersion gets the version of a Helm release
 func (m *Manager) getIstioHelmReleaseVersion(namespace, releaseName string) (string, error) {
-	cmd := exec.Command("helm", "list", "--namespace", namespace, "--filter", releaseName, "--output", "json")
-	output, err := cmd.CombinedOutput()
+	release, err := m.getHelmReleaseInfo(namespace, releaseName)
 	if err != nil {
-		return "", fmt.Errorf("failed to get helm release info: %w", err)
+		return "", err
 	}
 
-	var releases []struct {
-		Name       string `json:"name"`
-		Namespace  string `json:"namespace"`
-		Revision   string `json:"revision"`
-		Updated    string `json:"updated"`
-		Status     string `json:"status"`
-		Chart      string `json:"chart"`
-		AppVersion string `json:"app_version"`
+	// Return chart version or app version
+	if release.Chart != "" {
+		return release.Chart, nil
 	}
+	if release.AppVersion != "" {
+		return release.AppVersion, nil
+	}
+
+	return "unknown", nil
+}
+
+// ChartVersion represents an installable version of a Helm chart
+type ChartVersion struct {
+	Chart      string `json:"chart"`
+	Version    string `json:"version"`
+	AppVersion string `json:"app_version,omitempty"`
+	Released   string `json:"released,omitempty"`
+	Prerelease bool   `json:"prerelease,omitempty"`
+}
 
-	if err := json.Unmarshal(output, &releases); err != nil {
-		return "", fmt.Errorf("failed to parse helm release info: %w", err)
+// listAvailableIstioVersionsParams are the arguments of list_available_istio_versions
+type listAvailableIstioVersionsParams struct {
+	Chart             string `json:"chart,omitempty"`              // default: istiod
+	IncludePrerelease bool   `json:"include_prerelease,omitempty"` // include alpha/beta/rc versions
+	Limit             int    `json:"limit,omitempty"`              // default: 20
+	RepoURL           string `json:"repo_url,omitempty"`           // chart repository override
+}
+
+// ListAvailableIstioVersions lists the Istio chart versions available from the configured Helm repository
+func (m *Manager) ListAvailableIstioVersions(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params listAvailableIstioVersionsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
 	}
 
-	if len(releases) == 0 {
-		return "", fmt.Errorf("release %s not found", releaseName)
+	if params.Limit < 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Invalid parameters: limit must be positive",
+				},
+			},
+		}, nil
 	}
 
-	// Return chart version or app version
-	if releases[0].Chart != "" {
-		return releases[0].Chart, nil
+	// Set defaults
+	if params.Chart == "" {
+		params.Chart = "istiod"
 	}
-	if releases[0].AppVersion != "" {
-		return releases[0].AppVersion, nil
+	if params.Limit == 0 {
+		params.Limit = 20
 	}
 
-	return "unknown", nil
+	// Check if Helm is available
+	if err := m.checkHelmAvailable(); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
+				},
+			},
+		}, nil
+	}
+
+	repo := resolveChartRepository(m.config.Helm.Istio, params.RepoURL)
+	if repo.IsOCI() {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Listing versions is not supported for OCI registries (%s); query the registry tags directly", repo.URL),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to add Istio Helm repository: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	versions, err := m.getChartVersions(repo.Name, params.Chart)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list versions for chart %s: %v", params.Chart, err),
+				},
+			},
+		}, nil
+	}
+
+	var filtered []ChartVersion
+	for _, v := range versions {
+		if v.Prerelease && !params.IncludePrerelease {
+			continue
+		}
+		filtered = append(filtered, v)
+	}
+
+	total := len(filtered)
+	if len(filtered) > params.Limit {
+		filtered = filtered[:params.Limit]
+	}
+
+	result := map[string]interface{}{
+		"repository": repo.URL,
+		"chart":      params.Chart,
+		"total":      total,
+		"versions":   filtered,
+	}
+	if len(filtered) > 0 {
+		result["latest"] = filtered[0].Version
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getChartVersions reads chart versions, newest first, from the cached index of a Helm repository
+func (m *Manager) getChartVersions(repoName, chart string) ([]ChartVersion, error) {
+	indexPath := filepath.Join(helmRepositoryCache(), repoName+"-index.yaml")
+	data, err := os.ReadFile(indexPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read repository index: %w", err)
+	}
+
+	var index struct {
+		Entries map[string][]struct {
+			Version    string `json:"version"`
+			AppVersion string `json:"appVersion"`
+			Created    string `json:"created"`
+		} `json:"entries"`
+	}
+	if err := yaml.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse repository index: %w", err)
+	}
+
+	entries, exists := index.Entries[chart]
+	if !exists {
+		return nil, fmt.Errorf("chart %s not found in repository %s", chart, repoName)
+	}
+
+	var versions []ChartVersion
+	for _, entry := range entries {
+		chartVersion := ChartVersion{
+			Chart:      chart,
+			Version:    entry.Version,
+			AppVersion: entry.AppVersion,
+		}
+		if created, err := time.Parse(time.RFC3339Nano, entry.Created); err == nil {
+			chartVersion.Released = created.Format("2006-01-02")
+		}
+		if parsed, err := utilversion.ParseSemantic(entry.Version); err == nil {
+			chartVersion.Prerelease = parsed.PreRelease() != ""
+		}
+		versions = append(versions, chartVersion)
+	}
+
+	// Sort newest first, falling back to string comparison for non-semver versions
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, errI := utilversion.ParseSemantic(versions[i].Version)
+		vj, errJ := utilversion.ParseSemantic(versions[j].Version)
+		if errI != nil || errJ != nil {
+			return versions[i].Version > versions[j].Version
+		}
+		return vj.LessThan(vi)
+	})
+
+	return versions, nil
 }
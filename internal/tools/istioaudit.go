@@ -0,0 +1,543 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// IstioResourceFinding is an Istio resource that references something missing or selects nothing
+type IstioResourceFinding struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Severity  string `json:"severity"` // broken: a reference does not resolve; unused: the resource has no effect
+	Reference string `json:"reference,omitempty"`
+	Problem   string `json:"problem"`
+}
+
+// IstioResourceAudit is the result of auditing Istio resources for dangling references
+type IstioResourceAudit struct {
+	Namespace string                 `json:"namespace"`
+	Scanned   map[string]int         `json:"scanned"`
+	Summary   map[string]int         `json:"summary"` // findings per severity
+	Findings  []IstioResourceFinding `json:"findings"`
+	Notes     []string               `json:"notes,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+}
+
+// istioMeta is the metadata shared by the Istio resources the audit decodes
+type istioMeta struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// istioDestination is a route destination of a VirtualService
+type istioDestination struct {
+	Destination struct {
+		Host   string `json:"host"`
+		Subset string `json:"subset,omitempty"`
+	} `json:"destination"`
+}
+
+type auditVirtualService struct {
+	Metadata istioMeta `json:"metadata"`
+	Spec     struct {
+		Hosts    []string `json:"hosts,omitempty"`
+		Gateways []string `json:"gateways,omitempty"`
+		HTTP     []struct {
+			Match []struct {
+				Gateways []string `json:"gateways,omitempty"`
+			} `json:"match,omitempty"`
+			Route  []istioDestination `json:"route,omitempty"`
+			Mirror *struct {
+				Host   string `json:"host"`
+				Subset string `json:"subset,omitempty"`
+			} `json:"mirror,omitempty"`
+		} `json:"http,omitempty"`
+		TCP []struct {
+			Route []istioDestination `json:"route,omitempty"`
+		} `json:"tcp,omitempty"`
+		TLS []struct {
+			Route []istioDestination `json:"route,omitempty"`
+		} `json:"tls,omitempty"`
+	} `json:"spec"`
+}
+
+type auditDestinationRule struct {
+	Metadata istioMeta `json:"metadata"`
+	Spec     struct {
+		Host    string `json:"host"`
+		Subsets []struct {
+			Name   string            `json:"name"`
+			Labels map[string]string `json:"labels,omitempty"`
+		} `json:"subsets,omitempty"`
+	} `json:"spec"`
+}
+
+type auditGateway struct {
+	Metadata istioMeta `json:"metadata"`
+	Spec     struct {
+		Selector map[string]string `json:"selector,omitempty"`
+		Servers  []struct {
+			Port struct {
+				Number   int    `json:"number"`
+				Protocol string `json:"protocol,omitempty"`
+			} `json:"port"`
+			Hosts []string `json:"hosts,omitempty"`
+			TLS   *struct {
+				CredentialName string `json:"credentialName,omitempty"`
+			} `json:"tls,omitempty"`
+		} `json:"servers,omitempty"`
+	} `json:"spec"`
+}
+
+type auditServiceEntry struct {
+	Metadata istioMeta `json:"metadata"`
+	Spec     struct {
+		Hosts []string `json:"hosts"`
+	} `json:"spec"`
+}
+
+// auditWorkloadPolicy covers the selector-based policies (AuthorizationPolicy, PeerAuthentication)
+type auditWorkloadPolicy struct {
+	Metadata istioMeta `json:"metadata"`
+	Spec     struct {
+		Selector *struct {
+			MatchLabels map[string]string `json:"matchLabels,omitempty"`
+		} `json:"selector,omitempty"`
+		TargetRef  *policyTargetRef  `json:"targetRef,omitempty"`
+		TargetRefs []policyTargetRef `json:"targetRefs,omitempty"`
+	} `json:"spec"`
+}
+
+// istioAuditInventory is the cluster state references are resolved against
+type istioAuditInventory struct {
+	domain       string
+	namespaces   map[string]bool
+	services     map[string]*corev1.Service // keyed by FQDN
+	serviceHosts []string                   // ServiceEntry hosts, possibly wildcards
+	gateways     map[string]bool            // <namespace>/<name>
+	subsets      map[string]map[string]bool // FQDN -> subset names
+	pods         []corev1.Pod
+}
+
+// istioConfig holds the Istio resources of the cluster, decoded for auditing
+type istioConfig struct {
+	serviceEntries        []auditServiceEntry
+	gateways              []auditGateway
+	virtualServices       []auditVirtualService
+	destinationRules      []auditDestinationRule
+	authorizationPolicies []auditWorkloadPolicy
+	peerAuthentications   []auditWorkloadPolicy
+	notes                 []string // Istio CRDs that are not installed
+}
+
+// auditIstioResourcesParams are the arguments of audit_istio_resources
+type auditIstioResourcesParams struct {
+	Namespace     string `json:"namespace,omitempty"`      // namespace to audit, default: all
+	ClusterDomain string `json:"cluster_domain,omitempty"` // default: cluster.local
+}
+
+// AuditIstioResources finds VirtualServices, DestinationRules, Gateways and policies that reference
+// missing gateways, hosts, namespaces or subsets, or select no workloads, as cleanup candidates
+func (m *Manager) AuditIstioResources(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params auditIstioResourcesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.ClusterDomain == "" {
+		params.ClusterDomain = "cluster.local"
+	}
+
+	report := &IstioResourceAudit{
+		Namespace: params.Namespace,
+		Summary:   map[string]int{"broken": 0, "unused": 0},
+		Timestamp: time.Now(),
+	}
+	if report.Namespace == "" {
+		report.Namespace = "all"
+	}
+
+	inv, config, err := m.loadIstioAuditInventory(ctx, params.ClusterDomain)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to load Istio resources: %v", err),
+				},
+			},
+		}, nil
+	}
+	report.Notes = config.notes
+	report.Findings, report.Scanned = m.auditIstioConfig(ctx, inv, config, params.Namespace)
+	for _, finding := range report.Findings {
+		report.Summary[finding.Severity]++
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// loadIstioAuditInventory reads the namespaces, services, pods and Istio resources of the whole cluster;
+// references cross namespaces, so the inventory always covers every namespace
+func (m *Manager) loadIstioAuditInventory(ctx context.Context, domain string) (*istioAuditInventory, *istioConfig, error) {
+	inv := &istioAuditInventory{
+		domain:     domain,
+		namespaces: make(map[string]bool),
+		services:   make(map[string]*corev1.Service),
+		gateways:   make(map[string]bool),
+		subsets:    make(map[string]map[string]bool),
+	}
+	namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list namespaces: %w", err)
+	}
+	for _, ns := range namespaces.Items {
+		inv.namespaces[ns.Name] = true
+	}
+	services, err := m.k8sClient.Kubernetes.CoreV1().Services("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list services: %w", err)
+	}
+	for i := range services.Items {
+		svc := &services.Items[i]
+		inv.services[fmt.Sprintf("%s.%s.svc.%s", svc.Name, svc.Namespace, inv.domain)] = svc
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != corev1.PodSucceeded && pod.Status.Phase != corev1.PodFailed {
+			inv.pods = append(inv.pods, pod)
+		}
+	}
+
+	config := &istioConfig{}
+	lists := []struct {
+		kind string
+		gvr  schema.GroupVersionResource
+		out  interface{}
+	}{
+		{"ServiceEntry", serviceEntryGVR, &config.serviceEntries},
+		{"Gateway", istioGatewayGVR, &config.gateways},
+		{"VirtualService", virtualServiceGVR, &config.virtualServices},
+		{"DestinationRule", destinationRuleGVR, &config.destinationRules},
+		{"AuthorizationPolicy", authorizationPolicyGVRs[1], &config.authorizationPolicies},
+		{"PeerAuthentication", peerAuthenticationGVR, &config.peerAuthentications},
+	}
+	for _, list := range lists {
+		items, err := m.k8sClient.Dynamic.Resource(list.gvr).Namespace("").List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				config.notes = append(config.notes, fmt.Sprintf("%s CRD is not installed", list.kind))
+				continue
+			}
+			return nil, nil, fmt.Errorf("failed to list %s resources: %w", list.kind, err)
+		}
+		objects := make([]interface{}, 0, len(items.Items))
+		for _, item := range items.Items {
+			objects = append(objects, item.Object)
+		}
+		if err := remarshal(objects, list.out); err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %s resources: %w", list.kind, err)
+		}
+	}
+
+	for _, se := range config.serviceEntries {
+		inv.serviceHosts = append(inv.serviceHosts, se.Spec.Hosts...)
+	}
+	for _, gw := range config.gateways {
+		inv.gateways[gw.Metadata.Namespace+"/"+gw.Metadata.Name] = true
+	}
+	for _, dr := range config.destinationRules {
+		host := inv.fqdn(dr.Spec.Host, dr.Metadata.Namespace)
+		if inv.subsets[host] == nil {
+			inv.subsets[host] = make(map[string]bool)
+		}
+		for _, subset := range dr.Spec.Subsets {
+			inv.subsets[host][subset.Name] = true
+		}
+	}
+	return inv, config, nil
+}
+
+// auditIstioConfig checks the references and selectors of the Istio resources in a namespace, or in every
+// namespace when it is empty, and returns the findings, broken first, with the number of resources scanned
+func (m *Manager) auditIstioConfig(ctx context.Context, inv *istioAuditInventory, config *istioConfig, namespace string) ([]IstioResourceFinding, map[string]int) {
+	findings := []IstioResourceFinding{}
+	scanned := make(map[string]int)
+	inScope := func(meta istioMeta) bool {
+		return namespace == "" || meta.Namespace == namespace
+	}
+	add := func(kind string, meta istioMeta, severity, reference, problem string) {
+		findings = append(findings, IstioResourceFinding{
+			Kind:      kind,
+			Namespace: meta.Namespace,
+			Name:      meta.Name,
+			Severity:  severity,
+			Reference: reference,
+			Problem:   problem,
+		})
+	}
+	// VirtualServices: attached gateways, destination hosts and subsets
+	referencedGateways := make(map[string]bool)
+	for _, vs := range config.virtualServices {
+		attached := append([]string{}, vs.Spec.Gateways...)
+		for _, route := range vs.Spec.HTTP {
+			for _, match := range route.Match {
+				attached = append(attached, match.Gateways...)
+			}
+		}
+		for _, gateway := range attached {
+			ref := gatewayRef(gateway, vs.Metadata.Namespace)
+			referencedGateways[ref] = true
+		}
+		if !inScope(vs.Metadata) {
+			continue
+		}
+		scanned["VirtualService"]++
+
+		seen := make(map[string]bool)
+		for _, gateway := range attached {
+			if gateway == "mesh" || seen["gw:"+gateway] {
+				continue
+			}
+			seen["gw:"+gateway] = true
+			if ref := gatewayRef(gateway, vs.Metadata.Namespace); !inv.gateways[ref] {
+				add("VirtualService", vs.Metadata, "broken", "Gateway "+ref, fmt.Sprintf("Attached to Gateway %s, which does not exist; the routes never apply there", ref))
+			}
+		}
+
+		var destinations []istioDestination
+		for _, route := range vs.Spec.HTTP {
+			destinations = append(destinations, route.Route...)
+			if route.Mirror != nil {
+				var mirror istioDestination
+				mirror.Destination.Host, mirror.Destination.Subset = route.Mirror.Host, route.Mirror.Subset
+				destinations = append(destinations, mirror)
+			}
+		}
+		for _, route := range vs.Spec.TCP {
+			destinations = append(destinations, route.Route...)
+		}
+		for _, route := range vs.Spec.TLS {
+			destinations = append(destinations, route.Route...)
+		}
+		for _, dest := range destinations {
+			host := inv.fqdn(dest.Destination.Host, vs.Metadata.Namespace)
+			key := host + "|" + dest.Destination.Subset
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if problem := inv.hostProblem(host); problem != "" {
+				add("VirtualService", vs.Metadata, "broken", "host "+dest.Destination.Host, "Routes to "+problem)
+				continue
+			}
+			if subset := dest.Destination.Subset; subset != "" && !inv.subsets[host][subset] {
+				add("VirtualService", vs.Metadata, "broken", fmt.Sprintf("subset %s of %s", subset, host),
+					fmt.Sprintf("Routes to subset %s of %s, which no DestinationRule defines; requests fail with 503 (NR)", subset, host))
+			}
+		}
+	}
+
+	// DestinationRules: host and subsets selecting pods
+	for _, dr := range config.destinationRules {
+		if !inScope(dr.Metadata) {
+			continue
+		}
+		scanned["DestinationRule"]++
+		host := inv.fqdn(dr.Spec.Host, dr.Metadata.Namespace)
+		if problem := inv.hostProblem(host); problem != "" {
+			add("DestinationRule", dr.Metadata, "unused", "host "+dr.Spec.Host, "Applies to "+problem)
+			continue
+		}
+		svc := inv.services[host]
+		if svc == nil || len(svc.Spec.Selector) == 0 {
+			continue
+		}
+		for _, subset := range dr.Spec.Subsets {
+			selector := make(map[string]string)
+			for key, value := range svc.Spec.Selector {
+				selector[key] = value
+			}
+			for key, value := range subset.Labels {
+				selector[key] = value
+			}
+			if inv.countPods(svc.Namespace, selector) == 0 {
+				add("DestinationRule", dr.Metadata, "unused", "subset "+subset.Name,
+					fmt.Sprintf("Subset %s (%s) matches no pods of service %s/%s", subset.Name, labels.SelectorFromSet(subset.Labels).String(), svc.Namespace, svc.Name))
+			}
+		}
+	}
+
+	// Gateways: selected gateway pods, TLS secrets and attached routes
+	for _, gw := range config.gateways {
+		if !inScope(gw.Metadata) {
+			continue
+		}
+		scanned["Gateway"]++
+		ref := gw.Metadata.Namespace + "/" + gw.Metadata.Name
+		var gatewayPods []corev1.Pod
+		for _, pod := range inv.pods {
+			if len(gw.Spec.Selector) > 0 && labels.SelectorFromSet(gw.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+				gatewayPods = append(gatewayPods, pod)
+			}
+		}
+		if len(gatewayPods) == 0 {
+			add("Gateway", gw.Metadata, "unused", "selector "+labels.SelectorFromSet(gw.Spec.Selector).String(), "Selects no gateway pods in any namespace")
+		}
+		if !referencedGateways[ref] {
+			add("Gateway", gw.Metadata, "unused", "", "No VirtualService is attached to it; it opens listeners with no routes")
+		}
+		checked := make(map[string]bool)
+		for _, server := range gw.Spec.Servers {
+			if server.TLS == nil || server.TLS.CredentialName == "" || len(gatewayPods) == 0 {
+				continue
+			}
+			// Gateways read credentials from the namespace of the gateway pods
+			secretNamespace := gatewayPods[0].Namespace
+			secretRef := secretNamespace + "/" + server.TLS.CredentialName
+			if checked[secretRef] {
+				continue
+			}
+			checked[secretRef] = true
+			if _, err := m.k8sClient.Kubernetes.CoreV1().Secrets(secretNamespace).Get(ctx, server.TLS.CredentialName, metav1.GetOptions{}); errors.IsNotFound(err) {
+				add("Gateway", gw.Metadata, "broken", "Secret "+secretRef, fmt.Sprintf("TLS credential %s does not exist; the server has no certificate to serve", secretRef))
+			}
+		}
+	}
+
+	// Policies: workload selectors and target references
+	policies := []struct {
+		kind  string
+		items []auditWorkloadPolicy
+	}{
+		{"AuthorizationPolicy", config.authorizationPolicies},
+		{"PeerAuthentication", config.peerAuthentications},
+	}
+	for _, group := range policies {
+		for _, policy := range group.items {
+			if !inScope(policy.Metadata) {
+				continue
+			}
+			scanned[group.kind]++
+			if !inv.namespaces[policy.Metadata.Namespace] {
+				continue
+			}
+			if policy.Spec.Selector != nil && len(policy.Spec.Selector.MatchLabels) > 0 &&
+				inv.countPods(policy.Metadata.Namespace, policy.Spec.Selector.MatchLabels) == 0 {
+				add(group.kind, policy.Metadata, "unused", "selector "+labels.SelectorFromSet(policy.Spec.Selector.MatchLabels).String(),
+					fmt.Sprintf("Selects no pods in namespace %s", policy.Metadata.Namespace))
+			}
+			refs := policy.Spec.TargetRefs
+			if policy.Spec.TargetRef != nil {
+				refs = append(refs, *policy.Spec.TargetRef)
+			}
+			for _, ref := range refs {
+				if ref.Kind != "Service" {
+					continue
+				}
+				host := fmt.Sprintf("%s.%s.svc.%s", ref.Name, policy.Metadata.Namespace, inv.domain)
+				if inv.services[host] == nil {
+					add(group.kind, policy.Metadata, "broken", "Service "+ref.Name, fmt.Sprintf("Targets Service %s/%s, which does not exist", policy.Metadata.Namespace, ref.Name))
+				}
+			}
+		}
+	}
+
+	sort.Slice(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Severity != b.Severity {
+			return a.Severity == "broken"
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return a.Name < b.Name
+	})
+	return findings, scanned
+}
+
+// gatewayRef resolves a VirtualService gateway reference to <namespace>/<name>
+func gatewayRef(gateway, namespace string) string {
+	if strings.Contains(gateway, "/") {
+		return gateway
+	}
+	return namespace + "/" + gateway
+}
+
+// fqdn expands a short host name the way Istio does, relative to the resource's namespace
+func (inv *istioAuditInventory) fqdn(host, namespace string) string {
+	if host == "" || strings.Contains(host, ".") || strings.Contains(host, "*") {
+		return host
+	}
+	return fmt.Sprintf("%s.%s.svc.%s", host, namespace, inv.domain)
+}
+
+// hostProblem explains why a host resolves to nothing, or returns "" when it is known
+func (inv *istioAuditInventory) hostProblem(host string) string {
+	if host == "" || strings.Contains(host, "*") || inv.services[host] != nil {
+		return ""
+	}
+	for _, entry := range inv.serviceHosts {
+		if entry == host || (strings.HasPrefix(entry, "*") && strings.HasSuffix(host, strings.TrimPrefix(entry, "*"))) {
+			return ""
+		}
+	}
+	suffix := ".svc." + inv.domain
+	if strings.HasSuffix(host, suffix) {
+		parts := strings.Split(strings.TrimSuffix(host, suffix), ".")
+		if len(parts) == 2 && !inv.namespaces[parts[1]] {
+			return fmt.Sprintf("%s, whose namespace %s does not exist", host, parts[1])
+		}
+		return fmt.Sprintf("%s, which is not an existing Service", host)
+	}
+	return fmt.Sprintf("%s, which no Service or ServiceEntry defines", host)
+}
+
+// countPods counts the pods in a namespace whose labels include the given ones
+func (inv *istioAuditInventory) countPods(namespace string, set map[string]string) int {
+	selector := labels.SelectorFromSet(set)
+	count := 0
+	for _, pod := range inv.pods {
+		if pod.Namespace == namespace && selector.Matches(labels.Set(pod.Labels)) {
+			count++
+		}
+	}
+	return count
+}
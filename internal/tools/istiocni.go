@@ -0,0 +1,718 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// istioCNIContainer is the container of the istio-cni-node DaemonSet with the host CNI directory mounted
+	istioCNIContainer = "install-cni"
+	// defaultCNIConfDir is where the istio-cni-node DaemonSet mounts the host CNI configuration directory
+	defaultCNIConfDir = "/host/etc/cni/net.d"
+	// newNodeWindow is how recent a node must be to be reported as newly added
+	newNodeWindow = time.Hour
+)
+
+// cniPluginsAfterIstio are chained plugins that rewrite pod networking and should not run after istio-cni
+var cniPluginsAfterIstio = map[string]string{
+	"portmap":   "hostPort DNAT rules are installed after the Istio redirection and can bypass the sidecar",
+	"bandwidth": "traffic shaping is attached after the Istio redirection and may not apply to redirected traffic",
+	"tuning":    "sysctl changes after istio-cni can undo settings the redirection depends on",
+	"firewall":  "firewall rules added after istio-cni can drop traffic redirected to the proxy",
+	"sbr":       "source-based routing added after istio-cni can route redirected traffic around the proxy",
+}
+
+// CNIConfigFile is a CNI configuration file found on a node
+type CNIConfigFile struct {
+	Name    string   `json:"name"`
+	Plugins []string `json:"plugins,omitempty"`
+	Primary bool     `json:"primary"`
+}
+
+// NodeCNIStatus reports the Istio CNI state of a single node
+type NodeCNIStatus struct {
+	Node          string          `json:"node"`
+	New           bool            `json:"new,omitempty"`
+	Eligible      bool            `json:"eligible"`
+	CNIPod        string          `json:"cni_pod,omitempty"`
+	CNIPodReady   bool            `json:"cni_pod_ready"`
+	ConfigFiles   []CNIConfigFile `json:"config_files,omitempty"`
+	PrimaryConfig string          `json:"primary_config,omitempty"`
+	Plugins       []string        `json:"plugins,omitempty"`
+	IstioPosition int             `json:"istio_cni_position,omitempty"` // 1-based position of istio-cni in the chain
+	Issues        []string        `json:"issues,omitempty"`
+	Warnings      []string        `json:"warnings,omitempty"`
+}
+
+// CNIChainingReport is the result of checking istio-cni chaining across nodes
+type CNIChainingReport struct {
+	DaemonSet    string          `json:"daemonset"`
+	Desired      int32           `json:"desired"`
+	Ready        int32           `json:"ready"`
+	Updated      int32           `json:"updated"`
+	MissingNodes []string        `json:"missing_nodes,omitempty"`
+	Nodes        []NodeCNIStatus `json:"nodes"`
+	Healthy      bool            `json:"healthy"`
+	Issues       []string        `json:"issues,omitempty"`
+	Timestamp    time.Time       `json:"timestamp"`
+}
+
+// checkCNIChainingParams are the arguments of check_cni_chaining
+type checkCNIChainingParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: istio-system, falls back to searching all namespaces
+	Node      string `json:"node,omitempty"`      // only check this node
+	ConfDir   string `json:"conf_dir,omitempty"`  // default: /host/etc/cni/net.d
+}
+
+// CheckCNIChaining verifies istio-cni is chained into the active CNI configuration on every node
+func (m *Manager) CheckCNIChaining(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkCNIChainingParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+	if params.ConfDir == "" {
+		params.ConfDir = defaultCNIConfDir
+	}
+
+	ds, err := m.findIstioCNIDaemonSet(ctx, params.Namespace)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to find the istio-cni DaemonSet: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list nodes: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	cniPods, err := m.istioCNIPodsByNode(ctx, ds)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istio-cni pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := &CNIChainingReport{
+		DaemonSet: fmt.Sprintf("%s/%s", ds.Namespace, ds.Name),
+		Desired:   ds.Status.DesiredNumberScheduled,
+		Ready:     ds.Status.NumberReady,
+		Updated:   ds.Status.UpdatedNumberScheduled,
+		Timestamp: time.Now(),
+	}
+	if ds.Status.UpdatedNumberScheduled < ds.Status.DesiredNumberScheduled {
+		report.Issues = append(report.Issues, fmt.Sprintf("DaemonSet rollout incomplete: %d of %d pods updated", ds.Status.UpdatedNumberScheduled, ds.Status.DesiredNumberScheduled))
+	}
+	if ds.Spec.Template.Spec.Affinity != nil && ds.Spec.Template.Spec.Affinity.NodeAffinity != nil {
+		report.Issues = append(report.Issues, "DaemonSet has node affinity; nodes it excludes run pods without Istio traffic redirection")
+	}
+
+	for i := range nodes.Items {
+		node := &nodes.Items[i]
+		if params.Node != "" && node.Name != params.Node {
+			continue
+		}
+
+		status := NodeCNIStatus{
+			Node:     node.Name,
+			New:      time.Since(node.CreationTimestamp.Time) < newNodeWindow,
+			Eligible: daemonSetTargetsNode(ds, node),
+		}
+
+		pod := cniPods[node.Name]
+		switch {
+		case pod == nil && status.Eligible:
+			status.Issues = append(status.Issues, "No istio-cni pod is scheduled on this node")
+			if status.New {
+				status.Issues[len(status.Issues)-1] += " (node was added recently; pods started before the agent is ready bypass the mesh)"
+			}
+			report.MissingNodes = append(report.MissingNodes, node.Name)
+		case pod == nil:
+			status.Warnings = append(status.Warnings, "Node is excluded by the DaemonSet's taints or node selector; meshed pods scheduled here will not be redirected")
+		default:
+			status.CNIPod = pod.Name
+			status.CNIPodReady = isPodReady(pod)
+			if !status.CNIPodReady {
+				status.Issues = append(status.Issues, fmt.Sprintf("istio-cni pod %s is not ready", pod.Name))
+			}
+		}
+
+		if pod != nil && pod.Status.Phase == corev1.PodRunning {
+			m.inspectNodeCNIConfig(ctx, pod, params.ConfDir, &status)
+		}
+
+		report.Nodes = append(report.Nodes, status)
+	}
+
+	if params.Node != "" && len(report.Nodes) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Node %s not found", params.Node),
+				},
+			},
+		}, nil
+	}
+
+	for _, status := range report.Nodes {
+		for _, issue := range status.Issues {
+			report.Issues = append(report.Issues, fmt.Sprintf("%s: %s", status.Node, issue))
+		}
+	}
+	report.Healthy = len(report.Issues) == 0
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// findIstioCNIDaemonSet locates the istio-cni node agent, searching all namespaces when it is not in the given one
+func (m *Manager) findIstioCNIDaemonSet(ctx context.Context, namespace string) (*appsv1.DaemonSet, error) {
+	ds, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets(namespace).Get(ctx, "istio-cni-node", metav1.GetOptions{})
+	if err == nil {
+		return ds, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	list, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets("").List(ctx, metav1.ListOptions{LabelSelector: "k8s-app=istio-cni-node"})
+	if err != nil {
+		return nil, err
+	}
+	if len(list.Items) == 0 {
+		return nil, fmt.Errorf("istio-cni-node not found; Istio CNI is not installed")
+	}
+	return &list.Items[0], nil
+}
+
+// istioCNIPodsByNode maps node names to the istio-cni pod running there
+func (m *Manager) istioCNIPodsByNode(ctx context.Context, ds *appsv1.DaemonSet) (map[string]*corev1.Pod, error) {
+	selector, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(ds.Namespace).List(ctx, metav1.ListOptions{LabelSelector: selector.String()})
+	if err != nil {
+		return nil, err
+	}
+	byNode := make(map[string]*corev1.Pod)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.NodeName == "" || pod.DeletionTimestamp != nil {
+			continue
+		}
+		// Prefer a ready pod when a rollout leaves two on the same node
+		if existing, ok := byNode[pod.Spec.NodeName]; ok && isPodReady(existing) {
+			continue
+		}
+		byNode[pod.Spec.NodeName] = pod
+	}
+	return byNode, nil
+}
+
+// daemonSetTargetsNode reports whether a DaemonSet's node selector and tolerations allow it on a node
+func daemonSetTargetsNode(ds *appsv1.DaemonSet, node *corev1.Node) bool {
+	if len(ds.Spec.Template.Spec.NodeSelector) > 0 &&
+		!labels.SelectorFromSet(ds.Spec.Template.Spec.NodeSelector).Matches(labels.Set(node.Labels)) {
+		return false
+	}
+	for i := range node.Spec.Taints {
+		taint := &node.Spec.Taints[i]
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule {
+			continue
+		}
+		tolerated := false
+		for j := range ds.Spec.Template.Spec.Tolerations {
+			if ds.Spec.Template.Spec.Tolerations[j].ToleratesTaint(taint) {
+				tolerated = true
+				break
+			}
+		}
+		if !tolerated {
+			return false
+		}
+	}
+	return true
+}
+
+// isPodReady reports whether a pod has the Ready condition
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// cniConfigFileHeader separates files in the output of the config dump script
+var cniConfigFileHeader = regexp.MustCompile(`(?m)^==> (.+)$`)
+
+// inspectNodeCNIConfig reads the CNI configuration directory through the istio-cni pod and checks the chain
+func (m *Manager) inspectNodeCNIConfig(ctx context.Context, pod *corev1.Pod, confDir string, status *NodeCNIStatus) {
+	script := fmt.Sprintf(`cd %q && for f in $(ls -1 | sort); do echo "==> $f"; case "$f" in *.conf|*.conflist|*.json) cat "$f";; esac; echo; done`, confDir)
+	output, err := m.execCommandInPod(ctx, pod.Namespace, pod.Name, istioCNIContainer, []string{"sh", "-c", script})
+	if err != nil {
+		status.Warnings = append(status.Warnings, fmt.Sprintf("Unable to read %s through %s: %v", confDir, pod.Name, err))
+		return
+	}
+
+	contents := make(map[string]string)
+	var names []string
+	headers := cniConfigFileHeader.FindAllStringSubmatchIndex(output, -1)
+	for i, header := range headers {
+		name := output[header[2]:header[3]]
+		end := len(output)
+		if i+1 < len(headers) {
+			end = headers[i+1][0]
+		}
+		names = append(names, name)
+		contents[name] = strings.TrimSpace(output[header[1]:end])
+	}
+
+	// The container runtime uses the first configuration file in lexical order
+	var configs []string
+	for _, name := range names {
+		if strings.HasSuffix(name, ".conf") || strings.HasSuffix(name, ".conflist") || strings.HasSuffix(name, ".json") {
+			configs = append(configs, name)
+		}
+		if strings.HasSuffix(name, ".cilium_bak") {
+			status.Issues = append(status.Issues, fmt.Sprintf("%s was renamed by Cilium; set cni.exclusive=false in Cilium so istio-cni can chain into its configuration", name))
+		}
+	}
+	sort.Strings(configs)
+	if len(configs) == 0 {
+		status.Issues = append(status.Issues, fmt.Sprintf("No CNI configuration found in %s", confDir))
+		return
+	}
+	status.PrimaryConfig = configs[0]
+
+	istioFiles := 0
+	for i, name := range configs {
+		file := CNIConfigFile{Name: name, Primary: i == 0}
+		plugins, isList, err := parseCNIConfig(contents[name])
+		if err != nil {
+			status.Warnings = append(status.Warnings, fmt.Sprintf("Failed to parse %s: %v", name, err))
+			status.ConfigFiles = append(status.ConfigFiles, file)
+			continue
+		}
+		file.Plugins = plugins
+		status.ConfigFiles = append(status.ConfigFiles, file)
+
+		if containsString(plugins, "istio-cni") {
+			istioFiles++
+			if i > 0 {
+				status.Issues = append(status.Issues, fmt.Sprintf("istio-cni is chained into %s, but the runtime uses %s", name, configs[0]))
+			}
+		}
+		if i == 0 {
+			status.Plugins = plugins
+			if !isList {
+				status.Issues = append(status.Issues, fmt.Sprintf("%s is a single-plugin .conf file; istio-cni can only chain into a .conflist", name))
+			}
+			checkIstioCNIChain(plugins, status)
+		}
+	}
+	if istioFiles > 1 {
+		status.Warnings = append(status.Warnings, fmt.Sprintf("istio-cni appears in %d configuration files", istioFiles))
+	}
+}
+
+// parseCNIConfig returns the plugin types of a .conf or .conflist file
+func parseCNIConfig(content string) ([]string, bool, error) {
+	var config struct {
+		Type    string `json:"type"`
+		Plugins []struct {
+			Type string `json:"type"`
+		} `json:"plugins"`
+	}
+	if err := json.Unmarshal([]byte(content), &config); err != nil {
+		return nil, false, err
+	}
+	if config.Plugins == nil {
+		return []string{config.Type}, false, nil
+	}
+	plugins := make([]string, 0, len(config.Plugins))
+	for _, plugin := range config.Plugins {
+		plugins = append(plugins, plugin.Type)
+	}
+	return plugins, true, nil
+}
+
+// checkIstioCNIChain validates the position of istio-cni in the primary plugin chain
+func checkIstioCNIChain(plugins []string, status *NodeCNIStatus) {
+	positions := []int{}
+	for i, plugin := range plugins {
+		if plugin == "istio-cni" {
+			positions = append(positions, i)
+		}
+	}
+
+	switch {
+	case len(positions) == 0:
+		status.Issues = append(status.Issues, fmt.Sprintf("istio-cni is not chained into %s; the primary CNI may have rewritten its configuration", status.PrimaryConfig))
+		if len(plugins) > 0 && plugins[0] == "multus" {
+			status.Warnings = append(status.Warnings, "Multus is the primary CNI; istio-cni must be added through a NetworkAttachmentDefinition")
+		}
+		return
+	case len(positions) > 1:
+		status.Issues = append(status.Issues, fmt.Sprintf("istio-cni is chained %d times into %s", len(positions), status.PrimaryConfig))
+	}
+
+	position := positions[0]
+	status.IstioPosition = position + 1
+	if position == 0 {
+		status.Issues = append(status.Issues, "istio-cni is the first plugin; it must be chained after the plugin that sets up the pod interface")
+	}
+	for _, plugin := range plugins[position+1:] {
+		if plugin == "istio-cni" {
+			continue
+		}
+		if reason, ok := cniPluginsAfterIstio[plugin]; ok {
+			status.Issues = append(status.Issues, fmt.Sprintf("%s runs after istio-cni: %s", plugin, reason))
+		} else {
+			status.Warnings = append(status.Warnings, fmt.Sprintf("%s runs after istio-cni; istio-cni is expected to be the last plugin", plugin))
+		}
+	}
+}
+
+// CNIRacePod describes a meshed pod that may have started without Istio traffic redirection
+type CNIRacePod struct {
+	Name          string     `json:"name"`
+	Namespace     string     `json:"namespace"`
+	Node          string     `json:"node"`
+	Mode          string     `json:"mode"`   // sidecar or ambient
+	Status        string     `json:"status"` // affected or suspect
+	StartTime     *time.Time `json:"start_time,omitempty"`
+	AgentReadyAt  *time.Time `json:"agent_ready_at,omitempty"`
+	Evidence      []string   `json:"evidence"`
+	Owner         string     `json:"owner,omitempty"`
+	RestartAction string     `json:"restart_action"`
+}
+
+// CNIRaceReport is the result of looking for pods that raced the Istio CNI agent
+type CNIRaceReport struct {
+	DaemonSet      string       `json:"daemonset"`
+	PodsChecked    int          `json:"pods_checked"`
+	Affected       []CNIRacePod `json:"affected,omitempty"`
+	Suspect        []CNIRacePod `json:"suspect,omitempty"`
+	Issues         []string     `json:"issues,omitempty"`
+	Recommendation string       `json:"recommendation"`
+	Timestamp      time.Time    `json:"timestamp"`
+}
+
+// detectCNIRaceParams are the arguments of detect_cni_race
+type detectCNIRaceParams struct {
+	Namespace     string `json:"namespace,omitempty"`      // default: all namespaces
+	Node          string `json:"node,omitempty"`           // only check pods on this node
+	CNINamespace  string `json:"cni_namespace,omitempty"`  // default: istio-system
+	CheckIptables bool   `json:"check_iptables,omitempty"` // inspect iptables of suspect pods with a debug container
+	MaxIptables   int    `json:"max_iptables,omitempty"`   // default: 5
+}
+
+// DetectCNIRace finds meshed pods that started before the Istio CNI agent was ready on their node
+func (m *Manager) DetectCNIRace(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params detectCNIRaceParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.CNINamespace == "" {
+		params.CNINamespace = "istio-system"
+	}
+	if params.MaxIptables == 0 {
+		params.MaxIptables = 5
+	}
+
+	ds, err := m.findIstioCNIDaemonSet(ctx, params.CNINamespace)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to find the istio-cni DaemonSet: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	cniPods, err := m.istioCNIPodsByNode(ctx, ds)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istio-cni pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	listOptions := metav1.ListOptions{}
+	if params.Node != "" {
+		listOptions.FieldSelector = "spec.nodeName=" + params.Node
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, listOptions)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	namespaceModes := make(map[string]string)
+	if namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, ns := range namespaces.Items {
+			namespaceModes[ns.Name] = ns.Labels["istio.io/dataplane-mode"]
+		}
+	}
+
+	report := &CNIRaceReport{
+		DaemonSet: fmt.Sprintf("%s/%s", ds.Namespace, ds.Name),
+		Timestamp: time.Now(),
+	}
+	iptablesChecks := 0
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Spec.HostNetwork || pod.Spec.NodeName == "" || pod.Status.Phase == corev1.PodSucceeded || pod.Status.Phase == corev1.PodFailed {
+			continue
+		}
+		mode := cniRedirectionMode(pod, namespaceModes[pod.Namespace])
+		if mode == "" {
+			continue
+		}
+		report.PodsChecked++
+
+		race := CNIRacePod{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+			Node:      pod.Spec.NodeName,
+			Mode:      mode,
+		}
+		if pod.Status.StartTime != nil {
+			started := pod.Status.StartTime.Time
+			race.StartTime = &started
+		}
+
+		strong := false
+		agent := cniPods[pod.Spec.NodeName]
+		if agent == nil {
+			race.Evidence = append(race.Evidence, "No istio-cni agent runs on this node")
+			strong = true
+		} else if readyAt := podReadySince(agent); readyAt == nil {
+			race.Evidence = append(race.Evidence, fmt.Sprintf("istio-cni agent %s is not ready", agent.Name))
+		} else {
+			race.AgentReadyAt = readyAt
+			if race.StartTime != nil && race.StartTime.Before(*readyAt) {
+				race.Evidence = append(race.Evidence, fmt.Sprintf("Pod started %s before the istio-cni agent became ready", readyAt.Sub(*race.StartTime).Round(time.Second)))
+			}
+		}
+
+		if pod.Labels["cni.istio.io/uninitialized"] == "true" {
+			race.Evidence = append(race.Evidence, "Pod is labeled cni.istio.io/uninitialized by the istio-cni repair controller")
+			strong = true
+		}
+		if mode == "sidecar" {
+			for _, status := range pod.Status.InitContainerStatuses {
+				if status.Name != "istio-validation" {
+					continue
+				}
+				if status.RestartCount > 0 {
+					race.Evidence = append(race.Evidence, fmt.Sprintf("istio-validation init container restarted %d times (traffic redirection was not in place)", status.RestartCount))
+					strong = true
+				}
+				if terminated := status.LastTerminationState.Terminated; terminated != nil && terminated.ExitCode != 0 {
+					race.Evidence = append(race.Evidence, fmt.Sprintf("istio-validation last exited with code %d", terminated.ExitCode))
+					strong = true
+				}
+			}
+		} else if pod.Annotations["ambient.istio.io/redirection"] != "enabled" {
+			race.Evidence = append(race.Evidence, "Ambient pod lacks the ambient.istio.io/redirection=enabled annotation set by the CNI agent")
+			strong = true
+		}
+
+		if len(race.Evidence) == 0 {
+			continue
+		}
+
+		// The iptables state confirms or clears a suspicion but requires a debug container per pod
+		if params.CheckIptables && !strong && iptablesChecks < params.MaxIptables {
+			iptablesChecks++
+			var rules string
+			image, securityContext, err := m.prepareIptablesDebug(ctx, pod)
+			if err == nil {
+				rules, err = m.getIptablesWithDebug(ctx, pod.Namespace, pod.Name, "nat", []string{"-t", "nat", "-S"}, image, securityContext)
+			}
+			switch {
+			case err != nil:
+				race.Evidence = append(race.Evidence, fmt.Sprintf("iptables check failed: %v", err))
+			case !strings.Contains(rules, "ISTIO_"):
+				race.Evidence = append(race.Evidence, "No ISTIO_* chains in the pod's nat table")
+				strong = true
+			default:
+				// Redirection is in place, so the pod survived the race
+				continue
+			}
+		}
+
+		race.Owner = m.podOwner(ctx, pod)
+		if strings.HasPrefix(race.Owner, "Deployment/") || strings.HasPrefix(race.Owner, "StatefulSet/") || strings.HasPrefix(race.Owner, "DaemonSet/") {
+			race.RestartAction = fmt.Sprintf("kubectl rollout restart %s -n %s", strings.ToLower(race.Owner), pod.Namespace)
+		} else {
+			race.RestartAction = fmt.Sprintf("kubectl delete pod %s -n %s", pod.Name, pod.Namespace)
+		}
+
+		if strong {
+			race.Status = "affected"
+			report.Affected = append(report.Affected, race)
+			report.Issues = append(report.Issues, fmt.Sprintf("%s/%s has no traffic redirection: %s", pod.Namespace, pod.Name, race.Evidence[len(race.Evidence)-1]))
+		} else {
+			race.Status = "suspect"
+			report.Suspect = append(report.Suspect, race)
+		}
+	}
+
+	switch {
+	case len(report.Affected) > 0:
+		report.Recommendation = fmt.Sprintf("Restart the %d affected pods with the listed restart actions; enable the istio-cni repair controller (cni.repair.enabled) to have this handled automatically", len(report.Affected))
+	case len(report.Suspect) > 0:
+		report.Recommendation = "Suspect pods started before the CNI agent was ready but show no definite failure; re-run with check_iptables=true to confirm, or restart them to be safe"
+	default:
+		report.Recommendation = "No meshed pods raced the Istio CNI agent"
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// cniRedirectionMode returns how a pod's traffic is redirected by the CNI agent, or "" if it doesn't depend on it
+func cniRedirectionMode(pod *corev1.Pod, namespaceMode string) string {
+	for _, container := range pod.Spec.InitContainers {
+		switch container.Name {
+		case "istio-validation":
+			return "sidecar"
+		case "istio-init":
+			// Redirection is set up by the pod itself
+			return ""
+		}
+	}
+	if podHasSidecar(pod) {
+		return ""
+	}
+	mode := pod.Labels["istio.io/dataplane-mode"]
+	if mode == "" {
+		mode = namespaceMode
+	}
+	if mode == "ambient" {
+		return "ambient"
+	}
+	return ""
+}
+
+// podReadySince returns when a pod last became ready, or nil if it is not ready
+func podReadySince(pod *corev1.Pod) *time.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+			readyAt := condition.LastTransitionTime.Time
+			return &readyAt
+		}
+	}
+	return nil
+}
+
+// podOwner returns the top-level controller of a pod as Kind/name, resolving ReplicaSets to Deployments
+func (m *Manager) podOwner(ctx context.Context, pod *corev1.Pod) string {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil {
+		return ""
+	}
+	if owner.Kind == "ReplicaSet" {
+		rs, err := m.k8sClient.Kubernetes.AppsV1().ReplicaSets(pod.Namespace).Get(ctx, owner.Name, metav1.GetOptions{})
+		if err == nil {
+			if rsOwner := metav1.GetControllerOf(rs); rsOwner != nil {
+				return fmt.Sprintf("%s/%s", rsOwner.Kind, rsOwner.Name)
+			}
+		}
+	}
+	return fmt.Sprintf("%s/%s", owner.Kind, owner.Name)
+}
@@ -0,0 +1,253 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// istiodDebugEndpoints lists the istiod debug endpoints istiod_debug may read
+var istiodDebugEndpoints = []string{"syncz", "push_status", "adsz"}
+
+// IstiodDebugResponse is the filtered response of one istiod
+type IstiodDebugResponse struct {
+	Istiod  string          `json:"istiod"`
+	Total   int             `json:"total"`             // proxies the endpoint reported before filtering
+	Matched int             `json:"matched"`           // proxies left after filtering
+	Omitted int             `json:"omitted,omitempty"` // matches left out because of the limit
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// IstiodDebugResult is the result of istiod_debug
+type IstiodDebugResult struct {
+	Endpoint  string                `json:"endpoint"`
+	Proxy     string                `json:"proxy,omitempty"`
+	Namespace string                `json:"namespace,omitempty"`
+	Responses []IstiodDebugResponse `json:"responses"`
+	Timestamp time.Time             `json:"timestamp"`
+}
+
+// istiodDebugParams are the arguments of istiod_debug
+type istiodDebugParams struct {
+	Endpoint       string `json:"endpoint"`                  // syncz, push_status or adsz
+	Proxy          string `json:"proxy,omitempty"`           // only proxies whose ID (pod.namespace) contains this
+	Namespace      string `json:"namespace,omitempty"`       // only proxies in this namespace
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+	Revision       string `json:"revision,omitempty"`        // default: every revision
+	Limit          int    `json:"limit,omitempty"`           // default: 100 proxies per istiod
+}
+
+// IstiodDebug reads an istiod debug endpoint from every istiod pod and keeps the entries of the proxies
+// matching the filter; each istiod only knows the proxies connected to it
+func (m *Manager) IstiodDebug(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params istiodDebugParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Limit == 0 {
+		params.Limit = 100
+	}
+
+	params.Endpoint = strings.TrimPrefix(strings.TrimPrefix(params.Endpoint, "/"), "debug/")
+	if !containsString(istiodDebugEndpoints, params.Endpoint) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid endpoint %q: must be one of %s", params.Endpoint, strings.Join(istiodDebugEndpoints, ", ")),
+				},
+			},
+		}, nil
+	}
+
+	selector := "app=istiod"
+	if params.Revision != "" {
+		selector += ",istio.io/rev=" + params.Revision
+	}
+	istiods, err := m.runningPods(ctx, params.IstioNamespace, selector)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istiod pods: %v", err),
+				},
+			},
+		}, nil
+	}
+	if len(istiods) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No running istiod pods matching %s in namespace %s", selector, params.IstioNamespace),
+				},
+			},
+		}, nil
+	}
+
+	result := &IstiodDebugResult{
+		Endpoint:  "/debug/" + params.Endpoint,
+		Proxy:     params.Proxy,
+		Namespace: params.Namespace,
+		Responses: []IstiodDebugResponse{},
+		Timestamp: time.Now(),
+	}
+	matches := func(id string) bool {
+		return istiodProxyMatches(id, params.Proxy, params.Namespace)
+	}
+	for i := range istiods {
+		istiod := &istiods[i]
+		response := IstiodDebugResponse{Istiod: istiod.Name}
+		body, err := m.portForwardGet(ctx, istiod.Namespace, istiod.Name, istiodDebugPort, result.Endpoint)
+		if err == nil {
+			switch params.Endpoint {
+			case "syncz":
+				err = filterSyncz(body, matches, params.Limit, &response)
+			case "adsz":
+				err = filterAdsz(body, matches, params.Limit, &response)
+			case "push_status":
+				err = filterPushStatus(body, matches, &response)
+			}
+		}
+		if err != nil {
+			response.Error = err.Error()
+		}
+		result.Responses = append(result.Responses, response)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// filterSyncz keeps the /debug/syncz entries of the matching proxies
+func filterSyncz(body []byte, matches func(string) bool, limit int, response *IstiodDebugResponse) error {
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return fmt.Errorf("failed to parse sync status: %w", err)
+	}
+	response.Total = len(entries)
+	kept := []map[string]interface{}{}
+	for _, entry := range entries {
+		proxy, _ := entry["proxy"].(string)
+		if matches(proxy) {
+			kept = append(kept, entry)
+		}
+	}
+	response.Matched = len(kept)
+	if len(kept) > limit {
+		response.Omitted = len(kept) - limit
+		kept = kept[:limit]
+	}
+	response.Data, _ = json.Marshal(kept)
+	return nil
+}
+
+// filterAdsz keeps the /debug/adsz clients of the matching proxies, dropping the totals that no longer apply
+func filterAdsz(body []byte, matches func(string) bool, limit int, response *IstiodDebugResponse) error {
+	var adsz struct {
+		Clients []map[string]interface{} `json:"clients"`
+	}
+	if err := json.Unmarshal(body, &adsz); err != nil {
+		return fmt.Errorf("failed to parse ADS clients: %w", err)
+	}
+	response.Total = len(adsz.Clients)
+	kept := []map[string]interface{}{}
+	for _, client := range adsz.Clients {
+		id, _ := client["connectionId"].(string)
+		if matches(id) {
+			kept = append(kept, client)
+		}
+	}
+	response.Matched = len(kept)
+	if len(kept) > limit {
+		response.Omitted = len(kept) - limit
+		kept = kept[:limit]
+	}
+	response.Data, _ = json.Marshal(kept)
+	return nil
+}
+
+// filterPushStatus keeps the per-proxy push status entries of the matching proxies along with the
+// push-wide fields such as the push version and counters
+func filterPushStatus(body []byte, matches func(string) bool, response *IstiodDebugResponse) error {
+	var status map[string]interface{}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return fmt.Errorf("failed to parse push status: %w", err)
+	}
+	proxies := make(map[string]bool)
+	matched := make(map[string]bool)
+	if metrics, ok := status["ProxyStatus"].(map[string]interface{}); ok {
+		for metric, value := range metrics {
+			entries, ok := value.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for key, entry := range entries {
+				proxies[key] = true
+				id := key
+				if fields, ok := entry.(map[string]interface{}); ok {
+					if proxy, ok := fields["proxy"].(string); ok && proxy != "" {
+						id = proxy
+					}
+				}
+				if matches(id) {
+					matched[key] = true
+				} else {
+					delete(entries, key)
+				}
+			}
+			if len(entries) == 0 {
+				delete(metrics, metric)
+			}
+		}
+	}
+	response.Total = len(proxies)
+	response.Matched = len(matched)
+	response.Data, _ = json.Marshal(status)
+	return nil
+}
+
+// istiodProxyMatches reports whether a proxy ID, connection ID or node ID belongs to a proxy matching the
+// filters; node IDs look like sidecar~10.0.0.1~pod.namespace~namespace.svc.cluster.local, and connection IDs
+// add a -<counter> suffix
+func istiodProxyMatches(id, proxy, namespace string) bool {
+	name := id
+	if parts := strings.Split(id, "~"); len(parts) == 4 {
+		name = parts[2]
+	}
+	if proxy != "" && !strings.Contains(name, proxy) {
+		return false
+	}
+	if namespace != "" && !strings.HasSuffix(name, "."+namespace) {
+		return false
+	}
+	return true
+}
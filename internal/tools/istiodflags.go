@@ -0,0 +1,382 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+)
+
+// istiodFlag describes an istiod environment variable
+type istiodFlag struct {
+	feature    string
+	category   string              // feature, experimental, tuning, security, compatibility or install
+	deprecated string              // what replaces the flag, when it is deprecated
+	since      string              // for compatibility flags, the release whose behavior change the flag reverts
+	risk       func(string) string // explains why a value is risky, or returns ""
+}
+
+// istiodFlags covers the commonly set istiod feature flags; other PILOT_* and experimental variables are reported
+// as unrecognized
+var istiodFlags = map[string]istiodFlag{
+	// Set by the istiod chart to wire the deployment up
+	"REVISION":                       {feature: "Control plane revision", category: "install"},
+	"JWT_POLICY":                     {feature: "Service account token type", category: "install"},
+	"PILOT_CERT_PROVIDER":            {feature: "Provider of istiod's serving certificate", category: "install"},
+	"POD_NAME":                       {feature: "Downward API", category: "install"},
+	"POD_NAMESPACE":                  {feature: "Downward API", category: "install"},
+	"SERVICE_ACCOUNT":                {feature: "Downward API", category: "install"},
+	"KUBECONFIG":                     {feature: "Kubernetes client config", category: "install"},
+	"CLUSTER_ID":                     {feature: "Multicluster cluster name", category: "install"},
+	"GOMEMLIMIT":                     {feature: "Go memory limit", category: "install"},
+	"GOMAXPROCS":                     {feature: "Go CPU limit", category: "install"},
+	"PLATFORM":                       {feature: "Platform specific defaults", category: "install"},
+	"ISTIOD_CUSTOM_HOST":             {feature: "Extra hostnames in istiod's certificate", category: "install"},
+	"INJECTION_WEBHOOK_CONFIG_NAME":  {feature: "Sidecar injector webhook name", category: "install"},
+	"VALIDATION_WEBHOOK_CONFIG_NAME": {feature: "Validation webhook name", category: "install"},
+	"CA_TRUSTED_NODE_ACCOUNTS":       {feature: "ztunnel service accounts allowed to request certificates for other identities", category: "install"},
+
+	"PILOT_ENABLE_AMBIENT":                           {feature: "Ambient mode", category: "feature"},
+	"ENABLE_NATIVE_SIDECARS":                         {feature: "Inject istio-proxy as a Kubernetes native sidecar", category: "feature"},
+	"PILOT_ENABLE_GATEWAY_API":                       {feature: "Gateway API support", category: "feature"},
+	"PILOT_ENABLE_ALPHA_GATEWAY_API":                 {feature: "Alpha Gateway API types such as TCPRoute and TLSRoute", category: "experimental"},
+	"PILOT_ENABLE_GATEWAY_API_DEPLOYMENT_CONTROLLER": {feature: "Automated deployment of Gateway API gateways", category: "feature"},
+	"PILOT_ENABLE_WORKLOAD_ENTRY_AUTOREGISTRATION":   {feature: "VM WorkloadEntry auto-registration", category: "feature"},
+	"PILOT_ENABLE_WORKLOAD_ENTRY_HEALTHCHECKS":       {feature: "VM WorkloadEntry health checks", category: "feature"},
+	"PILOT_ENABLE_CROSS_CLUSTER_WORKLOAD_ENTRY":      {feature: "WorkloadEntries selected across clusters", category: "feature"},
+	"PILOT_ENABLE_K8S_SELECT_WORKLOAD_ENTRIES":       {feature: "Kubernetes Services selecting WorkloadEntries", category: "feature"},
+	"PILOT_ENABLE_IP_AUTOALLOCATE":                   {feature: "Controller allocating addresses for ServiceEntries", category: "feature"},
+	"PILOT_ENABLE_HBONE":                             {feature: "HBONE tunneling for sidecars", category: "experimental"},
+	"ISTIO_DUAL_STACK":                               {feature: "Dual-stack listeners and clusters", category: "experimental"},
+	"PILOT_ENABLE_QUIC_LISTENERS":                    {feature: "HTTP/3 listeners on gateways", category: "experimental"},
+	"PILOT_ENABLE_MYSQL_FILTER":                      {feature: "MySQL protocol filter", category: "experimental"},
+	"PILOT_ENABLE_REDIS_FILTER":                      {feature: "Redis protocol filter", category: "experimental"},
+	"PILOT_HTTP10":                                   {feature: "HTTP/1.0 support in proxies", category: "feature"},
+	"PILOT_ENABLE_ANALYSIS": {feature: "In-cluster analysis written to resource status", category: "feature", risk: func(value string) string {
+		if flagEnabled(value) {
+			return "analysis runs on every config change and writes status, adding istiod CPU and API server load in large meshes"
+		}
+		return ""
+	}},
+	"PILOT_ENABLE_CONFIG_DISTRIBUTION_TRACKING": {feature: "Config distribution status on resources", category: "feature", risk: func(value string) string {
+		if flagEnabled(value) {
+			return "istiod writes distribution status for every resource, which adds API server load in large meshes"
+		}
+		return ""
+	}},
+	"PILOT_ENABLE_PROTOCOL_SNIFFING_FOR_OUTBOUND": {feature: "Outbound protocol detection", category: "feature", risk: func(value string) string {
+		if !flagEnabled(value) {
+			return "ports without a protocol in their name or appProtocol are treated as plain TCP, losing HTTP routing and telemetry"
+		}
+		return ""
+	}},
+	"PILOT_ENABLE_PROTOCOL_SNIFFING_FOR_INBOUND": {feature: "Inbound protocol detection", category: "feature", risk: func(value string) string {
+		if !flagEnabled(value) {
+			return "ports without a protocol in their name or appProtocol are treated as plain TCP, losing HTTP routing and telemetry"
+		}
+		return ""
+	}},
+	"PILOT_SCOPE_GATEWAY_TO_NAMESPACE":    {feature: "Gateways select only gateway pods in their own namespace", category: "feature"},
+	"PILOT_FILTER_GATEWAY_CLUSTER_CONFIG": {feature: "Send gateways only the clusters their routes use", category: "tuning"},
+	"PILOT_DEBOUNCE_AFTER": {feature: "Quiet period before a config push", category: "tuning", risk: func(value string) string {
+		if d, err := time.ParseDuration(value); err == nil && d > time.Second {
+			return "config changes wait more than a second before they are pushed to proxies"
+		}
+		return ""
+	}},
+	"PILOT_DEBOUNCE_MAX": {feature: "Longest delay of a debounced push", category: "tuning", risk: func(value string) string {
+		if d, err := time.ParseDuration(value); err == nil && d > 30*time.Second {
+			return "a steady stream of config changes can hold pushes back for more than 30 seconds"
+		}
+		return ""
+	}},
+	"PILOT_PUSH_THROTTLE":       {feature: "Concurrent pushes", category: "tuning"},
+	"PILOT_ENABLE_EDS_DEBOUNCE": {feature: "Debounce endpoint updates", category: "tuning"},
+	"PILOT_XDS_CACHE_SIZE":      {feature: "xDS cache entries", category: "tuning"},
+	"PILOT_ENABLE_XDS_CACHE": {feature: "xDS response cache", category: "tuning", risk: func(value string) string {
+		if !flagEnabled(value) {
+			return "every push regenerates all config, raising istiod CPU sharply"
+		}
+		return ""
+	}},
+	"PILOT_TRACE_SAMPLING": {feature: "Trace sampling percentage", category: "tuning", risk: func(value string) string {
+		if percent, err := strconv.ParseFloat(value, 64); err == nil && percent >= 10 {
+			return fmt.Sprintf("%s%% of requests are traced, which costs proxy CPU and tracing backend capacity", value)
+		}
+		return ""
+	}},
+	"PILOT_JWT_ENABLE_REMOTE_JWKS": {feature: "Whether istiod or Envoy fetches JWKS", category: "security"},
+	"PILOT_SKIP_VALIDATE_TRUST_DOMAIN": {feature: "Trust domain check of authorization principals", category: "security", risk: func(value string) string {
+		if flagEnabled(value) {
+			return "AuthorizationPolicy principals match identities from any trust domain"
+		}
+		return ""
+	}},
+	"UNSAFE_ENABLE_ADMIN_ENDPOINTS": {feature: "Unauthenticated istiod debug endpoints", category: "security", risk: func(value string) string {
+		if flagEnabled(value) {
+			return "istiod's debug endpoints, including config and secrets metadata, are reachable without authentication"
+		}
+		return ""
+	}},
+	"ENABLE_DEBUG_ON_HTTP": {feature: "Debug endpoints on the plaintext port 8080", category: "security"},
+	"DEFAULT_WORKLOAD_CERT_TTL": {feature: "Workload certificate lifetime", category: "security", risk: func(value string) string {
+		if d, err := time.ParseDuration(value); err == nil && d > 7*24*time.Hour {
+			return "workload certificates live longer than a week, so a leaked key stays valid that long"
+		}
+		return ""
+	}},
+	"MAX_WORKLOAD_CERT_TTL":           {feature: "Longest workload certificate lifetime a proxy may request", category: "security"},
+	"CITADEL_SELF_SIGNED_CA_CERT_TTL": {feature: "Lifetime of istiod's self-signed root", category: "security"},
+	"EXTERNAL_CA":                     {feature: "Workload certificates signed by an external CA", category: "experimental"},
+	"ENABLE_LEGACY_FSGROUP_INJECTION": {feature: "fsGroup injected for proxy token access", category: "compatibility", deprecated: "not needed with current sidecar images; drop it"},
+
+	// Compatibility flags revert a behavior change; istio's compatibilityVersion profiles set them
+	"ENABLE_AUTO_SNI": {feature: "Set SNI from the host for DestinationRule TLS origination", category: "compatibility", since: "1.20", risk: func(value string) string {
+		if !flagEnabled(value) {
+			return "TLS originated by proxies sends no SNI unless a DestinationRule sets one, which many servers reject"
+		}
+		return ""
+	}},
+	"VERIFY_CERTIFICATE_AT_CLIENT": {feature: "Verify server certificates for DestinationRule TLS origination", category: "compatibility", since: "1.20", risk: func(value string) string {
+		if !flagEnabled(value) {
+			return "proxies originating TLS do not verify the server's certificate unless a DestinationRule sets caCertificates"
+		}
+		return ""
+	}},
+	"ENABLE_EXTERNAL_NAME_ALIAS":                                       {feature: "ExternalName services treated as DNS aliases", category: "compatibility", since: "1.20"},
+	"PERSIST_OLDEST_FIRST_HEURISTIC_FOR_VIRTUAL_SERVICE_HOST_MATCHING": {feature: "Oldest-first VirtualService host matching", category: "compatibility", since: "1.20"},
+	"ENABLE_ENHANCED_RESOURCE_SCOPING":                                 {feature: "discoverySelectors scoping of Istio resources", category: "compatibility", since: "1.20"},
+	"ENABLE_RESOLUTION_NONE_TARGET_PORT":                               {feature: "targetPort honored for resolution NONE ServiceEntries", category: "compatibility", since: "1.20"},
+	"ENABLE_ENHANCED_DESTINATIONRULE_MERGE":                            {feature: "Merging DestinationRules with different exportTo", category: "compatibility", since: "1.22"},
+	"PILOT_UNIFIED_SIDECAR_SCOPE":                                      {feature: "Unified Sidecar scope computation", category: "compatibility", since: "1.22"},
+	"ENABLE_DEFERRED_STATS_CREATION":                                   {feature: "Deferred Envoy stats creation", category: "compatibility", since: "1.22"},
+	"BYPASS_OVERLOAD_MANAGER_FOR_STATIC_LISTENERS":                     {feature: "Overload manager bypass for static listeners", category: "compatibility", since: "1.22"},
+	"ENABLE_INBOUND_RETRY_POLICY":                                      {feature: "Retries of inbound connection resets", category: "compatibility", since: "1.23"},
+	"EXCLUDE_UNSAFE_503_FROM_DEFAULT_RETRY":                            {feature: "Unsafe 503s excluded from default retries", category: "compatibility", since: "1.23"},
+	"PREFER_DESTINATIONRULE_TLS_FOR_EXTERNAL_SERVICES":                 {feature: "DestinationRule TLS preferred for ServiceEntries", category: "compatibility", since: "1.23"},
+}
+
+// IstiodFlag is one environment variable set on an istiod deployment
+type IstiodFlag struct {
+	Name       string `json:"name"`
+	Value      string `json:"value"`
+	Istiod     string `json:"istiod"` // deployment name
+	Feature    string `json:"feature,omitempty"`
+	Category   string `json:"category"` // feature, experimental, tuning, security, compatibility, install or unrecognized
+	Deprecated string `json:"deprecated,omitempty"`
+	Risk       string `json:"risk,omitempty"`
+}
+
+// IstiodDeploymentInfo identifies an audited istiod deployment
+type IstiodDeploymentInfo struct {
+	Deployment string `json:"deployment"`
+	Revision   string `json:"revision"`
+	Version    string `json:"version,omitempty"`
+}
+
+// IstiodFlagAudit is the result of audit_istiod_flags
+type IstiodFlagAudit struct {
+	IstioNamespace string                 `json:"istio_namespace"`
+	Istiods        []IstiodDeploymentInfo `json:"istiods"`
+	Flags          []IstiodFlag           `json:"flags"`
+	Summary        map[string]int         `json:"summary"` // flags per category
+	Issues         []string               `json:"issues,omitempty"`
+	Timestamp      time.Time              `json:"timestamp"`
+}
+
+// auditIstiodFlagsParams are the arguments of audit_istiod_flags
+type auditIstiodFlagsParams struct {
+	IstioNamespace  string `json:"istio_namespace,omitempty"`  // default: istio-system
+	Revision        string `json:"revision,omitempty"`         // default: every revision
+	IncludeDefaults bool   `json:"include_defaults,omitempty"` // also list the variables the chart sets to wire istiod up
+}
+
+// AuditIstiodFlags lists the feature flags set on istiod as environment variables, maps them to the features they
+// control, and flags deprecated and risky values, compatibility flags left behind by an upgrade and flags that
+// differ between revisions
+func (m *Manager) AuditIstiodFlags(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params auditIstiodFlagsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	selector := "app=istiod"
+	if params.Revision != "" {
+		selector += ",istio.io/rev=" + params.Revision
+	}
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.IstioNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istiod deployments: %v", err),
+				},
+			},
+		}, nil
+	}
+	if len(deployments.Items) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No istiod deployments matching %s in namespace %s", selector, params.IstioNamespace),
+				},
+			},
+		}, nil
+	}
+	sort.Slice(deployments.Items, func(i, j int) bool {
+		return deployments.Items[i].Name < deployments.Items[j].Name
+	})
+
+	audit := &IstiodFlagAudit{
+		IstioNamespace: params.IstioNamespace,
+		Istiods:        []IstiodDeploymentInfo{},
+		Flags:          []IstiodFlag{},
+		Summary:        make(map[string]int),
+		Timestamp:      time.Now(),
+	}
+	// Values per flag and istiod, to compare revisions
+	values := make(map[string]map[string]string)
+	for _, deployment := range deployments.Items {
+		info := IstiodDeploymentInfo{Deployment: deployment.Name, Revision: deployment.Labels["istio.io/rev"]}
+		if info.Revision == "" {
+			info.Revision = defaultRevision
+		}
+		var env []corev1.EnvVar
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name != "discovery" {
+				continue
+			}
+			env = container.Env
+			if idx := strings.LastIndex(container.Image, ":"); idx >= 0 {
+				info.Version, _, _ = strings.Cut(container.Image[idx+1:], "-")
+			}
+		}
+		audit.Istiods = append(audit.Istiods, info)
+		running, _ := utilversion.ParseGeneric(info.Version)
+
+		for _, variable := range env {
+			flag := IstiodFlag{Name: variable.Name, Value: variable.Value, Istiod: deployment.Name, Category: "unrecognized"}
+			if variable.ValueFrom != nil {
+				flag.Value = "(set from " + envSourceName(variable.ValueFrom) + ")"
+			}
+			known, ok := istiodFlags[variable.Name]
+			if ok {
+				flag.Feature, flag.Category, flag.Deprecated = known.feature, known.category, known.deprecated
+				if known.risk != nil && variable.ValueFrom == nil {
+					flag.Risk = known.risk(variable.Value)
+				}
+			} else if !strings.HasPrefix(variable.Name, "PILOT_") && !strings.HasPrefix(variable.Name, "ENABLE_") &&
+				!strings.HasPrefix(variable.Name, "ISTIO_") && !strings.Contains(variable.Name, "EXPERIMENTAL") {
+				flag.Category = "other"
+			}
+			if flag.Category == "install" && !params.IncludeDefaults {
+				continue
+			}
+			if values[flag.Name] == nil {
+				values[flag.Name] = make(map[string]string)
+			}
+			values[flag.Name][deployment.Name] = flag.Value
+
+			switch {
+			case flag.Risk != "":
+				audit.Issues = append(audit.Issues, fmt.Sprintf("%s sets %s=%s: %s", deployment.Name, flag.Name, flag.Value, flag.Risk))
+			case flag.Deprecated != "":
+				audit.Issues = append(audit.Issues, fmt.Sprintf("%s sets deprecated %s; %s", deployment.Name, flag.Name, flag.Deprecated))
+			}
+			if known.since != "" {
+				if since, err := utilversion.ParseGeneric(known.since); err == nil && running != nil && !running.LessThan(since) {
+					audit.Issues = append(audit.Issues, fmt.Sprintf("%s (%s) sets compatibility flag %s, which restores behavior from before %s; verify the workloads with the new behavior and remove it before a later release drops it",
+						deployment.Name, info.Version, flag.Name, known.since))
+				}
+			}
+			if flag.Category == "experimental" && variable.ValueFrom == nil && flagEnabled(flag.Value) {
+				audit.Issues = append(audit.Issues, fmt.Sprintf("%s enables experimental %s (%s); re-test it after every upgrade", deployment.Name, flag.Name, flag.Feature))
+			}
+			audit.Summary[flag.Category]++
+			audit.Flags = append(audit.Flags, flag)
+		}
+	}
+
+	// A flag set differently on two revisions changes behavior when workloads move between them
+	if len(audit.Istiods) > 1 {
+		names := make([]string, 0, len(values))
+		for name := range values {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if istiodFlags[name].category == "install" {
+				continue
+			}
+			var settings []string
+			differs := false
+			for i, istiod := range audit.Istiods {
+				value, ok := values[name][istiod.Deployment]
+				if !ok {
+					value = "(unset)"
+				}
+				settings = append(settings, istiod.Deployment+"="+value)
+				differs = differs || settings[i][len(istiod.Deployment):] != settings[0][len(audit.Istiods[0].Deployment):]
+			}
+			if differs {
+				audit.Issues = append(audit.Issues, fmt.Sprintf("%s differs between revisions (%s); workloads moving to another revision change behavior", name, strings.Join(settings, ", ")))
+			}
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(audit, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// flagEnabled reads a boolean feature flag the way istiod does; values that are not booleans count as set
+func flagEnabled(value string) bool {
+	enabled, err := strconv.ParseBool(value)
+	return err != nil || enabled
+}
+
+// envSourceName describes where an environment variable's value comes from
+func envSourceName(source *corev1.EnvVarSource) string {
+	switch {
+	case source.ConfigMapKeyRef != nil:
+		return fmt.Sprintf("ConfigMap %s key %s", source.ConfigMapKeyRef.Name, source.ConfigMapKeyRef.Key)
+	case source.SecretKeyRef != nil:
+		return fmt.Sprintf("Secret %s key %s", source.SecretKeyRef.Name, source.SecretKeyRef.Key)
+	case source.FieldRef != nil:
+		return source.FieldRef.FieldPath
+	case source.ResourceFieldRef != nil:
+		return source.ResourceFieldRef.Resource
+	}
+	return "a reference"
+}
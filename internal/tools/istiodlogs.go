@@ -0,0 +1,285 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// istioLogLevels orders the levels of Istio's logging scopes
+var istioLogLevels = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+	"fatal": 4,
+}
+
+// istiodScopeAliases maps the names agents tend to use to istiod's logging scopes
+var istiodScopeAliases = map[string]string{
+	"injection": "inject",
+	"injector":  "inject",
+	"xds":       "ads",
+	"webhook":   "validation",
+}
+
+// IstiodLogLine is one istiod log line, split into Istio's log fields
+type IstiodLogLine struct {
+	Time    string `json:"time,omitempty"`
+	Level   string `json:"level"`
+	Scope   string `json:"scope"`
+	Message string `json:"message"`
+}
+
+// IstiodPodLogs holds the matching lines of one istiod pod
+type IstiodPodLogs struct {
+	Istiod    string          `json:"istiod"`
+	Scanned   int             `json:"scanned"` // lines read before filtering
+	Matched   int             `json:"matched"`
+	Truncated bool            `json:"truncated,omitempty"` // more lines matched than max_lines
+	Lines     []IstiodLogLine `json:"lines"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// IstiodLogsResult is the result of get_istiod_logs
+type IstiodLogsResult struct {
+	IstioNamespace string          `json:"istio_namespace"`
+	Scopes         []string        `json:"scopes,omitempty"`
+	Level          string          `json:"level"`
+	ScopeCounts    map[string]int  `json:"scope_counts"` // matched lines per scope across pods
+	Pods           []IstiodPodLogs `json:"pods"`
+	Timestamp      time.Time       `json:"timestamp"`
+}
+
+// getIstiodLogsParams are the arguments of get_istiod_logs
+type getIstiodLogsParams struct {
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Revision       string   `json:"revision,omitempty"`        // default: every revision
+	PodName        string   `json:"pod_name,omitempty"`        // one istiod pod
+	Scopes         []string `json:"scopes,omitempty"`          // e.g. ads, validation, injection; default: all
+	Level          string   `json:"level,omitempty"`           // minimum level, default: info
+	Since          string   `json:"since,omitempty"`           // default: 1h
+	Lines          int64    `json:"lines,omitempty"`           // lines read per pod before filtering, default: 2000
+	MaxLines       int      `json:"max_lines,omitempty"`       // matching lines returned per pod, default: 200
+}
+
+// GetIstiodLogs reads the logs of the istiod pods, keeping the lines of the requested scopes at or above a level;
+// the control plane counterpart of get_istio_proxy_logs
+func (m *Manager) GetIstiodLogs(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getIstiodLogsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Level == "" {
+		params.Level = "info"
+	}
+	if params.Since == "" {
+		params.Since = "1h"
+	}
+	if params.Lines == 0 {
+		params.Lines = 2000
+	}
+	if params.MaxLines == 0 {
+		params.MaxLines = 200
+	}
+
+	params.Level = strings.ToLower(params.Level)
+	if params.Level == "warning" {
+		params.Level = "warn"
+	}
+	minLevel, ok := istioLogLevels[params.Level]
+	if !ok {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid level %q: use debug, info, warn, error or fatal", params.Level),
+				},
+			},
+		}, nil
+	}
+	since, err := time.ParseDuration(params.Since)
+	if err != nil || since <= 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid since %q: must be a positive duration such as 30m", params.Since),
+				},
+			},
+		}, nil
+	}
+	scopes := make(map[string]bool)
+	for i, scope := range params.Scopes {
+		scope = strings.ToLower(strings.TrimSpace(scope))
+		if alias, ok := istiodScopeAliases[scope]; ok {
+			scope = alias
+		}
+		params.Scopes[i] = scope
+		scopes[scope] = true
+	}
+
+	selector := "app=istiod"
+	if params.Revision != "" {
+		selector += ",istio.io/rev=" + params.Revision
+	}
+	istiods, err := m.runningPods(ctx, params.IstioNamespace, selector)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istiod pods: %v", err),
+				},
+			},
+		}, nil
+	}
+	if params.PodName != "" {
+		var selected []corev1.Pod
+		for _, istiod := range istiods {
+			if istiod.Name == params.PodName {
+				selected = append(selected, istiod)
+			}
+		}
+		istiods = selected
+	}
+	if len(istiods) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No running istiod pods matching %s in namespace %s", selector, params.IstioNamespace),
+				},
+			},
+		}, nil
+	}
+	sort.Slice(istiods, func(i, j int) bool {
+		return istiods[i].Name < istiods[j].Name
+	})
+
+	result := &IstiodLogsResult{
+		IstioNamespace: params.IstioNamespace,
+		Scopes:         params.Scopes,
+		Level:          params.Level,
+		ScopeCounts:    make(map[string]int),
+		Pods:           []IstiodPodLogs{},
+		Timestamp:      time.Now(),
+	}
+	sinceTime := metav1.NewTime(time.Now().Add(-since))
+	for _, istiod := range istiods {
+		podLogs := IstiodPodLogs{Istiod: istiod.Name, Lines: []IstiodLogLine{}}
+		logs, err := m.k8sClient.Kubernetes.CoreV1().Pods(istiod.Namespace).GetLogs(istiod.Name, &corev1.PodLogOptions{
+			Container: "discovery",
+			SinceTime: &sinceTime,
+			TailLines: &params.Lines,
+		}).Stream(ctx)
+		if err != nil {
+			podLogs.Error = err.Error()
+			result.Pods = append(result.Pods, podLogs)
+			continue
+		}
+
+		// Lines that are not Istio log lines, such as stack traces, continue the line before them
+		kept := false
+		scanner := bufio.NewScanner(logs)
+		for scanner.Scan() {
+			podLogs.Scanned++
+			line, ok := parseIstioLogLine(scanner.Text())
+			if !ok {
+				if kept && len(podLogs.Lines) > 0 {
+					podLogs.Lines[len(podLogs.Lines)-1].Message += "\n" + scanner.Text()
+				}
+				continue
+			}
+			kept = istioLogLevels[line.Level] >= minLevel && (len(scopes) == 0 || scopes[line.Scope])
+			if !kept {
+				continue
+			}
+			podLogs.Matched++
+			result.ScopeCounts[line.Scope]++
+			podLogs.Lines = append(podLogs.Lines, line)
+			// Keep the newest lines
+			if len(podLogs.Lines) > params.MaxLines {
+				podLogs.Lines = podLogs.Lines[1:]
+				podLogs.Truncated = true
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			podLogs.Error = fmt.Sprintf("error reading logs: %v", err)
+		}
+		logs.Close()
+		result.Pods = append(result.Pods, podLogs)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// parseIstioLogLine splits a line in Istio's text format (time, level, scope and message separated by tabs, with
+// the scope left out for the default scope) or JSON format
+func parseIstioLogLine(raw string) (IstiodLogLine, bool) {
+	if strings.HasPrefix(raw, "{") {
+		var entry struct {
+			Time  string `json:"time"`
+			Level string `json:"level"`
+			Scope string `json:"scope"`
+			Msg   string `json:"msg"`
+		}
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			return IstiodLogLine{}, false
+		}
+		if _, ok := istioLogLevels[entry.Level]; !ok {
+			return IstiodLogLine{}, false
+		}
+		if entry.Scope == "" {
+			entry.Scope = "default"
+		}
+		return IstiodLogLine{Time: entry.Time, Level: entry.Level, Scope: entry.Scope, Message: entry.Msg}, true
+	}
+
+	fields := strings.SplitN(raw, "\t", 4)
+	if len(fields) < 3 {
+		return IstiodLogLine{}, false
+	}
+	if _, ok := istioLogLevels[fields[1]]; !ok {
+		return IstiodLogLine{}, false
+	}
+	line := IstiodLogLine{Time: fields[0], Level: fields[1], Scope: "default", Message: strings.Join(fields[2:], "\t")}
+	// Scope names are short identifiers, unlike the first tab-separated part of a default scope message
+	if len(fields) == 4 && len(fields[2]) <= 32 && !strings.ContainsAny(fields[2], " :=") {
+		line.Scope, line.Message = fields[2], fields[3]
+	}
+	return line, true
+}
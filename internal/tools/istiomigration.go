@@ -0,0 +1,1036 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"meshpilot/internal/config"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+var (
+	istioOperatorGVR = schema.GroupVersionResource{Group: "install.istio.io", Version: "v1alpha1", Resource: "istiooperators"}
+	sailIstioGVRs    = []schema.GroupVersionResource{
+		{Group: "sailoperator.io", Version: "v1", Resource: "istios"},
+		{Group: "sailoperator.io", Version: "v1alpha1", Resource: "istios"},
+	}
+)
+
+// istioComponentLabel marks every resource istioctl and the in-cluster operator install
+const istioComponentLabel = "operator.istio.io/component"
+
+// istioVersionPattern extracts the release from an istiod image tag such as 1.22.1-distroless
+var istioVersionPattern = regexp.MustCompile(`^v?(\d+\.\d+\.\d+(-(alpha|beta|rc)\.\d+)?)`)
+
+// istioctlResourceTypes are the resource types istioctl creates, which Helm has to adopt
+var istioctlResourceTypes = []schema.GroupVersionResource{
+	{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"},
+	{Group: "", Version: "v1", Resource: "serviceaccounts"},
+	{Group: "", Version: "v1", Resource: "configmaps"},
+	{Group: "", Version: "v1", Resource: "services"},
+	{Group: "apps", Version: "v1", Resource: "deployments"},
+	{Group: "apps", Version: "v1", Resource: "daemonsets"},
+	{Group: "autoscaling", Version: "v2", Resource: "horizontalpodautoscalers"},
+	{Group: "policy", Version: "v1", Resource: "poddisruptionbudgets"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "roles"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "rolebindings"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterroles"},
+	{Group: "rbac.authorization.k8s.io", Version: "v1", Resource: "clusterrolebindings"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "mutatingwebhookconfigurations"},
+	{Group: "admissionregistration.k8s.io", Version: "v1", Resource: "validatingwebhookconfigurations"},
+	{Group: "networking.istio.io", Version: "v1alpha3", Resource: "envoyfilters"},
+}
+
+// IstioInstallDetection describes how the running Istio control plane was installed
+type IstioInstallDetection struct {
+	Method             string   `json:"method"`                        // istioctl, operator, helm, sail or manifest
+	IstioOperator      string   `json:"istio_operator,omitempty"`      // namespace/name of the IstioOperator resource
+	OperatorDeployment string   `json:"operator_deployment,omitempty"` // running in-cluster operator
+	Istiod             string   `json:"istiod"`
+	Revision           string   `json:"revision"`
+	Version            string   `json:"version,omitempty"`
+	Hub                string   `json:"hub,omitempty"`
+	Profile            string   `json:"profile,omitempty"`
+	Components         []string `json:"components"`
+}
+
+// MigrationRelease is a Helm release that takes over one istioctl component
+type MigrationRelease struct {
+	Release   string                 `json:"release"`
+	Chart     string                 `json:"chart"`
+	Namespace string                 `json:"namespace"`
+	Component string                 `json:"component"`
+	Values    map[string]interface{} `json:"values,omitempty"`
+	Adopt     []string               `json:"adopt,omitempty"` // existing resources labeled for Helm before the install
+	objects   []migrationObject
+}
+
+// migrationObject is an istioctl-managed resource
+type migrationObject struct {
+	gvr       schema.GroupVersionResource
+	namespace string
+	name      string
+	component string
+	labels    map[string]string
+}
+
+// String names the object the way kubectl accepts it
+func (o migrationObject) String() string {
+	resource := o.gvr.Resource
+	if o.gvr.Group != "" {
+		resource += "." + o.gvr.Group
+	}
+	if o.namespace == "" {
+		return resource + "/" + o.name
+	}
+	return resource + "/" + o.name + " -n " + o.namespace
+}
+
+// IstioMigrationResult is the plan, and when executed the outcome, of moving an installation to Helm or Sail
+type IstioMigrationResult struct {
+	Target        string                 `json:"target"`
+	Executed      bool                   `json:"executed"`
+	Detection     IstioInstallDetection  `json:"detection"`
+	Releases      []MigrationRelease     `json:"releases,omitempty"`
+	IstioResource map[string]interface{} `json:"istio_resource,omitempty"` // Sail Istio resource
+	Namespaces    []string               `json:"namespaces,omitempty"`     // namespaces moved to the Sail revision
+	Commands      []string               `json:"commands,omitempty"`
+	Steps         []CanaryUpgradeStep    `json:"steps,omitempty"`
+	Verified      bool                   `json:"verified"`
+	Blockers      []string               `json:"blockers,omitempty"`
+	Issues        []string               `json:"issues,omitempty"`
+	Notes         []string               `json:"notes,omitempty"`
+	Timestamp     time.Time              `json:"timestamp"`
+}
+
+// addStep records the outcome of a migration step
+func (r *IstioMigrationResult) addStep(step, status, detail string) {
+	r.Steps = append(r.Steps, CanaryUpgradeStep{Step: step, Status: status, Detail: detail})
+}
+
+// migrateIstioInstallParams are the arguments of migrate_istio_install
+type migrateIstioInstallParams struct {
+	Target         string   `json:"target,omitempty"`          // default: helm
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Revision       string   `json:"revision,omitempty"`        // revision to migrate, default: the default revision
+	Version        string   `json:"version,omitempty"`         // default: the version istiod runs
+	NewRevision    string   `json:"new_revision,omitempty"`    // Sail only, default: sail
+	Namespaces     []string `json:"namespaces,omitempty"`      // Sail only, default: every namespace using the revision
+	SailNamespace  string   `json:"sail_namespace,omitempty"`  // default: sail-operator
+	RepoURL        string   `json:"repo_url,omitempty"`        // chart repository override
+	Execute        bool     `json:"execute,omitempty"`         // default: false, only plan
+	Timeout        string   `json:"timeout,omitempty"`         // default: 5m
+}
+
+// MigrateIstioInstall detects an istioctl or IstioOperator installation and moves it to Helm releases
+// (adopting the existing resources in place) or to a Sail operator revision (side by side, then
+// moving the namespaces). Without execute it only returns the plan.
+func (m *Manager) MigrateIstioInstall(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params migrateIstioInstallParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Target == "" {
+		params.Target = "helm"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.NewRevision == "" {
+		params.NewRevision = "sail"
+	}
+	if params.SailNamespace == "" {
+		params.SailNamespace = "sail-operator"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	if params.Target != "helm" && params.Target != "sail" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid target %q: use helm or sail", params.Target),
+				},
+			},
+		}, nil
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	detection, iop, istiod, err := m.detectIstioInstall(ctx, params.IstioNamespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to detect the Istio installation: %v", err),
+				},
+			},
+		}, nil
+	}
+	if params.Version == "" {
+		params.Version = detection.Version
+	}
+
+	result := &IstioMigrationResult{
+		Target:    params.Target,
+		Executed:  params.Execute,
+		Detection: *detection,
+		Timestamp: time.Now(),
+	}
+	respond := func() (*CallToolResult, error) {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	switch detection.Method {
+	case "helm":
+		result.Blockers = append(result.Blockers, fmt.Sprintf("%s is already managed by Helm", detection.Istiod))
+	case "sail":
+		result.Blockers = append(result.Blockers, fmt.Sprintf("%s is already managed by the Sail operator", detection.Istiod))
+	case "manifest":
+		result.Notes = append(result.Notes, "istiod carries no istioctl labels, so it was probably applied from a generated manifest; only resources labeled "+istioComponentLabel+" can be adopted")
+	}
+	if params.Version == "" {
+		result.Blockers = append(result.Blockers, "Could not read the Istio version from the istiod image; pass version")
+	}
+	if params.Target == "sail" && params.NewRevision == detection.Revision {
+		result.Blockers = append(result.Blockers, fmt.Sprintf("new_revision %s is the revision being migrated; pick a different name", params.NewRevision))
+	}
+
+	values := istiodMigrationValues(iop, istiod, detection)
+	if iop == nil {
+		var meshConfig map[string]interface{}
+		if err := m.readMeshConfig(ctx, params.IstioNamespace, detection.Revision, &meshConfig); err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Mesh config was not copied: %v", err))
+		} else if len(meshConfig) > 0 {
+			values["meshConfig"] = meshConfig
+		}
+		result.Notes = append(result.Notes, "No IstioOperator resource was found, so values were read from the running istiod, its mesh config and the gateways; review them before executing")
+	} else {
+		result.Notes = append(result.Notes, unmappedComponentSettings(iop)...)
+	}
+
+	objects, err := m.istioctlObjects(ctx, detection.Revision)
+	if err != nil {
+		result.Notes = append(result.Notes, err.Error())
+	}
+	for _, object := range objects {
+		if !containsString(result.Detection.Components, object.component) {
+			result.Detection.Components = append(result.Detection.Components, object.component)
+		}
+	}
+	sort.Strings(result.Detection.Components)
+
+	if params.Target == "sail" {
+		return m.migrateIstioToSail(ctx, params.IstioNamespace, params.SailNamespace, params.Version, params.NewRevision, params.Namespaces, params.Execute, timeout, values, result, respond)
+	}
+
+	repo := resolveChartRepository(m.config.Helm.Istio, params.RepoURL)
+	result.Releases = m.planMigrationReleases(ctx, repo, params.IstioNamespace, iop, detection, values, objects)
+	result.Commands = migrationCommands(result.Releases, params.Version)
+	if detection.OperatorDeployment != "" {
+		namespace, name, _ := strings.Cut(detection.OperatorDeployment, "/")
+		result.Commands = append([]string{fmt.Sprintf("kubectl scale deployment/%s -n %s --replicas=0", name, namespace)}, result.Commands...)
+	}
+	if detection.IstioOperator != "" {
+		namespace, name, _ := strings.Cut(detection.IstioOperator, "/")
+		result.Commands = append(result.Commands, fmt.Sprintf("kubectl delete istiooperators.install.istio.io/%s -n %s", name, namespace))
+	}
+	result.Notes = append(result.Notes,
+		"Helm adopts the existing resources in place at the same version, so pods are not recreated unless a rendered field differs; upgrade the releases separately afterwards",
+		"Resources the charts no longer render (for example the istioctl gateway service accounts) keep running unmanaged; find them with the "+istioComponentLabel+" label and remove them once the releases are verified")
+
+	if !params.Execute || len(result.Blockers) > 0 {
+		if params.Execute {
+			result.Executed = false
+			result.Issues = append(result.Issues, "Nothing was changed because of the blockers")
+		}
+		return respond()
+	}
+
+	if err := m.checkHelmAvailable(); err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Helm is not available: %v", err))
+		return respond()
+	}
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to add Istio Helm repository: %v", err))
+		return respond()
+	}
+
+	// The in-cluster operator would reconcile the resources back, so it stops before anything moves
+	if detection.OperatorDeployment != "" {
+		namespace, name, _ := strings.Cut(detection.OperatorDeployment, "/")
+		patch := []byte(`{"spec":{"replicas":0}}`)
+		if _, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Patch(ctx, name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			result.addStep("stop in-cluster operator", "failed", err.Error())
+			result.Issues = append(result.Issues, "The in-cluster operator could not be stopped; nothing was adopted")
+			return respond()
+		}
+		result.addStep("stop in-cluster operator", "done", "scaled "+detection.OperatorDeployment+" to 0")
+	}
+
+	// Each release adopts its resources right before its install, so a failure leaves later components untouched
+	for _, release := range result.Releases {
+		if err := m.adoptForHelm(ctx, release); err != nil {
+			result.addStep("adopt "+release.Release, "failed", err.Error())
+			result.Issues = append(result.Issues, fmt.Sprintf("Stopped before release %s; earlier releases are installed", release.Release))
+			return respond()
+		}
+		result.addStep("adopt "+release.Release, "done", fmt.Sprintf("%d resources labeled for Helm", len(release.objects)))
+
+		installed, err := m.installHelmChart(ctx, helmChartRequest{
+			Release:   release.Release,
+			Chart:     release.Chart,
+			Namespace: release.Namespace,
+			Version:   params.Version,
+			Values:    release.Values,
+			Wait:      true,
+			Timeout:   params.Timeout,
+		})
+		if err != nil {
+			result.addStep("install "+release.Release, "failed", err.Error())
+			result.Issues = append(result.Issues, fmt.Sprintf("Stopped at release %s; the remaining components are still managed by istioctl", release.Release))
+			return respond()
+		}
+		result.addStep("install "+release.Release, "done", installed.String())
+	}
+
+	// The IstioOperator resource only records what istioctl installed; with the operator stopped its
+	// finalizer would never be removed
+	if iop != nil {
+		resource := m.k8sClient.Dynamic.Resource(istioOperatorGVR).Namespace(iop.GetNamespace())
+		if _, err := resource.Patch(ctx, iop.GetName(), types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{}); err != nil && !errors.IsNotFound(err) {
+			result.addStep("remove IstioOperator", "failed", err.Error())
+		} else if err := resource.Delete(ctx, iop.GetName(), metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			result.addStep("remove IstioOperator", "failed", err.Error())
+		} else {
+			result.addStep("remove IstioOperator", "done", detection.IstioOperator)
+		}
+	}
+
+	result.Verified = true
+	for _, release := range result.Releases {
+		info, err := m.getHelmReleaseInfo(release.Namespace, release.Release)
+		switch {
+		case err != nil:
+			result.Verified = false
+			result.Issues = append(result.Issues, fmt.Sprintf("Release %s: %v", release.Release, err))
+		case info.Status != "deployed":
+			result.Verified = false
+			result.Issues = append(result.Issues, fmt.Sprintf("Release %s is %s", release.Release, info.Status))
+		}
+	}
+	status, err := m.getIstioStatus(ctx, params.IstioNamespace)
+	if err != nil {
+		result.Verified = false
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to read Istio status: %v", err))
+	} else {
+		for _, component := range status.Components {
+			if !component.Ready {
+				result.Verified = false
+				result.Issues = append(result.Issues, fmt.Sprintf("%s is not ready (%d/%d)", component.Name, component.Available, component.Replicas))
+			}
+		}
+	}
+	if result.Verified {
+		result.addStep("verify", "done", fmt.Sprintf("%d releases deployed and the control plane is ready", len(result.Releases)))
+	} else {
+		result.addStep("verify", "failed", "see issues")
+	}
+	if detection.OperatorDeployment != "" {
+		namespace, _, _ := strings.Cut(detection.OperatorDeployment, "/")
+		result.Notes = append(result.Notes, fmt.Sprintf("The in-cluster operator is scaled to 0; delete namespace %s once you no longer need to roll back", namespace))
+	}
+	return respond()
+}
+
+// migrateIstioToSail creates a Sail Istio resource as a new revision next to the istioctl control
+// plane, then moves the namespaces to it; in-place adoption is not possible because Sail owns the
+// resources it renders through IstioRevision objects
+func (m *Manager) migrateIstioToSail(ctx context.Context, istioNamespace, sailNamespace, version, newRevision string, namespaces []string, execute bool, timeout time.Duration, values map[string]interface{}, result *IstioMigrationResult, respond func() (*CallToolResult, error)) (*CallToolResult, error) {
+	detection := result.Detection
+	// Sail renders the revision and owns the default webhook choice itself
+	delete(values, "revision")
+	delete(values, "defaultRevision")
+
+	spec := map[string]interface{}{
+		"version":        "v" + strings.TrimPrefix(version, "v"),
+		"namespace":      istioNamespace,
+		"updateStrategy": map[string]interface{}{"type": "InPlace"},
+		"values":         values,
+	}
+	if detection.Profile == "ambient" {
+		spec["profile"] = "ambient"
+	}
+	gvr, gvrErr := m.sailIstioGVR()
+	result.IstioResource = map[string]interface{}{
+		"apiVersion": gvr.GroupVersion().String(),
+		"kind":       "Istio",
+		"metadata":   map[string]interface{}{"name": newRevision},
+		"spec":       spec,
+	}
+
+	if len(namespaces) == 0 {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to list namespaces: %v", err))
+		} else {
+			for i := range list.Items {
+				if namespaceInjectionRevision(&list.Items[i]) == detection.Revision {
+					namespaces = append(namespaces, list.Items[i].Name)
+				}
+			}
+		}
+	}
+	result.Namespaces = namespaces
+
+	status, err := m.getSailOperatorStatus(ctx, sailNamespace)
+	switch {
+	case err != nil:
+		result.Blockers = append(result.Blockers, fmt.Sprintf("Failed to check the Sail operator: %v", err))
+	case !status.Installed || !status.Ready:
+		result.Blockers = append(result.Blockers, fmt.Sprintf("The Sail operator is not running in %s; install it with install_sail_operator first", sailNamespace))
+	}
+	if gvrErr != nil {
+		result.Blockers = append(result.Blockers, gvrErr.Error())
+	}
+
+	manifest, _ := json.Marshal(result.IstioResource)
+	result.Commands = append(result.Commands, fmt.Sprintf("echo '%s' | kubectl apply -f -", manifest))
+	for _, name := range namespaces {
+		result.Commands = append(result.Commands,
+			fmt.Sprintf("kubectl label namespace %s istio-injection- istio.io/rev=%s --overwrite", name, newRevision),
+			fmt.Sprintf("kubectl rollout restart deployment -n %s", name))
+	}
+	for _, component := range detection.Components {
+		if component == "Cni" || component == "Ztunnel" {
+			result.Notes = append(result.Notes, fmt.Sprintf("%s stays managed by istioctl; Sail's IstioCNI and ZTunnel resources replace the daemonsets, so migrate them in a maintenance window", component))
+		}
+		if component == "IngressGateways" || component == "EgressGateways" {
+			result.Notes = append(result.Notes, "istioctl gateways keep using the old control plane; redeploy them with gateway injection and the istio.io/rev="+newRevision+" label")
+		}
+	}
+	result.Notes = append(result.Notes,
+		"The Sail operator only installs the versions it ships; check that "+spec["version"].(string)+" is one of them",
+		fmt.Sprintf("The old revision keeps running until you remove it; when revision %s has no users left, uninstall it with istioctl uninstall --revision %s", detection.Revision, detection.Revision))
+
+	if !execute || len(result.Blockers) > 0 {
+		if execute {
+			result.Executed = false
+			result.Issues = append(result.Issues, "Nothing was changed because of the blockers")
+		}
+		return respond()
+	}
+
+	istio := &unstructured.Unstructured{Object: result.IstioResource}
+	markManaged(withManagingTool(ctx, "migrate_istio_install"), istio)
+	if _, err := m.k8sClient.Dynamic.Resource(gvr).Create(ctx, istio, metav1.CreateOptions{}); err != nil {
+		result.addStep("create Istio resource", "failed", err.Error())
+		return respond()
+	}
+	result.addStep("create Istio resource", "done", newRevision)
+
+	// Sail names the istiod of a non-default revision istiod-<revision>
+	deploymentName := "istiod"
+	if newRevision != defaultRevision {
+		deploymentName = "istiod-" + newRevision
+	}
+	pending := ""
+	err = wait.PollUntilContextTimeout(ctx, 5*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(istioNamespace).Get(ctx, deploymentName, metav1.GetOptions{})
+		if err != nil {
+			pending = err.Error()
+			return false, nil
+		}
+		pending = fmt.Sprintf("%d of %d replicas ready", deployment.Status.ReadyReplicas, deployment.Status.Replicas)
+		return deployment.Status.Replicas > 0 && deployment.Status.ReadyReplicas == deployment.Status.Replicas, nil
+	})
+	if err != nil {
+		result.addStep("wait for "+deploymentName, "failed", pending)
+		result.Issues = append(result.Issues, "The Sail revision did not become ready; no namespaces were moved")
+		return respond()
+	}
+	result.addStep("wait for "+deploymentName, "done", pending)
+
+	for _, name := range namespaces {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if ns.Labels == nil {
+				ns.Labels = make(map[string]string)
+			}
+			delete(ns.Labels, "istio-injection")
+			ns.Labels["istio.io/rev"] = newRevision
+			_, err = m.k8sClient.Kubernetes.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			result.addStep("relabel "+name, "failed", err.Error())
+			result.Issues = append(result.Issues, fmt.Sprintf("Namespace %s was not moved: %v", name, err))
+			continue
+		}
+		restarted, issues := m.restartSidecarWorkloads(ctx, name, timeout)
+		result.Issues = append(result.Issues, issues...)
+		status := "done"
+		if len(issues) > 0 {
+			status = "failed"
+		}
+		result.addStep("move "+name, status, fmt.Sprintf("istio.io/rev=%s, %d workloads restarted", newRevision, len(restarted)))
+	}
+
+	users := m.revisionUsers(ctx, detection.Revision)
+	if len(users) == 0 {
+		result.Verified = true
+		result.addStep("verify", "done", "revision "+detection.Revision+" has no users left")
+	} else {
+		result.addStep("verify", "failed", "revision "+detection.Revision+" still used by: "+strings.Join(users, "; "))
+	}
+	return respond()
+}
+
+// detectIstioInstall finds the istiod of a revision and works out which tool installed it
+func (m *Manager) detectIstioInstall(ctx context.Context, istioNamespace, revision string) (*IstioInstallDetection, *unstructured.Unstructured, *appsv1.Deployment, error) {
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(istioNamespace).List(ctx, metav1.ListOptions{LabelSelector: "app=istiod"})
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("failed to list istiod deployments: %w", err)
+	}
+	var istiod *appsv1.Deployment
+	for i := range deployments.Items {
+		deploymentRevision := deployments.Items[i].Labels["istio.io/rev"]
+		if deploymentRevision == "" {
+			deploymentRevision = defaultRevision
+		}
+		if deploymentRevision == revision || (revision == "" && deploymentRevision == defaultRevision) {
+			istiod = &deployments.Items[i]
+			break
+		}
+	}
+	if istiod == nil && revision == "" && len(deployments.Items) == 1 {
+		istiod = &deployments.Items[0]
+	}
+	if istiod == nil {
+		return nil, nil, nil, fmt.Errorf("no istiod deployment for revision %q in namespace %s", revision, istioNamespace)
+	}
+
+	detection := &IstioInstallDetection{
+		Method:     "manifest",
+		Istiod:     istioNamespace + "/" + istiod.Name,
+		Revision:   istiod.Labels["istio.io/rev"],
+		Components: []string{},
+	}
+	if detection.Revision == "" {
+		detection.Revision = defaultRevision
+	}
+	for _, container := range istiod.Spec.Template.Spec.Containers {
+		if container.Name != "discovery" {
+			continue
+		}
+		image, tag, _ := strings.Cut(container.Image[strings.LastIndex(container.Image, "/")+1:], ":")
+		detection.Hub = strings.TrimSuffix(strings.TrimSuffix(container.Image, image+":"+tag), "/")
+		if match := istioVersionPattern.FindStringSubmatch(tag); match != nil {
+			detection.Version = match[1]
+		}
+	}
+
+	switch {
+	case metav1.GetControllerOf(istiod) != nil && metav1.GetControllerOf(istiod).Kind == "IstioRevision":
+		detection.Method = "sail"
+	case istiod.Labels["app.kubernetes.io/managed-by"] == "Helm" || istiod.Annotations["meta.helm.sh/release-name"] != "":
+		detection.Method = "helm"
+	case istiod.Labels[istioComponentLabel] != "":
+		detection.Method = "istioctl"
+	}
+
+	operators, err := m.k8sClient.Kubernetes.AppsV1().Deployments("").List(ctx, metav1.ListOptions{FieldSelector: "metadata.name=istio-operator"})
+	if err == nil {
+		for _, operator := range operators.Items {
+			if operator.Spec.Replicas == nil || *operator.Spec.Replicas > 0 {
+				detection.OperatorDeployment = operator.Namespace + "/" + operator.Name
+				if detection.Method == "istioctl" {
+					detection.Method = "operator"
+				}
+			}
+		}
+	}
+
+	// The IstioOperator CRD is gone once istioctl stops persisting installed state, which is fine
+	var iop *unstructured.Unstructured
+	if operators, err := m.k8sClient.Dynamic.Resource(istioOperatorGVR).Namespace("").List(ctx, metav1.ListOptions{}); err == nil {
+		for i := range operators.Items {
+			iopRevision, _, _ := unstructured.NestedString(operators.Items[i].Object, "spec", "revision")
+			if iopRevision == "" {
+				iopRevision = defaultRevision
+			}
+			if iopRevision == detection.Revision {
+				iop = &operators.Items[i]
+				break
+			}
+		}
+	}
+	if iop != nil {
+		detection.IstioOperator = iop.GetNamespace() + "/" + iop.GetName()
+		detection.Profile, _, _ = unstructured.NestedString(iop.Object, "spec", "profile")
+		if hub, _, _ := unstructured.NestedString(iop.Object, "spec", "hub"); hub != "" {
+			detection.Hub = hub
+		}
+	}
+	return detection, iop, istiod, nil
+}
+
+// istioctlObjects lists the resources istioctl installed for a revision; resources of other revisions
+// stay with their own control plane
+func (m *Manager) istioctlObjects(ctx context.Context, revision string) ([]migrationObject, error) {
+	var objects []migrationObject
+	var skipped []string
+	for _, gvr := range istioctlResourceTypes {
+		list, err := m.k8sClient.Dynamic.Resource(gvr).Namespace("").List(ctx, metav1.ListOptions{LabelSelector: istioComponentLabel})
+		if err != nil {
+			if !errors.IsNotFound(err) {
+				skipped = append(skipped, gvr.Resource)
+			}
+			continue
+		}
+		for _, item := range list.Items {
+			labels := item.GetLabels()
+			objectRevision := labels["istio.io/rev"]
+			if objectRevision == "" {
+				objectRevision = defaultRevision
+			}
+			if objectRevision != revision && labels[istioComponentLabel] != "Base" {
+				continue
+			}
+			objects = append(objects, migrationObject{
+				gvr:       gvr,
+				namespace: item.GetNamespace(),
+				name:      item.GetName(),
+				component: labels[istioComponentLabel],
+				labels:    labels,
+			})
+		}
+	}
+	if len(skipped) > 0 {
+		return objects, fmt.Errorf("could not list %s; those resources are not adopted", strings.Join(skipped, ", "))
+	}
+	return objects, nil
+}
+
+// planMigrationReleases maps the istioctl components to Helm releases, in install order
+func (m *Manager) planMigrationReleases(ctx context.Context, repo config.ChartRepository, istioNamespace string, iop *unstructured.Unstructured, detection *IstioInstallDetection, values map[string]interface{}, objects []migrationObject) []MigrationRelease {
+	byComponent := make(map[string][]migrationObject)
+	for _, object := range objects {
+		byComponent[object.component] = append(byComponent[object.component], object)
+	}
+
+	global := map[string]interface{}{}
+	if detection.Hub != "" {
+		global["hub"] = detection.Hub
+	}
+	if tag, found, _ := unstructured.NestedFieldCopy(values, "global", "tag"); found {
+		global["tag"] = tag
+	}
+	var iopValues map[string]interface{}
+	if iop != nil {
+		iopValues, _, _ = unstructured.NestedMap(iop.Object, "spec", "values")
+	}
+
+	baseValues := map[string]interface{}{}
+	if detection.Revision != defaultRevision {
+		baseValues["defaultRevision"] = detection.Revision
+	}
+	istiodRelease := "istiod"
+	if detection.Revision != defaultRevision {
+		istiodRelease = "istiod-" + detection.Revision
+	}
+	releases := []MigrationRelease{
+		newMigrationRelease("istio-base", repo.ChartRef("base"), istioNamespace, "Base", baseValues, byComponent["Base"]),
+		newMigrationRelease(istiodRelease, repo.ChartRef("istiod"), istioNamespace, "Pilot", values, byComponent["Pilot"]),
+	}
+
+	// Daemonset components live wherever istioctl put them, which is where their release goes
+	for _, component := range []struct{ name, release, chart, daemonset string }{
+		{"Cni", "istio-cni", "cni", "istio-cni-node"},
+		{"Ztunnel", "ztunnel", "ztunnel", "ztunnel"},
+	} {
+		members := byComponent[component.name]
+		if len(members) == 0 {
+			continue
+		}
+		namespace := istioNamespace
+		for _, object := range members {
+			if object.gvr.Resource == "daemonsets" && object.name == component.daemonset {
+				namespace = object.namespace
+			}
+		}
+		componentValues := map[string]interface{}{"global": global}
+		if component.name == "Cni" {
+			if cni, ok := iopValues["cni"]; ok {
+				componentValues["cni"] = cni
+			}
+		} else {
+			if ztunnel, ok := iopValues["ztunnel"].(map[string]interface{}); ok {
+				for key, value := range ztunnel {
+					componentValues[key] = value
+				}
+			}
+			// The ztunnel chart reads the image settings at the top level
+			for key, value := range global {
+				componentValues[key] = value
+			}
+		}
+		if detection.Profile == "ambient" {
+			componentValues["profile"] = "ambient"
+		}
+		releases = append(releases, newMigrationRelease(component.release, repo.ChartRef(component.chart), namespace, component.name, componentValues, members))
+	}
+
+	// Every gateway is its own release named after its deployment, so the rendered names match
+	for _, component := range []string{"IngressGateways", "EgressGateways"} {
+		gateways := make(map[string][]migrationObject)
+		var names []string
+		for _, object := range byComponent[component] {
+			name := object.labels["app"]
+			if name == "" {
+				name = object.name
+			}
+			if _, ok := gateways[name]; !ok {
+				names = append(names, name)
+			}
+			gateways[name] = append(gateways[name], object)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			namespace := istioNamespace
+			for _, object := range gateways[name] {
+				if object.namespace != "" {
+					namespace = object.namespace
+				}
+			}
+			gatewayValues := m.gatewayMigrationValues(ctx, namespace, name)
+			if detection.Revision != defaultRevision {
+				gatewayValues["revision"] = detection.Revision
+			}
+			releases = append(releases, newMigrationRelease(name, repo.ChartRef("gateway"), namespace, component, gatewayValues, gateways[name]))
+		}
+	}
+	return releases
+}
+
+// newMigrationRelease builds a release plan and lists the resources it adopts
+func newMigrationRelease(name, chart, namespace, component string, values map[string]interface{}, objects []migrationObject) MigrationRelease {
+	release := MigrationRelease{
+		Release:   name,
+		Chart:     chart,
+		Namespace: namespace,
+		Component: component,
+		Values:    values,
+		objects:   objects,
+	}
+	for _, object := range objects {
+		release.Adopt = append(release.Adopt, object.String())
+	}
+	return release
+}
+
+// istiodMigrationValues converts the IstioOperator spec, or the running istiod when there is none, to
+// istiod chart values
+func istiodMigrationValues(iop *unstructured.Unstructured, istiod *appsv1.Deployment, detection *IstioInstallDetection) map[string]interface{} {
+	values := map[string]interface{}{}
+	if iop != nil {
+		if specValues, found, _ := unstructured.NestedMap(iop.Object, "spec", "values"); found {
+			values = specValues
+		}
+		// Those belong to the cni, ztunnel and gateway releases
+		delete(values, "cni")
+		delete(values, "ztunnel")
+		delete(values, "gateways")
+		if meshConfig, found, _ := unstructured.NestedMap(iop.Object, "spec", "meshConfig"); found {
+			values["meshConfig"] = meshConfig
+		}
+		if tag, found, _ := unstructured.NestedFieldCopy(iop.Object, "spec", "tag"); found {
+			_ = unstructured.SetNestedField(values, tag, "global", "tag")
+		}
+		pilot, _, _ := unstructured.NestedMap(iop.Object, "spec", "components", "pilot", "k8s")
+		for from, to := range map[string]string{
+			"replicaCount":   "replicaCount",
+			"resources":      "resources",
+			"nodeSelector":   "nodeSelector",
+			"tolerations":    "tolerations",
+			"affinity":       "affinity",
+			"podAnnotations": "podAnnotations",
+		} {
+			if value, ok := pilot[from]; ok {
+				_ = unstructured.SetNestedField(values, value, "pilot", to)
+			}
+		}
+		if min, found, _ := unstructured.NestedFieldCopy(pilot, "hpaSpec", "minReplicas"); found {
+			_ = unstructured.SetNestedField(values, min, "pilot", "autoscaleMin")
+		}
+		if max, found, _ := unstructured.NestedFieldCopy(pilot, "hpaSpec", "maxReplicas"); found {
+			_ = unstructured.SetNestedField(values, max, "pilot", "autoscaleMax")
+		}
+		if env, ok := pilot["env"].([]interface{}); ok {
+			envValues := map[string]interface{}{}
+			for _, entry := range env {
+				if variable, ok := entry.(map[string]interface{}); ok {
+					if name, ok := variable["name"].(string); ok && variable["value"] != nil {
+						envValues[name] = variable["value"]
+					}
+				}
+			}
+			if len(envValues) > 0 {
+				_ = unstructured.SetNestedField(values, envValues, "pilot", "env")
+			}
+		}
+	} else {
+		for _, container := range istiod.Spec.Template.Spec.Containers {
+			if container.Name != "discovery" {
+				continue
+			}
+			if _, tag, ok := strings.Cut(container.Image[strings.LastIndex(container.Image, "/")+1:], ":"); ok {
+				_ = unstructured.SetNestedField(values, tag, "global", "tag")
+			}
+			if resources, err := json.Marshal(container.Resources); err == nil && len(container.Resources.Requests)+len(container.Resources.Limits) > 0 {
+				var converted map[string]interface{}
+				if json.Unmarshal(resources, &converted) == nil {
+					_ = unstructured.SetNestedField(values, converted, "pilot", "resources")
+				}
+			}
+		}
+		if istiod.Spec.Replicas != nil {
+			_ = unstructured.SetNestedField(values, int64(*istiod.Spec.Replicas), "pilot", "replicaCount")
+		}
+	}
+	if detection.Hub != "" {
+		_ = unstructured.SetNestedField(values, detection.Hub, "global", "hub")
+	}
+	if _, ok := values["global"]; !ok {
+		values["global"] = map[string]interface{}{}
+	}
+	if detection.Revision != defaultRevision {
+		values["revision"] = detection.Revision
+	}
+	if detection.Profile == "ambient" {
+		values["profile"] = "ambient"
+	}
+	return values
+}
+
+// unmappedComponentSettings lists IstioOperator component settings that have no chart value, mainly
+// overlays, so they can be carried over by hand
+func unmappedComponentSettings(iop *unstructured.Unstructured) []string {
+	var notes []string
+	components, _, _ := unstructured.NestedMap(iop.Object, "spec", "components")
+	for name, component := range components {
+		var entries []interface{}
+		if list, ok := component.([]interface{}); ok {
+			entries = list
+		} else {
+			entries = []interface{}{component}
+		}
+		for _, entry := range entries {
+			settings, ok := entry.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if overlays, found, _ := unstructured.NestedSlice(settings, "k8s", "overlays"); found && len(overlays) > 0 {
+				notes = append(notes, fmt.Sprintf("components.%s has %d k8s overlays, which Helm charts cannot express; reapply them as a post-renderer or separate patch", name, len(overlays)))
+			}
+		}
+	}
+	sort.Strings(notes)
+	return notes
+}
+
+// gatewayMigrationValues reads gateway chart values from a running istioctl gateway
+func (m *Manager) gatewayMigrationValues(ctx context.Context, namespace, name string) map[string]interface{} {
+	values := map[string]interface{}{}
+	if deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		// The chart derives its selector from these labels, and a Deployment selector cannot change
+		if deployment.Spec.Selector != nil {
+			labels := map[string]interface{}{}
+			for key, value := range deployment.Spec.Selector.MatchLabels {
+				labels[key] = value
+			}
+			values["labels"] = labels
+		}
+		if deployment.Spec.Replicas != nil {
+			values["replicaCount"] = int64(*deployment.Spec.Replicas)
+		}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name == "istio-proxy" && len(container.Resources.Requests)+len(container.Resources.Limits) > 0 {
+				if resources, err := json.Marshal(container.Resources); err == nil {
+					var converted map[string]interface{}
+					if json.Unmarshal(resources, &converted) == nil {
+						values["resources"] = converted
+					}
+				}
+			}
+		}
+	}
+	if hpa, err := m.k8sClient.Kubernetes.AutoscalingV2().HorizontalPodAutoscalers(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		autoscaling := map[string]interface{}{"enabled": true, "maxReplicas": int64(hpa.Spec.MaxReplicas)}
+		if hpa.Spec.MinReplicas != nil {
+			autoscaling["minReplicas"] = int64(*hpa.Spec.MinReplicas)
+		}
+		values["autoscaling"] = autoscaling
+	} else {
+		values["autoscaling"] = map[string]interface{}{"enabled": false}
+	}
+	if service, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{}); err == nil {
+		serviceValues := map[string]interface{}{"type": string(service.Spec.Type)}
+		var ports []interface{}
+		for _, port := range service.Spec.Ports {
+			entry := map[string]interface{}{
+				"name":       port.Name,
+				"port":       int64(port.Port),
+				"protocol":   string(port.Protocol),
+				"targetPort": int64(port.TargetPort.IntValue()),
+			}
+			if port.NodePort != 0 && service.Spec.Type != corev1.ServiceTypeClusterIP {
+				entry["nodePort"] = int64(port.NodePort)
+			}
+			ports = append(ports, entry)
+		}
+		serviceValues["ports"] = ports
+		if len(service.Annotations) > 0 {
+			annotations := map[string]interface{}{}
+			for key, value := range service.Annotations {
+				if !strings.HasPrefix(key, "meta.helm.sh/") && key != "kubectl.kubernetes.io/last-applied-configuration" {
+					annotations[key] = value
+				}
+			}
+			serviceValues["annotations"] = annotations
+		}
+		if service.Spec.LoadBalancerIP != "" {
+			serviceValues["loadBalancerIP"] = service.Spec.LoadBalancerIP
+		}
+		if service.Spec.ExternalTrafficPolicy != "" {
+			serviceValues["externalTrafficPolicy"] = string(service.Spec.ExternalTrafficPolicy)
+		}
+		values["service"] = serviceValues
+	}
+	return values
+}
+
+// adoptForHelm labels and annotates a release's existing resources so Helm takes them over instead
+// of refusing to install over them
+func (m *Manager) adoptForHelm(ctx context.Context, release MigrationRelease) error {
+	patch, _ := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"labels": map[string]string{"app.kubernetes.io/managed-by": "Helm"},
+			"annotations": map[string]string{
+				"meta.helm.sh/release-name":      release.Release,
+				"meta.helm.sh/release-namespace": release.Namespace,
+			},
+		},
+	})
+	for _, object := range release.objects {
+		var err error
+		if object.namespace == "" {
+			_, err = m.k8sClient.Dynamic.Resource(object.gvr).Patch(ctx, object.name, types.MergePatchType, patch, metav1.PatchOptions{})
+		} else {
+			_, err = m.k8sClient.Dynamic.Resource(object.gvr).Namespace(object.namespace).Patch(ctx, object.name, types.MergePatchType, patch, metav1.PatchOptions{})
+		}
+		if err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("failed to adopt %s: %w", object, err)
+		}
+	}
+	return nil
+}
+
+// migrationCommands spells out the Helm plan as the commands that would carry it out by hand
+func migrationCommands(releases []MigrationRelease, version string) []string {
+	var commands []string
+	for _, release := range releases {
+		byNamespace := make(map[string][]string)
+		var namespaces []string
+		for _, object := range release.objects {
+			resource := object.gvr.Resource
+			if object.gvr.Group != "" {
+				resource += "." + object.gvr.Group
+			}
+			if _, ok := byNamespace[object.namespace]; !ok {
+				namespaces = append(namespaces, object.namespace)
+			}
+			byNamespace[object.namespace] = append(byNamespace[object.namespace], resource+"/"+object.name)
+		}
+		sort.Strings(namespaces)
+		for _, namespace := range namespaces {
+			targets := strings.Join(byNamespace[namespace], " ")
+			if namespace != "" {
+				targets += " -n " + namespace
+			}
+			commands = append(commands,
+				fmt.Sprintf("kubectl label %s app.kubernetes.io/managed-by=Helm --overwrite", targets),
+				fmt.Sprintf("kubectl annotate %s meta.helm.sh/release-name=%s meta.helm.sh/release-namespace=%s --overwrite", targets, release.Release, release.Namespace))
+		}
+		commands = append(commands, fmt.Sprintf("helm install %s %s -n %s --version %s --wait -f %s-values.yaml  # values listed under releases",
+			release.Release, release.Chart, release.Namespace, version, release.Release))
+	}
+	return commands
+}
+
+// sailIstioGVR picks the Istio resource version the installed Sail operator serves
+func (m *Manager) sailIstioGVR() (schema.GroupVersionResource, error) {
+	for _, gvr := range sailIstioGVRs {
+		resources, err := m.k8sClient.Kubernetes.Discovery().ServerResourcesForGroupVersion(gvr.GroupVersion().String())
+		if err != nil {
+			continue
+		}
+		for _, resource := range resources.APIResources {
+			if resource.Name == gvr.Resource {
+				return gvr, nil
+			}
+		}
+	}
+	return sailIstioGVRs[0], fmt.Errorf("the cluster does not serve the sailoperator.io Istio resource")
+}
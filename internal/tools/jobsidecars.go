@@ -0,0 +1,360 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// StuckJobPod is a Job pod whose containers finished while istio-proxy keeps running
+type StuckJobPod struct {
+	Pod           string   `json:"pod"`
+	AppContainers []string `json:"app_containers"` // name and exit code of each finished container
+	StuckFor      string   `json:"stuck_for"`
+	ProxyExited   bool     `json:"proxy_exited,omitempty"` // quitquitquit was sent and accepted
+}
+
+// JobSidecarReport is the sidecar state of one Job
+type JobSidecarReport struct {
+	Job           string        `json:"job"`
+	Namespace     string        `json:"namespace"`
+	CronJob       string        `json:"cronjob,omitempty"`
+	Active        int32         `json:"active"`
+	Succeeded     int32         `json:"succeeded"`
+	Failed        int32         `json:"failed"`
+	NativeSidecar bool          `json:"native_sidecar"`
+	StuckPods     []StuckJobPod `json:"stuck_pods,omitempty"`
+}
+
+// JobSidecarRemediation is one way to stop istio-proxy from holding Jobs open
+type JobSidecarRemediation struct {
+	Option      string `json:"option"`
+	Description string `json:"description"`
+	Available   bool   `json:"available"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// JobSidecarDiagnosis is the result of checking Jobs for sidecars that never exit
+type JobSidecarDiagnosis struct {
+	Namespace              string                  `json:"namespace"`
+	KubernetesVersion      string                  `json:"kubernetes_version,omitempty"`
+	NativeSidecarSupported bool                    `json:"native_sidecar_supported"`
+	Jobs                   []JobSidecarReport      `json:"jobs"`
+	StuckJobs              int                     `json:"stuck_jobs"`
+	Remediations           []JobSidecarRemediation `json:"remediations,omitempty"`
+	Apply                  string                  `json:"apply,omitempty"`
+	DryRun                 bool                    `json:"dry_run,omitempty"`
+	Applied                []string                `json:"applied,omitempty"`
+	Issues                 []string                `json:"issues,omitempty"`
+	Notes                  []string                `json:"notes,omitempty"`
+	Timestamp              time.Time               `json:"timestamp"`
+}
+
+// diagnoseJobSidecarsParams are the arguments of diagnose_job_sidecars
+type diagnoseJobSidecarsParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: all namespaces
+	Job            string `json:"job,omitempty"`             // default: all Jobs
+	Apply          string `json:"apply,omitempty"`           // quitquitquit, native or exclude, default: diagnose only
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+	Revision       string `json:"revision,omitempty"`        // control plane revision
+	DryRun         bool   `json:"dry_run,omitempty"`         // only report the changes the remediation would make
+}
+
+// DiagnoseJobSidecars finds Jobs whose pods stay NotReady because istio-proxy keeps running after the
+// job's containers finished, explains the remediation options and optionally applies one
+func (m *Manager) DiagnoseJobSidecars(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params diagnoseJobSidecarsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Apply != "" && params.Apply != "quitquitquit" && params.Apply != "native" && params.Apply != "exclude" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid apply %q: use quitquitquit, native or exclude", params.Apply),
+				},
+			},
+		}, nil
+	}
+	if params.Job != "" && params.Namespace == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "job requires namespace",
+				},
+			},
+		}, nil
+	}
+
+	result := &JobSidecarDiagnosis{
+		Namespace: params.Namespace,
+		Apply:     params.Apply,
+		DryRun:    params.DryRun,
+		Timestamp: time.Now(),
+	}
+	if result.Namespace == "" {
+		result.Namespace = "all"
+	}
+
+	mesh, issues := m.startupOrderingMesh(ctx, params.IstioNamespace, params.Revision)
+	result.KubernetesVersion = mesh.KubernetesVersion
+	result.NativeSidecarSupported = mesh.NativeSidecarSupported
+	result.Issues = append(result.Issues, issues...)
+
+	jobs, err := m.k8sClient.Kubernetes.BatchV1().Jobs(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list jobs: %v", err),
+				},
+			},
+		}, nil
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Index sidecar pods by their Job
+	jobPods := make(map[string][]*corev1.Pod)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		owner := metav1.GetControllerOf(pod)
+		if owner == nil || owner.Kind != "Job" || !podHasSidecar(pod) {
+			continue
+		}
+		key := pod.Namespace + "/" + owner.Name
+		jobPods[key] = append(jobPods[key], pod)
+	}
+
+	stuckPods := make(map[string][]*corev1.Pod)
+	for _, job := range jobs.Items {
+		if params.Job != "" && job.Name != params.Job {
+			continue
+		}
+		key := job.Namespace + "/" + job.Name
+		if len(jobPods[key]) == 0 {
+			continue
+		}
+		report := JobSidecarReport{
+			Job:           job.Name,
+			Namespace:     job.Namespace,
+			Active:        job.Status.Active,
+			Succeeded:     job.Status.Succeeded,
+			Failed:        job.Status.Failed,
+			NativeSidecar: true,
+		}
+		if owner := metav1.GetControllerOf(&job); owner != nil && owner.Kind == "CronJob" {
+			report.CronJob = owner.Name
+		}
+		for _, pod := range jobPods[key] {
+			_, native := sidecarStartupOrdering(pod)
+			report.NativeSidecar = report.NativeSidecar && native
+			if stuck := stuckJobPod(pod); stuck != nil {
+				report.StuckPods = append(report.StuckPods, *stuck)
+				stuckPods[key] = append(stuckPods[key], pod)
+			}
+		}
+		if len(report.StuckPods) > 0 {
+			result.StuckJobs++
+		}
+		result.Jobs = append(result.Jobs, report)
+	}
+	sort.Slice(result.Jobs, func(i, j int) bool {
+		a, b := result.Jobs[i], result.Jobs[j]
+		if (len(a.StuckPods) > 0) != (len(b.StuckPods) > 0) {
+			return len(a.StuckPods) > 0
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Job < b.Job
+	})
+	if params.Job != "" && len(result.Jobs) == 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("Job %s/%s has no pods with an istio-proxy sidecar", params.Namespace, params.Job))
+	}
+
+	result.Remediations = jobSidecarRemediations(result)
+
+	switch params.Apply {
+	case "":
+	case "quitquitquit":
+		for i := range result.Jobs {
+			report := &result.Jobs[i]
+			for j, pod := range stuckPods[report.Namespace+"/"+report.Job] {
+				if params.DryRun {
+					result.Applied = append(result.Applied, fmt.Sprintf("Would ask istio-proxy in %s/%s to exit", pod.Namespace, pod.Name))
+					continue
+				}
+				if _, err := m.execCommandInPod(ctx, pod.Namespace, pod.Name, "istio-proxy", []string{"pilot-agent", "request", "POST", "quitquitquit"}); err != nil {
+					result.Issues = append(result.Issues, fmt.Sprintf("Failed to stop istio-proxy in %s/%s: %v", pod.Namespace, pod.Name, err))
+					continue
+				}
+				report.StuckPods[j].ProxyExited = true
+				result.Applied = append(result.Applied, fmt.Sprintf("Asked istio-proxy in %s/%s to exit", pod.Namespace, pod.Name))
+			}
+		}
+		if len(result.Applied) > 0 {
+			result.Notes = append(result.Notes, "quitquitquit only releases the current runs; apply native or exclude so future runs complete on their own")
+		}
+	default:
+		if params.Apply == "native" && !result.NativeSidecarSupported {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Native sidecars need Kubernetes 1.%d or later (cluster runs %s); use quitquitquit or exclude instead", nativeSidecarMinMinor, result.KubernetesVersion),
+					},
+				},
+			}, nil
+		}
+		// Job pod templates are immutable, so the fix goes on the CronJobs that create new Jobs
+		cronJobs := make(map[string]bool)
+		for _, report := range result.Jobs {
+			if report.NativeSidecar || (len(report.StuckPods) == 0 && params.Job == "") {
+				continue
+			}
+			if report.CronJob == "" {
+				result.Notes = append(result.Notes, fmt.Sprintf("Job %s/%s was not created by a CronJob and its pod template is immutable; add the change to its manifest and recreate it", report.Namespace, report.Job))
+				continue
+			}
+			key := report.Namespace + "/" + report.CronJob
+			if cronJobs[key] {
+				continue
+			}
+			cronJobs[key] = true
+			if params.DryRun {
+				result.Applied = append(result.Applied, fmt.Sprintf("Would set %s on CronJob %s", jobSidecarFixDescription(params.Apply), key))
+				continue
+			}
+			err := m.updateWorkloadPodTemplate(ctx, report.Namespace, "CronJob/"+report.CronJob, func(template *corev1.PodTemplateSpec) error {
+				if params.Apply == "native" {
+					return applyStartupOrderingFix(template, "native")
+				}
+				if template.Labels == nil {
+					template.Labels = make(map[string]string)
+				}
+				template.Labels["sidecar.istio.io/inject"] = "false"
+				return nil
+			})
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Failed to update CronJob %s: %v", key, err))
+				continue
+			}
+			result.Applied = append(result.Applied, fmt.Sprintf("Set %s on CronJob %s; the next scheduled run picks it up", jobSidecarFixDescription(params.Apply), key))
+		}
+		if result.StuckJobs > 0 {
+			result.Notes = append(result.Notes, "Runs already stuck keep waiting; apply quitquitquit to release them")
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// stuckJobPod reports a running pod whose containers have all exited except a classic istio-proxy sidecar
+func stuckJobPod(pod *corev1.Pod) *StuckJobPod {
+	if pod.Status.Phase != corev1.PodRunning {
+		return nil
+	}
+	stuck := &StuckJobPod{Pod: pod.Name}
+	proxyRunning := false
+	var finished time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name == "istio-proxy" {
+			proxyRunning = status.State.Running != nil
+			continue
+		}
+		terminated := status.State.Terminated
+		if terminated == nil {
+			return nil
+		}
+		stuck.AppContainers = append(stuck.AppContainers, fmt.Sprintf("%s: exit %d", status.Name, terminated.ExitCode))
+		if terminated.FinishedAt.Time.After(finished) {
+			finished = terminated.FinishedAt.Time
+		}
+	}
+	if !proxyRunning || len(stuck.AppContainers) == 0 {
+		return nil
+	}
+	if !finished.IsZero() {
+		stuck.StuckFor = time.Since(finished).Round(time.Second).String()
+	}
+	return stuck
+}
+
+// jobSidecarRemediations lists the remediation options and whether they apply to this cluster
+func jobSidecarRemediations(result *JobSidecarDiagnosis) []JobSidecarRemediation {
+	quit := JobSidecarRemediation{
+		Option:      "quitquitquit",
+		Description: "Ask istio-proxy in stuck pods to exit (pilot-agent request POST quitquitquit) so the current runs complete; jobs can also call http://localhost:15020/quitquitquit themselves when done",
+		Available:   result.StuckJobs > 0,
+	}
+	if !quit.Available {
+		quit.Reason = "no stuck job pods"
+	}
+	native := JobSidecarRemediation{
+		Option:      "native",
+		Description: fmt.Sprintf("Run istio-proxy as a native sidecar (%s annotation on the CronJob pod template, or mesh-wide with audit_sidecar_startup fix=native); Kubernetes stops it when the job's containers exit", nativeSidecarAnnotation),
+		Available:   result.NativeSidecarSupported,
+	}
+	if !native.Available {
+		native.Reason = fmt.Sprintf("requires Kubernetes 1.%d or later", nativeSidecarMinMinor)
+	}
+	exclude := JobSidecarRemediation{
+		Option:      "exclude",
+		Description: "Disable injection for the job (sidecar.istio.io/inject=false label on the CronJob pod template); the job runs outside the mesh without mTLS, telemetry or authorization policy",
+		Available:   true,
+	}
+	return []JobSidecarRemediation{quit, native, exclude}
+}
+
+// jobSidecarFixDescription names the pod template change a remediation makes
+func jobSidecarFixDescription(apply string) string {
+	if apply == "native" {
+		return nativeSidecarAnnotation + "=true"
+	}
+	return "sidecar.istio.io/inject=false"
+}
@@ -14,6 +14,13 @@ import (
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+const (
+	// maxLogFollow caps how long get_pod_logs follows a container's log
+	maxLogFollow = 10 * time.Minute
+	// logFollowBatch is the number of followed lines sent to the caller at once; quieter logs are sent every second
+	logFollowBatch = 20
+)
+
 // LogEntry represents a single log entry
 type LogEntry struct {
 	Timestamp time.Time `json:"timestamp"`
@@ -33,22 +40,28 @@ type LogResult struct {
 	Entries   []LogEntry `json:"entries,omitempty"`
 	RawLogs   string     `json:"raw_logs,omitempty"`
 	Truncated bool       `json:"truncated,omitempty"`
+	StoppedBy string     `json:"stopped_by,omitempty"` // follow mode: max_duration, max_lines or stream_ended
+	Pagination
+}
+
+// getPodLogsParams are the arguments of get_pod_logs
+type getPodLogsParams struct {
+	PodName    string `json:"pod_name"`
+	Namespace  string `json:"namespace,omitempty"`
+	Container  string `json:"container,omitempty"`
+	Lines      int64  `json:"lines,omitempty"`      // number of lines to retrieve
+	Since      string `json:"since,omitempty"`      // duration like "1h", "30m"
+	Follow     bool   `json:"follow,omitempty"`     // stream new lines to the caller as they are written
+	Duration   string `json:"duration,omitempty"`   // how long to follow, default: 1m, max: 10m
+	Previous   bool   `json:"previous,omitempty"`   // get logs from previous container instance
+	Timestamps bool   `json:"timestamps,omitempty"` // include timestamps
+	ParseLogs  bool   `json:"parse_logs,omitempty"` // attempt to parse structured logs
+	MaxLines   int    `json:"max_lines,omitempty"`  // maximum lines to return (default: 1000)
 }
 
 // GetPodLogs retrieves logs from a specific pod
-func (m *Manager) GetPodLogs(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		PodName    string `json:"pod_name"`
-		Namespace  string `json:"namespace,omitempty"`
-		Container  string `json:"container,omitempty"`
-		Lines      int64  `json:"lines,omitempty"`      // number of lines to retrieve
-		Since      string `json:"since,omitempty"`      // duration like "1h", "30m"
-		Follow     bool   `json:"follow,omitempty"`     // stream logs (not recommended for MCP)
-		Previous   bool   `json:"previous,omitempty"`   // get logs from previous container instance
-		Timestamps bool   `json:"timestamps,omitempty"` // include timestamps
-		ParseLogs  bool   `json:"parse_logs,omitempty"` // attempt to parse structured logs
-		MaxLines   int    `json:"max_lines,omitempty"`  // maximum lines to return (default: 1000)
-	}
+func (m *Manager) GetPodLogs(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getPodLogsParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -72,9 +85,36 @@ func (m *Manager) GetPodLogs(args json.RawMessage) (*CallToolResult, error) {
 	if params.MaxLines == 0 {
 		params.MaxLines = 1000
 	}
+	if params.Duration == "" {
+		params.Duration = "1m"
+	}
 	params.Timestamps = true // Always include timestamps for better debugging
 
-	ctx := context.Background()
+	pages, err := m.newPager("get_pod_logs", args)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	followFor, err := time.ParseDuration(params.Duration)
+	if err != nil || followFor <= 0 || followFor > maxLogFollow {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %q: must be a positive duration of at most %s", params.Duration, maxLogFollow),
+				},
+			},
+		}, nil
+	}
 
 	// Get pod to validate it exists and get container info
 	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
@@ -110,7 +150,7 @@ func (m *Manager) GetPodLogs(args json.RawMessage) (*CallToolResult, error) {
 	// Build log options
 	logOptions := &corev1.PodLogOptions{
 		Container:  params.Container,
-		Follow:     false, // Never follow in MCP context
+		Follow:     params.Follow && !params.Previous, // a terminated container's log has nothing to follow
 		Previous:   params.Previous,
 		Timestamps: params.Timestamps,
 		TailLines:  &params.Lines,
@@ -134,6 +174,32 @@ func (m *Manager) GetPodLogs(args json.RawMessage) (*CallToolResult, error) {
 		logOptions.SinceTime = &sinceTime
 	}
 
+	// A later page carries on after the last line of the previous one, whatever the tail or since were
+	if !pages.firstPage() && !logOptions.Follow {
+		after, err := time.Parse(time.RFC3339Nano, pages.cursor.After)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "Invalid parameters: invalid cursor",
+					},
+				},
+			}, nil
+		}
+		sinceTime := metav1.NewTime(after)
+		logOptions.SinceTime = &sinceTime
+		logOptions.TailLines = nil
+	}
+
+	// Following ends after the duration even if the container keeps logging
+	if logOptions.Follow {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, followFor)
+		defer cancel()
+	}
+
 	// Get logs
 	req := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).GetLogs(params.PodName, logOptions)
 	logs, err := req.Stream(ctx)
@@ -151,7 +217,12 @@ func (m *Manager) GetPodLogs(args json.RawMessage) (*CallToolResult, error) {
 	defer logs.Close()
 
 	// Read and process logs
-	result, err := m.processLogs(logs, params.PodName, params.Namespace, params.Container, params.ParseLogs, params.MaxLines)
+	var result *LogResult
+	if logOptions.Follow {
+		result = m.followLogs(ctx, logs, params.PodName, params.Namespace, params.Container, params.ParseLogs, params.MaxLines)
+	} else {
+		result, err = m.processLogs(logs, params.PodName, params.Namespace, params.Container, params.ParseLogs, params.MaxLines, pages)
+	}
 	if err != nil {
 		return &CallToolResult{
 			IsError: true,
@@ -175,15 +246,20 @@ func (m *Manager) GetPodLogs(args json.RawMessage) (*CallToolResult, error) {
 	}, nil
 }
 
+// getIstioProxyLogsParams are the arguments of get_istio_proxy_logs
+type getIstioProxyLogsParams struct {
+	PodName   string `json:"pod_name"`
+	Namespace string `json:"namespace,omitempty"`
+	Lines     int64  `json:"lines,omitempty"`
+	Since     string `json:"since,omitempty"`
+	LogLevel  string `json:"log_level,omitempty"` // filter by log level
+	Cursor    string `json:"cursor,omitempty"`
+	PageSize  int    `json:"page_size,omitempty"`
+}
+
 // GetIstioProxyLogs retrieves Istio sidecar proxy logs from a pod
-func (m *Manager) GetIstioProxyLogs(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		PodName   string `json:"pod_name"`
-		Namespace string `json:"namespace,omitempty"`
-		Lines     int64  `json:"lines,omitempty"`
-		Since     string `json:"since,omitempty"`
-		LogLevel  string `json:"log_level,omitempty"` // filter by log level
-	}
+func (m *Manager) GetIstioProxyLogs(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getIstioProxyLogsParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -219,8 +295,16 @@ func (m *Manager) GetIstioProxyLogs(args json.RawMessage) (*CallToolResult, erro
 		proxyLogsArgs["since"] = params.Since
 	}
 
+	// Cursors are issued for the get_pod_logs call made here, so they are passed through as they are
+	if params.Cursor != "" {
+		proxyLogsArgs["cursor"] = params.Cursor
+	}
+	if params.PageSize != 0 {
+		proxyLogsArgs["page_size"] = params.PageSize
+	}
+
 	argsJSON, _ := json.Marshal(proxyLogsArgs)
-	result, err := m.GetPodLogs(argsJSON)
+	result, err := m.GetPodLogs(ctx, argsJSON)
 	if err != nil {
 		return result, err
 	}
@@ -234,16 +318,19 @@ func (m *Manager) GetIstioProxyLogs(args json.RawMessage) (*CallToolResult, erro
 	return result, nil
 }
 
+// execPodCommandParams are the arguments of exec_pod_command
+type execPodCommandParams struct {
+	PodName     string   `json:"pod_name"`
+	Namespace   string   `json:"namespace,omitempty"`
+	Container   string   `json:"container,omitempty"`
+	Command     []string `json:"command"`
+	Interactive bool     `json:"interactive,omitempty"` // not supported in MCP
+	Timeout     int      `json:"timeout,omitempty"`     // seconds
+}
+
 // ExecPodCommand executes a command in a pod and returns the output
-func (m *Manager) ExecPodCommand(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		PodName     string   `json:"pod_name"`
-		Namespace   string   `json:"namespace,omitempty"`
-		Container   string   `json:"container,omitempty"`
-		Command     []string `json:"command"`
-		Interactive bool     `json:"interactive,omitempty"` // not supported in MCP
-		Timeout     int      `json:"timeout,omitempty"`     // seconds
-	}
+func (m *Manager) ExecPodCommand(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params execPodCommandParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -263,7 +350,7 @@ func (m *Manager) ExecPodCommand(args json.RawMessage) (*CallToolResult, error)
 	}
 	if params.Container == "" {
 		// Try to determine the main container
-		ctx := context.Background()
+		ctx := ctx
 		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
 		if err != nil {
 			return &CallToolResult{
@@ -303,7 +390,6 @@ func (m *Manager) ExecPodCommand(args json.RawMessage) (*CallToolResult, error)
 		}, nil
 	}
 
-	ctx := context.Background()
 	if params.Timeout > 0 {
 		var cancel context.CancelFunc
 		ctx, cancel = context.WithTimeout(ctx, time.Duration(params.Timeout)*time.Second)
@@ -341,22 +427,57 @@ func (m *Manager) ExecPodCommand(args json.RawMessage) (*CallToolResult, error)
 }
 
 // processLogs processes log stream and returns structured result
-func (m *Manager) processLogs(logs io.Reader, podName, namespace, container string, parseLogs bool, maxLines int) (*LogResult, error) {
+func (m *Manager) processLogs(logs io.Reader, podName, namespace, container string, parseLogs bool, maxLines int, pages *pager) (*LogResult, error) {
 	result := &LogResult{
 		Pod:       podName,
 		Namespace: namespace,
 		Container: container,
 	}
 
+	// Lines of a later page start after the cursor's timestamp, less the lines at that very timestamp already returned
+	var after time.Time
+	skip := pages.cursor.Offset
+	if pages.cursor.After != "" {
+		after, _ = time.Parse(time.RFC3339Nano, pages.cursor.After)
+	}
+
 	var rawLogs strings.Builder
 	var entries []LogEntry
 	scanner := bufio.NewScanner(logs)
 	lineCount := 0
+	var lastTimestamp string
+	sameTimestamp := 0
 
-	for scanner.Scan() && lineCount < maxLines {
+	for lineCount < maxLines && scanner.Scan() {
 		line := scanner.Text()
+		timestamp, _, _ := strings.Cut(line, " ")
+		if !after.IsZero() {
+			if ts, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+				if ts.Before(after) || (ts.Equal(after) && skip > 0) {
+					if ts.Equal(after) {
+						skip--
+					}
+					continue
+				}
+			}
+			after = time.Time{}
+		}
+
+		if !pages.fits(lineCount, rawLogs.Len(), len(line)+1) {
+			result.NextCursor = pages.encode(pageCursor{After: lastTimestamp, Offset: sameTimestamp})
+			break
+		}
 		rawLogs.WriteString(line + "\n")
 		lineCount++
+		switch {
+		case timestamp == lastTimestamp:
+			sameTimestamp++
+		case timestamp == pages.cursor.After:
+			// Earlier pages returned the first lines at the cursor's timestamp
+			lastTimestamp, sameTimestamp = timestamp, pages.cursor.Offset+1
+		default:
+			lastTimestamp, sameTimestamp = timestamp, 1
+		}
 
 		if parseLogs {
 			entry := m.parseLogLine(line, podName, namespace, container)
@@ -381,6 +502,78 @@ func (m *Manager) processLogs(logs io.Reader, podName, namespace, container stri
 	return result, nil
 }
 
+// followLogs reads a followed log until ctx ends, maxLines lines were read or the container stops, sending the lines
+// to the caller in batches as they arrive; the result holds every line read
+func (m *Manager) followLogs(ctx context.Context, logs io.Reader, podName, namespace, container string, parseLogs bool, maxLines int) *LogResult {
+	result := &LogResult{
+		Pod:       podName,
+		Namespace: namespace,
+		Container: container,
+	}
+
+	// The stream only returns when a line arrives, so lines are read apart from the batching below
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(logs)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var rawLogs strings.Builder
+	var batch []string
+	flush := func() {
+		if len(batch) > 0 {
+			reportProgress(ctx, strings.Join(batch, "\n"))
+			batch = batch[:0]
+		}
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for result.StoppedBy == "" {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// The API server ends the stream when the container exits, or when ctx ends mid-read
+				result.StoppedBy = "stream_ended"
+				if ctx.Err() != nil {
+					result.StoppedBy = "max_duration"
+				}
+				break
+			}
+			rawLogs.WriteString(line + "\n")
+			result.Lines++
+			if parseLogs {
+				if entry := m.parseLogLine(line, podName, namespace, container); entry != nil {
+					result.Entries = append(result.Entries, *entry)
+				}
+			}
+			batch = append(batch, line)
+			if len(batch) >= logFollowBatch {
+				flush()
+			}
+			if result.Lines >= maxLines {
+				result.StoppedBy = "max_lines"
+				result.Truncated = true
+			}
+		case <-ticker.C:
+			flush()
+		case <-ctx.Done():
+			result.StoppedBy = "max_duration"
+		}
+	}
+	flush()
+
+	result.RawLogs = rawLogs.String()
+	return result
+}
+
 // parseLogLine attempts to parse a log line into structured format
 func (m *Manager) parseLogLine(line, podName, namespace, container string) *LogEntry {
 	// Basic parsing - in production, you'd want more sophisticated parsing
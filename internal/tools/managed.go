@@ -0,0 +1,487 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+const (
+	// managedByLabel marks resources meshpilot created, so they can be cleaned up without touching
+	// anything the user created
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedByValue = "meshpilot"
+	// managedToolLabel names the tool that created a resource and managedCreatedAnnotation records when
+	managedToolLabel         = "meshpilot.io/tool"
+	managedCreatedAnnotation = "meshpilot.io/created-at"
+	// debugContainerPrefix and captureContainerPrefix are the name prefixes of the ephemeral containers
+	// meshpilot attaches
+	debugContainerPrefix   = "debug-iptables-"
+	captureContainerPrefix = "debug-capture-"
+)
+
+// managedKind is a kind of resource meshpilot creates
+type managedKind struct {
+	kind       string
+	gvr        schema.GroupVersionResource
+	namespaced bool
+}
+
+// managedKinds lists every kind meshpilot labels at creation time, namespaces last
+var managedKinds = []managedKind{
+	{"Deployment", schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}, true},
+	{"Service", schema.GroupVersionResource{Version: "v1", Resource: "services"}, true},
+	{"ServiceAccount", schema.GroupVersionResource{Version: "v1", Resource: "serviceaccounts"}, true},
+	{"ConfigMap", schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}, true},
+	{"NetworkPolicy", schema.GroupVersionResource{Group: "networking.k8s.io", Version: "v1", Resource: "networkpolicies"}, true},
+	{"VirtualService", virtualServiceGVR, true},
+	{"DestinationRule", destinationRuleGVR, true},
+	{"Gateway", istioGatewayGVR, true},
+	{"HTTPRoute", httpRouteGVR, true},
+	{"GRPCRoute", grpcRouteGVR, true},
+	// Qualified by group, since the Istio Gateway above shares the kind
+	{"Gateway.gateway.networking.k8s.io", gatewayGVR, true},
+	{"ServiceEntry", serviceEntryGVR, true},
+	{"AuthorizationPolicy", authorizationPolicyGVRs[1], true},
+	{"PeerAuthentication", peerAuthenticationGVR, true},
+	{"Telemetry", telemetryGVR, true},
+	{"ClusterSPIFFEID", clusterSPIFFEIDGVR, false},
+	{"Namespace", schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}, false},
+}
+
+// ManagedResource identifies a resource meshpilot created
+type ManagedResource struct {
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+	Tool      string `json:"tool,omitempty"`
+	CreatedAt string `json:"created_at,omitempty"`
+}
+
+// ManagedInventory lists the resources meshpilot created across the cluster
+type ManagedInventory struct {
+	Namespace string            `json:"namespace"`
+	Total     int               `json:"total"`
+	ByKind    map[string]int    `json:"by_kind"`
+	ByTool    map[string]int    `json:"by_tool"`
+	Resources []ManagedResource `json:"resources"`
+	Notes     []string          `json:"notes,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// managingToolKey carries the name of the tool creating resources through its context
+type managingToolKey struct{}
+
+// withManagingTool records which tool is creating resources, for markManaged to label them with
+func withManagingTool(ctx context.Context, tool string) context.Context {
+	return context.WithValue(ctx, managingToolKey{}, tool)
+}
+
+// CleanupResult is the result of removing the resources meshpilot created
+type CleanupResult struct {
+	DryRun          bool              `json:"dry_run,omitempty"`
+	Namespace       string            `json:"namespace"`
+	Removed         []ManagedResource `json:"removed"`
+	MonitorsStopped []string          `json:"monitors_stopped,omitempty"`
+	DebugContainers []string          `json:"debug_containers,omitempty"` // <namespace>/<pod>/<container>; ephemeral containers cannot be removed
+	Failed          []string          `json:"failed,omitempty"`
+	Notes           []string          `json:"notes,omitempty"`
+	Timestamp       time.Time         `json:"timestamp"`
+}
+
+// markManaged labels an object as created by meshpilot and by the tool in ctx, and stamps the creation
+// time unless the object already carries one; labels and annotations are copied so maps shared with
+// other objects are left alone
+func markManaged(ctx context.Context, obj metav1.Object) {
+	labels := make(map[string]string, len(obj.GetLabels())+2)
+	for key, value := range obj.GetLabels() {
+		labels[key] = value
+	}
+	labels[managedByLabel] = managedByValue
+	if tool, ok := ctx.Value(managingToolKey{}).(string); ok {
+		labels[managedToolLabel] = tool
+	}
+	obj.SetLabels(labels)
+
+	annotations := make(map[string]string, len(obj.GetAnnotations())+1)
+	for key, value := range obj.GetAnnotations() {
+		annotations[key] = value
+	}
+	if annotations[managedCreatedAnnotation] == "" {
+		annotations[managedCreatedAnnotation] = time.Now().UTC().Format(time.RFC3339)
+	}
+	obj.SetAnnotations(annotations)
+}
+
+// keepManaged carries the meshpilot labels and creation time of an existing object over to the object about
+// to be applied over it, so a re-apply neither drops them nor restamps them; objects meshpilot did not create
+// stay unlabeled
+func keepManaged(obj, existing metav1.Object) {
+	if existing.GetLabels()[managedByLabel] != managedByValue {
+		return
+	}
+	labels := make(map[string]string, len(obj.GetLabels())+2)
+	for key, value := range obj.GetLabels() {
+		labels[key] = value
+	}
+	labels[managedByLabel] = managedByValue
+	if tool := existing.GetLabels()[managedToolLabel]; tool != "" {
+		labels[managedToolLabel] = tool
+	}
+	obj.SetLabels(labels)
+
+	if createdAt := existing.GetAnnotations()[managedCreatedAnnotation]; createdAt != "" {
+		annotations := make(map[string]string, len(obj.GetAnnotations())+1)
+		for key, value := range obj.GetAnnotations() {
+			annotations[key] = value
+		}
+		annotations[managedCreatedAnnotation] = createdAt
+		obj.SetAnnotations(annotations)
+	}
+}
+
+// listManagedResourcesParams are the arguments of list_managed_resources
+type listManagedResourcesParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: all namespaces and cluster-scoped resources
+	Tool      string `json:"tool,omitempty"`      // only resources created by this tool
+}
+
+// ListManagedResources inventories the resources meshpilot created, with the tool that created each
+func (m *Manager) ListManagedResources(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params listManagedResourcesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	resources, notes, err := m.listManagedResources(ctx, params.Namespace)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list managed resources: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	inventory := &ManagedInventory{
+		Namespace: params.Namespace,
+		ByKind:    make(map[string]int),
+		ByTool:    make(map[string]int),
+		Resources: []ManagedResource{},
+		Notes:     notes,
+		Timestamp: time.Now(),
+	}
+	if inventory.Namespace == "" {
+		inventory.Namespace = "all"
+	}
+	for _, kind := range managedKinds {
+		for _, resource := range resources[kind.kind] {
+			if params.Tool != "" && resource.Tool != params.Tool {
+				continue
+			}
+			inventory.Resources = append(inventory.Resources, resource)
+			inventory.ByKind[resource.Kind]++
+			tool := resource.Tool
+			if tool == "" {
+				tool = "unknown"
+			}
+			inventory.ByTool[tool]++
+		}
+	}
+	inventory.Total = len(inventory.Resources)
+	inventory.Notes = append(inventory.Notes, "Charts installed through Helm (Istio, Sail operator, MetalLB, SPIRE, egress gateway) carry Helm's labels instead and are not listed")
+
+	resultJSON, _ := json.MarshalIndent(inventory, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// cleanupDemoParams are the arguments of cleanup_demo
+type cleanupDemoParams struct {
+	Namespace    string `json:"namespace,omitempty"`     // only clean up this namespace, default: all
+	DryRun       bool   `json:"dry_run,omitempty"`       // list what would be removed
+	StopMonitors *bool  `json:"stop_monitors,omitempty"` // default: true
+}
+
+// CleanupDemo removes every resource carrying the meshpilot managed-by label, stops background
+// monitors and reports debug containers, leaving resources the user created untouched
+func (m *Manager) CleanupDemo(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params cleanupDemoParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.StopMonitors == nil {
+		params.StopMonitors = boolPtr(true)
+	}
+
+	result := &CleanupResult{
+		DryRun:    params.DryRun,
+		Namespace: params.Namespace,
+		Removed:   []ManagedResource{},
+		Timestamp: time.Now(),
+	}
+	if result.Namespace == "" {
+		result.Namespace = "all"
+	}
+
+	if *params.StopMonitors {
+		m.monitorsMu.Lock()
+		var monitors []*connectivityMonitor
+		for name, monitor := range m.monitors {
+			if monitor.isRunning() && (params.Namespace == "" || monitor.sourceNamespace == params.Namespace) {
+				monitors = append(monitors, monitor)
+				result.MonitorsStopped = append(result.MonitorsStopped, name)
+			}
+		}
+		m.monitorsMu.Unlock()
+		sort.Strings(result.MonitorsStopped)
+		if !params.DryRun {
+			for _, monitor := range monitors {
+				monitor.cancel()
+				<-monitor.done
+			}
+		}
+	}
+
+	resources, notes, err := m.listManagedResources(ctx, params.Namespace)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list managed resources: %v", err),
+				},
+			},
+		}, nil
+	}
+	result.Notes = append(result.Notes, notes...)
+
+	// Deleting a managed namespace removes everything in it, so its contents are not deleted one by one;
+	// namespaces that hold anything the user created since are kept
+	deletedNamespaces := make(map[string]bool)
+	for _, resource := range resources["Namespace"] {
+		foreign, err := m.unmanagedResources(ctx, resource.Name)
+		switch {
+		case err != nil:
+			deletedNamespaces[resource.Name] = false
+			result.Failed = append(result.Failed, fmt.Sprintf("Namespace %s: %v", resource.Name, err))
+		case len(foreign) > 0:
+			deletedNamespaces[resource.Name] = false
+			result.Notes = append(result.Notes, fmt.Sprintf("Kept namespace %s, which also holds resources meshpilot did not create (%s); only its managed resources were removed", resource.Name, truncatedList(foreign, 10)))
+		default:
+			deletedNamespaces[resource.Name] = true
+		}
+	}
+	for _, kind := range managedKinds {
+		for _, resource := range resources[kind.kind] {
+			if kind.namespaced && deletedNamespaces[resource.Namespace] {
+				continue
+			}
+			if kind.kind == "Namespace" && !deletedNamespaces[resource.Name] {
+				continue
+			}
+			if !params.DryRun {
+				client := m.k8sClient.Dynamic.Resource(kind.gvr)
+				var err error
+				if kind.namespaced {
+					err = client.Namespace(resource.Namespace).Delete(ctx, resource.Name, metav1.DeleteOptions{})
+				} else {
+					err = client.Delete(ctx, resource.Name, metav1.DeleteOptions{})
+				}
+				if err != nil && !errors.IsNotFound(err) {
+					result.Failed = append(result.Failed, fmt.Sprintf("%s %s: %v", kind.kind, resourceRef(resource), err))
+					continue
+				}
+			}
+			result.Removed = append(result.Removed, resource)
+		}
+	}
+
+	// Ephemeral containers stay in the pod spec until the pod is replaced
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		result.Notes = append(result.Notes, fmt.Sprintf("Failed to list pods for debug containers: %v", err))
+	} else {
+		for _, pod := range pods.Items {
+			if deletedNamespaces[pod.Namespace] {
+				continue
+			}
+			for _, container := range pod.Spec.EphemeralContainers {
+				if strings.HasPrefix(container.Name, debugContainerPrefix) || strings.HasPrefix(container.Name, captureContainerPrefix) {
+					result.DebugContainers = append(result.DebugContainers, fmt.Sprintf("%s/%s/%s", pod.Namespace, pod.Name, container.Name))
+				}
+			}
+		}
+		if len(result.DebugContainers) > 0 {
+			result.Notes = append(result.Notes, "Kubernetes cannot remove ephemeral containers; they have exited and disappear when their pods are next restarted")
+		}
+	}
+	result.Notes = append(result.Notes, "Helm releases (Istio, Sail operator, MetalLB, SPIRE, egress gateway) are not removed; use the matching uninstall tools")
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// listManagedResources finds the resources carrying the managed-by label, keyed by kind; kinds whose
+// CRD is not installed are skipped
+func (m *Manager) listManagedResources(ctx context.Context, namespace string) (map[string][]ManagedResource, []string, error) {
+	resources := make(map[string][]ManagedResource)
+	var notes []string
+	selector := managedByLabel + "=" + managedByValue
+	for _, kind := range managedKinds {
+		client := m.k8sClient.Dynamic.Resource(kind.gvr)
+		var items []ManagedResource
+		if kind.namespaced {
+			list, err := client.Namespace(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("failed to list %s: %w", kind.gvr.Resource, err)
+			}
+			for _, item := range list.Items {
+				items = append(items, ManagedResource{
+					Kind:      kind.kind,
+					Namespace: item.GetNamespace(),
+					Name:      item.GetName(),
+					Tool:      item.GetLabels()[managedToolLabel],
+					CreatedAt: item.GetAnnotations()[managedCreatedAnnotation],
+				})
+			}
+		} else {
+			list, err := client.List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				return nil, nil, fmt.Errorf("failed to list %s: %w", kind.gvr.Resource, err)
+			}
+			for _, item := range list.Items {
+				// With a namespace filter, only that namespace itself counts among cluster-scoped kinds
+				if namespace != "" && (kind.kind != "Namespace" || item.GetName() != namespace) {
+					continue
+				}
+				items = append(items, ManagedResource{
+					Kind:      kind.kind,
+					Name:      item.GetName(),
+					Tool:      item.GetLabels()[managedToolLabel],
+					CreatedAt: item.GetAnnotations()[managedCreatedAnnotation],
+				})
+			}
+		}
+		if len(items) > 0 {
+			resources[kind.kind] = items
+		}
+	}
+	if namespace != "" {
+		notes = append(notes, "Cluster-scoped resources such as ClusterSPIFFEIDs are only cleaned up without a namespace filter")
+	}
+	return resources, notes, nil
+}
+
+// unmanagedResources lists everything in a namespace that meshpilot did not create, across every namespaced
+// kind the API server serves; objects generated by Kubernetes or Istio for the namespace or for other objects
+// are skipped. If a kind cannot be discovered or listed, the namespace cannot be shown to be meshpilot's alone,
+// so an error is returned and the namespace is kept
+func (m *Manager) unmanagedResources(ctx context.Context, namespace string) ([]string, error) {
+	lists, err := m.k8sClient.Kubernetes.Discovery().ServerPreferredNamespacedResources()
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover namespaced resource kinds: %w", err)
+	}
+
+	selector := metav1.ListOptions{LabelSelector: managedByLabel + "!=" + managedByValue}
+	var foreign []string
+	for _, list := range lists {
+		gv, err := schema.ParseGroupVersion(list.GroupVersion)
+		if err != nil || gv.Group == "metrics.k8s.io" {
+			continue
+		}
+		for _, resource := range list.APIResources {
+			if strings.Contains(resource.Name, "/") || !containsString(resource.Verbs, "list") || !containsString(resource.Verbs, "delete") {
+				continue
+			}
+			gvr := gv.WithResource(resource.Name)
+			items, err := m.k8sClient.Dynamic.Resource(gvr).Namespace(namespace).List(ctx, selector)
+			if err != nil {
+				return nil, fmt.Errorf("failed to list %s: %w", gvr.GroupResource(), err)
+			}
+			for _, item := range items.Items {
+				if !generatedObject(resource.Name, item.GetName(), item.GetOwnerReferences(), item.Object["type"]) {
+					foreign = append(foreign, strings.ToLower(resource.Kind)+"/"+item.GetName())
+				}
+			}
+		}
+	}
+	sort.Strings(foreign)
+	return foreign, nil
+}
+
+// generatedObject tells whether an object was created by a controller rather than a user: anything with an
+// owner, events and endpoints, and the service account, CA bundles and token secrets every namespace gets
+func generatedObject(resource, name string, owners []metav1.OwnerReference, secretType interface{}) bool {
+	switch {
+	case len(owners) > 0:
+		return true
+	case resource == "events" || resource == "endpoints":
+		return true
+	case resource == "serviceaccounts" && name == "default":
+		return true
+	case resource == "configmaps" && (name == "kube-root-ca.crt" || name == "istio-ca-root-cert"):
+		return true
+	case resource == "secrets" && secretType == "kubernetes.io/service-account-token":
+		return true
+	}
+	return false
+}
+
+// resourceRef formats a managed resource as <namespace>/<name>, or <name> when cluster-scoped
+func resourceRef(resource ManagedResource) string {
+	if resource.Namespace == "" {
+		return resource.Name
+	}
+	return resource.Namespace + "/" + resource.Name
+}
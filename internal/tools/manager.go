@@ -1,23 +1,169 @@
 package tools
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"meshpilot/internal/config"
 	"meshpilot/internal/k8s"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
 )
 
+// configPropagationTimeout bounds how long a tool waits for Istio config it applied to reach the proxies
+const configPropagationTimeout = 45 * time.Second
+
 // Manager handles all tool operations
 type Manager struct {
+	// k8sClient is the client a tool call runs with; nil when no cluster is reachable
 	k8sClient *k8s.Client
+	config    *config.Config
+
+	// kubeContext is the kubeconfig context of a Manager derived for a tool call's context parameter;
+	// empty when the Manager uses the current context
+	kubeContext string
+
+	// managerState is shared with the Managers derived for other contexts
+	*managerState
+}
+
+// managerState holds the state that outlives a single tool call
+type managerState struct {
+	// client is the client for the kubeconfig's current context, created on first use and replaced when the
+	// context or kubeconfig changes
+	clientMu sync.Mutex
+	client   *k8s.Client
+
+	// clients caches a Kubernetes client per kubeconfig context for tool calls with a context parameter
+	clients *k8s.ClientCache
+
+	// monitors holds background connectivity monitors keyed by name
+	monitorsMu sync.Mutex
+	monitors   map[string]*connectivityMonitor
+
+	// alerts sends notifications when monitors or health tools keep failing
+	alerts *alerter
+
+	// scheduler runs the configured read-only health checks in server mode
+	schedulerMu sync.Mutex
+	scheduler   *cron.Cron
+	schedules   []*scheduledCheck
+
+	// history persists tool results for later review
+	history *historyStore
+
+	// missingPermissions holds the permissions each tool lacked at the last probe, and limitedPermissions
+	// those it lacked only in its default namespaces
+	permissionsMu      sync.Mutex
+	missingPermissions map[string][]string
+	limitedPermissions map[string][]string
+
+	// debugImages holds the context/node/image keys of debug images known to be pullable on a node
+	debugImagesMu sync.Mutex
+	debugImages   map[string]bool
 }
 
 // NewManager creates a new tool manager
-func NewManager(k8sClient *k8s.Client) *Manager {
+func NewManager(k8sClient *k8s.Client, cfg *config.Config) *Manager {
+	if cfg == nil {
+		cfg = config.Default()
+	}
 	return &Manager{
 		k8sClient: k8sClient,
+		config:    cfg,
+		managerState: &managerState{
+			client:      k8sClient,
+			clients:     k8s.NewClientCache(),
+			monitors:    make(map[string]*connectivityMonitor),
+			alerts:      newAlerter(cfg.Alerts),
+			history:     newHistoryStore(cfg.History),
+			debugImages: make(map[string]bool),
+		},
 	}
 }
 
+// forContext returns a Manager that runs tools against another kubeconfig context, sharing this Manager's state
+func (m *Manager) forContext(contextName string) (*Manager, error) {
+	client, err := m.clients.Get(contextName)
+	if err != nil {
+		return nil, err
+	}
+	return &Manager{
+		k8sClient:    client,
+		config:       m.config,
+		kubeContext:  contextName,
+		managerState: m.managerState,
+	}, nil
+}
+
+// currentClient returns the client for the kubeconfig's current context, creating it if no cluster was reachable
+// before, so a kubeconfig that appears after startup is picked up; a Manager for a named context keeps its client
+func (m *Manager) currentClient() (*k8s.Client, error) {
+	if m.kubeContext != "" {
+		return m.k8sClient, nil
+	}
+	m.clientMu.Lock()
+	defer m.clientMu.Unlock()
+	if m.client == nil {
+		client, err := k8s.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		m.client = client
+	}
+	return m.client, nil
+}
+
+// withCurrentClient returns a copy of the Manager for one call or resource read, holding the current client even
+// if the client is switched meanwhile; the copy has no client when none could be created
+func (m *Manager) withCurrentClient() (*Manager, error) {
+	client, err := m.currentClient()
+	call := *m
+	call.k8sClient = client
+	return &call, err
+}
+
+// reconnectCluster rebuilds the client from the current kubeconfig context, for this tool call and later ones
+func (m *Manager) reconnectCluster() error {
+	client, err := k8s.NewClient()
+	m.clientMu.Lock()
+	m.client = client
+	m.clientMu.Unlock()
+	m.k8sClient = client
+	return err
+}
+
+// AcceptsContext reports whether a tool takes the per-call context parameter; tools that manage kubeconfig
+// contexts themselves or never reach a cluster do not
+func AcceptsContext(toolName string) bool {
+	return !clusterlessTools[toolName]
+}
+
+// requestedContext returns the kubeconfig context a tool call asks for, if any
+func requestedContext(toolName string, args json.RawMessage) string {
+	if !AcceptsContext(toolName) {
+		return ""
+	}
+	var params struct {
+		Context string `json:"context,omitempty"`
+	}
+	// Malformed arguments are reported by the tool itself
+	_ = json.Unmarshal(args, &params)
+	return params.Context
+}
+
+// kubectlCommand prepares a kubectl command against the Manager's cluster, which is not the current
+// context when the tool was called with a context parameter
+func (m *Manager) kubectlCommand(ctx context.Context, args ...string) *exec.Cmd {
+	if m.kubeContext != "" {
+		args = append([]string{"--context", m.kubeContext}, args...)
+	}
+	return exec.CommandContext(ctx, "kubectl", args...)
+}
+
 // CallToolResult represents the result of a tool call
 type CallToolResult struct {
 	IsError bool          `json:"is_error,omitempty"`
@@ -30,76 +176,390 @@ type TextContent struct {
 	Text string `json:"text"`
 }
 
-// ExecuteTool executes a tool by name with given arguments
-func (m *Manager) ExecuteTool(toolName string, args json.RawMessage) (*CallToolResult, error) {
-	// Check if k8s client is available
-	if m.k8sClient == nil {
+// ProgressFunc receives incremental output of a tool call while it runs, such as followed log lines
+type ProgressFunc func(message string)
+
+// progressKey carries the caller's ProgressFunc through a tool call's context
+type progressKey struct{}
+
+// WithProgress returns a context whose tool calls report incremental output to fn before returning their result
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	return context.WithValue(ctx, progressKey{}, fn)
+}
+
+// reportProgress sends incremental output to the caller, if it listens for any
+func reportProgress(ctx context.Context, message string) {
+	if fn, ok := ctx.Value(progressKey{}).(ProgressFunc); ok && fn != nil {
+		fn(message)
+	}
+}
+
+// ResourceContent represents binary content embedded in a result as an MCP resource
+type ResourceContent struct {
+	Type     string `json:"type"`
+	URI      string `json:"uri"`
+	MIMEType string `json:"mime_type"`
+	Blob     []byte `json:"blob"`
+}
+
+// clusterlessTools lists tools that can run before a Kubernetes cluster is reachable
+var clusterlessTools = map[string]bool{
+	"list_contexts":         true,
+	"switch_context":        true,
+	"configure_kubeconfig":  true,
+	"create_dev_cluster":    true,
+	"delete_dev_cluster":    true,
+	"validate_access":       true,
+	"get_fleet_status":      true,
+	"self_test":             true,
+	"get_scheduled_results": true,
+	"list_history":          true,
+	"get_result":            true,
+}
+
+// ExecuteTool executes a tool by name with given arguments; cancelling ctx aborts the tool's Kubernetes, Helm and exec calls
+func (m *Manager) ExecuteTool(ctx context.Context, toolName string, args json.RawMessage) (*CallToolResult, error) {
+	// Run against another cluster without switching the kubeconfig's current context
+	if kubeContext := requestedContext(toolName, args); kubeContext != "" && kubeContext != m.kubeContext {
+		if err := m.checkContextScope(kubeContext); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Refused by namespace scope: %v", err),
+					},
+				},
+			}, nil
+		}
+		target, err := m.forContext(kubeContext)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to create a client for context %s: %v", kubeContext, err),
+					},
+				},
+			}, nil
+		}
+		return target.ExecuteTool(ctx, toolName, args)
+	}
+
+	// Connect on demand, so a kubeconfig that was missing at startup or fixed since is used; tools that manage
+	// kubeconfig contexts or dev clusters work without one
+	call, err := m.withCurrentClient()
+	if err != nil && !clusterlessTools[toolName] {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: "Kubernetes client not available. Please ensure kubeconfig is properly configured.",
+					Text: fmt.Sprintf("Kubernetes client not available: %v. Provide a kubeconfig with configure_kubeconfig or fix the current one (run validate_access to diagnose).", err),
 				},
 			},
 		}, nil
 	}
+	m = call
+
+	// Keep tools inside the configured namespaces
+	if err := m.checkScope(toolName, args); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Refused by namespace scope: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Keep destructive tools away from the control plane unless forced
+	if err := m.checkGuardrails(toolName, args); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Refused by guardrails: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	start := time.Now()
+	result, err := m.executeTool(ctx, toolName, args)
+	m.recordToolOutcome(toolName, args, result, err)
+	m.recordHistory(toolName, args, start, result, err)
+	return result, err
+}
+
+// executeTool dispatches a tool call to its implementation
+func (m *Manager) executeTool(ctx context.Context, toolName string, args json.RawMessage) (*CallToolResult, error) {
 	switch toolName {
 	// Cluster management tools
 	case "list_contexts":
-		return m.ListContexts(args)
+		return m.ListContexts(ctx, args)
 	case "switch_context":
-		return m.SwitchContext(args)
+		return m.SwitchContext(ctx, args)
+	case "configure_kubeconfig":
+		return m.ConfigureKubeconfig(ctx, args)
 	case "get_cluster_info":
-		return m.GetClusterInfo(args)
+		return m.GetClusterInfo(ctx, args)
+	case "check_tool_permissions":
+		return m.CheckToolPermissions(ctx, args)
+	case "validate_access":
+		return m.ValidateAccess(ctx, args)
+	case "get_fleet_status":
+		return m.GetFleetStatus(ctx, args)
+	case "create_dev_cluster":
+		return m.CreateDevCluster(ctx, args)
+	case "delete_dev_cluster":
+		return m.DeleteDevCluster(ctx, args)
+	case "install_metallb":
+		return m.InstallMetalLB(ctx, args)
+	case "self_test":
+		return m.SelfTest(ctx, args)
 
 	// Istio management tools
 	case "install_istio":
-		return m.InstallIstio(args)
+		return m.InstallIstio(ctx, args)
 	case "uninstall_istio":
-		return m.UninstallIstio(args)
+		return m.UninstallIstio(ctx, args)
+	case "istio_canary_upgrade":
+		return m.IstioCanaryUpgrade(ctx, args)
+	case "migrate_istio_install":
+		return m.MigrateIstioInstall(ctx, args)
+	case "migrate_to_ambient":
+		return m.MigrateToAmbient(ctx, args)
+	case "inspect_revision_tags":
+		return m.InspectRevisionTags(ctx, args)
+	case "audit_discovery_selectors":
+		return m.AuditDiscoverySelectors(ctx, args)
+	case "configure_discovery_selectors":
+		return m.ConfigureDiscoverySelectors(ctx, args)
+	case "audit_istio_resources":
+		return m.AuditIstioResources(ctx, args)
+	case "istio_analyze":
+		return m.IstioAnalyze(ctx, args)
+	case "get_injection_config":
+		return m.GetInjectionConfig(ctx, args)
+	case "set_injection_template":
+		return m.SetInjectionTemplate(ctx, args)
+	case "preview_injection":
+		return m.PreviewInjection(ctx, args)
+	case "install_otel_collector":
+		return m.InstallOtelCollector(ctx, args)
+	case "configure_tracing":
+		return m.ConfigureTracing(ctx, args)
+	case "proxy_status":
+		return m.ProxyStatus(ctx, args)
+	case "watch_mesh_events":
+		return m.WatchMeshEvents(ctx, args)
+	case "istiod_debug":
+		return m.IstiodDebug(ctx, args)
+	case "audit_istiod_flags":
+		return m.AuditIstiodFlags(ctx, args)
 	case "check_istio_status":
-		return m.CheckIstioStatus(args)
+		return m.CheckIstioStatus(ctx, args)
+	case "check_install_capacity":
+		return m.CheckInstallCapacity(ctx, args)
+	case "estimate_mesh_cost":
+		return m.EstimateMeshCost(ctx, args)
+	case "check_cni_chaining":
+		return m.CheckCNIChaining(ctx, args)
+	case "detect_cni_race":
+		return m.DetectCNIRace(ctx, args)
+	case "get_release_values":
+		return m.GetReleaseValues(ctx, args)
+	case "list_available_istio_versions":
+		return m.ListAvailableIstioVersions(ctx, args)
+	case "get_istio_release_notes":
+		return m.GetIstioReleaseNotes(ctx, args)
 
 	// Sail operator tools
 	case "install_sail_operator":
-		return m.InstallSailOperator(args)
+		return m.InstallSailOperator(ctx, args)
 	case "uninstall_sail_operator":
-		return m.UninstallSailOperator(args)
+		return m.UninstallSailOperator(ctx, args)
 	case "check_sail_status":
-		return m.CheckSailStatus(args)
+		return m.CheckSailStatus(ctx, args)
 
 	// Sample application tools
 	case "deploy_sleep_app":
-		return m.DeploySleepApp(args)
+		return m.DeploySleepApp(ctx, args)
 	case "deploy_httpbin_app":
-		return m.DeployHttpbinApp(args)
+		return m.DeployHttpbinApp(ctx, args)
 	case "undeploy_sleep_app":
-		return m.UndeploySleepApp(args)
+		return m.UndeploySleepApp(ctx, args)
 	case "undeploy_httpbin_app":
-		return m.UndeployHttpbinApp(args)
+		return m.UndeployHttpbinApp(ctx, args)
+	case "deploy_bookinfo_app":
+		return m.DeployBookinfoApp(ctx, args)
+	case "undeploy_bookinfo_app":
+		return m.UndeployBookinfoApp(ctx, args)
+	case "apply_manifest":
+		return m.ApplyManifest(ctx, args)
+	case "cleanup_demo":
+		return m.CleanupDemo(ctx, args)
+	case "list_managed_resources":
+		return m.ListManagedResources(ctx, args)
 
 	// Connectivity testing tools
 	case "test_connectivity":
-		return m.TestConnectivity(args)
+		return m.TestConnectivity(ctx, args)
 	case "test_sleep_to_httpbin":
-		return m.TestSleepToHttpbin(args)
+		return m.TestSleepToHttpbin(ctx, args)
+	case "test_ingress_connectivity":
+		return m.TestIngressConnectivity(ctx, args)
+	case "test_gateway_paths":
+		return m.TestGatewayPaths(ctx, args)
+	case "sweep_service_ports":
+		return m.SweepServicePorts(ctx, args)
+	case "verify_waypoint":
+		return m.VerifyWaypoint(ctx, args)
+	case "test_header_routing":
+		return m.TestHeaderRouting(ctx, args)
+	case "generate_traffic":
+		return m.GenerateTraffic(ctx, args)
+	case "run_mesh_conformance":
+		return m.RunMeshConformance(ctx, args)
+	case "configure_egress_routing":
+		return m.ConfigureEgressRouting(ctx, args)
+	case "probe_gateway_tls":
+		return m.ProbeGatewayTLS(ctx, args)
+	case "diagnose_ingress_request":
+		return m.DiagnoseIngressRequest(ctx, args)
+	case "benchmark_mesh_overhead":
+		return m.BenchmarkMeshOverhead(ctx, args)
+	case "start_monitor":
+		return m.StartMonitor(ctx, args)
+	case "stop_monitor":
+		return m.StopMonitor(ctx, args)
+	case "traffic_shift":
+		return m.TrafficShift(ctx, args)
+	case "create_virtual_service":
+		return m.CreateVirtualService(ctx, args)
+	case "get_virtual_service":
+		return m.GetVirtualService(ctx, args)
+	case "delete_virtual_service":
+		return m.DeleteVirtualService(ctx, args)
+	case "create_destination_rule":
+		return m.CreateDestinationRule(ctx, args)
+	case "get_destination_rule":
+		return m.GetDestinationRule(ctx, args)
+	case "delete_destination_rule":
+		return m.DeleteDestinationRule(ctx, args)
+	case "detect_connection_pool_exhaustion":
+		return m.DetectConnectionPoolExhaustion(ctx, args)
+	case "expose_service_via_gateway":
+		return m.ExposeServiceViaGateway(ctx, args)
+	case "create_gateway_route":
+		return m.CreateGatewayRoute(ctx, args)
+	case "get_gateway_routes":
+		return m.GetGatewayRoutes(ctx, args)
+	case "delete_gateway_route":
+		return m.DeleteGatewayRoute(ctx, args)
+	case "get_gateway_status":
+		return m.GetGatewayStatus(ctx, args)
+	case "get_monitor_results":
+		return m.GetMonitorResults(ctx, args)
+	case "get_scheduled_results":
+		return m.GetScheduledResults(ctx, args)
 
 	// Logging and debugging tools
 	case "get_pod_logs":
-		return m.GetPodLogs(args)
+		return m.GetPodLogs(ctx, args)
 	case "get_istio_proxy_logs":
-		return m.GetIstioProxyLogs(args)
+		return m.GetIstioProxyLogs(ctx, args)
+	case "get_istiod_logs":
+		return m.GetIstiodLogs(ctx, args)
+	case "analyze_response_flags":
+		return m.AnalyzeResponseFlags(ctx, args)
+	case "get_kubernetes_events":
+		return m.GetKubernetesEvents(ctx, args)
 	case "exec_pod_command":
-		return m.ExecPodCommand(args)
+		return m.ExecPodCommand(ctx, args)
 
 	// Network debugging tools
 	case "get_iptables_rules":
-		return m.GetIptablesRules(args)
+		return m.GetIptablesRules(ctx, args)
+	case "capture_packets":
+		return m.CapturePackets(ctx, args)
+	case "get_interception_mode":
+		return m.GetInterceptionMode(ctx, args)
+	case "configure_traffic_exclusions":
+		return m.ConfigureTrafficExclusions(ctx, args)
+	case "configure_dns_proxying":
+		return m.ConfigureDNSProxying(ctx, args)
+	case "tune_proxy":
+		return m.TuneProxy(ctx, args)
+	case "audit_sidecar_startup":
+		return m.AuditSidecarStartup(ctx, args)
+	case "diagnose_job_sidecars":
+		return m.DiagnoseJobSidecars(ctx, args)
+	case "inspect_sidecar_annotations":
+		return m.InspectSidecarAnnotations(ctx, args)
+	case "detect_dataplane_mode":
+		return m.DetectDataplaneMode(ctx, args)
+	case "get_proxy_config":
+		return m.GetProxyConfig(ctx, args)
+	case "envoy_admin_get":
+		return m.EnvoyAdminGet(ctx, args)
+	case "get_ztunnel_config":
+		return m.GetZtunnelConfig(ctx, args)
 	case "get_network_policies":
-		return m.GetNetworkPolicies(args)
+		return m.GetNetworkPolicies(ctx, args)
+	case "generate_network_policy":
+		return m.GenerateNetworkPolicy(ctx, args)
 	case "trace_network_path":
-		return m.TraceNetworkPath(args)
+		return m.TraceNetworkPath(ctx, args)
+	case "diagnose_pod_node_network":
+		return m.DiagnosePodNodeNetwork(ctx, args)
+	case "validate_dual_stack":
+		return m.ValidateDualStack(ctx, args)
+	case "check_clock_skew":
+		return m.CheckClockSkew(ctx, args)
+	case "scan_mesh_images":
+		return m.ScanMeshImages(ctx, args)
+	case "setup_ext_authz":
+		return m.SetupExtAuthz(ctx, args)
+	case "test_ext_authz":
+		return m.TestExtAuthz(ctx, args)
+	case "install_spire":
+		return m.InstallSPIRE(ctx, args)
+	case "configure_istio_spire":
+		return m.ConfigureIstioSPIRE(ctx, args)
+	case "verify_spire_identities":
+		return m.VerifySPIREIdentities(ctx, args)
+	case "set_mtls_mode":
+		return m.SetMTLSMode(ctx, args)
+	case "get_mtls_status":
+		return m.GetMTLSStatus(ctx, args)
+	case "check_mtls_between":
+		return m.CheckMTLSBetween(ctx, args)
+	case "get_workload_certificates":
+		return m.GetWorkloadCertificates(ctx, args)
+	case "inspect_trust_domain":
+		return m.InspectTrustDomain(ctx, args)
+	case "create_authorization_policy":
+		return m.CreateAuthorizationPolicy(ctx, args)
+	case "get_authorization_policy":
+		return m.GetAuthorizationPolicy(ctx, args)
+	case "delete_authorization_policy":
+		return m.DeleteAuthorizationPolicy(ctx, args)
+	case "audit_authorization_policies":
+		return m.AuditAuthorizationPolicies(ctx, args)
+
+	// Result history tools
+	case "list_history":
+		return m.ListHistory(ctx, args)
+	case "get_result":
+		return m.GetResult(ctx, args)
+	case "compare_with_snapshot":
+		return m.CompareWithSnapshot(ctx, args)
 
 	default:
 		return &CallToolResult{
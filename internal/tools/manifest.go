@@ -0,0 +1,350 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	// manifestFieldManager is the field manager apply_manifest applies as
+	manifestFieldManager = "meshpilot"
+	// maxManifestBytes caps the size of a manifest downloaded by apply_manifest
+	maxManifestBytes = 4 << 20
+	// manifestFetchTimeout bounds the download of a manifest URL
+	manifestFetchTimeout = 30 * time.Second
+)
+
+// AppliedObject is the outcome of applying one object of a manifest
+type AppliedObject struct {
+	APIVersion string `json:"api_version"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+	Action     string `json:"action,omitempty"` // created, configured or unchanged
+	Error      string `json:"error,omitempty"`
+}
+
+// ApplyManifestResult is the result of apply_manifest
+type ApplyManifestResult struct {
+	Source    string          `json:"source"`
+	DryRun    bool            `json:"dry_run,omitempty"`
+	Applied   int             `json:"applied"`
+	Failed    int             `json:"failed"`
+	Objects   []AppliedObject `json:"objects"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// applyManifestParams are the arguments of apply_manifest
+type applyManifestParams struct {
+	Manifest       string `json:"manifest,omitempty"`        // inline YAML or JSON, multiple documents allowed
+	URL            string `json:"url,omitempty"`             // http(s) URL to download the manifest from
+	Namespace      string `json:"namespace,omitempty"`       // default: default, for namespaced objects without one
+	DryRun         bool   `json:"dry_run,omitempty"`         // server-side dry run
+	ForceConflicts bool   `json:"force_conflicts,omitempty"` // take ownership of fields owned by other managers
+	Force          bool   `json:"force,omitempty"`           // override the guardrails on protected namespaces
+}
+
+// ApplyManifest applies the objects of a YAML or JSON manifest with server-side apply, mapping each kind
+// to its resource through API discovery so any built-in or custom resource can be applied
+func (m *Manager) ApplyManifest(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params applyManifestParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if (params.Manifest == "") == (params.URL == "") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Exactly one of manifest or url is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	ctx = withManagingTool(ctx, "apply_manifest")
+
+	source := "inline"
+	data := []byte(params.Manifest)
+	if params.URL != "" {
+		source = params.URL
+		var err error
+		data, err = fetchManifest(ctx, params.URL)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to download manifest: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	objects, err := decodeManifest(data)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to parse manifest: %v", err),
+				},
+			},
+		}, nil
+	}
+	if len(objects) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Manifest contains no objects",
+				},
+			},
+		}, nil
+	}
+
+	// The documents may name protected namespaces the namespace parameter does not
+	if !params.DryRun {
+		if err := m.checkManifestGuardrails(objects, params.Force); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Refused by guardrails: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(m.k8sClient.Config)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to create discovery client: %v", err),
+				},
+			},
+		}, nil
+	}
+	mapper := restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient))
+
+	result := &ApplyManifestResult{
+		Source:    source,
+		DryRun:    params.DryRun,
+		Objects:   []AppliedObject{},
+		Timestamp: time.Now(),
+	}
+	for _, obj := range objects {
+		applied := m.applyManifestObject(ctx, mapper, obj, params.Namespace, params.DryRun, params.ForceConflicts)
+		if applied.Error != "" {
+			result.Failed++
+		} else {
+			result.Applied++
+		}
+		result.Objects = append(result.Objects, applied)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		IsError: result.Applied == 0,
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// applyManifestObject server-side applies one object, defaulting the namespace of namespaced kinds
+func (m *Manager) applyManifestObject(ctx context.Context, mapper *restmapper.DeferredDiscoveryRESTMapper, obj *unstructured.Unstructured, namespace string, dryRun, force bool) AppliedObject {
+	applied := AppliedObject{
+		APIVersion: obj.GetAPIVersion(),
+		Kind:       obj.GetKind(),
+		Namespace:  obj.GetNamespace(),
+		Name:       obj.GetName(),
+	}
+	if applied.Name == "" {
+		applied.Error = "metadata.name is required; generateName is not supported by server-side apply"
+		return applied
+	}
+
+	gvk := obj.GroupVersionKind()
+	mapping, err := mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		// A CRD applied earlier in the same manifest is only discovered after a refresh
+		mapper.Reset()
+		mapping, err = mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		applied.Error = fmt.Sprintf("unknown kind: %v", err)
+		return applied
+	}
+
+	resources := m.k8sClient.Dynamic.Resource(mapping.Resource)
+	var client dynamic.ResourceInterface = resources
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		if applied.Namespace == "" {
+			applied.Namespace = namespace
+			obj.SetNamespace(namespace)
+		}
+		client = resources.Namespace(applied.Namespace)
+	} else {
+		applied.Namespace = ""
+		obj.SetNamespace("")
+	}
+
+	existing, err := client.Get(ctx, applied.Name, metav1.GetOptions{})
+	created := errors.IsNotFound(err)
+	if err != nil && !created {
+		applied.Error = fmt.Sprintf("failed to read current object: %v", err)
+		return applied
+	}
+
+	// Only objects this call creates become meshpilot's; cleanup_demo must never pick up the user's own
+	if created {
+		markManaged(ctx, obj)
+	} else {
+		keepManaged(obj, existing)
+	}
+	body, err := json.Marshal(obj.Object)
+	if err != nil {
+		applied.Error = fmt.Sprintf("failed to encode object: %v", err)
+		return applied
+	}
+
+	options := metav1.PatchOptions{FieldManager: manifestFieldManager, Force: boolPtr(force)}
+	if dryRun {
+		options.DryRun = []string{metav1.DryRunAll}
+	}
+	patched, err := client.Patch(ctx, applied.Name, types.ApplyPatchType, body, options)
+	if err != nil {
+		applied.Error = err.Error()
+		return applied
+	}
+
+	switch {
+	case created:
+		applied.Action = "created"
+	case patched.GetResourceVersion() == existing.GetResourceVersion():
+		applied.Action = "unchanged"
+	default:
+		applied.Action = "configured"
+	}
+	return applied
+}
+
+// decodeManifest splits a YAML or JSON manifest into objects, expanding List kinds and skipping empty documents
+func decodeManifest(data []byte) ([]*unstructured.Unstructured, error) {
+	decoder := utilyaml.NewYAMLOrJSONDecoder(bytes.NewReader(data), 4096)
+	var objects []*unstructured.Unstructured
+	for document := 1; ; document++ {
+		var raw map[string]interface{}
+		if err := decoder.Decode(&raw); err != nil {
+			if err == io.EOF {
+				return objects, nil
+			}
+			return nil, fmt.Errorf("document %d: %w", document, err)
+		}
+		if len(raw) == 0 {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{Object: raw}
+		if obj.GetAPIVersion() == "" || obj.GetKind() == "" {
+			return nil, fmt.Errorf("document %d: apiVersion and kind are required", document)
+		}
+		if !obj.IsList() {
+			objects = append(objects, obj)
+			continue
+		}
+		if err := obj.EachListItem(func(item runtime.Object) error {
+			objects = append(objects, item.(*unstructured.Unstructured))
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("document %d: %w", document, err)
+		}
+	}
+}
+
+// fetchManifest downloads a manifest over HTTP(S)
+func fetchManifest(ctx context.Context, manifestURL string) ([]byte, error) {
+	parsed, err := url.Parse(manifestURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q: must be http or https", parsed.Scheme)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, manifestFetchTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxManifestBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxManifestBytes {
+		return nil, fmt.Errorf("manifest exceeds %d bytes", maxManifestBytes)
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, fmt.Errorf("manifest is empty")
+	}
+	return data, nil
+}
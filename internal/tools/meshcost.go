@@ -0,0 +1,371 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// hoursPerMonth is the average number of hours in a month used for monthly cost projections
+const hoursPerMonth = 730
+
+// meshCostComponents lists the mesh component groups in the order they are reported
+var meshCostComponents = []string{"control_plane", "gateways", "waypoints", "node_agents", "sidecars"}
+
+// MeshComponentCost is the requested CPU and memory of one group of mesh components
+type MeshComponentCost struct {
+	Component   string   `json:"component"`
+	Pods        int      `json:"pods"`
+	CPUCores    float64  `json:"cpu_cores"`
+	MemoryGiB   float64  `json:"memory_gib"`
+	MonthlyCost float64  `json:"monthly_cost,omitempty"`
+	Workloads   []string `json:"workloads,omitempty"` // namespace/app with pod counts, or namespaces for sidecars
+}
+
+// InjectionProjection is the sidecar overhead enabling injection in a namespace would add
+type InjectionProjection struct {
+	Namespace   string  `json:"namespace"`
+	Pods        int     `json:"pods"`
+	CPUCores    float64 `json:"cpu_cores"`
+	MemoryGiB   float64 `json:"memory_gib"`
+	MonthlyCost float64 `json:"monthly_cost,omitempty"`
+	Skipped     int     `json:"skipped,omitempty"` // host-network or opted-out pods that would not be injected
+}
+
+// MeshCostEstimate reports the resources the mesh requests today and after enabling more injection
+type MeshCostEstimate struct {
+	Nodes              int                   `json:"nodes"`
+	ClusterCPUCores    float64               `json:"cluster_cpu_cores"`  // allocatable
+	ClusterMemoryGiB   float64               `json:"cluster_memory_gib"` // allocatable
+	ProxyCPU           string                `json:"proxy_cpu"`          // sidecar request used for projections
+	ProxyMemory        string                `json:"proxy_memory"`
+	Components         []MeshComponentCost   `json:"components"`
+	TotalCPUCores      float64               `json:"total_cpu_cores"`
+	TotalMemoryGiB     float64               `json:"total_memory_gib"`
+	TotalMonthlyCost   float64               `json:"total_monthly_cost,omitempty"`
+	ClusterCPUPercent  float64               `json:"cluster_cpu_percent"`
+	ClusterMemPercent  float64               `json:"cluster_memory_percent"`
+	Projections        []InjectionProjection `json:"projections,omitempty"`
+	ProjectedCPUCores  float64               `json:"projected_cpu_cores,omitempty"` // total after enabling injection
+	ProjectedMemoryGiB float64               `json:"projected_memory_gib,omitempty"`
+	ProjectedCost      float64               `json:"projected_monthly_cost,omitempty"`
+	Notes              []string              `json:"notes,omitempty"`
+	Timestamp          time.Time             `json:"timestamp"`
+}
+
+// estimateMeshCostParams are the arguments of estimate_mesh_cost
+type estimateMeshCostParams struct {
+	IstioNamespace     string   `json:"istio_namespace,omitempty"`       // default: istio-system
+	Revision           string   `json:"revision,omitempty"`              // injector revision for the proxy request, default: default
+	Namespaces         []string `json:"namespaces,omitempty"`            // default: every namespace without injection
+	ProxyCPU           string   `json:"proxy_cpu,omitempty"`             // default: injector values, else 100m
+	ProxyMemory        string   `json:"proxy_memory,omitempty"`          // default: injector values, else 128Mi
+	CPUCoreHourPrice   float64  `json:"cpu_core_hour_price,omitempty"`   // price of one requested core per hour
+	MemoryGiBHourPrice float64  `json:"memory_gib_hour_price,omitempty"` // price of one requested GiB per hour
+}
+
+// EstimateMeshCost sums the CPU and memory requested by the control plane, gateways, waypoints, node agents
+// and sidecars, and projects the increase from enabling sidecar injection in more namespaces
+func (m *Manager) EstimateMeshCost(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params estimateMeshCostParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	estimate := &MeshCostEstimate{Timestamp: time.Now()}
+	priced := params.CPUCoreHourPrice > 0 || params.MemoryGiBHourPrice > 0
+	monthly := func(cpu, memory float64) float64 {
+		return round3((cpu*params.CPUCoreHourPrice + memory*params.MemoryGiBHourPrice) * hoursPerMonth)
+	}
+
+	// The injector values hold the proxy requests new sidecars get unless a pod overrides them
+	proxyCPU := resource.MustParse("100m")
+	proxyMemory := resource.MustParse("128Mi")
+	if _, values, err := m.readInjectorConfig(ctx, params.IstioNamespace, params.Revision); err == nil {
+		if cpu, err := resource.ParseQuantity(getHelmValue(values, "global.proxy.resources.requests.cpu")); err == nil {
+			proxyCPU = cpu
+		}
+		if memory, err := resource.ParseQuantity(getHelmValue(values, "global.proxy.resources.requests.memory")); err == nil {
+			proxyMemory = memory
+		}
+	} else {
+		estimate.Notes = append(estimate.Notes, fmt.Sprintf("Could not read the sidecar injector values (%v); assuming the chart default proxy requests", err))
+	}
+	for _, override := range []struct {
+		value  string
+		target *resource.Quantity
+		name   string
+	}{{params.ProxyCPU, &proxyCPU, "proxy_cpu"}, {params.ProxyMemory, &proxyMemory, "proxy_memory"}} {
+		if override.value == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(override.value)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid %s %q: %v", override.name, override.value, err),
+					},
+				},
+			}, nil
+		}
+		*override.target = quantity
+	}
+	estimate.ProxyCPU = proxyCPU.String()
+	estimate.ProxyMemory = proxyMemory.String()
+
+	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list nodes: %v", err),
+				},
+			},
+		}, nil
+	}
+	estimate.Nodes = len(nodes.Items)
+	for _, node := range nodes.Items {
+		estimate.ClusterCPUCores += cpuCores(*node.Status.Allocatable.Cpu())
+		estimate.ClusterMemoryGiB += memoryGiB(*node.Status.Allocatable.Memory())
+	}
+
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Sum the requests of each component group, remembering the pods of every namespace for projections
+	components := make(map[string]*MeshComponentCost)
+	workloads := make(map[string]map[string]int)
+	for _, name := range meshCostComponents {
+		components[name] = &MeshComponentCost{Component: name}
+		workloads[name] = make(map[string]int)
+	}
+	namespacePods := make(map[string][]*corev1.Pod)
+	unrequested := 0
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		namespacePods[pod.Namespace] = append(namespacePods[pod.Namespace], pod)
+
+		component := meshPodComponent(pod)
+		if component == "" {
+			continue
+		}
+		var cpu, memory resource.Quantity
+		for _, container := range podRunningContainers(pod) {
+			if component == "sidecars" && container.Name != "istio-proxy" {
+				continue
+			}
+			cpu.Add(*container.Resources.Requests.Cpu())
+			memory.Add(*container.Resources.Requests.Memory())
+		}
+		if cpu.IsZero() && memory.IsZero() {
+			unrequested++
+		}
+
+		cost := components[component]
+		cost.Pods++
+		cost.CPUCores += cpuCores(cpu)
+		cost.MemoryGiB += memoryGiB(memory)
+		workload := pod.Namespace
+		if component != "sidecars" {
+			app := pod.Labels["app"]
+			if app == "" {
+				app = pod.Labels["gateway.networking.k8s.io/gateway-name"]
+			}
+			workload += "/" + app
+		}
+		workloads[component][workload]++
+	}
+	if unrequested > 0 {
+		estimate.Notes = append(estimate.Notes, fmt.Sprintf("%d mesh pods request no CPU or memory; they still consume resources that this estimate cannot account for", unrequested))
+	}
+
+	for _, name := range meshCostComponents {
+		cost := components[name]
+		for workload, count := range workloads[name] {
+			cost.Workloads = append(cost.Workloads, fmt.Sprintf("%s (%d pods)", workload, count))
+		}
+		sort.Strings(cost.Workloads)
+		cost.CPUCores = round3(cost.CPUCores)
+		cost.MemoryGiB = round3(cost.MemoryGiB)
+		if priced {
+			cost.MonthlyCost = monthly(cost.CPUCores, cost.MemoryGiB)
+		}
+		estimate.TotalCPUCores += cost.CPUCores
+		estimate.TotalMemoryGiB += cost.MemoryGiB
+		estimate.Components = append(estimate.Components, *cost)
+	}
+	estimate.TotalCPUCores = round3(estimate.TotalCPUCores)
+	estimate.TotalMemoryGiB = round3(estimate.TotalMemoryGiB)
+	if priced {
+		estimate.TotalMonthlyCost = monthly(estimate.TotalCPUCores, estimate.TotalMemoryGiB)
+	}
+	if estimate.ClusterCPUCores > 0 {
+		estimate.ClusterCPUPercent = round3(100 * estimate.TotalCPUCores / estimate.ClusterCPUCores)
+	}
+	if estimate.ClusterMemoryGiB > 0 {
+		estimate.ClusterMemPercent = round3(100 * estimate.TotalMemoryGiB / estimate.ClusterMemoryGiB)
+	}
+	estimate.ClusterCPUCores = round3(estimate.ClusterCPUCores)
+	estimate.ClusterMemoryGiB = round3(estimate.ClusterMemoryGiB)
+
+	// Project the sidecars of the requested namespaces, or of every namespace not yet in the mesh
+	targets := params.Namespaces
+	if len(targets) == 0 {
+		namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+					},
+				},
+			}, nil
+		}
+		for i := range namespaces.Items {
+			ns := &namespaces.Items[i]
+			if namespaceInjectionRevision(ns) != "" || ns.Labels["istio.io/dataplane-mode"] == "ambient" ||
+				ns.Name == params.IstioNamespace || strings.HasPrefix(ns.Name, "kube-") || len(namespacePods[ns.Name]) == 0 {
+				continue
+			}
+			targets = append(targets, ns.Name)
+		}
+	}
+	sort.Strings(targets)
+	projectedCPU, projectedMemory := estimate.TotalCPUCores, estimate.TotalMemoryGiB
+	for _, namespace := range targets {
+		projection := InjectionProjection{Namespace: namespace}
+		for _, pod := range namespacePods[namespace] {
+			if podHasSidecar(pod) {
+				continue
+			}
+			if pod.Spec.HostNetwork || pod.Labels["sidecar.istio.io/inject"] == "false" || pod.Annotations["sidecar.istio.io/inject"] == "false" {
+				projection.Skipped++
+				continue
+			}
+			cpu, memory := proxyCPU, proxyMemory
+			if value, err := resource.ParseQuantity(pod.Annotations["sidecar.istio.io/proxyCPU"]); err == nil {
+				cpu = value
+			}
+			if value, err := resource.ParseQuantity(pod.Annotations["sidecar.istio.io/proxyMemory"]); err == nil {
+				memory = value
+			}
+			projection.Pods++
+			projection.CPUCores += cpuCores(cpu)
+			projection.MemoryGiB += memoryGiB(memory)
+		}
+		projection.CPUCores = round3(projection.CPUCores)
+		projection.MemoryGiB = round3(projection.MemoryGiB)
+		if priced {
+			projection.MonthlyCost = monthly(projection.CPUCores, projection.MemoryGiB)
+		}
+		projectedCPU += projection.CPUCores
+		projectedMemory += projection.MemoryGiB
+		estimate.Projections = append(estimate.Projections, projection)
+	}
+	if len(estimate.Projections) > 0 {
+		estimate.ProjectedCPUCores = round3(projectedCPU)
+		estimate.ProjectedMemoryGiB = round3(projectedMemory)
+		if priced {
+			estimate.ProjectedCost = monthly(estimate.ProjectedCPUCores, estimate.ProjectedMemoryGiB)
+		}
+		estimate.Notes = append(estimate.Notes, "Projections count the pods running now at the proxy request above (or their sidecar.istio.io/proxyCPU and proxyMemory annotations); istiod needs more memory as the number of proxies grows")
+	}
+	if !priced {
+		estimate.Notes = append(estimate.Notes, "Set cpu_core_hour_price and memory_gib_hour_price to convert requests into a monthly cost")
+	}
+
+	resultJSON, _ := json.MarshalIndent(estimate, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// meshPodComponent returns the mesh component group a pod belongs to, or "" for pods outside the mesh
+func meshPodComponent(pod *corev1.Pod) string {
+	switch {
+	case pod.Labels["app"] == "istiod":
+		return "control_plane"
+	case pod.Labels["gateway.istio.io/managed"] == "istio.io-mesh-controller":
+		return "waypoints"
+	case pod.Labels["istio"] == "ingressgateway" || pod.Labels["istio"] == "egressgateway" ||
+		pod.Labels["gateway.networking.k8s.io/gateway-name"] != "":
+		return "gateways"
+	case pod.Labels["app"] == "ztunnel" || pod.Labels["k8s-app"] == "istio-cni-node":
+		return "node_agents"
+	case podHasSidecar(pod):
+		return "sidecars"
+	}
+	return ""
+}
+
+// podRunningContainers returns the containers that run for the life of a pod, including native sidecars
+func podRunningContainers(pod *corev1.Pod) []corev1.Container {
+	containers := append([]corev1.Container{}, pod.Spec.Containers...)
+	for _, container := range pod.Spec.InitContainers {
+		if container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			containers = append(containers, container)
+		}
+	}
+	return containers
+}
+
+// cpuCores converts a CPU quantity to cores
+func cpuCores(q resource.Quantity) float64 {
+	return float64(q.MilliValue()) / 1000
+}
+
+// memoryGiB converts a memory quantity to GiB
+func memoryGiB(q resource.Quantity) float64 {
+	return float64(q.Value()) / (1 << 30)
+}
+
+// round3 rounds to three decimals for readable reports
+func round3(value float64) float64 {
+	return math.Round(value*1000) / 1000
+}
@@ -0,0 +1,379 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+// maxMeshEventWatch caps how long watch_mesh_events blocks a tool call
+const maxMeshEventWatch = 10 * time.Minute
+
+// MeshEvent is a Warning event or container restart observed while watching
+type MeshEvent struct {
+	Time      time.Time `json:"time"`
+	Kind      string    `json:"kind"` // warning_event or pod_restart
+	Namespace string    `json:"namespace"`
+	Object    string    `json:"object"` // Kind/name of the involved object
+	Reason    string    `json:"reason"`
+	Message   string    `json:"message,omitempty"`
+	Count     int32     `json:"count,omitempty"` // occurrences of a Warning event while watching
+}
+
+// MeshEventWatch is the timeline collected by watch_mesh_events
+type MeshEventWatch struct {
+	Namespaces []string       `json:"namespaces"`
+	StartedAt  time.Time      `json:"started_at"`
+	EndedAt    time.Time      `json:"ended_at"`
+	StoppedBy  string         `json:"stopped_by"` // duration, first_warning or max_events
+	Summary    map[string]int `json:"summary"`
+	Events     []MeshEvent    `json:"events"`
+	Issues     []string       `json:"issues,omitempty"`
+}
+
+// meshEventCollector gathers events from the namespace watches into one timeline
+type meshEventCollector struct {
+	mu       sync.Mutex
+	events   []MeshEvent
+	byUID    map[string]int // Warning event UID to its index in events
+	baseline map[string]int32
+	issues   []string
+	notify   chan struct{}
+}
+
+// watchMeshEventsParams are the arguments of watch_mesh_events
+type watchMeshEventsParams struct {
+	IstioNamespace  string   `json:"istio_namespace,omitempty"`  // default: istio-system
+	Namespaces      []string `json:"namespaces,omitempty"`       // watched in addition to the Istio namespace
+	IncludeGateways *bool    `json:"include_gateways,omitempty"` // default: true
+	Duration        string   `json:"duration,omitempty"`         // default: 1m
+	StopOnWarning   bool     `json:"stop_on_warning,omitempty"`  // return at the first warning or restart
+	MaxEvents       int      `json:"max_events,omitempty"`       // default: 200
+}
+
+// WatchMeshEvents watches the Istio and gateway namespaces for a bounded duration and returns the Warning
+// events and container restarts seen meanwhile, so an install or upgrade can be followed as it happens
+func (m *Manager) WatchMeshEvents(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params watchMeshEventsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.IncludeGateways == nil {
+		params.IncludeGateways = boolPtr(true)
+	}
+	if params.Duration == "" {
+		params.Duration = "1m"
+	}
+	if params.MaxEvents == 0 {
+		params.MaxEvents = 200
+	}
+
+	duration, err := time.ParseDuration(params.Duration)
+	if err != nil || duration <= 0 || duration > maxMeshEventWatch {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %q: must be a positive duration of at most %s", params.Duration, maxMeshEventWatch),
+				},
+			},
+		}, nil
+	}
+
+	namespaces := []string{params.IstioNamespace}
+	for _, namespace := range params.Namespaces {
+		if !containsString(namespaces, namespace) {
+			namespaces = append(namespaces, namespace)
+		}
+	}
+	if *params.IncludeGateways {
+		gatewayNamespaces, err := m.gatewayNamespaces(ctx)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to find gateway namespaces: %v", err),
+					},
+				},
+			}, nil
+		}
+		for _, namespace := range gatewayNamespaces {
+			if !containsString(namespaces, namespace) {
+				namespaces = append(namespaces, namespace)
+			}
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	collector := &meshEventCollector{
+		byUID:    make(map[string]int),
+		baseline: make(map[string]int32),
+		notify:   make(chan struct{}, 1),
+	}
+	result := &MeshEventWatch{
+		Namespaces: namespaces,
+		StartedAt:  time.Now(),
+		Summary:    make(map[string]int),
+	}
+
+	// Start from the current resource versions so only what happens from now on is reported
+	var wg sync.WaitGroup
+	for _, namespace := range namespaces {
+		events, err := m.k8sClient.Kubernetes.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{FieldSelector: "type=Warning"})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to list events in %s: %v", namespace, err))
+		} else {
+			wg.Add(1)
+			go func(namespace, resourceVersion string) {
+				defer wg.Done()
+				m.watchWarningEvents(ctx, namespace, resourceVersion, collector)
+			}(namespace, events.ResourceVersion)
+		}
+
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to list pods in %s: %v", namespace, err))
+			continue
+		}
+		for i := range pods.Items {
+			collector.restarts(&pods.Items[i], true)
+		}
+		wg.Add(1)
+		go func(namespace, resourceVersion string) {
+			defer wg.Done()
+			m.watchPodRestarts(ctx, namespace, resourceVersion, collector)
+		}(namespace, pods.ResourceVersion)
+	}
+
+	result.StoppedBy = "duration"
+	for done := false; !done; {
+		select {
+		case <-ctx.Done():
+			done = true
+		case <-collector.notify:
+			count := collector.count()
+			if params.StopOnWarning && count > 0 {
+				result.StoppedBy = "first_warning"
+				done = true
+			} else if count >= params.MaxEvents {
+				result.StoppedBy = "max_events"
+				done = true
+			}
+		}
+	}
+	cancel()
+	wg.Wait()
+	result.EndedAt = time.Now()
+
+	collector.mu.Lock()
+	result.Events = collector.events
+	result.Issues = append(result.Issues, collector.issues...)
+	collector.mu.Unlock()
+	if result.Events == nil {
+		result.Events = []MeshEvent{}
+	}
+	sort.SliceStable(result.Events, func(i, j int) bool { return result.Events[i].Time.Before(result.Events[j].Time) })
+	if len(result.Events) > params.MaxEvents {
+		result.Events = result.Events[:params.MaxEvents]
+	}
+	for _, event := range result.Events {
+		result.Summary[event.Kind]++
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// gatewayNamespaces finds the namespaces running Istio ingress, egress or Gateway API gateways, leaving out
+// waypoints, which live in application namespaces
+func (m *Manager) gatewayNamespaces(ctx context.Context) ([]string, error) {
+	var namespaces []string
+	for _, selector := range []string{"istio in (ingressgateway,egressgateway)", "gateway.networking.k8s.io/gateway-name"} {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: selector})
+		if err != nil {
+			return nil, err
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Labels["gateway.istio.io/managed"] == "istio.io-mesh-controller" || containsString(namespaces, pod.Namespace) {
+				continue
+			}
+			namespaces = append(namespaces, pod.Namespace)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+// watchWarningEvents feeds the Warning events of a namespace to the collector until ctx ends, re-establishing
+// the watch when the API server closes it
+func (m *Manager) watchWarningEvents(ctx context.Context, namespace, resourceVersion string, collector *meshEventCollector) {
+	for ctx.Err() == nil {
+		watcher, err := m.k8sClient.Kubernetes.CoreV1().Events(namespace).Watch(ctx, metav1.ListOptions{
+			FieldSelector:   "type=Warning",
+			ResourceVersion: resourceVersion,
+		})
+		if err != nil {
+			if ctx.Err() == nil {
+				collector.issue(fmt.Sprintf("Failed to watch events in %s: %v", namespace, err))
+			}
+			return
+		}
+		for change := range watcher.ResultChan() {
+			event, ok := change.Object.(*corev1.Event)
+			if !ok {
+				continue
+			}
+			resourceVersion = event.ResourceVersion
+			if change.Type == watch.Added || change.Type == watch.Modified {
+				collector.warning(event)
+			}
+		}
+		watcher.Stop()
+	}
+}
+
+// watchPodRestarts feeds container restarts in a namespace to the collector until ctx ends
+func (m *Manager) watchPodRestarts(ctx context.Context, namespace, resourceVersion string, collector *meshEventCollector) {
+	for ctx.Err() == nil {
+		watcher, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).Watch(ctx, metav1.ListOptions{ResourceVersion: resourceVersion})
+		if err != nil {
+			if ctx.Err() == nil {
+				collector.issue(fmt.Sprintf("Failed to watch pods in %s: %v", namespace, err))
+			}
+			return
+		}
+		for change := range watcher.ResultChan() {
+			pod, ok := change.Object.(*corev1.Pod)
+			if !ok {
+				continue
+			}
+			resourceVersion = pod.ResourceVersion
+			if change.Type == watch.Added || change.Type == watch.Modified {
+				collector.restarts(pod, false)
+			}
+		}
+		watcher.Stop()
+	}
+}
+
+// warning records a Warning event, folding repeats of the same event into one entry
+func (c *meshEventCollector) warning(event *corev1.Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if i, ok := c.byUID[string(event.UID)]; ok {
+		c.events[i].Count++
+		c.events[i].Message = event.Message
+		c.signal()
+		return
+	}
+	seen := event.LastTimestamp.Time
+	if event.EventTime.Time.After(seen) {
+		seen = event.EventTime.Time
+	}
+	if seen.IsZero() {
+		seen = time.Now()
+	}
+	c.byUID[string(event.UID)] = len(c.events)
+	c.events = append(c.events, MeshEvent{
+		Time:      seen,
+		Kind:      "warning_event",
+		Namespace: event.Namespace,
+		Object:    event.InvolvedObject.Kind + "/" + event.InvolvedObject.Name,
+		Reason:    event.Reason,
+		Message:   event.Message,
+		Count:     1,
+	})
+	c.signal()
+}
+
+// restarts records containers whose restart count grew since the pod was last seen; with baseline set it only
+// remembers the current counts, so restarts from before the watch are not reported
+func (c *meshEventCollector) restarts(pod *corev1.Pod, baseline bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, status := range statuses {
+		key := pod.Namespace + "/" + pod.Name + "/" + status.Name
+		previous := c.baseline[key]
+		c.baseline[key] = status.RestartCount
+		if baseline || status.RestartCount <= previous {
+			continue
+		}
+
+		reason := "Restarted"
+		message := fmt.Sprintf("container %s restarted (restart count %d)", status.Name, status.RestartCount)
+		if terminated := status.LastTerminationState.Terminated; terminated != nil {
+			if terminated.Reason != "" {
+				reason = terminated.Reason
+			}
+			message = fmt.Sprintf("container %s restarted after exiting with code %d (restart count %d)", status.Name, terminated.ExitCode, status.RestartCount)
+		}
+		c.events = append(c.events, MeshEvent{
+			Time:      time.Now(),
+			Kind:      "pod_restart",
+			Namespace: pod.Namespace,
+			Object:    "Pod/" + pod.Name,
+			Reason:    reason,
+			Message:   message,
+		})
+		c.signal()
+	}
+}
+
+// issue records a watch that could not be kept open
+func (c *meshEventCollector) issue(message string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.issues = append(c.issues, message)
+}
+
+// count returns the number of entries collected so far
+func (c *meshEventCollector) count() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.events)
+}
+
+// signal wakes the watch loop without blocking; callers hold mu
+func (c *meshEventCollector) signal() {
+	select {
+	case c.notify <- struct{}{}:
+	default:
+	}
+}
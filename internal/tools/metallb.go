@@ -0,0 +1,241 @@
+package tools
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// installMetalLBParams are the arguments of install_metallb
+type installMetalLBParams struct {
+	Namespace     string   `json:"namespace,omitempty"`      // default: metallb-system
+	Version       string   `json:"version,omitempty"`        // chart version
+	AddressPool   []string `json:"address_pool,omitempty"`   // CIDRs or ranges, default: detected on kind
+	PoolName      string   `json:"pool_name,omitempty"`      // default: meshpilot-pool
+	DockerNetwork string   `json:"docker_network,omitempty"` // default: kind
+	Timeout       string   `json:"timeout,omitempty"`        // default: 5m
+}
+
+// InstallMetalLB installs MetalLB and configures an L2 address pool for LoadBalancer services
+func (m *Manager) InstallMetalLB(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params installMetalLBParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "metallb-system"
+	}
+	if params.PoolName == "" {
+		params.PoolName = "meshpilot-pool"
+	}
+	if params.DockerNetwork == "" {
+		params.DockerNetwork = "kind"
+		if network := os.Getenv("KIND_EXPERIMENTAL_DOCKER_NETWORK"); network != "" {
+			params.DockerNetwork = network
+		}
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	// Resolve the address pool before installing anything
+	if len(params.AddressPool) == 0 {
+		isKind, err := m.isKindCluster(ctx)
+		if err != nil || !isKind {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "address_pool is required: the address range can only be detected automatically on kind clusters",
+					},
+				},
+			}, nil
+		}
+		pool, err := detectDockerNetworkPool(ctx, params.DockerNetwork)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to detect an address pool from docker network %s (pass address_pool explicitly): %v", params.DockerNetwork, err),
+					},
+				},
+			}, nil
+		}
+		params.AddressPool = []string{pool}
+	}
+
+	// Check if Helm is available
+	if err := m.checkHelmAvailable(); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
+				},
+			},
+		}, nil
+	}
+
+	repo := m.config.Helm.MetalLB
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to add MetalLB Helm repository: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.installMetalLBChart(ctx, repo.ChartRef("metallb"), params.Namespace, params.Version, params.Timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to install MetalLB: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.applyMetalLBPool(ctx, params.Namespace, params.PoolName, params.AddressPool); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("MetalLB installed but the address pool could not be configured: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: fmt.Sprintf("MetalLB installed in namespace '%s' with L2 address pool '%s' (%s). LoadBalancer services such as the ingress gateway will now receive an external IP.",
+					params.Namespace, params.PoolName, strings.Join(params.AddressPool, ", ")),
+			},
+		},
+	}, nil
+}
+
+// installMetalLBChart installs or upgrades the MetalLB chart and waits for it to be ready
+func (m *Manager) installMetalLBChart(ctx context.Context, chart, namespace, version, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:         "metallb",
+		Chart:           chart,
+		Namespace:       namespace,
+		Version:         version,
+		CreateNamespace: true,
+		Wait:            true,
+		Timeout:         timeout,
+		Upgrade:         true,
+	})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("MetalLB installed: %s", release)
+	return nil
+}
+
+// applyMetalLBPool creates the IPAddressPool and L2Advertisement, retrying while the MetalLB webhook starts
+func (m *Manager) applyMetalLBPool(ctx context.Context, namespace, poolName string, addresses []string) error {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("apiVersion: metallb.io/v1beta1\nkind: IPAddressPool\nmetadata:\n  name: %s\n  namespace: %s\nspec:\n  addresses:\n", poolName, namespace))
+	for _, address := range addresses {
+		sb.WriteString(fmt.Sprintf("  - %s\n", address))
+	}
+	sb.WriteString(fmt.Sprintf("---\napiVersion: metallb.io/v1beta1\nkind: L2Advertisement\nmetadata:\n  name: %s\n  namespace: %s\nspec:\n  ipAddressPools:\n  - %s\n", poolName, namespace, poolName))
+	manifest := sb.String()
+
+	var lastErr error
+	for attempt := 0; attempt < 10; attempt++ {
+		cmd := m.kubectlCommand(ctx, "apply", "-f", "-")
+		cmd.Stdin = strings.NewReader(manifest)
+		output, err := cmd.CombinedOutput()
+		if err == nil {
+			logrus.Infof("MetalLB pool apply output: %s", string(output))
+			return nil
+		}
+		lastErr = fmt.Errorf("kubectl apply failed: %w, output: %s", err, string(output))
+		logrus.Debugf("Retrying MetalLB pool apply: %v", lastErr)
+		time.Sleep(3 * time.Second)
+	}
+	return lastErr
+}
+
+// isKindCluster reports whether the current cluster's nodes are provisioned by kind
+func (m *Manager) isKindCluster(ctx context.Context) (bool, error) {
+	nodes, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return false, err
+	}
+	for _, node := range nodes.Items {
+		if strings.HasPrefix(node.Spec.ProviderID, "kind://") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// detectDockerNetworkPool picks an address range at the top of the docker network's IPv4 subnet,
+// away from the addresses docker assigns to kind nodes
+func detectDockerNetworkPool(ctx context.Context, network string) (string, error) {
+	cmd := exec.CommandContext(ctx, "docker", "network", "inspect", network, "--format", "{{range .IPAM.Config}}{{.Subnet}} {{end}}")
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker network inspect failed: %w, output: %s", err, string(output))
+	}
+
+	for _, subnet := range strings.Fields(string(output)) {
+		_, ipNet, err := net.ParseCIDR(subnet)
+		if err != nil || ipNet.IP.To4() == nil {
+			continue
+		}
+		ones, bits := ipNet.Mask.Size()
+		if bits-ones < 8 {
+			return "", fmt.Errorf("subnet %s is too small for an address pool", subnet)
+		}
+
+		// Broadcast address of the subnet
+		base := binary.BigEndian.Uint32(ipNet.IP.To4())
+		broadcast := base | ^binary.BigEndian.Uint32(net.IP(ipNet.Mask).To4())
+		start := make(net.IP, 4)
+		end := make(net.IP, 4)
+		binary.BigEndian.PutUint32(start, broadcast-55)
+		binary.BigEndian.PutUint32(end, broadcast-5)
+		return fmt.Sprintf("%s-%s", start, end), nil
+	}
+
+	return "", fmt.Errorf("no IPv4 subnet found on docker network %s", network)
+}
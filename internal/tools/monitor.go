@@ -0,0 +1,617 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// maxMonitorProbes caps the number of probes a monitor keeps regardless of retention
+const maxMonitorProbes = 20000
+
+// MonitorProbe represents the outcome of a single background probe
+type MonitorProbe struct {
+	Endpoint   string    `json:"endpoint"`
+	SourcePod  string    `json:"source_pod,omitempty"`
+	Success    bool      `json:"success"`
+	StatusCode int       `json:"status_code,omitempty"`
+	LatencyMs  float64   `json:"latency_ms"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// EndpointStats summarizes the probes of one endpoint within a window
+type EndpointStats struct {
+	Endpoint     string     `json:"endpoint"`
+	Probes       int        `json:"probes"`
+	Failures     int        `json:"failures"`
+	SuccessRate  float64    `json:"success_rate"`
+	AvgLatencyMs float64    `json:"avg_latency_ms"`
+	MaxLatencyMs float64    `json:"max_latency_ms"`
+	LastFailure  *time.Time `json:"last_failure,omitempty"`
+	LastError    string     `json:"last_error,omitempty"`
+	Stable       bool       `json:"stable"`
+}
+
+// MonitorSummary represents the state and rolling results of a connectivity monitor
+type MonitorSummary struct {
+	Name            string          `json:"name"`
+	Running         bool            `json:"running"`
+	SourceNamespace string          `json:"source_namespace"`
+	SourcePod       string          `json:"source_pod,omitempty"`
+	SourceSelector  string          `json:"source_selector,omitempty"`
+	Endpoints       []string        `json:"endpoints"`
+	Interval        string          `json:"interval"`
+	Retention       string          `json:"retention"`
+	StartedAt       time.Time       `json:"started_at"`
+	Window          string          `json:"window,omitempty"`
+	WindowCovered   bool            `json:"window_covered"` // false when the monitor started after the window began
+	Stable          bool            `json:"stable"`
+	EndpointStats   []EndpointStats `json:"endpoint_stats,omitempty"`
+	RecentFailures  []MonitorProbe  `json:"recent_failures,omitempty"`
+}
+
+// connectivityMonitor periodically probes endpoints from a pod inside the cluster
+type connectivityMonitor struct {
+	name            string
+	sourceNamespace string
+	sourcePod       string
+	sourceSelector  string
+	container       string
+	endpoints       []string
+	interval        time.Duration
+	timeout         time.Duration
+	retention       time.Duration
+	startedAt       time.Time
+	cancel          context.CancelFunc
+	done            chan struct{}
+
+	mu     sync.Mutex
+	probes []MonitorProbe
+}
+
+// startMonitorParams are the arguments of start_monitor
+type startMonitorParams struct {
+	Name            string   `json:"name"`
+	Endpoints       []string `json:"endpoints"`                  // http(s)://host:port/path or tcp://host:port
+	SourceNamespace string   `json:"source_namespace,omitempty"` // default: default
+	SourcePod       string   `json:"source_pod,omitempty"`       // default: first ready pod matching source_selector
+	SourceSelector  string   `json:"source_selector,omitempty"`  // default: app=sleep
+	Container       string   `json:"container,omitempty"`        // default: sleep
+	Interval        string   `json:"interval,omitempty"`         // default: 30s
+	Timeout         int      `json:"timeout,omitempty"`          // seconds, default: 5
+	Retention       string   `json:"retention,omitempty"`        // default: 2h
+}
+
+// StartMonitor starts a background monitor that probes endpoints at a fixed interval
+func (m *Manager) StartMonitor(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params startMonitorParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Name == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "name is required",
+				},
+			},
+		}, nil
+	}
+	if len(params.Endpoints) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "endpoints is required",
+				},
+			},
+		}, nil
+	}
+	for _, endpoint := range params.Endpoints {
+		if _, _, err := monitorProbeCommand(endpoint, 1); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid endpoint %q: %v", endpoint, err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	// Set defaults
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = "default"
+	}
+	if params.SourceSelector == "" && params.SourcePod == "" {
+		params.SourceSelector = "app=sleep"
+	}
+	if params.Container == "" {
+		params.Container = "sleep"
+	}
+	if params.Interval == "" {
+		params.Interval = "30s"
+	}
+	if params.Timeout == 0 {
+		params.Timeout = 5
+	}
+	if params.Retention == "" {
+		params.Retention = "2h"
+	}
+
+	interval, err := time.ParseDuration(params.Interval)
+	if err != nil || interval < time.Second {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid interval %q: must be a duration of at least 1s", params.Interval),
+				},
+			},
+		}, nil
+	}
+	retention, err := time.ParseDuration(params.Retention)
+	if err != nil || retention < interval {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid retention %q: must be a duration no shorter than the interval", params.Retention),
+				},
+			},
+		}, nil
+	}
+
+	m.monitorsMu.Lock()
+	defer m.monitorsMu.Unlock()
+
+	if existing, ok := m.monitors[params.Name]; ok && existing.isRunning() {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Monitor %s is already running; stop it first", params.Name),
+				},
+			},
+		}, nil
+	}
+
+	// The monitor outlives the tool call that starts it
+	ctx, cancel := context.WithCancel(context.Background())
+	monitor := &connectivityMonitor{
+		name:            params.Name,
+		sourceNamespace: params.SourceNamespace,
+		sourcePod:       params.SourcePod,
+		sourceSelector:  params.SourceSelector,
+		container:       params.Container,
+		endpoints:       params.Endpoints,
+		interval:        interval,
+		timeout:         time.Duration(params.Timeout) * time.Second,
+		retention:       retention,
+		startedAt:       time.Now(),
+		cancel:          cancel,
+		done:            make(chan struct{}),
+	}
+	m.monitors[params.Name] = monitor
+
+	go m.runMonitor(ctx, monitor)
+
+	summary := monitor.summarize(0)
+	resultJSON, _ := json.MarshalIndent(summary, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// stopMonitorParams are the arguments of stop_monitor
+type stopMonitorParams struct {
+	Name   string `json:"name"`
+	Forget bool   `json:"forget,omitempty"` // discard the stored results as well
+}
+
+// StopMonitor stops a running monitor and returns its final results
+func (m *Manager) StopMonitor(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params stopMonitorParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	m.monitorsMu.Lock()
+	monitor, ok := m.monitors[params.Name]
+	if ok && params.Forget {
+		delete(m.monitors, params.Name)
+	}
+	m.monitorsMu.Unlock()
+
+	if !ok {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Monitor %s not found", params.Name),
+				},
+			},
+		}, nil
+	}
+
+	monitor.cancel()
+	<-monitor.done
+
+	summary := monitor.summarize(0)
+	resultJSON, _ := json.MarshalIndent(summary, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getMonitorResultsParams are the arguments of get_monitor_results
+type getMonitorResultsParams struct {
+	Name   string `json:"name,omitempty"`   // default: all monitors
+	Window string `json:"window,omitempty"` // default: 30m
+}
+
+// GetMonitorResults summarizes the rolling results of one or all monitors over a window
+func (m *Manager) GetMonitorResults(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getMonitorResultsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Window == "" {
+		params.Window = "30m"
+	}
+	window, err := time.ParseDuration(params.Window)
+	if err != nil || window <= 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid window %q", params.Window),
+				},
+			},
+		}, nil
+	}
+
+	m.monitorsMu.Lock()
+	var monitors []*connectivityMonitor
+	for name, monitor := range m.monitors {
+		if params.Name == "" || name == params.Name {
+			monitors = append(monitors, monitor)
+		}
+	}
+	m.monitorsMu.Unlock()
+
+	if params.Name != "" && len(monitors) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Monitor %s not found", params.Name),
+				},
+			},
+		}, nil
+	}
+
+	sort.Slice(monitors, func(i, j int) bool { return monitors[i].name < monitors[j].name })
+
+	summaries := []MonitorSummary{}
+	for _, monitor := range monitors {
+		summaries = append(summaries, monitor.summarize(window))
+	}
+
+	resultData := map[string]interface{}{
+		"window":   window.String(),
+		"monitors": summaries,
+	}
+	resultJSON, _ := json.MarshalIndent(resultData, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// runMonitor probes the monitor endpoints until its context is cancelled
+func (m *Manager) runMonitor(ctx context.Context, monitor *connectivityMonitor) {
+	defer close(monitor.done)
+
+	ticker := time.NewTicker(monitor.interval)
+	defer ticker.Stop()
+
+	for {
+		m.probeMonitorEndpoints(ctx, monitor)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// probeMonitorEndpoints runs one probe round against every endpoint of the monitor
+func (m *Manager) probeMonitorEndpoints(ctx context.Context, monitor *connectivityMonitor) {
+	sourcePod, err := m.resolveMonitorSource(ctx, monitor)
+	for _, endpoint := range monitor.endpoints {
+		probe := MonitorProbe{
+			Endpoint:  endpoint,
+			SourcePod: sourcePod,
+			Timestamp: time.Now(),
+		}
+		if err != nil {
+			probe.Error = err.Error()
+		} else {
+			m.runMonitorProbe(ctx, monitor, sourcePod, &probe)
+		}
+		if ctx.Err() != nil {
+			// Probes interrupted by stop_monitor are not real failures
+			return
+		}
+		if !probe.Success {
+			logrus.Debugf("Monitor %s probe to %s failed: %s", monitor.name, endpoint, probe.Error)
+		}
+		monitor.record(probe)
+		m.recordCheckOutcome(fmt.Sprintf("monitor %s: %s", monitor.name, endpoint), !probe.Success, []string{probe.Error})
+	}
+}
+
+// resolveMonitorSource returns the pod probes are sent from, re-resolving the selector
+// every round so the monitor survives pod restarts
+func (m *Manager) resolveMonitorSource(ctx context.Context, monitor *connectivityMonitor) (string, error) {
+	if monitor.sourcePod != "" {
+		return monitor.sourcePod, nil
+	}
+
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(monitor.sourceNamespace).List(ctx, metav1.ListOptions{
+		LabelSelector: monitor.sourceSelector,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list source pods: %w", err)
+	}
+	for _, pod := range pods.Items {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == corev1.PodReady && condition.Status == corev1.ConditionTrue {
+				return pod.Name, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("no ready source pod matches %s in namespace %s", monitor.sourceSelector, monitor.sourceNamespace)
+}
+
+// runMonitorProbe executes a single probe from the source pod and fills in its outcome
+func (m *Manager) runMonitorProbe(ctx context.Context, monitor *connectivityMonitor, sourcePod string, probe *MonitorProbe) {
+	command, isHTTP, err := monitorProbeCommand(probe.Endpoint, int(monitor.timeout.Seconds()))
+	if err != nil {
+		probe.Error = err.Error()
+		return
+	}
+
+	probeCtx, cancel := context.WithTimeout(ctx, monitor.timeout+10*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	output, err := m.execCommandInPod(probeCtx, monitor.sourceNamespace, sourcePod, monitor.container, command)
+	probe.LatencyMs = float64(time.Since(start).Microseconds()) / 1000
+	if err != nil {
+		probe.Error = err.Error()
+		return
+	}
+
+	if !isHTTP {
+		probe.Success = true
+		return
+	}
+
+	// curl reports the status code and the request time, which excludes exec overhead
+	fields := strings.Fields(output)
+	if len(fields) >= 2 {
+		fmt.Sscanf(fields[0], "%d", &probe.StatusCode)
+		var seconds float64
+		if _, err := fmt.Sscanf(fields[1], "%f", &seconds); err == nil {
+			probe.LatencyMs = seconds * 1000
+		}
+	}
+	probe.Success = probe.StatusCode >= 200 && probe.StatusCode < 400
+	if !probe.Success {
+		probe.Error = fmt.Sprintf("unexpected HTTP status %d", probe.StatusCode)
+	}
+}
+
+// monitorProbeCommand builds the in-pod command for an endpoint and reports whether it is HTTP
+func monitorProbeCommand(endpoint string, timeout int) ([]string, bool, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return nil, false, err
+	}
+	if u.Host == "" {
+		return nil, false, fmt.Errorf("endpoint must include a scheme and host, e.g. http://httpbin.default:8000/get")
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return []string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code} %{time_total}",
+			"--max-time", fmt.Sprintf("%d", timeout), endpoint}, true, nil
+	case "tcp":
+		if u.Port() == "" {
+			return nil, false, fmt.Errorf("tcp endpoints require a port")
+		}
+		return []string{"nc", "-z", "-w", fmt.Sprintf("%d", timeout), u.Hostname(), u.Port()}, false, nil
+	default:
+		return nil, false, fmt.Errorf("unsupported scheme %q (use http, https or tcp)", u.Scheme)
+	}
+}
+
+// record stores a probe and drops results older than the retention period
+func (c *connectivityMonitor) record(probe MonitorProbe) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.probes = append(c.probes, probe)
+
+	cutoff := time.Now().Add(-c.retention)
+	drop := 0
+	for drop < len(c.probes) && c.probes[drop].Timestamp.Before(cutoff) {
+		drop++
+	}
+	if excess := len(c.probes) - drop - maxMonitorProbes; excess > 0 {
+		drop += excess
+	}
+	if drop > 0 {
+		c.probes = append([]MonitorProbe(nil), c.probes[drop:]...)
+	}
+}
+
+// isRunning reports whether the monitor goroutine is still active
+func (c *connectivityMonitor) isRunning() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// summarize aggregates the probes within window; a zero window covers all retained probes
+func (c *connectivityMonitor) summarize(window time.Duration) MonitorSummary {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	summary := MonitorSummary{
+		Name:            c.name,
+		Running:         c.isRunning(),
+		SourceNamespace: c.sourceNamespace,
+		SourcePod:       c.sourcePod,
+		SourceSelector:  c.sourceSelector,
+		Endpoints:       c.endpoints,
+		Interval:        c.interval.String(),
+		Retention:       c.retention.String(),
+		StartedAt:       c.startedAt,
+		WindowCovered:   true,
+	}
+
+	var since time.Time
+	if window > 0 {
+		summary.Window = window.String()
+		since = time.Now().Add(-window)
+		summary.WindowCovered = !c.startedAt.After(since)
+	}
+
+	stats := make(map[string]*EndpointStats)
+	for _, endpoint := range c.endpoints {
+		stats[endpoint] = &EndpointStats{Endpoint: endpoint}
+	}
+
+	var failures []MonitorProbe
+	for _, probe := range c.probes {
+		if probe.Timestamp.Before(since) {
+			continue
+		}
+		s, ok := stats[probe.Endpoint]
+		if !ok {
+			continue
+		}
+		s.Probes++
+		s.AvgLatencyMs += probe.LatencyMs
+		if probe.LatencyMs > s.MaxLatencyMs {
+			s.MaxLatencyMs = probe.LatencyMs
+		}
+		if !probe.Success {
+			s.Failures++
+			timestamp := probe.Timestamp
+			s.LastFailure = &timestamp
+			s.LastError = probe.Error
+			failures = append(failures, probe)
+		}
+	}
+
+	summary.Stable = true
+	for _, endpoint := range c.endpoints {
+		s := stats[endpoint]
+		if s.Probes > 0 {
+			s.AvgLatencyMs /= float64(s.Probes)
+			s.SuccessRate = float64(s.Probes-s.Failures) / float64(s.Probes) * 100
+		}
+		s.Stable = s.Probes > 0 && s.Failures == 0
+		if !s.Stable {
+			summary.Stable = false
+		}
+		summary.EndpointStats = append(summary.EndpointStats, *s)
+	}
+	if !summary.WindowCovered {
+		// Stability can't be claimed for time the monitor wasn't running
+		summary.Stable = false
+	}
+
+	// Keep the most recent failures so the agent can see what went wrong
+	if len(failures) > 20 {
+		failures = failures[len(failures)-20:]
+	}
+	summary.RecentFailures = failures
+
+	return summary
+}
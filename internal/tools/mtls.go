@@ -0,0 +1,549 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	apisecurityv1beta1 "istio.io/api/security/v1beta1"
+	apitypev1beta1 "istio.io/api/type/v1beta1"
+	securityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// MTLSPolicy is a PeerAuthentication as seen by get_mtls_status
+type MTLSPolicy struct {
+	Name      string            `json:"name"`
+	Namespace string            `json:"namespace"`
+	Scope     string            `json:"scope"` // mesh, namespace or workload
+	Selector  map[string]string `json:"selector,omitempty"`
+	Mode      string            `json:"mode"`
+	PortModes map[string]string `json:"port_modes,omitempty"`
+}
+
+// WorkloadMTLSStatus is the effective mTLS mode of one pod and the policy it comes from
+type WorkloadMTLSStatus struct {
+	Pod       string            `json:"pod"`
+	Namespace string            `json:"namespace"`
+	Dataplane string            `json:"dataplane"` // sidecar, ambient or none
+	Mode      string            `json:"mode"`
+	Source    string            `json:"source"` // namespace/name of the deciding PeerAuthentication, or "default"
+	PortModes map[string]string `json:"port_modes,omitempty"`
+	Enforced  bool              `json:"enforced"` // false when no proxy terminates mTLS for the pod
+}
+
+// MTLSStatusReport is the result of get_mtls_status
+type MTLSStatusReport struct {
+	RootNamespace string               `json:"root_namespace"`
+	Namespace     string               `json:"namespace,omitempty"`
+	MeshMode      string               `json:"mesh_mode"`
+	Policies      []MTLSPolicy         `json:"policies"`
+	Workloads     []WorkloadMTLSStatus `json:"workloads"`
+	Summary       map[string]int       `json:"summary"`
+	Issues        []string             `json:"issues,omitempty"`
+	Timestamp     time.Time            `json:"timestamp"`
+}
+
+// MTLSChangeResult is the result of set_mtls_mode
+type MTLSChangeResult struct {
+	Action   string           `json:"action"` // created or updated
+	Scope    string           `json:"scope"`  // mesh, namespace or workload
+	DryRun   bool             `json:"dry_run,omitempty"`
+	Resource *TrafficResource `json:"resource"`
+	Warnings []string         `json:"warnings,omitempty"`
+}
+
+// setMTLSModeParams are the arguments of set_mtls_mode
+type setMTLSModeParams struct {
+	Mode           string            `json:"mode"`                      // STRICT, PERMISSIVE, DISABLE or UNSET
+	Namespace      string            `json:"namespace,omitempty"`       // default: the mesh root namespace (mesh-wide)
+	Selector       map[string]string `json:"selector,omitempty"`        // workload labels; namespace-wide when empty
+	PortLevel      map[string]string `json:"port_level,omitempty"`      // port -> mode, workload policies only
+	Name           string            `json:"name,omitempty"`            // default: "default", or the selector's app label
+	IstioNamespace string            `json:"istio_namespace,omitempty"` // default: istio-system
+	DryRun         bool              `json:"dry_run,omitempty"`
+}
+
+// SetMTLSMode creates or updates the PeerAuthentication that sets the mTLS mode of the mesh, a namespace
+// or the workloads matching a selector
+func (m *Manager) SetMTLSMode(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params setMTLSModeParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	params.Mode = strings.ToUpper(params.Mode)
+	mode, ok := apisecurityv1beta1.PeerAuthentication_MutualTLS_Mode_value[params.Mode]
+	if !ok {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid mode %q: must be STRICT, PERMISSIVE, DISABLE or UNSET", params.Mode),
+				},
+			},
+		}, nil
+	}
+	if len(params.PortLevel) > 0 && len(params.Selector) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "port_level requires a selector: Istio only honors port-level mTLS in workload policies",
+				},
+			},
+		}, nil
+	}
+
+	spec := apisecurityv1beta1.PeerAuthentication{
+		Mtls: &apisecurityv1beta1.PeerAuthentication_MutualTLS{Mode: apisecurityv1beta1.PeerAuthentication_MutualTLS_Mode(mode)},
+	}
+	if len(params.Selector) > 0 {
+		spec.Selector = &apitypev1beta1.WorkloadSelector{MatchLabels: params.Selector}
+	}
+	for portName, portMode := range params.PortLevel {
+		port, err := strconv.ParseUint(portName, 10, 32)
+		value, ok := apisecurityv1beta1.PeerAuthentication_MutualTLS_Mode_value[strings.ToUpper(portMode)]
+		if err != nil || port == 0 || port > 65535 || !ok {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid port_level entry %q: %q; expected a port number and STRICT, PERMISSIVE, DISABLE or UNSET", portName, portMode),
+					},
+				},
+			}, nil
+		}
+		if spec.PortLevelMtls == nil {
+			spec.PortLevelMtls = make(map[uint32]*apisecurityv1beta1.PeerAuthentication_MutualTLS)
+		}
+		spec.PortLevelMtls[uint32(port)] = &apisecurityv1beta1.PeerAuthentication_MutualTLS{Mode: apisecurityv1beta1.PeerAuthentication_MutualTLS_Mode(value)}
+	}
+
+	ctx = withManagingTool(ctx, "set_mtls_mode")
+
+	rootNamespace := m.meshRootNamespace(ctx, params.IstioNamespace)
+	if params.Namespace == "" {
+		params.Namespace = rootNamespace
+	}
+	result := &MTLSChangeResult{Action: "created", DryRun: params.DryRun}
+	switch {
+	case len(params.Selector) > 0:
+		result.Scope = "workload"
+	case params.Namespace == rootNamespace:
+		result.Scope = "mesh"
+	default:
+		result.Scope = "namespace"
+	}
+
+	client := m.k8sClient.Istio.SecurityV1beta1().PeerAuthentications(params.Namespace)
+	existingPolicies, err := client.List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list PeerAuthentications in %s: %v", params.Namespace, err),
+				},
+			},
+		}, nil
+	}
+
+	// Istio uses only the oldest selector-less policy of a namespace, so update that one instead of adding another
+	var existing *securityv1beta1.PeerAuthentication
+	if result.Scope != "workload" && params.Name == "" {
+		for _, policy := range sortedPeerAuthentications(existingPolicies.Items) {
+			if len(policy.Spec.GetSelector().GetMatchLabels()) == 0 {
+				existing = policy
+				break
+			}
+		}
+	}
+	if params.Name == "" {
+		switch {
+		case existing != nil:
+			params.Name = existing.Name
+		case result.Scope != "workload":
+			params.Name = "default"
+		case params.Selector["app"] != "":
+			params.Name = params.Selector["app"]
+		default:
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "name is required for workload policies whose selector has no app label",
+					},
+				},
+			}, nil
+		}
+	}
+	if existing == nil {
+		for _, policy := range existingPolicies.Items {
+			if policy.Name == params.Name {
+				existing = policy
+			}
+		}
+	}
+
+	var applied *securityv1beta1.PeerAuthentication
+	if existing != nil {
+		// Keep the existing metadata so labels and annotations set by others survive the update
+		existing.Spec.Reset()
+		existing.Spec.Selector = spec.Selector
+		existing.Spec.Mtls = spec.Mtls
+		existing.Spec.PortLevelMtls = spec.PortLevelMtls
+		applied, err = client.Update(ctx, existing, metav1.UpdateOptions{DryRun: trafficDryRun(params.DryRun)})
+		result.Action = "updated"
+	} else {
+		policy := &securityv1beta1.PeerAuthentication{
+			ObjectMeta: metav1.ObjectMeta{Name: params.Name, Namespace: params.Namespace},
+		}
+		policy.Spec.Selector = spec.Selector
+		policy.Spec.Mtls = spec.Mtls
+		policy.Spec.PortLevelMtls = spec.PortLevelMtls
+		markManaged(ctx, policy)
+		applied, err = client.Create(ctx, policy, metav1.CreateOptions{DryRun: trafficDryRun(params.DryRun)})
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply PeerAuthentication %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+	specJSON, _ := applied.Spec.MarshalJSON()
+	result.Resource = trafficResource("PeerAuthentication", applied.ObjectMeta, specJSON)
+
+	if params.Mode == "STRICT" {
+		result.Warnings = m.strictModeWarnings(ctx, result.Scope, params.Namespace, params.Selector)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getMTLSStatusParams are the arguments of get_mtls_status
+type getMTLSStatusParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: all namespaces
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+}
+
+// GetMTLSStatus resolves the PeerAuthentication hierarchy (workload, namespace, mesh) into the effective
+// mTLS mode of every pod
+func (m *Manager) GetMTLSStatus(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getMTLSStatusParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	policyList, err := m.k8sClient.Istio.SecurityV1beta1().PeerAuthentications("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list PeerAuthentications: %v", err),
+				},
+			},
+		}, nil
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := &MTLSStatusReport{
+		RootNamespace: m.meshRootNamespace(ctx, params.IstioNamespace),
+		Namespace:     params.Namespace,
+		MeshMode:      "PERMISSIVE",
+		Policies:      []MTLSPolicy{},
+		Workloads:     []WorkloadMTLSStatus{},
+		Summary:       make(map[string]int),
+		Timestamp:     time.Now(),
+	}
+
+	// Istio picks the oldest policy when several apply at the same level
+	var meshPolicy *securityv1beta1.PeerAuthentication
+	namespacePolicies := make(map[string]*securityv1beta1.PeerAuthentication)
+	workloadPolicies := make(map[string][]*securityv1beta1.PeerAuthentication)
+	for _, policy := range sortedPeerAuthentications(policyList.Items) {
+		entry := mtlsPolicy(policy, report.RootNamespace)
+		switch entry.Scope {
+		case "mesh":
+			if meshPolicy != nil {
+				report.Issues = append(report.Issues, fmt.Sprintf("Mesh-wide PeerAuthentication %s/%s is ignored because %s is older", policy.Namespace, policy.Name, meshPolicy.Name))
+			} else {
+				meshPolicy = policy
+			}
+		case "namespace":
+			if previous, ok := namespacePolicies[policy.Namespace]; ok {
+				report.Issues = append(report.Issues, fmt.Sprintf("Namespace-wide PeerAuthentication %s/%s is ignored because %s is older", policy.Namespace, policy.Name, previous.Name))
+			} else {
+				namespacePolicies[policy.Namespace] = policy
+			}
+		default:
+			workloadPolicies[policy.Namespace] = append(workloadPolicies[policy.Namespace], policy)
+		}
+		if params.Namespace == "" || policy.Namespace == params.Namespace || entry.Scope == "mesh" {
+			report.Policies = append(report.Policies, entry)
+		}
+	}
+	if mode := peerAuthenticationMode(meshPolicy); mode != "" {
+		report.MeshMode = mode
+	}
+
+	namespaceModes := make(map[string]string)
+	if namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, ns := range namespaces.Items {
+			namespaceModes[ns.Name] = ns.Labels["istio.io/dataplane-mode"]
+		}
+	}
+
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Spec.HostNetwork {
+			continue
+		}
+		status := WorkloadMTLSStatus{
+			Pod:       pod.Name,
+			Namespace: pod.Namespace,
+			Mode:      report.MeshMode,
+			Source:    "default",
+		}
+		switch podInterceptionMode(pod, namespaceModes[pod.Namespace]) {
+		case "ambient":
+			status.Dataplane = "ambient"
+		case "none":
+			status.Dataplane = "none"
+		default:
+			status.Dataplane = "sidecar"
+		}
+		status.Enforced = status.Dataplane != "none"
+
+		if mode := peerAuthenticationMode(meshPolicy); mode != "" {
+			status.Source = meshPolicy.Namespace + "/" + meshPolicy.Name
+		}
+		if policy := namespacePolicies[pod.Namespace]; policy != nil {
+			if mode := peerAuthenticationMode(policy); mode != "" {
+				status.Mode = mode
+				status.Source = policy.Namespace + "/" + policy.Name
+			}
+		}
+		var matched []string
+		for _, policy := range workloadPolicies[pod.Namespace] {
+			if !labels.SelectorFromSet(policy.Spec.GetSelector().GetMatchLabels()).Matches(labels.Set(pod.Labels)) {
+				continue
+			}
+			matched = append(matched, policy.Name)
+			if len(matched) > 1 {
+				continue
+			}
+			if mode := peerAuthenticationMode(policy); mode != "" {
+				status.Mode = mode
+				status.Source = policy.Namespace + "/" + policy.Name
+			}
+			status.PortModes = mtlsPolicy(policy, report.RootNamespace).PortModes
+		}
+		if len(matched) > 1 {
+			report.Issues = append(report.Issues, fmt.Sprintf("Pod %s/%s matches several workload PeerAuthentications (%s); only the oldest, %s, applies",
+				pod.Namespace, pod.Name, strings.Join(matched, ", "), matched[0]))
+		}
+
+		report.Summary[strings.ToLower(status.Mode)]++
+		if !status.Enforced {
+			report.Summary["not_enforced"]++
+		}
+		report.Workloads = append(report.Workloads, status)
+	}
+
+	sort.Slice(report.Workloads, func(i, j int) bool {
+		if report.Workloads[i].Namespace != report.Workloads[j].Namespace {
+			return report.Workloads[i].Namespace < report.Workloads[j].Namespace
+		}
+		return report.Workloads[i].Pod < report.Workloads[j].Pod
+	})
+	if report.Summary["disable"] > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d pods accept only plaintext because their mTLS mode is DISABLE", report.Summary["disable"]))
+	}
+	if report.Summary["not_enforced"] > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d pods run without a sidecar or ambient redirection, so no policy is enforced for them and they receive plaintext", report.Summary["not_enforced"]))
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// meshRootNamespace returns meshConfig.rootNamespace, which defaults to the namespace istiod runs in
+func (m *Manager) meshRootNamespace(ctx context.Context, istioNamespace string) string {
+	var mesh struct {
+		RootNamespace string `json:"rootNamespace"`
+	}
+	if err := m.readMeshConfig(ctx, istioNamespace, defaultRevision, &mesh); err != nil || mesh.RootNamespace == "" {
+		return istioNamespace
+	}
+	return mesh.RootNamespace
+}
+
+// strictModeWarnings lists running pods without a proxy that can no longer call the workloads now under STRICT
+func (m *Manager) strictModeWarnings(ctx context.Context, scope, namespace string, selector map[string]string) []string {
+	listNamespace := namespace
+	if scope == "mesh" {
+		listNamespace = ""
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(listNamespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return []string{fmt.Sprintf("Could not check for clients without a proxy: %v", err)}
+	}
+	namespaceModes := make(map[string]string)
+	if namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		for _, ns := range namespaces.Items {
+			namespaceModes[ns.Name] = ns.Labels["istio.io/dataplane-mode"]
+		}
+	}
+
+	var plaintext, unprotected []string
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Status.Phase != corev1.PodRunning || pod.Spec.HostNetwork || podInterceptionMode(pod, namespaceModes[pod.Namespace]) != "none" {
+			continue
+		}
+		if len(selector) > 0 && labels.SelectorFromSet(selector).Matches(labels.Set(pod.Labels)) {
+			unprotected = append(unprotected, pod.Namespace+"/"+pod.Name)
+		} else {
+			plaintext = append(plaintext, pod.Namespace+"/"+pod.Name)
+		}
+	}
+
+	var warnings []string
+	if len(unprotected) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d selected pods have no proxy, so STRICT is not enforced for them: %s", len(unprotected), truncatedList(unprotected, 10)))
+	}
+	if len(plaintext) > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d pods without a proxy send plaintext and can no longer reach the workloads under STRICT: %s", len(plaintext), truncatedList(plaintext, 10)))
+	}
+	return warnings
+}
+
+// sortedPeerAuthentications orders policies oldest first, the order Istio resolves conflicts in
+func sortedPeerAuthentications(policies []*securityv1beta1.PeerAuthentication) []*securityv1beta1.PeerAuthentication {
+	sorted := append([]*securityv1beta1.PeerAuthentication{}, policies...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if !sorted[i].CreationTimestamp.Equal(&sorted[j].CreationTimestamp) {
+			return sorted[i].CreationTimestamp.Before(&sorted[j].CreationTimestamp)
+		}
+		return sorted[i].Namespace+"/"+sorted[i].Name < sorted[j].Namespace+"/"+sorted[j].Name
+	})
+	return sorted
+}
+
+// mtlsPolicy summarizes a PeerAuthentication and classifies its scope
+func mtlsPolicy(policy *securityv1beta1.PeerAuthentication, rootNamespace string) MTLSPolicy {
+	entry := MTLSPolicy{
+		Name:      policy.Name,
+		Namespace: policy.Namespace,
+		Selector:  policy.Spec.GetSelector().GetMatchLabels(),
+		Mode:      policy.Spec.GetMtls().GetMode().String(),
+	}
+	switch {
+	case len(entry.Selector) > 0:
+		entry.Scope = "workload"
+	case policy.Namespace == rootNamespace:
+		entry.Scope = "mesh"
+	default:
+		entry.Scope = "namespace"
+	}
+	for port, mtls := range policy.Spec.GetPortLevelMtls() {
+		if entry.PortModes == nil {
+			entry.PortModes = make(map[string]string)
+		}
+		entry.PortModes[strconv.FormatUint(uint64(port), 10)] = mtls.GetMode().String()
+	}
+	return entry
+}
+
+// peerAuthenticationMode returns the mode a policy sets, or "" when it inherits from the level above
+func peerAuthenticationMode(policy *securityv1beta1.PeerAuthentication) string {
+	if policy == nil {
+		return ""
+	}
+	mode := policy.Spec.GetMtls().GetMode()
+	if mode == apisecurityv1beta1.PeerAuthentication_MutualTLS_UNSET {
+		return ""
+	}
+	return mode.String()
+}
+
+// truncatedList joins the first limit entries, noting how many were left out
+func truncatedList(entries []string, limit int) string {
+	if len(entries) <= limit {
+		return strings.Join(entries, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(entries[:limit], ", "), len(entries)-limit)
+}
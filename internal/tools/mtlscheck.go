@@ -0,0 +1,632 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"meshpilot/internal/config"
+
+	apinetworkingv1beta1 "istio.io/api/networking/v1beta1"
+	apisecurityv1beta1 "istio.io/api/security/v1beta1"
+	securityv1beta1 "istio.io/client-go/pkg/apis/security/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+)
+
+// mtlsCaptureScript captures the source's packets that carry a payload and counts those starting with a TLS
+// record header (content type 20-23, major version 3) and those starting a handshake record; arguments are the
+// duration in seconds and the BPF filter
+const mtlsCaptureScript = `duration=$1; shift
+timeout "$duration" tcpdump -i eth0 -nn -U -w /tmp/mtls.pcap "$@" 2>/dev/null
+offset='((tcp[12:1] & 0xf0) >> 2)'
+echo "data=$(tcpdump -nn -r /tmp/mtls.pcap 2>/dev/null | wc -l)"
+echo "tls=$(tcpdump -nn -r /tmp/mtls.pcap "tcp[$offset:1] >= 0x14 and tcp[$offset:1] <= 0x17 and tcp[$offset + 1:1] = 0x03" 2>/dev/null | wc -l)"
+echo "handshake=$(tcpdump -nn -r /tmp/mtls.pcap "tcp[$offset:1] = 0x16 and tcp[$offset + 1:1] = 0x03" 2>/dev/null | wc -l)"`
+
+// MTLSEndpoint is one end of the connection check_mtls_between examines
+type MTLSEndpoint struct {
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	IP        string `json:"ip,omitempty"`
+	Dataplane string `json:"dataplane"` // sidecar, ambient or none
+}
+
+// MTLSCapture is what a tcpdump sample on the destination pod saw of the source's traffic
+type MTLSCapture struct {
+	Container   string `json:"container"`
+	Filter      string `json:"filter"`
+	Duration    string `json:"duration"`
+	DataPackets int    `json:"data_packets"` // packets from the source carrying a payload
+	TLSRecords  int    `json:"tls_records"`  // data packets starting with a TLS record header
+	Handshakes  int    `json:"handshakes"`   // data packets starting a TLS handshake record
+}
+
+// MTLSCheck is the result of check_mtls_between
+type MTLSCheck struct {
+	Source           MTLSEndpoint     `json:"source"`
+	Destination      MTLSEndpoint     `json:"destination"`
+	Host             string           `json:"host,omitempty"` // service the source reaches the destination through
+	Port             int32            `json:"port"`           // destination pod port
+	ServicePort      int32            `json:"service_port,omitempty"`
+	ServerMode       string           `json:"server_mode"` // effective PeerAuthentication mode for the port
+	ServerModeSource string           `json:"server_mode_source"`
+	ClientTLSMode    string           `json:"client_tls_mode"` // DestinationRule tls mode, or "auto" when none sets one
+	DestinationRule  string           `json:"destination_rule,omitempty"`
+	AutoMTLS         bool             `json:"auto_mtls"`
+	Expected         string           `json:"expected"` // what the configuration implies
+	ClientStats      map[string]int64 `json:"client_stats,omitempty"`
+	ServerStats      map[string]int64 `json:"server_stats,omitempty"`
+	Capture          *MTLSCapture     `json:"capture,omitempty"`
+	Verdict          string           `json:"verdict"` // mtls, tls, plaintext, rejected or unknown
+	Basis            string           `json:"basis"`   // capture, envoy_stats or configuration
+	Evidence         []string         `json:"evidence"`
+	Issues           []string         `json:"issues,omitempty"`
+	Timestamp        time.Time        `json:"timestamp"`
+}
+
+// checkMTLSBetweenParams are the arguments of check_mtls_between
+type checkMTLSBetweenParams struct {
+	SourcePod            string `json:"source_pod"`
+	SourceNamespace      string `json:"source_namespace,omitempty"` // default: namespace
+	DestinationPod       string `json:"destination_pod,omitempty"`
+	DestinationService   string `json:"destination_service,omitempty"`
+	DestinationNamespace string `json:"destination_namespace,omitempty"` // default: namespace
+	Namespace            string `json:"namespace,omitempty"`             // default: default
+	Port                 int32  `json:"port,omitempty"`                  // pod port, or service port with destination_service
+	IstioNamespace       string `json:"istio_namespace,omitempty"`       // default: istio-system
+	Capture              bool   `json:"capture,omitempty"`               // sample the destination's traffic with tcpdump
+	Duration             int    `json:"duration,omitempty"`              // capture seconds, default: 10
+	Image                string `json:"image,omitempty"`                 // default: the configured debug image
+	Profile              string `json:"profile,omitempty"`               // sysadmin or netadmin, default: the configured profile or netadmin
+}
+
+// CheckMTLSBetween determines whether the traffic from one workload to another is mTLS encrypted. The
+// PeerAuthentication and DestinationRule in effect say what should happen, the client proxy's Envoy stats show
+// whether its connections to the service completed TLS handshakes, and an optional tcpdump sample on the
+// destination shows what actually arrives on the wire
+func (m *Manager) CheckMTLSBetween(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkMTLSBetweenParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.SourcePod == "" || (params.DestinationPod == "") == (params.DestinationService == "") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "source_pod and exactly one of destination_pod or destination_service are required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.DestinationNamespace == "" {
+		params.DestinationNamespace = params.Namespace
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Duration == 0 {
+		params.Duration = 10
+	}
+
+	duration := time.Duration(params.Duration) * time.Second
+	if params.Capture && (duration < time.Second || duration > maxCaptureDuration) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %d: must be between 1 and %d seconds", params.Duration, int(maxCaptureDuration.Seconds())),
+				},
+			},
+		}, nil
+	}
+
+	source, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.SourceNamespace).Get(ctx, params.SourcePod, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get source pod: %v", err),
+				},
+			},
+		}, nil
+	}
+	check := &MTLSCheck{
+		Evidence:  []string{},
+		Timestamp: time.Now(),
+	}
+	destination, err := m.resolveMTLSDestination(ctx, check, params.DestinationNamespace, params.DestinationPod, params.DestinationService, params.Port)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	namespaceModes := make(map[string]string)
+	for _, namespace := range []string{source.Namespace, destination.Namespace} {
+		if ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{}); err == nil {
+			namespaceModes[namespace] = ns.Labels["istio.io/dataplane-mode"]
+		}
+	}
+	check.Source = mtlsEndpoint(source, namespaceModes[source.Namespace])
+	check.Destination = mtlsEndpoint(destination, namespaceModes[destination.Namespace])
+
+	// What the configuration implies
+	rootNamespace := m.meshRootNamespace(ctx, params.IstioNamespace)
+	check.ServerMode, check.ServerModeSource, err = m.effectivePeerAuthentication(ctx, rootNamespace, destination, check.Port)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list PeerAuthentications: %v", err),
+				},
+			},
+		}, nil
+	}
+	check.AutoMTLS = true
+	var mesh struct {
+		EnableAutoMtls *bool `json:"enableAutoMtls"`
+	}
+	if err := m.readMeshConfig(ctx, params.IstioNamespace, defaultRevision, &mesh); err == nil && mesh.EnableAutoMtls != nil {
+		check.AutoMTLS = *mesh.EnableAutoMtls
+	}
+	check.ClientTLSMode = "auto"
+	if check.Host != "" {
+		rules, err := m.k8sClient.Istio.NetworkingV1beta1().DestinationRules(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			check.Issues = append(check.Issues, fmt.Sprintf("Failed to list DestinationRules, assuming none sets a TLS mode: %v", err))
+		} else if rule := connectionPoolRule(rules.Items, source.Namespace, check.Host); rule != nil {
+			check.DestinationRule = rule.Namespace + "/" + rule.Name
+			if mode := destinationRuleTLSMode(&rule.Spec, check.ServicePort); mode != "" {
+				check.ClientTLSMode = mode
+			}
+			for _, subset := range rule.Spec.Subsets {
+				if mode := trafficPolicyTLSMode(subset.TrafficPolicy, check.ServicePort); mode != "" && mode != check.ClientTLSMode {
+					check.Issues = append(check.Issues, fmt.Sprintf("Subset %s of DestinationRule %s sets tls mode %s; traffic routed to that subset differs from this report", subset.Name, check.DestinationRule, mode))
+				}
+			}
+		}
+	} else {
+		check.Issues = append(check.Issues, "No service selecting the destination exposes the port, so DestinationRules and the client's per-service stats are not checked")
+	}
+	var reason string
+	check.Expected, reason = expectedEncryption(check)
+	check.Evidence = append(check.Evidence, "Configuration: "+reason)
+	check.Verdict, check.Basis = check.Expected, "configuration"
+
+	// What the wire shows; the capture runs first so the stats read afterwards cover its traffic
+	if params.Capture {
+		if err := m.captureMTLSSample(ctx, check, source, destination, params.Duration, params.Image, params.Profile); err != nil {
+			check.Issues = append(check.Issues, fmt.Sprintf("Capture failed: %v", err))
+		}
+	}
+	m.readMTLSStats(ctx, check, source, destination)
+
+	switch {
+	case check.Capture != nil && check.Capture.DataPackets > 0:
+		check.Basis = "capture"
+		if check.Capture.TLSRecords > 0 {
+			check.Verdict = encryptedVerdict(check.ClientTLSMode)
+			check.Evidence = append(check.Evidence, fmt.Sprintf("Capture: %d of %d data packets from the source carried TLS records (%d handshakes)",
+				check.Capture.TLSRecords, check.Capture.DataPackets, check.Capture.Handshakes))
+		} else {
+			check.Verdict = "plaintext"
+			check.Evidence = append(check.Evidence, fmt.Sprintf("Capture: none of the %d data packets from the source carried a TLS record", check.Capture.DataPackets))
+		}
+	case check.ClientStats["upstream_cx_total"] > 0:
+		check.Basis = "envoy_stats"
+		if handshakes := check.ClientStats["ssl.handshake"]; handshakes > 0 {
+			check.Verdict = encryptedVerdict(check.ClientTLSMode)
+			check.Evidence = append(check.Evidence, fmt.Sprintf("Client stats: %d TLS handshakes over %d connections to %s",
+				handshakes, check.ClientStats["upstream_cx_total"], check.Host))
+		} else {
+			check.Verdict = "plaintext"
+			check.Evidence = append(check.Evidence, fmt.Sprintf("Client stats: none of the %d connections to %s completed a TLS handshake",
+				check.ClientStats["upstream_cx_total"], check.Host))
+		}
+	case check.Capture != nil:
+		check.Issues = append(check.Issues, "The capture saw no data from the source; send requests while it runs, e.g. with test_connectivity")
+	}
+	if failures := check.ClientStats["ssl.connection_error"]; failures > 0 {
+		check.Issues = append(check.Issues, fmt.Sprintf("The client proxy saw %d TLS connection errors towards %s", failures, check.Host))
+	}
+	if handshakes, ok := check.ServerStats["ssl.handshake"]; ok {
+		check.Evidence = append(check.Evidence, fmt.Sprintf("Server stats: the inbound listener completed %d TLS handshakes over %d connections from all clients",
+			handshakes, check.ServerStats["downstream_cx_total"]))
+	}
+
+	if check.Basis != "configuration" && check.Expected != "unknown" && check.Verdict != check.Expected {
+		check.Issues = append(check.Issues, fmt.Sprintf("Observed %s, but the configuration implies %s; check for stale proxy config with proxy_status", check.Verdict, check.Expected))
+	}
+	switch check.Verdict {
+	case "plaintext":
+		check.Issues = append(check.Issues, "Traffic between the workloads is not encrypted")
+	case "tls":
+		check.Issues = append(check.Issues, fmt.Sprintf("DestinationRule %s makes the client proxy originate %s TLS with its own certificates; the workloads get no Istio identity or mTLS", check.DestinationRule, check.ClientTLSMode))
+	case "rejected":
+		check.Issues = append(check.Issues, "The destination should refuse the connection because the two ends disagree on mTLS")
+	}
+
+	resultJSON, _ := json.MarshalIndent(check, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// resolveMTLSDestination finds the destination pod, its port and, when the source reaches it through a
+// service, the service's host and port. A service is resolved to one of its running pods
+func (m *Manager) resolveMTLSDestination(ctx context.Context, check *MTLSCheck, namespace, podName, serviceName string, port int32) (*corev1.Pod, error) {
+	var services []corev1.Service
+	if serviceName != "" {
+		svc, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Get(ctx, serviceName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get destination service: %w", err)
+		}
+		if len(svc.Spec.Selector) == 0 {
+			return nil, fmt.Errorf("service %s/%s has no selector; specify destination_pod instead", namespace, serviceName)
+		}
+		pods, err := m.runningPods(ctx, namespace, labels.SelectorFromSet(svc.Spec.Selector).String())
+		if err != nil {
+			return nil, fmt.Errorf("failed to list service pods: %w", err)
+		}
+		if len(pods) == 0 {
+			return nil, fmt.Errorf("service %s/%s has no running pods", namespace, serviceName)
+		}
+		podName = pods[0].Name
+		services = []corev1.Service{*svc}
+	} else {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list services: %w", err)
+		}
+		services = list.Items
+	}
+
+	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get destination pod: %w", err)
+	}
+
+	for i := range services {
+		svc := &services[i]
+		if len(svc.Spec.Selector) == 0 || !labels.SelectorFromSet(svc.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		for _, svcPort := range svc.Spec.Ports {
+			target := servicePortTarget(pod, svcPort)
+			// With a service the port is the service port, with a pod it is the pod port
+			matches := port == 0 && len(svc.Spec.Ports) == 1
+			if serviceName != "" {
+				matches = matches || svcPort.Port == port
+			} else {
+				matches = matches || (port != 0 && target == port)
+			}
+			if !matches {
+				continue
+			}
+			check.Host = fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+			check.ServicePort = svcPort.Port
+			check.Port = target
+			return pod, nil
+		}
+	}
+	if serviceName != "" {
+		return nil, fmt.Errorf("service %s/%s has no port %d; set port to one of its service ports", namespace, serviceName, port)
+	}
+	if port == 0 {
+		return nil, fmt.Errorf("port is required with destination_pod")
+	}
+	check.Port = port
+	return pod, nil
+}
+
+// servicePortTarget returns the pod port a service port forwards to
+func servicePortTarget(pod *corev1.Pod, svcPort corev1.ServicePort) int32 {
+	switch {
+	case svcPort.TargetPort.Type == intstr.String:
+		return resolveNamedPort(pod, svcPort.TargetPort.StrVal, string(svcPort.Protocol))
+	case svcPort.TargetPort.IntVal != 0:
+		return svcPort.TargetPort.IntVal
+	default:
+		return svcPort.Port
+	}
+}
+
+// mtlsEndpoint describes a pod and the dataplane that carries its traffic
+func mtlsEndpoint(pod *corev1.Pod, namespaceMode string) MTLSEndpoint {
+	endpoint := MTLSEndpoint{Pod: pod.Name, Namespace: pod.Namespace, IP: pod.Status.PodIP, Dataplane: "sidecar"}
+	switch podInterceptionMode(pod, namespaceMode) {
+	case "ambient":
+		endpoint.Dataplane = "ambient"
+	case "none":
+		endpoint.Dataplane = "none"
+	}
+	return endpoint
+}
+
+// effectivePeerAuthentication resolves the mTLS mode a pod accepts on a port the way get_mtls_status does, with
+// a workload policy's port-level mode taking precedence
+func (m *Manager) effectivePeerAuthentication(ctx context.Context, rootNamespace string, pod *corev1.Pod, port int32) (string, string, error) {
+	var policies []*securityv1beta1.PeerAuthentication
+	for _, namespace := range []string{rootNamespace, pod.Namespace} {
+		list, err := m.k8sClient.Istio.SecurityV1beta1().PeerAuthentications(namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return "", "", err
+		}
+		policies = append(policies, list.Items...)
+		if pod.Namespace == rootNamespace {
+			break
+		}
+	}
+
+	// Istio uses the oldest policy of each level
+	var mesh, namespace, workload *securityv1beta1.PeerAuthentication
+	for _, policy := range sortedPeerAuthentications(policies) {
+		switch {
+		case len(policy.Spec.GetSelector().GetMatchLabels()) > 0:
+			if workload == nil && policy.Namespace == pod.Namespace && labels.SelectorFromSet(policy.Spec.GetSelector().GetMatchLabels()).Matches(labels.Set(pod.Labels)) {
+				workload = policy
+			}
+		case policy.Namespace == pod.Namespace && pod.Namespace != rootNamespace:
+			if namespace == nil {
+				namespace = policy
+			}
+		case mesh == nil:
+			mesh = policy
+		}
+	}
+
+	mode, source := "PERMISSIVE", "default"
+	for _, policy := range []*securityv1beta1.PeerAuthentication{mesh, namespace} {
+		if policyMode := peerAuthenticationMode(policy); policyMode != "" {
+			mode, source = policyMode, policy.Namespace+"/"+policy.Name
+		}
+	}
+	if workload != nil {
+		if policyMode := peerAuthenticationMode(workload); policyMode != "" {
+			mode, source = policyMode, workload.Namespace+"/"+workload.Name
+		}
+		if portMTLS, ok := workload.Spec.GetPortLevelMtls()[uint32(port)]; ok && portMTLS.GetMode() != apisecurityv1beta1.PeerAuthentication_MutualTLS_UNSET {
+			mode, source = portMTLS.GetMode().String(), fmt.Sprintf("%s/%s (port %d)", workload.Namespace, workload.Name, port)
+		}
+	}
+	return mode, source, nil
+}
+
+// destinationRuleTLSMode returns the tls mode a DestinationRule sets for a service port, or "" when it leaves
+// the choice to auto mTLS
+func destinationRuleTLSMode(rule *apinetworkingv1beta1.DestinationRule, port int32) string {
+	return trafficPolicyTLSMode(rule.TrafficPolicy, port)
+}
+
+// trafficPolicyTLSMode returns the tls mode of a traffic policy, with port-level settings overriding it
+func trafficPolicyTLSMode(policy *apinetworkingv1beta1.TrafficPolicy, port int32) string {
+	if policy == nil {
+		return ""
+	}
+	tls := policy.GetTls()
+	for _, portPolicy := range policy.GetPortLevelSettings() {
+		if portPolicy.GetPort().GetNumber() == uint32(port) && portPolicy.GetTls() != nil {
+			tls = portPolicy.GetTls()
+		}
+	}
+	if tls == nil {
+		return ""
+	}
+	return tls.GetMode().String()
+}
+
+// expectedEncryption works out what the two proxies should put on the wire, and why
+func expectedEncryption(check *MTLSCheck) (string, string) {
+	client, server := check.Source.Dataplane, check.Destination.Dataplane
+	strict := check.ServerMode == "STRICT" && server != "none"
+
+	switch {
+	case client == "none" && strict:
+		return "rejected", "the source has no proxy and sends plaintext, which the destination refuses under STRICT"
+	case client == "none":
+		return "plaintext", "the source has no proxy, so it sends plaintext"
+	case client == "ambient" && server == "ambient":
+		return "mtls", "ztunnel carries traffic between ambient workloads over HBONE mTLS"
+	case client == "ambient" && server == "none":
+		return "plaintext", "the destination is outside the mesh, so ztunnel sends plaintext"
+	case client == "ambient" || server == "ambient":
+		return "unknown", "traffic between sidecar and ambient workloads depends on the HBONE support of the Istio version; run with capture to confirm"
+	}
+
+	// The source runs a sidecar
+	rule := "DestinationRule " + check.DestinationRule
+	switch check.ClientTLSMode {
+	case "DISABLE":
+		if strict {
+			return "rejected", rule + " disables TLS, which the destination refuses under STRICT"
+		}
+		return "plaintext", rule + " disables TLS"
+	case "SIMPLE", "MUTUAL":
+		return "tls", fmt.Sprintf("%s originates %s TLS instead of Istio mTLS", rule, check.ClientTLSMode)
+	case "ISTIO_MUTUAL":
+		if server == "none" {
+			return "rejected", rule + " forces Istio mTLS, but no proxy on the destination terminates it"
+		}
+		if check.ServerMode == "DISABLE" {
+			return "rejected", rule + " forces Istio mTLS, but the destination accepts only plaintext (mode DISABLE)"
+		}
+		return "mtls", rule + " sets ISTIO_MUTUAL"
+	}
+
+	switch {
+	case !check.AutoMTLS && strict:
+		return "rejected", "auto mTLS is disabled and no DestinationRule sets ISTIO_MUTUAL, so the source sends plaintext the destination refuses under STRICT"
+	case !check.AutoMTLS:
+		return "plaintext", "auto mTLS is disabled and no DestinationRule sets ISTIO_MUTUAL"
+	case server == "none":
+		return "plaintext", "auto mTLS sends plaintext because the destination has no sidecar"
+	case check.ServerMode == "DISABLE":
+		return "plaintext", fmt.Sprintf("auto mTLS sends plaintext because the destination's mode is DISABLE (%s)", check.ServerModeSource)
+	}
+	return "mtls", fmt.Sprintf("auto mTLS upgrades to Istio mTLS because the destination runs a sidecar in %s mode (%s)", check.ServerMode, check.ServerModeSource)
+}
+
+// encryptedVerdict names observed TLS: Istio mTLS, unless a DestinationRule originates TLS of its own
+func encryptedVerdict(clientTLSMode string) string {
+	if clientTLSMode == "SIMPLE" || clientTLSMode == "MUTUAL" {
+		return "tls"
+	}
+	return "mtls"
+}
+
+// readMTLSStats reads the client sidecar's TLS counters for the destination service and the server sidecar's
+// counters for its inbound listener. Istio's default stats inclusion may leave them out
+func (m *Manager) readMTLSStats(ctx context.Context, check *MTLSCheck, source, destination *corev1.Pod) {
+	if check.Source.Dataplane == "sidecar" && check.Host != "" {
+		// Outbound clusters are named outbound|<port>|<subset>|<host>; subsets are summed
+		filter := fmt.Sprintf(`^cluster\.outbound\|%d\|[^|]*\|%s\.(ssl\.handshake|ssl\.connection_error|upstream_cx_total)$`,
+			check.ServicePort, regexp.QuoteMeta(check.Host))
+		stats, err := m.readEnvoyCounters(ctx, source, filter)
+		switch {
+		case err != nil:
+			check.Issues = append(check.Issues, fmt.Sprintf("Failed to read the client proxy's stats: %v", err))
+		case len(stats) == 0:
+			check.Issues = append(check.Issues, fmt.Sprintf("The client proxy exposes no stats for %s; it has not called the service yet, or its proxyStatsMatcher excludes them (add the inclusion regexp .*ssl.* with tune_proxy)", check.Host))
+		default:
+			check.ClientStats = stats
+		}
+	}
+
+	if check.Destination.Dataplane == "sidecar" {
+		stats, err := m.readEnvoyCounters(ctx, destination, `^listener\..*_15006\.(ssl\.handshake|ssl\.connection_error|downstream_cx_total)$`)
+		if err == nil && len(stats) > 0 {
+			check.ServerStats = stats
+		}
+	}
+}
+
+// readEnvoyCounters sums the counters matching a stats filter by the stat name after the last
+// cluster or listener name segment, e.g. ssl.handshake
+func (m *Manager) readEnvoyCounters(ctx context.Context, pod *corev1.Pod, filter string) (map[string]int64, error) {
+	body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/stats?filter="+url.QueryEscape(filter))
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]int64)
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ": ")
+		if !found {
+			continue
+		}
+		count, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			continue
+		}
+		for _, stat := range []string{"ssl.handshake", "ssl.connection_error", "upstream_cx_total", "downstream_cx_total"} {
+			if strings.HasSuffix(name, "."+stat) {
+				stats[stat] += count
+			}
+		}
+	}
+	return stats, nil
+}
+
+// captureMTLSSample attaches an ephemeral tcpdump container to the destination and counts the source's data
+// packets that are TLS records. Ambient destinations receive the source's traffic on the HBONE port
+func (m *Manager) captureMTLSSample(ctx context.Context, check *MTLSCheck, source, destination *corev1.Pod, seconds int, image, profile string) error {
+	if destination.Spec.HostNetwork {
+		return fmt.Errorf("pod %s/%s uses the host network", destination.Namespace, destination.Name)
+	}
+	if source.Status.PodIP == "" || net.ParseIP(source.Status.PodIP).To4() == nil {
+		return fmt.Errorf("the capture filter needs the source's IPv4 address, got %q", source.Status.PodIP)
+	}
+	port := check.Port
+	if check.Destination.Dataplane == "ambient" {
+		port = 15008
+	}
+
+	image = m.debugImage(image, config.DefaultDebugImage)
+	securityContext, _, err := m.debugSecurityContext(profile, "netadmin")
+	if err != nil {
+		return err
+	}
+	if err := m.checkDebugImage(ctx, destination, image); err != nil {
+		return err
+	}
+
+	filter := fmt.Sprintf("src host %s and tcp dst port %d and (((ip[2:2] - ((ip[0]&0xf)<<2)) - ((tcp[12]&0xf0)>>2)) != 0)", source.Status.PodIP, port)
+	container := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:            captureContainerPrefix + utilrand.String(5),
+			Image:           image,
+			Command:         []string{"sh", "-c", mtlsCaptureScript, "sh", strconv.Itoa(seconds), filter},
+			SecurityContext: securityContext,
+		},
+	}
+	duration := time.Duration(seconds) * time.Second
+	logs, err := m.runEphemeralContainer(ctx, destination.Namespace, destination.Name, container, duration+2*time.Minute)
+	if err != nil {
+		return err
+	}
+
+	check.Capture = &MTLSCapture{Container: container.Name, Filter: filter, Duration: duration.String()}
+	for _, line := range strings.Split(logs, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), "=")
+		if !found {
+			continue
+		}
+		count, _ := strconv.Atoi(strings.TrimSpace(value))
+		switch key {
+		case "data":
+			check.Capture.DataPackets = count
+		case "tls":
+			check.Capture.TLSRecords = count
+		case "handshake":
+			check.Capture.Handshakes = count
+		}
+	}
+	return nil
+}
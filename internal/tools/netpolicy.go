@@ -0,0 +1,431 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// PolicyVerdict explains whether one direction of a connection is allowed by NetworkPolicies
+type PolicyVerdict struct {
+	Direction       string   `json:"direction"` // egress or ingress
+	Isolated        bool     `json:"isolated"`  // whether any policy selects the pod for this direction
+	Allowed         bool     `json:"allowed"`
+	DecidingPolicy  string   `json:"deciding_policy,omitempty"`
+	DecidingRule    int      `json:"deciding_rule,omitempty"` // 1-based rule index within the deciding policy
+	Reason          string   `json:"reason"`
+	SelectingPolicy []string `json:"selecting_policies,omitempty"`
+	CNIPolicies     []string `json:"cni_policies,omitempty"` // Cilium or Calico policies selecting the pod
+}
+
+// PolicyAnalysis is the result of simulating a connection against NetworkPolicies
+type PolicyAnalysis struct {
+	Source              PodInfo       `json:"source"`
+	Destination         PodInfo       `json:"destination"`
+	DestinationService  string        `json:"destination_service,omitempty"`
+	Port                int32         `json:"port"`
+	Protocol            string        `json:"protocol"`
+	Allowed             bool          `json:"allowed"`
+	Egress              PolicyVerdict `json:"egress"`
+	Ingress             PolicyVerdict `json:"ingress"`
+	DefaultDenyPolicies []string      `json:"default_deny_policies,omitempty"`
+	Notes               []string      `json:"notes,omitempty"`
+}
+
+// policyEndpoint is a resolved side of a simulated connection
+type policyEndpoint struct {
+	pod             *corev1.Pod
+	namespaceLabels map[string]string
+}
+
+// analyzeNetworkPolicies simulates a connection from a source pod to a destination pod or service
+func (m *Manager) analyzeNetworkPolicies(ctx context.Context, sourceNamespace, sourcePod, destNamespace, destPod, destService string, port int32, protocol string) (*PolicyAnalysis, error) {
+	if protocol == "" {
+		protocol = string(corev1.ProtocolTCP)
+	}
+	protocol = strings.ToUpper(protocol)
+
+	source, err := m.resolvePolicyEndpoint(ctx, sourceNamespace, sourcePod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve source pod: %w", err)
+	}
+
+	analysis := &PolicyAnalysis{Protocol: protocol}
+
+	// A service is resolved to one of its backing pods and its target port
+	if destService != "" {
+		svc, err := m.k8sClient.Kubernetes.CoreV1().Services(destNamespace).Get(ctx, destService, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to get destination service: %w", err)
+		}
+		if len(svc.Spec.Selector) == 0 {
+			return nil, fmt.Errorf("service %s/%s has no selector; specify destination_pod instead", destNamespace, destService)
+		}
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(destNamespace).List(ctx, metav1.ListOptions{
+			LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list service pods: %w", err)
+		}
+		if len(pods.Items) == 0 {
+			return nil, fmt.Errorf("service %s/%s has no backing pods", destNamespace, destService)
+		}
+		destPod = pods.Items[0].Name
+		if len(pods.Items) > 1 {
+			analysis.Notes = append(analysis.Notes, fmt.Sprintf("Service has %d pods; analyzed %s (pods with different labels may be treated differently)", len(pods.Items), destPod))
+		}
+		analysis.DestinationService = fmt.Sprintf("%s/%s", destNamespace, destService)
+
+		for _, svcPort := range svc.Spec.Ports {
+			if port != 0 && svcPort.Port != port {
+				continue
+			}
+			if port == 0 && len(svc.Spec.Ports) > 1 {
+				break
+			}
+			targetPort := svcPort.TargetPort
+			if targetPort.Type == intstr.String {
+				if resolved := resolveNamedPort(&pods.Items[0], targetPort.StrVal, string(svcPort.Protocol)); resolved != 0 {
+					port = resolved
+				}
+			} else if targetPort.IntVal != 0 {
+				port = targetPort.IntVal
+			} else {
+				port = svcPort.Port
+			}
+			protocol = string(svcPort.Protocol)
+			analysis.Protocol = protocol
+			analysis.Notes = append(analysis.Notes, fmt.Sprintf("Service port %d maps to pod port %d; policies are evaluated against the pod port", svcPort.Port, port))
+			break
+		}
+	}
+
+	if port == 0 {
+		return nil, fmt.Errorf("port is required")
+	}
+	analysis.Port = port
+
+	dest, err := m.resolvePolicyEndpoint(ctx, destNamespace, destPod)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve destination pod: %w", err)
+	}
+
+	analysis.Source = PodInfo{Name: source.pod.Name, Namespace: source.pod.Namespace, IP: source.pod.Status.PodIP, Node: source.pod.Spec.NodeName}
+	analysis.Destination = PodInfo{Name: dest.pod.Name, Namespace: dest.pod.Namespace, IP: dest.pod.Status.PodIP, Node: dest.pod.Spec.NodeName}
+
+	sourcePolicies, err := m.k8sClient.Kubernetes.NetworkingV1().NetworkPolicies(source.pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list policies in %s: %w", source.pod.Namespace, err)
+	}
+	destPolicies := sourcePolicies
+	if dest.pod.Namespace != source.pod.Namespace {
+		destPolicies, err = m.k8sClient.Kubernetes.NetworkingV1().NetworkPolicies(dest.pod.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list policies in %s: %w", dest.pod.Namespace, err)
+		}
+	}
+
+	analysis.Egress = evaluatePolicies(networkingv1.PolicyTypeEgress, sourcePolicies.Items, source, dest, port, protocol)
+	analysis.Ingress = evaluatePolicies(networkingv1.PolicyTypeIngress, destPolicies.Items, dest, source, port, protocol)
+
+	// CNI-specific policies are enforced alongside NetworkPolicies on Cilium and Calico clusters
+	sourceCNIPolicies, notes := m.listCNIPolicies(ctx, source.pod.Namespace)
+	analysis.Notes = append(analysis.Notes, notes...)
+	destCNIPolicies := sourceCNIPolicies
+	if dest.pod.Namespace != source.pod.Namespace {
+		destCNIPolicies, notes = m.listCNIPolicies(ctx, dest.pod.Namespace)
+		analysis.Notes = append(analysis.Notes, notes...)
+	}
+	applyCNIPolicies(&analysis.Egress, networkingv1.PolicyTypeEgress, sourceCNIPolicies, source, dest, port, protocol)
+	applyCNIPolicies(&analysis.Ingress, networkingv1.PolicyTypeIngress, destCNIPolicies, dest, source, port, protocol)
+	if len(analysis.Egress.CNIPolicies) > 0 || len(analysis.Ingress.CNIPolicies) > 0 {
+		analysis.Notes = append(analysis.Notes, "Cilium and Calico policies are evaluated at L3/L4 only; L7 rules, FQDN rules and non-default Calico tiers are not simulated")
+	}
+
+	analysis.Allowed = analysis.Egress.Allowed && analysis.Ingress.Allowed
+
+	for _, policy := range sourcePolicies.Items {
+		if isDefaultDeny(&policy, networkingv1.PolicyTypeEgress) {
+			analysis.DefaultDenyPolicies = append(analysis.DefaultDenyPolicies, fmt.Sprintf("%s/%s (egress)", policy.Namespace, policy.Name))
+		}
+	}
+	for _, policy := range destPolicies.Items {
+		if isDefaultDeny(&policy, networkingv1.PolicyTypeIngress) {
+			analysis.DefaultDenyPolicies = append(analysis.DefaultDenyPolicies, fmt.Sprintf("%s/%s (ingress)", policy.Namespace, policy.Name))
+		}
+	}
+	for i := range sourceCNIPolicies {
+		if sourceCNIPolicies[i].isDefaultDeny(networkingv1.PolicyTypeEgress) {
+			analysis.DefaultDenyPolicies = append(analysis.DefaultDenyPolicies, sourceCNIPolicies[i].ref()+" (egress)")
+		}
+	}
+	for i := range destCNIPolicies {
+		if destCNIPolicies[i].isDefaultDeny(networkingv1.PolicyTypeIngress) {
+			analysis.DefaultDenyPolicies = append(analysis.DefaultDenyPolicies, destCNIPolicies[i].ref()+" (ingress)")
+		}
+	}
+
+	if !analysis.Egress.Allowed && analysis.Egress.Isolated {
+		analysis.Notes = append(analysis.Notes, "Egress policies that restrict traffic usually also need a rule allowing DNS (UDP/TCP 53) to kube-dns")
+	}
+	if podHasSidecar(source.pod) || podHasSidecar(dest.pod) {
+		analysis.Notes = append(analysis.Notes, "Pods run Istio sidecars; istiod (15012) and control plane traffic must also be allowed for the mesh to work")
+	}
+
+	return analysis, nil
+}
+
+// resolvePolicyEndpoint loads a pod and the labels of its namespace
+func (m *Manager) resolvePolicyEndpoint(ctx context.Context, namespace, name string) (*policyEndpoint, error) {
+	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, namespace, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return &policyEndpoint{pod: pod, namespaceLabels: ns.Labels}, nil
+}
+
+// evaluatePolicies decides whether traffic is allowed for one direction; subject is the pod the
+// policies select and peer is the other end of the connection
+func evaluatePolicies(direction networkingv1.PolicyType, policies []networkingv1.NetworkPolicy, subject, peer *policyEndpoint, port int32, protocol string) PolicyVerdict {
+	verdict := PolicyVerdict{Direction: strings.ToLower(string(direction))}
+
+	// The port always refers to the destination pod, whichever side the policy selects
+	destination := peer
+	if direction == networkingv1.PolicyTypeIngress {
+		destination = subject
+	}
+
+	for i := range policies {
+		policy := &policies[i]
+		if !policyHasType(policy, direction) || !selectorMatches(&policy.Spec.PodSelector, subject.pod.Labels) {
+			continue
+		}
+		verdict.Isolated = true
+		name := fmt.Sprintf("%s/%s", policy.Namespace, policy.Name)
+		verdict.SelectingPolicy = append(verdict.SelectingPolicy, name)
+
+		if verdict.Allowed {
+			continue
+		}
+
+		if direction == networkingv1.PolicyTypeIngress {
+			for r, rule := range policy.Spec.Ingress {
+				if peersMatch(rule.From, policy.Namespace, peer) && portsMatch(rule.Ports, destination.pod, port, protocol) {
+					verdict.Allowed = true
+					verdict.DecidingPolicy = name
+					verdict.DecidingRule = r + 1
+					verdict.Reason = fmt.Sprintf("ingress rule %d of %s allows the source on port %d/%s", r+1, name, port, protocol)
+					break
+				}
+			}
+		} else {
+			for r, rule := range policy.Spec.Egress {
+				if peersMatch(rule.To, policy.Namespace, peer) && portsMatch(rule.Ports, destination.pod, port, protocol) {
+					verdict.Allowed = true
+					verdict.DecidingPolicy = name
+					verdict.DecidingRule = r + 1
+					verdict.Reason = fmt.Sprintf("egress rule %d of %s allows the destination on port %d/%s", r+1, name, port, protocol)
+					break
+				}
+			}
+		}
+	}
+
+	switch {
+	case !verdict.Isolated:
+		verdict.Allowed = true
+		verdict.Reason = fmt.Sprintf("no NetworkPolicy selects %s/%s for %s, so all %s traffic is allowed", subject.pod.Namespace, subject.pod.Name, verdict.Direction, verdict.Direction)
+	case !verdict.Allowed:
+		verdict.Reason = fmt.Sprintf("%s/%s is isolated for %s by %s and no rule matches the peer and port %d/%s",
+			subject.pod.Namespace, subject.pod.Name, verdict.Direction, strings.Join(verdict.SelectingPolicy, ", "), port, protocol)
+	}
+	return verdict
+}
+
+// policyHasType reports whether a policy applies to a direction, applying the API defaults
+func policyHasType(policy *networkingv1.NetworkPolicy, direction networkingv1.PolicyType) bool {
+	if len(policy.Spec.PolicyTypes) == 0 {
+		if direction == networkingv1.PolicyTypeIngress {
+			return true
+		}
+		return len(policy.Spec.Egress) > 0
+	}
+	for _, t := range policy.Spec.PolicyTypes {
+		if t == direction {
+			return true
+		}
+	}
+	return false
+}
+
+// isDefaultDeny reports whether a policy selects every pod and allows nothing in a direction
+func isDefaultDeny(policy *networkingv1.NetworkPolicy, direction networkingv1.PolicyType) bool {
+	if !policyHasType(policy, direction) {
+		return false
+	}
+	if len(policy.Spec.PodSelector.MatchLabels) > 0 || len(policy.Spec.PodSelector.MatchExpressions) > 0 {
+		return false
+	}
+	if direction == networkingv1.PolicyTypeIngress {
+		return len(policy.Spec.Ingress) == 0
+	}
+	return len(policy.Spec.Egress) == 0
+}
+
+// peersMatch reports whether any peer of a rule matches the endpoint; an empty list matches everything
+func peersMatch(peers []networkingv1.NetworkPolicyPeer, policyNamespace string, endpoint *policyEndpoint) bool {
+	if len(peers) == 0 {
+		return true
+	}
+	for _, peer := range peers {
+		if peer.IPBlock != nil {
+			if ipBlockContains(peer.IPBlock, endpoint.pod.Status.PodIP) {
+				return true
+			}
+			continue
+		}
+
+		// Without a namespace selector the peer is limited to the policy's namespace
+		if peer.NamespaceSelector == nil {
+			if endpoint.pod.Namespace != policyNamespace {
+				continue
+			}
+		} else if !selectorMatches(peer.NamespaceSelector, endpoint.namespaceLabels) {
+			continue
+		}
+
+		if peer.PodSelector == nil || selectorMatches(peer.PodSelector, endpoint.pod.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// portsMatch reports whether any port of a rule matches; an empty list matches every port
+func portsMatch(ports []networkingv1.NetworkPolicyPort, destPod *corev1.Pod, port int32, protocol string) bool {
+	if len(ports) == 0 {
+		return true
+	}
+	for _, p := range ports {
+		ruleProtocol := string(corev1.ProtocolTCP)
+		if p.Protocol != nil {
+			ruleProtocol = string(*p.Protocol)
+		}
+		if ruleProtocol != protocol {
+			continue
+		}
+		if p.Port == nil {
+			return true
+		}
+		rulePort := p.Port.IntVal
+		if p.Port.Type == intstr.String {
+			rulePort = resolveNamedPort(destPod, p.Port.StrVal, ruleProtocol)
+			if rulePort == 0 {
+				continue
+			}
+		}
+		if p.EndPort != nil && p.Port.Type == intstr.Int {
+			if port >= rulePort && port <= *p.EndPort {
+				return true
+			}
+			continue
+		}
+		if port == rulePort {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveNamedPort returns the container port number with the given name, or 0
+func resolveNamedPort(pod *corev1.Pod, name, protocol string) int32 {
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			portProtocol := string(p.Protocol)
+			if portProtocol == "" {
+				portProtocol = string(corev1.ProtocolTCP)
+			}
+			if p.Name == name && (protocol == "" || portProtocol == protocol) {
+				return p.ContainerPort
+			}
+		}
+	}
+	return 0
+}
+
+// ipBlockContains reports whether an IP falls inside an ipBlock and outside its exceptions
+func ipBlockContains(block *networkingv1.IPBlock, ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	_, cidr, err := net.ParseCIDR(block.CIDR)
+	if err != nil || !cidr.Contains(addr) {
+		return false
+	}
+	for _, except := range block.Except {
+		if _, exceptNet, err := net.ParseCIDR(except); err == nil && exceptNet.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// selectorMatches evaluates a label selector against a label set
+func selectorMatches(selector *metav1.LabelSelector, set map[string]string) bool {
+	s, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return false
+	}
+	return s.Matches(labels.Set(set))
+}
+
+// podHasSidecar reports whether a pod runs an istio-proxy container
+func podHasSidecar(pod *corev1.Pod) bool {
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "istio-proxy" {
+			return true
+		}
+	}
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == "istio-proxy" {
+			return true
+		}
+	}
+	return false
+}
+
+// networkPolicyAnalysisResult renders a policy analysis as a tool result
+func networkPolicyAnalysisResult(analysis *PolicyAnalysis) *CallToolResult {
+	verdict := "ALLOWED"
+	if !analysis.Allowed {
+		verdict = "DENIED"
+	}
+	result := map[string]interface{}{
+		"summary": fmt.Sprintf("Traffic from %s/%s to %s/%s on port %d/%s is %s",
+			analysis.Source.Namespace, analysis.Source.Name, analysis.Destination.Namespace, analysis.Destination.Name,
+			analysis.Port, analysis.Protocol, verdict),
+		"analysis": analysis,
+	}
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}
+}
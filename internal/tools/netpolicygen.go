@@ -0,0 +1,819 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+// PolicyIntent declares a connection an application is expected to accept or make
+type PolicyIntent struct {
+	Direction     string `json:"direction"`                // ingress or egress
+	PeerNamespace string `json:"peer_namespace,omitempty"` // default: the application namespace
+	PeerSelector  string `json:"peer_selector,omitempty"`  // label selector for peer pods, e.g. app=sleep
+	CIDR          string `json:"cidr,omitempty"`           // external peer instead of pods
+	Port          string `json:"port,omitempty"`           // number or named port; default: all ports
+	Protocol      string `json:"protocol,omitempty"`       // default: TCP
+}
+
+// ObservedConnection is a connection that ends up as a rule in a generated policy
+type ObservedConnection struct {
+	Direction string   `json:"direction"`
+	Peer      string   `json:"peer"`
+	Ports     []string `json:"ports,omitempty"`
+	Count     int      `json:"count,omitempty"`
+	Source    string   `json:"source"` // access_log, stats or intent
+}
+
+// GeneratedPolicy is the result of generating a least-privilege NetworkPolicy
+type GeneratedPolicy struct {
+	Name                string               `json:"name"`
+	Namespace           string               `json:"namespace"`
+	PodSelector         string               `json:"pod_selector"`
+	Connections         []ObservedConnection `json:"connections"`
+	YAML                string               `json:"yaml"`
+	Existing            bool                 `json:"existing"`
+	Diff                []SnapshotChange     `json:"diff,omitempty"`
+	OverlappingPolicies []string             `json:"overlapping_policies,omitempty"`
+	Applied             bool                 `json:"applied"`
+	DryRun              bool                 `json:"dry_run"`
+	Notes               []string             `json:"notes,omitempty"`
+}
+
+// policyPeer identifies the other end of a connection
+type policyPeer struct {
+	namespace string
+	labels    map[string]string
+	cidr      string
+}
+
+// key returns a stable identifier used to group ports per peer
+func (p policyPeer) key() string {
+	if p.cidr != "" {
+		return "cidr:" + p.cidr
+	}
+	keys := make([]string, 0, len(p.labels))
+	for k, v := range p.labels {
+		keys = append(keys, k+"="+v)
+	}
+	sort.Strings(keys)
+	return p.namespace + "/" + strings.Join(keys, ",")
+}
+
+// policyConnectionSet aggregates connections per direction and peer
+type policyConnectionSet struct {
+	order []string
+	conns map[string]*policyConnection
+}
+
+// policyConnection is one peer with the ports it uses
+type policyConnection struct {
+	direction string
+	peer      policyPeer
+	ports     map[string]bool // "<protocol>/<port>"; empty means all ports
+	allPorts  bool
+	count     int
+	source    string
+}
+
+func newPolicyConnectionSet() *policyConnectionSet {
+	return &policyConnectionSet{conns: make(map[string]*policyConnection)}
+}
+
+// add records a connection; an empty port allows every port for the peer
+func (s *policyConnectionSet) add(direction string, peer policyPeer, protocol, port, source string) {
+	key := direction + "|" + peer.key()
+	conn, ok := s.conns[key]
+	if !ok {
+		conn = &policyConnection{direction: direction, peer: peer, ports: make(map[string]bool), source: source}
+		s.conns[key] = conn
+		s.order = append(s.order, key)
+	}
+	conn.count++
+	if port == "" {
+		conn.allPorts = true
+		return
+	}
+	if protocol == "" {
+		protocol = string(corev1.ProtocolTCP)
+	}
+	conn.ports[strings.ToUpper(protocol)+"/"+port] = true
+}
+
+// accessLogAddress matches the ip:port tokens of Envoy access logs
+var accessLogAddress = regexp.MustCompile(`^\[?([0-9a-fA-F.:]+?)\]?:(\d+)$`)
+
+// statsLabel matches a label in Prometheus text format
+var statsLabel = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// ignoredPeerLabels are labels that differ between replicas and are unsuitable for peer selectors
+var ignoredPeerLabels = map[string]bool{
+	"pod-template-hash":                   true,
+	"controller-revision-hash":            true,
+	"pod-template-generation":             true,
+	"statefulset.kubernetes.io/pod-name":  true,
+	"security.istio.io/tlsMode":           true,
+	"service.istio.io/canonical-name":     true,
+	"service.istio.io/canonical-revision": true,
+	"apps.kubernetes.io/pod-index":        true,
+}
+
+// generateNetworkPolicyParams are the arguments of generate_network_policy
+type generateNetworkPolicyParams struct {
+	Namespace   string         `json:"namespace,omitempty"`    // default: default
+	App         string         `json:"app,omitempty"`          // selects pods with app=<app>
+	PodSelector string         `json:"pod_selector,omitempty"` // label selector, overrides app
+	From        string         `json:"from,omitempty"`         // observed, intents or both; default: observed, or intents when given
+	Intents     []PolicyIntent `json:"intents,omitempty"`
+	Since       string         `json:"since,omitempty"`       // default: 1h
+	IncludeDNS  *bool          `json:"include_dns,omitempty"` // default: true
+	PolicyName  string         `json:"policy_name,omitempty"` // default: <app>-least-privilege
+	Apply       bool           `json:"apply,omitempty"`       // default: false (dry run)
+}
+
+// GenerateNetworkPolicy builds a least-privilege NetworkPolicy from observed traffic or declared intents
+func (m *Manager) GenerateNetworkPolicy(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params generateNetworkPolicyParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.PodSelector == "" && params.App != "" {
+		params.PodSelector = "app=" + params.App
+	}
+	if params.From == "" {
+		params.From = "observed"
+		if len(params.Intents) > 0 {
+			params.From = "intents"
+		}
+	}
+	if params.Since == "" {
+		params.Since = "1h"
+	}
+	if params.IncludeDNS == nil {
+		params.IncludeDNS = boolPtr(true)
+	}
+
+	if params.PodSelector == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "app or pod_selector is required",
+				},
+			},
+		}, nil
+	}
+	if params.From != "observed" && params.From != "intents" && params.From != "both" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid from %q: must be observed, intents or both", params.From),
+				},
+			},
+		}, nil
+	}
+	if params.From != "observed" && len(params.Intents) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "intents are required when from is intents or both",
+				},
+			},
+		}, nil
+	}
+
+	since, err := time.ParseDuration(params.Since)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid since duration: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	selector, err := metav1.ParseToLabelSelector(params.PodSelector)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid pod_selector: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.PolicyName == "" {
+		name := params.App
+		if name == "" {
+			name = strings.NewReplacer("=", "-", ",", "-", " ", "", "!", "").Replace(params.PodSelector)
+		}
+		params.PolicyName = name + "-least-privilege"
+	}
+
+	ctx = withManagingTool(ctx, "generate_network_policy")
+
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{LabelSelector: params.PodSelector})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	generated := &GeneratedPolicy{
+		Name:        params.PolicyName,
+		Namespace:   params.Namespace,
+		PodSelector: params.PodSelector,
+		DryRun:      !params.Apply,
+	}
+	conns := newPolicyConnectionSet()
+
+	if params.From != "intents" {
+		if len(pods.Items) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("No pods match %s in namespace %s; declare intents instead", params.PodSelector, params.Namespace),
+					},
+				},
+			}, nil
+		}
+		notes, err := m.observePolicyConnections(ctx, pods.Items, since, conns)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to observe connections: %v", err),
+					},
+				},
+			}, nil
+		}
+		generated.Notes = append(generated.Notes, notes...)
+	}
+
+	if params.From != "observed" {
+		for i, intent := range params.Intents {
+			if err := addPolicyIntent(conns, params.Namespace, intent); err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Invalid intent %d: %v", i+1, err),
+						},
+					},
+				}, nil
+			}
+		}
+	}
+
+	sidecars := false
+	for i := range pods.Items {
+		if podHasSidecar(&pods.Items[i]) {
+			sidecars = true
+			break
+		}
+	}
+
+	policy := buildNetworkPolicy(params.PolicyName, params.Namespace, selector, conns, *params.IncludeDNS, sidecars)
+	for _, key := range conns.order {
+		conn := conns.conns[key]
+		generated.Connections = append(generated.Connections, ObservedConnection{
+			Direction: conn.direction,
+			Peer:      describePolicyPeer(conn.peer),
+			Ports:     sortedPorts(conn),
+			Count:     conn.count,
+			Source:    conn.source,
+		})
+	}
+	if len(conns.order) == 0 {
+		generated.Notes = append(generated.Notes, "No connections were found; the generated policy denies all ingress and all egress except the defaults")
+	}
+	if sidecars {
+		generated.Notes = append(generated.Notes, "Pods run Istio sidecars; egress to istiod on 15012 was added so the proxy keeps receiving configuration")
+	}
+
+	policyYAML, err := yaml.Marshal(policy)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to render policy: %v", err),
+				},
+			},
+		}, nil
+	}
+	generated.YAML = string(policyYAML)
+
+	// Dry-run diff against the existing policy of the same name and report overlapping policies
+	existing, err := m.k8sClient.Kubernetes.NetworkingV1().NetworkPolicies(params.Namespace).Get(ctx, params.PolicyName, metav1.GetOptions{})
+	if err == nil {
+		generated.Existing = true
+		var before, after interface{}
+		beforeJSON, _ := json.Marshal(existing.Spec)
+		afterJSON, _ := json.Marshal(policy.Spec)
+		_ = json.Unmarshal(beforeJSON, &before)
+		_ = json.Unmarshal(afterJSON, &after)
+		diffValues("spec", before, after, &generated.Diff)
+	} else if !errors.IsNotFound(err) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get existing policy: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if existingPolicies, err := m.k8sClient.Kubernetes.NetworkingV1().NetworkPolicies(params.Namespace).List(ctx, metav1.ListOptions{}); err == nil {
+		for _, other := range existingPolicies.Items {
+			if other.Name == params.PolicyName {
+				continue
+			}
+			for i := range pods.Items {
+				if selectorMatches(&other.Spec.PodSelector, pods.Items[i].Labels) {
+					generated.OverlappingPolicies = append(generated.OverlappingPolicies, other.Name)
+					break
+				}
+			}
+		}
+	}
+	if len(generated.OverlappingPolicies) > 0 {
+		generated.Notes = append(generated.Notes, "NetworkPolicies are additive; overlapping policies may still allow traffic the generated policy omits")
+	}
+
+	if params.Apply {
+		markManaged(ctx, policy)
+		if generated.Existing {
+			policy.ResourceVersion = existing.ResourceVersion
+			_, err = m.k8sClient.Kubernetes.NetworkingV1().NetworkPolicies(params.Namespace).Update(ctx, policy, metav1.UpdateOptions{})
+		} else {
+			_, err = m.k8sClient.Kubernetes.NetworkingV1().NetworkPolicies(params.Namespace).Create(ctx, policy, metav1.CreateOptions{})
+		}
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to apply network policy: %v", err),
+					},
+				},
+			}, nil
+		}
+		generated.Applied = true
+		logrus.Infof("Applied network policy %s/%s", params.Namespace, params.PolicyName)
+	}
+
+	resultJSON, _ := json.MarshalIndent(generated, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// observePolicyConnections collects connections from sidecar access logs, falling back to Envoy stats
+func (m *Manager) observePolicyConnections(ctx context.Context, pods []corev1.Pod, since time.Duration, conns *policyConnectionSet) ([]string, error) {
+	var notes []string
+
+	allPods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+	podsByIP := make(map[string]*corev1.Pod)
+	for i := range allPods.Items {
+		pod := &allPods.Items[i]
+		if pod.Status.PodIP != "" && !pod.Spec.HostNetwork {
+			podsByIP[pod.Status.PodIP] = pod
+		}
+	}
+
+	sinceSeconds := int64(since.Seconds())
+	for i := range pods {
+		pod := &pods[i]
+		if !podHasSidecar(pod) {
+			notes = append(notes, fmt.Sprintf("%s has no istio-proxy sidecar, so its traffic cannot be observed; declare intents instead", pod.Name))
+			continue
+		}
+
+		before := len(conns.order)
+		logs, err := m.k8sClient.Kubernetes.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:    "istio-proxy",
+			SinceSeconds: &sinceSeconds,
+		}).Do(ctx).Raw()
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("Failed to read access logs of %s: %v", pod.Name, err))
+		} else {
+			parsed := 0
+			scanner := bufio.NewScanner(strings.NewReader(string(logs)))
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				if m.addAccessLogConnection(ctx, scanner.Text(), pod, podsByIP, conns) {
+					parsed++
+				}
+			}
+			logrus.Debugf("Parsed %d access log entries for %s/%s", parsed, pod.Namespace, pod.Name)
+		}
+
+		if len(conns.order) > before {
+			continue
+		}
+
+		// Access logging is off by default; Istio metrics still record peers without ports
+		stats, err := m.execCommandInPod(ctx, pod.Namespace, pod.Name, "istio-proxy", []string{"pilot-agent", "request", "GET", "stats/prometheus"})
+		if err != nil {
+			notes = append(notes, fmt.Sprintf("No access log entries for %s and Envoy stats are unavailable: %v", pod.Name, err))
+			continue
+		}
+		m.addStatsConnections(ctx, stats, pod, conns)
+		notes = append(notes, fmt.Sprintf("No access log entries for %s; connections were derived from Envoy stats, which cover the whole proxy lifetime (enable meshConfig.accessLogFile for port-level detail)", pod.Name))
+	}
+
+	return notes, nil
+}
+
+// addAccessLogConnection parses one Envoy access log line in the default text or JSON format
+func (m *Manager) addAccessLogConnection(ctx context.Context, line string, pod *corev1.Pod, podsByIP map[string]*corev1.Pod, conns *policyConnectionSet) bool {
+	var cluster, upstreamHost, downstreamRemote string
+
+	if strings.HasPrefix(strings.TrimSpace(line), "{") {
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			return false
+		}
+		cluster, _ = entry["upstream_cluster"].(string)
+		upstreamHost, _ = entry["upstream_host"].(string)
+		downstreamRemote, _ = entry["downstream_remote_address"].(string)
+	} else {
+		fields := splitAccessLogFields(line)
+		for i, field := range fields {
+			if strings.HasPrefix(field, "inbound|") || strings.HasPrefix(field, "outbound|") || field == "PassthroughCluster" {
+				cluster = field
+				if i > 0 {
+					upstreamHost = fields[i-1]
+				}
+				if i+3 < len(fields) {
+					downstreamRemote = fields[i+3]
+				}
+				break
+			}
+		}
+	}
+
+	switch {
+	case strings.HasPrefix(cluster, "inbound|"):
+		parts := strings.Split(cluster, "|")
+		if len(parts) < 2 || parts[1] == "" {
+			return false
+		}
+		match := accessLogAddress.FindStringSubmatch(downstreamRemote)
+		if match == nil {
+			return false
+		}
+		conns.add("ingress", peerForIP(match[1], podsByIP), "TCP", parts[1], "access_log")
+		return true
+
+	case strings.HasPrefix(cluster, "outbound|"), cluster == "PassthroughCluster":
+		match := accessLogAddress.FindStringSubmatch(upstreamHost)
+		if match == nil {
+			return false
+		}
+		ip, port := match[1], match[2]
+		if _, ok := podsByIP[ip]; !ok && strings.HasPrefix(cluster, "outbound|") {
+			// The endpoint may be gone; fall back to the selector of the destination service
+			if peer, ok := m.peerForServiceHost(ctx, cluster[strings.LastIndex(cluster, "|")+1:], pod.Namespace); ok {
+				conns.add("egress", peer, "TCP", port, "access_log")
+				return true
+			}
+		}
+		conns.add("egress", peerForIP(ip, podsByIP), "TCP", port, "access_log")
+		return true
+	}
+	return false
+}
+
+// addStatsConnections derives peers from Istio standard metrics
+func (m *Manager) addStatsConnections(ctx context.Context, stats string, pod *corev1.Pod, conns *policyConnectionSet) {
+	for _, line := range strings.Split(stats, "\n") {
+		if !strings.HasPrefix(line, "istio_requests_total{") && !strings.HasPrefix(line, "istio_tcp_connections_opened_total{") {
+			continue
+		}
+		labels := make(map[string]string)
+		for _, match := range statsLabel.FindAllStringSubmatch(line, -1) {
+			labels[match[1]] = match[2]
+		}
+
+		switch labels["reporter"] {
+		case "destination":
+			service := labels["source_canonical_service"]
+			namespace := labels["source_workload_namespace"]
+			if service == "" || service == "unknown" || namespace == "" || namespace == "unknown" {
+				continue
+			}
+			peer := policyPeer{namespace: namespace, labels: map[string]string{"app": service}}
+			ports := declaredPorts(pod)
+			if len(ports) == 0 {
+				conns.add("ingress", peer, "", "", "stats")
+			}
+			for _, port := range ports {
+				conns.add("ingress", peer, string(port.Protocol), strconv.Itoa(int(port.ContainerPort)), "stats")
+			}
+
+		case "source":
+			host := labels["destination_service"]
+			peer, ok := m.peerForServiceHost(ctx, host, pod.Namespace)
+			if !ok {
+				continue
+			}
+			svc, err := m.k8sClient.Kubernetes.CoreV1().Services(peer.namespace).Get(ctx, strings.Split(host, ".")[0], metav1.GetOptions{})
+			if err != nil {
+				continue
+			}
+			for _, port := range svc.Spec.Ports {
+				target := port.TargetPort.String()
+				if port.TargetPort.Type == intstr.Int && port.TargetPort.IntVal == 0 {
+					target = strconv.Itoa(int(port.Port))
+				}
+				conns.add("egress", peer, string(port.Protocol), target, "stats")
+			}
+		}
+	}
+}
+
+// peerForServiceHost resolves a service hostname to a peer matching its backing pods
+func (m *Manager) peerForServiceHost(ctx context.Context, host, defaultNamespace string) (policyPeer, bool) {
+	parts := strings.Split(host, ".")
+	if len(parts) == 0 || parts[0] == "" || parts[0] == "unknown" {
+		return policyPeer{}, false
+	}
+	namespace := defaultNamespace
+	if len(parts) > 1 {
+		namespace = parts[1]
+	}
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Get(ctx, parts[0], metav1.GetOptions{})
+	if err != nil || len(svc.Spec.Selector) == 0 {
+		return policyPeer{}, false
+	}
+	return policyPeer{namespace: namespace, labels: svc.Spec.Selector}, true
+}
+
+// peerForIP maps an address to the pod owning it, or to a /32 (/128) block for anything else
+func peerForIP(ip string, podsByIP map[string]*corev1.Pod) policyPeer {
+	if pod, ok := podsByIP[ip]; ok {
+		return policyPeer{namespace: pod.Namespace, labels: peerLabels(pod.Labels)}
+	}
+	if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+		return policyPeer{cidr: ip + "/128"}
+	}
+	return policyPeer{cidr: ip + "/32"}
+}
+
+// peerLabels picks stable labels to select a peer workload
+func peerLabels(podLabels map[string]string) map[string]string {
+	for _, key := range []string{"app", "app.kubernetes.io/name", "k8s-app"} {
+		if value, ok := podLabels[key]; ok {
+			return map[string]string{key: value}
+		}
+	}
+	selected := make(map[string]string)
+	for k, v := range podLabels {
+		if !ignoredPeerLabels[k] {
+			selected[k] = v
+		}
+	}
+	return selected
+}
+
+// declaredPorts lists the container ports of a pod, excluding the sidecar
+func declaredPorts(pod *corev1.Pod) []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, container := range pod.Spec.Containers {
+		if container.Name == "istio-proxy" {
+			continue
+		}
+		for _, port := range container.Ports {
+			if port.Protocol == "" {
+				port.Protocol = corev1.ProtocolTCP
+			}
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// addPolicyIntent converts a declared intent into a connection
+func addPolicyIntent(conns *policyConnectionSet, namespace string, intent PolicyIntent) error {
+	direction := strings.ToLower(intent.Direction)
+	if direction != "ingress" && direction != "egress" {
+		return fmt.Errorf("direction must be ingress or egress")
+	}
+
+	var peer policyPeer
+	switch {
+	case intent.CIDR != "":
+		if _, _, err := net.ParseCIDR(intent.CIDR); err != nil {
+			return fmt.Errorf("invalid cidr: %w", err)
+		}
+		peer.cidr = intent.CIDR
+	default:
+		peer.namespace = intent.PeerNamespace
+		if peer.namespace == "" {
+			peer.namespace = namespace
+		}
+		peer.labels = map[string]string{}
+		if intent.PeerSelector != "" {
+			selector, err := metav1.ParseToLabelSelector(intent.PeerSelector)
+			if err != nil {
+				return fmt.Errorf("invalid peer_selector: %w", err)
+			}
+			if len(selector.MatchExpressions) > 0 {
+				return fmt.Errorf("peer_selector only supports equality requirements")
+			}
+			peer.labels = selector.MatchLabels
+		}
+	}
+
+	conns.add(direction, peer, intent.Protocol, intent.Port, "intent")
+	return nil
+}
+
+// buildNetworkPolicy renders the aggregated connections as a NetworkPolicy
+func buildNetworkPolicy(name, namespace string, selector *metav1.LabelSelector, conns *policyConnectionSet, includeDNS, sidecars bool) *networkingv1.NetworkPolicy {
+	policy := &networkingv1.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "networking.k8s.io/v1",
+			Kind:       "NetworkPolicy",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+		},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: *selector,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			Ingress:     []networkingv1.NetworkPolicyIngressRule{},
+			Egress:      []networkingv1.NetworkPolicyEgressRule{},
+		},
+	}
+
+	for _, key := range conns.order {
+		conn := conns.conns[key]
+		peer := networkPolicyPeer(conn.peer, namespace)
+		var ports []networkingv1.NetworkPolicyPort
+		if !conn.allPorts {
+			ports = networkPolicyPorts(sortedPorts(conn))
+		}
+		if conn.direction == "ingress" {
+			policy.Spec.Ingress = append(policy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{peer}, Ports: ports})
+		} else {
+			policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{To: []networkingv1.NetworkPolicyPeer{peer}, Ports: ports})
+		}
+	}
+
+	if includeDNS {
+		policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+			To:    []networkingv1.NetworkPolicyPeer{networkPolicyPeer(policyPeer{namespace: "kube-system", labels: map[string]string{"k8s-app": "kube-dns"}}, namespace)},
+			Ports: networkPolicyPorts([]string{"UDP/53", "TCP/53"}),
+		})
+	}
+	if sidecars {
+		policy.Spec.Egress = append(policy.Spec.Egress, networkingv1.NetworkPolicyEgressRule{
+			To:    []networkingv1.NetworkPolicyPeer{networkPolicyPeer(policyPeer{namespace: "istio-system", labels: map[string]string{"app": "istiod"}}, namespace)},
+			Ports: networkPolicyPorts([]string{"TCP/15012"}),
+		})
+	}
+
+	return policy
+}
+
+// networkPolicyPeer converts a peer into a NetworkPolicy peer relative to the policy namespace
+func networkPolicyPeer(peer policyPeer, namespace string) networkingv1.NetworkPolicyPeer {
+	if peer.cidr != "" {
+		return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: peer.cidr}}
+	}
+	result := networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: peer.labels}}
+	if peer.namespace != namespace {
+		result.NamespaceSelector = &metav1.LabelSelector{
+			MatchLabels: map[string]string{"kubernetes.io/metadata.name": peer.namespace},
+		}
+	}
+	return result
+}
+
+// networkPolicyPorts converts "<protocol>/<port>" entries into policy ports
+func networkPolicyPorts(entries []string) []networkingv1.NetworkPolicyPort {
+	var ports []networkingv1.NetworkPolicyPort
+	for _, entry := range entries {
+		protocol, port, _ := strings.Cut(entry, "/")
+		p := corev1.Protocol(protocol)
+		value := intstr.Parse(port)
+		ports = append(ports, networkingv1.NetworkPolicyPort{Protocol: &p, Port: &value})
+	}
+	return ports
+}
+
+// sortedPorts returns the ports of a connection in a stable order
+func sortedPorts(conn *policyConnection) []string {
+	if conn.allPorts {
+		return nil
+	}
+	ports := make([]string, 0, len(conn.ports))
+	for port := range conn.ports {
+		ports = append(ports, port)
+	}
+	sort.Strings(ports)
+	return ports
+}
+
+// describePolicyPeer renders a peer for the result summary
+func describePolicyPeer(peer policyPeer) string {
+	if peer.cidr != "" {
+		return peer.cidr
+	}
+	key := peer.key()
+	if strings.HasSuffix(key, "/") {
+		return key + "*"
+	}
+	return key
+}
+
+// splitAccessLogFields splits an access log line on spaces, keeping quoted fields together
+func splitAccessLogFields(line string) []string {
+	var fields []string
+	var current strings.Builder
+	inQuotes := false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case r == ' ' && !inQuotes:
+			if current.Len() > 0 {
+				fields = append(fields, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		fields = append(fields, current.String())
+	}
+	return fields
+}
@@ -4,13 +4,23 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
 	networkingv1 "k8s.io/api/networking/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilrand "k8s.io/apimachinery/pkg/util/rand"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// iptablesDebugTimeout bounds pulling, running and reading one iptables debug container
+	iptablesDebugTimeout = time.Minute
+	// iptablesDebugImage is the debug image of the tools that run iptables-nft, unless debug.image is set
+	iptablesDebugImage = "istio/base"
 )
 
 // IptablesRules represents iptables rules from a pod
@@ -18,7 +28,9 @@ type IptablesRules struct {
 	Pod       string            `json:"pod"`
 	Namespace string            `json:"namespace"`
 	Container string            `json:"container"`
-	Tables    map[string]string `json:"tables"` // table name -> rules
+	Image     string            `json:"image"`   // debug image the rules were read with
+	Profile   string            `json:"profile"` // debug profile of the ephemeral containers
+	Tables    map[string]string `json:"tables"`  // table name -> rules
 	Timestamp time.Time         `json:"timestamp"`
 }
 
@@ -30,6 +42,16 @@ type NetworkPolicyInfo struct {
 	Status    string                         `json:"status"`
 }
 
+// NetworkPolicyNamespace summarizes the policies of one namespace and whether they isolate all of its pods
+type NetworkPolicyNamespace struct {
+	Namespace           string   `json:"namespace"`
+	Policies            int      `json:"policies"`
+	CNIPolicies         int      `json:"cni_policies,omitempty"`
+	DefaultDenyIngress  bool     `json:"default_deny_ingress"`
+	DefaultDenyEgress   bool     `json:"default_deny_egress"`
+	DefaultDenyPolicies []string `json:"default_deny_policies,omitempty"`
+}
+
 // NetworkTrace represents network path tracing information
 type NetworkTrace struct {
 	Source      PodInfo   `json:"source"`
@@ -40,15 +62,20 @@ type NetworkTrace struct {
 	Timestamp   time.Time `json:"timestamp"`
 }
 
+// getIptablesRulesParams are the arguments of get_iptables_rules
+type getIptablesRulesParams struct {
+	PodName   string   `json:"pod_name"`
+	Namespace string   `json:"namespace,omitempty"`
+	Container string   `json:"container,omitempty"`
+	Tables    []string `json:"tables,omitempty"` // specific tables to query
+	Verbose   bool     `json:"verbose,omitempty"`
+	Image     string   `json:"image,omitempty"`   // default: the configured debug image
+	Profile   string   `json:"profile,omitempty"` // sysadmin or netadmin, default: the configured profile or sysadmin
+}
+
 // GetIptablesRules retrieves iptables rules from a pod
-func (m *Manager) GetIptablesRules(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		PodName   string   `json:"pod_name"`
-		Namespace string   `json:"namespace,omitempty"`
-		Container string   `json:"container,omitempty"`
-		Tables    []string `json:"tables,omitempty"` // specific tables to query
-		Verbose   bool     `json:"verbose,omitempty"`
-	}
+func (m *Manager) GetIptablesRules(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getIptablesRulesParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -69,8 +96,20 @@ func (m *Manager) GetIptablesRules(args json.RawMessage) (*CallToolResult, error
 	if len(params.Tables) == 0 {
 		params.Tables = []string{"filter", "nat", "mangle"}
 	}
+	params.Image = m.debugImage(params.Image, iptablesDebugImage)
 
-	ctx := context.Background()
+	securityContext, profile, err := m.debugSecurityContext(params.Profile, "sysadmin")
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
 
 	// Get pod to validate it exists
 	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
@@ -101,15 +140,29 @@ func (m *Manager) GetIptablesRules(args json.RawMessage) (*CallToolResult, error
 		}
 	}
 
+	if err := m.checkDebugImage(ctx, pod, params.Image); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
 	result := &IptablesRules{
 		Pod:       params.PodName,
 		Namespace: params.Namespace,
 		Container: params.Container,
+		Image:     params.Image,
+		Profile:   profile,
 		Tables:    make(map[string]string),
 		Timestamp: time.Now(),
 	}
 
-	// Query each iptables table using kubectl debug with istio/base image
+	// Query each iptables table from an ephemeral debug container
 	for _, table := range params.Tables {
 		var iptablesArgs []string
 		if params.Verbose {
@@ -118,7 +171,7 @@ func (m *Manager) GetIptablesRules(args json.RawMessage) (*CallToolResult, error
 			iptablesArgs = []string{"-t", table, "-L", "-n"}
 		}
 
-		output, err := m.getIptablesWithDebug(ctx, params.Namespace, params.PodName, table, iptablesArgs)
+		output, err := m.getIptablesWithDebug(ctx, params.Namespace, params.PodName, table, iptablesArgs, params.Image, securityContext)
 		if err != nil {
 			logrus.Warnf("Failed to get iptables rules for table %s: %v", table, err)
 			result.Tables[table] = fmt.Sprintf("Error: %v", err)
@@ -138,96 +191,109 @@ func (m *Manager) GetIptablesRules(args json.RawMessage) (*CallToolResult, error
 	}, nil
 }
 
-// getIptablesWithDebug uses kubectl debug to attach an ephemeral container to get iptables rules
-func (m *Manager) getIptablesWithDebug(ctx context.Context, namespace, podName, table string, iptablesArgs []string) (string, error) {
-	// Create a unique debug container name
-	debugContainerName := fmt.Sprintf("debug-iptables-%d", time.Now().Unix())
-
-	// Step 1: Create ephemeral container using kubectl debug (without interactive flags for programmatic use)
-	kubectlArgs := []string{
-		"debug",
-		podName,
-		"-n", namespace,
-		"--image=istio/base",
-		"--profile=sysadmin",
-		"--quiet",
-		"--attach=false",
-		"--stdin=false",
-		"-c", debugContainerName,
-		"--",
-		"iptables-nft",
+// prepareIptablesDebug returns the configured debug image and security context for the iptables debug containers
+// of tools that take no image or profile parameter, once the image is known to be pullable on the pod's node
+func (m *Manager) prepareIptablesDebug(ctx context.Context, pod *corev1.Pod) (string, *corev1.SecurityContext, error) {
+	image := m.debugImage("", iptablesDebugImage)
+	securityContext, _, err := m.debugSecurityContext("", "sysadmin")
+	if err != nil {
+		return "", nil, err
 	}
-	kubectlArgs = append(kubectlArgs, iptablesArgs...)
+	if err := m.checkDebugImage(ctx, pod, image); err != nil {
+		return "", nil, err
+	}
+	return image, securityContext, nil
+}
 
-	logrus.Debugf("Running kubectl debug command: kubectl %s", strings.Join(kubectlArgs, " "))
+// getIptablesWithDebug attaches an ephemeral container running iptables to the pod and returns its output
+func (m *Manager) getIptablesWithDebug(ctx context.Context, namespace, podName, table string, iptablesArgs []string, image string, securityContext *corev1.SecurityContext) (string, error) {
+	// One container per table; names must be unique within the pod
+	container := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:            fmt.Sprintf("%s%s-%s", debugContainerPrefix, table, utilrand.String(5)),
+			Image:           image,
+			Command:         append([]string{"iptables-nft"}, iptablesArgs...),
+			SecurityContext: securityContext,
+		},
+	}
 
-	// Execute kubectl debug command to create ephemeral container with timeout
-	debugCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
-	defer cancel()
+	logrus.Debugf("Attaching ephemeral container %s to %s/%s: %s", container.Name, namespace, podName, strings.Join(container.Command, " "))
 
-	cmd := exec.CommandContext(debugCtx, "kubectl", kubectlArgs...)
+	return m.runEphemeralContainer(ctx, namespace, podName, container, iptablesDebugTimeout)
+}
 
-	// Set up the command to not expect any input
-	cmd.Stdin = nil
+// runEphemeralContainer attaches an ephemeral container to a pod through the ephemeralcontainers subresource,
+// waits for it to terminate and returns its log; a non-zero exit code is an error carrying the log
+func (m *Manager) runEphemeralContainer(ctx context.Context, namespace, podName string, container corev1.EphemeralContainer, timeout time.Duration) (string, error) {
+	pods := m.k8sClient.Kubernetes.CoreV1().Pods(namespace)
 
-	debugOutput, err := cmd.CombinedOutput()
+	// The update must carry every ephemeral container already attached, so start from the current pod
+	pod, err := pods.Get(ctx, podName, metav1.GetOptions{})
 	if err != nil {
-		return "", fmt.Errorf("failed to create ephemeral container: %w, output: %s", err, string(debugOutput))
+		return "", fmt.Errorf("failed to get pod: %w", err)
+	}
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, container)
+	if _, err := pods.UpdateEphemeralContainers(ctx, podName, pod, metav1.UpdateOptions{}); err != nil {
+		return "", fmt.Errorf("failed to add ephemeral container: %w", err)
 	}
 
-	logrus.Debugf("Debug command output: %s", string(debugOutput))
-
-	// Step 2: Wait for the ephemeral container to be ready and complete
-	// We need to wait for the container to be ready before getting logs
-	maxWaitTime := 30 * time.Second
-	waitInterval := 2 * time.Second
-	startTime := time.Now()
-
-	var output []byte
-	var logsErr error
-
-	for time.Since(startTime) < maxWaitTime {
-		// Try to get logs from the ephemeral container
-		logsCmd := exec.CommandContext(ctx, "kubectl", "logs", podName, "-n", namespace, "-c", debugContainerName)
-		output, logsErr = logsCmd.CombinedOutput()
-
-		// If we got logs successfully, break out of the loop
-		if logsErr == nil && len(output) > 0 {
-			break
-		}
-
-		// If the error is about the container still initializing, wait and retry
-		if logsErr != nil && strings.Contains(string(output), "PodInitializing") {
-			logrus.Debugf("Container %s still initializing, waiting %v...", debugContainerName, waitInterval)
-			time.Sleep(waitInterval)
-			continue
+	var terminated *corev1.ContainerStateTerminated
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return false, err
 		}
-
-		// If it's a different error, wait a bit and try once more
-		if logsErr != nil {
-			logrus.Debugf("Error getting logs from %s: %v, retrying...", debugContainerName, logsErr)
-			time.Sleep(waitInterval)
-			continue
+		for _, status := range current.Status.EphemeralContainerStatuses {
+			if status.Name != container.Name {
+				continue
+			}
+			if status.State.Terminated != nil {
+				terminated = status.State.Terminated
+				return true, nil
+			}
+			if waiting := status.State.Waiting; waiting != nil {
+				switch waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerError", "CreateContainerConfigError":
+					return false, fmt.Errorf("container %s is not starting: %s: %s", container.Name, waiting.Reason, waiting.Message)
+				}
+			}
 		}
-
-		// If we got empty output, the container might still be running
-		time.Sleep(waitInterval)
+		return false, nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("waiting for container %s: %w", container.Name, err)
 	}
 
-	if logsErr != nil {
-		return "", fmt.Errorf("failed to get logs from ephemeral container after %v: %w, output: %s", maxWaitTime, logsErr, string(output))
+	logs, err := pods.GetLogs(podName, &corev1.PodLogOptions{Container: container.Name}).DoRaw(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to read logs of container %s: %w", container.Name, err)
 	}
+	if terminated.ExitCode != 0 {
+		return string(logs), fmt.Errorf("container %s exited with code %d (%s): %s", container.Name, terminated.ExitCode, terminated.Reason, strings.TrimSpace(string(logs)))
+	}
+	return string(logs), nil
+}
 
-	return string(output), nil
+// getNetworkPoliciesParams are the arguments of get_network_policies
+type getNetworkPoliciesParams struct {
+	Namespace     string `json:"namespace,omitempty"`      // default: all namespaces, or default with pod_name or analyze
+	PodName       string `json:"pod_name,omitempty"`       // filter policies affecting this pod
+	LabelSelector string `json:"label_selector,omitempty"` // filter by labels
+
+	// Analyze mode simulates a connection instead of listing policies
+	Analyze              bool   `json:"analyze,omitempty"`
+	SourcePod            string `json:"source_pod,omitempty"`
+	SourceNamespace      string `json:"source_namespace,omitempty"` // default: namespace
+	DestinationPod       string `json:"destination_pod,omitempty"`
+	DestinationService   string `json:"destination_service,omitempty"`
+	DestinationNamespace string `json:"destination_namespace,omitempty"` // default: namespace
+	Port                 int32  `json:"port,omitempty"`                  // pod port, or service port with destination_service
+	Protocol             string `json:"protocol,omitempty"`              // default: TCP
 }
 
-// GetNetworkPolicies retrieves network policies in a namespace
-func (m *Manager) GetNetworkPolicies(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace     string `json:"namespace,omitempty"`
-		PodName       string `json:"pod_name,omitempty"`       // filter policies affecting this pod
-		LabelSelector string `json:"label_selector,omitempty"` // filter by labels
-	}
+// GetNetworkPolicies retrieves network policies in a namespace or across all namespaces, grouped per namespace
+func (m *Manager) GetNetworkPolicies(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getNetworkPoliciesParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -241,12 +307,58 @@ func (m *Manager) GetNetworkPolicies(args json.RawMessage) (*CallToolResult, err
 		}, nil
 	}
 
-	// Set defaults
-	if params.Namespace == "" {
+	// Set defaults; a pod is looked up in the default namespace, otherwise every namespace is listed
+	if params.Namespace == "" && (params.PodName != "" || params.Analyze) {
 		params.Namespace = "default"
 	}
 
-	ctx := context.Background()
+	if params.Analyze {
+		if params.SourceNamespace == "" {
+			params.SourceNamespace = params.Namespace
+		}
+		if params.DestinationNamespace == "" {
+			params.DestinationNamespace = params.Namespace
+		}
+		if params.SourcePod == "" || (params.DestinationPod == "" && params.DestinationService == "") {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "analyze mode requires source_pod and either destination_pod or destination_service",
+					},
+				},
+			}, nil
+		}
+
+		analysis, err := m.analyzeNetworkPolicies(ctx, params.SourceNamespace, params.SourcePod,
+			params.DestinationNamespace, params.DestinationPod, params.DestinationService, params.Port, params.Protocol)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to analyze network policies: %v", err),
+					},
+				},
+			}, nil
+		}
+		return networkPolicyAnalysisResult(analysis), nil
+	}
+
+	pages, err := m.newPager("get_network_policies", args)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
 
 	// List network policies
 	listOptions := metav1.ListOptions{}
@@ -268,6 +380,7 @@ func (m *Manager) GetNetworkPolicies(args json.RawMessage) (*CallToolResult, err
 	}
 
 	var policyInfos []NetworkPolicyInfo
+	var selected []networkingv1.NetworkPolicy
 	var podLabels map[string]string
 
 	// If pod name is specified, get its labels for filtering
@@ -296,18 +409,62 @@ func (m *Manager) GetNetworkPolicies(args json.RawMessage) (*CallToolResult, err
 		}
 
 		policyInfos = append(policyInfos, policyInfo)
+		selected = append(selected, policy)
 	}
 
+	namespace := params.Namespace
+	if namespace == "" {
+		namespace = scopeAllNamespaces
+	}
+	policyPage, page := paginate(pages, policyInfos)
 	result := map[string]interface{}{
-		"namespace": params.Namespace,
+		"namespace": namespace,
 		"count":     len(policyInfos),
-		"policies":  policyInfos,
+		"policies":  policyPage,
+	}
+	if page.NextCursor != "" {
+		result["next_cursor"] = page.NextCursor
 	}
 
 	if params.PodName != "" {
 		result["filtered_for_pod"] = params.PodName
 	}
 
+	if !pages.firstPage() {
+		// The per-namespace summary and the Cilium and Calico policies come with the first page
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	// Include Cilium and Calico policies, which are enforced alongside NetworkPolicies
+	cniPolicies, notes := m.listCNIPolicies(ctx, params.Namespace)
+	var cniInfos []CNIPolicyInfo
+	var podEndpoint *policyEndpoint
+	if params.PodName != "" && podLabels != nil {
+		podEndpoint, _ = m.resolvePolicyEndpoint(ctx, params.Namespace, params.PodName)
+	}
+	for i := range cniPolicies {
+		if podEndpoint != nil && !cniPolicies[i].selects(podEndpoint) {
+			continue
+		}
+		cniInfos = append(cniInfos, cniPolicies[i].info())
+	}
+	if len(cniInfos) > 0 {
+		result["cni_policies"] = cniInfos
+		notes = append(notes, "Default-deny posture is derived from Kubernetes NetworkPolicies; Cilium and Calico policies may isolate further pods")
+	}
+	result["namespaces"] = networkPolicyNamespaces(selected, cniInfos)
+	if len(notes) > 0 {
+		result["notes"] = notes
+	}
+
 	resultJSON, _ := json.MarshalIndent(result, "", "  ")
 	return &CallToolResult{
 		Content: []interface{}{
@@ -319,18 +476,65 @@ func (m *Manager) GetNetworkPolicies(args json.RawMessage) (*CallToolResult, err
 	}, nil
 }
 
-// TraceNetworkPath traces the network path between two pods
-func (m *Manager) TraceNetworkPath(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		SourcePod       string `json:"source_pod"`
-		SourceNamespace string `json:"source_namespace,omitempty"`
-		TargetPod       string `json:"target_pod,omitempty"`
-		TargetNamespace string `json:"target_namespace,omitempty"`
-		TargetHost      string `json:"target_host,omitempty"`
-		TargetPort      int    `json:"target_port,omitempty"`
-		MaxHops         int    `json:"max_hops,omitempty"`
+// networkPolicyNamespaces groups policies per namespace and detects namespaces whose pods are all isolated by a
+// policy that selects every pod and allows nothing in a direction
+func networkPolicyNamespaces(policies []networkingv1.NetworkPolicy, cniInfos []CNIPolicyInfo) []NetworkPolicyNamespace {
+	byNamespace := map[string]*NetworkPolicyNamespace{}
+	group := func(namespace string) *NetworkPolicyNamespace {
+		summary, ok := byNamespace[namespace]
+		if !ok {
+			summary = &NetworkPolicyNamespace{Namespace: namespace}
+			byNamespace[namespace] = summary
+		}
+		return summary
+	}
+
+	for i := range policies {
+		policy := &policies[i]
+		summary := group(policy.Namespace)
+		summary.Policies++
+		denyIngress := isDefaultDeny(policy, networkingv1.PolicyTypeIngress)
+		denyEgress := isDefaultDeny(policy, networkingv1.PolicyTypeEgress)
+		summary.DefaultDenyIngress = summary.DefaultDenyIngress || denyIngress
+		summary.DefaultDenyEgress = summary.DefaultDenyEgress || denyEgress
+		if denyIngress || denyEgress {
+			summary.DefaultDenyPolicies = append(summary.DefaultDenyPolicies, policy.Name)
+		}
+	}
+	for _, info := range cniInfos {
+		// Cluster-wide policies belong to no namespace
+		if info.Namespace != "" {
+			group(info.Namespace).CNIPolicies++
+		}
 	}
 
+	names := make([]string, 0, len(byNamespace))
+	for namespace := range byNamespace {
+		names = append(names, namespace)
+	}
+	sort.Strings(names)
+	namespaces := make([]NetworkPolicyNamespace, 0, len(names))
+	for _, namespace := range names {
+		namespaces = append(namespaces, *byNamespace[namespace])
+	}
+	return namespaces
+}
+
+// traceNetworkPathParams are the arguments of trace_network_path
+type traceNetworkPathParams struct {
+	SourcePod       string `json:"source_pod"`
+	SourceNamespace string `json:"source_namespace,omitempty"`
+	TargetPod       string `json:"target_pod,omitempty"`
+	TargetNamespace string `json:"target_namespace,omitempty"`
+	TargetHost      string `json:"target_host,omitempty"`
+	TargetPort      int    `json:"target_port,omitempty"`
+	MaxHops         int    `json:"max_hops,omitempty"`
+}
+
+// TraceNetworkPath traces the network path between two pods
+func (m *Manager) TraceNetworkPath(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params traceNetworkPathParams
+
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
 			IsError: true,
@@ -354,8 +558,6 @@ func (m *Manager) TraceNetworkPath(args json.RawMessage) (*CallToolResult, error
 		params.MaxHops = 30
 	}
 
-	ctx := context.Background()
-
 	// Get source pod info
 	sourcePod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.SourceNamespace).Get(ctx, params.SourcePod, metav1.GetOptions{})
 	if err != nil {
@@ -0,0 +1,448 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"meshpilot/internal/config"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// nodeDebugPodTimeout bounds pulling, running and reading a node debug pod
+	nodeDebugPodTimeout = 2 * time.Minute
+	// maxNodeIptablesRules caps the node iptables rules reported for one pod
+	maxNodeIptablesRules = 100
+)
+
+// nodeNetworkScript dumps the node's view of a pod IP between section markers; the only argument is the pod IP
+const nodeNetworkScript = `ip=$1; pattern=$(printf '%s' "$ip" | sed 's/[.]/\\./g')
+echo "==sysctl=="
+for key in net.ipv4.ip_forward net.ipv4.conf.all.rp_filter net.bridge.bridge-nf-call-iptables; do
+  printf '%s = %s\n' "$key" "$(sysctl -n "$key" 2>/dev/null || echo unavailable)"
+done
+echo "==route=="
+ip route get "$ip" 2>&1
+echo "==links=="
+ip -o link show 2>&1
+echo "==neighbor=="
+ip neigh show "$ip" 2>&1
+echo "==iptables=="
+(iptables-save 2>/dev/null; iptables-nft-save 2>/dev/null; iptables-legacy-save 2>/dev/null) | grep -E "^[*:]|$pattern([/ ]|$)"
+echo "==conntrack=="
+conntrack -L 2>/dev/null | grep -F "$ip " | head -200`
+
+// NodeLink is a host network interface on the pod's path
+type NodeLink struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	State  string `json:"state"`
+	Master string `json:"master,omitempty"` // bridge the link is enslaved to
+	MTU    int    `json:"mtu,omitempty"`
+}
+
+// PodNodeNetwork is the result of diagnose_pod_node_network
+type PodNodeNetwork struct {
+	Pod           string            `json:"pod"`
+	Namespace     string            `json:"namespace"`
+	PodIP         string            `json:"pod_ip"`
+	Node          string            `json:"node"`
+	DebugPod      string            `json:"debug_pod"`
+	Sysctls       map[string]string `json:"sysctls"`
+	Route         string            `json:"route"`
+	RouteDevice   string            `json:"route_device,omitempty"`
+	HostVeth      *NodeLink         `json:"host_veth,omitempty"`
+	RouteLink     *NodeLink         `json:"route_link,omitempty"`
+	Neighbor      string            `json:"neighbor,omitempty"`
+	IptablesRules []string          `json:"iptables_rules,omitempty"` // node rules matching the pod IP, with their table
+	ForwardPolicy string            `json:"forward_policy,omitempty"` // filter FORWARD chain policy, DROP if any backend drops
+	Conntrack     ConntrackSummary  `json:"conntrack"`
+	Issues        []string          `json:"issues,omitempty"`
+	Timestamp     time.Time         `json:"timestamp"`
+}
+
+// ConntrackSummary counts the node's connection tracking entries for the pod IP
+type ConntrackSummary struct {
+	Available bool `json:"available"`
+	Entries   int  `json:"entries"`
+	Unreplied int  `json:"unreplied"`
+	Assured   int  `json:"assured"`
+	TimeWait  int  `json:"time_wait"`
+	SynSent   int  `json:"syn_sent"`
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// diagnosePodNodeNetworkParams are the arguments of diagnose_pod_node_network
+type diagnosePodNodeNetworkParams struct {
+	PodName        string `json:"pod_name"`
+	Namespace      string `json:"namespace,omitempty"`       // default: default
+	Image          string `json:"image,omitempty"`           // default: the configured debug image
+	DebugNamespace string `json:"debug_namespace,omitempty"` // default: default, namespace of the node debug pod
+}
+
+// DiagnosePodNodeNetwork runs a privileged host-network debug pod on a pod's node and inspects the node side
+// of the pod's traffic: the host route and veth, the bridge, forwarding sysctls, node iptables rules and
+// conntrack entries for the pod IP
+func (m *Manager) DiagnosePodNodeNetwork(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params diagnosePodNodeNetworkParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.PodName == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "pod_name is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	params.Image = m.debugImage(params.Image, config.DefaultDebugImage)
+	if params.DebugNamespace == "" {
+		params.DebugNamespace = "default"
+	}
+
+	ctx = withManagingTool(ctx, "diagnose_pod_node_network")
+
+	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get pod: %v", err),
+				},
+			},
+		}, nil
+	}
+	if pod.Spec.NodeName == "" || pod.Status.PodIP == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Pod %s/%s is not scheduled or has no IP yet", pod.Namespace, pod.Name),
+				},
+			},
+		}, nil
+	}
+	if pod.Spec.HostNetwork {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Pod %s/%s uses the host network; it has no veth or pod route on the node", pod.Namespace, pod.Name),
+				},
+			},
+		}, nil
+	}
+
+	result := &PodNodeNetwork{
+		Pod:       pod.Name,
+		Namespace: pod.Namespace,
+		PodIP:     pod.Status.PodIP,
+		Node:      pod.Spec.NodeName,
+		Sysctls:   make(map[string]string),
+		Timestamp: time.Now(),
+	}
+
+	// The pod's eth0 names the index of its host-side veth peer
+	hostIndex := 0
+	for _, container := range pod.Spec.Containers {
+		output, err := m.execCommandInPod(ctx, pod.Namespace, pod.Name, container.Name, []string{"cat", "/sys/class/net/eth0/iflink"})
+		if err == nil {
+			hostIndex, _ = strconv.Atoi(strings.TrimSpace(output))
+			break
+		}
+	}
+
+	output, debugPod, err := m.runNodeDebugPod(ctx, params.DebugNamespace, pod.Spec.NodeName, params.Image,
+		[]string{"sh", "-c", nodeNetworkScript, "sh", pod.Status.PodIP})
+	result.DebugPod = debugPod
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to run node debug pod on %s: %v", pod.Spec.NodeName, err),
+				},
+			},
+		}, nil
+	}
+
+	sections := splitSections(output)
+	for _, line := range sections["sysctl"] {
+		if key, value, ok := strings.Cut(line, " = "); ok {
+			result.Sysctls[key] = value
+		}
+	}
+
+	links := parseIPLinks(sections["links"])
+	result.Route = strings.Join(sections["route"], " ")
+	if fields := strings.Fields(result.Route); indexOf(fields, "dev")+1 < len(fields) {
+		result.RouteDevice = fields[indexOf(fields, "dev")+1]
+	}
+	for i := range links {
+		if hostIndex != 0 && links[i].Index == hostIndex {
+			result.HostVeth = &links[i]
+		}
+		if links[i].Name == result.RouteDevice {
+			result.RouteLink = &links[i]
+		}
+	}
+	result.Neighbor = strings.Join(sections["neighbor"], "; ")
+
+	table := ""
+	for _, line := range sections["iptables"] {
+		switch {
+		case strings.HasPrefix(line, "*"):
+			table = strings.TrimPrefix(line, "*")
+		case strings.HasPrefix(line, ":FORWARD "):
+			if fields := strings.Fields(line); table == "filter" && len(fields) > 1 && result.ForwardPolicy != "DROP" {
+				result.ForwardPolicy = fields[1]
+			}
+		case strings.HasPrefix(line, ":"):
+		default:
+			if len(result.IptablesRules) < maxNodeIptablesRules {
+				result.IptablesRules = append(result.IptablesRules, fmt.Sprintf("[%s] %s", table, line))
+			}
+		}
+	}
+
+	conntrack := sections["conntrack"]
+	result.Conntrack.Available = len(conntrack) > 0
+	result.Conntrack.Entries = len(conntrack)
+	result.Conntrack.Truncated = len(conntrack) >= 200
+	for _, entry := range conntrack {
+		switch {
+		case strings.Contains(entry, "[UNREPLIED]"):
+			result.Conntrack.Unreplied++
+		case strings.Contains(entry, "[ASSURED]"):
+			result.Conntrack.Assured++
+		}
+		if strings.Contains(entry, " TIME_WAIT ") {
+			result.Conntrack.TimeWait++
+		}
+		if strings.Contains(entry, " SYN_SENT ") {
+			result.Conntrack.SynSent++
+		}
+	}
+
+	result.Issues = nodeNetworkIssues(result, hostIndex)
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// nodeNetworkIssues flags the node-side conditions that drop or misroute a pod's traffic
+func nodeNetworkIssues(result *PodNodeNetwork, hostIndex int) []string {
+	var issues []string
+	if result.Sysctls["net.ipv4.ip_forward"] == "0" {
+		issues = append(issues, "net.ipv4.ip_forward is 0 on the node; pod traffic is not forwarded")
+	}
+	if result.Sysctls["net.ipv4.conf.all.rp_filter"] == "1" {
+		issues = append(issues, "Strict reverse path filtering (rp_filter=1) is on; asymmetric routes, e.g. through a tunnel or a second NIC, are dropped")
+	}
+	if result.Sysctls["net.bridge.bridge-nf-call-iptables"] == "0" && result.HostVeth != nil && result.HostVeth.Master != "" {
+		issues = append(issues, "bridge-nf-call-iptables is 0; bridged pod traffic bypasses iptables, so Service NAT and network policies do not apply to it")
+	}
+
+	switch {
+	case result.RouteDevice == "":
+		issues = append(issues, fmt.Sprintf("The node has no route to pod IP %s: %s", result.PodIP, result.Route))
+	case strings.Contains(result.Route, " via "):
+		issues = append(issues, fmt.Sprintf("Pod IP %s is routed through a gateway (%s) instead of a local interface; the CNI did not install the pod route", result.PodIP, result.Route))
+	case result.RouteLink == nil:
+		issues = append(issues, fmt.Sprintf("The route to the pod uses %s, which is not among the node's links", result.RouteDevice))
+	case result.RouteLink.State == "DOWN":
+		issues = append(issues, fmt.Sprintf("%s, the interface routing to the pod, is DOWN", result.RouteDevice))
+	}
+
+	if hostIndex == 0 {
+		issues = append(issues, "Could not read the pod's eth0 peer index (no container with cat); the host veth was not identified")
+	} else if result.HostVeth == nil {
+		issues = append(issues, fmt.Sprintf("No host interface has index %d, the pod's veth peer; the pod's network namespace is detached from the node", hostIndex))
+	} else {
+		if result.HostVeth.State == "DOWN" {
+			issues = append(issues, fmt.Sprintf("Host veth %s of the pod is DOWN", result.HostVeth.Name))
+		}
+		if result.RouteLink != nil && result.RouteLink.Name != result.HostVeth.Name && result.RouteLink.Name != result.HostVeth.Master {
+			issues = append(issues, fmt.Sprintf("The pod route uses %s but the pod's veth is %s (bridge %q); the route points at the wrong interface", result.RouteLink.Name, result.HostVeth.Name, result.HostVeth.Master))
+		}
+	}
+	if strings.Contains(result.Neighbor, "FAILED") || strings.Contains(result.Neighbor, "INCOMPLETE") {
+		issues = append(issues, fmt.Sprintf("The node cannot resolve the pod's MAC address: %s", result.Neighbor))
+	}
+
+	for _, rule := range result.IptablesRules {
+		if strings.Contains(rule, "-j DROP") || strings.Contains(rule, "-j REJECT") {
+			issues = append(issues, "Node iptables rule drops traffic of the pod: "+rule)
+		}
+	}
+	if result.ForwardPolicy == "DROP" {
+		issues = append(issues, "The node's FORWARD chain policy is DROP; pod traffic is only forwarded where the CNI or kube-proxy adds ACCEPT rules")
+	}
+	if result.Conntrack.Unreplied > 0 && result.Conntrack.Unreplied*2 >= result.Conntrack.Entries {
+		issues = append(issues, fmt.Sprintf("%d of %d conntrack entries for the pod are unreplied; connections leave or reach the node but get no answer", result.Conntrack.Unreplied, result.Conntrack.Entries))
+	}
+	return issues
+}
+
+// runNodeDebugPod runs a privileged host-network pod on a node, like kubectl debug node, returning its output;
+// the pod is deleted afterwards
+func (m *Manager) runNodeDebugPod(ctx context.Context, namespace, node, image string, command []string) (string, string, error) {
+	pods := m.k8sClient.Kubernetes.CoreV1().Pods(namespace)
+	pod := nodeDebugPod(namespace, node, image, command)
+	markManaged(ctx, pod)
+
+	created, err := pods.Create(ctx, pod, metav1.CreateOptions{})
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create debug pod: %w", err)
+	}
+	ref := fmt.Sprintf("%s/%s", created.Namespace, created.Name)
+	defer func() {
+		_ = pods.Delete(context.WithoutCancel(ctx), created.Name, metav1.DeleteOptions{})
+	}()
+
+	err = wait.PollUntilContextTimeout(ctx, 2*time.Second, nodeDebugPodTimeout, true, func(ctx context.Context) (bool, error) {
+		current, err := pods.Get(ctx, created.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		switch current.Status.Phase {
+		case corev1.PodSucceeded, corev1.PodFailed:
+			return true, nil
+		}
+		for _, status := range current.Status.ContainerStatuses {
+			if waiting := status.State.Waiting; waiting != nil {
+				switch waiting.Reason {
+				case "ErrImagePull", "ImagePullBackOff", "InvalidImageName", "CreateContainerError", "CreateContainerConfigError":
+					return false, fmt.Errorf("debug pod is not starting: %s: %s", waiting.Reason, waiting.Message)
+				}
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return "", ref, fmt.Errorf("waiting for debug pod %s: %w", ref, err)
+	}
+
+	logs, err := pods.GetLogs(created.Name, &corev1.PodLogOptions{Container: "debugger"}).DoRaw(ctx)
+	if err != nil {
+		return "", ref, fmt.Errorf("failed to read logs of debug pod %s: %w", ref, err)
+	}
+	return string(logs), ref, nil
+}
+
+// nodeDebugPod builds a privileged host-network pod pinned to a node that tolerates every taint
+func nodeDebugPod(namespace, node, image string, command []string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("node-debug-%d", time.Now().UnixNano()),
+			Namespace: namespace,
+			Labels:    map[string]string{"app": "meshpilot-node-debug"},
+			Annotations: map[string]string{
+				"sidecar.istio.io/inject": "false",
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName:      node,
+			HostNetwork:   true,
+			HostPID:       true,
+			RestartPolicy: corev1.RestartPolicyNever,
+			Tolerations:   []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+			Containers: []corev1.Container{{
+				Name:    "debugger",
+				Image:   image,
+				Command: command,
+				SecurityContext: &corev1.SecurityContext{
+					Privileged: boolPtr(true),
+				},
+			}},
+		},
+	}
+}
+
+// splitSections splits script output into the non-empty lines under each ==name== marker
+func splitSections(output string) map[string][]string {
+	sections := make(map[string][]string)
+	section := ""
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "==") && strings.HasSuffix(line, "==") && len(line) > 4 {
+			section = strings.Trim(line, "=")
+			continue
+		}
+		if line != "" && section != "" {
+			sections[section] = append(sections[section], line)
+		}
+	}
+	return sections
+}
+
+// parseIPLinks parses `ip -o link show` lines such as
+// "7: veth1a2b@if2: <BROADCAST,UP,LOWER_UP> mtu 1500 qdisc noqueue master cni0 state UP mode DEFAULT ..."
+func parseIPLinks(lines []string) []NodeLink {
+	var links []NodeLink
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimSuffix(fields[0], ":"))
+		if err != nil {
+			continue
+		}
+		name := strings.TrimSuffix(fields[1], ":")
+		if at := strings.Index(name, "@"); at >= 0 {
+			name = name[:at]
+		}
+		link := NodeLink{Index: index, Name: name}
+		for i := 2; i+1 < len(fields); i++ {
+			switch fields[i] {
+			case "state":
+				link.State = fields[i+1]
+			case "master":
+				link.Master = fields[i+1]
+			case "mtu":
+				link.MTU, _ = strconv.Atoi(fields[i+1])
+			}
+		}
+		links = append(links, link)
+	}
+	return links
+}
@@ -0,0 +1,583 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// otelCollectorImage is the OpenTelemetry collector deployed by install_otel_collector
+	otelCollectorImage = "otel/opentelemetry-collector:0.98.0"
+	// otelGRPCPort, otelHTTPPort and otelMetricsPort are the OTLP receivers and the collector's own metrics
+	otelGRPCPort    = 4317
+	otelHTTPPort    = 4318
+	otelMetricsPort = 8888
+)
+
+var telemetryGVR = schema.GroupVersionResource{Group: "telemetry.istio.io", Version: "v1alpha1", Resource: "telemetries"}
+
+// otelCollectorConfig receives OTLP traces and logs them with the debug exporter, so spans can be
+// seen in the collector logs without a tracing backend
+const otelCollectorConfig = `receivers:
+  otlp:
+    protocols:
+      grpc:
+        endpoint: 0.0.0.0:4317
+      http:
+        endpoint: 0.0.0.0:4318
+processors:
+  batch: {}
+exporters:
+  debug:
+    verbosity: basic
+service:
+  telemetry:
+    metrics:
+      address: 0.0.0.0:8888
+  pipelines:
+    traces:
+      receivers: [otlp]
+      processors: [batch]
+      exporters: [debug]
+`
+
+// OtelCollectorStatus is the result of installing the collector
+type OtelCollectorStatus struct {
+	Name      string   `json:"name"`
+	Namespace string   `json:"namespace"`
+	Ready     bool     `json:"ready"`
+	OTLPGRPC  string   `json:"otlp_grpc"`
+	OTLPHTTP  string   `json:"otlp_http"`
+	Issues    []string `json:"issues,omitempty"`
+	Notes     []string `json:"notes,omitempty"`
+}
+
+// TracingVerification is the outcome of generating traffic and checking the collector received spans
+type TracingVerification struct {
+	Requests      int    `json:"requests"`
+	Source        string `json:"source"`
+	Target        string `json:"target"`
+	SpansBefore   int    `json:"spans_before"`
+	SpansAfter    int    `json:"spans_after"`
+	SpansReceived int    `json:"spans_received"`
+	Passed        bool   `json:"passed"`
+	WaitedFor     string `json:"waited_for"`
+}
+
+// TracingConfigResult is the result of configuring mesh tracing
+type TracingConfigResult struct {
+	Provider        string                 `json:"provider"`
+	ProviderConfig  map[string]interface{} `json:"provider_config"`
+	ProviderUpdated bool                   `json:"provider_updated"`
+	Telemetry       string                 `json:"telemetry"`
+	Sampling        float64                `json:"sampling_percentage"`
+	Verification    *TracingVerification   `json:"verification,omitempty"`
+	Issues          []string               `json:"issues,omitempty"`
+	Notes           []string               `json:"notes,omitempty"`
+	Timestamp       time.Time              `json:"timestamp"`
+}
+
+// installOtelCollectorParams are the arguments of install_otel_collector
+type installOtelCollectorParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: observability
+	Name      string `json:"name,omitempty"`      // default: otel-collector
+	Image     string `json:"image,omitempty"`     // default: otelCollectorImage
+	Timeout   string `json:"timeout,omitempty"`   // wait for the collector, default: 5m
+}
+
+// InstallOtelCollector deploys an OpenTelemetry collector that receives OTLP traces
+func (m *Manager) InstallOtelCollector(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params installOtelCollectorParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "observability"
+	}
+	if params.Name == "" {
+		params.Name = "otel-collector"
+	}
+	if params.Image == "" {
+		params.Image = otelCollectorImage
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "install_otel_collector")
+
+	if err := m.createOrUpdateNamespace(ctx, params.Namespace, false); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to create namespace: %v", err),
+				},
+			},
+		}, nil
+	}
+	if err := m.createOtelCollector(ctx, params.Namespace, params.Name, params.Image); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to deploy the collector: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	status := &OtelCollectorStatus{
+		Name:      params.Name,
+		Namespace: params.Namespace,
+		OTLPGRPC:  fmt.Sprintf("%s.%s.svc.cluster.local:%d", params.Name, params.Namespace, otelGRPCPort),
+		OTLPHTTP:  fmt.Sprintf("%s.%s.svc.cluster.local:%d", params.Name, params.Namespace, otelHTTPPort),
+	}
+	if err := m.waitForPodsReady(ctx, params.Namespace, []string{"app=" + params.Name}, timeout); err != nil {
+		status.Issues = append(status.Issues, fmt.Sprintf("Collector did not become ready: %v", err))
+	} else {
+		status.Ready = true
+	}
+	status.Notes = append(status.Notes, "Spans are logged by the debug exporter; run configure_tracing to send mesh traces here")
+
+	resultJSON, _ := json.MarshalIndent(status, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// configureTracingParams are the arguments of configure_tracing
+type configureTracingParams struct {
+	CollectorService string  `json:"collector_service,omitempty"` // default: otel-collector.observability.svc.cluster.local
+	Port             int     `json:"port,omitempty"`              // OTLP gRPC port, default: 4317
+	Provider         string  `json:"provider,omitempty"`          // default: otel-tracing
+	Sampling         float64 `json:"sampling,omitempty"`          // percentage of requests traced, default: 100
+	IstioNamespace   string  `json:"istio_namespace,omitempty"`   // default: istio-system
+	Release          string  `json:"release,omitempty"`           // istiod Helm release, default: istiod
+	Revision         string  `json:"revision,omitempty"`          // control plane revision
+	RepoURL          string  `json:"repo_url,omitempty"`          // chart repository override
+	Verify           *bool   `json:"verify,omitempty"`            // default: true
+	Namespace        string  `json:"namespace,omitempty"`         // namespace of the sleep and httpbin apps used to verify, default: default
+	Requests         int     `json:"requests,omitempty"`          // default: 20
+	Timeout          string  `json:"timeout,omitempty"`           // default: 5m
+}
+
+// ConfigureTracing registers an OpenTelemetry collector as a meshConfig tracing provider, enables it
+// mesh-wide with a Telemetry resource at the given sampling rate, and verifies spans reach the collector
+func (m *Manager) ConfigureTracing(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureTracingParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.CollectorService == "" {
+		params.CollectorService = "otel-collector.observability.svc.cluster.local"
+	}
+	if params.Port == 0 {
+		params.Port = otelGRPCPort
+	}
+	if params.Provider == "" {
+		params.Provider = "otel-tracing"
+	}
+	if params.Sampling == 0 {
+		params.Sampling = 100
+	}
+	if params.Sampling < 0 || params.Sampling > 100 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid sampling %v: must be a percentage between 0 and 100", params.Sampling),
+				},
+			},
+		}, nil
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Verify == nil {
+		params.Verify = boolPtr(true)
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Requests == 0 {
+		params.Requests = 20
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	ctx = withManagingTool(ctx, "configure_tracing")
+
+	result := &TracingConfigResult{
+		Provider:  params.Provider,
+		Telemetry: params.IstioNamespace + "/mesh-default",
+		Sampling:  params.Sampling,
+		Timestamp: time.Now(),
+	}
+
+	// Register the collector as an extension provider, replacing any provider with the same name
+	provider := map[string]interface{}{
+		"name": params.Provider,
+		"opentelemetry": map[string]interface{}{
+			"service": params.CollectorService,
+			"port":    params.Port,
+		},
+	}
+	result.ProviderConfig = provider
+
+	providers, err := m.meshExtensionProviders(ctx, params.IstioNamespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+	var updated []map[string]interface{}
+	updated, result.ProviderUpdated = mergeExtensionProvider(providers, provider)
+	if result.ProviderUpdated {
+		if err := m.setIstiodMeshConfig(ctx, params.IstioNamespace, params.Release, params.RepoURL, "extensionProviders", updated, params.Timeout); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to register tracing provider: %v", err),
+					},
+				},
+			}, nil
+		}
+	} else {
+		result.Notes = append(result.Notes, fmt.Sprintf("Extension provider %s was already configured", params.Provider))
+	}
+
+	// A Telemetry resource in the root namespace turns the provider on for the whole mesh
+	telemetry := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "telemetry.istio.io/v1alpha1",
+		"kind":       "Telemetry",
+		"metadata": map[string]interface{}{
+			"name":      "mesh-default",
+			"namespace": params.IstioNamespace,
+		},
+		"spec": map[string]interface{}{
+			"tracing": []interface{}{map[string]interface{}{
+				"providers":                []interface{}{map[string]interface{}{"name": params.Provider}},
+				"randomSamplingPercentage": params.Sampling,
+			}},
+		},
+	}}
+	if err := m.applyResource(ctx, telemetryGVR, telemetry); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply Telemetry: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if *params.Verify {
+		verification, err := m.verifyTracing(ctx, params.CollectorService, params.Namespace, params.Requests)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Could not verify spans reach the collector: %v", err))
+		} else {
+			result.Verification = verification
+			if !verification.Passed {
+				result.Issues = append(result.Issues, fmt.Sprintf("The collector received no spans within %s of %d requests; check the istio-proxy logs for OpenTelemetry export errors", verification.WaitedFor, verification.Requests))
+			}
+			if params.Sampling < 100 {
+				result.Notes = append(result.Notes, fmt.Sprintf("Sampling is %v%%, so only some of the verification requests are traced", params.Sampling))
+			}
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// verifyTracing sends requests from sleep to httpbin and waits for the collector's accepted span
+// count to grow
+func (m *Manager) verifyTracing(ctx context.Context, collectorService, namespace string, requests int) (*TracingVerification, error) {
+	collector, err := m.otelCollectorPod(ctx, collectorService)
+	if err != nil {
+		return nil, err
+	}
+	client, err := m.findWaypointSource(ctx, namespace, "")
+	if err != nil {
+		return nil, fmt.Errorf("%w; deploy the sleep and httpbin apps to generate traffic", err)
+	}
+
+	verification := &TracingVerification{
+		Requests: requests,
+		Source:   client.Namespace + "/" + client.Name,
+		Target:   fmt.Sprintf("http://httpbin.%s.svc.cluster.local:8000/get", namespace),
+	}
+	if verification.SpansBefore, err = m.otelAcceptedSpans(ctx, collector); err != nil {
+		return nil, err
+	}
+
+	// Proxies pick up the Telemetry change asynchronously, so traffic is resent until spans arrive
+	start := time.Now()
+	err = wait.PollUntilContextTimeout(ctx, 5*time.Second, time.Minute, true, func(ctx context.Context) (bool, error) {
+		for i := 0; i < requests; i++ {
+			if _, _, err := m.curlFromPod(ctx, client, verification.Target, nil, 5); err != nil {
+				return false, err
+			}
+		}
+		spans, err := m.otelAcceptedSpans(ctx, collector)
+		if err != nil {
+			return false, err
+		}
+		verification.SpansAfter = spans
+		return spans > verification.SpansBefore, nil
+	})
+	verification.WaitedFor = time.Since(start).Round(time.Second).String()
+	if err != nil && verification.SpansAfter == 0 && verification.SpansBefore == 0 && !wait.Interrupted(err) {
+		return nil, err
+	}
+	verification.SpansReceived = verification.SpansAfter - verification.SpansBefore
+	verification.Passed = verification.SpansReceived > 0
+	return verification, nil
+}
+
+// otelCollectorPod finds a ready pod behind the collector service, given as <service>.<namespace>[.svc...]
+func (m *Manager) otelCollectorPod(ctx context.Context, collectorService string) (*corev1.Pod, error) {
+	parts := strings.Split(collectorService, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("collector service %q must include its namespace, e.g. otel-collector.observability", collectorService)
+	}
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(parts[1]).Get(ctx, parts[0], metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get collector service: %w", err)
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(parts[1]).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collector pods: %w", err)
+	}
+	for i := range pods.Items {
+		if isPodReady(&pods.Items[i]) {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no ready pod backs collector service %s/%s", parts[1], parts[0])
+}
+
+// otelAcceptedSpans reads how many spans the collector's receivers have accepted
+func (m *Manager) otelAcceptedSpans(ctx context.Context, collector *corev1.Pod) (int, error) {
+	body, err := m.portForwardGet(ctx, collector.Namespace, collector.Name, otelMetricsPort, "/metrics")
+	if err != nil {
+		return 0, fmt.Errorf("failed to read collector metrics: %w", err)
+	}
+	total := 0.0
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "otelcol_receiver_accepted_spans") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if value, err := strconv.ParseFloat(fields[len(fields)-1], 64); err == nil {
+			total += value
+		}
+	}
+	return int(total), nil
+}
+
+// createOtelCollector creates the collector's config, deployment and service
+func (m *Manager) createOtelCollector(ctx context.Context, namespace, name, image string) error {
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Data: map[string]string{
+			"config.yaml": otelCollectorConfig,
+		},
+	}
+	markManaged(ctx, configMap)
+	_, err := m.k8sClient.Kubernetes.CoreV1().ConfigMaps(namespace).Create(ctx, configMap, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create configmap: %w", err)
+	}
+
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &replicas,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{
+					"app": name,
+				},
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: map[string]string{
+						"app": name,
+					},
+					Annotations: map[string]string{
+						"sidecar.istio.io/inject": "false",
+					},
+				},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{
+						{
+							Name:            "otel-collector",
+							Image:           image,
+							ImagePullPolicy: corev1.PullIfNotPresent,
+							Args:            []string{"--config=/etc/otelcol/config.yaml"},
+							Ports: []corev1.ContainerPort{
+								{ContainerPort: otelGRPCPort, Name: "grpc-otlp", Protocol: corev1.ProtocolTCP},
+								{ContainerPort: otelHTTPPort, Name: "http-otlp", Protocol: corev1.ProtocolTCP},
+								{ContainerPort: otelMetricsPort, Name: "http-metrics", Protocol: corev1.ProtocolTCP},
+							},
+							VolumeMounts: []corev1.VolumeMount{
+								{
+									MountPath: "/etc/otelcol",
+									Name:      "config",
+								},
+							},
+							Resources: corev1.ResourceRequirements{
+								Requests: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("50m"),
+									corev1.ResourceMemory: resource.MustParse("64Mi"),
+								},
+								Limits: corev1.ResourceList{
+									corev1.ResourceCPU:    resource.MustParse("500m"),
+									corev1.ResourceMemory: resource.MustParse("512Mi"),
+								},
+							},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{
+							Name: "config",
+							VolumeSource: corev1.VolumeSource{
+								ConfigMap: &corev1.ConfigMapVolumeSource{
+									LocalObjectReference: corev1.LocalObjectReference{Name: name},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+	markManaged(ctx, deployment)
+	_, err = m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create deployment: %w", err)
+	}
+
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels: map[string]string{
+				"app": name,
+			},
+		},
+		Spec: corev1.ServiceSpec{
+			Ports: []corev1.ServicePort{
+				{Name: "grpc-otlp", Port: otelGRPCPort, TargetPort: intstr.FromInt(otelGRPCPort), Protocol: corev1.ProtocolTCP},
+				{Name: "http-otlp", Port: otelHTTPPort, TargetPort: intstr.FromInt(otelHTTPPort), Protocol: corev1.ProtocolTCP},
+			},
+			Selector: map[string]string{
+				"app": name,
+			},
+		},
+	}
+	markManaged(ctx, service)
+	_, err = m.k8sClient.Kubernetes.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return fmt.Errorf("failed to create service: %w", err)
+	}
+
+	return nil
+}
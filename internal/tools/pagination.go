@@ -0,0 +1,132 @@
+package tools
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+)
+
+// paginatedTools return their items a page at a time, taking the cursor and page_size arguments
+var paginatedTools = map[string]bool{
+	"get_cluster_info":      true,
+	"get_interception_mode": true,
+	"get_network_policies":  true,
+	"get_pod_logs":          true,
+	"get_istio_proxy_logs":  true,
+}
+
+// Paginated reports whether a tool returns its items a page at a time
+func Paginated(toolName string) bool {
+	return paginatedTools[toolName]
+}
+
+// Pagination is embedded in the results of list-style tools, which return one page of items per call
+type Pagination struct {
+	TotalItems int    `json:"total_items,omitempty"` // items across all pages, when known
+	NextCursor string `json:"next_cursor,omitempty"` // pass as cursor, with the same arguments, for the next page
+}
+
+// pageCursor is the state of a paged listing, handed to the caller as an opaque string. The server keeps nothing,
+// so a cursor works from the CLI as well as MCP and survives a restart
+type pageCursor struct {
+	Query  string `json:"q"`           // hash of the tool and arguments the cursor belongs to
+	Offset int    `json:"o,omitempty"` // items already returned, or log lines already returned at After
+	After  string `json:"a,omitempty"` // timestamp of the last log line returned
+}
+
+// pager cuts the items of one tool call into pages
+type pager struct {
+	query    string
+	size     int
+	maxBytes int
+	cursor   pageCursor
+}
+
+// newPager reads the cursor and page_size arguments of a list-style tool call
+func (m *Manager) newPager(toolName string, args json.RawMessage) (*pager, error) {
+	var params struct {
+		Cursor   string `json:"cursor,omitempty"`
+		PageSize int    `json:"page_size,omitempty"` // default: pagination.page_size in the config
+	}
+	if err := json.Unmarshal(args, &params); err != nil {
+		return nil, err
+	}
+	if params.PageSize < 0 {
+		return nil, fmt.Errorf("page_size must be positive")
+	}
+
+	p := &pager{
+		query:    pageQuery(toolName, args),
+		size:     params.PageSize,
+		maxBytes: m.config.Pagination.MaxResponseBytes,
+	}
+	if p.size == 0 {
+		p.size = m.config.Pagination.PageSize
+	}
+	if params.Cursor == "" {
+		return p, nil
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(params.Cursor)
+	if err == nil {
+		err = json.Unmarshal(data, &p.cursor)
+	}
+	if err != nil || p.cursor.Offset < 0 {
+		return nil, fmt.Errorf("invalid cursor")
+	}
+	if p.cursor.Query != p.query {
+		return nil, fmt.Errorf("cursor belongs to a %s call with other arguments", toolName)
+	}
+	return p, nil
+}
+
+// pageQuery identifies a listing by its tool and arguments, other than the paging ones
+func pageQuery(toolName string, args json.RawMessage) string {
+	var values map[string]interface{}
+	_ = json.Unmarshal(args, &values)
+	delete(values, "cursor")
+	delete(values, "page_size")
+	// Maps are marshaled with sorted keys, so the same arguments always hash the same
+	canonical, _ := json.Marshal(values)
+	sum := sha256.Sum256(append([]byte(toolName+"\x00"), canonical...))
+	return hex.EncodeToString(sum[:8])
+}
+
+// firstPage reports whether the call asked for the first page
+func (p *pager) firstPage() bool {
+	return p.cursor.Offset == 0 && p.cursor.After == ""
+}
+
+// encode returns the cursor of the next page
+func (p *pager) encode(cursor pageCursor) string {
+	cursor.Query = p.query
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// fits reports whether one more item of the given encoded size keeps a page within the response limit. The
+// first item of a page always fits, so a listing always moves forward
+func (p *pager) fits(count, used, size int) bool {
+	return count < p.size && (count == 0 || used+size <= p.maxBytes)
+}
+
+// paginate returns the page of items the cursor points at, and the cursor of the page after it
+func paginate[T any](p *pager, items []T) ([]T, Pagination) {
+	page := Pagination{TotalItems: len(items)}
+	start := min(p.cursor.Offset, len(items))
+	end, used := start, 0
+	for end < len(items) {
+		data, _ := json.Marshal(items[end])
+		if !p.fits(end-start, used, len(data)) {
+			break
+		}
+		used += len(data)
+		end++
+	}
+	if end < len(items) {
+		page.NextCursor = p.encode(pageCursor{Offset: end})
+	}
+	return items[start:end], page
+}
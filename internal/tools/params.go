@@ -0,0 +1,140 @@
+package tools
+
+import "reflect"
+
+// toolParams maps each tool to the struct its handler decodes the arguments into; the MCP input schemas are
+// derived from these types, so the parameters a tool advertises are the ones it parses
+var toolParams = map[string]interface{}{
+	"switch_context":                    switchContextParams{},
+	"configure_kubeconfig":              configureKubeconfigParams{},
+	"get_cluster_info":                  getClusterInfoParams{},
+	"check_tool_permissions":            checkToolPermissionsParams{},
+	"validate_access":                   validateAccessParams{},
+	"get_fleet_status":                  getFleetStatusParams{},
+	"create_dev_cluster":                createDevClusterParams{},
+	"delete_dev_cluster":                deleteDevClusterParams{},
+	"install_metallb":                   installMetalLBParams{},
+	"self_test":                         selfTestParams{},
+	"install_istio":                     installIstioParams{},
+	"uninstall_istio":                   uninstallIstioParams{},
+	"istio_canary_upgrade":              istioCanaryUpgradeParams{},
+	"migrate_istio_install":             migrateIstioInstallParams{},
+	"migrate_to_ambient":                migrateToAmbientParams{},
+	"inspect_revision_tags":             inspectRevisionTagsParams{},
+	"audit_discovery_selectors":         auditDiscoverySelectorsParams{},
+	"configure_discovery_selectors":     configureDiscoverySelectorsParams{},
+	"audit_istio_resources":             auditIstioResourcesParams{},
+	"istio_analyze":                     istioAnalyzeParams{},
+	"get_injection_config":              getInjectionConfigParams{},
+	"set_injection_template":            setInjectionTemplateParams{},
+	"preview_injection":                 previewInjectionParams{},
+	"install_otel_collector":            installOtelCollectorParams{},
+	"configure_tracing":                 configureTracingParams{},
+	"proxy_status":                      proxyStatusParams{},
+	"watch_mesh_events":                 watchMeshEventsParams{},
+	"istiod_debug":                      istiodDebugParams{},
+	"audit_istiod_flags":                auditIstiodFlagsParams{},
+	"check_istio_status":                checkIstioStatusParams{},
+	"check_install_capacity":            checkInstallCapacityParams{},
+	"estimate_mesh_cost":                estimateMeshCostParams{},
+	"check_cni_chaining":                checkCNIChainingParams{},
+	"detect_cni_race":                   detectCNIRaceParams{},
+	"get_release_values":                getReleaseValuesParams{},
+	"list_available_istio_versions":     listAvailableIstioVersionsParams{},
+	"get_istio_release_notes":           getIstioReleaseNotesParams{},
+	"install_sail_operator":             installSailOperatorParams{},
+	"uninstall_sail_operator":           uninstallSailOperatorParams{},
+	"check_sail_status":                 checkSailStatusParams{},
+	"deploy_sleep_app":                  deploySleepAppParams{},
+	"deploy_httpbin_app":                deployHttpbinAppParams{},
+	"undeploy_sleep_app":                undeploySleepAppParams{},
+	"undeploy_httpbin_app":              undeployHttpbinAppParams{},
+	"deploy_bookinfo_app":               deployBookinfoAppParams{},
+	"undeploy_bookinfo_app":             undeployBookinfoAppParams{},
+	"apply_manifest":                    applyManifestParams{},
+	"cleanup_demo":                      cleanupDemoParams{},
+	"list_managed_resources":            listManagedResourcesParams{},
+	"test_connectivity":                 testConnectivityParams{},
+	"test_sleep_to_httpbin":             testSleepToHttpbinParams{},
+	"test_ingress_connectivity":         testIngressConnectivityParams{},
+	"test_gateway_paths":                testGatewayPathsParams{},
+	"sweep_service_ports":               sweepServicePortsParams{},
+	"verify_waypoint":                   verifyWaypointParams{},
+	"test_header_routing":               testHeaderRoutingParams{},
+	"generate_traffic":                  generateTrafficParams{},
+	"run_mesh_conformance":              runMeshConformanceParams{},
+	"configure_egress_routing":          configureEgressRoutingParams{},
+	"probe_gateway_tls":                 probeGatewayTLSParams{},
+	"diagnose_ingress_request":          diagnoseIngressRequestParams{},
+	"benchmark_mesh_overhead":           benchmarkMeshOverheadParams{},
+	"start_monitor":                     startMonitorParams{},
+	"stop_monitor":                      stopMonitorParams{},
+	"traffic_shift":                     trafficShiftParams{},
+	"create_virtual_service":            createVirtualServiceParams{},
+	"get_virtual_service":               getVirtualServiceParams{},
+	"delete_virtual_service":            deleteVirtualServiceParams{},
+	"create_destination_rule":           createDestinationRuleParams{},
+	"get_destination_rule":              getDestinationRuleParams{},
+	"delete_destination_rule":           deleteDestinationRuleParams{},
+	"detect_connection_pool_exhaustion": detectConnectionPoolExhaustionParams{},
+	"expose_service_via_gateway":        exposeServiceViaGatewayParams{},
+	"create_gateway_route":              createGatewayRouteParams{},
+	"get_gateway_routes":                getGatewayRoutesParams{},
+	"delete_gateway_route":              deleteGatewayRouteParams{},
+	"get_gateway_status":                getGatewayStatusParams{},
+	"get_monitor_results":               getMonitorResultsParams{},
+	"get_scheduled_results":             getScheduledResultsParams{},
+	"get_pod_logs":                      getPodLogsParams{},
+	"get_istio_proxy_logs":              getIstioProxyLogsParams{},
+	"get_istiod_logs":                   getIstiodLogsParams{},
+	"analyze_response_flags":            analyzeResponseFlagsParams{},
+	"get_kubernetes_events":             getKubernetesEventsParams{},
+	"exec_pod_command":                  execPodCommandParams{},
+	"get_iptables_rules":                getIptablesRulesParams{},
+	"capture_packets":                   capturePacketsParams{},
+	"get_interception_mode":             getInterceptionModeParams{},
+	"configure_traffic_exclusions":      configureTrafficExclusionsParams{},
+	"configure_dns_proxying":            configureDNSProxyingParams{},
+	"tune_proxy":                        tuneProxyParams{},
+	"audit_sidecar_startup":             auditSidecarStartupParams{},
+	"diagnose_job_sidecars":             diagnoseJobSidecarsParams{},
+	"inspect_sidecar_annotations":       inspectSidecarAnnotationsParams{},
+	"detect_dataplane_mode":             detectDataplaneModeParams{},
+	"get_proxy_config":                  getProxyConfigParams{},
+	"envoy_admin_get":                   envoyAdminGetParams{},
+	"get_ztunnel_config":                getZtunnelConfigParams{},
+	"get_network_policies":              getNetworkPoliciesParams{},
+	"generate_network_policy":           generateNetworkPolicyParams{},
+	"trace_network_path":                traceNetworkPathParams{},
+	"diagnose_pod_node_network":         diagnosePodNodeNetworkParams{},
+	"validate_dual_stack":               validateDualStackParams{},
+	"check_clock_skew":                  checkClockSkewParams{},
+	"scan_mesh_images":                  scanMeshImagesParams{},
+	"setup_ext_authz":                   setupExtAuthzParams{},
+	"test_ext_authz":                    testExtAuthzParams{},
+	"install_spire":                     installSpireParams{},
+	"configure_istio_spire":             configureIstioSpireParams{},
+	"verify_spire_identities":           verifySpireIdentitiesParams{},
+	"set_mtls_mode":                     setMTLSModeParams{},
+	"get_mtls_status":                   getMTLSStatusParams{},
+	"check_mtls_between":                checkMTLSBetweenParams{},
+	"get_workload_certificates":         getWorkloadCertificatesParams{},
+	"inspect_trust_domain":              inspectTrustDomainParams{},
+	"create_authorization_policy":       createAuthorizationPolicyParams{},
+	"get_authorization_policy":          getAuthorizationPolicyParams{},
+	"delete_authorization_policy":       deleteAuthorizationPolicyParams{},
+	"audit_authorization_policies":      auditAuthorizationPoliciesParams{},
+	"list_history":                      listHistoryParams{},
+	"get_result":                        getResultParams{},
+	"compare_with_snapshot":             compareWithSnapshotParams{},
+}
+
+// ParamsType returns the type of the struct a tool's handler decodes the arguments into, or nil for tools
+// that take no arguments
+func ParamsType(toolName string) reflect.Type {
+	params, ok := toolParams[toolName]
+	if !ok {
+		return nil
+	}
+	return reflect.TypeOf(params)
+}
@@ -0,0 +1,504 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	authenticationv1 "k8s.io/api/authentication/v1"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// permissionProbeTimeout bounds a full permission probe of all tools
+	permissionProbeTimeout = 30 * time.Second
+	// permissionProbeConcurrency is the number of SelfSubjectAccessReviews in flight at once
+	permissionProbeConcurrency = 16
+)
+
+// clusterScopedResources are the resources in toolPermissions that have no namespace
+var clusterScopedResources = map[string]bool{
+	"nodes":                         true,
+	"namespaces":                    true,
+	"customresourcedefinitions":     true,
+	"clusterroles":                  true,
+	"mutatingwebhookconfigurations": true,
+	"csidrivers":                    true,
+	"clusterspiffeids":              true,
+}
+
+// permission is a Kubernetes API access a tool needs
+type permission struct {
+	verb        string
+	group       string
+	resource    string
+	subresource string
+}
+
+// String formats the permission like kubectl auth can-i, e.g. "create pods/exec"
+func (p permission) String() string {
+	resource := p.resource
+	if p.group != "" {
+		resource += "." + p.group
+	}
+	if p.subresource != "" {
+		resource += "/" + p.subresource
+	}
+	return p.verb + " " + resource
+}
+
+var (
+	getPods          = permission{verb: "get", resource: "pods"}
+	listPods         = permission{verb: "list", resource: "pods"}
+	listNodes        = permission{verb: "list", resource: "nodes"}
+	getNamespaces    = permission{verb: "get", resource: "namespaces"}
+	listNamespaces   = permission{verb: "list", resource: "namespaces"}
+	getServices      = permission{verb: "get", resource: "services"}
+	getPodLogs       = permission{verb: "get", resource: "pods", subresource: "log"}
+	execPods         = permission{verb: "create", resource: "pods", subresource: "exec"}
+	debugPods        = permission{verb: "update", resource: "pods", subresource: "ephemeralcontainers"}
+	portForwardPods  = permission{verb: "create", resource: "pods", subresource: "portforward"}
+	listDeployments  = permission{verb: "list", group: "apps", resource: "deployments"}
+	listDaemonSets   = permission{verb: "list", group: "apps", resource: "daemonsets"}
+	listSecrets      = permission{verb: "list", resource: "secrets"}
+	getConfigMaps    = permission{verb: "get", resource: "configmaps"}
+	createNamespaces = permission{verb: "create", resource: "namespaces"}
+	createCRDs       = permission{verb: "create", group: "apiextensions.k8s.io", resource: "customresourcedefinitions"}
+	deleteCRDs       = permission{verb: "delete", group: "apiextensions.k8s.io", resource: "customresourcedefinitions"}
+	createRoles      = permission{verb: "create", group: "rbac.authorization.k8s.io", resource: "clusterroles"}
+	createWebhooks   = permission{verb: "create", group: "admissionregistration.k8s.io", resource: "mutatingwebhookconfigurations"}
+	listNetpols      = permission{verb: "list", group: "networking.k8s.io", resource: "networkpolicies"}
+)
+
+// toolPermissions lists the permissions each tool needs to do its job; tools missing here need no cluster access
+var toolPermissions = map[string][]permission{
+	"get_cluster_info":              {listNodes, listNamespaces},
+	"install_metallb":               {createNamespaces, createCRDs, createRoles},
+	"self_test":                     {createNamespaces, createCRDs, createRoles, createWebhooks, execPods},
+	"install_istio":                 {createNamespaces, createCRDs, createRoles, createWebhooks},
+	"uninstall_istio":               {deleteCRDs, {verb: "delete", resource: "namespaces"}},
+	"migrate_istio_install":         {createCRDs, createRoles, createWebhooks, listSecrets, listPods, {verb: "patch", group: "apps", resource: "deployments"}, {verb: "patch", group: "rbac.authorization.k8s.io", resource: "clusterroles"}, {verb: "patch", group: "admissionregistration.k8s.io", resource: "mutatingwebhookconfigurations"}, {verb: "delete", group: "install.istio.io", resource: "istiooperators"}, {verb: "update", resource: "namespaces"}},
+	"migrate_to_ambient":            {listPods, execPods, {verb: "update", resource: "namespaces"}, {verb: "create", group: "gateway.networking.k8s.io", resource: "gateways"}, {verb: "update", group: "apps", resource: "deployments"}, {verb: "update", group: "apps", resource: "statefulsets"}, {verb: "update", group: "apps", resource: "daemonsets"}},
+	"istio_canary_upgrade":          {createCRDs, createRoles, createWebhooks, listSecrets, listPods, portForwardPods, {verb: "update", resource: "namespaces"}, {verb: "update", group: "apps", resource: "deployments"}, {verb: "update", group: "apps", resource: "statefulsets"}, {verb: "update", group: "apps", resource: "daemonsets"}},
+	"check_istio_status":            {listPods, listDeployments},
+	"proxy_status":                  {listPods, portForwardPods},
+	"istiod_debug":                  {listPods, portForwardPods},
+	"audit_istiod_flags":            {listDeployments},
+	"watch_mesh_events":             {listPods, {verb: "watch", resource: "pods"}, {verb: "watch", resource: "events"}},
+	"inspect_revision_tags":         {listDeployments, listNamespaces, listPods, {verb: "list", group: "admissionregistration.k8s.io", resource: "mutatingwebhookconfigurations"}},
+	"audit_discovery_selectors":     {listNamespaces, getConfigMaps},
+	"istio_analyze":                 {listNamespaces, listPods, listSecrets, {verb: "list", resource: "services"}, {verb: "list", group: "networking.istio.io", resource: "virtualservices"}, {verb: "list", group: "networking.istio.io", resource: "destinationrules"}, {verb: "list", group: "networking.istio.io", resource: "gateways"}, {verb: "list", group: "networking.istio.io", resource: "serviceentries"}, {verb: "list", group: "security.istio.io", resource: "authorizationpolicies"}, {verb: "list", group: "security.istio.io", resource: "peerauthentications"}},
+	"audit_istio_resources":         {listNamespaces, listPods, listSecrets, {verb: "list", resource: "services"}, {verb: "list", group: "networking.istio.io", resource: "virtualservices"}, {verb: "list", group: "networking.istio.io", resource: "destinationrules"}, {verb: "list", group: "networking.istio.io", resource: "gateways"}, {verb: "list", group: "networking.istio.io", resource: "serviceentries"}, {verb: "list", group: "security.istio.io", resource: "authorizationpolicies"}, {verb: "list", group: "security.istio.io", resource: "peerauthentications"}},
+	"get_injection_config":          {getConfigMaps},
+	"set_injection_template":        {getConfigMaps, listSecrets, {verb: "update", group: "apps", resource: "deployments"}, {verb: "update", resource: "configmaps"}, {verb: "create", resource: "pods"}},
+	"preview_injection":             {{verb: "create", resource: "pods"}},
+	"configure_discovery_selectors": {listNamespaces, getConfigMaps, listSecrets, {verb: "update", group: "apps", resource: "deployments"}},
+	"install_otel_collector":        {createNamespaces, listPods, {verb: "create", resource: "configmaps"}, {verb: "create", group: "apps", resource: "deployments"}, {verb: "create", resource: "services"}},
+	"configure_tracing":             {getConfigMaps, listSecrets, getServices, listPods, execPods, portForwardPods, {verb: "update", group: "apps", resource: "deployments"}, {verb: "create", group: "telemetry.istio.io", resource: "telemetries"}},
+	"check_install_capacity":        {listNodes, {verb: "list", resource: "resourcequotas"}, {verb: "list", resource: "limitranges"}},
+	"estimate_mesh_cost":            {listNodes, listPods, listNamespaces, getConfigMaps},
+	"check_cni_chaining":            {listNodes, listDaemonSets, execPods},
+	"detect_cni_race":               {listPods, listDaemonSets, getNamespaces},
+	"get_release_values":            {listSecrets},
+	"install_sail_operator":         {createNamespaces, createCRDs, createRoles},
+	"uninstall_sail_operator":       {deleteCRDs},
+	"check_sail_status":             {listPods, listDeployments},
+	"deploy_sleep_app": {
+		{verb: "create", group: "apps", resource: "deployments"},
+		{verb: "create", resource: "services"},
+		{verb: "create", resource: "serviceaccounts"},
+	},
+	"deploy_httpbin_app": {
+		{verb: "create", group: "apps", resource: "deployments"},
+		{verb: "create", resource: "services"},
+		{verb: "create", resource: "serviceaccounts"},
+	},
+	"undeploy_sleep_app": {
+		{verb: "delete", group: "apps", resource: "deployments"},
+		{verb: "delete", resource: "services"},
+		{verb: "delete", resource: "serviceaccounts"},
+	},
+	"undeploy_httpbin_app": {
+		{verb: "delete", group: "apps", resource: "deployments"},
+		{verb: "delete", resource: "services"},
+		{verb: "delete", resource: "serviceaccounts"},
+	},
+	"deploy_bookinfo_app": {
+		{verb: "create", group: "apps", resource: "deployments"},
+		{verb: "create", resource: "services"},
+		{verb: "create", resource: "serviceaccounts"},
+		{verb: "create", group: "networking.istio.io", resource: "destinationrules"},
+		{verb: "create", group: "networking.istio.io", resource: "gateways"},
+		{verb: "create", group: "networking.istio.io", resource: "virtualservices"},
+	},
+	"undeploy_bookinfo_app": {
+		{verb: "delete", group: "apps", resource: "deployments"},
+		{verb: "delete", resource: "services"},
+		{verb: "delete", resource: "serviceaccounts"},
+		{verb: "delete", group: "networking.istio.io", resource: "destinationrules"},
+		{verb: "delete", group: "networking.istio.io", resource: "gateways"},
+		{verb: "delete", group: "networking.istio.io", resource: "virtualservices"},
+	},
+	"apply_manifest": {
+		{verb: "patch", group: "apps", resource: "deployments"},
+		{verb: "patch", resource: "services"},
+		{verb: "patch", resource: "serviceaccounts"},
+		{verb: "patch", resource: "configmaps"},
+	},
+	"cleanup_demo": {
+		listPods, listDeployments,
+		{verb: "delete", group: "apps", resource: "deployments"},
+		{verb: "delete", resource: "services"},
+		{verb: "delete", resource: "serviceaccounts"},
+		{verb: "delete", resource: "configmaps"},
+		{verb: "delete", resource: "namespaces"},
+		{verb: "delete", group: "networking.k8s.io", resource: "networkpolicies"},
+		{verb: "delete", group: "networking.istio.io", resource: "virtualservices"},
+		{verb: "delete", group: "networking.istio.io", resource: "destinationrules"},
+		{verb: "delete", group: "networking.istio.io", resource: "gateways"},
+		{verb: "delete", group: "networking.istio.io", resource: "serviceentries"},
+		{verb: "delete", group: "security.istio.io", resource: "authorizationpolicies"},
+		{verb: "delete", group: "security.istio.io", resource: "peerauthentications"},
+		{verb: "delete", group: "telemetry.istio.io", resource: "telemetries"},
+	},
+	"list_managed_resources": {
+		listNamespaces, listDeployments,
+		{verb: "list", resource: "services"},
+		{verb: "list", resource: "serviceaccounts"},
+		{verb: "list", resource: "configmaps"},
+		{verb: "list", group: "networking.k8s.io", resource: "networkpolicies"},
+		{verb: "list", group: "networking.istio.io", resource: "virtualservices"},
+		{verb: "list", group: "networking.istio.io", resource: "destinationrules"},
+		{verb: "list", group: "networking.istio.io", resource: "gateways"},
+		{verb: "list", group: "networking.istio.io", resource: "serviceentries"},
+		{verb: "list", group: "security.istio.io", resource: "authorizationpolicies"},
+		{verb: "list", group: "security.istio.io", resource: "peerauthentications"},
+		{verb: "list", group: "telemetry.istio.io", resource: "telemetries"},
+	},
+	"test_connectivity":                 {getPods, execPods},
+	"test_sleep_to_httpbin":             {listPods, getServices, execPods},
+	"test_ingress_connectivity":         {getServices},
+	"test_gateway_paths":                {getServices, listPods, getPods, listNodes, execPods},
+	"sweep_service_ports":               {listPods, execPods, {verb: "list", resource: "services"}, {verb: "get", resource: "endpoints"}},
+	"probe_gateway_tls":                 {getServices, {verb: "list", group: "networking.istio.io", resource: "gateways"}},
+	"diagnose_ingress_request":          {getServices, listPods, getConfigMaps, portForwardPods, getPodLogs, {verb: "list", group: "networking.istio.io", resource: "gateways"}, {verb: "list", group: "networking.istio.io", resource: "virtualservices"}, {verb: "list", group: "gateway.networking.k8s.io", resource: "httproutes"}},
+	"verify_waypoint":                   {getServices, getPods, execPods, portForwardPods, getPodLogs},
+	"run_mesh_conformance":              {createNamespaces, execPods, portForwardPods, {verb: "delete", resource: "namespaces"}, {verb: "create", group: "networking.istio.io", resource: "virtualservices"}, {verb: "create", group: "security.istio.io", resource: "peerauthentications"}, {verb: "create", group: "security.istio.io", resource: "authorizationpolicies"}},
+	"test_header_routing":               {getServices, listPods, execPods, {verb: "list", group: "networking.istio.io", resource: "virtualservices"}},
+	"generate_traffic":                  {getServices, listPods, execPods},
+	"configure_egress_routing":          {listPods, getServices, getConfigMaps, execPods, portForwardPods, getPodLogs, {verb: "create", group: "networking.istio.io", resource: "serviceentries"}, {verb: "create", group: "networking.istio.io", resource: "gateways"}, {verb: "create", group: "networking.istio.io", resource: "destinationrules"}, {verb: "create", group: "networking.istio.io", resource: "virtualservices"}},
+	"benchmark_mesh_overhead":           {createNamespaces, {verb: "create", group: "apps", resource: "deployments"}, execPods},
+	"start_monitor":                     {getPods, execPods},
+	"traffic_shift":                     {getServices, listPods, {verb: "create", group: "networking.istio.io", resource: "destinationrules"}, {verb: "update", group: "networking.istio.io", resource: "destinationrules"}, {verb: "create", group: "networking.istio.io", resource: "virtualservices"}, {verb: "update", group: "networking.istio.io", resource: "virtualservices"}},
+	"create_virtual_service":            {{verb: "create", group: "networking.istio.io", resource: "virtualservices"}, {verb: "update", group: "networking.istio.io", resource: "virtualservices"}},
+	"get_virtual_service":               {{verb: "list", group: "networking.istio.io", resource: "virtualservices"}},
+	"delete_virtual_service":            {{verb: "delete", group: "networking.istio.io", resource: "virtualservices"}},
+	"create_destination_rule":           {{verb: "create", group: "networking.istio.io", resource: "destinationrules"}, {verb: "update", group: "networking.istio.io", resource: "destinationrules"}},
+	"get_destination_rule":              {{verb: "list", group: "networking.istio.io", resource: "destinationrules"}},
+	"delete_destination_rule":           {{verb: "delete", group: "networking.istio.io", resource: "destinationrules"}},
+	"detect_connection_pool_exhaustion": {listPods, portForwardPods, {verb: "list", group: "networking.istio.io", resource: "destinationrules"}},
+	"expose_service_via_gateway":        {getServices, {verb: "create", group: "networking.istio.io", resource: "gateways"}, {verb: "create", group: "networking.istio.io", resource: "virtualservices"}},
+	"create_gateway_route":              {{verb: "create", group: "gateway.networking.k8s.io", resource: "httproutes"}, {verb: "update", group: "gateway.networking.k8s.io", resource: "httproutes"}},
+	"get_gateway_routes":                {{verb: "list", group: "gateway.networking.k8s.io", resource: "httproutes"}},
+	"delete_gateway_route":              {{verb: "delete", group: "gateway.networking.k8s.io", resource: "httproutes"}},
+	"get_gateway_status":                {{verb: "list", group: "gateway.networking.k8s.io", resource: "gateways"}, {verb: "list", group: "gateway.networking.k8s.io", resource: "httproutes"}},
+	"get_pod_logs":                      {getPodLogs},
+	"get_istio_proxy_logs":              {getPodLogs},
+	"get_istiod_logs":                   {listPods, getPodLogs},
+	"analyze_response_flags":            {listPods, getPodLogs, getConfigMaps},
+	"get_kubernetes_events":             {{verb: "list", resource: "events"}, listPods, listDeployments, listDaemonSets, {verb: "list", group: "apps", resource: "replicasets"}},
+	"exec_pod_command":                  {execPods},
+	"get_iptables_rules":                {getPods, getPodLogs, debugPods, {verb: "create", resource: "pods"}, {verb: "delete", resource: "pods"}},
+	"capture_packets":                   {getPods, getPodLogs, debugPods, {verb: "create", resource: "pods"}, {verb: "delete", resource: "pods"}},
+	"get_interception_mode":             {listPods, getNamespaces},
+	"configure_traffic_exclusions": {
+		{verb: "update", group: "apps", resource: "deployments"},
+		listPods,
+		{verb: "patch", resource: "pods", subresource: "ephemeralcontainers"},
+		{verb: "create", resource: "pods"},
+		{verb: "delete", resource: "pods"},
+	},
+	"configure_dns_proxying": {
+		getConfigMaps, listSecrets, listPods, execPods,
+		{verb: "update", group: "apps", resource: "deployments"},
+		{verb: "create", group: "networking.istio.io", resource: "serviceentries"},
+		{verb: "delete", group: "networking.istio.io", resource: "serviceentries"},
+	},
+	"tune_proxy": {
+		getConfigMaps, listSecrets, listPods, execPods, createNamespaces,
+		{verb: "create", group: "apps", resource: "deployments"},
+		{verb: "update", group: "apps", resource: "deployments"},
+	},
+	"audit_sidecar_startup": {
+		getConfigMaps, listSecrets, listPods,
+		{verb: "get", group: "apps", resource: "replicasets"},
+		{verb: "get", group: "batch", resource: "jobs"},
+		{verb: "list", group: "apps", resource: "deployments"},
+		{verb: "update", group: "apps", resource: "deployments"},
+		{verb: "update", group: "apps", resource: "statefulsets"},
+		{verb: "update", group: "apps", resource: "daemonsets"},
+		{verb: "update", group: "batch", resource: "cronjobs"},
+	},
+	"diagnose_job_sidecars": {
+		getConfigMaps, listPods, execPods,
+		{verb: "list", group: "batch", resource: "jobs"},
+		{verb: "list", group: "apps", resource: "deployments"},
+		{verb: "update", group: "batch", resource: "cronjobs"},
+	},
+	"inspect_sidecar_annotations":  {getPods, {verb: "get", group: "apps", resource: "deployments"}},
+	"detect_dataplane_mode":        {listPods, listNamespaces},
+	"get_proxy_config":             {getPods, portForwardPods},
+	"envoy_admin_get":              {getPods, portForwardPods},
+	"get_ztunnel_config":           {listPods, portForwardPods},
+	"get_network_policies":         {listNetpols, listPods},
+	"generate_network_policy":      {listPods, getPodLogs, listNetpols},
+	"trace_network_path":           {getPods, execPods},
+	"validate_dual_stack":          {listNodes, listPods, getPods, execPods, getConfigMaps, listDeployments, getServices, {verb: "list", resource: "services"}},
+	"check_clock_skew":             {listNodes, listPods, getPods, execPods, {verb: "create", resource: "pods"}, {verb: "delete", resource: "pods"}},
+	"diagnose_pod_node_network":    {getPods, execPods, getPodLogs, {verb: "create", resource: "pods"}, {verb: "delete", resource: "pods"}},
+	"scan_mesh_images":             {listPods},
+	"setup_ext_authz":              {getConfigMaps, listSecrets, listPods, execPods, {verb: "create", group: "apps", resource: "deployments"}, {verb: "create", resource: "services"}, {verb: "update", group: "apps", resource: "deployments"}, {verb: "create", group: "security.istio.io", resource: "authorizationpolicies"}},
+	"install_spire":                {createNamespaces, createCRDs, createRoles, createWebhooks, {verb: "get", group: "storage.k8s.io", resource: "csidrivers"}},
+	"configure_istio_spire":        {getConfigMaps, listSecrets, {verb: "get", group: "storage.k8s.io", resource: "csidrivers"}, {verb: "update", group: "apps", resource: "deployments"}, {verb: "create", group: "spire.spiffe.io", resource: "clusterspiffeids"}},
+	"verify_spire_identities":      {getConfigMaps, listPods, portForwardPods},
+	"set_mtls_mode":                {listPods, {verb: "create", group: "security.istio.io", resource: "peerauthentications"}, {verb: "update", group: "security.istio.io", resource: "peerauthentications"}},
+	"get_mtls_status":              {listPods, listNamespaces, {verb: "list", group: "security.istio.io", resource: "peerauthentications"}},
+	"get_workload_certificates":    {getPods, listPods, portForwardPods},
+	"inspect_trust_domain":         {getConfigMaps, listPods, portForwardPods},
+	"check_mtls_between":           {getPods, listPods, getServices, getNamespaces, portForwardPods, {verb: "list", group: "security.istio.io", resource: "peerauthentications"}, {verb: "list", group: "networking.istio.io", resource: "destinationrules"}},
+	"create_authorization_policy":  {{verb: "create", group: "security.istio.io", resource: "authorizationpolicies"}, {verb: "update", group: "security.istio.io", resource: "authorizationpolicies"}},
+	"get_authorization_policy":     {{verb: "list", group: "security.istio.io", resource: "authorizationpolicies"}},
+	"delete_authorization_policy":  {{verb: "delete", group: "security.istio.io", resource: "authorizationpolicies"}},
+	"audit_authorization_policies": {listPods, listNamespaces, {verb: "list", group: "security.istio.io", resource: "authorizationpolicies"}},
+	"test_ext_authz":               {getConfigMaps, getServices, listPods, execPods, {verb: "list", group: "security.istio.io", resource: "authorizationpolicies"}},
+}
+
+// ToolAccess lists the permissions the current credentials lack for a tool
+type ToolAccess struct {
+	Tool    string   `json:"tool"`
+	Missing []string `json:"missing_permissions"`
+}
+
+// PermissionReport summarizes which tools the current credentials can run
+type PermissionReport struct {
+	User      string       `json:"user,omitempty"`
+	Groups    []string     `json:"groups,omitempty"`
+	Namespace string       `json:"namespace_checked,omitempty"`
+	Available int          `json:"available"`
+	Disabled  []ToolAccess `json:"disabled,omitempty"`
+	Limited   []ToolAccess `json:"limited,omitempty"` // lacking namespaced permissions in their default namespaces only
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// ProbeToolPermissions checks every tool's permissions with SelfSubjectAccessReviews, sent concurrently, and
+// caches the missing ones. A permission counts as granted if it is allowed cluster-wide or in a namespace the
+// tool may use: the scoped namespaces when tools are scoped, otherwise the tool's default namespaces. Unscoped
+// tools whose namespace is a parameter may still work elsewhere, so a namespaced permission denied there only
+// limits them (see LimitedPermissions) instead of disabling them
+func (m *Manager) ProbeToolPermissions(ctx context.Context) (map[string][]string, error) {
+	m, err := m.withCurrentClient()
+	if err != nil {
+		return nil, fmt.Errorf("Kubernetes client not available: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, permissionProbeTimeout)
+	defer cancel()
+
+	type check struct {
+		p         permission
+		namespace string
+	}
+	checks := make(map[check]bool)
+	for tool, perms := range toolPermissions {
+		for _, p := range perms {
+			for _, namespace := range m.permissionNamespaces(tool, p) {
+				checks[check{p, namespace}] = false
+			}
+		}
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		limit    = make(chan struct{}, permissionProbeConcurrency)
+	)
+	for c := range checks {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			review, err := m.k8sClient.Kubernetes.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authorizationv1.SelfSubjectAccessReview{
+				Spec: authorizationv1.SelfSubjectAccessReviewSpec{
+					ResourceAttributes: &authorizationv1.ResourceAttributes{
+						Namespace:   c.namespace,
+						Verb:        c.p.verb,
+						Group:       c.p.group,
+						Resource:    c.p.resource,
+						Subresource: c.p.subresource,
+					},
+				},
+			}, metav1.CreateOptions{})
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("failed to check %q: %w", c.p.String(), err)
+				}
+				return
+			}
+			checks[c] = review.Status.Allowed
+		}(c)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	missing := make(map[string][]string)
+	limited := make(map[string][]string)
+	for tool, perms := range toolPermissions {
+		for _, p := range perms {
+			granted := false
+			for _, namespace := range m.permissionNamespaces(tool, p) {
+				if checks[check{p, namespace}] {
+					granted = true
+					break
+				}
+			}
+			switch {
+			case granted:
+			case m.namespaceChosenPerCall(tool, p):
+				limited[tool] = append(limited[tool], p.String())
+			default:
+				missing[tool] = append(missing[tool], p.String())
+			}
+		}
+	}
+
+	m.permissionsMu.Lock()
+	m.missingPermissions = missing
+	m.limitedPermissions = limited
+	m.permissionsMu.Unlock()
+	return missing, nil
+}
+
+// permissionNamespaces returns the namespaces a tool's permission is checked in, cluster-wide first
+func (m *Manager) permissionNamespaces(tool string, p permission) []string {
+	namespaces := []string{""}
+	if clusterScopedResources[p.resource] {
+		return namespaces
+	}
+	if m.config.Scope.Enabled() {
+		return append(namespaces, m.config.Scope.Namespaces...)
+	}
+	for _, param := range toolScopes[tool].params {
+		for _, fallback := range []string{param.fallback, param.narrowFallback} {
+			if fallback != scopeAllNamespaces && fallback != scopeInherited && !containsString(namespaces, fallback) {
+				namespaces = append(namespaces, fallback)
+			}
+		}
+	}
+	return namespaces
+}
+
+// namespaceChosenPerCall reports whether a denied permission may still be granted in the namespace a call picks:
+// the permission is namespaced and the unscoped tool takes its namespace as a parameter
+func (m *Manager) namespaceChosenPerCall(tool string, p permission) bool {
+	ts := toolScopes[tool]
+	return !m.config.Scope.Enabled() && !clusterScopedResources[p.resource] && !ts.clusterWide && len(ts.params) > 0
+}
+
+// MissingPermissions returns the permissions a tool lacked at the last probe
+func (m *Manager) MissingPermissions(tool string) []string {
+	m.permissionsMu.Lock()
+	defer m.permissionsMu.Unlock()
+	return m.missingPermissions[tool]
+}
+
+// LimitedPermissions returns the namespaced permissions a tool lacked cluster-wide and in its default
+// namespaces at the last probe, which it may still have in the namespaces a call names
+func (m *Manager) LimitedPermissions(tool string) []string {
+	m.permissionsMu.Lock()
+	defer m.permissionsMu.Unlock()
+	return m.limitedPermissions[tool]
+}
+
+// checkToolPermissionsParams are the arguments of check_tool_permissions
+type checkToolPermissionsParams struct {
+	Tool string `json:"tool,omitempty"` // only report this tool
+}
+
+// CheckToolPermissions re-probes the current credentials and reports which tools they can run
+func (m *Manager) CheckToolPermissions(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkToolPermissionsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	missing, err := m.ProbeToolPermissions(ctx)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to probe permissions: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := PermissionReport{Timestamp: time.Now()}
+	if m.config.Scope.Enabled() {
+		report.Namespace = strings.Join(m.config.Scope.Namespaces, ", ")
+	}
+	// SelfSubjectReview needs Kubernetes 1.28+; the report is still useful without it
+	if review, err := m.k8sClient.Kubernetes.AuthenticationV1().SelfSubjectReviews().Create(ctx, &authenticationv1.SelfSubjectReview{}, metav1.CreateOptions{}); err == nil {
+		report.User = review.Status.UserInfo.Username
+		report.Groups = review.Status.UserInfo.Groups
+	}
+
+	tools := make([]string, 0, len(toolScopes))
+	for tool := range toolScopes {
+		if params.Tool == "" || params.Tool == tool {
+			tools = append(tools, tool)
+		}
+	}
+	sort.Strings(tools)
+	for _, tool := range tools {
+		if limited := m.LimitedPermissions(tool); len(limited) > 0 {
+			report.Limited = append(report.Limited, ToolAccess{Tool: tool, Missing: limited})
+		}
+		if len(missing[tool]) == 0 {
+			report.Available++
+			continue
+		}
+		report.Disabled = append(report.Disabled, ToolAccess{Tool: tool, Missing: missing[tool]})
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,100 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"k8s.io/client-go/tools/portforward"
+	"k8s.io/client-go/transport/spdy"
+)
+
+const (
+	// adminRequestTimeout bounds requests sent to admin endpoints through a port-forward
+	adminRequestTimeout = 30 * time.Second
+	// maxPortForwardBytes caps the responses read through a port-forward, well above the largest config dumps
+	maxPortForwardBytes = 64 * 1024 * 1024
+)
+
+// portForwardGet sends a GET request to a port inside a pod through a temporary port-forward.
+// Unlike the API server pod proxy, this reaches admin endpoints that only listen on localhost.
+// Responses larger than maxPortForwardBytes fail rather than being parsed incomplete.
+func (m *Manager) portForwardGet(ctx context.Context, namespace, podName string, port int, path string) ([]byte, error) {
+	body, truncated, err := m.portForwardGetLimited(ctx, namespace, podName, port, path, maxPortForwardBytes)
+	if err != nil {
+		return nil, err
+	}
+	if truncated {
+		return nil, fmt.Errorf("%s returned more than %d bytes", path, maxPortForwardBytes)
+	}
+	return body, nil
+}
+
+// portForwardGetLimited is portForwardGet reading at most maxBytes of the response, and reports whether
+// the response was longer
+func (m *Manager) portForwardGetLimited(ctx context.Context, namespace, podName string, port int, path string, maxBytes int) ([]byte, bool, error) {
+	transport, upgrader, err := spdy.RoundTripperFor(m.k8sClient.Config)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create port-forward transport: %w", err)
+	}
+
+	req := m.k8sClient.Kubernetes.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("portforward")
+	dialer := spdy.NewDialer(upgrader, &http.Client{Transport: transport}, http.MethodPost, req.URL())
+
+	stopCh := make(chan struct{})
+	readyCh := make(chan struct{})
+	forwarder, err := portforward.NewOnAddresses(dialer, []string{"127.0.0.1"}, []string{fmt.Sprintf("0:%d", port)}, stopCh, readyCh, io.Discard, io.Discard)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to create port-forward: %w", err)
+	}
+	defer close(stopCh)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- forwarder.ForwardPorts()
+	}()
+
+	select {
+	case <-readyCh:
+	case err := <-errCh:
+		return nil, false, fmt.Errorf("port-forward to %s/%s:%d failed: %w", namespace, podName, port, err)
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	ports, err := forwarder.GetPorts()
+	if err != nil || len(ports) == 0 {
+		return nil, false, fmt.Errorf("failed to get forwarded port: %v", err)
+	}
+
+	requestCtx, cancel := context.WithTimeout(ctx, adminRequestTimeout)
+	defer cancel()
+	httpReq, err := http.NewRequestWithContext(requestCtx, http.MethodGet, fmt.Sprintf("http://127.0.0.1:%d%s", ports[0].Local, path), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return nil, false, fmt.Errorf("request to %s failed: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	// A streaming or huge response must not be buffered whole
+	body, err := io.ReadAll(io.LimitReader(resp.Body, int64(maxBytes)+1))
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, false, fmt.Errorf("%s returned %s: %s", path, resp.Status, truncateText(string(body), 200))
+	}
+	if len(body) > maxBytes {
+		return body[:maxBytes], true, nil
+	}
+	return body, false, nil
+}
@@ -0,0 +1,456 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PreflightCheck represents the result of a single preflight check
+type PreflightCheck struct {
+	Name    string `json:"name"`
+	Passed  bool   `json:"passed"`
+	Message string `json:"message"`
+}
+
+// PreflightReport summarizes whether the cluster can accommodate an install
+type PreflightReport struct {
+	Passed bool             `json:"passed"`
+	Checks []PreflightCheck `json:"checks"`
+}
+
+// componentRequirement describes the resources an installed component will request
+type componentRequirement struct {
+	Name      string
+	Namespace string
+	Replicas  int
+	DaemonSet bool
+	CPU       resource.Quantity
+	Memory    resource.Quantity
+}
+
+// nodeCapacity tracks the unrequested resources of a schedulable node
+type nodeCapacity struct {
+	Name      string
+	CPU       resource.Quantity
+	Memory    resource.Quantity
+	Schedules bool // accepts pods without tolerations
+}
+
+// checkInstallCapacityParams are the arguments of check_install_capacity
+type checkInstallCapacityParams struct {
+	Namespace        string                 `json:"namespace,omitempty"`         // default: istio-system
+	Values           map[string]interface{} `json:"values,omitempty"`            // istiod helm values
+	InstallGateway   bool                   `json:"install_gateway,omitempty"`   // include the ingress gateway
+	GatewayNamespace string                 `json:"gateway_namespace,omitempty"` // default: istio-ingress
+	InstallCNI       bool                   `json:"install_cni,omitempty"`       // include the CNI node agent
+}
+
+// CheckInstallCapacity checks quotas, limit ranges and node capacity against an Istio install
+func (m *Manager) CheckInstallCapacity(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkInstallCapacityParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+	if params.GatewayNamespace == "" {
+		params.GatewayNamespace = "istio-ingress"
+	}
+
+	requirements := istioInstallRequirements(params.Namespace, params.GatewayNamespace, params.Values, params.InstallGateway, params.InstallCNI, false)
+	report, err := m.runInstallPreflight(ctx, requirements)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to run preflight checks: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// istioInstallRequirements returns the resource requests of the components an install will create,
+// using the chart defaults unless overridden in the istiod values
+func istioInstallRequirements(namespace, gatewayNamespace string, values map[string]interface{}, installGateway, installCNI, installZtunnel bool) []componentRequirement {
+	istiod := componentRequirement{
+		Name:      "istiod",
+		Namespace: namespace,
+		Replicas:  1,
+		CPU:       resource.MustParse("500m"),
+		Memory:    resource.MustParse("2048Mi"),
+	}
+	if cpu := getHelmValue(values, "pilot.resources.requests.cpu"); cpu != "" {
+		if q, err := resource.ParseQuantity(cpu); err == nil {
+			istiod.CPU = q
+		}
+	}
+	if memory := getHelmValue(values, "pilot.resources.requests.memory"); memory != "" {
+		if q, err := resource.ParseQuantity(memory); err == nil {
+			istiod.Memory = q
+		}
+	}
+	if pilot, ok := values["pilot"].(map[string]interface{}); ok {
+		if replicas, ok := pilot["replicaCount"].(float64); ok && replicas > 0 {
+			istiod.Replicas = int(replicas)
+		}
+	}
+
+	requirements := []componentRequirement{istiod}
+	if installGateway {
+		requirements = append(requirements, componentRequirement{
+			Name:      "istio-ingress",
+			Namespace: gatewayNamespace,
+			Replicas:  1,
+			CPU:       resource.MustParse("100m"),
+			Memory:    resource.MustParse("128Mi"),
+		})
+	}
+	if installCNI {
+		requirements = append(requirements, componentRequirement{
+			Name:      "istio-cni-node",
+			Namespace: namespace,
+			DaemonSet: true,
+			CPU:       resource.MustParse("100m"),
+			Memory:    resource.MustParse("100Mi"),
+		})
+	}
+	if installZtunnel {
+		requirements = append(requirements, componentRequirement{
+			Name:      "ztunnel",
+			Namespace: namespace,
+			DaemonSet: true,
+			CPU:       resource.MustParse("200m"),
+			Memory:    resource.MustParse("512Mi"),
+		})
+	}
+	return requirements
+}
+
+// runInstallPreflight checks node capacity, ResourceQuotas and LimitRanges against the requirements
+func (m *Manager) runInstallPreflight(ctx context.Context, requirements []componentRequirement) (*PreflightReport, error) {
+	report := &PreflightReport{Passed: true}
+
+	nodes, err := m.getNodeCapacities(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	add := func(check PreflightCheck) {
+		if !check.Passed {
+			report.Passed = false
+		}
+		report.Checks = append(report.Checks, check)
+	}
+
+	// Node capacity: place each replica on the node with the most free CPU
+	for _, req := range requirements {
+		add(checkNodeCapacity(req, nodes))
+	}
+
+	// Namespace quotas and limit ranges
+	namespaces := make(map[string][]componentRequirement)
+	for _, req := range requirements {
+		namespaces[req.Namespace] = append(namespaces[req.Namespace], req)
+	}
+	schedulable := 0
+	for _, node := range nodes {
+		if node.Schedules {
+			schedulable++
+		}
+	}
+	for namespace, reqs := range namespaces {
+		quotaChecks, err := m.checkResourceQuotas(ctx, namespace, reqs, len(nodes))
+		if err != nil {
+			return nil, err
+		}
+		for _, check := range quotaChecks {
+			add(check)
+		}
+
+		limitChecks, err := m.checkLimitRanges(ctx, namespace, reqs)
+		if err != nil {
+			return nil, err
+		}
+		for _, check := range limitChecks {
+			add(check)
+		}
+	}
+	if schedulable == 0 {
+		add(PreflightCheck{
+			Name:    "schedulable-nodes",
+			Passed:  false,
+			Message: "No nodes accept pods without tolerations; istiod and gateways would stay Pending",
+		})
+	}
+
+	return report, nil
+}
+
+// getNodeCapacities returns the allocatable resources of each node minus the requests of its pods
+func (m *Manager) getNodeCapacities(ctx context.Context) ([]*nodeCapacity, error) {
+	nodeList, err := m.k8sClient.Kubernetes.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list nodes: %w", err)
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{
+		FieldSelector: "status.phase!=Succeeded,status.phase!=Failed",
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list pods: %w", err)
+	}
+
+	var nodes []*nodeCapacity
+	byName := make(map[string]*nodeCapacity)
+	for _, node := range nodeList.Items {
+		if node.Spec.Unschedulable {
+			continue
+		}
+		capacity := &nodeCapacity{
+			Name:      node.Name,
+			CPU:       node.Status.Allocatable.Cpu().DeepCopy(),
+			Memory:    node.Status.Allocatable.Memory().DeepCopy(),
+			Schedules: true,
+		}
+		for _, taint := range node.Spec.Taints {
+			if taint.Effect == corev1.TaintEffectNoSchedule || taint.Effect == corev1.TaintEffectNoExecute {
+				capacity.Schedules = false
+			}
+		}
+		nodes = append(nodes, capacity)
+		byName[node.Name] = capacity
+	}
+
+	for _, pod := range pods.Items {
+		node, ok := byName[pod.Spec.NodeName]
+		if !ok {
+			continue
+		}
+		for _, container := range pod.Spec.Containers {
+			node.CPU.Sub(*container.Resources.Requests.Cpu())
+			node.Memory.Sub(*container.Resources.Requests.Memory())
+		}
+	}
+
+	return nodes, nil
+}
+
+// checkNodeCapacity verifies the component's pods fit on the nodes, reserving what they use
+func checkNodeCapacity(req componentRequirement, nodes []*nodeCapacity) PreflightCheck {
+	check := PreflightCheck{Name: fmt.Sprintf("node-capacity/%s", req.Name), Passed: true}
+
+	if req.DaemonSet {
+		// The CNI agent tolerates all taints and needs room on every node
+		var short []string
+		for _, node := range nodes {
+			if node.CPU.Cmp(req.CPU) < 0 || node.Memory.Cmp(req.Memory) < 0 {
+				short = append(short, node.Name)
+				continue
+			}
+			node.CPU.Sub(req.CPU)
+			node.Memory.Sub(req.Memory)
+		}
+		if len(short) > 0 {
+			check.Passed = false
+			check.Message = fmt.Sprintf("%s needs %s CPU and %s memory on every node; insufficient free resources on: %s",
+				req.Name, req.CPU.String(), req.Memory.String(), strings.Join(short, ", "))
+			return check
+		}
+		check.Message = fmt.Sprintf("All %d nodes can run %s (%s CPU, %s memory each)", len(nodes), req.Name, req.CPU.String(), req.Memory.String())
+		return check
+	}
+
+	for replica := 0; replica < req.Replicas; replica++ {
+		candidates := make([]*nodeCapacity, 0, len(nodes))
+		for _, node := range nodes {
+			if node.Schedules && node.CPU.Cmp(req.CPU) >= 0 && node.Memory.Cmp(req.Memory) >= 0 {
+				candidates = append(candidates, node)
+			}
+		}
+		if len(candidates) == 0 {
+			check.Passed = false
+			check.Message = fmt.Sprintf("No node has %s CPU and %s memory free for %s replica %d of %d; largest free: %s",
+				req.CPU.String(), req.Memory.String(), req.Name, replica+1, req.Replicas, describeLargestNode(nodes))
+			return check
+		}
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].CPU.Cmp(candidates[j].CPU) > 0
+		})
+		candidates[0].CPU.Sub(req.CPU)
+		candidates[0].Memory.Sub(req.Memory)
+	}
+
+	check.Message = fmt.Sprintf("%d replica(s) of %s fit (%s CPU, %s memory each)", req.Replicas, req.Name, req.CPU.String(), req.Memory.String())
+	return check
+}
+
+// describeLargestNode reports the node with the most free CPU for error messages
+func describeLargestNode(nodes []*nodeCapacity) string {
+	var largest *nodeCapacity
+	for _, node := range nodes {
+		if node.Schedules && (largest == nil || node.CPU.Cmp(largest.CPU) > 0) {
+			largest = node
+		}
+	}
+	if largest == nil {
+		return "no schedulable nodes"
+	}
+	return fmt.Sprintf("%s with %s CPU and %s memory", largest.Name, largest.CPU.String(), largest.Memory.String())
+}
+
+// checkResourceQuotas verifies the namespace quotas leave room for the components
+func (m *Manager) checkResourceQuotas(ctx context.Context, namespace string, reqs []componentRequirement, nodeCount int) ([]PreflightCheck, error) {
+	quotas, err := m.k8sClient.Kubernetes.CoreV1().ResourceQuotas(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list resource quotas in %s: %w", namespace, err)
+	}
+
+	// Total the requests of every pod the components will create
+	needed := corev1.ResourceList{}
+	pods := 0
+	for _, req := range reqs {
+		count := req.Replicas
+		if req.DaemonSet {
+			count = nodeCount
+		}
+		pods += count
+		for i := 0; i < count; i++ {
+			addQuantity(needed, corev1.ResourceRequestsCPU, req.CPU)
+			addQuantity(needed, corev1.ResourceRequestsMemory, req.Memory)
+			addQuantity(needed, corev1.ResourceCPU, req.CPU)
+			addQuantity(needed, corev1.ResourceMemory, req.Memory)
+		}
+	}
+	needed[corev1.ResourcePods] = *resource.NewQuantity(int64(pods), resource.DecimalSI)
+
+	var checks []PreflightCheck
+	for _, quota := range quotas.Items {
+		check := PreflightCheck{Name: fmt.Sprintf("resource-quota/%s/%s", namespace, quota.Name), Passed: true}
+		var problems []string
+		for name, hard := range quota.Status.Hard {
+			want, ok := needed[name]
+			if !ok {
+				// Limits must be set on every pod once a quota tracks them
+				if strings.HasPrefix(string(name), "limits.") {
+					problems = append(problems, fmt.Sprintf("quota tracks %s, so every pod needs limits (set them in values or add a LimitRange default)", name))
+				}
+				continue
+			}
+			used := quota.Status.Used[name]
+			total := used.DeepCopy()
+			total.Add(want)
+			if total.Cmp(hard) > 0 {
+				problems = append(problems, fmt.Sprintf("%s: needs %s but only %s of %s remains", name, want.String(), remaining(hard, used), hard.String()))
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			check.Passed = false
+			check.Message = strings.Join(problems, "; ")
+		} else {
+			check.Message = "Quota has room for the install"
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+// checkLimitRanges verifies the component requests are allowed by the namespace LimitRanges
+func (m *Manager) checkLimitRanges(ctx context.Context, namespace string, reqs []componentRequirement) ([]PreflightCheck, error) {
+	limitRanges, err := m.k8sClient.Kubernetes.CoreV1().LimitRanges(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list limit ranges in %s: %w", namespace, err)
+	}
+
+	var checks []PreflightCheck
+	for _, limitRange := range limitRanges.Items {
+		check := PreflightCheck{Name: fmt.Sprintf("limit-range/%s/%s", namespace, limitRange.Name), Passed: true}
+		var problems []string
+		for _, item := range limitRange.Spec.Limits {
+			if item.Type != corev1.LimitTypeContainer {
+				continue
+			}
+			for _, req := range reqs {
+				for name, value := range map[corev1.ResourceName]resource.Quantity{corev1.ResourceCPU: req.CPU, corev1.ResourceMemory: req.Memory} {
+					if max, ok := item.Max[name]; ok && value.Cmp(max) > 0 {
+						problems = append(problems, fmt.Sprintf("%s requests %s %s above the max of %s", req.Name, value.String(), name, max.String()))
+					}
+					if min, ok := item.Min[name]; ok && value.Cmp(min) < 0 {
+						problems = append(problems, fmt.Sprintf("%s requests %s %s below the min of %s", req.Name, value.String(), name, min.String()))
+					}
+					// A default limit below the request makes the pod invalid
+					if limit, ok := item.Default[name]; ok && value.Cmp(limit) > 0 {
+						problems = append(problems, fmt.Sprintf("%s requests %s %s above the default limit of %s", req.Name, value.String(), name, limit.String()))
+					}
+				}
+			}
+		}
+		if len(problems) > 0 {
+			sort.Strings(problems)
+			check.Passed = false
+			check.Message = strings.Join(problems, "; ")
+		} else {
+			check.Message = "Component requests are within the limit range"
+		}
+		checks = append(checks, check)
+	}
+
+	return checks, nil
+}
+
+// addQuantity adds a quantity to a resource list entry
+func addQuantity(list corev1.ResourceList, name corev1.ResourceName, value resource.Quantity) {
+	current := list[name]
+	current.Add(value)
+	list[name] = current
+}
+
+// remaining formats the unused portion of a quota
+func remaining(hard, used resource.Quantity) string {
+	left := hard.DeepCopy()
+	left.Sub(used)
+	return left.String()
+}
+
+// formatPreflightFailures renders the failed checks of a report for error messages
+func formatPreflightFailures(report *PreflightReport) string {
+	var failures []string
+	for _, check := range report.Checks {
+		if !check.Passed {
+			failures = append(failures, fmt.Sprintf("- %s: %s", check.Name, check.Message))
+		}
+	}
+	return strings.Join(failures, "\n")
+}
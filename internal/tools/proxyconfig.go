@@ -0,0 +1,642 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ProxyCluster is one Envoy cluster, with the Istio naming direction|port|subset|fqdn split out
+type ProxyCluster struct {
+	Name            string                 `json:"name"`
+	FQDN            string                 `json:"fqdn,omitempty"`
+	Port            int                    `json:"port,omitempty"`
+	Subset          string                 `json:"subset,omitempty"`
+	Direction       string                 `json:"direction,omitempty"`
+	Type            string                 `json:"type"`
+	DestinationRule string                 `json:"destination_rule,omitempty"`
+	Raw             map[string]interface{} `json:"raw,omitempty"`
+}
+
+// ProxyFilterChain is where one filter chain of a listener sends the traffic it matches
+type ProxyFilterChain struct {
+	Match       string `json:"match"`
+	Destination string `json:"destination"`
+}
+
+// ProxyListener is one Envoy listener and its filter chains
+type ProxyListener struct {
+	Name    string                 `json:"name"`
+	Address string                 `json:"address"`
+	Port    int                    `json:"port"`
+	Chains  []ProxyFilterChain     `json:"chains"`
+	Raw     map[string]interface{} `json:"raw,omitempty"`
+}
+
+// ProxyRoute is one route of an Envoy route configuration
+type ProxyRoute struct {
+	RouteConfig    string                 `json:"route_config"`
+	VirtualHost    string                 `json:"virtual_host"`
+	Domains        []string               `json:"domains"`
+	Match          string                 `json:"match"`
+	Destination    string                 `json:"destination"`
+	VirtualService string                 `json:"virtual_service,omitempty"`
+	Raw            map[string]interface{} `json:"raw,omitempty"`
+}
+
+// ProxyEndpoint is one upstream host Envoy load balances to
+type ProxyEndpoint struct {
+	Endpoint     string `json:"endpoint"`
+	Status       string `json:"status"`
+	OutlierCheck string `json:"outlier_check"`
+	Cluster      string `json:"cluster"`
+}
+
+// ProxyConfig is the Envoy configuration of a proxy, filtered like istioctl proxy-config
+type ProxyConfig struct {
+	Pod       string          `json:"pod"`
+	Type      string          `json:"type"`
+	Summary   map[string]int  `json:"summary"`
+	Clusters  []ProxyCluster  `json:"clusters,omitempty"`
+	Listeners []ProxyListener `json:"listeners,omitempty"`
+	Routes    []ProxyRoute    `json:"routes,omitempty"`
+	Endpoints []ProxyEndpoint `json:"endpoints,omitempty"`
+	Notes     []string        `json:"notes,omitempty"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// getProxyConfigParams are the arguments of get_proxy_config
+type getProxyConfigParams struct {
+	PodName   string `json:"pod_name"`
+	Namespace string `json:"namespace,omitempty"` // default: default
+	Type      string `json:"type,omitempty"`      // clusters, listeners, routes, endpoints or all (default: clusters)
+	FQDN      string `json:"fqdn,omitempty"`      // only entries for hosts containing this
+	Port      int    `json:"port,omitempty"`      // only entries for this port
+	Direction string `json:"direction,omitempty"` // clusters only: inbound or outbound
+	Subset    string `json:"subset,omitempty"`    // clusters only
+	Raw       bool   `json:"raw,omitempty"`       // include the full Envoy objects of the matches
+}
+
+// GetProxyConfig reads the Envoy config dump of a pod's istio-proxy and returns its clusters, listeners,
+// routes or endpoints, filtered by FQDN, port, direction and subset
+func (m *Manager) GetProxyConfig(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getProxyConfigParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.PodName == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "pod_name is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Type == "" {
+		params.Type = "clusters"
+	}
+	validTypes := []string{"clusters", "listeners", "routes", "endpoints", "all"}
+	if !containsString(validTypes, params.Type) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid type %q: must be one of %s", params.Type, strings.Join(validTypes, ", ")),
+				},
+			},
+		}, nil
+	}
+
+	pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get pod: %v", err),
+				},
+			},
+		}, nil
+	}
+	if !podHasSidecar(pod) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Pod %s/%s has no istio-proxy container; for ambient workloads use get_ztunnel_config", params.Namespace, params.PodName),
+				},
+			},
+		}, nil
+	}
+
+	result := &ProxyConfig{
+		Pod:       fmt.Sprintf("%s/%s", pod.Namespace, pod.Name),
+		Type:      params.Type,
+		Summary:   make(map[string]int),
+		Timestamp: time.Now(),
+	}
+	all := params.Type == "all"
+
+	if all || params.Type != "endpoints" {
+		// EDS is left out of the dump; endpoints come from /clusters, which also carries health
+		body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/config_dump")
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to read the Envoy config dump: %v", err),
+					},
+				},
+			}, nil
+		}
+		var dump struct {
+			Configs []map[string]interface{} `json:"configs"`
+		}
+		if err := json.Unmarshal(body, &dump); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to parse the Envoy config dump: %v", err),
+					},
+				},
+			}, nil
+		}
+
+		for _, section := range dump.Configs {
+			kind, _ := section["@type"].(string)
+			switch {
+			case strings.HasSuffix(kind, ".ClustersConfigDump") && (all || params.Type == "clusters"):
+				for _, key := range []string{"static_clusters", "dynamic_active_clusters"} {
+					for _, entry := range envoyDumpEntries(section[key], "cluster") {
+						cluster := newProxyCluster(entry)
+						if !proxyClusterMatches(cluster, params.FQDN, params.Port, params.Direction, params.Subset) {
+							continue
+						}
+						if params.Raw {
+							cluster.Raw = entry
+						}
+						result.Clusters = append(result.Clusters, cluster)
+					}
+				}
+			case strings.HasSuffix(kind, ".ListenersConfigDump") && (all || params.Type == "listeners"):
+				for _, key := range []string{"static_listeners", "dynamic_listeners"} {
+					for _, entry := range envoyDumpEntries(section[key], "listener") {
+						listener := newProxyListener(entry)
+						if params.Port != 0 && listener.Port != params.Port {
+							continue
+						}
+						if params.FQDN != "" && !proxyListenerMentions(listener, params.FQDN) {
+							continue
+						}
+						if params.Raw {
+							listener.Raw = entry
+						}
+						result.Listeners = append(result.Listeners, listener)
+					}
+				}
+			case strings.HasSuffix(kind, ".RoutesConfigDump") && (all || params.Type == "routes"):
+				for _, key := range []string{"static_route_configs", "dynamic_route_configs"} {
+					for _, entry := range envoyDumpEntries(section[key], "route_config") {
+						result.Routes = append(result.Routes, proxyRoutes(entry, params.FQDN, params.Port, params.Raw)...)
+					}
+				}
+			}
+		}
+	}
+
+	if all || params.Type == "endpoints" {
+		body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/clusters?format=json")
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to read endpoints: %v", err))
+		} else {
+			endpoints, err := parseProxyEndpoints(body, params.FQDN, params.Port)
+			if err != nil {
+				result.Notes = append(result.Notes, err.Error())
+			}
+			result.Endpoints = endpoints
+		}
+	}
+
+	sort.Slice(result.Clusters, func(i, j int) bool { return result.Clusters[i].Name < result.Clusters[j].Name })
+	sort.Slice(result.Listeners, func(i, j int) bool {
+		if result.Listeners[i].Port != result.Listeners[j].Port {
+			return result.Listeners[i].Port < result.Listeners[j].Port
+		}
+		return result.Listeners[i].Address < result.Listeners[j].Address
+	})
+	sort.Slice(result.Endpoints, func(i, j int) bool {
+		if result.Endpoints[i].Cluster != result.Endpoints[j].Cluster {
+			return result.Endpoints[i].Cluster < result.Endpoints[j].Cluster
+		}
+		return result.Endpoints[i].Endpoint < result.Endpoints[j].Endpoint
+	})
+
+	result.Summary["clusters"] = len(result.Clusters)
+	result.Summary["listeners"] = len(result.Listeners)
+	result.Summary["routes"] = len(result.Routes)
+	result.Summary["endpoints"] = len(result.Endpoints)
+	for _, endpoint := range result.Endpoints {
+		if endpoint.Status != "HEALTHY" {
+			result.Summary["unhealthy_endpoints"]++
+		}
+	}
+	if params.FQDN != "" || params.Port != 0 {
+		if len(result.Clusters)+len(result.Listeners)+len(result.Routes)+len(result.Endpoints) == 0 {
+			result.Notes = append(result.Notes, "Nothing matches the filters; the host may be missing from the proxy's config because of exportTo, a Sidecar resource or discoverySelectors")
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// envoyDumpEntries unwraps the entries of a config dump section, e.g. the "cluster" of each dynamic
+// cluster or the active listener of each dynamic listener
+func envoyDumpEntries(section interface{}, key string) []map[string]interface{} {
+	items, _ := section.([]interface{})
+	entries := make([]map[string]interface{}, 0, len(items))
+	for _, item := range items {
+		wrapper, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if state, ok := wrapper["active_state"].(map[string]interface{}); ok {
+			wrapper = state
+		}
+		if entry, ok := wrapper[key].(map[string]interface{}); ok {
+			entries = append(entries, entry)
+		}
+	}
+	return entries
+}
+
+// newProxyCluster summarizes an Envoy cluster
+func newProxyCluster(cluster map[string]interface{}) ProxyCluster {
+	result := ProxyCluster{}
+	result.Name, _ = cluster["name"].(string)
+	result.Type, _ = cluster["type"].(string)
+	if result.Type == "" {
+		if _, ok := cluster["eds_cluster_config"]; ok {
+			result.Type = "EDS"
+		}
+	}
+
+	// Istio names its clusters direction|port|subset|fqdn
+	if parts := strings.Split(result.Name, "|"); len(parts) == 4 {
+		result.Direction = parts[0]
+		result.Port, _ = strconv.Atoi(parts[1])
+		result.Subset = parts[2]
+		result.FQDN = parts[3]
+	}
+	result.DestinationRule = istioConfigSource(cluster, "destination-rule")
+	return result
+}
+
+// istioConfigSource returns name.namespace of the Istio resource that produced an Envoy object, taken
+// from the metadata Istio stamps on clusters and routes
+func istioConfigSource(object map[string]interface{}, kind string) string {
+	metadata, _ := object["metadata"].(map[string]interface{})
+	filterMetadata, _ := metadata["filter_metadata"].(map[string]interface{})
+	istio, _ := filterMetadata["istio"].(map[string]interface{})
+	var sources []string
+	if config, ok := istio["config"].(string); ok {
+		sources = append(sources, config)
+	}
+	if configs, ok := istio["services"].([]interface{}); ok {
+		for _, service := range configs {
+			if config, ok := service.(string); ok {
+				sources = append(sources, config)
+			}
+		}
+	}
+	// e.g. /apis/networking.istio.io/v1alpha3/namespaces/default/destination-rule/reviews
+	for _, source := range sources {
+		parts := strings.Split(source, "/")
+		if len(parts) == 8 && parts[6] == kind {
+			return parts[7] + "." + parts[5]
+		}
+	}
+	return ""
+}
+
+// proxyClusterMatches applies the cluster filters
+func proxyClusterMatches(cluster ProxyCluster, fqdn string, port int, direction, subset string) bool {
+	if fqdn != "" && !strings.Contains(cluster.FQDN, fqdn) && !strings.Contains(cluster.Name, fqdn) {
+		return false
+	}
+	if port != 0 && cluster.Port != port {
+		return false
+	}
+	if direction != "" && cluster.Direction != direction {
+		return false
+	}
+	if subset != "" && cluster.Subset != subset {
+		return false
+	}
+	return true
+}
+
+// newProxyListener summarizes an Envoy listener and where each filter chain sends traffic
+func newProxyListener(listener map[string]interface{}) ProxyListener {
+	result := ProxyListener{Chains: []ProxyFilterChain{}}
+	result.Name, _ = listener["name"].(string)
+	if address, ok := listener["address"].(map[string]interface{}); ok {
+		if socket, ok := address["socket_address"].(map[string]interface{}); ok {
+			result.Address, _ = socket["address"].(string)
+			if port, ok := socket["port_value"].(float64); ok {
+				result.Port = int(port)
+			}
+		}
+	}
+
+	chains, _ := listener["filter_chains"].([]interface{})
+	if defaultChain, ok := listener["default_filter_chain"]; ok {
+		chains = append(chains, defaultChain)
+	}
+	for _, item := range chains {
+		chain, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		entry := ProxyFilterChain{Match: "ALL", Destination: "-"}
+		if match, ok := chain["filter_chain_match"].(map[string]interface{}); ok {
+			entry.Match = filterChainMatchString(match)
+		}
+		filters, _ := chain["filters"].([]interface{})
+		for _, f := range filters {
+			filter, _ := f.(map[string]interface{})
+			config, _ := filter["typed_config"].(map[string]interface{})
+			if destination := filterDestination(config); destination != "" {
+				entry.Destination = destination
+			}
+		}
+		result.Chains = append(result.Chains, entry)
+	}
+	return result
+}
+
+// filterChainMatchString renders a filter chain match the way istioctl does
+func filterChainMatchString(match map[string]interface{}) string {
+	var parts []string
+	if port, ok := match["destination_port"].(float64); ok {
+		parts = append(parts, fmt.Sprintf("port %d", int(port)))
+	}
+	for _, key := range []string{"server_names", "application_protocols", "prefix_ranges"} {
+		values, _ := match[key].([]interface{})
+		var rendered []string
+		for _, value := range values {
+			switch v := value.(type) {
+			case string:
+				rendered = append(rendered, v)
+			case map[string]interface{}:
+				rendered = append(rendered, fmt.Sprintf("%v/%v", v["address_prefix"], v["prefix_len"]))
+			}
+		}
+		if len(rendered) > 0 {
+			parts = append(parts, key+" "+strings.Join(rendered, ","))
+		}
+	}
+	if protocol, ok := match["transport_protocol"].(string); ok {
+		parts = append(parts, "transport "+protocol)
+	}
+	if len(parts) == 0 {
+		return "ALL"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// filterDestination names where a network filter sends traffic: an RDS route, an inline route or a cluster
+func filterDestination(config map[string]interface{}) string {
+	if rds, ok := config["rds"].(map[string]interface{}); ok {
+		if name, ok := rds["route_config_name"].(string); ok {
+			return "Route: " + name
+		}
+	}
+	if route, ok := config["route_config"].(map[string]interface{}); ok {
+		name, _ := route["name"].(string)
+		return "Inline Route: " + name
+	}
+	if cluster, ok := config["cluster"].(string); ok {
+		return "Cluster: " + cluster
+	}
+	if weighted, ok := config["weighted_clusters"].(map[string]interface{}); ok {
+		clusters, _ := weighted["clusters"].([]interface{})
+		var names []string
+		for _, c := range clusters {
+			if cluster, ok := c.(map[string]interface{}); ok {
+				names = append(names, fmt.Sprintf("%v (%v)", cluster["name"], cluster["weight"]))
+			}
+		}
+		return "Clusters: " + strings.Join(names, ", ")
+	}
+	return ""
+}
+
+// proxyListenerMentions reports whether any filter chain of a listener matches or targets a host
+func proxyListenerMentions(listener ProxyListener, fqdn string) bool {
+	for _, chain := range listener.Chains {
+		if strings.Contains(chain.Match, fqdn) || strings.Contains(chain.Destination, fqdn) {
+			return true
+		}
+	}
+	return false
+}
+
+// proxyRoutes flattens a route configuration into one entry per route, keeping the virtual hosts
+// whose domains match the filters; outbound route configs are named after their port
+func proxyRoutes(config map[string]interface{}, fqdn string, port int, raw bool) []ProxyRoute {
+	name, _ := config["name"].(string)
+	if port != 0 && name != strconv.Itoa(port) && !strings.Contains(name, "."+strconv.Itoa(port)) && !strings.HasSuffix(name, "|"+strconv.Itoa(port)) {
+		return nil
+	}
+	var routes []ProxyRoute
+	virtualHosts, _ := config["virtual_hosts"].([]interface{})
+	for _, item := range virtualHosts {
+		vh, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		vhName, _ := vh["name"].(string)
+		var domains []string
+		if list, ok := vh["domains"].([]interface{}); ok {
+			for _, domain := range list {
+				if d, ok := domain.(string); ok {
+					domains = append(domains, d)
+				}
+			}
+		}
+		if fqdn != "" && !strings.Contains(vhName, fqdn) && !containsSubstring(domains, fqdn) {
+			continue
+		}
+		routeList, _ := vh["routes"].([]interface{})
+		for _, r := range routeList {
+			route, ok := r.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			entry := ProxyRoute{
+				RouteConfig:    name,
+				VirtualHost:    vhName,
+				Domains:        domains,
+				Match:          envoyRouteMatchString(route["match"]),
+				Destination:    envoyRouteDestination(route),
+				VirtualService: istioConfigSource(route, "virtual-service"),
+			}
+			if raw {
+				entry.Raw = route
+			}
+			routes = append(routes, entry)
+		}
+	}
+	return routes
+}
+
+// containsSubstring reports whether any value contains the substring
+func containsSubstring(values []string, substring string) bool {
+	for _, value := range values {
+		if strings.Contains(value, substring) {
+			return true
+		}
+	}
+	return false
+}
+
+// envoyRouteMatchString renders a route match as path, prefix or regex plus the header names it requires
+func envoyRouteMatchString(value interface{}) string {
+	match, _ := value.(map[string]interface{})
+	var result string
+	switch {
+	case match["path"] != nil:
+		result = fmt.Sprintf("path %v", match["path"])
+	case match["prefix"] != nil:
+		result = fmt.Sprintf("prefix %v", match["prefix"])
+	case match["path_separated_prefix"] != nil:
+		result = fmt.Sprintf("path_separated_prefix %v", match["path_separated_prefix"])
+	case match["safe_regex"] != nil:
+		regex, _ := match["safe_regex"].(map[string]interface{})
+		result = fmt.Sprintf("regex %v", regex["regex"])
+	default:
+		result = "*"
+	}
+	if headers, ok := match["headers"].([]interface{}); ok && len(headers) > 0 {
+		var names []string
+		for _, h := range headers {
+			if header, ok := h.(map[string]interface{}); ok {
+				names = append(names, fmt.Sprintf("%v", header["name"]))
+			}
+		}
+		result += " headers " + strings.Join(names, ",")
+	}
+	return result
+}
+
+// envoyRouteDestination names what a route does: forward to clusters, redirect or answer directly
+func envoyRouteDestination(route map[string]interface{}) string {
+	if action, ok := route["route"].(map[string]interface{}); ok {
+		if destination := filterDestination(action); destination != "" {
+			return destination
+		}
+	}
+	if _, ok := route["redirect"]; ok {
+		return "redirect"
+	}
+	if direct, ok := route["direct_response"].(map[string]interface{}); ok {
+		return fmt.Sprintf("direct response %v", direct["status"])
+	}
+	return "-"
+}
+
+// parseProxyEndpoints reads the upstream hosts and their health from the /clusters admin endpoint
+func parseProxyEndpoints(body []byte, fqdn string, port int) ([]ProxyEndpoint, error) {
+	var clusters struct {
+		ClusterStatuses []struct {
+			Name         string `json:"name"`
+			HostStatuses []struct {
+				Address struct {
+					SocketAddress struct {
+						Address   string `json:"address"`
+						PortValue int    `json:"port_value"`
+					} `json:"socket_address"`
+					Pipe struct {
+						Path string `json:"path"`
+					} `json:"pipe"`
+				} `json:"address"`
+				HealthStatus struct {
+					EDSHealthStatus    string `json:"eds_health_status"`
+					FailedOutlierCheck bool   `json:"failed_outlier_check"`
+				} `json:"health_status"`
+			} `json:"host_statuses"`
+		} `json:"cluster_statuses"`
+	}
+	if err := json.Unmarshal(body, &clusters); err != nil {
+		return nil, fmt.Errorf("failed to parse endpoints: %w", err)
+	}
+
+	endpoints := []ProxyEndpoint{}
+	for _, cluster := range clusters.ClusterStatuses {
+		if fqdn != "" && !strings.Contains(cluster.Name, fqdn) {
+			continue
+		}
+		for _, host := range cluster.HostStatuses {
+			socket := host.Address.SocketAddress
+			if port != 0 && socket.PortValue != port {
+				continue
+			}
+			endpoint := ProxyEndpoint{
+				Endpoint:     fmt.Sprintf("%s:%d", socket.Address, socket.PortValue),
+				Status:       host.HealthStatus.EDSHealthStatus,
+				OutlierCheck: "OK",
+				Cluster:      cluster.Name,
+			}
+			if host.Address.Pipe.Path != "" {
+				endpoint.Endpoint = "unix://" + host.Address.Pipe.Path
+			}
+			if endpoint.Status == "" {
+				endpoint.Status = "HEALTHY"
+			}
+			if host.HealthStatus.FailedOutlierCheck {
+				endpoint.OutlierCheck = "FAILED"
+			}
+			endpoints = append(endpoints, endpoint)
+		}
+	}
+	return endpoints, nil
+}
@@ -0,0 +1,286 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// xdsRejectMetrics maps the push status metrics istiod records for rejected (NACKed) configs to the xDS type
+var xdsRejectMetrics = map[string]string{
+	"pilot_xds_cds_reject":  "CDS",
+	"pilot_xds_lds_reject":  "LDS",
+	"pilot_xds_eds_reject":  "EDS",
+	"pilot_xds_rds_reject":  "RDS",
+	"pilot_xds_ecds_reject": "ECDS",
+}
+
+// ProxySyncStatus is the xDS state of one proxy as seen by the istiod it is connected to
+type ProxySyncStatus struct {
+	Proxy        string   `json:"proxy"` // pod.namespace
+	Type         string   `json:"type,omitempty"`
+	Istiod       string   `json:"istiod"`
+	IstioVersion string   `json:"istio_version,omitempty"`
+	CDS          string   `json:"cds"`
+	LDS          string   `json:"lds"`
+	EDS          string   `json:"eds"`
+	RDS          string   `json:"rds"`
+	ECDS         string   `json:"ecds"`
+	Rejections   []string `json:"rejections,omitempty"` // NACK messages from the proxy
+}
+
+// ProxyStatusReport is the mesh-wide view of istioctl proxy-status
+type ProxyStatusReport struct {
+	IstioNamespace string            `json:"istio_namespace"`
+	Namespace      string            `json:"namespace,omitempty"`
+	Istiods        []string          `json:"istiods"`
+	Summary        map[string]int    `json:"summary"`
+	Proxies        []ProxySyncStatus `json:"proxies"`
+	Disconnected   []string          `json:"disconnected,omitempty"` // sidecar pods no istiod knows about
+	Issues         []string          `json:"issues,omitempty"`
+	Timestamp      time.Time         `json:"timestamp"`
+}
+
+// proxyStatusParams are the arguments of proxy_status
+type proxyStatusParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // only proxies in this namespace
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+	OnlyProblems   bool   `json:"only_problems,omitempty"`   // leave out fully synced proxies
+}
+
+// ProxyStatus queries the debug endpoints of every istiod for the xDS sync state of the connected proxies,
+// flagging configs that are stale, never sent or rejected, and sidecars connected to no istiod
+func (m *Manager) ProxyStatus(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params proxyStatusParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	istiods, err := m.runningPods(ctx, params.IstioNamespace, "app=istiod")
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istiod pods: %v", err),
+				},
+			},
+		}, nil
+	}
+	if len(istiods) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No running istiod pods in namespace %s", params.IstioNamespace),
+				},
+			},
+		}, nil
+	}
+
+	report := &ProxyStatusReport{
+		IstioNamespace: params.IstioNamespace,
+		Namespace:      params.Namespace,
+		Istiods:        []string{},
+		Summary:        make(map[string]int),
+		Proxies:        []ProxySyncStatus{},
+		Timestamp:      time.Now(),
+	}
+
+	// Each proxy holds one connection, so every istiod reports only its own proxies
+	connected := make(map[string]bool)
+	for i := range istiods {
+		istiod := &istiods[i]
+		report.Istiods = append(report.Istiods, istiod.Name)
+
+		statuses, err := m.istiodSyncStatus(ctx, istiod)
+		if err != nil {
+			report.Issues = append(report.Issues, err.Error())
+			continue
+		}
+		rejections, err := m.istiodRejections(ctx, istiod)
+		if err != nil {
+			report.Issues = append(report.Issues, err.Error())
+		}
+
+		for _, status := range statuses {
+			connected[status.Proxy] = true
+			if params.Namespace != "" && !strings.HasSuffix(status.Proxy, "."+params.Namespace) {
+				continue
+			}
+			status.Rejections = rejections[status.Proxy]
+			report.Summary["proxies"]++
+			healthy := len(status.Rejections) == 0
+			for _, state := range []string{status.CDS, status.LDS, status.EDS, status.RDS, status.ECDS} {
+				if strings.HasPrefix(state, "STALE") {
+					healthy = false
+				}
+			}
+			if len(status.Rejections) > 0 {
+				report.Summary["rejected"]++
+			}
+			if healthy {
+				report.Summary["synced"]++
+				if params.OnlyProblems {
+					continue
+				}
+			} else {
+				report.Summary["stale_or_rejected"]++
+			}
+			report.Proxies = append(report.Proxies, status)
+		}
+	}
+
+	// Sidecars that no istiod lists never connected, or lost their connection
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		report.Issues = append(report.Issues, fmt.Sprintf("Failed to list pods: %v", err))
+	} else if len(report.Issues) == 0 {
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if !podHasSidecar(pod) || pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+				continue
+			}
+			if !connected[pod.Name+"."+pod.Namespace] {
+				report.Disconnected = append(report.Disconnected, pod.Namespace+"/"+pod.Name)
+			}
+		}
+	}
+	report.Summary["disconnected"] = len(report.Disconnected)
+
+	sort.Slice(report.Proxies, func(i, j int) bool { return report.Proxies[i].Proxy < report.Proxies[j].Proxy })
+	sort.Strings(report.Disconnected)
+	if report.Summary["rejected"] > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d proxies rejected their configuration; the messages usually point at an invalid EnvoyFilter or a config only newer proxies understand", report.Summary["rejected"]))
+	}
+	if len(report.Disconnected) > 0 {
+		report.Issues = append(report.Issues, fmt.Sprintf("%d running sidecars are not connected to any istiod in %s; check their istio-proxy logs and the discoveryAddress of their revision", len(report.Disconnected), params.IstioNamespace))
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// istiodSyncStatus reads /debug/syncz from an istiod and compares the nonce sent to each proxy per xDS
+// type with the nonce the proxy acknowledged
+func (m *Manager) istiodSyncStatus(ctx context.Context, istiod *corev1.Pod) ([]ProxySyncStatus, error) {
+	body, err := m.portForwardGet(ctx, istiod.Namespace, istiod.Name, istiodDebugPort, "/debug/syncz")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync status from %s: %w", istiod.Name, err)
+	}
+	var raw []struct {
+		Proxy                string `json:"proxy"`
+		ProxyType            string `json:"proxy_type"`
+		IstioVersion         string `json:"istio_version"`
+		ClusterSent          string `json:"cluster_sent"`
+		ClusterAcked         string `json:"cluster_acked"`
+		ListenerSent         string `json:"listener_sent"`
+		ListenerAcked        string `json:"listener_acked"`
+		EndpointSent         string `json:"endpoint_sent"`
+		EndpointAcked        string `json:"endpoint_acked"`
+		RouteSent            string `json:"route_sent"`
+		RouteAcked           string `json:"route_acked"`
+		ExtensionConfigSent  string `json:"extensionconfig_sent"`
+		ExtensionConfigAcked string `json:"extensionconfig_acked"`
+	}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse sync status from %s: %w", istiod.Name, err)
+	}
+	statuses := make([]ProxySyncStatus, 0, len(raw))
+	for _, entry := range raw {
+		statuses = append(statuses, ProxySyncStatus{
+			Proxy:        entry.Proxy,
+			Type:         entry.ProxyType,
+			Istiod:       istiod.Name,
+			IstioVersion: entry.IstioVersion,
+			CDS:          xdsSyncState(entry.ClusterSent, entry.ClusterAcked),
+			LDS:          xdsSyncState(entry.ListenerSent, entry.ListenerAcked),
+			EDS:          xdsSyncState(entry.EndpointSent, entry.EndpointAcked),
+			RDS:          xdsSyncState(entry.RouteSent, entry.RouteAcked),
+			ECDS:         xdsSyncState(entry.ExtensionConfigSent, entry.ExtensionConfigAcked),
+		})
+	}
+	return statuses, nil
+}
+
+// xdsSyncState classifies an xDS type the way istioctl proxy-status does
+func xdsSyncState(sent, acked string) string {
+	switch {
+	case sent == "":
+		return "NOT SENT"
+	case sent == acked:
+		return "SYNCED"
+	case acked == "":
+		return "STALE (Never Acknowledged)"
+	default:
+		return "STALE"
+	}
+}
+
+// istiodRejections reads the NACKs istiod recorded in its push status, keyed by proxy
+func (m *Manager) istiodRejections(ctx context.Context, istiod *corev1.Pod) (map[string][]string, error) {
+	body, err := m.portForwardGet(ctx, istiod.Namespace, istiod.Name, istiodDebugPort, "/debug/push_status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read push status from %s: %w", istiod.Name, err)
+	}
+	var status struct {
+		ProxyStatus map[string]map[string]struct {
+			Proxy   string `json:"proxy"`
+			Message string `json:"message"`
+		} `json:"ProxyStatus"`
+	}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return nil, fmt.Errorf("failed to parse push status from %s: %w", istiod.Name, err)
+	}
+	rejections := make(map[string][]string)
+	for metric, entries := range status.ProxyStatus {
+		xdsType, ok := xdsRejectMetrics[metric]
+		if !ok {
+			continue
+		}
+		for key, entry := range entries {
+			proxy := entry.Proxy
+			if proxy == "" {
+				proxy = key
+			}
+			// Push status keys proxies by their node ID, sidecar~10.0.0.1~pod.namespace~namespace.svc.cluster.local
+			if parts := strings.Split(proxy, "~"); len(parts) == 4 {
+				proxy = parts[2]
+			}
+			rejections[proxy] = append(rejections[proxy], xdsType+": "+entry.Message)
+		}
+	}
+	for proxy := range rejections {
+		sort.Strings(rejections[proxy])
+	}
+	return rejections, nil
+}
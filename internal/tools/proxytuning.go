@@ -0,0 +1,515 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+)
+
+// proxyResourceAnnotations are the sidecar annotations overriding the proxy's resources per workload
+var proxyResourceAnnotations = map[string]string{
+	"cpu_request":    "sidecar.istio.io/proxyCPU",
+	"cpu_limit":      "sidecar.istio.io/proxyCPULimit",
+	"memory_request": "sidecar.istio.io/proxyMemory",
+	"memory_limit":   "sidecar.istio.io/proxyMemoryLimit",
+}
+
+// ProxyTuning is a set of proxy concurrency, resource and stats settings
+type ProxyTuning struct {
+	Concurrency            *int     `json:"concurrency,omitempty"` // worker threads, 0 uses every core
+	CPURequest             string   `json:"cpu_request,omitempty"`
+	CPULimit               string   `json:"cpu_limit,omitempty"`
+	MemoryRequest          string   `json:"memory_request,omitempty"`
+	MemoryLimit            string   `json:"memory_limit,omitempty"`
+	StatsInclusionPrefixes []string `json:"stats_inclusion_prefixes,omitempty"`
+	StatsInclusionRegexps  []string `json:"stats_inclusion_regexps,omitempty"`
+}
+
+// ProxyLoadMeasurement is the sidecar CPU and latency measured under a fixed Fortio load
+type ProxyLoadMeasurement struct {
+	Load                 LoadResult `json:"load"`
+	ProxyMillicores      float64    `json:"proxy_millicores,omitempty"`
+	ProxyCPUPer1KRequest float64    `json:"proxy_cpu_ms_per_1k_req,omitempty"`
+}
+
+// ProxyTuningResult is the result of changing proxy tuning settings
+type ProxyTuningResult struct {
+	Scope            string                `json:"scope"` // mesh or deployments
+	Previous         ProxyTuning           `json:"previous"`
+	Requested        ProxyTuning           `json:"requested"`
+	DryRun           bool                  `json:"dry_run,omitempty"`
+	Deployments      []string              `json:"deployments,omitempty"`
+	Before           *ProxyLoadMeasurement `json:"before,omitempty"`
+	After            *ProxyLoadMeasurement `json:"after,omitempty"`
+	CPUChangePercent *float64              `json:"cpu_change_percent,omitempty"`
+	Issues           []string              `json:"issues,omitempty"`
+	Notes            []string              `json:"notes,omitempty"`
+	Timestamp        time.Time             `json:"timestamp"`
+}
+
+// tuneProxyParams are the arguments of tune_proxy
+type tuneProxyParams struct {
+	ProxyTuning
+	Namespace          string   `json:"namespace,omitempty"`           // namespace of the deployments, default: default
+	Deployments        []string `json:"deployments,omitempty"`         // default: mesh-wide
+	IstioNamespace     string   `json:"istio_namespace,omitempty"`     // default: istio-system
+	Release            string   `json:"release,omitempty"`             // istiod Helm release, default: istiod
+	Revision           string   `json:"revision,omitempty"`            // control plane revision
+	RepoURL            string   `json:"repo_url,omitempty"`            // chart repository override
+	Measure            *bool    `json:"measure,omitempty"`             // default: true
+	BenchmarkNamespace string   `json:"benchmark_namespace,omitempty"` // default: meshpilot-bench
+	QPS                int      `json:"qps,omitempty"`                 // default: 1000
+	Connections        int      `json:"connections,omitempty"`         // default: 16
+	Duration           string   `json:"duration,omitempty"`            // default: 30s
+	DryRun             bool     `json:"dry_run,omitempty"`             // only report the current settings
+	Timeout            string   `json:"timeout,omitempty"`             // default: 5m
+}
+
+// TuneProxy sets proxy concurrency, resources and stats inclusion mesh-wide or for selected deployments,
+// measuring sidecar CPU under the same Fortio load before and after the change
+func (m *Manager) TuneProxy(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params tuneProxyParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Measure == nil {
+		params.Measure = boolPtr(true)
+	}
+	if params.BenchmarkNamespace == "" {
+		params.BenchmarkNamespace = "meshpilot-bench"
+	}
+	if params.QPS == 0 {
+		params.QPS = 1000
+	}
+	if params.Connections == 0 {
+		params.Connections = 16
+	}
+	if params.Duration == "" {
+		params.Duration = "30s"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	requested := params.ProxyTuning
+	if err := validateProxyTuning(requested); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+	duration, err := time.ParseDuration(params.Duration)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %q: %v", params.Duration, err),
+				},
+			},
+		}, nil
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "tune_proxy")
+
+	result := &ProxyTuningResult{
+		Scope:     "mesh",
+		Requested: requested,
+		DryRun:    params.DryRun,
+		Timestamp: time.Now(),
+	}
+
+	var deployments []*appsv1.Deployment
+	for _, name := range params.Deployments {
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get deployment %s/%s: %v", params.Namespace, name, err),
+					},
+				},
+			}, nil
+		}
+		deployments = append(deployments, deployment)
+		result.Deployments = append(result.Deployments, params.Namespace+"/"+name)
+	}
+
+	if len(deployments) > 0 {
+		result.Scope = "deployments"
+		previous, err := proxyTuningFromAnnotations(deployments[0].Spec.Template.Annotations)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Deployment %s has an unparseable %s annotation: %v", deployments[0].Name, proxyConfigAnnotation, err))
+		}
+		result.Previous = previous
+		if len(deployments) > 1 {
+			result.Notes = append(result.Notes, fmt.Sprintf("previous shows the overrides of deployment %s", deployments[0].Name))
+		}
+	} else {
+		previous, err := m.meshProxyTuning(ctx, params.IstioNamespace, params.Release, params.Revision)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Could not read the current mesh-wide proxy settings: %v", err))
+		}
+		result.Previous = previous
+	}
+	if requested.CPULimit != "" && requested.Concurrency != nil && *requested.Concurrency == 0 {
+		result.Notes = append(result.Notes, "concurrency 0 starts a worker per node core regardless of the CPU limit, which wastes memory and causes throttling on large nodes")
+	}
+
+	if params.DryRun {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	// The benchmark runs against its own sidecar pair so every measurement sees the same load
+	var benchPods map[string]string
+	if *params.Measure {
+		benchPods, err = m.setupProxyBenchmark(ctx, params.BenchmarkNamespace)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Skipping the CPU measurement: %v", err))
+		} else {
+			defer func() {
+				if err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Delete(context.WithoutCancel(ctx), params.BenchmarkNamespace, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+					logrus.Warnf("Failed to delete benchmark namespace: %v", err)
+				}
+			}()
+			result.Before, err = m.measureProxyLoad(ctx, params.BenchmarkNamespace, benchPods, params.QPS, params.Connections, duration)
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Load test before the change failed: %v", err))
+			}
+		}
+	}
+
+	// Proxies read these settings at startup, so the affected pods are restarted
+	if len(deployments) == 0 {
+		if err := m.setIstiodValues(ctx, params.IstioNamespace, params.Release, params.RepoURL, proxyTuningValues(requested), params.Timeout); err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to update mesh-wide proxy settings: %v", err),
+					},
+				},
+			}, nil
+		}
+		result.Notes = append(result.Notes, "Running sidecars keep their previous settings until their pods are restarted")
+	} else {
+		for _, deployment := range deployments {
+			if err := m.applyProxyTuning(ctx, deployment, requested, timeout); err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Failed to update deployment %s/%s: %v", deployment.Namespace, deployment.Name, err))
+			}
+		}
+	}
+
+	if result.Before != nil {
+		// The benchmark pods pick up the same settings the workloads got
+		for _, name := range []string{"fortio-server-mesh", "fortio-client-mesh"} {
+			deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.BenchmarkNamespace).Get(ctx, name, metav1.GetOptions{})
+			if err == nil {
+				if len(deployments) == 0 {
+					err = m.restartDeployment(ctx, deployment, timeout)
+				} else {
+					err = m.applyProxyTuning(ctx, deployment, requested, timeout)
+				}
+			}
+			if err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Failed to roll benchmark deployment %s: %v", name, err))
+			}
+		}
+		benchPods, err = m.waitForBenchmarkPods(ctx, params.BenchmarkNamespace, 2, 3*time.Minute)
+		if err == nil {
+			result.After, err = m.measureProxyLoad(ctx, params.BenchmarkNamespace, benchPods, params.QPS, params.Connections, duration)
+		}
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Load test after the change failed: %v", err))
+		}
+	}
+
+	if result.Before != nil && result.After != nil {
+		if result.Before.ProxyMillicores > 0 && result.After.ProxyMillicores > 0 {
+			change := (result.After.ProxyMillicores - result.Before.ProxyMillicores) / result.Before.ProxyMillicores * 100
+			result.CPUChangePercent = &change
+		} else {
+			result.Issues = append(result.Issues, "Sidecar CPU usage could not be read from the istio-proxy cgroup")
+		}
+		if result.After.Load.ActualQPS < float64(params.QPS)*0.95 && result.Before.Load.ActualQPS >= float64(params.QPS)*0.95 {
+			result.Issues = append(result.Issues, "The requested QPS was reached before the change but not after; the new settings may be too tight for this load")
+		}
+		if result.After.Load.ErrorPercent > result.Before.Load.ErrorPercent {
+			result.Issues = append(result.Issues, fmt.Sprintf("Errors rose from %.2f%% to %.2f%% of requests after the change", result.Before.Load.ErrorPercent, result.After.Load.ErrorPercent))
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// validateProxyTuning checks that at least one setting is given and that the quantities parse
+func validateProxyTuning(tuning ProxyTuning) error {
+	quantities := map[string]string{
+		"cpu_request":    tuning.CPURequest,
+		"cpu_limit":      tuning.CPULimit,
+		"memory_request": tuning.MemoryRequest,
+		"memory_limit":   tuning.MemoryLimit,
+	}
+	set := tuning.Concurrency != nil || len(tuning.StatsInclusionPrefixes) > 0 || len(tuning.StatsInclusionRegexps) > 0
+	for name, value := range quantities {
+		if value == "" {
+			continue
+		}
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("Invalid %s %q: %v", name, value, err)
+		}
+		set = true
+	}
+	if !set {
+		return fmt.Errorf("Specify at least one of concurrency, cpu_request, cpu_limit, memory_request, memory_limit, stats_inclusion_prefixes or stats_inclusion_regexps")
+	}
+	if tuning.Concurrency != nil && *tuning.Concurrency < 0 {
+		return fmt.Errorf("concurrency must be 0 or more")
+	}
+	return nil
+}
+
+// proxyTuningValues maps proxy tuning settings to istiod chart values
+func proxyTuningValues(tuning ProxyTuning) map[string]interface{} {
+	values := make(map[string]interface{})
+	if tuning.Concurrency != nil {
+		values["meshConfig.defaultConfig.concurrency"] = *tuning.Concurrency
+	}
+	if len(tuning.StatsInclusionPrefixes) > 0 {
+		values["meshConfig.defaultConfig.proxyStatsMatcher.inclusionPrefixes"] = tuning.StatsInclusionPrefixes
+	}
+	if len(tuning.StatsInclusionRegexps) > 0 {
+		values["meshConfig.defaultConfig.proxyStatsMatcher.inclusionRegexps"] = tuning.StatsInclusionRegexps
+	}
+	for path, value := range map[string]string{
+		"global.proxy.resources.requests.cpu":    tuning.CPURequest,
+		"global.proxy.resources.limits.cpu":      tuning.CPULimit,
+		"global.proxy.resources.requests.memory": tuning.MemoryRequest,
+		"global.proxy.resources.limits.memory":   tuning.MemoryLimit,
+	} {
+		if value != "" {
+			values[path] = value
+		}
+	}
+	return values
+}
+
+// meshProxyTuning reads the mesh-wide proxy settings from the mesh config and the istiod release values
+func (m *Manager) meshProxyTuning(ctx context.Context, istioNamespace, release, revision string) (ProxyTuning, error) {
+	var tuning ProxyTuning
+	var mesh struct {
+		DefaultConfig struct {
+			Concurrency       *int `json:"concurrency"`
+			ProxyStatsMatcher struct {
+				InclusionPrefixes []string `json:"inclusionPrefixes"`
+				InclusionRegexps  []string `json:"inclusionRegexps"`
+			} `json:"proxyStatsMatcher"`
+		} `json:"defaultConfig"`
+	}
+	if err := m.readMeshConfig(ctx, istioNamespace, revision, &mesh); err != nil {
+		return tuning, err
+	}
+	tuning.Concurrency = mesh.DefaultConfig.Concurrency
+	tuning.StatsInclusionPrefixes = mesh.DefaultConfig.ProxyStatsMatcher.InclusionPrefixes
+	tuning.StatsInclusionRegexps = mesh.DefaultConfig.ProxyStatsMatcher.InclusionRegexps
+
+	values, err := m.getHelmReleaseValues(istioNamespace, release, true)
+	if err != nil {
+		return tuning, err
+	}
+	tuning.CPURequest, _, _ = unstructured.NestedString(values, "global", "proxy", "resources", "requests", "cpu")
+	tuning.CPULimit, _, _ = unstructured.NestedString(values, "global", "proxy", "resources", "limits", "cpu")
+	tuning.MemoryRequest, _, _ = unstructured.NestedString(values, "global", "proxy", "resources", "requests", "memory")
+	tuning.MemoryLimit, _, _ = unstructured.NestedString(values, "global", "proxy", "resources", "limits", "memory")
+	return tuning, nil
+}
+
+// proxyTuningFromAnnotations reads the per-workload proxy overrides from pod template annotations
+func proxyTuningFromAnnotations(annotations map[string]string) (ProxyTuning, error) {
+	tuning := ProxyTuning{
+		CPURequest:    annotations[proxyResourceAnnotations["cpu_request"]],
+		CPULimit:      annotations[proxyResourceAnnotations["cpu_limit"]],
+		MemoryRequest: annotations[proxyResourceAnnotations["memory_request"]],
+		MemoryLimit:   annotations[proxyResourceAnnotations["memory_limit"]],
+	}
+	var config struct {
+		Concurrency       *int `json:"concurrency"`
+		ProxyStatsMatcher struct {
+			InclusionPrefixes []string `json:"inclusionPrefixes"`
+			InclusionRegexps  []string `json:"inclusionRegexps"`
+		} `json:"proxyStatsMatcher"`
+	}
+	if err := yaml.Unmarshal([]byte(annotations[proxyConfigAnnotation]), &config); err != nil {
+		return tuning, err
+	}
+	tuning.Concurrency = config.Concurrency
+	tuning.StatsInclusionPrefixes = config.ProxyStatsMatcher.InclusionPrefixes
+	tuning.StatsInclusionRegexps = config.ProxyStatsMatcher.InclusionRegexps
+	return tuning, nil
+}
+
+// setProxyTuningAnnotations writes proxy overrides into pod template annotations, keeping other proxy config fields
+func setProxyTuningAnnotations(annotations map[string]string, tuning ProxyTuning) error {
+	for name, value := range map[string]string{
+		"cpu_request":    tuning.CPURequest,
+		"cpu_limit":      tuning.CPULimit,
+		"memory_request": tuning.MemoryRequest,
+		"memory_limit":   tuning.MemoryLimit,
+	} {
+		if value != "" {
+			annotations[proxyResourceAnnotations[name]] = value
+		}
+	}
+
+	if tuning.Concurrency == nil && len(tuning.StatsInclusionPrefixes) == 0 && len(tuning.StatsInclusionRegexps) == 0 {
+		return nil
+	}
+	config := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(annotations[proxyConfigAnnotation]), &config); err != nil {
+		return fmt.Errorf("failed to parse %s annotation: %w", proxyConfigAnnotation, err)
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	if tuning.Concurrency != nil {
+		config["concurrency"] = *tuning.Concurrency
+	}
+	if len(tuning.StatsInclusionPrefixes) > 0 || len(tuning.StatsInclusionRegexps) > 0 {
+		matcher, _ := config["proxyStatsMatcher"].(map[string]interface{})
+		if matcher == nil {
+			matcher = make(map[string]interface{})
+		}
+		if len(tuning.StatsInclusionPrefixes) > 0 {
+			matcher["inclusionPrefixes"] = tuning.StatsInclusionPrefixes
+		}
+		if len(tuning.StatsInclusionRegexps) > 0 {
+			matcher["inclusionRegexps"] = tuning.StatsInclusionRegexps
+		}
+		config["proxyStatsMatcher"] = matcher
+	}
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	annotations[proxyConfigAnnotation] = string(out)
+	return nil
+}
+
+// applyProxyTuning sets the proxy overrides on a deployment's pod template and waits for the rollout
+func (m *Manager) applyProxyTuning(ctx context.Context, deployment *appsv1.Deployment, tuning ProxyTuning, timeout time.Duration) error {
+	err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		if current.Spec.Template.Annotations == nil {
+			current.Spec.Template.Annotations = make(map[string]string)
+		}
+		if err := setProxyTuningAnnotations(current.Spec.Template.Annotations, tuning); err != nil {
+			return err
+		}
+		_, err = m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return m.waitForRollout(ctx, deployment, timeout)
+}
+
+// setupProxyBenchmark deploys a Fortio client and server with sidecars and returns their pods keyed by app
+func (m *Manager) setupProxyBenchmark(ctx context.Context, namespace string) (map[string]string, error) {
+	if err := m.createOrUpdateNamespace(ctx, namespace, false); err != nil {
+		return nil, fmt.Errorf("failed to create benchmark namespace: %w", err)
+	}
+	for _, name := range []string{"fortio-server-mesh", "fortio-client-mesh"} {
+		if err := m.createFortioDeployment(ctx, namespace, name, true); err != nil {
+			return nil, fmt.Errorf("failed to deploy %s: %w", name, err)
+		}
+	}
+	return m.waitForBenchmarkPods(ctx, namespace, 2, 3*time.Minute)
+}
+
+// measureProxyLoad runs the fixed Fortio load through the benchmark sidecars and reports their CPU
+func (m *Manager) measureProxyLoad(ctx context.Context, namespace string, pods map[string]string, qps, connections int, duration time.Duration) (*ProxyLoadMeasurement, error) {
+	proxyPods := []string{pods["fortio-client-mesh"], pods["fortio-server-mesh"]}
+	load, err := m.runFortioLoad(ctx, namespace, pods["fortio-client-mesh"], "fortio-server-mesh", qps, connections, duration, 0, proxyPods)
+	if err != nil {
+		return nil, err
+	}
+	measurement := &ProxyLoadMeasurement{Load: *load}
+	if load.ProxyCPUSecs > 0 {
+		measurement.ProxyMillicores = load.ProxyCPUSecs / duration.Seconds() * 1000
+		if load.Requests > 0 {
+			measurement.ProxyCPUPer1KRequest = load.ProxyCPUSecs * 1000 / float64(load.Requests) * 1000
+		}
+	}
+	return measurement, nil
+}
@@ -0,0 +1,419 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"golang.org/x/net/html"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilversion "k8s.io/apimachinery/pkg/util/version"
+	"sigs.k8s.io/yaml"
+)
+
+// istioUpgradeNotesURL is the upstream location of per-minor upgrade notes
+const istioUpgradeNotesURL = "https://istio.io/latest/news/releases/%d.%d.x/announcing-%d.%d/upgrade-notes/"
+
+// UpgradeNote represents a single section of upstream upgrade notes
+type UpgradeNote struct {
+	Release    string   `json:"release"`
+	Title      string   `json:"title"`
+	Summary    string   `json:"summary,omitempty"`
+	References []string `json:"references,omitempty"` // config fields, flags and APIs mentioned in the note
+	InUse      []string `json:"in_use,omitempty"`     // references found in the running configuration
+	Relevant   bool     `json:"relevant"`
+}
+
+// UpgradeImpact summarizes the upgrade notes between two Istio versions
+type UpgradeImpact struct {
+	FromVersion   string        `json:"from_version"`
+	ToVersion     string        `json:"to_version"`
+	Releases      []string      `json:"releases"`
+	RelevantCount int           `json:"relevant_count"`
+	Notes         []UpgradeNote `json:"notes"`
+	Sources       []string      `json:"sources"`
+	Issues        []string      `json:"issues,omitempty"`
+}
+
+// getIstioReleaseNotesParams are the arguments of get_istio_release_notes
+type getIstioReleaseNotesParams struct {
+	Namespace    string `json:"namespace,omitempty"`     // default: istio-system
+	FromVersion  string `json:"from_version,omitempty"`  // default: detected istiod version
+	ToVersion    string `json:"to_version"`              // target version
+	RelevantOnly bool   `json:"relevant_only,omitempty"` // only return notes touching the running configuration
+}
+
+// GetIstioReleaseNotes summarizes upstream upgrade notes between the installed and a target Istio version
+func (m *Manager) GetIstioReleaseNotes(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getIstioReleaseNotesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.ToVersion == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "to_version is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+
+	if params.FromVersion == "" {
+		detected, err := m.detectIstiodVersion(ctx, params.Namespace)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to detect installed Istio version (pass from_version explicitly): %v", err),
+					},
+				},
+			}, nil
+		}
+		params.FromVersion = detected
+	}
+
+	from, err := utilversion.ParseGeneric(params.FromVersion)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid from_version %q: %v", params.FromVersion, err),
+				},
+			},
+		}, nil
+	}
+	to, err := utilversion.ParseGeneric(params.ToVersion)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid to_version %q: %v", params.ToVersion, err),
+				},
+			},
+		}, nil
+	}
+	if from.Major() != to.Major() {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Upgrades across major versions (%s to %s) are not supported", params.FromVersion, params.ToVersion),
+				},
+			},
+		}, nil
+	}
+	if !from.LessThan(to) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("to_version %s must be newer than from_version %s", params.ToVersion, params.FromVersion),
+				},
+			},
+		}, nil
+	}
+
+	impact := &UpgradeImpact{
+		FromVersion: params.FromVersion,
+		ToVersion:   params.ToVersion,
+	}
+
+	// Collect the identifiers used by the running configuration
+	inUse, issues := m.collectIstioConfigReferences(ctx, params.Namespace)
+	impact.Issues = append(impact.Issues, issues...)
+
+	// Upgrade notes are published per minor release
+	httpClient := &http.Client{Timeout: 15 * time.Second}
+	if from.Minor() == to.Minor() {
+		impact.Issues = append(impact.Issues, "Patch upgrades within a minor release have no upgrade notes; review the patch announcements instead")
+	}
+	for minor := from.Minor() + 1; minor <= to.Minor(); minor++ {
+		release := fmt.Sprintf("%d.%d", to.Major(), minor)
+		url := fmt.Sprintf(istioUpgradeNotesURL, to.Major(), minor, to.Major(), minor)
+		impact.Releases = append(impact.Releases, release)
+		impact.Sources = append(impact.Sources, url)
+
+		notes, err := fetchUpgradeNotes(ctx, httpClient, url, release)
+		if err != nil {
+			logrus.Warnf("Failed to fetch upgrade notes for %s: %v", release, err)
+			impact.Issues = append(impact.Issues, fmt.Sprintf("Failed to fetch upgrade notes for %s: %v", release, err))
+			continue
+		}
+
+		for _, note := range notes {
+			note.InUse = matchConfigReferences(note.References, inUse)
+			note.Relevant = len(note.InUse) > 0
+			if note.Relevant {
+				impact.RelevantCount++
+			}
+			if params.RelevantOnly && !note.Relevant {
+				continue
+			}
+			impact.Notes = append(impact.Notes, note)
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(impact, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// detectIstiodVersion returns the version of the running istiod from its image tag
+func (m *Manager) detectIstiodVersion(ctx context.Context, namespace string) (string, error) {
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to list istiod deployments: %w", err)
+	}
+	if len(deployments.Items) == 0 {
+		return "", fmt.Errorf("no istiod deployment found in namespace %s", namespace)
+	}
+
+	for _, container := range deployments.Items[0].Spec.Template.Spec.Containers {
+		if container.Name != "discovery" {
+			continue
+		}
+		if idx := strings.LastIndex(container.Image, ":"); idx >= 0 {
+			tag := container.Image[idx+1:]
+			// Strip image variant suffixes such as -distroless
+			if dash := strings.Index(tag, "-"); dash >= 0 {
+				tag = tag[:dash]
+			}
+			return tag, nil
+		}
+	}
+
+	return "", fmt.Errorf("could not determine version from istiod image")
+}
+
+// collectIstioConfigReferences gathers the Helm value paths, mesh config fields and istiod
+// environment variables that are explicitly set in the running installation
+func (m *Manager) collectIstioConfigReferences(ctx context.Context, namespace string) (map[string]bool, []string) {
+	refs := make(map[string]bool)
+	var issues []string
+
+	// User-supplied Helm values for istiod
+	if values, err := m.getHelmReleaseValues(namespace, "istiod", false); err == nil {
+		flattenConfigKeys("", values, refs)
+	} else {
+		issues = append(issues, fmt.Sprintf("Could not read istiod Helm values: %v", err))
+	}
+
+	// Mesh config from the istio ConfigMap
+	if cm, err := m.k8sClient.Kubernetes.CoreV1().ConfigMaps(namespace).Get(ctx, "istio", metav1.GetOptions{}); err == nil {
+		meshConfig := make(map[string]interface{})
+		if err := yaml.Unmarshal([]byte(cm.Data["mesh"]), &meshConfig); err == nil {
+			flattenConfigKeys("meshConfig", meshConfig, refs)
+		}
+	} else {
+		issues = append(issues, fmt.Sprintf("Could not read mesh config: %v", err))
+	}
+
+	// Environment variables (feature flags) set on istiod
+	if deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: "app=istiod",
+	}); err == nil {
+		for _, deployment := range deployments.Items {
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				for _, env := range container.Env {
+					refs[env.Name] = true
+				}
+			}
+		}
+	}
+
+	return refs, issues
+}
+
+// flattenConfigKeys records every dotted key path and leaf name of a nested map
+func flattenConfigKeys(prefix string, values map[string]interface{}, refs map[string]bool) {
+	for key, value := range values {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		refs[path] = true
+		refs[key] = true
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenConfigKeys(path, nested, refs)
+		}
+	}
+}
+
+// matchConfigReferences returns the note references that appear in the running configuration
+func matchConfigReferences(references []string, inUse map[string]bool) []string {
+	var matches []string
+	for _, ref := range references {
+		candidate := strings.TrimPrefix(ref, "values.")
+		leaf := candidate
+		if idx := strings.LastIndex(candidate, "."); idx >= 0 {
+			leaf = candidate[idx+1:]
+		}
+		// Short leaf names (e.g. "enabled") are too generic to be meaningful on their own
+		if inUse[candidate] || inUse[ref] || (len(leaf) >= 8 && inUse[leaf]) {
+			matches = append(matches, ref)
+		}
+	}
+	sort.Strings(matches)
+	return matches
+}
+
+// fetchUpgradeNotes downloads an upgrade notes page and splits it into sections
+func fetchUpgradeNotes(ctx context.Context, client *http.Client, url, release string) ([]UpgradeNote, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+
+	doc, err := html.Parse(io.LimitReader(resp.Body, 4<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse page: %w", err)
+	}
+
+	// Restrict parsing to the article body when present
+	root := findHTMLElement(doc, "article")
+	if root == nil {
+		root = doc
+	}
+
+	var notes []UpgradeNote
+	var current *UpgradeNote
+	var summary strings.Builder
+	seenRefs := make(map[string]bool)
+
+	flush := func() {
+		if current != nil {
+			current.Summary = truncateText(strings.TrimSpace(summary.String()), 600)
+			notes = append(notes, *current)
+		}
+		summary.Reset()
+		seenRefs = make(map[string]bool)
+	}
+
+	// collecting is set inside a p or li whose text is already in the summary, so the paragraphs and nested
+	// items of a list item are not added again; their code references are still picked up
+	collecting := false
+	var walk func(n *html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.Data {
+			case "h2", "h3":
+				flush()
+				current = &UpgradeNote{Release: release, Title: strings.TrimSpace(htmlText(n))}
+				return
+			case "p", "li":
+				if current != nil && !collecting {
+					summary.WriteString(strings.Join(strings.Fields(htmlText(n)), " "))
+					summary.WriteString(" ")
+					collecting = true
+					defer func() { collecting = false }()
+				}
+			case "code":
+				if current != nil {
+					ref := strings.TrimSpace(htmlText(n))
+					if ref != "" && len(ref) < 120 && !strings.Contains(ref, " ") && !seenRefs[ref] {
+						seenRefs[ref] = true
+						current.References = append(current.References, ref)
+					}
+				}
+				return
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(root)
+	flush()
+
+	return notes, nil
+}
+
+// findHTMLElement returns the first element with the given tag name
+func findHTMLElement(n *html.Node, tag string) *html.Node {
+	if n.Type == html.ElementNode && n.Data == tag {
+		return n
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		if found := findHTMLElement(child, tag); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+// htmlText returns the concatenated text content of a node, with block elements such as the paragraphs and
+// nested lists of a list item set apart by a space
+func htmlText(n *html.Node) string {
+	if n.Type == html.TextNode {
+		return n.Data
+	}
+	var text strings.Builder
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		text.WriteString(htmlText(child))
+		if child.Type == html.ElementNode && htmlBlockElements[child.Data] {
+			text.WriteString(" ")
+		}
+	}
+	return text.String()
+}
+
+// htmlBlockElements are the elements whose text does not run on into the text after them
+var htmlBlockElements = map[string]bool{"p": true, "li": true, "ul": true, "ol": true, "div": true, "br": true, "pre": true}
+
+// truncateText shortens text to at most max bytes
+func truncateText(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+	return text[:max] + "..."
+}
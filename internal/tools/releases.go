@@ -0,0 +1,196 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/storage/driver"
+)
+
+// ReleaseValues represents the configuration of an installed Helm release
+type ReleaseValues struct {
+	Release        string                 `json:"release"`
+	Namespace      string                 `json:"namespace"`
+	Chart          string                 `json:"chart,omitempty"`
+	AppVersion     string                 `json:"app_version,omitempty"`
+	Revision       int                    `json:"revision,omitempty"`
+	Status         string                 `json:"status,omitempty"`
+	Updated        string                 `json:"updated,omitempty"`
+	UserSupplied   map[string]interface{} `json:"user_supplied"`
+	ComputedValues map[string]interface{} `json:"computed_values,omitempty"`
+}
+
+// releaseComponents maps well-known mesh components to their default Helm release and namespace
+var releaseComponents = map[string]struct {
+	Release   string
+	Namespace string
+}{
+	"base":    {Release: "istio-base", Namespace: "istio-system"},
+	"istiod":  {Release: "istiod", Namespace: "istio-system"},
+	"cni":     {Release: "istio-cni", Namespace: "istio-system"},
+	"gateway": {Release: "istio-ingress", Namespace: "istio-ingress"},
+	"sail":    {Release: "sail-operator", Namespace: "sail-operator"},
+}
+
+// getReleaseValuesParams are the arguments of get_release_values
+type getReleaseValuesParams struct {
+	Component   string `json:"component,omitempty"`    // istiod, gateway, base, cni, sail (default: istiod)
+	ReleaseName string `json:"release_name,omitempty"` // overrides the component's release name
+	Namespace   string `json:"namespace,omitempty"`    // overrides the component's namespace
+	Computed    bool   `json:"computed,omitempty"`     // include computed values (chart defaults merged with overrides)
+}
+
+// GetReleaseValues returns the user-supplied and computed values of an installed Helm release
+func (m *Manager) GetReleaseValues(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getReleaseValuesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Component == "" && params.ReleaseName == "" {
+		params.Component = "istiod"
+	}
+	if params.Component != "" {
+		component, exists := releaseComponents[params.Component]
+		if !exists {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Unknown component: %s (expected one of base, istiod, cni, gateway, sail)", params.Component),
+					},
+				},
+			}, nil
+		}
+		if params.ReleaseName == "" {
+			params.ReleaseName = component.Release
+		}
+		if params.Namespace == "" {
+			params.Namespace = component.Namespace
+		}
+	}
+	if params.Namespace == "" {
+		params.Namespace = "istio-system"
+	}
+
+	// Check if Helm is available
+	if err := m.checkHelmAvailable(); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
+				},
+			},
+		}, nil
+	}
+
+	userValues, err := m.getHelmReleaseValues(params.Namespace, params.ReleaseName, false)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get values for release %s: %v", params.ReleaseName, err),
+				},
+			},
+		}, nil
+	}
+
+	result := ReleaseValues{
+		Release:      params.ReleaseName,
+		Namespace:    params.Namespace,
+		UserSupplied: userValues,
+	}
+
+	if params.Computed {
+		computedValues, err := m.getHelmReleaseValues(params.Namespace, params.ReleaseName, true)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get computed values for release %s: %v", params.ReleaseName, err),
+					},
+				},
+			}, nil
+		}
+		result.ComputedValues = computedValues
+	}
+
+	// Add chart and status information when available
+	if release, err := m.getHelmReleaseInfo(params.Namespace, params.ReleaseName); err == nil {
+		result.Chart = release.Chart
+		result.AppVersion = release.AppVersion
+		result.Revision = release.Revision
+		result.Status = release.Status
+		result.Updated = release.Updated
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getHelmReleaseValues returns the values of a release, optionally including computed values
+func (m *Manager) getHelmReleaseValues(namespace, releaseName string, all bool) (map[string]interface{}, error) {
+	cfg, _, err := m.helmActionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	getValues := action.NewGetValues(cfg)
+	getValues.AllValues = all
+	values, err := getValues.Run(releaseName)
+	if err != nil {
+		return nil, fmt.Errorf("helm get values failed: %w", err)
+	}
+
+	// Releases installed without user values have none
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+
+	return values, nil
+}
+
+// getHelmReleaseInfo returns the chart, revision and status of the latest revision of a Helm release
+func (m *Manager) getHelmReleaseInfo(namespace, releaseName string) (*HelmRelease, error) {
+	cfg, _, err := m.helmActionConfig(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	rel, err := action.NewGet(cfg).Run(releaseName)
+	if err != nil {
+		if errors.Is(err, driver.ErrReleaseNotFound) {
+			return nil, fmt.Errorf("release %s not found", releaseName)
+		}
+		return nil, fmt.Errorf("failed to get helm release info: %w", err)
+	}
+
+	return newHelmRelease(rel), nil
+}
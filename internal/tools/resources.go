@@ -0,0 +1,210 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// NamespacesState is the live namespace list exposed as the cluster://namespaces resource
+type NamespacesState struct {
+	Namespaces []NamespaceState `json:"namespaces"`
+	Timestamp  time.Time        `json:"timestamp"`
+}
+
+// NamespaceState is a namespace with its mesh enrollment
+type NamespaceState struct {
+	Name      string             `json:"name"`
+	Phase     string             `json:"phase"`
+	Dataplane NamespaceDataplane `json:"dataplane"`
+}
+
+// MeshProxy is a running Envoy or ztunnel proxy
+type MeshProxy struct {
+	Pod       string `json:"pod"`
+	Namespace string `json:"namespace"`
+	Node      string `json:"node,omitempty"`
+	Kind      string `json:"kind"` // sidecar, gateway, waypoint or ztunnel
+	Owner     string `json:"owner,omitempty"`
+	Revision  string `json:"revision,omitempty"`
+	Image     string `json:"image,omitempty"`
+	Ready     bool   `json:"ready"`
+}
+
+// MeshProxiesState is the live proxy inventory exposed as the mesh://proxies resource
+type MeshProxiesState struct {
+	Summary   map[string]int `json:"summary"` // proxies per kind
+	Versions  map[string]int `json:"versions"`
+	Proxies   []MeshProxy    `json:"proxies"`
+	Timestamp time.Time      `json:"timestamp"`
+}
+
+// IstioStatusResource returns the control plane status exposed as the istio://status resource
+func (m *Manager) IstioStatusResource(ctx context.Context) (*IstioStatus, error) {
+	m, _ = m.withCurrentClient()
+	if err := m.checkResourceAccess("istio-system"); err != nil {
+		return nil, err
+	}
+	return m.getIstioStatus(ctx, "istio-system")
+}
+
+// NamespacesResource returns the namespaces and their mesh enrollment, limited to the configured scope
+func (m *Manager) NamespacesResource(ctx context.Context) (*NamespacesState, error) {
+	m, _ = m.withCurrentClient()
+	if err := m.checkResourceAccess(""); err != nil {
+		return nil, err
+	}
+	namespaces, err := m.resourceNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ztunnelInstalled := len(m.ztunnelPodsByNode(ctx)) > 0
+	state := &NamespacesState{Namespaces: []NamespaceState{}, Timestamp: time.Now()}
+	for i := range namespaces {
+		ns := &namespaces[i]
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in %s: %w", ns.Name, err)
+		}
+		state.Namespaces = append(state.Namespaces, NamespaceState{
+			Name:      ns.Name,
+			Phase:     string(ns.Status.Phase),
+			Dataplane: analyzeNamespaceDataplane(ns, pods.Items, ztunnelInstalled),
+		})
+	}
+	return state, nil
+}
+
+// MeshProxiesResource returns every sidecar, gateway, waypoint and ztunnel proxy in the configured scope
+func (m *Manager) MeshProxiesResource(ctx context.Context) (*MeshProxiesState, error) {
+	m, _ = m.withCurrentClient()
+	if err := m.checkResourceAccess(""); err != nil {
+		return nil, err
+	}
+	namespaces, err := m.resourceNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &MeshProxiesState{
+		Summary:   make(map[string]int),
+		Versions:  make(map[string]int),
+		Proxies:   []MeshProxy{},
+		Timestamp: time.Now(),
+	}
+	for _, ns := range namespaces {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list pods in %s: %w", ns.Name, err)
+		}
+		for i := range pods.Items {
+			pod := &pods.Items[i]
+			if pod.Status.Phase != corev1.PodRunning {
+				continue
+			}
+			proxy, ok := meshProxy(pod)
+			if !ok {
+				continue
+			}
+			proxy.Owner = m.podOwner(ctx, pod)
+			state.Summary[proxy.Kind]++
+			// Digest-pinned images carry no tag to tell the version from
+			if ref, err := parseImageReference(proxy.Image); err == nil {
+				state.Versions[ref.Tag]++
+			} else {
+				state.Versions["unknown"]++
+			}
+			state.Proxies = append(state.Proxies, proxy)
+		}
+	}
+	sort.Slice(state.Proxies, func(i, j int) bool {
+		if state.Proxies[i].Namespace != state.Proxies[j].Namespace {
+			return state.Proxies[i].Namespace < state.Proxies[j].Namespace
+		}
+		return state.Proxies[i].Pod < state.Proxies[j].Pod
+	})
+	return state, nil
+}
+
+// checkResourceAccess refuses resource reads without a cluster or outside the configured namespace scope
+func (m *Manager) checkResourceAccess(namespace string) error {
+	if m.k8sClient == nil {
+		return fmt.Errorf("Kubernetes client not available. Provide a kubeconfig with configure_kubeconfig or fix the current one (run validate_access to diagnose).")
+	}
+	scope := m.config.Scope
+	if namespace == "" || !scope.Enabled() {
+		return nil
+	}
+	if !containsString(scope.Namespaces, namespace) && !containsString(scope.ProtectedNamespaces, namespace) {
+		return fmt.Errorf("namespace %s is outside the configured scope (%s)", namespace, strings.Join(scope.Namespaces, ", "))
+	}
+	return nil
+}
+
+// resourceNamespaces lists the namespaces a resource covers: the scoped and protected namespaces when
+// scoping is enabled, otherwise every namespace
+func (m *Manager) resourceNamespaces(ctx context.Context) ([]corev1.Namespace, error) {
+	scope := m.config.Scope
+	if !scope.Enabled() {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("failed to list namespaces: %w", err)
+		}
+		return list.Items, nil
+	}
+
+	var namespaces []corev1.Namespace
+	for _, name := range append(append([]string{}, scope.Namespaces...), scope.ProtectedNamespaces...) {
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+		if err != nil {
+			continue
+		}
+		namespaces = append(namespaces, *ns)
+	}
+	return namespaces, nil
+}
+
+// meshProxy classifies a pod as a mesh proxy, returning false for pods without one
+func meshProxy(pod *corev1.Pod) (MeshProxy, bool) {
+	proxy := MeshProxy{
+		Pod:       pod.Name,
+		Namespace: pod.Namespace,
+		Node:      pod.Spec.NodeName,
+		Revision:  pod.Labels["istio.io/rev"],
+	}
+
+	container := "istio-proxy"
+	switch {
+	case pod.Labels["app"] == "ztunnel":
+		proxy.Kind = "ztunnel"
+		if len(pod.Spec.Containers) > 0 {
+			container = pod.Spec.Containers[0].Name
+		}
+	case !podHasSidecar(pod):
+		return proxy, false
+	case pod.Labels["gateway.istio.io/managed"] == "istio.io-mesh-controller":
+		proxy.Kind = "waypoint"
+	case pod.Labels["gateway.networking.k8s.io/gateway-name"] != "" || strings.Contains(pod.Labels["istio"], "gateway"):
+		proxy.Kind = "gateway"
+	default:
+		proxy.Kind = "sidecar"
+	}
+
+	for _, c := range append(append([]corev1.Container{}, pod.Spec.Containers...), pod.Spec.InitContainers...) {
+		if c.Name == container {
+			proxy.Image = c.Image
+		}
+	}
+	for _, status := range append(append([]corev1.ContainerStatus{}, pod.Status.ContainerStatuses...), pod.Status.InitContainerStatuses...) {
+		if status.Name == container {
+			proxy.Ready = status.Ready
+		}
+	}
+	return proxy, true
+}
@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// responseFlagCauses lists the usual reasons an Istio mesh produces each Envoy response flag
+var responseFlagCauses = map[string][]string{
+	"NR": {
+		"No VirtualService or HTTPRoute matches the host and path",
+		"A VirtualService routes to a subset that no DestinationRule defines",
+		"The Sidecar resource of the namespace does not export the destination host",
+	},
+	"NC": {
+		"The destination host has no Service or ServiceEntry",
+		"The destination is hidden by exportTo or the Sidecar egress hosts",
+	},
+	"UH": {
+		"The destination Service has no ready endpoints (pods not running, failing readiness probes or a selector mismatch)",
+		"Outlier detection ejected every endpoint of the destination",
+	},
+	"UF": {
+		"mTLS mismatch: a DestinationRule sets tls.mode DISABLE while the server requires STRICT, or the reverse",
+		"The Service targetPort is closed in the backend container",
+		"A NetworkPolicy blocks the connection to the backend pod",
+	},
+	"URX": {
+		"The backend keeps failing and the VirtualService retries are exhausted",
+		"Retries amplify an upstream overload; check the backend's errors and latency",
+	},
+	"UC": {
+		"The backend closes idle keep-alive connections before Envoy does; lower the DestinationRule connectionPool.http.idleTimeout",
+		"The backend pod restarted or was terminated during the request",
+	},
+	"UT": {
+		"The backend is slower than the VirtualService timeout",
+		"The backend is saturated; check its CPU, latency and the proxy concurrency",
+	},
+	"UO": {
+		"A DestinationRule connectionPool limit (maxConnections, http1MaxPendingRequests, http2MaxRequests) is too low for the load",
+		"The circuit breaker opened because the backend is slow and requests queue up",
+	},
+	"UR": {
+		"The backend crashed or reset the stream mid-request",
+		"HTTP/2 or gRPC protocol mismatch: the Service port name or appProtocol declares the wrong protocol",
+	},
+	"UAEX": {
+		"The external authorization service denied the request",
+		"The external authorization provider is unreachable and failOpen is not set",
+	},
+	"RL": {
+		"A local or global rate limit was reached",
+	},
+	"RLSE": {
+		"The global rate limit service is down or unreachable from the proxy",
+	},
+	"DC": {
+		"The client timed out or gave up before the backend answered; compare with the backend latency",
+		"A load balancer or ingress in front of the gateway closed the connection",
+	},
+	"DT": {
+		"A long-lived request exceeded the configured max stream or connection duration",
+	},
+	"LR": {
+		"The proxy reset the connection itself, often when a listener or cluster was drained during a config push",
+		"A protocol mismatch between the Service port name and the actual traffic",
+	},
+	"LH": {
+		"The local application failed its health check",
+	},
+	"DI": {
+		"A VirtualService fault.delay is configured for this route",
+	},
+	"FI": {
+		"A VirtualService fault.abort is configured for this route",
+	},
+	"SI": {
+		"A streaming or long-polling connection was idle longer than the stream idle timeout",
+	},
+	"IH": {
+		"The client sent a header value Envoy validates strictly, such as an invalid x-envoy-* header",
+	},
+	"DPE": {
+		"The client sent malformed HTTP, or non-HTTP traffic to a port declared as HTTP",
+	},
+	"UPE": {
+		"The backend answered with malformed HTTP, or speaks a different protocol than the Service port name declares",
+	},
+	"UMSDR": {
+		"A request exceeded the maxStreamDuration of the route or DestinationRule",
+	},
+	"NFCF": {
+		"A WasmPlugin or other ECDS filter could not be fetched or loaded",
+	},
+	"OM": {
+		"The proxy is close to its memory limit; raise the sidecar memory limit or reduce the config it receives",
+	},
+	"DF": {
+		"A ServiceEntry with resolution DNS points at a host that does not resolve",
+	},
+	"DO": {
+		"The load balancer drop overload configuration is shedding traffic",
+	},
+}
+
+// responseFlagSamples is how many access log entries are kept per flag
+const responseFlagSamples = 3
+
+// ResponseFlagStats aggregates the access log entries that carry one response flag
+type ResponseFlagStats struct {
+	Flag          string            `json:"flag"`
+	Count         int               `json:"count"`
+	Explanation   string            `json:"explanation"`
+	LikelyCauses  []string          `json:"likely_causes,omitempty"`
+	ResponseCodes map[string]int    `json:"response_codes"`
+	Proxies       map[string]int    `json:"proxies"`             // entries per pod
+	Upstreams     map[string]int    `json:"upstreams,omitempty"` // entries per upstream cluster
+	Samples       []IngressLogEntry `json:"samples,omitempty"`
+}
+
+// ResponseFlagAnalysis is the response flag breakdown of a namespace's proxies over a time window
+type ResponseFlagAnalysis struct {
+	Namespace        string              `json:"namespace"`
+	Selector         string              `json:"selector,omitempty"`
+	Since            string              `json:"since"`
+	ProxiesScanned   int                 `json:"proxies_scanned"`
+	EntriesScanned   int                 `json:"entries_scanned"`
+	EntriesWithFlags int                 `json:"entries_with_flags"`
+	Flags            []ResponseFlagStats `json:"flags"`
+	Notes            []string            `json:"notes,omitempty"`
+	Timestamp        time.Time           `json:"timestamp"`
+}
+
+// analyzeResponseFlagsParams are the arguments of analyze_response_flags
+type analyzeResponseFlagsParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: default
+	Selector       string `json:"selector,omitempty"`        // label selector for the pods to analyze
+	Since          string `json:"since,omitempty"`           // default: 10m
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+}
+
+// AnalyzeResponseFlags aggregates the Envoy response flags in the access logs of a namespace's proxies
+// and explains each flag with its likely causes
+func (m *Manager) AnalyzeResponseFlags(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params analyzeResponseFlagsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Since == "" {
+		params.Since = "10m"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	since, err := time.ParseDuration(params.Since)
+	if err != nil || since <= 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid since duration %q: use a positive duration like 10m or 1h", params.Since),
+				},
+			},
+		}, nil
+	}
+
+	pods, err := m.runningPods(ctx, params.Namespace, params.Selector)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := ResponseFlagAnalysis{
+		Namespace: params.Namespace,
+		Selector:  params.Selector,
+		Since:     params.Since,
+		Flags:     []ResponseFlagStats{},
+		Timestamp: time.Now(),
+	}
+
+	var mesh struct {
+		AccessLogFile string `json:"accessLogFile"`
+	}
+	if err := m.readMeshConfig(ctx, params.IstioNamespace, "", &mesh); err == nil && mesh.AccessLogFile == "" {
+		result.Notes = append(result.Notes, "meshConfig.accessLogFile is not set, so proxies write no access logs unless a Telemetry resource enables them; set it to /dev/stdout")
+	}
+
+	stats := make(map[string]*ResponseFlagStats)
+	sinceSeconds := int64(since.Seconds())
+	for i := range pods {
+		pod := &pods[i]
+		if !podHasSidecar(pod) {
+			continue
+		}
+		result.ProxiesScanned++
+		logs, err := m.k8sClient.Kubernetes.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			Container:    "istio-proxy",
+			SinceSeconds: &sinceSeconds,
+		}).Do(ctx).Raw()
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to read access logs of %s: %v", pod.Name, err))
+			continue
+		}
+		scanner := bufio.NewScanner(strings.NewReader(string(logs)))
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			entry, ok := parseIngressAccessLog(scanner.Text())
+			if !ok {
+				continue
+			}
+			entry.Pod = pod.Name
+			result.EntriesScanned++
+			if entry.ResponseFlags == "" || entry.ResponseFlags == "-" {
+				continue
+			}
+			result.EntriesWithFlags++
+			for _, flag := range strings.Split(entry.ResponseFlags, ",") {
+				flagStats := stats[flag]
+				if flagStats == nil {
+					flagStats = newResponseFlagStats(flag)
+					stats[flag] = flagStats
+				}
+				flagStats.Count++
+				flagStats.ResponseCodes[strconv.Itoa(entry.ResponseCode)]++
+				flagStats.Proxies[pod.Name]++
+				if entry.Cluster != "" && entry.Cluster != "-" {
+					flagStats.Upstreams[entry.Cluster]++
+				}
+				if len(flagStats.Samples) < responseFlagSamples {
+					flagStats.Samples = append(flagStats.Samples, entry)
+				}
+			}
+		}
+	}
+
+	for _, flagStats := range stats {
+		result.Flags = append(result.Flags, *flagStats)
+	}
+	sort.Slice(result.Flags, func(i, j int) bool {
+		if result.Flags[i].Count != result.Flags[j].Count {
+			return result.Flags[i].Count > result.Flags[j].Count
+		}
+		return result.Flags[i].Flag < result.Flags[j].Flag
+	})
+
+	switch {
+	case result.ProxiesScanned == 0:
+		result.Notes = append(result.Notes, fmt.Sprintf("No running pods with an istio-proxy container in namespace %s; ambient workloads log through ztunnel and waypoints instead", params.Namespace))
+	case result.EntriesScanned == 0:
+		result.Notes = append(result.Notes, fmt.Sprintf("No access log entries in the last %s", params.Since))
+	case result.EntriesWithFlags == 0:
+		result.Notes = append(result.Notes, fmt.Sprintf("None of the %d access log entries carry a response flag", result.EntriesScanned))
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// newResponseFlagStats starts the aggregate for a flag with its explanation and likely causes
+func newResponseFlagStats(flag string) *ResponseFlagStats {
+	explanation, ok := responseFlagExplanations[flag]
+	if !ok {
+		explanation = "unrecognized response flag; see the Envoy access log documentation for %RESPONSE_FLAGS%"
+	}
+	return &ResponseFlagStats{
+		Flag:          flag,
+		Explanation:   explanation,
+		LikelyCauses:  responseFlagCauses[flag],
+		ResponseCodes: make(map[string]int),
+		Proxies:       make(map[string]int),
+		Upstreams:     make(map[string]int),
+	}
+}
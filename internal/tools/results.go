@@ -0,0 +1,114 @@
+package tools
+
+import "reflect"
+
+// toolResults maps each tool to the type its successful result is the JSON encoding of; the MCP output schemas
+// are derived from these types. Tools answering with plain text or ad-hoc maps are not listed
+var toolResults = map[string]interface{}{
+	"configure_kubeconfig":              KubeconfigStatus{},
+	"get_cluster_info":                  ClusterInfo{},
+	"check_tool_permissions":            PermissionReport{},
+	"validate_access":                   AccessReport{},
+	"get_fleet_status":                  FleetStatus{},
+	"create_dev_cluster":                DevCluster{},
+	"self_test":                         SelfTestReport{},
+	"istio_canary_upgrade":              CanaryUpgradeResult{},
+	"migrate_istio_install":             IstioMigrationResult{},
+	"migrate_to_ambient":                AmbientMigrationResult{},
+	"inspect_revision_tags":             RevisionReport{},
+	"audit_discovery_selectors":         DiscoveryScopeReport{},
+	"configure_discovery_selectors":     DiscoverySelectorChange{},
+	"audit_istio_resources":             IstioResourceAudit{},
+	"istio_analyze":                     IstioAnalysis{},
+	"get_injection_config":              InjectionConfig{},
+	"set_injection_template":            InjectionTemplateChange{},
+	"preview_injection":                 InjectionPreview{},
+	"install_otel_collector":            OtelCollectorStatus{},
+	"configure_tracing":                 TracingConfigResult{},
+	"proxy_status":                      ProxyStatusReport{},
+	"watch_mesh_events":                 MeshEventWatch{},
+	"istiod_debug":                      IstiodDebugResult{},
+	"audit_istiod_flags":                IstiodFlagAudit{},
+	"check_istio_status":                IstioStatus{},
+	"check_install_capacity":            PreflightReport{},
+	"estimate_mesh_cost":                MeshCostEstimate{},
+	"check_cni_chaining":                CNIChainingReport{},
+	"detect_cni_race":                   CNIRaceReport{},
+	"get_release_values":                ReleaseValues{},
+	"get_istio_release_notes":           UpgradeImpact{},
+	"check_sail_status":                 SailStatus{},
+	"apply_manifest":                    ApplyManifestResult{},
+	"cleanup_demo":                      CleanupResult{},
+	"list_managed_resources":            ManagedInventory{},
+	"test_connectivity":                 ConnectivityReport{},
+	"test_sleep_to_httpbin":             ConnectivityReport{},
+	"test_ingress_connectivity":         IngressTestReport{},
+	"test_gateway_paths":                GatewayPathReport{},
+	"sweep_service_ports":               ServicePortSweep{},
+	"verify_waypoint":                   WaypointVerification{},
+	"test_header_routing":               HeaderRoutingResult{},
+	"generate_traffic":                  TrafficRun{},
+	"run_mesh_conformance":              ConformanceReport{},
+	"configure_egress_routing":          EgressRoutingResult{},
+	"probe_gateway_tls":                 GatewayTLSReport{},
+	"diagnose_ingress_request":          IngressDiagnosis{},
+	"benchmark_mesh_overhead":           MeshOverhead{},
+	"traffic_shift":                     TrafficShiftResult{},
+	"create_virtual_service":            TrafficChangeResult{},
+	"delete_virtual_service":            TrafficChangeResult{},
+	"create_destination_rule":           TrafficChangeResult{},
+	"delete_destination_rule":           TrafficChangeResult{},
+	"detect_connection_pool_exhaustion": ConnectionPoolReport{},
+	"expose_service_via_gateway":        ServiceExposure{},
+	"create_gateway_route":              GatewayRouteChange{},
+	"delete_gateway_route":              GatewayRouteChange{},
+	"get_gateway_status":                GatewayStatusReport{},
+	"get_pod_logs":                      LogResult{},
+	"get_istio_proxy_logs":              LogResult{},
+	"get_istiod_logs":                   IstiodLogsResult{},
+	"analyze_response_flags":            ResponseFlagAnalysis{},
+	"get_kubernetes_events":             KubernetesEventList{},
+	"get_iptables_rules":                IptablesRules{},
+	"capture_packets":                   PacketCapture{},
+	"get_interception_mode":             InterceptionReport{},
+	"configure_traffic_exclusions":      TrafficExclusionResult{},
+	"configure_dns_proxying":            DNSProxyingResult{},
+	"tune_proxy":                        ProxyTuningResult{},
+	"audit_sidecar_startup":             StartupOrderingAudit{},
+	"diagnose_job_sidecars":             JobSidecarDiagnosis{},
+	"inspect_sidecar_annotations":       SidecarAnnotationReport{},
+	"detect_dataplane_mode":             DataplaneReport{},
+	"get_proxy_config":                  ProxyConfig{},
+	"envoy_admin_get":                   EnvoyAdminResponse{},
+	"get_ztunnel_config":                ZtunnelConfig{},
+	"generate_network_policy":           GeneratedPolicy{},
+	"trace_network_path":                NetworkTrace{},
+	"diagnose_pod_node_network":         PodNodeNetwork{},
+	"validate_dual_stack":               DualStackReport{},
+	"check_clock_skew":                  ClockSkewReport{},
+	"scan_mesh_images":                  ImageScanReport{},
+	"setup_ext_authz":                   ExtAuthzSetupResult{},
+	"test_ext_authz":                    ExtAuthzTestResult{},
+	"install_spire":                     SPIREInstallResult{},
+	"configure_istio_spire":             SPIREIstioConfigResult{},
+	"verify_spire_identities":           SPIREIdentityReport{},
+	"set_mtls_mode":                     MTLSChangeResult{},
+	"get_mtls_status":                   MTLSStatusReport{},
+	"check_mtls_between":                MTLSCheck{},
+	"get_workload_certificates":         WorkloadCertificateReport{},
+	"inspect_trust_domain":              TrustDomainReport{},
+	"create_authorization_policy":       TrafficChangeResult{},
+	"delete_authorization_policy":       TrafficChangeResult{},
+	"audit_authorization_policies":      AuthorizationPolicyAudit{},
+	"get_result":                        HistoryEntry{},
+	"compare_with_snapshot":             SnapshotComparison{},
+}
+
+// ResultType returns the type a tool's successful result is the JSON encoding of, or nil if it has none
+func ResultType(toolName string) reflect.Type {
+	result, ok := toolResults[toolName]
+	if !ok {
+		return nil
+	}
+	return reflect.TypeOf(result)
+}
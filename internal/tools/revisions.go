@@ -0,0 +1,312 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	admissionregistrationv1 "k8s.io/api/admissionregistration/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultRevision is the revision name of a control plane installed without a revision
+const defaultRevision = "default"
+
+// ControlPlaneRevision is an istiod revision and the state of its deployment
+type ControlPlaneRevision struct {
+	Revision  string   `json:"revision"`
+	Namespace string   `json:"namespace"`
+	Istiod    string   `json:"istiod"`
+	Version   string   `json:"version,omitempty"`
+	Ready     string   `json:"ready"`
+	Webhooks  []string `json:"webhooks,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// RevisionTag is an istio.io/tag injection webhook and the revision it points at
+type RevisionTag struct {
+	Tag      string `json:"tag"`
+	Revision string `json:"revision"`
+	Webhook  string `json:"webhook"`
+	Service  string `json:"service,omitempty"`
+	Orphaned bool   `json:"orphaned"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// NamespaceRevision is how a namespace's injection labels resolve to a control plane
+type NamespaceRevision struct {
+	Namespace      string         `json:"namespace"`
+	Label          string         `json:"label"`
+	ResolvesTo     string         `json:"resolves_to,omitempty"` // revision whose istiod injects new pods
+	ViaTag         string         `json:"via_tag,omitempty"`
+	ProxyRevisions map[string]int `json:"proxy_revisions,omitempty"` // injected pods per revision they were injected by
+	StalePods      int            `json:"stale_pods,omitempty"`      // pods injected by another revision than the namespace resolves to
+}
+
+// RevisionReport maps revisions, tags and namespaces to control planes
+type RevisionReport struct {
+	Revisions  []ControlPlaneRevision `json:"revisions"`
+	Tags       []RevisionTag          `json:"tags"`
+	Namespaces []NamespaceRevision    `json:"namespaces"`
+	Issues     []string               `json:"issues,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// inspectRevisionTagsParams are the arguments of inspect_revision_tags
+type inspectRevisionTagsParams struct {
+	Namespace string `json:"namespace,omitempty"` // only resolve this namespace
+}
+
+// InspectRevisionTags lists the istiod revisions and revision tags, shows which control plane each
+// injection-enabled namespace resolves to, and detects tags pointing at removed revisions
+func (m *Manager) InspectRevisionTags(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params inspectRevisionTagsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := &RevisionReport{
+		Revisions:  []ControlPlaneRevision{},
+		Tags:       []RevisionTag{},
+		Namespaces: []NamespaceRevision{},
+		Timestamp:  time.Now(),
+	}
+
+	// Control planes are found by their istiod deployments in any namespace
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments("").List(ctx, metav1.ListOptions{LabelSelector: "app=istiod"})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list istiod deployments: %v", err),
+				},
+			},
+		}, nil
+	}
+	revisions := make(map[string]*ControlPlaneRevision)
+	for _, deployment := range deployments.Items {
+		revision := deployment.Labels["istio.io/rev"]
+		if revision == "" {
+			revision = defaultRevision
+		}
+		replicas := int32(1)
+		if deployment.Spec.Replicas != nil {
+			replicas = *deployment.Spec.Replicas
+		}
+		cp := &ControlPlaneRevision{
+			Revision:  revision,
+			Namespace: deployment.Namespace,
+			Istiod:    deployment.Name,
+			Ready:     fmt.Sprintf("%d/%d", deployment.Status.ReadyReplicas, replicas),
+		}
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if ref, err := parseImageReference(container.Image); err == nil && container.Name == "discovery" {
+				cp.Version = ref.Tag
+			}
+		}
+		if deployment.Status.ReadyReplicas == 0 {
+			report.Issues = append(report.Issues, fmt.Sprintf("istiod for revision %s (%s/%s) has no ready replicas; pods it injects cannot start", revision, deployment.Namespace, deployment.Name))
+		}
+		revisions[revision] = cp
+	}
+
+	webhooks, err := m.k8sClient.Kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list mutating webhook configurations: %v", err),
+				},
+			},
+		}, nil
+	}
+	tags := make(map[string]string) // tag -> revision
+	for i := range webhooks.Items {
+		webhook := &webhooks.Items[i]
+		revision, isIstio := webhook.Labels["istio.io/rev"]
+		if !isIstio {
+			continue
+		}
+		if revision == "" {
+			revision = defaultRevision
+		}
+		tag := webhook.Labels["istio.io/tag"]
+		if tag == "" {
+			if cp, ok := revisions[revision]; ok {
+				cp.Webhooks = append(cp.Webhooks, webhook.Name)
+			} else {
+				report.Issues = append(report.Issues, fmt.Sprintf("Injection webhook %s belongs to revision %s, which has no istiod deployment; pods it matches fail injection or start without a sidecar", webhook.Name, revision))
+			}
+			continue
+		}
+
+		entry := RevisionTag{Tag: tag, Revision: revision, Webhook: webhook.Name}
+		if service := webhookService(webhook); service != nil {
+			entry.Service = service.Namespace + "/" + service.Name
+			if _, err := m.k8sClient.Kubernetes.CoreV1().Services(service.Namespace).Get(ctx, service.Name, metav1.GetOptions{}); errors.IsNotFound(err) {
+				entry.Orphaned = true
+				entry.Reason = fmt.Sprintf("webhook service %s no longer exists", entry.Service)
+			}
+		}
+		if _, ok := revisions[revision]; !ok {
+			entry.Orphaned = true
+			entry.Reason = fmt.Sprintf("revision %s has no istiod deployment", revision)
+		}
+		if entry.Orphaned {
+			report.Issues = append(report.Issues, fmt.Sprintf("Revision tag %s is orphaned (%s); retarget it with istioctl tag set %s --revision <revision> --overwrite or remove it", tag, entry.Reason, tag))
+		} else {
+			revisions[revision].Tags = append(revisions[revision].Tags, tag)
+		}
+		tags[tag] = revision
+		report.Tags = append(report.Tags, entry)
+	}
+	sort.Slice(report.Tags, func(i, j int) bool { return report.Tags[i].Tag < report.Tags[j].Tag })
+
+	names := make([]string, 0, len(revisions))
+	for name := range revisions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		report.Revisions = append(report.Revisions, *revisions[name])
+	}
+
+	var namespaces []corev1.Namespace
+	if params.Namespace != "" {
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, params.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get namespace: %v", err),
+					},
+				},
+			}, nil
+		}
+		namespaces = []corev1.Namespace{*ns}
+	} else {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list namespaces: %v", err),
+					},
+				},
+			}, nil
+		}
+		namespaces = list.Items
+	}
+
+	for i := range namespaces {
+		ns := &namespaces[i]
+		injection, hasInjection := ns.Labels["istio-injection"]
+		rev, hasRev := ns.Labels["istio.io/rev"]
+		if !hasInjection && !hasRev {
+			continue
+		}
+
+		// istio-injection takes precedence over istio.io/rev; "enabled" selects the default tag or revision
+		entry := NamespaceRevision{Namespace: ns.Name}
+		target := rev
+		switch {
+		case hasInjection && hasRev:
+			report.Issues = append(report.Issues, fmt.Sprintf("Namespace %s has both istio-injection=%s and istio.io/rev=%s; istio-injection wins, remove one of them", ns.Name, injection, rev))
+			fallthrough
+		case hasInjection:
+			entry.Label = "istio-injection=" + injection
+			target = ""
+			if injection == "enabled" {
+				target = defaultRevision
+			}
+		default:
+			entry.Label = "istio.io/rev=" + rev
+		}
+
+		if target != "" {
+			if revision, ok := tags[target]; ok {
+				entry.ViaTag = target
+				target = revision
+			}
+			if _, ok := revisions[target]; ok {
+				entry.ResolvesTo = target
+			} else if entry.ViaTag != "" {
+				report.Issues = append(report.Issues, fmt.Sprintf("Namespace %s uses tag %s, which points at missing revision %s; new pods fail admission or start without a sidecar", ns.Name, entry.ViaTag, target))
+			} else {
+				report.Issues = append(report.Issues, fmt.Sprintf("Namespace %s selects %s, which is neither a revision nor a tag; new pods will not be injected", ns.Name, target))
+			}
+		}
+
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(ns.Name).List(ctx, metav1.ListOptions{})
+		if err == nil {
+			for j := range pods.Items {
+				pod := &pods.Items[j]
+				if !podHasSidecar(pod) || pod.Status.Phase != corev1.PodRunning {
+					continue
+				}
+				injectedBy := pod.Labels["istio.io/rev"]
+				if injectedBy == "" {
+					injectedBy = defaultRevision
+				}
+				if revision, ok := tags[injectedBy]; ok {
+					injectedBy = revision
+				}
+				if entry.ProxyRevisions == nil {
+					entry.ProxyRevisions = make(map[string]int)
+				}
+				entry.ProxyRevisions[injectedBy]++
+				if entry.ResolvesTo != "" && injectedBy != entry.ResolvesTo {
+					entry.StalePods++
+				}
+				if _, ok := revisions[injectedBy]; !ok {
+					report.Issues = append(report.Issues, fmt.Sprintf("Pod %s/%s was injected by revision %s, which has been removed; its proxy gets no configuration updates", ns.Name, pod.Name, injectedBy))
+				}
+			}
+		}
+		if entry.StalePods > 0 {
+			report.Issues = append(report.Issues, fmt.Sprintf("Namespace %s resolves to revision %s but %d pods run proxies from another revision; restart them to move over", ns.Name, entry.ResolvesTo, entry.StalePods))
+		}
+		report.Namespaces = append(report.Namespaces, entry)
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// webhookService returns the service the first webhook of a configuration calls, if any
+func webhookService(config *admissionregistrationv1.MutatingWebhookConfiguration) *admissionregistrationv1.ServiceReference {
+	for _, webhook := range config.Webhooks {
+		if webhook.ClientConfig.Service != nil {
+			return webhook.ClientConfig.Service
+		}
+	}
+	return nil
+}
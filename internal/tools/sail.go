@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"os/exec"
 	"strings"
 
 	"github.com/sirupsen/logrus"
@@ -23,16 +22,20 @@ type SailStatus struct {
 	Issues    []string `json:"issues,omitempty"`
 }
 
+// installSailOperatorParams are the arguments of install_sail_operator
+type installSailOperatorParams struct {
+	Namespace   string                 `json:"namespace,omitempty"`    // default: sail-operator
+	Version     string                 `json:"version,omitempty"`      // default: latest
+	ReleaseName string                 `json:"release_name,omitempty"` // default: sail-operator
+	Values      map[string]interface{} `json:"values,omitempty"`       // custom helm values
+	Wait        bool                   `json:"wait,omitempty"`         // wait for deployment to be ready
+	Timeout     string                 `json:"timeout,omitempty"`      // timeout for wait (default: 5m)
+	RepoURL     string                 `json:"repo_url,omitempty"`     // chart repository or oci:// registry override
+}
+
 // InstallSailOperator installs the Sail operator using Helm
-func (m *Manager) InstallSailOperator(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace   string                 `json:"namespace,omitempty"`    // default: sail-operator
-		Version     string                 `json:"version,omitempty"`      // default: latest
-		ReleaseName string                 `json:"release_name,omitempty"` // default: sail-operator
-		Values      map[string]interface{} `json:"values,omitempty"`       // custom helm values
-		Wait        bool                   `json:"wait,omitempty"`         // wait for deployment to be ready
-		Timeout     string                 `json:"timeout,omitempty"`      // timeout for wait (default: 5m)
-	}
+func (m *Manager) InstallSailOperator(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params installSailOperatorParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -65,14 +68,15 @@ func (m *Manager) InstallSailOperator(args json.RawMessage) (*CallToolResult, er
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Helm is not available: %v. Please install Helm to use this feature.", err),
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
 				},
 			},
 		}, nil
 	}
 
 	// Add Helm repository
-	if err := m.addSailOperatorHelmRepo(); err != nil {
+	repo := resolveChartRepository(m.config.Helm.Sail, params.RepoURL)
+	if err := m.addHelmRepo(ctx, repo); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -85,7 +89,7 @@ func (m *Manager) InstallSailOperator(args json.RawMessage) (*CallToolResult, er
 	}
 
 	// Install using Helm
-	if err := m.installSailOperatorWithHelm(params.Namespace, params.ReleaseName, params.Version, params.Values, params.Wait, params.Timeout); err != nil {
+	if err := m.installSailOperatorWithHelm(ctx, repo.ChartRef("sail-operator"), params.Namespace, params.ReleaseName, params.Version, params.Values, params.Wait, params.Timeout); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -98,7 +102,7 @@ func (m *Manager) InstallSailOperator(args json.RawMessage) (*CallToolResult, er
 	}
 
 	// Verify installation
-	status, err := m.getSailOperatorStatus(params.Namespace)
+	status, err := m.getSailOperatorStatus(ctx, params.Namespace)
 	if err != nil {
 		logrus.Warnf("Failed to verify Sail operator installation: %v", err)
 	}
@@ -124,14 +128,17 @@ func (m *Manager) InstallSailOperator(args json.RawMessage) (*CallToolResult, er
 	}, nil
 }
 
+// uninstallSailOperatorParams are the arguments of uninstall_sail_operator
+type uninstallSailOperatorParams struct {
+	Namespace   string `json:"namespace,omitempty"`    // default: sail-operator
+	ReleaseName string `json:"release_name,omitempty"` // default: sail-operator
+	Wait        bool   `json:"wait,omitempty"`         // wait for uninstall to complete
+	Timeout     string `json:"timeout,omitempty"`      // timeout for wait (default: 5m)
+}
+
 // UninstallSailOperator uninstalls the Sail operator using Helm
-func (m *Manager) UninstallSailOperator(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace   string `json:"namespace,omitempty"`    // default: sail-operator
-		ReleaseName string `json:"release_name,omitempty"` // default: sail-operator
-		Wait        bool   `json:"wait,omitempty"`         // wait for uninstall to complete
-		Timeout     string `json:"timeout,omitempty"`      // timeout for wait (default: 5m)
-	}
+func (m *Manager) UninstallSailOperator(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params uninstallSailOperatorParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -164,14 +171,14 @@ func (m *Manager) UninstallSailOperator(args json.RawMessage) (*CallToolResult,
 			Content: []interface{}{
 				TextContent{
 					Type: "text",
-					Text: fmt.Sprintf("Helm is not available: %v. Please install Helm to use this feature.", err),
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
 				},
 			},
 		}, nil
 	}
 
 	// Uninstall using Helm
-	if err := m.uninstallSailOperatorWithHelm(params.Namespace, params.ReleaseName, params.Wait, params.Timeout); err != nil {
+	if err := m.uninstallSailOperatorWithHelm(ctx, params.Namespace, params.ReleaseName, params.Wait, params.Timeout); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -193,11 +200,14 @@ func (m *Manager) UninstallSailOperator(args json.RawMessage) (*CallToolResult,
 	}, nil
 }
 
+// checkSailStatusParams are the arguments of check_sail_status
+type checkSailStatusParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: sail-operator
+}
+
 // CheckSailStatus checks the status of Sail operator installation
-func (m *Manager) CheckSailStatus(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace string `json:"namespace,omitempty"` // default: sail-operator
-	}
+func (m *Manager) CheckSailStatus(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params checkSailStatusParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -216,7 +226,7 @@ func (m *Manager) CheckSailStatus(args json.RawMessage) (*CallToolResult, error)
 	}
 
 	// Get status using the helper function
-	status, err := m.getSailOperatorStatus(params.Namespace)
+	status, err := m.getSailOperatorStatus(ctx, params.Namespace)
 	if err != nil {
 		return &CallToolResult{
 			IsError: true,
@@ -240,106 +250,33 @@ func (m *Manager) CheckSailStatus(args json.RawMessage) (*CallToolResult, error)
 	}, nil
 }
 
-// checkHelmAvailable checks if Helm is available in the system
-func (m *Manager) checkHelmAvailable() error {
-	cmd := exec.Command("helm", "version", "--short")
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("helm command not found or not working: %w", err)
-	}
-	return nil
-}
-
-// addSailOperatorHelmRepo adds the Sail operator Helm repository
-func (m *Manager) addSailOperatorHelmRepo() error {
-	// Add the repository
-	cmd := exec.Command("helm", "repo", "add", "sail-operator", "https://istio-ecosystem.github.io/sail-operator")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		// Check if repo already exists
-		if !strings.Contains(string(output), "already exists") {
-			return fmt.Errorf("failed to add sail-operator helm repo: %w, output: %s", err, string(output))
-		}
-	}
-
-	// Update repository
-	cmd = exec.Command("helm", "repo", "update", "sail-operator")
-	if output, err := cmd.CombinedOutput(); err != nil {
-		return fmt.Errorf("failed to update sail-operator helm repo: %w, output: %s", err, string(output))
-	}
-
-	return nil
-}
-
 // installSailOperatorWithHelm installs Sail operator using Helm
-func (m *Manager) installSailOperatorWithHelm(namespace, releaseName, version string, values map[string]interface{}, wait bool, timeout string) error {
-	args := []string{
-		"install", releaseName, "sail-operator/sail-operator",
-		"--namespace", namespace,
-		"--create-namespace",
-	}
-
-	// Add version if specified
-	if version != "" {
-		args = append(args, "--version", version)
-	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
-	}
-
-	// Add custom values if provided
-	if len(values) > 0 {
-		// Convert values to JSON and use --set-json
-		for key, value := range values {
-			valueJSON, err := json.Marshal(value)
-			if err != nil {
-				return fmt.Errorf("failed to marshal value for key %s: %w", key, err)
-			}
-			args = append(args, "--set-json", fmt.Sprintf("%s=%s", key, string(valueJSON)))
-		}
-	}
-
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
+func (m *Manager) installSailOperatorWithHelm(ctx context.Context, chart, namespace, releaseName, version string, values map[string]interface{}, wait bool, timeout string) error {
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:         releaseName,
+		Chart:           chart,
+		Namespace:       namespace,
+		Version:         version,
+		Values:          values,
+		CreateNamespace: true,
+		Wait:            wait,
+		Timeout:         timeout,
+	})
 	if err != nil {
-		return fmt.Errorf("helm install failed: %w, output: %s", err, string(output))
+		return err
 	}
 
-	logrus.Infof("Helm install output: %s", string(output))
+	logrus.Infof("Sail operator installed: %s", release)
 	return nil
 }
 
 // uninstallSailOperatorWithHelm uninstalls Sail operator using Helm
-func (m *Manager) uninstallSailOperatorWithHelm(namespace, releaseName string, wait bool, timeout string) error {
-	args := []string{
-		"uninstall", releaseName,
-		"--namespace", namespace,
-	}
-
-	// Add wait flag
-	if wait {
-		args = append(args, "--wait")
-		if timeout != "" {
-			args = append(args, "--timeout", timeout)
-		}
-	}
-
-	cmd := exec.Command("helm", args...)
-	output, err := cmd.CombinedOutput()
-	if err != nil {
-		return fmt.Errorf("helm uninstall failed: %w, output: %s", err, string(output))
-	}
-
-	logrus.Infof("Helm uninstall output: %s", string(output))
-	return nil
+func (m *Manager) uninstallSailOperatorWithHelm(ctx context.Context, namespace, releaseName string, wait bool, timeout string) error {
+	return m.uninstallHelmRelease(ctx, namespace, releaseName, false, wait, timeout)
 }
 
 // getSailOperatorStatus gets the current status of Sail operator
-func (m *Manager) getSailOperatorStatus(namespace string) (*SailStatus, error) {
-	ctx := context.Background()
+func (m *Manager) getSailOperatorStatus(ctx context.Context, namespace string) (*SailStatus, error) {
 
 	// Try to find the deployment (it might have a different name based on Helm chart)
 	var deployments *appsv1.DeploymentList
@@ -14,6 +14,13 @@ import (
 	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
+const (
+	// sleepImage is the image used by the sleep sample application
+	sleepImage = "curlimages/curl:8.5.0"
+	// httpbinImage is the image used by the httpbin sample application
+	httpbinImage = "quay.io/sridhargaddam/kong/httpbin:latest"
+)
+
 // AppStatus represents the status of a sample application
 type AppStatus struct {
 	Name      string   `json:"name"`
@@ -25,13 +32,17 @@ type AppStatus struct {
 	Issues    []string `json:"issues,omitempty"`
 }
 
+// deploySleepAppParams are the arguments of deploy_sleep_app
+type deploySleepAppParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: default
+	IstioInjection bool   `json:"istio_injection,omitempty"` // default: true
+	Replicas       int32  `json:"replicas,omitempty"`        // default: 1
+	PinDigests     bool   `json:"pin_digests,omitempty"`     // pin the image by digest
+}
+
 // DeploySleepApp deploys the sleep sample application
-func (m *Manager) DeploySleepApp(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace      string `json:"namespace,omitempty"`       // default: default
-		IstioInjection bool   `json:"istio_injection,omitempty"` // default: true
-		Replicas       int32  `json:"replicas,omitempty"`        // default: 1
-	}
+func (m *Manager) DeploySleepApp(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deploySleepAppParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -54,7 +65,7 @@ func (m *Manager) DeploySleepApp(args json.RawMessage) (*CallToolResult, error)
 	}
 	params.IstioInjection = true // Always enable for mesh testing
 
-	ctx := context.Background()
+	ctx = withManagingTool(ctx, "deploy_sleep_app")
 
 	// Create namespace if it doesn't exist and enable Istio injection
 	if err := m.createOrUpdateNamespace(ctx, params.Namespace, params.IstioInjection); err != nil {
@@ -82,8 +93,27 @@ func (m *Manager) DeploySleepApp(args json.RawMessage) (*CallToolResult, error)
 		}, nil
 	}
 
+	// Resolve the image digest if pinning was requested
+	image := sleepImage
+	var pinned []PinnedImage
+	if params.PinDigests {
+		var err error
+		image, err = pinImage(ctx, image, &pinned)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to pin image digest: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Create Deployment
-	if err := m.createSleepDeployment(ctx, params.Namespace, params.Replicas); err != nil {
+	if err := m.createSleepDeployment(ctx, params.Namespace, params.Replicas, image); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -99,20 +129,24 @@ func (m *Manager) DeploySleepApp(args json.RawMessage) (*CallToolResult, error)
 		Content: []interface{}{
 			TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Sleep app deployment initiated in namespace '%s' with %d replicas and Istio injection enabled", params.Namespace, params.Replicas),
+				Text: fmt.Sprintf("Sleep app deployment initiated in namespace '%s' with %d replicas and Istio injection enabled", params.Namespace, params.Replicas) + formatPinnedImages(pinned),
 			},
 		},
 	}, nil
 }
 
+// deployHttpbinAppParams are the arguments of deploy_httpbin_app
+type deployHttpbinAppParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: default
+	IstioInjection bool   `json:"istio_injection,omitempty"` // default: true
+	Replicas       int32  `json:"replicas,omitempty"`        // default: 1
+	ExposeService  bool   `json:"expose_service,omitempty"`  // default: true
+	PinDigests     bool   `json:"pin_digests,omitempty"`     // pin the image by digest
+}
+
 // DeployHttpbinApp deploys the httpbin sample application
-func (m *Manager) DeployHttpbinApp(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace      string `json:"namespace,omitempty"`       // default: default
-		IstioInjection bool   `json:"istio_injection,omitempty"` // default: true
-		Replicas       int32  `json:"replicas,omitempty"`        // default: 1
-		ExposeService  bool   `json:"expose_service,omitempty"`  // default: true
-	}
+func (m *Manager) DeployHttpbinApp(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deployHttpbinAppParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -136,7 +170,7 @@ func (m *Manager) DeployHttpbinApp(args json.RawMessage) (*CallToolResult, error
 	params.IstioInjection = true // Always enable for mesh testing
 	params.ExposeService = true  // Always expose for testing
 
-	ctx := context.Background()
+	ctx = withManagingTool(ctx, "deploy_httpbin_app")
 
 	// Create namespace if it doesn't exist and enable Istio injection
 	if err := m.createOrUpdateNamespace(ctx, params.Namespace, params.IstioInjection); err != nil {
@@ -164,8 +198,27 @@ func (m *Manager) DeployHttpbinApp(args json.RawMessage) (*CallToolResult, error
 		}, nil
 	}
 
+	// Resolve the image digest if pinning was requested
+	image := httpbinImage
+	var pinned []PinnedImage
+	if params.PinDigests {
+		var err error
+		image, err = pinImage(ctx, image, &pinned)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to pin image digest: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
 	// Create Deployment
-	if err := m.createHttpbinDeployment(ctx, params.Namespace, params.Replicas); err != nil {
+	if err := m.createHttpbinDeployment(ctx, params.Namespace, params.Replicas, image); err != nil {
 		return &CallToolResult{
 			IsError: true,
 			Content: []interface{}{
@@ -196,17 +249,20 @@ func (m *Manager) DeployHttpbinApp(args json.RawMessage) (*CallToolResult, error
 		Content: []interface{}{
 			TextContent{
 				Type: "text",
-				Text: fmt.Sprintf("Httpbin app deployment initiated in namespace '%s' with %d replicas, Istio injection enabled, and service exposed", params.Namespace, params.Replicas),
+				Text: fmt.Sprintf("Httpbin app deployment initiated in namespace '%s' with %d replicas, Istio injection enabled, and service exposed", params.Namespace, params.Replicas) + formatPinnedImages(pinned),
 			},
 		},
 	}, nil
 }
 
+// undeploySleepAppParams are the arguments of undeploy_sleep_app
+type undeploySleepAppParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: default
+}
+
 // UndeploySleepApp removes the sleep sample application
-func (m *Manager) UndeploySleepApp(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace string `json:"namespace,omitempty"` // default: default
-	}
+func (m *Manager) UndeploySleepApp(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params undeploySleepAppParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -224,8 +280,6 @@ func (m *Manager) UndeploySleepApp(args json.RawMessage) (*CallToolResult, error
 		params.Namespace = "default"
 	}
 
-	ctx := context.Background()
-
 	// Delete deployment
 	err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Delete(ctx, "sleep", metav1.DeleteOptions{})
 	if err != nil && !errors.IsNotFound(err) {
@@ -248,11 +302,14 @@ func (m *Manager) UndeploySleepApp(args json.RawMessage) (*CallToolResult, error
 	}, nil
 }
 
+// undeployHttpbinAppParams are the arguments of undeploy_httpbin_app
+type undeployHttpbinAppParams struct {
+	Namespace string `json:"namespace,omitempty"` // default: default
+}
+
 // UndeployHttpbinApp removes the httpbin sample application
-func (m *Manager) UndeployHttpbinApp(args json.RawMessage) (*CallToolResult, error) {
-	var params struct {
-		Namespace string `json:"namespace,omitempty"` // default: default
-	}
+func (m *Manager) UndeployHttpbinApp(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params undeployHttpbinAppParams
 
 	if err := json.Unmarshal(args, &params); err != nil {
 		return &CallToolResult{
@@ -270,8 +327,6 @@ func (m *Manager) UndeployHttpbinApp(args json.RawMessage) (*CallToolResult, err
 		params.Namespace = "default"
 	}
 
-	ctx := context.Background()
-
 	// Delete deployment
 	err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Delete(ctx, "httpbin", metav1.DeleteOptions{})
 	if err != nil && !errors.IsNotFound(err) {
@@ -315,6 +370,7 @@ func (m *Manager) createOrUpdateNamespace(ctx context.Context, name string, isti
 		},
 	}
 
+	markManaged(ctx, namespace)
 	_, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Create(ctx, namespace, metav1.CreateOptions{})
 	if errors.IsAlreadyExists(err) {
 		// Update existing namespace with labels
@@ -353,6 +409,7 @@ func (m *Manager) createSleepServiceAccount(ctx context.Context, namespace strin
 		},
 	}
 
+	markManaged(ctx, serviceAccount)
 	_, err := m.k8sClient.Kubernetes.CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create service account: %w", err)
@@ -361,7 +418,7 @@ func (m *Manager) createSleepServiceAccount(ctx context.Context, namespace strin
 	return nil
 }
 
-func (m *Manager) createSleepDeployment(ctx context.Context, namespace string, replicas int32) error {
+func (m *Manager) createSleepDeployment(ctx context.Context, namespace string, replicas int32, image string) error {
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "sleep",
@@ -390,7 +447,7 @@ func (m *Manager) createSleepDeployment(ctx context.Context, namespace string, r
 					Containers: []corev1.Container{
 						{
 							Name:  "sleep",
-							Image: "curlimages/curl:8.5.0",
+							Image: image,
 							Command: []string{
 								"/bin/sleep",
 								"infinity",
@@ -430,6 +487,7 @@ func (m *Manager) createSleepDeployment(ctx context.Context, namespace string, r
 		},
 	}
 
+	markManaged(ctx, deployment)
 	_, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create deployment: %w", err)
@@ -450,6 +508,7 @@ func (m *Manager) createHttpbinServiceAccount(ctx context.Context, namespace str
 		},
 	}
 
+	markManaged(ctx, serviceAccount)
 	_, err := m.k8sClient.Kubernetes.CoreV1().ServiceAccounts(namespace).Create(ctx, serviceAccount, metav1.CreateOptions{})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create service account: %w", err)
@@ -458,7 +517,7 @@ func (m *Manager) createHttpbinServiceAccount(ctx context.Context, namespace str
 	return nil
 }
 
-func (m *Manager) createHttpbinDeployment(ctx context.Context, namespace string, replicas int32) error {
+func (m *Manager) createHttpbinDeployment(ctx context.Context, namespace string, replicas int32, image string) error {
 	deployment := &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "httpbin",
@@ -487,7 +546,7 @@ func (m *Manager) createHttpbinDeployment(ctx context.Context, namespace string,
 					Containers: []corev1.Container{
 						{
 							Name:            "httpbin",
-							Image:           "quay.io/sridhargaddam/kong/httpbin:latest",
+							Image:           image,
 							ImagePullPolicy: corev1.PullIfNotPresent,
 							Command: []string{
 								"gunicorn",
@@ -521,6 +580,7 @@ func (m *Manager) createHttpbinDeployment(ctx context.Context, namespace string,
 		},
 	}
 
+	markManaged(ctx, deployment)
 	_, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Create(ctx, deployment, metav1.CreateOptions{})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create deployment: %w", err)
@@ -554,6 +614,7 @@ func (m *Manager) createHttpbinService(ctx context.Context, namespace string) er
 		},
 	}
 
+	markManaged(ctx, service)
 	_, err := m.k8sClient.Kubernetes.CoreV1().Services(namespace).Create(ctx, service, metav1.CreateOptions{})
 	if err != nil && !errors.IsAlreadyExists(err) {
 		return fmt.Errorf("failed to create service: %w", err)
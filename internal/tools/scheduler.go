@@ -0,0 +1,301 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"meshpilot/internal/config"
+
+	"github.com/robfig/cron/v3"
+	"github.com/sirupsen/logrus"
+)
+
+// maxScheduledOutputBytes caps the tool output stored with each scheduled outcome
+const maxScheduledOutputBytes = 8192
+
+// readOnlyTools lists the tools that may be run by the scheduler because they don't modify the cluster
+var readOnlyTools = map[string]bool{
+	"get_cluster_info":                  true,
+	"check_tool_permissions":            true,
+	"validate_access":                   true,
+	"get_fleet_status":                  true,
+	"check_istio_status":                true,
+	"proxy_status":                      true,
+	"istiod_debug":                      true,
+	"audit_istiod_flags":                true,
+	"watch_mesh_events":                 true,
+	"inspect_revision_tags":             true,
+	"audit_discovery_selectors":         true,
+	"audit_istio_resources":             true,
+	"istio_analyze":                     true,
+	"get_injection_config":              true,
+	"list_managed_resources":            true,
+	"check_install_capacity":            true,
+	"estimate_mesh_cost":                true,
+	"check_cni_chaining":                true,
+	"check_sail_status":                 true,
+	"get_virtual_service":               true,
+	"get_destination_rule":              true,
+	"detect_connection_pool_exhaustion": true,
+	"get_gateway_routes":                true,
+	"get_gateway_status":                true,
+	"get_release_values":                true,
+	"list_available_istio_versions":     true,
+	"test_connectivity":                 true,
+	"test_sleep_to_httpbin":             true,
+	"test_ingress_connectivity":         true,
+	"test_gateway_paths":                true,
+	"sweep_service_ports":               true,
+	"verify_waypoint":                   true,
+	"probe_gateway_tls":                 true,
+	"diagnose_ingress_request":          true,
+	"analyze_response_flags":            true,
+	"get_istiod_logs":                   true,
+	"get_kubernetes_events":             true,
+	"test_header_routing":               true,
+	"get_network_policies":              true,
+	"get_interception_mode":             true,
+	"validate_dual_stack":               true,
+	"inspect_sidecar_annotations":       true,
+	"detect_dataplane_mode":             true,
+	"get_proxy_config":                  true,
+	"envoy_admin_get":                   true,
+	"get_ztunnel_config":                true,
+	"get_monitor_results":               true,
+	"scan_mesh_images":                  true,
+	"test_ext_authz":                    true,
+	"verify_spire_identities":           true,
+	"get_mtls_status":                   true,
+	"get_workload_certificates":         true,
+	"inspect_trust_domain":              true,
+	"get_authorization_policy":          true,
+	"audit_authorization_policies":      true,
+}
+
+// ScheduledOutcome records a single scheduled tool run
+type ScheduledOutcome struct {
+	StartedAt time.Time `json:"started_at"`
+	Duration  string    `json:"duration"`
+	Success   bool      `json:"success"`
+	Failures  []string  `json:"failures,omitempty"`
+	Output    string    `json:"output,omitempty"`
+}
+
+// ScheduleStatus represents a configured schedule and its recorded outcomes
+type ScheduleStatus struct {
+	Name     string             `json:"name"`
+	Cron     string             `json:"cron"`
+	Tool     string             `json:"tool"`
+	Args     json.RawMessage    `json:"args,omitempty"`
+	Error    string             `json:"error,omitempty"` // why the schedule isn't running
+	NextRun  *time.Time         `json:"next_run,omitempty"`
+	Runs     int                `json:"runs"`
+	Failures int                `json:"failures"`
+	Outcomes []ScheduledOutcome `json:"outcomes,omitempty"`
+}
+
+// scheduledCheck is a configured schedule and its in-memory outcome history
+type scheduledCheck struct {
+	cfg     config.ScheduleConfig
+	args    json.RawMessage
+	entryID cron.EntryID
+	err     string
+
+	mu       sync.Mutex
+	runs     int
+	failures int
+	outcomes []ScheduledOutcome
+}
+
+// StartScheduler runs the configured schedules until ctx is cancelled; it only makes sense in server mode
+func (m *Manager) StartScheduler(ctx context.Context) {
+	if len(m.config.Schedules) == 0 {
+		return
+	}
+
+	scheduler := cron.New()
+	m.schedulerMu.Lock()
+	m.scheduler = scheduler
+	for _, cfg := range m.config.Schedules {
+		check := &scheduledCheck{cfg: cfg}
+		m.schedules = append(m.schedules, check)
+
+		if !readOnlyTools[cfg.Tool] {
+			check.err = fmt.Sprintf("tool %q is not a read-only tool and can't be scheduled", cfg.Tool)
+			logrus.Errorf("Schedule %s: %s", cfg.Name, check.err)
+			continue
+		}
+		args, err := json.Marshal(cfg.Args)
+		if err != nil || cfg.Args == nil {
+			args = json.RawMessage("{}")
+		}
+		check.args = args
+
+		id, err := scheduler.AddFunc(cfg.Cron, func() { m.runScheduledCheck(ctx, check) })
+		if err != nil {
+			check.err = fmt.Sprintf("invalid cron expression %q: %v", cfg.Cron, err)
+			logrus.Errorf("Schedule %s: %s", cfg.Name, check.err)
+			continue
+		}
+		check.entryID = id
+	}
+	m.schedulerMu.Unlock()
+
+	scheduler.Start()
+	go func() {
+		<-ctx.Done()
+		<-scheduler.Stop().Done()
+	}()
+}
+
+// runScheduledCheck executes a scheduled tool and records its outcome
+func (m *Manager) runScheduledCheck(ctx context.Context, check *scheduledCheck) {
+	start := time.Now()
+	result, err := m.ExecuteTool(ctx, check.cfg.Tool, check.args)
+
+	outcome := ScheduledOutcome{
+		StartedAt: start,
+		Duration:  time.Since(start).Round(time.Millisecond).String(),
+	}
+	switch {
+	case err != nil:
+		outcome.Failures = []string{err.Error()}
+	case result != nil:
+		outcome.Failures = toolResultFailures(result)
+		if len(result.Content) > 0 {
+			if tc, ok := result.Content[0].(TextContent); ok {
+				outcome.Output = truncateText(tc.Text, maxScheduledOutputBytes)
+			}
+		}
+	}
+	outcome.Success = len(outcome.Failures) == 0
+
+	if !outcome.Success {
+		logrus.Warnf("Scheduled check %s (%s) failed: %v", check.cfg.Name, check.cfg.Tool, outcome.Failures)
+	}
+
+	check.mu.Lock()
+	defer check.mu.Unlock()
+	check.runs++
+	if !outcome.Success {
+		check.failures++
+	}
+	check.outcomes = append(check.outcomes, outcome)
+	if len(check.outcomes) > check.cfg.History {
+		check.outcomes = check.outcomes[len(check.outcomes)-check.cfg.History:]
+	}
+}
+
+// getScheduledResultsParams are the arguments of get_scheduled_results
+type getScheduledResultsParams struct {
+	Name          string `json:"name,omitempty"`           // default: all schedules
+	Limit         int    `json:"limit,omitempty"`          // most recent outcomes per schedule, default: 5
+	FailuresOnly  bool   `json:"failures_only,omitempty"`  // only return failed outcomes
+	IncludeOutput bool   `json:"include_output,omitempty"` // include the tool output of each outcome
+}
+
+// GetScheduledResults returns the recorded outcomes of the scheduled health checks
+func (m *Manager) GetScheduledResults(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getScheduledResultsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Limit == 0 {
+		params.Limit = 5
+	}
+
+	m.schedulerMu.Lock()
+	scheduler := m.scheduler
+	schedules := append([]*scheduledCheck(nil), m.schedules...)
+	m.schedulerMu.Unlock()
+
+	if scheduler == nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "No schedules are running. Configure schedules in the meshpilot config file and run meshpilot in server mode.",
+				},
+			},
+		}, nil
+	}
+
+	statuses := []ScheduleStatus{}
+	for _, check := range schedules {
+		if params.Name != "" && check.cfg.Name != params.Name {
+			continue
+		}
+
+		status := ScheduleStatus{
+			Name:  check.cfg.Name,
+			Cron:  check.cfg.Cron,
+			Tool:  check.cfg.Tool,
+			Args:  check.args,
+			Error: check.err,
+		}
+		if check.err == "" {
+			next := scheduler.Entry(check.entryID).Next
+			if !next.IsZero() {
+				status.NextRun = &next
+			}
+		}
+
+		check.mu.Lock()
+		status.Runs = check.runs
+		status.Failures = check.failures
+		for i := len(check.outcomes) - 1; i >= 0 && len(status.Outcomes) < params.Limit; i-- {
+			outcome := check.outcomes[i]
+			if params.FailuresOnly && outcome.Success {
+				continue
+			}
+			if !params.IncludeOutput {
+				outcome.Output = ""
+			}
+			status.Outcomes = append(status.Outcomes, outcome)
+		}
+		check.mu.Unlock()
+
+		statuses = append(statuses, status)
+	}
+
+	if params.Name != "" && len(statuses) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Schedule %s not found", params.Name),
+				},
+			},
+		}, nil
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+
+	resultJSON, _ := json.MarshalIndent(map[string]interface{}{
+		"schedules": statuses,
+	}, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
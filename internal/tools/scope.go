@@ -0,0 +1,458 @@
+package tools
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// scopeAllNamespaces marks a namespace parameter that spans every namespace when omitted
+	scopeAllNamespaces = "*"
+	// scopeInherited marks a namespace parameter that falls back to another checked parameter when omitted
+	scopeInherited = ""
+)
+
+// namespaceParam describes a namespace parameter of a tool
+type namespaceParam struct {
+	fallback       string   // namespace used when the parameter is omitted
+	narrowedBy     []string // parameters that, when set, make the tool use narrowFallback instead of fallback
+	narrowFallback string   // namespace used when the parameter is omitted and one of narrowedBy is set
+	readOnly       bool     // the tool only reads from this namespace, even if it modifies others
+}
+
+// resolve returns the namespace a call acts on through this parameter, given the call's arguments
+func (p namespaceParam) resolve(name string, values map[string]interface{}) string {
+	if namespace, _ := values[name].(string); namespace != "" {
+		return namespace
+	}
+	for _, other := range p.narrowedBy {
+		switch value := values[other].(type) {
+		case string:
+			if value != "" {
+				return p.narrowFallback
+			}
+		case bool:
+			if value {
+				return p.narrowFallback
+			}
+		}
+	}
+	return p.fallback
+}
+
+// toolScope describes which namespaces a tool touches and whether it writes to them
+type toolScope struct {
+	readOnly    bool                      // never modifies the cluster, so it may inspect protected namespaces
+	clusterWide bool                      // acts on cluster-level resources or state shared by all tenants
+	params      map[string]namespaceParam // namespace parameters, keyed by argument name
+}
+
+// toolScopes classifies every tool for namespace scoping; tools missing here are refused when scoping is enabled
+var toolScopes = map[string]toolScope{
+	"list_contexts":           {readOnly: true},
+	"switch_context":          {clusterWide: true},
+	"configure_kubeconfig":    {clusterWide: true},
+	"get_cluster_info":        {readOnly: true},
+	"check_tool_permissions":  {readOnly: true},
+	"validate_access":         {readOnly: true},
+	"create_dev_cluster":      {clusterWide: true},
+	"delete_dev_cluster":      {clusterWide: true},
+	"install_metallb":         {clusterWide: true},
+	"self_test":               {clusterWide: true},
+	"run_mesh_conformance":    {clusterWide: true},
+	"install_istio":           {clusterWide: true},
+	"uninstall_istio":         {clusterWide: true},
+	"istio_canary_upgrade":    {clusterWide: true},
+	"migrate_istio_install":   {clusterWide: true},
+	"migrate_to_ambient":      {clusterWide: true},
+	"install_sail_operator":   {clusterWide: true},
+	"uninstall_sail_operator": {clusterWide: true},
+	"get_fleet_status": {readOnly: true, params: map[string]namespaceParam{
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"check_istio_status": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "istio-system"},
+	}},
+	"audit_istiod_flags": {readOnly: true, params: map[string]namespaceParam{
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"watch_mesh_events": {readOnly: true, clusterWide: true},
+	"istiod_debug": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: scopeAllNamespaces, readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"proxy_status": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: scopeAllNamespaces, readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"inspect_revision_tags": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces, readOnly: true},
+	}},
+	"audit_discovery_selectors": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"configure_discovery_selectors": {clusterWide: true},
+	"audit_istio_resources": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces, readOnly: true},
+	}},
+	"istio_analyze": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: scopeAllNamespaces, readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"get_injection_config": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"set_injection_template": {clusterWide: true},
+	"preview_injection": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"install_otel_collector": {params: map[string]namespaceParam{
+		"namespace": {fallback: "observability"},
+	}},
+	"configure_tracing": {clusterWide: true},
+	"check_install_capacity": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":         {fallback: "istio-system"},
+		"gateway_namespace": {fallback: "istio-ingress"},
+	}},
+	"estimate_mesh_cost": {readOnly: true, clusterWide: true},
+	"check_cni_chaining": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "istio-system"},
+	}},
+	"detect_cni_race": {params: map[string]namespaceParam{
+		"namespace":     {fallback: scopeAllNamespaces},
+		"cni_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"get_release_values": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces},
+	}},
+	"list_available_istio_versions": {readOnly: true},
+	"get_istio_release_notes": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "istio-system"},
+	}},
+	"check_sail_status": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "sail-operator"},
+	}},
+	"deploy_sleep_app": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"deploy_httpbin_app": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"undeploy_sleep_app": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"undeploy_httpbin_app": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"deploy_bookinfo_app": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"undeploy_bookinfo_app": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"apply_manifest": {clusterWide: true},
+	"cleanup_demo": {params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces},
+	}},
+	"list_managed_resources": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces, readOnly: true},
+	}},
+	"test_connectivity": {readOnly: true, params: map[string]namespaceParam{
+		"source_namespace": {fallback: "default"},
+	}},
+	"test_sleep_to_httpbin": {readOnly: true, params: map[string]namespaceParam{
+		"source_namespace": {fallback: "default"},
+		"target_namespace": {fallback: "default"},
+	}},
+	"test_ingress_connectivity": {readOnly: true, params: map[string]namespaceParam{
+		"gateway_namespace": {fallback: "istio-ingress"},
+	}},
+	"test_gateway_paths": {readOnly: true, params: map[string]namespaceParam{
+		"gateway_namespace": {fallback: "istio-ingress", readOnly: true},
+		"source_namespace":  {fallback: "default"},
+	}},
+	"sweep_service_ports": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":        {fallback: "default"},
+		"source_namespace": {fallback: scopeInherited},
+	}},
+	"probe_gateway_tls": {readOnly: true, params: map[string]namespaceParam{
+		"gateway_namespace": {fallback: "istio-ingress", readOnly: true},
+	}},
+	"diagnose_ingress_request": {readOnly: true, params: map[string]namespaceParam{
+		"gateway_namespace": {fallback: "istio-ingress", readOnly: true},
+		"istio_namespace":   {fallback: "istio-system", readOnly: true},
+	}},
+	"verify_waypoint": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":        {fallback: "default"},
+		"source_namespace": {fallback: scopeInherited},
+	}},
+	"test_header_routing": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":        {fallback: "default"},
+		"source_namespace": {fallback: scopeInherited},
+	}},
+	"generate_traffic": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":        {fallback: "default"},
+		"source_namespace": {fallback: scopeInherited},
+	}},
+	"configure_egress_routing": {params: map[string]namespaceParam{
+		"namespace":         {fallback: "default"},
+		"gateway_namespace": {fallback: "istio-system"},
+		"istio_namespace":   {fallback: "istio-system", readOnly: true},
+		"source_namespace":  {fallback: scopeInherited, readOnly: true},
+	}},
+	"benchmark_mesh_overhead": {params: map[string]namespaceParam{
+		"namespace": {fallback: "meshpilot-bench"},
+	}},
+	"start_monitor": {params: map[string]namespaceParam{
+		"source_namespace": {fallback: "default"},
+	}},
+	"stop_monitor":          {readOnly: true},
+	"get_monitor_results":   {readOnly: true},
+	"get_scheduled_results": {readOnly: true},
+	"traffic_shift": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"create_virtual_service": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_virtual_service": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"delete_virtual_service": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"create_destination_rule": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_destination_rule": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"delete_destination_rule": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"detect_connection_pool_exhaustion": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	// The self-signed certificate of an Istio Gateway is stored in the gateway namespace
+	"expose_service_via_gateway": {params: map[string]namespaceParam{
+		"namespace":         {fallback: "default"},
+		"gateway_namespace": {fallback: "istio-ingress"},
+	}},
+	// A route only references its parent Gateway, which may live in the gateway namespace
+	"create_gateway_route": {params: map[string]namespaceParam{
+		"namespace":         {fallback: "default"},
+		"gateway_namespace": {fallback: "istio-ingress", readOnly: true},
+	}},
+	"get_gateway_routes": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"delete_gateway_route": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_gateway_status": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces, readOnly: true},
+	}},
+	"get_pod_logs": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_istio_proxy_logs": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_istiod_logs": {readOnly: true, params: map[string]namespaceParam{
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"analyze_response_flags": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: "default", readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"get_kubernetes_events": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: "default", readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"exec_pod_command": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_iptables_rules": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"capture_packets": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_proxy_config": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"envoy_admin_get": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"configure_dns_proxying": {clusterWide: true},
+	"tune_proxy":             {clusterWide: true},
+	"audit_sidecar_startup":  {clusterWide: true},
+	"diagnose_job_sidecars": {params: map[string]namespaceParam{
+		"namespace":       {fallback: scopeAllNamespaces},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"configure_traffic_exclusions": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_interception_mode": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"inspect_sidecar_annotations": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"detect_dataplane_mode": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: scopeAllNamespaces},
+	}},
+	// ztunnel holds the workloads of every namespace on its node
+	"get_ztunnel_config": {readOnly: true, clusterWide: true},
+	"get_network_policies": {readOnly: true, params: map[string]namespaceParam{
+		// A pod or an analysis looks at one namespace, default unless set; a plain listing spans them all
+		"namespace":             {fallback: scopeAllNamespaces, narrowedBy: []string{"pod_name", "analyze"}, narrowFallback: "default"},
+		"source_namespace":      {fallback: scopeInherited},
+		"destination_namespace": {fallback: scopeInherited},
+	}},
+	"generate_network_policy": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"trace_network_path": {readOnly: true, params: map[string]namespaceParam{
+		"source_namespace": {fallback: "default"},
+		"target_namespace": {fallback: "default"},
+	}},
+	"validate_dual_stack": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":         {fallback: scopeAllNamespaces, readOnly: true},
+		"istio_namespace":   {fallback: "istio-system", readOnly: true},
+		"service_namespace": {fallback: "default", readOnly: true},
+		"source_namespace":  {fallback: "default", readOnly: true},
+	}},
+	"check_clock_skew": {params: map[string]namespaceParam{
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+		"debug_namespace": {fallback: "default"},
+	}},
+	"diagnose_pod_node_network": {params: map[string]namespaceParam{
+		"namespace":       {fallback: "default", readOnly: true},
+		"debug_namespace": {fallback: "default"},
+	}},
+	"scan_mesh_images": {readOnly: true},
+	"setup_ext_authz": {params: map[string]namespaceParam{
+		"namespace":       {fallback: "default"},
+		"istio_namespace": {fallback: "istio-system"},
+	}},
+	"test_ext_authz": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":        {fallback: "default", readOnly: true},
+		"source_namespace": {fallback: scopeInherited, readOnly: true},
+		"istio_namespace":  {fallback: "istio-system", readOnly: true},
+	}},
+	"install_spire":         {clusterWide: true},
+	"configure_istio_spire": {clusterWide: true},
+	"verify_spire_identities": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: "default", readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"set_mtls_mode": {params: map[string]namespaceParam{
+		"namespace":       {fallback: "istio-system"},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"get_mtls_status": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: scopeAllNamespaces, readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"get_workload_certificates": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"inspect_trust_domain": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: "default", readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	// The capture attaches an ephemeral container to the destination pod
+	"check_mtls_between": {params: map[string]namespaceParam{
+		"namespace":             {fallback: "default"},
+		"source_namespace":      {fallback: scopeInherited, readOnly: true},
+		"destination_namespace": {fallback: scopeInherited},
+		"istio_namespace":       {fallback: "istio-system", readOnly: true},
+	}},
+	"create_authorization_policy": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"get_authorization_policy": {readOnly: true, params: map[string]namespaceParam{
+		"namespace": {fallback: "default", readOnly: true},
+	}},
+	"delete_authorization_policy": {params: map[string]namespaceParam{
+		"namespace": {fallback: "default"},
+	}},
+	"audit_authorization_policies": {readOnly: true, params: map[string]namespaceParam{
+		"namespace":       {fallback: scopeAllNamespaces, readOnly: true},
+		"istio_namespace": {fallback: "istio-system", readOnly: true},
+	}},
+	"list_history":          {readOnly: true},
+	"get_result":            {readOnly: true},
+	"compare_with_snapshot": {readOnly: true},
+}
+
+// checkContextScope refuses a per-call context outside the configured allowlist when tools are scoped, since
+// another cluster is as much an escape from the scope as switch_context
+func (m *Manager) checkContextScope(kubeContext string) error {
+	scope := m.config.Scope
+	if !scope.Enabled() || containsString(scope.Contexts, kubeContext) {
+		return nil
+	}
+	if len(scope.Contexts) == 0 {
+		return fmt.Errorf("context %s cannot be selected when tools are scoped to namespaces; add it to scope.contexts to allow it", kubeContext)
+	}
+	return fmt.Errorf("context %s is outside the configured contexts (%s)", kubeContext, strings.Join(scope.Contexts, ", "))
+}
+
+// checkScope refuses tool calls that reach outside the configured namespaces or modify protected namespaces
+func (m *Manager) checkScope(toolName string, args json.RawMessage) error {
+	scope := m.config.Scope
+	if !scope.Enabled() {
+		return nil
+	}
+
+	ts, ok := toolScopes[toolName]
+	if !ok {
+		return fmt.Errorf("tool %s is not available when tools are scoped to namespaces", toolName)
+	}
+	mutating := !ts.readOnly
+	if ts.clusterWide {
+		return fmt.Errorf("tool %s acts on cluster-wide resources and is not available when tools are scoped to namespaces %s",
+			toolName, strings.Join(scope.Namespaces, ", "))
+	}
+
+	var values map[string]interface{}
+	if len(args) > 0 {
+		if err := json.Unmarshal(args, &values); err != nil {
+			// The namespaces can't be checked, so the call must not run
+			return fmt.Errorf("invalid arguments: %v", err)
+		}
+	}
+
+	names := make([]string, 0, len(ts.params))
+	for name := range ts.params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		param := ts.params[name]
+		namespace := param.resolve(name, values)
+		switch namespace {
+		case scopeInherited:
+			continue
+		case scopeAllNamespaces:
+			return fmt.Errorf("tool %s spans all namespaces unless %s is set; set it to one of %s",
+				toolName, name, strings.Join(scope.Namespaces, ", "))
+		}
+
+		protected := containsString(scope.ProtectedNamespaces, namespace)
+		if protected && mutating && !param.readOnly {
+			return fmt.Errorf("tool %s may modify namespace %s, which is protected", toolName, namespace)
+		}
+		if !protected && !containsString(scope.Namespaces, namespace) {
+			return fmt.Errorf("namespace %s (%s) is outside the configured scope (%s)",
+				namespace, name, strings.Join(scope.Namespaces, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package tools
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"meshpilot/internal/config"
+)
+
+func TestCheckScope(t *testing.T) {
+	scoped := config.ScopeConfig{
+		Namespaces:          []string{"team-a", "default"},
+		ProtectedNamespaces: []string{"istio-system"},
+	}
+	tests := []struct {
+		name    string
+		scope   config.ScopeConfig
+		tool    string
+		args    string
+		wantErr string // substring of the refusal, empty when the call is allowed
+	}{
+		{name: "scoping disabled", tool: "undeploy_sleep_app", args: `{"namespace": "kube-system"}`},
+		{name: "namespace in scope", scope: scoped, tool: "undeploy_sleep_app", args: `{"namespace": "team-a"}`},
+		{name: "defaulted namespace in scope", scope: scoped, tool: "undeploy_sleep_app", args: `{}`},
+		{name: "namespace outside scope", scope: scoped, tool: "undeploy_sleep_app", args: `{"namespace": "team-b"}`,
+			wantErr: "namespace team-b (namespace) is outside the configured scope"},
+		{name: "defaulted namespace outside scope", scope: config.ScopeConfig{Namespaces: []string{"team-a"}}, tool: "undeploy_sleep_app", args: `{}`,
+			wantErr: "namespace default (namespace) is outside the configured scope"},
+		{name: "protected namespace modified", scope: scoped, tool: "undeploy_sleep_app", args: `{"namespace": "istio-system"}`,
+			wantErr: "may modify namespace istio-system, which is protected"},
+		{name: "protected namespace read", scope: scoped, tool: "get_pod_logs", args: `{"namespace": "istio-system", "pod_name": "istiod"}`},
+		{name: "cluster-wide tool", scope: scoped, tool: "install_istio", args: `{}`,
+			wantErr: "acts on cluster-wide resources"},
+		{name: "unclassified tool", scope: scoped, tool: "no_such_tool", args: `{}`,
+			wantErr: "is not available when tools are scoped"},
+		{name: "unparsable arguments", scope: scoped, tool: "undeploy_sleep_app", args: `[]`,
+			wantErr: "invalid arguments"},
+		{name: "listing all namespaces", scope: scoped, tool: "get_network_policies", args: `{}`,
+			wantErr: "spans all namespaces unless namespace is set"},
+		{name: "listing one namespace", scope: scoped, tool: "get_network_policies", args: `{"namespace": "team-a"}`},
+		{name: "pod narrows to default", scope: scoped, tool: "get_network_policies", args: `{"pod_name": "web"}`},
+		{name: "analysis narrows to default", scope: config.ScopeConfig{Namespaces: []string{"team-a"}}, tool: "get_network_policies", args: `{"analyze": true}`,
+			wantErr: "namespace default (namespace) is outside the configured scope"},
+		{name: "unset analysis lists all", scope: scoped, tool: "get_network_policies", args: `{"analyze": false}`,
+			wantErr: "spans all namespaces"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manager{config: &config.Config{Scope: tt.scope}}
+			err := m.checkScope(tt.tool, json.RawMessage(tt.args))
+			switch {
+			case tt.wantErr == "" && err != nil:
+				t.Fatalf("checkScope(%s, %s) refused: %v", tt.tool, tt.args, err)
+			case tt.wantErr != "" && err == nil:
+				t.Fatalf("checkScope(%s, %s) allowed, want refusal containing %q", tt.tool, tt.args, tt.wantErr)
+			case tt.wantErr != "" && !strings.Contains(err.Error(), tt.wantErr):
+				t.Fatalf("checkScope(%s, %s) = %v, want refusal containing %q", tt.tool, tt.args, err, tt.wantErr)
+			}
+		})
+	}
+}
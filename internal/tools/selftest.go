@@ -0,0 +1,320 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// SelfTestStage is the outcome of one step of the self test
+type SelfTestStage struct {
+	Name     string   `json:"name"`
+	Tool     string   `json:"tool,omitempty"`
+	Status   string   `json:"status"` // passed, failed or skipped
+	Duration string   `json:"duration,omitempty"`
+	Details  []string `json:"details,omitempty"`
+}
+
+// SelfTestReport is the result of a full self test run
+type SelfTestReport struct {
+	Passed    bool            `json:"passed"`
+	Cluster   string          `json:"cluster,omitempty"` // dev cluster provisioned for the run
+	Namespace string          `json:"namespace"`
+	Summary   map[string]int  `json:"summary"`
+	Stages    []SelfTestStage `json:"stages"`
+	Issues    []string        `json:"issues,omitempty"`
+	Duration  string          `json:"duration"`
+	Timestamp time.Time       `json:"timestamp"`
+}
+
+// selfTestRun tracks the stages of a self test as they execute
+type selfTestRun struct {
+	ctx    context.Context
+	m      *Manager
+	report *SelfTestReport
+	failed bool
+}
+
+// tool runs a tool as a stage; once a stage fails, later non-teardown stages are skipped
+func (r *selfTestRun) tool(name, tool string, args map[string]interface{}, teardown bool) bool {
+	stage := SelfTestStage{Name: name, Tool: tool}
+	if r.failed && !teardown {
+		stage.Status = "skipped"
+		r.add(stage)
+		return false
+	}
+
+	// Teardown still runs when the caller gave up, so the test does not leave resources behind
+	ctx := r.ctx
+	if teardown {
+		ctx = context.WithoutCancel(ctx)
+	}
+	argsJSON, _ := json.Marshal(args)
+	start := time.Now()
+	result, err := r.m.ExecuteTool(ctx, tool, argsJSON)
+	stage.Duration = time.Since(start).Round(time.Millisecond).String()
+
+	switch {
+	case err != nil:
+		stage.Details = []string{err.Error()}
+	case result != nil:
+		stage.Details = toolResultFailures(result)
+	}
+	return r.finish(stage, teardown)
+}
+
+// step runs an internal check as a stage
+func (r *selfTestRun) step(name string, teardown bool, fn func() error) bool {
+	stage := SelfTestStage{Name: name}
+	if r.failed && !teardown {
+		stage.Status = "skipped"
+		r.add(stage)
+		return false
+	}
+
+	start := time.Now()
+	err := fn()
+	stage.Duration = time.Since(start).Round(time.Millisecond).String()
+	if err != nil {
+		stage.Details = []string{err.Error()}
+	}
+	return r.finish(stage, teardown)
+}
+
+// finish records a stage as passed or failed from its failure details
+func (r *selfTestRun) finish(stage SelfTestStage, teardown bool) bool {
+	if len(stage.Details) == 0 {
+		stage.Status = "passed"
+		r.add(stage)
+		return true
+	}
+	stage.Status = "failed"
+	if !teardown {
+		r.failed = true
+	}
+	for _, detail := range stage.Details {
+		r.report.Issues = append(r.report.Issues, fmt.Sprintf("%s: %s", stage.Name, truncateText(detail, 500)))
+	}
+	r.add(stage)
+	return false
+}
+
+// skip records a stage that doesn't apply to this run
+func (r *selfTestRun) skip(name, tool, reason string) {
+	r.add(SelfTestStage{Name: name, Tool: tool, Status: "skipped", Details: []string{reason}})
+}
+
+func (r *selfTestRun) add(stage SelfTestStage) {
+	r.report.Stages = append(r.report.Stages, stage)
+	r.report.Summary[stage.Status]++
+}
+
+// selfTestParams are the arguments of self_test
+type selfTestParams struct {
+	ProvisionCluster bool   `json:"provision_cluster,omitempty"` // create a disposable dev cluster first
+	ClusterName      string `json:"cluster_name,omitempty"`      // default: meshpilot-selftest
+	Provider         string `json:"provider,omitempty"`          // kind or minikube, default: kind
+	IstioVersion     string `json:"istio_version,omitempty"`     // default: latest chart version
+	Namespace        string `json:"namespace,omitempty"`         // default: meshpilot-selftest
+	Keep             bool   `json:"keep,omitempty"`              // leave everything in place for inspection
+	Timeout          string `json:"timeout,omitempty"`           // wait for installs and pods, default: 5m
+}
+
+// SelfTest exercises the toolchain end to end: optionally provision a dev cluster, install Istio,
+// deploy the sample apps, run connectivity and diagnostics, then tear everything down
+func (m *Manager) SelfTest(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params selfTestParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.ClusterName == "" {
+		params.ClusterName = "meshpilot-selftest"
+	}
+	if params.Provider == "" {
+		params.Provider = "kind"
+	}
+	if params.Namespace == "" {
+		params.Namespace = "meshpilot-selftest"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	started := time.Now()
+	report := &SelfTestReport{
+		Namespace: params.Namespace,
+		Summary:   make(map[string]int),
+		Timestamp: started,
+	}
+	run := &selfTestRun{ctx: ctx, m: m, report: report}
+
+	// Setup
+	provisioned := false
+	if params.ProvisionCluster {
+		report.Cluster = params.ClusterName
+		provisioned = run.tool("provision_cluster", "create_dev_cluster", map[string]interface{}{
+			"name":     params.ClusterName,
+			"provider": params.Provider,
+		}, false)
+	} else {
+		// Other contexts in the kubeconfig don't matter for this run
+		accessArgs := map[string]interface{}{}
+		if raw, err := clientcmd.NewDefaultClientConfigLoadingRules().Load(); err == nil && raw.CurrentContext != "" {
+			accessArgs["context"] = raw.CurrentContext
+		}
+		run.tool("validate_access", "validate_access", accessArgs, false)
+	}
+	if m.k8sClient == nil && !run.failed {
+		run.step("connect", false, func() error {
+			return fmt.Errorf("no Kubernetes client is available")
+		})
+	}
+
+	// Reuse an existing control plane rather than replacing it, and leave it in place afterwards
+	installedIstio := false
+	existingIstio := !run.failed && m.istiodInstalled(ctx)
+	if existingIstio {
+		run.skip("install_istio", "install_istio", "Istio is already installed in istio-system; reusing it and leaving it in place")
+	} else {
+		installArgs := map[string]interface{}{"wait": true, "timeout": params.Timeout}
+		if params.IstioVersion != "" {
+			installArgs["version"] = params.IstioVersion
+		}
+		installedIstio = run.tool("install_istio", "install_istio", installArgs, false)
+	}
+	run.tool("check_istio_status", "check_istio_status", map[string]interface{}{}, false)
+
+	// Only delete the test namespace afterwards if this run created it
+	createdNamespace := false
+	if !run.failed {
+		_, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, params.Namespace, metav1.GetOptions{})
+		createdNamespace = errors.IsNotFound(err)
+	}
+	// A failed deploy may still have created resources, so anything attempted is torn down
+	nsArgs := map[string]interface{}{"namespace": params.Namespace}
+	deployedSleep := !run.failed
+	run.tool("deploy_sleep_app", "deploy_sleep_app", nsArgs, false)
+	deployedHttpbin := !run.failed
+	run.tool("deploy_httpbin_app", "deploy_httpbin_app", nsArgs, false)
+	run.step("wait_for_sample_apps", false, func() error {
+		return m.waitForPodsReady(ctx, params.Namespace, []string{"app=sleep", "app=httpbin"}, timeout)
+	})
+
+	// Connectivity and diagnostics
+	run.tool("test_sleep_to_httpbin", "test_sleep_to_httpbin", map[string]interface{}{
+		"source_namespace": params.Namespace,
+		"target_namespace": params.Namespace,
+	}, false)
+	run.tool("get_interception_mode", "get_interception_mode", nsArgs, false)
+	run.tool("get_network_policies", "get_network_policies", nsArgs, false)
+
+	// Teardown runs even after failures so the cluster is left as it was found
+	switch {
+	case params.Keep:
+		run.skip("teardown", "", "keep is set; the test namespace, Istio and any dev cluster were left in place")
+	case provisioned:
+		run.tool("delete_cluster", "delete_dev_cluster", map[string]interface{}{
+			"name":     params.ClusterName,
+			"provider": params.Provider,
+		}, true)
+	case m.k8sClient != nil:
+		if deployedHttpbin {
+			run.tool("undeploy_httpbin_app", "undeploy_httpbin_app", nsArgs, true)
+		}
+		if deployedSleep {
+			run.tool("undeploy_sleep_app", "undeploy_sleep_app", nsArgs, true)
+		}
+		if createdNamespace {
+			run.step("delete_namespace", true, func() error {
+				err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Delete(ctx, params.Namespace, metav1.DeleteOptions{})
+				if errors.IsNotFound(err) {
+					return nil
+				}
+				return err
+			})
+		}
+		// This run installed Istio itself, so removing it is not what the guardrails protect against
+		if installedIstio {
+			run.tool("uninstall_istio", "uninstall_istio", map[string]interface{}{"wait": true, "timeout": params.Timeout, "force": true}, true)
+		}
+	}
+
+	report.Passed = len(report.Issues) == 0
+	report.Duration = time.Since(started).Round(time.Second).String()
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// istiodInstalled reports whether an istiod deployment exists in istio-system
+func (m *Manager) istiodInstalled(ctx context.Context) bool {
+	if m.k8sClient == nil {
+		return false
+	}
+	_, err := m.k8sClient.Kubernetes.AppsV1().Deployments("istio-system").Get(ctx, "istiod", metav1.GetOptions{})
+	return err == nil
+}
+
+// waitForPodsReady waits until at least one pod matches each selector and all matching pods are ready
+func (m *Manager) waitForPodsReady(ctx context.Context, namespace string, selectors []string, timeout time.Duration) error {
+	pending := ""
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		for _, selector := range selectors {
+			pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+			if err != nil {
+				return false, err
+			}
+			if len(pods.Items) == 0 {
+				pending = fmt.Sprintf("no pods match %s", selector)
+				return false, nil
+			}
+			for i := range pods.Items {
+				if !isPodReady(&pods.Items[i]) {
+					pending = fmt.Sprintf("pod %s is not ready", pods.Items[i].Name)
+					return false, nil
+				}
+			}
+		}
+		return true, nil
+	})
+	if err != nil && pending != "" {
+		return fmt.Errorf("timed out after %s: %s", timeout, pending)
+	}
+	return err
+}
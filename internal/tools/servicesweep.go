@@ -0,0 +1,378 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+// Port probe states reported by sweep_service_ports
+const (
+	portListening = "listening"
+	portRefused   = "refused"
+	portFiltered  = "filtered"
+	portError     = "error"
+	portSkipped   = "skipped"
+)
+
+// ServicePortProbe is the outcome of probing one declared port of a service
+type ServicePortProbe struct {
+	Service    string `json:"service"`
+	Port       int32  `json:"port"`
+	Name       string `json:"name,omitempty"`
+	Protocol   string `json:"protocol"`
+	TargetPort string `json:"target_port"`
+	State      string `json:"state"` // listening, refused, filtered, error or skipped
+	HTTPCode   string `json:"http_code,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	Endpoints  int    `json:"ready_endpoints"`
+}
+
+// ServicePortSweep reports the reachability of every declared port of the services in a namespace
+type ServicePortSweep struct {
+	Namespace string             `json:"namespace"`
+	Source    PodInfo            `json:"source"`
+	Sidecar   bool               `json:"source_has_sidecar"`
+	Probes    []ServicePortProbe `json:"probes"`
+	Summary   map[string]int     `json:"summary"`
+	Issues    []string           `json:"issues,omitempty"`
+	Notes     []string           `json:"notes,omitempty"`
+	Timestamp time.Time          `json:"timestamp"`
+}
+
+// sweepServicePortsParams are the arguments of sweep_service_ports
+type sweepServicePortsParams struct {
+	Namespace       string   `json:"namespace,omitempty"`        // default: default
+	Services        []string `json:"services,omitempty"`         // default: all services in the namespace
+	SourcePod       string   `json:"source_pod,omitempty"`       // default: first app=sleep pod
+	SourceNamespace string   `json:"source_namespace,omitempty"` // default: namespace
+	SourceContainer string   `json:"source_container,omitempty"` // default: first non-proxy container
+	Timeout         int      `json:"timeout,omitempty"`          // seconds per probe, default: 3
+}
+
+// SweepServicePorts probes every declared port of the services in a namespace from a test pod and
+// checks each targetPort against the ports the selected containers expose
+func (m *Manager) SweepServicePorts(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params sweepServicePortsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = 3
+	}
+
+	var services []corev1.Service
+	if len(params.Services) > 0 {
+		for _, name := range params.Services {
+			svc, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return &CallToolResult{
+					IsError: true,
+					Content: []interface{}{
+						TextContent{
+							Type: "text",
+							Text: fmt.Sprintf("Failed to get service %s: %v", name, err),
+						},
+					},
+				}, nil
+			}
+			services = append(services, *svc)
+		}
+	} else {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list services: %v", err),
+					},
+				},
+			}, nil
+		}
+		services = list.Items
+	}
+
+	source, err := m.findWaypointSource(ctx, params.SourceNamespace, params.SourcePod)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if params.SourceContainer == "" {
+		for _, container := range source.Spec.Containers {
+			if container.Name != "istio-proxy" {
+				params.SourceContainer = container.Name
+				break
+			}
+		}
+	}
+
+	result := &ServicePortSweep{
+		Namespace: params.Namespace,
+		Source: PodInfo{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			IP:        source.Status.PodIP,
+			Node:      source.Spec.NodeName,
+		},
+		Sidecar:   podHasSidecar(source),
+		Summary:   make(map[string]int),
+		Timestamp: time.Now(),
+	}
+	if result.Sidecar {
+		result.Notes = append(result.Notes, "The source pod has a sidecar, so connections terminate at its Envoy first; refused and filtered states are inferred from the upstream failure Envoy reports")
+	}
+
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	for _, svc := range services {
+		var backends []corev1.Pod
+		if len(svc.Spec.Selector) > 0 {
+			pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(svc.Namespace).List(ctx, metav1.ListOptions{
+				LabelSelector: labels.SelectorFromSet(svc.Spec.Selector).String(),
+			})
+			if err == nil {
+				backends = pods.Items
+			}
+		}
+		readyEndpoints := m.readyEndpointsByPort(ctx, svc)
+
+		for _, port := range svc.Spec.Ports {
+			probe := ServicePortProbe{
+				Service:    svc.Name,
+				Port:       port.Port,
+				Name:       port.Name,
+				Protocol:   string(port.Protocol),
+				TargetPort: port.TargetPort.String(),
+				Endpoints:  readyEndpoints[port.Name],
+			}
+			if port.TargetPort.IntValue() == 0 && port.TargetPort.Type == intstr.Int {
+				probe.TargetPort = strconv.Itoa(int(port.Port))
+			}
+
+			// A targetPort no container exposes is the usual reason a service refuses connections
+			if mismatch := targetPortMismatch(port, backends); mismatch != "" {
+				result.Issues = append(result.Issues, fmt.Sprintf("%s port %d: %s", svc.Name, port.Port, mismatch))
+			}
+
+			switch {
+			case svc.Spec.Type == corev1.ServiceTypeExternalName:
+				probe.State = portSkipped
+				probe.Detail = "ExternalName services have no virtual IP"
+			case port.Protocol != "" && port.Protocol != corev1.ProtocolTCP:
+				probe.State = portSkipped
+				probe.Detail = fmt.Sprintf("%s ports can't be probed with a TCP connection", port.Protocol)
+			default:
+				host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+				m.probeServicePort(ctx, source, params.SourceContainer, host, port.Port, params.Timeout, result.Sidecar, &probe)
+			}
+
+			if probe.State != portListening && probe.State != portSkipped && probe.Endpoints == 0 && len(svc.Spec.Selector) > 0 {
+				probe.Detail = strings.TrimPrefix(probe.Detail+"; the service has no ready endpoints for this port", "; ")
+			}
+			result.Summary[probe.State]++
+			result.Probes = append(result.Probes, probe)
+		}
+	}
+
+	for _, probe := range result.Probes {
+		if probe.State == portRefused || probe.State == portFiltered {
+			result.Issues = append(result.Issues, fmt.Sprintf("%s port %d is %s from %s/%s", probe.Service, probe.Port, probe.State, source.Namespace, source.Name))
+		}
+	}
+	if len(result.Probes) == 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("No service ports found in namespace %s", params.Namespace))
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// probeServicePort connects to a service port with curl from the source pod and records the outcome
+func (m *Manager) probeServicePort(ctx context.Context, source *corev1.Pod, container, host string, port int32, timeout int, viaSidecar bool, probe *ServicePortProbe) {
+	// curl's exit code tells a refused connection (7) from a timeout (28); the shell keeps a failing
+	// curl from turning into an exec error
+	script := fmt.Sprintf("curl -s -o - -w '\\nHTTP_CODE:%%{http_code} CONNECTED:%%{time_connect}' --connect-timeout %d --max-time %d http://%s:%d/ </dev/null; echo \" EXIT:$?\"",
+		timeout, timeout, host, port)
+	output, err := m.execCommandInPod(ctx, source.Namespace, source.Name, container, []string{"sh", "-c", script})
+	if err != nil {
+		probe.State = portError
+		probe.Detail = fmt.Sprintf("Probe failed: %v", err)
+		return
+	}
+
+	body := output
+	var httpCode, exit string
+	connected := false
+	if idx := strings.LastIndex(output, "HTTP_CODE:"); idx >= 0 {
+		body = output[:idx]
+		for _, field := range strings.Fields(output[idx:]) {
+			switch {
+			case strings.HasPrefix(field, "HTTP_CODE:"):
+				httpCode = strings.TrimPrefix(field, "HTTP_CODE:")
+			case strings.HasPrefix(field, "CONNECTED:"):
+				seconds, _ := strconv.ParseFloat(strings.TrimPrefix(field, "CONNECTED:"), 64)
+				connected = seconds > 0
+			case strings.HasPrefix(field, "EXIT:"):
+				exit = strings.TrimPrefix(field, "EXIT:")
+			}
+		}
+	} else if idx := strings.LastIndex(output, "EXIT:"); idx >= 0 {
+		exit = strings.TrimSpace(output[idx+len("EXIT:"):])
+	}
+	if httpCode != "" && httpCode != "000" {
+		probe.HTTPCode = httpCode
+	}
+
+	probe.State, probe.Detail = classifyPortProbe(exit, httpCode, connected, body, viaSidecar)
+}
+
+// classifyPortProbe maps curl's exit code, status and body to a port state
+func classifyPortProbe(exit, httpCode string, connected bool, body string, viaSidecar bool) (string, string) {
+	lower := strings.ToLower(body)
+
+	// Envoy answers 503 with the upstream failure when it can't reach the endpoint
+	if strings.Contains(lower, "upstream connect error") || strings.Contains(lower, "connection failure") {
+		switch {
+		case strings.Contains(lower, "refused") || strings.Contains(lower, "error: 111"):
+			return portRefused, "The endpoint refused the connection (reported by Envoy)"
+		case strings.Contains(lower, "timeout") || strings.Contains(lower, "error: 110"):
+			return portFiltered, "The connection to the endpoint timed out (reported by Envoy)"
+		default:
+			return portRefused, "Envoy could not connect to the endpoint: " + strings.TrimSpace(body)
+		}
+	}
+
+	switch exit {
+	case "0":
+		return portListening, fmt.Sprintf("Answered HTTP %s", httpCode)
+	case "6":
+		return portError, "The service name did not resolve"
+	case "7":
+		return portRefused, "Connection refused"
+	case "28":
+		if connected && !viaSidecar {
+			return portListening, "Accepted the connection but sent no HTTP response; the port likely speaks another protocol"
+		}
+		return portFiltered, "Timed out without a response; the traffic is likely dropped by a network policy or firewall"
+	case "52", "56":
+		if viaSidecar {
+			return portRefused, "The sidecar closed the connection without a response, which is how it reports an upstream connection failure on TCP ports"
+		}
+		return portListening, "Accepted the connection but closed it without an HTTP response; the port likely speaks another protocol"
+	case "1", "8", "16", "35", "60":
+		return portListening, fmt.Sprintf("Accepted the connection but did not speak plain HTTP (curl exit %s)", exit)
+	case "":
+		return portError, "The probe produced no result; the source container needs sh and curl"
+	default:
+		return portError, fmt.Sprintf("curl exited with code %s", exit)
+	}
+}
+
+// targetPortMismatch describes a service port whose targetPort is not exposed by any selected container
+func targetPortMismatch(port corev1.ServicePort, backends []corev1.Pod) string {
+	if len(backends) == 0 {
+		return ""
+	}
+
+	var declared []string
+	for _, pod := range backends {
+		for _, container := range pod.Spec.Containers {
+			if container.Name == "istio-proxy" {
+				continue
+			}
+			for _, containerPort := range container.Ports {
+				if port.TargetPort.Type == intstr.String {
+					if containerPort.Name == port.TargetPort.StrVal {
+						return ""
+					}
+				} else {
+					target := port.TargetPort.IntVal
+					if target == 0 {
+						target = port.Port
+					}
+					if containerPort.ContainerPort == target {
+						return ""
+					}
+				}
+				entry := strconv.Itoa(int(containerPort.ContainerPort))
+				if containerPort.Name != "" {
+					entry = containerPort.Name + "/" + entry
+				}
+				if !containsString(declared, entry) {
+					declared = append(declared, entry)
+				}
+			}
+		}
+	}
+
+	if port.TargetPort.Type == intstr.String {
+		return fmt.Sprintf("named targetPort %q is not declared by any selected container (declared: %s), so the service has no endpoints for it",
+			port.TargetPort.StrVal, strings.Join(declared, ", "))
+	}
+	// Numeric targetPorts work without a matching containerPort, so only flag them when the pods declare other ports
+	if len(declared) == 0 {
+		return ""
+	}
+	target := port.TargetPort.IntVal
+	if target == 0 {
+		target = port.Port
+	}
+	sort.Strings(declared)
+	return fmt.Sprintf("targetPort %d does not match any declared container port (%s)", target, strings.Join(declared, ", "))
+}
+
+// readyEndpointsByPort counts the ready endpoint addresses of a service per port name
+func (m *Manager) readyEndpointsByPort(ctx context.Context, svc corev1.Service) map[string]int {
+	counts := make(map[string]int)
+	endpoints, err := m.k8sClient.Kubernetes.CoreV1().Endpoints(svc.Namespace).Get(ctx, svc.Name, metav1.GetOptions{})
+	if err != nil {
+		return counts
+	}
+	for _, subset := range endpoints.Subsets {
+		for _, port := range subset.Ports {
+			counts[port.Name] += len(subset.Addresses)
+		}
+	}
+	return counts
+}
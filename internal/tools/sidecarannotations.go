@@ -0,0 +1,518 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// sidecarAnnotation describes a known sidecar annotation and how its value is validated
+type sidecarAnnotation struct {
+	effect     string
+	valueType  string   // bool, ports, cidrs, quantity, enum or string
+	values     []string // allowed values for enum
+	deprecated string   // why it is deprecated and what replaces it
+	injector   bool     // written by the injector rather than by users
+}
+
+// sidecarAnnotations catalogues the sidecar.istio.io and traffic.sidecar.istio.io pod annotations
+var sidecarAnnotations = map[string]sidecarAnnotation{
+	"sidecar.istio.io/inject": {
+		effect:     "Enables or disables sidecar injection for the pod",
+		valueType:  "bool",
+		deprecated: "Set sidecar.istio.io/inject as a pod label instead; the label takes precedence and supports revision-based injection",
+	},
+	"sidecar.istio.io/status": {
+		effect:    "Records what the injector added (containers, volumes, revision); written by the injector",
+		valueType: "string",
+		injector:  true,
+	},
+	"sidecar.istio.io/rewriteAppHTTPProbers": {
+		effect:    "Rewrites HTTP liveness/readiness probes to go through the pilot-agent so they pass with strict mTLS",
+		valueType: "bool",
+	},
+	"sidecar.istio.io/interceptionMode": {
+		effect:    "Chooses how inbound traffic is redirected to the sidecar: REDIRECT (NAT, loses the original source IP) or TPROXY (preserves it, needs NET_ADMIN)",
+		valueType: "enum",
+		values:    []string{"REDIRECT", "TPROXY", "NONE"},
+	},
+	"sidecar.istio.io/proxyCPU": {
+		effect:    "CPU request for the istio-proxy container",
+		valueType: "quantity",
+	},
+	"sidecar.istio.io/proxyCPULimit": {
+		effect:    "CPU limit for the istio-proxy container",
+		valueType: "quantity",
+	},
+	"sidecar.istio.io/proxyMemory": {
+		effect:    "Memory request for the istio-proxy container",
+		valueType: "quantity",
+	},
+	"sidecar.istio.io/proxyMemoryLimit": {
+		effect:    "Memory limit for the istio-proxy container",
+		valueType: "quantity",
+	},
+	"sidecar.istio.io/proxyImage": {
+		effect:    "Overrides the image of the istio-proxy container",
+		valueType: "string",
+	},
+	"sidecar.istio.io/proxyImageType": {
+		effect:    "Selects the proxy image variant, e.g. distroless or debug",
+		valueType: "string",
+	},
+	"sidecar.istio.io/logLevel": {
+		effect:    "Envoy log level for the sidecar",
+		valueType: "enum",
+		values:    []string{"trace", "debug", "info", "warning", "error", "critical", "off"},
+	},
+	"sidecar.istio.io/componentLogLevel": {
+		effect:    "Per-component Envoy log levels, e.g. misc:error,upstream:debug",
+		valueType: "string",
+	},
+	"sidecar.istio.io/agentLogLevel": {
+		effect:    "Log levels for the pilot-agent, e.g. default:info,xdsproxy:debug",
+		valueType: "string",
+	},
+	"sidecar.istio.io/enableCoreDump": {
+		effect:    "Lets Envoy write core dumps; requires a privileged init step",
+		valueType: "bool",
+	},
+	"sidecar.istio.io/bootstrapOverride": {
+		effect:    "Names a ConfigMap with a custom Envoy bootstrap template",
+		valueType: "string",
+	},
+	"sidecar.istio.io/userVolume": {
+		effect:    "JSON volumes to add to the pod for the sidecar",
+		valueType: "string",
+	},
+	"sidecar.istio.io/userVolumeMount": {
+		effect:    "JSON volume mounts to add to the istio-proxy container",
+		valueType: "string",
+	},
+	"sidecar.istio.io/statsHistogramBuckets": {
+		effect:    "Custom Envoy histogram buckets for matching stat prefixes",
+		valueType: "string",
+	},
+	"sidecar.istio.io/extraStatTags": {
+		effect:     "Extra tags to extract into Istio metrics",
+		valueType:  "string",
+		deprecated: "Customize metric tags with the Telemetry API instead",
+	},
+	"sidecar.istio.io/statsInclusionPrefixes": {
+		effect:     "Envoy stat prefixes to expose",
+		valueType:  "string",
+		deprecated: "Use proxyStatsMatcher in the proxy.istio.io/config annotation instead",
+	},
+	"sidecar.istio.io/statsInclusionSuffixes": {
+		effect:     "Envoy stat suffixes to expose",
+		valueType:  "string",
+		deprecated: "Use proxyStatsMatcher in the proxy.istio.io/config annotation instead",
+	},
+	"sidecar.istio.io/statsInclusionRegexps": {
+		effect:     "Envoy stat regexes to expose",
+		valueType:  "string",
+		deprecated: "Use proxyStatsMatcher in the proxy.istio.io/config annotation instead",
+	},
+	"sidecar.istio.io/controlPlaneAuthPolicy": {
+		effect:     "Authentication policy between the sidecar and the control plane",
+		valueType:  "string",
+		deprecated: "Control plane traffic is always mTLS; this annotation is ignored",
+	},
+	"sidecar.istio.io/discoveryAddress": {
+		effect:     "Overrides the control plane address the sidecar connects to",
+		valueType:  "string",
+		deprecated: "Set discoveryAddress in the proxy.istio.io/config annotation instead",
+	},
+	"sidecar.istio.io/nativeSidecar": {
+		effect:    "Injects istio-proxy as a Kubernetes native sidecar (restartable init container)",
+		valueType: "bool",
+	},
+	"status.sidecar.istio.io/port": {
+		effect:    "Port of the pilot-agent status server used for health checks; 0 disables it",
+		valueType: "ports",
+	},
+	"readiness.status.sidecar.istio.io/applicationPorts": {
+		effect:    "Application ports the sidecar waits for before reporting ready",
+		valueType: "ports",
+	},
+	"readiness.status.sidecar.istio.io/initialDelaySeconds": {
+		effect:    "Initial delay of the sidecar readiness probe",
+		valueType: "int",
+	},
+	"readiness.status.sidecar.istio.io/periodSeconds": {
+		effect:    "Period of the sidecar readiness probe",
+		valueType: "int",
+	},
+	"readiness.status.sidecar.istio.io/failureThreshold": {
+		effect:    "Failure threshold of the sidecar readiness probe",
+		valueType: "int",
+	},
+	"traffic.sidecar.istio.io/includeInboundPorts": {
+		effect:    "Inbound ports redirected to the sidecar; * redirects all, empty redirects none",
+		valueType: "ports",
+	},
+	"traffic.sidecar.istio.io/excludeInboundPorts": {
+		effect:    "Inbound ports that bypass the sidecar",
+		valueType: "ports",
+	},
+	"traffic.sidecar.istio.io/includeOutboundPorts": {
+		effect:    "Outbound ports redirected to the sidecar regardless of destination IP",
+		valueType: "ports",
+	},
+	"traffic.sidecar.istio.io/excludeOutboundPorts": {
+		effect:    "Outbound ports that bypass the sidecar",
+		valueType: "ports",
+	},
+	"traffic.sidecar.istio.io/includeOutboundIPRanges": {
+		effect:    "Outbound destination CIDRs redirected to the sidecar; * redirects all, empty redirects none",
+		valueType: "cidrs",
+	},
+	"traffic.sidecar.istio.io/excludeOutboundIPRanges": {
+		effect:    "Outbound destination CIDRs that bypass the sidecar",
+		valueType: "cidrs",
+	},
+	"traffic.sidecar.istio.io/excludeInterfaces": {
+		effect:    "Network interfaces whose traffic bypasses the sidecar",
+		valueType: "string",
+	},
+	"traffic.sidecar.istio.io/kubevirtInterfaces": {
+		effect:     "Virtual interfaces whose traffic is redirected to the sidecar",
+		valueType:  "string",
+		deprecated: "Use the istio.io/reroute-virtual-interfaces annotation instead",
+	},
+}
+
+// SidecarAnnotationInfo explains one sidecar annotation set on a workload
+type SidecarAnnotationInfo struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"`
+	Effect     string `json:"effect"`
+	Deprecated string `json:"deprecated,omitempty"`
+	Invalid    string `json:"invalid,omitempty"`
+}
+
+// SidecarAnnotationReport lists and explains the sidecar annotations of a pod or deployment
+type SidecarAnnotationReport struct {
+	Kind        string                  `json:"kind"`
+	Name        string                  `json:"name"`
+	Namespace   string                  `json:"namespace"`
+	Injected    bool                    `json:"injected"`
+	Annotations []SidecarAnnotationInfo `json:"annotations"`
+	Issues      []string                `json:"issues,omitempty"`
+	Notes       []string                `json:"notes,omitempty"`
+	Timestamp   time.Time               `json:"timestamp"`
+}
+
+// inspectSidecarAnnotationsParams are the arguments of inspect_sidecar_annotations
+type inspectSidecarAnnotationsParams struct {
+	Namespace  string `json:"namespace,omitempty"` // default: default
+	PodName    string `json:"pod_name,omitempty"`
+	Deployment string `json:"deployment,omitempty"`
+}
+
+// InspectSidecarAnnotations lists the sidecar.istio.io and traffic.sidecar.istio.io annotations of a
+// pod or deployment template, explains each one and flags deprecated, invalid or conflicting ones
+func (m *Manager) InspectSidecarAnnotations(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params inspectSidecarAnnotationsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if (params.PodName == "") == (params.Deployment == "") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "Exactly one of pod_name or deployment is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	report := &SidecarAnnotationReport{
+		Namespace: params.Namespace,
+		Timestamp: time.Now(),
+	}
+	var annotations, labels map[string]string
+	if params.PodName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get pod: %v", err),
+					},
+				},
+			}, nil
+		}
+		report.Kind = "Pod"
+		report.Name = pod.Name
+		annotations, labels = pod.Annotations, pod.Labels
+		report.Injected = annotations["sidecar.istio.io/status"] != ""
+	} else {
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, params.Deployment, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get deployment: %v", err),
+					},
+				},
+			}, nil
+		}
+		report.Kind = "Deployment"
+		report.Name = deployment.Name
+		annotations, labels = deployment.Spec.Template.Annotations, deployment.Spec.Template.Labels
+		report.Notes = append(report.Notes, "Showing the pod template; the injector adds sidecar.istio.io/status and, with the Istio CNI plugin, traffic annotations to the pods it creates")
+	}
+
+	keys := make([]string, 0, len(annotations))
+	for key := range annotations {
+		if strings.Contains(key, "sidecar.istio.io/") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	report.Annotations = []SidecarAnnotationInfo{}
+	for _, key := range keys {
+		info := SidecarAnnotationInfo{Key: key, Value: annotations[key]}
+		known, ok := sidecarAnnotations[key]
+		if !ok {
+			info.Effect = "Unknown annotation; it has no effect"
+			if suggestion := closestSidecarAnnotation(key); suggestion != "" {
+				report.Issues = append(report.Issues, fmt.Sprintf("%s is not a recognized annotation; did you mean %s?", key, suggestion))
+			} else {
+				report.Issues = append(report.Issues, fmt.Sprintf("%s is not a recognized annotation and has no effect", key))
+			}
+			report.Annotations = append(report.Annotations, info)
+			continue
+		}
+		info.Effect = known.effect
+		info.Deprecated = known.deprecated
+		if known.deprecated != "" {
+			report.Issues = append(report.Issues, fmt.Sprintf("%s is deprecated: %s", key, known.deprecated))
+		}
+		if known.injector && report.Kind == "Deployment" {
+			report.Issues = append(report.Issues, fmt.Sprintf("%s is written by the injector; setting it on the template makes the injector skip the pod", key))
+		}
+		if err := validateSidecarAnnotation(known, info.Value); err != nil {
+			info.Invalid = err.Error()
+			report.Issues = append(report.Issues, fmt.Sprintf("%s=%q is invalid: %v", key, info.Value, err))
+		}
+		report.Annotations = append(report.Annotations, info)
+	}
+
+	report.Issues = append(report.Issues, sidecarAnnotationConflicts(annotations, labels)...)
+
+	if status := annotations["sidecar.istio.io/status"]; status != "" {
+		var injected struct {
+			Revision string `json:"revision"`
+		}
+		if json.Unmarshal([]byte(status), &injected) == nil && injected.Revision != "" {
+			report.Notes = append(report.Notes, fmt.Sprintf("Sidecar was injected by control plane revision %s", injected.Revision))
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// validateSidecarAnnotation checks an annotation value against its expected type
+func validateSidecarAnnotation(known sidecarAnnotation, value string) error {
+	switch known.valueType {
+	case "bool":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("expected true or false")
+		}
+	case "int":
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+	case "enum":
+		if !containsString(known.values, value) {
+			return fmt.Errorf("expected one of %s", strings.Join(known.values, ", "))
+		}
+	case "quantity":
+		if _, err := resource.ParseQuantity(value); err != nil {
+			return fmt.Errorf("not a resource quantity: %v", err)
+		}
+	case "ports":
+		if _, err := parsePortList(value); err != nil {
+			return err
+		}
+	case "cidrs":
+		for _, cidr := range splitList(value) {
+			if cidr == "*" {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(cidr); err != nil {
+				return fmt.Errorf("%q is not a CIDR", cidr)
+			}
+		}
+	}
+	return nil
+}
+
+// parsePortList parses a comma-separated port list; "*" is returned as a port of 0
+func parsePortList(value string) ([]int, error) {
+	var ports []int
+	for _, item := range splitList(value) {
+		if item == "*" {
+			ports = append(ports, 0)
+			continue
+		}
+		port, err := strconv.Atoi(item)
+		if err != nil || port < 0 || port > 65535 {
+			return nil, fmt.Errorf("%q is not a port", item)
+		}
+		ports = append(ports, port)
+	}
+	return ports, nil
+}
+
+// sidecarAnnotationConflicts finds annotations that contradict each other or the injection setting
+func sidecarAnnotationConflicts(annotations, labels map[string]string) []string {
+	var issues []string
+
+	label, hasLabel := labels["sidecar.istio.io/inject"]
+	annotation, hasAnnotation := annotations["sidecar.istio.io/inject"]
+	if hasLabel && hasAnnotation && label != annotation {
+		issues = append(issues, fmt.Sprintf("sidecar.istio.io/inject is %q as a label but %q as an annotation; the label wins", label, annotation))
+	}
+	inject := annotation
+	if hasLabel {
+		inject = label
+	}
+	if disabled, err := strconv.ParseBool(inject); err == nil && !disabled {
+		var ignored []string
+		for key := range annotations {
+			if key != "sidecar.istio.io/inject" && strings.Contains(key, "sidecar.istio.io/") {
+				ignored = append(ignored, key)
+			}
+		}
+		sort.Strings(ignored)
+		if len(ignored) > 0 {
+			issues = append(issues, fmt.Sprintf("Injection is disabled, so %s have no effect", strings.Join(ignored, ", ")))
+		}
+	}
+
+	for _, direction := range []string{"Inbound", "Outbound"} {
+		include, hasInclude := annotations["traffic.sidecar.istio.io/include"+direction+"Ports"]
+		exclude := annotations["traffic.sidecar.istio.io/exclude"+direction+"Ports"]
+		includePorts, _ := parsePortList(include)
+		excludePorts, _ := parsePortList(exclude)
+		var overlap []string
+		for _, port := range includePorts {
+			if port != 0 && containsInt(excludePorts, port) {
+				overlap = append(overlap, strconv.Itoa(port))
+			}
+		}
+		if len(overlap) > 0 {
+			issues = append(issues, fmt.Sprintf("Ports %s are both included and excluded for %s traffic; the exclusion wins",
+				strings.Join(overlap, ", "), strings.ToLower(direction)))
+		}
+		if direction == "Inbound" && hasInclude && strings.TrimSpace(include) == "" {
+			issues = append(issues, "traffic.sidecar.istio.io/includeInboundPorts is empty, so no inbound traffic reaches the sidecar and inbound policy (mTLS, authorization) is not enforced")
+		}
+	}
+
+	include, hasInclude := annotations["traffic.sidecar.istio.io/includeOutboundIPRanges"]
+	if hasInclude && strings.TrimSpace(include) == "" && annotations["traffic.sidecar.istio.io/includeOutboundPorts"] == "" {
+		issues = append(issues, "traffic.sidecar.istio.io/includeOutboundIPRanges is empty, so no outbound traffic goes through the sidecar")
+	}
+	excludeRanges := splitList(annotations["traffic.sidecar.istio.io/excludeOutboundIPRanges"])
+	for _, cidr := range splitList(include) {
+		if containsString(excludeRanges, cidr) {
+			issues = append(issues, fmt.Sprintf("%s is both included and excluded for outbound traffic; the exclusion wins", cidr))
+		}
+	}
+
+	for _, pair := range [][2]string{{"proxyCPU", "proxyCPULimit"}, {"proxyMemory", "proxyMemoryLimit"}} {
+		request, errRequest := resource.ParseQuantity(annotations["sidecar.istio.io/"+pair[0]])
+		limit, errLimit := resource.ParseQuantity(annotations["sidecar.istio.io/"+pair[1]])
+		if errRequest == nil && errLimit == nil && request.Cmp(limit) > 0 {
+			issues = append(issues, fmt.Sprintf("sidecar.istio.io/%s (%s) is greater than sidecar.istio.io/%s (%s); the pod will be rejected",
+				pair[0], request.String(), pair[1], limit.String()))
+		}
+	}
+	return issues
+}
+
+// closestSidecarAnnotation suggests a known annotation for a misspelled or miscased key
+func closestSidecarAnnotation(key string) string {
+	best, bestDistance := "", 4
+	for known := range sidecarAnnotations {
+		if strings.EqualFold(known, key) {
+			return known
+		}
+		if distance := editDistance(strings.ToLower(known), strings.ToLower(key)); distance < bestDistance {
+			best, bestDistance = known, distance
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between two strings
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+	return previous[len(b)]
+}
+
+// containsInt reports whether a slice contains a value
+func containsInt(items []int, value int) bool {
+	for _, item := range items {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,516 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotChange describes a single difference between a stored snapshot and the current result
+type SnapshotChange struct {
+	Path   string      `json:"path"`
+	Kind   string      `json:"kind"` // added, removed or changed
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// SnapshotComparison is the result of comparing a tool's current output with a stored run
+type SnapshotComparison struct {
+	Tool           string           `json:"tool"`
+	Args           json.RawMessage  `json:"args,omitempty"`
+	BaselineID     uint64           `json:"baseline_id"`
+	BaselineTime   time.Time        `json:"baseline_time"`
+	BaselineAge    string           `json:"baseline_age"`
+	CurrentTime    time.Time        `json:"current_time"`
+	Regressions    []SnapshotChange `json:"regressions,omitempty"`
+	Improvements   []SnapshotChange `json:"improvements,omitempty"`
+	OtherChanges   []SnapshotChange `json:"other_changes,omitempty"`
+	Unchanged      bool             `json:"unchanged"`
+	CurrentIsError bool             `json:"current_is_error,omitempty"`
+}
+
+// healthyFlags are boolean fields where true means healthy
+var healthyFlags = map[string]bool{
+	"ready":     true,
+	"success":   true,
+	"installed": true,
+	"healthy":   true,
+	"stable":    true,
+	"reachable": true,
+	"passed":    true,
+}
+
+// healthyCounts are numeric fields where a decrease means degradation
+var healthyCounts = map[string]bool{
+	"available":      true,
+	"ready_replicas": true,
+	"success_rate":   true,
+}
+
+// compareWithSnapshotParams are the arguments of compare_with_snapshot
+type compareWithSnapshotParams struct {
+	Tool       string                 `json:"tool"`
+	Args       map[string]interface{} `json:"args,omitempty"`
+	BaselineID uint64                 `json:"baseline_id,omitempty"` // default: most recent matching run
+	OlderThan  string                 `json:"older_than,omitempty"`  // pick the newest matching run older than this, e.g. 24h
+}
+
+// CompareWithSnapshot re-runs a read-only tool and diffs its output against a stored run from the history
+func (m *Manager) CompareWithSnapshot(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params compareWithSnapshotParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Tool == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "tool is required",
+				},
+			},
+		}, nil
+	}
+	if !readOnlyTools[params.Tool] {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Tool %s is not a read-only tool and can't be compared", params.Tool),
+				},
+			},
+		}, nil
+	}
+
+	toolArgs := json.RawMessage("{}")
+	if params.Args != nil {
+		toolArgs, _ = json.Marshal(params.Args)
+	}
+
+	var olderThan time.Duration
+	if params.OlderThan != "" {
+		d, err := time.ParseDuration(params.OlderThan)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid older_than %q: %v", params.OlderThan, err),
+					},
+				},
+			}, nil
+		}
+		olderThan = d
+	}
+
+	// Find the baseline before running the tool so the new run can't be picked
+	baseline, err := m.findSnapshotBaseline(params.Tool, toolArgs, params.BaselineID, olderThan)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("No baseline to compare against: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	current, err := m.ExecuteTool(ctx, params.Tool, toolArgs)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to run %s: %v", params.Tool, err),
+				},
+			},
+		}, nil
+	}
+
+	var currentText string
+	if len(current.Content) > 0 {
+		if tc, ok := current.Content[0].(TextContent); ok {
+			currentText = tc.Text
+		}
+	}
+
+	now := time.Now()
+	comparison := &SnapshotComparison{
+		Tool:           params.Tool,
+		Args:           toolArgs,
+		BaselineID:     baseline.ID,
+		BaselineTime:   baseline.Timestamp,
+		BaselineAge:    now.Sub(baseline.Timestamp).Round(time.Second).String(),
+		CurrentTime:    now,
+		CurrentIsError: current.IsError,
+	}
+
+	var before, after interface{}
+	if err := json.Unmarshal([]byte(baseline.Result), &before); err != nil {
+		before = baseline.Result
+	}
+	if err := json.Unmarshal([]byte(currentText), &after); err != nil {
+		after = currentText
+	}
+
+	var changes []SnapshotChange
+	diffValues("", before, after, &changes)
+	for _, change := range changes {
+		switch classifyChange(change) {
+		case "regression":
+			comparison.Regressions = append(comparison.Regressions, change)
+		case "improvement":
+			comparison.Improvements = append(comparison.Improvements, change)
+		default:
+			comparison.OtherChanges = append(comparison.OtherChanges, change)
+		}
+	}
+	if baseline.IsError != current.IsError {
+		change := SnapshotChange{Path: "(result)", Kind: "changed", Before: errorState(baseline.IsError), After: errorState(current.IsError)}
+		if current.IsError {
+			comparison.Regressions = append([]SnapshotChange{change}, comparison.Regressions...)
+		} else {
+			comparison.Improvements = append([]SnapshotChange{change}, comparison.Improvements...)
+		}
+	}
+	comparison.Unchanged = len(comparison.Regressions)+len(comparison.Improvements)+len(comparison.OtherChanges) == 0
+
+	resultJSON, _ := json.MarshalIndent(comparison, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// findSnapshotBaseline returns the stored run to compare against
+func (m *Manager) findSnapshotBaseline(tool string, args json.RawMessage, id uint64, olderThan time.Duration) (*HistoryEntry, error) {
+	if id != 0 {
+		entry, err := m.history.get(id)
+		if err != nil {
+			return nil, err
+		}
+		if entry.Tool != tool {
+			return nil, fmt.Errorf("result %d is from %s, not %s", id, entry.Tool, tool)
+		}
+		return entry, nil
+	}
+
+	want := compactArgs(args)
+	cutoff := time.Now().Add(-olderThan)
+	entries, err := m.history.list(func(entry *HistoryEntry) bool {
+		return entry.Tool == tool && sameArgs(compactArgs(entry.Args), want) && !entry.Timestamp.After(cutoff)
+	}, 1)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no recorded %s run with the same arguments; run the tool once to create a snapshot", tool)
+	}
+	return &entries[0], nil
+}
+
+// sameArgs compares compacted JSON arguments regardless of key order
+func sameArgs(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var va, vb interface{}
+	if json.Unmarshal([]byte(a), &va) != nil || json.Unmarshal([]byte(b), &vb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
+
+// diffValues walks two decoded JSON documents and records their differences
+func diffValues(path string, before, after interface{}, changes *[]SnapshotChange) {
+	if isVolatileField(path) {
+		return
+	}
+
+	switch b := before.(type) {
+	case map[string]interface{}:
+		a, ok := after.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make(map[string]bool)
+		for k := range b {
+			keys[k] = true
+		}
+		for k := range a {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			bv, inBefore := b[k]
+			av, inAfter := a[k]
+			child := joinPath(path, k)
+			switch {
+			case !inAfter:
+				if !isVolatileField(child) {
+					*changes = append(*changes, SnapshotChange{Path: child, Kind: "removed", Before: bv})
+				}
+			case !inBefore:
+				if !isVolatileField(child) {
+					*changes = append(*changes, SnapshotChange{Path: child, Kind: "added", After: av})
+				}
+			default:
+				diffValues(child, bv, av, changes)
+			}
+		}
+		return
+
+	case []interface{}:
+		a, ok := after.([]interface{})
+		if !ok {
+			break
+		}
+		// Lists of named objects are matched by name so reordering isn't reported
+		if bk, ak := keyedItems(b), keyedItems(a); bk != nil && ak != nil {
+			keys := make([]string, 0, len(bk)+len(ak))
+			for k := range bk {
+				keys = append(keys, k)
+			}
+			for k := range ak {
+				if _, ok := bk[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				bv, inBefore := bk[k]
+				av, inAfter := ak[k]
+				child := fmt.Sprintf("%s[%s]", path, k)
+				switch {
+				case !inAfter:
+					*changes = append(*changes, SnapshotChange{Path: child, Kind: "removed", Before: bv})
+				case !inBefore:
+					*changes = append(*changes, SnapshotChange{Path: child, Kind: "added", After: av})
+				default:
+					diffValues(child, bv, av, changes)
+				}
+			}
+			return
+		}
+		// Lists of scalars (issues, namespaces) are compared as sets
+		if isScalarList(b) && isScalarList(a) {
+			for _, item := range b {
+				if !containsValue(a, item) {
+					*changes = append(*changes, SnapshotChange{Path: path, Kind: "removed", Before: item})
+				}
+			}
+			for _, item := range a {
+				if !containsValue(b, item) {
+					*changes = append(*changes, SnapshotChange{Path: path, Kind: "added", After: item})
+				}
+			}
+			return
+		}
+		for i := 0; i < len(b) || i < len(a); i++ {
+			child := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(a):
+				*changes = append(*changes, SnapshotChange{Path: child, Kind: "removed", Before: b[i]})
+			case i >= len(b):
+				*changes = append(*changes, SnapshotChange{Path: child, Kind: "added", After: a[i]})
+			default:
+				diffValues(child, b[i], a[i], changes)
+			}
+		}
+		return
+	}
+
+	if !reflect.DeepEqual(before, after) {
+		*changes = append(*changes, SnapshotChange{Path: path, Kind: "changed", Before: before, After: after})
+	}
+}
+
+// keyedItems indexes a list of objects by their name field, or returns nil if they aren't all named
+func keyedItems(items []interface{}) map[string]interface{} {
+	if len(items) == 0 {
+		return nil
+	}
+	keyed := make(map[string]interface{}, len(items))
+	for _, item := range items {
+		obj, ok := item.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		var key string
+		for _, field := range []string{"name", "endpoint", "image"} {
+			if v, ok := obj[field].(string); ok && v != "" {
+				key = v
+				break
+			}
+		}
+		if key == "" {
+			return nil
+		}
+		if ns, ok := obj["namespace"].(string); ok && ns != "" {
+			key = ns + "/" + key
+		}
+		if _, dup := keyed[key]; dup {
+			return nil
+		}
+		keyed[key] = obj
+	}
+	return keyed
+}
+
+// isScalarList reports whether a list only holds strings, numbers or booleans
+func isScalarList(items []interface{}) bool {
+	for _, item := range items {
+		switch item.(type) {
+		case map[string]interface{}, []interface{}:
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue reports whether a decoded JSON list contains a value
+func containsValue(items []interface{}, value interface{}) bool {
+	for _, item := range items {
+		if reflect.DeepEqual(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVolatileField reports whether a field changes on every run and should be ignored
+func isVolatileField(path string) bool {
+	field := lastPathField(path)
+	switch field {
+	case "timestamp", "duration", "time_total", "latency_ms", "avg_latency_ms", "max_latency_ms", "started_at", "response", "command", "next_run":
+		return true
+	}
+	return strings.HasSuffix(field, "_time") || strings.HasSuffix(field, "_age")
+}
+
+// classifyChange decides whether a change is a regression, an improvement or neutral
+func classifyChange(change SnapshotChange) string {
+	field := lastPathField(change.Path)
+
+	if field == "issues" || field == "failures" || field == "recent_failures" || field == "errors" {
+		switch change.Kind {
+		case "added":
+			return "regression"
+		case "removed":
+			return "improvement"
+		}
+	}
+
+	if healthyFlags[field] {
+		before, bok := change.Before.(bool)
+		after, aok := change.After.(bool)
+		if bok && aok {
+			if before && !after {
+				return "regression"
+			}
+			return "improvement"
+		}
+		if change.Kind == "added" && change.After == false {
+			return "regression"
+		}
+	}
+
+	if healthyCounts[field] {
+		before, bok := change.Before.(float64)
+		after, aok := change.After.(float64)
+		if bok && aok {
+			if after < before {
+				return "regression"
+			}
+			return "improvement"
+		}
+	}
+
+	if field == "status_code" {
+		before, bok := change.Before.(float64)
+		after, aok := change.After.(float64)
+		if bok && aok {
+			beforeOK := before >= 200 && before < 400
+			afterOK := after >= 200 && after < 400
+			if beforeOK && !afterOK {
+				return "regression"
+			}
+			if !beforeOK && afterOK {
+				return "improvement"
+			}
+		}
+	}
+
+	if field == "error" || field == "last_error" {
+		if change.Kind == "added" {
+			return "regression"
+		}
+		if change.Kind == "removed" {
+			return "improvement"
+		}
+	}
+
+	// Items (components, endpoints, results) that disappeared are regressions
+	if change.Kind == "removed" {
+		if _, ok := change.Before.(map[string]interface{}); ok {
+			return "regression"
+		}
+	}
+
+	return "other"
+}
+
+// lastPathField returns the last field name of a change path
+func lastPathField(path string) string {
+	if idx := strings.LastIndex(path, "."); idx >= 0 {
+		path = path[idx+1:]
+	}
+	if idx := strings.Index(path, "["); idx >= 0 {
+		path = path[:idx]
+	}
+	return path
+}
+
+// joinPath appends a field to a change path
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
+
+// errorState describes whether a tool call failed
+func errorState(isError bool) string {
+	if isError {
+		return "error"
+	}
+	return "ok"
+}
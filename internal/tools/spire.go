@@ -0,0 +1,645 @@
+package tools
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	// spireManagedLabel marks pods whose proxies get their identity from SPIRE
+	spireManagedLabel = "spiffe.io/spire-managed-identity"
+	// injectTemplatesAnnotation selects the sidecar injection templates applied to a pod
+	injectTemplatesAnnotation = "inject.istio.io/templates"
+	// spireCSIDriver is the SPIFFE CSI driver that mounts the SPIRE agent socket into pods
+	spireCSIDriver = "csi.spiffe.io"
+)
+
+var clusterSPIFFEIDGVR = schema.GroupVersionResource{Group: "spire.spiffe.io", Version: "v1alpha1", Resource: "clusterspiffeids"}
+
+// SPIREInstallResult is the result of installing SPIRE
+type SPIREInstallResult struct {
+	Release     string   `json:"release"`
+	Namespace   string   `json:"namespace"`
+	TrustDomain string   `json:"trust_domain"`
+	Server      string   `json:"server"` // ready replicas of the SPIRE server
+	Agents      string   `json:"agents"` // ready SPIRE agents out of scheduled ones
+	CSIDriver   bool     `json:"csi_driver"`
+	Issues      []string `json:"issues,omitempty"`
+	Notes       []string `json:"notes,omitempty"`
+}
+
+// SPIREIstioConfigResult is the result of configuring Istio to take workload identities from SPIRE
+type SPIREIstioConfigResult struct {
+	TrustDomain     string    `json:"trust_domain"`
+	TrustDomainWas  string    `json:"previous_trust_domain,omitempty"`
+	ClusterSPIFFEID string    `json:"cluster_spiffe_id"`
+	Annotated       []string  `json:"annotated_deployments,omitempty"`
+	Issues          []string  `json:"issues,omitempty"`
+	Notes           []string  `json:"notes,omitempty"`
+	Timestamp       time.Time `json:"timestamp"`
+}
+
+// WorkloadIdentity is the certificate a proxy is serving and the identity it should carry
+type WorkloadIdentity struct {
+	Pod          string `json:"pod"`
+	SPIREManaged bool   `json:"spire_managed"`
+	SPIFFEID     string `json:"spiffe_id,omitempty"`
+	Expected     string `json:"expected"`
+	Issuer       string `json:"issuer,omitempty"`
+	IssuedBy     string `json:"issued_by,omitempty"` // istiod, another CA such as SPIRE, or unknown
+	NotAfter     string `json:"not_after,omitempty"`
+	Matches      bool   `json:"matches"`
+	Problem      string `json:"problem,omitempty"`
+}
+
+// SPIREIdentityReport is the result of checking workload certificates against SPIFFE IDs
+type SPIREIdentityReport struct {
+	Namespace   string             `json:"namespace"`
+	TrustDomain string             `json:"trust_domain"`
+	Identities  []WorkloadIdentity `json:"identities"`
+	Issues      []string           `json:"issues,omitempty"`
+	Timestamp   time.Time          `json:"timestamp"`
+}
+
+// installSpireParams are the arguments of install_spire
+type installSpireParams struct {
+	Namespace       string                 `json:"namespace,omitempty"`        // release namespace, default: spire-mgmt
+	ServerNamespace string                 `json:"server_namespace,omitempty"` // default: spire-server
+	AgentNamespace  string                 `json:"agent_namespace,omitempty"`  // default: spire-system
+	TrustDomain     string                 `json:"trust_domain,omitempty"`     // must match Istio's, default: cluster.local
+	ClusterName     string                 `json:"cluster_name,omitempty"`     // default: meshpilot
+	Version         string                 `json:"version,omitempty"`          // default: latest chart version
+	Values          map[string]interface{} `json:"values,omitempty"`           // extra values for the spire chart
+	Timeout         string                 `json:"timeout,omitempty"`          // default: 5m
+	RepoURL         string                 `json:"repo_url,omitempty"`         // chart repository override
+}
+
+// InstallSPIRE installs the SPIRE server, agents and SPIFFE CSI driver from the hardened SPIFFE charts
+func (m *Manager) InstallSPIRE(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params installSpireParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "spire-mgmt"
+	}
+	if params.ServerNamespace == "" {
+		params.ServerNamespace = "spire-server"
+	}
+	if params.AgentNamespace == "" {
+		params.AgentNamespace = "spire-system"
+	}
+	if params.TrustDomain == "" {
+		params.TrustDomain = "cluster.local"
+	}
+	if params.ClusterName == "" {
+		params.ClusterName = "meshpilot"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+
+	if err := m.checkHelmAvailable(); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
+				},
+			},
+		}, nil
+	}
+
+	repo := resolveChartRepository(m.config.Helm.SPIRE, params.RepoURL)
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to add SPIRE Helm repository: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.installSPIREChart(ctx, "spire-crds", repo.ChartRef("spire-crds"), params.Namespace, params.Version, nil, params.Timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to install SPIRE CRDs: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	values := map[string]interface{}{
+		"global.spire.trustDomain":            params.TrustDomain,
+		"global.spire.clusterName":            params.ClusterName,
+		"global.spire.namespaces.create":      true,
+		"global.spire.namespaces.server.name": params.ServerNamespace,
+		"global.spire.namespaces.system.name": params.AgentNamespace,
+	}
+	for key, value := range params.Values {
+		values[key] = value
+	}
+	if err := m.installSPIREChart(ctx, "spire", repo.ChartRef("spire"), params.Namespace, params.Version, values, params.Timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to install SPIRE: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := &SPIREInstallResult{
+		Release:     "spire",
+		Namespace:   params.Namespace,
+		TrustDomain: params.TrustDomain,
+	}
+
+	server, err := m.k8sClient.Kubernetes.AppsV1().StatefulSets(params.ServerNamespace).Get(ctx, "spire-server", metav1.GetOptions{})
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("SPIRE server statefulset not found in %s: %v", params.ServerNamespace, err))
+	} else {
+		replicas := int32(1)
+		if server.Spec.Replicas != nil {
+			replicas = *server.Spec.Replicas
+		}
+		result.Server = fmt.Sprintf("%d/%d", server.Status.ReadyReplicas, replicas)
+		if server.Status.ReadyReplicas == 0 {
+			result.Issues = append(result.Issues, "SPIRE server has no ready replicas")
+		}
+	}
+
+	agents, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets(params.AgentNamespace).Get(ctx, "spire-agent", metav1.GetOptions{})
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("SPIRE agent daemonset not found in %s: %v", params.AgentNamespace, err))
+	} else {
+		result.Agents = fmt.Sprintf("%d/%d", agents.Status.NumberReady, agents.Status.DesiredNumberScheduled)
+		if agents.Status.NumberReady < agents.Status.DesiredNumberScheduled {
+			result.Issues = append(result.Issues, "Not every node runs a ready SPIRE agent; pods on those nodes cannot get identities")
+		}
+	}
+
+	if _, err := m.k8sClient.Kubernetes.StorageV1().CSIDrivers().Get(ctx, spireCSIDriver, metav1.GetOptions{}); err == nil {
+		result.CSIDriver = true
+	} else {
+		result.Issues = append(result.Issues, fmt.Sprintf("CSIDriver %s is not registered; proxies cannot mount the SPIRE agent socket", spireCSIDriver))
+	}
+
+	result.Notes = append(result.Notes, "Run configure_istio_spire to make Istio proxies take their identities from SPIRE")
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// installSPIREChart installs or upgrades a SPIRE chart and waits for it to be ready
+func (m *Manager) installSPIREChart(ctx context.Context, release, chart, namespace, version string, values map[string]interface{}, timeout string) error {
+	installed, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:         release,
+		Chart:           chart,
+		Namespace:       namespace,
+		Version:         version,
+		Values:          values,
+		CreateNamespace: true,
+		Wait:            true,
+		Timeout:         timeout,
+		Upgrade:         true,
+	})
+	if err != nil {
+		return err
+	}
+
+	logrus.Infof("SPIRE %s installed: %s", release, installed)
+	return nil
+}
+
+// configureIstioSpireParams are the arguments of configure_istio_spire
+type configureIstioSpireParams struct {
+	TrustDomain    string   `json:"trust_domain,omitempty"`    // default: cluster.local
+	SocketFile     string   `json:"socket_file,omitempty"`     // agent socket name in the CSI volume, default: spire-agent.sock
+	Namespace      string   `json:"namespace,omitempty"`       // namespace of the deployments to opt in, default: default
+	Deployments    []string `json:"deployments,omitempty"`     // deployments to switch to SPIRE identities
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Release        string   `json:"release,omitempty"`         // istiod Helm release, default: istiod
+	Revision       string   `json:"revision,omitempty"`        // control plane revision
+	RepoURL        string   `json:"repo_url,omitempty"`        // chart repository override
+	Timeout        string   `json:"timeout,omitempty"`         // default: 5m
+}
+
+// ConfigureIstioSPIRE adds a "spire" sidecar injection template that mounts the SPIRE agent socket
+// into istio-proxy, aligns the mesh trust domain, registers a ClusterSPIFFEID for mesh workloads and
+// optionally opts deployments in
+func (m *Manager) ConfigureIstioSPIRE(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureIstioSpireParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.TrustDomain == "" {
+		params.TrustDomain = "cluster.local"
+	}
+	if params.SocketFile == "" {
+		params.SocketFile = "spire-agent.sock"
+	}
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "configure_istio_spire")
+
+	if _, err := m.k8sClient.Kubernetes.StorageV1().CSIDrivers().Get(ctx, spireCSIDriver, metav1.GetOptions{}); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("CSIDriver %s is not registered; install SPIRE first with install_spire", spireCSIDriver),
+				},
+			},
+		}, nil
+	}
+
+	result := &SPIREIstioConfigResult{
+		TrustDomain:     params.TrustDomain,
+		ClusterSPIFFEID: "istio-mesh",
+		Timestamp:       time.Now(),
+	}
+
+	var mesh struct {
+		TrustDomain string `json:"trustDomain"`
+	}
+	if err := m.readMeshConfig(ctx, params.IstioNamespace, params.Revision, &mesh); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+	if mesh.TrustDomain == "" {
+		mesh.TrustDomain = "cluster.local"
+	}
+	if mesh.TrustDomain != params.TrustDomain {
+		result.TrustDomainWas = mesh.TrustDomain
+		result.Notes = append(result.Notes, fmt.Sprintf("The mesh trust domain changes from %s to %s; AuthorizationPolicies naming principals in the old trust domain need updating", mesh.TrustDomain, params.TrustDomain))
+	}
+
+	// The template adds the CSI volume holding the SPIRE agent socket where istio-proxy looks for an SDS server
+	template := fmt.Sprintf(`labels:
+  %s: mesh
+spec:
+  containers:
+  - name: istio-proxy
+    env:
+    - name: WORKLOAD_IDENTITY_SOCKET_FILE
+      value: %q
+    volumeMounts:
+    - name: workload-socket
+      mountPath: /run/secrets/workload-spiffe-uds
+      readOnly: true
+  volumes:
+  - name: workload-socket
+    csi:
+      driver: %q
+      readOnly: true
+`, spireManagedLabel, params.SocketFile, spireCSIDriver)
+	values := map[string]interface{}{
+		"sidecarInjectorWebhook.templates.spire": template,
+		"meshConfig.trustDomain":                 params.TrustDomain,
+	}
+	if err := m.setIstiodValues(ctx, params.IstioNamespace, params.Release, params.RepoURL, values, params.Timeout); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to configure istiod: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Register the SPIFFE IDs Istio expects for pods using the template
+	registration := &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "spire.spiffe.io/v1alpha1",
+		"kind":       "ClusterSPIFFEID",
+		"metadata":   map[string]interface{}{"name": result.ClusterSPIFFEID},
+		"spec": map[string]interface{}{
+			"spiffeIDTemplate": "spiffe://{{ .TrustDomain }}/ns/{{ .PodMeta.Namespace }}/sa/{{ .PodSpec.ServiceAccountName }}",
+			"podSelector": map[string]interface{}{
+				"matchLabels": map[string]interface{}{spireManagedLabel: "mesh"},
+			},
+			"workloadSelectorTemplates": []interface{}{"k8s:ns:{{ .PodMeta.Namespace }}"},
+		},
+	}}
+	if err := m.applyResource(ctx, clusterSPIFFEIDGVR, registration); err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to apply ClusterSPIFFEID %s: %v", result.ClusterSPIFFEID, err))
+	}
+
+	// Opt deployments in; the template change rolls their pods
+	for _, name := range params.Deployments {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if deployment.Spec.Template.Annotations == nil {
+				deployment.Spec.Template.Annotations = make(map[string]string)
+			}
+			deployment.Spec.Template.Annotations[injectTemplatesAnnotation] = "sidecar,spire"
+			_, err = m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Update(ctx, deployment, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to annotate deployment %s/%s: %v", params.Namespace, name, err))
+			continue
+		}
+		deployment, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, name, metav1.GetOptions{})
+		if err == nil {
+			err = m.waitForRollout(ctx, deployment, timeout)
+		}
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Deployment %s/%s did not finish rolling out: %v", params.Namespace, name, err))
+			continue
+		}
+		result.Annotated = append(result.Annotated, params.Namespace+"/"+name)
+	}
+	if len(params.Deployments) == 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("Opt workloads in with the pod annotation %s: \"sidecar,spire\" (or pass deployments), then check them with verify_spire_identities", injectTemplatesAnnotation))
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// verifySpireIdentitiesParams are the arguments of verify_spire_identities
+type verifySpireIdentitiesParams struct {
+	Namespace      string `json:"namespace,omitempty"`       // default: default
+	PodName        string `json:"pod_name,omitempty"`        // default: every pod with a sidecar
+	TrustDomain    string `json:"trust_domain,omitempty"`    // default: the mesh trust domain
+	IstioNamespace string `json:"istio_namespace,omitempty"` // default: istio-system
+	Revision       string `json:"revision,omitempty"`        // control plane revision
+}
+
+// VerifySPIREIdentities reads the certificate each proxy serves and checks it carries the expected
+// SPIFFE ID and, for SPIRE-managed pods, was issued by SPIRE rather than istiod
+func (m *Manager) VerifySPIREIdentities(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params verifySpireIdentitiesParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	if params.TrustDomain == "" {
+		var mesh struct {
+			TrustDomain string `json:"trustDomain"`
+		}
+		if err := m.readMeshConfig(ctx, params.IstioNamespace, params.Revision, &mesh); err != nil {
+			logrus.Debugf("Failed to read mesh config: %v", err)
+		}
+		params.TrustDomain = mesh.TrustDomain
+		if params.TrustDomain == "" {
+			params.TrustDomain = "cluster.local"
+		}
+	}
+
+	var pods []corev1.Pod
+	if params.PodName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get pod: %v", err),
+					},
+				},
+			}, nil
+		}
+		pods = []corev1.Pod{*pod}
+	} else {
+		list, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list pods: %v", err),
+					},
+				},
+			}, nil
+		}
+		pods = list.Items
+	}
+
+	report := &SPIREIdentityReport{
+		Namespace:   params.Namespace,
+		TrustDomain: params.TrustDomain,
+		Identities:  []WorkloadIdentity{},
+		Timestamp:   time.Now(),
+	}
+
+	// Certificates signed by istiod's CA name its root as the issuer
+	var istioRoot *x509.Certificate
+	if cm, err := m.k8sClient.Kubernetes.CoreV1().ConfigMaps(params.Namespace).Get(ctx, "istio-ca-root-cert", metav1.GetOptions{}); err == nil {
+		if block, _ := pem.Decode([]byte(cm.Data["root-cert.pem"])); block != nil {
+			istioRoot, _ = x509.ParseCertificate(block.Bytes)
+		}
+	}
+
+	for i := range pods {
+		pod := &pods[i]
+		if !podHasSidecar(pod) || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		serviceAccount := pod.Spec.ServiceAccountName
+		if serviceAccount == "" {
+			serviceAccount = "default"
+		}
+		identity := WorkloadIdentity{
+			Pod:          pod.Name,
+			SPIREManaged: pod.Labels[spireManagedLabel] != "",
+			Expected:     fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", params.TrustDomain, pod.Namespace, serviceAccount),
+		}
+
+		cert, err := m.proxyWorkloadCertificate(ctx, pod)
+		if err != nil {
+			identity.Problem = err.Error()
+			report.Issues = append(report.Issues, fmt.Sprintf("Pod %s: %v", pod.Name, err))
+			report.Identities = append(report.Identities, identity)
+			continue
+		}
+		for _, uri := range cert.URIs {
+			if uri.Scheme == "spiffe" {
+				identity.SPIFFEID = uri.String()
+			}
+		}
+		identity.Issuer = cert.Issuer.String()
+		identity.NotAfter = cert.NotAfter.Format(time.RFC3339)
+		switch {
+		case istioRoot != nil && cert.Issuer.String() == istioRoot.Subject.String():
+			identity.IssuedBy = "istiod"
+		case istioRoot != nil:
+			identity.IssuedBy = "other"
+		default:
+			identity.IssuedBy = "unknown"
+		}
+		identity.Matches = identity.SPIFFEID == identity.Expected
+
+		switch {
+		case !identity.Matches:
+			identity.Problem = fmt.Sprintf("certificate carries %q instead of %s", identity.SPIFFEID, identity.Expected)
+		case identity.SPIREManaged && identity.IssuedBy == "istiod":
+			identity.Problem = "pod is SPIRE-managed but its certificate was issued by istiod; the proxy could not reach the SPIRE agent socket or no ClusterSPIFFEID matches it"
+		}
+		if identity.Problem != "" {
+			report.Issues = append(report.Issues, fmt.Sprintf("Pod %s: %s", pod.Name, identity.Problem))
+		}
+		report.Identities = append(report.Identities, identity)
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// proxyWorkloadCertificate returns the leaf certificate of the proxy's "default" SDS secret
+func (m *Manager) proxyWorkloadCertificate(ctx context.Context, pod *corev1.Pod) (*x509.Certificate, error) {
+	body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/config_dump?resource=dynamic_active_secrets")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read proxy secrets: %w", err)
+	}
+	var dump struct {
+		Configs []struct {
+			Name   string `json:"name"`
+			Secret struct {
+				TLSCertificate struct {
+					CertificateChain struct {
+						InlineBytes string `json:"inline_bytes"`
+					} `json:"certificate_chain"`
+				} `json:"tls_certificate"`
+			} `json:"secret"`
+		} `json:"configs"`
+	}
+	if err := json.Unmarshal(body, &dump); err != nil {
+		return nil, fmt.Errorf("failed to parse proxy secrets: %w", err)
+	}
+	for _, config := range dump.Configs {
+		if config.Name != "default" {
+			continue
+		}
+		chain, err := base64.StdEncoding.DecodeString(config.Secret.TLSCertificate.CertificateChain.InlineBytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode certificate chain: %w", err)
+		}
+		block, _ := pem.Decode(chain)
+		if block == nil {
+			return nil, fmt.Errorf("no PEM certificate in the workload secret")
+		}
+		return x509.ParseCertificate(block.Bytes)
+	}
+	return nil, fmt.Errorf("proxy has no workload certificate yet; it may still be waiting for SDS")
+}
@@ -0,0 +1,495 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// nativeSidecarAnnotation opts a pod in or out of running istio-proxy as a Kubernetes native sidecar
+	nativeSidecarAnnotation = "sidecar.istio.io/nativeSidecar"
+	// nativeSidecarEnv is the istiod feature flag injecting istio-proxy as a native sidecar mesh-wide
+	nativeSidecarEnv = "ENABLE_NATIVE_SIDECARS"
+	// nativeSidecarMinMinor is the first Kubernetes minor version with the SidecarContainers feature on by default
+	nativeSidecarMinMinor = 29
+)
+
+// istioInitContainers are injected init containers that run before any app init container
+var istioInitContainers = map[string]bool{"istio-init": true, "istio-validation": true, "istio-proxy": true}
+
+// StartupOrderingWorkload is the sidecar startup ordering of one workload's pods
+type StartupOrderingWorkload struct {
+	Workload        string   `json:"workload"` // Kind/name
+	Namespace       string   `json:"namespace"`
+	Pods            int      `json:"pods"`
+	HoldApplication bool     `json:"hold_application"` // istio-proxy starts first and app containers wait until it is ready
+	NativeSidecar   bool     `json:"native_sidecar"`   // istio-proxy runs as a restartable init container
+	Job             bool     `json:"job,omitempty"`
+	AppRestarts     int32    `json:"app_restarts,omitempty"` // restarts of the app containers across the pods
+	Risk            string   `json:"risk"`                   // none, medium or high
+	Findings        []string `json:"findings,omitempty"`
+}
+
+// StartupOrderingMesh is the mesh-wide startup ordering configuration
+type StartupOrderingMesh struct {
+	HoldApplicationUntilProxyStarts bool   `json:"hold_application_until_proxy_starts"`
+	NativeSidecars                  bool   `json:"native_sidecars"` // ENABLE_NATIVE_SIDECARS on istiod
+	KubernetesVersion               string `json:"kubernetes_version,omitempty"`
+	NativeSidecarSupported          bool   `json:"native_sidecar_supported"`
+}
+
+// StartupOrderingAudit is the result of auditing and fixing sidecar startup ordering
+type StartupOrderingAudit struct {
+	Namespace string                    `json:"namespace"`
+	Mesh      StartupOrderingMesh       `json:"mesh"`
+	Workloads []StartupOrderingWorkload `json:"workloads"`
+	AtRisk    int                       `json:"at_risk"`
+	Fix       string                    `json:"fix,omitempty"`   // hold or native
+	Scope     string                    `json:"scope,omitempty"` // mesh or workloads
+	DryRun    bool                      `json:"dry_run,omitempty"`
+	Applied   []string                  `json:"applied,omitempty"`
+	Issues    []string                  `json:"issues,omitempty"`
+	Notes     []string                  `json:"notes,omitempty"`
+	Timestamp time.Time                 `json:"timestamp"`
+}
+
+// auditSidecarStartupParams are the arguments of audit_sidecar_startup
+type auditSidecarStartupParams struct {
+	Namespace      string   `json:"namespace,omitempty"`       // default: all namespaces
+	Fix            string   `json:"fix,omitempty"`             // hold or native, default: audit only
+	Workloads      []string `json:"workloads,omitempty"`       // Kind/name in namespace, default: mesh-wide
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Release        string   `json:"release,omitempty"`         // istiod Helm release, default: istiod
+	Revision       string   `json:"revision,omitempty"`        // control plane revision
+	RepoURL        string   `json:"repo_url,omitempty"`        // chart repository override
+	DryRun         bool     `json:"dry_run,omitempty"`         // only report the changes the fix would make
+	Timeout        string   `json:"timeout,omitempty"`         // default: 5m
+}
+
+// AuditSidecarStartup finds sidecar workloads whose app containers can start before Envoy is ready,
+// reports which use holdApplicationUntilProxyStarts or native sidecars, and optionally enables either
+// mesh-wide or for selected workloads
+func (m *Manager) AuditSidecarStartup(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params auditSidecarStartupParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.Release == "" {
+		params.Release = "istiod"
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	if params.Fix != "" && params.Fix != "hold" && params.Fix != "native" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid fix %q: use hold or native", params.Fix),
+				},
+			},
+		}, nil
+	}
+	if len(params.Workloads) > 0 && (params.Fix == "" || params.Namespace == "") {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "workloads requires fix and namespace",
+				},
+			},
+		}, nil
+	}
+
+	result := &StartupOrderingAudit{
+		Namespace: params.Namespace,
+		Fix:       params.Fix,
+		DryRun:    params.DryRun,
+		Timestamp: time.Now(),
+	}
+	if result.Namespace == "" {
+		result.Namespace = "all"
+	}
+
+	mesh, issues := m.startupOrderingMesh(ctx, params.IstioNamespace, params.Revision)
+	result.Mesh = mesh
+	result.Issues = append(result.Issues, issues...)
+
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to list pods: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Group sidecar pods by their top-level controller
+	workloads := make(map[string]*StartupOrderingWorkload)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !podHasSidecar(pod) || pod.Status.Phase == corev1.PodSucceeded {
+			continue
+		}
+		owner := m.podOwner(ctx, pod)
+		if strings.HasPrefix(owner, "Job/") {
+			// Jobs created by a CronJob are reported once, under the CronJob
+			job, err := m.k8sClient.Kubernetes.BatchV1().Jobs(pod.Namespace).Get(ctx, strings.TrimPrefix(owner, "Job/"), metav1.GetOptions{})
+			if err == nil {
+				if jobOwner := metav1.GetControllerOf(job); jobOwner != nil && jobOwner.Kind == "CronJob" {
+					owner = "CronJob/" + jobOwner.Name
+				}
+			}
+		}
+		if owner == "" {
+			owner = "Pod/" + pod.Name
+		}
+		key := pod.Namespace + "/" + owner
+		workload, exists := workloads[key]
+		if !exists {
+			workload = &StartupOrderingWorkload{Workload: owner, Namespace: pod.Namespace, HoldApplication: true, NativeSidecar: true}
+			workloads[key] = workload
+		}
+		hold, native := sidecarStartupOrdering(pod)
+		workload.Pods++
+		workload.HoldApplication = workload.HoldApplication && hold
+		workload.NativeSidecar = workload.NativeSidecar && native
+		workload.Job = workload.Job || podOwnedByJob(pod)
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != "istio-proxy" {
+				workload.AppRestarts += status.RestartCount
+			}
+		}
+		for _, container := range pod.Spec.InitContainers {
+			if !istioInitContainers[container.Name] && !native && !containsString(workload.Findings, appInitContainerFinding(container.Name)) {
+				workload.Findings = append(workload.Findings, appInitContainerFinding(container.Name))
+			}
+		}
+	}
+
+	for _, workload := range workloads {
+		assessStartupOrdering(workload)
+		if workload.Risk != "none" {
+			result.AtRisk++
+		}
+		result.Workloads = append(result.Workloads, *workload)
+	}
+	riskOrder := map[string]int{"high": 0, "medium": 1, "none": 2}
+	sort.Slice(result.Workloads, func(i, j int) bool {
+		a, b := result.Workloads[i], result.Workloads[j]
+		if riskOrder[a.Risk] != riskOrder[b.Risk] {
+			return riskOrder[a.Risk] < riskOrder[b.Risk]
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Workload < b.Workload
+	})
+
+	if params.Fix == "native" && !mesh.NativeSidecarSupported {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Native sidecars need Kubernetes 1.%d or later (cluster runs %s); use fix=hold instead", nativeSidecarMinMinor, mesh.KubernetesVersion),
+				},
+			},
+		}, nil
+	}
+
+	switch {
+	case params.Fix == "":
+		if result.AtRisk > 0 {
+			result.Notes = append(result.Notes, "Run again with fix=hold to start app containers only after istio-proxy is ready, or fix=native to also let Job pods complete")
+		}
+	case len(params.Workloads) == 0:
+		result.Scope = "mesh"
+		m.fixStartupOrderingMesh(ctx, result, params.IstioNamespace, params.Release, params.RepoURL, params.Timeout)
+	default:
+		result.Scope = "workloads"
+		for _, workload := range params.Workloads {
+			if !strings.Contains(workload, "/") {
+				workload = "Deployment/" + workload
+			}
+			if params.DryRun {
+				result.Applied = append(result.Applied, fmt.Sprintf("Would set %s on %s/%s", startupOrderingFixDescription(params.Fix), params.Namespace, workload))
+				continue
+			}
+			if err := m.updateWorkloadPodTemplate(ctx, params.Namespace, workload, func(template *corev1.PodTemplateSpec) error {
+				return applyStartupOrderingFix(template, params.Fix)
+			}); err != nil {
+				result.Issues = append(result.Issues, fmt.Sprintf("Failed to update %s/%s: %v", params.Namespace, workload, err))
+				continue
+			}
+			result.Applied = append(result.Applied, fmt.Sprintf("Set %s on %s/%s; its pods are replaced by a rollout", startupOrderingFixDescription(params.Fix), params.Namespace, workload))
+		}
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// startupOrderingMesh reads the mesh-wide hold setting, the istiod native sidecar flag and whether
+// the cluster supports native sidecars
+func (m *Manager) startupOrderingMesh(ctx context.Context, istioNamespace, revision string) (StartupOrderingMesh, []string) {
+	var mesh StartupOrderingMesh
+	var issues []string
+
+	var meshConfig struct {
+		DefaultConfig struct {
+			HoldApplicationUntilProxyStarts bool `json:"holdApplicationUntilProxyStarts"`
+		} `json:"defaultConfig"`
+	}
+	if err := m.readMeshConfig(ctx, istioNamespace, revision, &meshConfig); err != nil {
+		issues = append(issues, fmt.Sprintf("Failed to read mesh config: %v", err))
+	}
+	mesh.HoldApplicationUntilProxyStarts = meshConfig.DefaultConfig.HoldApplicationUntilProxyStarts
+
+	selector := "app=istiod"
+	if revision != "" && revision != defaultRevision {
+		selector += ",istio.io/rev=" + revision
+	}
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(istioNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		issues = append(issues, fmt.Sprintf("Failed to read istiod deployment: %v", err))
+	} else {
+		for _, deployment := range deployments.Items {
+			for _, container := range deployment.Spec.Template.Spec.Containers {
+				for _, env := range container.Env {
+					if env.Name == nativeSidecarEnv {
+						mesh.NativeSidecars, _ = strconv.ParseBool(env.Value)
+					}
+				}
+			}
+		}
+	}
+
+	version, err := m.k8sClient.Kubernetes.Discovery().ServerVersion()
+	if err != nil {
+		issues = append(issues, fmt.Sprintf("Failed to get server version: %v", err))
+	} else {
+		mesh.KubernetesVersion = version.GitVersion
+		minor, _ := strconv.Atoi(strings.TrimRight(version.Minor, "+"))
+		mesh.NativeSidecarSupported = version.Major == "1" && minor >= nativeSidecarMinMinor
+	}
+
+	return mesh, issues
+}
+
+// fixStartupOrderingMesh enables holdApplicationUntilProxyStarts or native sidecars on the istiod release
+func (m *Manager) fixStartupOrderingMesh(ctx context.Context, result *StartupOrderingAudit, istioNamespace, release, repoURL, timeout string) {
+	var err error
+	switch {
+	case result.Fix == "hold" && result.Mesh.HoldApplicationUntilProxyStarts, result.Fix == "native" && result.Mesh.NativeSidecars:
+		result.Notes = append(result.Notes, fmt.Sprintf("%s is already enabled mesh-wide", startupOrderingFixDescription(result.Fix)))
+		return
+	case result.DryRun:
+		result.Applied = append(result.Applied, fmt.Sprintf("Would set %s mesh-wide on the %s release", startupOrderingFixDescription(result.Fix), release))
+	case result.Fix == "hold":
+		err = m.setIstiodMeshConfig(ctx, istioNamespace, release, repoURL, "defaultConfig.holdApplicationUntilProxyStarts", true, timeout)
+	default:
+		err = m.setIstiodValues(ctx, istioNamespace, release, repoURL, map[string]interface{}{"pilot.env." + nativeSidecarEnv: "true"}, timeout)
+	}
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to enable %s: %v", startupOrderingFixDescription(result.Fix), err))
+		return
+	}
+	if !result.DryRun {
+		result.Applied = append(result.Applied, fmt.Sprintf("Set %s mesh-wide on the %s release", startupOrderingFixDescription(result.Fix), release))
+	}
+
+	// Injection happens at pod creation, so running pods keep their ordering until restarted
+	stale := 0
+	for _, workload := range result.Workloads {
+		if (result.Fix == "hold" && !workload.HoldApplication && !workload.NativeSidecar) || (result.Fix == "native" && !workload.NativeSidecar) {
+			stale++
+		}
+	}
+	if stale > 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("%d workloads keep their current startup ordering until their pods are restarted", stale))
+	}
+}
+
+// sidecarStartupOrdering reports whether a pod holds its app containers until istio-proxy is ready
+// and whether istio-proxy runs as a native sidecar
+func sidecarStartupOrdering(pod *corev1.Pod) (bool, bool) {
+	for _, container := range pod.Spec.InitContainers {
+		if container.Name == "istio-proxy" && container.RestartPolicy != nil && *container.RestartPolicy == corev1.ContainerRestartPolicyAlways {
+			// The kubelet starts app containers only after the sidecar's startup probe passes
+			return true, true
+		}
+	}
+	// With holdApplicationUntilProxyStarts, istio-proxy is injected first with a postStart hook that
+	// blocks until Envoy is ready
+	if len(pod.Spec.Containers) > 0 {
+		first := pod.Spec.Containers[0]
+		if first.Name == "istio-proxy" && first.Lifecycle != nil && first.Lifecycle.PostStart != nil {
+			return true, false
+		}
+	}
+	return false, false
+}
+
+// podOwnedByJob reports whether a pod was created by a Job
+func podOwnedByJob(pod *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(pod)
+	return owner != nil && owner.Kind == "Job"
+}
+
+// appInitContainerFinding explains why an app init container fails behind a classic sidecar
+func appInitContainerFinding(name string) string {
+	return fmt.Sprintf("Init container %s runs before istio-proxy starts; its outbound traffic is redirected to a proxy that is not running yet", name)
+}
+
+// assessStartupOrdering rates the startup ordering risk of a workload and explains it
+func assessStartupOrdering(workload *StartupOrderingWorkload) {
+	workload.Risk = "none"
+	if workload.NativeSidecar {
+		return
+	}
+	if len(workload.Findings) > 0 {
+		workload.Risk = "high"
+	}
+	if workload.Job {
+		workload.Risk = "high"
+		workload.Findings = append(workload.Findings, "Job pods never complete on their own because istio-proxy keeps running after the main container exits; native sidecars terminate with the job")
+	}
+	if workload.HoldApplication {
+		return
+	}
+	if workload.Risk == "none" {
+		workload.Risk = "medium"
+	}
+	finding := "App containers start in parallel with istio-proxy; requests made before Envoy is ready fail"
+	if workload.AppRestarts > 0 {
+		workload.Risk = "high"
+		finding += fmt.Sprintf(" (app containers restarted %d times)", workload.AppRestarts)
+	}
+	workload.Findings = append(workload.Findings, finding)
+}
+
+// startupOrderingFixDescription names the setting a fix enables
+func startupOrderingFixDescription(fix string) string {
+	if fix == "native" {
+		return "native sidecars"
+	}
+	return "holdApplicationUntilProxyStarts"
+}
+
+// applyStartupOrderingFix sets the per-pod override for a fix on a pod template
+func applyStartupOrderingFix(template *corev1.PodTemplateSpec, fix string) error {
+	if template.Annotations == nil {
+		template.Annotations = make(map[string]string)
+	}
+	if fix == "native" {
+		template.Annotations[nativeSidecarAnnotation] = "true"
+		return nil
+	}
+
+	config := make(map[string]interface{})
+	if err := yaml.Unmarshal([]byte(template.Annotations[proxyConfigAnnotation]), &config); err != nil {
+		return fmt.Errorf("failed to parse %s annotation: %w", proxyConfigAnnotation, err)
+	}
+	if config == nil {
+		config = make(map[string]interface{})
+	}
+	config["holdApplicationUntilProxyStarts"] = true
+	out, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	template.Annotations[proxyConfigAnnotation] = string(out)
+	return nil
+}
+
+// updateWorkloadPodTemplate applies a change to the pod template of a Deployment, StatefulSet,
+// DaemonSet or CronJob given as Kind/name, retrying on conflicts
+func (m *Manager) updateWorkloadPodTemplate(ctx context.Context, namespace, workload string, mutate func(*corev1.PodTemplateSpec) error) error {
+	kind, name, _ := strings.Cut(workload, "/")
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		switch kind {
+		case "Deployment":
+			current, err := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if err := mutate(&current.Spec.Template); err != nil {
+				return err
+			}
+			_, err = m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		case "StatefulSet":
+			current, err := m.k8sClient.Kubernetes.AppsV1().StatefulSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if err := mutate(&current.Spec.Template); err != nil {
+				return err
+			}
+			_, err = m.k8sClient.Kubernetes.AppsV1().StatefulSets(namespace).Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		case "DaemonSet":
+			current, err := m.k8sClient.Kubernetes.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if err := mutate(&current.Spec.Template); err != nil {
+				return err
+			}
+			_, err = m.k8sClient.Kubernetes.AppsV1().DaemonSets(namespace).Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		case "CronJob":
+			current, err := m.k8sClient.Kubernetes.BatchV1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if err := mutate(&current.Spec.JobTemplate.Spec.Template); err != nil {
+				return err
+			}
+			_, err = m.k8sClient.Kubernetes.BatchV1().CronJobs(namespace).Update(ctx, current, metav1.UpdateOptions{})
+			return err
+		case "Job":
+			return fmt.Errorf("the pod template of a Job is immutable; recreate the Job or fix its CronJob")
+		default:
+			return fmt.Errorf("unsupported workload kind %q: use Deployment, StatefulSet, DaemonSet or CronJob", kind)
+		}
+	})
+}
@@ -0,0 +1,318 @@
+package tools
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// defaultRunIDHeader is the request header generate_traffic tags requests with
+	defaultRunIDHeader = "x-meshpilot-run-id"
+	// maxTrafficRPS caps the request rate of generate_traffic; every request is a curl process in the source pod
+	maxTrafficRPS = 50
+	// maxTrafficDuration caps how long generate_traffic runs
+	maxTrafficDuration = 5 * time.Minute
+)
+
+// trafficScript starts one curl per interval in the background until the duration elapses, so slow responses
+// do not lower the rate; arguments are the duration in seconds, the interval and the curl arguments
+const trafficScript = `end=$(($(date +%s) + $1)); interval=$2; shift 2; seq=0
+while [ "$(date +%s)" -lt "$end" ]; do
+  seq=$((seq + 1))
+  curl "$@" -H "x-meshpilot-seq: $seq" &
+  sleep "$interval"
+done
+wait`
+
+// TrafficRun reports a tagged burst of traffic sent by generate_traffic
+type TrafficRun struct {
+	RunID         string         `json:"run_id"`
+	Header        string         `json:"header"`
+	UserAgent     string         `json:"user_agent"`
+	Source        PodInfo        `json:"source"`
+	Target        string         `json:"target"`
+	Method        string         `json:"method"`
+	RPS           int            `json:"rps"`
+	Duration      string         `json:"duration"`
+	RequestsSent  int            `json:"requests_sent"`
+	ResponseCodes map[string]int `json:"response_codes"`
+	P50Ms         float64        `json:"p50_ms,omitempty"`
+	P90Ms         float64        `json:"p90_ms,omitempty"`
+	P99Ms         float64        `json:"p99_ms,omitempty"`
+	MaxMs         float64        `json:"max_ms,omitempty"`
+	StartTime     time.Time      `json:"start_time"`
+	EndTime       time.Time      `json:"end_time"`
+	Filters       []string       `json:"filters"` // how to find this run's traffic in logs and metrics
+	Issues        []string       `json:"issues,omitempty"`
+}
+
+// generateTrafficParams are the arguments of generate_traffic
+type generateTrafficParams struct {
+	Service         string            `json:"service"`
+	Namespace       string            `json:"namespace,omitempty"`        // default: default
+	Port            int               `json:"port,omitempty"`             // default: first service port
+	Path            string            `json:"path,omitempty"`             // default: /
+	Method          string            `json:"method,omitempty"`           // default: GET
+	Headers         map[string]string `json:"headers,omitempty"`          // extra request headers
+	RPS             int               `json:"rps,omitempty"`              // default: 5
+	Duration        string            `json:"duration,omitempty"`         // default: 30s
+	RunID           string            `json:"run_id,omitempty"`           // default: generated
+	Header          string            `json:"header,omitempty"`           // default: x-meshpilot-run-id
+	SourcePod       string            `json:"source_pod,omitempty"`       // default: first app=sleep pod
+	SourceNamespace string            `json:"source_namespace,omitempty"` // default: namespace
+	SourceContainer string            `json:"source_container,omitempty"` // default: first non-proxy container
+	Timeout         int               `json:"timeout,omitempty"`          // seconds per request, default: 5
+}
+
+// GenerateTraffic sends requests at a fixed rate from a source pod to a service, tagging every request with a
+// run ID so the access logs and metrics of a diagnostic session can be narrowed to exactly this traffic
+func (m *Manager) GenerateTraffic(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params generateTrafficParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Service == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "service is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.Method == "" {
+		params.Method = "GET"
+	}
+	if params.RPS == 0 {
+		params.RPS = 5
+	}
+	if params.Duration == "" {
+		params.Duration = "30s"
+	}
+	if params.RunID == "" {
+		params.RunID = newTrafficRunID()
+	}
+	if params.Header == "" {
+		params.Header = defaultRunIDHeader
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = 5
+	}
+	params.Method = strings.ToUpper(params.Method)
+
+	duration, err := time.ParseDuration(params.Duration)
+	if err != nil || duration < time.Second || duration > maxTrafficDuration {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid duration %q: must be between 1s and %s", params.Duration, maxTrafficDuration),
+				},
+			},
+		}, nil
+	}
+	if params.RPS < 1 || params.RPS > maxTrafficRPS {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid rps %d: must be between 1 and %d", params.RPS, maxTrafficRPS),
+				},
+			},
+		}, nil
+	}
+
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Get(ctx, params.Service, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get service: %v", err),
+				},
+			},
+		}, nil
+	}
+	if params.Port == 0 {
+		if len(svc.Spec.Ports) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Service %s/%s has no ports", svc.Namespace, svc.Name),
+					},
+				},
+			}, nil
+		}
+		params.Port = int(svc.Spec.Ports[0].Port)
+	}
+
+	source, err := m.findWaypointSource(ctx, params.SourceNamespace, params.SourcePod)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if params.SourceContainer == "" {
+		for _, container := range source.Spec.Containers {
+			if container.Name != "istio-proxy" {
+				params.SourceContainer = container.Name
+				break
+			}
+		}
+	}
+
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+	result := &TrafficRun{
+		RunID:     params.RunID,
+		Header:    params.Header,
+		UserAgent: "meshpilot-traffic/" + params.RunID,
+		Source: PodInfo{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			IP:        source.Status.PodIP,
+			Node:      source.Spec.NodeName,
+		},
+		Target:        fmt.Sprintf("http://%s:%d%s", host, params.Port, params.Path),
+		Method:        params.Method,
+		RPS:           params.RPS,
+		Duration:      duration.String(),
+		ResponseCodes: make(map[string]int),
+	}
+
+	// The run ID goes into the User-Agent as well, which Istio's default access log format records
+	curlArgs := []string{"-s", "-o", "/dev/null", "-w", "%{http_code} %{time_total}\\n",
+		"--max-time", strconv.Itoa(params.Timeout), "-X", params.Method,
+		"-A", result.UserAgent, "-H", fmt.Sprintf("%s: %s", params.Header, params.RunID)}
+	for name, value := range params.Headers {
+		curlArgs = append(curlArgs, "-H", fmt.Sprintf("%s: %s", name, value))
+	}
+	curlArgs = append(curlArgs, result.Target)
+
+	command := append([]string{"sh", "-c", trafficScript, "sh",
+		strconv.Itoa(int(duration.Seconds())), fmt.Sprintf("%.3f", 1/float64(params.RPS))}, curlArgs...)
+
+	execCtx, cancel := context.WithTimeout(ctx, duration+time.Duration(params.Timeout)*time.Second+30*time.Second)
+	defer cancel()
+	result.StartTime = time.Now()
+	output, err := m.execCommandInPod(execCtx, source.Namespace, source.Name, params.SourceContainer, command)
+	result.EndTime = time.Now()
+	if err != nil && output == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to generate traffic from %s/%s (the source container needs sh and curl): %v", source.Namespace, source.Name, err),
+				},
+			},
+		}, nil
+	}
+
+	var latencies []float64
+	for _, line := range strings.Split(strings.TrimSpace(output), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		result.RequestsSent++
+		result.ResponseCodes[fields[0]]++
+		if fields[0] == "000" {
+			continue
+		}
+		if seconds, err := strconv.ParseFloat(fields[1], 64); err == nil {
+			latencies = append(latencies, seconds*1000)
+		}
+	}
+	if len(latencies) > 0 {
+		sort.Float64s(latencies)
+		result.P50Ms = latencyPercentile(latencies, 50)
+		result.P90Ms = latencyPercentile(latencies, 90)
+		result.P99Ms = latencyPercentile(latencies, 99)
+		result.MaxMs = latencies[len(latencies)-1]
+	}
+
+	window := fmt.Sprintf("%s to %s", result.StartTime.UTC().Format(time.RFC3339), result.EndTime.UTC().Format(time.RFC3339))
+	result.Filters = []string{
+		fmt.Sprintf("Access logs: match user agent %q in the source and destination istio-proxy logs", result.UserAgent),
+		fmt.Sprintf("Request headers: every request carries %s: %s and a per-run sequence number in x-meshpilot-seq", params.Header, params.RunID),
+		fmt.Sprintf("Metrics: Istio metrics carry no request headers; select source_workload and destination_service %s within %s", host, window),
+	}
+
+	if result.RequestsSent == 0 {
+		result.Issues = append(result.Issues, fmt.Sprintf("No requests were recorded; check that %s/%s has sh, curl and date", source.Namespace, source.Name))
+	} else if failed := result.ResponseCodes["000"]; failed > 0 {
+		result.Issues = append(result.Issues, fmt.Sprintf("%d of %d requests received no response", failed, result.RequestsSent))
+	}
+	if expected := int(duration.Seconds()) * params.RPS; result.RequestsSent > 0 && result.RequestsSent < expected*9/10 {
+		result.Issues = append(result.Issues, fmt.Sprintf("Sent %d requests instead of about %d; the source pod could not keep up with %d rps", result.RequestsSent, expected, params.RPS))
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// newTrafficRunID returns a run ID that sorts by start time and is unique across concurrent runs
+func newTrafficRunID() string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+	return fmt.Sprintf("run-%s-%s", time.Now().UTC().Format("20060102-150405"), hex.EncodeToString(suffix))
+}
+
+// latencyPercentile returns the nearest-rank percentile of sorted latencies
+func latencyPercentile(sorted []float64, percentile int) float64 {
+	rank := (len(sorted)*percentile + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
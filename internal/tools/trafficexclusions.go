@@ -0,0 +1,426 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+const (
+	excludeInboundPortsAnnotation   = "traffic.sidecar.istio.io/excludeInboundPorts"
+	excludeOutboundPortsAnnotation  = "traffic.sidecar.istio.io/excludeOutboundPorts"
+	excludeOutboundRangesAnnotation = "traffic.sidecar.istio.io/excludeOutboundIPRanges"
+	includeInboundPortsAnnotation   = "traffic.sidecar.istio.io/includeInboundPorts"
+	interceptionModeAnnotation      = "sidecar.istio.io/interceptionMode"
+)
+
+// ExclusionCheck is whether one requested exclusion was found in the pod's iptables rules
+type ExclusionCheck struct {
+	Kind     string `json:"kind"` // inbound_port, outbound_port or outbound_cidr
+	Value    string `json:"value"`
+	Verified bool   `json:"verified"`
+	Rule     string `json:"rule,omitempty"`
+}
+
+// TrafficExclusionResult reports the annotations applied to a workload and whether its new pods honor them
+type TrafficExclusionResult struct {
+	Deployment  string            `json:"deployment"`
+	Namespace   string            `json:"namespace"`
+	Annotations map[string]string `json:"annotations"`
+	Changed     bool              `json:"changed"`
+	RolledOut   bool              `json:"rolled_out"`
+	Pod         string            `json:"verified_pod,omitempty"`
+	Checks      []ExclusionCheck  `json:"checks,omitempty"`
+	Issues      []string          `json:"issues,omitempty"`
+	Notes       []string          `json:"notes,omitempty"`
+	Timestamp   time.Time         `json:"timestamp"`
+}
+
+// configureTrafficExclusionsParams are the arguments of configure_traffic_exclusions
+type configureTrafficExclusionsParams struct {
+	Deployment             string   `json:"deployment"`
+	Namespace              string   `json:"namespace,omitempty"`                  // default: default
+	ExcludeInboundPorts    []int    `json:"exclude_inbound_ports,omitempty"`      // ports that bypass the sidecar on the way in
+	ExcludeOutboundPorts   []int    `json:"exclude_outbound_ports,omitempty"`     // destination ports that bypass the sidecar
+	ExcludeOutboundIPRange []string `json:"exclude_outbound_ip_ranges,omitempty"` // destination CIDRs that bypass the sidecar
+	Replace                bool     `json:"replace,omitempty"`                    // replace existing exclusions instead of adding to them
+	Verify                 *bool    `json:"verify,omitempty"`                     // default: true
+	Timeout                string   `json:"timeout,omitempty"`                    // rollout timeout, default: 5m
+}
+
+// ConfigureTrafficExclusions sets sidecar interception exclusions on a deployment through annotations,
+// waits for the rollout and verifies the exclusions in the iptables rules of a new pod
+func (m *Manager) ConfigureTrafficExclusions(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params configureTrafficExclusionsParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Deployment == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "deployment is required",
+				},
+			},
+		}, nil
+	}
+	if len(params.ExcludeInboundPorts) == 0 && len(params.ExcludeOutboundPorts) == 0 && len(params.ExcludeOutboundIPRange) == 0 && !params.Replace {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "At least one of exclude_inbound_ports, exclude_outbound_ports or exclude_outbound_ip_ranges is required (or replace to clear them)",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Verify == nil {
+		params.Verify = boolPtr(true)
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	// Reject bad values before touching the workload
+	for _, port := range append(append([]int{}, params.ExcludeInboundPorts...), params.ExcludeOutboundPorts...) {
+		if port < 1 || port > 65535 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid port %d", port),
+					},
+				},
+			}, nil
+		}
+	}
+	var cidrs []string
+	for _, cidr := range params.ExcludeOutboundIPRange {
+		normalized, err := normalizeCIDR(cidr)
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Invalid CIDR %q: %v", cidr, err),
+					},
+				},
+			}, nil
+		}
+		cidrs = append(cidrs, normalized)
+	}
+
+	result := &TrafficExclusionResult{
+		Deployment:  params.Deployment,
+		Namespace:   params.Namespace,
+		Annotations: make(map[string]string),
+		Timestamp:   time.Now(),
+	}
+
+	var deployment *appsv1.Deployment
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Get(ctx, params.Deployment, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+		annotations := current.Spec.Template.Annotations
+		if annotations == nil {
+			annotations = make(map[string]string)
+		}
+
+		desired := map[string]string{
+			excludeInboundPortsAnnotation:   mergeExclusions(annotations[excludeInboundPortsAnnotation], formatPorts(params.ExcludeInboundPorts), params.Replace),
+			excludeOutboundPortsAnnotation:  mergeExclusions(annotations[excludeOutboundPortsAnnotation], formatPorts(params.ExcludeOutboundPorts), params.Replace),
+			excludeOutboundRangesAnnotation: mergeExclusions(annotations[excludeOutboundRangesAnnotation], cidrs, params.Replace),
+		}
+		result.Changed = false
+		for key, value := range desired {
+			if value == annotations[key] {
+				if value != "" {
+					result.Annotations[key] = value
+				}
+				continue
+			}
+			result.Changed = true
+			if value == "" {
+				delete(annotations, key)
+				continue
+			}
+			annotations[key] = value
+			result.Annotations[key] = value
+		}
+		current.Spec.Template.Annotations = annotations
+		deployment = current
+		if !result.Changed {
+			return nil
+		}
+		updated, err := m.k8sClient.Kubernetes.AppsV1().Deployments(params.Namespace).Update(ctx, current, metav1.UpdateOptions{})
+		if err == nil {
+			deployment = updated
+		}
+		return err
+	})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to update deployment: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	annotations := deployment.Spec.Template.Annotations
+	if include, ok := annotations[includeInboundPortsAnnotation]; ok && strings.TrimSpace(include) != "*" && len(params.ExcludeInboundPorts) > 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("%s is %q, so only those ports are redirected and inbound exclusions have no effect", includeInboundPortsAnnotation, include))
+	}
+	if !result.Changed {
+		result.Notes = append(result.Notes, "The exclusions were already set; the deployment was not changed")
+	}
+
+	// Changing pod template annotations already triggers a rollout; wait for it to finish
+	if err := m.waitForRollout(ctx, deployment, timeout); err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Rollout did not complete: %v", err))
+	} else {
+		result.RolledOut = true
+	}
+
+	if *params.Verify && result.RolledOut {
+		m.verifyTrafficExclusions(ctx, deployment, params.ExcludeInboundPorts, params.ExcludeOutboundPorts, cidrs, result)
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// waitForRollout waits until every replica of a deployment runs its latest template
+func (m *Manager) waitForRollout(ctx context.Context, deployment *appsv1.Deployment, timeout time.Duration) error {
+	pending := ""
+	err := wait.PollUntilContextTimeout(ctx, 2*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		current, err := m.k8sClient.Kubernetes.AppsV1().Deployments(deployment.Namespace).Get(ctx, deployment.Name, metav1.GetOptions{})
+		if err != nil {
+			return false, err
+		}
+		replicas := int32(1)
+		if current.Spec.Replicas != nil {
+			replicas = *current.Spec.Replicas
+		}
+		status := current.Status
+		switch {
+		case status.ObservedGeneration < current.Generation:
+			pending = "the controller has not observed the update"
+		case status.UpdatedReplicas < replicas:
+			pending = fmt.Sprintf("%d of %d replicas updated", status.UpdatedReplicas, replicas)
+		case status.Replicas > status.UpdatedReplicas:
+			pending = fmt.Sprintf("%d old replicas pending termination", status.Replicas-status.UpdatedReplicas)
+		case status.AvailableReplicas < status.UpdatedReplicas:
+			pending = fmt.Sprintf("%d of %d updated replicas available", status.AvailableReplicas, status.UpdatedReplicas)
+		default:
+			return true, nil
+		}
+		return false, nil
+	})
+	if err != nil && pending != "" {
+		return fmt.Errorf("timed out after %s: %s", timeout, pending)
+	}
+	return err
+}
+
+// verifyTrafficExclusions finds a pod of the new template and checks its iptables rules for each exclusion
+func (m *Manager) verifyTrafficExclusions(ctx context.Context, deployment *appsv1.Deployment, inbound, outbound []int, cidrs []string, result *TrafficExclusionResult) {
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(deployment.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(deployment.Spec.Selector.MatchLabels).String(),
+	})
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Failed to list pods: %v", err))
+		return
+	}
+
+	// The injector adds its own ports to the annotations, so match pods whose values cover ours
+	var pod *corev1.Pod
+	for i := range pods.Items {
+		candidate := &pods.Items[i]
+		if candidate.Status.Phase != corev1.PodRunning || candidate.DeletionTimestamp != nil {
+			continue
+		}
+		if annotationCovers(candidate.Annotations[excludeInboundPortsAnnotation], formatPorts(inbound)) &&
+			annotationCovers(candidate.Annotations[excludeOutboundPortsAnnotation], formatPorts(outbound)) &&
+			annotationCovers(candidate.Annotations[excludeOutboundRangesAnnotation], cidrs) {
+			pod = candidate
+			break
+		}
+	}
+	if pod == nil {
+		result.Issues = append(result.Issues, "No running pod carries the new annotations; the injector may have dropped them")
+		return
+	}
+	result.Pod = pod.Name
+
+	switch mode := podInterceptionMode(pod, ""); mode {
+	case "none", "ambient":
+		result.Issues = append(result.Issues, fmt.Sprintf("Pod %s has no sidecar (mode: %s); traffic.sidecar.istio.io annotations only apply to sidecars", pod.Name, mode))
+		return
+	}
+
+	// TPROXY mode programs inbound redirection in the mangle table
+	tables := []string{"nat"}
+	if pod.Annotations[interceptionModeAnnotation] == "TPROXY" {
+		tables = append(tables, "mangle")
+	}
+	image, securityContext, err := m.prepareIptablesDebug(ctx, pod)
+	if err != nil {
+		result.Issues = append(result.Issues, fmt.Sprintf("Cannot read iptables rules from %s: %v", pod.Name, err))
+		return
+	}
+	var rules []string
+	for _, table := range tables {
+		output, err := m.getIptablesWithDebug(ctx, pod.Namespace, pod.Name, table, []string{"-t", table, "-S"}, image, securityContext)
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to read %s iptables rules from %s: %v", table, pod.Name, err))
+			return
+		}
+		rules = append(rules, strings.Split(output, "\n")...)
+	}
+
+	for _, port := range inbound {
+		result.Checks = append(result.Checks, findExclusionRule(rules, "inbound_port", strconv.Itoa(port), "ISTIO_INBOUND", "--dport "+strconv.Itoa(port)))
+	}
+	for _, port := range outbound {
+		result.Checks = append(result.Checks, findExclusionRule(rules, "outbound_port", strconv.Itoa(port), "ISTIO_OUTPUT", "--dport "+strconv.Itoa(port)))
+	}
+	for _, cidr := range cidrs {
+		if strings.Contains(cidr, ":") {
+			result.Notes = append(result.Notes, fmt.Sprintf("%s is an IPv6 range and is programmed in ip6tables, which is not checked", cidr))
+			continue
+		}
+		result.Checks = append(result.Checks, findExclusionRule(rules, "outbound_cidr", cidr, "ISTIO_OUTPUT", "-d "+cidr))
+	}
+	for _, check := range result.Checks {
+		if !check.Verified {
+			result.Issues = append(result.Issues, fmt.Sprintf("No RETURN rule found for %s %s in pod %s", strings.ReplaceAll(check.Kind, "_", " "), check.Value, pod.Name))
+		}
+	}
+}
+
+// findExclusionRule looks for a RETURN rule in a chain matching the given criterion
+func findExclusionRule(rules []string, kind, value, chain, match string) ExclusionCheck {
+	check := ExclusionCheck{Kind: kind, Value: value}
+	for _, rule := range rules {
+		rule = strings.TrimSpace(rule)
+		fields := " " + rule + " "
+		if strings.HasPrefix(rule, "-A "+chain+" ") && strings.Contains(fields, " "+match+" ") && strings.Contains(fields, " -j RETURN ") {
+			check.Verified = true
+			check.Rule = rule
+			break
+		}
+	}
+	return check
+}
+
+// mergeExclusions combines an existing comma-separated annotation value with new entries
+func mergeExclusions(existing string, additions []string, replace bool) string {
+	var values []string
+	if !replace {
+		values = splitList(existing)
+	}
+	for _, value := range additions {
+		if !containsString(values, value) {
+			values = append(values, value)
+		}
+	}
+	return strings.Join(values, ",")
+}
+
+// annotationCovers reports whether a comma-separated annotation value includes all the wanted entries
+func annotationCovers(value string, wanted []string) bool {
+	have := splitList(value)
+	for _, want := range wanted {
+		if !containsString(have, want) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatPorts converts ports to sorted strings for annotations
+func formatPorts(ports []int) []string {
+	sorted := append([]int{}, ports...)
+	sort.Ints(sorted)
+	var values []string
+	for _, port := range sorted {
+		values = append(values, strconv.Itoa(port))
+	}
+	return values
+}
+
+// normalizeCIDR returns a CIDR in the form iptables prints it, treating bare addresses as single hosts
+func normalizeCIDR(value string) (string, error) {
+	if !strings.Contains(value, "/") {
+		ip := net.ParseIP(value)
+		if ip == nil {
+			return "", fmt.Errorf("not an IP address or CIDR")
+		}
+		if ip.To4() != nil {
+			return ip.String() + "/32", nil
+		}
+		return ip.String() + "/128", nil
+	}
+	_, network, err := net.ParseCIDR(value)
+	if err != nil {
+		return "", err
+	}
+	return network.String(), nil
+}
@@ -0,0 +1,872 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	apinetworkingv1beta1 "istio.io/api/networking/v1beta1"
+	networkingv1beta1 "istio.io/client-go/pkg/apis/networking/v1beta1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// TrafficRoute is a weighted destination of a VirtualService's default HTTP route
+type TrafficRoute struct {
+	Host   string `json:"host"`
+	Subset string `json:"subset,omitempty"`
+	Port   uint32 `json:"port,omitempty"`
+	Weight int32  `json:"weight,omitempty"`
+}
+
+// TrafficSubset is a named subset of a DestinationRule host
+type TrafficSubset struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+}
+
+// TrafficResource is a VirtualService or DestinationRule as returned by the traffic management tools
+type TrafficResource struct {
+	Kind            string            `json:"kind"`
+	Name            string            `json:"name"`
+	Namespace       string            `json:"namespace"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	ResourceVersion string            `json:"resource_version,omitempty"`
+	Created         *time.Time        `json:"created,omitempty"`
+	Spec            json.RawMessage   `json:"spec"`
+}
+
+// TrafficChangeResult is the result of creating, updating or deleting a traffic resource
+type TrafficChangeResult struct {
+	Action   string           `json:"action"` // created, updated or deleted
+	DryRun   bool             `json:"dry_run,omitempty"`
+	Resource *TrafficResource `json:"resource,omitempty"`
+	Kind     string           `json:"kind,omitempty"`
+	Name     string           `json:"name,omitempty"`
+}
+
+// createVirtualServiceParams are the arguments of create_virtual_service
+type createVirtualServiceParams struct {
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace,omitempty"` // default: default
+	Hosts     []string               `json:"hosts,omitempty"`
+	Gateways  []string               `json:"gateways,omitempty"`
+	Routes    []TrafficRoute         `json:"routes,omitempty"`  // destinations of the default HTTP route
+	Timeout   string                 `json:"timeout,omitempty"` // request timeout of the default route
+	Spec      map[string]interface{} `json:"spec,omitempty"`    // full spec, replaces the fields above
+	DryRun    bool                   `json:"dry_run,omitempty"` // validate server-side without persisting
+}
+
+// CreateVirtualService creates or updates a VirtualService from a full spec or from hosts, gateways
+// and weighted destinations
+func (m *Manager) CreateVirtualService(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params createVirtualServiceParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	if params.Name == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "name is required",
+				},
+			},
+		}, nil
+	}
+
+	spec := params.Spec
+	if spec == nil {
+		if len(params.Hosts) == 0 || len(params.Routes) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "hosts and routes are required unless spec is given",
+					},
+				},
+			}, nil
+		}
+		var destinations []interface{}
+		for _, route := range params.Routes {
+			destination := map[string]interface{}{"host": route.Host}
+			if route.Subset != "" {
+				destination["subset"] = route.Subset
+			}
+			if route.Port != 0 {
+				destination["port"] = map[string]interface{}{"number": route.Port}
+			}
+			entry := map[string]interface{}{"destination": destination}
+			if route.Weight != 0 {
+				entry["weight"] = route.Weight
+			}
+			destinations = append(destinations, entry)
+		}
+		httpRoute := map[string]interface{}{"route": destinations}
+		if params.Timeout != "" {
+			httpRoute["timeout"] = params.Timeout
+		}
+		spec = map[string]interface{}{
+			"hosts": params.Hosts,
+			"http":  []interface{}{httpRoute},
+		}
+		if len(params.Gateways) > 0 {
+			spec["gateways"] = params.Gateways
+		}
+	}
+
+	ctx = withManagingTool(ctx, "create_virtual_service")
+
+	vs := &networkingv1beta1.VirtualService{
+		ObjectMeta: metav1.ObjectMeta{Name: params.Name, Namespace: params.Namespace},
+	}
+	if err := decodeTrafficSpec(spec, &vs.Spec); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid VirtualService spec: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	client := m.k8sClient.Istio.NetworkingV1beta1().VirtualServices(params.Namespace)
+	result := &TrafficChangeResult{Action: "created", DryRun: params.DryRun}
+	existing, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		// Keep the existing metadata so labels and annotations set by others survive the update
+		existing.Spec.Reset()
+		proto.Merge(&existing.Spec, &vs.Spec)
+		vs, err = client.Update(ctx, existing, metav1.UpdateOptions{DryRun: trafficDryRun(params.DryRun)})
+		result.Action = "updated"
+	case errors.IsNotFound(err):
+		markManaged(ctx, vs)
+		vs, err = client.Create(ctx, vs, metav1.CreateOptions{DryRun: trafficDryRun(params.DryRun)})
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply VirtualService %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+	result.Resource = virtualServiceResource(vs)
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getVirtualServiceParams are the arguments of get_virtual_service
+type getVirtualServiceParams struct {
+	Name      string `json:"name,omitempty"`      // default: all in the namespace
+	Namespace string `json:"namespace,omitempty"` // default: default
+}
+
+// GetVirtualService returns a VirtualService, or every VirtualService in a namespace when no name is given
+func (m *Manager) GetVirtualService(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getVirtualServiceParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	client := m.k8sClient.Istio.NetworkingV1beta1().VirtualServices(params.Namespace)
+
+	var result interface{}
+	if params.Name != "" {
+		vs, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get VirtualService %s/%s: %v", params.Namespace, params.Name, err),
+					},
+				},
+			}, nil
+		}
+		result = virtualServiceResource(vs)
+	} else {
+		list, err := client.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list VirtualServices: %v", err),
+					},
+				},
+			}, nil
+		}
+		resources := []*TrafficResource{}
+		for _, vs := range list.Items {
+			resources = append(resources, virtualServiceResource(vs))
+		}
+		result = resources
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// deleteVirtualServiceParams are the arguments of delete_virtual_service
+type deleteVirtualServiceParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // default: default
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
+
+// DeleteVirtualService deletes a VirtualService
+func (m *Manager) DeleteVirtualService(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deleteVirtualServiceParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	err := m.k8sClient.Istio.NetworkingV1beta1().VirtualServices(params.Namespace).Delete(ctx, params.Name, metav1.DeleteOptions{DryRun: trafficDryRun(params.DryRun)})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to delete VirtualService %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+
+	result := &TrafficChangeResult{Action: "deleted", DryRun: params.DryRun, Kind: "VirtualService", Name: params.Namespace + "/" + params.Name}
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// createDestinationRuleParams are the arguments of create_destination_rule
+type createDestinationRuleParams struct {
+	Name          string                 `json:"name"`
+	Namespace     string                 `json:"namespace,omitempty"` // default: default
+	Host          string                 `json:"host,omitempty"`
+	Subsets       []TrafficSubset        `json:"subsets,omitempty"`
+	LoadBalancer  string                 `json:"load_balancer,omitempty"`  // ROUND_ROBIN, LEAST_REQUEST, RANDOM or PASSTHROUGH
+	TLSMode       string                 `json:"tls_mode,omitempty"`       // DISABLE, SIMPLE, MUTUAL or ISTIO_MUTUAL
+	TrafficPolicy map[string]interface{} `json:"traffic_policy,omitempty"` // merged over load_balancer and tls_mode
+	Spec          map[string]interface{} `json:"spec,omitempty"`           // full spec, replaces the fields above
+	DryRun        bool                   `json:"dry_run,omitempty"`        // validate server-side without persisting
+}
+
+// CreateDestinationRule creates or updates a DestinationRule from a full spec or from a host,
+// subsets and common traffic policy settings
+func (m *Manager) CreateDestinationRule(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params createDestinationRuleParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	if params.Name == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "name is required",
+				},
+			},
+		}, nil
+	}
+
+	spec := params.Spec
+	if spec == nil {
+		if params.Host == "" {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: "host is required unless spec is given",
+					},
+				},
+			}, nil
+		}
+		spec = map[string]interface{}{"host": params.Host}
+		var subsets []interface{}
+		for _, subset := range params.Subsets {
+			subsets = append(subsets, map[string]interface{}{"name": subset.Name, "labels": subset.Labels})
+		}
+		if len(subsets) > 0 {
+			spec["subsets"] = subsets
+		}
+		policy := make(map[string]interface{})
+		if params.LoadBalancer != "" {
+			policy["loadBalancer"] = map[string]interface{}{"simple": params.LoadBalancer}
+		}
+		if params.TLSMode != "" {
+			policy["tls"] = map[string]interface{}{"mode": params.TLSMode}
+		}
+		for key, value := range params.TrafficPolicy {
+			policy[key] = value
+		}
+		if len(policy) > 0 {
+			spec["trafficPolicy"] = policy
+		}
+	}
+
+	ctx = withManagingTool(ctx, "create_destination_rule")
+
+	dr := &networkingv1beta1.DestinationRule{
+		ObjectMeta: metav1.ObjectMeta{Name: params.Name, Namespace: params.Namespace},
+	}
+	if err := decodeTrafficSpec(spec, &dr.Spec); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid DestinationRule spec: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	client := m.k8sClient.Istio.NetworkingV1beta1().DestinationRules(params.Namespace)
+	result := &TrafficChangeResult{Action: "created", DryRun: params.DryRun}
+	existing, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		// Keep the existing metadata so labels and annotations set by others survive the update
+		existing.Spec.Reset()
+		proto.Merge(&existing.Spec, &dr.Spec)
+		dr, err = client.Update(ctx, existing, metav1.UpdateOptions{DryRun: trafficDryRun(params.DryRun)})
+		result.Action = "updated"
+	case errors.IsNotFound(err):
+		markManaged(ctx, dr)
+		dr, err = client.Create(ctx, dr, metav1.CreateOptions{DryRun: trafficDryRun(params.DryRun)})
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply DestinationRule %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+	result.Resource = destinationRuleResource(dr)
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// getDestinationRuleParams are the arguments of get_destination_rule
+type getDestinationRuleParams struct {
+	Name      string `json:"name,omitempty"`      // default: all in the namespace
+	Namespace string `json:"namespace,omitempty"` // default: default
+}
+
+// GetDestinationRule returns a DestinationRule, or every DestinationRule in a namespace when no name is given
+func (m *Manager) GetDestinationRule(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getDestinationRuleParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	client := m.k8sClient.Istio.NetworkingV1beta1().DestinationRules(params.Namespace)
+
+	var result interface{}
+	if params.Name != "" {
+		dr, err := client.Get(ctx, params.Name, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get DestinationRule %s/%s: %v", params.Namespace, params.Name, err),
+					},
+				},
+			}, nil
+		}
+		result = destinationRuleResource(dr)
+	} else {
+		list, err := client.List(ctx, metav1.ListOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list DestinationRules: %v", err),
+					},
+				},
+			}, nil
+		}
+		resources := []*TrafficResource{}
+		for _, dr := range list.Items {
+			resources = append(resources, destinationRuleResource(dr))
+		}
+		result = resources
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// deleteDestinationRuleParams are the arguments of delete_destination_rule
+type deleteDestinationRuleParams struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"` // default: default
+	DryRun    bool   `json:"dry_run,omitempty"`
+}
+
+// DeleteDestinationRule deletes a DestinationRule
+func (m *Manager) DeleteDestinationRule(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params deleteDestinationRuleParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+
+	err := m.k8sClient.Istio.NetworkingV1beta1().DestinationRules(params.Namespace).Delete(ctx, params.Name, metav1.DeleteOptions{DryRun: trafficDryRun(params.DryRun)})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to delete DestinationRule %s/%s: %v", params.Namespace, params.Name, err),
+				},
+			},
+		}, nil
+	}
+
+	result := &TrafficChangeResult{Action: "deleted", DryRun: params.DryRun, Kind: "DestinationRule", Name: params.Namespace + "/" + params.Name}
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// decodeTrafficSpec decodes a spec into its Istio API message, rejecting unknown fields and invalid
+// enum values before anything reaches the API server
+func decodeTrafficSpec(spec map[string]interface{}, into proto.Message) error {
+	data, err := json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	return protojson.Unmarshal(data, into)
+}
+
+// trafficDryRun returns the API server dry-run option
+func trafficDryRun(dryRun bool) []string {
+	if dryRun {
+		return []string{metav1.DryRunAll}
+	}
+	return nil
+}
+
+// virtualServiceResource converts a VirtualService for tool output
+func virtualServiceResource(vs *networkingv1beta1.VirtualService) *TrafficResource {
+	spec, _ := vs.Spec.MarshalJSON()
+	return trafficResource("VirtualService", vs.ObjectMeta, spec)
+}
+
+// destinationRuleResource converts a DestinationRule for tool output
+func destinationRuleResource(dr *networkingv1beta1.DestinationRule) *TrafficResource {
+	spec, _ := dr.Spec.MarshalJSON()
+	return trafficResource("DestinationRule", dr.ObjectMeta, spec)
+}
+
+// trafficResource builds the tool output for a traffic resource
+func trafficResource(kind string, meta metav1.ObjectMeta, spec []byte) *TrafficResource {
+	resource := &TrafficResource{
+		Kind:            kind,
+		Name:            meta.Name,
+		Namespace:       meta.Namespace,
+		Labels:          meta.Labels,
+		ResourceVersion: meta.ResourceVersion,
+		Spec:            json.RawMessage(spec),
+	}
+	if !meta.CreationTimestamp.IsZero() {
+		created := meta.CreationTimestamp.Time
+		resource.Created = &created
+	}
+	if len(spec) == 0 {
+		resource.Spec = json.RawMessage("{}")
+	}
+	return resource
+}
+
+// TrafficShiftResult reports the routing applied by traffic_shift
+type TrafficShiftResult struct {
+	Service         string           `json:"service"`
+	Namespace       string           `json:"namespace"`
+	Weights         map[string]int32 `json:"weights"` // subset -> percent of requests
+	SubsetPods      map[string]int   `json:"subset_pods"`
+	DryRun          bool             `json:"dry_run,omitempty"`
+	DestinationRule *TrafficResource `json:"destination_rule,omitempty"`
+	VirtualService  *TrafficResource `json:"virtual_service,omitempty"`
+	Actions         []string         `json:"actions"`
+	Issues          []string         `json:"issues,omitempty"`
+}
+
+// trafficShiftParams are the arguments of traffic_shift
+type trafficShiftParams struct {
+	Service     string `json:"service"`
+	Namespace   string `json:"namespace,omitempty"`    // default: default
+	From        string `json:"from,omitempty"`         // default: v1
+	To          string `json:"to,omitempty"`           // default: v2
+	Weight      *int32 `json:"weight"`                 // percent of requests sent to the "to" subset
+	SubsetLabel string `json:"subset_label,omitempty"` // default: version
+	DryRun      bool   `json:"dry_run,omitempty"`
+}
+
+// TrafficShift splits a service's traffic between two subsets by creating or updating its
+// DestinationRule subsets and the default route of its VirtualService
+func (m *Manager) TrafficShift(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params trafficShiftParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.From == "" {
+		params.From = "v1"
+	}
+	if params.To == "" {
+		params.To = "v2"
+	}
+	if params.SubsetLabel == "" {
+		params.SubsetLabel = "version"
+	}
+
+	if params.Service == "" || params.Weight == nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "service and weight are required",
+				},
+			},
+		}, nil
+	}
+	if *params.Weight < 0 || *params.Weight > 100 || params.From == params.To {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "weight must be between 0 and 100 and from and to must be different subsets",
+				},
+			},
+		}, nil
+	}
+
+	ctx = withManagingTool(ctx, "traffic_shift")
+
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Get(ctx, params.Service, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get service: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := &TrafficShiftResult{
+		Service:    params.Service,
+		Namespace:  params.Namespace,
+		Weights:    map[string]int32{params.From: 100 - *params.Weight, params.To: *params.Weight},
+		SubsetPods: make(map[string]int),
+		DryRun:     params.DryRun,
+	}
+
+	// A subset without pods turns its share of the traffic into 503s
+	subsets := []*apinetworkingv1beta1.Subset{
+		{Name: params.From, Labels: map[string]string{params.SubsetLabel: params.From}},
+		{Name: params.To, Labels: map[string]string{params.SubsetLabel: params.To}},
+	}
+	for _, subset := range subsets {
+		selector := make(map[string]string)
+		for key, value := range svc.Spec.Selector {
+			selector[key] = value
+		}
+		for key, value := range subset.Labels {
+			selector[key] = value
+		}
+		pods, err := m.runningPods(ctx, params.Namespace, labels.SelectorFromSet(selector).String())
+		if err != nil {
+			result.Issues = append(result.Issues, fmt.Sprintf("Failed to list pods of subset %s: %v", subset.Name, err))
+			continue
+		}
+		result.SubsetPods[subset.Name] = len(pods)
+		if len(pods) == 0 && result.Weights[subset.Name] > 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("No running pods of %s have %s=%s, so the %d%% routed to subset %s will fail",
+				params.Service, params.SubsetLabel, subset.Name, result.Weights[subset.Name], subset.Name))
+		}
+	}
+
+	dryRun := trafficDryRun(params.DryRun)
+
+	// DestinationRule: add the subsets to the service's rule, keeping anything else it configures
+	drClient := m.k8sClient.Istio.NetworkingV1beta1().DestinationRules(params.Namespace)
+	dr, err := drClient.Get(ctx, params.Service, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		changed := false
+		for _, subset := range subsets {
+			found := false
+			for _, existing := range dr.Spec.Subsets {
+				if existing.Name == subset.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				dr.Spec.Subsets = append(dr.Spec.Subsets, subset)
+				changed = true
+			}
+		}
+		if changed {
+			dr, err = drClient.Update(ctx, dr, metav1.UpdateOptions{DryRun: dryRun})
+			result.Actions = append(result.Actions, fmt.Sprintf("Added subsets %s and %s to DestinationRule %s", params.From, params.To, params.Service))
+		} else {
+			result.Actions = append(result.Actions, fmt.Sprintf("DestinationRule %s already defines subsets %s and %s", params.Service, params.From, params.To))
+		}
+	case errors.IsNotFound(err):
+		dr = &networkingv1beta1.DestinationRule{
+			ObjectMeta: metav1.ObjectMeta{Name: params.Service, Namespace: params.Namespace},
+		}
+		dr.Spec.Host = params.Service
+		dr.Spec.Subsets = subsets
+		markManaged(ctx, dr)
+		dr, err = drClient.Create(ctx, dr, metav1.CreateOptions{DryRun: dryRun})
+		result.Actions = append(result.Actions, fmt.Sprintf("Created DestinationRule %s with subsets %s and %s", params.Service, params.From, params.To))
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply DestinationRule %s/%s: %v", params.Namespace, params.Service, err),
+				},
+			},
+		}, nil
+	}
+	result.DestinationRule = destinationRuleResource(dr)
+
+	// VirtualService: replace the destinations of the default route, keeping match-based routes
+	route := &apinetworkingv1beta1.HTTPRoute{
+		Route: []*apinetworkingv1beta1.HTTPRouteDestination{
+			{Destination: &apinetworkingv1beta1.Destination{Host: params.Service, Subset: params.From}, Weight: 100 - *params.Weight},
+			{Destination: &apinetworkingv1beta1.Destination{Host: params.Service, Subset: params.To}, Weight: *params.Weight},
+		},
+	}
+	vsClient := m.k8sClient.Istio.NetworkingV1beta1().VirtualServices(params.Namespace)
+	vs, err := vsClient.Get(ctx, params.Service, metav1.GetOptions{})
+	switch {
+	case err == nil:
+		replaced := false
+		for _, existing := range vs.Spec.Http {
+			if len(existing.Match) == 0 {
+				existing.Route = route.Route
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			vs.Spec.Http = append(vs.Spec.Http, route)
+		}
+		vs, err = vsClient.Update(ctx, vs, metav1.UpdateOptions{DryRun: dryRun})
+		result.Actions = append(result.Actions, fmt.Sprintf("Updated the default route of VirtualService %s to %s=%d%%, %s=%d%%",
+			params.Service, params.From, 100-*params.Weight, params.To, *params.Weight))
+	case errors.IsNotFound(err):
+		vs = &networkingv1beta1.VirtualService{
+			ObjectMeta: metav1.ObjectMeta{Name: params.Service, Namespace: params.Namespace},
+		}
+		vs.Spec.Hosts = []string{params.Service}
+		vs.Spec.Http = []*apinetworkingv1beta1.HTTPRoute{route}
+		markManaged(ctx, vs)
+		vs, err = vsClient.Create(ctx, vs, metav1.CreateOptions{DryRun: dryRun})
+		result.Actions = append(result.Actions, fmt.Sprintf("Created VirtualService %s routing %s=%d%%, %s=%d%%",
+			params.Service, params.From, 100-*params.Weight, params.To, *params.Weight))
+	}
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to apply VirtualService %s/%s: %v", params.Namespace, params.Service, err),
+				},
+			},
+		}, nil
+	}
+	result.VirtualService = virtualServiceResource(vs)
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
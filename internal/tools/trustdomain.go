@@ -0,0 +1,367 @@
+package tools
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultTokenAudience is the audience of the istio-token volume and of istiod's token reviews unless
+// TOKEN_AUDIENCES changes it
+const defaultTokenAudience = "istio-ca"
+
+// TrustDomainWorkload is the identity one proxy presents compared to the one the mesh expects
+type TrustDomainWorkload struct {
+	Pod                 string `json:"pod"`
+	ServiceAccount      string `json:"service_account"`
+	SPIFFEID            string `json:"spiffe_id,omitempty"`
+	Expected            string `json:"expected"`
+	TrustDomain         string `json:"trust_domain,omitempty"` // trust domain of the certificate
+	Matches             bool   `json:"matches"`
+	TokenAudience       string `json:"token_audience,omitempty"`           // audience of the istio-token volume
+	TokenExpirationSecs int64  `json:"token_expiration_seconds,omitempty"` // lifetime of the istio-token volume
+	Problem             string `json:"problem,omitempty"`
+}
+
+// ClusterTrustDomain is the trust configuration of another cluster sharing the mesh
+type ClusterTrustDomain struct {
+	Context          string   `json:"context"`
+	TrustDomain      string   `json:"trust_domain,omitempty"`
+	Aliases          []string `json:"trust_domain_aliases,omitempty"`
+	MeshID           string   `json:"mesh_id,omitempty"`
+	RootFingerprints []string `json:"root_fingerprints,omitempty"` // SHA-256 of the roots in istio-ca-root-cert
+	SharedRoot       bool     `json:"shared_root"`
+	Compatible       bool     `json:"compatible"` // same trust domain, or each side lists the other as an alias
+	Error            string   `json:"error,omitempty"`
+}
+
+// TrustDomainReport is the result of inspect_trust_domain
+type TrustDomainReport struct {
+	TrustDomain      string                `json:"trust_domain"`
+	Aliases          []string              `json:"trust_domain_aliases,omitempty"`
+	MeshID           string                `json:"mesh_id,omitempty"`
+	RootFingerprints []string              `json:"root_fingerprints,omitempty"`
+	TokenAudiences   []string              `json:"token_audiences"` // audiences istiod accepts on workload tokens
+	Namespace        string                `json:"namespace"`
+	Workloads        []TrustDomainWorkload `json:"workloads"`
+	Clusters         []ClusterTrustDomain  `json:"clusters,omitempty"`
+	Issues           []string              `json:"issues,omitempty"`
+	Notes            []string              `json:"notes,omitempty"`
+	Timestamp        time.Time             `json:"timestamp"`
+}
+
+// meshTrustConfig holds the parts of the mesh config that define workload identities
+type meshTrustConfig struct {
+	TrustDomain        string   `json:"trustDomain"`
+	TrustDomainAliases []string `json:"trustDomainAliases"`
+	DefaultConfig      struct {
+		MeshID string `json:"meshId"`
+	} `json:"defaultConfig"`
+}
+
+// inspectTrustDomainParams are the arguments of inspect_trust_domain
+type inspectTrustDomainParams struct {
+	Namespace      string   `json:"namespace,omitempty"`       // default: default
+	PodName        string   `json:"pod_name,omitempty"`        // default: every pod with a sidecar
+	IstioNamespace string   `json:"istio_namespace,omitempty"` // default: istio-system
+	Revision       string   `json:"revision,omitempty"`        // control plane revision
+	Contexts       []string `json:"contexts,omitempty"`        // other clusters of the mesh, by kubeconfig context
+}
+
+// InspectTrustDomain reports the mesh trust domain and its aliases, checks that the SPIFFE IDs of the workload
+// certificates and the audience of their service account tokens match what istiod expects, and compares the
+// trust domain and root of trust with other clusters sharing the mesh
+func (m *Manager) InspectTrustDomain(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params inspectTrustDomainParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+
+	mesh, err := m.meshTrustConfig(ctx, params.IstioNamespace, params.Revision)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read the mesh config: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	report := &TrustDomainReport{
+		TrustDomain:    mesh.TrustDomain,
+		Aliases:        mesh.TrustDomainAliases,
+		MeshID:         mesh.DefaultConfig.MeshID,
+		TokenAudiences: m.istiodTokenAudiences(ctx, params.IstioNamespace, params.Revision),
+		Namespace:      params.Namespace,
+		Workloads:      []TrustDomainWorkload{},
+		Timestamp:      time.Now(),
+	}
+	report.RootFingerprints, err = m.meshRootFingerprints(ctx, params.IstioNamespace)
+	if err != nil {
+		report.Issues = append(report.Issues, fmt.Sprintf("Failed to read the mesh root certificate: %v", err))
+	}
+	if report.TrustDomain == "cluster.local" && len(params.Contexts) > 0 {
+		report.Notes = append(report.Notes, "The mesh uses the default trust domain cluster.local; a distinct trust domain per mesh keeps identities from different meshes apart")
+	}
+
+	var pods []corev1.Pod
+	if params.PodName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get pod: %v", err),
+					},
+				},
+			}, nil
+		}
+		pods = []corev1.Pod{*pod}
+	} else {
+		pods, err = m.runningPods(ctx, params.Namespace, "")
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to list pods: %v", err),
+					},
+				},
+			}, nil
+		}
+	}
+
+	skipped := 0
+	for i := range pods {
+		pod := &pods[i]
+		if !podHasSidecar(pod) || pod.Status.Phase != corev1.PodRunning {
+			skipped++
+			continue
+		}
+		workload := m.inspectWorkloadIdentity(ctx, pod, report)
+		if workload.Problem != "" {
+			report.Issues = append(report.Issues, fmt.Sprintf("Pod %s: %s", pod.Name, workload.Problem))
+		}
+		report.Workloads = append(report.Workloads, workload)
+	}
+	if skipped > 0 {
+		report.Notes = append(report.Notes, fmt.Sprintf("%d pods without a running sidecar were skipped; ambient workloads get their certificates through ztunnel", skipped))
+	}
+
+	for _, kubeContext := range params.Contexts {
+		cluster := m.remoteTrustDomain(ctx, kubeContext, params.IstioNamespace, params.Revision, report)
+		switch {
+		case cluster.Error != "":
+			report.Issues = append(report.Issues, fmt.Sprintf("Context %s: %s", kubeContext, cluster.Error))
+		case !cluster.SharedRoot:
+			report.Issues = append(report.Issues, fmt.Sprintf("Context %s does not share a root certificate with this cluster, so mTLS between their workloads fails; plug in certificates from a common root", kubeContext))
+		}
+		if cluster.Error == "" && !cluster.Compatible {
+			report.Issues = append(report.Issues, fmt.Sprintf("Context %s uses trust domain %s and this cluster %s without listing each other in trustDomainAliases; AuthorizationPolicies on either side do not match the other cluster's principals",
+				kubeContext, cluster.TrustDomain, report.TrustDomain))
+		}
+		if cluster.Error == "" && cluster.MeshID != report.MeshID {
+			report.Issues = append(report.Issues, fmt.Sprintf("Context %s has mesh ID %q and this cluster %q; clusters of one mesh need the same mesh ID", kubeContext, cluster.MeshID, report.MeshID))
+		}
+		report.Clusters = append(report.Clusters, cluster)
+	}
+
+	resultJSON, _ := json.MarshalIndent(report, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// meshTrustConfig reads the trust domain settings of a revision, defaulting the trust domain like istiod does
+func (m *Manager) meshTrustConfig(ctx context.Context, istioNamespace, revision string) (*meshTrustConfig, error) {
+	var mesh meshTrustConfig
+	if err := m.readMeshConfig(ctx, istioNamespace, revision, &mesh); err != nil {
+		return nil, err
+	}
+	if mesh.TrustDomain == "" {
+		mesh.TrustDomain = "cluster.local"
+	}
+	return &mesh, nil
+}
+
+// meshRootFingerprints returns the SHA-256 fingerprints of the roots istiod distributes in istio-ca-root-cert;
+// there are two while a root is being rotated
+func (m *Manager) meshRootFingerprints(ctx context.Context, istioNamespace string) ([]string, error) {
+	cm, err := m.k8sClient.Kubernetes.CoreV1().ConfigMaps(istioNamespace).Get(ctx, "istio-ca-root-cert", metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	var fingerprints []string
+	for _, cert := range parsePEMCertificates([]byte(cm.Data["root-cert.pem"])) {
+		fingerprints = append(fingerprints, fmt.Sprintf("%x", sha256.Sum256(cert.Raw)))
+	}
+	sort.Strings(fingerprints)
+	return fingerprints, nil
+}
+
+// istiodTokenAudiences returns the audiences istiod accepts on the service account tokens workloads present
+// for their certificates, read from TOKEN_AUDIENCES on the istiod deployments
+func (m *Manager) istiodTokenAudiences(ctx context.Context, istioNamespace, revision string) []string {
+	selector := "app=istiod"
+	if revision != "" {
+		selector += ",istio.io/rev=" + revision
+	}
+	deployments, err := m.k8sClient.Kubernetes.AppsV1().Deployments(istioNamespace).List(ctx, metav1.ListOptions{LabelSelector: selector})
+	if err != nil {
+		logrus.Debugf("Failed to list istiod deployments: %v", err)
+		return []string{defaultTokenAudience}
+	}
+	for _, deployment := range deployments.Items {
+		for _, container := range deployment.Spec.Template.Spec.Containers {
+			if container.Name != "discovery" {
+				continue
+			}
+			for _, env := range container.Env {
+				if env.Name == "TOKEN_AUDIENCES" && env.Value != "" {
+					return splitList(env.Value)
+				}
+			}
+		}
+	}
+	return []string{defaultTokenAudience}
+}
+
+// inspectWorkloadIdentity compares the SPIFFE ID of a proxy's certificate with the one derived from the trust
+// domain, namespace and service account, and checks the audience of its istio-token volume
+func (m *Manager) inspectWorkloadIdentity(ctx context.Context, pod *corev1.Pod, report *TrustDomainReport) TrustDomainWorkload {
+	serviceAccount := pod.Spec.ServiceAccountName
+	if serviceAccount == "" {
+		serviceAccount = "default"
+	}
+	workload := TrustDomainWorkload{
+		Pod:            pod.Name,
+		ServiceAccount: serviceAccount,
+		Expected:       fmt.Sprintf("spiffe://%s/ns/%s/sa/%s", report.TrustDomain, pod.Namespace, serviceAccount),
+	}
+
+	// Sidecars exchange the istio-token projected token for their certificate
+	for _, volume := range pod.Spec.Volumes {
+		if volume.Name != "istio-token" || volume.Projected == nil {
+			continue
+		}
+		for _, source := range volume.Projected.Sources {
+			if source.ServiceAccountToken != nil {
+				workload.TokenAudience = source.ServiceAccountToken.Audience
+				if source.ServiceAccountToken.ExpirationSeconds != nil {
+					workload.TokenExpirationSecs = *source.ServiceAccountToken.ExpirationSeconds
+				}
+			}
+		}
+	}
+	var problems []string
+	if workload.TokenAudience != "" && !containsString(report.TokenAudiences, workload.TokenAudience) {
+		problems = append(problems, fmt.Sprintf("its istio-token audience %s is not one istiod accepts (%s), so certificate requests are rejected", workload.TokenAudience, strings.Join(report.TokenAudiences, ", ")))
+	}
+
+	cert, err := m.proxyWorkloadCertificate(ctx, pod)
+	if err != nil {
+		workload.Problem = strings.Join(append(problems, err.Error()), "; ")
+		return workload
+	}
+	for _, uri := range cert.URIs {
+		if uri.Scheme == "spiffe" {
+			workload.SPIFFEID = uri.String()
+		}
+	}
+	workload.TrustDomain = spiffeTrustDomain(workload.SPIFFEID)
+	workload.Matches = workload.SPIFFEID == workload.Expected
+
+	if !workload.Matches {
+		switch {
+		case workload.SPIFFEID == "":
+			problems = append(problems, "its certificate carries no SPIFFE ID")
+		case workload.TrustDomain == report.TrustDomain:
+			problems = append(problems, fmt.Sprintf("its certificate names %s, not its namespace and service account (%s)", workload.SPIFFEID, workload.Expected))
+		case containsString(report.Aliases, workload.TrustDomain):
+			problems = append(problems, fmt.Sprintf("its certificate is still in the alias trust domain %s; it rotates into %s with its next certificate", workload.TrustDomain, report.TrustDomain))
+		default:
+			problems = append(problems, fmt.Sprintf("its certificate is in trust domain %s, neither the mesh trust domain %s nor an alias; peers reject it in AuthorizationPolicies", workload.TrustDomain, report.TrustDomain))
+		}
+	}
+	workload.Problem = strings.Join(problems, "; ")
+	return workload
+}
+
+// remoteTrustDomain reads the trust configuration of another cluster and compares it with the local one
+func (m *Manager) remoteTrustDomain(ctx context.Context, kubeContext, istioNamespace, revision string, report *TrustDomainReport) ClusterTrustDomain {
+	cluster := ClusterTrustDomain{Context: kubeContext}
+	if err := m.checkContextScope(kubeContext); err != nil {
+		cluster.Error = err.Error()
+		return cluster
+	}
+	target, err := m.forContext(kubeContext)
+	if err != nil {
+		cluster.Error = fmt.Sprintf("failed to create a client: %v", err)
+		return cluster
+	}
+
+	mesh, err := target.meshTrustConfig(ctx, istioNamespace, revision)
+	if err != nil {
+		cluster.Error = fmt.Sprintf("failed to read the mesh config: %v", err)
+		return cluster
+	}
+	cluster.TrustDomain, cluster.Aliases, cluster.MeshID = mesh.TrustDomain, mesh.TrustDomainAliases, mesh.DefaultConfig.MeshID
+	cluster.Compatible = cluster.TrustDomain == report.TrustDomain ||
+		(containsString(report.Aliases, cluster.TrustDomain) && containsString(cluster.Aliases, report.TrustDomain))
+
+	cluster.RootFingerprints, err = target.meshRootFingerprints(ctx, istioNamespace)
+	if err != nil {
+		cluster.Error = fmt.Sprintf("failed to read the mesh root certificate: %v", err)
+		return cluster
+	}
+	for _, fingerprint := range cluster.RootFingerprints {
+		cluster.SharedRoot = cluster.SharedRoot || containsString(report.RootFingerprints, fingerprint)
+	}
+	return cluster
+}
+
+// spiffeTrustDomain returns the trust domain of a spiffe://<trust domain>/<path> ID
+func spiffeTrustDomain(id string) string {
+	parsed, err := url.Parse(id)
+	if err != nil || parsed.Scheme != "spiffe" {
+		return ""
+	}
+	return parsed.Host
+}
@@ -0,0 +1,521 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+)
+
+// istiodDebugPort serves istiod's debug endpoints, including the proxy sync status
+const istiodDebugPort = 15014
+
+// CanaryUpgradeStep is one step of a revision-based upgrade
+type CanaryUpgradeStep struct {
+	Step   string `json:"step"`
+	Status string `json:"status"` // done, skipped or failed
+	Detail string `json:"detail,omitempty"`
+}
+
+// CanaryNamespace is the migration state of one namespace
+type CanaryNamespace struct {
+	Namespace     string   `json:"namespace"`
+	PreviousLabel string   `json:"previous_label,omitempty"`
+	Restarted     []string `json:"restarted,omitempty"`
+	Proxies       int      `json:"proxies"`
+	OnNewRevision int      `json:"on_new_revision"`         // pods injected by the new revision
+	Connected     int      `json:"connected"`               // pods connected to the new istiod
+	NotRestarted  int      `json:"not_restarted,omitempty"` // Job and bare pods, which move when next created
+}
+
+// CanaryUpgradeResult is the result of a revision-based control plane upgrade
+type CanaryUpgradeResult struct {
+	Version            string              `json:"version"`
+	OldRevision        string              `json:"old_revision"`
+	NewRevision        string              `json:"new_revision"`
+	Release            string              `json:"release"`
+	Steps              []CanaryUpgradeStep `json:"steps"`
+	Namespaces         []CanaryNamespace   `json:"namespaces"`
+	Verified           bool                `json:"verified"`
+	OldRevisionRemoved bool                `json:"old_revision_removed"`
+	Issues             []string            `json:"issues,omitempty"`
+	Notes              []string            `json:"notes,omitempty"`
+	Timestamp          time.Time           `json:"timestamp"`
+}
+
+// addStep records the outcome of an upgrade step
+func (r *CanaryUpgradeResult) addStep(step, status, detail string) {
+	r.Steps = append(r.Steps, CanaryUpgradeStep{Step: step, Status: status, Detail: detail})
+}
+
+// istioCanaryUpgradeParams are the arguments of istio_canary_upgrade
+type istioCanaryUpgradeParams struct {
+	Version           string                 `json:"version"`                       // Istio chart version to upgrade to
+	Namespaces        []string               `json:"namespaces"`                    // namespaces to move to the new revision
+	Revision          string                 `json:"revision,omitempty"`            // default: version with dots replaced by dashes
+	OldRevision       string                 `json:"old_revision,omitempty"`        // default: the revision the first namespace uses
+	OldRelease        string                 `json:"old_release,omitempty"`         // default: istiod, or istiod-<old_revision>
+	IstioNamespace    string                 `json:"istio_namespace,omitempty"`     // default: istio-system
+	Values            map[string]interface{} `json:"values,omitempty"`              // extra helm values for the new revision
+	CopyValues        *bool                  `json:"copy_values,omitempty"`         // default: true
+	UpgradeBase       *bool                  `json:"upgrade_base,omitempty"`        // default: true
+	Restart           *bool                  `json:"restart,omitempty"`             // default: true
+	RemoveOldRevision bool                   `json:"remove_old_revision,omitempty"` // uninstall the old revision once verified
+	RepoURL           string                 `json:"repo_url,omitempty"`            // chart repository override
+	Timeout           string                 `json:"timeout,omitempty"`             // default: 5m
+}
+
+// IstioCanaryUpgrade installs a new istiod revision next to the running one, moves the selected
+// namespaces to it, restarts their workloads, verifies the proxies reconnect to the new revision and
+// optionally removes the old revision
+func (m *Manager) IstioCanaryUpgrade(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params istioCanaryUpgradeParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Version == "" || len(params.Namespaces) == 0 {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "version and namespaces are required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Revision == "" {
+		params.Revision = strings.ReplaceAll(strings.TrimPrefix(params.Version, "v"), ".", "-")
+	}
+	if params.IstioNamespace == "" {
+		params.IstioNamespace = "istio-system"
+	}
+	if params.CopyValues == nil {
+		params.CopyValues = boolPtr(true)
+	}
+	if params.UpgradeBase == nil {
+		params.UpgradeBase = boolPtr(true)
+	}
+	if params.Restart == nil {
+		params.Restart = boolPtr(true)
+	}
+	if params.Timeout == "" {
+		params.Timeout = "5m"
+	}
+	timeout, err := time.ParseDuration(params.Timeout)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid timeout %q: %v", params.Timeout, err),
+				},
+			},
+		}, nil
+	}
+
+	// The old revision is whatever the first namespace injects from today
+	if params.OldRevision == "" {
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, params.Namespaces[0], metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get namespace %s: %v", params.Namespaces[0], err),
+					},
+				},
+			}, nil
+		}
+		params.OldRevision = namespaceInjectionRevision(ns)
+		if params.OldRevision == "" {
+			params.OldRevision = defaultRevision
+		}
+	}
+	if params.OldRelease == "" {
+		params.OldRelease = "istiod"
+		if params.OldRevision != defaultRevision {
+			params.OldRelease = "istiod-" + params.OldRevision
+		}
+	}
+	if params.OldRevision == params.Revision {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("The namespaces already use revision %s; pick a different revision name", params.Revision),
+				},
+			},
+		}, nil
+	}
+
+	if err := m.checkHelmAvailable(); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Helm is not available: %v. Check that the cluster is reachable with the configured kubeconfig.", err),
+				},
+			},
+		}, nil
+	}
+	repo := resolveChartRepository(m.config.Helm.Istio, params.RepoURL)
+	if err := m.addHelmRepo(ctx, repo); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to add Istio Helm repository: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	result := &CanaryUpgradeResult{
+		Version:     params.Version,
+		OldRevision: params.OldRevision,
+		NewRevision: params.Revision,
+		Release:     "istiod-" + params.Revision,
+		Timestamp:   time.Now(),
+	}
+	respond := func() (*CallToolResult, error) {
+		resultJSON, _ := json.MarshalIndent(result, "", "  ")
+		return &CallToolResult{
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: string(resultJSON),
+				},
+			},
+		}, nil
+	}
+
+	// CRDs are backwards compatible, so the base chart moves to the new version first
+	if *params.UpgradeBase {
+		if _, err := m.getHelmReleaseInfo(params.IstioNamespace, "istio-base"); err != nil {
+			result.addStep("upgrade base chart", "skipped", fmt.Sprintf("no istio-base release: %v", err))
+		} else {
+			release, err := m.installHelmChart(ctx, helmChartRequest{
+				Release:     "istio-base",
+				Chart:       repo.ChartRef("base"),
+				Namespace:   params.IstioNamespace,
+				Version:     params.Version,
+				Wait:        true,
+				Timeout:     params.Timeout,
+				Upgrade:     true,
+				ReuseValues: true,
+			})
+			if err != nil {
+				result.addStep("upgrade base chart", "failed", err.Error())
+				result.Issues = append(result.Issues, "The base chart upgrade failed; the old revision is untouched")
+				return respond()
+			}
+			result.addStep("upgrade base chart", "done", release.String())
+		}
+	}
+
+	// The new revision starts from the old revision's values so mesh config and resources carry over
+	values := make(map[string]interface{})
+	if *params.CopyValues {
+		oldValues, err := m.getHelmReleaseValues(params.IstioNamespace, params.OldRelease, false)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Could not copy values from release %s: %v", params.OldRelease, err))
+		}
+		for key, value := range oldValues {
+			values[key] = value
+		}
+	}
+	for key, value := range params.Values {
+		values[key] = value
+	}
+	values["revision"] = params.Revision
+	// Only one revision may own the default injection webhook
+	delete(values, "defaultRevision")
+
+	release, err := m.installHelmChart(ctx, helmChartRequest{
+		Release:   result.Release,
+		Chart:     repo.ChartRef("istiod"),
+		Namespace: params.IstioNamespace,
+		Version:   params.Version,
+		Values:    values,
+		Wait:      true,
+		Timeout:   params.Timeout,
+		Upgrade:   true,
+	})
+	if err != nil {
+		result.addStep("install new revision", "failed", err.Error())
+		result.Issues = append(result.Issues, "The new revision failed to install; no namespaces were moved")
+		return respond()
+	}
+	result.addStep("install new revision", "done", release.String())
+
+	istiodPods, err := m.runningPods(ctx, params.IstioNamespace, "app=istiod,istio.io/rev="+params.Revision)
+	if err != nil || len(istiodPods) == 0 {
+		result.addStep("install new revision", "failed", fmt.Sprintf("no running istiod pod for revision %s: %v", params.Revision, err))
+		return respond()
+	}
+
+	// Move the namespaces; istio-injection would override istio.io/rev, so it is removed
+	for _, name := range params.Namespaces {
+		entry := CanaryNamespace{Namespace: name}
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, name, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+			if value, ok := ns.Labels["istio-injection"]; ok {
+				entry.PreviousLabel = "istio-injection=" + value
+			} else if value, ok := ns.Labels["istio.io/rev"]; ok {
+				entry.PreviousLabel = "istio.io/rev=" + value
+			}
+			if ns.Labels == nil {
+				ns.Labels = make(map[string]string)
+			}
+			delete(ns.Labels, "istio-injection")
+			ns.Labels["istio.io/rev"] = params.Revision
+			_, err = m.k8sClient.Kubernetes.CoreV1().Namespaces().Update(ctx, ns, metav1.UpdateOptions{})
+			return err
+		})
+		if err != nil {
+			result.addStep("relabel "+name, "failed", err.Error())
+			result.Issues = append(result.Issues, fmt.Sprintf("Namespace %s was not moved: %v", name, err))
+		} else {
+			result.addStep("relabel "+name, "done", "istio.io/rev="+params.Revision)
+		}
+		result.Namespaces = append(result.Namespaces, entry)
+	}
+
+	// Pods are injected at creation, so workloads move only when restarted
+	if *params.Restart {
+		for i := range result.Namespaces {
+			entry := &result.Namespaces[i]
+			restarted, issues := m.restartSidecarWorkloads(ctx, entry.Namespace, timeout)
+			entry.Restarted = restarted
+			result.Issues = append(result.Issues, issues...)
+			status := "done"
+			if len(issues) > 0 {
+				status = "failed"
+			}
+			result.addStep("restart "+entry.Namespace, status, fmt.Sprintf("%d workloads restarted", len(restarted)))
+		}
+	} else {
+		result.addStep("restart workloads", "skipped", "restart is false; pods move to the new revision when next recreated")
+	}
+
+	// Proxies must be injected by the new revision and connected to its istiod
+	pending := ""
+	err = wait.PollUntilContextTimeout(ctx, 5*time.Second, timeout, true, func(ctx context.Context) (bool, error) {
+		connected, err := m.istiodConnectedProxies(ctx, params.IstioNamespace, istiodPods[0].Name)
+		if err != nil {
+			pending = err.Error()
+			return false, nil
+		}
+		pending = ""
+		for i := range result.Namespaces {
+			entry := &result.Namespaces[i]
+			entry.Proxies, entry.OnNewRevision, entry.Connected, entry.NotRestarted = 0, 0, 0, 0
+			pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(entry.Namespace).List(ctx, metav1.ListOptions{})
+			if err != nil {
+				return false, err
+			}
+			for j := range pods.Items {
+				pod := &pods.Items[j]
+				if !podHasSidecar(pod) || pod.Status.Phase != corev1.PodRunning || pod.DeletionTimestamp != nil {
+					continue
+				}
+				if owner := metav1.GetControllerOf(pod); owner == nil || (owner.Kind != "ReplicaSet" && owner.Kind != "StatefulSet" && owner.Kind != "DaemonSet") {
+					entry.NotRestarted++
+					continue
+				}
+				entry.Proxies++
+				if pod.Labels["istio.io/rev"] == params.Revision {
+					entry.OnNewRevision++
+				}
+				if connected[pod.Name+"."+pod.Namespace] {
+					entry.Connected++
+				}
+			}
+			if entry.Connected < entry.Proxies && pending == "" {
+				pending = fmt.Sprintf("%d of %d proxies in %s connected to revision %s", entry.Connected, entry.Proxies, entry.Namespace, params.Revision)
+			}
+		}
+		return pending == "" || !*params.Restart, nil
+	})
+	switch {
+	case err != nil:
+		result.addStep("verify proxies", "failed", pending)
+		result.Issues = append(result.Issues, fmt.Sprintf("Proxies did not all reconnect within %s: %s", params.Timeout, pending))
+	case pending != "":
+		result.addStep("verify proxies", "skipped", pending)
+	default:
+		result.Verified = true
+		result.addStep("verify proxies", "done", "all proxies in the namespaces are connected to the new revision")
+	}
+
+	if params.RemoveOldRevision {
+		blockers := m.revisionUsers(ctx, params.OldRevision)
+		switch {
+		case !result.Verified:
+			result.addStep("remove old revision", "skipped", "verification did not pass")
+		case len(blockers) > 0:
+			result.addStep("remove old revision", "skipped", "still in use: "+strings.Join(blockers, "; "))
+		default:
+			if err := m.uninstallHelmRelease(ctx, params.IstioNamespace, params.OldRelease, false, true, params.Timeout); err != nil {
+				result.addStep("remove old revision", "failed", err.Error())
+			} else {
+				result.OldRevisionRemoved = true
+				result.addStep("remove old revision", "done", "uninstalled release "+params.OldRelease)
+			}
+		}
+	}
+	if !result.OldRevisionRemoved {
+		result.Notes = append(result.Notes, fmt.Sprintf("To roll back, label the namespaces istio.io/rev=%s (or restore their previous label) and restart the workloads", params.OldRevision))
+	}
+	if params.OldRevision == defaultRevision {
+		result.Notes = append(result.Notes, "Namespaces labeled istio-injection=enabled keep using the old default revision; move the default revision tag once every namespace is migrated")
+	}
+
+	return respond()
+}
+
+// namespaceInjectionRevision returns the revision or tag a namespace's injection labels select
+func namespaceInjectionRevision(ns *corev1.Namespace) string {
+	if ns.Labels["istio-injection"] == "enabled" {
+		return defaultRevision
+	}
+	return ns.Labels["istio.io/rev"]
+}
+
+// restartSidecarWorkloads restarts every workload in a namespace running sidecar pods, waiting for
+// Deployment rollouts
+func (m *Manager) restartSidecarWorkloads(ctx context.Context, namespace string, timeout time.Duration) ([]string, []string) {
+	var restarted, issues []string
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, []string{fmt.Sprintf("Failed to list pods in %s: %v", namespace, err)}
+	}
+	owners := make(map[string]bool)
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if !podHasSidecar(pod) || pod.Status.Phase != corev1.PodRunning {
+			continue
+		}
+		if owner := m.podOwner(ctx, pod); owner != "" {
+			owners[owner] = true
+		}
+	}
+	names := make([]string, 0, len(owners))
+	for owner := range owners {
+		names = append(names, owner)
+	}
+	sort.Strings(names)
+
+	for _, owner := range names {
+		kind, name, _ := strings.Cut(owner, "/")
+		var err error
+		switch kind {
+		case "Deployment":
+			deployment, getErr := m.k8sClient.Kubernetes.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+			if getErr != nil {
+				err = getErr
+				break
+			}
+			err = m.restartDeployment(ctx, deployment, timeout)
+		case "StatefulSet", "DaemonSet":
+			err = m.updateWorkloadPodTemplate(ctx, namespace, owner, func(template *corev1.PodTemplateSpec) error {
+				if template.Annotations == nil {
+					template.Annotations = make(map[string]string)
+				}
+				template.Annotations["kubectl.kubernetes.io/restartedAt"] = time.Now().Format(time.RFC3339)
+				return nil
+			})
+		default:
+			// Jobs and bare pods pick up the new revision when they are next created
+			continue
+		}
+		if err != nil {
+			issues = append(issues, fmt.Sprintf("Failed to restart %s/%s: %v", namespace, owner, err))
+			continue
+		}
+		restarted = append(restarted, owner)
+	}
+	return restarted, issues
+}
+
+// istiodConnectedProxies returns the proxies connected to an istiod pod, keyed by pod.namespace
+func (m *Manager) istiodConnectedProxies(ctx context.Context, namespace, pod string) (map[string]bool, error) {
+	body, err := m.portForwardGet(ctx, namespace, pod, istiodDebugPort, "/debug/syncz")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync status from %s: %w", pod, err)
+	}
+	var statuses []struct {
+		Proxy string `json:"proxy"`
+	}
+	if err := json.Unmarshal(body, &statuses); err != nil {
+		return nil, fmt.Errorf("failed to parse sync status: %w", err)
+	}
+	connected := make(map[string]bool)
+	for _, status := range statuses {
+		connected[status.Proxy] = true
+	}
+	return connected, nil
+}
+
+// revisionUsers lists namespaces, revision tags and pods that still depend on a revision
+func (m *Manager) revisionUsers(ctx context.Context, revision string) []string {
+	var users []string
+	if namespaces, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().List(ctx, metav1.ListOptions{}); err == nil {
+		for i := range namespaces.Items {
+			if namespaceInjectionRevision(&namespaces.Items[i]) == revision {
+				users = append(users, "namespace "+namespaces.Items[i].Name)
+			}
+		}
+	} else {
+		users = append(users, fmt.Sprintf("could not list namespaces: %v", err))
+	}
+
+	if webhooks, err := m.k8sClient.Kubernetes.AdmissionregistrationV1().MutatingWebhookConfigurations().List(ctx, metav1.ListOptions{LabelSelector: "istio.io/tag"}); err == nil {
+		for _, webhook := range webhooks.Items {
+			if webhook.Labels["istio.io/rev"] == revision {
+				users = append(users, "tag "+webhook.Labels["istio.io/tag"])
+			}
+		}
+	}
+
+	if pods, err := m.k8sClient.Kubernetes.CoreV1().Pods("").List(ctx, metav1.ListOptions{LabelSelector: "istio.io/rev=" + revision}); err == nil {
+		stale := 0
+		for _, pod := range pods.Items {
+			if pod.Status.Phase == corev1.PodRunning {
+				stale++
+			}
+		}
+		if stale > 0 {
+			users = append(users, fmt.Sprintf("%d running pods", stale))
+		}
+	}
+	return users
+}
@@ -0,0 +1,528 @@
+package tools
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	gatewayGVR = schema.GroupVersionResource{Group: "gateway.networking.k8s.io", Version: "v1", Resource: "gateways"}
+
+	authorizationPolicyGVRs = []schema.GroupVersionResource{
+		{Group: "security.istio.io", Version: "v1", Resource: "authorizationpolicies"},
+		{Group: "security.istio.io", Version: "v1beta1", Resource: "authorizationpolicies"},
+	}
+)
+
+// WaypointBinding describes which waypoint a service is bound to and how
+type WaypointBinding struct {
+	Waypoint    string   `json:"waypoint,omitempty"`
+	Namespace   string   `json:"namespace,omitempty"`
+	BoundBy     string   `json:"bound_by,omitempty"` // service or namespace label
+	WaypointFor string   `json:"waypoint_for,omitempty"`
+	Pods        []string `json:"pods,omitempty"`
+}
+
+// WaypointVerification is the result of sending test traffic through a waypoint
+type WaypointVerification struct {
+	Service          string          `json:"service"`
+	Source           PodInfo         `json:"source"`
+	SourceMode       string          `json:"source_mode"`
+	Binding          WaypointBinding `json:"binding"`
+	RequestsSent     int             `json:"requests_sent"`
+	ResponseCodes    map[string]int  `json:"response_codes"`
+	WaypointRequests int             `json:"waypoint_requests"`  // upstream requests the waypoint sent to the service during the test
+	WaypointRBAC     map[string]int  `json:"waypoint_rbac"`      // RBAC filter decisions at the waypoint during the test
+	AccessLogMatches int             `json:"access_log_matches"` // waypoint access log entries carrying the test marker
+	ThroughWaypoint  bool            `json:"through_waypoint"`
+	L7Policies       []string        `json:"l7_policies,omitempty"`
+	Issues           []string        `json:"issues,omitempty"`
+	Notes            []string        `json:"notes,omitempty"`
+	Timestamp        time.Time       `json:"timestamp"`
+}
+
+// waypointPolicy holds the parts of an AuthorizationPolicy needed to tell where it is enforced
+type waypointPolicy struct {
+	Metadata struct {
+		Name      string `json:"name"`
+		Namespace string `json:"namespace"`
+	} `json:"metadata"`
+	Spec struct {
+		Selector   *metav1.LabelSelector `json:"selector,omitempty"`
+		TargetRef  *policyTargetRef      `json:"targetRef,omitempty"`
+		TargetRefs []policyTargetRef     `json:"targetRefs,omitempty"`
+		Rules      []struct {
+			To []struct {
+				Operation struct {
+					Hosts      []string `json:"hosts,omitempty"`
+					NotHosts   []string `json:"notHosts,omitempty"`
+					Methods    []string `json:"methods,omitempty"`
+					NotMethods []string `json:"notMethods,omitempty"`
+					Paths      []string `json:"paths,omitempty"`
+					NotPaths   []string `json:"notPaths,omitempty"`
+				} `json:"operation"`
+			} `json:"to,omitempty"`
+			From []struct {
+				Source struct {
+					RequestPrincipals    []string `json:"requestPrincipals,omitempty"`
+					NotRequestPrincipals []string `json:"notRequestPrincipals,omitempty"`
+				} `json:"source"`
+			} `json:"from,omitempty"`
+			When []struct {
+				Key string `json:"key"`
+			} `json:"when,omitempty"`
+		} `json:"rules,omitempty"`
+	} `json:"spec"`
+}
+
+// policyTargetRef is a Gateway API style policy attachment reference
+type policyTargetRef struct {
+	Group string `json:"group,omitempty"`
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+}
+
+// verifyWaypointParams are the arguments of verify_waypoint
+type verifyWaypointParams struct {
+	Service         string `json:"service"`
+	Namespace       string `json:"namespace,omitempty"`
+	Port            int    `json:"port,omitempty"`             // default: first service port
+	Path            string `json:"path,omitempty"`             // default: /
+	SourcePod       string `json:"source_pod,omitempty"`       // default: first app=sleep pod
+	SourceNamespace string `json:"source_namespace,omitempty"` // default: namespace
+	SourceContainer string `json:"source_container,omitempty"` // default: first non-proxy container
+	Requests        int    `json:"requests,omitempty"`         // default: 5
+	Timeout         int    `json:"timeout,omitempty"`          // seconds per request, default: 5
+}
+
+// VerifyWaypoint sends test traffic to a service bound to a waypoint and confirms the waypoint handled it
+func (m *Manager) VerifyWaypoint(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params verifyWaypointParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	if params.Service == "" {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: "service is required",
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Path == "" {
+		params.Path = "/"
+	}
+	if params.SourceNamespace == "" {
+		params.SourceNamespace = params.Namespace
+	}
+	if params.Requests <= 0 {
+		params.Requests = 5
+	}
+	if params.Timeout <= 0 {
+		params.Timeout = 5
+	}
+
+	svc, err := m.k8sClient.Kubernetes.CoreV1().Services(params.Namespace).Get(ctx, params.Service, metav1.GetOptions{})
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to get service: %v", err),
+				},
+			},
+		}, nil
+	}
+	if params.Port == 0 {
+		if len(svc.Spec.Ports) == 0 {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Service %s/%s has no ports", svc.Namespace, svc.Name),
+					},
+				},
+			}, nil
+		}
+		params.Port = int(svc.Spec.Ports[0].Port)
+	}
+
+	source, err := m.findWaypointSource(ctx, params.SourceNamespace, params.SourcePod)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+	if params.SourceContainer == "" {
+		for _, container := range source.Spec.Containers {
+			if container.Name != "istio-proxy" {
+				params.SourceContainer = container.Name
+				break
+			}
+		}
+	}
+
+	host := fmt.Sprintf("%s.%s.svc.cluster.local", svc.Name, svc.Namespace)
+	result := &WaypointVerification{
+		Service: fmt.Sprintf("%s:%d", host, params.Port),
+		Source: PodInfo{
+			Name:      source.Name,
+			Namespace: source.Namespace,
+			IP:        source.Status.PodIP,
+			Node:      source.Spec.NodeName,
+		},
+		ResponseCodes: make(map[string]int),
+		WaypointRBAC:  make(map[string]int),
+		Timestamp:     time.Now(),
+	}
+
+	// Clients outside the mesh reach the destination ztunnel directly and skip the waypoint
+	sourceNamespaceMode := ""
+	if ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, source.Namespace, metav1.GetOptions{}); err == nil {
+		sourceNamespaceMode = ns.Labels["istio.io/dataplane-mode"]
+	}
+	result.SourceMode = podInterceptionMode(source, sourceNamespaceMode)
+	switch result.SourceMode {
+	case "none":
+		result.Issues = append(result.Issues, fmt.Sprintf("Source pod %s is not in the mesh; its traffic is not redirected to the waypoint", source.Name))
+	case "init-container", "cni":
+		result.Notes = append(result.Notes, "Source pod runs a sidecar; sidecars only send traffic to waypoints when the control plane enables sidecar waypoint interop")
+	}
+
+	binding, bindingIssues := m.resolveWaypointBinding(ctx, svc)
+	result.Binding = binding
+	result.Issues = append(result.Issues, bindingIssues...)
+
+	var waypointPods []corev1.Pod
+	if binding.Waypoint != "" {
+		pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(binding.Namespace).List(ctx, metav1.ListOptions{
+			LabelSelector: "gateway.networking.k8s.io/gateway-name=" + binding.Waypoint,
+		})
+		if err == nil {
+			for _, pod := range pods.Items {
+				if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+					waypointPods = append(waypointPods, pod)
+					result.Binding.Pods = append(result.Binding.Pods, pod.Name)
+				}
+			}
+		}
+		if len(waypointPods) == 0 {
+			result.Issues = append(result.Issues, fmt.Sprintf("Waypoint %s/%s has no running pods", binding.Namespace, binding.Waypoint))
+		}
+	}
+
+	l7Policies, policyIssues := m.checkWaypointPolicies(ctx, svc, binding)
+	result.L7Policies = l7Policies
+	result.Issues = append(result.Issues, policyIssues...)
+
+	// Snapshot waypoint stats before and after the test so only our traffic is counted
+	before := make(map[string]map[string]int)
+	for _, pod := range waypointPods {
+		stats, err := m.waypointStats(ctx, &pod, host, params.Port)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to read stats from waypoint pod %s: %v", pod.Name, err))
+			continue
+		}
+		before[pod.Name] = stats
+	}
+
+	marker := fmt.Sprintf("meshpilot-verify-%d", time.Now().UnixNano())
+	separator := "?"
+	if strings.Contains(params.Path, "?") {
+		separator = "&"
+	}
+	url := fmt.Sprintf("http://%s:%d%s%s%s", host, params.Port, params.Path, separator, marker)
+	command := []string{"curl", "-s", "-o", "/dev/null", "-w", "%{http_code}", "--max-time", strconv.Itoa(params.Timeout), url}
+	testStart := time.Now()
+	for i := 0; i < params.Requests; i++ {
+		output, err := m.execCommandInPod(ctx, source.Namespace, source.Name, params.SourceContainer, command)
+		code := strings.TrimSpace(output)
+		if err != nil && code == "" {
+			code = "error"
+		}
+		result.ResponseCodes[code]++
+		result.RequestsSent++
+	}
+
+	for _, pod := range waypointPods {
+		previous, ok := before[pod.Name]
+		if !ok {
+			continue
+		}
+		stats, err := m.waypointStats(ctx, &pod, host, params.Port)
+		if err != nil {
+			result.Notes = append(result.Notes, fmt.Sprintf("Failed to read stats from waypoint pod %s: %v", pod.Name, err))
+			continue
+		}
+		result.WaypointRequests += stats["upstream_rq_total"] - previous["upstream_rq_total"]
+		for _, decision := range []string{"allowed", "denied"} {
+			if delta := stats["rbac."+decision] - previous["rbac."+decision]; delta > 0 {
+				result.WaypointRBAC[decision] += delta
+			}
+		}
+
+		sinceSeconds := int64(time.Since(testStart).Seconds()) + 5
+		logs, err := m.k8sClient.Kubernetes.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+			SinceSeconds: &sinceSeconds,
+		}).Do(ctx).Raw()
+		if err == nil {
+			scanner := bufio.NewScanner(strings.NewReader(string(logs)))
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				if strings.Contains(scanner.Text(), marker) {
+					result.AccessLogMatches++
+				}
+			}
+		}
+	}
+
+	result.ThroughWaypoint = result.WaypointRequests > 0 || result.AccessLogMatches > 0 ||
+		result.WaypointRBAC["allowed"] > 0 || result.WaypointRBAC["denied"] > 0
+
+	answered := result.RequestsSent - result.ResponseCodes["000"] - result.ResponseCodes["error"]
+	switch {
+	case answered == 0:
+		result.Issues = append(result.Issues, fmt.Sprintf("None of the %d test requests to %s received a response", result.RequestsSent, result.Service))
+	case binding.Waypoint != "" && len(before) > 0 && !result.ThroughWaypoint:
+		result.Issues = append(result.Issues, fmt.Sprintf("%d of %d requests were answered but the waypoint %s/%s saw none of them; L7 policy is being bypassed",
+			answered, result.RequestsSent, binding.Namespace, binding.Waypoint))
+	}
+	if result.WaypointRBAC["denied"] > 0 {
+		result.Notes = append(result.Notes, fmt.Sprintf("The waypoint denied %d requests, so authorization policy is enforced there", result.WaypointRBAC["denied"]))
+	}
+	if result.ThroughWaypoint && result.AccessLogMatches == 0 {
+		result.Notes = append(result.Notes, "Traffic was confirmed from waypoint stats; no access log entries were found (access logging may be disabled)")
+	}
+
+	resultJSON, _ := json.MarshalIndent(result, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// findWaypointSource returns the named source pod, or the first running app=sleep pod in the namespace
+func (m *Manager) findWaypointSource(ctx context.Context, namespace, podName string) (*corev1.Pod, error) {
+	if podName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).Get(ctx, podName, metav1.GetOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("Failed to get source pod: %v", err)
+		}
+		return pod, nil
+	}
+	pods, err := m.k8sClient.Kubernetes.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "app=sleep"})
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list sleep pods: %v", err)
+	}
+	for i := range pods.Items {
+		if pods.Items[i].Status.Phase == corev1.PodRunning {
+			return &pods.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("No running sleep pods found in namespace %s; specify source_pod", namespace)
+}
+
+// resolveWaypointBinding follows istio.io/use-waypoint labels on the service and its namespace to a waypoint
+func (m *Manager) resolveWaypointBinding(ctx context.Context, svc *corev1.Service) (WaypointBinding, []string) {
+	var binding WaypointBinding
+	var issues []string
+
+	labels := svc.Labels
+	binding.BoundBy = "service"
+	if labels["istio.io/use-waypoint"] == "" {
+		ns, err := m.k8sClient.Kubernetes.CoreV1().Namespaces().Get(ctx, svc.Namespace, metav1.GetOptions{})
+		if err != nil {
+			return binding, []string{fmt.Sprintf("Failed to get namespace %s: %v", svc.Namespace, err)}
+		}
+		labels = ns.Labels
+		binding.BoundBy = "namespace"
+	}
+
+	binding.Waypoint = labels["istio.io/use-waypoint"]
+	switch binding.Waypoint {
+	case "":
+		binding.BoundBy = ""
+		return binding, []string{fmt.Sprintf("Service %s/%s is not bound to a waypoint; label it or its namespace with istio.io/use-waypoint", svc.Namespace, svc.Name)}
+	case "none":
+		binding.Waypoint = ""
+		return binding, []string{fmt.Sprintf("Service %s/%s opts out of waypoints with istio.io/use-waypoint=none", svc.Namespace, svc.Name)}
+	}
+	binding.Namespace = labels["istio.io/use-waypoint-namespace"]
+	if binding.Namespace == "" {
+		binding.Namespace = svc.Namespace
+	}
+
+	gateway, err := m.k8sClient.Dynamic.Resource(gatewayGVR).Namespace(binding.Namespace).Get(ctx, binding.Waypoint, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			issues = append(issues, fmt.Sprintf("Waypoint Gateway %s/%s referenced by istio.io/use-waypoint does not exist", binding.Namespace, binding.Waypoint))
+		} else {
+			issues = append(issues, fmt.Sprintf("Failed to get waypoint Gateway %s/%s: %v", binding.Namespace, binding.Waypoint, err))
+		}
+		return binding, issues
+	}
+
+	className, _, _ := unstructured.NestedString(gateway.Object, "spec", "gatewayClassName")
+	if className != "istio-waypoint" {
+		issues = append(issues, fmt.Sprintf("Gateway %s/%s has gatewayClassName %q, not istio-waypoint", binding.Namespace, binding.Waypoint, className))
+	}
+
+	// Waypoints handle service-addressed traffic unless istio.io/waypoint-for says otherwise
+	binding.WaypointFor = gateway.GetLabels()["istio.io/waypoint-for"]
+	if binding.WaypointFor == "" {
+		binding.WaypointFor = "service"
+	}
+	if binding.WaypointFor == "workload" || binding.WaypointFor == "none" {
+		issues = append(issues, fmt.Sprintf("Waypoint %s/%s is labeled istio.io/waypoint-for=%s, so traffic addressed to the service does not use it",
+			binding.Namespace, binding.Waypoint, binding.WaypointFor))
+	}
+	return binding, issues
+}
+
+// checkWaypointPolicies lists L7 AuthorizationPolicies for the service and flags ones ztunnel enforces instead of the waypoint
+func (m *Manager) checkWaypointPolicies(ctx context.Context, svc *corev1.Service, binding WaypointBinding) ([]string, []string) {
+	var l7Policies, issues []string
+
+	var items []map[string]interface{}
+	for _, gvr := range authorizationPolicyGVRs {
+		list, err := m.k8sClient.Dynamic.Resource(gvr).Namespace(svc.Namespace).List(ctx, metav1.ListOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			return nil, []string{fmt.Sprintf("Failed to list AuthorizationPolicies: %v", err)}
+		}
+		for _, item := range list.Items {
+			items = append(items, item.Object)
+		}
+		break
+	}
+
+	for _, item := range items {
+		var policy waypointPolicy
+		if err := remarshal(item, &policy); err != nil || !policy.hasL7Rules() {
+			continue
+		}
+		name := fmt.Sprintf("%s/%s", policy.Metadata.Namespace, policy.Metadata.Name)
+
+		refs := policy.Spec.TargetRefs
+		if policy.Spec.TargetRef != nil {
+			refs = append(refs, *policy.Spec.TargetRef)
+		}
+		if len(refs) == 0 {
+			// Selector and namespace-wide policies are enforced by ztunnel, which cannot evaluate L7 attributes
+			if policy.Spec.Selector == nil || selectorMatches(policy.Spec.Selector, svc.Spec.Selector) {
+				issues = append(issues, fmt.Sprintf("AuthorizationPolicy %s has L7 rules but no targetRefs; ztunnel enforces it and cannot evaluate L7 attributes, so attach it to the waypoint or service with targetRefs", name))
+			}
+			continue
+		}
+
+		for _, ref := range refs {
+			targetsWaypoint := ref.Kind == "Gateway" && ref.Name == binding.Waypoint && policy.Metadata.Namespace == binding.Namespace
+			targetsService := ref.Kind == "Service" && ref.Name == svc.Name
+			if targetsWaypoint || targetsService {
+				l7Policies = append(l7Policies, name)
+				break
+			}
+		}
+	}
+	sort.Strings(l7Policies)
+	return l7Policies, issues
+}
+
+// hasL7Rules reports whether any rule uses attributes only a waypoint or sidecar can evaluate
+func (p *waypointPolicy) hasL7Rules() bool {
+	for _, rule := range p.Spec.Rules {
+		for _, to := range rule.To {
+			op := to.Operation
+			if len(op.Hosts)+len(op.NotHosts)+len(op.Methods)+len(op.NotMethods)+len(op.Paths)+len(op.NotPaths) > 0 {
+				return true
+			}
+		}
+		for _, from := range rule.From {
+			if len(from.Source.RequestPrincipals)+len(from.Source.NotRequestPrincipals) > 0 {
+				return true
+			}
+		}
+		for _, when := range rule.When {
+			if strings.HasPrefix(when.Key, "request.") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// waypointStats reads the waypoint's upstream request and RBAC counters for a service
+func (m *Manager) waypointStats(ctx context.Context, pod *corev1.Pod, host string, port int) (map[string]int, error) {
+	body, err := m.portForwardGet(ctx, pod.Namespace, pod.Name, 15000, "/stats?filter=inbound-vip|rbac")
+	if err != nil {
+		return nil, err
+	}
+
+	// Waypoint clusters for a service are named inbound-vip|<port>|<protocol>|<host>
+	clusterPrefix := fmt.Sprintf("cluster.inbound-vip|%d|", port)
+	stats := make(map[string]int)
+	scanner := bufio.NewScanner(strings.NewReader(string(body)))
+	for scanner.Scan() {
+		name, value, found := strings.Cut(scanner.Text(), ": ")
+		if !found {
+			continue
+		}
+		count, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(name, clusterPrefix) && strings.Contains(name, "|"+host) && strings.HasSuffix(name, ".upstream_rq_total"):
+			stats["upstream_rq_total"] += count
+		case strings.HasSuffix(name, "rbac.allowed"):
+			stats["rbac.allowed"] += count
+		case strings.HasSuffix(name, "rbac.denied"):
+			stats["rbac.denied"] += count
+		}
+	}
+	return stats, nil
+}
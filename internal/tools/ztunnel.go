@@ -0,0 +1,315 @@
+package tools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ztunnelAdminPort is the port of ztunnel's admin server, which only listens on localhost
+const ztunnelAdminPort = 15000
+
+// certificateExpiryWarning is how close to expiry a workload certificate must be to be reported
+const certificateExpiryWarning = time.Hour
+
+// ZtunnelCertificate summarizes a workload certificate held by ztunnel
+type ZtunnelCertificate struct {
+	Identity   string     `json:"identity"`
+	State      string     `json:"state"`
+	Serial     string     `json:"serial,omitempty"`
+	ValidFrom  *time.Time `json:"valid_from,omitempty"`
+	Expiration *time.Time `json:"expiration,omitempty"`
+	ExpiresIn  string     `json:"expires_in,omitempty"`
+}
+
+// ZtunnelConfig is the workload, service and certificate state of a ztunnel instance
+type ZtunnelConfig struct {
+	Ztunnel      string                   `json:"ztunnel"`
+	Node         string                   `json:"node"`
+	Summary      map[string]int           `json:"summary"`
+	Workloads    []map[string]interface{} `json:"workloads,omitempty"`
+	Services     []map[string]interface{} `json:"services,omitempty"`
+	Policies     []map[string]interface{} `json:"policies,omitempty"`
+	Certificates []ZtunnelCertificate     `json:"certificates,omitempty"`
+	Issues       []string                 `json:"issues,omitempty"`
+	Timestamp    time.Time                `json:"timestamp"`
+}
+
+// getZtunnelConfigParams are the arguments of get_ztunnel_config
+type getZtunnelConfigParams struct {
+	Node      string `json:"node,omitempty"`       // node whose ztunnel to inspect
+	PodName   string `json:"pod_name,omitempty"`   // or: an ambient pod, whose node is used
+	Namespace string `json:"namespace,omitempty"`  // namespace of pod_name (default: default)
+	Section   string `json:"section,omitempty"`    // summary, workloads, services, policies, certificates or all (default: summary)
+	Filter    string `json:"filter,omitempty"`     // only entries whose name, namespace or hostname contains this
+	LocalOnly bool   `json:"local_only,omitempty"` // only workloads scheduled on the node
+}
+
+// GetZtunnelConfig dumps the workload, service, policy and certificate state of the ztunnel on a node
+func (m *Manager) GetZtunnelConfig(ctx context.Context, args json.RawMessage) (*CallToolResult, error) {
+	var params getZtunnelConfigParams
+
+	if err := json.Unmarshal(args, &params); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid parameters: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	// Set defaults
+	if params.Namespace == "" {
+		params.Namespace = "default"
+	}
+	if params.Section == "" {
+		params.Section = "summary"
+	}
+	validSections := []string{"summary", "workloads", "services", "policies", "certificates", "all"}
+	if !containsString(validSections, params.Section) {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Invalid section %q: must be one of %s", params.Section, strings.Join(validSections, ", ")),
+				},
+			},
+		}, nil
+	}
+
+	if params.Node == "" && params.PodName != "" {
+		pod, err := m.k8sClient.Kubernetes.CoreV1().Pods(params.Namespace).Get(ctx, params.PodName, metav1.GetOptions{})
+		if err != nil {
+			return &CallToolResult{
+				IsError: true,
+				Content: []interface{}{
+					TextContent{
+						Type: "text",
+						Text: fmt.Sprintf("Failed to get pod: %v", err),
+					},
+				},
+			}, nil
+		}
+		params.Node = pod.Spec.NodeName
+	}
+
+	ztunnel, err := m.findZtunnel(ctx, params.Node)
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: err.Error(),
+				},
+			},
+		}, nil
+	}
+
+	body, err := m.portForwardGet(ctx, ztunnel.Namespace, ztunnel.Name, ztunnelAdminPort, "/config_dump")
+	if err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to read ztunnel config dump: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	var dump map[string]interface{}
+	if err := json.Unmarshal(body, &dump); err != nil {
+		return &CallToolResult{
+			IsError: true,
+			Content: []interface{}{
+				TextContent{
+					Type: "text",
+					Text: fmt.Sprintf("Failed to parse ztunnel config dump: %v", err),
+				},
+			},
+		}, nil
+	}
+
+	config := &ZtunnelConfig{
+		Ztunnel:   fmt.Sprintf("%s/%s", ztunnel.Namespace, ztunnel.Name),
+		Node:      ztunnel.Spec.NodeName,
+		Summary:   make(map[string]int),
+		Timestamp: time.Now(),
+	}
+
+	workloads := filterZtunnelEntries(ztunnelEntries(dump["workloads"]), params.Filter)
+	if params.LocalOnly {
+		local := workloads[:0]
+		for _, workload := range workloads {
+			if node, _ := workload["node"].(string); node == ztunnel.Spec.NodeName {
+				local = append(local, workload)
+			}
+		}
+		workloads = local
+	}
+	services := filterZtunnelEntries(ztunnelEntries(dump["services"]), params.Filter)
+	policies := filterZtunnelEntries(ztunnelEntries(dump["policies"]), params.Filter)
+	certificates := ztunnelCertificates(dump["certificates"])
+
+	config.Summary["workloads"] = len(workloads)
+	config.Summary["services"] = len(services)
+	config.Summary["policies"] = len(policies)
+	config.Summary["certificates"] = len(certificates)
+	for _, workload := range workloads {
+		if status, _ := workload["status"].(string); status != "" && status != "Healthy" {
+			config.Summary["unhealthy_workloads"]++
+		}
+		if protocol, _ := workload["protocol"].(string); protocol == "HBONE" {
+			config.Summary["hbone_workloads"]++
+		}
+		if waypoint, ok := workload["waypoint"]; ok && waypoint != nil {
+			config.Summary["workloads_with_waypoint"]++
+		}
+	}
+
+	for _, cert := range certificates {
+		switch {
+		case cert.State != "" && !strings.EqualFold(cert.State, "Available"):
+			config.Issues = append(config.Issues, fmt.Sprintf("Certificate for %s is %s", cert.Identity, cert.State))
+		case cert.Expiration != nil && time.Until(*cert.Expiration) < 0:
+			config.Issues = append(config.Issues, fmt.Sprintf("Certificate for %s expired at %s", cert.Identity, cert.Expiration.Format(time.RFC3339)))
+		case cert.Expiration != nil && time.Until(*cert.Expiration) < certificateExpiryWarning:
+			config.Issues = append(config.Issues, fmt.Sprintf("Certificate for %s expires in %s", cert.Identity, cert.ExpiresIn))
+		}
+	}
+
+	switch params.Section {
+	case "workloads":
+		config.Workloads = workloads
+	case "services":
+		config.Services = services
+	case "policies":
+		config.Policies = policies
+	case "certificates", "summary":
+		config.Certificates = certificates
+	case "all":
+		config.Workloads = workloads
+		config.Services = services
+		config.Policies = policies
+		config.Certificates = certificates
+	}
+
+	resultJSON, _ := json.MarshalIndent(config, "", "  ")
+	return &CallToolResult{
+		Content: []interface{}{
+			TextContent{
+				Type: "text",
+				Text: string(resultJSON),
+			},
+		},
+	}, nil
+}
+
+// findZtunnel returns the ztunnel pod on a node, or the only ztunnel pod when no node is given
+func (m *Manager) findZtunnel(ctx context.Context, node string) (*corev1.Pod, error) {
+	ztunnels := m.ztunnelPodsByNode(ctx)
+	if len(ztunnels) == 0 {
+		return nil, fmt.Errorf("no ztunnel pods found; ambient mode is not installed")
+	}
+	if node == "" {
+		if len(ztunnels) > 1 {
+			nodes := make([]string, 0, len(ztunnels))
+			for name := range ztunnels {
+				nodes = append(nodes, name)
+			}
+			sort.Strings(nodes)
+			return nil, fmt.Errorf("ztunnel runs on %d nodes; specify node or pod_name (nodes: %s)", len(ztunnels), strings.Join(nodes, ", "))
+		}
+		for _, pod := range ztunnels {
+			return pod, nil
+		}
+	}
+	pod, ok := ztunnels[node]
+	if !ok {
+		return nil, fmt.Errorf("no ztunnel pod runs on node %s", node)
+	}
+	return pod, nil
+}
+
+// ztunnelEntries normalizes a config dump section, which is a list or a map keyed by uid depending on the version
+func ztunnelEntries(section interface{}) []map[string]interface{} {
+	var entries []map[string]interface{}
+	switch v := section.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if entry, ok := item.(map[string]interface{}); ok {
+				entries = append(entries, entry)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if entry, ok := v[key].(map[string]interface{}); ok {
+				entries = append(entries, entry)
+			}
+		}
+	}
+	return entries
+}
+
+// filterZtunnelEntries keeps entries whose name, namespace or hostname contains the filter
+func filterZtunnelEntries(entries []map[string]interface{}, filter string) []map[string]interface{} {
+	if filter == "" {
+		return entries
+	}
+	var filtered []map[string]interface{}
+	for _, entry := range entries {
+		for _, field := range []string{"name", "namespace", "hostname", "workloadName", "serviceAccount"} {
+			if value, _ := entry[field].(string); strings.Contains(value, filter) {
+				filtered = append(filtered, entry)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+// ztunnelCertificates summarizes the certificates section of a config dump
+func ztunnelCertificates(section interface{}) []ZtunnelCertificate {
+	var certs []ZtunnelCertificate
+	for _, entry := range ztunnelEntries(section) {
+		cert := ZtunnelCertificate{}
+		cert.Identity, _ = entry["identity"].(string)
+		cert.State, _ = entry["state"].(string)
+
+		chain, _ := entry["certChain"].([]interface{})
+		if len(chain) > 0 {
+			leaf, _ := chain[0].(map[string]interface{})
+			cert.Serial, _ = leaf["serialNumber"].(string)
+			if value, ok := leaf["validFrom"].(string); ok {
+				if t, err := time.Parse(time.RFC3339, value); err == nil {
+					cert.ValidFrom = &t
+				}
+			}
+			if value, ok := leaf["expirationTime"].(string); ok {
+				if t, err := time.Parse(time.RFC3339, value); err == nil {
+					cert.Expiration = &t
+					cert.ExpiresIn = time.Until(t).Round(time.Second).String()
+				}
+			}
+		}
+		certs = append(certs, cert)
+	}
+	return certs
+}
@@ -11,6 +11,7 @@ import (
 	"syscall"
 	"time"
 
+	"meshpilot/internal/config"
 	"meshpilot/internal/k8s"
 	"meshpilot/internal/mcp"
 	"meshpilot/internal/tools"
@@ -49,17 +50,28 @@ func main() {
 
 	// Initialize Kubernetes client
 	k8sClient, err := k8s.NewClient()
+	if err != nil {
+		// Tools retry on their first call, so a kubeconfig provided later is picked up; dev cluster tools can still create one
+		if !isMCPMode {
+			logrus.Warnf("Failed to create Kubernetes client: %v", err)
+		}
+		k8sClient = nil
+	}
+
+	// Load server configuration
+	cfg, err := config.Load(config.DefaultPath())
 	if err != nil {
 		if isMCPMode {
-			// In MCP mode, fail silently and let the MCP client handle errors
-			k8sClient = nil
+			// In MCP mode, fall back to defaults rather than failing to start
+			logrus.Errorf("Failed to load config, using defaults: %v", err)
+			cfg = config.Default()
 		} else {
-			log.Fatalf("Failed to create Kubernetes client: %v", err)
+			log.Fatalf("Failed to load config: %v", err)
 		}
 	}
 
 	// Initialize tool manager
-	toolManager := tools.NewManager(k8sClient)
+	toolManager := tools.NewManager(k8sClient, cfg)
 
 	// Create MCP server using official SDK
 	server := mcp.NewServer("meshpilot", "0.1.0", toolManager)
@@ -70,6 +82,8 @@ func main() {
 	if isMCPMode {
 		// Running as MCP server - handle stdio communication
 		ctx := context.Background()
+		server.ProbePermissions(ctx)
+		toolManager.StartScheduler(ctx)
 		if err := server.Serve(ctx); err != nil {
 			logrus.Errorf("MCP server failed: %v", err)
 			os.Exit(1)
@@ -100,6 +114,14 @@ func main() {
 			handleDirectExecution(toolManager)
 			return
 		}
+		if os.Args[1] == "--http" {
+			addr := "127.0.0.1:8080"
+			if len(os.Args) > 2 {
+				addr = os.Args[2]
+			}
+			serveHTTP(server, toolManager, addr)
+			return
+		}
 		fmt.Printf("Unknown argument: %s\n", os.Args[1])
 		showHelp()
 		return
@@ -144,6 +166,11 @@ func main() {
 	}
 	defer serverCancel()
 
+	server.ProbePermissions(serverCtx)
+
+	// Scheduled health checks run for as long as the server does
+	toolManager.StartScheduler(serverCtx)
+
 	done := make(chan error, 1)
 	go func() {
 		done <- server.Serve(serverCtx)
@@ -163,6 +190,31 @@ func main() {
 	}
 }
 
+// serveHTTP runs the MCP server over HTTP until SIGINT or SIGTERM, for in-cluster deployments
+// that several agents connect to
+func serveHTTP(server *mcp.Server, toolManager *tools.Manager, addr string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		logrus.Infof("Received signal %s, shutting down gracefully...", sig)
+		cancel()
+	}()
+
+	server.ProbePermissions(ctx)
+
+	// Scheduled health checks run for as long as the server does
+	toolManager.StartScheduler(ctx)
+
+	if err := server.ListenAndServe(ctx, addr, os.Getenv("MESHPILOT_HTTP_TOKEN")); err != nil {
+		log.Fatalf("MCP HTTP server failed: %v", err)
+	}
+	logrus.Info("MeshPilot HTTP server stopped")
+}
+
 // handleDirectExecution allows direct tool execution from command line
 func handleDirectExecution(toolManager *tools.Manager) {
 	if len(os.Args) < 3 {
@@ -210,7 +262,16 @@ func handleDirectExecution(toolManager *tools.Manager) {
 		os.Exit(1)
 	}
 
-	result, err := toolManager.ExecuteTool(toolName, args)
+	// Ctrl-C cancels the tool's in-flight Kubernetes, Helm and exec calls
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	// Tools that stream, such as get_pod_logs with follow, print their output as it arrives
+	ctx = tools.WithProgress(ctx, func(message string) {
+		fmt.Println(message)
+	})
+
+	result, err := toolManager.ExecuteTool(ctx, toolName, args)
 	if err != nil {
 		fmt.Printf("❌ Error executing tool %s: %v\n", toolName, err)
 		os.Exit(1)
@@ -234,6 +295,7 @@ OPTIONS:
     --tool-help <name>  Show detailed help for a specific tool
     --tool <name>       Execute a specific tool
         --args <json>   JSON arguments for the tool (optional)
+    --http [addr]       Serve MCP over streamable HTTP (/mcp) and SSE (/sse) (default addr: 127.0.0.1:8080; set MESHPILOT_HTTP_TOKEN to require a bearer token)
 
 EXAMPLES:
     # Start MCP server (production mode - runs until Ctrl+C)
@@ -242,6 +304,9 @@ EXAMPLES:
     # Start MCP server in demo mode (30s timeout)
     MESHPILOT_DEMO=true ./meshpilot
 
+    # Serve MCP over HTTP for several agents, e.g. from inside the cluster
+    MESHPILOT_HTTP_TOKEN=<secret> ./meshpilot --http :8080
+
     # Show available tools
     ./meshpilot --list-tools
 
@@ -253,14 +318,20 @@ EXAMPLES:
     ./meshpilot --tool get_cluster_info --args '{}'
     ./meshpilot --tool install_istio --args '{"profile":"demo","namespace":"istio-system"}'
 
+    # Run a tool against another kubeconfig context without switching the current one
+    ./meshpilot --tool check_istio_status --args '{"context":"kind-east"}'
+
 TOOL CATEGORIES:
-    📋 Cluster Management: list_contexts, switch_context, get_cluster_info
-    🕸️  Istio Management: install_istio, uninstall_istio, check_istio_status
+    📋 Cluster Management: list_contexts, switch_context, configure_kubeconfig, get_cluster_info, validate_access, get_fleet_status, check_tool_permissions, create_dev_cluster, delete_dev_cluster, install_metallb, self_test
+    🕸️  Istio Management: install_istio, uninstall_istio, istio_canary_upgrade, migrate_istio_install, migrate_to_ambient, check_istio_status, proxy_status, istiod_debug, audit_istiod_flags, watch_mesh_events, inspect_revision_tags, audit_discovery_selectors, configure_discovery_selectors, audit_istio_resources, istio_analyze, get_injection_config, set_injection_template, preview_injection, install_otel_collector, configure_tracing, get_release_values, list_available_istio_versions, get_istio_release_notes, check_install_capacity, estimate_mesh_cost, check_cni_chaining, detect_cni_race
     ⛵ Sail Operator: install_sail_operator, uninstall_sail_operator, check_sail_status
-    📦 Sample Apps: deploy_sleep_app, deploy_httpbin_app, undeploy_*_app
-    🔗 Connectivity: test_connectivity, test_sleep_to_httpbin
-    📄 Logging: get_pod_logs, get_istio_proxy_logs, exec_pod_command
-    🌐 Network Debug: get_iptables_rules, get_network_policies, trace_network_path
+    📦 Sample Apps: deploy_sleep_app, deploy_httpbin_app, deploy_bookinfo_app, undeploy_*_app, apply_manifest, cleanup_demo, list_managed_resources
+    🔗 Connectivity: test_connectivity, test_sleep_to_httpbin, test_ingress_connectivity, test_gateway_paths, sweep_service_ports, probe_gateway_tls, diagnose_ingress_request, verify_waypoint, test_header_routing, generate_traffic, configure_egress_routing, run_mesh_conformance, benchmark_mesh_overhead, start_monitor, stop_monitor, get_monitor_results, get_scheduled_results
+    🚦 Traffic Management: traffic_shift, create_virtual_service, get_virtual_service, delete_virtual_service, create_destination_rule, get_destination_rule, delete_destination_rule, detect_connection_pool_exhaustion, expose_service_via_gateway, create_gateway_route, get_gateway_routes, delete_gateway_route, get_gateway_status
+    📄 Logging: get_pod_logs, get_istio_proxy_logs, get_istiod_logs, analyze_response_flags, get_kubernetes_events, exec_pod_command
+    🌐 Network Debug: get_iptables_rules, capture_packets, get_interception_mode, inspect_sidecar_annotations, configure_traffic_exclusions, configure_dns_proxying, tune_proxy, audit_sidecar_startup, diagnose_job_sidecars, detect_dataplane_mode, get_proxy_config, envoy_admin_get, get_ztunnel_config, get_network_policies, generate_network_policy, trace_network_path, diagnose_pod_node_network, validate_dual_stack, check_clock_skew
+    🛡️  Security: scan_mesh_images, setup_ext_authz, test_ext_authz, install_spire, configure_istio_spire, verify_spire_identities, set_mtls_mode, get_mtls_status, check_mtls_between, get_workload_certificates, inspect_trust_domain, create_authorization_policy, get_authorization_policy, delete_authorization_policy, audit_authorization_policies
+    🗂️  History: list_history, get_result, compare_with_snapshot
 
 For detailed documentation, see README.md`)
 }
@@ -274,12 +345,44 @@ func listTools(server *mcp.Server) {
 		"📋 Cluster Management": {
 			"list_contexts - List available Kubernetes contexts",
 			"switch_context - Switch to a different Kubernetes context",
-			"get_cluster_info - Get information about the current cluster",
+			"configure_kubeconfig - Provide or refresh the kubeconfig at runtime",
+			"get_cluster_info - Get cluster version, platform, CNI, ingress/gateway classes and add-ons",
+			"validate_access - Check kubeconfig contexts, API reachability and credential expiry",
+			"get_fleet_status - Survey reachability, Kubernetes/Istio versions and mesh health across contexts",
+			"check_tool_permissions - List tools the current credentials cannot run",
+			"create_dev_cluster - Create a local kind or minikube cluster",
+			"delete_dev_cluster - Delete a local kind or minikube cluster",
+			"install_metallb - Install MetalLB so LoadBalancer services get an external IP",
+			"self_test - Validate the meshpilot and cluster setup end to end",
 		},
 		"🕸️  Istio Management": {
 			"install_istio - Install Istio on the cluster using Helm (with optional CNI support)",
 			"uninstall_istio - Uninstall Istio from the cluster using Helm",
+			"istio_canary_upgrade - Upgrade Istio by installing a new revision and moving namespaces to it",
+			"migrate_istio_install - Move an istioctl/IstioOperator installation to Helm or Sail management",
+			"migrate_to_ambient - Move namespaces from sidecars to ambient with validation and rollback",
 			"check_istio_status - Check Istio installation status",
+			"proxy_status - Show xDS sync and NACK state of every proxy",
+			"istiod_debug - Read istiod syncz, push_status or adsz filtered by proxy",
+			"audit_istiod_flags - Map istiod feature flags and flag risky or deprecated ones",
+			"watch_mesh_events - Follow Warning events and pod restarts in Istio and gateway namespaces",
+			"inspect_revision_tags - Map revision tags and namespaces to istiod revisions",
+			"audit_discovery_selectors - Show which namespaces are inside istiod's discovery scope",
+			"configure_discovery_selectors - Restrict istiod to selected namespaces with discoverySelectors",
+			"audit_istio_resources - Find Istio resources with dangling references as cleanup candidates",
+			"istio_analyze - Analyze Istio and Kubernetes config for common misconfigurations",
+			"get_injection_config - Show the sidecar injection policy, templates and values",
+			"set_injection_template - Add or override custom sidecar injection templates and values",
+			"preview_injection - Render a canary pod through the injection webhook",
+			"install_otel_collector - Deploy an OpenTelemetry collector for mesh traces",
+			"configure_tracing - Send mesh traces to an OpenTelemetry collector and verify spans arrive",
+			"check_install_capacity - Check cluster capacity and quotas before installing Istio",
+			"estimate_mesh_cost - Sum mesh resource requests and project injection overhead",
+			"check_cni_chaining - Check istio-cni chaining and DaemonSet coverage on every node",
+			"detect_cni_race - Find pods that started before the Istio CNI agent was ready",
+			"get_release_values - Show the Helm values of an installed mesh release",
+			"list_available_istio_versions - List installable Istio versions from the Helm repository",
+			"get_istio_release_notes - Summarize upgrade notes and their impact on the running config",
 		},
 		"⛵ Sail Operator": {
 			"install_sail_operator - Install Sail operator using Helm",
@@ -291,20 +394,96 @@ func listTools(server *mcp.Server) {
 			"deploy_httpbin_app - Deploy httpbin sample application",
 			"undeploy_sleep_app - Remove sleep sample application",
 			"undeploy_httpbin_app - Remove httpbin sample application",
+			"deploy_bookinfo_app - Deploy Bookinfo sample application",
+			"undeploy_bookinfo_app - Remove Bookinfo sample application",
+			"apply_manifest - Server-side apply a YAML/JSON manifest",
+			"cleanup_demo - Remove everything meshpilot deployed",
+			"list_managed_resources - Inventory the resources meshpilot created",
 		},
 		"🔗 Connectivity Testing": {
 			"test_connectivity - Test connectivity between pods",
 			"test_sleep_to_httpbin - Test connectivity from sleep to httpbin",
+			"test_ingress_connectivity - Test requests through the ingress gateway from outside the cluster",
+			"test_gateway_paths - Compare the gateway via pod, ClusterIP, node ports and load balancer",
+			"sweep_service_ports - Probe every service port in a namespace and report listening/refused/filtered",
+			"probe_gateway_tls - Probe the ingress gateway with SNI/ALPN/Host combinations",
+			"diagnose_ingress_request - Explain why a host and path fail at the ingress gateway",
+			"verify_waypoint - Confirm traffic to a service actually traverses its ambient waypoint",
+			"test_header_routing - Check which backend versions answer requests with given headers/cookies",
+			"generate_traffic - Send run-ID tagged requests at a fixed rate for a duration",
+			"configure_egress_routing - Route external hosts through the egress gateway and verify it",
+			"run_mesh_conformance - Check routing, faults, retries, mTLS and authorization in a sandbox",
+			"benchmark_mesh_overhead - Measure latency and CPU overhead added by the sidecars",
+			"start_monitor - Start periodic background probes of endpoints from inside the cluster",
+			"stop_monitor - Stop a connectivity monitor",
+			"get_monitor_results - Summarize monitor results and stability over a time window",
+			"get_scheduled_results - Show the recorded outcomes of scheduled health checks",
+		},
+		"🚦 Traffic Management": {
+			"traffic_shift - Split a service's traffic between two subsets for a canary",
+			"create_virtual_service - Create or update a VirtualService",
+			"get_virtual_service - Show one or all VirtualServices in a namespace",
+			"delete_virtual_service - Delete a VirtualService",
+			"create_destination_rule - Create or update a DestinationRule",
+			"get_destination_rule - Show one or all DestinationRules in a namespace",
+			"delete_destination_rule - Delete a DestinationRule",
+			"detect_connection_pool_exhaustion - Find connection pool overflows and suggest DestinationRule limits",
+			"expose_service_via_gateway - Route a service through the ingress gateway and return the URL to curl",
+			"create_gateway_route - Create or update a Gateway API HTTPRoute or GRPCRoute",
+			"get_gateway_routes - Show Gateway API routes with the status their gateways report",
+			"delete_gateway_route - Delete a Gateway API HTTPRoute or GRPCRoute",
+			"get_gateway_status - Check that Gateway API gateways and their routes are accepted and programmed",
 		},
 		"📄 Logging & Debugging": {
 			"get_pod_logs - Get logs from a specific pod",
 			"get_istio_proxy_logs - Get Istio proxy logs from a pod",
+			"get_istiod_logs - Get istiod logs filtered by scope and level",
+			"analyze_response_flags - Count Envoy response flags in a namespace's access logs and explain them",
+			"get_kubernetes_events - List Events of a namespace, pod, object or Istio component",
 			"exec_pod_command - Execute a command in a pod",
 		},
 		"🌐 Network Debugging": {
 			"get_iptables_rules - Get iptables rules from a pod",
-			"get_network_policies - Get network policies in a namespace",
+			"capture_packets - Capture a pod's traffic with tcpdump and return the pcap",
+			"get_interception_mode - Show how pod traffic is intercepted (istio-init, CNI or ambient)",
+			"inspect_sidecar_annotations - Explain and check a workload's sidecar annotations",
+			"configure_traffic_exclusions - Set sidecar port/CIDR exclusions and verify them in iptables",
+			"configure_dns_proxying - Toggle sidecar DNS capture and auto-allocation and verify interception",
+			"tune_proxy - Set proxy concurrency, resources and stats and measure sidecar CPU before and after",
+			"audit_sidecar_startup - Find workloads racing Envoy at startup and enable holdApplicationUntilProxyStarts or native sidecars",
+			"diagnose_job_sidecars - Find Jobs held open by istio-proxy and release or fix them",
+			"detect_dataplane_mode - Detect sidecar vs ambient mode per namespace",
+			"get_proxy_config - Show Envoy clusters, listeners, routes or endpoints of a sidecar",
+			"envoy_admin_get - Read a whitelisted read-only Envoy admin endpoint of a proxy",
+			"get_ztunnel_config - Dump ztunnel workload, service and certificate state",
+			"get_network_policies - Get network policies per namespace, flagging default-deny namespaces",
+			"generate_network_policy - Generate a least-privilege network policy for an app",
 			"trace_network_path - Trace network path between pods",
+			"diagnose_pod_node_network - Inspect the node side of a pod's network path",
+			"validate_dual_stack - Validate IPv4/IPv6 dual-stack settings and connectivity",
+			"check_clock_skew - Compare node clocks against istiod's for certificate-breaking skew",
+		},
+		"🛡️  Security": {
+			"scan_mesh_images - Scan mesh and sample app images for vulnerabilities",
+			"setup_ext_authz - Deploy a sample ext-authz service and protect a workload with it",
+			"test_ext_authz - Verify ext_authz allows and denies requests end to end",
+			"install_spire - Install SPIRE with the SPIFFE CSI driver",
+			"configure_istio_spire - Make Istio proxies take their identities from SPIRE",
+			"verify_spire_identities - Check workload certificates carry the expected SPIFFE IDs",
+			"set_mtls_mode - Set STRICT/PERMISSIVE/DISABLE mTLS mesh-wide, per namespace or per workload",
+			"get_mtls_status - Show the effective mTLS mode of every workload and its source policy",
+			"check_mtls_between - Verify whether traffic between two workloads is actually mTLS encrypted",
+			"get_workload_certificates - Show SANs, issuer and days to expiry of proxy and gateway certificates",
+			"inspect_trust_domain - Check workload SPIFFE IDs and token audiences against the trust domain",
+			"create_authorization_policy - Create or update an AuthorizationPolicy",
+			"get_authorization_policy - Show one or all AuthorizationPolicies in a namespace",
+			"delete_authorization_policy - Delete an AuthorizationPolicy",
+			"audit_authorization_policies - Flag allow-all, deny-all and unreachable authorization rules",
+		},
+		"🗂️  Result History": {
+			"list_history - List previously recorded tool results",
+			"get_result - Show the full output of a recorded tool result",
+			"compare_with_snapshot - Diff a status or report against a previous run and highlight regressions",
 		},
 	}
 
@@ -319,17 +498,22 @@ func listTools(server *mcp.Server) {
 	fmt.Printf("📖 Help:  ./meshpilot --help\n\n")
 }
 
+// validTools lists every tool name accepted by direct execution
+var validTools = []string{
+	"list_contexts", "switch_context", "configure_kubeconfig", "get_cluster_info", "validate_access", "get_fleet_status", "check_tool_permissions", "create_dev_cluster", "delete_dev_cluster", "install_metallb", "self_test",
+	"install_istio", "uninstall_istio", "istio_canary_upgrade", "migrate_istio_install", "migrate_to_ambient", "check_istio_status", "proxy_status", "istiod_debug", "audit_istiod_flags", "watch_mesh_events", "inspect_revision_tags", "audit_discovery_selectors", "configure_discovery_selectors", "audit_istio_resources", "istio_analyze", "get_injection_config", "set_injection_template", "preview_injection", "install_otel_collector", "configure_tracing", "check_install_capacity", "estimate_mesh_cost", "check_cni_chaining", "detect_cni_race", "get_release_values", "list_available_istio_versions", "get_istio_release_notes",
+	"install_sail_operator", "uninstall_sail_operator", "check_sail_status",
+	"deploy_sleep_app", "deploy_httpbin_app", "undeploy_sleep_app", "undeploy_httpbin_app", "deploy_bookinfo_app", "undeploy_bookinfo_app", "apply_manifest", "cleanup_demo", "list_managed_resources",
+	"test_connectivity", "test_sleep_to_httpbin", "test_ingress_connectivity", "test_gateway_paths", "sweep_service_ports", "probe_gateway_tls", "diagnose_ingress_request", "verify_waypoint", "test_header_routing", "generate_traffic", "configure_egress_routing", "run_mesh_conformance", "benchmark_mesh_overhead", "start_monitor", "stop_monitor", "get_monitor_results", "get_scheduled_results",
+	"traffic_shift", "create_virtual_service", "get_virtual_service", "delete_virtual_service", "create_destination_rule", "get_destination_rule", "delete_destination_rule", "detect_connection_pool_exhaustion", "expose_service_via_gateway", "create_gateway_route", "get_gateway_routes", "delete_gateway_route", "get_gateway_status",
+	"get_pod_logs", "get_istio_proxy_logs", "get_istiod_logs", "analyze_response_flags", "get_kubernetes_events", "exec_pod_command",
+	"get_iptables_rules", "capture_packets", "get_interception_mode", "inspect_sidecar_annotations", "configure_traffic_exclusions", "configure_dns_proxying", "tune_proxy", "audit_sidecar_startup", "diagnose_job_sidecars", "detect_dataplane_mode", "get_proxy_config", "envoy_admin_get", "get_ztunnel_config", "get_network_policies", "generate_network_policy", "trace_network_path", "diagnose_pod_node_network", "validate_dual_stack", "check_clock_skew",
+	"scan_mesh_images", "setup_ext_authz", "test_ext_authz", "install_spire", "configure_istio_spire", "verify_spire_identities", "set_mtls_mode", "get_mtls_status", "check_mtls_between", "get_workload_certificates", "inspect_trust_domain", "create_authorization_policy", "get_authorization_policy", "delete_authorization_policy", "audit_authorization_policies",
+	"list_history", "get_result", "compare_with_snapshot",
+}
+
 // isValidTool checks if a tool name is valid
 func isValidTool(toolName string) bool {
-	validTools := []string{
-		"list_contexts", "switch_context", "get_cluster_info",
-		"install_istio", "uninstall_istio", "check_istio_status",
-		"install_sail_operator", "uninstall_sail_operator", "check_sail_status",
-		"deploy_sleep_app", "deploy_httpbin_app", "undeploy_sleep_app", "undeploy_httpbin_app",
-		"test_connectivity", "test_sleep_to_httpbin",
-		"get_pod_logs", "get_istio_proxy_logs", "exec_pod_command",
-		"get_iptables_rules", "get_network_policies", "trace_network_path",
-	}
 
 	for _, valid := range validTools {
 		if toolName == valid {
@@ -345,15 +529,6 @@ func showToolHelp(toolName string) {
 
 	// Simple fuzzy matching
 	suggestions := []string{}
-	validTools := []string{
-		"list_contexts", "switch_context", "get_cluster_info",
-		"install_istio", "uninstall_istio", "check_istio_status",
-		"install_sail_operator", "uninstall_sail_operator", "check_sail_status",
-		"deploy_sleep_app", "deploy_httpbin_app", "undeploy_sleep_app", "undeploy_httpbin_app",
-		"test_connectivity", "test_sleep_to_httpbin",
-		"get_pod_logs", "get_istio_proxy_logs", "exec_pod_command",
-		"get_iptables_rules", "get_network_policies", "trace_network_path",
-	}
 
 	for _, valid := range validTools {
 		if strings.Contains(valid, toolName) || strings.Contains(toolName, strings.Split(valid, "_")[0]) {
@@ -379,43 +554,243 @@ func showToolParameters(toolName string) {
 
 		"switch_context": "Required: context (string)\n  Example: --args '{\"context\":\"my-cluster\"}'",
 
-		"get_cluster_info": "No parameters required - gets current cluster information\n  Example: --args '{}'",
+		"configure_kubeconfig": "Required: path (string) or content (string, kubeconfig YAML)\n  Optional: context (string)\n  Example: --args '{\"path\":\"/etc/meshpilot/kubeconfig\"}'\n  Example: --args '{\"path\":\"/etc/meshpilot/kubeconfig\",\"context\":\"prod\"}'",
+
+		"get_cluster_info": "Optional: include_namespaces (bool, default: true), namespace_selector (string), namespace_details (bool)\n  Example: --args '{}'\n  Example: --args '{\"namespace_selector\":\"istio-injection=enabled\",\"namespace_details\":true}'\n  Example: --args '{\"include_namespaces\":false}'",
+
+		"validate_access": "Optional: context (string, default: all contexts), timeout (int, default: 5)\n  Example: --args '{}'",
+
+		"get_fleet_status": "Optional: contexts (array, default: all kubeconfig contexts), istio_namespace (string, default: \"istio-system\"), timeout (int, seconds per cluster, default: 10)\n  Example: --args '{}'\n  Example: --args '{\"contexts\":[\"prod-east\",\"prod-west\"]}'",
+
+		"check_tool_permissions": "Optional: tool (string)\n  Example: --args '{}'",
+
+		"create_dev_cluster": "Optional: name (string, default: \"meshpilot\"), provider (string: kind|minikube), kubernetes_version (string), node_image (string), workers (int), http_port (int, default: 80), https_port (int, default: 443), wait (string, default: \"5m\")\n  Example: --args '{\"name\":\"demo\",\"kubernetes_version\":\"v1.29.2\",\"workers\":1}'",
+
+		"delete_dev_cluster": "Optional: name (string, default: \"meshpilot\"), provider (string: kind|minikube), force (bool)\n  Example: --args '{\"name\":\"demo\",\"force\":true}'",
+
+		"self_test": "Optional: provision_cluster (bool), cluster_name (string, default: \"meshpilot-selftest\"), provider (string: kind|minikube, default: kind), istio_version (string), namespace (string, default: \"meshpilot-selftest\"), keep (bool), timeout (string, default: \"5m\")\n  Example: --args '{\"provision_cluster\":true}'",
+
+		"install_metallb": "Optional: namespace (string, default: \"metallb-system\"), version (string), address_pool (array, default: detected on kind), pool_name (string), docker_network (string, default: \"kind\"), timeout (string, default: \"5m\")\n  Example: --args '{\"address_pool\":[\"172.18.255.200-172.18.255.250\"]}'",
+
+		"install_istio": "Optional: namespace (string, default: \"istio-system\"), version (string), profile (string: default|ambient), values (object), install_gateway (bool), gateway_namespace (string, default: \"istio-ingress\"), gateway_mode (string: helm|gateway_api, default: \"helm\"), gateway_api_crds (bool), gateway_api_version (string, default: \"v1.2.1\"), install_cni (bool), cni_values (object), timeout (string, default: \"5m\"), repo_url (string), pin_digests (bool), image_variant (string: default|distroless|fips), skip_preflight (bool)\n  Example: --args '{\"namespace\":\"istio-system\",\"version\":\"1.26.3\",\"install_gateway\":true,\"install_cni\":true}'",
 
-		"install_istio": "Optional: namespace (string, default: \"istio-system\"), version (string), values (object), install_gateway (bool), gateway_namespace (string, default: \"istio-ingress\"), install_cni (bool), cni_values (object), timeout (string, default: \"5m\")\n  Example: --args '{\"namespace\":\"istio-system\",\"version\":\"1.26.3\",\"install_gateway\":true,\"install_cni\":true}'",
+		"uninstall_istio": "Optional: namespace (string, default: \"istio-system\"), gateway_namespace (string, default: \"istio-ingress\"), uninstall_cni (bool), delete_crds (bool, default: false), timeout (string, default: \"5m\"), force (bool)\n  Example: --args '{\"namespace\":\"istio-system\",\"uninstall_cni\":true,\"delete_crds\":true,\"force\":true}'",
 
-		"uninstall_istio": "Optional: namespace (string, default: \"istio-system\"), gateway_namespace (string, default: \"istio-ingress\"), uninstall_cni (bool), delete_crds (bool, default: false), timeout (string, default: \"5m\")\n  Example: --args '{\"namespace\":\"istio-system\",\"uninstall_cni\":true,\"delete_crds\":true}'",
+		"istio_canary_upgrade": "Required: version (string), namespaces ([]string)\n  Optional: revision (string, default: version with dashes), old_revision (string, default: the first namespace's revision), old_release (string, default: \"istiod\" or \"istiod-<old_revision>\"), istio_namespace (string, default: \"istio-system\"), values (object), copy_values (bool, default: true), upgrade_base (bool, default: true), restart (bool, default: true), remove_old_revision (bool, default: false), repo_url (string), timeout (string, default: \"5m\"), force (bool)\n  Example: --args '{\"version\":\"1.23.2\",\"namespaces\":[\"bookinfo\"],\"force\":true}'\n  Example: --args '{\"version\":\"1.23.2\",\"namespaces\":[\"bookinfo\",\"default\"],\"remove_old_revision\":true,\"force\":true}'",
+
+		"migrate_istio_install": "Optional: target (string: helm|sail, default: \"helm\"), istio_namespace (string, default: \"istio-system\"), revision (string, default: \"default\"), version (string, default: the running version), new_revision (string, default: \"sail\"), namespaces ([]string, default: every namespace using the revision), sail_namespace (string, default: \"sail-operator\"), repo_url (string), execute (bool, default: false), timeout (string, default: \"5m\"), force (bool)\n  Example: --args '{}'\n  Example: --args '{\"target\":\"helm\",\"execute\":true,\"force\":true}'\n  Example: --args '{\"target\":\"sail\",\"namespaces\":[\"bookinfo\"],\"execute\":true,\"force\":true}'",
+
+		"migrate_to_ambient": "Required: namespaces ([]string)\n  Optional: waypoint_name (string, default: \"waypoint\"), deploy_waypoints (bool, default: true), source_pod (string), source_namespace (string, default: the migrated namespace), rollback (bool, default: true), execute (bool, default: false), timeout (string, default: \"5m\"), force (bool)\n  Example: --args '{\"namespaces\":[\"bookinfo\"]}'\n  Example: --args '{\"namespaces\":[\"frontend\",\"backend\"],\"execute\":true}'",
 
 		"check_istio_status": "Optional: namespace (string, default: \"istio-system\")\n  Example: --args '{\"namespace\":\"istio-system\"}'",
 
-		"install_sail_operator": "Optional: namespace (string, default: \"sail-operator\"), version (string), release_name (string, default: \"sail-operator\"), values (object), timeout (string, default: \"5m\")\n  Example: --args '{\"namespace\":\"sail-operator\",\"version\":\"1.24.0\"}'",
+		"proxy_status": "Optional: namespace (string, default: all namespaces), istio_namespace (string, default: \"istio-system\"), only_problems (bool)\n  Example: --args '{}'\n  Example: --args '{\"namespace\":\"bookinfo\",\"only_problems\":true}'",
+
+		"istiod_debug": "Required: endpoint (string: syncz|push_status|adsz)\n  Optional: proxy (string), namespace (string), istio_namespace (string, default: \"istio-system\"), revision (string), limit (int, default: 100)\n  Example: --args '{\"endpoint\":\"syncz\",\"namespace\":\"bookinfo\"}'\n  Example: --args '{\"endpoint\":\"adsz\",\"proxy\":\"productpage-v1\"}'",
+
+		"audit_istiod_flags": "Optional: istio_namespace (string, default: \"istio-system\"), revision (string), include_defaults (bool)\n  Example: --args '{}'\n  Example: --args '{\"revision\":\"1-24-0\"}'",
+
+		"watch_mesh_events": "Optional: istio_namespace (string, default: \"istio-system\"), namespaces ([]string), include_gateways (bool, default: true), duration (string, default: \"1m\", max: \"10m\"), stop_on_warning (bool), max_events (int, default: 200)\n  Example: --args '{\"duration\":\"5m\"}'\n  Example: --args '{\"duration\":\"10m\",\"stop_on_warning\":true}'",
+
+		"inspect_revision_tags": "Optional: namespace (string, default: all namespaces with injection labels)\n  Example: --args '{\"namespace\":\"default\"}'",
+
+		"audit_discovery_selectors": "Optional: namespace (string, default: \"istio-system\"), revision (string)\n  Example: --args '{}'",
+
+		"configure_discovery_selectors": "Required: one of namespaces ([]string), selectors ([]object) or clear (bool)\n  Optional: namespace (string, default: \"istio-system\"), revision (string), release (string, default: \"istiod\"), dry_run (bool), timeout (string, default: \"5m\"), repo_url (string), force (bool)\n  Example: --args '{\"namespaces\":[\"bookinfo\",\"istio-ingress\"],\"dry_run\":true}'\n  Example: --args '{\"selectors\":[{\"matchLabels\":{\"istio-discovery\":\"enabled\"}}],\"force\":true}'",
+
+		"audit_istio_resources": "Optional: namespace (string, default: all namespaces), cluster_domain (string, default: \"cluster.local\")\n  Example: --args '{}'\n  Example: --args '{\"namespace\":\"bookinfo\"}'",
+
+		"istio_analyze": "Optional: namespace (string, default: all namespaces), istio_namespace (string, default: \"istio-system\"), cluster_domain (string, default: \"cluster.local\"), min_level (string: Error|Warning|Info, default: \"Info\"), suppress ([]string, message codes)\n  Example: --args '{}'\n  Example: --args '{\"namespace\":\"bookinfo\",\"min_level\":\"Warning\",\"suppress\":[\"IST0118\"]}'",
+
+		"get_injection_config": "Optional: namespace (string, default: \"istio-system\"), revision (string), template (string)\n  Example: --args '{\"template\":\"sidecar\"}'",
+
+		"set_injection_template": "Required: name with template or remove, default_templates ([]string) or values (object)\n  Optional: preview_namespace (string), namespace (string, default: \"istio-system\"), revision (string), release (string, default: \"istiod\"), dry_run (bool), timeout (string, default: \"5m\"), repo_url (string), force (bool)\n  Example: --args '{\"name\":\"prestop\",\"template\":\"spec:\\n  containers:\\n  - name: istio-proxy\\n    lifecycle:\\n      preStop:\\n        exec:\\n          command: [\\\"sleep\\\", \\\"10\\\"]\",\"preview_namespace\":\"default\",\"force\":true}'\n  Example: --args '{\"values\":{\"global.proxy.holdApplicationUntilProxyStarts\":true},\"dry_run\":true}'",
+
+		"preview_injection": "Optional: namespace (string, default: \"default\"), revision (string), templates ([]string), annotations (object), show_pod (bool)\n  Example: --args '{\"templates\":[\"sidecar\",\"prestop\"]}'",
+
+		"install_otel_collector": "Optional: namespace (string, default: \"observability\"), name (string, default: \"otel-collector\"), image (string), timeout (string, default: \"5m\")\n  Example: --args '{}'",
+
+		"configure_tracing": "Optional: collector_service (string, default: \"otel-collector.observability.svc.cluster.local\"), port (int, default: 4317), provider (string, default: \"otel-tracing\"), sampling (number, default: 100), istio_namespace (string, default: \"istio-system\"), release (string, default: \"istiod\"), revision (string), repo_url (string), verify (bool, default: true), namespace (string, default: \"default\"), requests (int, default: 20), timeout (string, default: \"5m\")\n  Example: --args '{\"sampling\":10}'",
+
+		"check_install_capacity": "Optional: namespace (string, default: \"istio-system\"), values (object), install_gateway (bool), gateway_namespace (string, default: \"istio-ingress\"), install_cni (bool)\n  Example: --args '{\"install_gateway\":true,\"install_cni\":true}'",
+
+		"estimate_mesh_cost": "Optional: istio_namespace (string, default: \"istio-system\"), revision (string), namespaces ([]string, default: every namespace without injection), proxy_cpu (string, default: injector values or \"100m\"), proxy_memory (string, default: injector values or \"128Mi\"), cpu_core_hour_price (number), memory_gib_hour_price (number)\n  Example: --args '{}'\n  Example: --args '{\"namespaces\":[\"shop\",\"payments\"],\"cpu_core_hour_price\":0.031,\"memory_gib_hour_price\":0.004}'",
+
+		"check_cni_chaining": "Optional: namespace (string, default: \"istio-system\"), node (string), conf_dir (string, default: \"/host/etc/cni/net.d\")\n  Example: --args '{}'\n  Example: --args '{\"node\":\"kind-worker\"}'",
+
+		"detect_cni_race": "Optional: namespace (string, default: all), node (string), cni_namespace (string, default: \"istio-system\"), check_iptables (bool), max_iptables (int, default: 5)\n  Example: --args '{}'\n  Example: --args '{\"namespace\":\"default\",\"check_iptables\":true}'",
+
+		"get_release_values": "Optional: component (string: base, istiod, cni, gateway, sail; default: \"istiod\"), release_name (string), namespace (string), computed (bool, default: false)\n  Example: --args '{\"component\":\"gateway\",\"computed\":true}'",
+
+		"list_available_istio_versions": "Optional: chart (string, default: \"istiod\"), include_prerelease (bool, default: false), limit (int, default: 20), repo_url (string)\n  Example: --args '{\"limit\":5}'",
+
+		"get_istio_release_notes": "Required: to_version (string)\n  Optional: from_version (string, default: detected), namespace (string, default: \"istio-system\"), relevant_only (bool)\n  Example: --args '{\"to_version\":\"1.24.0\",\"relevant_only\":true}'",
+
+		"install_sail_operator": "Optional: namespace (string, default: \"sail-operator\"), version (string), release_name (string, default: \"sail-operator\"), values (object), timeout (string, default: \"5m\"), repo_url (string)\n  Example: --args '{\"namespace\":\"sail-operator\",\"version\":\"1.24.0\"}'",
 
-		"uninstall_sail_operator": "Optional: namespace (string, default: \"sail-operator\"), release_name (string, default: \"sail-operator\"), timeout (string, default: \"5m\")\n  Example: --args '{\"namespace\":\"sail-operator\"}'",
+		"uninstall_sail_operator": "Optional: namespace (string, default: \"sail-operator\"), release_name (string, default: \"sail-operator\"), timeout (string, default: \"5m\"), force (bool)\n  Example: --args '{\"namespace\":\"sail-operator\"}'",
 
 		"check_sail_status": "Optional: namespace (string, default: \"sail-operator\")\n  Example: --args '{\"namespace\":\"sail-operator\"}'",
 
-		"deploy_sleep_app": "Optional: namespace (string, default: \"default\"), replicas (int, default: 1)\n  Example: --args '{\"namespace\":\"default\",\"replicas\":1}'",
+		"deploy_sleep_app": "Optional: namespace (string, default: \"default\"), replicas (int, default: 1), pin_digests (bool)\n  Example: --args '{\"namespace\":\"default\",\"replicas\":1}'",
 
-		"deploy_httpbin_app": "Optional: namespace (string, default: \"default\"), replicas (int, default: 1)\n  Example: --args '{\"namespace\":\"default\",\"replicas\":1}'",
+		"deploy_httpbin_app": "Optional: namespace (string, default: \"default\"), replicas (int, default: 1), pin_digests (bool)\n  Example: --args '{\"namespace\":\"default\",\"replicas\":1}'",
 
-		"undeploy_sleep_app": "Optional: namespace (string, default: \"default\")\n  Example: --args '{\"namespace\":\"default\"}'",
+		"undeploy_sleep_app": "Optional: namespace (string, default: \"default\"), force (bool)\n  Example: --args '{\"namespace\":\"default\"}'",
 
-		"undeploy_httpbin_app": "Optional: namespace (string, default: \"default\")\n  Example: --args '{\"namespace\":\"default\"}'",
+		"undeploy_httpbin_app": "Optional: namespace (string, default: \"default\"), force (bool)\n  Example: --args '{\"namespace\":\"default\"}'",
+
+		"deploy_bookinfo_app": "Optional: namespace (string, default: \"default\"), replicas (int, default: 1), pin_digests (bool), subsets (bool, default: true), gateway (bool), gateway_selector (string, default: \"istio=ingressgateway\"), hosts (array, default: [\"*\"])\n  Example: --args '{\"namespace\":\"bookinfo\",\"gateway\":true}'",
+
+		"undeploy_bookinfo_app": "Optional: namespace (string, default: \"default\"), force (bool)\n  Example: --args '{\"namespace\":\"bookinfo\"}'",
+
+		"apply_manifest": "Required: manifest (string, YAML or JSON, multiple documents allowed) or url (string, http or https)\n  Optional: namespace (string, default: \"default\", for namespaced objects without one), dry_run (bool), force_conflicts (bool), force (bool)\n  Example: --args '{\"url\":\"https://raw.githubusercontent.com/istio/istio/release-1.20/samples/helloworld/helloworld.yaml\",\"namespace\":\"demo\"}'",
+
+		"cleanup_demo": "Optional: namespace (string, default: all namespaces), dry_run (bool), stop_monitors (bool, default: true), force (bool)\n  Example: --args '{\"dry_run\":true}'",
+
+		"list_managed_resources": "Optional: namespace (string, default: all namespaces), tool (string)\n  Example: --args '{}'\n  Example: --args '{\"tool\":\"deploy_sleep_app\"}'",
 
 		"test_connectivity": "Required: source_pod (string), target_service (string), target_port (int)\n  Optional: source_namespace (string), protocol (string), timeout (int)\n  Example: --args '{\"source_pod\":\"sleep-xxx\",\"target_service\":\"httpbin.default.svc.cluster.local\",\"target_port\":8000}'",
 
 		"test_sleep_to_httpbin": "Optional: source_namespace (string, default: \"default\"), target_namespace (string, default: \"default\")\n  Example: --args '{\"source_namespace\":\"default\",\"target_namespace\":\"default\"}'",
 
-		"get_pod_logs": "Required: pod_name (string)\n  Optional: namespace (string), container (string), lines (int), since (string)\n  Example: --args '{\"pod_name\":\"my-pod\",\"namespace\":\"default\",\"lines\":100}'",
+		"verify_waypoint": "Required: service (string)\n  Optional: namespace (string, default: \"default\"), port (int, default: first service port), path (string, default: \"/\"), source_pod (string, default: first app=sleep pod), source_namespace (string), source_container (string), requests (int, default: 5), timeout (int, default: 5)\n  Example: --args '{\"service\":\"httpbin\",\"path\":\"/get\"}'",
+
+		"test_header_routing": "Required: service (string)\n  Optional: namespace (string, default: \"default\"), port (int, default: first service port), path (string, default: \"/\"), headers (object), cookies (object), version_header (string), requests (int, default: 10), source_pod (string, default: first app=sleep pod), source_namespace (string), source_container (string), timeout (int, default: 5)\n  Example: --args '{\"service\":\"reviews\",\"port\":9080,\"path\":\"/reviews/0\",\"headers\":{\"end-user\":\"jason\"}}'",
+
+		"generate_traffic": "Required: service (string)\n  Optional: namespace (string, default: \"default\"), port (int, default: first service port), path (string, default: \"/\"), method (string, default: \"GET\"), headers (object), rps (int, default: 5, max: 50), duration (string, default: \"30s\", max: \"5m\"), run_id (string, default: generated), header (string, default: \"x-meshpilot-run-id\"), source_pod (string, default: first app=sleep pod), source_namespace (string), source_container (string), timeout (int, default: 5)\n  Example: --args '{\"service\":\"productpage\",\"path\":\"/productpage\",\"rps\":10,\"duration\":\"1m\"}'",
+
+		"configure_egress_routing": "Required: hosts ([]string)\n  Optional: protocol (string: tls|http, default: \"tls\"), namespace (string, default: \"default\"), name (string, default: \"egress\"), gateway_namespace (string, default: \"istio-system\"), gateway_service (string, default: \"istio-egressgateway\"), gateway_selector (string, default: \"istio=egressgateway\"), install_gateway (bool), istio_namespace (string, default: \"istio-system\"), revision (string), repo_url (string), verify (bool, default: true), source_pod (string, default: first app=sleep pod), source_namespace (string), timeout (string, default: \"5m\")\n  Example: --args '{\"hosts\":[\"edition.cnn.com\"]}'\n  Example: --args '{\"hosts\":[\"httpbin.org\"],\"protocol\":\"http\",\"install_gateway\":true}'",
+
+		"run_mesh_conformance": "Optional: namespace (string, default: \"meshpilot-conformance\"), scenarios ([]string: baseline_http|header_routing|fault_abort|fault_delay|request_timeout|retries|mtls_strict|authorization_deny, default: all), keep (bool), timeout (string, default: \"5m\")\n  Example: --args '{}'\n  Example: --args '{\"scenarios\":[\"retries\",\"mtls_strict\"]}'",
+
+		"test_ingress_connectivity": "Optional: gateway_namespace (string, default: \"istio-ingress\"), gateway_service (string, default: \"istio-ingress\"), host (string), path (string, default: \"/\"), port (int, default: 80), timeout (int, default: 10)\n  Example: --args '{\"host\":\"httpbin.example.com\",\"path\":\"/get\"}'",
+
+		"test_gateway_paths": "Optional: gateway_namespace (string, default: \"istio-ingress\"), gateway_service (string, default: \"istio-ingress\"), host (string), path (string, default: \"/\"), port (int, default: 80), source_pod (string, default: first app=sleep pod), source_namespace (string, default: \"default\"), timeout (int, default: 5)\n  Example: --args '{\"host\":\"httpbin.example.com\",\"path\":\"/headers\"}'",
+
+		"sweep_service_ports": "Optional: namespace (string, default: \"default\"), services ([]string, default: all services), source_pod (string, default: first app=sleep pod), source_namespace (string), source_container (string), timeout (int, default: 3)\n  Example: --args '{\"namespace\":\"bookinfo\",\"source_namespace\":\"default\"}'\n  Example: --args '{\"services\":[\"httpbin\"]}'",
+
+		"probe_gateway_tls": "Optional: gateway_namespace (string, default: \"istio-ingress\"), gateway_service (string, default: \"istio-ingress\"), port (int, default: 443), sni (array, default: configured hosts plus no SNI), alpn (array, default: [\"h2,http/1.1\"]), hosts (array, default: same as SNI), path (string, default: \"/\"), timeout (int, default: 10)\n  Example: --args '{\"sni\":[\"httpbin.example.com\"],\"alpn\":[\"h2\",\"http/1.1\"]}'",
+
+		"diagnose_ingress_request": "Required: host (string)\n  Optional: path (string, default: \"/\"), gateway_namespace (string, default: \"istio-ingress\"), gateway_service (string, default: \"istio-ingress\"), port (int, default: 80, 443 with https), https (bool), istio_namespace (string, default: \"istio-system\"), send_request (bool, default: true), since (int, default: 600), timeout (int, default: 10)\n  Example: --args '{\"host\":\"httpbin.example.com\",\"path\":\"/status/200\"}'",
+
+		"benchmark_mesh_overhead": "Optional: namespace (string, default: \"meshpilot-bench\"), qps (int, default: 1000), connections (int, default: 16), duration (string, default: \"30s\"), payload_bytes (int), keep_resources (bool)\n  Example: --args '{\"qps\":500,\"duration\":\"60s\"}'",
+
+		"start_monitor": "Required: name (string), endpoints (array of http(s):// or tcp:// URLs)\n  Optional: source_namespace (string, default: \"default\"), source_pod (string), source_selector (string, default: \"app=sleep\"), container (string, default: \"sleep\"), interval (string, default: \"30s\"), timeout (int, default: 5), retention (string, default: \"2h\")\n  Example: --args '{\"name\":\"httpbin\",\"endpoints\":[\"http://httpbin.default:8000/get\"],\"interval\":\"10s\"}'",
+
+		"stop_monitor": "Required: name (string)\n  Optional: forget (bool, default: false)\n  Example: --args '{\"name\":\"httpbin\"}'",
+
+		"get_monitor_results": "Optional: name (string, default: all monitors), window (string, default: \"30m\")\n  Example: --args '{\"name\":\"httpbin\",\"window\":\"30m\"}'",
+
+		"get_scheduled_results": "Optional: name (string, default: all schedules), limit (int, default: 5), failures_only (bool), include_output (bool)\n  Example: --args '{\"name\":\"istio-health\",\"failures_only\":true}'",
+
+		"traffic_shift": "Required: service (string), weight (int, 0-100: percent sent to the to subset)\n  Optional: namespace (string, default: \"default\"), from (string, default: \"v1\"), to (string, default: \"v2\"), subset_label (string, default: \"version\"), dry_run (bool)\n  Example: --args '{\"service\":\"reviews\",\"weight\":20}'\n  Example: --args '{\"service\":\"reviews\",\"from\":\"v2\",\"to\":\"v3\",\"weight\":100}'",
+
+		"create_virtual_service": "Required: name (string), and hosts ([]string) with routes ([]{host, subset, port, weight}) or spec (object)\n  Optional: namespace (string, default: \"default\"), gateways ([]string), timeout (string), spec (object), dry_run (bool)\n  Example: --args '{\"name\":\"reviews\",\"hosts\":[\"reviews\"],\"routes\":[{\"host\":\"reviews\",\"subset\":\"v1\",\"weight\":90},{\"host\":\"reviews\",\"subset\":\"v2\",\"weight\":10}]}'",
+
+		"get_virtual_service": "Optional: name (string, default: all in the namespace), namespace (string, default: \"default\")\n  Example: --args '{\"name\":\"reviews\"}'",
+
+		"delete_virtual_service": "Required: name (string)\n  Optional: namespace (string, default: \"default\"), dry_run (bool), force (bool)\n  Example: --args '{\"name\":\"reviews\"}'",
+
+		"create_destination_rule": "Required: name (string), and host (string) or spec (object)\n  Optional: namespace (string, default: \"default\"), subsets ([]{name, labels}), load_balancer (string: ROUND_ROBIN|LEAST_REQUEST|RANDOM|PASSTHROUGH), tls_mode (string: DISABLE|SIMPLE|MUTUAL|ISTIO_MUTUAL), traffic_policy (object), spec (object), dry_run (bool)\n  Example: --args '{\"name\":\"reviews\",\"host\":\"reviews\",\"subsets\":[{\"name\":\"v1\",\"labels\":{\"version\":\"v1\"}},{\"name\":\"v2\",\"labels\":{\"version\":\"v2\"}}]}'",
+
+		"get_destination_rule": "Optional: name (string, default: all in the namespace), namespace (string, default: \"default\")\n  Example: --args '{\"name\":\"reviews\"}'",
+
+		"delete_destination_rule": "Required: name (string)\n  Optional: namespace (string, default: \"default\"), dry_run (bool), force (bool)\n  Example: --args '{\"name\":\"reviews\"}'",
+
+		"detect_connection_pool_exhaustion": "Optional: namespace (string, default: \"default\"), selector (string)\n  Example: --args '{\"namespace\":\"bookinfo\",\"selector\":\"app=productpage\"}'",
+
+		"expose_service_via_gateway": "Required: service (string)\n  Optional: namespace (string, default: \"default\"), port (int, default: http or only service port), host (string, default: \"*\"), path (string, default: \"/\"), name (string, default: service), api (string: istio|gateway_api, default: \"istio\"), gateway_namespace (string, default: \"istio-ingress\"), gateway_service (string, default: \"istio-ingress\"), gateway_class (string, default: \"istio\"), tls (bool), credential_name (string, default: self-signed), verify (bool, default: true), timeout (string, default: \"2m\")\n  Example: --args '{\"service\":\"httpbin\"}'\n  Example: --args '{\"service\":\"httpbin\",\"host\":\"httpbin.example.com\",\"tls\":true,\"api\":\"gateway_api\"}'",
+		"create_gateway_route":       "Required: name (string), and backends ([]{name, namespace, port, weight}) or spec (object)\n  Optional: namespace (string, default: \"default\"), kind (string: HTTPRoute|GRPCRoute, default: \"HTTPRoute\"), gateway (string, default: \"istio-ingress\"), gateway_namespace (string, default: \"istio-ingress\"), section_name (string), hostnames ([]string), path (string, default: \"/\"), timeout (string), grpc_service (string), spec (object), dry_run (bool)\n  Example: --args '{\"name\":\"httpbin\",\"hostnames\":[\"httpbin.example.com\"],\"backends\":[{\"name\":\"httpbin\",\"port\":8000}]}'\n  Example: --args '{\"name\":\"greeter\",\"kind\":\"GRPCRoute\",\"grpc_service\":\"helloworld.Greeter\",\"backends\":[{\"name\":\"greeter\",\"port\":50051}]}'",
+		"get_gateway_routes":         "Optional: name (string, default: all in the namespace), namespace (string, default: \"default\"), kind (string: HTTPRoute|GRPCRoute, default: both)\n  Example: --args '{\"name\":\"httpbin\"}'",
+		"delete_gateway_route":       "Required: name (string)\n  Optional: namespace (string, default: \"default\"), kind (string: HTTPRoute|GRPCRoute, default: \"HTTPRoute\"), dry_run (bool), force (bool)\n  Example: --args '{\"name\":\"httpbin\"}'",
+		"get_gateway_status":         "Optional: name (string, default: every Gateway), namespace (string, default: all namespaces)\n  Example: --args '{\"namespace\":\"istio-ingress\"}'",
+
+		"get_pod_logs": "Required: pod_name (string)\n  Optional: namespace (string), container (string), lines (int), since (string), follow (bool), duration (string, default: \"1m\", max: \"10m\"), max_lines (int, default: 1000)\n  Example: --args '{\"pod_name\":\"my-pod\",\"namespace\":\"default\",\"lines\":100}'\n  Example: --args '{\"pod_name\":\"my-pod\",\"follow\":true,\"duration\":\"5m\"}'",
 
 		"get_istio_proxy_logs": "Required: pod_name (string)\n  Optional: namespace (string), lines (int), since (string)\n  Example: --args '{\"pod_name\":\"my-pod\",\"namespace\":\"default\"}'",
 
-		"exec_pod_command": "Required: pod_name (string), command (array of strings)\n  Optional: namespace (string), container (string)\n  Example: --args '{\"pod_name\":\"my-pod\",\"command\":[\"ls\",\"-la\"]}'",
+		"get_istiod_logs": "Optional: scopes (array: ads|validation|injection|...), level (string: debug|info|warn|error, default: info), istio_namespace (string, default: \"istio-system\"), revision (string), pod_name (string), since (string, default: \"1h\"), lines (int, default: 2000), max_lines (int, default: 200)\n  Example: --args '{\"scopes\":[\"ads\"],\"level\":\"warn\"}'\n  Example: --args '{\"scopes\":[\"injection\",\"validation\"],\"since\":\"30m\"}'",
+
+		"analyze_response_flags": "Optional: namespace (string, default: \"default\"), selector (string), since (string, default: \"10m\"), istio_namespace (string, default: \"istio-system\")\n  Example: --args '{\"namespace\":\"bookinfo\",\"since\":\"1h\"}'",
+
+		"get_kubernetes_events": "Optional: namespace (string, default: \"default\"), pod_name (string), kind (string), name (string), component (string: istiod, gateway, ztunnel, cni), istio_namespace (string, default: \"istio-system\"), type (string: Warning, Normal), since (string), max_events (int, default: 100)\n  Example: --args '{\"component\":\"istiod\",\"type\":\"Warning\"}'\n  Example: --args '{\"namespace\":\"bookinfo\",\"pod_name\":\"productpage-v1-abc\",\"since\":\"1h\"}'",
+
+		"exec_pod_command": "Required: pod_name (string), command (array of strings)\n  Optional: namespace (string), container (string), force (bool)\n  Example: --args '{\"pod_name\":\"my-pod\",\"command\":[\"ls\",\"-la\"]}'",
+
+		"get_iptables_rules": "Required: pod_name (string)\n  Optional: namespace (string), container (string), tables (array), verbose (bool), image (string, default: debug.image or \"istio/base\"), profile (string: sysadmin|netadmin, default: debug.profile or sysadmin)\n  Example: --args '{\"pod_name\":\"my-pod\",\"namespace\":\"default\"}'",
+
+		"capture_packets": "Required: pod_name (string)\n  Optional: namespace (string, default: \"default\"), filter (string, BPF), duration (int, seconds, default: 10, max: 120), interface (string, default: \"any\"), snap_length (int, default: 262144), max_packets (int, default: 5000), image (string, default: debug.image), profile (string: netadmin|sysadmin, default: debug.profile or netadmin), output (string: base64|resource, default: \"base64\")\n  Example: --args '{\"pod_name\":\"productpage-v1-abc\",\"namespace\":\"bookinfo\",\"filter\":\"tcp port 9080\",\"duration\":15}'",
+
+		"get_interception_mode": "Optional: namespace (string, default: \"default\"), pod_name (string)\n  Example: --args '{\"namespace\":\"default\"}'",
+
+		"configure_traffic_exclusions": "Required: deployment (string)\n  Optional: namespace (string, default: \"default\"), exclude_inbound_ports (array of int), exclude_outbound_ports (array of int), exclude_outbound_ip_ranges (array), replace (bool, default: false), verify (bool, default: true), timeout (string, default: \"5m\")\n  Example: --args '{\"deployment\":\"httpbin\",\"exclude_outbound_ports\":[3306],\"exclude_outbound_ip_ranges\":[\"169.254.169.254/32\"]}'",
+
+		"configure_dns_proxying": "Optional: dns_capture (bool, default: true), auto_allocate (bool, default: true), namespace (string, default: \"default\"), deployments ([]string, default: mesh-wide), source_deployment (string, default: \"sleep\"), istio_namespace (string, default: \"istio-system\"), release (string, default: \"istiod\"), revision (string), repo_url (string), verify (bool, default: true), dry_run (bool), timeout (string, default: \"5m\")\n  Example: --args '{\"dry_run\":true}'\n  Example: --args '{\"deployments\":[\"sleep\"],\"auto_allocate\":false}'",
+
+		"tune_proxy": "Optional: concurrency (int, 0 = all cores), cpu_request (string), cpu_limit (string), memory_request (string), memory_limit (string), stats_inclusion_prefixes ([]string), stats_inclusion_regexps ([]string), namespace (string, default: \"default\"), deployments ([]string, default: mesh-wide), istio_namespace (string, default: \"istio-system\"), release (string, default: \"istiod\"), revision (string), repo_url (string), measure (bool, default: true), benchmark_namespace (string, default: \"meshpilot-bench\"), qps (int, default: 1000), connections (int, default: 16), duration (string, default: \"30s\"), dry_run (bool), timeout (string, default: \"5m\")\n  Example: --args '{\"concurrency\":2,\"cpu_limit\":\"2\",\"memory_limit\":\"512Mi\"}'\n  Example: --args '{\"deployments\":[\"productpage\"],\"namespace\":\"bookinfo\",\"concurrency\":1,\"stats_inclusion_prefixes\":[\"cluster.outbound\"]}'",
+
+		"audit_sidecar_startup": "Optional: namespace (string, default: all namespaces), fix (string: \"hold\" or \"native\"), workloads ([]string, Kind/name, default: mesh-wide; requires namespace), istio_namespace (string, default: \"istio-system\"), release (string, default: \"istiod\"), revision (string), repo_url (string), dry_run (bool), timeout (string, default: \"5m\")\n  Example: --args '{}'\n  Example: --args '{\"fix\":\"hold\",\"namespace\":\"default\",\"workloads\":[\"Deployment/reviews\",\"CronJob/report\"]}'",
+
+		"diagnose_job_sidecars": "Optional: namespace (string, default: all namespaces), job (string; requires namespace), apply (string: \"quitquitquit\", \"native\" or \"exclude\"), istio_namespace (string, default: \"istio-system\"), revision (string), dry_run (bool)\n  Example: --args '{}'\n  Example: --args '{\"namespace\":\"batch\",\"apply\":\"quitquitquit\"}'",
+
+		"inspect_sidecar_annotations": "Required: pod_name (string) OR deployment (string)\n  Optional: namespace (string, default: \"default\")\n  Example: --args '{\"deployment\":\"httpbin\"}'",
+
+		"detect_dataplane_mode": "Optional: namespace (string, default: all non-system namespaces), include_system (bool)\n  Example: --args '{}'",
+
+		"get_proxy_config": "Required: pod_name (string)\n  Optional: namespace (string, default: \"default\"), type (string: clusters|listeners|routes|endpoints|all, default: clusters), fqdn (string), port (int), direction (string: inbound|outbound), subset (string), raw (bool)\n  Example: --args '{\"pod_name\":\"productpage-v1-xxx\",\"fqdn\":\"reviews.default.svc.cluster.local\"}'\n  Example: --args '{\"pod_name\":\"productpage-v1-xxx\",\"type\":\"endpoints\",\"port\":9080}'",
+
+		"envoy_admin_get": "Required: pod_name (string), endpoint (string: /stats|/clusters|/config_dump|/certs|/listeners)\n  Optional: namespace (string, default: \"default\"), query (object), max_bytes (int, default: 65536, max: 1048576)\n  Example: --args '{\"pod_name\":\"productpage-v1-xxx\",\"endpoint\":\"/stats\",\"query\":{\"filter\":\"upstream_rq_5xx\"}}'\n  Example: --args '{\"pod_name\":\"productpage-v1-xxx\",\"endpoint\":\"/config_dump\",\"query\":{\"resource\":\"dynamic_active_clusters\"},\"max_bytes\":262144}'",
+
+		"get_ztunnel_config": "Optional: node (string) or pod_name (string) with namespace (string, default: \"default\"), section (string: summary|workloads|services|policies|certificates|all, default: summary), filter (string), local_only (bool)\n  Example: --args '{\"node\":\"worker-1\",\"section\":\"certificates\"}'",
 
-		"get_iptables_rules": "Required: pod_name (string)\n  Optional: namespace (string), container (string), tables (array), verbose (bool)\n  Example: --args '{\"pod_name\":\"my-pod\",\"namespace\":\"default\"}'",
+		"get_network_policies": "Optional: namespace (string, default: all namespaces, or \"default\" with pod_name or analyze), pod_name (string), label_selector (string)\n  Analyze mode: analyze (bool), source_pod (string), source_namespace (string), destination_pod (string) or destination_service (string), destination_namespace (string), port (int), protocol (string, default: TCP)\n  Example: --args '{}'\n  Example: --args '{\"namespace\":\"default\"}'\n  Example: --args '{\"analyze\":true,\"source_pod\":\"sleep-xxx\",\"destination_service\":\"httpbin\",\"port\":8000}'",
 
-		"get_network_policies": "Optional: namespace (string, default: \"default\"), pod_name (string)\n  Example: --args '{\"namespace\":\"default\"}'",
+		"generate_network_policy": "Required: app (string) OR pod_selector (string)\n  Optional: namespace (string, default: \"default\"), from (observed|intents|both), intents (array of {direction, peer_namespace, peer_selector, cidr, port, protocol}), since (string, default: 1h), include_dns (bool, default: true), policy_name (string), apply (bool, default: false)\n  Example: --args '{\"app\":\"httpbin\"}'\n  Example: --args '{\"app\":\"httpbin\",\"intents\":[{\"direction\":\"ingress\",\"peer_selector\":\"app=sleep\",\"port\":\"8080\"}]}'",
 
 		"trace_network_path": "Required: source_pod (string), target_host OR target_pod (string)\n  Optional: source_namespace, target_namespace (string), max_hops (int)\n  Example: --args '{\"source_pod\":\"sleep-xxx\",\"target_host\":\"httpbin.default.svc.cluster.local\"}'",
+
+		"diagnose_pod_node_network": "Required: pod_name (string)\n  Optional: namespace (string, default: \"default\"), image (string, default: debug.image), debug_namespace (string, default: \"default\")\n  Example: --args '{\"pod_name\":\"reviews-v1-abc\",\"namespace\":\"bookinfo\"}'",
+
+		"validate_dual_stack": "Optional: namespace (string, default: all namespaces), istio_namespace (string, default: \"istio-system\"), revision (string), service (string, probed over each family), service_namespace (string, default: namespace or \"default\"), port (int, default: first service port), path (string, default: \"/\"), source_pod (string, default: first app=sleep pod), source_namespace (string, default: service_namespace)\n  Example: --args '{}'\n  Example: --args '{\"service\":\"httpbin\",\"service_namespace\":\"default\"}'",
+
+		"check_clock_skew": "Optional: nodes ([]string, default: all nodes), max_skew (string, default: \"1s\"), istio_namespace (string, default: \"istio-system\"), image (string, default: debug.image), debug_namespace (string, default: \"default\")\n  Example: --args '{}'\n  Example: --args '{\"max_skew\":\"500ms\"}'",
+
+		"scan_mesh_images": "Optional: components (array, default: all), scanner (string, default: \"trivy\"), severities (array), timeout_seconds (int, default: 300)\n  Example: --args '{\"components\":[\"istiod\",\"gateway\"],\"severities\":[\"CRITICAL\",\"HIGH\"]}'",
+
+		"setup_ext_authz": "Optional: namespace (string, default: \"default\"), workload (string, default: \"httpbin\"), provider (string), protocol (string: http|grpc, default: http), paths ([]string, default: all), istio_namespace (string, default: \"istio-system\"), release (string, default: \"istiod\"), revision (string), repo_url (string), verify (bool, default: true), timeout (string, default: \"5m\")\n  Example: --args '{}'\n  Example: --args '{\"paths\":[\"/headers\"],\"protocol\":\"grpc\"}'",
+
+		"test_ext_authz": "Optional: namespace (string, default: \"default\"), service (string, default: \"httpbin\"), port (int), path (string, default: \"/headers\"), provider (string, default: \"sample-ext-authz-http\"), source_pod (string), source_namespace (string), istio_namespace (string, default: \"istio-system\"), revision (string), timeout (int, default: 30)\n  Example: --args '{}'",
+
+		"install_spire": "Optional: namespace (string, default: \"spire-mgmt\"), server_namespace (string, default: \"spire-server\"), agent_namespace (string, default: \"spire-system\"), trust_domain (string, default: \"cluster.local\"), cluster_name (string, default: \"meshpilot\"), version (string), values (object), timeout (string, default: \"5m\"), repo_url (string)\n  Example: --args '{}'",
+
+		"configure_istio_spire": "Optional: trust_domain (string, default: \"cluster.local\"), socket_file (string, default: \"spire-agent.sock\"), namespace (string, default: \"default\"), deployments ([]string), istio_namespace (string, default: \"istio-system\"), release (string, default: \"istiod\"), revision (string), repo_url (string), timeout (string, default: \"5m\")\n  Example: --args '{\"deployments\":[\"sleep\",\"httpbin\"]}'",
+
+		"verify_spire_identities": "Optional: namespace (string, default: \"default\"), pod_name (string), trust_domain (string, default: mesh trust domain), istio_namespace (string, default: \"istio-system\"), revision (string)\n  Example: --args '{\"namespace\":\"default\"}'",
+
+		"set_mtls_mode": "Required: mode (string: STRICT|PERMISSIVE|DISABLE|UNSET)\n  Optional: namespace (string, default: mesh root namespace), selector (object), port_level (object), name (string), istio_namespace (string, default: \"istio-system\"), dry_run (bool)\n  Example: --args '{\"mode\":\"STRICT\"}'\n  Example: --args '{\"mode\":\"PERMISSIVE\",\"namespace\":\"legacy\"}'\n  Example: --args '{\"mode\":\"STRICT\",\"namespace\":\"default\",\"selector\":{\"app\":\"httpbin\"},\"port_level\":{\"8080\":\"PERMISSIVE\"}}'",
+
+		"get_mtls_status": "Optional: namespace (string, default: all namespaces), istio_namespace (string, default: \"istio-system\")\n  Example: --args '{\"namespace\":\"default\"}'",
+
+		"get_workload_certificates": "Optional: namespace (string, default: \"default\"), pod_name (string), source (string: proxy|gateway_secrets, default: \"proxy\"), warn_days (int, default: 30)\n  Example: --args '{\"namespace\":\"default\"}'\n  Example: --args '{\"namespace\":\"istio-ingress\",\"source\":\"gateway_secrets\",\"warn_days\":14}'",
+
+		"inspect_trust_domain": "Optional: namespace (string, default: \"default\"), pod_name (string), istio_namespace (string, default: \"istio-system\"), revision (string), contexts ([]string)\n  Example: --args '{\"namespace\":\"bookinfo\"}'\n  Example: --args '{\"contexts\":[\"kind-east\",\"kind-west\"]}'",
+
+		"check_mtls_between": "Required: source_pod (string), and destination_pod (string) or destination_service (string)\n  Optional: source_namespace (string, default: namespace), destination_namespace (string, default: namespace), namespace (string, default: \"default\"), port (int, pod port or service port), istio_namespace (string, default: \"istio-system\"), capture (bool), duration (int, seconds, default: 10), image (string), profile (string: netadmin|sysadmin)\n  Example: --args '{\"source_pod\":\"sleep-7d8f9c\",\"destination_service\":\"httpbin\",\"port\":8000}'\n  Example: --args '{\"source_pod\":\"sleep-7d8f9c\",\"destination_service\":\"httpbin\",\"capture\":true,\"duration\":15}'",
+
+		"create_authorization_policy": "Required: name (string), and rules ([]object) or spec (object)\n  Optional: namespace (string, default: \"default\"), action (string: ALLOW|DENY|AUDIT|CUSTOM, default: \"ALLOW\"), selector (object), provider (string), spec (object), dry_run (bool)\n  Example: --args '{\"name\":\"deny-all\",\"namespace\":\"default\",\"rules\":[]}'\n  Example: --args '{\"name\":\"httpbin-get\",\"selector\":{\"app\":\"httpbin\"},\"rules\":[{\"from\":[{\"source\":{\"namespaces\":[\"default\"]}}],\"to\":[{\"operation\":{\"methods\":[\"GET\"]}}]}]}'",
+
+		"get_authorization_policy": "Optional: name (string, default: all in the namespace), namespace (string, default: \"default\")\n  Example: --args '{\"name\":\"httpbin-get\"}'",
+
+		"delete_authorization_policy": "Required: name (string)\n  Optional: namespace (string, default: \"default\"), dry_run (bool), force (bool)\n  Example: --args '{\"name\":\"httpbin-get\"}'",
+
+		"audit_authorization_policies": "Optional: namespace (string, default: all namespaces), istio_namespace (string, default: \"istio-system\")\n  Example: --args '{\"namespace\":\"default\"}'",
+
+		"list_history": "Optional: tool (string), since (string: duration or RFC3339), errors_only (bool), limit (int, default: 20)\n  Example: --args '{\"tool\":\"check_istio_status\",\"since\":\"24h\"}'",
+
+		"get_result": "Required: id (int)\n  Example: --args '{\"id\":42}'",
+
+		"compare_with_snapshot": "Required: tool (string)\n  Optional: args (object), baseline_id (int, default: most recent matching run), older_than (string, e.g. \"24h\")\n  Example: --args '{\"tool\":\"check_istio_status\",\"older_than\":\"24h\"}'",
 	}
 
 	if params, exists := toolParams[toolName]; exists {
@@ -423,6 +798,12 @@ func showToolParameters(toolName string) {
 	} else {
 		fmt.Printf("  No parameter information available for this tool.\n")
 	}
+	if tools.AcceptsContext(toolName) {
+		fmt.Printf("  Also accepts: context (string, kubeconfig context for this call, default: current context)\n")
+	}
+	if tools.Paginated(toolName) {
+		fmt.Printf("  Paging: cursor (string, next_cursor of the previous page, with the same other arguments), page_size (int, default: 100)\n")
+	}
 }
 
 // showDetailedToolHelp shows comprehensive help for a specific tool
@@ -439,27 +820,129 @@ func showDetailedToolHelp(toolName string) {
 
 	// Tool descriptions
 	descriptions := map[string]string{
-		"list_contexts":           "Lists all available Kubernetes contexts from your kubeconfig",
-		"switch_context":          "Switches to a different Kubernetes context in your kubeconfig",
-		"get_cluster_info":        "Retrieves detailed information about the current Kubernetes cluster",
-		"install_istio":           "Installs Istio service mesh on the cluster with specified profile",
-		"uninstall_istio":         "Removes Istio service mesh from the cluster",
-		"check_istio_status":      "Checks the installation status and health of Istio components",
-		"install_sail_operator":   "Installs the Sail operator for managing Istio",
-		"uninstall_sail_operator": "Removes the Sail operator from the cluster",
-		"check_sail_status":       "Checks the status and health of the Sail operator",
-		"deploy_sleep_app":        "Deploys the sleep sample application for testing",
-		"deploy_httpbin_app":      "Deploys the httpbin sample application for testing",
-		"undeploy_sleep_app":      "Removes the sleep sample application",
-		"undeploy_httpbin_app":    "Removes the httpbin sample application",
-		"test_connectivity":       "Tests network connectivity between pods",
-		"test_sleep_to_httpbin":   "Tests connectivity from sleep pod to httpbin service",
-		"get_pod_logs":            "Retrieves logs from a specific pod and container",
-		"get_istio_proxy_logs":    "Gets Istio sidecar proxy logs from a pod",
-		"exec_pod_command":        "Executes a command inside a pod container",
-		"get_iptables_rules":      "Inspects iptables rules inside a pod (useful for debugging)",
-		"get_network_policies":    "Lists network policies affecting pods in a namespace",
-		"trace_network_path":      "Traces the network path between two pods",
+		"list_contexts":                     "Lists all available Kubernetes contexts from your kubeconfig",
+		"switch_context":                    "Switches to a different Kubernetes context in your kubeconfig and reconnects the tools to it",
+		"configure_kubeconfig":              "Validates a kubeconfig file or inline kubeconfig (saved to ~/.meshpilot/kubeconfig), optionally selects a context, sets KUBECONFIG for the process so kubectl, Helm and per-context clients follow it, reconnects and reports whether the API server answers; tool output is never stored in the history",
+		"get_cluster_info":                  "Retrieves the cluster version, nodes and namespaces, and detects the platform (EKS, GKE, AKS, OpenShift, kind, ...), node OS/arch, CNI, ingress and gateway classes, metrics-server and LoadBalancer implementation",
+		"validate_access":                   "Validates each kubeconfig context: parses the config, checks API server reachability and latency, token and client certificate expiry, and whether the credentials can list namespaces and pods",
+		"get_fleet_status":                  "Checks the clusters of the selected kubeconfig contexts in parallel and returns one row per cluster with reachability, Kubernetes version, whether Istio is installed and its version, and mesh health (healthy, degraded, not-installed), plus counts across the fleet",
+		"check_tool_permissions":            "Checks the current credentials with SelfSubjectAccessReviews and lists tools that would be Forbidden with the permissions they lack; in server mode those tools are registered as disabled",
+		"create_dev_cluster":                "Provisions a kind or minikube cluster with gateway port mappings, registers its kubeconfig context and connects the tools to it",
+		"delete_dev_cluster":                "Deletes a kind or minikube cluster created by create_dev_cluster along with its kubeconfig context",
+		"self_test":                         "Runs the toolchain end to end (optionally on a fresh kind cluster): installs Istio unless already present, deploys the sample apps, tests connectivity and runs diagnostics, then tears down what it created and reports pass/fail per stage",
+		"install_metallb":                   "Installs MetalLB and an L2 address pool, auto-detecting the docker network range on kind, so ingress gateways get a reachable external IP",
+		"install_istio":                     "Installs Istio service mesh on the cluster with Helm, in sidecar mode or, with profile ambient, with ztunnel and the CNI node agent configured for ambient",
+		"uninstall_istio":                   "Removes Istio service mesh from the cluster; istio-system and the istiod releases are protected by the guardrails, so the call needs force",
+		"istio_canary_upgrade":              "Upgrades the base chart, installs istiod-<revision> with the old release's values next to the running control plane, relabels the namespaces istio.io/rev=<revision>, restarts their sidecar workloads, waits until every proxy is injected by and connected to the new istiod, and optionally uninstalls the old revision when nothing uses it",
+		"migrate_istio_install":             "Detects whether istiod was installed by istioctl, the in-cluster operator, Helm or Sail, extracts values from the IstioOperator resource (or the running istiod, mesh config and gateways), and plans Helm releases that adopt the existing resources in place or a Sail Istio revision the namespaces move to; with execute it stops the in-cluster operator, adopts and installs each release in order, removes the IstioOperator resource and verifies the control plane",
+		"migrate_to_ambient":                "Plans, or with execute performs, a namespace-by-namespace sidecar-to-ambient migration: finds L7 features that need a waypoint and deploys one, removes the injection labels and sets istio.io/dataplane-mode=ambient, restarts the workloads, waits until every pod runs without a sidecar under ztunnel redirection, probes every service port before and after, and on any regression restores the labels, removes the created waypoint and restarts the workloads with sidecars",
+		"check_istio_status":                "Checks the installation status and health of Istio components, including the istio-cni-node and ztunnel DaemonSets",
+		"proxy_status":                      "Reads /debug/syncz and /debug/push_status from every istiod and reports, per proxy, the connected istiod and whether CDS, LDS, EDS, RDS and ECDS are synced, stale, never sent or rejected with the proxy's NACK message, plus running sidecars no istiod knows about",
+		"istiod_debug":                      "Port-forwards to the debug port of every istiod pod (optionally of one revision) and returns /debug/syncz, /debug/push_status or /debug/adsz, filtered to the proxies whose ID contains the proxy name or ends with the namespace, with counts before and after filtering",
+		"audit_istiod_flags":                "Reads the environment of the discovery container of every istiod deployment, maps PILOT_*, ENABLE_* and experimental variables to the features they control, and flags risky values, deprecated flags, compatibility flags older than the running version, enabled experimental features and flags that differ between revisions",
+		"watch_mesh_events":                 "Watches Warning events and container restart counts in the Istio namespace, the gateway namespaces and any extra namespaces for a bounded duration, folding repeated events together, and returns the timeline with the exit reason of every restart; can return early at the first warning",
+		"audit_discovery_selectors":         "Reads meshConfig.discoverySelectors from the mesh configmap, lists the namespaces inside and outside istiod's discovery scope, and flags meshed namespaces or istiod's own namespace left outside",
+		"configure_discovery_selectors":     "Previews which namespaces enter or leave istiod's discovery scope, then upgrades the istiod Helm release in place with the new meshConfig.discoverySelectors and confirms the mesh config picked them up",
+		"istio_analyze":                     "Reports istioctl analyze style messages with codes and levels: unresolved references (IST0101), conflicting mesh VirtualService hosts (IST0109) and Gateways (IST0145), gateway ports missing from the gateway Service (IST0162), subsets and policies matching no pods (IST0173, IST0127), un-injected namespaces, especially ones gateway routes send traffic to (IST0102), pods missing the proxy (IST0103) and port names Istio cannot infer the protocol from (IST0118)",
+		"audit_istio_resources":             "Resolves the gateways, hosts, subsets, selectors, TLS credentials and target references of VirtualServices, DestinationRules, Gateways, AuthorizationPolicies and PeerAuthentications against the cluster, reporting broken references and resources that have no effect",
+		"get_injection_config":              "Reads the istio-sidecar-injector configmap of a revision and reports the injection policy, default templates, built-in and custom templates, injected annotations and the global.proxy and sidecarInjectorWebhook values the templates render",
+		"set_injection_template":            "Validates a custom injection template's syntax, then adds, replaces or removes it under sidecarInjectorWebhook.templates (or sets default templates and injection values) with an in-place istiod Helm upgrade, and optionally renders a canary pod once istiod reloads the config",
+		"preview_injection":                 "Creates a canary pod with a server-side dry run so the injection webhook renders it without persisting anything, and lists the injected containers, lifecycle hooks, volume mounts, volumes and annotations",
+		"install_otel_collector":            "Deploys an OpenTelemetry collector with OTLP gRPC/HTTP receivers and a debug exporter, outside the mesh, and waits for it to become ready",
+		"configure_tracing":                 "Adds an opentelemetry extension provider with an in-place istiod Helm upgrade, applies a mesh-wide Telemetry resource with the sampling rate, then sends sleep-to-httpbin traffic until the collector's accepted span count grows",
+		"inspect_revision_tags":             "Lists istiod revisions and revision tag webhooks, resolves each namespace's istio-injection/istio.io/rev label to a control plane, and flags orphaned tags and pods running proxies from another revision",
+		"check_install_capacity":            "Checks allocatable node resources, ResourceQuotas and LimitRanges against the requests of istiod, gateways and CNI; also run automatically by install_istio",
+		"estimate_mesh_cost":                "Sums the CPU and memory requested by istiod, gateways, waypoints, ztunnel/CNI and sidecars as a share of the cluster, and projects the sidecar overhead of enabling injection in more namespaces, optionally priced per core-hour and GiB-hour",
+		"detect_cni_race":                   "Compares meshed pod start times with when the node's istio-cni agent became ready, checks istio-validation failures, repair labels and ambient redirection annotations (optionally iptables), and lists the pods to restart",
+		"check_cni_chaining":                "Reads each node's CNI configuration through the istio-cni pod, checks istio-cni is chained into the active conflist after the interface plugin with no conflicting plugins after it, and verifies the DaemonSet covers every node including newly added ones",
+		"get_release_values":                "Shows the user-supplied and computed Helm values of an installed mesh release",
+		"list_available_istio_versions":     "Lists installable Istio chart/app versions with release dates so a valid version can be passed to install_istio",
+		"get_istio_release_notes":           "Fetches upstream upgrade notes between the installed and target Istio versions and flags breaking changes that reference configuration actually in use",
+		"install_sail_operator":             "Installs the Sail operator for managing Istio",
+		"uninstall_sail_operator":           "Removes the Sail operator from the cluster",
+		"check_sail_status":                 "Checks the status and health of the Sail operator",
+		"deploy_sleep_app":                  "Deploys the sleep sample application for testing",
+		"deploy_httpbin_app":                "Deploys the httpbin sample application for testing",
+		"undeploy_sleep_app":                "Removes the sleep sample application",
+		"undeploy_httpbin_app":              "Removes the httpbin sample application",
+		"deploy_bookinfo_app":               "Deploys the Bookinfo sample application (productpage, details, ratings, reviews v1-v3) with DestinationRule version subsets and an optional ingress Gateway and VirtualService",
+		"undeploy_bookinfo_app":             "Removes the Bookinfo sample application and its DestinationRules, Gateway and VirtualService",
+		"apply_manifest":                    "Applies the objects of an inline or downloaded YAML/JSON manifest with server-side apply through the dynamic client, mapping kinds via API discovery, and reports whether each object was created, configured or unchanged",
+		"cleanup_demo":                      "Deletes the deployments, services, service accounts, config maps, network policies, Istio resources and namespaces labeled app.kubernetes.io/managed-by=meshpilot, keeping any managed namespace that holds an object of any kind without the label, stops running monitors and lists debug containers that only a pod restart removes",
+		"list_managed_resources":            "Lists the resources labeled app.kubernetes.io/managed-by=meshpilot by kind, namespace and name, grouped by the creating tool recorded in the meshpilot.io/tool label, with the creation time from the meshpilot.io/created-at annotation",
+		"test_connectivity":                 "Tests network connectivity between pods",
+		"test_sleep_to_httpbin":             "Tests connectivity from sleep pod to httpbin service",
+		"probe_gateway_tls":                 "Performs TLS handshakes against the ingress gateway with each SNI/ALPN/Host combination, reporting the served certificate, negotiated protocol, response code and the Gateway servers and routes that match",
+		"diagnose_ingress_request":          "Matches the host and path against the Gateway servers and VirtualService or HTTPRoute rules bound to the gateway, selects the Envoy route and checks its cluster health on each gateway pod, sends the request, and explains 404/503 outcomes from the status codes and response flags in the gateway access logs",
+		"verify_waypoint":                   "Sends test requests to a waypoint-bound service and checks waypoint stats and access logs to confirm L7 policy is applied there, flagging bypasses, misbound waypoints and L7 policies ztunnel cannot enforce",
+		"test_ingress_connectivity":         "Sends an HTTP request through the ingress gateway external IP (e.g. assigned by MetalLB), falling back to the node port",
+		"test_gateway_paths":                "Sends the same request to each gateway pod and the gateway ClusterIP and every node port from a source pod, and to the load balancer address from the meshpilot host, then names the innermost failing layer: mesh, kube-proxy or the external load balancer",
+		"sweep_service_ports":               "Connects to every declared port of the services in a namespace from a test pod, classifies each as listening, refused or filtered from curl's result and Envoy's upstream errors, and flags targetPorts that no selected container declares",
+		"run_mesh_conformance":              "Deploys sleep and httpbin in a sandbox namespace plus a plaintext client without a sidecar, then applies one VirtualService, PeerAuthentication or AuthorizationPolicy per scenario and checks routing, fault injection, timeouts, retries, STRICT mTLS and DENY policies behave as configured",
+		"configure_egress_routing":          "Creates a ServiceEntry for the hosts, a Gateway on the egress gateway, a DestinationRule for the gateway and per-host VirtualServices routing sidecar traffic to the gateway and gateway traffic out, then curls each host and compares gateway upstream connection counts and access logs before and after",
+		"test_header_routing":               "Sends requests with the given headers and cookies from a sleep pod, identifies the backend version that answered each one and compares it with the route the VirtualService match rules select",
+		"generate_traffic":                  "Sends requests from a source pod to a service at a fixed rate for a duration, tagging each with a run ID header and user agent, and reports response codes, latency percentiles, the time window and how to filter logs and metrics to the run",
+		"benchmark_mesh_overhead":           "Runs identical Fortio load against sidecar and no-sidecar deployments and reports added p50/p99 latency and sidecar CPU per 1000 requests",
+		"start_monitor":                     "Starts a background monitor inside the server process that probes endpoints from a pod at a fixed interval and keeps rolling results (MCP server mode only; monitors stop when the process exits)",
+		"stop_monitor":                      "Stops a connectivity monitor and returns its final summary",
+		"get_monitor_results":               "Summarizes monitor probes over a time window with per-endpoint success rate, latency and stability, answering whether connectivity has been stable",
+		"get_scheduled_results":             "Returns the recent outcomes of the read-only tools scheduled with cron expressions in the config file (MCP server mode only)",
+		"traffic_shift":                     "Adds the from and to subsets (selected by subset_label) to the service's DestinationRule and sets the default route of its VirtualService to the weight split, creating either resource when missing, and warns when a subset receiving traffic has no running pods",
+		"create_virtual_service":            "Builds a VirtualService whose default HTTP route splits traffic across the weighted destinations, or takes a full spec, validates it against the Istio API and creates it or updates the existing resource",
+		"get_virtual_service":               "Returns a VirtualService, or every VirtualService in the namespace, with its spec",
+		"delete_virtual_service":            "Deletes a VirtualService",
+		"create_destination_rule":           "Builds a DestinationRule with subsets, load balancing and TLS mode, or takes a full spec, validates it against the Istio API and creates it or updates the existing resource",
+		"get_destination_rule":              "Returns a DestinationRule, or every DestinationRule in the namespace, with its spec",
+		"delete_destination_rule":           "Deletes a DestinationRule",
+		"detect_connection_pool_exhaustion": "Reads upstream_cx_overflow, upstream_rq_pending_overflow and upstream_rq_retry_overflow from the outbound clusters of every sidecar in a namespace, resolves the DestinationRule connectionPool that applies to each cluster (subset and port-level settings included), and suggests raised tcp.maxConnections, http1MaxPendingRequests, http2MaxRequests or maxRetries limits, also flagging limits the busiest proxy is close to",
+		"expose_service_via_gateway":        "Creates an Istio Gateway selecting the ingress gateway pods and a VirtualService, or a Gateway API Gateway (which Istio deploys a gateway for) and an HTTPRoute, routing the host and path prefix to the service; with tls, serves a given secret or a provisioned self-signed certificate on port 443; returns the load balancer or node port address with a curl command and checks the route answers",
+		"create_gateway_route":              "Builds an HTTPRoute (path prefix match, optional request timeout) or GRPCRoute (optional service match) attached to a Gateway API Gateway and splitting traffic across weighted backend Services, or takes a full spec, and creates it or updates the existing route",
+		"get_gateway_routes":                "Returns HTTPRoutes and GRPCRoutes with their spec, parent references and the Accepted and ResolvedRefs conditions each parent Gateway reports, flagging routes no gateway has accepted",
+		"delete_gateway_route":              "Deletes an HTTPRoute or GRPCRoute",
+		"get_gateway_status":                "Reads the Accepted and Programmed conditions and addresses of Gateway API Gateways, the conditions and attached route counts of their listeners, and the status of every route referencing them, flagging gateways that are not programmed, conflicted listeners and routes that were not accepted or whose backends do not resolve",
+		"get_pod_logs":                      "Retrieves logs from a specific pod and container; with follow it streams new lines for up to duration (max 10m) or max_lines, as MCP progress notifications or printed as they arrive on the command line",
+		"get_istio_proxy_logs":              "Gets Istio sidecar proxy logs from a pod",
+		"get_istiod_logs":                   "Reads the discovery container logs of every istiod pod (or one revision or pod) and keeps the lines of the given scopes (ads, validation, injection, ...) at or above a level, with per-scope counts; multi-line entries such as stack traces stay with their line",
+		"analyze_response_flags":            "Reads the istio-proxy access logs of every sidecar and gateway pod in a namespace over a window, counts each Envoy response flag (NR, UH, UF, UO, URX, DC, ...) with its status codes, pods and upstream clusters, and explains each flag with its likely causes in an Istio mesh",
+		"get_kubernetes_events":             "Lists the Events of a namespace, filtered server-side by pod, involved object kind and name, or type; for an Istio component it matches the component's pods, ReplicaSets, Deployments and DaemonSets so scheduling and image pull failures show up even before a pod exists. Returns the most recent events first with Warning and Normal counts",
+		"exec_pod_command":                  "Executes a command inside a pod container",
+		"get_iptables_rules":                "Inspects iptables rules inside a pod from ephemeral debug containers, after checking that the debug image can be pulled on the pod's node, since ephemeral containers can never be removed",
+		"capture_packets":                   "Attaches an ephemeral tcpdump container (NET_ADMIN, NET_RAW) to the pod, captures for the given seconds with an optional BPF filter and returns the pcap base64-encoded, or as an embedded MCP resource over MCP; captures are capped at 5MiB",
+		"detect_dataplane_mode":             "Inspects namespace istio.io/dataplane-mode and injection labels, sidecar presence and ztunnel enrollment, and warns about namespaces left in a mixed state during ambient migration",
+		"get_proxy_config":                  "Reads the Envoy config dump of a pod's istio-proxy through its admin port and lists clusters (with direction, port, subset, FQDN and the DestinationRule behind them), listeners and their filter chain destinations, routes with the VirtualService behind them, or endpoints with health and outlier status",
+		"envoy_admin_get":                   "Port-forwards to the Envoy admin port of a pod's istio-proxy and returns one of /stats, /clusters, /config_dump, /certs or /listeners, passing only the query parameters each endpoint allows and truncating the response at max_bytes; mutating admin endpoints are never reachable",
+		"get_ztunnel_config":                "Dumps the workloads, services, authorization policies and workload certificates held by the ztunnel on a node via its admin endpoint, flagging certificates that are not ready or about to expire",
+		"inspect_sidecar_annotations":       "Lists sidecar.istio.io/* and traffic.sidecar.istio.io/* annotations on a pod or deployment template, explains their effect and flags deprecated, invalid, misspelled or conflicting ones",
+		"diagnose_job_sidecars":             "Finds running Job pods whose containers have exited while istio-proxy keeps running, and can send quitquitquit to release them or set a native sidecar annotation or sidecar.istio.io/inject=false on the owning CronJobs",
+		"audit_sidecar_startup":             "Groups sidecar pods by workload, reports whether each holds app containers until istio-proxy is ready or runs it as a native sidecar, flags app init containers, Jobs that cannot complete and app restarts, and can set holdApplicationUntilProxyStarts or ENABLE_NATIVE_SIDECARS on the istiod release or a per-workload annotation",
+		"configure_dns_proxying":            "Sets ISTIO_META_DNS_CAPTURE and ISTIO_META_DNS_AUTO_ALLOCATE in the mesh proxy metadata (istiod Helm upgrade) or in the deployments' proxy.istio.io/config annotation, restarts the affected pods and resolves a fixed-address and an address-less probe ServiceEntry host from the source before and after",
+		"tune_proxy":                        "Sets proxy concurrency and stats inclusion in meshConfig.defaultConfig and proxy resources in global.proxy.resources (istiod Helm upgrade), or in the deployments' proxy.istio.io/config and sidecar.istio.io/proxy* annotations, measuring sidecar CPU and latency of a Fortio client and server under the same load before and after",
+		"configure_traffic_exclusions":      "Sets traffic.sidecar.istio.io exclusion annotations on a deployment, waits for the rollout and checks a new pod's iptables rules for the matching RETURN rules",
+		"get_interception_mode":             "Reports per pod whether traffic is intercepted by the istio-init container, the Istio CNI plugin or ambient (ztunnel), and which debugging path applies",
+		"get_network_policies":              "Lists network policies (including Cilium and Calico policies) in a namespace or across all namespaces, grouped per namespace with the namespaces whose pods a default-deny policy isolates, or with analyze=true simulates a connection and reports which ingress/egress rule allows or denies it",
+		"generate_network_policy":           "Generates a least-privilege NetworkPolicy for an app from sidecar access logs, Envoy stats or declared intents, and diffs it against existing policies before applying",
+		"trace_network_path":                "Traces the network path between two pods",
+		"check_clock_skew":                  "Reads each node's clock from a short-lived debug pod, best of three exec round trips, and flags nodes whose clock differs from the istiod node's by more than max_skew: nodes behind reject freshly issued workload certificates, nodes ahead see certificates and tokens expire early",
+		"validate_dual_stack":               "Compares the IP families of node pod CIDRs, the service CIDR, Services and pods, checks that ISTIO_DUAL_STACK is set consistently on istiod and in the proxy metadata of a dual-stack cluster, and optionally curls a Service's ClusterIPs and pod IPs over IPv4 and IPv6 from a source pod",
+		"diagnose_pod_node_network":         "Runs a short-lived privileged host-network pod on the pod's node and checks the host route to the pod IP, the pod's host veth and bridge, ip_forward/rp_filter/bridge-nf sysctls, node iptables rules matching the pod IP, the FORWARD policy and conntrack entries, for traffic that fails although pod-level checks look clean",
+		"setup_ext_authz":                   "Deploys Istio's sample ext-authz service, adds it to meshConfig.extensionProviders with an in-place istiod Helm upgrade, applies a CUSTOM AuthorizationPolicy to the workload and checks that x-ext-authz: allow passes and deny gets 403",
+		"test_ext_authz":                    "Checks the extension provider is in the mesh config and referenced by a CUSTOM AuthorizationPolicy, then sends allowed and denied requests from a sleep pod and explains unexpected results",
+		"install_spire":                     "Installs the spire-crds and spire charts (server, agents, SPIFFE CSI driver and controller manager) and reports server, agent and CSI driver readiness",
+		"configure_istio_spire":             "Adds a spire sidecar injection template and the mesh trust domain with an in-place istiod Helm upgrade, registers a ClusterSPIFFEID with Istio's spiffe://<td>/ns/<ns>/sa/<sa> format and annotates the given deployments to use it",
+		"verify_spire_identities":           "Reads each proxy's workload certificate from its SDS secrets, compares the URI SAN with the expected SPIFFE ID, and flags SPIRE-managed pods whose certificate was still issued by istiod",
+		"set_mtls_mode":                     "Creates or updates the PeerAuthentication for the mesh root namespace, a namespace or a workload selector, updating the namespace's existing selector-less policy instead of adding a conflicting one, and warns which pods without a proxy lose access under STRICT",
+		"get_workload_certificates":         "Reads the active SDS secrets of each proxy from its config dump, like istioctl proxy-config secret, or the tls.crt/cert and ca.crt/cacert keys of the Secrets gateways reference, and reports every certificate's SANs, issuer, serial, validity window and days to expiry; workload certificates are flagged when under a quarter of their lifetime is left, since proxies rotate at half, and gateway certificates that do not cover a configured host are reported",
+		"inspect_trust_domain":              "Reads trustDomain, trustDomainAliases and the mesh ID from the mesh config and TOKEN_AUDIENCES from istiod, compares the SPIFFE ID of each proxy's workload certificate with spiffe://<trust domain>/ns/<namespace>/sa/<service account> and its istio-token audience with the accepted ones, and for each other context compares the trust domain, aliases, mesh ID and istio-ca-root-cert fingerprints",
+		"check_mtls_between":                "Resolves the destination's effective PeerAuthentication mode for the port and the DestinationRule tls mode the client applies to the service, works out what auto mTLS puts on the wire for the two dataplanes, then confirms it from the client proxy's ssl.handshake and upstream_cx_total stats and, with capture, from the share of the source's packets on the destination that are TLS records",
+		"get_mtls_status":                   "Resolves the workload, namespace and mesh PeerAuthentications (oldest wins on conflicts, UNSET inherits) into the effective mTLS mode and port overrides of every running pod, flagging pods no proxy enforces the policy for",
+		"create_authorization_policy":       "Builds an AuthorizationPolicy from an action, selector and rules, or takes a full spec, validates it against the Istio API and creates it or updates the existing resource; omitted rules are refused because an ALLOW policy without rules denies everything",
+		"get_authorization_policy":          "Returns an AuthorizationPolicy, or every AuthorizationPolicy in the namespace, with its spec",
+		"delete_authorization_policy":       "Deletes an AuthorizationPolicy",
+		"audit_authorization_policies":      "Checks each AuthorizationPolicy against the running pods and flags allow-all and deny-all policies, selectors matching no pods, ALLOW rules shadowed by a deny-all DENY policy, duplicate rules, sources in namespaces that do not exist, CUSTOM policies without a provider and L7 rules ztunnel enforces on ambient pods",
+		"scan_mesh_images":                  "Enumerates images used by istiod, gateways, ztunnel, CNI and sample apps and scans them with Trivy, returning CVE counts by severity per image",
+		"list_history":                      "Lists tool results recorded in the local history store (~/.meshpilot/history.db) with their parameters, cluster context and timestamps, for before/after comparisons and post-incident review",
+		"get_result":                        "Returns the full recorded output of a tool result listed by list_history",
+		"compare_with_snapshot":             "Re-runs a read-only tool (status checks, connectivity tests, reports) and diffs its output against a run stored in the history, separating regressions from improvements and ignoring timestamps and latencies",
 	}
 
 	if desc, exists := descriptions[toolName]; exists {
@@ -482,8 +965,17 @@ func showDetailedToolHelp(toolName string) {
 			"# Install Istio with demo profile",
 			"./meshpilot --tool install_istio",
 			"",
-			"# Install Istio with specific profile and namespace",
-			"./meshpilot --tool install_istio --args '{\"profile\":\"minimal\",\"namespace\":\"istio-system\"}'",
+			"# Install Istio in ambient mode with ztunnel",
+			"./meshpilot --tool install_istio --args '{\"profile\":\"ambient\",\"namespace\":\"istio-system\"}'",
+			"",
+			"# Install Istio with distroless images",
+			"./meshpilot --tool install_istio --args '{\"image_variant\":\"distroless\",\"install_cni\":true}'",
+			"",
+			"# Install Istio with all images pinned by digest",
+			"./meshpilot --tool install_istio --args '{\"version\":\"1.26.3\",\"pin_digests\":true}'",
+			"",
+			"# Install Istio with an ingress gateway deployed through the Gateway API",
+			"./meshpilot --tool install_istio --args '{\"install_gateway\":true,\"gateway_mode\":\"gateway_api\"}'",
 		},
 		"get_pod_logs": {
 			"# Get logs from a pod (will show error if pod_name not provided)",
@@ -491,6 +983,10 @@ func showDetailedToolHelp(toolName string) {
 			"",
 			"# Get last 50 lines from specific container",
 			"./meshpilot --tool get_pod_logs --args '{\"pod_name\":\"my-pod\",\"container\":\"app\",\"lines\":50}'",
+			"",
+			"# Read 500 lines a page of 100 at a time, passing next_cursor back with the same arguments",
+			"./meshpilot --tool get_pod_logs --args '{\"pod_name\":\"my-pod\",\"lines\":500,\"page_size\":100}'",
+			"./meshpilot --tool get_pod_logs --args '{\"pod_name\":\"my-pod\",\"lines\":500,\"page_size\":100,\"cursor\":\"<next_cursor>\"}'",
 		},
 		"deploy_sleep_app": {
 			"# Deploy sleep app in default namespace",
@@ -561,6 +1057,8 @@ func formatStructuredResult(toolName string, data interface{}) {
 		formatIstioStatus(data)
 	case "get_cluster_info":
 		formatClusterInfo(data)
+	case "get_fleet_status":
+		formatFleetStatus(data)
 	case "get_pod_logs":
 		formatPodLogs(data)
 	case "get_istio_proxy_logs":
@@ -752,9 +1250,45 @@ func formatClusterInfo(data interface{}) {
 		fmt.Printf("🖥️  Platform: %s\n", platform)
 	}
 
-	if nodeCount, exists := dataMap["node_count"]; exists {
+	if cloud, exists := dataMap["cloud_provider"]; exists {
+		fmt.Printf("☁️  Cloud Provider: %s\n", cloud)
+	}
+
+	if nodeCount, exists := dataMap["nodes"]; exists {
 		fmt.Printf("🖥️  Nodes: %v\n", nodeCount)
 	}
+	if nodePlatforms, ok := dataMap["node_platforms"].(map[string]interface{}); ok {
+		for platform, count := range nodePlatforms {
+			fmt.Printf("   • %s: %v\n", platform, count)
+		}
+	}
+	if cni, ok := dataMap["cni"].([]interface{}); ok {
+		var plugins []string
+		for _, plugin := range cni {
+			plugins = append(plugins, fmt.Sprint(plugin))
+		}
+		fmt.Printf("🔌 CNI: %s\n", strings.Join(plugins, ", "))
+	}
+	for _, field := range []struct{ key, title string }{{"ingress_classes", "Ingress Classes"}, {"gateway_classes", "Gateway Classes"}} {
+		if classes, ok := dataMap[field.key].([]interface{}); ok {
+			fmt.Printf("🚪 %s:\n", field.title)
+			for _, class := range classes {
+				if classMap, ok := class.(map[string]interface{}); ok {
+					suffix := ""
+					if classMap["default"] == true {
+						suffix = " (default)"
+					}
+					fmt.Printf("   • %s → %s%s\n", classMap["name"], classMap["controller"], suffix)
+				}
+			}
+		}
+	}
+	if metricsServer, exists := dataMap["metrics_server"]; exists {
+		fmt.Printf("📈 Metrics Server: %v\n", metricsServer)
+	}
+	if loadBalancer, exists := dataMap["load_balancer"]; exists {
+		fmt.Printf("⚖️  Load Balancer: %s\n", loadBalancer)
+	}
 
 	if namespaces, exists := dataMap["namespaces"]; exists {
 		if nsArray, ok := namespaces.([]interface{}); ok {
@@ -764,6 +1298,63 @@ func formatClusterInfo(data interface{}) {
 			}
 		}
 	}
+	if details, ok := dataMap["namespace_details"].([]interface{}); ok {
+		fmt.Printf("📂 Namespaces: %d\n", len(details))
+		for _, detail := range details {
+			if nsMap, ok := detail.(map[string]interface{}); ok {
+				fmt.Printf("   • %s: %v/%v pods running, injection: %v\n", nsMap["name"], nsMap["running_pods"], nsMap["pods"], nsMap["injection"])
+			}
+		}
+	}
+	formatNextCursor(dataMap)
+	fmt.Printf("\n")
+}
+
+// formatFleetStatus formats the fleet survey as one row per cluster
+func formatFleetStatus(data interface{}) {
+	dataMap, ok := data.(map[string]interface{})
+	if !ok {
+		fmt.Printf("📋 Fleet Status:\n%v\n", data)
+		return
+	}
+
+	fmt.Printf("🌍 Fleet Status\n")
+	fmt.Printf("═══════════════\n\n")
+
+	fmt.Printf("%-30s %-10s %-14s %-14s %s\n", "CONTEXT", "REACHABLE", "KUBERNETES", "ISTIO", "MESH HEALTH")
+	clusters, _ := dataMap["clusters"].([]interface{})
+	for _, cluster := range clusters {
+		clusterMap, ok := cluster.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name := fmt.Sprint(clusterMap["context"])
+		if clusterMap["current"] == true {
+			name += " *"
+		}
+		istio := "-"
+		if version, exists := clusterMap["istio_version"]; exists {
+			istio = fmt.Sprint(version)
+		}
+		kubernetes := "-"
+		if version, exists := clusterMap["kubernetes_version"]; exists {
+			kubernetes = fmt.Sprint(version)
+		}
+		fmt.Printf("%-30s %-10v %-14s %-14s %v\n", name, clusterMap["reachable"], kubernetes, istio, clusterMap["mesh_health"])
+	}
+
+	for _, cluster := range clusters {
+		clusterMap, _ := cluster.(map[string]interface{})
+		if issues, ok := clusterMap["issues"].([]interface{}); ok {
+			fmt.Printf("\n⚠️  %s:\n", clusterMap["context"])
+			for _, issue := range issues {
+				fmt.Printf("   • %s\n", issue)
+			}
+		}
+	}
+	if summary, ok := dataMap["summary"].(map[string]interface{}); ok {
+		fmt.Printf("\n📊 Summary: %v\n", summary)
+	}
 	fmt.Printf("\n")
 }
 
@@ -793,6 +1384,11 @@ func formatPodLogs(data interface{}) {
 	if truncated, exists := dataMap["truncated"]; exists && truncated == true {
 		fmt.Printf("⚠️  Logs truncated (showing latest entries)\n")
 	}
+	// Followed logs were printed as they arrived
+	if stoppedBy, exists := dataMap["stopped_by"]; exists {
+		fmt.Printf("⏹️  Stopped following: %v\n", stoppedBy)
+		return
+	}
 
 	// Look for raw_logs field (this is what the LogResult struct uses)
 	if rawLogs, exists := dataMap["raw_logs"]; exists {
@@ -807,6 +1403,7 @@ func formatPodLogs(data interface{}) {
 	} else {
 		fmt.Printf("\n📄 No logs available\n")
 	}
+	formatNextCursor(dataMap)
 }
 
 // formatIstioProxyLogs formats Istio proxy (Envoy) logs with enhanced readability
@@ -852,6 +1449,14 @@ func formatIstioProxyLogs(data interface{}) {
 		fmt.Printf("\n📄 No proxy logs available\n")
 		fmt.Printf("💡 Tip: Ensure the pod has Istio sidecar injection enabled\n")
 	}
+	formatNextCursor(dataMap)
+}
+
+// formatNextCursor tells how to get the next page of a paginated result
+func formatNextCursor(dataMap map[string]interface{}) {
+	if cursor, exists := dataMap["next_cursor"]; exists {
+		fmt.Printf("\n➡️  More results: repeat with the same arguments and \"cursor\":\"%v\"\n", cursor)
+	}
 }
 
 // processEnvoyLogs processes raw Envoy logs to highlight important information